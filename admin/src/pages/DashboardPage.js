@@ -1,18 +1,21 @@
-import { computed } from 'vue'
+import { computed, onMounted } from 'vue'
 import { useRouter } from 'vue-router'
+import { client } from '../client.js'
 import { useHealthStore } from '../stores/health.js'
 import { useAppsStore } from '../stores/apps.js'
 import { useAliasesStore } from '../stores/aliases.js'
 import { useLogsStore } from '../stores/logs.js'
+import { useUsageStore } from '../stores/usage.js'
 import { useIcons } from '../lib/useIcons.js'
 import { usePanel } from '../lib/usePanel.js'
 import { formatBytes, formatUptime } from '../lib/format.js'
 import FPanel from '../components/FPanel.js'
 import StatCard from '../components/StatCard.js'
+import FTable from '../components/FTable.js'
 
 export default {
   name: 'DashboardPage',
-  components: { FPanel, StatCard },
+  components: { FPanel, StatCard, FTable },
   setup() {
     useIcons()
     const router = useRouter()
@@ -20,7 +23,9 @@ export default {
     const appsStore = useAppsStore()
     const aliasesStore = useAliasesStore()
     const logsStore = useLogsStore()
+    const usageStore = useUsageStore()
     const panel = usePanel('dashboard.system.collapsed', false)
+    const usagePanel = usePanel('dashboard.usage.collapsed', false)
 
     const stats = computed(() => [
       {
@@ -64,9 +69,23 @@ export default {
       },
     ])
 
+    const usageColumns = [
+      { key: 'app', label: 'App' },
+      { key: 'requests', label: 'Requests' },
+      { key: 'cpu_ms', label: 'CPU' },
+      { key: 'db', label: 'DB Reads / Writes', hideOnMobile: true },
+      { key: 'storage_bytes', label: 'Stored', hideOnMobile: true },
+      { key: 'egress_bytes', label: 'Egress', hideOnMobile: true },
+      { key: 'response_bytes', label: 'Served', hideOnMobile: true },
+    ]
+
+    const formatCPU = (ms) => (ms / 1000).toFixed(1) + 's'
+
     const navigateTo = (path) => router.push(path)
 
-    return { panel, stats, navigateTo }
+    onMounted(() => { usageStore.load(client) })
+
+    return { panel, stats, navigateTo, usagePanel, usageStore, usageColumns, formatCPU, formatBytes }
   },
   template: `
     <div class="design-system-page">
@@ -86,6 +105,36 @@ export default {
                         @click="stat.route && navigateTo(stat.route)" />
             </div>
           </FPanel>
+
+          <FPanel title="Usage" mode="content" class="mt-4"
+                  :collapsed="usagePanel.collapsed" @update:collapsed="usagePanel.toggle">
+            <template #header-actions>
+              <span class="text-caption text-faint ml-auto hide-mobile">last {{ usageStore.days }} days, approximate</span>
+            </template>
+            <FTable :columns="usageColumns" :rows="usageStore.apps" row-key="app"
+                    :loading="usageStore.loading" :clickable="false"
+                    empty-icon="gauge" empty-title="No usage yet"
+                    empty-message="Costs appear once sites serve requests">
+              <template #cell-app="{ row }">
+                <span class="text-label text-primary">{{ row.name || row.app }}</span>
+              </template>
+              <template #cell-cpu_ms="{ row }">
+                <span class="text-caption text-muted mono">{{ formatCPU(row.cpu_ms) }}</span>
+              </template>
+              <template #cell-db="{ row }">
+                <span class="text-caption text-muted mono">{{ row.db_reads }} / {{ row.db_writes }}</span>
+              </template>
+              <template #cell-storage_bytes="{ row }">
+                <span class="text-caption text-muted">{{ formatBytes(row.storage_bytes) }}</span>
+              </template>
+              <template #cell-egress_bytes="{ row }">
+                <span class="text-caption text-muted">{{ formatBytes(row.egress_bytes) }}</span>
+              </template>
+              <template #cell-response_bytes="{ row }">
+                <span class="text-caption text-muted">{{ formatBytes(row.response_bytes) }}</span>
+              </template>
+            </FTable>
+          </FPanel>
         </div>
       </div>
     </div>
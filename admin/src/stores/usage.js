@@ -0,0 +1,24 @@
+import { defineStore } from 'pinia'
+import { ref } from 'vue'
+import { useUIStore } from './ui.js'
+
+export const useUsageStore = defineStore('usage', () => {
+  const apps = ref([])
+  const days = ref(7)
+  const loading = ref(false)
+
+  async function load(client) {
+    loading.value = true
+    try {
+      const data = await client.system.usage({ days: days.value }) || {}
+      apps.value = data.apps || []
+    } catch (err) {
+      const ui = useUIStore()
+      ui.notify({ type: 'error', message: 'Failed to load usage' })
+    } finally {
+      loading.value = false
+    }
+  }
+
+  return { apps, days, loading, load }
+})
@@ -121,6 +121,10 @@ func handleAppDeploy(args []string) {
 	noBuild := flags.Bool("no-build", false, "Skip build step")
 	spaFlag := flags.Bool("spa", false, "Enable SPA routing (clean URLs)")
 	includePrivate := flags.Bool("include-private", false, "Include gitignored private/ directory")
+	analyticsFlag := flags.String("analytics", "", "Analytics snippet injection for this app: on or off (default: unchanged)")
+	noAnalytics := flags.Bool("no-analytics", false, "Same as --analytics off")
+	precompress := flags.Bool("precompress", false, "Store brotli and gzip variants of static files for compressed serving")
+	emergency := flags.Bool("emergency", false, "Deploy during a freeze window (audited)")
 
 	flags.Usage = func() {
 		// Try markdown-based help first
@@ -130,7 +134,7 @@ func handleAppDeploy(args []string) {
 			return
 		}
 		// LEGACY_CODE: migrate to cli/app/deploy.md
-		fmt.Println("Usage: fazt app deploy <directory> [--name <app>] [--no-build] [--spa] [--include-private]")
+		fmt.Println("Usage: fazt app deploy <directory> [--name <app>] [--no-build] [--spa] [--include-private] [--analytics on|off] [--precompress] [--emergency]")
 		fmt.Println("       fazt @<peer> app deploy <directory> [options]")
 		fmt.Println()
 		flags.PrintDefaults()
@@ -155,6 +159,20 @@ func handleAppDeploy(args []string) {
 
 	flags.Parse(flagArgs)
 
+	if *noAnalytics {
+		*analyticsFlag = "off"
+	}
+	var analytics *bool
+	switch *analyticsFlag {
+	case "":
+	case "on", "off":
+		on := *analyticsFlag == "on"
+		analytics = &on
+	default:
+		fmt.Printf("Error: invalid --analytics '%s' (must be 'on' or 'off')\n", *analyticsFlag)
+		os.Exit(1)
+	}
+
 	// Validate directory exists
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		fmt.Printf("Error: directory '%s' does not exist\n", dir)
@@ -275,11 +293,23 @@ func handleAppDeploy(args []string) {
 	fmt.Printf("Zipped %d files (%s)\n", zipResult.FileCount, formatSize(int64(zipResult.Buffer.Len())))
 
 	client := remote.NewClient(peer)
+	opts := &remote.DeployOptions{
+		SPA:         *spaFlag,
+		Analytics:   analytics,
+		Precompress: *precompress,
+		Emergency:   *emergency,
+	}
 	var result *remote.DeployResponse
-	if *spaFlag {
-		result, err = client.DeployWithOptions(tmpFile.Name(), name, &remote.DeployOptions{SPA: true})
+	if int64(zipResult.Buffer.Len()) > remote.ResumableThreshold {
+		// Large bundles go up in resumable chunks that survive a flaky link
+		result, err = client.DeployResumable(tmpFile.Name(), name, opts, func(sent, total int64) {
+			fmt.Printf("\rUploading: %s / %s (%d%%)", formatSize(sent), formatSize(total), sent*100/total)
+		})
+		fmt.Println()
 	} else {
-		result, err = client.Deploy(tmpFile.Name(), name)
+		// The server deploys in the background while we follow along
+		result, err = client.DeployAsync(tmpFile.Name(), name, opts, printDeployProgress)
+		fmt.Println()
 	}
 	if err != nil {
 		fmt.Printf("Error deploying: %v\n", err)
@@ -293,6 +323,56 @@ func handleAppDeploy(args []string) {
 	if *spaFlag {
 		fmt.Println("SPA:      enabled (clean URLs)")
 	}
+	if analytics != nil {
+		fmt.Printf("Analytics: injection %s\n", *analyticsFlag)
+	}
+	if *precompress {
+		fmt.Printf("Precompressed: %d files\n", result.Precompressed)
+	}
+	printDeploySecrets(result.Secrets)
+	if result.Hook != nil {
+		fmt.Printf("Hook:     %s %s", result.Hook.Handler, result.Hook.Status)
+		if result.Hook.Error != "" {
+			fmt.Printf(" (%s)", result.Hook.Error)
+		}
+		fmt.Println()
+	}
+}
+
+// printDeployProgress shows a background deploy's progress on one line
+func printDeployProgress(p remote.DeployProgress) {
+	var line string
+	switch p.Stage {
+	case "uploading":
+		line = fmt.Sprintf("Uploading: %s / %s (%d%%)", formatSize(p.Sent), formatSize(p.Total), p.Sent*100/p.Total)
+	case "writing":
+		line = fmt.Sprintf("Writing files: %d / %d", p.FilesWritten, p.FilesTotal)
+	case "finishing":
+		line = fmt.Sprintf("Finishing: %d files written", p.FilesWritten)
+	case "hook":
+		line = fmt.Sprintf("Deploy hook %s: %s", p.Hook.Handler, p.Hook.Status)
+	default:
+		line = "Waiting for the server..."
+	}
+	// Pad to clear what a longer previous line left behind
+	fmt.Printf("\r%-60s", line)
+}
+
+// printDeploySecrets warns about likely secrets the server deployed anyway
+func printDeploySecrets(secrets []remote.DeploySecret) {
+	if len(secrets) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("Warning: likely secrets were deployed and are publicly served:")
+	for _, s := range secrets {
+		if s.Line > 0 {
+			fmt.Printf("  %s:%d (%s)\n", s.Path, s.Line, s.Kind)
+		} else {
+			fmt.Printf("  %s (%s)\n", s.Path, s.Kind)
+		}
+	}
+	fmt.Println("Remove them, or move them to private/ or env vars, and deploy again.")
 }
 
 // handleAppInfo shows details about an app
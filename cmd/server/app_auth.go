@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+// handleAppAuth configures end-user sign-in for an app
+func handleAppAuth(args []string) {
+	if len(args) < 1 {
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "enable":
+		handleAppAuthEnable(args[1:])
+	case "disable":
+		handleAppAuthDisable(args[1:])
+	case "list":
+		handleAppAuthList(args[1:])
+	case "users":
+		handleAppAuthUsers(args[1:])
+	case "--help", "-h", "help":
+		printAppAuthUsage()
+	default:
+		fmt.Printf("Unknown app auth command: %s\n\n", args[0])
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+}
+
+func printAppAuthUsage() {
+	fmt.Println("Usage: fazt app auth enable <app> --provider <name> --client-id <id> --client-secret <secret>")
+	fmt.Println("       fazt app auth disable <app> --provider <name>")
+	fmt.Println("       fazt app auth list <app>")
+	fmt.Println("       fazt app auth users <app> [--remove <user_id>]")
+	fmt.Println()
+	fmt.Println("Providers: google, github, discord, microsoft")
+	fmt.Println()
+	fmt.Println("Register the OAuth client with this callback URL:")
+	fmt.Println("  https://<app-host>/auth/app/<app_id>/callback/<provider>")
+}
+
+// splitAppArg separates the leading <app> argument from flags
+func splitAppArg(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+// handleAppAuthEnable configures and enables an OAuth provider for an app
+func handleAppAuthEnable(args []string) {
+	flags := flag.NewFlagSet("app auth enable", flag.ExitOnError)
+	providerFlag := flags.String("provider", "", "OAuth provider (google, github, discord, microsoft)")
+	clientIDFlag := flags.String("client-id", "", "OAuth client ID (omit to re-enable)")
+	clientSecretFlag := flags.String("client-secret", "", "OAuth client secret")
+	flags.Usage = printAppAuthUsage
+
+	app, rest := splitAppArg(args)
+	flags.Parse(rest)
+
+	if app == "" || *providerFlag == "" {
+		fmt.Println("Error: app and --provider are required")
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+
+	var body interface{}
+	if *clientIDFlag != "" {
+		body = map[string]string{"client_id": *clientIDFlag, "client_secret": *clientSecretFlag}
+	}
+
+	var result struct {
+		Data struct {
+			AppID    string `json:"app_id"`
+			Provider string `json:"provider"`
+			Login    string `json:"login"`
+			Callback string `json:"callback"`
+		} `json:"data"`
+	}
+	appAuthRequest("PUT", app, "/auth/providers/"+url.PathEscape(*providerFlag), body, &result)
+
+	fmt.Printf("Enabled %s sign-in for %s\n", result.Data.Provider, app)
+	fmt.Printf("  Login:    %s\n", result.Data.Login)
+	fmt.Printf("  Callback: https://<app host>%s\n", result.Data.Callback)
+}
+
+// handleAppAuthDisable disables an app's OAuth provider
+func handleAppAuthDisable(args []string) {
+	flags := flag.NewFlagSet("app auth disable", flag.ExitOnError)
+	providerFlag := flags.String("provider", "", "OAuth provider to disable")
+	flags.Usage = printAppAuthUsage
+
+	app, rest := splitAppArg(args)
+	flags.Parse(rest)
+
+	if app == "" || *providerFlag == "" {
+		fmt.Println("Error: app and --provider are required")
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	appAuthRequest("DELETE", app, "/auth/providers/"+url.PathEscape(*providerFlag), nil, &result)
+
+	fmt.Printf("Disabled %s sign-in for %s\n", *providerFlag, app)
+}
+
+// handleAppAuthList shows the OAuth providers configured for an app
+func handleAppAuthList(args []string) {
+	app, _ := splitAppArg(args)
+	if app == "" {
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data struct {
+			AppID     string `json:"app_id"`
+			Providers []struct {
+				Provider  string `json:"provider"`
+				Enabled   bool   `json:"enabled"`
+				ClientID  string `json:"client_id"`
+				CreatedAt int64  `json:"created_at"`
+			} `json:"providers"`
+		} `json:"data"`
+	}
+	appAuthRequest("GET", app, "/auth", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Provider", "Enabled", "Client ID", "Configured"},
+		Rows:    [][]string{},
+	}
+	for _, p := range result.Data.Providers {
+		enabled := "no"
+		if p.Enabled {
+			enabled = "yes"
+		}
+		table.Rows = append(table.Rows, []string{
+			p.Provider, enabled, p.ClientID, time.Unix(p.CreatedAt, 0).Format("2006-01-02"),
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Sign-in: %s", app)).
+		Table(table).
+		Para(fmt.Sprintf("Login URL: /auth/app/%s/login?provider=<name>", result.Data.AppID)).
+		String(), result.Data)
+}
+
+// handleAppAuthUsers lists or removes an app's end-users
+func handleAppAuthUsers(args []string) {
+	flags := flag.NewFlagSet("app auth users", flag.ExitOnError)
+	removeFlag := flags.String("remove", "", "Remove the user with this ID")
+	flags.Usage = printAppAuthUsage
+
+	app, rest := splitAppArg(args)
+	flags.Parse(rest)
+
+	if app == "" {
+		printAppAuthUsage()
+		os.Exit(1)
+	}
+
+	if *removeFlag != "" {
+		var result map[string]interface{}
+		appAuthRequest("DELETE", app, "/auth/users/"+url.PathEscape(*removeFlag), nil, &result)
+		fmt.Printf("Removed %s from %s\n", *removeFlag, app)
+		return
+	}
+
+	var result struct {
+		Data struct {
+			Users []struct {
+				ID        string `json:"id"`
+				Email     string `json:"email"`
+				Name      string `json:"name"`
+				Provider  string `json:"provider"`
+				LastLogin *int64 `json:"last_login"`
+			} `json:"users"`
+		} `json:"data"`
+	}
+	appAuthRequest("GET", app, "/auth/users", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Email", "Name", "Provider", "Last Login"},
+		Rows:    [][]string{},
+	}
+	for _, u := range result.Data.Users {
+		lastLogin := "-"
+		if u.LastLogin != nil {
+			lastLogin = time.Unix(*u.LastLogin, 0).Format("2006-01-02 15:04")
+		}
+		table.Rows = append(table.Rows, []string{u.ID, u.Email, u.Name, u.Provider, lastLogin})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Users: %s", app)).
+		Table(table).
+		String(), result.Data)
+}
+
+// appAuthRequest calls the app auth API on the target peer and decodes the response
+func appAuthRequest(method, app, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/apps/"+url.PathEscape(app)+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
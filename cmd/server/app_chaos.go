@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/chaos"
+)
+
+func printAppChaosUsage() {
+	fmt.Println("Usage: fazt [@peer] app chaos <app> [--rate <0-1>] [--latency <ms>] [--storage] [--egress]")
+	fmt.Println("       fazt [@peer] app chaos <app> --off")
+	fmt.Println()
+	fmt.Println("Disrupts an app's storage and egress calls at random, so you can check")
+	fmt.Println("its handlers degrade gracefully. Development servers only; settings are")
+	fmt.Println("kept in memory and cleared on restart. With no flags, shows the settings")
+	fmt.Println("and how many calls they've delayed and failed.")
+	fmt.Println()
+	fmt.Println("  --rate <0-1>    Share of calls disrupted")
+	fmt.Println("  --latency <ms>  Add a random delay up to this long (max 10000)")
+	fmt.Println("  --storage       Fail disrupted fazt.app.* and fazt.storage.* calls")
+	fmt.Println("  --egress        Fail disrupted fazt.net.fetch calls")
+	fmt.Println("  --off           Turn chaos off")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @local app chaos my-app --rate 0.2 --storage --egress")
+	fmt.Println("  fazt @local app chaos my-app --rate 0.5 --latency 2000")
+	fmt.Println("  fazt @local app chaos my-app --off")
+}
+
+func handleAppChaos(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppChaosUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app chaos", flag.ExitOnError)
+	flags.Usage = printAppChaosUsage
+	rate := flags.Float64("rate", 0, "Share of calls disrupted (0-1)")
+	latency := flags.Int("latency", 0, "Max added delay in ms")
+	failStorage := flags.Bool("storage", false, "Fail disrupted storage calls")
+	failEgress := flags.Bool("egress", false, "Fail disrupted fetches")
+	off := flags.Bool("off", false, "Turn chaos off")
+	flags.Parse(args[1:])
+
+	path := "/api/apps/" + url.PathEscape(app) + "/chaos"
+	var result struct {
+		Data chaos.Status `json:"data"`
+	}
+	switch {
+	case *off:
+		peerRequest("DELETE", path, nil, &result)
+	case flags.NFlag() > 0:
+		if *rate == 0 {
+			fmt.Println("Error: --rate is required (use --off to turn chaos off)")
+			os.Exit(1)
+		}
+		cfg := chaos.Config{Rate: *rate, LatencyMS: *latency, Storage: *failStorage, Egress: *failEgress}
+		peerRequest("PUT", path, cfg, &result)
+	default:
+		peerRequest("GET", path, nil, &result)
+	}
+
+	status := result.Data
+	if !status.Enabled {
+		fmt.Printf("Chaos: off for %s\n", app)
+		return
+	}
+	var fails []string
+	if status.Storage {
+		fails = append(fails, "storage")
+	}
+	if status.Egress {
+		fails = append(fails, "egress")
+	}
+	if len(fails) == 0 {
+		fails = append(fails, "none")
+	}
+	fmt.Printf("Chaos: on for %s\n", app)
+	fmt.Printf("  Rate:     %.0f%% of calls\n", status.Rate*100)
+	fmt.Printf("  Latency:  up to %dms\n", status.LatencyMS)
+	fmt.Printf("  Fails:    %s\n", strings.Join(fails, ", "))
+	fmt.Printf("  Delayed:  %d\n", status.Delayed)
+	fmt.Printf("  Failed:   %d\n", status.Injected)
+}
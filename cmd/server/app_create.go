@@ -152,6 +152,25 @@ func handleAppCreate(args []string) {
 		os.Exit(1)
 	}
 
+	// Editor types for the serverless runtime, copied as-is: fazt.d.ts
+	// is full of braces text/template would choke on
+	typesFS := assets.GetTypes()
+	err = fs.WalkDir(typesFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(typesFS, path)
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(appName, path), content)
+	})
+
+	if err != nil {
+		fmt.Printf("Error creating app: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Success message
 	fmt.Printf("Created '%s' from '%s' template\n\n", appName, *templateName)
 
@@ -169,6 +188,8 @@ func handleAppCreate(args []string) {
 		fmt.Println("Next steps:")
 		fmt.Printf("  fazt app deploy %s --to zyt\n", appName)
 	}
+	fmt.Println()
+	fmt.Println("fazt.d.ts types the fazt.* runtime for your editor (not deployed)")
 }
 
 // isValidAppName validates an app name for subdomain use
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/handlers"
+	"github.com/fazt-sh/fazt/internal/output"
+)
+
+// handleAppDiff compares an app with the app it was forked from, or with
+// --against, on a peer
+func handleAppDiff(args []string) {
+	flags := flag.NewFlagSet("app diff", flag.ExitOnError)
+	aliasFlag := flags.String("alias", "", "Lookup by alias")
+	idFlag := flags.String("id", "", "Lookup by app ID")
+	againstFlag := flags.String("against", "", "App (ID or alias) to compare against (default: the app it was forked from)")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt app diff [<app> | --alias <alias> | --id <id>] [--against <app>]")
+		fmt.Println("       fazt @<peer> app diff [<app> | --alias <alias> | --id <id>] [--against <app>]")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	var identifier string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		identifier = args[0]
+		args = args[1:]
+	}
+	flags.Parse(args)
+
+	if *aliasFlag != "" {
+		identifier = *aliasFlag
+	}
+	if *idFlag != "" {
+		identifier = *idFlag
+	}
+	if identifier == "" {
+		fmt.Println("Error: an app (--alias or --id) is required")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	path := "/api/apps/" + url.PathEscape(identifier) + "/diff"
+	if *againstFlag != "" {
+		path += "?against=" + url.QueryEscape(*againstFlag)
+	}
+
+	var result struct {
+		Data handlers.AppDiff `json:"data"`
+	}
+	peerRequest("GET", path, nil, &result)
+	diff := result.Data
+
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"App", diffSideLabel(diff.App)},
+			{"Against", diffSideLabel(diff.Against)},
+			{"Relation", diff.Relation},
+			{"Added", strconv.Itoa(diff.Summary["added"])},
+			{"Removed", strconv.Itoa(diff.Summary["removed"])},
+			{"Modified", strconv.Itoa(diff.Summary["modified"])},
+			{"Unchanged", strconv.Itoa(diff.Summary["unchanged"])},
+		},
+	}
+
+	md := output.NewMarkdown().
+		H1(fmt.Sprintf("Diff: %s against %s", diff.App.ID, diff.Against.ID)).
+		Table(table)
+
+	if len(diff.Files) > 0 {
+		files := &output.Table{Headers: []string{"Path", "Status", "Size", "Against"}}
+		for _, f := range diff.Files {
+			size, againstSize := "-", "-"
+			if f.Status != "removed" {
+				size = formatSize(f.Size)
+			}
+			if f.Status != "added" {
+				againstSize = formatSize(f.AgainstSize)
+			}
+			files.Rows = append(files.Rows, []string{f.Path, f.Status, size, againstSize})
+		}
+		md = md.H2("Files").Table(files)
+	}
+
+	if len(diff.Metadata) > 0 {
+		meta := &output.Table{Headers: []string{"Field", diff.App.ID, diff.Against.ID}}
+		for _, m := range diff.Metadata {
+			meta.Rows = append(meta.Rows, []string{m.Field, orDash(m.App), orDash(m.Against)})
+		}
+		md = md.H2("Metadata").Table(meta)
+	}
+
+	changed := len(diff.Files) > 0 || len(diff.Metadata) > 0
+	switch {
+	case !changed:
+		md = md.Para("No differences. " + discardHint(diff))
+	case diff.Relation == handlers.RelationFork && diff.AgainstChanged:
+		md = md.Para(fmt.Sprintf("%s was updated after this fork was made; review the modified files before merging back.", diff.Against.ID))
+	case diff.Relation == handlers.RelationFork:
+		md = md.Para(fmt.Sprintf("%s is unchanged since the fork, so deploying the fork's files to it merges cleanly.", diff.Against.ID))
+	}
+
+	getRenderer().Print(md.String(), diff)
+}
+
+// diffSideLabel describes one app of a diff as "id (title)"
+func diffSideLabel(side handlers.AppDiffSide) string {
+	if side.Title == "" || side.Title == side.ID {
+		return side.ID
+	}
+	return fmt.Sprintf("%s (%s)", side.ID, side.Title)
+}
+
+// discardHint suggests deleting a fork that matches its original
+func discardHint(diff handlers.AppDiff) string {
+	if diff.Relation != handlers.RelationFork {
+		return ""
+	}
+	return fmt.Sprintf("The fork can be discarded with `fazt app remove --id %s`.", diff.App.ID)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
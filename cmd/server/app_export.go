@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+func printAppExportUsage() {
+	fmt.Println("Usage: fazt [@peer] app export <app> --static-site <dir> [--route <path>]... [--ipfs]")
+	fmt.Println()
+	fmt.Println("Writes an app out as plain files any static host can serve, so its")
+	fmt.Println("content outlives the server. The app's files are copied as they are,")
+	fmt.Println("except api/ and private/, which only the server can serve. Dynamic")
+	fmt.Println("routes, e.g. an API the pages fetch or a feed, are rendered once by")
+	fmt.Println("the server: those listed in the app's manifest.json as")
+	fmt.Println("  \"export\": {\"routes\": [\"/api/posts\", \"/feed.xml\"]}")
+	fmt.Println("and any given with --route. Each must return 200.")
+	fmt.Println()
+	fmt.Println("Pages without an extension are written to <path>/index.html. The")
+	fmt.Println("manifest's not_found page, or an SPA's index.html, is copied to 404.html.")
+	fmt.Println("Redirects, rewrites, forms and anything needing a login aren't exported.")
+	fmt.Println()
+	fmt.Println("  --static-site <dir>  Directory to write to; must be empty or not exist")
+	fmt.Println("  --route <path>       Also render this route (repeatable)")
+	fmt.Println("  --ipfs               Add the directory to IPFS with the ipfs command")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @zyt app export blog --static-site ./out")
+	fmt.Println("  fazt @zyt app export blog --static-site ./out --route /api/posts --ipfs")
+}
+
+// routeFlags collects repeated --route flags
+type routeFlags []string
+
+func (r *routeFlags) String() string {
+	return strings.Join(*r, ", ")
+}
+
+func (r *routeFlags) Set(value string) error {
+	*r = append(*r, value)
+	return nil
+}
+
+// handleAppExport handles `fazt app export`
+func handleAppExport(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppExportUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app export", flag.ExitOnError)
+	flags.Usage = printAppExportUsage
+	dir := flags.String("static-site", "", "Directory to write to")
+	var routes routeFlags
+	flags.Var(&routes, "route", "Also render this route")
+	ipfs := flags.Bool("ipfs", false, "Add the directory to IPFS")
+	flags.Parse(args[1:])
+
+	if *dir == "" {
+		fmt.Println("Error: --static-site is required")
+		printAppExportUsage()
+		os.Exit(1)
+	}
+	if entries, err := os.ReadDir(*dir); err == nil && len(entries) > 0 {
+		fmt.Printf("Error: %s isn't empty\n", *dir)
+		os.Exit(1)
+	}
+	if *ipfs {
+		if _, err := exec.LookPath("ipfs"); err != nil {
+			fmt.Println("Error: --ipfs needs the ipfs command (https://docs.ipfs.tech/install/)")
+			os.Exit(1)
+		}
+	}
+
+	db := getClientDB()
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	database.Close()
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	data, err := remote.NewClient(peer).ExportStaticSite(app, routes)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	count, size, err := writeStaticSite(*dir, data)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✓ Exported %s from %s to %s (%d files, %s)\n", app, peer.Name, *dir, count, formatBytes(size))
+
+	if *ipfs {
+		out, err := exec.Command("ipfs", "add", "-r", "-Q", "--cid-version=1", *dir).Output()
+		if err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			fmt.Printf("Error: ipfs add: %v\n", err)
+			os.Exit(1)
+		}
+		cid := strings.TrimSpace(string(out))
+		fmt.Printf("✓ Added to IPFS: %s\n", cid)
+		fmt.Printf("  https://%s.ipfs.dweb.link/\n", cid)
+		fmt.Println("  Pin it on a pinning service to keep it available while this node is off.")
+	}
+}
+
+// writeStaticSite unpacks an export into dir, returning the files and
+// bytes written
+func writeStaticSite(dir string, data []byte) (int, int64, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid export: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, 0, err
+	}
+	var size int64
+	for _, f := range zr.File {
+		if !filepath.IsLocal(f.Name) {
+			return 0, 0, fmt.Errorf("invalid export: path %q", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return 0, 0, err
+		}
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(rc)
+		rc.Close()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return 0, 0, err
+		}
+		if err := os.WriteFile(target, buf.Bytes(), 0644); err != nil {
+			return 0, 0, err
+		}
+		size += int64(buf.Len())
+	}
+	return len(zr.File), size, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+func printAppFixturesUsage() {
+	fmt.Println("Usage: fazt [@peer] app fixtures <app> [--record | --replay [--file <path>] | --off]")
+	fmt.Println("       fazt [@peer] app fixtures <app> --save <path>")
+	fmt.Println()
+	fmt.Println("Records an app's fazt.net.fetch responses on a development server and")
+	fmt.Println("replays them, so its handlers run the same every time, offline. Fixtures")
+	fmt.Println("are kept in memory and cleared on restart. With no flags, lists them.")
+	fmt.Println()
+	fmt.Println("  --record         Send fetches and keep their responses")
+	fmt.Println("  --replay         Answer fetches from fixtures; unmatched ones throw")
+	fmt.Println("  --file <path>    With --replay, use the fixtures in this file instead")
+	fmt.Println("  --save <path>    Write the fixtures to a file, e.g. " + egress.FixturesFile)
+	fmt.Println("                   in the app's directory for `fazt app test`")
+	fmt.Println("  --off            Stop recording or replaying and drop the fixtures")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @local app fixtures my-app --record")
+	fmt.Println("  fazt @local app fixtures my-app --save ./my-app/" + egress.FixturesFile)
+	fmt.Println("  fazt @local app fixtures my-app --replay")
+	fmt.Println("  fazt @local app fixtures my-app --replay --file ./my-app/" + egress.FixturesFile)
+}
+
+func handleAppFixtures(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppFixturesUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app fixtures", flag.ExitOnError)
+	flags.Usage = printAppFixturesUsage
+	record := flags.Bool("record", false, "Send fetches and keep their responses")
+	replay := flags.Bool("replay", false, "Answer fetches from fixtures")
+	file := flags.String("file", "", "Fixtures to replay")
+	save := flags.String("save", "", "Write the fixtures to a file")
+	off := flags.Bool("off", false, "Stop and drop the fixtures")
+	flags.Parse(args[1:])
+
+	path := "/api/apps/" + url.PathEscape(app) + "/fixtures"
+	var result struct {
+		Data struct {
+			Mode     string           `json:"mode"`
+			Fixtures []egress.Fixture `json:"fixtures"`
+		} `json:"data"`
+	}
+	switch {
+	case *off:
+		peerRequest("DELETE", path, nil, &result)
+	case *record:
+		peerRequest("PUT", path, map[string]interface{}{"mode": egress.FixturesRecord}, &result)
+	case *replay:
+		body := map[string]interface{}{"mode": egress.FixturesReplay}
+		if *file != "" {
+			set, err := egress.LoadFixtures(*file)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			body["fixtures"] = set.All()
+		}
+		peerRequest("PUT", path, body, &result)
+	default:
+		peerRequest("GET", path, nil, &result)
+	}
+
+	if *save != "" {
+		if err := egress.NewFixtureSet(result.Data.Fixtures).Save(*save); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Saved %d fixtures to %s\n", len(result.Data.Fixtures), *save)
+		return
+	}
+
+	mode := result.Data.Mode
+	if mode == "" {
+		mode = "off"
+	}
+	fmt.Printf("Fixtures: %s for %s (%d)\n", mode, app, len(result.Data.Fixtures))
+	for _, f := range result.Data.Fixtures {
+		fmt.Printf("  %-6s %s → %d\n", f.Method, truncate(f.URL, 70), f.Response.Status)
+	}
+}
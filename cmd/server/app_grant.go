@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func printAppGrantUsage() {
+	fmt.Println("Usage: fazt [@peer] app grant <caller> --to <app> [--scope api]")
+	fmt.Println("       fazt [@peer] app grant <caller> --to <app> --revoke [--scope <scope>]")
+	fmt.Println("       fazt [@peer] app grant <app>")
+	fmt.Println()
+	fmt.Println("Lets the caller app run another app's handlers in-process with")
+	fmt.Println("fazt.call(\"app\", \"/api/...\"), or lists the grants an app has given and")
+	fmt.Println("been given. Granting takes owner access to the app being called.")
+	fmt.Println()
+	fmt.Println("  --to <app>       The app the caller may call")
+	fmt.Println("  --scope <scope>  api for its whole API (default), or a path such as /api/reports")
+	fmt.Println("  --revoke         Remove the grant; without --scope, every grant to the caller")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @prod app grant shop --to billing")
+	fmt.Println("  fazt @prod app grant dashboard --to billing --scope /api/reports")
+	fmt.Println("  fazt @prod app grant shop --to billing --revoke")
+	fmt.Println("  fazt @prod app grant billing")
+}
+
+// handleAppGrant handles `fazt app grant`
+func handleAppGrant(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppGrantUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app grant", flag.ExitOnError)
+	flags.Usage = printAppGrantUsage
+	to := flags.String("to", "", "The app the caller may call")
+	scope := flags.String("scope", "", "api, or a path under /api/")
+	revoke := flags.Bool("revoke", false, "Remove the grant")
+	flags.Parse(args[1:])
+
+	if *to == "" {
+		if *revoke || *scope != "" {
+			fmt.Println("Error: --to is required")
+			os.Exit(1)
+		}
+		var result struct {
+			Data struct {
+				Grants []hosting.AppGrant `json:"grants"`
+			} `json:"data"`
+		}
+		peerRequest("GET", "/api/apps/"+url.PathEscape(app)+"/grants", nil, &result)
+		if len(result.Data.Grants) == 0 {
+			fmt.Printf("%s has no grants\n", app)
+			return
+		}
+		for _, g := range result.Data.Grants {
+			fmt.Printf("  %s → %s  %s\n", g.Caller, g.Target, g.Scope)
+		}
+		return
+	}
+
+	path := "/api/apps/" + url.PathEscape(*to) + "/grants"
+	if *revoke {
+		query := url.Values{"app": {app}}
+		if *scope != "" {
+			query.Set("scope", *scope)
+		}
+		var result struct {
+			Data struct {
+				Removed int `json:"removed"`
+			} `json:"data"`
+		}
+		peerRequest("DELETE", path+"?"+query.Encode(), nil, &result)
+		fmt.Printf("✓ Removed %d grant(s): %s may no longer call %s\n", result.Data.Removed, app, *to)
+		return
+	}
+
+	if *scope == "" {
+		*scope = hosting.GrantScopeAPI
+	}
+	if !hosting.IsValidGrantScope(*scope) {
+		fmt.Printf("Error: %v\n", hosting.ErrInvalidGrantScope)
+		os.Exit(1)
+	}
+	var result struct {
+		Data hosting.AppGrant `json:"data"`
+	}
+	peerRequest("PUT", path, map[string]string{"app": app, "scope": *scope}, &result)
+	fmt.Printf("✓ %s may call %s (%s) with fazt.call\n", result.Data.Caller, result.Data.Target, result.Data.Scope)
+}
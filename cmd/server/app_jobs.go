@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// handleAppJobs routes fazt app jobs subcommands
+func handleAppJobs(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			handleAppJobsList(args[1:])
+			return
+		case "show":
+			handleAppJobShow(args[1:])
+			return
+		case "cancel":
+			handleAppJobCancel(args[1:])
+			return
+		}
+	}
+	printAppJobsUsage()
+	if len(args) == 0 || (args[0] != "--help" && args[0] != "-h" && args[0] != "help") {
+		os.Exit(1)
+	}
+}
+
+func printAppJobsUsage() {
+	fmt.Println("Usage: fazt [@peer] app jobs list <app> [--status <status>] [--limit N]")
+	fmt.Println("       fazt [@peer] app jobs show <app> <job>")
+	fmt.Println("       fazt [@peer] app jobs cancel <app> <job>")
+	fmt.Println()
+	fmt.Println("list    Background jobs spawned with fazt.worker.spawn, newest first,")
+	fmt.Println("        with the progress they report through job.progress()")
+	fmt.Println("show    A job's logs (job.log) and last checkpoint (job.checkpoint)")
+	fmt.Println("cancel  Stop a pending or running job; daemons aren't restarted")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @zyt app jobs list my-app --status running")
+	fmt.Println("  fazt @zyt app jobs show my-app job_3f9a2c1b")
+	fmt.Println("  fazt @zyt app jobs cancel my-app job_3f9a2c1b")
+}
+
+func handleAppJobsList(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppJobsUsage()
+		os.Exit(1)
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app jobs list", flag.ExitOnError)
+	status := flags.String("status", "", "pending, running, done, failed or cancelled")
+	limit := flags.Int("limit", 50, "Jobs to show")
+	flags.Parse(args[1:])
+
+	query := url.Values{"limit": {strconv.Itoa(*limit)}}
+	if *status != "" {
+		query.Set("status", *status)
+	}
+
+	var result struct {
+		Data struct {
+			App  string           `json:"app"`
+			Jobs []worker.JobInfo `json:"jobs"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/apps/"+url.PathEscape(app)+"/jobs?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Job", "Handler", "Status", "Progress", "Attempt", "Started", "Duration"},
+		Rows:    [][]string{},
+	}
+	for _, job := range result.Data.Jobs {
+		table.Rows = append(table.Rows, []string{
+			job.ID,
+			job.Handler,
+			jobStatusLabel(job),
+			fmt.Sprintf("%d%%", job.Progress),
+			fmt.Sprintf("%d/%d", job.Attempt, max(job.MaxAttempts, 1)),
+			jobTime(job.StartedAt),
+			jobDuration(job),
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Jobs: %s", app)).
+		Table(table).
+		String(), result.Data)
+}
+
+func handleAppJobShow(args []string) {
+	if len(args) < 2 {
+		printAppJobsUsage()
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data worker.JobInfo `json:"data"`
+	}
+	peerRequest("GET", appJobPath(args[0], args[1]), nil, &result)
+	job := result.Data
+
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Handler", job.Handler},
+			{"Status", jobStatusLabel(job)},
+			{"Progress", fmt.Sprintf("%d%%", job.Progress)},
+			{"Attempt", fmt.Sprintf("%d/%d", job.Attempt, max(job.MaxAttempts, 1))},
+			{"Created", jobTime(&job.CreatedAt)},
+			{"Started", jobTime(job.StartedAt)},
+			{"Duration", jobDuration(job)},
+		},
+	}
+	if job.Error != "" {
+		table.Rows = append(table.Rows, []string{"Error", job.Error})
+	}
+	if len(job.Result) > 0 {
+		table.Rows = append(table.Rows, []string{"Result", string(job.Result)})
+	}
+
+	md := output.NewMarkdown().H1(fmt.Sprintf("Job: %s", job.ID)).Table(table)
+	if len(job.Checkpoint) > 0 {
+		md = md.H2("Checkpoint").Code(string(job.Checkpoint), "json")
+	}
+	if len(job.Logs) > 0 {
+		md = md.H2("Logs").Code(strings.Join(job.Logs, "\n"), "")
+	}
+	getRenderer().Print(md.String(), job)
+}
+
+func handleAppJobCancel(args []string) {
+	if len(args) < 2 {
+		printAppJobsUsage()
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	peerRequest("POST", appJobPath(args[0], args[1])+"/cancel", nil, &result)
+	fmt.Printf("Cancelled %s\n", args[1])
+}
+
+func appJobPath(app, job string) string {
+	return "/api/apps/" + url.PathEscape(app) + "/jobs/" + url.PathEscape(job)
+}
+
+// jobStatusLabel marks daemons, which restart after failing
+func jobStatusLabel(job worker.JobInfo) string {
+	if job.Daemon {
+		return string(job.Status) + " (daemon)"
+	}
+	return string(job.Status)
+}
+
+func jobTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// jobDuration is how long a job ran, or has run so far
+func jobDuration(job worker.JobInfo) string {
+	if job.StartedAt == nil {
+		return "-"
+	}
+	end := time.Now()
+	if job.DoneAt != nil {
+		end = *job.DoneAt
+	}
+	return end.Sub(*job.StartedAt).Round(time.Second).String()
+}
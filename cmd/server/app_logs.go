@@ -93,9 +93,7 @@ func fetchLogs(peer *remote.Peer, appName string, limit int) {
 	}
 
 	var result struct {
-		Data struct {
-			Logs []LogEntry `json:"logs"`
-		} `json:"data"`
+		Data []LogEntry `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -103,14 +101,14 @@ func fetchLogs(peer *remote.Peer, appName string, limit int) {
 		os.Exit(1)
 	}
 
-	if len(result.Data.Logs) == 0 {
+	if len(result.Data) == 0 {
 		fmt.Printf("No logs found for %s\n", appName)
 		return
 	}
 
 	// Print logs in reverse order (oldest first)
-	for i := len(result.Data.Logs) - 1; i >= 0; i-- {
-		log := result.Data.Logs[i]
+	for i := len(result.Data) - 1; i >= 0; i-- {
+		log := result.Data[i]
 		printLog(log)
 	}
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+func printAppMailUsage() {
+	fmt.Println("Usage: fazt [@peer] app mail <app> [--status <status>] [--limit <n>]")
+	fmt.Println("       fazt [@peer] app mail <app> [--smtp <host[:port]> ...] [--daily-limit <n>]")
+	fmt.Println("       fazt [@peer] app mail <app> --reset")
+	fmt.Println()
+	fmt.Println("Lists the email an app sent with fazt.app.mail.send, newest first, or")
+	fmt.Println("changes its mail settings. Apps send through the server's mail server")
+	fmt.Println("(fazt server set-mail) unless they have their own. Settings left out")
+	fmt.Println("keep their values.")
+	fmt.Println()
+	fmt.Println("  --status <status>       Only queued, sent or failed messages")
+	fmt.Println("  --limit <n>             Messages to list (default: 20)")
+	fmt.Println("  --smtp <host[:port]>    Send through this mail server, port 587 by default")
+	fmt.Println("  --smtp-user <user>      Its username")
+	fmt.Println("  --smtp-password <pass>  Its password")
+	fmt.Println("  --from <address>        Sender address (default: the username)")
+	fmt.Println("  --daily-limit <n>       Emails the app may send per UTC day")
+	fmt.Println("  --reset                 Drop the app's settings, going back to the server's")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @prod app mail my-app")
+	fmt.Println("  fazt @prod app mail my-app --status failed")
+	fmt.Println("  fazt @prod app mail my-app --smtp smtp.postmarkapp.com --smtp-user KEY --smtp-password KEY --from hello@my-app.com")
+	fmt.Println("  fazt @prod app mail my-app --daily-limit 1000")
+}
+
+// appMailResult is the data of the app mail API's responses
+type appMailResult struct {
+	Data struct {
+		Settings   *mailer.Settings `json:"settings"`
+		DailyLimit int              `json:"daily_limit"`
+		SentToday  int              `json:"sent_today"`
+		Messages   []mailer.Mail    `json:"messages"`
+	} `json:"data"`
+}
+
+// handleAppMail handles `fazt app mail`
+func handleAppMail(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppMailUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app mail", flag.ExitOnError)
+	flags.Usage = printAppMailUsage
+	status := flags.String("status", "", "Only queued, sent or failed messages")
+	limit := flags.Int("limit", 20, "Messages to list")
+	smtp := flags.String("smtp", "", "Mail server as host[:port]")
+	user := flags.String("smtp-user", "", "SMTP username")
+	password := flags.String("smtp-password", "", "SMTP password")
+	from := flags.String("from", "", "Sender address")
+	dailyLimit := flags.String("daily-limit", "", "Emails per UTC day")
+	reset := flags.Bool("reset", false, "Drop the app's settings")
+	flags.Parse(args[1:])
+
+	path := "/api/apps/" + url.PathEscape(app) + "/mail"
+	var result appMailResult
+	switch {
+	case *reset:
+		peerRequest("DELETE", path+"/settings", nil, &result)
+		fmt.Printf("✓ %s sends through the server's mail server\n", app)
+	case *smtp != "" || *user != "" || *password != "" || *from != "" || *dailyLimit != "":
+		body := map[string]interface{}{}
+		if *smtp != "" {
+			host, port, err := parseSMTPAddr(*smtp)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			body["host"] = host
+			body["port"], _ = strconv.Atoi(port)
+		}
+		if *user != "" {
+			body["username"] = *user
+		}
+		if *password != "" {
+			body["password"] = *password
+		}
+		if *from != "" {
+			body["from"] = *from
+		}
+		if *dailyLimit != "" {
+			n, err := strconv.Atoi(*dailyLimit)
+			if err != nil || n < 0 {
+				fmt.Printf("Error: invalid --daily-limit '%s'\n", *dailyLimit)
+				os.Exit(1)
+			}
+			body["daily_limit"] = n
+		}
+		peerRequest("PUT", path+"/settings", body, &result)
+		fmt.Printf("✓ Mail settings updated for %s\n", app)
+	default:
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(*limit))
+		if *status != "" {
+			query.Set("status", *status)
+		}
+		peerRequest("GET", path+"?"+query.Encode(), nil, &result)
+	}
+
+	d := result.Data
+	server := "the server's mail server"
+	if s := d.Settings; s != nil && s.Host != "" {
+		server = fmt.Sprintf("%s:%d", s.Host, s.Port)
+	}
+	fmt.Printf("Mail: %s, %d of %d sent today (UTC)\n", server, d.SentToday, d.DailyLimit)
+	for _, m := range d.Messages {
+		line := fmt.Sprintf("  %s  %-6s  %s  %s → %s", m.CreatedAt.Format("2006-01-02 15:04"), m.Status,
+			m.ID, truncate(m.Subject, 40), truncate(strings.Join(m.To, ", "), 40))
+		if m.Error != "" {
+			line += "  (" + truncate(m.Error, 60) + ")"
+		}
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/handlers"
+	"github.com/fazt-sh/fazt/internal/output"
+)
+
+// handleAppMerge deploys a fork's file changes to the app it was forked
+// from, on a peer. Conflicts are shown and stop the merge unless --force.
+func handleAppMerge(args []string) {
+	flags := flag.NewFlagSet("app merge", flag.ExitOnError)
+	aliasFlag := flags.String("alias", "", "Lookup the fork by alias")
+	idFlag := flags.String("id", "", "Lookup the fork by app ID")
+	intoFlag := flags.String("into", "", "App (ID or alias) to merge into (default: the app it was forked from)")
+	forceFlag := flags.Bool("force", false, "Take the fork's side of conflicting files")
+	dryRunFlag := flags.Bool("dry-run", false, "Show what would be merged without deploying")
+	emergencyFlag := flags.Bool("emergency", false, "Deploy the merge during a freeze window (audited)")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt app merge [<fork> | --alias <alias> | --id <id>] [--into <app>] [--force] [--dry-run] [--emergency]")
+		fmt.Println("       fazt @<peer> app merge [<fork> | --alias <alias> | --id <id>] [--into <app>] [--force] [--dry-run] [--emergency]")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	var identifier string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		identifier = args[0]
+		args = args[1:]
+	}
+	flags.Parse(args)
+
+	if *aliasFlag != "" {
+		identifier = *aliasFlag
+	}
+	if *idFlag != "" {
+		identifier = *idFlag
+	}
+	if identifier == "" {
+		fmt.Println("Error: a fork (--alias or --id) is required")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	path := "/api/apps/" + url.PathEscape(identifier) + "/merge"
+	body := handlers.MergeRequest{Into: *intoFlag, Force: *forceFlag, DryRun: true}
+
+	// Preview first so conflicts can be listed before anything is deployed
+	var result struct {
+		Data handlers.AppMerge `json:"data"`
+	}
+	peerRequest("POST", path, body, &result)
+	if !*dryRunFlag && (len(result.Data.Conflicts) == 0 || *forceFlag) && len(result.Data.Changes)+len(result.Data.Conflicts) > 0 {
+		body.DryRun = false
+		peerRequest("POST", path+emergencyQuery("", *emergencyFlag), body, &result)
+	}
+	merge := result.Data
+
+	title := fmt.Sprintf("Merge: %s into %s", merge.App.ID, merge.Into.ID)
+	if !merge.Merged {
+		title += " (not merged)"
+	}
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Fork", diffSideLabel(merge.App)},
+			{"Into", diffSideLabel(merge.Into)},
+			{"Changes", strconv.Itoa(len(merge.Changes))},
+			{"Conflicts", strconv.Itoa(len(merge.Conflicts))},
+			{"Kept", strconv.Itoa(merge.Kept)},
+			{"Files after", fmt.Sprintf("%d (%s)", merge.FileCount, formatSize(merge.SizeBytes))},
+		},
+	}
+	if !merge.BaseKnown {
+		table.Rows = append(table.Rows, []string{"Base", "not recorded: files the fork lacks are kept"})
+	}
+	md := output.NewMarkdown().H1(title).Table(table)
+
+	if len(merge.Changes) > 0 {
+		changes := &output.Table{Headers: []string{"Path", "Change"}}
+		for _, c := range merge.Changes {
+			changes.Rows = append(changes.Rows, []string{c.Path, c.Status})
+		}
+		md = md.H2("Changes").Table(changes)
+	}
+	if len(merge.Conflicts) > 0 {
+		conflicts := &output.Table{Headers: []string{"Path", "Fork", merge.Into.ID, "Resolution"}}
+		for _, c := range merge.Conflicts {
+			resolution := "-"
+			if c.Forced {
+				resolution = "fork"
+			}
+			conflicts.Rows = append(conflicts.Rows, []string{c.Path, c.Status, c.Into, resolution})
+		}
+		md = md.H2("Conflicts").Table(conflicts)
+	}
+
+	switch {
+	case merge.Merged:
+		md = md.Para(fmt.Sprintf("Deployed as a new version of %s.", merge.Into.Title))
+	case len(merge.Changes)+len(merge.Conflicts) == 0:
+		md = md.Para("Nothing to merge: the fork has no changes that aren't in " + merge.Into.ID + ".")
+	case *dryRunFlag:
+		md = md.Para("Dry run: nothing was deployed.")
+	default:
+		md = md.Para(fmt.Sprintf("%s was deployed after the fork was made and changed the same files. "+
+			"Compare them with `fazt app diff %s`, then merge again with --force to take the fork's side.",
+			merge.Into.ID, merge.App.ID))
+	}
+
+	getRenderer().Print(md.String(), merge)
+	if !merge.Merged && !*dryRunFlag && len(merge.Conflicts) > 0 {
+		os.Exit(1)
+	}
+}
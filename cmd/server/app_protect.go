@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/handlers"
+)
+
+// handleAppProtect sets or clears the protected flag of an app or alias on a
+// peer. Protected apps can't be removed, and protected aliases can't be
+// removed or repointed, without --force and a typed confirmation.
+func handleAppProtect(args []string, protected bool) {
+	name := "protect"
+	if !protected {
+		name = "unprotect"
+	}
+	flags := flag.NewFlagSet("app "+name, flag.ExitOnError)
+	aliasFlag := flags.String("alias", "", "Protect the alias itself instead of the app")
+	idFlag := flags.String("id", "", "App ID")
+
+	flags.Usage = func() {
+		fmt.Printf("Usage: fazt app %s [<app> | --id <id>]\n", name)
+		fmt.Printf("       fazt app %s --alias <subdomain>\n", name)
+		fmt.Printf("       fazt @<peer> app %s [<app> | --alias <subdomain> | --id <id>]\n", name)
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	var identifier string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		identifier = args[0]
+		args = args[1:]
+	}
+	flags.Parse(args)
+	if *idFlag != "" {
+		identifier = *idFlag
+	}
+
+	if *aliasFlag == "" && identifier == "" {
+		fmt.Println("Error: an app (--id) or --alias is required")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	path := "/api/apps/" + url.PathEscape(identifier) + "/protect"
+	target := identifier
+	if *aliasFlag != "" {
+		path = "/api/aliases/" + url.PathEscape(*aliasFlag) + "/protect"
+		target = "alias " + *aliasFlag
+	}
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	peerRequest("PUT", path, handlers.ProtectRequest{Protected: protected}, &result)
+
+	if protected {
+		fmt.Printf("Protected %s\n", target)
+		fmt.Println("Removing or repointing it now needs --force and its name typed again.")
+	} else {
+		fmt.Printf("Unprotected %s\n", target)
+	}
+}
+
+// confirmProtected is the second confirmation of a forced change to protected
+// apps or aliases: the name typed again, unless --confirm gave it already
+func confirmProtected(force bool, confirm string, names ...string) []string {
+	if !force {
+		return nil
+	}
+	if confirm != "" {
+		return strings.Split(confirm, ",")
+	}
+
+	confirms := make([]string, len(names))
+	for i, name := range names {
+		fmt.Printf("If %s is protected, this removes or repoints it. Type %s to confirm: ", name, name)
+		fmt.Scanln(&confirms[i])
+		if confirms[i] != name {
+			fmt.Println("Operation cancelled.")
+			os.Exit(0)
+		}
+	}
+	return confirms
+}
+
+// protectQuery is the query string overriding protection on API requests
+func protectQuery(confirms []string) string {
+	if len(confirms) == 0 {
+		return ""
+	}
+	return "?" + url.Values{"force": {"true"}, "confirm": confirms}.Encode()
+}
+
+// protectArgs are the command gateway flags overriding protection
+func protectArgs(confirms []string) []string {
+	if len(confirms) == 0 {
+		return nil
+	}
+	return []string{"--confirm", confirms[0], "--force"}
+}
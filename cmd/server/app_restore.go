@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/handlers"
+)
+
+func printAppRestoreUsage() {
+	fmt.Println("Usage: fazt [@peer] app restore <app> --at <time> [--yes]")
+	fmt.Println("       fazt [@peer] app restore <app> --backup <name> [--yes]")
+	fmt.Println("       fazt [@peer] app restore <app> --list")
+	fmt.Println()
+	fmt.Println("Puts one app's files and storage (KV, documents, blobs) back as the")
+	fmt.Println("newest database backup at or before a time had them. Other apps, and")
+	fmt.Println("the app's settings and members, are left as they are.")
+	fmt.Println()
+	fmt.Println("Backups are read from the backups directory next to the server's")
+	fmt.Println("database, named backup_YYYYMMDD_HHMMSS.db in the server's local time.")
+	fmt.Println()
+	fmt.Println("  --at <time>      Restore point: 2024-05-01T12:00 (server time) or RFC 3339")
+	fmt.Println("  --backup <name>  Restore from this backup instead")
+	fmt.Println("  --list           List the backups, oldest first")
+	fmt.Println("  --yes            Skip confirmation")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @prod app restore blog --list")
+	fmt.Println("  fazt @prod app restore blog --at \"2024-05-01T12:00\"")
+}
+
+// handleAppRestore handles `fazt app restore`
+func handleAppRestore(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppRestoreUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app restore", flag.ExitOnError)
+	flags.Usage = printAppRestoreUsage
+	at := flags.String("at", "", "Restore point")
+	backup := flags.String("backup", "", "Backup to restore from")
+	list := flags.Bool("list", false, "List the backups")
+	yes := flags.Bool("yes", false, "Skip confirmation")
+	flags.Parse(args[1:])
+
+	path := "/api/apps/" + url.PathEscape(app)
+	if *list {
+		var result struct {
+			Data struct {
+				Backups []struct {
+					Name      string `json:"name"`
+					TakenAt   string `json:"taken_at"`
+					SizeBytes int64  `json:"size_bytes"`
+				} `json:"backups"`
+			} `json:"data"`
+		}
+		peerRequest("GET", path+"/backups", nil, &result)
+		if len(result.Data.Backups) == 0 {
+			fmt.Println("No backups.")
+			return
+		}
+		fmt.Printf("%-28s %-26s %s\n", "BACKUP", "TAKEN", "SIZE")
+		for _, b := range result.Data.Backups {
+			fmt.Printf("%-28s %-26s %s\n", b.Name, b.TakenAt, formatBytes(b.SizeBytes))
+		}
+		return
+	}
+
+	if *at == "" && *backup == "" {
+		fmt.Println("Error: --at or --backup is required")
+		printAppRestoreUsage()
+		os.Exit(1)
+	}
+
+	if !*yes {
+		fmt.Printf("Replace %s's files and storage with the backup's? [y/N] ", app)
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Operation cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	var result struct {
+		Data struct {
+			Backup struct {
+				Name    string `json:"name"`
+				TakenAt string `json:"taken_at"`
+			} `json:"backup"`
+			Files int64 `json:"files"`
+			KV    int64 `json:"kv"`
+			Docs  int64 `json:"docs"`
+			Blobs int64 `json:"blobs"`
+		} `json:"data"`
+	}
+	peerRequest("POST", path+"/restore", handlers.RestoreRequest{At: *at, Backup: *backup}, &result)
+
+	d := result.Data
+	fmt.Printf("✓ Restored %s from %s (%s)\n", app, d.Backup.Name, d.Backup.TakenAt)
+	fmt.Printf("  %d files, %d keys, %d documents, %d blobs\n", d.Files, d.KV, d.Docs, d.Blobs)
+}
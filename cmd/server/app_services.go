@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// handleAppServices routes fazt app services subcommands
+func handleAppServices(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			handleAppServicesList(args[1:])
+			return
+		case "restart":
+			handleAppServiceRestart(args[1:])
+			return
+		case "logs":
+			handleAppServiceLogs(args[1:])
+			return
+		}
+	}
+	printAppServicesUsage()
+	if len(args) == 0 || (args[0] != "--help" && args[0] != "-h" && args[0] != "help") {
+		os.Exit(1)
+	}
+}
+
+func printAppServicesUsage() {
+	fmt.Println("Usage: fazt [@peer] app services list <app>")
+	fmt.Println("       fazt [@peer] app services restart <app> <service>")
+	fmt.Println("       fazt [@peer] app services logs <app> <service> [-f]")
+	fmt.Println()
+	fmt.Println("list     Services declared under \"services\" in manifest.json, with")
+	fmt.Println("         their status, restarts and last passed health check")
+	fmt.Println("restart  Stop a service and start it again from the deployed code")
+	fmt.Println("logs     A service's recent logs; -f follows them across restarts")
+	fmt.Println()
+	fmt.Println("Services start on every deploy and restart per their policy:")
+	fmt.Println("  \"services\": {\"bot\": {\"handler\": \"services/bot.js\", \"restart\": \"always\", \"health\": \"/api/health\"}}")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @zyt app services list my-app")
+	fmt.Println("  fazt @zyt app services restart my-app bot")
+	fmt.Println("  fazt @zyt app services logs my-app bot -f")
+}
+
+func handleAppServicesList(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppServicesUsage()
+		os.Exit(1)
+	}
+	app := args[0]
+
+	var result struct {
+		Data struct {
+			App      string               `json:"app"`
+			Services []worker.ServiceInfo `json:"services"`
+		} `json:"data"`
+	}
+	peerRequest("GET", appServicesPath(app), nil, &result)
+
+	md := output.NewMarkdown().H1(fmt.Sprintf("Services: %s", app))
+	if len(result.Data.Services) == 0 {
+		md = md.Para("No services declared in manifest.json.")
+		getRenderer().Print(md.String(), result.Data)
+		return
+	}
+
+	table := &output.Table{
+		Headers: []string{"Service", "Handler", "Status", "Restart", "Restarts", "Started", "Healthy", "Error"},
+		Rows:    [][]string{},
+	}
+	for _, svc := range result.Data.Services {
+		healthy := "-"
+		if svc.Health != "" {
+			healthy = jobTime(svc.HealthyAt)
+		}
+		errMsg := "-"
+		if svc.Error != "" {
+			errMsg = truncate(svc.Error, 40)
+		}
+		table.Rows = append(table.Rows, []string{
+			svc.Name,
+			svc.Handler,
+			svc.Status,
+			svc.Restart,
+			fmt.Sprintf("%d", svc.RestartCount),
+			jobTime(svc.StartedAt),
+			healthy,
+			errMsg,
+		})
+	}
+	getRenderer().Print(md.Table(table).String(), result.Data)
+}
+
+func handleAppServiceRestart(args []string) {
+	if len(args) < 2 {
+		printAppServicesUsage()
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data worker.JobInfo `json:"data"`
+	}
+	peerRequest("POST", appServicesPath(args[0])+"/"+url.PathEscape(args[1])+"/restart", nil, &result)
+	fmt.Printf("Restarted %s (%s)\n", args[1], result.Data.ID)
+}
+
+func handleAppServiceLogs(args []string) {
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		printAppServicesUsage()
+		os.Exit(1)
+	}
+	app, service := args[0], args[1]
+
+	flags := flag.NewFlagSet("app services logs", flag.ExitOnError)
+	follow := flags.Bool("f", false, "Follow new log lines (stream)")
+	flags.Parse(args[2:])
+
+	path := appServicesPath(app) + "/" + url.PathEscape(service) + "/logs"
+	if !*follow {
+		var result struct {
+			Data struct {
+				Logs []worker.ServiceLog `json:"logs"`
+			} `json:"data"`
+		}
+		peerRequest("GET", path, nil, &result)
+		if len(result.Data.Logs) == 0 {
+			fmt.Printf("No logs for %s\n", service)
+			return
+		}
+		for _, line := range result.Data.Logs {
+			fmt.Println(line.Line)
+		}
+		return
+	}
+
+	streamServiceLogs(path + "?follow=true")
+}
+
+// streamServiceLogs prints the lines of a service's log stream until it
+// ends or is interrupted
+func streamServiceLogs(path string) {
+	db := getClientDB()
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	database.Close()
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	req, _ := http.NewRequest("GET", peer.URL+path, nil)
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := (&http.Client{}).Do(req) // No timeout for streaming
+	if err != nil {
+		fmt.Printf("Error connecting: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(body))
+		os.Exit(1)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var line worker.ServiceLog
+		if err := json.Unmarshal([]byte(data), &line); err == nil {
+			fmt.Println(line.Line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Printf("Stream error: %v\n", err)
+	}
+}
+
+func appServicesPath(app string) string {
+	return "/api/apps/" + url.PathEscape(app) + "/services"
+}
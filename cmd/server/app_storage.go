@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// handleAppStorage routes app storage subcommands
+func handleAppStorage(args []string) {
+	if len(args) >= 2 && args[0] == "ds" {
+		switch args[1] {
+		case "explain":
+			handleAppStorageExplain(args[2:])
+			return
+		case "candidates":
+			handleAppStorageCandidates(args[2:])
+			return
+		case "extract":
+			handleAppStorageExtract(args[2:])
+			return
+		}
+	}
+	printAppStorageUsage()
+	if len(args) == 0 || (args[0] != "--help" && args[0] != "-h" && args[0] != "help") {
+		os.Exit(1)
+	}
+}
+
+func printAppStorageUsage() {
+	fmt.Println("Usage: fazt [@peer] app storage ds explain <app> <collection> ['<query>'] [--limit N] [--order asc|desc]")
+	fmt.Println("       fazt [@peer] app storage ds candidates <app>")
+	fmt.Println("       fazt [@peer] app storage ds extract <field> [--drop]")
+	fmt.Println()
+	fmt.Println("explain     Show how a ds.find query runs: the generated SQL, the index")
+	fmt.Println("            used, and how many rows are scanned to find the matches")
+	fmt.Println("candidates  List JSON fields the app filters on often in large collections")
+	fmt.Println("extract     Index a JSON field for all collections (admin), or drop it")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println(`  fazt @zyt app storage ds explain myapp posts '{"author":"x"}'`)
+	fmt.Println("  fazt @zyt app storage ds candidates myapp")
+	fmt.Println("  fazt @zyt app storage ds extract author")
+}
+
+// handleAppStorageExplain explains a document store query on a peer
+func handleAppStorageExplain(args []string) {
+	flags := flag.NewFlagSet("app storage ds explain", flag.ExitOnError)
+	limit := flags.Int("limit", 0, "Limit, as passed to ds.find")
+	order := flags.String("order", "", "Order by created_at: asc or desc (default desc)")
+	flags.Usage = printAppStorageUsage
+
+	// Positional args first: app, collection, optional query
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") && len(positional) < 3 {
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	flags.Parse(args)
+
+	if len(positional) < 2 {
+		fmt.Println("Error: app and collection are required")
+		printAppStorageUsage()
+		os.Exit(1)
+	}
+	app, collection := positional[0], positional[1]
+
+	query := map[string]interface{}{}
+	if len(positional) == 3 {
+		if err := json.Unmarshal([]byte(positional[2]), &query); err != nil {
+			fmt.Printf("Error: query must be a JSON object: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var result struct {
+		Data struct {
+			AppID      string            `json:"app_id"`
+			Collection string            `json:"collection"`
+			Plan       storage.QueryPlan `json:"plan"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/apps/"+url.PathEscape(app)+"/storage/explain", map[string]interface{}{
+		"collection": collection,
+		"query":      query,
+		"limit":      *limit,
+		"order":      *order,
+	}, &result)
+	plan := result.Data.Plan
+
+	index := plan.Index
+	if index == "" {
+		index = "none (full table scan)"
+	}
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Index", index},
+			{"Indexed fields", joinOrDash(plan.IndexedFields)},
+			{"JSON fields", joinOrDash(plan.JSONFields)},
+			{"Rows scanned", strconv.FormatInt(plan.Scanned, 10)},
+			{"Rows matched", strconv.FormatInt(plan.Matched, 10)},
+		},
+	}
+
+	md := output.NewMarkdown().
+		H1(fmt.Sprintf("Explain: %s/%s", app, collection)).
+		Table(table).
+		H2("SQL").
+		Code(plan.SQL, "sql").
+		H2("Plan").
+		List(plan.Plan)
+	if len(plan.JSONFields) > 0 && plan.Scanned > plan.Matched {
+		md = md.Para(fmt.Sprintf("JSON fields (%s) are extracted from each of the %d scanned rows. "+
+			"Filter on id or session, or extract a hot field with `fazt app storage ds extract <field>`, to narrow the scan with an index.",
+			joinOrDash(plan.JSONFields), plan.Scanned))
+	}
+
+	getRenderer().Print(md.String(), result.Data)
+}
+
+// handleAppStorageCandidates lists fields worth extracting for an app
+func handleAppStorageCandidates(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Error: app is required")
+		printAppStorageUsage()
+		os.Exit(1)
+	}
+	app := args[0]
+
+	var result struct {
+		Data struct {
+			AppID      string                   `json:"app_id"`
+			Candidates []storage.FieldCandidate `json:"candidates"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/apps/"+url.PathEscape(app)+"/storage/candidates", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Collection", "Field", "Queries", "Docs", "Avg Matched", "Index"},
+		Rows:    [][]string{},
+	}
+	for _, c := range result.Data.Candidates {
+		index := c.Index
+		if index == "" {
+			index = "-"
+		}
+		table.Rows = append(table.Rows, []string{
+			c.Collection, c.Field,
+			strconv.FormatInt(c.Queries, 10),
+			strconv.FormatInt(c.Docs, 10),
+			fmt.Sprintf("%.1f", c.AvgMatched),
+			index,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Extraction Candidates: %s", app)).
+		Table(table).
+		Para("Fields filtered on often in collections of 1000+ docs, matching few of them. "+
+			"Extract one with `fazt app storage ds extract <field>`.").
+		String(), result.Data)
+}
+
+// handleAppStorageExtract indexes a JSON field, or drops its index
+func handleAppStorageExtract(args []string) {
+	flags := flag.NewFlagSet("app storage ds extract", flag.ExitOnError)
+	drop := flags.Bool("drop", false, "Drop the field's index")
+	flags.Usage = printAppStorageUsage
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Error: field is required")
+		printAppStorageUsage()
+		os.Exit(1)
+	}
+	field := args[0]
+	flags.Parse(args[1:])
+
+	if *drop {
+		var result map[string]interface{}
+		peerRequest("DELETE", "/api/system/storage/fields/"+url.PathEscape(field), nil, &result)
+		fmt.Printf("Dropped index for %s\n", field)
+		return
+	}
+
+	var result struct {
+		Data struct {
+			Index string `json:"index"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/system/storage/fields", map[string]string{"field": field}, &result)
+	fmt.Printf("Extracted %s into %s\n", field, result.Data.Index)
+}
+
+// peerRequest calls an API endpoint on the peer and decodes the response
+func peerRequest(method, path string, body interface{}, out interface{}) {
+	peerRequestTo(targetPeerName, method, path, body, out)
+}
+
+// peerRequestTo calls an API endpoint on the named peer, for commands that
+// work with two peers
+func peerRequestTo(peerName, method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, peerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, _ := http.NewRequest(method, peer.URL+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func joinOrDash(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+// A sync sends content in batches of at most syncBatchItems items or
+// syncBatchBytes bytes, well within the server's 100MB limit
+const (
+	syncBatchItems = 500
+	syncBatchBytes = 8 << 20
+)
+
+func printAppSyncUsage() {
+	fmt.Println("Usage: fazt [@peer] app sync <app> --to <peer> [--data kv,ds,s3|all] [--dry-run] [--yes]")
+	fmt.Println()
+	fmt.Println("Copies an app from one fazt server to another: its files and, with")
+	fmt.Println("--data, its storage. Both copies are hashed and only what differs is")
+	fmt.Println("sent; what the other copy has beyond this one is removed. Run it again,")
+	fmt.Println("e.g. from cron, to keep a standby copy of an important app.")
+	fmt.Println()
+	fmt.Println("The app is created on the other server by its first sync. Storage is")
+	fmt.Println("synced under the app's name and the aliases both servers give it.")
+	fmt.Println("Changed files are deployed as a new version.")
+	fmt.Println()
+	fmt.Println("  --to <peer>      Server to sync to (required)")
+	fmt.Println("  --data <kinds>   Storage to include: kv, ds (documents), s3 (blobs), or all")
+	fmt.Println("  --dry-run        List what would change without changing it")
+	fmt.Println("  --yes            Skip confirmation")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @zyt app sync blog --to backup --dry-run")
+	fmt.Println("  fazt @zyt app sync blog --to backup --data all --yes")
+}
+
+// syncManifest is an app's copy on one server
+type syncManifest struct {
+	Title string             `json:"title"`
+	Sites []string           `json:"sites"`
+	Items []hosting.SyncItem `json:"items"`
+}
+
+// handleAppSync handles `fazt app sync`
+func handleAppSync(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppSyncUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app sync", flag.ExitOnError)
+	flags.Usage = printAppSyncUsage
+	to := flags.String("to", "", "Server to sync to")
+	data := flags.String("data", "", "Storage to include")
+	dryRun := flags.Bool("dry-run", false, "List what would change")
+	yes := flags.Bool("yes", false, "Skip confirmation")
+	flags.Parse(args[1:])
+
+	if *to == "" {
+		fmt.Println("Error: --to is required")
+		printAppSyncUsage()
+		os.Exit(1)
+	}
+
+	db := getClientDB()
+	from, err := remote.ResolvePeer(db, targetPeerName)
+	var dest *remote.Peer
+	if err == nil {
+		dest, err = remote.ResolvePeer(db, *to)
+	}
+	database.Close()
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+	if dest.Name == from.Name {
+		fmt.Printf("Error: %s is the server the app is synced from\n", dest.Name)
+		os.Exit(1)
+	}
+
+	syncApp(from, dest, app, *data, *dryRun, *yes)
+}
+
+func syncApp(from, to *remote.Peer, app, data string, dryRun, yes bool) {
+	query := ""
+	if data != "" {
+		query = "?data=" + url.QueryEscape(data)
+	}
+
+	var src syncManifest
+	if err := syncCall(from, "GET", "/api/apps/"+url.PathEscape(app)+"/sync"+query, nil, &src); err != nil {
+		fmt.Printf("Error: %s: %v\n", from.Name, err)
+		os.Exit(1)
+	}
+	srcPath := "/api/apps/" + url.PathEscape(app) + "/sync"
+	dstPath := "/api/apps/" + url.PathEscape(src.Title) + "/sync"
+
+	// The app is created by its first sync, under its name
+	dst := syncManifest{Title: src.Title, Sites: []string{src.Title}}
+	exists := true
+	if err := syncCall(to, "GET", dstPath+query, nil, &dst); err != nil {
+		if apiErr, ok := err.(*remote.APIError); !ok || apiErr.Code != "APP_NOT_FOUND" {
+			fmt.Printf("Error: %s: %v\n", to.Name, err)
+			os.Exit(1)
+		}
+		exists = false
+	}
+
+	// Storage goes under the sites both servers give the app
+	dstSites := map[string]bool{}
+	for _, s := range dst.Sites {
+		dstSites[s] = true
+	}
+	var skipped []string
+	for _, s := range src.Sites {
+		if !dstSites[s] {
+			skipped = append(skipped, s)
+		}
+	}
+	var changes []hosting.SyncChange
+	for _, c := range hosting.DiffSync(src.Items, dst.Items) {
+		if c.Kind == hosting.SyncFile || dstSites[c.Site] {
+			changes = append(changes, c)
+		}
+	}
+
+	printSyncPlan(src.Title, from.Name, to.Name, changes, dryRun)
+	if len(skipped) > 0 && data != "" {
+		fmt.Printf("Storage under %s isn't synced: not the app's on %s\n", strings.Join(skipped, ", "), to.Name)
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s is in sync on %s\n", src.Title, to.Name)
+		return
+	}
+	if dryRun {
+		return
+	}
+	if exists && !yes {
+		fmt.Printf("Change %s on %s to match %s? [y/N] ", src.Title, to.Name, from.Name)
+		var response string
+		fmt.Scanln(&response)
+		if response != "y" && response != "Y" {
+			fmt.Println("Operation cancelled.")
+			os.Exit(0)
+		}
+	}
+
+	var files, storage []hosting.SyncItem
+	var fileRemoves, storageRemoves []hosting.SyncItem
+	for _, c := range changes {
+		switch {
+		case c.Status == "removed" && c.Kind == hosting.SyncFile:
+			fileRemoves = append(fileRemoves, c.SyncItem)
+		case c.Status == "removed":
+			storageRemoves = append(storageRemoves, c.SyncItem)
+		case c.Kind == hosting.SyncFile:
+			files = append(files, c.SyncItem)
+		default:
+			storage = append(storage, c.SyncItem)
+		}
+	}
+
+	type applied struct {
+		FileCount      int   `json:"file_count"`
+		StorageWritten int64 `json:"storage_written"`
+		StorageRemoved int64 `json:"storage_removed"`
+	}
+	var written, removed int64
+	fileCount := -1
+
+	// Files are deployed together, so they go in one request
+	if len(files) > 0 || len(fileRemoves) > 0 {
+		var put []hosting.SyncData
+		for _, batch := range syncBatches(files) {
+			put = append(put, exportSyncItems(from, srcPath, batch)...)
+		}
+		var result applied
+		if err := syncCall(to, "POST", dstPath, handlers.AppSyncRequest{Put: put, Remove: fileRemoves}, &result); err != nil {
+			fmt.Printf("Error: %s: %v\n", to.Name, err)
+			os.Exit(1)
+		}
+		fileCount = result.FileCount
+	}
+	for _, batch := range syncBatches(storage) {
+		put := exportSyncItems(from, srcPath, batch)
+		var result applied
+		if err := syncCall(to, "POST", dstPath, handlers.AppSyncRequest{Put: put}, &result); err != nil {
+			fmt.Printf("Error: %s: %v\n", to.Name, err)
+			os.Exit(1)
+		}
+		written += result.StorageWritten
+	}
+	for _, batch := range syncBatches(storageRemoves) {
+		var result applied
+		if err := syncCall(to, "POST", dstPath, handlers.AppSyncRequest{Remove: batch}, &result); err != nil {
+			fmt.Printf("Error: %s: %v\n", to.Name, err)
+			os.Exit(1)
+		}
+		removed += result.StorageRemoved
+	}
+
+	fmt.Printf("✓ Synced %s from %s to %s\n", src.Title, from.Name, to.Name)
+	if fileCount >= 0 {
+		fmt.Printf("  %d files changed, %d deployed\n", len(files)+len(fileRemoves), fileCount)
+	}
+	if data != "" {
+		fmt.Printf("  %d storage entries written, %d removed\n", written, removed)
+	}
+}
+
+// printSyncPlan shows the changes per kind, or each change for a dry run
+func printSyncPlan(app, from, to string, changes []hosting.SyncChange, dryRun bool) {
+	if dryRun {
+		table := &output.Table{
+			Headers: []string{"Status", "Kind", "Site", "Key", "Size"},
+			Rows:    [][]string{},
+		}
+		for _, c := range changes {
+			key := c.Key
+			if c.Collection != "" {
+				key = c.Collection + "/" + c.Key
+			}
+			table.Rows = append(table.Rows, []string{c.Status, c.Kind, c.Site, key, formatBytes(c.Size)})
+		}
+		getRenderer().Print(output.NewMarkdown().
+			H1(fmt.Sprintf("Sync %s: %s → %s (dry run)", app, from, to)).
+			Table(table).
+			String(), changes)
+		return
+	}
+
+	counts := map[string][3]int64{}
+	var kinds []string
+	for _, c := range changes {
+		n, ok := counts[c.Kind]
+		if !ok {
+			kinds = append(kinds, c.Kind)
+		}
+		if c.Status == "removed" {
+			n[1]++
+		} else {
+			n[0]++
+			n[2] += c.Size
+		}
+		counts[c.Kind] = n
+	}
+	for _, kind := range kinds {
+		n := counts[kind]
+		fmt.Printf("  %-4s %d to send (%s), %d to remove\n", kind, n[0], formatBytes(n[2]), n[1])
+	}
+}
+
+// syncBatches splits items into batches small enough to send at once
+func syncBatches(items []hosting.SyncItem) [][]hosting.SyncItem {
+	var batches [][]hosting.SyncItem
+	var batch []hosting.SyncItem
+	var size int64
+	for _, i := range items {
+		if len(batch) > 0 && (len(batch) >= syncBatchItems || size+i.Size > syncBatchBytes) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, i)
+		size += i.Size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// exportSyncItems fetches the content of items from the source
+func exportSyncItems(from *remote.Peer, path string, items []hosting.SyncItem) []hosting.SyncData {
+	var result struct {
+		Items []hosting.SyncData `json:"items"`
+	}
+	if err := syncCall(from, "POST", path+"/export", handlers.AppSyncExportRequest{Items: items}, &result); err != nil {
+		fmt.Printf("Error: %s: %v\n", from.Name, err)
+		os.Exit(1)
+	}
+	return result.Items
+}
+
+// syncCall calls an API endpoint on a peer and decodes the response's
+// data, returning API errors as *remote.APIError
+func syncCall(peer *remote.Peer, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, peer.URL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var apiResp remote.APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", resp.Status, err)
+	}
+	if apiResp.Error != nil {
+		return apiResp.Error
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.Unmarshal(apiResp.Data, out)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/handlers"
+)
+
+func printAppSystemUsage() {
+	fmt.Println("Usage: fazt [@peer] app system <app> [--off]")
+	fmt.Println()
+	fmt.Println("Lets the app's handlers read server-wide information with fazt.sys:")
+	fmt.Println("every app, the server's stats and every app's jobs, e.g. for a custom")
+	fmt.Println("dashboard app. It's read-only, but shows what every app holds, so only")
+	fmt.Println("server admins can give it.")
+	fmt.Println()
+	fmt.Println("  --off  Take system access away")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  fazt @prod app system dashboard")
+	fmt.Println("  fazt @prod app system dashboard --off")
+}
+
+// handleAppSystem handles `fazt app system`
+func handleAppSystem(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		printAppSystemUsage()
+		if len(args) == 0 || (args[0] != "--help" && args[0] != "-h") {
+			os.Exit(1)
+		}
+		return
+	}
+	app := args[0]
+
+	flags := flag.NewFlagSet("app system", flag.ExitOnError)
+	flags.Usage = printAppSystemUsage
+	off := flags.Bool("off", false, "Take system access away")
+	flags.Parse(args[1:])
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	peerRequest("PUT", "/api/apps/"+url.PathEscape(app)+"/system", handlers.SystemAccessRequest{SystemAccess: !*off}, &result)
+
+	if *off {
+		fmt.Printf("✓ %s no longer has system access\n", app)
+		return
+	}
+	fmt.Printf("✓ %s may read server-wide information with fazt.sys\n", app)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/apptest"
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+// handleAppTest runs an app's *.test.js files against its serverless
+// handlers, locally
+func handleAppTest(args []string) {
+	// Guard: this is a local-only command
+	if targetPeerName != "" {
+		fmt.Fprintf(os.Stderr, "Error: 'app test' is a local operation\n")
+		fmt.Fprintf(os.Stderr, "This command tests local files, not apps on remote peers.\n")
+		fmt.Fprintf(os.Stderr, "Usage: fazt app test <directory> [--run <name>] [--record] [--json]\n")
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet("app test", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "Output as JSON")
+	runFilter := flags.String("run", "", "Only run tests whose name contains this")
+	timeoutFlag := flags.Duration("timeout", apptest.DefaultTimeout, "Time limit per test")
+	record := flags.Bool("record", false, "Send unmocked fetches and save their responses to "+egress.FixturesFile)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt app test <directory> [--run <name>] [--record] [--json]")
+		fmt.Println()
+		fmt.Println("Run the app's *.test.js files against its api/ handlers, with empty")
+		fmt.Println("in-memory storage and fazt.net.fetch stubbed by mockFetch(). Fetches")
+		fmt.Println("without a mock replay the responses recorded in " + egress.FixturesFile + ";")
+		fmt.Println("--record sends them for real and saves their responses there.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	// Find directory arg
+	var dir string
+	var flagArgs []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") && dir == "" {
+			dir = arg
+			flagArgs = args[i+1:]
+			break
+		}
+	}
+
+	if dir == "" {
+		dir = "."
+		flagArgs = args
+	}
+
+	flags.Parse(flagArgs)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Printf("Error: directory '%s' does not exist\n", dir)
+		os.Exit(1)
+	}
+
+	report, err := apptest.Run(dir, apptest.Options{Filter: *runFilter, Timeout: *timeoutFlag, Record: *record})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printTestReport(report, dir)
+	}
+
+	if len(report.Files) == 0 || !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// printTestReport prints test results and handler coverage
+func printTestReport(report *apptest.Report, dir string) {
+	if len(report.Files) == 0 {
+		fmt.Printf("No *.test.js files found in %s\n", dir)
+		return
+	}
+	fmt.Printf("Testing %s (%d files)...\n", report.App, len(report.Files))
+
+	for _, file := range report.Files {
+		fmt.Println()
+		fmt.Println(file.File)
+		if file.Error != "" {
+			fmt.Printf("  ✗ failed to load: %s\n", file.Error)
+			continue
+		}
+		for _, t := range file.Tests {
+			switch t.Status {
+			case apptest.StatusPass:
+				fmt.Printf("  ✓ %s (%dms)\n", t.Name, t.DurationMs)
+			case apptest.StatusSkip:
+				fmt.Printf("  - %s (skipped)\n", t.Name)
+			default:
+				fmt.Printf("  ✗ %s\n", t.Name)
+				fmt.Printf("      %s\n", t.Error)
+				for _, line := range t.Logs {
+					fmt.Printf("      console: %s\n", line)
+				}
+			}
+		}
+	}
+
+	if len(report.Coverage) > 0 {
+		fmt.Println()
+		fmt.Printf("Handlers: %d/%d covered\n", report.Covered(), len(report.Coverage))
+		for _, h := range report.Coverage {
+			mark := "✓"
+			if h.Hits == 0 {
+				mark = "✗"
+			}
+			fmt.Printf("  %s %-7s %-28s %s (%d)\n", mark, h.Method, h.Route, h.File, h.Hits)
+		}
+	}
+
+	if report.Recorded > 0 {
+		fmt.Println()
+		fmt.Printf("Recorded %d fetches to %s\n", report.Recorded, egress.FixturesFile)
+	}
+
+	fmt.Println()
+	summary := fmt.Sprintf("%d passed, %d failed, %d skipped (%dms)", report.Passed, report.Failed, report.Skipped, report.DurationMs)
+	if report.OK() {
+		fmt.Printf("✓ %s\n", summary)
+	} else {
+		fmt.Printf("✗ %s\n", summary)
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/help"
@@ -38,6 +41,8 @@ func handleAppCommandV2(args []string) {
 		handleAppCreate(args[1:]) // Use existing create
 	case "validate":
 		handleAppValidate(args[1:]) // Use existing validate
+	case "test":
+		handleAppTest(args[1:])
 	case "logs":
 		handleAppLogs(args[1:]) // Use existing logs
 	case "install":
@@ -56,14 +61,50 @@ func handleAppCommandV2(args []string) {
 		handleAppSwap(args[1:])
 	case "split":
 		handleAppSplit(args[1:])
+	case "canary":
+		handleAppCanary(args[1:])
+	case "share":
+		handleAppShare(args[1:])
+	case "auth":
+		handleAppAuth(args[1:])
 	case "lineage":
 		handleAppLineage(args[1:])
+	case "diff":
+		handleAppDiff(args[1:])
+	case "merge":
+		handleAppMerge(args[1:])
+	case "protect":
+		handleAppProtect(args[1:], true)
+	case "unprotect":
+		handleAppProtect(args[1:], false)
 	case "upgrade":
 		handleAppUpgrade(args[1:])
 	case "pull":
 		handleAppPull(args[1:])
 	case "files":
 		handleAppFiles(args[1:])
+	case "storage":
+		handleAppStorage(args[1:])
+	case "jobs":
+		handleAppJobs(args[1:])
+	case "services":
+		handleAppServices(args[1:])
+	case "chaos":
+		handleAppChaos(args[1:])
+	case "fixtures":
+		handleAppFixtures(args[1:])
+	case "mail":
+		handleAppMail(args[1:])
+	case "grant":
+		handleAppGrant(args[1:])
+	case "system":
+		handleAppSystem(args[1:])
+	case "restore":
+		handleAppRestore(args[1:])
+	case "sync":
+		handleAppSync(args[1:])
+	case "export":
+		handleAppExport(args[1:])
 	case "--help", "-h", "help":
 		printAppHelpV2()
 	default:
@@ -293,6 +334,19 @@ func handleAppInfoV2(args []string) {
 		fmt.Printf("Source:      %s\n", getString(app, "source"))
 		fmt.Printf("Files:       %v\n", app["file_count"])
 		fmt.Printf("Size:        %s\n", formatSize(int64(getFloat(app, "size_bytes"))))
+		if protected, _ := app["protected"].(bool); protected {
+			fmt.Println("Protected:   yes (remove needs --force)")
+		}
+		if inject, ok := app["analytics_inject"].(bool); ok {
+			if inject {
+				fmt.Println("Analytics:   on (snippet injected into HTML)")
+			} else {
+				fmt.Println("Analytics:   off (change with app deploy --analytics on)")
+			}
+		}
+		if system, _ := app["system_access"].(bool); system {
+			fmt.Println("System:      yes (reads the whole server with fazt.sys)")
+		}
 
 		if aliases, ok := app["aliases"].([]interface{}); ok && len(aliases) > 0 {
 			var aliasStrs []string
@@ -416,10 +470,13 @@ func handleAppRemoveV2(args []string) {
 	aliasFlag := flags.String("alias", "", "Remove alias only")
 	idFlag := flags.String("id", "", "Remove app by ID")
 	withForks := flags.Bool("with-forks", false, "Also delete all forks")
+	forceFlag := flags.Bool("force", false, "Remove even if protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected app or alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Remove an alias during a freeze window (audited)")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt app remove [--alias <alias> | --id <id>] [--with-forks]")
-		fmt.Println("       fazt @<peer> app remove [--alias <alias> | --id <id>] [--with-forks]")
+		fmt.Println("Usage: fazt app remove [--alias <alias> | --id <id>] [--with-forks] [--force] [--emergency]")
+		fmt.Println("       fazt @<peer> app remove [--alias <alias> | --id <id>] [--with-forks] [--force] [--emergency]")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -472,6 +529,8 @@ func handleAppRemoveV2(args []string) {
 	if *withForks {
 		cmdArgs = append(cmdArgs, "--with-forks")
 	}
+	cmdArgs = append(cmdArgs, protectArgs(confirmProtected(*forceFlag, *confirmFlag, identifier))...)
+	cmdArgs = append(cmdArgs, emergencyArgs(*emergencyFlag)...)
 
 	result, err := executeRemoteCmd(peer, "app", cmdArgs)
 	if err != nil {
@@ -493,10 +552,13 @@ func handleAppRemoveV2(args []string) {
 func handleAppLink(args []string) {
 	flags := flag.NewFlagSet("app link", flag.ExitOnError)
 	idFlag := flags.String("id", "", "App ID to link (required)")
+	forceFlag := flags.Bool("force", false, "Repoint even if protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Change the alias during a freeze window (audited)")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt app link <subdomain> --id <app_id>")
-		fmt.Println("       fazt @<peer> app link <subdomain> --id <app_id>")
+		fmt.Println("Usage: fazt app link <subdomain> --id <app_id> [--force] [--emergency]")
+		fmt.Println("       fazt @<peer> app link <subdomain> --id <app_id> [--force] [--emergency]")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -531,7 +593,9 @@ func handleAppLink(args []string) {
 		os.Exit(1)
 	}
 
-	result, err := executeRemoteCmd(peer, "app", []string{"link", subdomain, "--id", *idFlag})
+	cmdArgs := append([]string{"link", subdomain, "--id", *idFlag}, protectArgs(confirmProtected(*forceFlag, *confirmFlag, subdomain))...)
+	cmdArgs = append(cmdArgs, emergencyArgs(*emergencyFlag)...)
+	result, err := executeRemoteCmd(peer, "app", cmdArgs)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -548,10 +612,13 @@ func handleAppLink(args []string) {
 // handleAppUnlink removes an alias
 func handleAppUnlink(args []string) {
 	flags := flag.NewFlagSet("app unlink", flag.ExitOnError)
+	forceFlag := flags.Bool("force", false, "Remove even if protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Change the alias during a freeze window (audited)")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt app unlink <subdomain>")
-		fmt.Println("       fazt @<peer> app unlink <subdomain>")
+		fmt.Println("Usage: fazt app unlink <subdomain> [--force] [--emergency]")
+		fmt.Println("       fazt @<peer> app unlink <subdomain> [--force] [--emergency]")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -586,7 +653,9 @@ func handleAppUnlink(args []string) {
 		os.Exit(1)
 	}
 
-	_, err = executeRemoteCmd(peer, "app", []string{"unlink", subdomain})
+	cmdArgs := append([]string{"unlink", subdomain}, protectArgs(confirmProtected(*forceFlag, *confirmFlag, subdomain))...)
+	cmdArgs = append(cmdArgs, emergencyArgs(*emergencyFlag)...)
+	_, err = executeRemoteCmd(peer, "app", cmdArgs)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -598,10 +667,13 @@ func handleAppUnlink(args []string) {
 // handleAppReserve reserves a subdomain
 func handleAppReserve(args []string) {
 	flags := flag.NewFlagSet("app reserve", flag.ExitOnError)
+	forceFlag := flags.Bool("force", false, "Reserve even if protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Change the alias during a freeze window (audited)")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt app reserve <subdomain>")
-		fmt.Println("       fazt @<peer> app reserve <subdomain>")
+		fmt.Println("Usage: fazt app reserve <subdomain> [--force] [--emergency]")
+		fmt.Println("       fazt @<peer> app reserve <subdomain> [--force] [--emergency]")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -636,7 +708,9 @@ func handleAppReserve(args []string) {
 		os.Exit(1)
 	}
 
-	_, err = executeRemoteCmd(peer, "app", []string{"reserve", subdomain})
+	cmdArgs := append([]string{"reserve", subdomain}, protectArgs(confirmProtected(*forceFlag, *confirmFlag, subdomain))...)
+	cmdArgs = append(cmdArgs, emergencyArgs(*emergencyFlag)...)
+	_, err = executeRemoteCmd(peer, "app", cmdArgs)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -651,7 +725,7 @@ func handleAppFork(args []string) {
 	aliasFlag := flags.String("alias", "", "Source alias")
 	idFlag := flags.String("id", "", "Source app ID")
 	asFlag := flags.String("as", "", "New alias for fork")
-	noStorage := flags.Bool("no-storage", false, "Don't copy storage")
+	noStorage := flags.Bool("no-storage", false, "Don't copy storage (copied to --as, anonymized per the manifest)")
 
 	flags.Usage = func() {
 		fmt.Println("Usage: fazt app fork [--alias <alias> | --id <id>] [--as <new-alias>] [--no-storage]")
@@ -711,16 +785,22 @@ func handleAppFork(args []string) {
 		if url := getString(resp, "url"); url != "" {
 			fmt.Printf("URL:    %s\n", url)
 		}
+		if copied, ok := resp["storage_copied"]; ok {
+			fmt.Printf("Data:   %v entries copied, %v fields anonymized\n", copied, resp["anonymized_fields"])
+		}
 	}
 }
 
 // handleAppSwap swaps two aliases
 func handleAppSwap(args []string) {
 	flags := flag.NewFlagSet("app swap", flag.ExitOnError)
+	forceFlag := flags.Bool("force", false, "Swap even if protected (asks for both names again)")
+	confirmFlag := flags.String("confirm", "", "Comma-separated names of the protected aliases, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Swap the aliases during a freeze window (audited)")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt app swap <alias1> <alias2>")
-		fmt.Println("       fazt @<peer> app swap <alias1> <alias2>")
+		fmt.Println("Usage: fazt app swap <alias1> <alias2> [--force] [--emergency]")
+		fmt.Println("       fazt @<peer> app swap <alias1> <alias2> [--force] [--emergency]")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -757,7 +837,8 @@ func handleAppSwap(args []string) {
 	body := map[string]string{"alias1": aliases[0], "alias2": aliases[1]}
 	jsonBody, _ := json.Marshal(body)
 
-	req, _ := http.NewRequest("POST", peer.URL+"/api/aliases/swap", bytes.NewBuffer(jsonBody))
+	query := emergencyQuery(protectQuery(confirmProtected(*forceFlag, *confirmFlag, aliases[0], aliases[1])), *emergencyFlag)
+	req, _ := http.NewRequest("POST", peer.URL+"/api/aliases/swap"+query, bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+peer.Token)
 
@@ -779,15 +860,25 @@ func handleAppSwap(args []string) {
 
 // handleAppSplit configures traffic splitting
 func handleAppSplit(args []string) {
+	if len(args) > 0 && args[0] == "status" {
+		handleAppSplitStatus(args[1:])
+		return
+	}
+
 	flags := flag.NewFlagSet("app split", flag.ExitOnError)
 	idsFlag := flags.String("ids", "", "Comma-separated app_id:weight pairs (e.g., app_abc:50,app_def:50)")
+	forceFlag := flags.Bool("force", false, "Split even if protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Change the alias during a freeze window (audited)")
 
 	flags.Usage = func() {
 		fmt.Println("Usage: fazt app split <subdomain> --ids <id1:weight1,id2:weight2>")
+		fmt.Println("       fazt app split status <subdomain>")
 		fmt.Println("       fazt @<peer> app split <subdomain> --ids <id1:weight1,id2:weight2>")
 		fmt.Println()
 		fmt.Println("Example:")
 		fmt.Println("  fazt @zyt app split tetris --ids app_v1:50,app_v2:50")
+		fmt.Println("  fazt @zyt app split status tetris")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -844,7 +935,8 @@ func handleAppSplit(args []string) {
 	body := map[string]interface{}{"targets": targets}
 	jsonBody, _ := json.Marshal(body)
 
-	req, _ := http.NewRequest("POST", peer.URL+"/api/aliases/"+subdomain+"/split", bytes.NewBuffer(jsonBody))
+	query := emergencyQuery(protectQuery(confirmProtected(*forceFlag, *confirmFlag, subdomain)), *emergencyFlag)
+	req, _ := http.NewRequest("POST", peer.URL+"/api/aliases/"+subdomain+"/split"+query, bytes.NewBuffer(jsonBody))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+peer.Token)
 
@@ -867,6 +959,90 @@ func handleAppSplit(args []string) {
 	}
 }
 
+// handleAppSplitStatus shows live request counts per split variant
+func handleAppSplitStatus(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: fazt app split status <subdomain>")
+		fmt.Println("       fazt @<peer> app split status <subdomain>")
+		os.Exit(1)
+	}
+	subdomain := args[0]
+
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest("GET", peer.URL+"/api/aliases/"+subdomain+"/split", nil)
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data struct {
+			Subdomain string `json:"subdomain"`
+			Variants  []struct {
+				AppID     string `json:"app_id"`
+				Title     string `json:"title"`
+				Weight    int    `json:"weight"`
+				Requests  int64  `json:"requests"`
+				Pageviews int64  `json:"pageviews_24h"`
+			} `json:"variants"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var total int64
+	for _, v := range result.Data.Variants {
+		total += v.Requests
+	}
+
+	table := &output.Table{
+		Headers: []string{"App ID", "Title", "Weight", "Requests", "Share", "Pageviews (24h)"},
+		Rows:    [][]string{},
+	}
+	for _, v := range result.Data.Variants {
+		share := "-"
+		if total > 0 {
+			share = fmt.Sprintf("%.1f%%", float64(v.Requests)*100/float64(total))
+		}
+		table.Rows = append(table.Rows, []string{
+			v.AppID,
+			v.Title,
+			fmt.Sprintf("%d%%", v.Weight),
+			fmt.Sprintf("%d", v.Requests),
+			share,
+			fmt.Sprintf("%d", v.Pageviews),
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Traffic split: %s", subdomain)).
+		Table(table).
+		Para("Requests are counted since server start.").
+		String(), result.Data)
+}
+
 // handleAppLineage shows the lineage tree for an app
 func handleAppLineage(args []string) {
 	flags := flag.NewFlagSet("app lineage", flag.ExitOnError)
@@ -1035,6 +1211,342 @@ func getFloat(m map[string]interface{}, key string) float64 {
 	return 0
 }
 
+// handleAppShare grants, lists, or revokes user access to an app
+func handleAppShare(args []string) {
+	flags := flag.NewFlagSet("app share", flag.ExitOnError)
+	userFlag := flags.String("user", "", "Email of the user to share with")
+	roleFlag := flags.String("role", "viewer", "Role: owner, editor, or viewer")
+	removeFlag := flags.Bool("remove", false, "Revoke the user's access")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt app share <app> --user <email> [--role owner|editor|viewer]")
+		fmt.Println("       fazt app share <app> --user <email> --remove")
+		fmt.Println("       fazt app share <app>                          (list members)")
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  fazt @zyt app share tetris --user alice@example.com --role editor")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	var app string
+	var flagArgs []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") && app == "" {
+			app = arg
+			flagArgs = args[i+1:]
+			break
+		}
+	}
+
+	if len(flagArgs) == 0 {
+		flagArgs = args
+	}
+	flags.Parse(flagArgs)
+
+	if app == "" {
+		fmt.Println("Error: app is required")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	membersURL := peer.URL + "/api/apps/" + url.PathEscape(app) + "/members"
+
+	var req *http.Request
+	switch {
+	case *userFlag == "":
+		req, _ = http.NewRequest("GET", membersURL, nil)
+	case *removeFlag:
+		req, _ = http.NewRequest("DELETE", membersURL+"/"+url.PathEscape(*userFlag), nil)
+	default:
+		jsonBody, _ := json.Marshal(map[string]string{"email": *userFlag, "role": *roleFlag})
+		req, _ = http.NewRequest("POST", membersURL, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	httpClient := &http.Client{}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	switch {
+	case *userFlag == "":
+		var result struct {
+			Data struct {
+				AppID   string `json:"app_id"`
+				Members []struct {
+					Email string `json:"email"`
+					Name  string `json:"name"`
+					Role  string `json:"role"`
+				} `json:"members"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		table := &output.Table{
+			Headers: []string{"Email", "Name", "Role"},
+			Rows:    [][]string{},
+		}
+		for _, m := range result.Data.Members {
+			table.Rows = append(table.Rows, []string{m.Email, m.Name, m.Role})
+		}
+
+		renderer := getRenderer()
+		renderer.Print(output.NewMarkdown().
+			H1(fmt.Sprintf("Members: %s", app)).
+			Table(table).
+			Para("Server admins have full access to every app.").
+			String(), result.Data)
+	case *removeFlag:
+		fmt.Printf("Removed %s from %s\n", *userFlag, app)
+	default:
+		fmt.Printf("Shared %s with %s as %s\n", app, *userFlag, *roleFlag)
+	}
+}
+
+// handleAppCanary starts, inspects or aborts a canary rollout on an alias
+func handleAppCanary(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "status":
+			handleAppCanaryStatus(args[1:])
+			return
+		case "abort":
+			handleAppCanaryAbort(args[1:])
+			return
+		}
+	}
+
+	flags := flag.NewFlagSet("app canary", flag.ExitOnError)
+	newFlag := flags.String("new", "", "App ID of the new version to roll out")
+	stepFlag := flags.Int("step", 10, "Traffic percentage added per interval")
+	intervalFlag := flags.String("interval", "10m", "Time between steps (e.g., 5m, 1h)")
+	errorsFlag := flags.String("rollback-on-errors", "5%", "Roll back when the canary error rate exceeds this")
+	forceFlag := flags.Bool("force", false, "Roll out even if the alias is protected (asks for the name again)")
+	confirmFlag := flags.String("confirm", "", "Name of the protected alias, instead of the prompt")
+	emergencyFlag := flags.Bool("emergency", false, "Change the alias during a freeze window (audited)")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt app canary <subdomain> --new <app_id> [--step 10] [--interval 10m] [--rollback-on-errors 5%]")
+		fmt.Println("       fazt app canary status <subdomain>")
+		fmt.Println("       fazt app canary abort <subdomain>")
+		fmt.Println()
+		fmt.Println("Example:")
+		fmt.Println("  fazt @zyt app canary tetris --new app_v2 --step 10 --interval 10m --rollback-on-errors 5%")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	var subdomain string
+	var flagArgs []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") && subdomain == "" {
+			subdomain = arg
+			flagArgs = args[i+1:]
+			break
+		}
+	}
+
+	if len(flagArgs) == 0 {
+		flagArgs = args
+	}
+	flags.Parse(flagArgs)
+
+	if subdomain == "" || *newFlag == "" {
+		fmt.Println("Error: subdomain and --new are required")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if _, err := time.ParseDuration(*intervalFlag); err != nil {
+		fmt.Printf("Error: invalid --interval '%s'\n", *intervalFlag)
+		os.Exit(1)
+	}
+
+	maxErrorRate, err := parsePercent(*errorsFlag)
+	if err != nil {
+		fmt.Printf("Error: invalid --rollback-on-errors '%s'\n", *errorsFlag)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"app_id":         *newFlag,
+		"step":           *stepFlag,
+		"interval":       *intervalFlag,
+		"max_error_rate": maxErrorRate,
+	}
+
+	var result struct {
+		Data struct {
+			StableAppID string `json:"stable_app_id"`
+			CanaryAppID string `json:"canary_app_id"`
+			Weight      int    `json:"weight"`
+		} `json:"data"`
+	}
+	query := emergencyQuery(protectQuery(confirmProtected(*forceFlag, *confirmFlag, subdomain)), *emergencyFlag)
+	canaryRequest("POST", subdomain+"/canary"+query, body, &result)
+
+	fmt.Printf("Started canary for %s\n", subdomain)
+	fmt.Printf("  %s: %d%%\n", result.Data.StableAppID, 100-result.Data.Weight)
+	fmt.Printf("  %s: %d%% (+%d%% every %s, rollback above %s errors)\n",
+		result.Data.CanaryAppID, result.Data.Weight, *stepFlag, *intervalFlag, *errorsFlag)
+}
+
+// handleAppCanaryStatus shows the current state of a canary rollout
+func handleAppCanaryStatus(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: fazt app canary status <subdomain>")
+		os.Exit(1)
+	}
+	subdomain := args[0]
+
+	var result struct {
+		Data struct {
+			Canary struct {
+				StableAppID  string  `json:"stable_app_id"`
+				CanaryAppID  string  `json:"canary_app_id"`
+				Step         int     `json:"step"`
+				Weight       int     `json:"weight"`
+				MaxErrorRate float64 `json:"max_error_rate"`
+				Status       string  `json:"status"`
+				Reason       string  `json:"reason"`
+			} `json:"canary"`
+			Stats *struct {
+				Requests  int64   `json:"requests"`
+				Errors    int64   `json:"errors"`
+				ErrorRate float64 `json:"error_rate"`
+			} `json:"stats"`
+			NextStepAt int64 `json:"next_step_at"`
+		} `json:"data"`
+	}
+	canaryRequest("GET", subdomain+"/canary", nil, &result)
+
+	c := result.Data.Canary
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Status", c.Status},
+			{"Stable", c.StableAppID},
+			{"Canary", c.CanaryAppID},
+			{"Weight", fmt.Sprintf("%d%% (+%d%% per step)", c.Weight, c.Step)},
+			{"Rollback above", fmt.Sprintf("%.1f%% errors", c.MaxErrorRate*100)},
+		},
+	}
+	if s := result.Data.Stats; s != nil {
+		table.Rows = append(table.Rows,
+			[]string{"Requests (this step)", fmt.Sprintf("%d", s.Requests)},
+			[]string{"Errors (this step)", fmt.Sprintf("%d (%.1f%%)", s.Errors, s.ErrorRate*100)},
+			[]string{"Next step", time.Unix(result.Data.NextStepAt, 0).Format(time.RFC3339)},
+		)
+	}
+	if c.Reason != "" {
+		table.Rows = append(table.Rows, []string{"Reason", c.Reason})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Canary: %s", subdomain)).
+		Table(table).
+		String(), result.Data)
+}
+
+// handleAppCanaryAbort stops a running canary and restores the stable app
+func handleAppCanaryAbort(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Println("Usage: fazt app canary abort <subdomain>")
+		os.Exit(1)
+	}
+	subdomain := args[0]
+
+	var result struct {
+		Data struct {
+			StableAppID string `json:"stable_app_id"`
+		} `json:"data"`
+	}
+	canaryRequest("DELETE", subdomain+"/canary", nil, &result)
+
+	fmt.Printf("Aborted canary for %s, all traffic back on %s\n", subdomain, result.Data.StableAppID)
+}
+
+// canaryRequest calls the canary API (path under /api/aliases/) on the target
+// peer and decodes the response
+func canaryRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/aliases/"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parsePercent parses "5%" or "0.05" into a 0.0-1.0 fraction
+func parsePercent(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, err
+		}
+		return v / 100, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
 func printAppHelpV2() {
 	// Try markdown-based help first
 	if help.Exists("app") {
@@ -1059,23 +1571,45 @@ REMOTE COMMANDS (support @peer):
   logs <app>            View serverless execution logs (-f to follow)
   install <url>         Install app from git repository
   remove [identifier]   Remove app (--alias, --id, --with-forks)
+  share <app>           Share app with a user (--user, --role, --remove)
+  auth <cmd> <app>      End-user sign-in (enable, disable, list, users)
   upgrade <app>         Upgrade git-sourced app
   link <subdomain>      Link subdomain to app (--id required)
   unlink <subdomain>    Remove alias
   reserve <subdomain>   Reserve/block subdomain
   swap <a1> <a2>        Atomically swap two aliases
   split <subdomain>     Configure traffic splitting (--ids)
+  canary <subdomain>    Gradual rollout with auto-rollback (--new, status, abort)
+  storage ds explain    Show SQL, index and rows scanned for a ds query
+  jobs <cmd> <app>      Background jobs (list, show, cancel)
+  services <cmd> <app>  Long-running services from manifest.json (list, restart, logs)
+  chaos <app>           Inject latency and failures into bindings, dev only (--rate, --off)
+  fixtures <app>        Record and replay fetch responses, dev only (--record, --replay, --save)
+  mail <app>            Sent email and mail settings (--status, --smtp, --daily-limit, --reset)
+  grant <app>           Let an app call another with fazt.call (--to, --scope, --revoke)
+  system <app>          Let an app read server-wide information with fazt.sys (--off)
+  restore <app>         Restore an app's files and storage from a backup (--at, --list)
+  sync <app>            Copy an app to another server, only what changed (--to, --data, --dry-run)
+  export <app>          Write an app out as a static site (--static-site, --route, --ipfs)
   fork                  Fork an app (--alias/--id, --as, --no-storage)
   lineage               Show fork tree (--alias/--id)
+  diff                  Compare a fork with its original (--alias/--id, --against)
+  merge                 Deploy a fork's changes to its original (--into, --force, --dry-run)
+  protect [app]         Guard an app or alias (--alias) against removal and repointing
+  unprotect [app]       Lift protection (--alias for an alias)
 
 LOCAL COMMANDS (no @peer support):
   create <name>         Create local app from template (static, vue, vue-api)
   validate <dir>        Validate local directory before deployment
+  test <dir>            Run *.test.js against api/ handlers (--run, --record, --json)
 
 OPTIONS:
   --alias <name>        Reference app by alias
   --id <app_id>         Reference app by ID
   --with-forks          Delete app and all its forks
+  --force               Remove or repoint a protected app or alias; asks for its name again
+  --confirm <name>      The name for --force, instead of the prompt (for scripts)
+  --emergency           Deploy or change aliases during a freeze window (audited)
 
 GLOBAL FLAGS:
   --verbose             Show detailed output (migrations, debug info)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,6 +10,11 @@ import (
 	"strings"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/handlers"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/services/wasm"
+	"github.com/fazt-sh/fazt/internal/worker"
 )
 
 // ValidationResult holds the result of validating an app
@@ -143,6 +149,199 @@ func validateManifest(dir string, result *ValidationResult) {
 			result.Valid = false
 		}
 	}
+
+	validateEventHandlers(dir, manifest["on"], result)
+	validateServices(dir, data, result)
+	validateForms(data, result)
+	validateCalendar(data, result)
+	validateDocs(data, result)
+	validateRuntime(data, result)
+}
+
+// validateRuntime checks the manifest's "runtime" API version
+func validateRuntime(data []byte, result *ValidationResult) {
+	var manifest struct {
+		Runtime interface{} `json:"runtime"`
+	}
+	json.Unmarshal(data, &manifest)
+
+	switch v := manifest.Runtime.(type) {
+	case nil:
+		result.Warnings = append(result.Warnings, ValidationError{
+			File:    "manifest.json",
+			Message: fmt.Sprintf(`no "runtime": the app runs as %s, with its deprecated bindings (current: %q)`, hosting.RuntimeV0, hosting.CurrentRuntime),
+		})
+	case string:
+		if !hosting.ValidRuntime(v) {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: fmt.Sprintf("unknown runtime %q (expected one of %s)", v, strings.Join(hosting.RuntimeVersions, ", ")),
+			})
+			result.Valid = false
+		}
+	default:
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: fmt.Sprintf(`"runtime" must be a version, e.g. %q`, hosting.CurrentRuntime),
+		})
+		result.Valid = false
+	}
+}
+
+// validateEventHandlers checks the manifest's "on" map of event handlers
+func validateEventHandlers(dir string, on interface{}, result *ValidationResult) {
+	if on == nil {
+		return
+	}
+	handlers, ok := on.(map[string]interface{})
+	if !ok {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: `"on" must map event types to handler files`,
+		})
+		result.Valid = false
+		return
+	}
+
+	for eventType, handler := range handlers {
+		if !events.Valid(eventType) {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: fmt.Sprintf("unknown event %q (expected one of %s)", eventType, strings.Join(events.Types, ", ")),
+			})
+			result.Valid = false
+			continue
+		}
+		path, ok := handler.(string)
+		if !ok || path == "" {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: fmt.Sprintf("handler for %q must be a file path", eventType),
+			})
+			result.Valid = false
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path))); err != nil {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: fmt.Sprintf("handler for %q not found: %s", eventType, path),
+			})
+			result.Valid = false
+		}
+	}
+}
+
+// validateServices checks the manifest's "services": each needs a handler
+// file, and a valid restart policy, health path and memory when given
+func validateServices(dir string, data []byte, result *ValidationResult) {
+	var manifest struct {
+		Services map[string]hosting.AppService `json:"services"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: `"services" must map names to {"handler", "restart", "health", "memory"}`,
+		})
+		result.Valid = false
+		return
+	}
+
+	for name, svc := range manifest.Services {
+		if err := worker.ValidateService(name, svc); err != nil {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: err.Error(),
+			})
+			result.Valid = false
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(svc.Handler))); err != nil {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: fmt.Sprintf("handler for service %q not found: %s", name, svc.Handler),
+			})
+			result.Valid = false
+		}
+	}
+}
+
+// validateForms checks the manifest's "forms": names that can be posted to,
+// and a valid redirect, origins and required fields when given
+func validateForms(data []byte, result *ValidationResult) {
+	var manifest struct {
+		Forms map[string]hosting.AppForm `json:"forms"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: `"forms" must map names to {"fields", "required", "collection", "redirect", "origins", "honeypot", "notify"}`,
+		})
+		result.Valid = false
+		return
+	}
+
+	for name, form := range manifest.Forms {
+		if err := handlers.ValidateForm(name, form); err != nil {
+			result.Errors = append(result.Errors, ValidationError{
+				File:    "manifest.json",
+				Message: err.Error(),
+			})
+			result.Valid = false
+		}
+	}
+}
+
+// validateCalendar checks the manifest's "calendar" feed
+func validateCalendar(data []byte, result *ValidationResult) {
+	var manifest struct {
+		Calendar *hosting.AppCalendar `json:"calendar"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: `"calendar" must be {"collection", "name", "where"}`,
+		})
+		result.Valid = false
+		return
+	}
+	if manifest.Calendar == nil {
+		return
+	}
+
+	if err := handlers.ValidateCalendar(*manifest.Calendar); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: err.Error(),
+		})
+		result.Valid = false
+	}
+}
+
+// validateDocs checks the manifest's "docs": route notes keyed by a method
+// and path, with valid params and responses
+func validateDocs(data []byte, result *ValidationResult) {
+	var manifest struct {
+		Docs *hosting.AppDocs `json:"docs"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: `"docs" must be {"title", "version", "description", "routes"}`,
+		})
+		result.Valid = false
+		return
+	}
+	if manifest.Docs == nil {
+		return
+	}
+
+	if err := handlers.ValidateDocs(*manifest.Docs); err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    "manifest.json",
+			Message: err.Error(),
+		})
+		result.Valid = false
+	}
 }
 
 // validateRequiredFiles checks for required files
@@ -157,7 +356,8 @@ func validateRequiredFiles(dir string, result *ValidationResult) {
 	}
 }
 
-// validateAPIFiles validates JavaScript files in the api/ directory
+// validateAPIFiles validates JavaScript files and WASI handlers in the api/
+// directory
 func validateAPIFiles(dir string, result *ValidationResult) {
 	apiDir := filepath.Join(dir, "api")
 	if _, err := os.Stat(apiDir); os.IsNotExist(err) {
@@ -174,12 +374,13 @@ func validateAPIFiles(dir string, result *ValidationResult) {
 			return nil
 		}
 
-		if !strings.HasSuffix(path, ".js") {
-			return nil
-		}
-
 		relPath, _ := filepath.Rel(dir, path)
-		validateJSFile(path, relPath, result)
+		switch filepath.Ext(path) {
+		case ".js":
+			validateJSFile(path, relPath, result)
+		case ".wasm":
+			validateWASIFile(path, relPath, result)
+		}
 		return nil
 	})
 }
@@ -221,6 +422,16 @@ func validateJSFile(path, relPath string, result *ValidationResult) {
 
 	// Check for handler function
 	code := string(content)
+	for i, line := range strings.Split(code, "\n") {
+		if strings.Contains(line, "fazt.storage.") {
+			result.Warnings = append(result.Warnings, ValidationError{
+				File:    relPath,
+				Line:    i + 1,
+				Message: "fazt.storage.* is deprecated and only exists on runtime v0; use fazt.app.*",
+			})
+			break
+		}
+	}
 	if !strings.Contains(code, "function handler") && !strings.Contains(code, "handler =") {
 		result.Warnings = append(result.Warnings, ValidationError{
 			File:    relPath,
@@ -229,6 +440,27 @@ func validateJSFile(path, relPath string, result *ValidationResult) {
 	}
 }
 
+// validateWASIFile checks that a .wasm route is a WASI command module.
+// Modules under _-prefixed directories aren't routes.
+func validateWASIFile(path, relPath string, result *ValidationResult) {
+	for _, part := range strings.Split(filepath.ToSlash(relPath), "/") {
+		if strings.HasPrefix(part, "_") {
+			return
+		}
+	}
+	code, err := os.ReadFile(path)
+	if err == nil {
+		err = wasm.ValidateHandler(context.Background(), code)
+	}
+	if err != nil {
+		result.Errors = append(result.Errors, ValidationError{
+			File:    relPath,
+			Message: err.Error(),
+		})
+		result.Valid = false
+	}
+}
+
 // printValidationResult prints validation results in a human-readable format
 func printValidationResult(result *ValidationResult, dir string) {
 	fmt.Printf("Validating %s...\n\n", dir)
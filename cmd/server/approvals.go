@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/approval"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+func handleApprovalsCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("approvals", printApprovalsUsage)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		handleApprovalsList(args[1:])
+	case "approve":
+		handleApprovalDecision("approve", args[1:])
+	case "reject":
+		handleApprovalDecision("reject", args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("approvals", printApprovalsUsage)
+	default:
+		fmt.Printf("Unknown approvals subcommand: %s\n", args[0])
+		printApprovalsUsage()
+		os.Exit(1)
+	}
+}
+
+func printApprovalsUsage() {
+	fmt.Println("fazt approvals - Four-eyes approval of destructive operations")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] approvals <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  list                        List pending approvals")
+	fmt.Println("  approve <id>                Approve and run a pending operation")
+	fmt.Println("  reject <id>                 Reject a pending operation (or withdraw your own)")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --all                       List decided and expired approvals too")
+	fmt.Println()
+	fmt.Println("With approvals required (fazt server set-config --require-approval true),")
+	fmt.Println("app removal, user deletion and config changes are queued until another")
+	fmt.Println("admin approves them. Requests expire after 7 days.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt approvals list")
+	fmt.Println("  fazt @zyt approvals approve 12")
+}
+
+func handleApprovalsList(args []string) {
+	fs := flag.NewFlagSet("approvals list", flag.ExitOnError)
+	allFlag := fs.Bool("all", false, "Include decided and expired approvals")
+	fs.Parse(args)
+
+	query := "?status=" + approval.StatusPending
+	if *allFlag {
+		query = ""
+	}
+
+	var result struct {
+		Data struct {
+			Approvals []approval.Approval `json:"approvals"`
+			Required  bool                `json:"required"`
+		} `json:"data"`
+	}
+	approvalsRequest("GET", query, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Operation", "Summary", "Requested By", "Requested", "Status"},
+		Rows:    [][]string{},
+	}
+	for _, a := range result.Data.Approvals {
+		status := a.Status
+		if a.DecidedBy != "" {
+			status += " by " + a.DecidedBy
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(a.ID, 10),
+			a.Operation,
+			a.Summary,
+			a.RequestedBy,
+			time.Unix(a.CreatedAt, 0).Format("Mon Jan 2 15:04"),
+			status,
+		})
+	}
+
+	md := output.NewMarkdown().H1("Approvals").Table(table)
+	if !result.Data.Required {
+		md = md.Para("Approvals are not required on this server.")
+	}
+
+	renderer := getRenderer()
+	renderer.Print(md.String(), result.Data)
+}
+
+func handleApprovalDecision(action string, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: approval ID required")
+		fmt.Fprintf(os.Stderr, "Usage: fazt approvals %s <id>\n", action)
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid approval ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data approval.Approval `json:"data"`
+	}
+	approvalsRequest("POST", "/"+args[0]+"/"+action, &result)
+
+	a := result.Data
+	switch a.Status {
+	case approval.StatusExecuted:
+		fmt.Printf("Approved #%d: %s\n", a.ID, a.Summary)
+	case approval.StatusFailed:
+		fmt.Fprintf(os.Stderr, "Approved #%d, but it failed: %s\n", a.ID, a.Error)
+		os.Exit(1)
+	default:
+		fmt.Printf("Rejected #%d: %s\n", a.ID, a.Summary)
+	}
+}
+
+// approvalsRequest calls the peer's /api/approvals endpoint and decodes the response
+func approvalsRequest(method, path string, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/approvals"+path, bytes.NewReader(nil))
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
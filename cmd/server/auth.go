@@ -11,6 +11,7 @@ import (
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/output"
 	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/security"
 )
 
 // handleAuthCommand handles auth-related subcommands
@@ -311,6 +312,14 @@ func handleAuthUser(args []string) {
 			fmt.Printf("Error: %v\n", err)
 			os.Exit(1)
 		}
+		if user.Role != *role {
+			security.Record(database.GetDB(), security.Event{
+				Type:    security.EventRoleChanged,
+				Actor:   "cli",
+				Subject: user.Email,
+				Details: map[string]interface{}{"user_id": user.ID, "from": user.Role, "to": *role},
+			})
+		}
 		fmt.Printf("User '%s' role updated to '%s'.\n", user.Email, *role)
 		user.Role = *role
 	}
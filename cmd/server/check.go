@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+func handleCheckCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("check", printCheckUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handleCheckAdd(args[1:])
+	case "list":
+		handleCheckList(args[1:])
+	case "runs":
+		handleCheckRuns(args[1:])
+	case "delete":
+		handleCheckDelete(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("check", printCheckUsage)
+	default:
+		fmt.Printf("Unknown check subcommand: %s\n", args[0])
+		printCheckUsage()
+		os.Exit(1)
+	}
+}
+
+func printCheckUsage() {
+	fmt.Println("fazt check - Synthetic transaction checks")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] check <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <site> <name>           Run a deployed JS script as a check")
+	fmt.Println("  list                        List checks with their latest result")
+	fmt.Println("  runs <id>                   Show a check's recent runs")
+	fmt.Println("  delete <id>                 Remove a check and its runs")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --script <path>             Script path within the site, e.g. checks/login.js")
+	fmt.Println("  --every <d>                 Run interval (default 5m, min 1m)")
+	fmt.Println("  --timeout <d>               Per-run timeout (default 30s, max 5m)")
+	fmt.Println("  --limit <n>                 Runs to show (default 20)")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt check add shop checkout --script checks/checkout.js")
+	fmt.Println("  fazt @zyt check add shop login --script checks/login.js --every 1m --timeout 10s")
+	fmt.Println("  fazt @zyt check runs 1")
+}
+
+func handleCheckAdd(args []string) {
+	fs := flag.NewFlagSet("check add", flag.ExitOnError)
+	scriptFlag := fs.String("script", "", "Script path within the site")
+	everyFlag := fs.String("every", "5m", "Run interval")
+	timeoutFlag := fs.String("timeout", "30s", "Per-run timeout")
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Error: site and name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt check add <site> <name> --script <path> [--every <d>] [--timeout <d>]")
+		os.Exit(1)
+	}
+	site, name := args[0], args[1]
+	fs.Parse(args[2:])
+
+	if *scriptFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --script is required")
+		os.Exit(1)
+	}
+	every, err := parseDurationValue(*everyFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --every: %v\n", err)
+		os.Exit(1)
+	}
+	timeout, err := time.ParseDuration(*timeoutFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --timeout: %v\n", err)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"site":             site,
+		"name":             name,
+		"script":           *scriptFlag,
+		"interval_seconds": int64(every.Seconds()),
+		"timeout_seconds":  int64(timeout.Seconds()),
+	}
+
+	var result struct {
+		Data status.Synthetic `json:"data"`
+	}
+	checkRequest("POST", "", body, &result)
+
+	fmt.Printf("Check %d added: %s on %s runs %s every %s\n", result.Data.ID, result.Data.Name, result.Data.Site,
+		result.Data.Script, time.Duration(result.Data.IntervalSeconds)*time.Second)
+}
+
+func handleCheckList(args []string) {
+	var result struct {
+		Data struct {
+			Synthetics []status.Synthetic `json:"synthetics"`
+		} `json:"data"`
+	}
+	checkRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Site", "Name", "Script", "Every", "Result", "Last Run"},
+		Rows:    [][]string{},
+	}
+	for _, c := range result.Data.Synthetics {
+		res, last := "-", "never"
+		if c.LastRun != nil {
+			res = formatRunResult(*c.LastRun)
+			last = formatTime(time.Unix(c.LastRun.RanAt, 0))
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(c.ID, 10),
+			c.Site,
+			c.Name,
+			c.Script,
+			(time.Duration(c.IntervalSeconds) * time.Second).String(),
+			res,
+			last,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Synthetic Checks").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleCheckRuns(args []string) {
+	fs := flag.NewFlagSet("check runs", flag.ExitOnError)
+	limitFlag := fs.Int("limit", 20, "Runs to show")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: check ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt check runs <id> [--limit <n>]")
+		os.Exit(1)
+	}
+	id := args[0]
+	fs.Parse(args[1:])
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid check ID %q\n", id)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data struct {
+			Synthetic status.Synthetic      `json:"synthetic"`
+			Runs      []status.SyntheticRun `json:"runs"`
+		} `json:"data"`
+	}
+	checkRequest("GET", fmt.Sprintf("/%s/runs?limit=%d", id, *limitFlag), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Ran", "Result", "Duration", "Error"},
+		Rows:    [][]string{},
+	}
+	for _, run := range result.Data.Runs {
+		table.Rows = append(table.Rows, []string{
+			formatTime(time.Unix(run.RanAt, 0)),
+			formatRunResult(run),
+			fmt.Sprintf("%dms", run.DurationMs),
+			run.Error,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("%s on %s", result.Data.Synthetic.Name, result.Data.Synthetic.Site)).
+		Table(table).
+		String(), result.Data)
+}
+
+func formatRunResult(run status.SyntheticRun) string {
+	if run.OK {
+		return "pass"
+	}
+	return "FAIL"
+}
+
+func handleCheckDelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: check ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt check delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid check ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	checkRequest("DELETE", "/"+args[0], nil, &result)
+	fmt.Printf("Check %s deleted\n", args[0])
+}
+
+// checkRequest calls the peer's /api/status/synthetics endpoint and decodes the response
+func checkRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/status/synthetics"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
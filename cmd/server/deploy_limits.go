@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/output"
+)
+
+func handleDeployLimitsCommand(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			args = args[1:]
+		case "set":
+			handleDeployLimitsSet(args[1:])
+			return
+		case "clear":
+			handleDeployLimitsClear(args[1:])
+			return
+		case "--help", "-h", "help":
+			showCommandHelp("deploy-limits", printDeployLimitsUsage)
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown deploy-limits command: %s\n\n", args[0])
+			printDeployLimitsUsage()
+			os.Exit(1)
+		}
+	}
+
+	var result struct {
+		Data struct {
+			Global    hosting.DeployLimits          `json:"global"`
+			Overrides []hosting.DeployLimitOverride `json:"overrides"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/system/deploy-limits", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Scope", "Name", "Bundle", "Files", "Per File"},
+		Rows:    [][]string{deployLimitsRow("global", "-", result.Data.Global, "unlimited")},
+	}
+	for _, o := range result.Data.Overrides {
+		table.Rows = append(table.Rows, deployLimitsRow(o.Scope, o.Name, o.DeployLimits, "-"))
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Deploy Limits").
+		Table(table).
+		String(), result.Data)
+}
+
+// deployLimitsRow renders limits, showing unset ones as unset
+func deployLimitsRow(scope, name string, l hosting.DeployLimits, unset string) []string {
+	cell := func(n int64, format func(int64) string) string {
+		if n == 0 {
+			return unset
+		}
+		return format(n)
+	}
+	count := func(n int64) string { return strconv.FormatInt(n, 10) }
+	return []string{
+		scope,
+		name,
+		cell(l.MaxBundleBytes, formatBytes),
+		cell(int64(l.MaxFiles), count),
+		cell(l.MaxFileBytes, formatBytes),
+	}
+}
+
+func printDeployLimitsUsage() {
+	fmt.Println("fazt deploy-limits - Deploy size and file-count limits")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] deploy-limits [list]")
+	fmt.Println("  fazt [@peer] deploy-limits set <app|user> <name> [--bundle <size>] [--files <n>] [--file <size>]")
+	fmt.Println("  fazt [@peer] deploy-limits clear <app|user> <name>")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  list                        Global limits and overrides (default)")
+	fmt.Println("  set <scope> <name>          Set an app's or a deployer's limits")
+	fmt.Println("  clear <scope> <name>        Remove an app's or a deployer's limits")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --bundle <size>             Total size of the files, uncompressed, e.g. 200MB")
+	fmt.Println("  --files <n>                 Number of files")
+	fmt.Println("  --file <size>               Largest single file, e.g. 20MB")
+	fmt.Println()
+	fmt.Println("Apps are named as deployed to; deployers as api_key:<name> or by user ID.")
+	fmt.Println("Each limit is the app's if set, else the deployer's, else the global one")
+	fmt.Println("(fazt server set-config --deploy-limits).")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt deploy-limits")
+	fmt.Println("  fazt @zyt deploy-limits set app blog --bundle 2GB --files 50000")
+	fmt.Println("  fazt @zyt deploy-limits set user api_key:ci --bundle 50MB")
+}
+
+func handleDeployLimitsSet(args []string) {
+	fs := flag.NewFlagSet("deploy-limits set", flag.ExitOnError)
+	bundleFlag := fs.String("bundle", "", "Total size of the files")
+	filesFlag := fs.Int("files", 0, "Number of files")
+	fileFlag := fs.String("file", "", "Largest single file")
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Error: scope and name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt deploy-limits set <app|user> <name> [--bundle <size>] [--files <n>] [--file <size>]")
+		os.Exit(1)
+	}
+	scope, name := args[0], args[1]
+	fs.Parse(args[2:])
+
+	bundleBytes, err := parseByteSize(*bundleFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --bundle: %v\n", err)
+		os.Exit(1)
+	}
+	fileBytes, err := parseByteSize(*fileFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --file: %v\n", err)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data hosting.DeployLimitOverride `json:"data"`
+	}
+	peerRequest("PUT", "/api/system/deploy-limits/"+scope+"/"+name, hosting.DeployLimits{
+		MaxBundleBytes: bundleBytes,
+		MaxFiles:       *filesFlag,
+		MaxFileBytes:   fileBytes,
+	}, &result)
+
+	fmt.Printf("Deploy limits set for %s %s\n", result.Data.Scope, result.Data.Name)
+}
+
+func handleDeployLimitsClear(args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: scope and name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt deploy-limits clear <app|user> <name>")
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	peerRequest("DELETE", "/api/system/deploy-limits/"+args[0]+"/"+args[1], nil, &result)
+	fmt.Printf("Deploy limits removed for %s %s\n", args[0], args[1])
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/failover"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+func handleFailoverCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("failover", printFailoverUsage)
+		return
+	}
+
+	switch args[0] {
+	case "status":
+		handleFailoverStatus(args[1:])
+	case "standby":
+		handleFailoverStandby(args[1:])
+	case "sync":
+		handleFailoverSync(args[1:])
+	case "dns":
+		handleFailoverDNS(args[1:])
+	case "promote":
+		handleFailoverPromote(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("failover", printFailoverUsage)
+	default:
+		fmt.Printf("Unknown failover subcommand: %s\n", args[0])
+		printFailoverUsage()
+		os.Exit(1)
+	}
+}
+
+func printFailoverUsage() {
+	fmt.Println("fazt failover - Replicate apps to a standby peer and promote it in a disaster")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] failover <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  status                  Show the role, standby and last replica")
+	fmt.Println("  standby <peer>          Make <peer> the standby and send it a first replica")
+	fmt.Println("  standby --off           Stop replicating")
+	fmt.Println("  sync                    Send the standby a replica now")
+	fmt.Println("  dns --provider <p> ...  Set the DNS records the standby repoints when promoted")
+	fmt.Println("  dns --off               Remove the DNS settings")
+	fmt.Println("  promote <peer>          Make <peer> the primary and repoint DNS at it")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Printf("  --provider <p>          DNS provider: %s (dns)\n", strings.Join(failover.DNSProviderNames(), ", "))
+	fmt.Println("  --set key=value         A provider setting, repeatable (dns)")
+	fmt.Println("  --records <a,b>         Records to repoint, comma-separated (dns)")
+	fmt.Println("  --ip <addr>             Address the records point at once promoted (dns)")
+	fmt.Println("  --token <key>           The standby's API key for replicas (standby, default: the peer's)")
+	fmt.Println()
+	fmt.Println("The primary sends its apps, files and storage to the standby every")
+	fmt.Println("minute when something changed. Users, API keys and settings stay with")
+	fmt.Println("each server. A promoted standby refuses replicas, so an old primary")
+	fmt.Println("that comes back can't overwrite it.")
+	fmt.Println()
+	fmt.Println("PROVIDER SETTINGS:")
+	fmt.Println("  cloudflare              zone_id, api_token (with DNS edit permission)")
+	fmt.Println("  webhook                 url, secret (optional); POSTs {name, type, ip}")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt failover standby backup")
+	fmt.Println("  fazt @backup failover dns --provider cloudflare --set zone_id=abc \\")
+	fmt.Println("      --set api_token=xyz --records example.com,*.example.com --ip 203.0.113.7")
+	fmt.Println("  fazt failover promote backup")
+}
+
+// dnsSettings collects repeated --set key=value flags
+type dnsSettings map[string]string
+
+func (d dnsSettings) String() string {
+	return fmt.Sprintf("%d settings", len(d))
+}
+
+func (d dnsSettings) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	d[strings.TrimSpace(key)] = val
+	return nil
+}
+
+func handleFailoverStatus(args []string) {
+	var result struct {
+		Data failover.State `json:"data"`
+	}
+	peerRequest("GET", "/api/failover", nil, &result)
+	s := result.Data
+
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows:    [][]string{{"Role", s.Role}},
+	}
+	if s.Role == failover.RolePrimary {
+		standby, last := "-", "never"
+		if s.StandbyURL != "" {
+			standby = s.StandbyURL
+		}
+		if s.LastSyncAt != nil {
+			last = fmt.Sprintf("%s (%s)", formatTime(time.Unix(*s.LastSyncAt, 0)), formatBytes(s.LastSyncBytes))
+		}
+		table.Rows = append(table.Rows, []string{"Standby", standby}, []string{"Last replica sent", last})
+		if s.LastSyncError != "" {
+			table.Rows = append(table.Rows, []string{"Last error", s.LastSyncError})
+		}
+	} else {
+		last := "never"
+		if s.ReplicaAt != nil {
+			last = fmt.Sprintf("%s (%d apps)", formatTime(time.Unix(*s.ReplicaAt, 0)), s.ReplicaApps)
+		}
+		table.Rows = append(table.Rows, []string{"Last replica received", last})
+	}
+	if s.PromotedAt != nil {
+		table.Rows = append(table.Rows, []string{"Promoted", time.Unix(*s.PromotedAt, 0).Format(time.RFC3339)})
+	}
+	dns := "-"
+	if s.DNS.Provider != "" {
+		dns = fmt.Sprintf("%s: %s -> %s", s.DNS.Provider, strings.Join(s.DNS.Records, ", "), s.DNS.IP)
+	}
+	table.Rows = append(table.Rows, []string{"DNS", dns})
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("Failover").
+		Table(table).
+		String(), s)
+}
+
+func handleFailoverStandby(args []string) {
+	fs := flag.NewFlagSet("failover standby", flag.ExitOnError)
+	offFlag := fs.Bool("off", false, "Stop replicating")
+	tokenFlag := fs.String("token", "", "The standby's API key for replicas")
+
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name = args[0]
+		args = args[1:]
+	}
+	fs.Parse(args)
+
+	if *offFlag {
+		var result map[string]interface{}
+		peerRequest("PUT", "/api/failover/standby", map[string]string{"url": ""}, &result)
+		fmt.Println("Replication stopped; the standby keeps the apps it has")
+		return
+	}
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "Error: standby peer required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt [@peer] failover standby <peer> [--token <key>]")
+		os.Exit(1)
+	}
+
+	db := getClientDB()
+	standby, err := remote.ResolvePeer(db, name)
+	database.Close()
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+	if standby.Token == "" && *tokenFlag == "" {
+		fmt.Fprintf(os.Stderr, "Error: peer %s has no API key; pass --token\n", name)
+		os.Exit(1)
+	}
+	token := standby.Token
+	if *tokenFlag != "" {
+		token = *tokenFlag
+	}
+
+	var result map[string]interface{}
+	peerRequestTo(name, "PUT", "/api/failover/role", map[string]string{"role": failover.RoleStandby}, &result)
+	peerRequest("PUT", "/api/failover/standby", map[string]string{"url": standby.URL, "token": token}, &result)
+	fmt.Printf("%s is now the standby; sending a first replica...\n", name)
+
+	var sync struct {
+		Data struct {
+			SentBytes int64 `json:"sent_bytes"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/failover/sync", nil, &sync)
+	fmt.Printf("Sent %s. Changes are sent every minute.\n", formatBytes(sync.Data.SentBytes))
+}
+
+func handleFailoverSync(args []string) {
+	var result struct {
+		Data struct {
+			SentBytes int64 `json:"sent_bytes"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/failover/sync", nil, &result)
+	if result.Data.SentBytes == 0 {
+		fmt.Println("No standby set; nothing sent")
+		return
+	}
+	fmt.Printf("Sent %s to the standby\n", formatBytes(result.Data.SentBytes))
+}
+
+func handleFailoverDNS(args []string) {
+	fs := flag.NewFlagSet("failover dns", flag.ExitOnError)
+	providerFlag := fs.String("provider", "", "DNS provider")
+	recordsFlag := fs.String("records", "", "Records to repoint, comma-separated")
+	ipFlag := fs.String("ip", "", "Address the records point at")
+	offFlag := fs.Bool("off", false, "Remove the DNS settings")
+	settings := dnsSettings{}
+	fs.Var(settings, "set", "A provider setting, key=value")
+	fs.Parse(args)
+
+	body := map[string]interface{}{}
+	if !*offFlag {
+		if *providerFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: --provider is required, or --off")
+			fmt.Fprintln(os.Stderr, "Usage: fazt [@peer] failover dns --provider <p> --set key=value --records <a,b> --ip <addr>")
+			os.Exit(1)
+		}
+		var records []string
+		for _, r := range strings.Split(*recordsFlag, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				records = append(records, r)
+			}
+		}
+		body = map[string]interface{}{
+			"provider": *providerFlag,
+			"settings": map[string]string(settings),
+			"records":  records,
+			"ip":       *ipFlag,
+		}
+	}
+
+	var result map[string]interface{}
+	peerRequest("PUT", "/api/failover/dns", body, &result)
+	if *offFlag {
+		fmt.Println("DNS settings removed; promoting won't change DNS")
+		return
+	}
+	fmt.Printf("Promoting will point %s at %s through %s\n", strings.Join(body["records"].([]string), ", "), *ipFlag, *providerFlag)
+}
+
+func handleFailoverPromote(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: peer to promote required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt failover promote <peer>")
+		os.Exit(1)
+	}
+	name := args[0]
+
+	var result struct {
+		Data struct {
+			Records  []failover.RecordResult `json:"records"`
+			DNSError string                  `json:"dns_error"`
+		} `json:"data"`
+	}
+	peerRequestTo(name, "POST", "/api/failover/promote", nil, &result)
+
+	fmt.Printf("%s is now the primary\n", name)
+	if result.Data.DNSError != "" {
+		fmt.Printf("DNS not updated: %s\n", result.Data.DNSError)
+	}
+	failed := 0
+	for _, r := range result.Data.Records {
+		if r.Error != "" {
+			failed++
+			fmt.Printf("  %s: %s\n", r.Name, r.Error)
+		} else {
+			fmt.Printf("  %s: repointed\n", r.Name)
+		}
+	}
+	if failed > 0 || result.Data.DNSError != "" {
+		fmt.Println("Update the records above by hand.")
+	}
+	fmt.Printf("Make it the default peer with: fazt peer default %s\n", name)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/freeze"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+func handleFreezeCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("freeze", printFreezeUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handleFreezeAdd(args[1:])
+	case "list":
+		handleFreezeList(args[1:])
+	case "delete":
+		handleFreezeDelete(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("freeze", printFreezeUsage)
+	default:
+		fmt.Printf("Unknown freeze subcommand: %s\n", args[0])
+		printFreezeUsage()
+		os.Exit(1)
+	}
+}
+
+func printFreezeUsage() {
+	fmt.Println("fazt freeze - Deploy freeze windows")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] freeze <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <name>                  Define a freeze window")
+	fmt.Println("  list                        List freeze windows and which are in effect")
+	fmt.Println("  delete <id>                 Remove a freeze window")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --schedule <cron>           When the freeze starts: minute hour day month weekday")
+	fmt.Println("                              (server local time)")
+	fmt.Println("  --for <duration>            How long it lasts, e.g. 2h, 64h, 1d (max 7d)")
+	fmt.Println("  --tags <t1,t2>              Only freeze apps with one of these tags (default: all apps)")
+	fmt.Println()
+	fmt.Println("During a freeze, deploys and alias changes to the apps it covers are refused")
+	fmt.Println("unless run with --emergency, which is recorded in the activity log.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt freeze add weekend --schedule \"0 18 * * 5\" --for 64h")
+	fmt.Println("  fazt @zyt freeze add demo-day --schedule \"0 9 20 11 *\" --for 10h --tags demo")
+	fmt.Println("  fazt @zyt freeze list")
+}
+
+func handleFreezeAdd(args []string) {
+	fs := flag.NewFlagSet("freeze add", flag.ExitOnError)
+	scheduleFlag := fs.String("schedule", "", "Cron schedule the freeze starts on")
+	forFlag := fs.String("for", "", "How long the freeze lasts, e.g. 2h")
+	tagsFlag := fs.String("tags", "", "Comma-separated app tags (default: all apps)")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt freeze add <name> --schedule <cron> --for <duration> [--tags <t1,t2>]")
+		os.Exit(1)
+	}
+	name := args[0]
+	fs.Parse(args[1:])
+
+	if *scheduleFlag == "" || *forFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --schedule and --for are required")
+		os.Exit(1)
+	}
+	if _, err := freeze.ParseSchedule(*scheduleFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	d, err := parseDurationValue(*forFlag)
+	if err != nil || d < time.Minute || d%time.Minute != 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --for %q (use whole minutes, e.g. 90m or 2h)\n", *forFlag)
+		os.Exit(1)
+	}
+
+	cfg := freeze.Config{
+		Name:            name,
+		Schedule:        *scheduleFlag,
+		DurationMinutes: int(d / time.Minute),
+	}
+	if *tagsFlag != "" {
+		cfg.Tags = strings.Split(*tagsFlag, ",")
+	}
+
+	var result struct {
+		Data freeze.Window `json:"data"`
+	}
+	freezeRequest("POST", "", cfg, &result)
+
+	scope := "all apps"
+	if len(result.Data.Tags) > 0 {
+		scope = "apps tagged " + strings.Join(result.Data.Tags, ", ")
+	}
+	fmt.Printf("Freeze %d added: %s, %s (%s)\n", result.Data.ID, result.Data.Name, result.Data.Describe(), scope)
+	if result.Data.ActiveUntil != nil {
+		fmt.Printf("In effect now, until %s\n", time.Unix(*result.Data.ActiveUntil, 0).Format("Mon Jan 2 15:04"))
+	}
+}
+
+func handleFreezeList(args []string) {
+	var result struct {
+		Data struct {
+			Freezes []freeze.Window `json:"freezes"`
+		} `json:"data"`
+	}
+	freezeRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Name", "Schedule", "Tags", "Status"},
+		Rows:    [][]string{},
+	}
+	for _, w := range result.Data.Freezes {
+		tags := "(all apps)"
+		if len(w.Tags) > 0 {
+			tags = strings.Join(w.Tags, ",")
+		}
+		status := "-"
+		if w.ActiveUntil != nil {
+			status = "frozen until " + time.Unix(*w.ActiveUntil, 0).Format("Mon Jan 2 15:04")
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(w.ID, 10),
+			w.Name,
+			w.Describe(),
+			tags,
+			status,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Freeze Windows").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleFreezeDelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: freeze window ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt freeze delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid freeze window ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	freezeRequest("DELETE", "/"+args[0], nil, &result)
+	fmt.Printf("Freeze %s deleted\n", args[0])
+}
+
+// emergencyQuery adds emergency=true to a request's query string when set
+func emergencyQuery(query string, emergency bool) string {
+	if !emergency {
+		return query
+	}
+	if query == "" {
+		return "?emergency=true"
+	}
+	return query + "&emergency=true"
+}
+
+// emergencyArgs is the command gateway flag for changes during a freeze
+func emergencyArgs(emergency bool) []string {
+	if !emergency {
+		return nil
+	}
+	return []string{"--emergency"}
+}
+
+// freezeRequest calls the peer's /api/freezes endpoint and decodes the response
+func freezeRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/freezes"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/heartbeats"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/output"
+)
+
+func handleHeartbeatCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("heartbeat", printHeartbeatUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handleHeartbeatAdd(args[1:])
+	case "list":
+		handleHeartbeatList(args[1:])
+	case "pause":
+		handleHeartbeatSetEnabled(args[1:], false)
+	case "resume":
+		handleHeartbeatSetEnabled(args[1:], true)
+	case "remove":
+		handleHeartbeatRemove(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("heartbeat", printHeartbeatUsage)
+	default:
+		fmt.Printf("Unknown heartbeat subcommand: %s\n", args[0])
+		printHeartbeatUsage()
+		os.Exit(1)
+	}
+}
+
+func printHeartbeatUsage() {
+	fmt.Println("fazt heartbeat - Check-ins from scripts, with alerts when one is missed")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] heartbeat <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <name> --every <d>  Create a heartbeat and print its check-in URL")
+	fmt.Println("  list                    List heartbeats, their status and URLs")
+	fmt.Println("  pause <name>            Stop alerting on missed check-ins")
+	fmt.Println("  resume <name>           Alert again, from the next check-in")
+	fmt.Println("  remove <name>           Delete a heartbeat; its URL stops working")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --every <d>             Expected time between check-ins, 1m to 35d (add, required)")
+	fmt.Println("  --grace <d>             How late a check-in may be (add, default: 5m)")
+	fmt.Println()
+	fmt.Println("Scripts check in with a GET or POST to the URL. A heartbeat is watched")
+	fmt.Println("from its first check-in; a heartbeat.missed notification is sent when")
+	fmt.Println("one is missed and again when check-ins resume.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt heartbeat add nightly-backup --every 1d --grace 1h")
+	fmt.Println("  restic backup /data && curl -fsS -m 10 https://admin.example.com/api/heartbeats/<token>")
+}
+
+func handleHeartbeatAdd(args []string) {
+	fs := flag.NewFlagSet("heartbeat add", flag.ExitOnError)
+	everyFlag := fs.String("every", "", "Expected time between check-ins")
+	graceFlag := fs.String("grace", "5m", "How late a check-in may be")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: heartbeat name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt heartbeat add <name> --every <d> [--grace <d>]")
+		os.Exit(1)
+	}
+	name := args[0]
+	fs.Parse(args[1:])
+
+	if *everyFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --every is required, e.g. --every 1d")
+		os.Exit(1)
+	}
+	period, err := hosting.ParseExpiry(*everyFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --every %q: use e.g. 15m, 6h or 1d\n", *everyFlag)
+		os.Exit(1)
+	}
+	grace, err := hosting.ParseExpiry(*graceFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --grace %q: use e.g. 5m, 1h or 1d\n", *graceFlag)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"name":           name,
+		"period_seconds": int64(period.Seconds()),
+		"grace_seconds":  int64(grace.Seconds()),
+	}
+
+	var result struct {
+		Data heartbeats.Heartbeat `json:"data"`
+	}
+	peerRequest("POST", "/api/heartbeats", body, &result)
+
+	fmt.Printf("Heartbeat %s added: every %s, grace %s\n", result.Data.Name,
+		formatSeconds(result.Data.PeriodSeconds), formatSeconds(result.Data.GraceSeconds))
+	fmt.Println()
+	fmt.Println("Check in from your script with:")
+	fmt.Printf("  curl -fsS -m 10 %s\n", result.Data.URL)
+}
+
+func handleHeartbeatList(args []string) {
+	var result struct {
+		Data struct {
+			Heartbeats []heartbeats.Heartbeat `json:"heartbeats"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/heartbeats", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Name", "Status", "Every", "Grace", "Last Check-in", "URL"},
+		Rows:    [][]string{},
+	}
+	for _, h := range result.Data.Heartbeats {
+		status := h.Status
+		if !h.Enabled {
+			status = "paused"
+		}
+		last := "never"
+		if h.LastCheckinAt != nil {
+			last = formatTime(time.Unix(*h.LastCheckinAt, 0))
+		}
+		table.Rows = append(table.Rows, []string{
+			h.Name,
+			status,
+			formatSeconds(h.PeriodSeconds),
+			formatSeconds(h.GraceSeconds),
+			last,
+			h.URL,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("Heartbeats").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleHeartbeatSetEnabled(args []string, enabled bool) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: heartbeat name required")
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data heartbeats.Heartbeat `json:"data"`
+	}
+	peerRequest("PATCH", heartbeatPath(args[0]), map[string]interface{}{"enabled": enabled}, &result)
+
+	if enabled {
+		fmt.Printf("Heartbeat %s resumed; watched again from its next check-in\n", result.Data.Name)
+	} else {
+		fmt.Printf("Heartbeat %s paused\n", result.Data.Name)
+	}
+}
+
+func handleHeartbeatRemove(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: heartbeat name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt heartbeat remove <name>")
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	peerRequest("DELETE", heartbeatPath(args[0]), nil, &result)
+	fmt.Printf("Heartbeat %s removed\n", args[0])
+}
+
+// formatSeconds shows a period as "1d", "6h", "90m" or "45s"
+func formatSeconds(s int64) string {
+	switch {
+	case s > 0 && s%86400 == 0:
+		return fmt.Sprintf("%dd", s/86400)
+	case s > 0 && s%3600 == 0:
+		return fmt.Sprintf("%dh", s/3600)
+	case s > 0 && s%60 == 0:
+		return fmt.Sprintf("%dm", s/60)
+	default:
+		return fmt.Sprintf("%ds", s)
+	}
+}
+
+func heartbeatPath(name string) string {
+	return "/api/heartbeats/" + url.PathEscape(name)
+}
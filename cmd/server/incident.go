@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+func handleIncidentCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("incident", printIncidentUsage)
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		handleIncidentCreate(args[1:])
+	case "maintenance":
+		handleIncidentMaintenance(args[1:])
+	case "update":
+		handleIncidentUpdate(args[1:], "")
+	case "resolve":
+		handleIncidentUpdate(args[1:], status.IncidentResolved)
+	case "list":
+		handleIncidentList(args[1:])
+	case "delete":
+		handleIncidentDelete(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("incident", printIncidentUsage)
+	default:
+		fmt.Printf("Unknown incident subcommand: %s\n", args[0])
+		printIncidentUsage()
+		os.Exit(1)
+	}
+}
+
+func printIncidentUsage() {
+	fmt.Println("fazt incident - Status page incident log")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] incident <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  create <title>              Open an incident")
+	fmt.Println("  maintenance <title>         Schedule a maintenance window")
+	fmt.Println("  update <id> --message <m>   Post an update")
+	fmt.Println("  resolve <id>                Resolve an incident")
+	fmt.Println("  list                        List open and recent incidents")
+	fmt.Println("  delete <id>                 Remove an incident from the log")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --severity <s>              minor (default), major, or maintenance (create)")
+	fmt.Println("  --site <a,b,...>            Affected sites (create, maintenance; default: whole server)")
+	fmt.Println("  --start <time>              Window start: now (default), 2026-01-02 15:04, or RFC 3339 (maintenance)")
+	fmt.Println("  --duration <d>              Window length, e.g. 30m, 2h, 1d (maintenance)")
+	fmt.Println("  --end <time>                Window end, instead of --duration (maintenance)")
+	fmt.Println("  --status <s>                investigating, identified, monitoring, resolved (update)")
+	fmt.Println("  --message <text>            Update message")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt incident create \"Slow deploys\" --severity minor --site blog")
+	fmt.Println("  fazt @zyt incident update 1 --status identified --message \"Disk is full\"")
+	fmt.Println("  fazt @zyt incident resolve 1 --message \"Disk cleaned up\"")
+	fmt.Println("  fazt @zyt incident maintenance \"Database upgrade\" --start \"2026-01-02 02:00\" --duration 1h --site blog,wiki")
+}
+
+func handleIncidentCreate(args []string) {
+	fs := flag.NewFlagSet("incident create", flag.ExitOnError)
+	severityFlag := fs.String("severity", status.SeverityMinor, "Severity: minor, major, or maintenance")
+	siteFlag := fs.String("site", "", "Affected sites, comma-separated (default: whole server)")
+	messageFlag := fs.String("message", "", "First update (default: the title)")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: incident title required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt incident create <title> [--severity minor|major|maintenance] [--site <a,b,...>]")
+		os.Exit(1)
+	}
+	title := args[0]
+	fs.Parse(args[1:])
+
+	if !status.IsValidSeverity(*severityFlag) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", status.ErrInvalidSeverity)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data status.Incident `json:"data"`
+	}
+	incidentRequest("POST", "", map[string]interface{}{
+		"title":    title,
+		"severity": *severityFlag,
+		"sites":    splitSites(*siteFlag),
+		"message":  *messageFlag,
+	}, &result)
+
+	fmt.Printf("Incident %d opened: %s\n", result.Data.ID, result.Data.Title)
+}
+
+// handleIncidentMaintenance schedules a maintenance window
+func handleIncidentMaintenance(args []string) {
+	fs := flag.NewFlagSet("incident maintenance", flag.ExitOnError)
+	siteFlag := fs.String("site", "", "Affected sites, comma-separated (default: whole server)")
+	startFlag := fs.String("start", "now", "Window start: now, 2006-01-02 15:04 (local), or RFC 3339")
+	durationFlag := fs.String("duration", "", "Window length, e.g. 30m, 2h, 1d")
+	endFlag := fs.String("end", "", "Window end (instead of --duration)")
+	messageFlag := fs.String("message", "", "Announcement (default: the title)")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: maintenance title required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt incident maintenance <title> [--start <time>] [--duration <d> | --end <time>] [--site <a,b,...>]")
+		os.Exit(1)
+	}
+	title := args[0]
+	fs.Parse(args[1:])
+
+	start, err := parseWindowTime(*startFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --start: %v\n", err)
+		os.Exit(1)
+	}
+
+	var end time.Time
+	switch {
+	case *durationFlag != "" && *endFlag != "":
+		fmt.Fprintln(os.Stderr, "Error: use either --duration or --end, not both")
+		os.Exit(1)
+	case *durationFlag != "":
+		d, err := parseDurationValue(*durationFlag)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --duration %q\n", *durationFlag)
+			os.Exit(1)
+		}
+		end = start.Add(d)
+	case *endFlag != "":
+		if end, err = parseWindowTime(*endFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if !end.IsZero() && !end.After(start) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", status.ErrInvalidWindow)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"title":     title,
+		"severity":  status.SeverityMaintenance,
+		"sites":     splitSites(*siteFlag),
+		"message":   *messageFlag,
+		"starts_at": start.Unix(),
+	}
+	if !end.IsZero() {
+		body["ends_at"] = end.Unix()
+	}
+
+	var result struct {
+		Data status.Incident `json:"data"`
+	}
+	incidentRequest("POST", "", body, &result)
+
+	fmt.Printf("Maintenance %d %s: %s\n", result.Data.ID, strings.ReplaceAll(result.Data.Status, "_", " "), result.Data.Title)
+	fmt.Printf("  Window: %s\n", formatWindow(&result.Data))
+	fmt.Println("  Downtime alerts for affected sites are paused while it is in progress")
+}
+
+// handleIncidentUpdate posts an update; resolve passes a fixed status
+func handleIncidentUpdate(args []string, fixedStatus string) {
+	name := "update"
+	if fixedStatus != "" {
+		name = "resolve"
+	}
+
+	fs := flag.NewFlagSet("incident "+name, flag.ExitOnError)
+	statusFlag := fs.String("status", "", "New status: investigating, identified, monitoring, or resolved (default: unchanged)")
+	messageFlag := fs.String("message", "", "Update message")
+
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: incident ID required")
+		fmt.Fprintf(os.Stderr, "Usage: fazt incident %s <id> --message <text>\n", name)
+		os.Exit(1)
+	}
+	id := args[0]
+	fs.Parse(args[1:])
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid incident ID %q\n", id)
+		os.Exit(1)
+	}
+
+	newStatus := *statusFlag
+	if fixedStatus != "" {
+		newStatus = fixedStatus
+	}
+	message := *messageFlag
+	if message == "" && fixedStatus == status.IncidentResolved {
+		message = "This incident has been resolved."
+	}
+
+	if newStatus != "" && !status.IsValidStatus(newStatus) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", status.ErrInvalidStatus)
+		os.Exit(1)
+	}
+	if message == "" {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", status.ErrMissingMessage)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data status.Incident `json:"data"`
+	}
+	incidentRequest("POST", "/"+id+"/updates", map[string]string{
+		"status":  newStatus,
+		"message": message,
+	}, &result)
+
+	fmt.Printf("Incident %d is now %s\n", result.Data.ID, result.Data.Status)
+}
+
+func handleIncidentList(args []string) {
+	var result struct {
+		Data struct {
+			Incidents []status.Incident `json:"incidents"`
+		} `json:"data"`
+	}
+	incidentRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Title", "Severity", "Status", "Sites", "Window", "Opened", "Latest Update"},
+		Rows:    [][]string{},
+	}
+	for _, inc := range result.Data.Incidents {
+		sites := strings.Join(inc.Sites, ", ")
+		if sites == "" {
+			sites = "all"
+		}
+		latest := ""
+		if len(inc.Updates) > 0 {
+			latest = inc.Updates[0].Message
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(inc.ID, 10),
+			inc.Title,
+			inc.Severity,
+			inc.Status,
+			sites,
+			formatWindow(&inc),
+			time.Unix(inc.CreatedAt, 0).Local().Format("2006-01-02 15:04"),
+			latest,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Incidents").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleIncidentDelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: incident ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt incident delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid incident ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	incidentRequest("DELETE", "/"+args[0], nil, &result)
+	fmt.Printf("Incident %s deleted\n", args[0])
+}
+
+// incidentRequest calls the peer's /api/status/incidents endpoint and decodes the response
+func incidentRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/status/incidents"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// splitSites parses a comma-separated --site value
+func splitSites(s string) []string {
+	sites := []string{}
+	for _, site := range strings.Split(s, ",") {
+		if site = strings.TrimSpace(site); site != "" {
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// parseWindowTime accepts "now", "2006-01-02 15:04" in local time, or RFC 3339
+func parseWindowTime(s string) (time.Time, error) {
+	if s == "" || s == "now" {
+		return time.Now(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected now, 2006-01-02 15:04, or RFC 3339, got %q", s)
+	}
+	return t, nil
+}
+
+// formatWindow renders a maintenance window as "start → end" in local time
+func formatWindow(inc *status.Incident) string {
+	if inc.StartsAt == nil {
+		return ""
+	}
+	const layout = "2006-01-02 15:04"
+	window := time.Unix(*inc.StartsAt, 0).Local().Format(layout) + " → "
+	if inc.EndsAt != nil {
+		return window + time.Unix(*inc.EndsAt, 0).Local().Format(layout)
+	}
+	return window + "until resolved"
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+func handleJobsCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("jobs", printJobsUsage)
+		return
+	}
+
+	switch args[0] {
+	case "dead":
+		handleJobsDead(args[1:])
+	case "retry":
+		handleJobsRetry(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("jobs", printJobsUsage)
+	default:
+		fmt.Printf("Unknown jobs subcommand: %s\n", args[0])
+		printJobsUsage()
+		os.Exit(1)
+	}
+}
+
+func printJobsUsage() {
+	fmt.Println("fazt jobs - Background jobs that failed their last attempt")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] jobs <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  dead                        List dead-lettered jobs, most recent first")
+	fmt.Println("  retry <job>                 Replay a dead-lettered job as a new job")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --app <app>                 Only list this app's jobs")
+	fmt.Println("  --limit N                   Jobs to list (default 50)")
+	fmt.Println()
+	fmt.Println("A job that fails its last attempt is kept with its error, logs and last")
+	fmt.Println("checkpoint. A retry starts from that checkpoint with the same handler and")
+	fmt.Println("options. Each dead-lettered job can be retried once.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt jobs dead --app my-app")
+	fmt.Println("  fazt @zyt jobs retry job_3f9a2c1b")
+}
+
+func handleJobsDead(args []string) {
+	fs := flag.NewFlagSet("jobs dead", flag.ExitOnError)
+	app := fs.String("app", "", "Only list this app's jobs")
+	limit := fs.Int("limit", 50, "Jobs to list")
+	fs.Parse(args)
+
+	query := url.Values{"limit": {strconv.Itoa(*limit)}}
+	if *app != "" {
+		query.Set("app", *app)
+	}
+
+	var result struct {
+		Data struct {
+			Jobs []worker.DeadLetter `json:"jobs"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/system/jobs/dead?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Job", "App", "Handler", "Attempts", "Failed", "Error", "Retried As"},
+		Rows:    [][]string{},
+	}
+	for _, d := range result.Data.Jobs {
+		retried := "-"
+		if d.RetryJobID != "" {
+			retried = d.RetryJobID
+		}
+		table.Rows = append(table.Rows, []string{
+			d.JobID,
+			d.AppID,
+			d.Handler,
+			strconv.Itoa(d.Attempts),
+			jobTime(&d.FailedAt),
+			firstLine(d.Error),
+			retried,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("Dead-Lettered Jobs").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleJobsRetry(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: job ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt jobs retry <job>")
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data worker.JobInfo `json:"data"`
+	}
+	peerRequest("POST", "/api/system/jobs/dead/"+url.PathEscape(args[0])+"/retry", nil, &result)
+	fmt.Printf("Retrying %s as %s\n", args[0], result.Data.ID)
+}
+
+// firstLine trims an error to its first line for table display
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
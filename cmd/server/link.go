@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/models"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+// linkInfo is a link as listed by /api/redirects
+type linkInfo struct {
+	models.Redirect
+	URL       string `json:"url"`
+	Clicks7d  int64  `json:"clicks_7d"`
+	Clicks30d int64  `json:"clicks_30d"`
+}
+
+func handleLinkCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("link", printLinkUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handleLinkAdd(args[1:])
+	case "list":
+		handleLinkList(args[1:])
+	case "import":
+		handleLinkImport(args[1:])
+	case "remove":
+		handleLinkRemove(args[1:])
+	case "stats":
+		handleLinkStats(args[1:])
+	case "qr":
+		handleLinkQR(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("link", printLinkUsage)
+	default:
+		fmt.Printf("Unknown link subcommand: %s\n", args[0])
+		printLinkUsage()
+		os.Exit(1)
+	}
+}
+
+func printLinkUsage() {
+	fmt.Println("fazt link - Short links (/r/<slug>) and their click stats")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] link <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <slug> <url>        Create a short link")
+	fmt.Println("  list                    List short links")
+	fmt.Println("  import <file.csv>       Create links from a CSV file")
+	fmt.Println("  remove <slug>           Delete a short link")
+	fmt.Println("  stats <slug>            Clicks over time, referrers, countries and devices")
+	fmt.Println("  qr <slug>               Save a QR code of the short link as PNG")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --tags <a,b>            Tags added to every click (add)")
+	fmt.Println("  --expires <when>        Lifetime (30d, 12h) or date (2026-12-31); expired")
+	fmt.Println("                          links answer 410 Gone (add)")
+	fmt.Println("  --stats                 Add clicks in the last 7 and 30 days (list)")
+	fmt.Println("  --update                Overwrite links whose slug exists (import)")
+	fmt.Println("  --days <n>              Stats window in days (stats, default: 30)")
+	fmt.Println("  -o <file>               Output file (qr, default: <slug>.png)")
+	fmt.Println("  --size <px>             Image width (qr, default: 256)")
+	fmt.Println()
+	fmt.Println("CSV columns are slug, url, tags and expires; tags and expires are optional")
+	fmt.Println("and a header row is skipped. Tags within a cell are comma separated.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt link add launch https://example.com/blog/launch?utm_source=qr")
+	fmt.Println("  fazt @zyt link add promo https://shop.example.com --expires 2026-12-31")
+	fmt.Println("  fazt @zyt link list --stats")
+	fmt.Println("  fazt @zyt link import links.csv --update")
+	fmt.Println("  fazt @zyt link qr launch -o launch.png --size 512")
+}
+
+func handleLinkAdd(args []string) {
+	fs := flag.NewFlagSet("link add", flag.ExitOnError)
+	tagsFlag := fs.String("tags", "", "Comma-separated tags")
+	expiresFlag := fs.String("expires", "", "Lifetime (30d) or date (2026-12-31)")
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Error: slug and URL required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt link add <slug> <url> [--tags <a,b>] [--expires <when>]")
+		os.Exit(1)
+	}
+	fs.Parse(args[2:])
+
+	link, err := newLinkRequest(args[0], args[1], *tagsFlag, *expiresFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data struct {
+			URL       string     `json:"url"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/redirects", link, &result)
+
+	fmt.Printf("Link created: %s\n", result.Data.URL)
+	fmt.Printf("  -> %s\n", args[1])
+	if result.Data.ExpiresAt != nil {
+		fmt.Printf("  Expires: %s\n", result.Data.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+// newLinkRequest builds the API body for a link. expires is a lifetime
+// (30d, 12h) or a date (2026-12-31, or RFC 3339).
+func newLinkRequest(slug, destination, tags, expires string) (map[string]interface{}, error) {
+	link := map[string]interface{}{
+		"slug":        strings.TrimSpace(slug),
+		"destination": strings.TrimSpace(destination),
+		"tags":        splitTags(tags),
+	}
+	expires = strings.TrimSpace(expires)
+	if expires == "" {
+		return link, nil
+	}
+	if _, err := time.Parse("2006-01-02", expires); err == nil {
+		link["expires_at"] = expires
+	} else if _, err := time.Parse(time.RFC3339, expires); err == nil {
+		link["expires_at"] = expires
+	} else if _, err := hosting.ParseExpiry(expires); err == nil {
+		link["expires"] = expires
+	} else {
+		return nil, fmt.Errorf("invalid expiry %q: use a lifetime (30d, 12h) or a date (2026-12-31)", expires)
+	}
+	return link, nil
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func handleLinkList(args []string) {
+	fs := flag.NewFlagSet("link list", flag.ExitOnError)
+	statsFlag := fs.Bool("stats", false, "Add clicks in the last 7 and 30 days")
+	fs.Parse(args)
+
+	path := "/api/redirects"
+	if *statsFlag {
+		path += "?stats=true"
+	}
+	var result struct {
+		Data []linkInfo `json:"data"`
+	}
+	peerRequest("GET", path, nil, &result)
+
+	headers := []string{"Slug", "Destination", "Clicks", "Expires", "Created"}
+	if *statsFlag {
+		headers = []string{"Slug", "Destination", "Clicks", "7d", "30d", "Expires"}
+	}
+	table := &output.Table{Headers: headers, Rows: [][]string{}}
+	now := time.Now()
+	for _, l := range result.Data {
+		expires := formatTokenTime(l.ExpiresAt, "never")
+		if l.ExpiresAt != nil && !now.Before(*l.ExpiresAt) {
+			expires = "expired"
+		}
+		row := []string{l.Slug, truncate(l.Destination, 50), strconv.FormatInt(l.ClickCount, 10)}
+		if *statsFlag {
+			row = append(row, strconv.FormatInt(l.Clicks7d, 10), strconv.FormatInt(l.Clicks30d, 10), expires)
+		} else {
+			row = append(row, expires, l.CreatedAt.Local().Format("2006-01-02"))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Links").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleLinkImport(args []string) {
+	fs := flag.NewFlagSet("link import", flag.ExitOnError)
+	updateFlag := fs.Bool("update", false, "Overwrite links whose slug exists")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: CSV file required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt link import <file.csv> [--update]")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	links, lines, err := readLinksCSV(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	if len(links) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s has no links\n", args[0])
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data struct {
+			Created int `json:"created"`
+			Updated int `json:"updated"`
+			Skipped int `json:"skipped"`
+			Errors  []struct {
+				Index int    `json:"index"`
+				Slug  string `json:"slug"`
+				Error string `json:"error"`
+			} `json:"errors"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/redirects/import", map[string]interface{}{
+		"links":  links,
+		"update": *updateFlag,
+	}, &result)
+
+	d := result.Data
+	fmt.Printf("Imported %d links: %d created, %d updated, %d skipped, %d failed\n",
+		len(links), d.Created, d.Updated, d.Skipped, len(d.Errors))
+	if d.Skipped > 0 && !*updateFlag {
+		fmt.Println("Skipped links already exist; use --update to overwrite them.")
+	}
+	for _, e := range d.Errors {
+		line := 0
+		if e.Index >= 0 && e.Index < len(lines) {
+			line = lines[e.Index]
+		}
+		fmt.Fprintf(os.Stderr, "  line %d: %s\n", line, e.Error)
+	}
+	if len(d.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readLinksCSV reads slug,url[,tags[,expires]] rows, skipping a header row
+// and blank lines. It returns the links and the line each was on.
+func readLinksCSV(r io.Reader) ([]map[string]interface{}, []int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var links []map[string]interface{}
+	var lines []int
+	for first := true; ; first = false {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(row[0]), "slug") {
+			continue
+		}
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		if len(row) < 2 {
+			return nil, nil, fmt.Errorf("line %d: expected slug,url[,tags[,expires]]", line)
+		}
+		for len(row) < 4 {
+			row = append(row, "")
+		}
+		link, err := newLinkRequest(row[0], row[1], row[2], row[3])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		links = append(links, link)
+		lines = append(lines, line)
+	}
+	return links, lines, nil
+}
+
+func handleLinkRemove(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: slug required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt link remove <slug>")
+		os.Exit(1)
+	}
+	slug := args[0]
+
+	var list struct {
+		Data []linkInfo `json:"data"`
+	}
+	peerRequest("GET", "/api/redirects", nil, &list)
+	for _, l := range list.Data {
+		if l.Slug == slug {
+			var result map[string]interface{}
+			peerRequest("DELETE", "/api/redirects/"+strconv.FormatInt(l.ID, 10), nil, &result)
+			fmt.Printf("Link %s removed\n", slug)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error: link %q not found\n", slug)
+	os.Exit(1)
+}
+
+func handleLinkStats(args []string) {
+	fs := flag.NewFlagSet("link stats", flag.ExitOnError)
+	daysFlag := fs.Int("days", 30, "Stats window in days")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: slug required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt link stats <slug> [--days <n>]")
+		os.Exit(1)
+	}
+	fs.Parse(args[1:])
+
+	var result struct {
+		Data models.LinkStats `json:"data"`
+	}
+	peerRequest("GET", fmt.Sprintf("/api/redirects/%s/stats?days=%d", url.PathEscape(args[0]), *daysFlag), nil, &result)
+	s := result.Data
+
+	md := output.NewMarkdown().H1("Link: " + s.Slug)
+	md.Para(fmt.Sprintf("%s -> %s", s.URL, s.Destination))
+	summary := fmt.Sprintf("%d clicks from %d visitors in the last %d days (%d all time)", s.Clicks, s.Visitors, s.Days, s.TotalClicks)
+	if s.ExpiresAt != nil {
+		summary += ". Expires " + formatTokenTime(s.ExpiresAt, "")
+	}
+	md.Para(summary)
+
+	timeline := &output.Table{Headers: []string{"Day", "Clicks"}, Rows: [][]string{}}
+	for _, t := range s.Timeline {
+		timeline.Rows = append(timeline.Rows, []string{t.Timestamp, strconv.FormatInt(t.Count, 10)})
+	}
+	md.H2("Clicks per day").Table(timeline)
+
+	for _, b := range []struct {
+		title, column string
+		stats         []models.BreakdownStat
+	}{
+		{"Referrers", "Type", s.ReferrerTypes},
+		{"Countries", "Country", s.Countries},
+		{"Devices", "Device", s.Devices},
+		{"Campaigns", "Campaign", s.Campaigns},
+	} {
+		table := &output.Table{Headers: []string{b.column, "Clicks"}, Rows: [][]string{}}
+		for _, stat := range b.stats {
+			table.Rows = append(table.Rows, []string{stat.Key, strconv.FormatInt(stat.Count, 10)})
+		}
+		md.H2(b.title).Table(table)
+	}
+
+	getRenderer().Print(md.String(), s)
+}
+
+func handleLinkQR(args []string) {
+	fs := flag.NewFlagSet("link qr", flag.ExitOnError)
+	outFlag := fs.String("o", "", "Output file (default: <slug>.png)")
+	sizeFlag := fs.Int("size", 256, "Image width in pixels")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: slug required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt link qr <slug> [-o <file>] [--size <px>]")
+		os.Exit(1)
+	}
+	slug := args[0]
+	fs.Parse(args[1:])
+
+	out := *outFlag
+	if out == "" {
+		out = slug + ".png"
+	}
+
+	png, err := peerGetBytes(fmt.Sprintf("/api/redirects/%s/qr?size=%d", url.PathEscape(slug), *sizeFlag))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(out, png, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("QR code saved to %s\n", out)
+}
+
+// peerGetBytes fetches a non-JSON resource, such as an image, from the peer
+func peerGetBytes(path string) ([]byte, error) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	req, _ := http.NewRequest("GET", peer.URL+path, nil)
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(string(body))
+	}
+	return body, nil
+}
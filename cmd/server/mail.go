@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// mailOptions are the set-mail flags; empty fields are left unchanged
+type mailOptions struct {
+	SMTP         string // host[:port]
+	SMTPUser     string
+	SMTPPassword string
+	From         string
+	DailyLimit   string
+
+	Inbound       string // Port to receive email on, or "off"
+	InboundDomain string
+	InboundMaxMB  string
+}
+
+func (o mailOptions) empty() bool {
+	return o == mailOptions{}
+}
+
+// setMailCommand stores the mail server apps send email through
+func setMailCommand(opts mailOptions, dbPath string) error {
+	keys := map[string]string{}
+
+	if opts.SMTP != "" {
+		if opts.SMTP == "none" {
+			keys["mail.smtp.host"] = ""
+		} else {
+			host, port, err := parseSMTPAddr(opts.SMTP)
+			if err != nil {
+				return err
+			}
+			keys["mail.smtp.host"] = host
+			keys["mail.smtp.port"] = port
+		}
+	}
+	if opts.SMTPUser != "" {
+		keys["mail.smtp.username"] = opts.SMTPUser
+	}
+	if opts.SMTPPassword != "" {
+		keys["mail.smtp.password"] = opts.SMTPPassword
+	}
+	if opts.From != "" {
+		if _, err := mail.ParseAddress(opts.From); err != nil {
+			return fmt.Errorf("Error: invalid --from '%s'", opts.From)
+		}
+		keys["mail.smtp.from"] = opts.From
+	}
+	if opts.DailyLimit != "" {
+		if n, err := strconv.Atoi(opts.DailyLimit); err != nil || n < 0 {
+			return fmt.Errorf("Error: invalid --daily-limit '%s' (expected a number of emails)", opts.DailyLimit)
+		}
+		keys["mail.daily_limit"] = opts.DailyLimit
+	}
+	if opts.Inbound != "" {
+		if opts.Inbound == "off" {
+			keys["mail.inbound.port"] = ""
+		} else if n, err := strconv.Atoi(opts.Inbound); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("Error: invalid --inbound '%s' (expected a port or off)", opts.Inbound)
+		} else {
+			keys["mail.inbound.port"] = opts.Inbound
+		}
+	}
+	if opts.InboundDomain != "" {
+		domain := strings.ToLower(strings.TrimSuffix(opts.InboundDomain, "."))
+		if !strings.Contains(domain, ".") || strings.ContainsAny(domain, "@/: ") {
+			return fmt.Errorf("Error: invalid --inbound-domain '%s'", opts.InboundDomain)
+		}
+		keys["mail.inbound.domain"] = domain
+	}
+	if opts.InboundMaxMB != "" {
+		if n, err := strconv.Atoi(opts.InboundMaxMB); err != nil || n < 1 {
+			return fmt.Errorf("Error: invalid --inbound-max-mb '%s'", opts.InboundMaxMB)
+		}
+		keys["mail.inbound.max_mb"] = opts.InboundMaxMB
+	}
+
+	if err := database.Init(dbPath); err != nil {
+		return fmt.Errorf("failed to init database: %w", err)
+	}
+	defer database.Close()
+
+	store := config.NewDBConfigStore(database.GetDB())
+	for k, v := range keys {
+		if err := store.Set(k, v); err != nil {
+			return fmt.Errorf("failed to set %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// mailSummary describes the app mail server for status
+func mailSummary(cfg *config.Config) string {
+	smtp := cfg.Mail.SMTP
+	if smtp.Host == "" {
+		return "off (only apps with their own mail server send email)"
+	}
+	from := smtp.From
+	if from == "" {
+		from = smtp.Username
+	}
+	return fmt.Sprintf("%s:%d as %s, up to %d per app a day", smtp.Host, smtp.Port, from, cfg.Mail.DailyLimit)
+}
+
+// inboundMailDomain is the domain apps receive email at
+func inboundMailDomain(cfg *config.Config) string {
+	if cfg.Mail.Inbound.Domain != "" {
+		return cfg.Mail.Inbound.Domain
+	}
+	return "apps." + extractDomain(cfg.Server.Domain)
+}
+
+// inboundMailSummary describes the receiving side for status
+func inboundMailSummary(cfg *config.Config) string {
+	in := cfg.Mail.Inbound
+	if in.Port == "" {
+		return "off"
+	}
+	return fmt.Sprintf("<app>@%s on :%s, up to %d MB", inboundMailDomain(cfg), in.Port, in.MaxMB)
+}
+
+func handleSetMailCommand() {
+	flags := flag.NewFlagSet("set-mail", flag.ExitOnError)
+	var opts mailOptions
+	flags.StringVar(&opts.SMTP, "smtp", "", "Mail server as host[:port], port 587 by default, 465 for implicit TLS (none turns app email off)")
+	flags.StringVar(&opts.SMTPUser, "smtp-user", "", "SMTP username")
+	flags.StringVar(&opts.SMTPPassword, "smtp-password", "", "SMTP password")
+	flags.StringVar(&opts.From, "from", "", "Sender address (default: the SMTP username)")
+	flags.StringVar(&opts.DailyLimit, "daily-limit", "", "Emails each app may send per UTC day (default: 100)")
+	flags.StringVar(&opts.Inbound, "inbound", "", "Port to receive email for apps on, usually 25 (off stops receiving)")
+	flags.StringVar(&opts.InboundDomain, "inbound-domain", "", "Domain apps receive email at (default: apps.<domain>)")
+	flags.StringVar(&opts.InboundMaxMB, "inbound-max-mb", "", "Largest email accepted in MB (default: 10)")
+	db := flags.String("db", "", "Database file path")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server set-mail [flags]")
+		fmt.Println()
+		fmt.Println("Configure the mail server apps send email through with fazt.app.mail,")
+		fmt.Println("and receiving email for apps at <app>@apps.<domain>. Apps can have their")
+		fmt.Println("own mail server (fazt app mail <app> --smtp ...). Changes take effect on")
+		fmt.Println("restart.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server set-mail --smtp smtp.example.com --smtp-user apps@example.com --smtp-password secret")
+		fmt.Println("  fazt server set-mail --from 'My Apps <noreply@example.com>' --daily-limit 500")
+		fmt.Println("  fazt server set-mail --smtp none")
+		fmt.Println("  fazt server set-mail --inbound 25")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Resolve DB Path
+	dbPath := "./data.db"
+	if envPath := os.Getenv("FAZT_DB_PATH"); envPath != "" {
+		dbPath = envPath
+	}
+	if *db != "" {
+		dbPath = config.ExpandPath(*db)
+	}
+
+	if opts.empty() {
+		flags.Usage()
+		os.Exit(1)
+	}
+	if err := setMailCommand(opts, dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("✓ Mail settings updated (restart the server to apply)")
+}
@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
@@ -27,26 +28,45 @@ import (
 
 	"github.com/fazt-sh/fazt/internal/activity"
 	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/approval"
 	"github.com/fazt-sh/fazt/internal/audit"
 	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/canary"
 	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/connect"
+	"github.com/fazt-sh/fazt/internal/crashloop"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/egress"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/failover"
 	"github.com/fazt-sh/fazt/internal/handlers"
+	"github.com/fazt-sh/fazt/internal/heartbeats"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/inbox"
 	"github.com/fazt-sh/fazt/internal/listener"
 	"github.com/fazt-sh/fazt/internal/middleware"
+	"github.com/fazt-sh/fazt/internal/notify"
+	"github.com/fazt-sh/fazt/internal/pings"
 	"github.com/fazt-sh/fazt/internal/provision"
+	"github.com/fazt-sh/fazt/internal/realip"
 	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/slo"
+	"github.com/fazt-sh/fazt/internal/usage"
+	"github.com/fazt-sh/fazt/internal/uploads"
+	"github.com/fazt-sh/fazt/internal/virusscan"
+	"github.com/fazt-sh/fazt/internal/status"
+	"github.com/fazt-sh/fazt/internal/throttle"
 	jsruntime "github.com/fazt-sh/fazt/internal/runtime"
 	"github.com/fazt-sh/fazt/internal/security"
 	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/system"
 	"github.com/fazt-sh/fazt/internal/worker"
 	"github.com/fazt-sh/fazt/internal/term"
 	"github.com/fazt-sh/fazt/internal/output"
 	"github.com/fazt-sh/fazt/internal/help"
 	ignore "github.com/sabhiram/go-gitignore"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/time/rate"
 	_ "modernc.org/sqlite"
 	"github.com/caddyserver/certmagic"
 )
@@ -164,6 +184,38 @@ func main() {
 		handleNetCommand(os.Args[2:])
 	case "secret":
 		handleSecretCommand(os.Args[2:])
+	case "token":
+		handleTokenCommand(os.Args[2:])
+	case "incident":
+		handleIncidentCommand(os.Args[2:])
+	case "slo":
+		handleSLOCommand(os.Args[2:])
+	case "freeze":
+		handleFreezeCommand(os.Args[2:])
+	case "approvals":
+		handleApprovalsCommand(os.Args[2:])
+	case "security":
+		handleSecurityCommand(os.Args[2:])
+	case "jobs":
+		handleJobsCommand(os.Args[2:])
+	case "check":
+		handleCheckCommand(os.Args[2:])
+	case "usage":
+		handleUsageCommand(os.Args[2:])
+	case "throttle":
+		handleThrottleCommand(os.Args[2:])
+	case "deploy-limits":
+		handleDeployLimitsCommand(os.Args[2:])
+	case "link":
+		handleLinkCommand(os.Args[2:])
+	case "ping":
+		handlePingCommand(os.Args[2:])
+	case "heartbeat":
+		handleHeartbeatCommand(os.Args[2:])
+	case "s3":
+		handleS3Command(os.Args[2:])
+	case "failover":
+		handleFailoverCommand(os.Args[2:])
 	default:
 		fmt.Printf("Unknown command: %s\n\n", command)
 		printUsage()
@@ -225,6 +277,54 @@ func handleAtPeerRouting(peerName string, args []string) {
 	case "logs":
 		handleLogsCommandWithPeer(peerName, cmdArgs)
 
+	case "token":
+		handleTokenCommand(cmdArgs)
+
+	case "incident":
+		handleIncidentCommand(cmdArgs)
+
+	case "slo":
+		handleSLOCommand(cmdArgs)
+
+	case "freeze":
+		handleFreezeCommand(cmdArgs)
+
+	case "approvals":
+		handleApprovalsCommand(cmdArgs)
+
+	case "security":
+		handleSecurityCommand(cmdArgs)
+
+	case "jobs":
+		handleJobsCommand(cmdArgs)
+
+	case "check":
+		handleCheckCommand(cmdArgs)
+
+	case "usage":
+		handleUsageCommand(cmdArgs)
+
+	case "throttle":
+		handleThrottleCommand(cmdArgs)
+
+	case "deploy-limits":
+		handleDeployLimitsCommand(cmdArgs)
+
+	case "link":
+		handleLinkCommand(cmdArgs)
+
+	case "ping":
+		handlePingCommand(cmdArgs)
+
+	case "heartbeat":
+		handleHeartbeatCommand(cmdArgs)
+
+	case "s3":
+		handleS3Command(cmdArgs)
+
+	case "failover":
+		handleFailoverCommand(cmdArgs)
+
 	// === LOCAL-ONLY COMMANDS (helpful errors) ===
 
 	case "service":
@@ -262,6 +362,22 @@ func handleAtPeerRouting(peerName string, args []string) {
 		fmt.Fprintf(os.Stderr, "  auth <subcommand>   Auth management\n")
 		fmt.Fprintf(os.Stderr, "  peer <subcommand>   Peer configuration on remote\n")
 		fmt.Fprintf(os.Stderr, "  server <subcommand> Server management\n")
+		fmt.Fprintf(os.Stderr, "  token <subcommand>  API token management\n")
+		fmt.Fprintf(os.Stderr, "  incident <subcmd>   Status page incident log\n")
+		fmt.Fprintf(os.Stderr, "  slo <subcmd>        SLOs and error budgets\n")
+		fmt.Fprintf(os.Stderr, "  freeze <subcmd>     Deploy freeze windows\n")
+		fmt.Fprintf(os.Stderr, "  approvals <subcmd>  Pending approvals of destructive operations\n")
+		fmt.Fprintf(os.Stderr, "  security <subcmd>   Security events and alert rules\n")
+		fmt.Fprintf(os.Stderr, "  jobs <subcmd>       Dead-lettered background jobs\n")
+		fmt.Fprintf(os.Stderr, "  check <subcmd>      Synthetic transaction checks\n")
+		fmt.Fprintf(os.Stderr, "  usage               Approximate cost per app\n")
+		fmt.Fprintf(os.Stderr, "  throttle <subcmd>   App budgets and degraded mode\n")
+		fmt.Fprintf(os.Stderr, "  deploy-limits       Deploy size and file-count limits\n")
+		fmt.Fprintf(os.Stderr, "  link <subcmd>       Short links (/r/) and their stats\n")
+		fmt.Fprintf(os.Stderr, "  ping <subcmd>       Scheduled outbound HTTP calls\n")
+		fmt.Fprintf(os.Stderr, "  heartbeat <subcmd>  Check-ins from scripts, alerts when missed\n")
+		fmt.Fprintf(os.Stderr, "  s3 <subcmd>         S3 access keys and presigned URLs\n")
+		fmt.Fprintf(os.Stderr, "  failover <subcmd>   Standby replication and promotion\n")
 		os.Exit(1)
 	}
 }
@@ -310,6 +426,9 @@ func handleServerCommandRemote(peerName string, args []string) {
 		fmt.Fprintf(os.Stderr, "Remote commands:\n")
 		fmt.Fprintf(os.Stderr, "  info      Show server info (works remotely)\n")
 		fmt.Fprintf(os.Stderr, "  status    Show server status (works remotely)\n")
+		fmt.Fprintf(os.Stderr, "  storage   Show database size by table and app\n")
+		fmt.Fprintf(os.Stderr, "  maintain  Prune old data and vacuum the database\n")
+		fmt.Fprintf(os.Stderr, "  rebuild   Regenerate derived data (rollups, media cache, search, usage)\n")
 		fmt.Fprintf(os.Stderr, "\nLocal-only commands (require SSH):\n")
 		fmt.Fprintf(os.Stderr, "  init, start, set-credentials, set-config, create-key, reset-admin\n")
 		os.Exit(1)
@@ -321,6 +440,15 @@ func handleServerCommandRemote(peerName string, args []string) {
 		// These can work remotely
 		handlePeerServerInfo(peerName)
 
+	case "storage":
+		handleServerStorageCommand(args[1:])
+
+	case "maintain":
+		handleServerMaintainCommand(args[1:])
+
+	case "rebuild":
+		handleServerRebuildCommand(args[1:])
+
 	case "init":
 		fmt.Fprintf(os.Stderr, "Error: 'server init' requires direct access - no server exists yet.\n\n")
 		fmt.Fprintf(os.Stderr, "To initialize a new server:\n")
@@ -338,7 +466,7 @@ func handleServerCommandRemote(peerName string, args []string) {
 		fmt.Fprintf(os.Stderr, "  systemctl --user start fazt-local\n")
 		os.Exit(1)
 
-	case "set-credentials", "set-config", "create-key", "reset-admin":
+	case "set-credentials", "set-config", "set-notify", "set-mail", "create-key", "reset-admin":
 		fmt.Fprintf(os.Stderr, "Error: 'server %s' requires direct database access.\n\n", subcommand)
 		fmt.Fprintf(os.Stderr, "To run this command:\n")
 		fmt.Fprintf(os.Stderr, "  ssh user@%s-host\n", peerName)
@@ -476,10 +604,98 @@ func setCredentialsCommand(username, password, dbPath string) error {
 }
 
 // setConfigCommand updates server configuration settings
-func setConfigCommand(domain, port, env, dbPath string) error {
+// require2FA and requireApproval are "true", "false", or "" (unchanged)
+// rateLimit is a parseRateLimit spec, or "" (unchanged)
+// trustedProxies is a comma-separated CIDR list, "none", or "" (unchanged)
+// vfsCache is the VFS cache size in MB, or "" (unchanged)
+// slowStorage is the slow storage op threshold in ms, or "" (unchanged)
+// vmPool is a parseVMPool spec, or "" (unchanged)
+// secretScan is "off", "warn", "block", or "" (unchanged)
+// analyticsSpec is a parseAnalytics spec, or "" (unchanged)
+// virusScan is a parseVirusScan spec, or "" (unchanged)
+// virusScanPaths is a comma-separated list of blob path globs, "all", or "" (unchanged)
+// uploadsSpec is a parseUploads spec, or "" (unchanged)
+// deployLimitsSpec is a parseDeployLimits spec, or "" (unchanged)
+func setConfigCommand(domain, port, env, require2FA, requireApproval, rateLimit, trustedProxies, vfsCache, slowStorage, vmPool, secretScan, analyticsSpec, virusScan, virusScanPaths, uploadsSpec, deployLimitsSpec, dbPath string) error {
 	// Validate at least one field is provided
-	if domain == "" && port == "" && env == "" {
-		return errors.New("Error: at least one of --domain, --port, or --env is required")
+	if domain == "" && port == "" && env == "" && require2FA == "" && requireApproval == "" && rateLimit == "" && trustedProxies == "" && vfsCache == "" && slowStorage == "" && vmPool == "" && secretScan == "" && analyticsSpec == "" && virusScan == "" && virusScanPaths == "" && uploadsSpec == "" && deployLimitsSpec == "" {
+		return errors.New("Error: at least one of --domain, --port, --env, --require-2fa, --require-approval, --rate-limit, --trusted-proxies, --vfs-cache, --slow-storage, --vm-pool, --secret-scan, --analytics, --virus-scan, --virus-scan-paths, --uploads, or --deploy-limits is required")
+	}
+
+	if require2FA != "" && require2FA != "true" && require2FA != "false" {
+		return fmt.Errorf("Error: invalid --require-2fa '%s' (must be 'true' or 'false')", require2FA)
+	}
+
+	if requireApproval != "" && requireApproval != "true" && requireApproval != "false" {
+		return fmt.Errorf("Error: invalid --require-approval '%s' (must be 'true' or 'false')", requireApproval)
+	}
+
+	rateLimitKeys, err := parseRateLimit(rateLimit)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --rate-limit: %v", err)
+	}
+
+	vmPoolKeys, err := parseVMPool(vmPool)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --vm-pool: %v", err)
+	}
+
+	analyticsKeys, err := parseAnalytics(analyticsSpec)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --analytics: %v", err)
+	}
+
+	if secretScan != "" && !hosting.ValidSecretScanMode(secretScan) {
+		return fmt.Errorf("Error: invalid --secret-scan '%s' (must be 'off', 'warn' or 'block')", secretScan)
+	}
+
+	virusScanKeys, err := parseVirusScan(virusScan)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --virus-scan: %v", err)
+	}
+
+	uploadsKeys, err := parseUploads(uploadsSpec)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --uploads: %v", err)
+	}
+
+	deployLimitsKeys, err := parseDeployLimits(deployLimitsSpec)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --deploy-limits: %v", err)
+	}
+
+	var scanPaths []string
+	if virusScanPaths != "" && virusScanPaths != "all" {
+		for _, p := range strings.Split(virusScanPaths, ",") {
+			p = strings.Trim(strings.TrimSpace(p), "/")
+			if err := virusscan.ValidPattern(p); err != nil {
+				return fmt.Errorf("Error: invalid --virus-scan-paths: %v", err)
+			}
+			scanPaths = append(scanPaths, p)
+		}
+	}
+
+	if vfsCache != "" {
+		if mb, err := strconv.Atoi(vfsCache); err != nil || mb < 0 || mb > 4096 {
+			return fmt.Errorf("Error: invalid --vfs-cache '%s' (must be 0-4096 MB)", vfsCache)
+		}
+	}
+
+	if slowStorage != "" {
+		if ms, err := strconv.Atoi(slowStorage); err != nil || ms < 1 || ms > 60000 {
+			return fmt.Errorf("Error: invalid --slow-storage '%s' (must be 1-60000 ms)", slowStorage)
+		}
+	}
+
+	var proxyCIDRs []string
+	if trustedProxies != "" && trustedProxies != "none" {
+		prefixes, err := realip.ParseTrustedProxies(strings.Split(trustedProxies, ","))
+		if err != nil {
+			return fmt.Errorf("Error: invalid --trusted-proxies: %v", err)
+		}
+		for _, p := range prefixes {
+			proxyCIDRs = append(proxyCIDRs, p.String())
+		}
 	}
 
 	// Initialize DB
@@ -519,9 +735,286 @@ func setConfigCommand(domain, port, env, dbPath string) error {
 		}
 	}
 
+	// Update two-factor requirement if provided (takes effect on restart)
+	if require2FA != "" {
+		if err := store.Set("auth.require_2fa", require2FA); err != nil {
+			return fmt.Errorf("failed to set require-2fa: %w", err)
+		}
+	}
+
+	// Update the approval requirement if provided (takes effect on restart)
+	if requireApproval != "" {
+		if err := store.Set("auth.require_approval", requireApproval); err != nil {
+			return fmt.Errorf("failed to set require-approval: %w", err)
+		}
+	}
+
+	// Update rate limits if provided (takes effect on restart)
+	for key, value := range rateLimitKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set rate limit: %w", err)
+		}
+	}
+
+	// Update trusted proxies if provided (takes effect on restart)
+	if trustedProxies != "" {
+		if err := store.Set("server.trusted_proxies", strings.Join(proxyCIDRs, ",")); err != nil {
+			return fmt.Errorf("failed to set trusted proxies: %w", err)
+		}
+	}
+
+	// Update VFS cache size if provided (takes effect on restart)
+	if vfsCache != "" {
+		if err := store.Set("server.vfs_cache_mb", vfsCache); err != nil {
+			return fmt.Errorf("failed to set vfs cache: %w", err)
+		}
+	}
+
+	// Update slow storage op threshold if provided (takes effect on restart)
+	if slowStorage != "" {
+		if err := store.Set("server.slow_storage_ms", slowStorage); err != nil {
+			return fmt.Errorf("failed to set slow storage threshold: %w", err)
+		}
+	}
+
+	// Update VM pool settings if provided (takes effect on restart)
+	for key, value := range vmPoolKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set vm pool: %w", err)
+		}
+	}
+
+	// Update the secret scan mode if provided (takes effect on restart)
+	if secretScan != "" {
+		if err := store.Set("server.secret_scan", secretScan); err != nil {
+			return fmt.Errorf("failed to set secret scan: %w", err)
+		}
+	}
+
+	// Update analytics retention if provided (takes effect on restart)
+	for key, value := range analyticsKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set analytics: %w", err)
+		}
+	}
+
+	// Update the virus scanner if provided (takes effect on restart)
+	for key, value := range virusScanKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set virus scan: %w", err)
+		}
+	}
+	if virusScanPaths != "" {
+		if err := store.Set("server.virus_scan.paths", strings.Join(scanPaths, ",")); err != nil {
+			return fmt.Errorf("failed to set virus scan paths: %w", err)
+		}
+	}
+
+	// Update upload limits if provided (takes effect on restart)
+	for key, value := range uploadsKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set uploads: %w", err)
+		}
+	}
+
+	// Update deploy limits if provided (takes effect on restart)
+	for key, value := range deployLimitsKeys {
+		if err := store.Set(key, value); err != nil {
+			return fmt.Errorf("failed to set deploy limits: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// parseDeployLimits turns a spec like "bundle=500,files=10000,file=100" into
+// server.deploy_limits.* config keys: the total size of a deploy's files in
+// MB, their number, and the largest single file in MB. 0 is unlimited.
+func parseDeployLimits(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	limits := map[string]struct {
+		key string
+		max int
+	}{
+		"bundle": {"server.deploy_limits.max_bundle_mb", 65536},
+		"files":  {"server.deploy_limits.max_files", 1000000},
+		"file":   {"server.deploy_limits.max_file_mb", 65536},
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, known := limits[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("'%s' (expected bundle=<MB>, files=<n> or file=<MB>)", entry)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > limit.max {
+			return nil, fmt.Errorf("invalid %s '%s' (must be 0-%d)", name, value, limit.max)
+		}
+		keys[limit.key] = strconv.Itoa(n)
+	}
+	return keys, nil
+}
+
+// parseUploads turns a spec like "max=1024,expire=48" into
+// server.uploads.* config keys: the largest resumable upload in MB and the
+// hours an unfinished upload is kept.
+func parseUploads(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	limits := map[string]struct {
+		key      string
+		min, max int
+	}{
+		"max":    {"server.uploads.max_mb", 1, 65536},
+		"expire": {"server.uploads.expire_hours", 1, 720},
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, known := limits[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("'%s' (expected max=<MB> or expire=<hours>)", entry)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < limit.min || n > limit.max {
+			return nil, fmt.Errorf("invalid %s '%s' (must be %d-%d)", name, value, limit.min, limit.max)
+		}
+		keys[limit.key] = strconv.Itoa(n)
+	}
+	return keys, nil
+}
+
+// parseVirusScan turns a spec like "clamd", "clamd:/run/clamav/clamd.ctl",
+// "clamd:127.0.0.1:3310" or "exec:/usr/bin/clamscan" into
+// server.virus_scan.* config keys. "off" disables scanning.
+func parseVirusScan(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	mode, address, _ := strings.Cut(spec, ":")
+	if !virusscan.ValidMode(mode) || (mode == virusscan.ModeOff && address != "") {
+		return nil, fmt.Errorf("'%s' (expected off, clamd[:<socket or host:port>] or exec[:<clamscan path>])", spec)
+	}
+	keys["server.virus_scan.mode"] = mode
+	keys["server.virus_scan.address"] = address
+	return keys, nil
+}
+
+// parseAnalytics turns a spec like "retention=365,raw=7,ip=drop" into
+// server.analytics.* config keys: the days raw events are kept (0 keeps
+// them forever), the recent days stats read from raw events rather than
+// the rollups, whether events keep the visitor's IP address, and the
+// MaxMind DB events are located with (geoip=<path>, or none). Raw events
+// stats still read are kept whatever the retention.
+func parseAnalytics(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	limits := map[string]struct {
+		key      string
+		min, max int
+	}{
+		"retention": {"server.analytics.retention_days", 0, 36500},
+		"raw":       {"server.analytics.raw_days", 1, 365},
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if ok && name == "ip" {
+			if value != "keep" && value != "drop" {
+				return nil, fmt.Errorf("invalid ip '%s' (must be keep or drop)", value)
+			}
+			keys["server.analytics.drop_ip"] = strconv.FormatBool(value == "drop")
+			continue
+		}
+		if ok && name == "geoip" {
+			if value == "none" {
+				value = ""
+			} else if _, err := analytics.OpenGeoIP(value); err != nil {
+				return nil, fmt.Errorf("invalid geoip '%s': %v", value, err)
+			}
+			keys["server.analytics.geoip_path"] = value
+			continue
+		}
+		limit, known := limits[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("'%s' (expected retention or raw=<days>, ip=keep|drop, or geoip=<path>|none)", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || n < limit.min || n > limit.max {
+			return nil, fmt.Errorf("invalid %s '%s' (must be %d-%d days)", name, value, limit.min, limit.max)
+		}
+		keys[limit.key] = strconv.Itoa(n)
+	}
+	return keys, nil
+}
+
+// parseVMPool turns a spec like "size=50,app=10,queue=1000" into
+// server.vm_pool.* config keys: the number of pooled VMs, the concurrent
+// executions allowed per app, and how long a request queues for a VM in ms.
+func parseVMPool(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	limits := map[string]struct {
+		key      string
+		min, max int
+	}{
+		"size":  {"server.vm_pool.size", 1, 1000},
+		"app":   {"server.vm_pool.app_concurrency", 1, 1000},
+		"queue": {"server.vm_pool.queue_ms", 1, 60000},
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		limit, known := limits[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("'%s' (expected size, app, or queue=<n>)", entry)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < limit.min || n > limit.max {
+			return nil, fmt.Errorf("invalid %s '%s' (must be %d-%d)", name, value, limit.min, limit.max)
+		}
+		keys[limit.key] = strconv.Itoa(n)
+	}
+	return keys, nil
+}
+
+// parseRateLimit turns a spec like "site=200/400,api=50" into
+// server.rate_limit.* config keys. Each entry is <ip|site|api>=<rps>[/<burst>];
+// the burst defaults to twice the rate and a rate of 0 disables the limit.
+func parseRateLimit(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || (name != "ip" && name != "site" && name != "api") {
+			return nil, fmt.Errorf("'%s' (expected ip, site, or api=<rps>[/<burst>])", entry)
+		}
+		rpsStr, burstStr, hasBurst := strings.Cut(value, "/")
+		rps, err := strconv.ParseFloat(rpsStr, 64)
+		if err != nil || rps < 0 {
+			return nil, fmt.Errorf("invalid rate '%s' for %s", rpsStr, name)
+		}
+		burst := int(math.Ceil(rps * 2))
+		if hasBurst {
+			if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+				return nil, fmt.Errorf("invalid burst '%s' for %s", burstStr, name)
+			}
+		}
+		keys["server.rate_limit."+name+"_rps"] = strconv.FormatFloat(rps, 'f', -1, 64)
+		keys["server.rate_limit."+name+"_burst"] = strconv.Itoa(burst)
+	}
+	return keys, nil
+}
+
 // statusCommand displays current configuration and server status
 func statusCommand(dbPath string) (string, error) {
 	// Initialize DB
@@ -550,6 +1043,57 @@ func statusCommand(dbPath string) (string, error) {
 	output.WriteString(fmt.Sprintf("Port:         %s\n", get("server.port", "4698")))
 	output.WriteString(fmt.Sprintf("Environment:  %s\n", get("server.env", "development")))
 	output.WriteString(fmt.Sprintf("Username:     %s\n", get("auth.username", "(not set)")))
+	output.WriteString(fmt.Sprintf("Require 2FA:  %s\n", get("auth.require_2fa", "false")))
+	output.WriteString(fmt.Sprintf("Approvals:    %s\n", get("auth.require_approval", "false")))
+	rateLimit := func(name, rps, burst string) string {
+		rps = get("server.rate_limit."+name+"_rps", rps)
+		if rps == "0" {
+			return "off"
+		}
+		return fmt.Sprintf("%s/s (burst %s)", rps, get("server.rate_limit."+name+"_burst", burst))
+	}
+	output.WriteString(fmt.Sprintf("Rate Limits:  per IP %s, per site %s, API per IP %s\n",
+		rateLimit("ip", "500", "1000"), rateLimit("site", "0", "0"), rateLimit("api", "100", "200")))
+	trustedProxies := get("server.trusted_proxies", "")
+	if trustedProxies == "" {
+		trustedProxies = "none (forwarding headers ignored)"
+	}
+	output.WriteString(fmt.Sprintf("Proxies:      %s\n", trustedProxies))
+	output.WriteString(fmt.Sprintf("VFS Cache:    %s MB\n", get("server.vfs_cache_mb", "64")))
+	output.WriteString(fmt.Sprintf("Slow Storage: %s ms\n", get("server.slow_storage_ms", "100")))
+	output.WriteString(fmt.Sprintf("VM Pool:      %s VMs, %s per app, %s ms queue\n",
+		get("server.vm_pool.size", "100"), get("server.vm_pool.app_concurrency", "20"), get("server.vm_pool.queue_ms", "2000")))
+	output.WriteString(fmt.Sprintf("Secret Scan:  %s\n", get("server.secret_scan", hosting.SecretScanWarn)))
+	retention := get("server.analytics.retention_days", "0") + " days"
+	if retention == "0 days" {
+		retention = "forever"
+	}
+	ips := "kept"
+	if get("server.analytics.drop_ip", "false") == "true" {
+		ips = "dropped"
+	}
+	geo := "off"
+	if path := get("server.analytics.geoip_path", ""); path != "" {
+		geo = path
+	}
+	output.WriteString(fmt.Sprintf("Analytics:    raw events kept %s, stats read raw for %s days, IPs %s, geo-IP %s\n", retention, get("server.analytics.raw_days", "7"), ips, geo))
+	virusScan := get("server.virus_scan.mode", virusscan.ModeOff)
+	if virusScan != virusscan.ModeOff {
+		if address := get("server.virus_scan.address", ""); address != "" {
+			virusScan += " (" + address + ")"
+		}
+		virusScan += ", paths " + get("server.virus_scan.paths", "all")
+	}
+	output.WriteString(fmt.Sprintf("Virus Scan:   %s\n", virusScan))
+	output.WriteString(fmt.Sprintf("Uploads:      up to %s MB, unfinished kept %s hours\n",
+		get("server.uploads.max_mb", "512"), get("server.uploads.expire_hours", "24")))
+	output.WriteString(fmt.Sprintf("Deploys:      up to %s MB, %s files, %s MB per file (0 is unlimited)\n",
+		get("server.deploy_limits.max_bundle_mb", "500"), get("server.deploy_limits.max_files", "10000"), get("server.deploy_limits.max_file_mb", "100")))
+	notifyCfg := config.CreateDefaultConfig()
+	store.Apply(notifyCfg)
+	output.WriteString(fmt.Sprintf("Notify:       %s\n", notifySummary(notifyCfg)))
+	output.WriteString(fmt.Sprintf("Mail:         %s\n", mailSummary(notifyCfg)))
+	output.WriteString(fmt.Sprintf("Inbound Mail: %s\n", inboundMailSummary(notifyCfg)))
 
 	// Check database size
 	if stat, err := os.Stat(dbPath); err == nil {
@@ -591,6 +1135,10 @@ func handleServerCommand(args []string) {
 		handleSetCredentials()
 	case "set-config":
 		handleSetConfigCommand()
+	case "set-notify":
+		handleSetNotifyCommand()
+	case "set-mail":
+		handleSetMailCommand()
 	case "status":
 		handleStatusCommand()
 	case "start":
@@ -599,6 +1147,12 @@ func handleServerCommand(args []string) {
 		handleResetAdminCommand()
 	case "create-key":
 		handleCreateKeyCommand()
+	case "storage":
+		handleServerStorageCommand(args[1:])
+	case "maintain":
+		handleServerMaintainCommand(args[1:])
+	case "rebuild":
+		handleServerRebuildCommand(args[1:])
 	case "--help", "-h", "help":
 		printServerHelp()
 	default:
@@ -1018,6 +1572,7 @@ func handlePeerStatus(args []string) {
 			{"Memory", fmt.Sprintf("%.1f MB / %.0f MB", status.Memory.UsedMB, status.Memory.LimitMB)},
 			{"Goroutines", fmt.Sprintf("%d", status.Runtime.Goroutines)},
 			{"DB Connections", fmt.Sprintf("%d open, %d in use", status.Database.OpenConnections, status.Database.InUse)},
+			{"Write Queue", fmt.Sprintf("%d / %d pending, %d rejected", status.Database.WriteQueue.QueueDepth, status.Database.WriteQueue.QueueCapacity, status.Database.WriteQueue.Rejected)},
 		},
 	}
 
@@ -1216,6 +1771,7 @@ func handlePeerDeploy(args []string) {
 	fmt.Printf("Deployed: %s\n", result.Site)
 	fmt.Printf("Files:    %d\n", result.FileCount)
 	fmt.Printf("Size:     %s\n", formatSize(result.SizeBytes))
+	printDeploySecrets(result.Secrets)
 }
 
 func formatDuration(seconds float64) string {
@@ -1415,6 +1971,7 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
@@ -1422,6 +1979,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += int64(n)
+	return n, err
+}
+
 // Hijack implements http.Hijacker for WebSocket support
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
@@ -1430,6 +1993,12 @@ func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
 }
 
+// Unwrap lets http.ResponseController reach the connection, e.g. to extend
+// deadlines for resumable uploads
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // printVersion displays version information
 func printVersion() {
 	fmt.Printf("fazt.sh %s\n", config.Version)
@@ -1451,9 +2020,14 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 
 	mainDomain := extractDomain(cfg.Server.Domain)
 
+	// Cookie-authenticated dashboard requests must carry the CSRF token
+	dashboard := middleware.CSRF(authHandler.Service())(dashboardMux)
 
+	// Connect calls are answered by their REST endpoints, sent back through
+	// this handler so each gets its own auth
+	var rpc *connect.Handler
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	root := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		host := r.Host
 
@@ -1473,6 +2047,12 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 
 		}
 
+		// S3 gateway: app blob storage for S3 clients, signed with app access keys
+		if host == "s3."+mainDomain {
+			handlers.S3GatewayHandler(w, r)
+			return
+		}
+
 		// Local-only: /_app/<id>/ routes for direct app access by ID
 		// Only available from local/private IPs (dev/testing escape hatch)
 		if appID, remaining, ok := hosting.ParseAppPath(r.URL.Path); ok {
@@ -1495,6 +2075,20 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 				handlers.LoginHandler(w, r)
 				return
 			}
+			// Second factor for password logins (POST /auth/login/2fa)
+			if r.URL.Path == "/auth/login/2fa" && r.Method == http.MethodPost {
+				handlers.LoginTwoFactorHandler(w, r)
+				return
+			}
+			// Passkey login from the login page (POST /auth/passkey/login/{begin,finish})
+			if r.URL.Path == "/auth/passkey/login/begin" && r.Method == http.MethodPost {
+				handlers.PasskeyLoginBeginHandler(w, r)
+				return
+			}
+			if r.URL.Path == "/auth/passkey/login/finish" && r.Method == http.MethodPost {
+				handlers.PasskeyLoginFinishHandler(w, r)
+				return
+			}
 			authHandler.ServeHTTP(w, r)
 			return
 		}
@@ -1507,7 +2101,12 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 
 		if host == "localhost" {
 
-			middleware.AuthMiddleware(authHandler.Service())(dashboardMux).ServeHTTP(w, r)
+			if strings.HasPrefix(r.URL.Path, connect.PathPrefix) {
+				rpc.ServeHTTP(w, r)
+				return
+			}
+
+			middleware.AuthMiddleware(authHandler.Service())(dashboard).ServeHTTP(w, r)
 
 			return
 
@@ -1517,27 +2116,59 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 
 		// admin.* routing: API endpoints go to dashboardMux, everything else serves the app
 		if host == "admin."+mainDomain {
+			// The admin API over Connect (admin.proto)
+			if strings.HasPrefix(r.URL.Path, connect.PathPrefix) {
+				rpc.ServeHTTP(w, r)
+				return
+			}
 			// Endpoints with their own API key auth - bypass AdminMiddleware
 			// These are used by remote peers and CLI tools
 			if r.URL.Path == "/api/deploy" ||
+				strings.HasPrefix(r.URL.Path, "/api/deploy/") ||
+				strings.HasPrefix(r.URL.Path, "/api/uploads") ||
 				strings.HasPrefix(r.URL.Path, "/api/users") ||
 				strings.HasPrefix(r.URL.Path, "/api/aliases") ||
-				(strings.HasPrefix(r.URL.Path, "/api/apps/") && strings.HasSuffix(r.URL.Path, "/status")) ||
+				strings.HasPrefix(r.URL.Path, "/api/apps") ||
 				r.URL.Path == "/api/system/health" ||
+				strings.HasPrefix(r.URL.Path, "/api/system/usage") ||
+				strings.HasPrefix(r.URL.Path, "/api/system/throttle") ||
+				strings.HasPrefix(r.URL.Path, "/api/system/deploy-limits") ||
+				strings.HasPrefix(r.URL.Path, "/api/system/crashloops") ||
 				strings.HasPrefix(r.URL.Path, "/api/system/logs") ||
+				strings.HasPrefix(r.URL.Path, "/api/system/jobs") ||
+				r.URL.Path == "/api/system/storage" ||
+				r.URL.Path == "/api/system/maintenance" ||
 				r.URL.Path == "/api/sql" ||
 				r.URL.Path == "/api/upgrade" ||
-				r.URL.Path == "/api/cmd" {
+				r.URL.Path == "/api/cmd" ||
+				r.URL.Path == "/api/keys" ||
+				strings.HasPrefix(r.URL.Path, "/api/status/incidents") ||
+				strings.HasPrefix(r.URL.Path, "/api/status/synthetics") ||
+				strings.HasPrefix(r.URL.Path, "/api/pings") ||
+				strings.HasPrefix(r.URL.Path, "/api/heartbeats") ||
+				strings.HasPrefix(r.URL.Path, "/api/failover") ||
+				strings.HasPrefix(r.URL.Path, "/api/s3") ||
+				strings.HasPrefix(r.URL.Path, "/api/slos") ||
+				strings.HasPrefix(r.URL.Path, "/api/freezes") ||
+				strings.HasPrefix(r.URL.Path, "/api/approvals") ||
+				strings.HasPrefix(r.URL.Path, "/api/security") ||
+				strings.HasPrefix(r.URL.Path, "/api/2fa") ||
+				strings.HasPrefix(r.URL.Path, "/api/redirects") {
+				middleware.APIKeyScope(dashboard).ServeHTTP(w, r)
+				return
+			}
+			// Runtime types for app editors (no auth required)
+			if r.URL.Path == "/api/types/fazt.d.ts" {
 				dashboardMux.ServeHTTP(w, r)
 				return
 			}
 			// Admin API endpoints require admin/owner role
 			if strings.HasPrefix(r.URL.Path, "/api/") {
-				middleware.AdminMiddleware(authHandler.Service())(dashboardMux).ServeHTTP(w, r)
+				middleware.AdminMiddleware(authHandler.Service())(dashboard).ServeHTTP(w, r)
 				return
 			}
-			// Public tracking endpoint (no auth required)
-			if r.URL.Path == "/track" {
+			// Public tracking endpoint and short links (no auth required)
+			if r.URL.Path == "/track" || strings.HasPrefix(r.URL.Path, "/r/") {
 				dashboardMux.ServeHTTP(w, r)
 				return
 			}
@@ -1568,10 +2199,27 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 
 
 
-		// 4. Subdomain Routing
+		// 4. Status Page Routing: public summary API, everything else serves the status site
 
 		subdomain := extractSubdomain(host, mainDomain)
 
+		if subdomain == "status" {
+
+			if r.URL.Path == "/api/status" {
+				handlers.StatusPageHandler(w, r)
+				return
+			}
+
+			siteHandler(w, r, "status")
+
+			return
+
+		}
+
+
+
+		// 5. Subdomain Routing
+
 		if subdomain != "" {
 
 			siteHandler(w, r, subdomain)
@@ -1587,7 +2235,9 @@ func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, authHand
 		serveSiteNotFound(w, r, host)
 
 	})
+	rpc = connect.NewHandler(connect.Admin, root)
 
+	return root
 }
 
 // extractDomain extracts the domain from a URL (removes protocol and path)
@@ -1668,6 +2318,19 @@ func siteHandler(w http.ResponseWriter, r *http.Request, subdomain string) {
 	// Use subdomain for file lookups (files are stored with site_id = subdomain)
 	// Use appID for analytics and identity tracking
 	siteID := subdomain
+	analyticsTags := ""
+
+	// Traffic split: pick a sticky variant via the fazt_split cookie and
+	// serve that app's files instead of the alias's own
+	if aliasType == "split" {
+		if variantID, err := handlers.ResolveSplitVariant(w, r, subdomain); err == nil && variantID != "" {
+			if variantSite, err := handlers.ResolveAppSiteID(variantID); err == nil && variantSite != "" {
+				appID = variantID
+				siteID = variantSite
+				analyticsTags = analytics.SplitTag(subdomain)
+			}
+		}
+	}
 
 	// Check if site exists
 	if !hosting.SiteExists(subdomain) && appID == "" {
@@ -1677,16 +2340,83 @@ func siteHandler(w http.ResponseWriter, r *http.Request, subdomain string) {
 
 	// Handle WebSocket connections at /_ws
 	if r.URL.Path == "/_ws" {
-		hosting.HandleWebSocket(w, r, subdomain)
+		hosting.HandleWebSocket(w, r, siteID)
 		return
 	}
 
+	// Resumable uploads into the signed-in user's blobs at /_uploads
+	if r.URL.Path == "/_uploads" || strings.HasPrefix(r.URL.Path, "/_uploads/") {
+		handlers.ServeAppUpload(w, r, siteID)
+		return
+	}
+
+	// Form submissions from the app's static pages at /__forms/<name>
+	if strings.HasPrefix(r.URL.Path, "/__forms/") {
+		handlers.ServeAppForm(w, r, siteID)
+		return
+	}
+
+	// The app's events as an iCalendar feed, when its manifest declares one;
+	// otherwise a calendar.ics file the app ships is served as usual
+	if r.URL.Path == "/calendar.ics" && hosting.AppCalendarFor(siteID) != nil {
+		handlers.ServeAppCalendar(w, r, siteID)
+		return
+	}
+
+	// The app's API docs, when its manifest turns them on; otherwise paths
+	// under /_docs are served as usual
+	if (r.URL.Path == "/_docs" || strings.HasPrefix(r.URL.Path, "/_docs/")) && hosting.AppDocsFor(siteID) != nil {
+		handlers.ServeAppDocs(w, r, siteID)
+		return
+	}
+
+	// Count the request toward the alias's SLO rollups and the app's daily
+	// cost (status probes excluded). Storage and egress bindings add to the
+	// meter carried in the request context. A panic is counted as a 500
+	// before the recovery middleware handles it.
+	if !status.IsProbe(r) {
+		usageApp := subdomain
+		if appID != "" {
+			usageApp = appID
+		}
+
+		// Apps in degraded mode get a strict request rate
+		if ok, wait := throttle.Allow(usageApp); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		ctx, meter := usage.WithMeter(r.Context())
+		r = r.WithContext(ctx)
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		start := time.Now()
+		defer func() {
+			elapsed := time.Since(start)
+			usage.Record(usageApp, meter.Cost(elapsed, rw.bytes))
+			if p := recover(); p != nil {
+				slo.Record(subdomain, http.StatusInternalServerError, elapsed)
+				panic(p)
+			}
+			slo.Record(subdomain, rw.statusCode, elapsed)
+		}()
+		w = rw
+	}
+
+	// Apply per-alias response transforms (headers, HTML injection, find/replace)
+	if transforms, err := handlers.GetAliasTransforms(subdomain); err == nil && len(transforms) > 0 {
+		tw := hosting.NewTransformWriter(w, r, transforms)
+		defer tw.Finish()
+		w = tw
+	}
+
 	// Log analytics event for site visits (using app_id for v0.10, fallback to subdomain)
 	analyticsID := subdomain
 	if appID != "" {
 		analyticsID = appID
 	}
-	logSiteVisit(r, analyticsID)
+	logSiteVisit(r, analyticsID, analyticsTags)
 
 	// Auth-gated private directory access
 	// Authenticated users can stream files directly; serverless can also access via fazt.private.*
@@ -1726,17 +2456,27 @@ func siteHandler(w http.ResponseWriter, r *http.Request, subdomain string) {
 }
 
 // logSiteVisit logs an analytics event for a site visit
-func logSiteVisit(r *http.Request, subdomain string) {
-	analytics.Add(analytics.Event{
+// tags attributes the event to a routing source (e.g. "split:<alias>")
+// Skipped for status monitor probes, when the app disables collection, or to
+// honor the visitor's DNT/GPC signal
+func logSiteVisit(r *http.Request, subdomain, tags string) {
+	if status.IsProbe(r) || !analytics.ShouldTrack(r, subdomain) {
+		return
+	}
+	event := analytics.Event{
 		Domain:      subdomain,
+		Tags:        tags,
 		SourceType:  "hosting",
 		EventType:   "pageview",
 		Path:        r.URL.Path,
 		Referrer:    r.Referer(),
 		UserAgent:   r.UserAgent(),
-		IPAddress:   r.RemoteAddr,
+		IPAddress:   realip.FromRequest(r),
 		QueryParams: r.URL.RawQuery,
-	})
+		Campaign:    analytics.ParseCampaign(r.URL.Query()),
+	}
+	analytics.Add(event)
+	analytics.RecordLive(event)
 }
 
 // serveSiteNotFound renders the 404 page for non-existent sites
@@ -1836,6 +2576,11 @@ func createDeployZipWithOptions(dir string, opts *DeployZipOptions) (*DeployZipR
 		".git",
 		".DS_Store",
 		"*.log",
+		// Editor types from `fazt app create`, never served
+		"*.d.ts",
+		"jsconfig.json",
+		// Recorded fetch responses for `fazt app test`; may hold API data
+		"fetch.fixtures.json",
 	}
 
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
@@ -2092,6 +2837,19 @@ func handleSetConfigCommand() {
 	domain := flags.String("domain", "", "Server domain")
 	port := flags.String("port", "", "Server port")
 	env := flags.String("env", "", "Environment (development|production)")
+	require2FA := flags.String("require-2fa", "", "Require TOTP two-factor auth for password, OAuth and invite logins (true|false)")
+	requireApproval := flags.String("require-approval", "", "Require a second admin to approve app removal, user deletion and config changes (true|false)")
+	rateLimit := flags.String("rate-limit", "", "Request rate limits, e.g. ip=500/1000,site=200/400,api=100/200 (0 disables)")
+	trustedProxies := flags.String("trusted-proxies", "", "CIDRs whose X-Forwarded-For is trusted, e.g. 127.0.0.1,10.0.0.0/8 (none clears)")
+	vfsCache := flags.String("vfs-cache", "", "In-memory cache for hot static files, in MB (0 disables)")
+	slowStorage := flags.String("slow-storage", "", "Log app storage operations slower than this, in ms")
+	vmPool := flags.String("vm-pool", "", "Serverless VM pool, e.g. size=100,app=20,queue=2000 (VMs, executions per app, queue ms)")
+	secretScan := flags.String("secret-scan", "", "On deploys with likely secrets (.env files, keys): off, warn or block")
+	analyticsSpec := flags.String("analytics", "", "Analytics retention in days, e.g. retention=365,raw=7 (raw events kept; 0 keeps them, recent days read raw), ip=keep|drop, and geoip=<MaxMind DB path>|none")
+	virusScan := flags.String("virus-scan", "", "Scan uploaded blobs with ClamAV: off, clamd[:<socket or host:port>] or exec[:<clamscan path>]")
+	virusScanPaths := flags.String("virus-scan-paths", "", "Blob paths to scan, e.g. uploads/**,**/*.pdf (all scans every upload)")
+	uploadsSpec := flags.String("uploads", "", "Resumable upload limits, e.g. max=1024,expire=48 (largest upload in MB, hours unfinished uploads are kept)")
+	deployLimitsSpec := flags.String("deploy-limits", "", "Deploy limits, e.g. bundle=500,files=10000,file=100 (total MB, file count, largest file MB; 0 is unlimited)")
 	db := flags.String("db", "", "Database file path")
 
 	flags.Usage = func() {
@@ -2105,6 +2863,19 @@ func handleSetConfigCommand() {
 		fmt.Println("  fazt server set-config --domain https://newdomain.com")
 		fmt.Println("  fazt server set-config --port 8080")
 		fmt.Println("  fazt server set-config --env production")
+		fmt.Println("  fazt server set-config --require-2fa true")
+		fmt.Println("  fazt server set-config --require-approval true")
+		fmt.Println("  fazt server set-config --rate-limit site=200/400,api=50")
+		fmt.Println("  fazt server set-config --trusted-proxies 127.0.0.1,::1")
+		fmt.Println("  fazt server set-config --vfs-cache 256")
+		fmt.Println("  fazt server set-config --slow-storage 50")
+		fmt.Println("  fazt server set-config --vm-pool size=50,app=10")
+		fmt.Println("  fazt server set-config --secret-scan block")
+		fmt.Println("  fazt server set-config --analytics retention=365,ip=drop")
+		fmt.Println("  fazt server set-config --analytics geoip=/var/lib/GeoIP/GeoLite2-City.mmdb")
+		fmt.Println("  fazt server set-config --virus-scan clamd:/run/clamav/clamd.ctl --virus-scan-paths 'uploads/**'")
+		fmt.Println("  fazt server set-config --uploads max=2048,expire=48")
+		fmt.Println("  fazt server set-config --deploy-limits bundle=200,files=5000")
 		fmt.Println("  fazt server set-config --domain https://prod.com --port 443 --env production")
 		fmt.Println("  fazt server set-config --domain https://prod.com --db /path/to/data.db")
 	}
@@ -2123,7 +2894,7 @@ func handleSetConfigCommand() {
 	}
 
 	// Call command function
-	if err := setConfigCommand(*domain, *port, *env, dbPath); err != nil {
+	if err := setConfigCommand(*domain, *port, *env, *require2FA, *requireApproval, *rateLimit, *trustedProxies, *vfsCache, *slowStorage, *vmPool, *secretScan, *analyticsSpec, *virusScan, *virusScanPaths, *uploadsSpec, *deployLimitsSpec, dbPath); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
@@ -2138,6 +2909,45 @@ func handleSetConfigCommand() {
 	if *env != "" {
 		fmt.Printf("  Environment: %s\n", *env)
 	}
+	if *require2FA != "" {
+		fmt.Printf("  Require 2FA: %s (restart the server to apply)\n", *require2FA)
+	}
+	if *requireApproval != "" {
+		fmt.Printf("  Require approval: %s (restart the server to apply)\n", *requireApproval)
+	}
+	if *rateLimit != "" {
+		fmt.Printf("  Rate limits: %s (restart the server to apply)\n", *rateLimit)
+	}
+	if *trustedProxies != "" {
+		fmt.Printf("  Trusted proxies: %s (restart the server to apply)\n", *trustedProxies)
+	}
+	if *vfsCache != "" {
+		fmt.Printf("  VFS cache: %s MB (restart the server to apply)\n", *vfsCache)
+	}
+	if *slowStorage != "" {
+		fmt.Printf("  Slow storage threshold: %s ms (restart the server to apply)\n", *slowStorage)
+	}
+	if *vmPool != "" {
+		fmt.Printf("  VM pool: %s (restart the server to apply)\n", *vmPool)
+	}
+	if *secretScan != "" {
+		fmt.Printf("  Secret scan: %s (restart the server to apply)\n", *secretScan)
+	}
+	if *analyticsSpec != "" {
+		fmt.Printf("  Analytics: %s (restart the server to apply)\n", *analyticsSpec)
+	}
+	if *virusScan != "" {
+		fmt.Printf("  Virus scan: %s (restart the server to apply)\n", *virusScan)
+	}
+	if *virusScanPaths != "" {
+		fmt.Printf("  Virus scan paths: %s (restart the server to apply)\n", *virusScanPaths)
+	}
+	if *uploadsSpec != "" {
+		fmt.Printf("  Uploads: %s (restart the server to apply)\n", *uploadsSpec)
+	}
+	if *deployLimitsSpec != "" {
+		fmt.Printf("  Deploy limits: %s (restart the server to apply)\n", *deployLimitsSpec)
+	}
 	fmt.Println()
 }
 
@@ -2343,12 +3153,11 @@ func handleClientLogsCommand() {
 	}
 
 	var result struct {
-		Success bool `json:"success"`
-		Logs    []struct {
+		Data []struct {
 			Level     string `json:"level"`
 			Message   string `json:"message"`
 			CreatedAt string `json:"created_at"`
-		} `json:"logs"`
+		} `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -2357,7 +3166,7 @@ func handleClientLogsCommand() {
 	}
 
 	fmt.Printf("Logs for %s (last %d):\n", *site, *limit)
-	for _, log := range result.Logs {
+	for _, log := range result.Data {
 		fmt.Printf("[%s] [%s] %s\n", log.CreatedAt, log.Level, log.Message)
 	}
 }
@@ -2709,6 +3518,20 @@ func handleStartCommand() {
 	// All sessions are database-backed for persistence and unified auth
 	isSecure := cfg.Server.Env == "production" || cfg.HTTPS.Enabled
 	authService := auth.NewService(database.GetDB(), cfg.Server.Domain, isSecure)
+	authService.SetRequire2FA(cfg.Auth.Require2FA)
+	approval.SetRequired(cfg.Auth.RequireApproval)
+	hosting.SetSecretScanMode(cfg.Server.SecretScan)
+	hosting.SetDeployLimits(hosting.DeployLimits{
+		MaxBundleBytes: int64(cfg.Server.DeployLimits.MaxBundleMB) << 20,
+		MaxFiles:       cfg.Server.DeployLimits.MaxFiles,
+		MaxFileBytes:   int64(cfg.Server.DeployLimits.MaxFileMB) << 20,
+	})
+	if scanPolicy, err := virusscan.NewPolicy(cfg.Server.VirusScan.Mode, cfg.Server.VirusScan.Address, cfg.Server.VirusScan.Paths); err != nil {
+		log.Fatalf("Invalid virus scan config: %v", err)
+	} else if scanPolicy != nil {
+		storage.SetUploadScanner(scanPolicy.Check)
+		fmt.Printf("  Virus scan:     ✓ %s\n", scanPolicy.Mode)
+	}
 	authHandler := auth.NewHandler(authService)
 
 	// Initialize auth handlers with auth service and rate limiter
@@ -2724,6 +3547,9 @@ func handleStartCommand() {
 
 	// Display auth status (v0.4.0: auth always required)
 	fmt.Printf("  Authentication: ✓ Enabled (user: %s)\n", cfg.Auth.Username)
+	if cfg.Auth.Require2FA {
+		fmt.Println("  Two-factor:     ✓ Required")
+	}
 	fmt.Println()
 
 	// Initialize audit logging (LEGACY_CODE: Migrate to activity.Log())
@@ -2733,6 +3559,8 @@ func handleStartCommand() {
 
 	// Initialize global write queue (must come before analytics/activity)
 	storage.InitWriter()
+	usage.SetWriter(storage.QueueWriteTx)
+	storage.SetSlowOpThreshold(time.Duration(cfg.Server.SlowStorageMs) * time.Millisecond)
 
 	// Initialize activity logger (unified logging system)
 	activity.Init()
@@ -2752,17 +3580,32 @@ func handleStartCommand() {
 	// Initialize analytics buffer (LEGACY_CODE: Migrate to activity.Log())
 	analytics.Init()
 
+	// Roll analytics up into hourly and daily counts and apply retention
+	analytics.SetRawDays(cfg.Server.Analytics.RawDays)
+	analytics.SetDropIP(cfg.Server.Analytics.DropIP)
+	if path := cfg.Server.Analytics.GeoIPPath; path != "" {
+		if geo, err := analytics.OpenGeoIP(path); err != nil {
+			log.Printf("Warning: Failed to open GeoIP database, events won't be located: %v", err)
+		} else {
+			analytics.SetGeoIP(geo)
+		}
+	}
+	analyticsRollups := analytics.NewRollups(database.GetDB(), cfg.Server.Analytics.RetentionDays)
+	analyticsRollups.Start()
+	defer analyticsRollups.Stop()
+
 	// Initialize worker pool
 	if err := worker.Init(database.GetDB()); err != nil {
 		log.Printf("Warning: Failed to initialize worker pool: %v", err)
 	}
-	worker.SetupGlobalExecutor(database.GetDB())
+	workerExecutor := worker.SetupGlobalExecutor(database.GetDB())
 
 	// Initialize hosting system
 	if err := hosting.Init(database.GetDB()); err != nil {
 		log.Fatalf("Failed to initialize hosting: %v", err)
 	}
-	log.Printf("Hosting initialized (VFS Mode)")
+	hosting.SetCacheSize(int64(cfg.Server.VFSCacheMB) * 1024 * 1024)
+	log.Printf("Hosting initialized (VFS Mode, %d MB cache)", cfg.Server.VFSCacheMB)
 
 	// Set up worker idle timeout listener count function
 	worker.SetListenerCountFunc(func(appID, channel string) int {
@@ -2771,7 +3614,14 @@ func handleStartCommand() {
 	})
 
 	// Initialize serverless handler with storage support
-	serverlessHandler = jsruntime.NewServerlessHandler(database.GetDB())
+	// Pre-warmed VMs, shared by all apps with a per-app concurrency cap
+	serverlessHandler = jsruntime.NewServerlessHandlerWithRuntime(database.GetDB(), jsruntime.NewRuntimeWithPool(jsruntime.PoolConfig{
+		Size:           cfg.Server.VMPool.Size,
+		AppConcurrency: cfg.Server.VMPool.AppConcurrency,
+		QueueTimeout:   time.Duration(cfg.Server.VMPool.QueueMs) * time.Millisecond,
+	}, jsruntime.DefaultTimeout))
+	handlers.SetVMPoolStats(func() interface{} { return serverlessHandler.PoolStats() })
+	log.Printf("Serverless VM pool: %d VMs, %d per app", cfg.Server.VMPool.Size, cfg.Server.VMPool.AppConcurrency)
 
 	// Initialize egress proxy for fazt.net.fetch()
 	egressAllowlist := egress.NewAllowlist(database.GetDB())
@@ -2782,9 +3632,103 @@ func handleStartCommand() {
 	egressLogger.Start()
 	defer egressLogger.Stop()
 	egressProxy.SetLogger(egressLogger)
+
+	// Canary rollout controller: steps traffic splits and promotes or rolls back
+	canaryController := canary.NewController(database.GetDB())
+	canaryController.Start()
+	defer canaryController.Stop()
+
+	// SLO controller: flushes request rollups and raises burn-rate alerts
+	sloController := slo.NewController(database.GetDB())
+	sloController.Start()
+	defer sloController.Stop()
+
+	// Usage flusher: writes per-app request costs to app_usage
+	usageFlusher := usage.NewFlusher(database.GetDB())
+	usageFlusher.Start()
+	defer usageFlusher.Stop()
+
+	// Field advisor: logs JSON fields worth extracting into an index
+	fieldAdvisor := storage.NewFieldAdvisor(database.GetDB())
+	fieldAdvisor.Start()
+	defer fieldAdvisor.Stop()
+
+	// Throttle controller: puts apps over their daily budgets in degraded
+	// mode (one job and two executions at a time, strict request rate,
+	// cached fetches only)
+	throttleController := throttle.NewController(database.GetDB())
+	throttleController.OnChange = func(app string, degraded bool) {
+		limit := 0
+		if degraded {
+			limit = throttle.DegradedWorkers
+		}
+		worker.SetAppLimit(app, limit)
+
+		vms := 0
+		if degraded {
+			vms = throttle.DegradedExecutions
+		}
+		serverlessHandler.SetAppLimit(app, vms)
+	}
+	throttleController.Start()
+	defer throttleController.Stop()
+
+	// Crash-loop isolation: restore apps tripped before the restart
+	if err := crashloop.Init(database.GetDB()); err != nil {
+		log.Printf("Warning: Failed to restore crash-loop state: %v", err)
+	}
+
+	// Notify the admin of jobs that failed every attempt, once an hour per
+	// app and handler
+	stopJobAlerts := events.Subscribe(func(e events.Event) {
+		if e.Type != events.JobFailed {
+			return
+		}
+		handler, _ := e.Data["handler"].(string)
+		message := fmt.Sprintf("Job %v (%s) in %s failed after %v attempts: %v",
+			e.Data["job_id"], handler, e.AppID, e.Data["attempts"], e.Data["error"])
+		if err := notify.SendOnce(notify.EventJobFailed, e.AppID+" "+handler, "Job failed", message); err != nil {
+			log.Printf("Failed to send job notification: %v", err)
+		}
+	})
+	defer stopJobAlerts()
+
+	// Disk monitor: alerts when the volume holding the database fills up
+	diskMonitor := system.NewDiskMonitor(filepath.Dir(cfg.Database.Path))
+	diskMonitor.Start()
+	defer diskMonitor.Stop()
+
+	// Resumable uploads are staged next to the database until complete
+	uploadStore, err := uploads.NewStore(database.GetDB(), filepath.Join(filepath.Dir(cfg.Database.Path), "uploads"),
+		cfg.Server.Uploads.MaxMB, cfg.Server.Uploads.ExpireHours)
+	if err != nil {
+		log.Fatalf("Failed to initialize uploads: %v", err)
+	}
+	handlers.InitUploads(uploadStore)
+	uploadStore.Start()
+	defer uploadStore.Stop()
 	egressCache := egress.NewNetCache()
 	egressProxy.SetCache(egressCache)
+	egressProxy.SetCacheOnly(throttle.IsDegraded)
 	serverlessHandler.SetEgressProxy(egressProxy)
+	workerExecutor.SetEgressProxy(egressProxy)
+
+	// Scheduled pings: outbound calls on a cron schedule, through the proxy
+	pingController := pings.NewController(database.GetDB(), egressProxy)
+	handlers.InitPings(pingController)
+	pingController.Start()
+	defer pingController.Stop()
+
+	heartbeatController := heartbeats.NewController(database.GetDB())
+	handlers.InitHeartbeats(heartbeatController)
+	heartbeatController.Start()
+	defer heartbeatController.Stop()
+
+	// Failover: replicate apps to the standby peer, if one is set
+	failoverController := failover.NewController(database.GetDB(), cfg.Database.Path)
+	handlers.InitFailover(failoverController)
+	failoverController.Start()
+	defer failoverController.Stop()
 
 	// Connect auth service to serverless handler for fazt.auth.* bindings
 	serverlessHandler.SetAuthProvider(auth.NewAuthProviderAdapter(authService))
@@ -2793,6 +3737,32 @@ func handleStartCommand() {
 	if err := worker.RestoreDaemons(); err != nil {
 		log.Printf("Warning: Failed to restore daemon workers: %v", err)
 	}
+	// Queue the sends of app email a restart interrupted
+	if err := worker.ResumeMail(); err != nil {
+		log.Printf("Warning: Failed to resume queued mail: %v", err)
+	}
+
+	// Receive email for apps at <app>@apps.<domain> (mail.inbound.port)
+	if port := cfg.Mail.Inbound.Port; port != "" {
+		inboxServer := inbox.NewServer(database.GetDB(), inboundMailDomain(cfg), int64(cfg.Mail.Inbound.MaxMB)<<20)
+		// Without TCP_DEFER_ACCEPT: SMTP clients wait for the server to speak first
+		inboxListener, err := listener.Listen("tcp4", ":"+port, listener.ListenConfig{})
+		if err != nil {
+			log.Printf("Warning: Could not receive mail on :%s: %v", port, err)
+		} else {
+			log.Printf("Receiving mail for <app>@%s on :%s", inboxServer.Domain, port)
+			go func() {
+				err := inboxServer.Serve(listener.NewConnLimiter(inboxListener, listener.ConnLimiterConfig{
+					MaxConnsPerIP: 10,
+					MaxTotalConns: 500,
+				}))
+				if err != nil {
+					log.Printf("Mail server error: %v", err)
+				}
+			}()
+			defer inboxServer.Close()
+		}
+	}
 
 	// Generate mock data in development mode
 	if cfg.IsDevelopment() {
@@ -2816,12 +3786,34 @@ func handleStartCommand() {
 
 	// Authentication routes (admin dashboard)
 	dashboardMux.HandleFunc("/api/login", handlers.LoginHandler)
+	dashboardMux.HandleFunc("/api/login/2fa", handlers.LoginTwoFactorHandler)
+	dashboardMux.HandleFunc("/api/login/passkey/begin", handlers.PasskeyLoginBeginHandler)
+	dashboardMux.HandleFunc("/api/login/passkey/finish", handlers.PasskeyLoginFinishHandler)
 	dashboardMux.HandleFunc("/api/logout", handlers.LogoutHandler)
+
+	// Two-factor authentication (TOTP)
+	dashboardMux.HandleFunc("GET /api/2fa", handlers.TwoFactorStatusHandler)
+	dashboardMux.HandleFunc("POST /api/2fa/enroll", handlers.TwoFactorEnrollHandler)
+	dashboardMux.HandleFunc("POST /api/2fa/enable", handlers.TwoFactorEnableHandler)
+	dashboardMux.HandleFunc("POST /api/2fa/disable", handlers.TwoFactorDisableHandler)
+	dashboardMux.HandleFunc("POST /api/2fa/recovery-codes", handlers.TwoFactorRecoveryCodesHandler)
+
+	// Passkeys (WebAuthn)
+	dashboardMux.HandleFunc("GET /api/passkeys", handlers.PasskeysListHandler)
+	dashboardMux.HandleFunc("POST /api/passkeys/register/begin", handlers.PasskeyRegisterBeginHandler)
+	dashboardMux.HandleFunc("POST /api/passkeys/register/finish", handlers.PasskeyRegisterFinishHandler)
+	dashboardMux.HandleFunc("DELETE /api/passkeys/{id}", handlers.PasskeyDeleteHandler)
 	dashboardMux.HandleFunc("/api/auth/status", handlers.AuthStatusHandler)
 	dashboardMux.HandleFunc("/api/user/me", handlers.UserMeHandler)
 	dashboardMux.HandleFunc("GET /api/users", handlers.UsersListHandler)
 	dashboardMux.HandleFunc("GET /api/users/{id}/status", handlers.UserStatusHandler)
 	dashboardMux.HandleFunc("POST /api/users/role", handlers.UserSetRoleHandler)
+	dashboardMux.HandleFunc("POST /api/users/{id}/impersonate", handlers.UserImpersonateHandler)
+	dashboardMux.HandleFunc("DELETE /api/users/{id}/impersonate", handlers.UserImpersonateEndHandler)
+	dashboardMux.HandleFunc("GET /api/users/sessions", handlers.UserSessionsListHandler)
+	dashboardMux.HandleFunc("DELETE /api/users/{id}/sessions/{session}", handlers.UserSessionRevokeHandler)
+	dashboardMux.HandleFunc("GET /api/auth/sessions", handlers.SessionsListHandler)
+	dashboardMux.HandleFunc("DELETE /api/auth/sessions/{id}", handlers.SessionRevokeHandler)
 
 	// Multi-user auth routes (v0.16) - includes POST /auth/login for simple password login
 	authHandler.RegisterRoutes(dashboardMux)
@@ -2834,9 +3826,15 @@ func handleStartCommand() {
 
 	// API routes - Dashboard
 	dashboardMux.HandleFunc("/api/stats", handlers.StatsHandler)
+	dashboardMux.HandleFunc("GET /api/stats/breakdown", handlers.StatsBreakdownHandler)
+	dashboardMux.HandleFunc("GET /api/stats/campaigns", handlers.StatsCampaignsHandler)
+	dashboardMux.HandleFunc("GET /api/stats/live", handlers.StatsLiveHandler)
 	dashboardMux.HandleFunc("/api/events", handlers.EventsHandler)
 	dashboardMux.HandleFunc("/api/redirects", handlers.RedirectsHandler)
 	dashboardMux.HandleFunc("DELETE /api/redirects/{id}", handlers.DeleteRedirectHandler)
+	dashboardMux.HandleFunc("POST /api/redirects/import", handlers.RedirectImportHandler)
+	dashboardMux.HandleFunc("GET /api/redirects/{slug}/stats", handlers.RedirectStatsHandler)
+	dashboardMux.HandleFunc("GET /api/redirects/{slug}/qr", handlers.RedirectQRHandler)
 	dashboardMux.HandleFunc("/api/domains", handlers.DomainsHandler)
 	dashboardMux.HandleFunc("/api/tags", handlers.TagsHandler)
 	dashboardMux.HandleFunc("/api/webhooks", handlers.WebhooksHandler)
@@ -2844,40 +3842,111 @@ func handleStartCommand() {
 	dashboardMux.HandleFunc("PUT /api/webhooks/{id}", handlers.UpdateWebhookHandler)
 	dashboardMux.HandleFunc("GET /api/system/limits", handlers.SystemLimitsHandler)
 	dashboardMux.HandleFunc("GET /api/system/limits/schema", handlers.SystemLimitsSchemaHandler)
+	dashboardMux.HandleFunc("GET /api/types/fazt.d.ts", handlers.FaztTypesHandler)
 	dashboardMux.HandleFunc("POST /api/sql", handlers.HandleSQL)
 	dashboardMux.HandleFunc("GET /api/system/cache", handlers.SystemCacheHandler)
 	dashboardMux.HandleFunc("GET /api/system/db", handlers.SystemDBHandler)
 	dashboardMux.HandleFunc("GET /api/system/config", handlers.SystemConfigHandler)
+	dashboardMux.HandleFunc("PUT /api/system/config", handlers.SystemConfigUpdateHandler)
 	dashboardMux.HandleFunc("/api/config", handlers.SystemConfigHandler) // Alias
 	dashboardMux.HandleFunc("GET /api/system/health", handlers.SystemHealthHandler)
 	dashboardMux.HandleFunc("GET /api/system/capacity", handlers.SystemCapacityHandler)
 	dashboardMux.HandleFunc("GET /api/system/logs", handlers.SystemLogsHandler)
 	dashboardMux.HandleFunc("GET /api/system/logs/stats", handlers.SystemLogsStatsHandler)
+	dashboardMux.HandleFunc("GET /api/system/usage", handlers.SystemUsageHandler)
+	dashboardMux.HandleFunc("GET /api/system/usage/export", handlers.UsageExportHandler)
+	dashboardMux.HandleFunc("GET /api/system/usage/runtime", handlers.SystemRuntimeUsageHandler)
+	dashboardMux.HandleFunc("GET /api/system/usage/hooks", handlers.UsageHooksListHandler)
+	dashboardMux.HandleFunc("POST /api/system/usage/hooks", handlers.UsageHookCreateHandler)
+	dashboardMux.HandleFunc("DELETE /api/system/usage/hooks/{id}", handlers.UsageHookDeleteHandler)
+	dashboardMux.HandleFunc("GET /api/system/throttle", handlers.ThrottleListHandler)
+	dashboardMux.HandleFunc("PUT /api/system/throttle/{app}", handlers.ThrottleBudgetSetHandler)
+	dashboardMux.HandleFunc("DELETE /api/system/throttle/{app}", handlers.ThrottleBudgetDeleteHandler)
+	dashboardMux.HandleFunc("POST /api/system/throttle/{app}/override", handlers.ThrottleOverrideHandler)
+	dashboardMux.HandleFunc("GET /api/system/deploy-limits", handlers.DeployLimitsListHandler)
+	dashboardMux.HandleFunc("PUT /api/system/deploy-limits/{scope}/{name}", handlers.DeployLimitsSetHandler)
+	dashboardMux.HandleFunc("DELETE /api/system/deploy-limits/{scope}/{name}", handlers.DeployLimitsDeleteHandler)
+	dashboardMux.HandleFunc("GET /api/system/crashloops", handlers.CrashLoopListHandler)
+	dashboardMux.HandleFunc("DELETE /api/system/crashloops/{app}", handlers.CrashLoopResetHandler)
+	dashboardMux.HandleFunc("GET /api/system/storage/ops", handlers.SystemStorageOpsHandler)
+	dashboardMux.HandleFunc("GET /api/system/storage/fields", handlers.SystemStorageFieldsHandler)
+	dashboardMux.HandleFunc("POST /api/system/storage/fields", handlers.SystemStorageExtractHandler)
+	dashboardMux.HandleFunc("DELETE /api/system/storage/fields/{field}", handlers.SystemStorageDropFieldHandler)
 	dashboardMux.HandleFunc("POST /api/system/logs/cleanup", handlers.SystemLogsCleanupHandler)
 
 	// API routes - Hosting/Deploy
 	dashboardMux.HandleFunc("/api/deploy", handlers.DeployHandler)
+	dashboardMux.HandleFunc("/api/deploy/", handlers.AppAccess(handlers.DeployStatusHandler))
+	dashboardMux.HandleFunc("/api/uploads", handlers.AppAccess(handlers.UploadsHandler))
+	dashboardMux.HandleFunc("/api/uploads/", handlers.AppAccess(handlers.UploadsHandler))
 	dashboardMux.HandleFunc("/api/sites", handlers.SitesHandler)
 	dashboardMux.HandleFunc("GET /api/sites/{id}", handlers.SiteDetailHandler)
 	dashboardMux.HandleFunc("GET /api/sites/{id}/files", handlers.SiteFilesHandler)
 	dashboardMux.HandleFunc("GET /api/sites/{id}/files/{path...}", handlers.SiteFileContentHandler)
 
 	// Apps API v2 (v0.10 - identity model)
-	dashboardMux.HandleFunc("GET /api/apps", handlers.AppsListHandlerV2)
-	dashboardMux.HandleFunc("POST /api/apps", handlers.AppCreateHandlerV2)
-	dashboardMux.HandleFunc("POST /api/apps/install", handlers.AppInstallHandler)
-	dashboardMux.HandleFunc("POST /api/apps/create", handlers.AppCreateHandler) // Legacy
+	// AppAccess authenticates the caller; handlers enforce per-app roles (app_members)
+	dashboardMux.HandleFunc("GET /api/apps", handlers.AppAccess(handlers.AppsListHandlerV2))
+	dashboardMux.HandleFunc("POST /api/apps", handlers.AppAccess(handlers.AppCreateHandlerV2))
+	dashboardMux.HandleFunc("POST /api/apps/install", handlers.AppAccess(handlers.AppInstallHandler))
+	dashboardMux.HandleFunc("POST /api/apps/create", handlers.AppAccess(handlers.AppCreateHandler)) // Legacy
 	dashboardMux.HandleFunc("GET /api/templates", handlers.TemplatesListHandler)
-	dashboardMux.HandleFunc("GET /api/apps/{id}", handlers.AppDetailHandlerV2)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/status", handlers.AppStatusHandler)
-	dashboardMux.HandleFunc("PUT /api/apps/{id}", handlers.AppUpdateHandlerV2)
-	dashboardMux.HandleFunc("DELETE /api/apps/{id}", handlers.AppDeleteHandlerV2)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/files", handlers.AppFilesHandler)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/source", handlers.AppSourceHandler)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/files/{path...}", handlers.AppFileContentHandler)
-	dashboardMux.HandleFunc("POST /api/apps/{id}/fork", handlers.AppForkHandler)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/lineage", handlers.AppLineageHandler)
-	dashboardMux.HandleFunc("GET /api/apps/{id}/forks", handlers.AppForksHandler)
+	dashboardMux.HandleFunc("GET /api/apps/{id}", handlers.AppAccess(handlers.AppDetailHandlerV2))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/status", handlers.AppAccess(handlers.AppStatusHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}", handlers.AppAccess(handlers.AppUpdateHandlerV2))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}", handlers.AppAccess(handlers.AppDeleteHandlerV2))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/protect", handlers.AppAccess(handlers.AppProtectHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/system", handlers.AppAccess(handlers.AppSystemAccessHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/backups", handlers.AppAccess(handlers.AppBackupsHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/restore", handlers.AppAccess(handlers.AppRestoreHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/sync", handlers.AppAccess(handlers.AppSyncManifestHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/sync", handlers.AppAccess(handlers.AppSyncHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/sync/export", handlers.AppAccess(handlers.AppSyncExportHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/export", handlers.AppAccess(handlers.AppExportHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/files", handlers.AppAccess(handlers.AppFilesHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/source", handlers.AppAccess(handlers.AppSourceHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/files/{path...}", handlers.AppAccess(handlers.AppFileContentHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/fork", handlers.AppAccess(handlers.AppForkHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/lineage", handlers.AppAccess(handlers.AppLineageHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/diff", handlers.AppAccess(handlers.AppDiffHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/merge", handlers.AppAccess(handlers.AppMergeHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/jobs", handlers.AppAccess(handlers.AppJobsHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/jobs/{job}", handlers.AppAccess(handlers.AppJobHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/jobs/{job}/cancel", handlers.AppAccess(handlers.AppJobCancelHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/services", handlers.AppAccess(handlers.AppServicesHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/services/{name}/restart", handlers.AppAccess(handlers.AppServiceRestartHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/services/{name}/logs", handlers.AppAccess(handlers.AppServiceLogsHandler))
+	dashboardMux.HandleFunc("GET /api/system/jobs/dead", handlers.DeadJobsHandler)
+	dashboardMux.HandleFunc("POST /api/system/jobs/dead/{id}/retry", handlers.DeadJobRetryHandler)
+	dashboardMux.HandleFunc("GET /api/system/storage", handlers.SystemStorageHandler)
+	dashboardMux.HandleFunc("GET /api/system/maintenance", handlers.SystemMaintenanceStatusHandler)
+	dashboardMux.HandleFunc("POST /api/system/maintenance", handlers.SystemMaintenanceHandler)
+	dashboardMux.HandleFunc("GET /api/apps/{id}/forks", handlers.AppAccess(handlers.AppForksHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/members", handlers.AppAccess(handlers.AppMembersListHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/members", handlers.AppAccess(handlers.AppMemberShareHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/members/{user}", handlers.AppAccess(handlers.AppMemberRemoveHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/auth", handlers.AppAccess(handlers.AppAuthHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/auth/providers/{provider}", handlers.AppAccess(handlers.AppAuthEnableHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/auth/providers/{provider}", handlers.AppAccess(handlers.AppAuthDisableHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/auth/users", handlers.AppAccess(handlers.AppAuthUsersHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/auth/users/{user}", handlers.AppAccess(handlers.AppAuthUserDeleteHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/storage/ops", handlers.AppAccess(handlers.AppStorageOpsHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/storage/explain", handlers.AppAccess(handlers.AppStorageExplainHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/storage/candidates", handlers.AppAccess(handlers.AppStorageCandidatesHandler))
+	dashboardMux.HandleFunc("POST /api/apps/{id}/bindings", handlers.AppAccess(handlers.AppBindingCallHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/chaos", handlers.AppAccess(handlers.AppChaosHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/chaos", handlers.AppAccess(handlers.AppChaosSetHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/chaos", handlers.AppAccess(handlers.AppChaosClearHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/fixtures", handlers.AppAccess(handlers.AppFixturesHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/fixtures", handlers.AppAccess(handlers.AppFixturesSetHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/fixtures", handlers.AppAccess(handlers.AppFixturesClearHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/mail", handlers.AppAccess(handlers.AppMailHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/mail/{mail}", handlers.AppAccess(handlers.AppMailMessageHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/mail/settings", handlers.AppAccess(handlers.AppMailSettingsHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/mail/settings", handlers.AppAccess(handlers.AppMailSettingsDeleteHandler))
+	dashboardMux.HandleFunc("GET /api/apps/{id}/grants", handlers.AppAccess(handlers.AppGrantsHandler))
+	dashboardMux.HandleFunc("PUT /api/apps/{id}/grants", handlers.AppAccess(handlers.AppGrantHandler))
+	dashboardMux.HandleFunc("DELETE /api/apps/{id}/grants", handlers.AppAccess(handlers.AppGrantDeleteHandler))
 
 	// Aliases API (v0.10 - routing layer)
 	dashboardMux.HandleFunc("GET /api/aliases", handlers.AliasesListHandler)
@@ -2885,8 +3954,15 @@ func handleStartCommand() {
 	dashboardMux.HandleFunc("GET /api/aliases/{subdomain}", handlers.AliasDetailHandler)
 	dashboardMux.HandleFunc("PUT /api/aliases/{subdomain}", handlers.AliasUpdateHandler)
 	dashboardMux.HandleFunc("DELETE /api/aliases/{subdomain}", handlers.AliasDeleteHandler)
+	dashboardMux.HandleFunc("PUT /api/aliases/{subdomain}/protect", handlers.AliasProtectHandler)
 	dashboardMux.HandleFunc("POST /api/aliases/{subdomain}/reserve", handlers.AliasReserveHandler)
 	dashboardMux.HandleFunc("POST /api/aliases/{subdomain}/split", handlers.AliasSplitHandler)
+	dashboardMux.HandleFunc("GET /api/aliases/{subdomain}/split", handlers.AliasSplitStatusHandler)
+	dashboardMux.HandleFunc("GET /api/aliases/{subdomain}/transforms", handlers.AliasTransformsGetHandler)
+	dashboardMux.HandleFunc("PUT /api/aliases/{subdomain}/transforms", handlers.AliasTransformsUpdateHandler)
+	dashboardMux.HandleFunc("GET /api/aliases/{subdomain}/canary", handlers.AliasCanaryStatusHandler)
+	dashboardMux.HandleFunc("POST /api/aliases/{subdomain}/canary", handlers.AliasCanaryStartHandler)
+	dashboardMux.HandleFunc("DELETE /api/aliases/{subdomain}/canary", handlers.AliasCanaryAbortHandler)
 	dashboardMux.HandleFunc("POST /api/aliases/swap", handlers.AliasSwapHandler)
 
 	// Command Gateway (v0.10 - for @peer remote execution)
@@ -2902,6 +3978,63 @@ func handleStartCommand() {
 	dashboardMux.HandleFunc("GET /_fazt/logs", handlers.AgentLogsHandler)
 	dashboardMux.HandleFunc("GET /_fazt/errors", handlers.AgentErrorsHandler)
 
+	// Status page incident log (public page is served on status.<domain>)
+	dashboardMux.HandleFunc("GET /api/status/incidents", handlers.IncidentsListHandler)
+	dashboardMux.HandleFunc("POST /api/status/incidents", handlers.IncidentCreateHandler)
+	dashboardMux.HandleFunc("POST /api/status/incidents/{id}/updates", handlers.IncidentUpdateHandler)
+	dashboardMux.HandleFunc("DELETE /api/status/incidents/{id}", handlers.IncidentDeleteHandler)
+
+	// Synthetic transaction checks (JS scripts run in the worker pool)
+	dashboardMux.HandleFunc("GET /api/status/synthetics", handlers.SyntheticsListHandler)
+	dashboardMux.HandleFunc("POST /api/status/synthetics", handlers.SyntheticCreateHandler)
+	dashboardMux.HandleFunc("GET /api/status/synthetics/{id}/runs", handlers.SyntheticRunsHandler)
+	dashboardMux.HandleFunc("DELETE /api/status/synthetics/{id}", handlers.SyntheticDeleteHandler)
+	dashboardMux.HandleFunc("GET /api/pings", handlers.PingsListHandler)
+	dashboardMux.HandleFunc("POST /api/pings", handlers.PingCreateHandler)
+	dashboardMux.HandleFunc("PATCH /api/pings/{name}", handlers.PingUpdateHandler)
+	dashboardMux.HandleFunc("GET /api/pings/{name}/runs", handlers.PingRunsHandler)
+	dashboardMux.HandleFunc("POST /api/pings/{name}/run", handlers.PingRunHandler)
+	dashboardMux.HandleFunc("DELETE /api/pings/{name}", handlers.PingDeleteHandler)
+	dashboardMux.HandleFunc("GET /api/heartbeats", handlers.HeartbeatsListHandler)
+	dashboardMux.HandleFunc("POST /api/heartbeats", handlers.HeartbeatCreateHandler)
+	dashboardMux.HandleFunc("GET /api/heartbeats/{token}", handlers.HeartbeatCheckInHandler)
+	dashboardMux.HandleFunc("POST /api/heartbeats/{token}", handlers.HeartbeatCheckInHandler)
+	dashboardMux.HandleFunc("PATCH /api/heartbeats/{name}", handlers.HeartbeatUpdateHandler)
+	dashboardMux.HandleFunc("DELETE /api/heartbeats/{name}", handlers.HeartbeatDeleteHandler)
+	dashboardMux.HandleFunc("GET /api/failover", handlers.FailoverStatusHandler)
+	dashboardMux.HandleFunc("PUT /api/failover/role", handlers.FailoverRoleHandler)
+	dashboardMux.HandleFunc("PUT /api/failover/standby", handlers.FailoverStandbyHandler)
+	dashboardMux.HandleFunc("PUT /api/failover/dns", handlers.FailoverDNSHandler)
+	dashboardMux.HandleFunc("POST /api/failover/sync", handlers.FailoverSyncHandler)
+	dashboardMux.HandleFunc("POST /api/failover/replica", handlers.FailoverReplicaHandler)
+	dashboardMux.HandleFunc("POST /api/failover/promote", handlers.FailoverPromoteHandler)
+
+	// S3 gateway access keys and presigned URLs
+	dashboardMux.HandleFunc("GET /api/s3/keys", handlers.S3KeysListHandler)
+	dashboardMux.HandleFunc("POST /api/s3/keys", handlers.S3KeyCreateHandler)
+	dashboardMux.HandleFunc("DELETE /api/s3/keys/{id}", handlers.S3KeyDeleteHandler)
+	dashboardMux.HandleFunc("POST /api/s3/presign", handlers.S3PresignHandler)
+
+	// Service level objectives and error budgets
+	dashboardMux.HandleFunc("GET /api/slos", handlers.SLOsListHandler)
+	dashboardMux.HandleFunc("POST /api/slos", handlers.SLOCreateHandler)
+	dashboardMux.HandleFunc("GET /api/slos/{id}", handlers.SLOGetHandler)
+	dashboardMux.HandleFunc("DELETE /api/slos/{id}", handlers.SLODeleteHandler)
+	dashboardMux.HandleFunc("GET /api/freezes", handlers.FreezesListHandler)
+	dashboardMux.HandleFunc("POST /api/freezes", handlers.FreezeCreateHandler)
+	dashboardMux.HandleFunc("DELETE /api/freezes/{id}", handlers.FreezeDeleteHandler)
+
+	// Four-eyes approval of destructive operations
+	dashboardMux.HandleFunc("GET /api/approvals", handlers.ApprovalsListHandler)
+	dashboardMux.HandleFunc("POST /api/approvals/{id}/approve", handlers.ApprovalApproveHandler)
+	dashboardMux.HandleFunc("POST /api/approvals/{id}/reject", handlers.ApprovalRejectHandler)
+
+	// Security event stream and its notification rules
+	dashboardMux.HandleFunc("GET /api/security/events", handlers.SecurityEventsHandler)
+	dashboardMux.HandleFunc("GET /api/security/rules", handlers.SecurityRulesListHandler)
+	dashboardMux.HandleFunc("POST /api/security/rules", handlers.SecurityRuleCreateHandler)
+	dashboardMux.HandleFunc("DELETE /api/security/rules/{id}", handlers.SecurityRuleDeleteHandler)
+
 	dashboardMux.HandleFunc("/api/keys", handlers.APIKeysHandler)
 	dashboardMux.HandleFunc("/api/deployments", handlers.DeploymentsHandler)
 	dashboardMux.HandleFunc("/api/envvars", handlers.EnvVarsHandler)
@@ -2926,20 +4059,50 @@ func handleStartCommand() {
 	// Create the root handler with host-based routing
 	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
 
-	// Initialize global rate limiter (500 req/s sustained, 1000 burst per IP)
-	globalRateLimiter := middleware.NewRateLimiter(middleware.DefaultRateLimit, middleware.DefaultBurst)
+	// Static exports render apps' dynamic routes through it in-process
+	handlers.SetSiteRenderer(rootHandler, extractDomain(cfg.Server.Domain))
+
+	// Status page monitor: probes every site in-process for uptime and response time
+	statusMonitor := status.NewMonitor(database.GetDB(), rootHandler, extractDomain(cfg.Server.Domain))
+	statusMonitor.Start()
+	defer statusMonitor.Stop()
+
+	// App services' health paths are polled the same way
+	worker.SetHealthCheckFunc(statusMonitor.Probe)
+
+	// Forwarding headers are only honored from trusted proxies
+	// (server.trusted_proxies); everything downstream uses the resolved IP
+	if err := realip.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	// Rate limits per client IP and per site, with a separate per-IP budget
+	// for /api/* (server.rate_limit.* keys)
+	rl := cfg.Server.RateLimit
+	trafficLimiter := middleware.NewTrafficLimiter(middleware.Limits{
+		IPRate:    rate.Limit(rl.IPRate),
+		IPBurst:   rl.IPBurst,
+		SiteRate:  rate.Limit(rl.SiteRate),
+		SiteBurst: rl.SiteBurst,
+		APIRate:   rate.Limit(rl.APIRate),
+		APIBurst:  rl.APIBurst,
+	})
 
 	// Note: Per-IP connection limiting is now at TCP level (internal/listener/connlimit.go)
 	// This provides better protection by rejecting connections before they consume goroutines
 
-	// Apply middleware (order: rate limit -> tracing -> logging -> body limit -> security -> cors -> recovery -> root)
-	handler := globalRateLimiter.Middleware(
-		middleware.RequestTracing(
-			loggingMiddleware(
-				middleware.BodySizeLimit(middleware.MaxBodySize)(
-					middleware.SecurityHeaders(
-						corsMiddleware(
-							recoveryMiddleware(rootHandler),
+	// Apply middleware (order: real IP -> rate limit -> tracing -> logging -> body limit -> security -> compress -> cors -> recovery -> root)
+	handler := realip.Middleware(
+		trafficLimiter.Middleware(
+			middleware.RequestTracing(
+				loggingMiddleware(
+					middleware.BodySizeLimit(middleware.MaxBodySize)(
+						middleware.SecurityHeaders(
+							middleware.Compress(
+								corsMiddleware(
+									recoveryMiddleware(rootHandler),
+								),
+							),
 						),
 					),
 				),
@@ -3017,6 +4180,29 @@ func handleStartCommand() {
 			}
 			magic.Issuers = []certmagic.Issuer{acmeIssuer}
 
+			// Notify the admin when a certificate can't be obtained or renewed.
+			// CertMagic retries with backoff, so alert once an hour per domain.
+			magic.OnEvent = func(ctx context.Context, event string, data map[string]any) error {
+				if event != "cert_failed" {
+					return nil
+				}
+				name, _ := data["identifier"].(string)
+				action := "obtain"
+				if renewal, _ := data["renewal"].(bool); renewal {
+					action = "renew"
+				}
+				message := fmt.Sprintf("Failed to %s the certificate for %s: %v", action, name, data["error"])
+				if remaining, ok := data["remaining"].(time.Duration); ok {
+					message += fmt.Sprintf(" (expires in %s)", remaining.Round(time.Hour))
+				}
+				go func() {
+					if err := notify.SendOnce(notify.EventCertFailed, name, "Certificate "+action+" failed", message); err != nil {
+						log.Printf("Failed to send certificate notification: %v", err)
+					}
+				}()
+				return nil
+			}
+
 			// Configure OnDemand TLS for subdomains
 			magic.OnDemand = &certmagic.OnDemandConfig{
 				DecisionFunc: func(ctx context.Context, name string) error {
@@ -3281,8 +4467,13 @@ func printServerHelp() {
 	fmt.Println("  status           Show configuration and server status")
 	fmt.Println("  set-credentials  Update admin credentials (password reset)")
 	fmt.Println("  set-config       Update settings (domain, port, env)")
+	fmt.Println("  set-notify       Configure notification channels (ntfy, email, webhook)")
+	fmt.Println("  set-mail         Configure the mail server apps send email through")
 	fmt.Println("  create-key       Create an API key for deployments")
 	fmt.Println("  reset-admin      Reset admin dashboard to embedded version")
+	fmt.Println("  storage          Show database size by table and app")
+	fmt.Println("  maintain         Prune old data and vacuum the database in the background")
+	fmt.Println("  rebuild          Regenerate derived data from its source in the background")
 	fmt.Println("  --help, -h       Show this help")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
@@ -3298,6 +4489,12 @@ func printServerHelp() {
 	fmt.Println("  # Reset Admin Password")
 	fmt.Println("  fazt server set-credentials --username admin --password newsecret")
 	fmt.Println()
+	fmt.Println("  # Drop analytics older than 90 days and shrink the database")
+	fmt.Println("  fazt server maintain --vacuum --prune-events 90d")
+	fmt.Println()
+	fmt.Println("  # Reindex search after a bug left the index stale")
+	fmt.Println("  fazt server rebuild search-index")
+	fmt.Println()
 }
 
 // printClientHelp displays client-specific help
@@ -3382,7 +4579,8 @@ func handleResetAdminCommand() {
 func handleCreateKeyCommand() {
 	flags := flag.NewFlagSet("create-key", flag.ExitOnError)
 	name := flags.String("name", "", "Key name (required)")
-	scopes := flags.String("scopes", "deploy", "Key scopes (default: deploy)")
+	scopes := flags.String("scopes", "admin", "Key scope: admin, deploy, or read")
+	user := flags.String("user", "", "Limit the key to this user's apps (email)")
 	db := flags.String("db", "", "Database file path")
 
 	flags.Usage = func() {
@@ -3395,6 +4593,7 @@ func handleCreateKeyCommand() {
 		fmt.Println()
 		fmt.Println("Example:")
 		fmt.Println("  fazt server create-key --name my-laptop")
+		fmt.Println("  fazt server create-key --name alice-laptop --user alice@example.com")
 		fmt.Println("  # Then on your laptop:")
 		fmt.Println("  fazt servers add prod --url https://your-server.com --token <TOKEN>")
 	}
@@ -3425,17 +4624,37 @@ func handleCreateKeyCommand() {
 	}
 	defer database.Close()
 
+	// Resolve user for member-scoped keys
+	var userID string
+	if *user != "" {
+		id, err := hosting.GetUserIDByEmail(database.GetDB(), *user)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "User not found: %s\n", *user)
+			os.Exit(1)
+		}
+		userID = id
+	}
+
 	// Create API key
-	token, err := hosting.CreateAPIKey(database.GetDB(), *name, *scopes)
+	token, err := hosting.CreateUserAPIKey(database.GetDB(), *name, *scopes, userID)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create API key: %v\n", err)
 		os.Exit(1)
 	}
+	security.Record(database.GetDB(), security.Event{
+		Type:    security.EventTokenCreated,
+		Actor:   "cli",
+		Subject: *name,
+		Details: map[string]interface{}{"scope": *scopes, "user_id": userID},
+	})
 
 	fmt.Println("API Key created successfully!")
 	fmt.Println()
 	fmt.Printf("  Name:   %s\n", *name)
 	fmt.Printf("  Scopes: %s\n", *scopes)
+	if *user != "" {
+		fmt.Printf("  User:   %s (limited to apps shared with this user)\n", *user)
+	}
 	fmt.Printf("  Token:  %s\n", token)
 	fmt.Println()
 	fmt.Println("Save this token - it won't be shown again!")
@@ -3589,6 +4808,10 @@ func handleUserCommand(args []string) {
 		handleUserStatus(args[1:])
 	case "set-role":
 		handleUserSetRole(args[1:])
+	case "impersonate":
+		fmt.Fprintln(os.Stderr, "Error: impersonation mints a session on a running server")
+		fmt.Fprintln(os.Stderr, "Usage: fazt @<peer> user impersonate <ID|EMAIL> [--end]")
+		os.Exit(1)
 	case "--help", "-h", "help":
 		printUserUsage()
 	default:
@@ -3608,12 +4831,14 @@ func printUserUsage() {
 	fmt.Println("  list                    List all users")
 	fmt.Println("  status                  Show user status with app data (requires --email or --id)")
 	fmt.Println("  set-role                Set a user's role")
+	fmt.Println("  impersonate <id|email>  Mint a 1-hour session as a user (remote only)")
 	fmt.Println()
 	fmt.Println("OPTIONS:")
 	fmt.Println("  --email <email>         User email (for status, set-role)")
 	fmt.Println("  --id <id>               User ID (for status, set-role)")
 	fmt.Println("  --app <app-id>          Filter by app (for list)")
 	fmt.Println("  --role <role>           Role to set (for set-role)")
+	fmt.Println("  --end                   End all impersonation sessions (for impersonate)")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
 	fmt.Println("  fazt user list")
@@ -3622,6 +4847,7 @@ func printUserUsage() {
 	fmt.Println("  fazt user status --id fazt_usr_xxx")
 	fmt.Println("  fazt user set-role --email user@example.com --role admin")
 	fmt.Println("  fazt @zyt user status --email user@example.com")
+	fmt.Println("  fazt @zyt user impersonate user@example.com")
 }
 
 func handleUserList(args []string) {
@@ -4031,6 +5257,8 @@ func handleUserCommandWithPeer(peerName string, args []string) {
 		handleUserStatusRemote(peerName, args[1:])
 	case "set-role":
 		handleUserSetRoleRemote(peerName, args[1:])
+	case "impersonate":
+		handleUserImpersonateRemote(peerName, args[1:])
 	default:
 		fmt.Printf("Unknown user subcommand: %s\n", subCmd)
 		printUserUsage()
@@ -4174,37 +5402,51 @@ func handleUserListRemote(peerName string, args []string) {
 		os.Exit(1)
 	}
 
-	req, _ := http.NewRequest("GET", peer.URL+"/api/users", nil)
-	req.Header.Set("Authorization", "Bearer "+peer.Token)
+	type listedUser struct {
+		ID        string `json:"id"`
+		Email     string `json:"email"`
+		Name      string `json:"name"`
+		Role      string `json:"role"`
+		Provider  string `json:"provider"`
+		LastLogin int64  `json:"last_login"`
+	}
+	var users []listedUser
 
 	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-	defer resp.Body.Close()
+	cursor := ""
+	for {
+		req, _ := http.NewRequest("GET", peer.URL+"/api/users?limit=100&cursor="+url.QueryEscape(cursor), nil)
+		req.Header.Set("Authorization", "Bearer "+peer.Token)
 
-	var response struct {
-		Data []struct {
-			ID        string `json:"id"`
-			Email     string `json:"email"`
-			Name      string `json:"name"`
-			Role      string `json:"role"`
-			Provider  string `json:"provider"`
-			LastLogin int64  `json:"last_login"`
-		} `json:"data"`
-	}
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
-		os.Exit(1)
+		var response struct {
+			Data []listedUser `json:"data"`
+			Meta struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error decoding response: %v\n", err)
+			os.Exit(1)
+		}
+		users = append(users, response.Data...)
+		if response.Meta.NextCursor == "" {
+			break
+		}
+		cursor = response.Meta.NextCursor
 	}
 
 	renderer := getRenderer()
 
 	var tableRows [][]string
-	for _, u := range response.Data {
+	for _, u := range users {
 		// Wrap email in backticks to prevent glamour from auto-linking
 		tableRows = append(tableRows, []string{"`" + u.Email + "`", u.Name, u.Role, u.Provider})
 	}
@@ -4219,7 +5461,7 @@ func handleUserListRemote(peerName string, args []string) {
 		Table(table).
 		String()
 
-	renderer.Print(md, map[string]interface{}{"users": response.Data, "count": len(response.Data)})
+	renderer.Print(md, map[string]interface{}{"users": users, "count": len(users)})
 }
 
 func handleUserSetRoleRemote(peerName string, args []string) {
@@ -4294,3 +5536,79 @@ func handleUserSetRoleRemote(peerName string, args []string) {
 
 	fmt.Printf("✓ User %s role updated to: %s (on @%s)\n", userEmail, *role, peerName)
 }
+
+func handleUserImpersonateRemote(peerName string, args []string) {
+	fs := flag.NewFlagSet("impersonate", flag.ExitOnError)
+	end := fs.Bool("end", false, "End all impersonation sessions for the user")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: user ID or email required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt @<peer> user impersonate <ID|EMAIL> [--end]")
+		os.Exit(1)
+	}
+	userIDOrEmail := args[0]
+	fs.Parse(args[1:])
+
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, peerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	method := "POST"
+	if *end {
+		method = "DELETE"
+	}
+	req, _ := http.NewRequest(method, peer.URL+"/api/users/"+url.PathEscape(userIDOrEmail)+"/impersonate", nil)
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Data struct {
+			User struct {
+				ID    string `json:"id"`
+				Email string `json:"email"`
+			} `json:"user"`
+			Token     string `json:"token"`
+			Cookie    string `json:"cookie"`
+			ExpiresAt int64  `json:"expires_at"`
+			Sessions  int64  `json:"sessions"`
+		} `json:"data"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		if response.Error.Message != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", response.Error.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: request failed with status %d\n", resp.StatusCode)
+		}
+		os.Exit(1)
+	}
+
+	if *end {
+		fmt.Printf("✓ Ended %d impersonation session(s) for %s (on @%s)\n", response.Data.Sessions, userIDOrEmail, peerName)
+		return
+	}
+
+	fmt.Printf("✓ Impersonating %s (%s) on @%s\n", response.Data.User.Email, response.Data.User.ID, peerName)
+	fmt.Printf("  Expires: %s\n", time.Unix(response.Data.ExpiresAt, 0).Local().Format("2006-01-02 15:04"))
+	fmt.Println()
+	fmt.Println("Use the session in a private window or with curl:")
+	fmt.Printf("  Cookie: %s=%s\n", response.Data.Cookie, response.Data.Token)
+	fmt.Println()
+	fmt.Printf("End it with: fazt @%s user impersonate %s --end\n", peerName, userIDOrEmail)
+}
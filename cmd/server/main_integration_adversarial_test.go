@@ -54,9 +54,10 @@ func setupAdversarialTest(t *testing.T) *integrationTestServer {
 // TestAdversarial_SessionFixationViaCookieInjection simulates a subdomain cookie
 // injection attack. An attacker on evil.domain.com sets a fazt_session cookie scoped
 // to .domain.com. When the victim visits admin.domain.com, the browser sends both
-// cookies. Go's r.Cookie() returns the first match — the attacker's invalid token.
+// cookies, the attacker's first.
 //
-// Result: auth denial-of-service (victim gets 401), NOT privilege escalation.
+// Every session cookie is tried, so the planted one neither grants access nor
+// shadows the victim's session.
 func TestAdversarial_SessionFixationViaCookieInjection(t *testing.T) {
 	s := setupIntegrationTest(t)
 
@@ -64,7 +65,6 @@ func TestAdversarial_SessionFixationViaCookieInjection(t *testing.T) {
 	validToken := s.createSession(t, "victim@test.com", "admin")
 
 	// Attacker's invalid token placed FIRST in the cookie header
-	// Go's http.Request.Cookie() returns the first match by name
 	attackerToken := "attacker-planted-invalid-token"
 
 	req, err := http.NewRequest("GET", s.server.URL+"/api/auth/me", nil)
@@ -84,13 +84,21 @@ func TestAdversarial_SessionFixationViaCookieInjection(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	// Go's r.Cookie() returns the FIRST match — attacker's invalid token
-	// This means the valid session is shadowed → 401
-	if resp.StatusCode == http.StatusOK {
-		t.Error("Cookie injection should NOT grant access — attacker's token evaluated first should fail")
+	// The attacker's token is skipped; the victim stays signed in
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Planted cookie should not shadow the valid session, got %d", resp.StatusCode)
 	}
 
-	t.Logf("Cookie injection result: status=%d (attacker causes auth DoS, not privilege escalation)", resp.StatusCode)
+	// The planted token alone grants nothing
+	resp3 := s.makeRequest(t, "GET", "/api/auth/me", nil,
+		withHost("admin.testdomain.com"),
+		withSession(attackerToken),
+	)
+	defer resp3.Body.Close()
+
+	if resp3.StatusCode == http.StatusOK {
+		t.Error("Cookie injection should NOT grant access")
+	}
 
 	// Verify the legitimate token still works when sent alone
 	resp2 := s.makeRequest(t, "GET", "/api/auth/me", nil,
@@ -156,6 +164,10 @@ func TestAdversarial_CookieAttributeVerification(t *testing.T) {
 		t.Errorf("Cookie MaxAge should be positive, got %d", sessionCookie.MaxAge)
 	}
 
+	if sessionCookie.Domain != "" {
+		t.Errorf("Cookie should be host-only so hosted apps never receive it, got Domain=%q", sessionCookie.Domain)
+	}
+
 	// Note: Secure flag is false in test mode (not HTTPS) — expected behavior
 	// In production (secure=true), this would be set
 	t.Logf("Cookie attributes verified: HttpOnly=%v, SameSite=%v, Path=%s, MaxAge=%d, Secure=%v",
@@ -419,9 +431,9 @@ func TestAdversarial_LoginTimingSideChannel(t *testing.T) {
 	}
 }
 
-// TestAdversarial_RateLimitBypassViaIPSpoofing demonstrates that getClientIP()
-// trusts X-Forwarded-For unconditionally. An attacker can exhaust the rate limit
-// for their real IP, then bypass it by spoofing different IPs.
+// TestAdversarial_RateLimitBypassViaIPSpoofing verifies that an attacker who
+// exhausts the rate limit for their real IP can't bypass it by spoofing
+// X-Forwarded-For: the header is ignored unless the peer is a trusted proxy.
 func TestAdversarial_RateLimitBypassViaIPSpoofing(t *testing.T) {
 	s := setupAdversarialTest(t)
 
@@ -482,8 +494,7 @@ func TestAdversarial_RateLimitBypassViaIPSpoofing(t *testing.T) {
 	t.Logf("IP spoofing bypass: %d/20 requests bypassed rate limit via X-Forwarded-For", bypassCount)
 
 	if bypassCount > 0 {
-		t.Logf("VULNERABILITY: getClientIP() trusts X-Forwarded-For unconditionally")
-		t.Logf("Attacker can send unlimited login attempts by rotating spoofed IPs")
+		t.Errorf("X-Forwarded-For from an untrusted peer bypassed the login rate limit %d times", bypassCount)
 	}
 }
 
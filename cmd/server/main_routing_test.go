@@ -47,6 +47,10 @@ func setupRoutingTestDB(t *testing.T) *sql.DB {
 		created_at INTEGER NOT NULL DEFAULT (unixepoch()),
 		expires_at INTEGER NOT NULL,
 		last_seen INTEGER,
+		impersonator_id TEXT,
+		user_agent TEXT,
+		ip TEXT,
+		host TEXT,
 		FOREIGN KEY (user_id) REFERENCES auth_users(id) ON DELETE CASCADE
 	);
 	CREATE TABLE IF NOT EXISTS api_keys (
@@ -259,9 +263,9 @@ func TestRouting_AdminDomain_AdminMiddleware(t *testing.T) {
 	userSession := createTestSession(t, authService, regularUserID)
 
 	dashboardMux := http.NewServeMux()
-	dashboardMux.HandleFunc("/api/apps", func(w http.ResponseWriter, r *http.Request) {
+	dashboardMux.HandleFunc("/api/system/config", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
-		w.Write([]byte("apps"))
+		w.Write([]byte("config"))
 	})
 	dashboardMux.HandleFunc("/api/stats", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
@@ -271,7 +275,7 @@ func TestRouting_AdminDomain_AdminMiddleware(t *testing.T) {
 	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
 
 	adminProtectedPaths := []string{
-		"/api/apps",
+		"/api/system/config",
 		"/api/stats",
 	}
 
@@ -331,6 +335,52 @@ func TestRouting_AdminDomain_AdminMiddleware(t *testing.T) {
 	db.Close()
 }
 
+func TestRouting_AdminDomain_Connect(t *testing.T) {
+	db := setupRoutingTestDB(t)
+	defer db.Close()
+	cfg := setupRoutingTestConfig(t)
+
+	authService := auth.NewService(db, cfg.Server.Domain, false)
+	authHandler := auth.NewHandler(authService)
+	adminSession := createTestSession(t, authService, createTestUser(t, authService, "admin@test.local", "admin"))
+
+	dashboardMux := http.NewServeMux()
+	dashboardMux.HandleFunc("GET /api/system/capacity", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"max_apps":10}}`))
+	})
+	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
+
+	call := func(session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/connect/fazt.admin.v1.SystemService/GetCapacity", strings.NewReader("{}"))
+		req.Host = "admin.test.local"
+		req.Header.Set("Content-Type", "application/json")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "fazt_session", Value: session})
+		}
+		rr := httptest.NewRecorder()
+		rootHandler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The REST endpoint's auth applies
+	if rr := call(""); rr.Code != 401 || !strings.Contains(rr.Body.String(), `"unauthenticated"`) {
+		t.Errorf("Expected unauthenticated without auth, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := call(adminSession); rr.Code != 200 || strings.TrimSpace(rr.Body.String()) != `{"max_apps":10}` {
+		t.Errorf("Expected the capacity for an admin, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// admin.proto is public, like fazt.d.ts
+	req := httptest.NewRequest("GET", "/api/connect/admin.proto", nil)
+	req.Host = "admin.test.local"
+	rr := httptest.NewRecorder()
+	rootHandler.ServeHTTP(rr, req)
+	if rr.Code != 200 || !strings.Contains(rr.Body.String(), "service SystemService") {
+		t.Errorf("Expected admin.proto, got %d", rr.Code)
+	}
+}
+
 func TestRouting_AdminDomain_TrackEndpoint(t *testing.T) {
 	db := setupRoutingTestDB(t)
 	cfg := setupRoutingTestConfig(t)
@@ -364,6 +414,35 @@ func TestRouting_AdminDomain_TrackEndpoint(t *testing.T) {
 	db.Close()
 }
 
+func TestRouting_AdminDomain_ShortLinks(t *testing.T) {
+	db := setupRoutingTestDB(t)
+	cfg := setupRoutingTestConfig(t)
+
+	authService := auth.NewService(db, cfg.Server.Domain, false)
+	authHandler := auth.NewHandler(authService)
+
+	dashboardMux := http.NewServeMux()
+	dashboardMux.HandleFunc("/r/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusFound)
+	})
+
+	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
+
+	// Short links are public, and not served by the admin app
+	req := httptest.NewRequest("GET", "/r/launch", nil)
+	req.Host = "admin.test.local"
+
+	rr := httptest.NewRecorder()
+	rootHandler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusFound {
+		t.Errorf("Expected 302 for /r/launch, got %d", rr.Code)
+	}
+
+	// Cleanup
+	db.Close()
+}
+
 func TestRouting_LocalhostSpecialCase(t *testing.T) {
 	db := setupRoutingTestDB(t)
 	cfg := setupRoutingTestConfig(t)
@@ -483,6 +562,70 @@ func TestRouting_404Domain(t *testing.T) {
 	db.Close()
 }
 
+func TestRouting_StatusDomain(t *testing.T) {
+	db := setupRoutingTestDB(t)
+	cfg := setupRoutingTestConfig(t)
+
+	authService := auth.NewService(db, cfg.Server.Domain, false)
+	authHandler := auth.NewHandler(authService)
+
+	// Note: "status" site is automatically seeded by hosting.Init() in setupRoutingTestDB
+
+	dashboardMux := http.NewServeMux()
+	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "status.test.local"
+
+	rr := httptest.NewRecorder()
+	rootHandler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Status domain should serve the status site, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "/api/status") {
+		t.Error("Expected the status page to fetch /api/status")
+	}
+
+	// The summary API is public and handled before site serving
+	req = httptest.NewRequest("GET", "/api/status", nil)
+	req.Host = "status.test.local"
+
+	rr = httptest.NewRecorder()
+	rootHandler.ServeHTTP(rr, req)
+
+	if rr.Code == 401 || rr.Code == 403 {
+		t.Errorf("Status API should not require auth, got %d", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Expected JSON from status API, got %q", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestRouting_S3Domain(t *testing.T) {
+	db := setupRoutingTestDB(t)
+	cfg := setupRoutingTestConfig(t)
+
+	authService := auth.NewService(db, cfg.Server.Domain, false)
+	authHandler := auth.NewHandler(authService)
+
+	dashboardMux := http.NewServeMux()
+	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
+
+	// The gateway answers with S3 errors, even for /auth/ paths
+	for _, path := range []string{"/", "/blog/file.txt", "/auth/login"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "s3.test.local"
+
+		rr := httptest.NewRecorder()
+		rootHandler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusForbidden || !strings.Contains(rr.Body.String(), "<Code>AccessDenied</Code>") {
+			t.Errorf("%s: expected an S3 AccessDenied error for an unsigned request, got %d %s", path, rr.Code, rr.Body)
+		}
+	}
+}
+
 func TestRouting_SubdomainRouting(t *testing.T) {
 	db := setupRoutingTestDB(t)
 	cfg := setupRoutingTestConfig(t)
@@ -695,15 +838,15 @@ func TestRouting_MiddlewareOrder_AuthBeforeAdmin(t *testing.T) {
 	authHandler := auth.NewHandler(authService)
 
 	dashboardMux := http.NewServeMux()
-	dashboardMux.HandleFunc("/api/apps", func(w http.ResponseWriter, r *http.Request) {
+	dashboardMux.HandleFunc("/api/system/config", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
-		w.Write([]byte("apps"))
+		w.Write([]byte("config"))
 	})
 
 	rootHandler := createRootHandler(cfg, dashboardMux, authHandler)
 
 	// Request to admin-protected endpoint without any auth
-	req := httptest.NewRequest("GET", "/api/apps", nil)
+	req := httptest.NewRequest("GET", "/api/system/config", nil)
 	req.Host = "admin.test.local"
 
 	rr := httptest.NewRecorder()
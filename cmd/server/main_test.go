@@ -79,7 +79,7 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	}
 
 	// 4. Update config
-	err = setConfigCommand("https://new.com", "8080", "production", dbPath)
+	err = setConfigCommand("https://new.com", "8080", "production", "true", "true", "site=200/400,api=50", "127.0.0.1, 10.0.0.0/8", "128", "50", "size=50,app=10,queue=1000", "block", "retention=365,raw=14,ip=drop", "clamd:127.0.0.1:3310", "uploads/**, **/*.pdf", "max=1024,expire=48", "bundle=200,files=5000,file=50", dbPath)
 	if err != nil {
 		t.Fatalf("set-config failed: %v", err)
 	}
@@ -98,6 +98,43 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	if dbMap["server.port"] != "8080" {
 		t.Errorf("Port not updated. Got: %s", dbMap["server.port"])
 	}
+	if dbMap["auth.require_2fa"] != "true" {
+		t.Errorf("Require 2FA not updated. Got: %s", dbMap["auth.require_2fa"])
+	}
+	if dbMap["auth.require_approval"] != "true" {
+		t.Errorf("Require approval not updated. Got: %s", dbMap["auth.require_approval"])
+	}
+	if dbMap["server.rate_limit.site_rps"] != "200" || dbMap["server.rate_limit.site_burst"] != "400" ||
+		dbMap["server.rate_limit.api_rps"] != "50" || dbMap["server.rate_limit.api_burst"] != "100" {
+		t.Errorf("Rate limits not updated. Got: %v", dbMap)
+	}
+	if dbMap["server.trusted_proxies"] != "127.0.0.1/32,10.0.0.0/8" {
+		t.Errorf("Trusted proxies not updated. Got: %s", dbMap["server.trusted_proxies"])
+	}
+	if dbMap["server.vfs_cache_mb"] != "128" {
+		t.Errorf("VFS cache not updated. Got: %s", dbMap["server.vfs_cache_mb"])
+	}
+	if dbMap["server.slow_storage_ms"] != "50" {
+		t.Errorf("Slow storage threshold not updated. Got: %s", dbMap["server.slow_storage_ms"])
+	}
+	if dbMap["server.vm_pool.size"] != "50" || dbMap["server.vm_pool.app_concurrency"] != "10" || dbMap["server.vm_pool.queue_ms"] != "1000" {
+		t.Errorf("VM pool not updated. Got: %v", dbMap)
+	}
+	if dbMap["server.secret_scan"] != "block" {
+		t.Errorf("Secret scan mode not updated. Got: %s", dbMap["server.secret_scan"])
+	}
+	if dbMap["server.analytics.retention_days"] != "365" || dbMap["server.analytics.raw_days"] != "14" || dbMap["server.analytics.drop_ip"] != "true" {
+		t.Errorf("Analytics retention not updated. Got: %v", dbMap)
+	}
+	if dbMap["server.virus_scan.mode"] != "clamd" || dbMap["server.virus_scan.address"] != "127.0.0.1:3310" || dbMap["server.virus_scan.paths"] != "uploads/**,**/*.pdf" {
+		t.Errorf("Virus scan not updated. Got: %v", dbMap)
+	}
+	if dbMap["server.uploads.max_mb"] != "1024" || dbMap["server.uploads.expire_hours"] != "48" {
+		t.Errorf("Upload limits not updated. Got: %v", dbMap)
+	}
+	if dbMap["server.deploy_limits.max_bundle_mb"] != "200" || dbMap["server.deploy_limits.max_files"] != "5000" || dbMap["server.deploy_limits.max_file_mb"] != "50" {
+		t.Errorf("Deploy limits not updated. Got: %v", dbMap)
+	}
 	if dbMap["auth.username"] != "newadmin" {
 		t.Errorf("Username not updated. Got: %s", dbMap["auth.username"])
 	}
@@ -111,4 +148,139 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	if !strings.Contains(output, "https://new.com") {
 		t.Error("Status output doesn't reflect new domain")
 	}
-}
\ No newline at end of file
+	if !strings.Contains(output, "per site 200/s (burst 400)") {
+		t.Errorf("Status output doesn't reflect rate limits:\n%s", output)
+	}
+
+	if err := setConfigCommand("", "", "", "", "", "sites=5", "", "", "", "", "", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected unknown rate limit name to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "10.0.0.0/33", "", "", "", "", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected invalid trusted proxy to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "-1", "", "", "", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected negative vfs cache to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "0", "", "", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected zero slow storage threshold to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "size=0", "", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected an empty vm pool to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "loud", "", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected an unknown secret scan mode to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "raw=0", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected an empty raw analytics window to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "ip=hash", "", "", "", "", dbPath); err == nil {
+		t.Error("Expected an unknown analytics ip mode to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "geoip="+filepath.Join(t.TempDir(), "missing.mmdb"), "", "", "", "", dbPath); err == nil {
+		t.Error("Expected a missing GeoIP database to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "", "sophos", "", "", "", dbPath); err == nil {
+		t.Error("Expected an unknown virus scanner to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "", "", "uploads/[", "", "", dbPath); err == nil {
+		t.Error("Expected a malformed virus scan path to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "", "", "", "max=0", "", dbPath); err == nil {
+		t.Error("Expected a zero upload size to fail")
+	}
+	if err := setConfigCommand("", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "files=-1", dbPath); err == nil {
+		t.Error("Expected a negative deploy limit to fail")
+	}
+}
+func TestSetNotifyCommand(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data.db")
+
+	err := setNotifyCommand(notifyOptions{
+		NtfyTopic: "alerts",
+		SMTP:      "smtp.example.com:465",
+		SMTPUser:  "alerts@example.com",
+		EmailTo:   "ops@example.com, admin@example.com",
+		Routes:    "cert.failed=email+ntfy,traffic_spike=none",
+	}, dbPath)
+	if err != nil {
+		t.Fatalf("set-notify failed: %v", err)
+	}
+
+	if err := database.Init(dbPath); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	cfg := config.CreateDefaultConfig()
+	config.NewDBConfigStore(database.GetDB()).Apply(cfg)
+	database.Close()
+
+	if cfg.Ntfy.Topic != "alerts" || cfg.Notify.SMTP.Host != "smtp.example.com" || cfg.Notify.SMTP.Port != 465 {
+		t.Errorf("Channels not updated: %+v %+v", cfg.Ntfy, cfg.Notify.SMTP)
+	}
+	if got := notifySummary(cfg); got != "email, ntfy (cert.failed=email+ntfy, traffic_spike=none)" {
+		t.Errorf("notifySummary = %q", got)
+	}
+
+	bad := []notifyOptions{
+		{SMTP: "smtp.example.com:0"},
+		{EmailTo: "not an address"},
+		{Webhook: "ftp://example.com"},
+		{Routes: "cert.expired=email"},
+		{Routes: "cert.failed=sms"},
+	}
+	for _, opts := range bad {
+		if err := setNotifyCommand(opts, dbPath); err == nil {
+			t.Errorf("Expected %+v to fail", opts)
+		}
+	}
+}
+
+func TestSetMailCommand(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data.db")
+
+	err := setMailCommand(mailOptions{
+		SMTP:       "smtp.example.com",
+		SMTPUser:   "apps",
+		From:       "Apps <apps@example.com>",
+		DailyLimit: "250",
+		Inbound:    "2525",
+	}, dbPath)
+	if err != nil {
+		t.Fatalf("set-mail failed: %v", err)
+	}
+
+	if err := database.Init(dbPath); err != nil {
+		t.Fatalf("Failed to init db: %v", err)
+	}
+	cfg := config.CreateDefaultConfig()
+	config.NewDBConfigStore(database.GetDB()).Apply(cfg)
+	database.Close()
+
+	if cfg.Mail.SMTP.Host != "smtp.example.com" || cfg.Mail.SMTP.Port != 587 || cfg.Mail.DailyLimit != 250 {
+		t.Errorf("Mail not updated: %+v", cfg.Mail)
+	}
+	if cfg.Mail.Inbound.Port != "2525" || cfg.Mail.Inbound.MaxMB != 10 {
+		t.Errorf("Inbound mail not updated: %+v", cfg.Mail.Inbound)
+	}
+	cfg.Server.Domain = "https://example.com"
+	if got := inboundMailSummary(cfg); got != "<app>@apps.example.com on :2525, up to 10 MB" {
+		t.Errorf("inboundMailSummary = %q", got)
+	}
+	// The notification mail server is separate
+	if cfg.Notify.SMTP.Host != "" {
+		t.Errorf("Expected notifications untouched, got %+v", cfg.Notify.SMTP)
+	}
+
+	bad := []mailOptions{
+		{SMTP: "smtp.example.com:0"},
+		{From: "not an address"},
+		{DailyLimit: "-1"},
+		{Inbound: "smtp"},
+		{InboundDomain: "localhost"},
+		{InboundMaxMB: "0"},
+	}
+	for _, opts := range bad {
+		if err := setMailCommand(opts, dbPath); err == nil {
+			t.Errorf("Expected %+v to fail", opts)
+		}
+	}
+}
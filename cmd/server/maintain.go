@@ -0,0 +1,294 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/maintenance"
+	"github.com/fazt-sh/fazt/internal/output"
+)
+
+// handleServerStorageCommand handles: fazt [@peer] server storage
+func handleServerStorageCommand(args []string) {
+	fs := flag.NewFlagSet("server storage", flag.ExitOnError)
+	limit := fs.Int("limit", 10, "Largest tables and apps to show")
+	fs.Usage = func() {
+		fmt.Println("Usage: fazt [@peer] server storage [--limit n]")
+		fmt.Println()
+		fmt.Println("Show where the database's space goes, by category, table and app.")
+	}
+	fs.Parse(args)
+
+	var result struct {
+		Data maintenance.StorageReport `json:"data"`
+	}
+	peerRequest("GET", "/api/system/storage", nil, &result)
+	report := result.Data
+
+	summary := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Database", formatBytes(report.FileBytes)},
+			{"WAL", formatBytes(report.WALBytes)},
+			{"Free (reclaimable)", formatBytes(report.FreeBytes)},
+			{"Auto-vacuum", report.AutoVacuum},
+		},
+	}
+
+	categories := &output.Table{Headers: []string{"Category", "Size"}, Rows: [][]string{}}
+	var names []string
+	for name := range report.Categories {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return report.Categories[names[i]] > report.Categories[names[j]]
+	})
+	for _, name := range names {
+		categories.Rows = append(categories.Rows, []string{name, formatBytes(report.Categories[name])})
+	}
+
+	tables := &output.Table{Headers: []string{"Table", "Category", "Rows", "Size"}, Rows: [][]string{}}
+	for i, t := range report.Tables {
+		if i == *limit {
+			break
+		}
+		tables.Rows = append(tables.Rows, []string{t.Name, t.Category, fmt.Sprintf("%d", t.Rows), formatBytes(t.Bytes)})
+	}
+
+	apps := &output.Table{Headers: []string{"App", "Files", "Blobs", "Media cache", "Data", "Logs", "Total"}, Rows: [][]string{}}
+	for i, a := range report.Apps {
+		if i == *limit {
+			break
+		}
+		apps.Rows = append(apps.Rows, []string{
+			a.App, formatBytes(a.Files), formatBytes(a.Blobs), formatBytes(a.MediaCache),
+			formatBytes(a.Data), formatBytes(a.Logs), formatBytes(a.Total),
+		})
+	}
+
+	md := output.NewMarkdown().
+		H1("Storage").
+		Table(summary).
+		H2("By Category").
+		Table(categories).
+		H2("Largest Tables").
+		Table(tables)
+	if report.Estimated {
+		md.Para("Table sizes are estimated from content; this SQLite build has no page statistics.")
+	}
+	md.H2("By App").Table(apps)
+
+	getRenderer().Print(md.String(), report)
+}
+
+// handleServerMaintainCommand handles: fazt [@peer] server maintain
+func handleServerMaintainCommand(args []string) {
+	fs := flag.NewFlagSet("server maintain", flag.ExitOnError)
+	vacuum := fs.Bool("vacuum", false, "Release free pages and shrink the database file")
+	pruneEvents := fs.String("prune-events", "", "Delete analytics events older than this, e.g. 90d")
+	pruneLogs := fs.String("prune-logs", "", "Delete site, activity and egress logs older than this, e.g. 30d")
+	noWait := fs.Bool("no-wait", false, "Start the run and return without waiting for it")
+	status := fs.Bool("status", false, "Show the current or last run")
+	fs.Usage = func() {
+		fmt.Println("Usage: fazt [@peer] server maintain [flags]")
+		fmt.Println()
+		fmt.Println("Prune old data and vacuum the database in the background. The server")
+		fmt.Println("keeps serving while it runs; the first vacuum of a database switches it")
+		fmt.Println("to incremental auto-vacuum, which takes one full VACUUM.")
+		fmt.Println()
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server maintain --vacuum --prune-events 90d")
+		fmt.Println("  fazt @zyt server maintain --prune-logs 30d --no-wait")
+		fmt.Println("  fazt @zyt server maintain --status")
+	}
+	fs.Parse(args)
+
+	if *status {
+		printMaintenanceRun(maintenanceStatus())
+		return
+	}
+
+	body := map[string]interface{}{"vacuum": *vacuum}
+	for _, f := range []struct {
+		flag  string
+		key   string
+		value string
+	}{
+		{"--prune-events", "prune_events", *pruneEvents},
+		{"--prune-logs", "prune_logs", *pruneLogs},
+	} {
+		if f.value == "" {
+			continue
+		}
+		d, err := parseDurationValue(f.value)
+		if err != nil || d < 24*time.Hour {
+			fmt.Fprintf(os.Stderr, "Error: invalid %s %q (at least 1d, e.g. 90d)\n", f.flag, f.value)
+			os.Exit(1)
+		}
+		body[f.key] = d.String()
+	}
+	if !*vacuum && len(body) == 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data maintenance.Run `json:"data"`
+	}
+	peerRequest("POST", "/api/system/maintenance", body, &result)
+	if *noWait {
+		fmt.Printf("Maintenance %s started (check with: fazt server maintain --status)\n", result.Data.ID)
+		return
+	}
+	waitForMaintenance(result.Data)
+}
+
+// handleServerRebuildCommand handles: fazt [@peer] server rebuild [target...]
+func handleServerRebuildCommand(args []string) {
+	fs := flag.NewFlagSet("server rebuild", flag.ExitOnError)
+	noWait := fs.Bool("no-wait", false, "Start the run and return without waiting for it")
+	fs.Usage = func() {
+		fmt.Println("Usage: fazt [@peer] server rebuild [target...] [--no-wait]")
+		fmt.Println()
+		fmt.Println("Regenerate derived data from its source in the background, after a bug")
+		fmt.Println("or schema change left it wrong. Without targets, rebuilds them all.")
+		fmt.Println()
+		fmt.Println("Targets:")
+		fmt.Println("  analytics-rollups  Recount the hourly, daily, visitor and geo rollups from")
+		fmt.Println("                     the raw events still kept; older days keep their rollups")
+		fmt.Println("  media-cache        Drop cached image variants, thumbnails and waveforms,")
+		fmt.Println("                     which are made again when next requested")
+		fmt.Println("  search-index       Reindex every collection with search enabled")
+		fmt.Println("  usage              Move usage metered under a subdomain to the app it routes")
+		fmt.Println("                     to and drop days past retention")
+		fmt.Println()
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server rebuild search-index")
+		fmt.Println("  fazt @zyt server rebuild analytics-rollups media-cache --no-wait")
+		fmt.Println("  fazt @zyt server maintain --status")
+	}
+
+	// Targets may come before or after the flags
+	var targets, flags []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+		} else {
+			targets = append(targets, arg)
+		}
+	}
+	fs.Parse(flags)
+	targets = append(targets, fs.Args()...)
+
+	if len(targets) == 0 {
+		targets = maintenance.RebuildTargets
+	}
+	for _, target := range targets {
+		if !maintenance.IsRebuildTarget(target) {
+			fmt.Fprintf(os.Stderr, "Error: unknown target %q (one of %s)\n", target, strings.Join(maintenance.RebuildTargets, ", "))
+			os.Exit(1)
+		}
+	}
+
+	var result struct {
+		Data maintenance.Run `json:"data"`
+	}
+	peerRequest("POST", "/api/system/maintenance", map[string]interface{}{"rebuild": targets}, &result)
+	if *noWait {
+		fmt.Printf("Rebuild %s started (check with: fazt server maintain --status)\n", result.Data.ID)
+		return
+	}
+	waitForMaintenance(result.Data)
+}
+
+// waitForMaintenance prints a run's progress until it finishes, then its
+// result, exiting non-zero if it failed
+func waitForMaintenance(run maintenance.Run) {
+	last := ""
+	for run.State == maintenance.StateRunning {
+		line := fmt.Sprintf("[%3.0f%%] %s", run.Progress*100, run.Step)
+		if line != last {
+			fmt.Fprintln(os.Stderr, line)
+			last = line
+		}
+		time.Sleep(time.Second)
+		run = maintenanceStatus()
+	}
+	printMaintenanceRun(run)
+	if run.State == maintenance.StateFailed {
+		os.Exit(1)
+	}
+}
+
+func maintenanceStatus() maintenance.Run {
+	var result struct {
+		Data maintenance.Run `json:"data"`
+	}
+	peerRequest("GET", "/api/system/maintenance", nil, &result)
+	return result.Data
+}
+
+func printMaintenanceRun(run maintenance.Run) {
+	table := &output.Table{
+		Headers: []string{"Field", "Value"},
+		Rows: [][]string{
+			{"Run", run.ID},
+			{"State", run.State},
+			{"Progress", fmt.Sprintf("%.0f%%", run.Progress*100)},
+			{"Started", output.TimeAgoUnix(run.StartedAt)},
+		},
+	}
+	if run.State == maintenance.StateRunning {
+		table.Rows = append(table.Rows, []string{"Step", run.Step})
+	} else {
+		table.Rows = append(table.Rows, []string{"Took", (time.Duration(run.FinishedAt-run.StartedAt) * time.Second).String()})
+	}
+
+	var tables []string
+	for name := range run.Deleted {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+	for _, name := range tables {
+		table.Rows = append(table.Rows, []string{"Pruned " + name, fmt.Sprintf("%d rows", run.Deleted[name])})
+	}
+	for _, target := range maintenance.RebuildTargets {
+		if n, ok := run.Rebuilt[target]; ok {
+			table.Rows = append(table.Rows, []string{"Rebuilt " + target, rebuiltCount(target, n)})
+		}
+	}
+	if run.Vacuum {
+		table.Rows = append(table.Rows, []string{"Reclaimed", formatBytes(run.Reclaimed)})
+	}
+	if run.Error != "" {
+		table.Rows = append(table.Rows, []string{"Error", run.Error})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("Maintenance").
+		Table(table).
+		String(), run)
+}
+
+// rebuiltCount describes what a rebuild of target covered
+func rebuiltCount(target string, n int64) string {
+	switch target {
+	case maintenance.RebuildAnalyticsRollups:
+		return fmt.Sprintf("%d events recounted", n)
+	case maintenance.RebuildMediaCache:
+		return fmt.Sprintf("%d variants dropped", n)
+	case maintenance.RebuildSearchIndex:
+		return fmt.Sprintf("%d documents indexed", n)
+	case maintenance.RebuildUsage:
+		return fmt.Sprintf("%d rows moved", n)
+	}
+	return fmt.Sprintf("%d", n)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/notify"
+)
+
+// notifyOptions are the set-notify flags; empty fields are left unchanged
+type notifyOptions struct {
+	NtfyTopic    string
+	NtfyURL      string
+	SMTP         string // host[:port]
+	SMTPUser     string
+	SMTPPassword string
+	EmailFrom    string
+	EmailTo      string // Comma-separated
+	Webhook      string
+	Routes       string // e.g. cert.failed=email+ntfy,login.new_ip=none
+}
+
+func (o notifyOptions) empty() bool {
+	return o == notifyOptions{}
+}
+
+// setNotifyCommand stores notification channel settings and routes
+func setNotifyCommand(opts notifyOptions, dbPath string) error {
+	keys := map[string]string{}
+
+	if opts.NtfyTopic != "" {
+		topic := opts.NtfyTopic
+		if topic == "none" {
+			topic = ""
+		}
+		keys["ntfy.topic"] = topic
+	}
+	if opts.NtfyURL != "" {
+		if err := validateNotifyURL(opts.NtfyURL); err != nil {
+			return fmt.Errorf("Error: invalid --ntfy-url: %v", err)
+		}
+		keys["ntfy.url"] = strings.TrimSuffix(opts.NtfyURL, "/")
+	}
+
+	if opts.SMTP != "" {
+		if opts.SMTP == "none" {
+			keys["notify.smtp.host"] = ""
+		} else {
+			host, port, err := parseSMTPAddr(opts.SMTP)
+			if err != nil {
+				return err
+			}
+			keys["notify.smtp.host"] = host
+			keys["notify.smtp.port"] = port
+		}
+	}
+	if opts.SMTPUser != "" {
+		keys["notify.smtp.username"] = opts.SMTPUser
+	}
+	if opts.SMTPPassword != "" {
+		keys["notify.smtp.password"] = opts.SMTPPassword
+	}
+	if opts.EmailFrom != "" {
+		if _, err := mail.ParseAddress(opts.EmailFrom); err != nil {
+			return fmt.Errorf("Error: invalid --email-from '%s'", opts.EmailFrom)
+		}
+		keys["notify.smtp.from"] = opts.EmailFrom
+	}
+	if opts.EmailTo != "" {
+		var to []string
+		for _, addr := range strings.Split(opts.EmailTo, ",") {
+			addr = strings.TrimSpace(addr)
+			if _, err := mail.ParseAddress(addr); err != nil {
+				return fmt.Errorf("Error: invalid --email-to address '%s'", addr)
+			}
+			to = append(to, addr)
+		}
+		keys["notify.smtp.to"] = strings.Join(to, ",")
+	}
+
+	if opts.Webhook != "" {
+		webhook := opts.Webhook
+		if webhook == "none" {
+			webhook = ""
+		} else if err := validateNotifyURL(webhook); err != nil {
+			return fmt.Errorf("Error: invalid --webhook: %v", err)
+		}
+		keys["notify.webhook_url"] = webhook
+	}
+
+	routeKeys, err := parseNotifyRoutes(opts.Routes)
+	if err != nil {
+		return fmt.Errorf("Error: invalid --route: %v", err)
+	}
+	for k, v := range routeKeys {
+		keys[k] = v
+	}
+
+	// Initialize DB
+	if err := database.Init(dbPath); err != nil {
+		return fmt.Errorf("failed to init database: %w", err)
+	}
+	defer database.Close()
+
+	store := config.NewDBConfigStore(database.GetDB())
+	for k, v := range keys {
+		if err := store.Set(k, v); err != nil {
+			return fmt.Errorf("failed to set %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// parseNotifyRoutes turns a spec like "cert.failed=email+ntfy,login.new_ip=none"
+// into notify.route.* config keys. "all" sends the event to every configured
+// channel, which is also what events without a route get.
+func parseNotifyRoutes(spec string) (map[string]string, error) {
+	keys := map[string]string{}
+	if spec == "" {
+		return keys, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		event, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || !notify.ValidEvent(event) {
+			return nil, fmt.Errorf("'%s' (expected <event>=<channels>, events: %s)", entry, strings.Join(notify.Events, ", "))
+		}
+		switch value {
+		case "all":
+			value = ""
+		case "none":
+		default:
+			channels := strings.Split(value, "+")
+			for _, c := range channels {
+				if !notify.ValidChannel(c) {
+					return nil, fmt.Errorf("unknown channel '%s' for %s (expected %s, all or none)", c, event, strings.Join(notify.ChannelNames, ", "))
+				}
+			}
+			value = strings.Join(channels, ",")
+		}
+		keys["notify.route."+event] = value
+	}
+	return keys, nil
+}
+
+// parseSMTPAddr splits a --smtp value, host or host:port, defaulting to
+// port 587
+func parseSMTPAddr(addr string) (host, port string, err error) {
+	host, port = addr, "587"
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		host, port = h, p
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || host == "" {
+		return "", "", fmt.Errorf("Error: invalid --smtp '%s' (expected host or host:port)", addr)
+	}
+	return host, port, nil
+}
+
+func validateNotifyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("must be an http(s) URL")
+	}
+	return nil
+}
+
+// notifySummary describes the configured channels and routes for status
+func notifySummary(cfg *config.Config) string {
+	channels := notify.Channels(cfg)
+	if len(channels) == 0 {
+		return "off (no channels configured)"
+	}
+	var names []string
+	for name := range channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := strings.Join(names, ", ")
+	var routes []string
+	for _, event := range notify.Events {
+		if route, ok := cfg.Notify.Routes[event]; ok {
+			if len(route) == 0 {
+				routes = append(routes, event+"=none")
+			} else {
+				routes = append(routes, event+"="+strings.Join(route, "+"))
+			}
+		}
+	}
+	if len(routes) > 0 {
+		summary += " (" + strings.Join(routes, ", ") + ")"
+	}
+	return summary
+}
+
+// testNotifyCommand sends a test message to every configured channel
+func testNotifyCommand(dbPath string) (map[string]error, error) {
+	if err := database.Init(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to init database: %w", err)
+	}
+	defer database.Close()
+
+	cfg := config.CreateDefaultConfig()
+	if err := config.NewDBConfigStore(database.GetDB()).Apply(cfg); err != nil {
+		return nil, err
+	}
+	results := notify.SendTest(cfg)
+	if len(results) == 0 {
+		return nil, errors.New("Error: no notification channels configured")
+	}
+	return results, nil
+}
+
+func handleSetNotifyCommand() {
+	flags := flag.NewFlagSet("set-notify", flag.ExitOnError)
+	var opts notifyOptions
+	flags.StringVar(&opts.NtfyTopic, "ntfy-topic", "", "ntfy topic to publish to (none disables ntfy)")
+	flags.StringVar(&opts.NtfyURL, "ntfy-url", "", "ntfy server (default: https://ntfy.sh)")
+	flags.StringVar(&opts.SMTP, "smtp", "", "Mail server as host[:port], port 587 by default, 465 for implicit TLS (none disables email)")
+	flags.StringVar(&opts.SMTPUser, "smtp-user", "", "SMTP username")
+	flags.StringVar(&opts.SMTPPassword, "smtp-password", "", "SMTP password")
+	flags.StringVar(&opts.EmailFrom, "email-from", "", "Sender address (default: the SMTP username)")
+	flags.StringVar(&opts.EmailTo, "email-to", "", "Comma-separated recipient addresses")
+	flags.StringVar(&opts.Webhook, "webhook", "", "URL notifications are POSTed to as JSON (none disables it)")
+	flags.StringVar(&opts.Routes, "route", "", "Channels per event, e.g. cert.failed=email+ntfy,traffic_spike=none (all resets)")
+	test := flags.Bool("test", false, "Send a test notification to every configured channel")
+	db := flags.String("db", "", "Database file path")
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server set-notify [flags]")
+		fmt.Println()
+		fmt.Println("Configure where admin notifications are sent. Events without a route")
+		fmt.Println("go to every configured channel. Changes take effect on restart.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Printf("Events: %s\n", strings.Join(notify.Events, ", "))
+		fmt.Printf("Channels: %s\n", strings.Join(notify.ChannelNames, ", "))
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server set-notify --ntfy-topic my-fazt-alerts")
+		fmt.Println("  fazt server set-notify --smtp smtp.example.com --smtp-user alerts@example.com --smtp-password secret --email-to ops@example.com")
+		fmt.Println("  fazt server set-notify --webhook https://hooks.example.com/fazt")
+		fmt.Println("  fazt server set-notify --route cert.failed=email+ntfy,disk.pressure=email,traffic_spike=none")
+		fmt.Println("  fazt server set-notify --test")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Resolve DB Path
+	dbPath := "./data.db"
+	if envPath := os.Getenv("FAZT_DB_PATH"); envPath != "" {
+		dbPath = envPath
+	}
+	if *db != "" {
+		dbPath = config.ExpandPath(*db)
+	}
+
+	if opts.empty() && !*test {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if !opts.empty() {
+		if err := setNotifyCommand(opts, dbPath); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("✓ Notification settings updated (restart the server to apply)")
+	}
+
+	if *test {
+		results, err := testNotifyCommand(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		failed := false
+		for _, name := range notify.ChannelNames {
+			err, ok := results[name]
+			if !ok {
+				continue
+			}
+			if err != nil {
+				failed = true
+				fmt.Printf("  ✗ %s: %v\n", name, err)
+			} else {
+				fmt.Printf("  ✓ %s\n", name)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/pings"
+)
+
+func handlePingCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("ping", printPingUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handlePingAdd(args[1:])
+	case "list":
+		handlePingList(args[1:])
+	case "runs":
+		handlePingRuns(args[1:])
+	case "run":
+		handlePingRun(args[1:])
+	case "pause":
+		handlePingSetEnabled(args[1:], false)
+	case "resume":
+		handlePingSetEnabled(args[1:], true)
+	case "remove":
+		handlePingRemove(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("ping", printPingUsage)
+	default:
+		fmt.Printf("Unknown ping subcommand: %s\n", args[0])
+		printPingUsage()
+		os.Exit(1)
+	}
+}
+
+func printPingUsage() {
+	fmt.Println("fazt ping - Scheduled outbound HTTP calls (cron-as-a-service)")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] ping <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <name> <url>        Call a URL on a cron schedule")
+	fmt.Println("  list                    List pings with their latest result")
+	fmt.Println("  runs <name>             Show a ping's recent runs")
+	fmt.Println("  run <name>              Call a ping now and show the result")
+	fmt.Println("  pause <name>            Stop calling a ping on its schedule")
+	fmt.Println("  resume <name>           Start calling a paused ping again")
+	fmt.Println("  remove <name>           Delete a ping and its runs")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --schedule <cron>       5-field cron expression, server time (add, required)")
+	fmt.Println("  -X, --method <m>        HTTP method (add, default: GET)")
+	fmt.Println("  -H, --header <k: v>     Request header, repeatable (add)")
+	fmt.Println("  --body <text>           Request body (add)")
+	fmt.Println("  --timeout <d>           Per-call timeout (add, default: 30s, max: 2m)")
+	fmt.Println("  --limit <n>             Runs to show (runs, default: 20)")
+	fmt.Println()
+	fmt.Println("Calls go through the egress proxy: allow the URL's domain first with")
+	fmt.Println("fazt net allow. A notification is sent when a ping starts failing or")
+	fmt.Println("recovers.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt ping add keep-awake https://api.example.com/health --schedule \"*/10 * * * *\"")
+	fmt.Println("  fazt @zyt ping add nightly https://hooks.example.com/rebuild --schedule \"0 3 * * *\" \\")
+	fmt.Println("      -X POST -H \"Authorization: Bearer abc123\" --body '{\"full\":true}'")
+	fmt.Println("  fazt @zyt ping runs keep-awake")
+}
+
+// headerFlags collects repeated -H "Name: value" flags
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return fmt.Sprintf("%d headers", len(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", value)
+	}
+	h[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	return nil
+}
+
+func handlePingAdd(args []string) {
+	fs := flag.NewFlagSet("ping add", flag.ExitOnError)
+	scheduleFlag := fs.String("schedule", "", "5-field cron expression")
+	methodFlag := fs.String("method", "GET", "HTTP method")
+	fs.StringVar(methodFlag, "X", "GET", "HTTP method (shorthand)")
+	headers := headerFlags{}
+	fs.Var(headers, "header", "Request header (repeatable)")
+	fs.Var(headers, "H", "Request header (shorthand)")
+	bodyFlag := fs.String("body", "", "Request body")
+	timeoutFlag := fs.String("timeout", "30s", "Per-call timeout")
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Error: name and URL required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt ping add <name> <url> --schedule <cron> [-X <method>] [-H <k: v>]... [--body <text>] [--timeout <d>]")
+		os.Exit(1)
+	}
+	name, target := args[0], args[1]
+	fs.Parse(args[2:])
+
+	if *scheduleFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --schedule is required, e.g. --schedule \"*/5 * * * *\"")
+		os.Exit(1)
+	}
+	timeout, err := time.ParseDuration(*timeoutFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --timeout: %v\n", err)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"name":            name,
+		"url":             target,
+		"method":          *methodFlag,
+		"headers":         headers,
+		"body":            *bodyFlag,
+		"schedule":        *scheduleFlag,
+		"timeout_seconds": int64(timeout.Seconds()),
+	}
+
+	var result struct {
+		Data pings.Ping `json:"data"`
+	}
+	peerRequest("POST", "/api/pings", body, &result)
+
+	fmt.Printf("Ping %s added: %s %s on \"%s\"\n", result.Data.Name, result.Data.Method, result.Data.URL, result.Data.Schedule)
+}
+
+func handlePingList(args []string) {
+	var result struct {
+		Data struct {
+			Pings []pings.Ping `json:"pings"`
+		} `json:"data"`
+	}
+	peerRequest("GET", "/api/pings", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Name", "Request", "Schedule", "Result", "Last Run"},
+		Rows:    [][]string{},
+	}
+	for _, p := range result.Data.Pings {
+		res, last := "-", "never"
+		if p.LastRun != nil {
+			res = formatPingResult(*p.LastRun)
+			last = formatTime(time.Unix(p.LastRun.RanAt, 0))
+		}
+		if !p.Enabled {
+			res += " (paused)"
+		}
+		table.Rows = append(table.Rows, []string{
+			p.Name,
+			p.Method + " " + truncate(p.URL, 50),
+			p.Schedule,
+			res,
+			last,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("Pings").
+		Table(table).
+		String(), result.Data)
+}
+
+func handlePingRuns(args []string) {
+	fs := flag.NewFlagSet("ping runs", flag.ExitOnError)
+	limitFlag := fs.Int("limit", 20, "Runs to show")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: ping name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt ping runs <name> [--limit <n>]")
+		os.Exit(1)
+	}
+	name := args[0]
+	fs.Parse(args[1:])
+
+	var result struct {
+		Data struct {
+			Ping pings.Ping  `json:"ping"`
+			Runs []pings.Run `json:"runs"`
+		} `json:"data"`
+	}
+	peerRequest("GET", fmt.Sprintf("%s/runs?limit=%d", pingPath(name), *limitFlag), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Ran", "Result", "Duration", "Error"},
+		Rows:    [][]string{},
+	}
+	for _, run := range result.Data.Runs {
+		table.Rows = append(table.Rows, []string{
+			formatTime(time.Unix(run.RanAt, 0)),
+			formatPingResult(run),
+			fmt.Sprintf("%dms", run.DurationMs),
+			run.Error,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1(fmt.Sprintf("%s: %s %s", result.Data.Ping.Name, result.Data.Ping.Method, result.Data.Ping.URL)).
+		Table(table).
+		String(), result.Data)
+}
+
+func handlePingRun(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: ping name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt ping run <name>")
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data pings.Run `json:"data"`
+	}
+	peerRequest("POST", pingPath(args[0])+"/run", nil, &result)
+
+	fmt.Printf("%s: %s in %dms\n", args[0], formatPingResult(result.Data), result.Data.DurationMs)
+	if result.Data.Error != "" {
+		fmt.Printf("Error: %s\n", result.Data.Error)
+	}
+	if !result.Data.OK {
+		os.Exit(1)
+	}
+}
+
+func handlePingSetEnabled(args []string, enabled bool) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: ping name required")
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data pings.Ping `json:"data"`
+	}
+	peerRequest("PATCH", pingPath(args[0]), map[string]interface{}{"enabled": enabled}, &result)
+
+	if enabled {
+		fmt.Printf("Ping %s resumed\n", result.Data.Name)
+	} else {
+		fmt.Printf("Ping %s paused\n", result.Data.Name)
+	}
+}
+
+func handlePingRemove(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: ping name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt ping remove <name>")
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	peerRequest("DELETE", pingPath(args[0]), nil, &result)
+	fmt.Printf("Ping %s removed\n", args[0])
+}
+
+// formatPingResult shows a run as "200", "FAIL 503" or "FAIL"
+func formatPingResult(run pings.Run) string {
+	switch {
+	case run.OK:
+		return fmt.Sprintf("%d", run.Status)
+	case run.Status != 0:
+		return fmt.Sprintf("FAIL %d", run.Status)
+	default:
+		return "FAIL"
+	}
+}
+
+func pingPath(name string) string {
+	return "/api/pings/" + url.PathEscape(name)
+}
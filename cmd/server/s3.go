@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/s3gw"
+)
+
+func handleS3Command(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("s3", printS3Usage)
+		return
+	}
+
+	switch args[0] {
+	case "key":
+		handleS3KeyCommand(args[1:])
+	case "presign":
+		handleS3Presign(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("s3", printS3Usage)
+	default:
+		fmt.Printf("Unknown s3 subcommand: %s\n", args[0])
+		printS3Usage()
+		os.Exit(1)
+	}
+}
+
+func handleS3KeyCommand(args []string) {
+	if len(args) < 1 {
+		printS3Usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "add":
+		handleS3KeyAdd(args[1:])
+	case "list":
+		handleS3KeyList(args[1:])
+	case "remove":
+		handleS3KeyRemove(args[1:])
+	default:
+		fmt.Printf("Unknown s3 key subcommand: %s\n", args[0])
+		printS3Usage()
+		os.Exit(1)
+	}
+}
+
+func printS3Usage() {
+	fmt.Println("fazt s3 - S3-compatible access to app storage")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] s3 <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  key add <app>           Create an access key for the app's bucket")
+	fmt.Println("  key list                List access keys")
+	fmt.Println("  key remove <id>         Revoke an access key")
+	fmt.Println("  presign <app> <path>    Print a presigned URL for one object")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --name <name>           Label for the key (key add)")
+	fmt.Println("  --read-only             Allow only GET and HEAD (key add)")
+	fmt.Println("  --app <app>             Only list keys for this app (key list)")
+	fmt.Println("  --expires <d>           URL lifetime, up to 7d (presign, default: 1h)")
+	fmt.Println("  --method <m>            GET, HEAD, PUT or DELETE (presign, default: GET)")
+	fmt.Println("  --key <id>              Key to sign with (presign, default: the app's newest)")
+	fmt.Println()
+	fmt.Println("Each app is a bucket named after it at https://s3.<domain>, addressed")
+	fmt.Println("path-style. The secret is shown once, when the key is created.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt s3 key add blog --name backups")
+	fmt.Println("  fazt @zyt s3 presign blog reports/2026.pdf --expires 1d")
+	fmt.Println("  fazt @zyt s3 presign blog uploads/photo.jpg --method PUT --expires 15m")
+}
+
+func handleS3KeyAdd(args []string) {
+	fs := flag.NewFlagSet("s3 key add", flag.ExitOnError)
+	nameFlag := fs.String("name", "", "Label for the key")
+	readOnlyFlag := fs.Bool("read-only", false, "Allow only GET and HEAD")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: app name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt s3 key add <app> [--name <name>] [--read-only]")
+		os.Exit(1)
+	}
+	app := args[0]
+	fs.Parse(args[1:])
+
+	body := map[string]interface{}{
+		"app":       app,
+		"name":      *nameFlag,
+		"read_only": *readOnlyFlag,
+	}
+
+	var result struct {
+		Data struct {
+			Key      s3gw.Key `json:"key"`
+			Endpoint string   `json:"endpoint"`
+			Region   string   `json:"region"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/s3/keys", body, &result)
+
+	k := result.Data.Key
+	access := "read-write"
+	if k.ReadOnly {
+		access = "read-only"
+	}
+	fmt.Printf("Access key created for bucket %s (%s)\n", k.AppID, access)
+	fmt.Println()
+	fmt.Printf("  Endpoint:          %s\n", result.Data.Endpoint)
+	fmt.Printf("  Region:            %s\n", result.Data.Region)
+	fmt.Printf("  Access key ID:     %s\n", k.ID)
+	fmt.Printf("  Secret access key: %s\n", k.Secret)
+	fmt.Println()
+	fmt.Println("Save the secret now; it can't be shown again.")
+}
+
+func handleS3KeyList(args []string) {
+	fs := flag.NewFlagSet("s3 key list", flag.ExitOnError)
+	appFlag := fs.String("app", "", "Only list keys for this app")
+	fs.Parse(args)
+
+	path := "/api/s3/keys"
+	if *appFlag != "" {
+		path += "?app=" + url.QueryEscape(*appFlag)
+	}
+
+	var result struct {
+		Data struct {
+			Keys     []s3gw.Key `json:"keys"`
+			Endpoint string     `json:"endpoint"`
+		} `json:"data"`
+	}
+	peerRequest("GET", path, nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "App", "Name", "Access", "Created", "Last Used"},
+		Rows:    [][]string{},
+	}
+	for _, k := range result.Data.Keys {
+		access := "read-write"
+		if k.ReadOnly {
+			access = "read-only"
+		}
+		lastUsed := "never"
+		if k.LastUsedAt != nil {
+			lastUsed = formatTime(time.Unix(*k.LastUsedAt, 0))
+		}
+		table.Rows = append(table.Rows, []string{
+			k.ID,
+			k.AppID,
+			k.Name,
+			access,
+			formatTime(time.Unix(k.CreatedAt, 0)),
+			lastUsed,
+		})
+	}
+
+	getRenderer().Print(output.NewMarkdown().
+		H1("S3 Access Keys").
+		Para("Endpoint: "+result.Data.Endpoint).
+		Table(table).
+		String(), result.Data)
+}
+
+func handleS3KeyRemove(args []string) {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: access key ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt s3 key remove <id>")
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	peerRequest("DELETE", "/api/s3/keys/"+url.PathEscape(args[0]), nil, &result)
+	fmt.Printf("Access key %s revoked\n", args[0])
+}
+
+func handleS3Presign(args []string) {
+	fs := flag.NewFlagSet("s3 presign", flag.ExitOnError)
+	expiresFlag := fs.String("expires", "1h", "URL lifetime")
+	methodFlag := fs.String("method", "GET", "HTTP method the URL is for")
+	keyFlag := fs.String("key", "", "Access key ID to sign with")
+
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") || strings.HasPrefix(args[1], "-") {
+		fmt.Fprintln(os.Stderr, "Error: app and path required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt s3 presign <app> <path> [--expires <d>] [--method <m>] [--key <id>]")
+		os.Exit(1)
+	}
+	app, path := args[0], args[1]
+	fs.Parse(args[2:])
+
+	expires, err := hosting.ParseExpiry(*expiresFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --expires %q: use e.g. 15m, 6h or 1d\n", *expiresFlag)
+		os.Exit(1)
+	}
+
+	body := map[string]interface{}{
+		"app":             app,
+		"path":            path,
+		"method":          *methodFlag,
+		"expires_seconds": int64(expires.Seconds()),
+		"key_id":          *keyFlag,
+	}
+
+	var result struct {
+		Data struct {
+			URL       string `json:"url"`
+			ExpiresAt string `json:"expires_at"`
+		} `json:"data"`
+	}
+	peerRequest("POST", "/api/s3/presign", body, &result)
+
+	fmt.Println(result.Data.URL)
+	fmt.Fprintf(os.Stderr, "Expires %s\n", result.Data.ExpiresAt)
+}
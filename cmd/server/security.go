@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/security"
+)
+
+func handleSecurityCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("security", printSecurityUsage)
+		return
+	}
+
+	switch args[0] {
+	case "events":
+		handleSecurityEvents(args[1:])
+	case "rules":
+		handleSecurityRules(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("security", printSecurityUsage)
+	default:
+		fmt.Printf("Unknown security subcommand: %s\n", args[0])
+		printSecurityUsage()
+		os.Exit(1)
+	}
+}
+
+func printSecurityUsage() {
+	fmt.Println("fazt security - Security event stream and alert rules")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] security <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  events                      List recent security events")
+	fmt.Println("  rules                       List alert rules")
+	fmt.Println("  rules add <name>            Alert on an event type")
+	fmt.Println("  rules delete <id>           Remove an alert rule")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --type <type>               Only events of this type (events)")
+	fmt.Println("  --since <duration>          Only events this recent, e.g. 24h, 7d (events)")
+	fmt.Println("  --limit <n>                 Maximum events to show (events, default: 50)")
+	fmt.Println("  --event <type>              Event type the rule matches, or * for any (rules add)")
+	fmt.Println("  --threshold <n>             Alert after n events (rules add, default: 1)")
+	fmt.Println("  --window <duration>         ...within this long, e.g. 10m (rules add)")
+	fmt.Println("  --webhook <url>             POST alerts here instead of the notification channels (rules add)")
+	fmt.Println()
+	fmt.Printf("Event types: %s\n", strings.Join(security.EventTypes, ", "))
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt security events --type login.failed --since 24h")
+	fmt.Println("  fazt @zyt security rules add \"New API keys\" --event token.created")
+	fmt.Println("  fazt @zyt security rules add \"Brute force\" --event login.failed --threshold 20 --window 5m")
+}
+
+func handleSecurityEvents(args []string) {
+	fs := flag.NewFlagSet("security events", flag.ExitOnError)
+	typeFlag := fs.String("type", "", "Only events of this type")
+	sinceFlag := fs.String("since", "", "Only events this recent, e.g. 24h")
+	limitFlag := fs.Int("limit", 50, "Maximum events to show")
+	fs.Parse(args)
+
+	query := url.Values{"limit": {strconv.Itoa(*limitFlag)}}
+	if *typeFlag != "" {
+		if !security.ValidEventType(*typeFlag) {
+			fmt.Fprintf(os.Stderr, "Error: unknown event type %q (expected one of %s)\n", *typeFlag, strings.Join(security.EventTypes, ", "))
+			os.Exit(1)
+		}
+		query.Set("type", *typeFlag)
+	}
+	if *sinceFlag != "" {
+		d, err := parseDurationValue(*sinceFlag)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: invalid --since %q (e.g. 24h or 7d)\n", *sinceFlag)
+			os.Exit(1)
+		}
+		query.Set("since", d.String())
+	}
+
+	var result struct {
+		Data struct {
+			Events []security.Event `json:"events"`
+		} `json:"data"`
+	}
+	securityRequest("GET", "/events?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"Time", "Type", "Severity", "Actor", "Subject", "IP"},
+		Rows:    [][]string{},
+	}
+	for _, e := range result.Data.Events {
+		table.Rows = append(table.Rows, []string{
+			time.Unix(e.CreatedAt, 0).Format("Mon Jan 2 15:04"),
+			e.Type,
+			e.Severity,
+			orDash(e.Actor),
+			orDash(e.Subject),
+			orDash(e.IP),
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Security Events").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleSecurityRules(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			handleSecurityRuleAdd(args[1:])
+			return
+		case "delete":
+			handleSecurityRuleDelete(args[1:])
+			return
+		default:
+			fmt.Printf("Unknown security rules subcommand: %s\n", args[0])
+			printSecurityUsage()
+			os.Exit(1)
+		}
+	}
+
+	var result struct {
+		Data struct {
+			Rules []security.Rule `json:"rules"`
+		} `json:"data"`
+	}
+	securityRequest("GET", "/rules", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Name", "Event", "When", "Sends To", "Last Fired"},
+		Rows:    [][]string{},
+	}
+	for _, r := range result.Data.Rules {
+		when := "every event"
+		if r.Threshold > 1 {
+			when = fmt.Sprintf("%d in %s", r.Threshold, time.Duration(r.Window)*time.Second)
+		}
+		sendsTo := "notify"
+		if r.WebhookURL != "" {
+			sendsTo = r.WebhookURL
+		}
+		lastFired := "-"
+		if r.LastFiredAt != nil {
+			lastFired = time.Unix(*r.LastFiredAt, 0).Format("Mon Jan 2 15:04")
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(r.ID, 10),
+			r.Name,
+			r.EventType,
+			when,
+			sendsTo,
+			lastFired,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Security Alert Rules").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleSecurityRuleAdd(args []string) {
+	fs := flag.NewFlagSet("security rules add", flag.ExitOnError)
+	eventFlag := fs.String("event", "", "Event type the rule matches, or * for any")
+	thresholdFlag := fs.Int("threshold", 1, "Alert after this many events")
+	windowFlag := fs.String("window", "", "Within this long, e.g. 10m")
+	webhookFlag := fs.String("webhook", "", "POST alerts here instead of the notification channels")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: name required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt security rules add <name> --event <type> [--threshold <n> --window <duration>] [--webhook <url>]")
+		os.Exit(1)
+	}
+	name := args[0]
+	fs.Parse(args[1:])
+
+	if *eventFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --event is required")
+		os.Exit(1)
+	}
+
+	rule := security.Rule{
+		Name:       name,
+		EventType:  *eventFlag,
+		Threshold:  *thresholdFlag,
+		WebhookURL: *webhookFlag,
+	}
+	if *windowFlag != "" {
+		d, err := parseDurationValue(*windowFlag)
+		if err != nil || d < time.Second {
+			fmt.Fprintf(os.Stderr, "Error: invalid --window %q (e.g. 10m or 1h)\n", *windowFlag)
+			os.Exit(1)
+		}
+		rule.Window = int(d / time.Second)
+	}
+	if err := rule.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data security.Rule `json:"data"`
+	}
+	securityRequest("POST", "/rules", rule, &result)
+	fmt.Printf("Rule %d added: %s\n", result.Data.ID, result.Data.Name)
+}
+
+func handleSecurityRuleDelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: rule ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt security rules delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid rule ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	securityRequest("DELETE", "/rules/"+args[0], nil, &result)
+	fmt.Printf("Rule %s deleted\n", args[0])
+}
+
+// securityRequest calls the peer's /api/security endpoint and decodes the response
+func securityRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/security"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/slo"
+)
+
+func handleSLOCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("slo", printSLOUsage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		handleSLOAdd(args[1:])
+	case "list":
+		handleSLOList(args[1:])
+	case "delete":
+		handleSLODelete(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("slo", printSLOUsage)
+	default:
+		fmt.Printf("Unknown slo subcommand: %s\n", args[0])
+		printSLOUsage()
+		os.Exit(1)
+	}
+}
+
+func printSLOUsage() {
+	fmt.Println("fazt slo - Service level objectives and error budgets")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] slo <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  add <alias>                 Define an SLO for an alias")
+	fmt.Println("  list                        List SLOs with budget and burn rates")
+	fmt.Println("  delete <id>                 Remove an SLO")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --availability <pct>        Availability objective, e.g. 99.9 (non-5xx responses)")
+	fmt.Println("  --latency <ms>              Latency threshold: 100, 250, 500, 1000, 2500, or 5000")
+	fmt.Println("  --objective <pct>           Share of requests under the latency threshold, e.g. 99")
+	fmt.Println("  --window <d>                Compliance window, e.g. 7d (default 30d, max 90d)")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt slo add blog --availability 99.9")
+	fmt.Println("  fazt @zyt slo add blog --latency 500ms --objective 99 --window 7d")
+	fmt.Println("  fazt @zyt slo list")
+}
+
+func handleSLOAdd(args []string) {
+	fs := flag.NewFlagSet("slo add", flag.ExitOnError)
+	availabilityFlag := fs.String("availability", "", "Availability objective, e.g. 99.9")
+	latencyFlag := fs.String("latency", "", "Latency threshold, e.g. 500ms")
+	objectiveFlag := fs.String("objective", "", "Share of requests under the latency threshold, e.g. 99")
+	windowFlag := fs.String("window", "30d", "Compliance window, e.g. 7d")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: alias required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt slo add <alias> (--availability <pct> | --latency <ms> --objective <pct>) [--window <d>]")
+		os.Exit(1)
+	}
+	alias := args[0]
+	fs.Parse(args[1:])
+
+	cfg := map[string]interface{}{"alias": alias}
+	var err error
+	switch {
+	case *availabilityFlag != "" && *latencyFlag != "":
+		fmt.Fprintln(os.Stderr, "Error: use either --availability or --latency, not both")
+		os.Exit(1)
+	case *availabilityFlag != "":
+		cfg["kind"] = slo.KindAvailability
+		cfg["objective"], err = parseObjective(*availabilityFlag)
+	case *latencyFlag != "":
+		if *objectiveFlag == "" {
+			fmt.Fprintln(os.Stderr, "Error: --latency requires --objective")
+			os.Exit(1)
+		}
+		d, perr := time.ParseDuration(strings.TrimSpace(*latencyFlag))
+		if perr != nil {
+			ms, aerr := strconv.Atoi(*latencyFlag)
+			if aerr != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --latency: %v\n", perr)
+				os.Exit(1)
+			}
+			d = time.Duration(ms) * time.Millisecond
+		}
+		cfg["kind"] = slo.KindLatency
+		cfg["threshold_ms"] = d.Milliseconds()
+		cfg["objective"], err = parseObjective(*objectiveFlag)
+	default:
+		fmt.Fprintln(os.Stderr, "Error: --availability or --latency is required")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	window, err := parseDurationValue(*windowFlag)
+	if err != nil || window < 24*time.Hour || window%(24*time.Hour) != 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid --window %q (use whole days, e.g. 30d)\n", *windowFlag)
+		os.Exit(1)
+	}
+	cfg["window_days"] = int(window / (24 * time.Hour))
+
+	var result struct {
+		Data slo.SLO `json:"data"`
+	}
+	sloRequest("POST", "", cfg, &result)
+
+	fmt.Printf("SLO %d added: %s %s\n", result.Data.ID, result.Data.Alias, result.Data.Describe())
+}
+
+// parseObjective accepts a percentage ("99.9" or "99.9%") and returns a fraction
+func parseObjective(s string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || pct <= 0 || pct >= 100 {
+		return 0, fmt.Errorf("objective must be a percentage between 0 and 100, e.g. 99.9")
+	}
+	return pct / 100, nil
+}
+
+func handleSLOList(args []string) {
+	var result struct {
+		Data struct {
+			SLOs []slo.Report `json:"slos"`
+		} `json:"data"`
+	}
+	sloRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Alias", "Objective", "SLI", "Budget Left", "Burn 1h", "Burn 6h", "Alert"},
+		Rows:    [][]string{},
+	}
+	for _, r := range result.Data.SLOs {
+		sli := "-"
+		if r.Requests > 0 {
+			sli = fmt.Sprintf("%.3f%%", r.SLI*100)
+		}
+		alert := r.AlertState
+		if alert == "" {
+			alert = "ok"
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(r.ID, 10),
+			r.Alias,
+			r.Describe(),
+			sli,
+			fmt.Sprintf("%.0f%%", r.BudgetRemaining*100),
+			fmt.Sprintf("%.1fx", r.BurnRates["1h"]),
+			fmt.Sprintf("%.1fx", r.BurnRates["6h"]),
+			alert,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("SLOs").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleSLODelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: SLO ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt slo delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid SLO ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	sloRequest("DELETE", "/"+args[0], nil, &result)
+	fmt.Printf("SLO %s deleted\n", args[0])
+}
+
+// sloRequest calls the peer's /api/slos endpoint and decodes the response
+func sloRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/slos"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/throttle"
+)
+
+func handleThrottleCommand(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			args = args[1:]
+		case "set":
+			handleThrottleSet(args[1:])
+			return
+		case "clear":
+			handleThrottleClear(args[1:])
+			return
+		case "override":
+			handleThrottleOverride(args[1:])
+			return
+		case "--help", "-h", "help":
+			showCommandHelp("throttle", printThrottleUsage)
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown throttle command: %s\n\n", args[0])
+			printThrottleUsage()
+			os.Exit(1)
+		}
+	}
+
+	var result struct {
+		Data struct {
+			Budgets []throttle.Status `json:"budgets"`
+		} `json:"data"`
+	}
+	throttleRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"App", "State", "CPU", "Egress", "Stored", "Override", "Reason"},
+		Rows:    [][]string{},
+	}
+	for _, s := range result.Data.Budgets {
+		state := "normal"
+		if s.Degraded {
+			state = "degraded"
+		}
+		table.Rows = append(table.Rows, []string{
+			s.App,
+			state,
+			budgetCell(fmt.Sprintf("%.1fs", float64(s.Today.CPUMs)/1000), s.CPUMs, fmt.Sprintf("%.1fs", float64(s.CPUMs)/1000)),
+			budgetCell(formatBytes(s.Today.EgressBytes), s.EgressBytes, formatBytes(s.EgressBytes)),
+			budgetCell(formatBytes(s.Today.StorageBytes), s.StorageBytes, formatBytes(s.StorageBytes)),
+			s.Override,
+			s.Reason,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("App Budgets (today)").
+		Table(table).
+		String(), result.Data)
+}
+
+// budgetCell shows today's use against the limit, or just the use when
+// there is no limit
+func budgetCell(used string, limit int64, limitStr string) string {
+	if limit == 0 {
+		return used
+	}
+	return used + " / " + limitStr
+}
+
+func printThrottleUsage() {
+	fmt.Println("fazt throttle - Daily resource budgets and degraded mode")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] throttle [list]")
+	fmt.Println("  fazt [@peer] throttle set <app> [--cpu <d>] [--egress <size>] [--storage <size>]")
+	fmt.Println("  fazt [@peer] throttle clear <app>")
+	fmt.Println("  fazt [@peer] throttle override <app> <on|off|auto>")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  list                        Budgets, today's usage and state (default)")
+	fmt.Println("  set <app>                   Set an app's daily budget")
+	fmt.Println("  clear <app>                 Remove an app's budget and override")
+	fmt.Println("  override <app> <mode>       on: force degraded, off: never degrade, auto: follow budget")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --cpu <duration>            Daily handler time, e.g. 10m")
+	fmt.Println("  --egress <size>             Daily fazt.net.fetch bytes, e.g. 500MB")
+	fmt.Println("  --storage <size>            Daily bytes written, e.g. 1GB")
+	fmt.Println()
+	fmt.Println("An app over budget runs one job and two requests at a time, is limited to a")
+	fmt.Println("few requests per second and only gets cached fetches, until the next UTC day.")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt throttle")
+	fmt.Println("  fazt @zyt throttle set app_7f3k9x2m --cpu 10m --egress 500MB")
+	fmt.Println("  fazt @zyt throttle override app_7f3k9x2m off")
+}
+
+func handleThrottleSet(args []string) {
+	fs := flag.NewFlagSet("throttle set", flag.ExitOnError)
+	cpuFlag := fs.Duration("cpu", 0, "Daily handler time")
+	egressFlag := fs.String("egress", "", "Daily egress bytes")
+	storageFlag := fs.String("storage", "", "Daily bytes written")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: app required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt throttle set <app> [--cpu <d>] [--egress <size>] [--storage <size>]")
+		os.Exit(1)
+	}
+	app := args[0]
+	fs.Parse(args[1:])
+
+	egressBytes, err := parseByteSize(*egressFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --egress: %v\n", err)
+		os.Exit(1)
+	}
+	storageBytes, err := parseByteSize(*storageFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data throttle.Budget `json:"data"`
+	}
+	throttleRequest("PUT", "/"+app, map[string]int64{
+		"cpu_ms":        cpuFlag.Milliseconds(),
+		"egress_bytes":  egressBytes,
+		"storage_bytes": storageBytes,
+	}, &result)
+
+	fmt.Printf("Budget set for %s\n", result.Data.App)
+}
+
+func handleThrottleClear(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: app required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt throttle clear <app>")
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	throttleRequest("DELETE", "/"+args[0], nil, &result)
+	fmt.Printf("Budget removed for %s\n", args[0])
+}
+
+func handleThrottleOverride(args []string) {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Error: app and mode required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt throttle override <app> <on|off|auto>")
+		os.Exit(1)
+	}
+
+	var result struct {
+		Data throttle.Budget `json:"data"`
+	}
+	throttleRequest("POST", "/"+args[0]+"/override", map[string]string{"mode": args[1]}, &result)
+	fmt.Printf("Override for %s set to %s; applied within a minute\n", result.Data.App, result.Data.Override)
+}
+
+// parseByteSize parses sizes like 500MB or 1GB; plain numbers are bytes
+func parseByteSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix))
+			mult = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// throttleRequest calls the peer's /api/system/throttle endpoint and decodes the response
+func throttleRequest(method, path string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, _ := http.NewRequest(method, peer.URL+"/api/system/throttle"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fmt.Printf("Error: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+)
+
+func handleTokenCommand(args []string) {
+	if len(args) < 1 {
+		showCommandHelp("token", printTokenUsage)
+		return
+	}
+
+	switch args[0] {
+	case "create":
+		handleTokenCreate(args[1:])
+	case "list":
+		handleTokenList(args[1:])
+	case "revoke":
+		handleTokenRevoke(args[1:])
+	case "--help", "-h", "help":
+		showCommandHelp("token", printTokenUsage)
+	default:
+		fmt.Printf("Unknown token subcommand: %s\n", args[0])
+		printTokenUsage()
+		os.Exit(1)
+	}
+}
+
+func printTokenUsage() {
+	fmt.Println("fazt token - API token management")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] token <command> [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  create --name <name>    Create an API token")
+	fmt.Println("  list                    List API tokens")
+	fmt.Println("  revoke <id>             Revoke an API token")
+	fmt.Println()
+	fmt.Println("OPTIONS (create):")
+	fmt.Println("  --scope <scope>         admin (default), deploy, or read")
+	fmt.Println("  --app <app>             Limit the token to one app")
+	fmt.Println("  --expires <duration>    Lifetime, e.g. 30d, 12h (default: never)")
+	fmt.Println("  --user <email>          Limit the token to apps shared with this user")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt token create --name ci --scope deploy --app myapp --expires 30d")
+	fmt.Println("  fazt @zyt token list")
+	fmt.Println("  fazt @zyt token revoke 3")
+}
+
+func handleTokenCreate(args []string) {
+	fs := flag.NewFlagSet("token create", flag.ExitOnError)
+	nameFlag := fs.String("name", "", "Token name")
+	scopeFlag := fs.String("scope", hosting.ScopeAdmin, "Scope: admin, deploy, or read")
+	appFlag := fs.String("app", "", "Limit the token to one app (ID, alias, or title)")
+	expiresFlag := fs.String("expires", "", "Lifetime, e.g. 30d or 12h")
+	userFlag := fs.String("user", "", "Limit the token to apps shared with this user (email)")
+	fs.Parse(args)
+
+	if !hosting.IsValidScope(*scopeFlag) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", hosting.ErrInvalidScope)
+		os.Exit(1)
+	}
+	if *expiresFlag != "" {
+		if _, err := hosting.ParseExpiry(*expiresFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	name := *nameFlag
+	if name == "" {
+		name = *scopeFlag
+		if *appFlag != "" {
+			name += "-" + *appFlag
+		}
+	}
+
+	body := map[string]string{
+		"name":    name,
+		"scopes":  *scopeFlag,
+		"app":     *appFlag,
+		"expires": *expiresFlag,
+		"user":    *userFlag,
+	}
+
+	var result struct {
+		Data struct {
+			Token     string     `json:"token"`
+			Name      string     `json:"name"`
+			Scopes    string     `json:"scopes"`
+			AppID     string     `json:"app_id"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"data"`
+	}
+	tokenRequest("POST", "", body, &result)
+
+	fmt.Println("API token created!")
+	fmt.Println()
+	fmt.Printf("  Name:    %s\n", result.Data.Name)
+	fmt.Printf("  Scope:   %s\n", result.Data.Scopes)
+	if result.Data.AppID != "" {
+		fmt.Printf("  App:     %s\n", result.Data.AppID)
+	}
+	if result.Data.ExpiresAt != nil {
+		fmt.Printf("  Expires: %s\n", result.Data.ExpiresAt.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("  Token:   %s\n", result.Data.Token)
+	fmt.Println()
+	fmt.Println("Save this token - it won't be shown again!")
+}
+
+func handleTokenList(args []string) {
+	fs := flag.NewFlagSet("token list", flag.ExitOnError)
+	allFlag := fs.Bool("all", false, "Include revoked and expired tokens")
+	fs.Parse(args)
+
+	var result struct {
+		Data struct {
+			Keys []hosting.APIKeyInfo `json:"keys"`
+		} `json:"data"`
+	}
+	tokenRequest("GET", "", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "Name", "Scope", "App", "Expires", "Last Used", "Status"},
+		Rows:    [][]string{},
+	}
+	now := time.Now()
+	for _, k := range result.Data.Keys {
+		status := "active"
+		switch {
+		case k.RevokedAt != nil:
+			status = "revoked"
+		case k.ExpiresAt != nil && now.After(*k.ExpiresAt):
+			status = "expired"
+		}
+		if status != "active" && !*allFlag {
+			continue
+		}
+
+		app := k.AppTitle
+		if app == "" {
+			app = k.AppID
+		}
+		if app == "" {
+			app = "-"
+		}
+		table.Rows = append(table.Rows, []string{
+			strconv.FormatInt(k.ID, 10),
+			k.Name,
+			k.Scopes,
+			app,
+			formatTokenTime(k.ExpiresAt, "never"),
+			formatTokenTime(k.LastUsedAt, "never"),
+			status,
+		})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("API Tokens").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleTokenRevoke(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: token ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt token revoke <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid token ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	tokenRequest("DELETE", "?id="+args[0], nil, &result)
+	fmt.Printf("Token %s revoked\n", args[0])
+}
+
+// tokenRequest calls the peer's /api/keys endpoint and decodes the response
+func tokenRequest(method, query string, body interface{}, out interface{}) {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	httpClient := &http.Client{}
+	req, _ := http.NewRequest(method, peer.URL+"/api/keys"+query, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		fmt.Printf("Error: %s\n", string(bodyBytes))
+		os.Exit(1)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func formatTokenTime(t *time.Time, empty string) string {
+	if t == nil {
+		return empty
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/output"
+	"github.com/fazt-sh/fazt/internal/remote"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+func handleUsageCommand(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			handleUsageExport(args[1:])
+			return
+		case "hooks":
+			handleUsageHooks(args[1:])
+			return
+		case "runtime":
+			handleUsageRuntime(args[1:])
+			return
+		case "--help", "-h", "help":
+			showCommandHelp("usage", printUsageCommandUsage)
+			return
+		}
+	}
+
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	daysFlag := fs.Int("days", 7, "Days to include, including today (max 90)")
+	appFlag := fs.String("app", "", "Show one app's daily breakdown")
+	fs.Usage = printUsageCommandUsage
+	fs.Parse(args)
+
+	if *daysFlag < 1 || *daysFlag > 90 {
+		fmt.Fprintln(os.Stderr, "Error: --days must be between 1 and 90")
+		os.Exit(1)
+	}
+
+	query := url.Values{"days": {strconv.Itoa(*daysFlag)}}
+	if *appFlag != "" {
+		query.Set("app", *appFlag)
+		handleUsageDaily(query)
+		return
+	}
+
+	var result struct {
+		Data struct {
+			Days int              `json:"days"`
+			Apps []usage.AppUsage `json:"apps"`
+		} `json:"data"`
+	}
+	usageRequest("GET", "?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: usageHeaders("App"),
+		Rows:    [][]string{},
+	}
+	for _, u := range result.Data.Apps {
+		name := u.App
+		if u.Name != "" && u.Name != u.App {
+			name = fmt.Sprintf("%s (%s)", u.Name, u.App)
+		}
+		table.Rows = append(table.Rows, usageRow(name, u.Cost))
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Usage (last %d days)", result.Data.Days)).
+		Table(table).
+		String(), result.Data)
+}
+
+func handleUsageDaily(query url.Values) {
+	var result struct {
+		Data struct {
+			App   string           `json:"app"`
+			Days  int              `json:"days"`
+			Daily []usage.DayUsage `json:"daily"`
+		} `json:"data"`
+	}
+	usageRequest("GET", "?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: usageHeaders("Day"),
+		Rows:    [][]string{},
+	}
+	for _, u := range result.Data.Daily {
+		table.Rows = append(table.Rows, usageRow(u.Day, u.Cost))
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Usage for %s (last %d days)", result.Data.App, result.Data.Days)).
+		Table(table).
+		String(), result.Data)
+}
+
+func usageHeaders(first string) []string {
+	return []string{first, "Requests", "CPU", "DB Reads", "DB Writes", "Stored", "Egress", "Served"}
+}
+
+func usageRow(label string, c usage.Cost) []string {
+	return []string{
+		label,
+		strconv.FormatInt(c.Requests, 10),
+		fmt.Sprintf("%.1fs", float64(c.CPUMs)/1000),
+		strconv.FormatInt(c.DBReads, 10),
+		strconv.FormatInt(c.DBWrites, 10),
+		formatBytes(c.StorageBytes),
+		formatBytes(c.EgressBytes),
+		formatBytes(c.ResponseBytes),
+	}
+}
+
+func printUsageCommandUsage() {
+	fmt.Println("fazt usage - Approximate cost per app")
+	fmt.Println()
+	fmt.Println("USAGE:")
+	fmt.Println("  fazt [@peer] usage [options]")
+	fmt.Println("  fazt [@peer] usage export [options]")
+	fmt.Println("  fazt [@peer] usage hooks [add <url> | delete <id>]")
+	fmt.Println("  fazt [@peer] usage runtime [options]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("  export                      Per-user usage for a period, for billing")
+	fmt.Println("  hooks                       List hooks that post each period's export")
+	fmt.Println("  hooks add <url>             Post each completed period to an endpoint")
+	fmt.Println("  hooks delete <id>           Remove a hook")
+	fmt.Println("  runtime                     Handlers using the most CPU and memory")
+	fmt.Println()
+	fmt.Println("OPTIONS:")
+	fmt.Println("  --days <n>                  Days to include, including today (default 7, max 90)")
+	fmt.Println("  --app <id>                  Show one app's daily breakdown")
+	fmt.Println("  --month <YYYY-MM>           Export a calendar month (default: this month)")
+	fmt.Println("  --from, --to <YYYY-MM-DD>   Export a range of days instead")
+	fmt.Println("  --format <csv|json>         Export format (default csv)")
+	fmt.Println("  --period <day|week|month>   How often a hook is sent (default month)")
+	fmt.Println("  --secret <s>                Hook signing secret (default: generated)")
+	fmt.Println("  --sort <order>              Runtime order: cpu, max_cpu, alloc, killed (default cpu)")
+	fmt.Println("  --limit <n>                 Runtime handlers to show (default 20)")
+	fmt.Println()
+	fmt.Println("EXAMPLES:")
+	fmt.Println("  fazt @zyt usage")
+	fmt.Println("  fazt @zyt usage --days 30")
+	fmt.Println("  fazt @zyt usage --app app_7f3k9x2m --days 14")
+	fmt.Println("  fazt @zyt usage runtime --sort max_cpu")
+	fmt.Println("  fazt @zyt usage export --month 2026-09 > usage-2026-09.csv")
+	fmt.Println("  fazt @zyt usage hooks add https://billing.example.com/fazt --period month")
+}
+
+func handleUsageRuntime(args []string) {
+	fs := flag.NewFlagSet("usage runtime", flag.ExitOnError)
+	daysFlag := fs.Int("days", 7, "Days to include, including today (max 90)")
+	sortFlag := fs.String("sort", "cpu", "cpu, max_cpu, alloc or killed")
+	appFlag := fs.String("app", "", "Only this app's handlers")
+	limitFlag := fs.Int("limit", 20, "Handlers to show")
+	fs.Usage = printUsageCommandUsage
+	fs.Parse(args)
+
+	if *daysFlag < 1 || *daysFlag > 90 {
+		fmt.Fprintln(os.Stderr, "Error: --days must be between 1 and 90")
+		os.Exit(1)
+	}
+
+	query := url.Values{
+		"days":  {strconv.Itoa(*daysFlag)},
+		"sort":  {*sortFlag},
+		"limit": {strconv.Itoa(*limitFlag)},
+	}
+	if *appFlag != "" {
+		query.Set("app", *appFlag)
+	}
+
+	var result struct {
+		Data struct {
+			Days    int                `json:"days"`
+			Sort    string             `json:"sort"`
+			Entries []usage.EntryUsage `json:"entries"`
+			Limits  struct {
+				CPUMs       int   `json:"cpu_ms"`
+				MemoryBytes int64 `json:"memory_bytes"`
+			} `json:"limits"`
+		} `json:"data"`
+	}
+	usageRequest("GET", "/runtime?"+query.Encode(), nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"App", "Entry", "Runs", "CPU", "Avg", "Max CPU", "Alloc", "Max Alloc", "Killed"},
+		Rows:    [][]string{},
+	}
+	for _, u := range result.Data.Entries {
+		name := u.App
+		if u.Name != "" && u.Name != u.App {
+			name = fmt.Sprintf("%s (%s)", u.Name, u.App)
+		}
+		table.Rows = append(table.Rows, []string{
+			name,
+			u.Entry,
+			strconv.FormatInt(u.Executions, 10),
+			fmt.Sprintf("%.1fs", float64(u.CPUMs)/1000),
+			fmt.Sprintf("%dms", u.AvgCPUMs),
+			fmt.Sprintf("%dms", u.MaxCPUMs),
+			formatBytes(u.AllocBytes),
+			formatBytes(u.MaxAllocBytes),
+			strconv.FormatInt(u.Killed, 10),
+		})
+	}
+
+	limits := result.Data.Limits
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1(fmt.Sprintf("Runtime usage by %s (last %d days)", result.Data.Sort, result.Data.Days)).
+		Table(table).
+		Para(fmt.Sprintf("Each execution is stopped after %dms of CPU time or %s of memory.",
+			limits.CPUMs, formatBytes(limits.MemoryBytes))).
+		String(), result.Data)
+}
+
+func handleUsageExport(args []string) {
+	fs := flag.NewFlagSet("usage export", flag.ExitOnError)
+	monthFlag := fs.String("month", "", "Calendar month, YYYY-MM")
+	fromFlag := fs.String("from", "", "First day, YYYY-MM-DD")
+	toFlag := fs.String("to", "", "Last day, YYYY-MM-DD")
+	formatFlag := fs.String("format", "csv", "csv or json")
+	fs.Parse(args)
+
+	if *formatFlag != "csv" && *formatFlag != "json" {
+		fmt.Fprintln(os.Stderr, "Error: --format must be csv or json")
+		os.Exit(1)
+	}
+
+	query := url.Values{"format": {*formatFlag}}
+	for key, value := range map[string]string{"month": *monthFlag, "from": *fromFlag, "to": *toFlag} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	body := usageRaw("GET", "/export?"+query.Encode(), nil)
+	if *formatFlag == "json" {
+		var result struct {
+			Data json.RawMessage `json:"data"`
+		}
+		json.Unmarshal(body, &result)
+		var out bytes.Buffer
+		json.Indent(&out, result.Data, "", "  ")
+		body = append(out.Bytes(), '\n')
+	}
+	os.Stdout.Write(body)
+}
+
+func handleUsageHooks(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			handleUsageHookAdd(args[1:])
+			return
+		case "delete":
+			handleUsageHookDelete(args[1:])
+			return
+		}
+	}
+
+	var result struct {
+		Data struct {
+			Hooks []usage.Hook `json:"hooks"`
+		} `json:"data"`
+	}
+	usageRequest("GET", "/hooks", nil, &result)
+
+	table := &output.Table{
+		Headers: []string{"ID", "URL", "Period", "Delivered Through", "Last Error"},
+		Rows:    [][]string{},
+	}
+	for _, h := range result.Data.Hooks {
+		delivered := h.DeliveredThrough
+		if delivered == "" {
+			delivered = "-"
+		}
+		table.Rows = append(table.Rows, []string{strconv.FormatInt(h.ID, 10), h.URL, h.Period, delivered, h.LastError})
+	}
+
+	renderer := getRenderer()
+	renderer.Print(output.NewMarkdown().
+		H1("Usage Hooks").
+		Table(table).
+		String(), result.Data)
+}
+
+func handleUsageHookAdd(args []string) {
+	fs := flag.NewFlagSet("usage hooks add", flag.ExitOnError)
+	periodFlag := fs.String("period", "month", "day, week or month")
+	secretFlag := fs.String("secret", "", "Signing secret")
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Error: URL required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt usage hooks add <url> [--period day|week|month] [--secret <s>]")
+		os.Exit(1)
+	}
+	hookURL := args[0]
+	fs.Parse(args[1:])
+
+	var result struct {
+		Data usage.Hook `json:"data"`
+	}
+	usageRequest("POST", "/hooks", map[string]string{
+		"url":    hookURL,
+		"period": *periodFlag,
+		"secret": *secretFlag,
+	}, &result)
+
+	fmt.Printf("Hook %d added: %s receives usage every %s\n", result.Data.ID, result.Data.URL, result.Data.Period)
+	fmt.Printf("Signing secret: %s\n", result.Data.Secret)
+	fmt.Println("Deliveries carry an X-Webhook-Signature header: hex HMAC-SHA256 of the body.")
+}
+
+func handleUsageHookDelete(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintln(os.Stderr, "Error: hook ID required")
+		fmt.Fprintln(os.Stderr, "Usage: fazt usage hooks delete <id>")
+		os.Exit(1)
+	}
+	if _, err := strconv.ParseInt(args[0], 10, 64); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid hook ID %q\n", args[0])
+		os.Exit(1)
+	}
+
+	var result map[string]interface{}
+	usageRequest("DELETE", "/hooks/"+args[0], nil, &result)
+	fmt.Printf("Hook %s deleted\n", args[0])
+}
+
+// usageRequest calls the peer's /api/system/usage endpoint and decodes the response
+func usageRequest(method, path string, body interface{}, out interface{}) {
+	if err := json.Unmarshal(usageRaw(method, path, body), out); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// usageRaw calls the peer's /api/system/usage endpoint and returns the body
+func usageRaw(method, path string, body interface{}) []byte {
+	db := getClientDB()
+	defer database.Close()
+
+	peer, err := remote.ResolvePeer(db, targetPeerName)
+	if err != nil {
+		handlePeerError(err)
+		os.Exit(1)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		jsonBody, _ := json.Marshal(body)
+		reqBody = bytes.NewBuffer(jsonBody)
+	}
+
+	req, _ := http.NewRequest(method, peer.URL+"/api/system/usage"+path, reqBody)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+peer.Token)
+
+	resp, err := (&http.Client{Timeout: time.Minute}).Do(req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		fmt.Printf("Error: %s\n", string(respBody))
+		os.Exit(1)
+	}
+	return respBody
+}
@@ -3,6 +3,7 @@ module github.com/fazt-sh/fazt
 go 1.24.7
 
 require (
+	github.com/andybalholm/brotli v1.2.0
 	github.com/caddyserver/certmagic v0.25.0
 	github.com/charmbracelet/glamour v0.10.0
 	github.com/dop251/goja v0.0.0-20260106131823-651366fbe6e3
@@ -10,8 +11,10 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
 	github.com/sabhiram/go-gitignore v0.0.0-20210923224102-525f6e181f06
+	github.com/tetratelabs/wazero v1.9.0
 	github.com/valyala/tcplisten v1.0.0
 	golang.org/x/crypto v0.46.0
+	golang.org/x/image v0.35.0
 	golang.org/x/time v0.14.0
 	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.40.1
@@ -69,7 +72,6 @@ require (
 	go.uber.org/zap v1.27.0 // indirect
 	go.uber.org/zap/exp v0.3.0 // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
-	golang.org/x/image v0.35.0 // indirect
 	golang.org/x/mod v0.31.0 // indirect
 	golang.org/x/net v0.48.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
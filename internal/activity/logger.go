@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/realip"
 	"github.com/fazt-sh/fazt/internal/storage"
 	"github.com/fazt-sh/fazt/internal/system"
 )
@@ -184,7 +184,7 @@ func LogFromRequest(r *http.Request, userID, resourceType, resourceID, action st
 	Log(Entry{
 		ActorType:    actorType,
 		ActorID:      actorID,
-		ActorIP:      ExtractIP(r),
+		ActorIP:      realip.FromRequest(r),
 		ActorUA:      r.UserAgent(),
 		ResourceType: resourceType,
 		ResourceID:   resourceID,
@@ -195,32 +195,6 @@ func LogFromRequest(r *http.Request, userID, resourceType, resourceID, action st
 	})
 }
 
-// ExtractIP gets the client's IP address from the request
-func ExtractIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-
-	return ip
-}
-
 // GetBufferStats returns the current buffer statistics
 func GetBufferStats() (queued int, batchSize int) {
 	if globalLogger == nil {
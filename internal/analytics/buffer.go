@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fazt-sh/fazt/internal/database"
@@ -22,7 +23,25 @@ type Event struct {
 	UserAgent   string
 	IPAddress   string
 	QueryParams string
-	CreatedAt   time.Time
+	// Screen size reported by the tracking snippet (0 if unknown)
+	ScreenWidth  int
+	ScreenHeight int
+	// Derived from screen size and user agent when not set
+	Device  string
+	Browser string
+	// Located from IPAddress at flush time when a GeoIP database is set
+	Country string
+	Region  string
+	// utm_* parameters of the visit, and the kind of referrer it came from
+	// (derived from Referrer and Domain when not set)
+	Campaign     Campaign
+	ReferrerType string
+	CreatedAt    time.Time
+}
+
+// SplitTag is the event tag attributing traffic to a split alias variant
+func SplitTag(subdomain string) string {
+	return "split:" + subdomain
 }
 
 // Config holds the buffer configuration
@@ -30,6 +49,9 @@ type Config struct {
 	FlushInterval time.Duration
 	BatchSize     int
 	MaxRetries    int
+	// MaxQueued bounds the events held in memory; beyond it new events are
+	// dropped, so a stalled database costs at most this many pageviews
+	MaxQueued int
 }
 
 // DefaultConfig returns safe defaults
@@ -38,17 +60,29 @@ func DefaultConfig() Config {
 		FlushInterval: 30 * time.Second,
 		BatchSize:     1000,
 		MaxRetries:    1,
+		MaxQueued:     10000,
 	}
 }
 
-// Buffer aggregates events and flushes them to the DB
+// retryDelay is the pause before re-attempting a failed batch
+var retryDelay = time.Second
+
+// Buffer aggregates events and flushes them to the DB. Add never touches the
+// database: a single background flusher writes batches on a timer or as soon
+// as a batch fills up.
 type Buffer struct {
 	mu         sync.Mutex
 	events     []Event
 	config     Config
 	stopChan   chan struct{}
+	flushChan  chan struct{} // signals the flusher that a batch is full
+	flushMu    sync.Mutex    // serializes flushes
 	wg         sync.WaitGroup
 	isShutdown bool
+
+	flushed atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
 }
 
 var (
@@ -60,17 +94,22 @@ var (
 // Init initializes the global analytics buffer
 func Init() {
 	initOnce.Do(func() {
-		globalBuffer = &Buffer{
-			events:   make([]Event, 0, DefaultConfig().BatchSize),
-			config:   DefaultConfig(),
-			stopChan: make(chan struct{}),
-		}
+		globalBuffer = newBuffer(DefaultConfig())
 		globalBuffer.startFlusher()
 		log.Println("Analytics: Write buffer initialized")
 	})
 }
 
-// Add queues an event for writing
+func newBuffer(cfg Config) *Buffer {
+	return &Buffer{
+		events:    make([]Event, 0, cfg.BatchSize),
+		config:    cfg,
+		stopChan:  make(chan struct{}),
+		flushChan: make(chan struct{}, 1),
+	}
+}
+
+// Add queues an event for writing. It never blocks on the database.
 func Add(e Event) {
 	if globalBuffer == nil {
 		// Fallback if not initialized (shouldn't happen in prod)
@@ -82,26 +121,50 @@ func Add(e Event) {
 	if e.CreatedAt.IsZero() {
 		e.CreatedAt = time.Now()
 	}
+	if e.Device == "" {
+		e.Device = DeviceClass(e.ScreenWidth, e.UserAgent)
+	}
+	if e.Browser == "" {
+		e.Browser = BrowserFamily(e.UserAgent)
+	}
+	if e.ReferrerType == "" {
+		e.ReferrerType = ClassifyReferrer(e.Referrer, e.Domain)
+	}
 
-	globalBuffer.mu.Lock()
-	defer globalBuffer.mu.Unlock()
+	globalBuffer.add(e)
+}
 
-	if globalBuffer.isShutdown {
+func (b *Buffer) add(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.isShutdown {
+		return
+	}
+	if len(b.events) >= b.config.MaxQueued {
+		b.dropped.Add(1)
 		return
 	}
 
-	globalBuffer.events = append(globalBuffer.events, e)
+	b.events = append(b.events, e)
 
-	// Trigger flush if batch size reached
-	if len(globalBuffer.events) >= globalBuffer.config.BatchSize {
-		go globalBuffer.flush()
+	// Wake the flusher if batch size reached
+	if len(b.events) >= b.config.BatchSize {
+		select {
+		case b.flushChan <- struct{}{}:
+		default:
+		}
 	}
 }
 
 // BufferStats holds current buffer metrics
 type BufferStats struct {
-	EventsQueued int
-	BatchSize    int
+	EventsQueued  int
+	BatchSize     int
+	MaxQueued     int
+	EventsFlushed int64
+	EventsDropped int64 // over MaxQueued, or failed after retries
+	FailedFlushes int64
 }
 
 // GetStats returns the current buffer statistics
@@ -112,8 +175,12 @@ func GetStats() BufferStats {
 	globalBuffer.mu.Lock()
 	defer globalBuffer.mu.Unlock()
 	return BufferStats{
-		EventsQueued: len(globalBuffer.events),
-		BatchSize:    globalBuffer.config.BatchSize,
+		EventsQueued:  len(globalBuffer.events),
+		BatchSize:     globalBuffer.config.BatchSize,
+		MaxQueued:     globalBuffer.config.MaxQueued,
+		EventsFlushed: globalBuffer.flushed.Load(),
+		EventsDropped: globalBuffer.dropped.Load(),
+		FailedFlushes: globalBuffer.failed.Load(),
 	}
 }
 
@@ -150,6 +217,8 @@ func (b *Buffer) startFlusher() {
 			select {
 			case <-ticker.C:
 				b.flush()
+			case <-b.flushChan:
+				b.flush()
 			case <-b.stopChan:
 				return
 			}
@@ -157,8 +226,12 @@ func (b *Buffer) startFlusher() {
 	}()
 }
 
-// flush writes the current buffer to the database
+// flush writes the current buffer to the database, retrying a failed batch
+// up to MaxRetries times before dropping it
 func (b *Buffer) flush() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
 	b.mu.Lock()
 	if len(b.events) == 0 {
 		b.mu.Unlock()
@@ -170,14 +243,28 @@ func (b *Buffer) flush() {
 	b.events = make([]Event, 0, b.config.BatchSize)
 	b.mu.Unlock()
 
-	if err := b.writeBatch(batch); err != nil {
-		log.Printf("Analytics Error: Failed to flush batch of %d events: %v", len(batch), err)
-		// Note: In a production system, we might retry or persist to disk.
-		// For now, per plan, we drop if failing to preserve system stability.
-	} else {
-		if len(batch) > 100 {
-			log.Printf("Analytics: Flushed %d events", len(batch))
+	var err error
+	for attempt := 0; attempt <= b.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryDelay)
 		}
+		if err = b.writeBatch(batch); err == nil {
+			break
+		}
+		b.failed.Add(1)
+	}
+
+	if err != nil {
+		// Drop rather than hold on: memory stays bounded and a broken
+		// database can't back up into page serving
+		b.dropped.Add(int64(len(batch)))
+		log.Printf("Analytics Error: Dropped batch of %d events: %v", len(batch), err)
+		return
+	}
+
+	b.flushed.Add(int64(len(batch)))
+	if len(batch) > 100 {
+		log.Printf("Analytics: Flushed %d events", len(batch))
 	}
 }
 
@@ -187,6 +274,8 @@ func (b *Buffer) writeBatch(batch []Event) error {
 		return fmt.Errorf("database not available")
 	}
 
+	locateEvents(batch)
+
 	// Route through WriteQueue to prevent SQLITE_BUSY errors
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -199,16 +288,26 @@ func (b *Buffer) writeBatch(batch []Event) error {
 		defer tx.Rollback()
 
 		stmt, err := tx.Prepare(`
-			INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, query_params, created_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, query_params,
+				screen_width, screen_height, device, browser, visitor_id, country, region,
+				utm_source, utm_medium, utm_campaign, referrer_type, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`)
 		if err != nil {
 			return err
 		}
 		defer stmt.Close()
 
+		salts := map[string][]byte{}
 		for _, e := range batch {
-			_, err := stmt.Exec(
+			visitor, err := visitorID(tx, salts, e)
+			if err != nil {
+				return err
+			}
+			if dropIP.Load() {
+				e.IPAddress = ""
+			}
+			_, err = stmt.Exec(
 				e.Domain,
 				e.Tags,
 				e.SourceType,
@@ -218,7 +317,20 @@ func (b *Buffer) writeBatch(batch []Event) error {
 				e.UserAgent,
 				e.IPAddress,
 				e.QueryParams,
-				e.CreatedAt,
+				nullIfZero(e.ScreenWidth),
+				nullIfZero(e.ScreenHeight),
+				nullIfEmpty(e.Device),
+				nullIfEmpty(e.Browser),
+				nullIfEmpty(visitor),
+				nullIfEmpty(e.Country),
+				nullIfEmpty(e.Region),
+				nullIfEmpty(e.Campaign.Source),
+				nullIfEmpty(e.Campaign.Medium),
+				nullIfEmpty(e.Campaign.Name),
+				nullIfEmpty(e.ReferrerType),
+				// In UTC and a format SQLite's date functions parse, which
+				// rollups depend on
+				e.CreatedAt.UTC().Format(eventFormat),
 			)
 			if err != nil {
 				return err
@@ -228,3 +340,17 @@ func (b *Buffer) writeBatch(batch []Event) error {
 		return tx.Commit()
 	})
 }
+
+func nullIfZero(n int) interface{} {
+	if n <= 0 {
+		return nil
+	}
+	return n
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -230,3 +230,58 @@ func TestConcurrentAdds(t *testing.T) {
 	// So we just verify no panic occurred and some events were queued
 	t.Logf("Events queued after concurrent adds: %d", stats.EventsQueued)
 }
+
+func TestAddDropsBeyondMaxQueued(t *testing.T) {
+	b := newBuffer(Config{FlushInterval: time.Hour, BatchSize: 100, MaxQueued: 3})
+
+	for i := 0; i < 5; i++ {
+		b.add(Event{Domain: "full.com", EventType: "pageview"})
+	}
+
+	if len(b.events) != 3 {
+		t.Errorf("Expected 3 queued events, got %d", len(b.events))
+	}
+	if got := b.dropped.Load(); got != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", got)
+	}
+}
+
+func TestAddSignalsFlusherWhenBatchFull(t *testing.T) {
+	b := newBuffer(Config{FlushInterval: time.Hour, BatchSize: 2, MaxQueued: 10})
+
+	b.add(Event{Domain: "batch.com"})
+	select {
+	case <-b.flushChan:
+		t.Fatal("Expected no flush signal before batch is full")
+	default:
+	}
+
+	b.add(Event{Domain: "batch.com"})
+	b.add(Event{Domain: "batch.com"})
+	select {
+	case <-b.flushChan:
+	default:
+		t.Fatal("Expected flush signal once batch is full")
+	}
+}
+
+func TestFlushDropsBatchAfterRetries(t *testing.T) {
+	database.SetDB(nil)
+	retryDelay = 0
+	defer func() { retryDelay = time.Second }()
+
+	b := newBuffer(Config{FlushInterval: time.Hour, BatchSize: 10, MaxRetries: 2, MaxQueued: 10})
+	b.add(Event{Domain: "down.com"})
+	b.add(Event{Domain: "down.com"})
+	b.flush()
+
+	if got := b.failed.Load(); got != 3 {
+		t.Errorf("Expected 3 failed attempts, got %d", got)
+	}
+	if got := b.dropped.Load(); got != 2 {
+		t.Errorf("Expected 2 dropped events, got %d", got)
+	}
+	if len(b.events) != 0 {
+		t.Errorf("Expected buffer emptied, got %d events", len(b.events))
+	}
+}
@@ -0,0 +1,121 @@
+package analytics
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Referrer types
+const (
+	ReferrerDirect   = "direct"   // No referrer: typed, bookmarked, or from an app
+	ReferrerInternal = "internal" // Another page of the same site
+	ReferrerSearch   = "search"
+	ReferrerSocial   = "social"
+	ReferrerOther    = "referral"
+)
+
+// maxUTMLength caps each stored utm_* value
+const maxUTMLength = 100
+
+// searchEngines are matched by the host's first label after www./m., on
+// any TLD (google.co.uk), so mail.google.com isn't search
+var searchEngines = map[string]bool{
+	"google": true, "bing": true, "duckduckgo": true, "yahoo": true,
+	"yandex": true, "baidu": true, "ecosia": true, "qwant": true,
+	"startpage": true, "kagi": true, "naver": true, "seznam": true,
+}
+
+// searchHosts are search engines on a subdomain, and apps whose referrer
+// is android-app://<package>
+var searchHosts = []string{
+	"search.yahoo.com", "search.brave.com", "com.google.android.googlequicksearchbox",
+}
+
+// socialHosts are matched with any subdomain (l.facebook.com, old.reddit.com)
+var socialHosts = []string{
+	"facebook.com", "fb.com", "fb.me", "instagram.com", "threads.net",
+	"twitter.com", "x.com", "t.co", "bsky.app", "mastodon.social",
+	"linkedin.com", "lnkd.in", "reddit.com", "news.ycombinator.com",
+	"youtube.com", "youtu.be", "tiktok.com", "pinterest.com", "tumblr.com",
+	"quora.com", "vk.com", "weibo.com", "t.me", "discord.com", "whatsapp.com",
+}
+
+// Campaign is the utm_* attribution of a visit
+type Campaign struct {
+	Source string // utm_source: google, newsletter
+	Medium string // utm_medium: cpc, email
+	Name   string // utm_campaign: spring_sale
+}
+
+// ParseCampaign reads utm_source, utm_medium and utm_campaign. Values are
+// lowercased so "Newsletter" and "newsletter" count together.
+func ParseCampaign(query url.Values) Campaign {
+	return Campaign{
+		Source: utmValue(query.Get("utm_source")),
+		Medium: utmValue(query.Get("utm_medium")),
+		Name:   utmValue(query.Get("utm_campaign")),
+	}
+}
+
+// CampaignFromMap is ParseCampaign for query parameters sent as a map by
+// the tracking script
+func CampaignFromMap(params map[string]string) Campaign {
+	return Campaign{
+		Source: utmValue(params["utm_source"]),
+		Medium: utmValue(params["utm_medium"]),
+		Name:   utmValue(params["utm_campaign"]),
+	}
+}
+
+// IsZero reports whether no utm_* parameter was set
+func (c Campaign) IsZero() bool {
+	return c == Campaign{}
+}
+
+func utmValue(v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if len(v) > maxUTMLength {
+		v = strings.ToValidUTF8(v[:maxUTMLength], "")
+	}
+	return v
+}
+
+// ClassifyReferrer returns the referrer type of a visit to site, which is a
+// hostname or a subdomain ("blog" for blog.example.com).
+func ClassifyReferrer(referrer, site string) string {
+	if referrer == "" {
+		return ReferrerDirect
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Hostname() == "" {
+		return ReferrerDirect
+	}
+	host := strings.ToLower(u.Hostname())
+	site = strings.ToLower(site)
+
+	if site != "" && (host == site || strings.HasSuffix(host, "."+site) ||
+		(!strings.Contains(site, ".") && strings.HasPrefix(host, site+"."))) {
+		return ReferrerInternal
+	}
+	if matchesHost(host, searchHosts) {
+		return ReferrerSearch
+	}
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(host, "www."), "m.")
+	if label, _, ok := strings.Cut(trimmed, "."); ok && searchEngines[label] {
+		return ReferrerSearch
+	}
+	if matchesHost(host, socialHosts) {
+		return ReferrerSocial
+	}
+	return ReferrerOther
+}
+
+// matchesHost reports whether host is one of hosts or a subdomain of one
+func matchesHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package analytics
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseCampaign(t *testing.T) {
+	query, _ := url.ParseQuery("utm_source=Newsletter&utm_medium=%20email%20&utm_campaign=spring_sale&ref=x")
+	want := Campaign{Source: "newsletter", Medium: "email", Name: "spring_sale"}
+	if got := ParseCampaign(query); got != want {
+		t.Errorf("ParseCampaign = %+v, want %+v", got, want)
+	}
+	if got := CampaignFromMap(map[string]string{"utm_source": "Newsletter", "utm_medium": "email", "utm_campaign": "spring_sale"}); got != want {
+		t.Errorf("CampaignFromMap = %+v, want %+v", got, want)
+	}
+
+	if c := ParseCampaign(url.Values{"page": {"2"}}); !c.IsZero() {
+		t.Errorf("Expected no campaign, got %+v", c)
+	}
+	if c := ParseCampaign(url.Values{"utm_campaign": {strings.Repeat("a", 500)}}); len(c.Name) != maxUTMLength {
+		t.Errorf("Expected campaign capped at %d, got %d", maxUTMLength, len(c.Name))
+	}
+}
+
+func TestClassifyReferrer(t *testing.T) {
+	tests := []struct {
+		referrer, site, want string
+	}{
+		{"", "blog", ReferrerDirect},
+		{"not a url", "blog", ReferrerDirect},
+		{"https://blog.example.com/posts", "blog", ReferrerInternal},
+		{"https://example.com/about", "example.com", ReferrerInternal},
+		{"https://www.example.com/about", "example.com", ReferrerInternal},
+		{"https://www.google.com/", "blog", ReferrerSearch},
+		{"https://www.google.co.uk/search?q=x", "blog", ReferrerSearch},
+		{"https://duckduckgo.com/", "blog", ReferrerSearch},
+		{"https://search.brave.com/search?q=x", "blog", ReferrerSearch},
+		{"android-app://com.google.android.googlequicksearchbox/", "blog", ReferrerSearch},
+		{"https://mail.google.com/", "blog", ReferrerOther},
+		{"https://l.facebook.com/l.php", "blog", ReferrerSocial},
+		{"https://t.co/abc", "blog", ReferrerSocial},
+		{"https://old.reddit.com/r/golang", "blog", ReferrerSocial},
+		{"https://news.ycombinator.com/item?id=1", "blog", ReferrerSocial},
+		{"https://dev.to/someone", "blog", ReferrerOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyReferrer(tt.referrer, tt.site); got != tt.want {
+			t.Errorf("ClassifyReferrer(%q, %q) = %q, want %q", tt.referrer, tt.site, got, tt.want)
+		}
+	}
+}
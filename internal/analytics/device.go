@@ -0,0 +1,100 @@
+package analytics
+
+import "strings"
+
+// Device classes
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+	DeviceBot     = "bot"
+)
+
+// Screen width breakpoints (CSS pixels) for device classes
+const (
+	tabletMinWidth  = 600
+	desktopMinWidth = 1024
+)
+
+// DeviceClass classifies a client by screen width, falling back to the
+// user agent when the width is unknown (pixel, redirect, server-side events)
+func DeviceClass(screenWidth int, ua string) string {
+	lower := strings.ToLower(ua)
+	if isBot(lower) {
+		return DeviceBot
+	}
+
+	if screenWidth > 0 {
+		switch {
+		case screenWidth < tabletMinWidth:
+			return DeviceMobile
+		case screenWidth < desktopMinWidth:
+			return DeviceTablet
+		default:
+			return DeviceDesktop
+		}
+	}
+
+	switch {
+	case ua == "":
+		return ""
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		return DeviceTablet
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
+
+// BrowserFamily returns the browser name for a user agent.
+// Order matters: Edge and Opera include "Chrome", Chrome includes "Safari".
+func BrowserFamily(ua string) string {
+	lower := strings.ToLower(ua)
+	switch {
+	case ua == "":
+		return ""
+	case isBot(lower):
+		return "Bot"
+	case strings.Contains(lower, "edg/") || strings.Contains(lower, "edge/"):
+		return "Edge"
+	case strings.Contains(lower, "opr/") || strings.Contains(lower, "opera"):
+		return "Opera"
+	case strings.Contains(lower, "samsungbrowser"):
+		return "Samsung Internet"
+	case strings.Contains(lower, "firefox") || strings.Contains(lower, "fxios"):
+		return "Firefox"
+	case strings.Contains(lower, "chrome") || strings.Contains(lower, "crios"):
+		return "Chrome"
+	case strings.Contains(lower, "safari"):
+		return "Safari"
+	case strings.HasPrefix(lower, "curl/") || strings.HasPrefix(lower, "wget/"):
+		return "CLI"
+	default:
+		return "Other"
+	}
+}
+
+func isBot(lowerUA string) bool {
+	for _, marker := range []string{"bot", "crawler", "spider", "slurp", "headless"} {
+		if strings.Contains(lowerUA, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// PathDepth returns the number of segments in a URL path ("/" is 0)
+func PathDepth(path string) int {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	depth := 0
+	for _, seg := range strings.Split(path, "/") {
+		if seg != "" {
+			depth++
+		}
+	}
+	return depth
+}
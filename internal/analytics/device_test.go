@@ -0,0 +1,70 @@
+package analytics
+
+import "testing"
+
+const (
+	uaIPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1"
+	uaIPad    = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/604.1"
+	uaChrome  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	uaEdge    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0"
+	uaFirefox = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	uaBot     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestDeviceClass(t *testing.T) {
+	tests := []struct {
+		name  string
+		width int
+		ua    string
+		want  string
+	}{
+		{"narrow screen", 390, uaChrome, DeviceMobile},
+		{"medium screen", 820, uaChrome, DeviceTablet},
+		{"wide screen", 1920, uaIPhone, DeviceDesktop},
+		{"iphone ua", 0, uaIPhone, DeviceMobile},
+		{"ipad ua", 0, uaIPad, DeviceTablet},
+		{"desktop ua", 0, uaFirefox, DeviceDesktop},
+		{"bot wins over width", 1920, uaBot, DeviceBot},
+		{"unknown", 0, "", ""},
+	}
+	for _, tt := range tests {
+		if got := DeviceClass(tt.width, tt.ua); got != tt.want {
+			t.Errorf("%s: DeviceClass(%d) = %q, want %q", tt.name, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestBrowserFamily(t *testing.T) {
+	tests := map[string]string{
+		uaIPhone:       "Safari",
+		uaChrome:       "Chrome",
+		uaEdge:         "Edge",
+		uaFirefox:      "Firefox",
+		uaBot:          "Bot",
+		"curl/8.4.0":   "CLI",
+		"":             "",
+		"SomeClient/1": "Other",
+	}
+	for ua, want := range tests {
+		if got := BrowserFamily(ua); got != want {
+			t.Errorf("BrowserFamily(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	tests := map[string]int{
+		"":               0,
+		"/":              0,
+		"/about":         1,
+		"/posts/hello":   2,
+		"/posts/hello/":  2,
+		"/a/b/c?x=/y/z":  3,
+		"/docs#/section": 1,
+	}
+	for path, want := range tests {
+		if got := PathDepth(path); got != want {
+			t.Errorf("PathDepth(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package analytics
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Geo is where an event came from: an ISO 3166-1 country code, and an
+// ISO 3166-2 region code like "US-CA" when the database has regions
+type Geo struct {
+	Country string
+	Region  string
+}
+
+// GeoIP looks IP addresses up in a MaxMind DB (MMDB) file such as
+// GeoLite2-Country or GeoLite2-City. The file is read into memory.
+type GeoIP struct {
+	buf        []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	data       mmdbDecoder // The data section, after the search tree
+	ipv4Start  uint        // Node reached by ::/96, where IPv4 lookups start in an IPv6 tree
+
+	mu    sync.Mutex
+	cache map[uint]Geo // By data offset: most addresses share a few records
+}
+
+// geoCacheSize bounds the records a GeoIP keeps decoded
+const geoCacheSize = 10000
+
+var (
+	// mmdbMetadataMarker precedes the metadata at the end of an MMDB file
+	mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+	errMMDBCorrupt     = errors.New("corrupt MaxMind DB")
+)
+
+// MMDB data types
+const (
+	mmdbExtended = 0
+	mmdbPointer  = 1
+	mmdbString   = 2
+	mmdbDouble   = 3
+	mmdbBytes    = 4
+	mmdbUint16   = 5
+	mmdbUint32   = 6
+	mmdbMap      = 7
+	mmdbInt32    = 8
+	mmdbUint64   = 9
+	mmdbUint128  = 10
+	mmdbArray    = 11
+	mmdbBool     = 14
+	mmdbFloat    = 15
+)
+
+var geoIP atomic.Pointer[GeoIP]
+
+// SetGeoIP sets the database events are located with at flush time; nil
+// stops locating them
+func SetGeoIP(g *GeoIP) {
+	geoIP.Store(g)
+}
+
+// OpenGeoIP reads a MaxMind DB file
+func OpenGeoIP(path string) (*GeoIP, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGeoIP(buf)
+}
+
+func parseGeoIP(buf []byte) (*GeoIP, error) {
+	tail := 128 * 1024
+	if len(buf) < tail {
+		tail = len(buf)
+	}
+	i := bytes.LastIndex(buf[len(buf)-tail:], mmdbMetadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB: no metadata")
+	}
+	metaStart := uint(len(buf)-tail+i) + uint(len(mmdbMetadataMarker))
+	meta, _, err := mmdbDecoder{buf: buf, base: metaStart}.decode(metaStart, 0)
+	if err != nil {
+		return nil, fmt.Errorf("reading MaxMind DB metadata: %w", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errMMDBCorrupt
+	}
+	metaUint := func(key string) uint {
+		n, _ := m[key].(uint64)
+		return uint(n)
+	}
+
+	g := &GeoIP{
+		buf:        buf,
+		nodeCount:  metaUint("node_count"),
+		recordSize: metaUint("record_size"),
+		ipVersion:  metaUint("ip_version"),
+		cache:      map[uint]Geo{},
+	}
+	if v := metaUint("binary_format_major_version"); v != 2 {
+		return nil, fmt.Errorf("unsupported MaxMind DB format version %d", v)
+	}
+	if g.recordSize != 24 && g.recordSize != 28 && g.recordSize != 32 {
+		return nil, fmt.Errorf("unsupported MaxMind DB record size %d", g.recordSize)
+	}
+	if g.ipVersion != 4 && g.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported MaxMind DB IP version %d", g.ipVersion)
+	}
+	treeSize := g.nodeCount * g.recordSize / 4
+	if treeSize+16 > metaStart {
+		return nil, errMMDBCorrupt
+	}
+	g.data = mmdbDecoder{buf: buf[:metaStart-uint(len(mmdbMetadataMarker))], base: treeSize + 16}
+
+	if g.ipVersion == 6 {
+		for i := 0; i < 96 && g.ipv4Start < g.nodeCount; i++ {
+			g.ipv4Start = g.record(g.ipv4Start, 0)
+		}
+	}
+	return g, nil
+}
+
+// record returns a node's left (bit 0) or right (bit 1) record
+func (g *GeoIP) record(node, bit uint) uint {
+	b := g.buf
+	switch g.recordSize {
+	case 24:
+		o := node*6 + bit*3
+		return uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+	case 28:
+		o := node * 7
+		if bit == 0 {
+			return uint(b[o+3]&0xf0)<<20 | uint(b[o])<<16 | uint(b[o+1])<<8 | uint(b[o+2])
+		}
+		return uint(b[o+3]&0x0f)<<24 | uint(b[o+4])<<16 | uint(b[o+5])<<8 | uint(b[o+6])
+	default:
+		o := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[o:]))
+	}
+}
+
+// Lookup returns where an IP address is from, or a zero Geo if it's
+// invalid, private or not in the database
+func (g *GeoIP) Lookup(ip string) Geo {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Geo{}
+	}
+	addr = addr.Unmap()
+
+	var bits []byte
+	node := uint(0)
+	if addr.Is4() {
+		b := addr.As4()
+		bits = b[:]
+		if g.ipVersion == 6 {
+			node = g.ipv4Start
+		}
+	} else {
+		if g.ipVersion == 4 {
+			return Geo{}
+		}
+		b := addr.As16()
+		bits = b[:]
+	}
+	for i := 0; i < len(bits)*8 && node < g.nodeCount; i++ {
+		node = g.record(node, uint(bits[i/8]>>(7-i%8))&1)
+	}
+	if node <= g.nodeCount {
+		// node_count itself means not found
+		return Geo{}
+	}
+
+	offset := g.data.base + node - g.nodeCount - 16
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if geo, ok := g.cache[offset]; ok {
+		return geo
+	}
+	v, _, err := g.data.decode(offset, 0)
+	if err != nil {
+		return Geo{}
+	}
+	geo := geoFromRecord(v)
+	if len(g.cache) >= geoCacheSize {
+		clear(g.cache)
+	}
+	g.cache[offset] = geo
+	return geo
+}
+
+// geoFromRecord reads the country and first subdivision of a GeoLite2 or
+// GeoIP2 record, falling back to the country the network is registered in
+func geoFromRecord(v interface{}) Geo {
+	rec, _ := v.(map[string]interface{})
+	isoCode := func(v interface{}) string {
+		m, _ := v.(map[string]interface{})
+		code, _ := m["iso_code"].(string)
+		return code
+	}
+
+	var geo Geo
+	if geo.Country = isoCode(rec["country"]); geo.Country == "" {
+		geo.Country = isoCode(rec["registered_country"])
+	}
+	if subdivisions, _ := rec["subdivisions"].([]interface{}); len(subdivisions) > 0 && geo.Country != "" {
+		if code := isoCode(subdivisions[0]); code != "" {
+			geo.Region = geo.Country + "-" + code
+		}
+	}
+	return geo
+}
+
+// locateEvents sets the country and region of events with an IP, if a
+// database is set. It runs at flush time, off the request path.
+func locateEvents(batch []Event) {
+	g := geoIP.Load()
+	if g == nil {
+		return
+	}
+	for i := range batch {
+		if batch[i].Country == "" && batch[i].IPAddress != "" {
+			geo := g.Lookup(batch[i].IPAddress)
+			batch[i].Country, batch[i].Region = geo.Country, geo.Region
+		}
+	}
+}
+
+// geoCounts counts located events per UTC day, site, country and region in
+// raw events from the first to the second argument
+const geoCounts = `
+	SELECT substr(created_at, 1, 10), domain, country, COALESCE(region, ''), COUNT(*)
+	FROM events
+	WHERE country IS NOT NULL AND created_at >= ? AND created_at < ?
+	GROUP BY 1, 2, 3, 4`
+
+// rollupGeo counts the located events of the days from the first to the
+// second argument into analytics_geo
+func rollupGeo(tx *sql.Tx, from, to string) error {
+	_, err := tx.Exec(`
+		INSERT INTO analytics_geo (day, domain, country, region, count)
+		`+geoCounts+`
+		ON CONFLICT DO UPDATE SET count = excluded.count
+	`, from, to)
+	return err
+}
+
+// GeoBreakdown counts located events since a time (zero for all time),
+// rounded down to its UTC day, by "country" or "region". Days already
+// rolled up are read from analytics_geo, the rest from raw events. limit <= 0
+// returns every key.
+func GeoBreakdown(db *sql.DB, since time.Time, column string, limit int) ([]Count, error) {
+	if column != "country" && column != "region" {
+		return nil, fmt.Errorf("unknown geo column %q", column)
+	}
+	until, err := watermark(db, "daily")
+	if err != nil {
+		return nil, err
+	}
+	var sinceDay string
+	if !since.IsZero() {
+		sinceDay = since.UTC().Truncate(24 * time.Hour).Format(hourFormat)
+	}
+	untilDay := until.Format(hourFormat)
+
+	query := `
+		SELECT key, SUM(n) AS n FROM (
+			SELECT ` + column + ` AS key, SUM(count) AS n FROM analytics_geo
+			WHERE day >= substr(?, 1, 10) AND day < substr(?, 1, 10) AND ` + column + ` != '' GROUP BY key
+			UNION ALL
+			SELECT ` + column + ` AS key, COUNT(*) AS n FROM events
+			WHERE created_at >= ? AND ` + column + ` != '' GROUP BY key
+		) GROUP BY key ORDER BY n DESC, key`
+	args := []interface{}{sinceDay, untilDay, max(sinceDay, untilDay)}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []Count
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Key, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// mmdbDecoder decodes values from an MMDB data or metadata section, whose
+// pointers are relative to base
+type mmdbDecoder struct {
+	buf  []byte
+	base uint
+}
+
+// decode returns the value at offset and the offset after it
+func (d mmdbDecoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > 32 || offset >= uint(len(d.buf)) {
+		return nil, 0, errMMDBCorrupt
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+
+	if typ == mmdbPointer {
+		size := uint(ctrl>>3)&0x3 + 1
+		if offset+size > uint(len(d.buf)) {
+			return nil, 0, errMMDBCorrupt
+		}
+		var ptr uint
+		if size < 4 {
+			ptr = uint(ctrl & 0x7)
+		}
+		for _, b := range d.buf[offset : offset+size] {
+			ptr = ptr<<8 | uint(b)
+		}
+		switch size {
+		case 2:
+			ptr += 2048
+		case 3:
+			ptr += 526336
+		}
+		v, _, err := d.decode(d.base+ptr, depth+1)
+		return v, offset + size, err
+	}
+
+	if typ == mmdbExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errMMDBCorrupt
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, errMMDBCorrupt
+		}
+		var v uint
+		for _, b := range d.buf[offset : offset+n] {
+			v = v<<8 | uint(b)
+		}
+		offset += n
+		size = [...]uint{29, 285, 65821}[n-1] + v
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, errMMDBCorrupt
+			}
+			if m[key], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, size)
+		for i := range a {
+			var err error
+			if a[i], offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, errMMDBCorrupt
+	}
+	data := d.buf[offset : offset+size]
+	offset += size
+
+	switch typ {
+	case mmdbString:
+		return string(data), offset, nil
+	case mmdbBytes:
+		return append([]byte(nil), data...), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errMMDBCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errMMDBCorrupt
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbUint128:
+		// uint128 values don't fit; only their low 64 bits are kept
+		var n uint64
+		for _, b := range data {
+			n = n<<8 | uint64(b)
+		}
+		return n, offset, nil
+	case mmdbInt32:
+		// Only 4-byte values can be negative
+		var n uint32
+		for _, b := range data {
+			n = n<<8 | uint32(b)
+		}
+		return int32(n), offset, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown type %d", errMMDBCorrupt, typ)
+}
@@ -0,0 +1,194 @@
+package analytics
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+// encodeMMDB encodes a value in the MMDB data format. Sizes must be under 29.
+func encodeMMDB(v interface{}) []byte {
+	ctrl := func(typ, size int) []byte {
+		if typ <= 7 {
+			return []byte{byte(typ<<5 | size)}
+		}
+		return []byte{byte(size), byte(typ - 7)}
+	}
+	switch v := v.(type) {
+	case string:
+		return append(ctrl(mmdbString, len(v)), v...)
+	case int:
+		return binary.BigEndian.AppendUint32(ctrl(mmdbUint32, 4), uint32(v))
+	case []interface{}:
+		out := ctrl(mmdbArray, len(v))
+		for _, item := range v {
+			out = append(out, encodeMMDB(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := ctrl(mmdbMap, len(v))
+		for _, k := range keys {
+			out = append(out, encodeMMDB(k)...)
+			out = append(out, encodeMMDB(v[k])...)
+		}
+		return out
+	}
+	panic("unsupported MMDB test value")
+}
+
+// buildMMDB builds an IPv4 MaxMind DB with 24-bit records mapping each
+// network to a data section offset
+func buildMMDB(networks map[string]int, data []byte) []byte {
+	// Children are node indexes, data leaves -(offset+1), and 0 is empty
+	nodes := [][2]int{{}}
+	for network, offset := range networks {
+		prefix := netip.MustParsePrefix(network)
+		ip := prefix.Addr().As4()
+		n := 0
+		for i := 0; i < prefix.Bits(); i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if i == prefix.Bits()-1 {
+				nodes[n][bit] = -(offset + 1)
+				break
+			}
+			if nodes[n][bit] <= 0 {
+				nodes = append(nodes, [2]int{})
+				nodes[n][bit] = len(nodes) - 1
+			}
+			n = nodes[n][bit]
+		}
+	}
+
+	var buf []byte
+	for _, node := range nodes {
+		for _, child := range node {
+			record := len(nodes) // Not found
+			if child > 0 {
+				record = child
+			} else if child < 0 {
+				record = len(nodes) + 16 + (-child - 1)
+			}
+			buf = append(buf, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, mmdbMetadataMarker...)
+	return append(buf, encodeMMDB(map[string]interface{}{
+		"binary_format_major_version": 2,
+		"database_type":               "Test-City",
+		"ip_version":                  4,
+		"node_count":                  len(nodes),
+		"record_size":                 24,
+	})...)
+}
+
+func testGeoIP(t *testing.T) *GeoIP {
+	t.Helper()
+	city := encodeMMDB(map[string]interface{}{
+		"country":      map[string]interface{}{"iso_code": "US"},
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "CA"}},
+	})
+	registered := encodeMMDB(map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "DE"},
+	})
+	data := append(city, registered...)
+	// A pointer back to the first record
+	pointer := len(data)
+	data = append(data, byte(mmdbPointer<<5), 0)
+
+	g, err := parseGeoIP(buildMMDB(map[string]int{
+		"203.0.113.0/24":  0,
+		"198.51.100.0/24": len(city),
+		"192.0.2.0/25":    pointer,
+	}, data))
+	if err != nil {
+		t.Fatalf("parseGeoIP failed: %v", err)
+	}
+	return g
+}
+
+func TestGeoIP_Lookup(t *testing.T) {
+	g := testGeoIP(t)
+
+	tests := []struct {
+		ip   string
+		want Geo
+	}{
+		{"203.0.113.7", Geo{Country: "US", Region: "US-CA"}},
+		{"198.51.100.1", Geo{Country: "DE"}},
+		{"192.0.2.1", Geo{Country: "US", Region: "US-CA"}},
+		{"::ffff:203.0.113.7", Geo{Country: "US", Region: "US-CA"}},
+		{"192.0.2.200", Geo{}},
+		{"10.0.0.1", Geo{}},
+		{"2001:db8::1", Geo{}},
+		{"not-an-ip", Geo{}},
+	}
+	for _, tt := range tests {
+		if got := g.Lookup(tt.ip); got != tt.want {
+			t.Errorf("Lookup(%s) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+
+	if _, err := parseGeoIP([]byte("not a MaxMind DB")); err == nil {
+		t.Error("Expected a file without metadata to fail")
+	}
+}
+
+func TestGeoBreakdown(t *testing.T) {
+	db := dbtest.Open(t)
+	database.SetDB(db)
+	t.Cleanup(func() { database.SetDB(nil) })
+	SetGeoIP(testGeoIP(t))
+	t.Cleanup(func() { SetGeoIP(nil) })
+
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -2)
+	var batch []Event
+	for _, ip := range []string{"203.0.113.1", "203.0.113.2", "198.51.100.1", "10.0.0.1", ""} {
+		batch = append(batch, Event{Domain: "blog", SourceType: "hosting", EventType: "pageview", Path: "/",
+			IPAddress: ip, CreatedAt: day.Add(time.Hour)})
+	}
+	b := newBuffer(DefaultConfig())
+	if err := b.writeBatch(batch); err != nil {
+		t.Fatalf("writeBatch failed: %v", err)
+	}
+
+	check := func(when string) {
+		t.Helper()
+		countries, err := GeoBreakdown(db, time.Time{}, "country", 0)
+		if err != nil {
+			t.Fatalf("GeoBreakdown failed: %v", err)
+		}
+		if len(countries) != 2 || countries[0] != (Count{"US", 2}) || countries[1] != (Count{"DE", 1}) {
+			t.Errorf("Expected 2 events from US and 1 from DE %s, got %v", when, countries)
+		}
+		regions, err := GeoBreakdown(db, day, "region", 0)
+		if err != nil {
+			t.Fatalf("GeoBreakdown failed: %v", err)
+		}
+		if len(regions) != 1 || regions[0] != (Count{"US-CA", 2}) {
+			t.Errorf("Expected 2 events from US-CA %s, got %v", when, regions)
+		}
+	}
+	check("in raw events")
+
+	if err := Rollup(db, time.Now()); err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	db.Exec(`DELETE FROM events`)
+	check("once rolled up")
+
+	if _, err := GeoBreakdown(db, time.Time{}, "city", 0); err == nil {
+		t.Error("Expected an unknown geo column to fail")
+	}
+}
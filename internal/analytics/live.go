@@ -0,0 +1,121 @@
+package analytics
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// LiveWindow is how recently a visitor must have viewed a page to count as
+// active
+const LiveWindow = 5 * time.Minute
+
+// liveCapacity is how many recent pageviews are kept in memory
+const liveCapacity = 2000
+
+// LivePageview is a recent pageview, as shown on the live dashboard
+type LivePageview struct {
+	Seq      uint64    `json:"seq"`
+	Site     string    `json:"site"`
+	Path     string    `json:"path"`
+	Referrer string    `json:"referrer,omitempty"`
+	Device   string    `json:"device,omitempty"`
+	At       time.Time `json:"at"`
+
+	visitor [16]byte
+}
+
+// LiveSnapshot is what the live dashboard shows
+type LiveSnapshot struct {
+	Seq         uint64         `json:"seq"`          // The newest pageview's
+	Active      map[string]int `json:"active"`       // Visitors seen in the last LiveWindow, by site
+	ActiveTotal int            `json:"active_total"` // Sum of Active
+	Pageviews   []LivePageview `json:"pageviews"`    // Newest first
+}
+
+// liveRing holds the latest pageviews. Visitors are keyed with a random
+// per-process key, so keys can't be traced back to an IP.
+type liveRing struct {
+	mu    sync.Mutex
+	views []LivePageview
+	next  int
+	seq   uint64
+	key   []byte
+}
+
+var live = newLiveRing(liveCapacity)
+
+func newLiveRing(capacity int) *liveRing {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &liveRing{views: make([]LivePageview, capacity), key: key}
+}
+
+// RecordLive adds a pageview to the live dashboard. It only touches memory.
+func RecordLive(e Event) {
+	live.record(e)
+}
+
+func (l *liveRing) record(e Event) {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	if e.Device == "" {
+		e.Device = DeviceClass(e.ScreenWidth, e.UserAgent)
+	}
+	mac := hmac.New(sha256.New, l.key)
+	mac.Write([]byte(e.IPAddress))
+	mac.Write([]byte{0})
+	mac.Write([]byte(e.UserAgent))
+
+	v := LivePageview{Site: e.Domain, Path: e.Path, Referrer: e.Referrer, Device: e.Device, At: e.CreatedAt}
+	copy(v.visitor[:], mac.Sum(nil))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	v.Seq = l.seq
+	l.views[l.next] = v
+	l.next = (l.next + 1) % len(l.views)
+}
+
+// Live returns the active visitors of a site ("" for all sites) and up to
+// limit of its pageviews after seq, newest first
+func Live(site string, after uint64, limit int) LiveSnapshot {
+	return live.snapshot(site, after, limit, time.Now())
+}
+
+func (l *liveRing) snapshot(site string, after uint64, limit int, now time.Time) LiveSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snap := LiveSnapshot{Seq: l.seq, Active: map[string]int{}, Pageviews: []LivePageview{}}
+	seen := map[string]map[[16]byte]bool{}
+	cutoff := now.Add(-LiveWindow)
+	for i := 1; i <= len(l.views); i++ {
+		v := l.views[(l.next-i+len(l.views))%len(l.views)]
+		if v.Seq == 0 {
+			break
+		}
+		if site != "" && v.Site != site {
+			continue
+		}
+		if v.Seq > after && len(snap.Pageviews) < limit {
+			snap.Pageviews = append(snap.Pageviews, v)
+		}
+		if v.At.Before(cutoff) {
+			continue
+		}
+		if seen[v.Site] == nil {
+			seen[v.Site] = map[[16]byte]bool{}
+		}
+		if !seen[v.Site][v.visitor] {
+			seen[v.Site][v.visitor] = true
+			snap.Active[v.Site]++
+			snap.ActiveTotal++
+		}
+	}
+	return snap
+}
@@ -0,0 +1,42 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveRing(t *testing.T) {
+	l := newLiveRing(4)
+	now := time.Now()
+	view := func(site, path, ip string, ago time.Duration) {
+		l.record(Event{Domain: site, Path: path, IPAddress: ip, UserAgent: "test", CreatedAt: now.Add(-ago)})
+	}
+	view("blog", "/old", "203.0.113.9", 10*time.Minute)
+	view("blog", "/", "203.0.113.1", time.Minute)
+	view("blog", "/about", "203.0.113.1", 30*time.Second)
+	view("docs", "/", "203.0.113.2", 0)
+
+	snap := l.snapshot("", 0, 10, now)
+	if snap.Seq != 4 || len(snap.Pageviews) != 4 || snap.Pageviews[0].Site != "docs" {
+		t.Fatalf("Expected 4 pageviews, newest first, got %+v", snap)
+	}
+	// One visitor viewed two pages; the old pageview is outside the window
+	if snap.Active["blog"] != 1 || snap.Active["docs"] != 1 || snap.ActiveTotal != 2 {
+		t.Errorf("Expected 1 active visitor on each site, got %v", snap.Active)
+	}
+
+	snap = l.snapshot("blog", 2, 10, now)
+	if len(snap.Pageviews) != 1 || snap.Pageviews[0].Path != "/about" || snap.ActiveTotal != 1 {
+		t.Errorf("Expected blog's pageview after seq 2, got %+v", snap)
+	}
+
+	// A full ring drops the oldest pageview
+	view("docs", "/new", "203.0.113.3", 0)
+	snap = l.snapshot("", 0, 10, now)
+	if len(snap.Pageviews) != 4 || snap.Pageviews[3].Path != "/" || snap.Pageviews[0].Seq != 5 {
+		t.Errorf("Expected the oldest pageview to be dropped, got %+v", snap.Pageviews)
+	}
+	if snap = l.snapshot("", 0, 2, now); len(snap.Pageviews) != 2 {
+		t.Errorf("Expected the limit to cap pageviews, got %d", len(snap.Pageviews))
+	}
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// HasOptOutSignal reports whether the visitor sent a Do-Not-Track (DNT: 1)
+// or Global Privacy Control (Sec-GPC: 1) header
+func HasOptOutSignal(r *http.Request) bool {
+	return strings.TrimSpace(r.Header.Get("DNT")) == "1" ||
+		strings.TrimSpace(r.Header.Get("Sec-GPC")) == "1"
+}
+
+// SitePrivacy returns the analytics privacy settings for a site.
+// site may be an app ID, app title, or alias subdomain.
+// Unknown sites collect analytics and ignore DNT/GPC.
+func SitePrivacy(site string) (collect, honorDNT bool) {
+	db := database.GetDB()
+	if db == nil || site == "" {
+		return true, false
+	}
+
+	var c, h int
+	err := db.QueryRow(`
+		SELECT COALESCE(analytics_collect, 1), COALESCE(analytics_honor_dnt, 0)
+		FROM apps
+		WHERE id = ? OR title = ?
+			OR id = (SELECT json_extract(targets, '$.app_id') FROM aliases WHERE subdomain = ? AND type IN ('proxy', 'app'))
+		LIMIT 1
+	`, site, site, site).Scan(&c, &h)
+	if err != nil {
+		return true, false
+	}
+	return c == 1, h == 1
+}
+
+// ShouldTrack reports whether an event for site may be recorded for this request
+func ShouldTrack(r *http.Request, site string) bool {
+	collect, honorDNT := SitePrivacy(site)
+	if !collect {
+		return false
+	}
+	return !(honorDNT && HasOptOutSignal(r))
+}
+
+// SiteFromHost returns the site name for a tracked hostname
+// (e.g. "tetris.zyt.app" -> "tetris"). Bare domains are returned as-is.
+func SiteFromHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.Count(host, ".") < 2 {
+		return host
+	}
+	return host[:strings.Index(host, ".")]
+}
@@ -0,0 +1,73 @@
+package analytics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupPrivacyTest(t *testing.T) {
+	t.Helper()
+
+	db := dbtest.Open(t)
+	database.SetDB(db)
+	t.Cleanup(func() {
+		database.SetDB(nil)
+	})
+
+	db.Exec(`INSERT INTO apps (id, title, analytics_collect, analytics_honor_dnt) VALUES
+		('app_open', 'open', 1, 0),
+		('app_private', 'private', 0, 0),
+		('app_polite', 'polite', 1, 1)`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('shop', 'proxy', '{"app_id":"app_polite"}')`)
+}
+
+func TestShouldTrack(t *testing.T) {
+	setupPrivacyTest(t)
+
+	tests := []struct {
+		name    string
+		site    string
+		headers map[string]string
+		want    bool
+	}{
+		{"default", "open", nil, true},
+		{"unknown site", "nope", map[string]string{"DNT": "1"}, true},
+		{"dnt ignored", "open", map[string]string{"DNT": "1"}, true},
+		{"collection disabled", "private", nil, false},
+		{"collection disabled by id", "app_private", nil, false},
+		{"honor dnt without signal", "polite", nil, true},
+		{"honor dnt", "polite", map[string]string{"DNT": "1"}, false},
+		{"honor gpc", "polite", map[string]string{"Sec-GPC": "1"}, false},
+		{"dnt zero", "polite", map[string]string{"DNT": "0"}, true},
+		{"via alias", "shop", map[string]string{"Sec-GPC": "1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := ShouldTrack(r, tt.site); got != tt.want {
+				t.Errorf("ShouldTrack(%q) = %v, want %v", tt.site, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSiteFromHost(t *testing.T) {
+	tests := map[string]string{
+		"tetris.zyt.app":      "tetris",
+		"tetris.zyt.app:8080": "tetris",
+		"zyt.app":             "zyt.app",
+		"localhost":           "localhost",
+	}
+	for host, want := range tests {
+		if got := SiteFromHost(host); got != want {
+			t.Errorf("SiteFromHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+const (
+	hourFormat  = "2006-01-02 15:00:00"
+	eventFormat = "2006-01-02 15:04:05.999999999"
+)
+
+// DefaultRawDays is how many recent days stats read from raw events
+const DefaultRawDays = 7
+
+// HourlyRetention is how long hourly rollups are kept; daily ones are kept forever
+const HourlyRetention = 90 * 24 * time.Hour
+
+// rollupDelay leaves time for buffered events to be flushed before their
+// hour is rolled up
+const rollupDelay = 5 * time.Minute
+
+// maxRollupHours bounds how much history one tick backfills, so rolling up
+// a large existing events table is spread over several ticks
+const maxRollupHours = 31 * 24
+
+// rollupInterval is how often the rollup loop runs
+const rollupInterval = 15 * time.Minute
+
+// pruneBatch is how many events each retention delete removes
+const pruneBatch = 5000
+
+// rollupColumns are the dimensions events are counted by
+const rollupColumns = `domain, source_type, event_type, path, referrer, tags`
+
+var rawWindow = struct {
+	sync.RWMutex
+	d time.Duration
+}{d: DefaultRawDays * 24 * time.Hour}
+
+// SetRawDays sets how many recent days stats read from raw events; older
+// ranges are read from rollups
+func SetRawDays(days int) {
+	if days < 1 {
+		days = DefaultRawDays
+	}
+	rawWindow.Lock()
+	rawWindow.d = time.Duration(days) * 24 * time.Hour
+	rawWindow.Unlock()
+}
+
+func getRawWindow() time.Duration {
+	rawWindow.RLock()
+	defer rawWindow.RUnlock()
+	return rawWindow.d
+}
+
+// Rollups periodically counts events into the hourly and daily rollup
+// tables and prunes raw events past their retention
+type Rollups struct {
+	db        *sql.DB
+	retention time.Duration // 0 keeps raw events forever
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewRollups creates a rollup loop. Raw events older than retentionDays are
+// deleted once rolled up; 0 keeps them.
+func NewRollups(db *sql.DB, retentionDays int) *Rollups {
+	return &Rollups{
+		db:        db,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		done:      make(chan struct{}),
+	}
+}
+
+// Start begins the background rollup loop
+func (r *Rollups) Start() {
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.Tick(time.Now())
+
+		ticker := time.NewTicker(rollupInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.Tick(time.Now())
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the rollup loop
+func (r *Rollups) Stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// Tick rolls up, then prunes raw events past retention
+func (r *Rollups) Tick(now time.Time) {
+	if err := Rollup(r.db, now); err != nil {
+		log.Printf("Analytics: rollup failed: %v", err)
+		return
+	}
+	if r.retention <= 0 {
+		return
+	}
+	n, err := Prune(r.db, now.Add(-r.retention))
+	if err != nil {
+		log.Printf("Analytics: pruning events failed: %v", err)
+	} else if n > 0 {
+		log.Printf("Analytics: pruned %d events older than %s", n, r.retention)
+	}
+}
+
+// RolledUpUntil returns the time before which all events are in the
+// rollups, or the zero time before the first rollup
+func RolledUpUntil(db *sql.DB) (time.Time, error) {
+	return watermark(db, "hourly")
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func watermark(db queryRower, name string) (time.Time, error) {
+	var until string
+	err := db.QueryRow(`SELECT until FROM analytics_rollup_state WHERE name = ?`, name).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(hourFormat, until)
+}
+
+// Rollup counts the complete hours since the last rollup into
+// analytics_hourly, the complete days into analytics_daily, and drops hourly
+// rows past HourlyRetention
+func Rollup(db *sql.DB, now time.Time) error {
+	from, err := watermark(db, "hourly")
+	if err != nil {
+		return err
+	}
+	if from.IsZero() {
+		// First run: start from the oldest event
+		var oldest sql.NullString
+		if err := db.QueryRow(`SELECT strftime('%Y-%m-%d %H:00:00', MIN(created_at)) FROM events`).Scan(&oldest); err != nil {
+			return err
+		}
+		if from, err = time.Parse(hourFormat, oldest.String); !oldest.Valid || err != nil {
+			from = now.UTC().Add(-rollupDelay).Truncate(time.Hour)
+		}
+	}
+	to := now.UTC().Add(-rollupDelay).Truncate(time.Hour)
+	if limit := from.Add(maxRollupHours * time.Hour); to.After(limit) {
+		to = limit
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	return storage.QueueWrite(ctx, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if to.After(from) {
+			// Events are selected by when they were stored, so each one is
+			// counted exactly once whatever hour it lands in
+			if _, err := tx.Exec(`
+				INSERT INTO analytics_hourly (hour, `+rollupColumns+`, count)
+				SELECT strftime('%Y-%m-%d %H:00:00', created_at), domain, source_type, event_type,
+					COALESCE(path, ''), COALESCE(referrer, ''), COALESCE(tags, ''), COUNT(*)
+				FROM events
+				WHERE created_at >= ? AND created_at < ?
+				GROUP BY 1, 2, 3, 4, 5, 6, 7
+				ON CONFLICT DO UPDATE SET count = analytics_hourly.count + excluded.count
+			`, from.Format(eventFormat), to.Format(eventFormat)); err != nil {
+				return fmt.Errorf("hourly rollup: %w", err)
+			}
+			if err := setWatermark(tx, "hourly", to); err != nil {
+				return err
+			}
+		}
+
+		// Days whose hours are all rolled up
+		dayFrom, err := watermark(tx, "daily")
+		if err != nil {
+			return err
+		}
+		if dayFrom.IsZero() {
+			var first sql.NullString
+			if err := tx.QueryRow(`SELECT MIN(hour) FROM analytics_hourly`).Scan(&first); err != nil {
+				return err
+			}
+			dayFrom, _ = time.Parse(hourFormat, first.String)
+			dayFrom = dayFrom.Truncate(24 * time.Hour)
+		}
+		dayTo := to.Truncate(24 * time.Hour)
+		if !dayFrom.IsZero() && dayTo.After(dayFrom) {
+			if _, err := tx.Exec(`
+				INSERT INTO analytics_daily (day, `+rollupColumns+`, count)
+				SELECT substr(hour, 1, 10), `+rollupColumns+`, SUM(count)
+				FROM analytics_hourly
+				WHERE hour >= ? AND hour < ?
+				GROUP BY 1, 2, 3, 4, 5, 6, 7
+				ON CONFLICT DO UPDATE SET count = excluded.count
+			`, dayFrom.Format(hourFormat), dayTo.Format(hourFormat)); err != nil {
+				return fmt.Errorf("daily rollup: %w", err)
+			}
+			if err := rollupVisitors(tx, dayFrom.Format(hourFormat), dayTo.Format(hourFormat)); err != nil {
+				return fmt.Errorf("visitor rollup: %w", err)
+			}
+			if err := rollupGeo(tx, dayFrom.Format(hourFormat), dayTo.Format(hourFormat)); err != nil {
+				return fmt.Errorf("geo rollup: %w", err)
+			}
+			if err := setWatermark(tx, "daily", dayTo); err != nil {
+				return err
+			}
+
+			// Hourly rows are only read for recent ranges
+			expired := now.UTC().Add(-HourlyRetention).Truncate(24 * time.Hour)
+			if expired.After(dayTo) {
+				expired = dayTo
+			}
+			if _, err := tx.Exec(`DELETE FROM analytics_hourly WHERE hour < ?`, expired.Format(hourFormat)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// RebuildRollups recounts the hourly, daily, visitor and geo rollups from
+// the raw events still kept, for when the rollups were miscounted. Days
+// before the oldest event keep their rollups, as does the oldest event's
+// day if earlier events were pruned from it. It reports progress from 0 to
+// 1 and returns how many events were recounted.
+func RebuildRollups(db *sql.DB, now time.Time, progress func(done float64)) (int64, error) {
+	var oldest sql.NullString
+	if err := db.QueryRow(`SELECT strftime('%Y-%m-%d %H:00:00', MIN(created_at)) FROM events`).Scan(&oldest); err != nil {
+		return 0, err
+	}
+	if !oldest.Valid {
+		progress(1)
+		return 0, nil
+	}
+	first, err := time.Parse(hourFormat, oldest.String)
+	if err != nil {
+		return 0, err
+	}
+	from := first.Truncate(24 * time.Hour)
+
+	// A day whose first events were pruned can't be recounted: its hourly
+	// rollups start before its events do or, once those have expired,
+	// earlier days were pruned too
+	var pruned bool
+	if err := db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM analytics_hourly WHERE hour >= ? AND hour < ?)
+			OR (? AND EXISTS (SELECT 1 FROM analytics_daily WHERE day < ?))
+	`, from.Format(hourFormat), first.Format(hourFormat),
+		from.Before(now.UTC().Add(-HourlyRetention)), from.Format("2006-01-02")).Scan(&pruned); err != nil {
+		return 0, err
+	}
+	if pruned {
+		from = from.Add(24 * time.Hour)
+	}
+
+	var events int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM events WHERE created_at >= ?`, from.Format(eventFormat)).Scan(&events); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = storage.QueueWrite(ctx, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		hour, day := from.Format(hourFormat), from.Format("2006-01-02")
+		for _, q := range []struct {
+			query string
+			arg   string
+		}{
+			{`DELETE FROM analytics_hourly WHERE hour >= ?`, hour},
+			{`DELETE FROM analytics_daily WHERE day >= ?`, day},
+			{`DELETE FROM analytics_visitors WHERE day >= ?`, day},
+			{`DELETE FROM analytics_geo WHERE day >= ?`, day},
+		} {
+			if _, err := tx.Exec(q.query, q.arg); err != nil {
+				return err
+			}
+		}
+		if err := setWatermark(tx, "hourly", from); err != nil {
+			return err
+		}
+		if err := setWatermark(tx, "daily", from); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reset rollups: %w", err)
+	}
+
+	// Each Rollup backfills at most maxRollupHours
+	to := now.UTC().Add(-rollupDelay).Truncate(time.Hour)
+	for {
+		until, err := watermark(db, "hourly")
+		if err != nil {
+			return events, err
+		}
+		if !until.Before(to) {
+			break
+		}
+		if span := to.Sub(from); span > 0 {
+			progress(float64(until.Sub(from)) / float64(span))
+		}
+		if err := Rollup(db, now); err != nil {
+			return events, err
+		}
+		if next, err := watermark(db, "hourly"); err != nil || !next.After(until) {
+			return events, err
+		}
+	}
+	progress(1)
+	return events, nil
+}
+
+func setWatermark(tx *sql.Tx, name string, until time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO analytics_rollup_state (name, until) VALUES (?, ?)
+		ON CONFLICT(name) DO UPDATE SET until = excluded.until
+	`, name, until.Format(hourFormat))
+	return err
+}
+
+// PruneCutoff returns the time raw events may be deleted before when asked
+// to delete those before the given time: events not yet rolled up, those
+// of days whose visitors aren't counted yet, and those stats still read
+// raw, are kept. It's the zero time when none can be.
+func PruneCutoff(db *sql.DB, before time.Time) (time.Time, error) {
+	until, err := RolledUpUntil(db)
+	if err != nil {
+		return time.Time{}, err
+	}
+	days, err := watermark(db, "daily")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if days.Before(until) {
+		until = days
+	}
+	cutoff := before.UTC().Truncate(time.Hour)
+	if raw := time.Now().UTC().Add(-getRawWindow()).Truncate(time.Hour); raw.Before(cutoff) {
+		cutoff = raw
+	}
+	if until.Before(cutoff) {
+		cutoff = until
+	}
+	return cutoff, nil
+}
+
+// Prune deletes raw events from before the given time, within PruneCutoff
+func Prune(db *sql.DB, before time.Time) (int64, error) {
+	cutoff, err := PruneCutoff(db, before)
+	if err != nil || cutoff.IsZero() {
+		return 0, err
+	}
+
+	var deleted int64
+	for {
+		res, err := db.Exec(`DELETE FROM events WHERE rowid IN (
+			SELECT rowid FROM events WHERE created_at < ? LIMIT ?
+		)`, cutoff.Format(eventFormat), pruneBatch)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		deleted += n
+		if n < pruneBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// Count is the number of events with a key
+type Count struct {
+	Key   string
+	Count int64
+}
+
+var breakdownColumns = map[string]bool{
+	"": true, "domain": true, "source_type": true, "event_type": true,
+	"path": true, "referrer": true, "tags": true,
+}
+
+// Breakdown counts events since a time (zero for all time) grouped by
+// column, or as a single total when column is "". The most recent days are
+// counted from raw events and older ones from the rollups. limit <= 0
+// returns every key.
+func Breakdown(db *sql.DB, since time.Time, column string, limit int) ([]Count, error) {
+	if !breakdownColumns[column] {
+		return nil, fmt.Errorf("unknown breakdown column %q", column)
+	}
+	key, rawKey := "''", "''"
+	if column != "" {
+		key, rawKey = column, "COALESCE("+column+", '')"
+	}
+
+	// Rollups cover up to split; raw events from there on
+	until, err := RolledUpUntil(db)
+	if err != nil {
+		return nil, err
+	}
+	split := time.Now().UTC().Add(-getRawWindow()).Truncate(time.Hour)
+	if until.Before(split) {
+		split = until
+	}
+
+	// Whole days from the daily rollup, partial ones from the hourly one
+	var sinceStr, splitStr, sinceDay, splitDay, rawFrom string
+	if !since.IsZero() {
+		since = since.UTC()
+		sinceStr = since.Format(hourFormat)
+		day := since.Truncate(24 * time.Hour)
+		if day.Before(since) {
+			day = day.Add(24 * time.Hour)
+		}
+		sinceDay = day.Format(hourFormat)
+		rawFrom = since.Format(eventFormat)
+	}
+	if !split.IsZero() {
+		splitStr = split.Format(hourFormat)
+		splitDay = split.Truncate(24 * time.Hour).Format(hourFormat)
+		rawFrom = max(rawFrom, split.Format(eventFormat))
+	}
+	if sinceDay > splitDay {
+		// The range starts within a day of split: no whole days
+		sinceDay = splitDay
+	}
+
+	query := `
+		SELECT key, SUM(n) AS n FROM (
+			SELECT ` + key + ` AS key, SUM(count) AS n FROM analytics_daily
+			WHERE day >= substr(?, 1, 10) AND day < substr(?, 1, 10) GROUP BY key
+			UNION ALL
+			SELECT ` + key + ` AS key, SUM(count) AS n FROM analytics_hourly
+			WHERE (hour >= ? AND hour < ?) OR (hour >= ? AND hour < ?) GROUP BY key
+			UNION ALL
+			SELECT ` + rawKey + ` AS key, COUNT(*) AS n FROM events
+			WHERE created_at >= ? GROUP BY key
+		) GROUP BY key ORDER BY n DESC, key`
+	args := []interface{}{
+		sinceDay, splitDay,
+		sinceStr, sinceDay, max(sinceStr, splitDay), splitStr,
+		rawFrom,
+	}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []Count
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Key, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// Total counts events since a time (zero for all time)
+func Total(db *sql.DB, since time.Time) (int64, error) {
+	counts, err := Breakdown(db, since, "", 0)
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0].Count, nil
+}
@@ -0,0 +1,124 @@
+package analytics
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func insertEvent(t *testing.T, db *sql.DB, domain, path string, at time.Time) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO events (domain, source_type, event_type, path, referrer, created_at) VALUES (?, 'web', 'pageview', ?, 'https://news.example', ?)`,
+		domain, path, at.UTC().Format(eventFormat))
+	if err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+}
+
+func TestRollupAndPrune(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Now()
+
+	insertEvent(t, db, "blog.example", "/a", now.Add(-40*24*time.Hour))
+	insertEvent(t, db, "blog.example", "/a", now.Add(-40*24*time.Hour))
+	insertEvent(t, db, "shop.example", "/", now.Add(-10*24*time.Hour))
+	insertEvent(t, db, "blog.example", "/b", now)
+
+	// Backfill takes more than one tick; later ticks must not count twice
+	for i := 0; i < 4; i++ {
+		if err := Rollup(db, now); err != nil {
+			t.Fatalf("Rollup failed: %v", err)
+		}
+	}
+
+	var daily int64
+	day := now.Add(-40 * 24 * time.Hour).UTC().Format("2006-01-02")
+	db.QueryRow(`SELECT count FROM analytics_daily WHERE day = ? AND domain = 'blog.example' AND path = '/a' AND referrer = 'https://news.example'`, day).Scan(&daily)
+	if daily != 2 {
+		t.Errorf("Expected 2 pageviews of /a rolled up on %s, got %d", day, daily)
+	}
+
+	if total, _ := Total(db, time.Time{}); total != 4 {
+		t.Errorf("Expected 4 events in total, got %d", total)
+	}
+
+	// Rows before the raw window are deleted; the rollups still count them
+	n, err := Prune(db, now.Add(-30*24*time.Hour))
+	if err != nil || n != 2 {
+		t.Fatalf("Expected 2 events pruned, got %d (%v)", n, err)
+	}
+	if total, _ := Total(db, time.Time{}); total != 4 {
+		t.Errorf("Expected 4 events in total after pruning, got %d", total)
+	}
+
+	since := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -20)
+	if total, _ := Total(db, since); total != 2 {
+		t.Errorf("Expected 2 events in the last 20 days, got %d", total)
+	}
+
+	domains, err := Breakdown(db, time.Time{}, "domain", 0)
+	if err != nil {
+		t.Fatalf("Breakdown failed: %v", err)
+	}
+	if len(domains) != 2 || domains[0] != (Count{"blog.example", 3}) || domains[1] != (Count{"shop.example", 1}) {
+		t.Errorf("Unexpected domain breakdown: %+v", domains)
+	}
+
+	if _, err := Breakdown(db, time.Time{}, "ip_address", 0); err == nil {
+		t.Error("Expected an unknown column to fail")
+	}
+}
+
+func TestPruneKeepsEventsNotRolledUp(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Now()
+
+	insertEvent(t, db, "blog.example", "/", now.Add(-40*24*time.Hour))
+	if n, err := Prune(db, now); err != nil || n != 0 {
+		t.Errorf("Expected nothing pruned before the first rollup, got %d (%v)", n, err)
+	}
+}
+
+func TestRebuildRollups(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour).UTC().Format("2006-01-02")
+	recent := now.Add(-10 * 24 * time.Hour).UTC().Format("2006-01-02")
+
+	insertEvent(t, db, "blog.example", "/a", now.Add(-40*24*time.Hour))
+	insertEvent(t, db, "blog.example", "/a", now.Add(-40*24*time.Hour))
+	insertEvent(t, db, "shop.example", "/", now.Add(-10*24*time.Hour))
+	for i := 0; i < 4; i++ {
+		Rollup(db, now)
+	}
+	if _, err := Prune(db, now.Add(-30*24*time.Hour)); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	// A bug miscounted every day
+	db.Exec(`UPDATE analytics_daily SET count = count * 10`)
+	db.Exec(`UPDATE analytics_hourly SET count = count * 10`)
+
+	var last float64
+	events, err := RebuildRollups(db, now, func(done float64) { last = done })
+	if err != nil || events != 1 || last != 1 {
+		t.Fatalf("Expected 1 event recounted, got %d (%v), progress %v", events, err, last)
+	}
+
+	count := func(day string) (n int64) {
+		db.QueryRow(`SELECT COALESCE(SUM(count), 0) FROM analytics_daily WHERE day = ?`, day).Scan(&n)
+		return n
+	}
+	if n := count(recent); n != 1 {
+		t.Errorf("Expected %s recounted to 1, got %d", recent, n)
+	}
+	// Its events are gone, so the pruned day keeps its rollup
+	if n := count(old); n != 20 {
+		t.Errorf("Expected %s left at 20, got %d", old, n)
+	}
+	if until, _ := RolledUpUntil(db); until.Before(now.UTC().Add(-rollupDelay - time.Hour)) {
+		t.Errorf("Expected the rollups caught up, got %v", until)
+	}
+}
@@ -0,0 +1,147 @@
+package analytics
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// SessionGap is how long a visitor can be idle before their next event
+// starts a new session
+const SessionGap = 30 * time.Minute
+
+const dayFormat = "2006-01-02"
+
+// visitorCounts counts unique visitors and sessions per UTC day and site in
+// raw events from the first to the second argument. Visitor IDs rotate
+// daily, so neither a visitor nor a session spans days.
+var visitorCounts = `
+	SELECT substr(created_at, 1, 10) AS day, domain, COUNT(DISTINCT visitor_id),
+		SUM(CASE WHEN prev IS NULL OR julianday(created_at) - julianday(prev) > ` + formatDays(SessionGap) + ` THEN 1 ELSE 0 END)
+	FROM (
+		SELECT created_at, domain, visitor_id,
+			LAG(created_at) OVER (PARTITION BY domain, visitor_id ORDER BY created_at) AS prev
+		FROM events
+		WHERE visitor_id IS NOT NULL AND created_at >= ? AND created_at < ?
+	)
+	GROUP BY 1, 2`
+
+var dropIP atomic.Bool
+
+// SetDropIP sets whether events are stored without the visitor's IP
+// address. Visitor IDs are still derived from it before it's dropped.
+func SetDropIP(drop bool) {
+	dropIP.Store(drop)
+}
+
+// visitorID hashes an event's IP, user agent and site with the salt of its
+// UTC day, so a visitor can be counted once a day without their IP being
+// kept. salts caches the salts already read in this batch. Events without
+// an IP have no visitor ID.
+func visitorID(tx *sql.Tx, salts map[string][]byte, e Event) (string, error) {
+	if e.IPAddress == "" {
+		return "", nil
+	}
+	day := e.CreatedAt.UTC().Format(dayFormat)
+	salt, ok := salts[day]
+	if !ok {
+		var err error
+		if salt, err = dailySalt(tx, day); err != nil {
+			return "", err
+		}
+		salts[day] = salt
+	}
+
+	h := sha256.New()
+	h.Write(salt)
+	for _, s := range []string{e.Domain, e.IPAddress, e.UserAgent} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16]), nil
+}
+
+// dailySalt returns the salt for a UTC day, creating it on first use.
+// Salts from before yesterday are deleted as new ones are made: once gone,
+// nothing links their visitor IDs to an IP or to the next day's IDs.
+func dailySalt(tx *sql.Tx, day string) ([]byte, error) {
+	var salt []byte
+	err := tx.QueryRow(`SELECT salt FROM analytics_salts WHERE day = ?`, day).Scan(&salt)
+	if err != sql.ErrNoRows {
+		return salt, err
+	}
+
+	salt = make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dayFormat)
+	if _, err := tx.Exec(`DELETE FROM analytics_salts WHERE day < ?`, yesterday); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`INSERT INTO analytics_salts (day, salt) VALUES (?, ?)`, day, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// Visitors counts unique visitors and sessions since a time (zero for all
+// time), rounded down to its UTC day. A visitor seen on several days counts
+// once per day. Days already rolled up are read from analytics_visitors,
+// the rest from raw events.
+func Visitors(db *sql.DB, since time.Time) (visitors, sessions int64, err error) {
+	until, err := watermark(db, "daily")
+	if err != nil {
+		return 0, 0, err
+	}
+	var sinceDay string
+	if !since.IsZero() {
+		sinceDay = since.UTC().Truncate(24 * time.Hour).Format(hourFormat)
+	}
+	untilDay := until.Format(hourFormat)
+
+	if err := db.QueryRow(`
+		SELECT COALESCE(SUM(visitors), 0), COALESCE(SUM(sessions), 0) FROM analytics_visitors
+		WHERE day >= substr(?, 1, 10) AND day < substr(?, 1, 10)
+	`, sinceDay, untilDay).Scan(&visitors, &sessions); err != nil {
+		return 0, 0, err
+	}
+
+	// A bound like "9999" would compare as a number against the DATETIME
+	// column and match nothing
+	rows, err := db.Query(visitorCounts, max(sinceDay, untilDay), "9999-12-31")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day, domain string
+		var v, s int64
+		if err := rows.Scan(&day, &domain, &v, &s); err != nil {
+			return 0, 0, err
+		}
+		visitors += v
+		sessions += s
+	}
+	return visitors, sessions, rows.Err()
+}
+
+// rollupVisitors counts the visitors and sessions of the days from the
+// first to the second argument into analytics_visitors
+func rollupVisitors(tx *sql.Tx, from, to string) error {
+	_, err := tx.Exec(`
+		INSERT INTO analytics_visitors (day, domain, visitors, sessions)
+		`+visitorCounts+`
+		ON CONFLICT DO UPDATE SET visitors = excluded.visitors, sessions = excluded.sessions
+	`, from, to)
+	return err
+}
+
+// formatDays renders a duration in days for comparing julianday differences
+func formatDays(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours()/24, 'f', -1, 64)
+}
@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestVisitorsAndSessions(t *testing.T) {
+	db := dbtest.Open(t)
+	database.SetDB(db)
+	t.Cleanup(func() { database.SetDB(nil) })
+
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	visit := func(ip, ua string, at time.Duration) Event {
+		return Event{Domain: "blog", SourceType: "hosting", EventType: "pageview", Path: "/",
+			IPAddress: ip, UserAgent: ua, CreatedAt: day.Add(at)}
+	}
+	batch := []Event{
+		// One visitor, two sessions an hour apart
+		visit("203.0.113.1", "Firefox", time.Minute),
+		visit("203.0.113.1", "Firefox", 10*time.Minute),
+		visit("203.0.113.1", "Firefox", 90*time.Minute),
+		// Same IP, another browser
+		visit("203.0.113.1", "Safari", 5*time.Minute),
+		// No IP, no visitor
+		visit("", "Firefox", 5*time.Minute),
+	}
+	b := newBuffer(DefaultConfig())
+	if err := b.writeBatch(batch); err != nil {
+		t.Fatalf("writeBatch failed: %v", err)
+	}
+
+	visitors, sessions, err := Visitors(db, day)
+	if err != nil {
+		t.Fatalf("Visitors failed: %v", err)
+	}
+	if visitors != 2 || sessions != 3 {
+		t.Errorf("Expected 2 visitors in 3 sessions, got %d in %d", visitors, sessions)
+	}
+
+	var ips int
+	db.QueryRow(`SELECT COUNT(*) FROM events WHERE ip_address = '203.0.113.1'`).Scan(&ips)
+	if ips != 4 {
+		t.Errorf("Expected IPs kept by default, got %d", ips)
+	}
+
+	// The same visitor tomorrow gets another ID
+	SetDropIP(true)
+	t.Cleanup(func() { SetDropIP(false) })
+	if err := b.writeBatch([]Event{visit("203.0.113.1", "Firefox", 25*time.Hour)}); err != nil {
+		t.Fatalf("writeBatch failed: %v", err)
+	}
+	var ids int
+	db.QueryRow(`SELECT COUNT(DISTINCT visitor_id) FROM events`).Scan(&ids)
+	if ids != 3 {
+		t.Errorf("Expected a new visitor ID on a new day, got %d IDs", ids)
+	}
+	var ip string
+	db.QueryRow(`SELECT COALESCE(ip_address, '') FROM events ORDER BY created_at DESC LIMIT 1`).Scan(&ip)
+	if ip != "" {
+		t.Errorf("Expected the IP dropped, got %q", ip)
+	}
+
+	// Rolled-up days keep their counts once raw events are pruned
+	if err := Rollup(db, day.Add(72*time.Hour)); err != nil {
+		t.Fatalf("Rollup failed: %v", err)
+	}
+	db.Exec(`DELETE FROM events`)
+	visitors, sessions, err = Visitors(db, day)
+	if err != nil || visitors != 3 || sessions != 4 {
+		t.Errorf("Expected 3 visitors in 4 sessions from the rollups, got %d in %d (%v)", visitors, sessions, err)
+	}
+}
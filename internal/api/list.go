@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// List Conventions
+// ============================================================================
+//
+// Collection endpoints share these query parameters:
+//
+//	?limit=50             page size, clamped to the endpoint's maximum
+//	?cursor=<cursor>      the page after the one whose meta.next_cursor it is
+//	?sort=-created_at     sort key; a leading - sorts descending
+//	?fields=id,title      fields to include in each item
+//
+// and answer {"data": [...], "meta": {"limit", "has_more", "next_cursor"}}.
+// Pages are keyset pages: a cursor holds the sort key and ID of the last
+// item, so paging stays cheap and stable while items are added. Invalid
+// parameters are 400 VALIDATION_FAILED naming the parameter.
+
+// ListSpec describes what a collection endpoint can be sorted and
+// projected by
+type ListSpec struct {
+	// Sorts maps sort keys to SQL expressions. Expressions must not be NULL
+	// (wrap nullable columns in COALESCE), which also keeps DATETIME
+	// columns as the text they're stored as so cursors compare correctly.
+	Sorts map[string]string
+	// DefaultSort is used without ?sort=, e.g. "-created_at"
+	DefaultSort string
+	// ID is the SQL expression of the item's unique ID, breaking sort ties
+	ID string
+	// Fields are the item fields ?fields= may select
+	Fields []string
+	// DefaultLimit and MaxLimit bound the page size
+	DefaultLimit, MaxLimit int
+}
+
+// ListMeta is the meta of a list response
+type ListMeta struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListQuery is a parsed list request. Build the SQL with Where, OrderBy and
+// LimitArgs, select SortExpr to pass each row's key to Next, and answer
+// with ListSuccess.
+type ListQuery struct {
+	Limit  int
+	Offset int      // Deprecated ?offset=, for clients that predate cursors
+	Fields []string // Empty for all fields
+
+	sort     string
+	expr     string
+	desc     bool
+	id       string
+	after    *listCursor
+	rows     int
+	lastKey  interface{}
+	lastID   interface{}
+	nextPage bool
+}
+
+// listCursor is the position after an item
+type listCursor struct {
+	Sort string      `json:"s"`
+	Key  interface{} `json:"k"`
+	ID   interface{} `json:"i"`
+}
+
+// ParseList parses a list request's parameters against spec, writing a
+// 400 and returning false if they're invalid
+func ParseList(w http.ResponseWriter, r *http.Request, spec ListSpec) (*ListQuery, bool) {
+	query := r.URL.Query()
+	q := &ListQuery{Limit: spec.DefaultLimit, id: spec.ID}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			ValidationError(w, "limit must be a positive integer", "limit", "min")
+			return nil, false
+		}
+		q.Limit = n
+	}
+	if q.Limit > spec.MaxLimit {
+		q.Limit = spec.MaxLimit
+	}
+
+	q.sort = spec.DefaultSort
+	if v := query.Get("sort"); v != "" {
+		q.sort = v
+	}
+	key := strings.TrimPrefix(q.sort, "-")
+	q.desc = key != q.sort
+	q.expr = spec.Sorts[key]
+	if q.expr == "" {
+		ValidationError(w, "sort must be one of: "+strings.Join(sortKeys(spec), ", "), "sort", "enum")
+		return nil, false
+	}
+
+	if v := query.Get("fields"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			if !contains(spec.Fields, f) {
+				ValidationError(w, "unknown field "+f+"; fields are: "+strings.Join(spec.Fields, ", "), "fields", "enum")
+				return nil, false
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != q.sort {
+			ValidationError(w, "cursor is invalid or from a different sort", "cursor", "format")
+			return nil, false
+		}
+		q.after = c
+	} else if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			ValidationError(w, "offset must be a non-negative integer", "offset", "min")
+			return nil, false
+		}
+		q.Offset = n
+	}
+	return q, true
+}
+
+// SortExpr is the sort key's SQL expression; select it and pass its value
+// to Next
+func (q *ListQuery) SortExpr() string {
+	return q.expr
+}
+
+// Where returns the condition selecting the items after the cursor, or ""
+// for the first page
+func (q *ListQuery) Where() (string, []interface{}) {
+	if q.after == nil {
+		return "", nil
+	}
+	op := ">"
+	if q.desc {
+		op = "<"
+	}
+	return "(" + q.expr + " " + op + " ? OR (" + q.expr + " = ? AND " + q.id + " " + op + " ?))",
+		[]interface{}{q.after.Key, q.after.Key, q.after.ID}
+}
+
+// OrderBy returns the ORDER BY list, e.g. "created_at DESC, id DESC"
+func (q *ListQuery) OrderBy() string {
+	dir := " ASC"
+	if q.desc {
+		dir = " DESC"
+	}
+	return q.expr + dir + ", " + q.id + dir
+}
+
+// LimitArgs returns the arguments of "LIMIT ? OFFSET ?": one row more than
+// the page, which tells Next whether there's another page
+func (q *ListQuery) LimitArgs() []interface{} {
+	return []interface{}{q.Limit + 1, q.Offset}
+}
+
+// Next records a row's sort key and ID, returning false for the row past
+// the page, which isn't part of the response
+func (q *ListQuery) Next(key, id interface{}) bool {
+	q.rows++
+	if q.rows > q.Limit {
+		q.nextPage = true
+		return false
+	}
+	q.lastKey, q.lastID = key, id
+	return true
+}
+
+// Wants reports whether field is in the response, so endpoints can skip
+// work for fields that aren't
+func (q *ListQuery) Wants(field string) bool {
+	return len(q.Fields) == 0 || contains(q.Fields, field)
+}
+
+// Meta returns the response meta
+func (q *ListQuery) Meta() ListMeta {
+	meta := ListMeta{Limit: q.Limit, HasMore: q.nextPage}
+	if q.nextPage {
+		meta.NextCursor = encodeCursor(&listCursor{Sort: q.sort, Key: q.lastKey, ID: q.lastID})
+	}
+	return meta
+}
+
+// ListSuccess writes a page of items with the fields the query selects
+func ListSuccess(w http.ResponseWriter, items interface{}, q *ListQuery) {
+	data, err := selectFields(items, q.Fields)
+	if err != nil {
+		InternalError(w, err)
+		return
+	}
+	SuccessWithMeta(w, http.StatusOK, data, q.Meta())
+}
+
+// selectFields projects items, a slice, onto fields; an empty list, or a
+// nil slice, is answered as-is (a nil slice as [])
+func selectFields(items interface{}, fields []string) (interface{}, error) {
+	raw, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		return []interface{}{}, nil
+	}
+	if len(fields) == 0 {
+		return json.RawMessage(raw), nil
+	}
+	var all []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+	selected := make([]map[string]json.RawMessage, len(all))
+	for i, item := range all {
+		selected[i] = make(map[string]json.RawMessage, len(fields))
+		for _, f := range fields {
+			if v, ok := item[f]; ok {
+				selected[i][f] = v
+			}
+		}
+	}
+	return selected, nil
+}
+
+func encodeCursor(c *listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var c listCursor
+	if err := dec.Decode(&c); err != nil {
+		return nil, err
+	}
+	// Numbers go back to SQLite as numbers, not text
+	c.Key, c.ID = cursorValue(c.Key), cursorValue(c.ID)
+	return &c, nil
+}
+
+func cursorValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+func sortKeys(spec ListSpec) []string {
+	keys := make([]string, 0, len(spec.Sorts))
+	for k := range spec.Sorts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var testSpec = ListSpec{
+	Sorts:        map[string]string{"created_at": "COALESCE(created_at, '')", "size": "size"},
+	DefaultSort:  "-created_at",
+	ID:           "id",
+	Fields:       []string{"id", "name", "size"},
+	DefaultLimit: 2,
+	MaxLimit:     10,
+}
+
+func parse(t *testing.T, query string) (*ListQuery, *httptest.ResponseRecorder) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	q, _ := ParseList(rec, httptest.NewRequest("GET", "/items?"+query, nil), testSpec)
+	return q, rec
+}
+
+func TestParseList(t *testing.T) {
+	q, _ := parse(t, "")
+	if q.Limit != 2 || q.OrderBy() != "COALESCE(created_at, '') DESC, id DESC" || len(q.Fields) != 0 {
+		t.Errorf("Unexpected defaults: %+v", q)
+	}
+	if where, _ := q.Where(); where != "" {
+		t.Errorf("Expected no condition on the first page, got %q", where)
+	}
+
+	q, _ = parse(t, "limit=500&sort=size&fields=id,+name")
+	if q.Limit != 10 || q.OrderBy() != "size ASC, id ASC" || !reflect.DeepEqual(q.Fields, []string{"id", "name"}) {
+		t.Errorf("Unexpected query: %+v", q)
+	}
+	if q.Wants("size") || !q.Wants("name") {
+		t.Error("Expected only the selected fields wanted")
+	}
+
+	for _, query := range []string{"limit=-1", "limit=x", "sort=name", "fields=id,secret", "cursor=!!!", "offset=-3"} {
+		if q, rec := parse(t, query); q != nil || rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rec.Code)
+		}
+	}
+}
+
+func TestListQueryCursor(t *testing.T) {
+	q, _ := parse(t, "sort=size")
+	for i, size := range []int64{5, 7, 9} {
+		if q.Next(size, int64(i+1)) != (i < 2) {
+			t.Errorf("Next(%d) answered wrongly", i)
+		}
+	}
+	meta := q.Meta()
+	if !meta.HasMore || meta.NextCursor == "" {
+		t.Fatalf("Expected another page, got %+v", meta)
+	}
+
+	q, rec := parse(t, "sort=size&cursor="+meta.NextCursor)
+	if q == nil {
+		t.Fatalf("Expected the cursor accepted: %s", rec.Body)
+	}
+	where, args := q.Where()
+	if where != "(size > ? OR (size = ? AND id > ?))" || !reflect.DeepEqual(args, []interface{}{int64(7), int64(7), int64(2)}) {
+		t.Errorf("Unexpected condition: %s %v", where, args)
+	}
+
+	// The cursor belongs to its sort
+	if q, _ := parse(t, "sort=-size&cursor="+meta.NextCursor); q != nil {
+		t.Error("Expected a cursor from another sort refused")
+	}
+
+	q, _ = parse(t, "")
+	q.Next("2026-01-01", int64(1))
+	if meta := q.Meta(); meta.HasMore || meta.NextCursor != "" {
+		t.Errorf("Expected the last page, got %+v", meta)
+	}
+}
+
+func TestListSuccess(t *testing.T) {
+	type item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+		Size int    `json:"size"`
+	}
+	q, _ := parse(t, "fields=name")
+	rec := httptest.NewRecorder()
+	ListSuccess(rec, []item{{1, "a", 3}}, q)
+
+	var body struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta ListMeta                 `json:"meta"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.Data) != 1 || len(body.Data[0]) != 1 || body.Data[0]["name"] != "a" || body.Meta.Limit != 2 {
+		t.Errorf("Unexpected response: %s", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	var none []item
+	ListSuccess(rec, none, q)
+	if json.Unmarshal(rec.Body.Bytes(), &body); body.Data == nil {
+		t.Errorf("Expected [] for no items, got %s", rec.Body)
+	}
+}
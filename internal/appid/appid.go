@@ -16,6 +16,8 @@ const (
 	PrefixToken   = "tok"
 	PrefixSession = "ses"
 	PrefixInvite  = "inv"
+	PrefixVisitor = "vis"
+	PrefixAppUser = "apu"
 
 	// Base62 alphabet for new IDs (case-sensitive, URL-safe)
 	base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
@@ -59,6 +61,16 @@ func GenerateInvite() string {
 	return Generate(PrefixInvite)
 }
 
+// GenerateVisitor creates a new anonymous visitor ID: fazt_vis_<12 chars>
+func GenerateVisitor() string {
+	return Generate(PrefixVisitor)
+}
+
+// GenerateAppUser creates a new app end-user ID: fazt_apu_<12 chars>
+func GenerateAppUser() string {
+	return Generate(PrefixAppUser)
+}
+
 // IsValid checks if a string is a valid fazt ID
 func IsValid(id string) bool {
 	if !strings.HasPrefix(id, FaztPrefix) {
@@ -77,7 +89,7 @@ func IsValid(id string) bool {
 	random := parts[1]
 
 	// Validate type prefix
-	validTypes := []string{PrefixUser, PrefixApp, PrefixToken, PrefixSession, PrefixInvite}
+	validTypes := []string{PrefixUser, PrefixApp, PrefixToken, PrefixSession, PrefixInvite, PrefixVisitor, PrefixAppUser}
 	typeValid := false
 	for _, t := range validTypes {
 		if typePrefix == t {
@@ -0,0 +1,275 @@
+// Package approval implements four-eyes approval of destructive operations.
+//
+// With approvals required (auth.require_approval), removing an app, deleting
+// a user or changing server config doesn't happen right away: the request is
+// queued as pending, and runs once a second admin approves it. Whoever asked
+// can't approve their own request, but may withdraw it by rejecting it.
+// Pending requests expire after a week.
+package approval
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operations that need approval
+const (
+	OpAppRemove    = "app.remove"
+	OpUserDelete   = "user.delete"
+	OpConfigChange = "config.change"
+)
+
+// Approval statuses. Approved requests move on to executed or failed once
+// their operation has run.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusExecuted = "executed"
+	StatusFailed   = "failed"
+	StatusRejected = "rejected"
+	StatusExpired  = "expired"
+)
+
+// TTL is how long a request waits for a decision
+const TTL = 7 * 24 * time.Hour
+
+// Common errors
+var (
+	ErrNotFound         = errors.New("approval not found")
+	ErrNotPending       = errors.New("approval has already been decided")
+	ErrExpired          = errors.New("approval has expired")
+	ErrSelfApproval     = errors.New("a request must be approved by someone other than its requester")
+	ErrUnknownOperation = errors.New("unknown operation")
+	ErrRequesterUnknown = errors.New("requester is required")
+)
+
+// Executor performs an approved operation
+type Executor func(params map[string]string) error
+
+var (
+	required atomic.Bool
+
+	executorsMu sync.RWMutex
+	executors   = make(map[string]Executor)
+)
+
+// Required reports whether destructive operations need approval
+func Required() bool {
+	return required.Load()
+}
+
+// SetRequired turns approvals on or off
+func SetRequired(on bool) {
+	required.Store(on)
+}
+
+// Register sets the executor that runs an operation once approved
+func Register(op string, fn Executor) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	executors[op] = fn
+}
+
+func executor(op string) Executor {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	return executors[op]
+}
+
+// Approval is a queued operation
+type Approval struct {
+	ID          int64             `json:"id"`
+	Operation   string            `json:"operation"`
+	Target      string            `json:"target"`
+	Summary     string            `json:"summary"`
+	Params      map[string]string `json:"params"`
+	Status      string            `json:"status"`
+	RequestedBy string            `json:"requested_by"`
+	DecidedBy   string            `json:"decided_by,omitempty"`
+	DecidedAt   *int64            `json:"decided_at,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   int64             `json:"created_at"`
+	ExpiresAt   int64             `json:"expires_at"`
+}
+
+// Request describes an operation to queue
+type Request struct {
+	Operation   string
+	Target      string
+	Summary     string
+	Params      map[string]string
+	RequestedBy string
+}
+
+// Submit queues an operation for approval
+func Submit(db *sql.DB, req Request, now time.Time) (*Approval, error) {
+	if executor(req.Operation) == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperation, req.Operation)
+	}
+	if req.RequestedBy == "" {
+		return nil, ErrRequesterUnknown
+	}
+	if req.Params == nil {
+		req.Params = map[string]string{}
+	}
+
+	params, _ := json.Marshal(req.Params)
+	res, err := db.Exec(`
+		INSERT INTO approvals (operation, target, summary, params, status, requested_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, req.Operation, req.Target, req.Summary, string(params), StatusPending, req.RequestedBy,
+		now.Unix(), now.Add(TTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := res.LastInsertId()
+	return Get(db, id, now)
+}
+
+// Get returns a single approval
+func Get(db *sql.DB, id int64, now time.Time) (*Approval, error) {
+	a, err := scanApproval(db.QueryRow(`
+		SELECT id, operation, target, summary, params, status, requested_by,
+			decided_by, decided_at, error, created_at, expires_at
+		FROM approvals WHERE id = ?
+	`, id), now)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// List returns up to 200 approvals newest first, optionally only those with
+// a status. Pending requests past their expiry count as expired.
+func List(db *sql.DB, status string, now time.Time) ([]Approval, error) {
+	where, args := "", []interface{}{}
+	switch status {
+	case "":
+	case StatusPending:
+		where, args = "WHERE status = ? AND expires_at > ?", []interface{}{StatusPending, now.Unix()}
+	case StatusExpired:
+		where = "WHERE status = ? OR (status = ? AND expires_at <= ?)"
+		args = []interface{}{StatusExpired, StatusPending, now.Unix()}
+	default:
+		where, args = "WHERE status = ?", []interface{}{status}
+	}
+
+	rows, err := db.Query(`
+		SELECT id, operation, target, summary, params, status, requested_by,
+			decided_by, decided_at, error, created_at, expires_at
+		FROM approvals `+where+` ORDER BY id DESC LIMIT 200
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := []Approval{}
+	for rows.Next() {
+		a, err := scanApproval(rows, now)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, *a)
+	}
+	return approvals, rows.Err()
+}
+
+// Approve runs a pending operation on behalf of a second admin. The returned
+// approval records whether the operation succeeded.
+func Approve(db *sql.DB, id int64, by string, now time.Time) (*Approval, error) {
+	a, err := claim(db, id, by, StatusApproved, now)
+	if err != nil {
+		return nil, err
+	}
+
+	status, errMsg := StatusExecuted, ""
+	if fn := executor(a.Operation); fn == nil {
+		status, errMsg = StatusFailed, ErrUnknownOperation.Error()
+	} else if err := fn(a.Params); err != nil {
+		status, errMsg = StatusFailed, err.Error()
+	}
+
+	if _, err := db.Exec(`UPDATE approvals SET status = ?, error = ? WHERE id = ?`,
+		status, nullString(errMsg), id); err != nil {
+		return nil, err
+	}
+	return Get(db, id, now)
+}
+
+// Reject turns down a pending operation. Requesters may reject (withdraw)
+// their own requests.
+func Reject(db *sql.DB, id int64, by string, now time.Time) (*Approval, error) {
+	if _, err := claim(db, id, by, StatusRejected, now); err != nil {
+		return nil, err
+	}
+	return Get(db, id, now)
+}
+
+// claim moves a pending approval to status, so only one decision is made
+func claim(db *sql.DB, id int64, by, status string, now time.Time) (*Approval, error) {
+	a, err := Get(db, id, now)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case a.Status == StatusExpired:
+		return nil, ErrExpired
+	case a.Status != StatusPending:
+		return nil, ErrNotPending
+	case status == StatusApproved && by == a.RequestedBy:
+		return nil, ErrSelfApproval
+	}
+
+	res, err := db.Exec(`
+		UPDATE approvals SET status = ?, decided_by = ?, decided_at = ?
+		WHERE id = ? AND status = ? AND expires_at > ?
+	`, status, by, now.Unix(), id, StatusPending, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotPending
+	}
+	return a, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanApproval reads a row, reporting pending requests past their expiry
+// as expired
+func scanApproval(row rowScanner, now time.Time) (*Approval, error) {
+	var a Approval
+	var params string
+	var decidedBy, errMsg sql.NullString
+	var decidedAt sql.NullInt64
+	if err := row.Scan(&a.ID, &a.Operation, &a.Target, &a.Summary, &params, &a.Status, &a.RequestedBy,
+		&decidedBy, &decidedAt, &errMsg, &a.CreatedAt, &a.ExpiresAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(params), &a.Params)
+	if a.Params == nil {
+		a.Params = map[string]string{}
+	}
+	a.DecidedBy = decidedBy.String
+	a.Error = errMsg.String
+	if decidedAt.Valid {
+		a.DecidedAt = &decidedAt.Int64
+	}
+	if a.Status == StatusPending && a.ExpiresAt <= now.Unix() {
+		a.Status = StatusExpired
+	}
+	return &a, nil
+}
@@ -0,0 +1,120 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestApprove(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	var ran []string
+	Register("test.op", func(params map[string]string) error {
+		if params["fail"] == "true" {
+			return errors.New("boom")
+		}
+		ran = append(ran, params["id"])
+		return nil
+	})
+
+	if _, err := Submit(db, Request{Operation: "test.unknown", RequestedBy: "alice"}, now); !errors.Is(err, ErrUnknownOperation) {
+		t.Errorf("Expected ErrUnknownOperation, got %v", err)
+	}
+
+	a, err := Submit(db, Request{Operation: "test.op", Target: "x", Params: map[string]string{"id": "x"}, RequestedBy: "alice"}, now)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if a.Status != StatusPending || a.ExpiresAt != now.Add(TTL).Unix() {
+		t.Errorf("Expected a pending approval expiring in a week, got %+v", a)
+	}
+
+	if _, err := Approve(db, a.ID, "alice", now); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("Expected ErrSelfApproval, got %v", err)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("Expected nothing to run before approval, ran %v", ran)
+	}
+
+	a, err = Approve(db, a.ID, "bob", now)
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if a.Status != StatusExecuted || a.DecidedBy != "bob" || len(ran) != 1 {
+		t.Errorf("Expected the operation executed once by bob, got %+v (ran %v)", a, ran)
+	}
+	if _, err := Approve(db, a.ID, "carol", now); !errors.Is(err, ErrNotPending) {
+		t.Errorf("Expected ErrNotPending, got %v", err)
+	}
+
+	failing, _ := Submit(db, Request{Operation: "test.op", Params: map[string]string{"fail": "true"}, RequestedBy: "alice"}, now)
+	failing, err = Approve(db, failing.ID, "bob", now)
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if failing.Status != StatusFailed || failing.Error != "boom" {
+		t.Errorf("Expected a failed operation with its error, got %+v", failing)
+	}
+}
+
+func TestRejectAndExpire(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	Register("test.op", func(map[string]string) error { return nil })
+
+	withdrawn, _ := Submit(db, Request{Operation: "test.op", RequestedBy: "alice"}, now)
+	withdrawn, err := Reject(db, withdrawn.ID, "alice", now)
+	if err != nil {
+		t.Fatalf("Expected requesters to withdraw their own request, got %v", err)
+	}
+	if withdrawn.Status != StatusRejected {
+		t.Errorf("Expected rejected, got %s", withdrawn.Status)
+	}
+
+	stale, _ := Submit(db, Request{Operation: "test.op", RequestedBy: "alice"}, now)
+	later := now.Add(TTL)
+	if _, err := Approve(db, stale.ID, "bob", later); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+
+	pending, err := List(db, StatusPending, later)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending approvals after expiry, got %d", len(pending))
+	}
+	if expired, _ := List(db, StatusExpired, later); len(expired) != 1 {
+		t.Errorf("Expected 1 expired approval, got %d", len(expired))
+	}
+	if _, err := Get(db, 999, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestListFiltersBeforeLimit(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	Register("test.op", func(map[string]string) error { return nil })
+
+	old, _ := Submit(db, Request{Operation: "test.op", RequestedBy: "alice"}, now)
+	for i := 0; i < 210; i++ {
+		a, _ := Submit(db, Request{Operation: "test.op", RequestedBy: "alice"}, now)
+		Reject(db, a.ID, "alice", now)
+	}
+
+	pending, err := List(db, StatusPending, now)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != old.ID {
+		t.Errorf("Expected the old pending approval behind 210 newer ones, got %d", len(pending))
+	}
+	if all, _ := List(db, "", now); len(all) != 200 {
+		t.Errorf("Expected the unfiltered list capped at 200, got %d", len(all))
+	}
+}
@@ -0,0 +1,699 @@
+// Package apptest runs an app's *.test.js files against its serverless
+// handlers before deploy (fazt app test). Each test gets a fresh in-memory
+// database holding the app's files, so fazt.app storage starts empty, and
+// fazt.net.fetch is stubbed: handlers only see responses registered with
+// mockFetch() or recorded in the app's fetch.fixtures.json. Requests go
+// through the same handler as production, so file-based routes, modules
+// and auth behave as deployed.
+package apptest
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/crashloop"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/egress"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/runtime"
+	"github.com/fazt-sh/fazt/internal/storage"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed prelude.js
+var prelude string
+
+const preludeName = "prelude.js"
+
+// DefaultTimeout bounds one test, including its hooks
+const DefaultTimeout = 30 * time.Second
+
+// Test outcomes
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Options control a run
+type Options struct {
+	Filter  string        // only run tests whose name contains this
+	Timeout time.Duration // per test; DefaultTimeout when zero
+	Record  bool          // send unmocked fetches and save their responses as fixtures
+}
+
+// Report is the outcome of a run
+type Report struct {
+	App        string       `json:"app"`
+	Passed     int          `json:"passed"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	Files      []FileResult `json:"files"`
+	Coverage   []Handler    `json:"coverage"`
+	Recorded   int          `json:"recorded,omitempty"` // fetches saved as fixtures
+	DurationMs int64        `json:"duration_ms"`
+}
+
+// OK reports whether every test passed and every file loaded
+func (r *Report) OK() bool {
+	return r.Failed == 0
+}
+
+// Covered counts the handlers at least one test requested
+func (r *Report) Covered() int {
+	n := 0
+	for _, h := range r.Coverage {
+		if h.Hits > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// FileResult holds the tests of one *.test.js file
+type FileResult struct {
+	File  string   `json:"file"`
+	Error string   `json:"error,omitempty"` // the file itself failed to run
+	Tests []Result `json:"tests"`
+}
+
+// Result is the outcome of one test
+type Result struct {
+	Name       string   `json:"name"`
+	Status     string   `json:"status"`
+	Error      string   `json:"error,omitempty"`
+	Logs       []string `json:"logs,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// Handler is one method of a route file (or api/main.js) and how many test
+// requests reached it
+type Handler struct {
+	Method string `json:"method"` // "*" for api/main.js and WASI routes
+	Route  string `json:"route"`
+	File   string `json:"file"`
+	Hits   int    `json:"hits"`
+}
+
+// Discover returns the *.test.js files under dir, relative to it
+func Discover(dir string) ([]string, error) {
+	var tests []string
+	err := walkApp(dir, func(rel string) {
+		if isTestFile(rel) {
+			tests = append(tests, rel)
+		}
+	})
+	sort.Strings(tests)
+	return tests, err
+}
+
+// Run runs every test file in the app at dir
+func Run(dir string, opts Options) (*Report, error) {
+	start := time.Now()
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	r := &runner{
+		dir:   dir,
+		app:   appName(dir),
+		files: make(map[string][]byte),
+		fetch: &fetchStub{},
+		hits:  make(map[string]int),
+		opts:  opts,
+	}
+	var tests []string
+	err := walkApp(dir, func(rel string) {
+		if isTestFile(rel) {
+			tests = append(tests, rel)
+		} else if rel == egress.FixturesFile {
+			return // Not deployed
+		} else if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+			r.files[rel] = data
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(tests)
+
+	fixturesPath := filepath.Join(dir, egress.FixturesFile)
+	fixtures, err := egress.LoadFixtures(fixturesPath)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Record || fixtures.Len() > 0 {
+		r.fetch.fixtures, r.fetch.recording = fixtures, opts.Record
+	}
+
+	report := &Report{App: r.app, Files: []FileResult{}}
+	for _, file := range tests {
+		res := r.runFile(file)
+		for _, t := range res.Tests {
+			switch t.Status {
+			case StatusPass:
+				report.Passed++
+			case StatusFail:
+				report.Failed++
+			case StatusSkip:
+				report.Skipped++
+			}
+		}
+		if res.Error != "" {
+			report.Failed++
+		}
+		report.Files = append(report.Files, res)
+	}
+
+	report.Coverage = r.coverage()
+	if r.fetch.newFixtures > 0 {
+		if err := fixtures.Save(fixturesPath); err != nil {
+			return nil, err
+		}
+		report.Recorded = r.fetch.newFixtures
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+// runner holds the state shared by a run's test files
+type runner struct {
+	dir   string
+	app   string
+	files map[string][]byte // the app as deployed, without test files
+	fetch *fetchStub
+	hits  map[string]int // handler key -> requests
+	opts  Options
+
+	// Per test
+	db      *sql.DB
+	handler *runtime.ServerlessHandler
+	current *Result
+}
+
+// runFile loads one test file and runs its tests in order
+func (r *runner) runFile(file string) FileResult {
+	res := FileResult{File: file, Tests: []Result{}}
+	source, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(file)))
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	vm := goja.New()
+	if err := r.setupVM(vm, file); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	// Top-level code may already use storage or send requests
+	if err := r.reset(vm); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer r.close()
+
+	r.current = &Result{}
+	program, err := modules.CompileScript(r.app, file, string(source))
+	if err == nil {
+		err = r.withTimeout(vm, func() error {
+			_, err := vm.RunProgram(program)
+			return err
+		})
+	}
+	if err != nil {
+		res.Error = errorText(err)
+		return res
+	}
+
+	tests := vm.Get("__faztTests").ToObject(vm)
+	plan, _ := goja.AssertFunction(vm.Get("__faztPlan"))
+	n := int(tests.Get("length").ToInteger())
+	for i := 0; i < n; i++ {
+		t := tests.Get(fmt.Sprint(i)).ToObject(vm)
+		result := Result{Name: t.Get("name").String(), Status: StatusPass}
+		if r.opts.Filter != "" && !strings.Contains(result.Name, r.opts.Filter) {
+			continue
+		}
+		if t.Get("skip").ToBoolean() {
+			result.Status = StatusSkip
+			res.Tests = append(res.Tests, result)
+			continue
+		}
+
+		started := time.Now()
+		r.current = &result
+		if err := r.reset(vm); err != nil {
+			result.Status, result.Error = StatusFail, err.Error()
+		} else if err := r.runTest(vm, plan, i); err != nil {
+			result.Status, result.Error = StatusFail, errorText(err)
+		}
+		result.DurationMs = time.Since(started).Milliseconds()
+		res.Tests = append(res.Tests, result)
+	}
+	return res
+}
+
+// runTest calls a test's beforeEach hooks, the test and its afterEach
+// hooks. afterEach runs even when the test fails; the first error wins.
+func (r *runner) runTest(vm *goja.Runtime, plan goja.Callable, i int) error {
+	return r.withTimeout(vm, func() error {
+		v, err := plan(goja.Undefined(), vm.ToValue(i))
+		if err != nil {
+			return err
+		}
+		steps := v.ToObject(vm)
+
+		for _, fn := range arrayValues(steps.Get("before")) {
+			if err = callStep(fn); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = callStep(steps.Get("fn"))
+		}
+		for _, fn := range arrayValues(steps.Get("after")) {
+			if afterErr := callStep(fn); err == nil {
+				err = afterErr
+			}
+		}
+		return err
+	})
+}
+
+// withTimeout runs fn, interrupting the VM when the test runs too long
+func (r *runner) withTimeout(vm *goja.Runtime, fn func() error) error {
+	timer := time.AfterFunc(r.opts.Timeout, func() {
+		vm.Interrupt(fmt.Errorf("timed out after %s", r.opts.Timeout))
+	})
+	defer func() {
+		timer.Stop()
+		vm.ClearInterrupt()
+	}()
+	return fn()
+}
+
+// callStep calls a test or hook. Async functions have settled by the time
+// the call returns, as fazt's bindings are synchronous.
+func callStep(fn goja.Value) error {
+	call, ok := goja.AssertFunction(fn)
+	if !ok {
+		return fmt.Errorf("test or hook is not a function")
+	}
+	v, err := call(goja.Undefined())
+	if err != nil {
+		return err
+	}
+	if p, ok := v.Export().(*goja.Promise); ok {
+		switch p.State() {
+		case goja.PromiseStateRejected:
+			return rejection(p.Result())
+		case goja.PromiseStatePending:
+			return fmt.Errorf("returned a promise that never settled")
+		}
+	}
+	return nil
+}
+
+// setupVM installs the test globals: the prelude (test, expect, ...),
+// fazt.app, require(), request(), mockFetch() and fetchCalls()
+func (r *runner) setupVM(vm *goja.Runtime, file string) error {
+	if _, err := vm.RunScript(preludeName, prelude); err != nil {
+		return fmt.Errorf("prelude: %w", err)
+	}
+	app := &runtime.AppContext{ID: r.app, Name: r.app}
+	if err := runtime.InjectFaztNamespace(vm, app, runtime.EnvVars{}, &runtime.ExecuteResult{}); err != nil {
+		return err
+	}
+	modules.Enable(vm, r.app, func(p string) (string, error) {
+		data, ok := r.files[p]
+		if !ok {
+			return "", fs.ErrNotExist
+		}
+		return string(data), nil
+	}, path.Dir(file))
+
+	console := vm.NewObject()
+	for _, level := range []string{"log", "info", "warn", "error", "debug"} {
+		console.Set(level, func(call goja.FunctionCall) goja.Value {
+			parts := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				parts[i] = arg.String()
+			}
+			if r.current != nil {
+				r.current.Logs = append(r.current.Logs, strings.Join(parts, " "))
+			}
+			return goja.Undefined()
+		})
+	}
+	vm.Set("console", console)
+
+	vm.Set("request", func(call goja.FunctionCall) goja.Value {
+		resp, err := r.request(vm, call.Argument(0).String(), call.Argument(1).String(), call.Argument(2))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return resp
+	})
+	vm.Set("mockFetch", func(pattern string, resp map[string]interface{}) {
+		if err := r.fetch.mock(pattern, resp); err != nil {
+			panic(vm.NewGoError(err))
+		}
+	})
+	vm.Set("fetchCalls", func() []map[string]interface{} {
+		calls := []map[string]interface{}{}
+		for _, c := range r.fetch.recorded() {
+			calls = append(calls, map[string]interface{}{
+				"method": c.Method, "url": c.URL, "headers": c.Headers, "body": c.Body,
+			})
+		}
+		return calls
+	})
+	return nil
+}
+
+// reset gives the next test an empty database with the app's files, a new
+// handler and no fetch mocks
+func (r *runner) reset(vm *goja.Runtime) error {
+	r.close()
+	r.fetch.reset()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(1)
+	if err := database.RunMigrations(db); err != nil {
+		db.Close()
+		return fmt.Errorf("migrations: %w", err)
+	}
+	for p, data := range r.files {
+		sum := sha256.Sum256(data)
+		if _, err := db.Exec(`
+			INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, r.app, r.app, p, string(data), len(data), mime.TypeByExtension(path.Ext(p)), hex.EncodeToString(sum[:])); err != nil {
+			db.Close()
+			return fmt.Errorf("load %s: %w", p, err)
+		}
+	}
+	hosting.LoadAPIRoutes(db, r.app)
+
+	h := runtime.NewServerlessHandlerWithRuntime(db, runtime.NewRuntime(1, runtime.DefaultTimeout))
+	h.SetAuthProvider(testAuth{})
+	h.AddInjector(r.fetch.inject)
+	r.db, r.handler = db, h
+
+	return storage.InjectAppNamespace(vm, db, nil, r.app, "", context.Background(), nil)
+}
+
+func (r *runner) close() {
+	if r.db != nil {
+		r.db.Close()
+		r.db, r.handler = nil, nil
+	}
+}
+
+// request sends a request to the app's handlers:
+//
+//	request("POST", "/api/items", {body, headers, query, user})
+//
+// and returns {status, headers, body, text}; body is parsed when JSON
+func (r *runner) request(vm *goja.Runtime, method, target string, optsVal goja.Value) (goja.Value, error) {
+	method = strings.ToUpper(method)
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("request: invalid path %q", target)
+	}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+
+	var opts struct {
+		Body    interface{}            `json:"body"`
+		Headers map[string]interface{} `json:"headers"`
+		Query   map[string]interface{} `json:"query"`
+		User    map[string]interface{} `json:"user"`
+	}
+	if obj, ok := optsVal.(*goja.Object); ok {
+		data, err := json.Marshal(obj.Export())
+		if err != nil {
+			return nil, fmt.Errorf("request options: %w", err)
+		}
+		if err := json.Unmarshal(data, &opts); err != nil {
+			return nil, fmt.Errorf("request options: %w", err)
+		}
+	}
+
+	q := u.Query()
+	for k, v := range opts.Query {
+		q.Set(k, fmt.Sprint(v))
+	}
+	u.RawQuery = q.Encode()
+
+	var body string
+	contentType := ""
+	switch b := opts.Body.(type) {
+	case nil:
+	case string:
+		body = b
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+		body, contentType = string(data), "application/json"
+	}
+
+	req := httptest.NewRequest(method, "http://"+r.app+".localhost"+u.RequestURI(), strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range opts.Headers {
+		req.Header.Set(k, fmt.Sprint(v))
+	}
+	if opts.User != nil {
+		if _, ok := opts.User["id"]; !ok {
+			opts.User["id"] = "test-user"
+		}
+		req = req.WithContext(context.WithValue(req.Context(), userKey{}, opts.User))
+	}
+
+	r.hit(method, u.Path)
+	// A test may crash the handler on purpose; don't let it isolate the app
+	crashloop.Reset(r.app)
+	w := httptest.NewRecorder()
+	r.handler.HandleRequest(w, req, r.app, r.app)
+
+	headers := make(map[string]string)
+	for k := range w.Header() {
+		headers[strings.ToLower(k)] = w.Header().Get(k)
+	}
+	text := w.Body.String()
+	var parsed interface{} = text
+	if strings.Contains(headers["content-type"], "application/json") {
+		var v interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &v); err == nil {
+			parsed = v
+		}
+	}
+
+	resp := vm.NewObject()
+	resp.Set("status", w.Code)
+	resp.Set("headers", headers)
+	resp.Set("body", parsed)
+	resp.Set("text", text)
+	return resp, nil
+}
+
+// hit records which handler a request will reach
+func (r *runner) hit(method, urlPath string) {
+	if route, _, ok := hosting.APIRoutesFor(r.db, r.app).Match(urlPath); ok {
+		if !route.Allows(method) {
+			return
+		}
+		if route.WASI {
+			method = "*"
+		} else if method == "HEAD" && !containsString(route.Methods, "HEAD") {
+			method = "GET"
+		}
+		r.hits[method+" "+route.File]++
+		return
+	}
+	if _, ok := r.files["api/main.js"]; ok {
+		r.hits["* api/main.js"]++
+	}
+}
+
+// coverage lists every handler the app has with its hits
+func (r *runner) coverage() []Handler {
+	sources := make(map[string]string)
+	for p, data := range r.files {
+		if strings.HasPrefix(p, "api/") && (strings.HasSuffix(p, ".js") || strings.HasSuffix(p, ".wasm")) {
+			sources[p] = string(data)
+		}
+	}
+
+	handlers := []Handler{}
+	for _, route := range hosting.BuildAPIRouteTable(sources).Routes {
+		if route.WASI {
+			handlers = append(handlers, Handler{Method: "*", Route: route.Pattern, File: route.File, Hits: r.hits["* "+route.File]})
+			continue
+		}
+		for _, m := range route.Methods {
+			handlers = append(handlers, Handler{Method: m, Route: route.Pattern, File: route.File, Hits: r.hits[m+" "+route.File]})
+		}
+	}
+	if _, ok := r.files["api/main.js"]; ok {
+		handlers = append(handlers, Handler{Method: "*", Route: "/api/*", File: "api/main.js", Hits: r.hits["* api/main.js"]})
+	}
+	return handlers
+}
+
+// userKey carries request()'s user to testAuth
+type userKey struct{}
+
+// testAuth signs requests in as the user passed to request()
+type testAuth struct{}
+
+func (testAuth) GetSessionFromRequest(r *http.Request) (interface{}, error) {
+	if user, ok := r.Context().Value(userKey{}).(map[string]interface{}); ok {
+		return user, nil
+	}
+	return nil, errors.New("not signed in")
+}
+
+func (testAuth) Domain() string {
+	return "localhost"
+}
+
+// walkApp calls fn with the slash path of each app file, skipping
+// node_modules and dot files as deploys do
+func walkApp(dir string, fn func(rel string)) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if rel == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			fn(filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+func isTestFile(rel string) bool {
+	return strings.HasSuffix(rel, ".test.js")
+}
+
+// appName is the manifest's name, or the directory's
+func appName(dir string) string {
+	var manifest struct {
+		Name string `json:"name"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "manifest.json")); err == nil {
+		if json.Unmarshal(data, &manifest) == nil && manifest.Name != "" {
+			return manifest.Name
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return filepath.Base(abs)
+	}
+	return "app"
+}
+
+// errorText is an error as a test failure message, located at the test
+// file line that threw rather than inside expect()
+func errorText(err error) string {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return fmt.Sprint(interrupted.Value())
+	}
+	var exc *goja.Exception
+	if errors.As(err, &exc) && exc.Value() != nil {
+		msg := exc.Value().String()
+		for _, frame := range exc.Stack() {
+			if name := frame.SrcName(); name != "" && name != preludeName {
+				return msg + " (" + frame.Position().String() + ")"
+			}
+		}
+		return msg
+	}
+	return err.Error()
+}
+
+// rejection is the error for a rejected promise. Its stack is only
+// available as text: "Error: msg\n\tat fn (file:line:col(pc))\n...".
+func rejection(v goja.Value) error {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return errors.New(v.String())
+	}
+	stack := obj.Get("stack")
+	if stack == nil || goja.IsUndefined(stack) {
+		return errors.New(v.String())
+	}
+	lines := strings.Split(stack.String(), "\n")
+	for _, line := range lines[1:] {
+		loc := strings.TrimPrefix(strings.TrimSpace(line), "at ")
+		if i := strings.Index(loc, " ("); i >= 0 {
+			loc = strings.TrimSuffix(loc[i+2:], ")")
+		}
+		if i := strings.LastIndex(loc, "("); i > 0 {
+			loc = loc[:i]
+		}
+		if loc != "" && !strings.HasPrefix(loc, preludeName) {
+			return fmt.Errorf("%s (%s)", lines[0], loc)
+		}
+	}
+	return errors.New(lines[0])
+}
+
+func arrayValues(v goja.Value) []goja.Value {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	n := int(obj.Get("length").ToInteger())
+	values := make([]goja.Value, n)
+	for i := range values {
+		values[i] = obj.Get(fmt.Sprint(i))
+	}
+	return values
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
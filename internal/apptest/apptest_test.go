@@ -0,0 +1,221 @@
+package apptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeApp creates an app directory from path -> content
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for p, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func results(report *Report) map[string]Result {
+	out := make(map[string]Result)
+	for _, f := range report.Files {
+		for _, r := range f.Tests {
+			out[r.Name] = r
+		}
+	}
+	return out
+}
+
+func TestDiscover(t *testing.T) {
+	dir := writeApp(t, map[string]string{
+		"api/items.js":                  "exports.GET = function() {}",
+		"api/items.test.js":             "",
+		"tests/a.test.js":               "",
+		"node_modules/x/x.test.js":      "",
+		".git/hooks/pre-commit.test.js": "",
+	})
+
+	files, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if got := strings.Join(files, ","); got != "api/items.test.js,tests/a.test.js" {
+		t.Errorf("Discover = %s", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := writeApp(t, map[string]string{
+		"manifest.json": `{"name": "shop"}`,
+		"api/items/index.js": `
+exports.GET = function() {
+  return respond({ items: fazt.app.ds.find("items", {}) });
+};
+exports.POST = function() {
+  if (!fazt.auth.getUser()) return respond(401, { error: "sign in" });
+  var id = fazt.app.ds.insert("items", request.body);
+  return respond(201, { id: id });
+};`,
+		"api/rates.js": `
+exports.GET = function() {
+  var res = fazt.net.fetch("https://rates.example.com/usd");
+  return respond({ eur: res.json().eur });
+};`,
+		"api/_lib/math.js": `exports.double = function(n) { return n * 2; };`,
+		"tests/items.test.js": `
+var math = require("../api/_lib/math.js");
+
+describe("items", function() {
+  beforeEach(function() { fazt.app.ds.insert("items", { name: "seed" }); });
+
+  test("lists seeded items", function() {
+    var res = request("GET", "/api/items");
+    expect(res.status).toBe(200);
+    expect(res.body.items).toHaveLength(1);
+  });
+
+  test("requires sign-in to create", function() {
+    expect(request("POST", "/api/items", { body: { name: "x" } }).status).toBe(401);
+    var res = request("POST", "/api/items", { body: { name: "x" }, user: { id: "u1" } });
+    expect(res.status).toBe(201);
+    expect(request("GET", "/api/items").body.items).toHaveLength(2);
+  });
+
+  test("fails", function() {
+    expect(math.double(2)).toEqual(5);
+  });
+
+  test.skip("later");
+});
+
+test("mocks fetch", async function() {
+  mockFetch("GET https://rates.example.com/*", { body: { eur: 0.9 } });
+  var res = request("GET", "/api/rates");
+  expect(res.body).toEqual({ eur: 0.9 });
+  expect(fetchCalls()[0].url).toBe("https://rates.example.com/usd");
+});
+
+test("unmocked fetch fails the handler", function() {
+  var res = request("GET", "/api/rates");
+  expect(res.status).toBe(500);
+  expect(res.body.error).toContain("no mock matches");
+});`,
+		"tests/broken.test.js": `test("x", function() {`,
+	})
+
+	report, err := Run(dir, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.App != "shop" {
+		t.Errorf("App = %q, want shop", report.App)
+	}
+
+	got := results(report)
+	for name, status := range map[string]string{
+		"items > lists seeded items":         StatusPass,
+		"items > requires sign-in to create": StatusPass,
+		"items > fails":                      StatusFail,
+		"items > later":                      StatusSkip,
+		"mocks fetch":                        StatusPass,
+		"unmocked fetch fails the handler":   StatusPass,
+	} {
+		if got[name].Status != status {
+			t.Errorf("%s: status %q (%s), want %q", name, got[name].Status, got[name].Error, status)
+		}
+	}
+	if !strings.Contains(got["items > fails"].Error, "toEqual(5)") {
+		t.Errorf("Unexpected failure message: %s", got["items > fails"].Error)
+	}
+
+	// The syntax error fails its file
+	if report.Passed != 4 || report.Failed != 2 || report.Skipped != 1 || report.OK() {
+		t.Errorf("Counts = %d passed, %d failed, %d skipped", report.Passed, report.Failed, report.Skipped)
+	}
+
+	hits := make(map[string]int)
+	for _, h := range report.Coverage {
+		hits[h.Method+" "+h.Route] = h.Hits
+	}
+	if len(hits) != 3 || hits["GET /api/items"] != 2 || hits["POST /api/items"] != 2 || hits["GET /api/rates"] != 2 {
+		t.Errorf("Coverage = %+v", report.Coverage)
+	}
+	if report.Covered() != 3 {
+		t.Errorf("Covered = %d, want 3", report.Covered())
+	}
+}
+
+func TestRunFilterAndTimeout(t *testing.T) {
+	dir := writeApp(t, map[string]string{
+		"app.test.js": `
+test("spins", function() { for (;;) {} });
+test("quick", function() {});`,
+	})
+
+	report, err := Run(dir, Options{Filter: "spin", Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	got := results(report)
+	if len(got) != 1 || got["spins"].Status != StatusFail || !strings.Contains(got["spins"].Error, "timed out") {
+		t.Errorf("Results = %+v", got)
+	}
+	if len(report.Coverage) != 0 {
+		t.Errorf("Expected no handlers, got %+v", report.Coverage)
+	}
+}
+
+func TestRunRecordAndReplay(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"eur":0.9}`))
+	}))
+	defer srv.Close()
+
+	dir := writeApp(t, map[string]string{
+		"api/rates.js": `
+exports.GET = function() {
+  var res = fazt.net.fetch("` + srv.URL + `/usd");
+  return respond({ eur: res.json().eur });
+};`,
+		"rates.test.js": `
+test("converts", function() {
+  var res = request("GET", "/api/rates");
+  expect(res.status).toBe(200);
+  expect(res.body.eur).toBe(0.9);
+});`,
+	})
+
+	report, err := Run(dir, Options{Record: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.OK() || report.Recorded != 1 || hits != 1 {
+		t.Fatalf("Recording: ok=%v recorded=%d hits=%d", report.OK(), report.Recorded, hits)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "fetch.fixtures.json")); err != nil {
+		t.Fatalf("Expected a fixtures file: %v", err)
+	}
+
+	// Replays come from the file, without going out
+	srv.Close()
+	report, err = Run(dir, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !report.OK() || report.Recorded != 0 || hits != 1 {
+		t.Errorf("Replaying: ok=%v recorded=%d hits=%d (%+v)", report.OK(), report.Recorded, hits, results(report))
+	}
+}
@@ -0,0 +1,260 @@
+package apptest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+// recordTimeout bounds a live fetch made while recording fixtures
+const recordTimeout = 30 * time.Second
+
+// fetchMock is a canned response registered with mockFetch()
+type fetchMock struct {
+	method  string // "" matches any method
+	url     string
+	prefix  bool // url ended in "*"
+	status  int
+	headers map[string]string
+	body    string
+}
+
+// FetchCall is an outbound request a handler made, as seen by fetchCalls()
+type FetchCall struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// fetchStub stands in for fazt.net.fetch. Handlers can't reach the network
+// in tests: a request without a matching mock is answered from the app's
+// fixtures, and throws when there is none. While recording, such requests
+// go out and their responses become fixtures.
+type fetchStub struct {
+	mu    sync.Mutex
+	mocks []fetchMock
+	calls []FetchCall
+
+	fixtures    *egress.FixtureSet // nil: no fixtures
+	recording   bool
+	session     *egress.FixtureSession // per test
+	newFixtures int
+}
+
+func (s *fetchStub) reset() {
+	s.mu.Lock()
+	s.mocks, s.calls = nil, nil
+	if s.fixtures != nil {
+		s.session = s.fixtures.Session()
+	}
+	s.mu.Unlock()
+}
+
+// unmocked answers a request no mock matches from the fixtures, or while
+// recording from the network
+func (s *fetchStub) unmocked(req FetchCall) (egress.FixtureResponse, error) {
+	s.mu.Lock()
+	session, recording := s.session, s.recording
+	s.mu.Unlock()
+	if session == nil {
+		return egress.FixtureResponse{}, fmt.Errorf("fetch %s %s: no mock matches; register one with mockFetch(%q, {...})", req.Method, req.URL, req.Method+" "+req.URL)
+	}
+	if !recording {
+		if resp, ok := session.Replay(req.Method, req.URL, req.Body); ok {
+			return resp, nil
+		}
+		return egress.FixtureResponse{}, fmt.Errorf("fetch %s %s: no mock or fixture matches; register one with mockFetch(%q, {...}) or record it with --record", req.Method, req.URL, req.Method+" "+req.URL)
+	}
+
+	resp, err := liveFetch(req)
+	if err != nil {
+		return egress.FixtureResponse{}, err
+	}
+	session.Record(req.Method, req.URL, req.Body, resp)
+	s.mu.Lock()
+	s.newFixtures++
+	s.mu.Unlock()
+	return resp, nil
+}
+
+// liveFetch sends a request for real, to record its response
+func liveFetch(req FetchCall) (egress.FixtureResponse, error) {
+	httpReq, err := http.NewRequest(req.Method, req.URL, strings.NewReader(req.Body))
+	if err != nil {
+		return egress.FixtureResponse{}, fmt.Errorf("fetch %s %s: %w", req.Method, req.URL, err)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := (&http.Client{Timeout: recordTimeout}).Do(httpReq)
+	if err != nil {
+		return egress.FixtureResponse{}, fmt.Errorf("fetch %s %s: %w", req.Method, req.URL, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return egress.FixtureResponse{}, fmt.Errorf("fetch %s %s: %w", req.Method, req.URL, err)
+	}
+
+	headers := make(map[string]string)
+	for k, v := range resp.Header {
+		if len(v) > 0 {
+			headers[strings.ToLower(k)] = v[0]
+		}
+	}
+	return egress.FixtureResponse{Status: resp.StatusCode, Headers: headers, Body: string(body)}, nil
+}
+
+// mock registers a response for pattern: a URL, optionally preceded by a
+// method ("POST https://...") and ending in "*" to match a prefix
+func (s *fetchStub) mock(pattern string, resp map[string]interface{}) error {
+	m := fetchMock{status: 200, headers: map[string]string{}}
+	if method, rest, ok := strings.Cut(pattern, " "); ok {
+		m.method, pattern = strings.ToUpper(method), strings.TrimSpace(rest)
+	}
+	if p, ok := strings.CutSuffix(pattern, "*"); ok {
+		m.url, m.prefix = p, true
+	} else {
+		m.url = pattern
+	}
+	if m.url == "" && !m.prefix {
+		return fmt.Errorf("mockFetch needs a URL")
+	}
+
+	if status, ok := resp["status"]; ok {
+		n, ok := toInt(status)
+		if !ok || n < 100 || n > 599 {
+			return fmt.Errorf("mockFetch status must be an HTTP status code")
+		}
+		m.status = n
+	}
+	if headers, ok := resp["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			m.headers[strings.ToLower(k)] = fmt.Sprint(v)
+		}
+	}
+	switch body := resp["body"].(type) {
+	case nil:
+	case string:
+		m.body = body
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("mockFetch body: %w", err)
+		}
+		m.body = string(data)
+		if _, ok := m.headers["content-type"]; !ok {
+			m.headers["content-type"] = "application/json"
+		}
+	}
+
+	s.mu.Lock()
+	s.mocks = append(s.mocks, m)
+	s.mu.Unlock()
+	return nil
+}
+
+// match returns the most recently registered mock for a request
+func (s *fetchStub) match(method, url string) (fetchMock, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.mocks) - 1; i >= 0; i-- {
+		m := s.mocks[i]
+		if m.method != "" && m.method != method {
+			continue
+		}
+		if m.url == url || (m.prefix && strings.HasPrefix(url, m.url)) {
+			return m, true
+		}
+	}
+	return fetchMock{}, false
+}
+
+func (s *fetchStub) record(call FetchCall) {
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+}
+
+func (s *fetchStub) recorded() []FetchCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FetchCall{}, s.calls...)
+}
+
+// inject replaces fazt.net.fetch in a handler's VM. The response has the
+// same shape as the real one.
+func (s *fetchStub) inject(vm *goja.Runtime) error {
+	fazt := vm.Get("fazt").ToObject(vm)
+	netObj := vm.NewObject()
+	netObj.Set("fetch", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) == 0 {
+			panic(vm.NewGoError(fmt.Errorf("fetch requires a URL argument")))
+		}
+		req := FetchCall{Method: "GET", URL: call.Argument(0).String(), Headers: map[string]string{}}
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			if v := opts.Get("method"); v != nil && !goja.IsUndefined(v) {
+				req.Method = strings.ToUpper(v.String())
+			}
+			if v := opts.Get("body"); v != nil && !goja.IsUndefined(v) {
+				req.Body = v.String()
+			}
+			if headers, ok := opts.Get("headers").(*goja.Object); ok {
+				for _, k := range headers.Keys() {
+					req.Headers[k] = headers.Get(k).String()
+				}
+			}
+		}
+		s.record(req)
+
+		if m, ok := s.match(req.Method, req.URL); ok {
+			return fetchResponse(vm, m.status, m.headers, m.body)
+		}
+		fixture, err := s.unmocked(req)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return fetchResponse(vm, fixture.Status, fixture.Headers, fixture.Body)
+	})
+	fazt.Set("net", netObj)
+	return nil
+}
+
+// fetchResponse builds a response with the same shape as the real one
+func fetchResponse(vm *goja.Runtime, status int, headers map[string]string, body string) goja.Value {
+	resp := vm.NewObject()
+	resp.Set("status", status)
+	resp.Set("ok", status >= 200 && status < 300)
+	resp.Set("headers", headers)
+	resp.Set("text", func(goja.FunctionCall) goja.Value {
+		return vm.ToValue(body)
+	})
+	resp.Set("json", func(goja.FunctionCall) goja.Value {
+		var data interface{}
+		if err := json.Unmarshal([]byte(body), &data); err != nil {
+			panic(vm.NewGoError(fmt.Errorf("invalid JSON: %w", err)))
+		}
+		return vm.ToValue(data)
+	})
+	return resp
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), n == float64(int(n))
+	case int:
+		return n, true
+	}
+	return 0, false
+}
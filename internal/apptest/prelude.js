@@ -0,0 +1,133 @@
+// Test globals for *.test.js files. The Go runner reads __faztTests after
+// the file runs and asks __faztPlan for each test's hooks.
+var __faztTests = [];
+
+(function (global) {
+  var root = { name: "", parent: null, before: [], after: [] };
+  var scope = root;
+
+  function fullName(name) {
+    var parts = [name];
+    for (var s = scope; s && s !== root; s = s.parent) parts.unshift(s.name);
+    return parts.join(" > ");
+  }
+
+  function test(name, fn) {
+    if (typeof fn !== "function") throw new TypeError("test(\"" + name + "\") needs a function");
+    __faztTests.push({ name: fullName(String(name)), fn: fn, scope: scope, skip: false });
+  }
+  test.skip = function (name) {
+    __faztTests.push({ name: fullName(String(name)), fn: null, scope: scope, skip: true });
+  };
+
+  function describe(name, fn) {
+    var parent = scope;
+    scope = { name: String(name), parent: parent, before: [], after: [] };
+    try {
+      fn();
+    } finally {
+      scope = parent;
+    }
+  }
+
+  global.test = test;
+  global.it = test;
+  global.describe = describe;
+  global.beforeEach = function (fn) { scope.before.push(fn); };
+  global.afterEach = function (fn) { scope.after.push(fn); };
+
+  // __faztPlan returns the functions to call for test i in order: outer
+  // beforeEach hooks first, then the test, then afterEach innermost first
+  global.__faztPlan = function (i) {
+    var t = __faztTests[i];
+    var chain = [];
+    for (var s = t.scope; s; s = s.parent) chain.unshift(s);
+    var before = [], after = [];
+    chain.forEach(function (s) { before = before.concat(s.before); });
+    chain.slice().reverse().forEach(function (s) { after = after.concat(s.after); });
+    return { before: before, fn: t.fn, after: after };
+  };
+
+  function show(v) {
+    if (typeof v === "string") return JSON.stringify(v);
+    if (typeof v === "function") return "[Function]";
+    if (v === undefined) return "undefined";
+    try {
+      return JSON.stringify(v);
+    } catch (e) {
+      return String(v);
+    }
+  }
+
+  function equal(a, b) {
+    if (a === b) return true;
+    if (typeof a !== "object" || typeof b !== "object" || a === null || b === null) {
+      return a !== a && b !== b; // NaN
+    }
+    if (Array.isArray(a) !== Array.isArray(b)) return false;
+    var ka = Object.keys(a).filter(function (k) { return a[k] !== undefined; });
+    var kb = Object.keys(b).filter(function (k) { return b[k] !== undefined; });
+    if (ka.length !== kb.length) return false;
+    for (var i = 0; i < ka.length; i++) {
+      if (!Object.prototype.hasOwnProperty.call(b, ka[i]) || !equal(a[ka[i]], b[ka[i]])) return false;
+    }
+    return true;
+  }
+
+  function matchers(actual, negate) {
+    function check(pass, message) {
+      if (pass === negate) {
+        throw new Error("expect(" + show(actual) + ")" + (negate ? ".not" : "") + message);
+      }
+    }
+    var m = {
+      toBe: function (v) { check(actual === v, ".toBe(" + show(v) + ")"); },
+      toEqual: function (v) { check(equal(actual, v), ".toEqual(" + show(v) + ")"); },
+      toBeTruthy: function () { check(!!actual, ".toBeTruthy()"); },
+      toBeFalsy: function () { check(!actual, ".toBeFalsy()"); },
+      toBeNull: function () { check(actual === null, ".toBeNull()"); },
+      toBeUndefined: function () { check(actual === undefined, ".toBeUndefined()"); },
+      toBeDefined: function () { check(actual !== undefined, ".toBeDefined()"); },
+      toBeGreaterThan: function (v) { check(actual > v, ".toBeGreaterThan(" + show(v) + ")"); },
+      toBeLessThan: function (v) { check(actual < v, ".toBeLessThan(" + show(v) + ")"); },
+      toHaveLength: function (n) {
+        check(actual != null && actual.length === n, ".toHaveLength(" + n + ")");
+      },
+      toContain: function (v) {
+        var pass = typeof actual === "string" ? actual.indexOf(v) !== -1 :
+          Array.isArray(actual) && actual.some(function (x) { return equal(x, v); });
+        check(pass, ".toContain(" + show(v) + ")");
+      },
+      toMatch: function (re) {
+        var pass = typeof re === "string" ? String(actual).indexOf(re) !== -1 : re.test(String(actual));
+        check(pass, ".toMatch(" + String(re) + ")");
+      },
+      toHaveProperty: function (key, value) {
+        var pass = actual != null && Object.prototype.hasOwnProperty.call(actual, key) &&
+          (arguments.length < 2 || equal(actual[key], value));
+        check(pass, ".toHaveProperty(" + show(key) + (arguments.length < 2 ? "" : ", " + show(value)) + ")");
+      },
+      toThrow: function (expected) {
+        var thrown = null;
+        try {
+          actual();
+        } catch (e) {
+          thrown = e;
+        }
+        var pass = thrown !== null;
+        if (pass && expected !== undefined) {
+          var msg = thrown && thrown.message !== undefined ? String(thrown.message) : String(thrown);
+          pass = typeof expected === "string" ? msg.indexOf(expected) !== -1 : expected.test(msg);
+        }
+        check(pass, ".toThrow(" + (expected === undefined ? "" : String(expected)) + ")");
+      }
+    };
+    return m;
+  }
+
+  global.expect = function (actual) {
+    var m = matchers(actual, false);
+    m.not = matchers(actual, true);
+    return m;
+  };
+})(this);
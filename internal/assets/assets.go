@@ -11,3 +11,5 @@ var StdlibFS embed.FS
 //go:embed templates/**/*
 var TemplatesFS embed.FS
 
+//go:embed types/*
+var TypesFS embed.FS
@@ -43,3 +43,17 @@ func TemplateExists(name string) bool {
 	_, err := GetTemplate(name)
 	return err == nil
 }
+
+// GetTypes returns the editor support every new app gets: fazt.d.ts, the
+// types of the serverless runtime, and a jsconfig.json applying them to the
+// app's server-side code. Unlike templates they're copied as is.
+func GetTypes() fs.FS {
+	sub, _ := fs.Sub(TypesFS, "types")
+	return sub
+}
+
+// FaztTypes returns fazt.d.ts
+func FaztTypes() []byte {
+	data, _ := TypesFS.ReadFile("types/fazt.d.ts")
+	return data
+}
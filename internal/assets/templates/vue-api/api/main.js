@@ -2,7 +2,7 @@
 // All /api/* requests are routed to this file
 // Handles: /api/items and /api/items/:id
 
-var ds = fazt.storage.ds
+var ds = fazt.app.ds
 
 // Simple ID generator (timestamp + random suffix)
 function genId() {
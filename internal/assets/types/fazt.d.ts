@@ -0,0 +1,763 @@
+// Type definitions for the fazt serverless runtime: the globals handlers
+// (api/), workers, event hooks and services see when they run on fazt.
+//
+// Shipped with `fazt app create` and served by every fazt server at
+// /api/types/fazt.d.ts, so editors can autocomplete and check fazt.* calls.
+// Handlers run synchronously: calls return values, not promises.
+
+// ---------------------------------------------------------------------------
+// Requests and responses
+// ---------------------------------------------------------------------------
+
+/** A file from a multipart/form-data request */
+interface FaztFile {
+  /** Original filename, e.g. "photo.jpg" */
+  name: string
+  /** MIME type, e.g. "image/jpeg" */
+  type: string
+  /** Byte count */
+  size: number
+  /** The file's bytes; pass straight to fazt.app.s3.put() */
+  data: ArrayBuffer
+}
+
+/** The request a handler is answering */
+interface FaztRequest {
+  method: 'GET' | 'HEAD' | 'POST' | 'PUT' | 'PATCH' | 'DELETE' | 'OPTIONS' | (string & {})
+  /** e.g. "/api/items/123" */
+  path: string
+  /** Route params from file names, e.g. { id: "123" } from api/items/[id].js */
+  params: Record<string, string>
+  query: Record<string, string>
+  /** Request headers */
+  headers: Record<string, string>
+  /** Parsed JSON, or form fields for form posts */
+  body: any
+  /** Uploaded files by field name (multipart/form-data only) */
+  files?: Record<string, FaztFile>
+  /** The app that made the request with fazt.call; unset for outside requests */
+  caller?: string
+}
+
+/** What respond() builds and handlers return */
+interface FaztResponse {
+  status: number
+  body: any
+  headers?: Record<string, string>
+}
+
+/** A route file's exported method handler, e.g. exports.GET */
+type FaztHandler = (request: FaztRequest) => FaztResponse | void
+
+// ---------------------------------------------------------------------------
+// Storage: fazt.app.kv / ds / s3 / media
+// ---------------------------------------------------------------------------
+
+interface FaztKVEntry {
+  key: string
+  value: any
+  /** Epoch ms, when the entry has a TTL */
+  expiresAt?: number
+}
+
+interface FaztKV {
+  /** Store a value, optionally expiring after ttlMs milliseconds */
+  set(key: string, value: any, ttlMs?: number | null): void
+  /** The stored value, or null */
+  get<T = any>(key: string): T | null
+  delete(key: string): void
+  /** Entries whose keys start with prefix (all when omitted) */
+  list(prefix?: string): FaztKVEntry[]
+}
+
+/** Comparison operators for a field in a ds query */
+interface FaztQueryOps {
+  $eq?: any
+  $ne?: any
+  $gt?: any
+  $gte?: any
+  $lt?: any
+  $lte?: any
+  $in?: any[]
+  $nin?: any[]
+  $contains?: any
+}
+
+/** Fields to match: a value for equality, or operators */
+type FaztQuery = Record<string, any | FaztQueryOps>
+
+/** Changes for ds.update: fields to set, or update operators */
+type FaztChanges = Record<string, any> & {
+  $set?: Record<string, any>
+  $unset?: Record<string, any>
+  $inc?: Record<string, number>
+}
+
+/** A stored document, with the fields fazt adds */
+type FaztDoc<T = Record<string, any>> = T & {
+  id: string
+  /** Epoch ms */
+  _createdAt: number
+  /** Epoch ms */
+  _updatedAt: number
+}
+
+interface FaztFindOptions {
+  limit?: number
+  offset?: number
+  /** By creation time (default "desc") */
+  order?: 'asc' | 'desc'
+}
+
+interface FaztSearchOptions {
+  /** Default 20, max 100 */
+  limit?: number
+  offset?: number
+  /** Take FTS5 syntax: "exact phrase", OR, NOT, prefix* */
+  raw?: boolean
+}
+
+type FaztSearchResult<T = Record<string, any>> = FaztDoc<T> & {
+  /** Relevance, higher is better */
+  _score: number
+  /** HTML-escaped excerpt with matches in <mark> */
+  _snippet: string
+}
+
+interface FaztDS {
+  /** Insert a document; returns its id (generated unless the doc has one) */
+  insert(collection: string, doc: Record<string, any>): string
+  find<T = Record<string, any>>(collection: string, query?: FaztQuery | null, options?: FaztFindOptions): FaztDoc<T>[]
+  /** The first matching document, or null. A string is looked up as the id */
+  findOne<T = Record<string, any>>(collection: string, query: FaztQuery | string): FaztDoc<T> | null
+  /** Returns how many documents changed */
+  update(collection: string, query: FaztQuery, changes: FaztChanges): number
+  /** Returns how many documents were deleted */
+  delete(collection: string, query: FaztQuery): number
+  count(collection: string, query?: FaztQuery | null): number
+  /** Index fields for search(); a no-op when they're already indexed */
+  enableSearch(collection: string, fields: string[]): void
+  disableSearch(collection: string): void
+  /** Full-text search, most relevant first */
+  search<T = Record<string, any>>(collection: string, query: string, options?: FaztSearchOptions): FaztSearchResult<T>[]
+}
+
+interface FaztBlob {
+  /** Base64-encoded bytes */
+  data: string
+  mime: string
+  size: number
+  hash: string
+}
+
+interface FaztBlobInfo {
+  path: string
+  mime: string
+  size: number
+  /** Epoch ms */
+  updatedAt: number
+}
+
+interface FaztS3 {
+  /** Store bytes (ArrayBuffer) or text at path */
+  put(path: string, data: ArrayBuffer | string, mimeType?: string): void
+  /** The blob, or null */
+  get(path: string): FaztBlob | null
+  delete(path: string): void
+  /** Blobs whose paths start with prefix (all when omitted) */
+  list(prefix?: string): FaztBlobInfo[]
+}
+
+interface FaztMediaFile {
+  /** Base64-encoded bytes */
+  data: string
+  mime: string
+  size: number
+}
+
+interface FaztVideoInfo {
+  container: string
+  videoCodec: string
+  audioCodec: string
+  width: number
+  height: number
+  /** Seconds */
+  duration: number
+  /** Plays in browsers as is */
+  compatible: boolean
+}
+
+interface FaztMedia {
+  /**
+   * A stored image resized by the request's ?w=, ?h=, ?fit=, ?q= and
+   * ?format= params (cached), or the original; null when missing. With
+   * ?hls, a video's HLS master playlist, and the playlists and segments it
+   * links to; null until transcode() has made them. With ?preview or
+   * ?preview=<px>, a document's first page as preview() renders it; null
+   * for other files
+   */
+  serve(path: string): FaztMediaFile | null
+  /** Codec, size and duration of video bytes */
+  probe(data: ArrayBuffer): FaztVideoInfo
+  /**
+   * The frame of a stored video at atSeconds (default 0) as a JPEG
+   * (cached); null when missing or the server has no ffmpeg
+   */
+  thumbnail(path: string, atSeconds?: number): FaztMediaFile | null
+  /**
+   * Peak amplitudes (0-1) of a stored audio or video file's sound, in
+   * samples (default 100, max 2000) equal slices (cached); null when
+   * missing or the server has no ffmpeg
+   */
+  waveform(path: string, samples?: number): number[] | null
+  /**
+   * The first page of a stored PDF or office document as a PNG, size
+   * (default 512, 16-2048) pixels on its longest side (cached); null when
+   * missing or the server has no converter for it
+   */
+  preview(path: string, size?: number): FaztMediaFile | null
+  /**
+   * Queue a browser-compatible H.264 copy of a stored video, when it needs
+   * one, and HLS renditions of it for adaptive streaming
+   */
+  transcode(path: string): {
+    status: 'queued' | 'compatible' | 'not_video' | 'too_large' | 'no_ffmpeg'
+    /** HLS renditions queued */
+    hls: boolean
+  }
+}
+
+/** Storage isolated per signed-in user; calls throw without a user */
+interface FaztUserStorage {
+  kv: FaztKV
+  ds: FaztDS
+  s3: FaztS3
+  media: FaztMedia
+}
+
+// ---------------------------------------------------------------------------
+// Users and sign-in
+// ---------------------------------------------------------------------------
+
+interface FaztUser {
+  id: string
+  email: string
+  name: string
+  picture: string
+  role: 'owner' | 'admin' | 'user' | (string & {})
+  provider: string
+  /** Set while an admin is impersonating this user */
+  impersonated_by?: string
+}
+
+interface FaztAuth {
+  /** The signed-in fazt account, or null */
+  getUser(): FaztUser | null
+  isLoggedIn(): boolean
+  isOwner(): boolean
+  /** Owner or admin */
+  isAdmin(): boolean
+  hasRole(role: string): boolean
+  /** Redirects to sign-in when signed out */
+  requireLogin(): void
+  /** 403 unless the user has the role */
+  requireRole(role: string): void
+  requireOwner(): void
+  requireAdmin(): void
+  getLoginURL(redirect?: string): string
+  getLogoutURL(): string
+}
+
+interface FaztAppUser {
+  id: string
+  email: string
+  name: string
+  picture: string
+  provider: string
+}
+
+/** Sign-in with the app's own OAuth providers (fazt app auth enable) */
+interface FaztAppAuth {
+  /** The signed-in app user, or null */
+  currentUser(): FaztAppUser | null
+  isLoggedIn(): boolean
+  /** Redirects to sign-in when signed out; provider may be omitted with one enabled */
+  requireLogin(provider?: string): void
+  loginURL(provider?: string, redirect?: string): string
+  logoutURL(redirect?: string): string
+}
+
+interface FaztCSRF {
+  /** A token for a hidden _csrf field or an X-CSRF-Token header */
+  token(): string
+  verify(token: string | null | undefined): boolean
+}
+
+interface FaztMailMessage {
+  /** An address or up to 50 addresses */
+  to: string | string[]
+  subject: string
+  /** At least one of html and text; with both, mail clients pick */
+  html?: string
+  text?: string
+  replyTo?: string
+}
+
+/** Email through the app's or the server's mail server (fazt server set-mail) */
+interface FaztMail {
+  /** Queues an email for a job that retries it; throws past the daily limit */
+  send(message: FaztMailMessage): { id: string; status: 'queued' }
+}
+
+/** A browser's PushSubscription, or its toJSON() */
+interface FaztPushSubscription {
+  endpoint: string
+  expirationTime?: number | null
+  keys: { p256dh: string; auth: string }
+}
+
+interface FaztPushOptions {
+  /** Seconds the push service keeps an undelivered message; a day by default */
+  ttl?: number
+  urgency?: 'very-low' | 'low' | 'normal' | 'high'
+  /** Replaces an undelivered message with the same topic */
+  topic?: string
+}
+
+/** Web Push to browsers, signed with the app's VAPID keys */
+interface FaztPush {
+  /** The applicationServerKey to subscribe browsers with */
+  publicKey(): string
+  /** Keeps a subscription for the signed-in user; returns its ID */
+  subscribe(subscription: FaztPushSubscription): string
+  /** Forgets one of the signed-in user's subscriptions, or all; returns how many */
+  unsubscribe(endpoint?: string): number
+  /** Objects are sent as JSON; a gone subscription should be dropped */
+  send(subscription: FaztPushSubscription, payload: string | object, options?: FaztPushOptions): { status: number; gone: boolean }
+  /** Sends to each of a user's subscriptions, removing gone ones */
+  sendToUser(userId: string, payload: string | object, options?: FaztPushOptions): { sent: number; removed: number; failed: number }
+}
+
+interface FaztApp {
+  readonly id: string
+  readonly name: string
+  kv: FaztKV
+  ds: FaztDS
+  s3: FaztS3
+  media: FaztMedia
+  user: FaztUserStorage
+  auth: FaztAppAuth
+  csrf: FaztCSRF
+  mail: FaztMail
+  push: FaztPush
+}
+
+// ---------------------------------------------------------------------------
+// Background jobs: fazt.worker and the job global
+// ---------------------------------------------------------------------------
+
+type FaztJobStatus = 'pending' | 'running' | 'done' | 'failed' | 'cancelled'
+
+type FaztPriority = 'low' | 'normal' | 'high'
+
+interface FaztSpawnOptions {
+  /** Available to the worker as job.data */
+  data?: Record<string, any>
+  /** e.g. "64MB" (default 32MB) */
+  memory?: string
+  /** e.g. "5m"; null runs without a timeout */
+  timeout?: string | null
+  daemon?: boolean
+  /** Attempts after the first */
+  retry?: number
+  /** e.g. "1m" */
+  retryDelay?: string
+  priority?: FaztPriority
+  /** A queue defined with fazt.worker.queue() (default "default") */
+  queue?: string
+  /** Return the app's unfinished job with this key instead of spawning another */
+  uniqueKey?: string
+  /** Stop after this long without listeners on idleChannel, e.g. "1m" */
+  idleTimeout?: string
+  idleChannel?: string
+}
+
+interface FaztJobInfo {
+  id: string
+  handler: string
+  status: FaztJobStatus
+  /** 0-100 */
+  progress: number
+  attempt: number
+  queue: string
+  data?: Record<string, any>
+  result?: string
+  error?: string
+  logs?: string[]
+  /** Epoch ms */
+  createdAt?: number
+  startedAt?: number
+  completedAt?: number
+}
+
+interface FaztWorker {
+  /** Run a workers/*.js file in the background */
+  spawn(handler: string, options?: FaztSpawnOptions): FaztJobInfo
+  get(id: string): FaztJobInfo
+  list(options?: { status?: FaztJobStatus; limit?: number }): FaztJobInfo[]
+  cancel(id: string): void
+  /** Block until the job finishes (default timeout "5m") */
+  wait(id: string, options?: { timeout?: string }): FaztJobInfo
+  /** Define a queue's concurrency and priority before spawning into it */
+  queue(name: string, options?: { concurrency?: number; priority?: FaztPriority }): void
+}
+
+/** The job a worker, event hook or service is running as */
+interface FaztJob {
+  readonly id: string
+  readonly data: Record<string, any> & { event?: FaztEvent }
+  readonly attempt: number
+  /** Memory budget in bytes */
+  readonly memory: number
+  readonly daemon: boolean
+  /** The service's name in manifest.json, for services */
+  readonly service: string
+  /** True once the job is cancelled; long loops should stop */
+  readonly cancelled: boolean
+  /** Report progress, 0-100 */
+  progress(percent: number): void
+  /** A timestamped log line (the last 100 are kept) */
+  log(message: string): void
+  /** Save JSON state (max 1MB) right away */
+  checkpoint(state: any): void
+  /** The last checkpoint from an earlier attempt or run, or null */
+  restoreCheckpoint<T = any>(): T | null
+  /** @deprecated Use restoreCheckpoint() */
+  getCheckpoint<T = any>(): T | null
+}
+
+// ---------------------------------------------------------------------------
+// Events: job.data.event in handlers registered under "on" in manifest.json
+// ---------------------------------------------------------------------------
+
+interface FaztEventData {
+  'app.deployed': { files: number; size_bytes: number }
+  's3.put': { path: string; mime_type: string; size: number; hash: string; user_id?: string }
+  'user.created': { user_id: string; email: string; name: string; provider: string }
+  'job.failed': { job_id: string; handler: string; error: string; attempts: number }
+  'form.submitted': { form: string; id: string; collection: string; fields: Record<string, string> }
+  'mail.received': FaztReceivedMail
+}
+
+/** An email sent to <app>@apps.<domain> */
+interface FaztReceivedMail {
+  id: string
+  /** Envelope sender, "" for bounces */
+  mail_from: string
+  /** The app's addresses it was sent to */
+  to: string[]
+  /** "42" for <app>+42@apps.<domain> */
+  tag: string
+  from: string
+  from_name: string
+  subject: string
+  /** RFC 3339, "" without a Date header */
+  date: string
+  message_id: string
+  in_reply_to: string
+  references: string[]
+  /** First value of each header */
+  headers: Record<string, string>
+  text: string
+  html: string
+  /** Stored in the app's blobs; read with fazt.app.s3.get(path) */
+  attachments: { filename: string; mime_type: string; size: number; path: string }[]
+  remote_addr: string
+}
+
+type FaztEventType = keyof FaztEventData
+
+type FaztEvent = {
+  [K in FaztEventType]: {
+    type: K
+    app_id: string
+    /** RFC 3339 */
+    time: string
+    data: FaztEventData[K]
+  }
+}[FaztEventType]
+
+// ---------------------------------------------------------------------------
+// Other namespaces
+// ---------------------------------------------------------------------------
+
+interface FaztFetchOptions {
+  method?: string
+  headers?: Record<string, string>
+  body?: string
+  /** Name of a stored secret to authenticate the request with */
+  auth?: string
+}
+
+interface FaztFetchResponse {
+  status: number
+  ok: boolean
+  headers: Record<string, string>
+  text(): string
+  json<T = any>(): T
+}
+
+interface FaztCallOptions {
+  method?: string
+  query?: Record<string, string | number | boolean>
+  headers?: Record<string, string>
+  /** Objects are sent as JSON */
+  body?: string | object
+}
+
+interface FaztCallResponse {
+  status: number
+  headers: Record<string, string>
+  /** Parsed for JSON responses, text otherwise */
+  body: any
+}
+
+interface FaztSysApp {
+  id: string
+  title: string
+  visibility: 'public' | 'unlisted' | 'private'
+  aliases: string[]
+  fileCount: number
+  sizeBytes: number
+  protected: boolean
+  systemAccess: boolean
+  createdAt: string
+  updatedAt: string
+}
+
+interface FaztSysStats {
+  version: string
+  uptimeSeconds: number
+  goroutines: number
+  memoryMB: number
+  /** Number of apps */
+  apps: number
+  /** Requests served and handler time since UTC midnight, across apps */
+  today: { requests: number; cpuMs: number }
+  database: { openConnections: number; inUse: number }
+  /** The static file cache */
+  cache: { files: number; bytes: number; hits: number; misses: number }
+  /** The background job pool, once started */
+  jobs?: { active: number; queued: number; total: number }
+}
+
+/** A job of any app, without its data, result or logs */
+interface FaztSysJob {
+  id: string
+  app: string
+  handler: string
+  status: FaztJobStatus
+  /** 0-100 */
+  progress: number
+  attempt: number
+  error?: string
+  /** Epoch ms */
+  createdAt?: number
+  startedAt?: number
+  completedAt?: number
+}
+
+/** Read-only information about the whole server. Calls throw unless an admin gave the app system access (fazt app system) */
+interface FaztSys {
+  apps: {
+    /** Every app, oldest first */
+    list(): FaztSysApp[]
+  }
+  stats(): FaztSysStats
+  /** Every app's recent jobs, newest first (limit default 50, max 500) */
+  jobs(options?: { app?: string; status?: FaztJobStatus; limit?: number }): FaztSysJob[]
+}
+
+interface FaztImageResult {
+  data: ArrayBuffer
+  width: number
+  height: number
+  size: number
+}
+
+interface FaztResizeOptions {
+  width?: number
+  height?: number
+  /** Default "contain" */
+  fit?: 'contain' | 'cover' | 'fill'
+  format?: 'jpeg' | 'jpg' | 'png'
+  quality?: number
+}
+
+interface FaztCalendarEvent {
+  title?: string
+  summary?: string
+  /** ISO 8601, epoch ms, or YYYY-MM-DD for an all-day event */
+  start: string | number
+  end?: string | number
+  allDay?: boolean
+  id?: string
+  uid?: string
+  description?: string
+  location?: string
+  url?: string
+  status?: 'tentative' | 'confirmed' | 'cancelled'
+}
+
+interface FaztContactAddress {
+  type?: string
+  street?: string
+  city?: string
+  region?: string
+  postalCode?: string
+  country?: string
+}
+
+type FaztContactValue = string | string[] | { type?: string; value: string }[]
+
+interface FaztContact {
+  name?: string
+  firstName?: string
+  lastName?: string
+  org?: string
+  title?: string
+  email?: FaztContactValue
+  phone?: FaztContactValue
+  url?: FaztContactValue
+  address?: FaztContactAddress | string | (FaztContactAddress | string)[]
+  photo?: string
+  /** YYYY-MM-DD */
+  birthday?: string
+  note?: string
+}
+
+interface FaztWasmModule {
+  /** The module's exported functions; numbers in and out */
+  exports: Record<string, (...args: number[]) => any>
+  memory: {
+    read(ptr: number, length: number): ArrayBuffer
+    readString(ptr: number, length: number): string
+    write(ptr: number, data: ArrayBuffer | string): void
+    /** Bytes */
+    size(): number
+  }
+}
+
+/** @deprecated Use fazt.app.kv, ds and s3 */
+interface FaztLegacyStorage {
+  kv: FaztKV
+  ds: Omit<FaztDS, 'enableSearch' | 'disableSearch' | 'search'> & {
+    /** Delete all but the newest keep documents; returns how many were deleted */
+    deleteOldest(collection: string, keep: number): number
+  }
+  s3: FaztS3
+}
+
+interface Fazt {
+  /** The server's runtime version */
+  readonly version: string
+  /** The runtime API version the app runs as, from manifest.json "runtime" */
+  readonly runtime: 'v0' | 'v1'
+  app: FaztApp
+  auth: FaztAuth
+  env: {
+    /** An env var set with fazt app env, or defaultValue */
+    get(name: string, defaultValue?: string): string | undefined
+    has(name: string): boolean
+  }
+  log: {
+    info(message: string): void
+    warn(message: string): void
+    error(message: string): void
+    debug(message: string): void
+  }
+  worker: FaztWorker
+  /** Runs another app's handler in-process; the app must grant this one the path (fazt app grant) */
+  call(app: string, path: string, options?: FaztCallOptions): FaztCallResponse
+  sys: FaztSys
+  realtime: {
+    /** Send data to a WebSocket channel's subscribers */
+    broadcast(channel: string, data: any): void
+    /** Send data to every connected client */
+    broadcastAll(data: any): void
+    /** Client IDs subscribed to the channel */
+    subscribers(channel: string): string[]
+    /** Clients on the channel, or all connected clients */
+    count(channel?: string): number
+    /** Disconnect a client; false when it isn't connected */
+    kick(clientId: string, reason?: string): boolean
+  }
+  net: {
+    /** An HTTP request to an allowlisted host */
+    fetch(url: string, options?: FaztFetchOptions): FaztFetchResponse
+  }
+  private: {
+    /** A file under private/, or undefined */
+    read(path: string): string | undefined
+    readJSON<T = any>(path: string): T | null
+    exists(path: string): boolean
+    list(): string[]
+  }
+  image: {
+    resize(data: ArrayBuffer, options: FaztResizeOptions): FaztImageResult
+    /** A square thumbnail, size pixels wide */
+    thumbnail(data: ArrayBuffer, size: number): FaztImageResult
+  }
+  util: {
+    /** An iCalendar document (at most 5000 events) */
+    ics(events: FaztCalendarEvent[], options?: { name?: string; refresh?: number }): string
+    /** A vCard for the contact */
+    vcard(contact: FaztContact): string
+    /** A QR code PNG, size 64-2048 pixels (default 256) */
+    qr(text: string, options?: { size?: number }): FaztMediaFile & { width: number }
+  }
+  wasm: {
+    /** Load a .wasm module shipped with the app; path is from the app root */
+    load(path: string): FaztWasmModule
+  }
+  /**
+   * Runtime v0 only; each binding used logs a deprecation warning.
+   * @deprecated Use fazt.app.kv, ds and s3
+   */
+  storage?: FaztLegacyStorage
+}
+
+// ---------------------------------------------------------------------------
+// Globals
+// ---------------------------------------------------------------------------
+
+declare var fazt: Fazt
+
+/** The request being handled (handlers only) */
+declare var request: FaztRequest
+
+/** The job being run (workers, event hooks and services only) */
+declare var job: FaztJob
+
+/** Build a response: respond(body), respond(status), respond(status, body, headers) */
+declare function respond(body?: any): FaztResponse
+declare function respond(status: number, body?: any, headers?: Record<string, string>): FaztResponse
+
+/** Block for ms milliseconds (workers only) */
+declare function sleep(ms: number): void
+
+/** Lines go to the app's logs; extra arguments fill %v-style placeholders */
+declare var console: {
+  log(message?: any, ...args: any[]): void
+  info(message?: any, ...args: any[]): void
+  warn(message?: any, ...args: any[]): void
+  error(message?: any, ...args: any[]): void
+  debug(message?: any, ...args: any[]): void
+}
+
+/** Load an app file relative to this one, a .json file, or a stdlib package */
+declare function require(id: string): any
+
+declare var module: { exports: any }
+
+declare var exports: any
@@ -9,9 +9,13 @@ const state = {
   redirects: [],
   webhooks: [],
   domains: [],
-  tags: []
+  tags: [],
+  live: { active: {}, activeTotal: 0, pageviews: [] }
 };
 
+// Live stats socket, open while the dashboard is shown
+let liveSocket = null;
+
 // API client
 const api = {
   async get(endpoint) {
@@ -37,6 +41,8 @@ function navigate(page) {
   document.getElementById('page-title').textContent =
     page.charAt(0).toUpperCase() + page.slice(1);
 
+  if (page !== 'dashboard') disconnectLive();
+
   // Update active nav item
   document.querySelectorAll('.nav-link').forEach(link => {
     link.classList.remove('active');
@@ -84,9 +90,10 @@ async function loadPage(page) {
 async function loadDashboard() {
   const content = document.getElementById('app-content');
 
-  // Fetch data
+  // Fetch data; recent pageviews arrive over the live socket
   state.stats = await api.get('stats');
-  state.events = await api.get('events?limit=20');
+  state.campaigns = await api.get('stats/campaigns').catch(() => null);
+  const campaigns = (state.campaigns && state.campaigns.campaigns) || [];
 
   // Render dashboard
   content.innerHTML = `
@@ -205,23 +212,137 @@ async function loadDashboard() {
       <div class="col-lg-6">
         <div class="card">
           <div class="card-header">
-            <h3 class="card-title">Recent Events</h3>
+            <h3 class="card-title">Live Pageviews</h3>
+            <div class="card-actions">
+              <span class="badge bg-green-lt" id="live-active"></span>
+            </div>
           </div>
+          <div class="card-body py-2 text-muted" id="live-sites"></div>
           <div class="card-table table-responsive">
             <table class="table table-vcenter">
               <thead>
                 <tr>
-                  <th>Domain</th>
-                  <th>Type</th>
+                  <th>Site</th>
+                  <th>Path</th>
                   <th>Time</th>
                 </tr>
               </thead>
+              <tbody id="live-pageviews"></tbody>
+            </table>
+          </div>
+        </div>
+      </div>
+    </div>
+
+    ${(state.stats.top_countries || []).length ? `
+    <!-- Top Countries & Regions (events located by IP) -->
+    <div class="row row-cards mt-3">
+      <div class="col-lg-6">
+        <div class="card">
+          <div class="card-header">
+            <h3 class="card-title">Top Countries</h3>
+          </div>
+          <div class="card-table table-responsive">
+            <table class="table table-vcenter">
+              <thead>
+                <tr>
+                  <th>Country</th>
+                  <th class="text-end">Events</th>
+                </tr>
+              </thead>
+              <tbody>
+                ${state.stats.top_countries.map(c => `
+                  <tr>
+                    <td>${c.key}</td>
+                    <td class="text-end">${c.count.toLocaleString()}</td>
+                  </tr>
+                `).join('')}
+              </tbody>
+            </table>
+          </div>
+        </div>
+      </div>
+      <div class="col-lg-6">
+        <div class="card">
+          <div class="card-header">
+            <h3 class="card-title">Top Regions</h3>
+          </div>
+          <div class="card-table table-responsive">
+            <table class="table table-vcenter">
+              <thead>
+                <tr>
+                  <th>Region</th>
+                  <th class="text-end">Events</th>
+                </tr>
+              </thead>
+              <tbody>
+                ${(state.stats.top_regions || []).map(r => `
+                  <tr>
+                    <td>${r.key}</td>
+                    <td class="text-end">${r.count.toLocaleString()}</td>
+                  </tr>
+                `).join('')}
+              </tbody>
+            </table>
+          </div>
+        </div>
+      </div>
+    </div>
+    ` : ''}
+
+    ${campaigns.length ? `
+    <!-- Campaigns (utm_* parameters on site visits and short links) -->
+    <div class="row row-cards mt-3">
+      <div class="col-lg-8">
+        <div class="card">
+          <div class="card-header">
+            <h3 class="card-title">Campaigns</h3>
+            <div class="card-actions text-muted">Last ${state.campaigns.days} days</div>
+          </div>
+          <div class="card-table table-responsive">
+            <table class="table table-vcenter">
+              <thead>
+                <tr>
+                  <th>Campaign</th>
+                  <th>Source / Medium</th>
+                  <th class="text-end">Clicks</th>
+                  <th class="text-end">Pageviews</th>
+                  <th class="text-end">Visitors</th>
+                </tr>
+              </thead>
               <tbody>
-                ${state.events.slice(0, 10).map(e => `
+                ${campaigns.map(c => `
                   <tr>
-                    <td class="table-truncate">${e.domain}</td>
-                    <td><span class="badge">${e.event_type}</span></td>
-                    <td class="text-muted">${timeAgo(e.created_at)}</td>
+                    <td>${escapeHtml(c.campaign || '-')}</td>
+                    <td class="text-muted">${escapeHtml(c.source || '-')} / ${escapeHtml(c.medium || '-')}</td>
+                    <td class="text-end">${c.clicks.toLocaleString()}</td>
+                    <td class="text-end">${c.pageviews.toLocaleString()}</td>
+                    <td class="text-end">${c.visitors.toLocaleString()}</td>
+                  </tr>
+                `).join('')}
+              </tbody>
+            </table>
+          </div>
+        </div>
+      </div>
+      <div class="col-lg-4">
+        <div class="card">
+          <div class="card-header">
+            <h3 class="card-title">Referrers</h3>
+          </div>
+          <div class="card-table table-responsive">
+            <table class="table table-vcenter">
+              <thead>
+                <tr>
+                  <th>Type</th>
+                  <th class="text-end">Visits</th>
+                </tr>
+              </thead>
+              <tbody>
+                ${(state.campaigns.referrer_types || []).map(r => `
+                  <tr>
+                    <td>${escapeHtml(r.key)}</td>
+                    <td class="text-end">${r.count.toLocaleString()}</td>
                   </tr>
                 `).join('')}
               </tbody>
@@ -230,11 +351,65 @@ async function loadDashboard() {
         </div>
       </div>
     </div>
+    ` : ''}
   `;
 
   // Render charts
   renderTrafficChart();
   renderSourceChart();
+
+  renderLive();
+  connectLive();
+}
+
+// Connect to the live stats socket, reconnecting while the dashboard is shown
+function connectLive() {
+  if (liveSocket) return;
+  const proto = location.protocol === 'https:' ? 'wss:' : 'ws:';
+  liveSocket = new WebSocket(`${proto}//${location.host}/api/stats/live`);
+
+  liveSocket.onmessage = (msg) => {
+    const data = JSON.parse(msg.data);
+    state.live.active = data.active;
+    state.live.activeTotal = data.active_total;
+    state.live.pageviews = data.type === 'snapshot'
+      ? data.pageviews
+      : data.pageviews.concat(state.live.pageviews).slice(0, 20);
+    renderLive();
+  };
+
+  liveSocket.onclose = () => {
+    liveSocket = null;
+    if (state.currentPage === 'dashboard') setTimeout(connectLive, 5000);
+  };
+}
+
+function disconnectLive() {
+  if (!liveSocket) return;
+  liveSocket.onclose = null;
+  liveSocket.close();
+  liveSocket = null;
+}
+
+// Render active visitors and recent pageviews
+function renderLive() {
+  const active = document.getElementById('live-active');
+  const sites = document.getElementById('live-sites');
+  const tbody = document.getElementById('live-pageviews');
+  if (!active || !sites || !tbody) return;
+
+  active.textContent = `${state.live.activeTotal.toLocaleString()} active`;
+  sites.textContent = Object.entries(state.live.active)
+    .sort((a, b) => b[1] - a[1])
+    .map(([site, n]) => `${site} ${n}`)
+    .join(' · ') || 'No active visitors';
+  tbody.innerHTML = state.live.pageviews.slice(0, 10).map(p => `
+    <tr>
+      <td class="table-truncate">${escapeHtml(p.site)}</td>
+      <td class="table-truncate">${escapeHtml(p.path)}</td>
+      <td class="text-muted">${timeAgo(p.at)}</td>
+    </tr>
+  `).join('');
 }
 
 // Render traffic timeline chart
@@ -701,6 +876,13 @@ function timeAgo(dateString) {
   return Math.floor(seconds / 86400) + 'd ago';
 }
 
+// Utility: Escape text for HTML
+function escapeHtml(text) {
+  const div = document.createElement('div');
+  div.textContent = text;
+  return div.innerHTML;
+}
+
 // Theme toggle
 function toggleTheme() {
   state.theme = state.theme === 'light' ? 'dark' : 'light';
@@ -754,11 +936,4 @@ document.addEventListener('DOMContentLoaded', () => {
       .then(reg => console.log('Service Worker registered', reg))
       .catch(err => console.log('Service Worker registration failed', err));
   }
-
-  // Auto-refresh every 30 seconds
-  setInterval(() => {
-    if (state.currentPage === 'dashboard') {
-      refreshData();
-    }
-  }, 30000);
 });
@@ -27,7 +27,9 @@
       e: eventType || 'pageview',
       t: tags.concat(data.tags || []),
       ref: data.referrer || document.referrer,
-      q: data.query || {}
+      q: data.query || {},
+      sw: window.screen ? window.screen.width : 0,
+      sh: window.screen ? window.screen.height : 0
     };
 
     // Send beacon
@@ -0,0 +1,511 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/events"
+)
+
+// App end-user authentication.
+// Apps sign in their own users through OAuth clients the app owner registers
+// per app. App users live in app_users, are scoped to one app, and never get
+// access to the platform (auth_users and fazt_session are untouched).
+
+const (
+	// AppSessionCookieName is the cookie holding an app end-user session.
+	// It is host-only, so each app's session stays on the app's own host.
+	AppSessionCookieName = "fazt_app_session"
+
+	// AppSessionTTL is how long app end-user sessions last (30 days)
+	AppSessionTTL = 30 * 24 * time.Hour
+
+	// appStatePrefix marks OAuth states started by an app login so they
+	// can't be completed through the platform callback (and vice versa)
+	appStatePrefix = "app:"
+)
+
+// AppProviderConfig is an OAuth client configured for one app
+type AppProviderConfig struct {
+	AppID        string `json:"app_id"`
+	Provider     string `json:"provider"`
+	Enabled      bool   `json:"enabled"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"-"` // Never serialize the secret
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// AppUser is an end-user of an app, signed in through the app's OAuth providers
+type AppUser struct {
+	ID        string `json:"id"`
+	AppID     string `json:"app_id"`
+	Email     string `json:"email,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Picture   string `json:"picture,omitempty"`
+	Provider  string `json:"provider"`
+	CreatedAt int64  `json:"created_at"`
+	LastLogin *int64 `json:"last_login,omitempty"`
+}
+
+// SetAppProvider configures and enables an OAuth provider for an app.
+// An empty clientID re-enables a provider that was configured before.
+func (s *Service) SetAppProvider(appID, providerName, clientID, clientSecret string) error {
+	if _, ok := Providers[providerName]; !ok {
+		return fmt.Errorf("unknown provider: %s", providerName)
+	}
+
+	if clientID == "" {
+		result, err := s.db.Exec(`
+			UPDATE app_auth_providers SET enabled = 1 WHERE app_id = ? AND provider = ?
+		`, appID, providerName)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("provider %s not configured for this app, client ID required", providerName)
+		}
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO app_auth_providers (app_id, provider, enabled, client_id, client_secret, created_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON CONFLICT(app_id, provider) DO UPDATE SET
+			enabled = 1,
+			client_id = excluded.client_id,
+			client_secret = excluded.client_secret
+	`, appID, providerName, clientID, clientSecret, time.Now().Unix())
+	return err
+}
+
+// DisableAppProvider turns off an app's OAuth provider, keeping its credentials
+func (s *Service) DisableAppProvider(appID, providerName string) error {
+	result, err := s.db.Exec(`
+		UPDATE app_auth_providers SET enabled = 0 WHERE app_id = ? AND provider = ?
+	`, appID, providerName)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrProviderDisabled
+	}
+	return nil
+}
+
+// GetAppProvider retrieves an app's OAuth provider configuration
+func (s *Service) GetAppProvider(appID, providerName string) (*AppProviderConfig, error) {
+	cfg := AppProviderConfig{AppID: appID, Provider: providerName}
+	var enabled int
+	var clientSecret sql.NullString
+
+	err := s.db.QueryRow(`
+		SELECT enabled, client_id, client_secret, created_at
+		FROM app_auth_providers WHERE app_id = ? AND provider = ?
+	`, appID, providerName).Scan(&enabled, &cfg.ClientID, &clientSecret, &cfg.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrProviderDisabled
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Enabled = enabled == 1
+	if clientSecret.Valid {
+		cfg.ClientSecret = clientSecret.String
+	}
+	return &cfg, nil
+}
+
+// ListAppProviders returns the OAuth providers configured for an app
+func (s *Service) ListAppProviders(appID string) ([]*AppProviderConfig, error) {
+	rows, err := s.db.Query(`
+		SELECT provider, enabled, client_id, created_at
+		FROM app_auth_providers WHERE app_id = ? ORDER BY provider
+	`, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	providers := []*AppProviderConfig{}
+	for rows.Next() {
+		cfg := AppProviderConfig{AppID: appID}
+		var enabled int
+		if err := rows.Scan(&cfg.Provider, &enabled, &cfg.ClientID, &cfg.CreatedAt); err != nil {
+			continue
+		}
+		cfg.Enabled = enabled == 1
+		providers = append(providers, &cfg)
+	}
+	return providers, nil
+}
+
+// enabledAppProvider returns the provider definition and config, or ErrProviderDisabled
+func (s *Service) enabledAppProvider(appID, providerName string) (*OAuthProvider, *AppProviderConfig, error) {
+	provider, ok := Providers[providerName]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown provider: %s", providerName)
+	}
+	cfg, err := s.GetAppProvider(appID, providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cfg.Enabled {
+		return nil, nil, ErrProviderDisabled
+	}
+	return provider, cfg, nil
+}
+
+// StartAppOAuthFlow initiates an app end-user login.
+// Returns the provider authorization URL to redirect the user to.
+func (s *Service) StartAppOAuthFlow(appID, providerName, redirectTo, callbackURL string) (string, error) {
+	provider, cfg, err := s.enabledAppProvider(appID, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := s.CreateState(appStatePrefix+providerName, redirectTo, appID)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.authURL(cfg.ClientID, state, callbackURL), nil
+}
+
+// CompleteAppOAuthFlow processes an app login callback and creates an app session.
+// Returns the session token, the app user, and where to redirect.
+func (s *Service) CompleteAppOAuthFlow(appID, providerName, code, state, callbackURL string) (string, *AppUser, string, error) {
+	oauthState, err := s.ValidateState(state)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if oauthState.Provider != appStatePrefix+providerName || oauthState.AppID != appID {
+		return "", nil, "", ErrInvalidState
+	}
+
+	provider, cfg, err := s.enabledAppProvider(appID, providerName)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	accessToken, err := provider.exchangeCode(cfg.ClientID, cfg.ClientSecret, code, callbackURL)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	info, err := s.FetchUserInfo(providerName, accessToken)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	user, err := s.upsertAppUser(appID, providerName, info)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	token, err := s.CreateAppSession(appID, user.ID)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	return token, user, oauthState.RedirectTo, nil
+}
+
+// upsertAppUser creates the app user for a provider identity or refreshes their profile
+func (s *Service) upsertAppUser(appID, providerName string, info *UserInfo) (*AppUser, error) {
+	now := time.Now().Unix()
+	newID := appid.GenerateAppUser()
+
+	_, err := s.db.Exec(`
+		INSERT INTO app_users (id, app_id, provider, provider_id, email, name, picture, created_at, last_login)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app_id, provider, provider_id) DO UPDATE SET
+			email = excluded.email,
+			name = excluded.name,
+			picture = excluded.picture,
+			last_login = excluded.last_login
+	`, newID, appID, providerName, info.ID, info.Email, info.Name, info.Picture, now, now)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.db.QueryRow(`
+		SELECT id, app_id, email, name, picture, provider, created_at, last_login
+		FROM app_users WHERE app_id = ? AND provider = ? AND provider_id = ?
+	`, appID, providerName, info.ID)
+	user, err := scanAppUser(row)
+	if err != nil {
+		return nil, err
+	}
+
+	// The insert only kept our ID if this is the user's first sign-in
+	if user.ID == newID {
+		events.Emit(events.UserCreated, appID, map[string]interface{}{
+			"user_id":  user.ID,
+			"email":    user.Email,
+			"name":     user.Name,
+			"provider": user.Provider,
+		})
+	}
+	return user, nil
+}
+
+// GetAppUser retrieves an app user by ID
+func (s *Service) GetAppUser(appID, userID string) (*AppUser, error) {
+	row := s.db.QueryRow(`
+		SELECT id, app_id, email, name, picture, provider, created_at, last_login
+		FROM app_users WHERE app_id = ? AND id = ?
+	`, appID, userID)
+	return scanAppUser(row)
+}
+
+// ListAppUsers returns the users that have signed in to an app
+func (s *Service) ListAppUsers(appID string) ([]*AppUser, error) {
+	rows, err := s.db.Query(`
+		SELECT id, app_id, email, name, picture, provider, created_at, last_login
+		FROM app_users WHERE app_id = ? ORDER BY created_at DESC
+	`, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*AppUser{}
+	for rows.Next() {
+		user, err := scanAppUser(rows)
+		if err != nil {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// DeleteAppUser removes an app user and signs them out
+func (s *Service) DeleteAppUser(appID, userID string) error {
+	s.db.Exec(`DELETE FROM app_sessions WHERE app_id = ? AND user_id = ?`, appID, userID)
+
+	result, err := s.db.Exec(`DELETE FROM app_users WHERE app_id = ? AND id = ?`, appID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrAppUserNotFound
+	}
+	return nil
+}
+
+func scanAppUser(row interface{ Scan(...interface{}) error }) (*AppUser, error) {
+	var user AppUser
+	var email, name, picture sql.NullString
+	var lastLogin sql.NullInt64
+
+	err := row.Scan(&user.ID, &user.AppID, &email, &name, &picture, &user.Provider, &user.CreatedAt, &lastLogin)
+	if err == sql.ErrNoRows {
+		return nil, ErrAppUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.Email = email.String
+	user.Name = name.String
+	user.Picture = picture.String
+	if lastLogin.Valid {
+		user.LastLogin = &lastLogin.Int64
+	}
+	return &user, nil
+}
+
+// CreateAppSession creates a session for an app user and returns the token
+func (s *Service) CreateAppSession(appID, userID string) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	_, err = s.db.Exec(`
+		INSERT INTO app_sessions (token_hash, app_id, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashToken(token), appID, userID, now, now+int64(AppSessionTTL.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateAppSession returns the app user for a session token.
+// Sessions only validate for the app that created them.
+func (s *Service) ValidateAppSession(appID, token string) (*AppUser, error) {
+	if token == "" {
+		return nil, ErrInvalidSession
+	}
+
+	var userID string
+	var expiresAt int64
+	err := s.db.QueryRow(`
+		SELECT user_id, expires_at FROM app_sessions WHERE token_hash = ? AND app_id = ?
+	`, hashToken(token), appID).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() > expiresAt {
+		s.DeleteAppSession(token)
+		return nil, ErrSessionExpired
+	}
+
+	return s.GetAppUser(appID, userID)
+}
+
+// DeleteAppSession removes an app session by token
+func (s *Service) DeleteAppSession(token string) error {
+	if token == "" {
+		return nil
+	}
+	_, err := s.db.Exec(`DELETE FROM app_sessions WHERE token_hash = ?`, hashToken(token))
+	return err
+}
+
+// GetAppUserFromRequest returns the app user signed in to appID, if any
+func (s *Service) GetAppUserFromRequest(r *http.Request, appID string) (*AppUser, error) {
+	cookie, err := r.Cookie(AppSessionCookieName)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	return s.ValidateAppSession(appID, cookie.Value)
+}
+
+// AppSessionCookie creates a host-only cookie for an app session
+func (s *Service) AppSessionCookie(token string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     AppSessionCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// App login handlers
+
+// AppLogin starts an app end-user login.
+// The provider defaults to the app's only enabled provider.
+// GET /auth/app/{id}/login?provider=github&redirect=/
+func (h *Handler) AppLogin(w http.ResponseWriter, r *http.Request) {
+	appID := r.PathValue("id")
+	providerName := r.URL.Query().Get("provider")
+
+	if providerName == "" {
+		providers, err := h.service.ListAppProviders(appID)
+		if err != nil {
+			http.Error(w, "Failed to load sign-in providers", http.StatusInternalServerError)
+			return
+		}
+		var enabled []string
+		for _, p := range providers {
+			if p.Enabled {
+				enabled = append(enabled, p.Provider)
+			}
+		}
+		switch len(enabled) {
+		case 0:
+			http.Error(w, "Sign-in is not enabled for this app", http.StatusNotFound)
+			return
+		case 1:
+			providerName = enabled[0]
+		default:
+			http.Error(w, "provider required (one of: "+strings.Join(enabled, ", ")+")", http.StatusBadRequest)
+			return
+		}
+	}
+
+	redirectTo := localRedirect(r.URL.Query().Get("redirect"))
+
+	authURL, err := h.service.StartAppOAuthFlow(appID, providerName, redirectTo, h.appCallbackURL(r, appID, providerName))
+	if err != nil {
+		if err == ErrProviderDisabled {
+			http.Error(w, "Sign-in with "+providerName+" is not enabled for this app", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+// AppCallback completes an app end-user login and sets the app session cookie
+// GET /auth/app/{id}/callback/{provider}
+func (h *Handler) AppCallback(w http.ResponseWriter, r *http.Request) {
+	appID := r.PathValue("id")
+	providerName := r.PathValue("provider")
+
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		errDesc := r.URL.Query().Get("error_description")
+		http.Error(w, fmt.Sprintf("Authentication failed: %s - %s", errCode, errDesc), http.StatusUnauthorized)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	if code == "" || state == "" {
+		http.Error(w, "Invalid callback parameters", http.StatusBadRequest)
+		return
+	}
+
+	token, _, redirectTo, err := h.service.CompleteAppOAuthFlow(appID, providerName, code, state, h.appCallbackURL(r, appID, providerName))
+	if err != nil {
+		http.Error(w, "Authentication failed: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	http.SetCookie(w, h.service.AppSessionCookie(token, int(AppSessionTTL.Seconds())))
+	http.Redirect(w, r, localRedirect(redirectTo), http.StatusTemporaryRedirect)
+}
+
+// AppLogout ends the app end-user session
+// GET or POST /auth/app/{id}/logout?redirect=/
+func (h *Handler) AppLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(AppSessionCookieName); err == nil {
+		h.service.DeleteAppSession(cookie.Value)
+	}
+	http.SetCookie(w, h.service.AppSessionCookie("", -1))
+
+	if r.Header.Get("Accept") == "application/json" {
+		api.Success(w, http.StatusOK, map[string]interface{}{
+			"message": "Logged out successfully",
+		})
+		return
+	}
+	http.Redirect(w, r, localRedirect(r.URL.Query().Get("redirect")), http.StatusTemporaryRedirect)
+}
+
+// appCallbackURL is the redirect URI registered with the app's OAuth client.
+// Unlike platform logins it uses the request host, which is the app's own host.
+func (h *Handler) appCallbackURL(r *http.Request, appID, providerName string) string {
+	scheme := "https"
+	if !h.service.IsSecure() {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/auth/app/%s/callback/%s", scheme, r.Host, appID, providerName)
+}
+
+// localRedirect keeps post-login redirects on the current host
+func localRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
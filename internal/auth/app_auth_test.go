@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakeGitHub stands in for GitHub's OAuth endpoints during a test
+func fakeGitHub(t *testing.T, userJSON string) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "app-client" || r.Form.Get("client_secret") != "app-secret" || r.Form.Get("code") != "good-code" {
+			w.Write([]byte(`{"error":"bad_verification_code"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"tok123","token_type":"bearer"}`))
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(userJSON))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	original := Providers["github"]
+	fake := *original
+	fake.AuthURL = server.URL + "/authorize"
+	fake.TokenURL = server.URL + "/token"
+	fake.UserInfoURL = server.URL + "/user"
+	Providers["github"] = &fake
+	t.Cleanup(func() { Providers["github"] = original })
+}
+
+func TestAppProviderConfig(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+
+	if err := service.SetAppProvider("app1", "myspace", "id", "secret"); err == nil {
+		t.Error("Expected unknown provider to fail")
+	}
+	if err := service.SetAppProvider("app1", "github", "", ""); err == nil {
+		t.Error("Expected enabling an unconfigured provider without a client ID to fail")
+	}
+
+	if err := service.SetAppProvider("app1", "github", "app-client", "app-secret"); err != nil {
+		t.Fatalf("SetAppProvider failed: %v", err)
+	}
+	if err := service.DisableAppProvider("app1", "github"); err != nil {
+		t.Fatalf("DisableAppProvider failed: %v", err)
+	}
+	if _, err := service.StartAppOAuthFlow("app1", "github", "/", "http://app1.test.com/cb"); err != ErrProviderDisabled {
+		t.Errorf("Expected ErrProviderDisabled, got %v", err)
+	}
+
+	// Re-enabling keeps the stored credentials
+	if err := service.SetAppProvider("app1", "github", "", ""); err != nil {
+		t.Fatalf("Re-enable failed: %v", err)
+	}
+	cfg, err := service.GetAppProvider("app1", "github")
+	if err != nil || !cfg.Enabled || cfg.ClientSecret != "app-secret" {
+		t.Errorf("Unexpected config after re-enable: %+v, %v", cfg, err)
+	}
+
+	// Providers are per app
+	if _, err := service.GetAppProvider("app2", "github"); err != ErrProviderDisabled {
+		t.Errorf("Expected app2 to have no provider, got %v", err)
+	}
+	providers, _ := service.ListAppProviders("app1")
+	if len(providers) != 1 || providers[0].Provider != "github" {
+		t.Errorf("Unexpected providers: %+v", providers)
+	}
+}
+
+func TestAppOAuthFlow(t *testing.T) {
+	fakeGitHub(t, `{"id":42,"login":"octo","email":"octo@example.com","avatar_url":"https://img/octo.png"}`)
+
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	if err := service.SetAppProvider("app1", "github", "app-client", "app-secret"); err != nil {
+		t.Fatalf("SetAppProvider failed: %v", err)
+	}
+
+	callback := "http://app1.test.com/auth/app/app1/callback/github"
+	authURL, err := service.StartAppOAuthFlow("app1", "github", "/notes", callback)
+	if err != nil {
+		t.Fatalf("StartAppOAuthFlow failed: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Query().Get("client_id") != "app-client" || u.Query().Get("redirect_uri") != callback {
+		t.Errorf("Auth URL should use the app's client: %s", authURL)
+	}
+	state := u.Query().Get("state")
+
+	// App states can't complete through the platform flow
+	if _, _, err := service.CompleteOAuthFlow("github", "good-code", state, callback); err != ErrInvalidState {
+		t.Errorf("Expected platform callback to reject app state, got %v", err)
+	}
+
+	authURL, _ = service.StartAppOAuthFlow("app1", "github", "/notes", callback)
+	u, _ = url.Parse(authURL)
+	state = u.Query().Get("state")
+
+	// ...or through another app's callback
+	if _, _, _, err := service.CompleteAppOAuthFlow("app2", "github", "good-code", state, callback); err != ErrInvalidState {
+		t.Errorf("Expected other app to reject state, got %v", err)
+	}
+
+	authURL, _ = service.StartAppOAuthFlow("app1", "github", "/notes", callback)
+	u, _ = url.Parse(authURL)
+	state = u.Query().Get("state")
+
+	token, user, redirectTo, err := service.CompleteAppOAuthFlow("app1", "github", "good-code", state, callback)
+	if err != nil {
+		t.Fatalf("CompleteAppOAuthFlow failed: %v", err)
+	}
+	if redirectTo != "/notes" {
+		t.Errorf("Expected redirect /notes, got %s", redirectTo)
+	}
+	if !strings.HasPrefix(user.ID, "fazt_apu_") || user.Email != "octo@example.com" || user.Name != "octo" || user.AppID != "app1" {
+		t.Errorf("Unexpected app user: %+v", user)
+	}
+
+	// App users are not platform users
+	if _, err := service.GetUserByEmail("octo@example.com"); err == nil {
+		t.Error("App login must not create a platform user")
+	}
+	if _, err := service.ValidateSession(token); err == nil {
+		t.Error("App session must not be a platform session")
+	}
+
+	got, err := service.ValidateAppSession("app1", token)
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("ValidateAppSession failed: %+v, %v", got, err)
+	}
+	if _, err := service.ValidateAppSession("app2", token); err != ErrInvalidSession {
+		t.Errorf("Session must not validate for another app, got %v", err)
+	}
+
+	// Signing in again keeps the same app user
+	authURL, _ = service.StartAppOAuthFlow("app1", "github", "/", callback)
+	u, _ = url.Parse(authURL)
+	_, again, _, err := service.CompleteAppOAuthFlow("app1", "github", "good-code", u.Query().Get("state"), callback)
+	if err != nil || again.ID != user.ID {
+		t.Errorf("Expected the same app user on second login, got %+v, %v", again, err)
+	}
+
+	if err := service.DeleteAppUser("app1", user.ID); err != nil {
+		t.Fatalf("DeleteAppUser failed: %v", err)
+	}
+	if _, err := service.ValidateAppSession("app1", token); err != ErrInvalidSession {
+		t.Errorf("Deleting the user should end their sessions, got %v", err)
+	}
+	if err := service.DeleteAppUser("app1", user.ID); err != ErrAppUserNotFound {
+		t.Errorf("Expected ErrAppUserNotFound, got %v", err)
+	}
+}
+
+func TestAppLoginHandlers(t *testing.T) {
+	fakeGitHub(t, `{"id":7,"login":"cat","email":"cat@example.com"}`)
+
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	handler := NewHandler(service)
+
+	// No providers configured
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://app1.test.com/auth/app/app1/login", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without providers, got %d", rr.Code)
+	}
+
+	service.SetAppProvider("app1", "github", "app-client", "app-secret")
+
+	// The only enabled provider is the default; external redirects are dropped
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://app1.test.com/auth/app/app1/login?redirect=//evil.com", nil))
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected redirect to provider, got %d: %s", rr.Code, rr.Body.String())
+	}
+	u, _ := url.Parse(rr.Header().Get("Location"))
+	if u.Query().Get("redirect_uri") != "http://app1.test.com/auth/app/app1/callback/github" {
+		t.Errorf("Unexpected redirect_uri: %s", u.Query().Get("redirect_uri"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://app1.test.com/auth/app/app1/callback/github?code=good-code&state="+u.Query().Get("state"), nil))
+	if rr.Code != http.StatusTemporaryRedirect || rr.Header().Get("Location") != "/" {
+		t.Fatalf("Expected redirect to /, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	var session *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == AppSessionCookieName {
+			session = c
+		}
+	}
+	if session == nil || session.Domain != "" || !session.HttpOnly {
+		t.Fatalf("Expected host-only app session cookie, got %+v", session)
+	}
+
+	req := httptest.NewRequest("GET", "http://app1.test.com/", nil)
+	req.AddCookie(session)
+	user, err := service.GetAppUserFromRequest(req, "app1")
+	if err != nil || user.Email != "cat@example.com" {
+		t.Fatalf("GetAppUserFromRequest failed: %+v, %v", user, err)
+	}
+
+	// Logout ends the session
+	req = httptest.NewRequest("GET", "http://app1.test.com/auth/app/app1/logout", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected logout redirect, got %d", rr.Code)
+	}
+	if _, err := service.ValidateAppSession("app1", session.Value); err == nil {
+		t.Error("Session should be gone after logout")
+	}
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+)
+
+// CSRFCookieName is the script-readable cookie holding the dashboard's CSRF
+// token. Clients echo it in the X-CSRF-Token header on state-changing requests.
+const CSRFCookieName = "fazt_csrf"
+
+// CSRFHeader is the request header carrying the CSRF token
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFToken derives the CSRF token for a session. It is bound to the session
+// token, so it needs no storage and a cookie planted by another subdomain
+// can't forge it.
+func CSRFToken(sessionToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + sessionToken))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidCSRFToken reports whether token is the CSRF token for sessionToken
+func ValidCSRFToken(sessionToken, token string) bool {
+	if sessionToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(CSRFToken(sessionToken)), []byte(token)) == 1
+}
+
+// CSRFCookie creates the host-only cookie exposing a session's CSRF token to
+// the dashboard's scripts. It is deliberately not HttpOnly.
+func (s *Service) CSRFCookie(sessionToken string) *http.Cookie {
+	return &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    CSRFToken(sessionToken),
+		Path:     "/",
+		MaxAge:   int(DefaultSessionTTL.Seconds()),
+		Secure:   s.secure,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
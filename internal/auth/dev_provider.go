@@ -150,6 +150,7 @@ func (h *Handler) DevLoginCallback(w http.ResponseWriter, r *http.Request) {
 		h.renderDevLoginPage(w, redirectTo, "Failed to create session: "+err.Error())
 		return
 	}
+	h.service.TagSession(sessionToken, r)
 
 	// Set session cookie
 	http.SetCookie(w, h.service.SessionCookie(sessionToken, int(DefaultSessionTTL.Seconds())))
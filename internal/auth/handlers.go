@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/approval"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/security"
 )
 
 // Handler wraps the auth service for HTTP handlers
@@ -43,7 +47,9 @@ func (h *Handler) registerInternalRoutes() {
 	h.mux.HandleFunc("GET /auth/login/{provider}", h.StartLogin)
 	h.mux.HandleFunc("GET /auth/callback/{provider}", h.Callback)
 	h.mux.HandleFunc("GET /auth/session", h.Session)
+	h.mux.HandleFunc("GET /auth/handoff", h.Handoff)
 	h.mux.HandleFunc("POST /auth/logout", h.Logout)
+	h.mux.HandleFunc("POST /auth/2fa", h.SecondFactor)
 
 	// Dev login routes (local only)
 	h.mux.HandleFunc("GET /auth/dev/login", h.DevLoginForm)
@@ -52,6 +58,11 @@ func (h *Handler) registerInternalRoutes() {
 	// Invite routes
 	h.mux.HandleFunc("GET /auth/invite/{code}", h.InvitePage)
 	h.mux.HandleFunc("POST /auth/invite/{code}", h.RedeemInvite)
+
+	// App end-user login (per-app OAuth providers)
+	h.mux.HandleFunc("GET /auth/app/{id}/login", h.AppLogin)
+	h.mux.HandleFunc("GET /auth/app/{id}/callback/{provider}", h.AppCallback)
+	h.mux.HandleFunc("/auth/app/{id}/logout", h.AppLogout)
 }
 
 // RegisterRoutes registers auth routes on a mux
@@ -62,7 +73,9 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /auth/login/{provider}", h.StartLogin)
 	mux.HandleFunc("GET /auth/callback/{provider}", h.Callback)
 	mux.HandleFunc("GET /auth/session", h.Session)
+	mux.HandleFunc("GET /auth/handoff", h.Handoff)
 	mux.HandleFunc("POST /auth/logout", h.Logout)
+	mux.HandleFunc("POST /auth/2fa", h.SecondFactor)
 
 	// Dev login routes (local only)
 	mux.HandleFunc("GET /auth/dev/login", h.DevLoginForm)
@@ -72,6 +85,11 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /auth/invite/{code}", h.InvitePage)
 	mux.HandleFunc("POST /auth/invite/{code}", h.RedeemInvite)
 
+	// App end-user login (per-app OAuth providers)
+	mux.HandleFunc("GET /auth/app/{id}/login", h.AppLogin)
+	mux.HandleFunc("GET /auth/app/{id}/callback/{provider}", h.AppCallback)
+	mux.HandleFunc("/auth/app/{id}/logout", h.AppLogout)
+
 	// Admin routes (require authentication)
 	mux.HandleFunc("GET /auth/users", h.ListUsers)
 	mux.HandleFunc("GET /auth/users/{id}", h.GetUser)
@@ -100,8 +118,8 @@ func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If no providers configured and not in local mode, show setup message
-	if len(providers) == 0 && !IsLocalMode(r) {
+	// If no providers or passkeys are configured and not in local mode, show setup message
+	if len(providers) == 0 && !IsLocalMode(r) && !h.service.HasPasskeys() {
 		h.renderLoginPageWithRequest(w, r, nil, redirectTo, "No login providers configured. Contact the administrator.")
 		return
 	}
@@ -142,6 +160,7 @@ func (h *Handler) SimpleLogin(w http.ResponseWriter, r *http.Request) {
 		api.InternalError(w, err)
 		return
 	}
+	h.service.TagSession(token, r)
 
 	// Set cookie (24 hour session)
 	http.SetCookie(w, h.service.SessionCookie(token, 24*60*60))
@@ -170,6 +189,12 @@ func (h *Handler) StartLogin(w http.ResponseWriter, r *http.Request) {
 	if !h.service.IsSecure() {
 		scheme = "http"
 	}
+
+	// Remember the host the login started on, so the callback can hand the
+	// session over to it
+	if !strings.Contains(redirectTo, "://") {
+		redirectTo = fmt.Sprintf("%s://%s%s", scheme, r.Host, localRedirect(redirectTo))
+	}
 	// Use configured domain (root), not request host (could be subdomain)
 	callbackURL := fmt.Sprintf("%s://%s/auth/callback/%s", scheme, h.service.Domain(), providerName)
 
@@ -215,25 +240,24 @@ func (h *Handler) Callback(w http.ResponseWriter, r *http.Request) {
 	callbackURL := fmt.Sprintf("%s://%s/auth/callback/%s", scheme, h.service.Domain(), providerName)
 
 	// Complete OAuth flow
-	sessionToken, _, redirectTo, err := h.service.CompleteOAuthFlow(providerName, code, state, callbackURL)
+	user, redirectTo, err := h.service.CompleteOAuthFlow(providerName, code, state, callbackURL)
 	if err != nil {
 		h.renderErrorPage(w, "Authentication failed: "+err.Error())
 		return
 	}
 
-	// Set session cookie
-	http.SetCookie(w, h.service.SessionCookie(sessionToken, int(DefaultSessionTTL.Seconds())))
-
-	// Redirect to original destination
-	if redirectTo == "" {
-		redirectTo = "/"
+	// No session until the second factor passes, for users who need one
+	if h.service.NeedsSecondFactor(user.ID) {
+		h.challengeLogin(w, user, redirectTo)
+		return
 	}
-	http.Redirect(w, r, redirectTo, http.StatusTemporaryRedirect)
+
+	h.finishLogin(w, r, user, redirectTo, nil)
 }
 
 // Session returns the current session info
 func (h *Handler) Session(w http.ResponseWriter, r *http.Request) {
-	user, err := h.service.GetSessionFromRequest(r)
+	token, user, err := h.service.sessionFromRequest(r)
 	if err != nil {
 		api.Success(w, http.StatusOK, map[string]interface{}{
 			"authenticated": false,
@@ -241,18 +265,17 @@ func (h *Handler) Session(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	http.SetCookie(w, h.service.CSRFCookie(token))
 	api.Success(w, http.StatusOK, map[string]interface{}{
 		"authenticated": true,
 		"user":          user,
+		"csrf_token":    CSRFToken(token),
 	})
 }
 
 // Logout clears the session
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("fazt_session")
-	if err == nil && cookie.Value != "" {
-		h.service.DeleteSession(cookie.Value)
-	}
+	h.service.DeleteSession(h.service.SessionToken(r))
 
 	http.SetCookie(w, h.service.ClearSessionCookie())
 
@@ -347,6 +370,15 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 			api.BadRequest(w, err.Error())
 			return
 		}
+		if targetUser.Role != req.Role {
+			security.Record(h.service.db, security.Event{
+				Type:    security.EventRoleChanged,
+				Actor:   currentUser.Email,
+				IP:      realip.FromRequest(r),
+				Subject: targetUser.Email,
+				Details: map[string]interface{}{"user_id": targetUser.ID, "from": targetUser.Role, "to": req.Role},
+			})
+		}
 	}
 
 	user, err := h.service.GetUserByID(userID)
@@ -390,6 +422,26 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// With approvals required, queue the deletion for a second admin
+	if approval.Required() {
+		a, err := approval.Submit(h.service.db, approval.Request{
+			Operation:   approval.OpUserDelete,
+			Target:      userID,
+			Summary:     "Delete user " + targetUser.Email,
+			Params:      map[string]string{"user_id": userID},
+			RequestedBy: currentUser.ID,
+		}, time.Now())
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		api.Success(w, http.StatusAccepted, map[string]interface{}{
+			"approval": a,
+			"message":  "User deletion is pending approval by another admin",
+		})
+		return
+	}
+
 	if err := h.service.DeleteUser(userID); err != nil {
 		api.InternalError(w, err)
 		return
@@ -458,6 +510,12 @@ func (h *Handler) renderLoginPageWithRequest(w http.ResponseWriter, r *http.Requ
     <p class="hint">Simulates OAuth for local testing</p>`, redirectTo)
 	}
 
+	// Offer passkey sign-in once any passkey is registered
+	passkeyHTML := ""
+	if h.service.HasPasskeys() {
+		passkeyHTML = renderPasskeyLogin(redirectTo)
+	}
+
 	errorHTML := ""
 	if errorMsg != "" {
 		errorHTML = fmt.Sprintf(`<div class="error">%s</div>`, errorMsg)
@@ -545,6 +603,14 @@ func (h *Handler) renderLoginPageWithRequest(w http.ResponseWriter, r *http.Requ
       background: #444;
       color: #fff;
     }
+    .provider-btn.passkey {
+      width: 100%%;
+      border: none;
+      font-size: 16px;
+      cursor: pointer;
+      background: #2563eb;
+      color: #fff;
+    }
     .divider {
       display: flex;
       align-items: center;
@@ -583,6 +649,7 @@ func (h *Handler) renderLoginPageWithRequest(w http.ResponseWriter, r *http.Requ
     <h1>Sign In</h1>
     <p class="subtitle">to %s</p>
     %s
+    %s
     <div class="providers">
       %s
     </div>
@@ -590,11 +657,65 @@ func (h *Handler) renderLoginPageWithRequest(w http.ResponseWriter, r *http.Requ
     <p class="footer">Powered by Fazt</p>
   </div>
 </body>
-</html>`, h.service.Domain(), errorHTML, providerButtons.String(), devLoginHTML)
+</html>`, h.service.Domain(), errorHTML, passkeyHTML, providerButtons.String(), devLoginHTML)
 
 	w.Write([]byte(html))
 }
 
+// renderPasskeyLogin returns the passkey button and the WebAuthn script that
+// drives /auth/passkey/login/{begin,finish}, then redirects to redirectTo
+func renderPasskeyLogin(redirectTo string) string {
+	target, _ := json.Marshal(redirectTo) // HTML-safe: escapes <, >, &
+	return `<button type="button" id="passkey-btn" class="provider-btn passkey">Sign in with a passkey</button>
+    <p class="hint" id="passkey-error"></p>
+    <div class="divider"><span>or</span></div>
+    <script>
+    (function() {
+      var btn = document.getElementById('passkey-btn');
+      if (!window.PublicKeyCredential) { btn.style.display = 'none'; return; }
+      function dec(s) {
+        s = s.replace(/-/g, '+').replace(/_/g, '/');
+        while (s.length % 4) s += '=';
+        return Uint8Array.from(atob(s), function(c) { return c.charCodeAt(0); });
+      }
+      function enc(buf) {
+        return btoa(String.fromCharCode.apply(null, new Uint8Array(buf)))
+          .replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+      }
+      async function post(url, body) {
+        var res = await fetch(url, {
+          method: 'POST',
+          credentials: 'same-origin',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(body || {})
+        });
+        var data = await res.json();
+        if (!res.ok) throw new Error((data.error && data.error.message) || 'Passkey sign-in failed');
+        return data.data;
+      }
+      btn.addEventListener('click', async function() {
+        var errEl = document.getElementById('passkey-error');
+        errEl.textContent = '';
+        try {
+          var opts = (await post('/auth/passkey/login/begin')).publicKey;
+          opts.challenge = dec(opts.challenge);
+          var cred = await navigator.credentials.get({ publicKey: opts });
+          await post('/auth/passkey/login/finish', { credential: {
+            id: cred.id,
+            clientDataJSON: enc(cred.response.clientDataJSON),
+            authenticatorData: enc(cred.response.authenticatorData),
+            signature: enc(cred.response.signature),
+            userHandle: cred.response.userHandle ? enc(cred.response.userHandle) : ''
+          }});
+          window.location.href = ` + string(target) + `;
+        } catch (e) {
+          errEl.textContent = e.name === 'NotAllowedError' ? 'Passkey sign-in was cancelled' : e.message;
+        }
+      });
+    })();
+    </script>`
+}
+
 func (h *Handler) renderErrorPage(w http.ResponseWriter, errorMsg string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
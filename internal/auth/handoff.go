@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Session handoff.
+// Session cookies are host-only, so a login that finishes on another host
+// than the one it started from (OAuth callbacks land on the root domain)
+// hands the user over with a one-time code. The host redeems the code for a
+// session of its own, bound to that host: no two hosts share a session, and
+// a hosted app never holds a token that works on the dashboard.
+
+const (
+	// HandoffTTL is how long a handoff code can be redeemed
+	HandoffTTL = time.Minute
+)
+
+// ErrInvalidHandoff is returned for unknown, used, expired or misdirected codes
+var ErrInvalidHandoff = errors.New("invalid or expired handoff")
+
+// CreateHandoff issues a one-time code that signs userID in on host
+func (s *Service) CreateHandoff(userID, host string) (string, error) {
+	code, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().Unix()
+	_, err = s.db.Exec(`
+		INSERT INTO auth_handoffs (code_hash, user_id, host, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashToken(code), userID, strings.ToLower(host), now, now+int64(HandoffTTL.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemHandoff consumes a code presented on host and returns a new session
+// token bound to that host
+func (s *Service) RedeemHandoff(code, host string) (string, error) {
+	if code == "" {
+		return "", ErrInvalidHandoff
+	}
+
+	codeHash := hashToken(code)
+	var userID, boundHost string
+	var expiresAt int64
+	err := s.db.QueryRow(`
+		SELECT user_id, host, expires_at FROM auth_handoffs WHERE code_hash = ?
+	`, codeHash).Scan(&userID, &boundHost, &expiresAt)
+	if err != nil {
+		return "", ErrInvalidHandoff
+	}
+
+	// One-time use: only the request that deletes the code redeems it
+	result, err := s.db.Exec(`DELETE FROM auth_handoffs WHERE code_hash = ?`, codeHash)
+	if err != nil {
+		return "", err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", ErrInvalidHandoff
+	}
+	if time.Now().Unix() > expiresAt || boundHost != strings.ToLower(host) {
+		return "", ErrInvalidHandoff
+	}
+
+	token, err := s.CreateSession(userID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.db.Exec(`UPDATE auth_sessions SET host = ? WHERE token_hash = ?`, boundHost, hashToken(token)); err != nil {
+		s.DeleteSession(token)
+		return "", err
+	}
+	return token, nil
+}
+
+// Handoff signs the user in on this host with a code from a login that
+// finished on another host
+// GET /auth/handoff?code=...&redirect=/
+func (h *Handler) Handoff(w http.ResponseWriter, r *http.Request) {
+	token, err := h.service.RedeemHandoff(r.URL.Query().Get("code"), requestHost(r))
+	if err != nil {
+		h.renderErrorPage(w, "This sign-in link is invalid or has expired. Please sign in again.")
+		return
+	}
+	h.service.TagSession(token, r)
+
+	http.SetCookie(w, h.service.SessionCookie(token, int(DefaultSessionTTL.Seconds())))
+	http.Redirect(w, r, localRedirect(r.URL.Query().Get("redirect")), http.StatusTemporaryRedirect)
+}
+
+// handoffURL returns where to send a user whose login finished on this host
+// but should land on target's host, or "" if target is on this host (or
+// isn't a platform host at all, in which case the login lands here).
+func (h *Handler) handoffURL(r *http.Request, userID string, target *url.URL) (string, error) {
+	if target.Host == "" || !h.service.isPlatformHost(target.Hostname()) ||
+		strings.EqualFold(target.Hostname(), requestHost(r)) {
+		return "", nil
+	}
+
+	code, err := h.service.CreateHandoff(userID, target.Hostname())
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "https"
+	if !h.service.IsSecure() {
+		scheme = "http"
+	}
+	query := url.Values{"code": {code}, "redirect": {localRedirect(target.RequestURI())}}
+	return fmt.Sprintf("%s://%s/auth/handoff?%s", scheme, target.Host, query.Encode()), nil
+}
+
+// isPlatformHost reports whether host is the platform's domain or one of its
+// subdomains
+func (s *Service) isPlatformHost(host string) bool {
+	domain := strings.ToLower(s.domain)
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://")
+	if h, _, err := net.SplitHostPort(domain); err == nil {
+		domain = h
+	}
+	host = strings.ToLower(host)
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// requestHost is the host a request was made to, lowercased without the port
+func requestHost(r *http.Request) string {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
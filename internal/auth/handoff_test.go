@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestLoginHandoff(t *testing.T) {
+	fakeGitHub(t, `{"id":9,"login":"ada","email":"ada@example.com"}`)
+
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	handler := NewHandler(service)
+	service.SetProviderConfig("github", "app-client", "app-secret")
+	service.EnableProvider("github")
+
+	// Login starts on an app host; the callback lands on the root domain
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://app1.test.com/auth/login/github?redirect=/notes", nil))
+	u, _ := url.Parse(rr.Header().Get("Location"))
+	if u.Query().Get("redirect_uri") != "http://test.com/auth/callback/github" {
+		t.Fatalf("Unexpected redirect_uri: %s", u.Query().Get("redirect_uri"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://test.com/auth/callback/github?code=good-code&state="+u.Query().Get("state"), nil))
+	if len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected no session on the root domain, got %v", rr.Result().Cookies())
+	}
+	handoff, _ := url.Parse(rr.Header().Get("Location"))
+	if rr.Code != http.StatusTemporaryRedirect || handoff.Host != "app1.test.com" || handoff.Path != "/auth/handoff" ||
+		handoff.Query().Get("redirect") != "/notes" {
+		t.Fatalf("Expected a handoff to the app host, got %d %s", rr.Code, handoff)
+	}
+
+	// The app host redeems it for a host-only session of its own
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", handoff.String(), nil))
+	if rr.Header().Get("Location") != "/notes" {
+		t.Fatalf("Expected redirect to /notes, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	var session *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			session = c
+		}
+	}
+	if session == nil || session.Domain != "" {
+		t.Fatalf("Expected a host-only session cookie, got %+v", session)
+	}
+
+	req := httptest.NewRequest("GET", "http://app1.test.com/", nil)
+	req.AddCookie(session)
+	if user, err := service.GetSessionFromRequest(req); err != nil || user.Email != "ada@example.com" {
+		t.Fatalf("Expected the session valid on the app host: %+v, %v", user, err)
+	}
+
+	// The app's session is refused on the dashboard
+	req = httptest.NewRequest("GET", "http://admin.test.com/api/auth/me", nil)
+	req.AddCookie(session)
+	if _, err := service.GetSessionFromRequest(req); err == nil {
+		t.Error("Expected the app host's session refused on the admin host")
+	}
+
+	// Codes are single-use
+	if _, err := service.RedeemHandoff(handoff.Query().Get("code"), "app1.test.com"); err != ErrInvalidHandoff {
+		t.Errorf("Expected a used code refused, got %v", err)
+	}
+}
+
+func TestRedeemHandoffWrongHost(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	user, _ := service.CreateUser("ada@example.com", "Ada", "", "google", nil)
+
+	code, err := service.CreateHandoff(user.ID, "app1.test.com")
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if _, err := service.RedeemHandoff(code, "admin.test.com"); err != ErrInvalidHandoff {
+		t.Errorf("Expected a code for another host refused, got %v", err)
+	}
+	if _, err := service.RedeemHandoff(code, "app1.test.com"); err != ErrInvalidHandoff {
+		t.Errorf("Expected a misdirected code to be spent, got %v", err)
+	}
+}
+
+func TestSessionCookieInjection(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	user, _ := service.CreateUser("ada@example.com", "Ada", "", "google", nil)
+	token, _ := service.CreateSession(user.ID)
+
+	// A sibling subdomain planted a parent-domain cookie ahead of the real one
+	req := httptest.NewRequest("GET", "http://admin.test.com/", nil)
+	req.Header.Set("Cookie", SessionCookieName+"=planted; "+SessionCookieName+"="+token)
+	got, err := service.GetSessionFromRequest(req)
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("Expected the valid session to win, got %+v, %v", got, err)
+	}
+	if service.SessionToken(req) != token {
+		t.Error("SessionToken should return the valid cookie's token")
+	}
+}
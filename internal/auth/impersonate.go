@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"time"
+)
+
+// ImpersonationTTL is how long an impersonation session lasts. It is short
+// and never refreshed: impersonation is for debugging, not for working as
+// another user.
+const ImpersonationTTL = time.Hour
+
+// CreateImpersonationSession mints a session that acts as userID on behalf of
+// impersonator (an admin user ID or "api_key:<name>"). Only regular users can
+// be impersonated, so the session never grants more than the target already
+// has. The user's last login is not touched.
+func (s *Service) CreateImpersonationSession(userID, impersonator string) (string, int64, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return "", 0, err
+	}
+	if user.Role != "user" || user.ID == impersonator {
+		return "", 0, ErrCannotImpersonate
+	}
+
+	token, err := generateToken(32)
+	if err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now().Unix()
+	expiresAt := now + int64(ImpersonationTTL.Seconds())
+
+	_, err = s.db.Exec(`
+		INSERT INTO auth_sessions (token_hash, user_id, created_at, expires_at, last_seen, impersonator_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, hashToken(token), userID, now, expiresAt, now, impersonator)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// EndImpersonation removes all impersonation sessions for a user and returns
+// how many were ended. The user's own sessions are kept.
+func (s *Service) EndImpersonation(userID string) (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM auth_sessions WHERE user_id = ? AND impersonator_id IS NOT NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestImpersonationSession(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+
+	user, _ := service.CreateUser("user@test.com", "User", "", "google", nil)
+	admin, _ := service.CreateUser("admin@test.com", "Admin", "", "google", nil)
+	service.UpdateUserRole(admin.ID, "admin")
+
+	if _, _, err := service.CreateImpersonationSession(admin.ID, "someone"); err != ErrCannotImpersonate {
+		t.Errorf("Expected admins to be off limits, got %v", err)
+	}
+	if _, _, err := service.CreateImpersonationSession("fazt_usr_missing", admin.ID); err == nil {
+		t.Error("Expected unknown user to fail")
+	}
+
+	own, _ := service.CreateSession(user.ID)
+	token, expiresAt, err := service.CreateImpersonationSession(user.ID, admin.ID)
+	if err != nil {
+		t.Fatalf("CreateImpersonationSession failed: %v", err)
+	}
+	if expiresAt-time.Now().Unix() > int64(ImpersonationTTL.Seconds()) {
+		t.Errorf("Expiry too far out: %d", expiresAt)
+	}
+
+	got, err := service.ValidateSession(token)
+	if err != nil || got.ID != user.ID || got.ImpersonatedBy != admin.ID {
+		t.Fatalf("Expected impersonated user, got %+v, %v", got, err)
+	}
+	if got, _ := service.ValidateSession(own); got.ImpersonatedBy != "" {
+		t.Errorf("User's own session must not be flagged, got %q", got.ImpersonatedBy)
+	}
+
+	// Impersonation sessions are never extended
+	service.RefreshSession(token)
+	var expires int64
+	db.QueryRow(`SELECT expires_at FROM auth_sessions WHERE token_hash = ?`, hashToken(token)).Scan(&expires)
+	if expires != expiresAt {
+		t.Errorf("Impersonation session was extended: %d -> %d", expiresAt, expires)
+	}
+
+	ended, err := service.EndImpersonation(user.ID)
+	if err != nil || ended != 1 {
+		t.Fatalf("EndImpersonation = %d, %v; want 1", ended, err)
+	}
+	if _, err := service.ValidateSession(token); err != ErrInvalidSession {
+		t.Errorf("Expected impersonation session gone, got %v", err)
+	}
+	if _, err := service.ValidateSession(own); err != nil {
+		t.Errorf("User's own session should survive, got %v", err)
+	}
+}
@@ -323,6 +323,29 @@ func (h *Handler) RedeemInvite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A server that requires 2FA has the new user set it up before the
+	// account gets a session
+	if h.service.NeedsSecondFactor(user.ID) {
+		if !isJSON {
+			h.challengeLogin(w, user, "/")
+			return
+		}
+		challenge, err := h.service.CreateLoginChallenge(user.ID, false)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		api.Success(w, http.StatusCreated, map[string]interface{}{
+			"user":                  user,
+			"message":               "Account created; two-factor authentication required",
+			"two_factor_required":   true,
+			"two_factor_enrolled":   h.service.TwoFactorEnabled(user.ID),
+			"two_factor_token":      challenge,
+			"two_factor_expires_in": int(LoginChallengeTTL.Seconds()),
+		})
+		return
+	}
+
 	// Create session
 	sessionToken, err := h.service.CreateSession(user.ID)
 	if err != nil {
@@ -333,6 +356,7 @@ func (h *Handler) RedeemInvite(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	h.service.TagSession(sessionToken, r)
 
 	// Set session cookie
 	http.SetCookie(w, h.service.SessionCookie(sessionToken, int(DefaultSessionTTL.Seconds())))
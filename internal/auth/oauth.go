@@ -145,31 +145,26 @@ func (s *Service) StartOAuthFlow(providerName, redirectTo, appID, callbackURL st
 	return authURL, nil
 }
 
-// CompleteOAuthFlow processes the OAuth callback and creates a session
-// Returns the session token
-func (s *Service) CompleteOAuthFlow(providerName, code, state, callbackURL string) (string, *User, string, error) {
+// CompleteOAuthFlow processes the OAuth callback and returns the user and
+// where the login should land. The caller creates the session, once any
+// second factor has passed.
+func (s *Service) CompleteOAuthFlow(providerName, code, state, callbackURL string) (*User, string, error) {
 	// Validate state
 	oauthState, err := s.ValidateState(state)
 	if err != nil {
-		return "", nil, "", err
+		return nil, "", err
 	}
 
 	// Verify provider matches
 	if oauthState.Provider != providerName {
-		return "", nil, "", ErrInvalidState
+		return nil, "", ErrInvalidState
 	}
 
 	// Handle the OAuth callback
 	user, err := s.HandleOAuthCallback(providerName, code, callbackURL)
 	if err != nil {
-		return "", nil, "", err
+		return nil, "", err
 	}
 
-	// Create session
-	sessionToken, err := s.CreateSession(user.ID)
-	if err != nil {
-		return "", nil, "", err
-	}
-
-	return sessionToken, user, oauthState.RedirectTo, nil
+	return user, oauthState.RedirectTo, nil
 }
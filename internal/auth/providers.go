@@ -322,20 +322,7 @@ func (s *Service) BuildAuthURL(providerName, state, redirectURI string) (string,
 		return "", ErrProviderDisabled
 	}
 
-	params := url.Values{}
-	params.Set("client_id", cfg.ClientID)
-	params.Set("redirect_uri", redirectURI)
-	params.Set("response_type", "code")
-	params.Set("scope", strings.Join(provider.Scopes, " "))
-	params.Set("state", state)
-
-	// Provider-specific params
-	if providerName == "google" {
-		params.Set("access_type", "offline")
-		params.Set("prompt", "select_account")
-	}
-
-	return provider.AuthURL + "?" + params.Encode(), nil
+	return provider.authURL(cfg.ClientID, state, redirectURI), nil
 }
 
 // ExchangeCode exchanges an authorization code for tokens
@@ -350,14 +337,37 @@ func (s *Service) ExchangeCode(providerName, code, redirectURI string) (string,
 		return "", err
 	}
 
+	return provider.exchangeCode(cfg.ClientID, cfg.ClientSecret, code, redirectURI)
+}
+
+// authURL builds the authorization URL for the given OAuth client
+func (p *OAuthProvider) authURL(clientID, state, redirectURI string) string {
+	params := url.Values{}
+	params.Set("client_id", clientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(p.Scopes, " "))
+	params.Set("state", state)
+
+	// Provider-specific params
+	if p.Name == "google" {
+		params.Set("access_type", "offline")
+		params.Set("prompt", "select_account")
+	}
+
+	return p.AuthURL + "?" + params.Encode()
+}
+
+// exchangeCode exchanges an authorization code for an access token using the given OAuth client
+func (p *OAuthProvider) exchangeCode(clientID, clientSecret, code, redirectURI string) (string, error) {
 	data := url.Values{}
-	data.Set("client_id", cfg.ClientID)
-	data.Set("client_secret", cfg.ClientSecret)
+	data.Set("client_id", clientID)
+	data.Set("client_secret", clientSecret)
 	data.Set("code", code)
 	data.Set("redirect_uri", redirectURI)
 	data.Set("grant_type", "authorization_code")
 
-	req, err := http.NewRequest("POST", provider.TokenURL, strings.NewReader(data.Encode()))
+	req, err := http.NewRequest("POST", p.TokenURL, strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Second factor for browser logins.
+// OAuth callbacks and invite signups finish in the browser rather than in
+// the dashboard's JSON client, so when the user must pass 2FA the page asks
+// for the code itself. As with password logins, the login waits as a
+// challenge and gets a session only once the code checks out. A user the
+// server requires 2FA from who hasn't set it up yet enrolls on the same page.
+
+// challengeLogin holds back a login whose first factor passed until the user
+// enters their second factor
+func (h *Handler) challengeLogin(w http.ResponseWriter, user *User, redirectTo string) {
+	token, err := h.service.CreateLoginChallenge(user.ID, false)
+	if err != nil {
+		h.renderErrorPage(w, "Failed to start sign-in")
+		return
+	}
+	h.renderSecondFactorPage(w, token, user, redirectTo, "")
+}
+
+// SecondFactor completes a challenged browser login with a TOTP or recovery
+// code, or with the first code from a required enrollment
+// POST /auth/2fa (form: token, code, redirect)
+func (h *Handler) SecondFactor(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	token := r.FormValue("token")
+	code := strings.TrimSpace(r.FormValue("code"))
+	redirectTo := r.FormValue("redirect")
+
+	challenge, err := h.service.GetLoginChallenge(token)
+	if err != nil {
+		h.renderErrorPage(w, "This sign-in has expired. Please sign in again.")
+		return
+	}
+	user, err := h.service.GetUserByID(challenge.UserID)
+	if err != nil {
+		h.renderErrorPage(w, "This sign-in has expired. Please sign in again.")
+		return
+	}
+
+	var recoveryCodes []string
+	if h.service.TwoFactorEnabled(user.ID) {
+		_, err = h.service.CompleteLoginChallenge(token, code)
+	} else {
+		_, recoveryCodes, err = h.service.EnableTOTPForChallenge(token, code)
+	}
+	if err == ErrInvalidTOTPCode {
+		h.renderSecondFactorPage(w, token, user, redirectTo, "Invalid authentication code")
+		return
+	}
+	if err != nil {
+		h.renderErrorPage(w, "Authentication failed: "+err.Error())
+		return
+	}
+
+	h.finishLogin(w, r, user, redirectTo, recoveryCodes)
+}
+
+// finishLogin signs in a user who has passed every factor: on the target's
+// host through a handoff, or here with a session cookie. Recovery codes from
+// an enrollment that just completed are shown before moving on.
+func (h *Handler) finishLogin(w http.ResponseWriter, r *http.Request, user *User, redirectTo string, recoveryCodes []string) {
+	target, err := url.Parse(redirectTo)
+	if err != nil {
+		target = &url.URL{Path: "/"}
+	}
+
+	// A login started on another host (the dashboard or an app) is handed
+	// over to it; no session is made here
+	next, err := h.handoffURL(r, user.ID, target)
+	if err != nil {
+		h.renderErrorPage(w, "Authentication failed: "+err.Error())
+		return
+	}
+	if next == "" {
+		token, err := h.service.CreateSession(user.ID)
+		if err != nil {
+			h.renderErrorPage(w, "Failed to create session")
+			return
+		}
+		h.service.TagSession(token, r)
+		http.SetCookie(w, h.service.SessionCookie(token, int(DefaultSessionTTL.Seconds())))
+		next = localRedirect(target.RequestURI())
+	}
+
+	if len(recoveryCodes) > 0 {
+		h.renderRecoveryCodesPage(w, recoveryCodes, next)
+		return
+	}
+
+	// Form posts must not be replayed against the destination
+	status := http.StatusTemporaryRedirect
+	if r.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+	http.Redirect(w, r, next, status)
+}
+
+// pendingTOTPSecret returns the secret of an enrollment not yet confirmed
+func (s *Service) pendingTOTPSecret(userID string) string {
+	var secret string
+	s.db.QueryRow(`SELECT secret FROM auth_totp WHERE user_id = ? AND enabled = 0`, userID).Scan(&secret)
+	return secret
+}
+
+func (h *Handler) renderSecondFactorPage(w http.ResponseWriter, token string, user *User, redirectTo, errorMsg string) {
+	// Users who must enroll get a key to add to their authenticator app.
+	// Redisplays keep the pending key rather than starting over.
+	title := "Two-Factor Authentication"
+	prompt := "Enter the code from your authenticator app, or a recovery code."
+	enrollHTML := ""
+	if !h.service.TwoFactorEnabled(user.ID) {
+		secret := h.service.pendingTOTPSecret(user.ID)
+		if secret == "" {
+			var err error
+			if secret, _, err = h.service.EnrollTOTP(user); err != nil {
+				h.renderErrorPage(w, "Failed to set up two-factor authentication")
+				return
+			}
+		}
+		title = "Set Up Two-Factor Authentication"
+		prompt = "This server requires two-factor authentication. Add this key to your authenticator app, then enter the code it shows."
+		enrollHTML = fmt.Sprintf(`<div class="secret">%s</div>
+    <p class="hint"><a href="%s">Open in authenticator app</a></p>`,
+			secret, html.EscapeString(ProvisioningURI(secret, user.Email)))
+	}
+
+	errorHTML := ""
+	if errorMsg != "" {
+		errorHTML = fmt.Sprintf(`<div class="error">%s</div>`, errorMsg)
+	}
+
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>%s - Fazt</title>
+  <style>%s</style>
+</head>
+<body>
+  <div class="container">
+    <h1>%s</h1>
+    <p class="subtitle">%s</p>
+    %s
+    %s
+    <form method="POST" action="/auth/2fa">
+      <input type="hidden" name="token" value="%s">
+      <input type="hidden" name="redirect" value="%s">
+      <div>
+        <label for="code">Code</label>
+        <input type="text" id="code" name="code" required autofocus autocomplete="one-time-code" placeholder="123456">
+      </div>
+      <button type="submit">Continue</button>
+    </form>
+    <p class="footer">Powered by Fazt</p>
+  </div>
+</body>
+</html>`, title, secondFactorStyle, title, prompt, errorHTML, enrollHTML,
+		html.EscapeString(token), html.EscapeString(redirectTo))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(page))
+}
+
+func (h *Handler) renderRecoveryCodesPage(w http.ResponseWriter, codes []string, next string) {
+	var list strings.Builder
+	for _, code := range codes {
+		list.WriteString("<li>" + html.EscapeString(code) + "</li>")
+	}
+
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Recovery Codes - Fazt</title>
+  <style>%s</style>
+</head>
+<body>
+  <div class="container">
+    <h1>Recovery Codes</h1>
+    <p class="subtitle">Two-factor authentication is on. Save these codes somewhere safe: each one signs you in once if you lose your authenticator app. They won't be shown again.</p>
+    <ul class="codes">%s</ul>
+    <a href="%s" class="button">Continue</a>
+    <p class="footer">Powered by Fazt</p>
+  </div>
+</body>
+</html>`, secondFactorStyle, list.String(), html.EscapeString(next))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(page))
+}
+
+const secondFactorStyle = `
+    * { box-sizing: border-box; margin: 0; padding: 0; }
+    body {
+      font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
+      background: #0a0a0a;
+      color: #fff;
+      min-height: 100vh;
+      display: flex;
+      align-items: center;
+      justify-content: center;
+      padding: 20px;
+    }
+    .container {
+      width: 100%;
+      max-width: 400px;
+      background: #141414;
+      border: 1px solid #333;
+      border-radius: 12px;
+      padding: 40px;
+    }
+    h1 {
+      font-size: 24px;
+      font-weight: 600;
+      margin-bottom: 8px;
+      text-align: center;
+    }
+    .subtitle {
+      color: #888;
+      text-align: center;
+      margin-bottom: 24px;
+    }
+    .error {
+      background: #3b1c1c;
+      border: 1px solid #5c2626;
+      color: #f87171;
+      padding: 12px;
+      border-radius: 8px;
+      margin-bottom: 24px;
+      font-size: 14px;
+    }
+    .secret, .codes {
+      font-family: ui-monospace, SFMono-Regular, Menlo, monospace;
+      background: #1a1a1a;
+      border: 1px solid #333;
+      border-radius: 8px;
+      padding: 12px 16px;
+      text-align: center;
+      word-break: break-all;
+    }
+    .codes {
+      list-style: none;
+      columns: 2;
+      margin-bottom: 24px;
+    }
+    .hint {
+      color: #666;
+      font-size: 12px;
+      text-align: center;
+      margin: 8px 0 24px;
+    }
+    .hint a { color: #888; }
+    form {
+      display: flex;
+      flex-direction: column;
+      gap: 16px;
+    }
+    label {
+      display: block;
+      font-size: 14px;
+      color: #888;
+      margin-bottom: 6px;
+    }
+    input {
+      width: 100%;
+      padding: 12px 16px;
+      background: #1a1a1a;
+      border: 1px solid #333;
+      border-radius: 8px;
+      color: #fff;
+      font-size: 16px;
+    }
+    input:focus {
+      outline: none;
+      border-color: #666;
+    }
+    button, .button {
+      display: block;
+      width: 100%;
+      padding: 14px 20px;
+      background: #fff;
+      color: #000;
+      border: none;
+      border-radius: 8px;
+      font-weight: 600;
+      font-size: 16px;
+      text-align: center;
+      text-decoration: none;
+      cursor: pointer;
+    }
+    button:hover, .button:hover {
+      background: #e0e0e0;
+    }
+    .footer {
+      margin-top: 24px;
+      text-align: center;
+      color: #666;
+      font-size: 12px;
+    }
+`
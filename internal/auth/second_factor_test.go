@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var challengeField = regexp.MustCompile(`name="token" value="([^"]+)"`)
+
+// challengeToken returns the login challenge embedded in a second-factor page
+func challengeToken(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	if len(rr.Result().Cookies()) != 0 {
+		t.Fatalf("Expected no session before the second factor, got %v", rr.Result().Cookies())
+	}
+	m := challengeField.FindStringSubmatch(rr.Body.String())
+	if rr.Code != http.StatusOK || m == nil {
+		t.Fatalf("Expected a second-factor page, got %d %s", rr.Code, rr.Body.String())
+	}
+	return m[1]
+}
+
+// oauthLogin runs a GitHub login on the root domain and returns the callback response
+func oauthLogin(t *testing.T, handler *Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://test.com/auth/login/github?redirect=/notes", nil))
+	u, _ := url.Parse(rr.Header().Get("Location"))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "http://test.com/auth/callback/github?code=good-code&state="+u.Query().Get("state"), nil))
+	return rr
+}
+
+func postSecondFactor(handler *Handler, token, code string) *httptest.ResponseRecorder {
+	form := url.Values{"token": {token}, "code": {code}, "redirect": {"/notes"}}
+	req := httptest.NewRequest("POST", "http://test.com/auth/2fa", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func sessionCookie(rr *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == SessionCookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestOAuthCallbackSecondFactor(t *testing.T) {
+	fakeGitHub(t, `{"id":9,"login":"ada","email":"ada@example.com"}`)
+
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	handler := NewHandler(service)
+	service.SetProviderConfig("github", "app-client", "app-secret")
+	service.EnableProvider("github")
+	service.SetRequire2FA(true)
+
+	// Required but not set up: the callback enrolls instead of signing in
+	token := challengeToken(t, oauthLogin(t, handler))
+	challenge, err := service.GetLoginChallenge(token)
+	if err != nil {
+		t.Fatalf("Expected a pending challenge: %v", err)
+	}
+	secret := service.pendingTOTPSecret(challenge.UserID)
+	if secret == "" {
+		t.Fatal("Expected the callback to start enrollment")
+	}
+
+	rr := postSecondFactor(handler, token, "000000")
+	if !strings.Contains(rr.Body.String(), "Invalid authentication code") || sessionCookie(rr) != nil {
+		t.Fatalf("Expected a wrong code refused, got %d %s", rr.Code, rr.Body.String())
+	}
+	if service.pendingTOTPSecret(challenge.UserID) != secret {
+		t.Error("Expected a redisplay to keep the pending key")
+	}
+
+	code, _ := TOTPCode(secret, time.Now())
+	rr = postSecondFactor(handler, token, code)
+	if sessionCookie(rr) == nil || !strings.Contains(rr.Body.String(), "Recovery Codes") ||
+		!strings.Contains(rr.Body.String(), `href="/notes"`) {
+		t.Fatalf("Expected a session and recovery codes, got %d %s", rr.Code, rr.Body.String())
+	}
+	if !service.TwoFactorEnabled(challenge.UserID) {
+		t.Error("Expected 2FA enabled after enrollment")
+	}
+
+	// Enrolled: the next login asks for a code, even once 2FA isn't required
+	service.SetRequire2FA(false)
+	token = challengeToken(t, oauthLogin(t, handler))
+	next, _ := TOTPCode(secret, time.Now().Add(totpPeriod*time.Second))
+	rr = postSecondFactor(handler, token, next)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/notes" || sessionCookie(rr) == nil {
+		t.Fatalf("Expected a session and a redirect to /notes, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// Challenges are single-use
+	rr = postSecondFactor(handler, token, next)
+	if sessionCookie(rr) != nil || !strings.Contains(rr.Body.String(), "expired") {
+		t.Errorf("Expected a used challenge refused, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestRedeemInviteSecondFactor(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	handler := NewHandler(service)
+	service.SetRequire2FA(true)
+
+	// JSON signups get a challenge token for the enrollment API
+	invite, _ := service.CreateInvite("user", "owner", 1, nil)
+	req := httptest.NewRequest("POST", "http://test.com/auth/invite/"+invite.Code,
+		strings.NewReader(`{"email":"new@test.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || sessionCookie(rr) != nil {
+		t.Fatalf("Expected an account without a session, got %d %v", rr.Code, rr.Result().Cookies())
+	}
+	var resp struct {
+		Data struct {
+			Required bool   `json:"two_factor_required"`
+			Enrolled bool   `json:"two_factor_enrolled"`
+			Token    string `json:"two_factor_token"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if !resp.Data.Required || resp.Data.Enrolled {
+		t.Errorf("Expected an unenrolled two-factor challenge, got %+v", resp.Data)
+	}
+	if _, err := service.GetLoginChallenge(resp.Data.Token); err != nil {
+		t.Errorf("Expected a valid challenge token: %v", err)
+	}
+
+	// Form signups enroll on the page
+	invite, _ = service.CreateInvite("user", "owner", 1, nil)
+	form := url.Values{"email": {"other@test.com"}, "password": {"password123"}}
+	req = httptest.NewRequest("POST", "http://test.com/auth/invite/"+invite.Code, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	token := challengeToken(t, rr)
+	if !strings.Contains(rr.Body.String(), "Set Up Two-Factor Authentication") {
+		t.Errorf("Expected the enrollment page, got %s", rr.Body.String())
+	}
+
+	challenge, _ := service.GetLoginChallenge(token)
+	code, _ := TOTPCode(service.pendingTOTPSecret(challenge.UserID), time.Now())
+	if rr = postSecondFactor(handler, token, code); sessionCookie(rr) == nil {
+		t.Errorf("Expected a session after enrolling, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -8,20 +8,22 @@ import (
 	"encoding/hex"
 	"errors"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // Common errors
 var (
-	ErrUserNotFound     = errors.New("user not found")
-	ErrUserExists       = errors.New("user already exists")
-	ErrInvalidSession   = errors.New("invalid session")
-	ErrSessionExpired   = errors.New("session expired")
-	ErrProviderDisabled = errors.New("provider not enabled")
-	ErrInvalidState     = errors.New("invalid or expired state")
-	ErrInvalidInvite    = errors.New("invalid or expired invite code")
-	ErrInviteUsed       = errors.New("invite code already used")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserExists        = errors.New("user already exists")
+	ErrInvalidSession    = errors.New("invalid session")
+	ErrSessionExpired    = errors.New("session expired")
+	ErrSessionNotFound   = errors.New("session not found")
+	ErrProviderDisabled  = errors.New("provider not enabled")
+	ErrInvalidState      = errors.New("invalid or expired state")
+	ErrInvalidInvite     = errors.New("invalid or expired invite code")
+	ErrInviteUsed        = errors.New("invite code already used")
+	ErrAppUserNotFound   = errors.New("app user not found")
+	ErrCannotImpersonate = errors.New("only regular users can be impersonated")
 )
 
 // Service is the main authentication service
@@ -29,6 +31,8 @@ type Service struct {
 	db     *sql.DB
 	domain string // Base domain for cookies (e.g., "zyt.app")
 	secure bool   // Whether to use secure cookies (HTTPS)
+
+	require2FA bool // Password logins must complete a TOTP second factor
 }
 
 // NewService creates a new auth service
@@ -120,6 +124,30 @@ func (s *Service) CleanupExpired() error {
 		return err
 	}
 
+	// Clean expired two-factor login challenges
+	_, err = s.db.Exec(`DELETE FROM auth_login_challenges WHERE expires_at < ?`, now)
+	if err != nil {
+		return err
+	}
+
+	// Clean expired passkey challenges
+	_, err = s.db.Exec(`DELETE FROM auth_webauthn_challenges WHERE expires_at < ?`, now)
+	if err != nil {
+		return err
+	}
+
+	// Clean expired app end-user sessions
+	_, err = s.db.Exec(`DELETE FROM app_sessions WHERE expires_at < ?`, now)
+	if err != nil {
+		return err
+	}
+
+	// Clean expired session handoffs
+	_, err = s.db.Exec(`DELETE FROM auth_handoffs WHERE expires_at < ?`, now)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -139,29 +167,18 @@ func (s *Service) StartCleanupRoutine(stop <-chan struct{}) {
 	}()
 }
 
-// SessionCookie creates a session cookie for the given token
+// SessionCookie creates a session cookie for the given token. The cookie is
+// host-only: the dashboard's session is never sent to hosted apps, and each
+// app host gets its own session through a handoff (see handoff.go).
 func (s *Service) SessionCookie(token string, maxAge int) *http.Cookie {
-	domain := s.domain
-	// Add leading dot for subdomain cookie sharing
-	if !strings.HasPrefix(domain, ".") && !strings.Contains(domain, "localhost") {
-		domain = "." + domain
-	}
-
-	sameSite := http.SameSiteLaxMode
-	// For localhost/dev, we can't use Secure or cross-domain cookies
-	if strings.Contains(s.domain, "localhost") || strings.Contains(s.domain, "127.0.0.1") {
-		domain = "" // Don't set domain for localhost
-	}
-
 	return &http.Cookie{
-		Name:     "fazt_session",
+		Name:     SessionCookieName,
 		Value:    token,
-		Domain:   domain,
 		Path:     "/",
 		MaxAge:   maxAge,
 		HttpOnly: true,
 		Secure:   s.secure,
-		SameSite: sameSite,
+		SameSite: http.SameSiteLaxMode,
 	}
 }
 
@@ -174,12 +191,33 @@ func (s *Service) ClearSessionCookie() *http.Cookie {
 
 // GetSessionFromRequest extracts and validates the session from a request
 func (s *Service) GetSessionFromRequest(r *http.Request) (*User, error) {
-	cookie, err := r.Cookie("fazt_session")
-	if err != nil {
-		return nil, ErrInvalidSession
-	}
+	_, user, err := s.sessionFromRequest(r)
+	return user, err
+}
 
-	return s.ValidateSession(cookie.Value)
+// SessionToken returns the token of the request's valid session, or ""
+func (s *Service) SessionToken(r *http.Request) string {
+	token, _, _ := s.sessionFromRequest(r)
+	return token
+}
+
+// sessionFromRequest returns the first session cookie that is valid on the
+// request's host. Browsers send every cookie matching the host, so a sibling
+// subdomain can plant one scoped to the parent domain ahead of the real one;
+// trying each keeps that from shadowing the user's session.
+func (s *Service) sessionFromRequest(r *http.Request) (string, *User, error) {
+	host := requestHost(r)
+	err := ErrInvalidSession
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != SessionCookieName || cookie.Value == "" {
+			continue
+		}
+		var user *User
+		if user, err = s.validateSession(cookie.Value, host); err == nil {
+			return cookie.Value, user, nil
+		}
+	}
+	return "", nil, err
 }
 
 // GetSessionFromRequestInterface is a wrapper that returns interface{} for runtime compatibility
@@ -189,14 +227,18 @@ func (s *Service) GetSessionFromRequestInterface(r *http.Request) (interface{},
 		return nil, err
 	}
 	// Convert to map for runtime package (avoids import cycle)
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"id":       user.ID,
 		"email":    user.Email,
 		"name":     user.Name,
 		"picture":  user.Picture,
 		"role":     user.Role,
 		"provider": user.Provider,
-	}, nil
+	}
+	if user.ImpersonatedBy != "" {
+		m["impersonated_by"] = user.ImpersonatedBy
+	}
+	return m, nil
 }
 
 // AuthProviderAdapter adapts the Service to the runtime.AuthProvider interface
@@ -214,6 +256,27 @@ func (a *AuthProviderAdapter) GetSessionFromRequest(r *http.Request) (interface{
 	return a.service.GetSessionFromRequestInterface(r)
 }
 
+// GetAppUserFromRequest implements runtime.AppUserProvider
+func (a *AuthProviderAdapter) GetAppUserFromRequest(r *http.Request, appID string) (interface{}, error) {
+	user, err := a.service.GetAppUserFromRequest(r, appID)
+	if err != nil {
+		return nil, err
+	}
+	// Convert to map for runtime package (avoids import cycle)
+	return map[string]interface{}{
+		"id":       user.ID,
+		"email":    user.Email,
+		"name":     user.Name,
+		"picture":  user.Picture,
+		"provider": user.Provider,
+	}, nil
+}
+
+// SessionToken implements runtime.SessionTokenProvider
+func (a *AuthProviderAdapter) SessionToken(r *http.Request) string {
+	return a.service.SessionToken(r)
+}
+
 // Domain implements runtime.AuthProvider
 func (a *AuthProviderAdapter) Domain() string {
 	return a.service.Domain()
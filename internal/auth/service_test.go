@@ -2,11 +2,14 @@ package auth
 
 import (
 	"database/sql"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 func setupTestDB(t *testing.T) *sql.DB {
@@ -51,7 +54,11 @@ func setupTestDB(t *testing.T) *sql.DB {
 			user_id TEXT NOT NULL,
 			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
 			expires_at INTEGER NOT NULL,
-			last_seen INTEGER
+			last_seen INTEGER,
+			impersonator_id TEXT,
+			user_agent TEXT,
+			ip TEXT,
+			host TEXT
 		);
 		CREATE TABLE auth_states (
 			state TEXT PRIMARY KEY,
@@ -72,6 +79,82 @@ func setupTestDB(t *testing.T) *sql.DB {
 			used_by TEXT,
 			used_at INTEGER
 		);
+		CREATE TABLE auth_totp (
+			user_id TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 0,
+			last_step INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			enabled_at INTEGER
+		);
+		CREATE TABLE auth_recovery_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			code_hash TEXT NOT NULL,
+			used_at INTEGER
+		);
+		CREATE TABLE auth_passkeys (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			public_key BLOB NOT NULL,
+			algorithm INTEGER NOT NULL,
+			sign_count INTEGER NOT NULL DEFAULT 0,
+			aaguid TEXT,
+			attestation_format TEXT,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			last_used_at INTEGER
+		);
+		CREATE TABLE auth_webauthn_challenges (
+			challenge_hash TEXT PRIMARY KEY,
+			ceremony TEXT NOT NULL,
+			user_id TEXT,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE auth_login_challenges (
+			token_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			remember_me INTEGER NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE auth_handoffs (
+			code_hash TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			host TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);
+		CREATE TABLE app_auth_providers (
+			app_id TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			enabled INTEGER DEFAULT 1,
+			client_id TEXT NOT NULL,
+			client_secret TEXT,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			PRIMARY KEY (app_id, provider)
+		);
+		CREATE TABLE app_users (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			provider_id TEXT NOT NULL,
+			email TEXT,
+			name TEXT,
+			picture TEXT,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			last_login INTEGER,
+			UNIQUE (app_id, provider, provider_id)
+		);
+		CREATE TABLE app_sessions (
+			token_hash TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+			expires_at INTEGER NOT NULL
+		);
 	`)
 	if err != nil {
 		t.Fatalf("Failed to create tables: %v", err)
@@ -202,6 +285,60 @@ func TestServiceDeleteSession(t *testing.T) {
 	}
 }
 
+func TestListAndRevokeSessions(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+
+	user, _ := service.CreateUser("test@test.com", "Test", "", "google", nil)
+	other, _ := service.CreateUser("other@test.com", "Other", "", "google", nil)
+
+	token, _ := service.CreateSession(user.ID)
+	service.CreateSession(user.ID)
+	otherToken, _ := service.CreateSession(other.ID)
+
+	realip.SetTrustedProxies([]string{"10.0.0.0/8"})
+	t.Cleanup(func() { realip.SetTrustedProxies(nil) })
+	req := httptest.NewRequest("POST", "/auth/login", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("User-Agent", "Firefox/130.0")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	if err := service.TagSession(token, req); err != nil {
+		t.Fatalf("TagSession failed: %v", err)
+	}
+
+	sessions, err := service.ListUserSessions(user.ID)
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListUserSessions = %d, %v; want 2", len(sessions), err)
+	}
+	var tagged *SessionInfo
+	for i := range sessions {
+		if sessions[i].ID == SessionID(token) {
+			tagged = &sessions[i]
+		}
+	}
+	if tagged == nil || tagged.UserAgent != "Firefox/130.0" || tagged.IP != "203.0.113.7" || tagged.Email != "test@test.com" {
+		t.Fatalf("Expected tagged session, got %+v", tagged)
+	}
+
+	all, _ := service.ListAllSessions()
+	if len(all) != 3 {
+		t.Errorf("ListAllSessions = %d, want 3", len(all))
+	}
+
+	if err := service.RevokeSession(SessionID(otherToken), user.ID); err != ErrSessionNotFound {
+		t.Errorf("Expected another user's session to be off limits, got %v", err)
+	}
+	if err := service.RevokeSession(SessionID(token), user.ID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if _, err := service.ValidateSession(token); err != ErrInvalidSession {
+		t.Errorf("Expected revoked session to be invalid, got %v", err)
+	}
+	if err := service.RevokeSession(SessionID(otherToken), ""); err != nil {
+		t.Errorf("Expected unscoped revoke to succeed, got %v", err)
+	}
+}
+
 func TestCreateInvite(t *testing.T) {
 	db := setupTestDB(t)
 	service := NewService(db, "test.com", false)
@@ -313,6 +450,9 @@ func TestSessionCookie(t *testing.T) {
 	if !cookie.Secure {
 		t.Error("Cookie should be Secure in production")
 	}
+	if cookie.Domain != "" {
+		t.Errorf("Cookie should be host-only, got Domain=%q", cookie.Domain)
+	}
 }
 
 func TestInviteExpiry(t *testing.T) {
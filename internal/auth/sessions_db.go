@@ -2,7 +2,14 @@ package auth
 
 import (
 	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
 	"time"
+
+	"github.com/fazt-sh/fazt/internal/notify"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/security"
 )
 
 const (
@@ -17,6 +24,24 @@ type DBSession struct {
 	CreatedAt int64
 	ExpiresAt int64
 	LastSeen  int64
+
+	ImpersonatorID sql.NullString // Set for sessions minted by an admin via impersonation
+	Host           sql.NullString // Host the session is bound to; NULL for sessions not issued to a browser
+}
+
+// SessionInfo is a session as shown to users and admins. ID is the token
+// hash, which identifies the session without being usable as a credential.
+type SessionInfo struct {
+	ID             string `json:"id"`
+	UserID         string `json:"user_id"`
+	Email          string `json:"email,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
+	ExpiresAt      int64  `json:"expires_at"`
+	LastSeen       int64  `json:"last_seen"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	IP             string `json:"ip,omitempty"`
+	ImpersonatorID string `json:"impersonator_id,omitempty"`
+	Current        bool   `json:"current,omitempty"`
 }
 
 // CreateSession creates a new session for a user and returns the token
@@ -46,8 +71,88 @@ func (s *Service) CreateSession(userID string) (string, error) {
 	return token, nil
 }
 
+// TagSession records the user agent and client IP of the request that
+// created a session, and binds the session to the request's host: its cookie
+// is host-only, and the token is refused on any other host.
+func (s *Service) TagSession(token string, r *http.Request) error {
+	_, err := s.db.Exec(`UPDATE auth_sessions SET user_agent = ?, ip = ?, host = ? WHERE token_hash = ?`,
+		r.UserAgent(), realip.FromRequest(r), requestHost(r), hashToken(token))
+	if err != nil {
+		return err
+	}
+	s.checkLogin(token, r)
+	return nil
+}
+
+// checkLogin records a security event when a user signs in from a user
+// agent or client IP they haven't used before, and notifies the admin of
+// a new IP
+func (s *Service) checkLogin(token string, r *http.Request) {
+	var userID, email string
+	err := s.db.QueryRow(`
+		SELECT s.user_id, COALESCE(u.email, '') FROM auth_sessions s
+		LEFT JOIN auth_users u ON u.id = s.user_id WHERE s.token_hash = ?
+	`, hashToken(token)).Scan(&userID, &email)
+	if err != nil {
+		return
+	}
+	ip := realip.FromRequest(r)
+	now := time.Now()
+
+	isNew, err := security.NewDevice(s.db, userID, r.UserAgent(), now)
+	if err != nil {
+		log.Printf("Failed to check login device: %v", err)
+	} else if isNew {
+		security.Record(s.db, security.Event{
+			Type:    security.EventNewDevice,
+			Actor:   email,
+			IP:      ip,
+			Subject: userID,
+			Details: map[string]interface{}{"user_agent": r.UserAgent(), "host": requestHost(r)},
+		})
+	}
+
+	isNew, err = security.NewIP(s.db, userID, ip, now)
+	if err != nil {
+		log.Printf("Failed to check login IP: %v", err)
+	} else if isNew {
+		security.Record(s.db, security.Event{
+			Type:    security.EventNewIP,
+			Actor:   email,
+			IP:      ip,
+			Subject: userID,
+			Details: map[string]interface{}{"user_agent": r.UserAgent(), "host": requestHost(r)},
+		})
+		message := fmt.Sprintf("%s signed in from %s (%s)", orUserID(email, userID), ip, r.UserAgent())
+		go func() {
+			if err := notify.SendOnce(notify.EventLoginNewIP, userID+" "+ip, "Login from a new IP", message); err != nil {
+				log.Printf("Failed to send login notification: %v", err)
+			}
+		}()
+	}
+}
+
+// orUserID names a user by email, or by ID when they have none
+func orUserID(email, userID string) string {
+	if email != "" {
+		return email
+	}
+	return userID
+}
+
+// SessionID returns the ID under which a session token is listed
+func SessionID(token string) string {
+	return hashToken(token)
+}
+
 // ValidateSession validates a session token and returns the associated user
 func (s *Service) ValidateSession(token string) (*User, error) {
+	return s.validateSession(token, "")
+}
+
+// validateSession validates a session token presented on host. Sessions bound
+// to another host are refused; an empty host skips the check.
+func (s *Service) validateSession(token, host string) (*User, error) {
 	if token == "" {
 		return nil, ErrInvalidSession
 	}
@@ -57,11 +162,11 @@ func (s *Service) ValidateSession(token string) (*User, error) {
 
 	var session DBSession
 	err := s.db.QueryRow(`
-		SELECT token_hash, user_id, created_at, expires_at, last_seen
+		SELECT token_hash, user_id, created_at, expires_at, last_seen, impersonator_id, host
 		FROM auth_sessions WHERE token_hash = ?
 	`, tokenHash).Scan(
 		&session.TokenHash, &session.UserID,
-		&session.CreatedAt, &session.ExpiresAt, &session.LastSeen,
+		&session.CreatedAt, &session.ExpiresAt, &session.LastSeen, &session.ImpersonatorID, &session.Host,
 	)
 
 	if err == sql.ErrNoRows {
@@ -71,6 +176,10 @@ func (s *Service) ValidateSession(token string) (*User, error) {
 		return nil, err
 	}
 
+	if host != "" && session.Host.String != "" && session.Host.String != host {
+		return nil, ErrInvalidSession
+	}
+
 	// Check if expired
 	if now > session.ExpiresAt {
 		// Clean up expired session
@@ -84,7 +193,12 @@ func (s *Service) ValidateSession(token string) (*User, error) {
 	}
 
 	// Get the user
-	return s.GetUserByID(session.UserID)
+	user, err := s.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	user.ImpersonatedBy = session.ImpersonatorID.String
+	return user, nil
 }
 
 // DeleteSession removes a session by token
@@ -106,34 +220,65 @@ func (s *Service) DeleteUserSessions(userID string) (int64, error) {
 	return result.RowsAffected()
 }
 
-// ListUserSessions returns all active sessions for a user
-func (s *Service) ListUserSessions(userID string) ([]*DBSession, error) {
-	now := time.Now().Unix()
-	rows, err := s.db.Query(`
-		SELECT token_hash, user_id, created_at, expires_at, last_seen
-		FROM auth_sessions
-		WHERE user_id = ? AND expires_at > ?
-		ORDER BY last_seen DESC
-	`, userID, now)
+const sessionInfoColumns = `s.token_hash, s.user_id, COALESCE(u.email, ''), s.created_at, s.expires_at,
+	COALESCE(s.last_seen, s.created_at), COALESCE(s.user_agent, ''), COALESCE(s.ip, ''), COALESCE(s.impersonator_id, '')`
+
+// ListUserSessions returns all active sessions for a user, most recently used first
+func (s *Service) ListUserSessions(userID string) ([]SessionInfo, error) {
+	return s.querySessions(`
+		SELECT `+sessionInfoColumns+`
+		FROM auth_sessions s LEFT JOIN auth_users u ON u.id = s.user_id
+		WHERE s.user_id = ? AND s.expires_at > ?
+		ORDER BY s.last_seen DESC
+	`, userID, time.Now().Unix())
+}
+
+// ListAllSessions returns every active session, most recently used first
+func (s *Service) ListAllSessions() ([]SessionInfo, error) {
+	return s.querySessions(`
+		SELECT `+sessionInfoColumns+`
+		FROM auth_sessions s LEFT JOIN auth_users u ON u.id = s.user_id
+		WHERE s.expires_at > ?
+		ORDER BY s.last_seen DESC
+	`, time.Now().Unix())
+}
+
+func (s *Service) querySessions(query string, args ...interface{}) ([]SessionInfo, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var sessions []*DBSession
+	sessions := []SessionInfo{}
 	for rows.Next() {
-		var session DBSession
-		err := rows.Scan(
-			&session.TokenHash, &session.UserID,
-			&session.CreatedAt, &session.ExpiresAt, &session.LastSeen,
-		)
-		if err != nil {
-			continue
+		var info SessionInfo
+		if err := rows.Scan(&info.ID, &info.UserID, &info.Email, &info.CreatedAt, &info.ExpiresAt,
+			&info.LastSeen, &info.UserAgent, &info.IP, &info.ImpersonatorID); err != nil {
+			return nil, err
 		}
-		sessions = append(sessions, &session)
+		sessions = append(sessions, info)
 	}
+	return sessions, rows.Err()
+}
 
-	return sessions, nil
+// RevokeSession deletes a session by ID. When userID is set, only that
+// user's sessions can be revoked.
+func (s *Service) RevokeSession(id, userID string) error {
+	query := `DELETE FROM auth_sessions WHERE token_hash = ?`
+	args := []interface{}{id}
+	if userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
 }
 
 // CountActiveSessions returns the number of active sessions
@@ -144,7 +289,8 @@ func (s *Service) CountActiveSessions() (int, error) {
 	return count, err
 }
 
-// RefreshSession extends the session expiry
+// RefreshSession extends the session expiry. Impersonation sessions are
+// never extended.
 func (s *Service) RefreshSession(token string) error {
 	tokenHash := hashToken(token)
 	now := time.Now().Unix()
@@ -153,7 +299,7 @@ func (s *Service) RefreshSession(token string) error {
 	_, err := s.db.Exec(`
 		UPDATE auth_sessions
 		SET expires_at = ?, last_seen = ?
-		WHERE token_hash = ?
+		WHERE token_hash = ? AND impersonator_id IS NULL
 	`, newExpiry, now, tokenHash)
 
 	return err
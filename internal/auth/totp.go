@@ -0,0 +1,421 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPIssuer labels the account in authenticator apps
+	TOTPIssuer = "fazt"
+
+	totpPeriod     = 30 // seconds
+	totpDigits     = 6
+	totpSecretSize = 20 // bytes (160 bits, RFC 4226 recommendation)
+	totpSkew       = 1  // accept one step either side for clock drift
+
+	// RecoveryCodeCount is how many recovery codes are issued on enrollment
+	RecoveryCodeCount = 10
+
+	// LoginChallengeTTL is how long a password-verified login waits for its second factor
+	LoginChallengeTTL = 5 * time.Minute
+
+	// maxChallengeAttempts is how many wrong codes a challenge tolerates
+	maxChallengeAttempts = 5
+)
+
+// Two-factor errors
+var (
+	ErrTOTPNotEnrolled     = errors.New("two-factor authentication is not set up")
+	ErrTOTPAlreadyEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrInvalidTOTPCode     = errors.New("invalid authentication code")
+	ErrInvalidChallenge    = errors.New("invalid or expired login challenge")
+	ErrTwoFactorRequired   = errors.New("two-factor authentication is required on this server")
+	ErrTooManyCodeAttempts = errors.New("too many invalid codes, please log in again")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32 TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPCode returns the RFC 6238 code for secret at time t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+	return hotp(key, uint64(t.Unix()/totpPeriod)), nil
+}
+
+// hotp computes an RFC 4226 HMAC-SHA1 one-time password
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// validateTOTP checks code against secret around time t and returns the matched step
+func validateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	step := t.Unix() / totpPeriod
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(step+i))), []byte(code)) == 1 {
+			return step + i, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth:// URI that authenticator apps scan as a QR code
+func ProvisioningURI(secret, account string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + account)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", TOTPIssuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// SetRequire2FA sets whether every password, OAuth and invite login must use
+// a second factor
+func (s *Service) SetRequire2FA(require bool) {
+	s.require2FA = require
+}
+
+// Require2FA reports whether the server requires two-factor authentication
+func (s *Service) Require2FA() bool {
+	return s.require2FA
+}
+
+// TwoFactorEnabled reports whether the user has confirmed TOTP enrollment
+func (s *Service) TwoFactorEnabled(userID string) bool {
+	var enabled int
+	s.db.QueryRow(`SELECT enabled FROM auth_totp WHERE user_id = ?`, userID).Scan(&enabled)
+	return enabled == 1
+}
+
+// NeedsSecondFactor reports whether a login for the user must pass a
+// second factor before it gets a session
+func (s *Service) NeedsSecondFactor(userID string) bool {
+	return s.require2FA || s.TwoFactorEnabled(userID)
+}
+
+// RecoveryCodesRemaining returns the number of unused recovery codes
+func (s *Service) RecoveryCodesRemaining(userID string) int {
+	var n int
+	s.db.QueryRow(`SELECT COUNT(*) FROM auth_recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&n)
+	return n
+}
+
+// EnrollTOTP starts (or restarts) enrollment and returns the secret and
+// provisioning URI. 2FA stays off until EnableTOTP confirms a code.
+func (s *Service) EnrollTOTP(user *User) (secret, uri string, err error) {
+	if s.TwoFactorEnabled(user.ID) {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO auth_totp (user_id, secret, enabled, last_step, created_at)
+		VALUES (?, ?, 0, 0, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+	`, user.ID, secret, time.Now().Unix())
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, ProvisioningURI(secret, user.Email), nil
+}
+
+// EnableTOTP confirms enrollment with a code from the authenticator app and
+// returns a fresh set of recovery codes (shown once, stored hashed)
+func (s *Service) EnableTOTP(userID, code string) ([]string, error) {
+	var secret string
+	var enabled int
+	err := s.db.QueryRow(`SELECT secret, enabled FROM auth_totp WHERE user_id = ?`, userID).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabled == 1 {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE auth_totp SET enabled = 1, last_step = ?, enabled_at = ? WHERE user_id = ?`,
+		step, time.Now().Unix(), userID); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns off 2FA after verifying a current code or recovery code
+func (s *Service) DisableTOTP(userID, code string) error {
+	if s.require2FA {
+		return ErrTwoFactorRequired
+	}
+	if err := s.VerifySecondFactor(userID, code); err != nil {
+		return err
+	}
+
+	s.db.Exec(`DELETE FROM auth_recovery_codes WHERE user_id = ?`, userID)
+	_, err := s.db.Exec(`DELETE FROM auth_totp WHERE user_id = ?`, userID)
+	return err
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after verifying a current code
+func (s *Service) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	if err := s.VerifySecondFactor(userID, code); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return codes, tx.Commit()
+}
+
+// VerifySecondFactor checks a TOTP code or, failing that, consumes a recovery code.
+// A TOTP code is accepted at most once.
+func (s *Service) VerifySecondFactor(userID, code string) error {
+	var secret string
+	err := s.db.QueryRow(`SELECT secret FROM auth_totp WHERE user_id = ? AND enabled = 1`, userID).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	if step, ok := validateTOTP(secret, code, time.Now()); ok {
+		// Conditional update so two concurrent requests can't both use the code
+		res, err := s.db.Exec(`UPDATE auth_totp SET last_step = ? WHERE user_id = ? AND last_step < ?`, step, userID, step)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return nil
+		}
+		return ErrInvalidTOTPCode
+	}
+
+	res, err := s.db.Exec(`
+		UPDATE auth_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now().Unix(), userID, hashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil
+	}
+	return ErrInvalidTOTPCode
+}
+
+// replaceRecoveryCodes deletes existing codes and stores new ones hashed
+func replaceRecoveryCodes(tx *sql.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec(`DELETE FROM auth_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`INSERT INTO auth_recovery_codes (user_id, code_hash) VALUES (?, ?)`,
+			userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k7m2q-x9fpa" (50 bits)
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// LoginChallenge is a password-verified login waiting for its second factor
+type LoginChallenge struct {
+	UserID     string
+	RememberMe bool
+}
+
+// CreateLoginChallenge records a verified password login and returns the
+// challenge token the client exchanges, with a code, for a session
+func (s *Service) CreateLoginChallenge(userID string, rememberMe bool) (string, error) {
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	remember := 0
+	if rememberMe {
+		remember = 1
+	}
+	now := time.Now().Unix()
+	_, err = s.db.Exec(`
+		INSERT INTO auth_login_challenges (token_hash, user_id, remember_me, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashToken(token), userID, remember, now, now+int64(LoginChallengeTTL.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetLoginChallenge returns a pending challenge without consuming it
+func (s *Service) GetLoginChallenge(token string) (*LoginChallenge, error) {
+	if token == "" {
+		return nil, ErrInvalidChallenge
+	}
+
+	var c LoginChallenge
+	var remember int
+	err := s.db.QueryRow(`
+		SELECT user_id, remember_me FROM auth_login_challenges
+		WHERE token_hash = ? AND expires_at > ?
+	`, hashToken(token), time.Now().Unix()).Scan(&c.UserID, &remember)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	c.RememberMe = remember == 1
+	return &c, nil
+}
+
+// CompleteLoginChallenge verifies the second factor and consumes the challenge.
+// Wrong codes count against the challenge; it is discarded after too many.
+func (s *Service) CompleteLoginChallenge(token, code string) (*LoginChallenge, error) {
+	c, err := s.GetLoginChallenge(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.VerifySecondFactor(c.UserID, code); err != nil {
+		if err != ErrInvalidTOTPCode {
+			return nil, err
+		}
+		return nil, s.failLoginChallenge(token)
+	}
+
+	s.DeleteLoginChallenge(token)
+	return c, nil
+}
+
+// EnableTOTPForChallenge confirms the enrollment of a user who must set up
+// 2FA before their login completes, and consumes the challenge. Wrong codes
+// count against the challenge as in CompleteLoginChallenge.
+func (s *Service) EnableTOTPForChallenge(token, code string) (*LoginChallenge, []string, error) {
+	c, err := s.GetLoginChallenge(token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	codes, err := s.EnableTOTP(c.UserID, code)
+	if err != nil {
+		if err != ErrInvalidTOTPCode {
+			return nil, nil, err
+		}
+		return nil, nil, s.failLoginChallenge(token)
+	}
+
+	s.DeleteLoginChallenge(token)
+	return c, codes, nil
+}
+
+// failLoginChallenge records a wrong code and discards the challenge after
+// too many. Returns the error to report for the attempt.
+func (s *Service) failLoginChallenge(token string) error {
+	tokenHash := hashToken(token)
+	s.db.Exec(`UPDATE auth_login_challenges SET attempts = attempts + 1 WHERE token_hash = ?`, tokenHash)
+	var attempts int
+	s.db.QueryRow(`SELECT attempts FROM auth_login_challenges WHERE token_hash = ?`, tokenHash).Scan(&attempts)
+	if attempts >= maxChallengeAttempts {
+		s.DeleteLoginChallenge(token)
+		return ErrTooManyCodeAttempts
+	}
+	return ErrInvalidTOTPCode
+}
+
+// DeleteLoginChallenge discards a pending challenge
+func (s *Service) DeleteLoginChallenge(token string) {
+	s.db.Exec(`DELETE FROM auth_login_challenges WHERE token_hash = ?`, hashToken(token))
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// RFC 6238 Appendix B SHA-1 vectors, truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(t=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret, _ := GenerateTOTPSecret()
+	now := time.Now()
+
+	prev, _ := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+	if _, ok := validateTOTP(secret, prev, now); !ok {
+		t.Error("Expected previous step to be accepted")
+	}
+
+	old, _ := TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+	if _, ok := validateTOTP(secret, old, now); ok {
+		t.Error("Expected code from 3 steps ago to be rejected")
+	}
+
+	if _, ok := validateTOTP(secret, "12345", now); ok {
+		t.Error("Expected short code to be rejected")
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("ABC", "admin@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/fazt:admin@example.com?") {
+		t.Errorf("Unexpected URI prefix: %s", uri)
+	}
+	if !strings.Contains(uri, "secret=ABC") || !strings.Contains(uri, "issuer=fazt") {
+		t.Errorf("URI missing secret or issuer: %s", uri)
+	}
+}
+
+// enrollTestUser creates a user with 2FA enabled and returns its secret and recovery codes
+func enrollTestUser(t *testing.T, service *Service) (*User, string, []string) {
+	t.Helper()
+
+	user, err := service.GetOrCreateLocalAdmin("admin")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	secret, _, err := service.EnrollTOTP(user)
+	if err != nil {
+		t.Fatalf("EnrollTOTP failed: %v", err)
+	}
+
+	code, _ := TOTPCode(secret, time.Now())
+	codes, err := service.EnableTOTP(user.ID, code)
+	if err != nil {
+		t.Fatalf("EnableTOTP failed: %v", err)
+	}
+	return user, secret, codes
+}
+
+func TestEnableTOTP(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+
+	user, err := service.GetOrCreateLocalAdmin("admin")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if _, err := service.EnableTOTP(user.ID, "123456"); err != ErrTOTPNotEnrolled {
+		t.Errorf("Expected ErrTOTPNotEnrolled, got %v", err)
+	}
+
+	secret, uri, err := service.EnrollTOTP(user)
+	if err != nil {
+		t.Fatalf("EnrollTOTP failed: %v", err)
+	}
+	if !strings.Contains(uri, secret) {
+		t.Errorf("Provisioning URI should contain the secret")
+	}
+	if service.TwoFactorEnabled(user.ID) {
+		t.Error("2FA should not be enabled before confirmation")
+	}
+
+	if _, err := service.EnableTOTP(user.ID, "000000"); err != ErrInvalidTOTPCode {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+
+	code, _ := TOTPCode(secret, time.Now())
+	codes, err := service.EnableTOTP(user.ID, code)
+	if err != nil {
+		t.Fatalf("EnableTOTP failed: %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %d", RecoveryCodeCount, len(codes))
+	}
+	if !service.TwoFactorEnabled(user.ID) || service.RecoveryCodesRemaining(user.ID) != RecoveryCodeCount {
+		t.Error("Expected 2FA enabled with all recovery codes remaining")
+	}
+
+	if _, _, err := service.EnrollTOTP(user); err != ErrTOTPAlreadyEnabled {
+		t.Errorf("Expected ErrTOTPAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestVerifySecondFactor(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	user, secret, codes := enrollTestUser(t, service)
+
+	// The code used to enable can't be replayed
+	code, _ := TOTPCode(secret, time.Now())
+	if err := service.VerifySecondFactor(user.ID, code); err != ErrInvalidTOTPCode {
+		t.Errorf("Expected replayed code to fail, got %v", err)
+	}
+
+	next, _ := TOTPCode(secret, time.Now().Add(totpPeriod*time.Second))
+	if err := service.VerifySecondFactor(user.ID, next); err != nil {
+		t.Errorf("Expected next step code to pass, got %v", err)
+	}
+
+	// Recovery codes work once, with any case or spacing
+	recovery := strings.ToUpper(codes[0])
+	if err := service.VerifySecondFactor(user.ID, " "+recovery+" "); err != nil {
+		t.Errorf("Expected recovery code to pass, got %v", err)
+	}
+	if err := service.VerifySecondFactor(user.ID, codes[0]); err != ErrInvalidTOTPCode {
+		t.Errorf("Expected used recovery code to fail, got %v", err)
+	}
+	if n := service.RecoveryCodesRemaining(user.ID); n != RecoveryCodeCount-1 {
+		t.Errorf("Expected %d recovery codes remaining, got %d", RecoveryCodeCount-1, n)
+	}
+
+	// Regenerating invalidates the old set
+	fresh, err := service.RegenerateRecoveryCodes(user.ID, codes[1])
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes failed: %v", err)
+	}
+	if err := service.VerifySecondFactor(user.ID, codes[2]); err != ErrInvalidTOTPCode {
+		t.Errorf("Expected old recovery code to fail after regeneration, got %v", err)
+	}
+	if err := service.VerifySecondFactor(user.ID, fresh[0]); err != nil {
+		t.Errorf("Expected new recovery code to pass, got %v", err)
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	user, _, codes := enrollTestUser(t, service)
+
+	service.SetRequire2FA(true)
+	if err := service.DisableTOTP(user.ID, codes[0]); err != ErrTwoFactorRequired {
+		t.Errorf("Expected ErrTwoFactorRequired, got %v", err)
+	}
+
+	service.SetRequire2FA(false)
+	if err := service.DisableTOTP(user.ID, "000000"); err != ErrInvalidTOTPCode {
+		t.Errorf("Expected ErrInvalidTOTPCode, got %v", err)
+	}
+	if err := service.DisableTOTP(user.ID, codes[0]); err != nil {
+		t.Fatalf("DisableTOTP failed: %v", err)
+	}
+	if service.TwoFactorEnabled(user.ID) || service.RecoveryCodesRemaining(user.ID) != 0 {
+		t.Error("Expected 2FA and recovery codes to be removed")
+	}
+}
+
+func TestLoginChallenge(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "test.com", false)
+	user, secret, _ := enrollTestUser(t, service)
+
+	token, err := service.CreateLoginChallenge(user.ID, true)
+	if err != nil {
+		t.Fatalf("CreateLoginChallenge failed: %v", err)
+	}
+
+	// Wrong codes count against the challenge until it is discarded
+	for i := 1; i < maxChallengeAttempts; i++ {
+		if _, err := service.CompleteLoginChallenge(token, "000000"); err != ErrInvalidTOTPCode {
+			t.Fatalf("Attempt %d: expected ErrInvalidTOTPCode, got %v", i, err)
+		}
+	}
+	if _, err := service.CompleteLoginChallenge(token, "000000"); err != ErrTooManyCodeAttempts {
+		t.Fatalf("Expected ErrTooManyCodeAttempts, got %v", err)
+	}
+	if _, err := service.GetLoginChallenge(token); err != ErrInvalidChallenge {
+		t.Errorf("Expected challenge to be discarded, got %v", err)
+	}
+
+	token, _ = service.CreateLoginChallenge(user.ID, true)
+	code, _ := TOTPCode(secret, time.Now().Add(totpPeriod*time.Second))
+	challenge, err := service.CompleteLoginChallenge(token, code)
+	if err != nil {
+		t.Fatalf("CompleteLoginChallenge failed: %v", err)
+	}
+	if challenge.UserID != user.ID || !challenge.RememberMe {
+		t.Errorf("Unexpected challenge: %+v", challenge)
+	}
+	if _, err := service.GetLoginChallenge(token); err != ErrInvalidChallenge {
+		t.Errorf("Expected challenge to be consumed, got %v", err)
+	}
+
+	// Expired challenges are rejected and cleaned up
+	token, _ = service.CreateLoginChallenge(user.ID, false)
+	db.Exec(`UPDATE auth_login_challenges SET expires_at = ?`, time.Now().Add(-time.Minute).Unix())
+	if _, err := service.GetLoginChallenge(token); err != ErrInvalidChallenge {
+		t.Errorf("Expected expired challenge to be rejected, got %v", err)
+	}
+	service.CleanupExpired()
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM auth_login_challenges`).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected expired challenges to be cleaned up, got %d", count)
+	}
+}
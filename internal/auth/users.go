@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/appid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -21,6 +22,10 @@ type User struct {
 	InvitedBy  *string `json:"invited_by,omitempty"`
 	CreatedAt  int64   `json:"created_at"`
 	LastLogin  *int64  `json:"last_login,omitempty"`
+
+	// ImpersonatedBy is set when the user was loaded from an impersonation
+	// session; UIs show a banner so the admin knows they are acting as the user
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
 }
 
 // IsOwner returns true if the user has owner role
@@ -97,8 +102,8 @@ func (s *Service) CreatePasswordUser(email, name, password, invitedBy string) (*
 	}
 
 	_, err = s.db.Exec(`
-		INSERT INTO auth_users (id, email, name, provider, password_hash, role, invited_by, created_at, last_login)
-		VALUES (?, ?, ?, 'password', ?, ?, ?, ?, ?)
+		INSERT INTO auth_users (id, email, name, picture, provider, password_hash, role, invited_by, created_at, last_login)
+		VALUES (?, ?, ?, '', 'password', ?, ?, ?, ?, ?)
 	`, id, email, name, string(hash), role, invitedByPtr, now, now)
 
 	if err != nil {
@@ -250,8 +255,9 @@ func (s *Service) UpdateUserProfile(userID, name, picture string) error {
 
 // DeleteUser removes a user from the database
 func (s *Service) DeleteUser(userID string) error {
-	// First delete all sessions for this user
+	// First delete all sessions and passkeys for this user
 	s.db.Exec(`DELETE FROM auth_sessions WHERE user_id = ?`, userID)
+	s.db.Exec(`DELETE FROM auth_passkeys WHERE user_id = ?`, userID)
 
 	result, err := s.db.Exec(`DELETE FROM auth_users WHERE id = ?`, userID)
 	if err != nil {
@@ -320,6 +326,74 @@ func (s *Service) ListUsersPaginated(offset, limit int) ([]*User, int, error) {
 	return users, total, nil
 }
 
+// UsersListSpec is how GET /api/users pages, sorts and projects users
+var UsersListSpec = api.ListSpec{
+	Sorts: map[string]string{
+		"last_login": "COALESCE(last_login, 0)",
+		"created_at": "created_at",
+		"email":      "email",
+		"id":         "id",
+	},
+	DefaultSort:  "-last_login",
+	ID:           "id",
+	Fields:       []string{"id", "email", "name", "picture", "provider", "role", "invited_by", "created_at", "last_login"},
+	DefaultLimit: 20,
+	MaxLimit:     100,
+}
+
+// ListUsersPage returns the page of users q asks for
+func (s *Service) ListUsersPage(q *api.ListQuery) ([]*User, error) {
+	where, args := q.Where()
+	if where != "" {
+		where = "WHERE " + where
+	}
+	rows, err := s.db.Query(`
+		SELECT id, email, name, picture, provider, provider_id, role, invited_by, created_at, last_login, `+q.SortExpr()+`
+		FROM auth_users `+where+`
+		ORDER BY `+q.OrderBy()+`
+		LIMIT ? OFFSET ?
+	`, append(args, q.LimitArgs()...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		var user User
+		var providerID sql.NullString
+		var invitedBy sql.NullString
+		var lastLogin sql.NullInt64
+		var sortKey interface{}
+
+		err := rows.Scan(
+			&user.ID, &user.Email, &user.Name, &user.Picture,
+			&user.Provider, &providerID, &user.Role, &invitedBy,
+			&user.CreatedAt, &lastLogin, &sortKey,
+		)
+		if err != nil {
+			continue
+		}
+		if !q.Next(sortKey, user.ID) {
+			break
+		}
+
+		if providerID.Valid {
+			user.ProviderID = &providerID.String
+		}
+		if invitedBy.Valid {
+			user.InvitedBy = &invitedBy.String
+		}
+		if lastLogin.Valid {
+			user.LastLogin = &lastLogin.Int64
+		}
+
+		users = append(users, &user)
+	}
+
+	return users, rows.Err()
+}
+
 // VerifyPassword checks if the provided password matches the user's stored hash
 func (s *Service) VerifyPassword(userID, password string) (bool, error) {
 	var hash sql.NullString
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// cborEncode is a test-only encoder for the subset cborDecode reads
+func cborEncode(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		default:
+			b := []byte{major<<5 | 26, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(b[1:], uint32(n))
+			return b
+		}
+	}
+
+	switch x := v.(type) {
+	case int:
+		return cborEncode(int64(x))
+	case int64:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case []interface{}:
+		out := head(4, uint64(len(x)))
+		for _, item := range x {
+			out = append(out, cborEncode(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(x))
+		byKey := map[string]interface{}{}
+		for k := range x {
+			s := fmt.Sprint(k)
+			keys = append(keys, s)
+			byKey[s] = k
+		}
+		sort.Strings(keys)
+		out := head(5, uint64(len(x)))
+		for _, s := range keys {
+			k := byKey[s]
+			out = append(out, cborEncode(k)...)
+			out = append(out, cborEncode(x[k])...)
+		}
+		return out
+	}
+	panic(fmt.Sprintf("cborEncode: unsupported %T", v))
+}
+
+// testAuthenticator is a software passkey bound to one RP ID
+type testAuthenticator struct {
+	rpID      string
+	id        []byte
+	ecKey     *ecdsa.PrivateKey
+	edKey     ed25519.PrivateKey
+	signCount uint32
+}
+
+func newTestAuthenticator(rpID string, ed bool) *testAuthenticator {
+	a := &testAuthenticator{rpID: rpID, id: make([]byte, 16)}
+	rand.Read(a.id)
+	if ed {
+		_, a.edKey, _ = ed25519.GenerateKey(rand.Reader)
+	} else {
+		a.ecKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return a
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	if a.edKey != nil {
+		return cborEncode(map[interface{}]interface{}{
+			int64(1): int64(1), int64(3): int64(coseAlgEdDSA), int64(-1): int64(6),
+			int64(-2): []byte(a.edKey.Public().(ed25519.PublicKey)),
+		})
+	}
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.ecKey.X.FillBytes(x)
+	a.ecKey.Y.FillBytes(y)
+	return cborEncode(map[interface{}]interface{}{
+		int64(1): int64(2), int64(3): int64(coseAlgES256), int64(-1): int64(1),
+		int64(-2): x, int64(-3): y,
+	})
+}
+
+func (a *testAuthenticator) authData(flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(a.rpID))
+	b := append([]byte{}, rpHash[:]...)
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint32(b, a.signCount)
+	if attested {
+		b = append(b, make([]byte, 16)...) // AAGUID
+		b = binary.BigEndian.AppendUint16(b, uint16(len(a.id)))
+		b = append(b, a.id...)
+		b = append(b, a.coseKey()...)
+	}
+	return b
+}
+
+func (a *testAuthenticator) sign(data []byte) []byte {
+	if a.edKey != nil {
+		return ed25519.Sign(a.edKey, data)
+	}
+	h := sha256.Sum256(data)
+	sig, _ := ecdsa.SignASN1(rand.Reader, a.ecKey, h[:])
+	return sig
+}
+
+func clientDataJSON(typ, challenge, origin string) []byte {
+	b, _ := json.Marshal(map[string]interface{}{"type": typ, "challenge": challenge, "origin": origin})
+	return b
+}
+
+var b64 = base64.RawURLEncoding.EncodeToString
+
+// register runs a registration ceremony; format is "none" or "packed" (self attestation)
+func (a *testAuthenticator) register(challenge, origin, format string) *PasskeyAttestationResponse {
+	cd := clientDataJSON("webauthn.create", challenge, origin)
+	authData := a.authData(authFlagUserPresent|authFlagUserVerified|authFlagAttestedData, true)
+
+	stmt := map[interface{}]interface{}{}
+	if format == "packed" {
+		cdHash := sha256.Sum256(cd)
+		alg := int64(coseAlgES256)
+		if a.edKey != nil {
+			alg = coseAlgEdDSA
+		}
+		stmt["alg"] = alg
+		stmt["sig"] = a.sign(append(append([]byte{}, authData...), cdHash[:]...))
+	}
+
+	att := cborEncode(map[interface{}]interface{}{"fmt": format, "authData": authData, "attStmt": stmt})
+	return &PasskeyAttestationResponse{ID: b64(a.id), ClientDataJSON: b64(cd), AttestationObject: b64(att)}
+}
+
+func (a *testAuthenticator) login(challenge, origin, userID string) *PasskeyAssertionResponse {
+	a.signCount++
+	cd := clientDataJSON("webauthn.get", challenge, origin)
+	authData := a.authData(authFlagUserPresent|authFlagUserVerified, false)
+	cdHash := sha256.Sum256(cd)
+	return &PasskeyAssertionResponse{
+		ID:                b64(a.id),
+		ClientDataJSON:    b64(cd),
+		AuthenticatorData: b64(authData),
+		Signature:         b64(a.sign(append(append([]byte{}, authData...), cdHash[:]...))),
+		UserHandle:        b64([]byte(userID)),
+	}
+}
+
+func TestCBORDecode(t *testing.T) {
+	v, n, err := cborDecode(append(cborEncode(map[interface{}]interface{}{
+		"a": []interface{}{int64(1), int64(-300), []byte{0xff}}, int64(-1): "x",
+	}), 0xf6))
+	if err != nil {
+		t.Fatalf("cborDecode failed: %v", err)
+	}
+	m := v.(map[interface{}]interface{})
+	arr := m["a"].([]interface{})
+	if arr[0] != int64(1) || arr[1] != int64(-300) || m[int64(-1)] != "x" {
+		t.Errorf("Unexpected decode: %#v", m)
+	}
+	if n != len(cborEncode(m)) {
+		t.Errorf("Expected trailing byte to be left unconsumed, consumed %d", n)
+	}
+
+	for _, bad := range [][]byte{{}, {0x5f}, {0x43, 0x01}, {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, {0xfb}} {
+		if _, _, err := cborDecode(bad); err == nil {
+			t.Errorf("Expected error for % x", bad)
+		}
+	}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		ed     bool
+		format string
+	}{
+		{"ES256 none", false, "none"},
+		{"EdDSA packed self", true, "packed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			service := NewService(db, "example.com", true)
+			user, _ := service.CreateUser("owner@example.com", "Owner", "", "google", nil)
+			auth := newTestAuthenticator("example.com", tc.ed)
+
+			if service.HasPasskeys() {
+				t.Fatal("Expected no passkeys yet")
+			}
+
+			opts, err := service.BeginPasskeyRegistration(user)
+			if err != nil {
+				t.Fatalf("BeginPasskeyRegistration failed: %v", err)
+			}
+			if opts.RP.ID != "example.com" || opts.User.ID != b64([]byte(user.ID)) {
+				t.Errorf("Unexpected options: %+v", opts)
+			}
+
+			pk, err := service.FinishPasskeyRegistration(user, "Laptop", auth.register(opts.Challenge, "https://admin.example.com", tc.format))
+			if err != nil {
+				t.Fatalf("FinishPasskeyRegistration failed: %v", err)
+			}
+			if pk.ID != b64(auth.id) || pk.Name != "Laptop" || pk.AttestationFormat != tc.format {
+				t.Errorf("Unexpected passkey: %+v", pk)
+			}
+
+			login, _ := service.BeginPasskeyLogin()
+			got, err := service.FinishPasskeyLogin(auth.login(login.Challenge, "https://example.com", user.ID))
+			if err != nil {
+				t.Fatalf("FinishPasskeyLogin failed: %v", err)
+			}
+			if got.ID != user.ID {
+				t.Errorf("Expected user %s, got %s", user.ID, got.ID)
+			}
+
+			list, _ := service.ListPasskeys(user.ID)
+			if len(list) != 1 || list[0].SignCount != 1 || list[0].LastUsedAt == nil {
+				t.Errorf("Expected sign count and last use to be recorded, got %+v", list)
+			}
+		})
+	}
+}
+
+func TestPasskeyVerificationFailures(t *testing.T) {
+	db := setupTestDB(t)
+	service := NewService(db, "example.com", true)
+	user, _ := service.CreateUser("owner@example.com", "Owner", "", "google", nil)
+	auth := newTestAuthenticator("example.com", false)
+
+	// Wrong origin, plain HTTP with secure cookies, and a reused challenge
+	opts, _ := service.BeginPasskeyRegistration(user)
+	if _, err := service.FinishPasskeyRegistration(user, "", auth.register(opts.Challenge, "https://evil.com", "none")); err != ErrInvalidPasskey {
+		t.Errorf("Expected ErrInvalidPasskey for foreign origin, got %v", err)
+	}
+	if _, err := service.FinishPasskeyRegistration(user, "", auth.register(opts.Challenge, "http://example.com", "none")); err != ErrInvalidPasskey {
+		t.Errorf("Expected ErrInvalidPasskey for http origin, got %v", err)
+	}
+	if _, err := service.FinishPasskeyRegistration(user, "", auth.register(opts.Challenge, "https://example.com", "none")); err != nil {
+		t.Fatalf("FinishPasskeyRegistration failed: %v", err)
+	}
+	if _, err := service.FinishPasskeyRegistration(user, "", auth.register(opts.Challenge, "https://example.com", "none")); err != ErrInvalidChallenge {
+		t.Errorf("Expected ErrInvalidChallenge for reused challenge, got %v", err)
+	}
+
+	// A credential for another RP ID is rejected
+	other := newTestAuthenticator("other.com", false)
+	opts, _ = service.BeginPasskeyRegistration(user)
+	if _, err := service.FinishPasskeyRegistration(user, "", other.register(opts.Challenge, "https://example.com", "none")); err != ErrInvalidPasskey {
+		t.Errorf("Expected ErrInvalidPasskey for wrong RP ID, got %v", err)
+	}
+
+	// Tampered signature
+	login, _ := service.BeginPasskeyLogin()
+	resp := auth.login(login.Challenge, "https://example.com", user.ID)
+	resp.Signature = b64([]byte("not a signature"))
+	if _, err := service.FinishPasskeyLogin(resp); err != ErrInvalidPasskey {
+		t.Errorf("Expected ErrInvalidPasskey for bad signature, got %v", err)
+	}
+
+	// Counter going backwards looks like a cloned authenticator
+	login, _ = service.BeginPasskeyLogin()
+	auth.signCount = 10
+	if _, err := service.FinishPasskeyLogin(auth.login(login.Challenge, "https://example.com", user.ID)); err != nil {
+		t.Fatalf("FinishPasskeyLogin failed: %v", err)
+	}
+	login, _ = service.BeginPasskeyLogin()
+	auth.signCount = 3
+	if _, err := service.FinishPasskeyLogin(auth.login(login.Challenge, "https://example.com", user.ID)); err != ErrPasskeyCloned {
+		t.Errorf("Expected ErrPasskeyCloned, got %v", err)
+	}
+
+	// Unknown credential
+	login, _ = service.BeginPasskeyLogin()
+	if _, err := service.FinishPasskeyLogin(other.login(login.Challenge, "https://example.com", user.ID)); err != ErrPasskeyNotFound {
+		t.Errorf("Expected ErrPasskeyNotFound, got %v", err)
+	}
+
+	// Deleted passkeys can't log in
+	if err := service.DeletePasskey(user.ID, b64(auth.id)); err != nil {
+		t.Fatalf("DeletePasskey failed: %v", err)
+	}
+	if err := service.DeletePasskey(user.ID, b64(auth.id)); err != ErrPasskeyNotFound {
+		t.Errorf("Expected ErrPasskeyNotFound on second delete, got %v", err)
+	}
+}
+
+func TestValidWebAuthnOrigin(t *testing.T) {
+	secure := NewService(nil, "example.com", true)
+	local := NewService(nil, "localhost:8080", false)
+
+	tests := []struct {
+		service *Service
+		origin  string
+		want    bool
+	}{
+		{secure, "https://example.com", true},
+		{secure, "https://admin.example.com", true},
+		{secure, "https://example.com:8443", true},
+		{secure, "http://example.com", false},
+		{secure, "https://badexample.com", false},
+		{secure, "https://example.com.evil.com", false},
+		{secure, "https://example.com/path", false},
+		{local, "http://localhost:8080", true},
+		{local, "http://admin.localhost:8080", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.service.validWebAuthnOrigin(tt.origin); got != tt.want {
+			t.Errorf("validWebAuthnOrigin(%q) on %s = %v, want %v", tt.origin, tt.service.domain, got, tt.want)
+		}
+	}
+}
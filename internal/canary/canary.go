@@ -0,0 +1,477 @@
+// Package canary implements progressive canary rollouts on top of split aliases.
+//
+// A canary shifts traffic from the stable app behind an alias to a new app in
+// fixed steps. Before each step the controller compares the canary's error
+// rate (site_logs errors / split pageviews) against a threshold and either
+// advances, promotes (100%), or rolls back to the stable app.
+package canary
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/analytics"
+)
+
+// Canary statuses
+const (
+	StatusRunning    = "running"
+	StatusPromoted   = "promoted"
+	StatusRolledBack = "rolled_back"
+	StatusAborted    = "aborted"
+)
+
+// Defaults for new canaries
+const (
+	DefaultStep         = 10
+	DefaultInterval     = 10 * time.Minute
+	DefaultMaxErrorRate = 0.05
+	MinInterval         = time.Minute
+
+	// minSampleSize is the number of requests needed before the error rate
+	// is trusted enough to trigger a rollback
+	minSampleSize = 10
+
+	// tickInterval is how often the controller checks running canaries
+	tickInterval = 30 * time.Second
+)
+
+// Common errors
+var (
+	ErrNotFound       = errors.New("canary not found")
+	ErrNotRunning     = errors.New("no canary is running for this alias")
+	ErrAlreadyRunning = errors.New("a canary is already running for this alias")
+	ErrAliasNotFound  = errors.New("alias not found")
+	ErrAliasNotProxy  = errors.New("alias must point to a single app to start a canary")
+	ErrAppNotFound    = errors.New("canary app not found")
+	ErrSameApp        = errors.New("canary app is already the stable app")
+	ErrInvalidConfig  = errors.New("invalid canary configuration")
+)
+
+// Canary is a progressive rollout of a new app behind an alias
+type Canary struct {
+	ID              int64   `json:"id"`
+	Subdomain       string  `json:"subdomain"`
+	StableAppID     string  `json:"stable_app_id"`
+	CanaryAppID     string  `json:"canary_app_id"`
+	Step            int     `json:"step"`
+	IntervalSeconds int64   `json:"interval_seconds"`
+	MaxErrorRate    float64 `json:"max_error_rate"`
+	Weight          int     `json:"weight"`
+	Status          string  `json:"status"`
+	Reason          string  `json:"reason,omitempty"`
+	LastStepAt      int64   `json:"last_step_at"`
+	CreatedAt       int64   `json:"created_at"`
+	UpdatedAt       int64   `json:"updated_at"`
+}
+
+// Config configures a new canary rollout
+type Config struct {
+	Subdomain    string
+	CanaryAppID  string
+	Step         int
+	Interval     time.Duration
+	MaxErrorRate float64
+}
+
+// Validate fills defaults and checks bounds
+func (c *Config) Validate() error {
+	if c.Subdomain == "" || c.CanaryAppID == "" {
+		return fmt.Errorf("%w: subdomain and canary app are required", ErrInvalidConfig)
+	}
+	if c.Step == 0 {
+		c.Step = DefaultStep
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.MaxErrorRate == 0 {
+		c.MaxErrorRate = DefaultMaxErrorRate
+	}
+	if c.Step < 1 || c.Step > 100 {
+		return fmt.Errorf("%w: step must be between 1 and 100", ErrInvalidConfig)
+	}
+	if c.Interval < MinInterval {
+		return fmt.Errorf("%w: interval must be at least %s", ErrInvalidConfig, MinInterval)
+	}
+	if c.MaxErrorRate <= 0 || c.MaxErrorRate > 1 {
+		return fmt.Errorf("%w: error rate threshold must be between 0 and 100%%", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// Start begins a canary rollout. The alias must currently proxy to a single app,
+// which becomes the stable app. The first step is applied immediately.
+func Start(db *sql.DB, cfg Config) (*Canary, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var aliasType string
+	var targets sql.NullString
+	err = tx.QueryRow(`SELECT type, targets FROM aliases WHERE subdomain = ?`, cfg.Subdomain).Scan(&aliasType, &targets)
+	if err == sql.ErrNoRows {
+		return nil, ErrAliasNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (aliasType != "proxy" && aliasType != "app") || !targets.Valid {
+		return nil, ErrAliasNotProxy
+	}
+	var target struct {
+		AppID string `json:"app_id"`
+	}
+	if err := json.Unmarshal([]byte(targets.String), &target); err != nil || target.AppID == "" {
+		return nil, ErrAliasNotProxy
+	}
+	if target.AppID == cfg.CanaryAppID {
+		return nil, ErrSameApp
+	}
+
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM apps WHERE id = ?`, cfg.CanaryAppID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrAppNotFound
+	}
+
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM canaries WHERE subdomain = ? AND status = ?`, cfg.Subdomain, StatusRunning).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyRunning
+	}
+
+	weight := cfg.Step
+	if weight > 100 {
+		weight = 100
+	}
+	now := time.Now().Unix()
+
+	res, err := tx.Exec(`
+		INSERT INTO canaries (subdomain, stable_app_id, canary_app_id, step, interval_seconds, max_error_rate, weight, status, last_step_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, cfg.Subdomain, target.AppID, cfg.CanaryAppID, cfg.Step, int64(cfg.Interval/time.Second), cfg.MaxErrorRate, weight, StatusRunning, now, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+
+	c := &Canary{
+		ID:              id,
+		Subdomain:       cfg.Subdomain,
+		StableAppID:     target.AppID,
+		CanaryAppID:     cfg.CanaryAppID,
+		Step:            cfg.Step,
+		IntervalSeconds: int64(cfg.Interval / time.Second),
+		MaxErrorRate:    cfg.MaxErrorRate,
+		Weight:          weight,
+		Status:          StatusRunning,
+		LastStepAt:      now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if weight >= 100 {
+		if err := finish(tx, c, StatusPromoted, "step covers full traffic"); err != nil {
+			return nil, err
+		}
+	} else if err := applySplit(tx, c); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logEvent(c, "start", nil)
+	return c, nil
+}
+
+// Get returns the most recent canary for a subdomain
+func Get(db *sql.DB, subdomain string) (*Canary, error) {
+	row := db.QueryRow(`
+		SELECT id, subdomain, stable_app_id, canary_app_id, step, interval_seconds, max_error_rate,
+			weight, status, COALESCE(reason, ''), last_step_at, created_at, updated_at
+		FROM canaries WHERE subdomain = ?
+		ORDER BY id DESC LIMIT 1
+	`, subdomain)
+	c, err := scanCanary(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return c, err
+}
+
+// Abort stops a running canary and routes all traffic back to the stable app
+func Abort(db *sql.DB, subdomain string) (*Canary, error) {
+	c, err := Get(db, subdomain)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != StatusRunning {
+		return nil, ErrNotRunning
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := finish(tx, c, StatusAborted, "aborted by operator"); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	logEvent(c, "abort", nil)
+	return c, nil
+}
+
+// Stats holds the observed canary traffic for the current step
+type Stats struct {
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// CurrentStats returns canary traffic and errors since the last step
+func CurrentStats(db *sql.DB, c *Canary) Stats {
+	var s Stats
+	since := c.LastStepAt
+
+	db.QueryRow(`
+		SELECT COUNT(*) FROM events
+		WHERE domain = ? AND tags = ? AND created_at >= DATETIME(?, 'unixepoch')
+	`, c.CanaryAppID, analytics.SplitTag(c.Subdomain), since).Scan(&s.Requests)
+
+	// Serverless errors are logged under the app's site ID (title)
+	db.QueryRow(`
+		SELECT COUNT(*) FROM site_logs
+		WHERE level = 'error'
+		AND site_id IN (SELECT title FROM apps WHERE id = ? UNION SELECT ?)
+		AND created_at >= DATETIME(?, 'unixepoch')
+	`, c.CanaryAppID, c.CanaryAppID, since).Scan(&s.Errors)
+
+	if s.Requests > 0 {
+		s.ErrorRate = float64(s.Errors) / float64(s.Requests)
+	} else if s.Errors > 0 {
+		s.ErrorRate = 1
+	}
+	return s
+}
+
+// Advance evaluates a running canary and moves it one step forward,
+// promotes it, or rolls it back. It is a no-op before the interval elapses.
+func Advance(db *sql.DB, c *Canary, now time.Time) error {
+	if c.Status != StatusRunning || now.Unix() < c.LastStepAt+c.IntervalSeconds {
+		return nil
+	}
+
+	stats := CurrentStats(db, c)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	details := map[string]interface{}{
+		"requests":   stats.Requests,
+		"errors":     stats.Errors,
+		"error_rate": stats.ErrorRate,
+	}
+
+	sampled := stats.Requests >= minSampleSize || stats.Errors >= minSampleSize
+	action := "step"
+	switch {
+	case sampled && stats.ErrorRate > c.MaxErrorRate:
+		reason := fmt.Sprintf("error rate %.1f%% exceeded %.1f%% at weight %d%%",
+			stats.ErrorRate*100, c.MaxErrorRate*100, c.Weight)
+		if err := finish(tx, c, StatusRolledBack, reason); err != nil {
+			return err
+		}
+		action = "rollback"
+	case c.Weight+c.Step >= 100:
+		if err := finish(tx, c, StatusPromoted, ""); err != nil {
+			return err
+		}
+		action = "promote"
+	default:
+		c.Weight += c.Step
+		c.LastStepAt = now.Unix()
+		c.UpdatedAt = now.Unix()
+		if _, err := tx.Exec(`UPDATE canaries SET weight = ?, last_step_at = ?, updated_at = ? WHERE id = ?`,
+			c.Weight, c.LastStepAt, c.UpdatedAt, c.ID); err != nil {
+			return err
+		}
+		if err := applySplit(tx, c); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	logEvent(c, action, details)
+	return nil
+}
+
+// Controller periodically advances running canaries
+type Controller struct {
+	db   *sql.DB
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewController creates a canary controller
+func NewController(db *sql.DB) *Controller {
+	return &Controller{
+		db:   db,
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background control loop
+func (ctl *Controller) Start() {
+	ctl.wg.Add(1)
+	go func() {
+		defer ctl.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctl.Tick(time.Now())
+			case <-ctl.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the control loop
+func (ctl *Controller) Stop() {
+	close(ctl.done)
+	ctl.wg.Wait()
+}
+
+// Tick advances every running canary whose interval has elapsed
+func (ctl *Controller) Tick(now time.Time) {
+	rows, err := ctl.db.Query(`
+		SELECT id, subdomain, stable_app_id, canary_app_id, step, interval_seconds, max_error_rate,
+			weight, status, COALESCE(reason, ''), last_step_at, created_at, updated_at
+		FROM canaries WHERE status = ?
+	`, StatusRunning)
+	if err != nil {
+		log.Printf("Canary: failed to list running canaries: %v", err)
+		return
+	}
+
+	var running []*Canary
+	for rows.Next() {
+		if c, err := scanCanary(rows); err == nil {
+			running = append(running, c)
+		}
+	}
+	rows.Close()
+
+	for _, c := range running {
+		if err := Advance(ctl.db, c, now); err != nil {
+			log.Printf("Canary: failed to advance %s: %v", c.Subdomain, err)
+		}
+	}
+}
+
+// finish ends a canary and points the alias at the winning app
+func finish(tx *sql.Tx, c *Canary, status, reason string) error {
+	winner := c.StableAppID
+	if status == StatusPromoted {
+		winner = c.CanaryAppID
+		c.Weight = 100
+	} else {
+		c.Weight = 0
+	}
+
+	target, _ := json.Marshal(map[string]string{"app_id": winner})
+	if _, err := tx.Exec(`UPDATE aliases SET type = 'proxy', targets = ?, updated_at = CURRENT_TIMESTAMP WHERE subdomain = ?`,
+		string(target), c.Subdomain); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	c.Status = status
+	c.Reason = reason
+	c.UpdatedAt = now
+	_, err := tx.Exec(`UPDATE canaries SET status = ?, reason = ?, weight = ?, updated_at = ? WHERE id = ?`,
+		status, reason, c.Weight, now, c.ID)
+	return err
+}
+
+// applySplit writes the current stable/canary weights to the alias
+func applySplit(tx *sql.Tx, c *Canary) error {
+	targets, _ := json.Marshal([]map[string]interface{}{
+		{"app_id": c.StableAppID, "weight": 100 - c.Weight},
+		{"app_id": c.CanaryAppID, "weight": c.Weight},
+	})
+	_, err := tx.Exec(`UPDATE aliases SET type = 'split', targets = ?, updated_at = CURRENT_TIMESTAMP WHERE subdomain = ?`,
+		string(targets), c.Subdomain)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanCanary(row rowScanner) (*Canary, error) {
+	var c Canary
+	err := row.Scan(&c.ID, &c.Subdomain, &c.StableAppID, &c.CanaryAppID, &c.Step, &c.IntervalSeconds,
+		&c.MaxErrorRate, &c.Weight, &c.Status, &c.Reason, &c.LastStepAt, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func logEvent(c *Canary, action string, extra map[string]interface{}) {
+	details := map[string]interface{}{
+		"stable_app_id": c.StableAppID,
+		"canary_app_id": c.CanaryAppID,
+		"weight":        c.Weight,
+		"status":        c.Status,
+	}
+	if c.Reason != "" {
+		details["reason"] = c.Reason
+	}
+	for k, v := range extra {
+		details[k] = v
+	}
+
+	activity.Log(activity.Entry{
+		ActorType:    activity.ActorSystem,
+		ResourceType: "canary",
+		ResourceID:   c.Subdomain,
+		Action:       action,
+		Weight:       activity.WeightDeployment,
+		Details:      details,
+	})
+	log.Printf("Canary %s: %s (weight %d%%, status %s)", c.Subdomain, action, c.Weight, c.Status)
+}
@@ -0,0 +1,121 @@
+package canary
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	db.Exec(`INSERT INTO apps (id, title) VALUES ('app_v1', 'shop-v1'), ('app_v2', 'shop-v2')`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('shop', 'proxy', '{"app_id":"app_v1"}')`)
+	return db
+}
+
+func aliasState(t *testing.T, db *sql.DB) (string, string) {
+	t.Helper()
+	var aliasType, targets string
+	if err := db.QueryRow(`SELECT type, targets FROM aliases WHERE subdomain = 'shop'`).Scan(&aliasType, &targets); err != nil {
+		t.Fatalf("Failed to read alias: %v", err)
+	}
+	return aliasType, targets
+}
+
+func TestStartValidation(t *testing.T) {
+	db := setupTestDB(t)
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want error
+	}{
+		{"unknown alias", Config{Subdomain: "nope", CanaryAppID: "app_v2"}, ErrAliasNotFound},
+		{"unknown app", Config{Subdomain: "shop", CanaryAppID: "app_v9"}, ErrAppNotFound},
+		{"same app", Config{Subdomain: "shop", CanaryAppID: "app_v1"}, ErrSameApp},
+		{"short interval", Config{Subdomain: "shop", CanaryAppID: "app_v2", Interval: time.Second}, ErrInvalidConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Start(db, tt.cfg); !errors.Is(err, tt.want) {
+				t.Errorf("Start() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvanceToPromotion(t *testing.T) {
+	db := setupTestDB(t)
+
+	c, err := Start(db, Config{Subdomain: "shop", CanaryAppID: "app_v2", Step: 50, Interval: time.Minute})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if aliasType, targets := aliasState(t, db); aliasType != "split" ||
+		targets != `[{"app_id":"app_v1","weight":50},{"app_id":"app_v2","weight":50}]` {
+		t.Fatalf("Unexpected alias after start: %s %s", aliasType, targets)
+	}
+
+	if _, err := Start(db, Config{Subdomain: "shop", CanaryAppID: "app_v2"}); !errors.Is(err, ErrAliasNotProxy) {
+		t.Errorf("Expected second start to be rejected, got %v", err)
+	}
+
+	// Nothing happens before the interval elapses
+	Advance(db, c, time.Now())
+	if c.Weight != 50 {
+		t.Errorf("Expected weight 50 before interval, got %d", c.Weight)
+	}
+
+	if err := Advance(db, c, time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+	if c.Status != StatusPromoted {
+		t.Fatalf("Expected promoted, got %s", c.Status)
+	}
+	if aliasType, targets := aliasState(t, db); aliasType != "proxy" || targets != `{"app_id":"app_v2"}` {
+		t.Errorf("Unexpected alias after promotion: %s %s", aliasType, targets)
+	}
+}
+
+func TestAdvanceRollsBackOnErrors(t *testing.T) {
+	db := setupTestDB(t)
+
+	c, err := Start(db, Config{Subdomain: "shop", CanaryAppID: "app_v2", Interval: time.Minute, MaxErrorRate: 0.1})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	for i := 0; i < 20; i++ {
+		db.Exec(`INSERT INTO events (domain, tags, source_type, event_type, path) VALUES ('app_v2', ?, 'hosting', 'pageview', '/')`,
+			analytics.SplitTag("shop"))
+	}
+	for i := 0; i < 5; i++ {
+		db.Exec(`INSERT INTO site_logs (site_id, level, message) VALUES ('shop-v2', 'error', 'boom')`)
+	}
+
+	if s := CurrentStats(db, c); s.Requests != 20 || s.Errors != 5 {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+
+	if err := Advance(db, c, time.Now().Add(2*time.Minute)); err != nil {
+		t.Fatalf("Advance failed: %v", err)
+	}
+	if c.Status != StatusRolledBack {
+		t.Fatalf("Expected rolled back, got %s", c.Status)
+	}
+	if aliasType, targets := aliasState(t, db); aliasType != "proxy" || targets != `{"app_id":"app_v1"}` {
+		t.Errorf("Unexpected alias after rollback: %s %s", aliasType, targets)
+	}
+
+	if _, err := Abort(db, "shop"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning, got %v", err)
+	}
+}
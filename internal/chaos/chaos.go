@@ -0,0 +1,143 @@
+// Package chaos disrupts an app's bindings on purpose, on development
+// servers, so developers can check their handlers degrade gracefully:
+// storage and egress calls get random latency and fail at a configured
+// rate. Settings live in memory and are gone on restart.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxLatency caps the delay a disrupted call can get
+const MaxLatency = 10 * time.Second
+
+var (
+	// ErrStorage is returned by storage calls chaos fails
+	ErrStorage = errors.New("chaos: injected storage error")
+	// ErrEgress is returned by fetches chaos fails
+	ErrEgress = errors.New("chaos: injected egress failure")
+)
+
+// Config is an app's chaos settings. Each storage or egress call is
+// disrupted with probability Rate: it waits a random delay up to
+// LatencyMS, then fails if its kind is turned on.
+type Config struct {
+	Rate      float64 `json:"rate"`       // 0-1
+	LatencyMS int     `json:"latency_ms"` // Max added delay; 0 = none
+	Storage   bool    `json:"storage"`    // Fail fazt.app.* / fazt.storage.* calls
+	Egress    bool    `json:"egress"`     // Fail fazt.net.fetch calls
+}
+
+// Validate checks a config's ranges
+func (c Config) Validate() error {
+	if c.Rate < 0 || c.Rate > 1 {
+		return errors.New("rate must be between 0 and 1")
+	}
+	if c.LatencyMS < 0 || time.Duration(c.LatencyMS)*time.Millisecond > MaxLatency {
+		return errors.New("latency_ms must be between 0 and 10000")
+	}
+	return nil
+}
+
+// Status is an app's chaos settings with what they've done so far
+type Status struct {
+	Config
+	Enabled  bool   `json:"enabled"`
+	Delayed  uint64 `json:"delayed"`  // Calls given added latency
+	Injected uint64 `json:"injected"` // Calls failed
+}
+
+type appChaos struct {
+	cfg      Config
+	delayed  atomic.Uint64
+	injected atomic.Uint64
+}
+
+var (
+	apps   = make(map[string]*appChaos)
+	appsMu sync.RWMutex
+)
+
+// Set turns chaos on for an app, replacing its settings and counts. A zero
+// rate turns it off.
+func Set(appID string, cfg Config) {
+	appsMu.Lock()
+	defer appsMu.Unlock()
+	if cfg.Rate == 0 {
+		delete(apps, appID)
+		return
+	}
+	apps[appID] = &appChaos{cfg: cfg}
+}
+
+// Clear turns chaos off for an app
+func Clear(appID string) {
+	Set(appID, Config{})
+}
+
+// Get returns an app's chaos settings and counts
+func Get(appID string) Status {
+	appsMu.RLock()
+	a := apps[appID]
+	appsMu.RUnlock()
+	if a == nil {
+		return Status{}
+	}
+	return Status{Config: a.cfg, Enabled: true, Delayed: a.delayed.Load(), Injected: a.injected.Load()}
+}
+
+type ctxKey struct{}
+
+// WithApp returns ctx carrying the app's chaos settings, if it has any, for
+// the bindings of one execution
+func WithApp(ctx context.Context, appID string) context.Context {
+	appsMu.RLock()
+	a := apps[appID]
+	appsMu.RUnlock()
+	if a == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, a)
+}
+
+// Storage disrupts a storage call of the execution ctx belongs to, as its
+// app's settings roll. It returns ErrStorage when the call should fail.
+func Storage(ctx context.Context) error {
+	return disrupt(ctx, func(cfg Config) bool { return cfg.Storage }, ErrStorage)
+}
+
+// Egress disrupts a fetch of the execution ctx belongs to, as its app's
+// settings roll. It returns ErrEgress when the fetch should fail.
+func Egress(ctx context.Context) error {
+	return disrupt(ctx, func(cfg Config) bool { return cfg.Egress }, ErrEgress)
+}
+
+func disrupt(ctx context.Context, fails func(Config) bool, err error) error {
+	a, ok := ctx.Value(ctxKey{}).(*appChaos)
+	if !ok || rand.Float64() >= a.cfg.Rate {
+		return nil
+	}
+
+	if a.cfg.LatencyMS > 0 {
+		a.delayed.Add(1)
+		delay := time.Duration(rand.Int64N(int64(a.cfg.LatencyMS)*int64(time.Millisecond) + 1))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	if !fails(a.cfg) {
+		return nil
+	}
+	a.injected.Add(1)
+	return err
+}
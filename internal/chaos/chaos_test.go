@@ -0,0 +1,72 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDisrupt(t *testing.T) {
+	t.Cleanup(func() { Clear("app1") })
+
+	// No settings: calls are left alone
+	ctx := WithApp(context.Background(), "app1")
+	if err := Storage(ctx); err != nil {
+		t.Fatalf("Expected no error without chaos, got %v", err)
+	}
+
+	Set("app1", Config{Rate: 1, Storage: true})
+	ctx = WithApp(context.Background(), "app1")
+	if err := Storage(ctx); !errors.Is(err, ErrStorage) {
+		t.Errorf("Expected ErrStorage, got %v", err)
+	}
+	if err := Egress(ctx); err != nil {
+		t.Errorf("Expected egress untouched, got %v", err)
+	}
+	// Other apps' executions are unaffected
+	if err := Storage(WithApp(context.Background(), "app2")); err != nil {
+		t.Errorf("Expected no error for another app, got %v", err)
+	}
+
+	status := Get("app1")
+	if !status.Enabled || status.Injected != 1 || status.Delayed != 0 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	Clear("app1")
+	if Get("app1").Enabled {
+		t.Error("Expected chaos off after Clear")
+	}
+}
+
+func TestDisrupt_Latency(t *testing.T) {
+	t.Cleanup(func() { Clear("app1") })
+	Set("app1", Config{Rate: 1, LatencyMS: 10000, Egress: true})
+
+	// A cancelled execution stops waiting
+	ctx, cancel := context.WithTimeout(WithApp(context.Background(), "app1"), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := Egress(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, ErrEgress) {
+		t.Errorf("Expected deadline or ErrEgress, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Expected the delay to end with the context")
+	}
+	if Get("app1").Delayed != 1 {
+		t.Errorf("Expected 1 delayed call, got %d", Get("app1").Delayed)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []Config{{Rate: -0.1}, {Rate: 1.5}, {Rate: 0.5, LatencyMS: -1}, {Rate: 0.5, LatencyMS: 10001}} {
+		if cfg.Validate() == nil {
+			t.Errorf("Expected %+v to be invalid", cfg)
+		}
+	}
+	if err := (Config{Rate: 0.5, LatencyMS: 10000, Storage: true}).Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 // WildcardDNSProviders is the list of wildcard DNS services to try, in order.
@@ -86,6 +88,8 @@ type Config struct {
 	Database DatabaseConfig `json:"database"`
 	Auth AuthConfig     `json:"auth"`
 	Ntfy NtfyConfig     `json:"ntfy"`
+	Notify NotifyConfig `json:"notify"`
+	Mail   MailConfig   `json:"mail"`
 	APIKey APIKeyConfig `json:"api_key,omitempty"`
 	HTTPS  HTTPSConfig  `json:"https"`
 }
@@ -95,6 +99,82 @@ type ServerConfig struct {
 	Port   string `json:"port"`
 	Domain string `json:"domain"`
 	Env    string `json:"env"` // development/production
+
+	RateLimit RateLimitConfig `json:"rate_limit"`
+
+	// TrustedProxies are the CIDRs whose X-Forwarded-For and X-Real-IP
+	// headers are honored. Empty trusts no one.
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	// VFSCacheMB is the in-memory LRU budget for hot static files; 0 disables it
+	VFSCacheMB int `json:"vfs_cache_mb"`
+
+	// SlowStorageMs is the duration above which app storage operations are
+	// kept in the slow-op log
+	SlowStorageMs int `json:"slow_storage_ms"`
+
+	VMPool VMPoolConfig `json:"vm_pool"`
+
+	// SecretScan is what happens to deploys with likely secrets in publicly
+	// served files: off, warn, or block
+	SecretScan string `json:"secret_scan"`
+
+	Analytics AnalyticsConfig `json:"analytics"`
+
+	VirusScan VirusScanConfig `json:"virus_scan"`
+
+	Uploads UploadsConfig `json:"uploads"`
+
+	// Deploy limits, which apps and deployers can override
+	DeployLimits DeployLimitsConfig `json:"deploy_limits"`
+}
+
+// DeployLimitsConfig bounds what one deploy may write. 0 is unlimited.
+type DeployLimitsConfig struct {
+	MaxBundleMB int `json:"max_bundle_mb"` // Total size of the files, uncompressed
+	MaxFiles    int `json:"max_files"`
+	MaxFileMB   int `json:"max_file_mb"` // Largest single file, uncompressed
+}
+
+// UploadsConfig limits resumable (tus) uploads
+type UploadsConfig struct {
+	MaxMB       int `json:"max_mb"`       // Largest upload accepted
+	ExpireHours int `json:"expire_hours"` // Unfinished uploads are removed after this long
+}
+
+// VirusScanConfig sets how uploaded blobs are checked with ClamAV
+type VirusScanConfig struct {
+	Mode    string   `json:"mode"`            // off, clamd, or exec
+	Address string   `json:"address"`         // clamd socket or host:port, or the clamscan binary
+	Paths   []string `json:"paths,omitempty"` // Blob path globs to scan; empty scans every upload
+}
+
+// AnalyticsConfig sets how long raw analytics events are kept. Events are
+// rolled up into hourly and daily counts, which are kept forever.
+type AnalyticsConfig struct {
+	RetentionDays int    `json:"retention_days"`       // Raw events older than this are deleted; 0 keeps them
+	RawDays       int    `json:"raw_days"`             // Stats read raw events for this many recent days, rollups before
+	DropIP        bool   `json:"drop_ip"`              // Store events without the visitor's IP address
+	GeoIPPath     string `json:"geoip_path,omitempty"` // MaxMind DB to locate events with by country and region
+}
+
+// VMPoolConfig sizes the pool of pre-warmed JavaScript VMs that serve
+// serverless requests
+type VMPoolConfig struct {
+	Size           int `json:"size"`            // VMs shared by all apps
+	AppConcurrency int `json:"app_concurrency"` // Concurrent executions per app
+	QueueMs        int `json:"queue_ms"`        // How long a request waits for a VM
+}
+
+// RateLimitConfig holds request rate limits. Rates are requests per second
+// with a token-bucket burst; a rate of 0 disables that limit.
+type RateLimitConfig struct {
+	IPRate    float64 `json:"ip_rps"`   // Site requests per client IP
+	IPBurst   int     `json:"ip_burst"`
+	SiteRate  float64 `json:"site_rps"` // Requests per site (host), all clients combined
+	SiteBurst int     `json:"site_burst"`
+	APIRate   float64 `json:"api_rps"`  // /api/* requests per client IP
+	APIBurst  int     `json:"api_burst"`
 }
 
 // HTTPSConfig holds automatic HTTPS configuration
@@ -111,8 +191,10 @@ type DatabaseConfig struct {
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"` // bcrypt hash
+	Username        string `json:"username"`
+	PasswordHash    string `json:"password_hash"`    // bcrypt hash
+	Require2FA      bool   `json:"require_2fa"`      // Password logins must complete TOTP
+	RequireApproval bool   `json:"require_approval"` // Destructive operations need a second admin
 }
 
 // NtfyConfig holds notification configuration
@@ -121,6 +203,47 @@ type NtfyConfig struct {
 	URL   string `json:"url"`
 }
 
+// NotifyConfig holds the email and webhook notification channels (ntfy is
+// configured under Ntfy) and which channels each event type goes to
+type NotifyConfig struct {
+	SMTP       SMTPConfig `json:"smtp"`
+	WebhookURL string     `json:"webhook_url,omitempty"`
+
+	// Routes maps an event type to the channels it is sent to. Event types
+	// without a route go to every configured channel.
+	Routes map[string][]string `json:"routes,omitempty"`
+}
+
+// SMTPConfig holds a mail server emails are sent through
+type SMTPConfig struct {
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"-"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// MailConfig holds the mail server apps send email through with
+// fazt.app.mail, which an app's own settings can override
+type MailConfig struct {
+	SMTP SMTPConfig `json:"smtp"` // To is unused: apps choose their recipients
+
+	// DailyLimit is how many emails an app may send per UTC day, unless its
+	// settings give it another limit
+	DailyLimit int `json:"daily_limit"`
+
+	Inbound InboundMailConfig `json:"inbound"`
+}
+
+// InboundMailConfig holds the SMTP server that receives email for apps at
+// <app>@<domain>, handed to their mail.received handlers
+type InboundMailConfig struct {
+	Port   string `json:"port,omitempty"`   // e.g. "25"; empty turns receiving off
+	Domain string `json:"domain,omitempty"` // Default: apps.<server domain>
+	MaxMB  int    `json:"max_mb"`           // Largest message accepted
+}
+
 // APIKeyConfig holds API key configuration for deployment
 type APIKeyConfig struct {
 	Token string `json:"token,omitempty"`
@@ -185,6 +308,35 @@ func CreateDefaultConfig() *Config {
 			Port:   "4698",
 			Domain: "https://fazt.sh",
 			Env:    "development",
+			RateLimit: RateLimitConfig{
+				IPRate:   500,
+				IPBurst:  1000,
+				APIRate:  100,
+				APIBurst: 200,
+			},
+			VFSCacheMB:    64,
+			SlowStorageMs: 100,
+			SecretScan:    "warn",
+			VMPool: VMPoolConfig{
+				Size:           100,
+				AppConcurrency: 20,
+				QueueMs:        2000,
+			},
+			Analytics: AnalyticsConfig{
+				RawDays: 7,
+			},
+			VirusScan: VirusScanConfig{
+				Mode: "off",
+			},
+			Uploads: UploadsConfig{
+				MaxMB:       512,
+				ExpireHours: 24,
+			},
+			DeployLimits: DeployLimitsConfig{
+				MaxBundleMB: 500,
+				MaxFiles:    10000,
+				MaxFileMB:   100,
+			},
 		},
 		Database: DatabaseConfig{
 			Path: defaultDBPath,
@@ -197,6 +349,14 @@ func CreateDefaultConfig() *Config {
 			Topic: "",
 			URL:   "https://ntfy.sh",
 		},
+		Notify: NotifyConfig{
+			SMTP: SMTPConfig{Port: 587},
+		},
+		Mail: MailConfig{
+			SMTP:       SMTPConfig{Port: 587},
+			DailyLimit: 100,
+			Inbound:    InboundMailConfig{MaxMB: 10},
+		},
 		HTTPS: HTTPSConfig{
 			Enabled: false,
 			Email:   "",
@@ -240,6 +400,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid port: %d (must be 1-65535)", port)
 	}
 
+	// Validate trusted proxies
+	if _, err := realip.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return err
+	}
+
 	// Validate environment
 	if c.Server.Env != "development" && c.Server.Env != "production" {
 		return fmt.Errorf("invalid environment: %s (must be 'development' or 'production')", c.Server.Env)
@@ -279,6 +444,12 @@ func Get() *Config {
 	return appConfig
 }
 
+// Loaded reports whether the configuration has been loaded, as it is in the
+// server but not in most CLI commands
+func Loaded() bool {
+	return appConfig != nil
+}
+
 // SetConfig sets the application configuration (primarily for testing)
 func SetConfig(cfg *Config) {
 	appConfig = cfg
@@ -293,3 +293,29 @@ func TestAuthConfig_RequiresBothUsernameAndPassword(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyDBMap_Notify(t *testing.T) {
+	cfg := CreateDefaultConfig()
+	applyDBMap(cfg, map[string]string{
+		"notify.smtp.host":          "smtp.example.com",
+		"notify.smtp.port":          "465",
+		"notify.smtp.to":            "ops@example.com, admin@example.com",
+		"notify.webhook_url":        "https://hooks.example.com/fazt",
+		"notify.route.cert.failed":  "email,ntfy",
+		"notify.route.login.new_ip": "none",
+	})
+
+	smtp := cfg.Notify.SMTP
+	if smtp.Host != "smtp.example.com" || smtp.Port != 465 || len(smtp.To) != 2 || smtp.To[1] != "admin@example.com" {
+		t.Errorf("Unexpected SMTP config: %+v", smtp)
+	}
+	if cfg.Notify.WebhookURL != "https://hooks.example.com/fazt" {
+		t.Errorf("WebhookURL = %s", cfg.Notify.WebhookURL)
+	}
+	if r := cfg.Notify.Routes["cert.failed"]; len(r) != 2 || r[0] != "email" {
+		t.Errorf("cert.failed route = %v, want [email ntfy]", r)
+	}
+	if r, ok := cfg.Notify.Routes["login.new_ip"]; !ok || len(r) != 0 {
+		t.Errorf("login.new_ip route = %v, want empty", r)
+	}
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 // DBConfigStore handles database operations for configuration
@@ -48,6 +50,17 @@ func (s *DBConfigStore) Set(key, value string) error {
 	return err
 }
 
+// Apply loads the stored configuration onto cfg, without validating it or
+// making it the app's configuration
+func (s *DBConfigStore) Apply(cfg *Config) error {
+	data, err := s.Load()
+	if err != nil {
+		return err
+	}
+	applyDBMap(cfg, data)
+	return nil
+}
+
 // LoadFromDB loads config from SQLite database and applies CLI flag overrides.
 // Config priority: CLI flags > Database > Defaults
 // The database is the source of truth. CLI flags are for temporary overrides.
@@ -86,12 +99,76 @@ func applyDBMap(cfg *Config, data map[string]string) {
 			cfg.Server.Domain = v
 		case "server.env":
 			cfg.Server.Env = v
+		case "server.rate_limit.ip_rps":
+			parseFloat(v, &cfg.Server.RateLimit.IPRate)
+		case "server.rate_limit.ip_burst":
+			parseInt(v, &cfg.Server.RateLimit.IPBurst)
+		case "server.rate_limit.site_rps":
+			parseFloat(v, &cfg.Server.RateLimit.SiteRate)
+		case "server.rate_limit.site_burst":
+			parseInt(v, &cfg.Server.RateLimit.SiteBurst)
+		case "server.rate_limit.api_rps":
+			parseFloat(v, &cfg.Server.RateLimit.APIRate)
+		case "server.rate_limit.api_burst":
+			parseInt(v, &cfg.Server.RateLimit.APIBurst)
+		case "server.vfs_cache_mb":
+			parseInt(v, &cfg.Server.VFSCacheMB)
+		case "server.slow_storage_ms":
+			parseInt(v, &cfg.Server.SlowStorageMs)
+		case "server.vm_pool.size":
+			parseInt(v, &cfg.Server.VMPool.Size)
+		case "server.vm_pool.app_concurrency":
+			parseInt(v, &cfg.Server.VMPool.AppConcurrency)
+		case "server.vm_pool.queue_ms":
+			parseInt(v, &cfg.Server.VMPool.QueueMs)
+		case "server.secret_scan":
+			cfg.Server.SecretScan = v
+		case "server.analytics.retention_days":
+			parseInt(v, &cfg.Server.Analytics.RetentionDays)
+		case "server.analytics.raw_days":
+			parseInt(v, &cfg.Server.Analytics.RawDays)
+		case "server.analytics.drop_ip":
+			cfg.Server.Analytics.DropIP = (v == "true")
+		case "server.analytics.geoip_path":
+			cfg.Server.Analytics.GeoIPPath = v
+		case "server.virus_scan.mode":
+			cfg.Server.VirusScan.Mode = v
+		case "server.virus_scan.address":
+			cfg.Server.VirusScan.Address = v
+		case "server.virus_scan.paths":
+			cfg.Server.VirusScan.Paths = nil
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					cfg.Server.VirusScan.Paths = append(cfg.Server.VirusScan.Paths, p)
+				}
+			}
+		case "server.uploads.max_mb":
+			parseInt(v, &cfg.Server.Uploads.MaxMB)
+		case "server.uploads.expire_hours":
+			parseInt(v, &cfg.Server.Uploads.ExpireHours)
+		case "server.deploy_limits.max_bundle_mb":
+			parseInt(v, &cfg.Server.DeployLimits.MaxBundleMB)
+		case "server.deploy_limits.max_files":
+			parseInt(v, &cfg.Server.DeployLimits.MaxFiles)
+		case "server.deploy_limits.max_file_mb":
+			parseInt(v, &cfg.Server.DeployLimits.MaxFileMB)
+		case "server.trusted_proxies":
+			cfg.Server.TrustedProxies = nil
+			for _, cidr := range strings.Split(v, ",") {
+				if cidr = strings.TrimSpace(cidr); cidr != "" {
+					cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, cidr)
+				}
+			}
 		
 		// Auth
 		case "auth.username":
 			cfg.Auth.Username = v
 		case "auth.password_hash":
 			cfg.Auth.PasswordHash = v
+		case "auth.require_2fa":
+			cfg.Auth.Require2FA = (v == "true")
+		case "auth.require_approval":
+			cfg.Auth.RequireApproval = (v == "true")
 			
 		// Ntfy
 		case "ntfy.topic":
@@ -99,6 +176,42 @@ func applyDBMap(cfg *Config, data map[string]string) {
 		case "ntfy.url":
 			cfg.Ntfy.URL = v
 
+		// Notification channels and routes
+		case "notify.smtp.host":
+			cfg.Notify.SMTP.Host = v
+		case "notify.smtp.port":
+			parseInt(v, &cfg.Notify.SMTP.Port)
+		case "notify.smtp.username":
+			cfg.Notify.SMTP.Username = v
+		case "notify.smtp.password":
+			cfg.Notify.SMTP.Password = v
+		case "notify.smtp.from":
+			cfg.Notify.SMTP.From = v
+		case "notify.smtp.to":
+			cfg.Notify.SMTP.To = splitList(v)
+		case "notify.webhook_url":
+			cfg.Notify.WebhookURL = v
+
+		// App email (fazt.app.mail)
+		case "mail.smtp.host":
+			cfg.Mail.SMTP.Host = v
+		case "mail.smtp.port":
+			parseInt(v, &cfg.Mail.SMTP.Port)
+		case "mail.smtp.username":
+			cfg.Mail.SMTP.Username = v
+		case "mail.smtp.password":
+			cfg.Mail.SMTP.Password = v
+		case "mail.smtp.from":
+			cfg.Mail.SMTP.From = v
+		case "mail.daily_limit":
+			parseInt(v, &cfg.Mail.DailyLimit)
+		case "mail.inbound.port":
+			cfg.Mail.Inbound.Port = v
+		case "mail.inbound.domain":
+			cfg.Mail.Inbound.Domain = v
+		case "mail.inbound.max_mb":
+			parseInt(v, &cfg.Mail.Inbound.MaxMB)
+
 		// HTTPS
 		case "https.enabled":
 			cfg.HTTPS.Enabled = (v == "true")
@@ -112,6 +225,45 @@ func applyDBMap(cfg *Config, data map[string]string) {
 			cfg.APIKey.Token = v
 		case "api_key.name":
 			cfg.APIKey.Name = v
+
+		default:
+			// notify.route.<event>: the channels an event type goes to,
+			// "none" to send it nowhere, empty for every channel
+			if event, ok := strings.CutPrefix(k, "notify.route."); ok && event != "" && v != "" {
+				if cfg.Notify.Routes == nil {
+					cfg.Notify.Routes = make(map[string][]string)
+				}
+				channels := []string{}
+				if v != "none" {
+					channels = splitList(v)
+				}
+				cfg.Notify.Routes[event] = channels
+			}
 		}
 	}
 }
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// parseFloat sets *dst from a non-negative number, leaving it unchanged if v is invalid
+func parseFloat(v string, dst *float64) {
+	if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+		*dst = f
+	}
+}
+
+// parseInt sets *dst from a non-negative integer, leaving it unchanged if v is invalid
+func parseInt(v string, dst *int) {
+	if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+		*dst = n
+	}
+}
@@ -0,0 +1,248 @@
+// Package connect serves the admin API over the Connect RPC protocol
+// (connectrpc.com), for clients generated from admin.proto with buf. Each
+// procedure is a REST endpoint: a call is turned into that endpoint's
+// request (path parameters from the request message, other fields as the
+// query or JSON body), answered by the same handler with the same auth,
+// and its response turned back into a Connect response.
+//
+// Only unary calls with the JSON codec are served: clients are generated
+// with the JSON codec on (connect-go's WithProtoJSON, connect-es's
+// useBinaryFormat: false). The binary protobuf codec and gRPC need a
+// protobuf runtime and answer 415.
+package connect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+)
+
+// PathPrefix is where procedures are served: clients use
+// https://admin.<domain>/api/connect as their base URL
+const PathPrefix = "/api/connect/"
+
+// ProtoPath serves admin.proto, for generating clients
+const ProtoPath = PathPrefix + "admin.proto"
+
+// maxRequestBytes bounds a request message
+const maxRequestBytes = 1 << 20
+
+// Handler answers Connect calls with the REST endpoints behind them
+type Handler struct {
+	services []Service
+	target   http.Handler
+	byPath   map[string]Procedure
+}
+
+// NewHandler returns a handler for services, answering each call by
+// sending its REST request to target
+func NewHandler(services []Service, target http.Handler) *Handler {
+	h := &Handler{services: services, target: target, byPath: make(map[string]Procedure)}
+	for _, s := range services {
+		for _, p := range s.Procedures {
+			h.byPath[PathPrefix+Package+"."+s.Name+"/"+p.Name] = p
+		}
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ProtoPath && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `inline; filename="admin.proto"`)
+		io.WriteString(w, Proto(h.services))
+		return
+	}
+
+	p, ok := h.byPath[r.URL.Path]
+	if !ok {
+		writeError(w, CodeUnimplemented, "unknown procedure "+strings.TrimPrefix(r.URL.Path, PathPrefix), "")
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		// Binary protobuf, gRPC and gRPC-Web aren't served
+		w.Header().Set("Accept-Post", "application/json")
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := r.Context()
+	if ms := r.Header.Get("Connect-Timeout-Ms"); ms != "" {
+		n, err := strconv.ParseInt(ms, 10, 64)
+		if err != nil || n <= 0 {
+			writeError(w, CodeInvalidArgument, "invalid Connect-Timeout-Ms", "")
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(n)*time.Millisecond)
+		defer cancel()
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes+1))
+	if err != nil {
+		writeError(w, CodeInvalidArgument, "failed to read the request", "")
+		return
+	}
+	if len(body) > maxRequestBytes {
+		writeError(w, CodeResourceExhausted, "request message is larger than 1MB", "")
+		return
+	}
+	fields := map[string]json.RawMessage{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			writeError(w, CodeInvalidArgument, "request message must be a JSON object", "")
+			return
+		}
+	}
+
+	rest, err := p.request(r.WithContext(ctx), fields)
+	if err != nil {
+		writeError(w, CodeInvalidArgument, err.Error(), "")
+		return
+	}
+	rec := httptest.NewRecorder()
+	h.target.ServeHTTP(rec, rest)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		writeError(w, CodeDeadlineExceeded, "the call timed out", "")
+		return
+	}
+	reply(w, rec)
+}
+
+// request builds the REST request a call stands for
+func (p Procedure) request(r *http.Request, fields map[string]json.RawMessage) (*http.Request, error) {
+	method, path := p.route()
+
+	// Path parameters: {id} or {path...}
+	var escaped, unescaped strings.Builder
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			escaped.WriteString(path)
+			unescaped.WriteString(path)
+			break
+		}
+		end := strings.IndexByte(path[start:], '}') + start
+		escaped.WriteString(path[:start])
+		unescaped.WriteString(path[:start])
+		wildcard := path[start+1 : end]
+		name := strings.TrimSuffix(wildcard, "...")
+		var value string
+		if raw, ok := fields[name]; !ok || json.Unmarshal(raw, &value) != nil || value == "" {
+			return nil, fmt.Errorf("%s is required", name)
+		}
+		delete(fields, name)
+		if wildcard != name {
+			// {path...} spans segments
+			segments := strings.Split(value, "/")
+			for i, s := range segments {
+				segments[i] = url.PathEscape(s)
+			}
+			escaped.WriteString(strings.Join(segments, "/"))
+		} else {
+			escaped.WriteString(url.PathEscape(value))
+		}
+		unescaped.WriteString(value)
+		path = path[end+1:]
+	}
+
+	// The rest are the query or the body, under their REST names
+	params := make(map[string]json.RawMessage, len(fields))
+	for name, raw := range fields {
+		params[p.param(name)] = raw
+	}
+	query := url.Values{}
+	var body []byte
+	if method == http.MethodGet || method == http.MethodDelete {
+		for name, raw := range params {
+			var v interface{}
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			// Zero values are proto3's unset fields, left out of the query
+			switch v := v.(type) {
+			case nil:
+			case string:
+				if v != "" {
+					query.Set(name, v)
+				}
+			case bool, float64:
+				if v != false && v != 0.0 {
+					query.Set(name, string(raw))
+				}
+			default:
+				return nil, fmt.Errorf("%s must be a string, number or boolean", name)
+			}
+		}
+	} else {
+		body, _ = json.Marshal(params)
+	}
+
+	rest := r.Clone(r.Context())
+	rest.Method = method
+	rest.URL = &url.URL{Path: unescaped.String(), RawPath: escaped.String(), RawQuery: query.Encode()}
+	rest.RequestURI = ""
+	rest.Body = io.NopCloser(bytes.NewReader(body))
+	rest.ContentLength = int64(len(body))
+	for name := range rest.Header {
+		if strings.HasPrefix(name, "Connect-") {
+			rest.Header.Del(name)
+		}
+	}
+	rest.Header.Del("Content-Length")
+	rest.Header.Set("Content-Type", "application/json")
+	return rest, nil
+}
+
+// reply turns a REST response into a Connect one: the data of a success,
+// with a list's next cursor in the Fazt-Next-Cursor header, or an error with
+// the endpoint's error code in the Fazt-Error-Code header
+func reply(w http.ResponseWriter, rec *httptest.ResponseRecorder) {
+	if rec.Code >= 200 && rec.Code < 300 {
+		var env struct {
+			Data json.RawMessage `json:"data"`
+			Meta struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+			writeError(w, CodeInternal, "the endpoint didn't answer with JSON", "")
+			return
+		}
+		data := env.Data
+		if len(data) == 0 {
+			data = json.RawMessage("null")
+		}
+		if env.Meta.NextCursor != "" {
+			w.Header().Set("Fazt-Next-Cursor", env.Meta.NextCursor)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+		return
+	}
+
+	var env api.ErrorEnvelope
+	message := http.StatusText(rec.Code)
+	if json.Unmarshal(rec.Body.Bytes(), &env) == nil && env.Error.Message != "" {
+		message = env.Error.Message
+	}
+	writeError(w, codeForStatus(rec.Code), message, env.Error.Code)
+}
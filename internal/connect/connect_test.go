@@ -0,0 +1,174 @@
+package connect
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/api"
+)
+
+// checkedInProto is the admin.proto clients are generated from
+const checkedInProto = "../../packages/fazt-proto/fazt/admin/v1/admin.proto"
+
+// seen is a REST request the test mux answered
+type seen struct {
+	method, path, query, body, auth string
+}
+
+func newTestHandler(t *testing.T) (*Handler, *seen) {
+	t.Helper()
+	var got seen
+	record := func(r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = seen{r.Method, r.URL.Path, r.URL.RawQuery, string(body), r.Header.Get("Authorization")}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		if r.PathValue("id") == "missing" {
+			api.NotFound(w, "APP_NOT_FOUND", "App not found")
+			return
+		}
+		api.Success(w, http.StatusOK, map[string]string{"id": r.PathValue("id")})
+	})
+	mux.HandleFunc("DELETE /api/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		api.Success(w, http.StatusOK, nil)
+	})
+	mux.HandleFunc("PUT /api/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		api.Success(w, http.StatusOK, map[string]bool{"updated": true})
+	})
+	mux.HandleFunc("GET /api/apps/{id}/jobs", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		api.Success(w, http.StatusOK, []string{})
+	})
+	mux.HandleFunc("GET /api/apps", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		api.SuccessWithMeta(w, http.StatusOK, []string{"blog"}, api.ListMeta{Limit: 1, HasMore: true, NextCursor: "abc"})
+	})
+	return NewHandler(Admin, mux), &got
+}
+
+func call(h http.Handler, procedure, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, PathPrefix+Package+"."+procedure, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	req.Header.Set("Authorization", "Bearer key")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandlerCallsEndpoints(t *testing.T) {
+	h, got := newTestHandler(t)
+
+	rec := call(h, "AppService/GetApp", `{"id":"my app"}`)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"id":"my app"}` {
+		t.Fatalf("GetApp: %d %s", rec.Code, rec.Body)
+	}
+	if got.method != http.MethodGet || got.path != "/api/apps/my app" || got.auth != "Bearer key" {
+		t.Errorf("Unexpected REST request: %+v", got)
+	}
+
+	// Fields go in the query under their REST names
+	rec = call(h, "AppService/DeleteApp", `{"id":"blog","with_forks":true}`)
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "null" {
+		t.Fatalf("DeleteApp: %d %s", rec.Code, rec.Body)
+	}
+	if got.method != http.MethodDelete || got.query != "with-forks=true" {
+		t.Errorf("Unexpected REST request: %+v", got)
+	}
+	call(h, "JobService/ListJobs", `{"id":"blog","status":"failed","limit":5}`)
+	if got.query != "limit=5&status=failed" {
+		t.Errorf("Unexpected query: %q", got.query)
+	}
+
+	// A list's next cursor is a header
+	rec = call(h, "AppService/ListApps", `{"limit":1,"cursor":"","all":false}`)
+	if strings.TrimSpace(rec.Body.String()) != `["blog"]` || rec.Header().Get("Fazt-Next-Cursor") != "abc" || got.query != "limit=1" {
+		t.Errorf("ListApps: %s %v %q", rec.Body, rec.Header(), got.query)
+	}
+
+	// ... or the JSON body
+	call(h, "AppService/UpdateApp", `{"id":"blog","title":"Blog","tags":["a"]}`)
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(got.body), &body); err != nil || body["title"] != "Blog" || len(body) != 2 {
+		t.Errorf("Unexpected REST body: %q", got.body)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	for _, tc := range []struct {
+		procedure, body string
+		status          int
+		code, faztCode  string
+	}{
+		{"AppService/GetApp", `{"id":"missing"}`, http.StatusNotFound, CodeNotFound, "APP_NOT_FOUND"},
+		{"AppService/GetApp", `{}`, http.StatusBadRequest, CodeInvalidArgument, ""},
+		{"AppService/GetApp", `[1]`, http.StatusBadRequest, CodeInvalidArgument, ""},
+		{"AppService/Nope", `{}`, http.StatusNotImplemented, CodeUnimplemented, ""},
+		{"AppService/DeleteApp", `{"id":"blog","with_forks":{}}`, http.StatusBadRequest, CodeInvalidArgument, ""},
+	} {
+		rec := call(h, tc.procedure, tc.body)
+		var e struct{ Code, Message string }
+		json.Unmarshal(rec.Body.Bytes(), &e)
+		if rec.Code != tc.status || e.Code != tc.code || e.Message == "" || rec.Header().Get("Fazt-Error-Code") != tc.faztCode {
+			t.Errorf("%s %s: %d %s (%s)", tc.procedure, tc.body, rec.Code, rec.Body, rec.Header().Get("Fazt-Error-Code"))
+		}
+	}
+
+	// Binary protobuf isn't served
+	req := httptest.NewRequest(http.MethodPost, PathPrefix+Package+".AppService/GetApp", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/proto")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Accept-Post") != "application/json" {
+		t.Errorf("Expected 415 for application/proto, got %d", rec.Code)
+	}
+}
+
+func TestAdminRoutes(t *testing.T) {
+	names := map[string]bool{}
+	for _, s := range Admin {
+		for _, p := range s.Procedures {
+			if names[p.Name] {
+				t.Errorf("%s is declared twice", p.Name)
+			}
+			names[p.Name] = true
+			method, path := p.route()
+			if method == "" || !strings.HasPrefix(path, "/api/") {
+				t.Errorf("%s: bad route %q", p.Name, p.Route)
+			}
+		}
+	}
+}
+
+// TestProtoCheckedIn keeps packages/fazt-proto in step with Admin. Run with
+// FAZT_UPDATE_PROTO=1 to rewrite it.
+func TestProtoCheckedIn(t *testing.T) {
+	proto := Proto(Admin)
+	if os.Getenv("FAZT_UPDATE_PROTO") != "" {
+		if err := os.WriteFile(checkedInProto, []byte(proto), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(checkedInProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != proto {
+		t.Errorf("%s is out of date; run FAZT_UPDATE_PROTO=1 go test ./internal/connect", checkedInProto)
+	}
+	if !strings.Contains(proto, "rpc GetApp(GetAppRequest) returns (google.protobuf.Value);") ||
+		!strings.Contains(proto, `bool with_forks = 2 [json_name = "with_forks"];`) {
+		t.Errorf("Unexpected proto:\n%s", proto)
+	}
+}
@@ -0,0 +1,81 @@
+package connect
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Connect error codes (connectrpc.com/docs/protocol#error-codes)
+const (
+	CodeCanceled           = "canceled"
+	CodeUnknown            = "unknown"
+	CodeInvalidArgument    = "invalid_argument"
+	CodeDeadlineExceeded   = "deadline_exceeded"
+	CodeNotFound           = "not_found"
+	CodeAlreadyExists      = "already_exists"
+	CodePermissionDenied   = "permission_denied"
+	CodeResourceExhausted  = "resource_exhausted"
+	CodeFailedPrecondition = "failed_precondition"
+	CodeUnimplemented      = "unimplemented"
+	CodeInternal           = "internal"
+	CodeUnavailable        = "unavailable"
+	CodeUnauthenticated    = "unauthenticated"
+)
+
+// statusForCode is the HTTP status the protocol gives each code
+var statusForCode = map[string]int{
+	CodeCanceled:           499,
+	CodeUnknown:            http.StatusInternalServerError,
+	CodeInvalidArgument:    http.StatusBadRequest,
+	CodeDeadlineExceeded:   http.StatusGatewayTimeout,
+	CodeNotFound:           http.StatusNotFound,
+	CodeAlreadyExists:      http.StatusConflict,
+	CodePermissionDenied:   http.StatusForbidden,
+	CodeResourceExhausted:  http.StatusTooManyRequests,
+	CodeFailedPrecondition: http.StatusBadRequest,
+	CodeUnimplemented:      http.StatusNotImplemented,
+	CodeInternal:           http.StatusInternalServerError,
+	CodeUnavailable:        http.StatusServiceUnavailable,
+	CodeUnauthenticated:    http.StatusUnauthorized,
+}
+
+// codeForStatus maps a REST endpoint's status to a Connect code
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return CodeInvalidArgument
+	case http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case http.StatusForbidden:
+		return CodePermissionDenied
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeAlreadyExists
+	case http.StatusPreconditionFailed, http.StatusLocked:
+		return CodeFailedPrecondition
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return CodeResourceExhausted
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return CodeUnimplemented
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeDeadlineExceeded
+	case http.StatusInternalServerError:
+		return CodeInternal
+	}
+	return CodeUnknown
+}
+
+// writeError writes a Connect error. faztCode, the REST endpoint's error
+// code (e.g. APP_NOT_FOUND), goes in the Fazt-Error-Code header, which
+// clients see as error metadata.
+func writeError(w http.ResponseWriter, code, message, faztCode string) {
+	if faztCode != "" {
+		w.Header().Set("Fazt-Error-Code", faztCode)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusForCode[code])
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "message": message})
+}
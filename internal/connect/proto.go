@@ -0,0 +1,54 @@
+package connect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Proto renders services as admin.proto. Responses are
+// google.protobuf.Value: each is its REST endpoint's data, whatever its
+// shape, so clients get it as JSON.
+func Proto(services []Service) string {
+	var b strings.Builder
+	b.WriteString("// Code generated by fazt from internal/connect. DO NOT EDIT.\n")
+	b.WriteString("//\n")
+	b.WriteString("// The fazt admin API over Connect. Clients must use the JSON codec.\n\n")
+	b.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(&b, "package %s;\n\n", Package)
+	b.WriteString("import \"google/protobuf/struct.proto\";\n")
+
+	for _, s := range services {
+		fmt.Fprintf(&b, "\n// %s\n", s.Doc)
+		fmt.Fprintf(&b, "service %s {\n", s.Name)
+		for i, p := range s.Procedures {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "  // %s (%s)\n", p.Doc, p.Route)
+			fmt.Fprintf(&b, "  rpc %s(%sRequest) returns (google.protobuf.Value);\n", p.Name, p.Name)
+		}
+		b.WriteString("}\n")
+	}
+
+	for _, s := range services {
+		for _, p := range s.Procedures {
+			params := p.pathParams()
+			if len(params) == 0 && len(p.Fields) == 0 {
+				fmt.Fprintf(&b, "\nmessage %sRequest {}\n", p.Name)
+				continue
+			}
+			fmt.Fprintf(&b, "\nmessage %sRequest {\n", p.Name)
+			n := 1
+			for _, name := range params {
+				fmt.Fprintf(&b, "  string %s = %d [json_name = %q];\n", name, n, name)
+				n++
+			}
+			for _, f := range p.Fields {
+				fmt.Fprintf(&b, "  %s %s = %d [json_name = %q];\n", f.Type, f.Name, n, f.Name)
+				n++
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
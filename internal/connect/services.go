@@ -0,0 +1,142 @@
+package connect
+
+import "strings"
+
+// Package is the protobuf package of the admin services
+const Package = "fazt.admin.v1"
+
+// Service is a group of procedures, a service in admin.proto
+type Service struct {
+	Name       string // e.g. AppService
+	Doc        string
+	Procedures []Procedure
+}
+
+// Procedure is an RPC answered by a REST endpoint. Its request message has
+// the route's path parameters followed by Fields; its response is the
+// endpoint's data, any JSON value.
+type Procedure struct {
+	Name   string // e.g. ListApps
+	Route  string // e.g. "GET /api/apps/{id}/jobs", as registered on the mux
+	Doc    string
+	Fields []Field
+}
+
+// Field is a request field besides the path parameters
+type Field struct {
+	Name  string // snake_case, e.g. with_forks
+	Type  string // Protobuf type, e.g. "string", "optional int32", "repeated string"
+	Param string // Query or body name when it isn't Name, e.g. "with-forks"
+}
+
+func (p Procedure) route() (method, path string) {
+	method, path, _ = strings.Cut(p.Route, " ")
+	return method, path
+}
+
+// param returns a field's name in the REST request
+func (p Procedure) param(name string) string {
+	for _, f := range p.Fields {
+		if f.Name == name && f.Param != "" {
+			return f.Param
+		}
+	}
+	return name
+}
+
+// pathParams returns the names of the route's path parameters, in order
+func (p Procedure) pathParams() []string {
+	_, path := p.route()
+	var names []string
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			return names
+		}
+		end := strings.IndexByte(path[start:], '}') + start
+		names = append(names, strings.TrimSuffix(path[start+1:end], "..."))
+		path = path[end+1:]
+	}
+}
+
+// Admin is the admin API offered over Connect. Procedures are added here
+// as their REST endpoints settle; admin.proto is generated from it.
+var Admin = []Service{
+	{
+		Name: "AppService",
+		Doc:  "Apps, their status and the email they sent",
+		Procedures: []Procedure{
+			{Name: "ListApps", Route: "GET /api/apps", Doc: "Lists a page of apps; all includes hidden ones. The next page's cursor is in the Fazt-Next-Cursor header",
+				Fields: []Field{
+					{Name: "all", Type: "bool"},
+					{Name: "limit", Type: "int32"},
+					{Name: "cursor", Type: "string"},
+					{Name: "sort", Type: "string"},
+					{Name: "fields", Type: "string"},
+				}},
+			{Name: "GetApp", Route: "GET /api/apps/{id}", Doc: "Returns an app's details"},
+			{Name: "GetAppStatus", Route: "GET /api/apps/{id}/status", Doc: "Returns whether an app is serving, with its recent errors"},
+			{Name: "UpdateApp", Route: "PUT /api/apps/{id}", Doc: "Changes an app's details; fields left out keep their values",
+				Fields: []Field{
+					{Name: "title", Type: "optional string"},
+					{Name: "description", Type: "optional string"},
+					{Name: "tags", Type: "repeated string"},
+					{Name: "visibility", Type: "optional string"},
+				}},
+			{Name: "DeleteApp", Route: "DELETE /api/apps/{id}", Doc: "Removes an app, and its forks with with_forks",
+				Fields: []Field{{Name: "with_forks", Type: "bool", Param: "with-forks"}}},
+			{Name: "ListForks", Route: "GET /api/apps/{id}/forks", Doc: "Lists an app's forks"},
+			{Name: "ListMail", Route: "GET /api/apps/{id}/mail", Doc: "Lists the email an app sent, newest first, with its mail settings",
+				Fields: []Field{{Name: "status", Type: "string"}, {Name: "limit", Type: "int32"}}},
+			{Name: "GetMail", Route: "GET /api/apps/{id}/mail/{mail}", Doc: "Returns a sent email with its body"},
+		},
+	},
+	{
+		Name: "JobService",
+		Doc:  "Background jobs, services and the dead letter",
+		Procedures: []Procedure{
+			{Name: "ListJobs", Route: "GET /api/apps/{id}/jobs", Doc: "Lists an app's jobs, newest first",
+				Fields: []Field{{Name: "status", Type: "string"}, {Name: "limit", Type: "int32"}}},
+			{Name: "GetJob", Route: "GET /api/apps/{id}/jobs/{job}", Doc: "Returns a job with its logs and checkpoint"},
+			{Name: "CancelJob", Route: "POST /api/apps/{id}/jobs/{job}/cancel", Doc: "Stops a pending or running job"},
+			{Name: "ListServices", Route: "GET /api/apps/{id}/services", Doc: "Lists the services an app declares, with their state"},
+			{Name: "RestartService", Route: "POST /api/apps/{id}/services/{name}/restart", Doc: "Restarts one of an app's services"},
+			{Name: "ListDeadJobs", Route: "GET /api/system/jobs/dead", Doc: "Lists jobs that failed their last attempt",
+				Fields: []Field{{Name: "app", Type: "string"}, {Name: "limit", Type: "int32"}}},
+			{Name: "RetryDeadJob", Route: "POST /api/system/jobs/dead/{id}/retry", Doc: "Runs a dead-lettered job again"},
+		},
+	},
+	{
+		Name: "AliasService",
+		Doc:  "Subdomains and the apps they serve",
+		Procedures: []Procedure{
+			{Name: "ListAliases", Route: "GET /api/aliases", Doc: "Lists aliases"},
+			{Name: "GetAlias", Route: "GET /api/aliases/{subdomain}", Doc: "Returns an alias with its targets"},
+			{Name: "DeleteAlias", Route: "DELETE /api/aliases/{subdomain}", Doc: "Removes an alias"},
+			{Name: "SwapAliases", Route: "POST /api/aliases/swap", Doc: "Swaps the apps two aliases serve",
+				Fields: []Field{{Name: "alias1", Type: "string"}, {Name: "alias2", Type: "string"}}},
+		},
+	},
+	{
+		Name: "SystemService",
+		Doc:  "The server's health, capacity and limits",
+		Procedures: []Procedure{
+			{Name: "GetHealth", Route: "GET /api/system/health", Doc: "Returns the server's health"},
+			{Name: "GetCapacity", Route: "GET /api/system/capacity", Doc: "Returns the server's capacity and how much is used"},
+			{Name: "GetLimits", Route: "GET /api/system/limits", Doc: "Returns the server's resource limits"},
+			{Name: "GetStorage", Route: "GET /api/system/storage", Doc: "Returns storage use by app"},
+			{Name: "GetMaintenance", Route: "GET /api/system/maintenance", Doc: "Returns the state of maintenance tasks"},
+		},
+	},
+	{
+		Name: "MonitorService",
+		Doc:  "Uptime checks, heartbeats, incidents and SLOs",
+		Procedures: []Procedure{
+			{Name: "ListPings", Route: "GET /api/pings", Doc: "Lists uptime checks with their last results"},
+			{Name: "RunPing", Route: "POST /api/pings/{name}/run", Doc: "Runs an uptime check now"},
+			{Name: "ListHeartbeats", Route: "GET /api/heartbeats", Doc: "Lists heartbeats with when they were last seen"},
+			{Name: "ListIncidents", Route: "GET /api/status/incidents", Doc: "Lists status page incidents"},
+			{Name: "ListSLOs", Route: "GET /api/slos", Doc: "Lists SLOs with their error budgets"},
+		},
+	},
+}
@@ -0,0 +1,288 @@
+// Package crashloop isolates serverless apps whose handlers keep crashing.
+// A crash is a recovered panic or an uncaught error that fails the request.
+// After Threshold crashes within Window the app is tripped: its API routes
+// get a static fallback or a 503 for Cooldown, an incident opens on the
+// status page and the admin is notified. Once the cooldown passes, requests
+// are let through as probes; a success restores the app and resolves the
+// incident, another crash trips it again straight away.
+package crashloop
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notifier"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+const (
+	Threshold = 10              // Crashes within Window that trip an app
+	Window    = 5 * time.Minute // Sliding window crashes are counted over
+	Cooldown  = 5 * time.Minute // How long a tripped app is refused before a probe
+)
+
+// ErrNotTripped is returned when resetting an app that isn't tripped
+var ErrNotTripped = errors.New("app is not tripped")
+
+// State is an app's crash record
+type State struct {
+	App        string `json:"app"`
+	Crashes    int    `json:"crashes"` // Within Window, or the count that tripped it
+	Tripped    bool   `json:"tripped"`
+	LastError  string `json:"last_error,omitempty"`
+	IncidentID int64  `json:"incident_id,omitempty"`
+	TrippedAt  int64  `json:"tripped_at,omitempty"`
+	RetryAt    int64  `json:"retry_at,omitempty"`
+}
+
+type appState struct {
+	crashes    []time.Time
+	tripped    bool
+	count      int // crashes that tripped it
+	lastError  string
+	incidentID int64
+	trippedAt  time.Time
+	retryAt    time.Time
+}
+
+var guard = struct {
+	sync.Mutex
+	db   *sql.DB
+	apps map[string]*appState
+}{apps: make(map[string]*appState)}
+
+// notify sends an admin notification without holding up the request that
+// tripped the app
+var notify = func(title, message string) {
+	go func() {
+		if err := notifier.Send(title, message, notifier.NotificationError); err != nil {
+			log.Printf("Crashloop: failed to send notification: %v", err)
+		}
+	}()
+}
+
+// Init restores tripped apps from the database. Without it crashes are
+// still tracked, but nothing is persisted or posted to the status page.
+func Init(db *sql.DB) error {
+	rows, err := db.Query(`SELECT app, crashes, COALESCE(last_error, ''), COALESCE(incident_id, 0), tripped_at, retry_at FROM crash_loops`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	guard.Lock()
+	defer guard.Unlock()
+	guard.db = db
+	for rows.Next() {
+		var app string
+		var trippedAt, retryAt int64
+		s := &appState{tripped: true}
+		if err := rows.Scan(&app, &s.count, &s.lastError, &s.incidentID, &trippedAt, &retryAt); err != nil {
+			return err
+		}
+		s.trippedAt = time.Unix(trippedAt, 0)
+		s.retryAt = time.Unix(retryAt, 0)
+		guard.apps[app] = s
+	}
+	return rows.Err()
+}
+
+// Allow reports whether a request may run the app's handler. While the app
+// is tripped it returns false and how long until the next probe.
+func Allow(app string, now time.Time) (bool, time.Duration) {
+	guard.Lock()
+	defer guard.Unlock()
+	s, ok := guard.apps[app]
+	if !ok || !s.tripped || !now.Before(s.retryAt) {
+		return true, 0
+	}
+	return false, s.retryAt.Sub(now)
+}
+
+// IsTripped reports whether an app is currently isolated
+func IsTripped(app string) bool {
+	guard.Lock()
+	defer guard.Unlock()
+	s, ok := guard.apps[app]
+	return ok && s.tripped
+}
+
+// RecordCrash counts a crash, tripping the app when it crosses Threshold
+// within Window or when a probe after the cooldown crashes
+func RecordCrash(app, reason string, now time.Time) {
+	guard.Lock()
+	s, ok := guard.apps[app]
+	if !ok {
+		s = &appState{}
+		guard.apps[app] = s
+	}
+	s.lastError = reason
+
+	if s.tripped {
+		// A probe crashed: back into cooldown
+		s.retryAt = now.Add(Cooldown)
+		db, incidentID, retryAt := guard.db, s.incidentID, s.retryAt
+		guard.Unlock()
+		if db != nil {
+			if _, err := db.Exec(`UPDATE crash_loops SET last_error = ?, retry_at = ? WHERE app = ?`, reason, retryAt.Unix(), app); err != nil {
+				log.Printf("Crashloop: failed to update %s: %v", app, err)
+			}
+			if incidentID != 0 {
+				if _, err := status.UpdateIncident(db, incidentID, "", fmt.Sprintf("Still crashing after cooldown: %s", reason)); err != nil {
+					log.Printf("Crashloop: failed to update incident for %s: %v", app, err)
+				}
+			}
+		}
+		return
+	}
+
+	cutoff := now.Add(-Window)
+	kept := s.crashes[:0]
+	for _, t := range s.crashes {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	s.crashes = append(kept, now)
+	if len(s.crashes) < Threshold {
+		guard.Unlock()
+		return
+	}
+
+	s.tripped = true
+	s.count = len(s.crashes)
+	s.crashes = nil
+	s.trippedAt = now
+	s.retryAt = now.Add(Cooldown)
+	count, db := s.count, guard.db
+	guard.Unlock()
+
+	log.Printf("Crashloop: %s tripped after %d crashes in %s: %s", app, count, Window, reason)
+	notify("App crash-looping", fmt.Sprintf("%s crashed %d times in %s and is isolated: %s", app, count, Window, reason))
+	if db == nil {
+		return
+	}
+
+	var incidentID int64
+	inc, err := status.CreateIncident(db, status.IncidentConfig{
+		Title:    fmt.Sprintf("%s API unavailable", app),
+		Severity: status.SeverityMajor,
+		Sites:    []string{app},
+		Message:  fmt.Sprintf("The API handler crashed %d times in %s and has been isolated. Last error: %s", count, Window, reason),
+	}, now)
+	if err != nil {
+		log.Printf("Crashloop: failed to open incident for %s: %v", app, err)
+	} else {
+		incidentID = inc.ID
+	}
+
+	guard.Lock()
+	s.incidentID = incidentID
+	guard.Unlock()
+
+	if _, err := db.Exec(`
+		INSERT INTO crash_loops (app, crashes, last_error, incident_id, tripped_at, retry_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app) DO UPDATE SET crashes = excluded.crashes, last_error = excluded.last_error,
+			incident_id = excluded.incident_id, tripped_at = excluded.tripped_at, retry_at = excluded.retry_at
+	`, app, count, reason, nullID(incidentID), now.Unix(), now.Add(Cooldown).Unix()); err != nil {
+		log.Printf("Crashloop: failed to record %s: %v", app, err)
+	}
+}
+
+// RecordSuccess restores a tripped app once a probe request succeeds
+func RecordSuccess(app string) {
+	guard.Lock()
+	s, ok := guard.apps[app]
+	if !ok || !s.tripped {
+		guard.Unlock()
+		return
+	}
+	guard.Unlock()
+
+	if restore(app, "The API handler is responding again.") {
+		notify("App recovered", fmt.Sprintf("%s is handling requests again", app))
+	}
+}
+
+// Reset restores a tripped app by hand, ahead of its cooldown
+func Reset(app string) error {
+	if !IsTripped(app) {
+		return ErrNotTripped
+	}
+	restore(app, "Restored by an administrator.")
+	return nil
+}
+
+// restore clears an app's state and resolves its incident, reporting
+// whether this call did it (concurrent probes may race to restore)
+func restore(app, message string) bool {
+	guard.Lock()
+	s, ok := guard.apps[app]
+	if !ok || !s.tripped {
+		guard.Unlock()
+		return false
+	}
+	delete(guard.apps, app)
+	db, incidentID := guard.db, s.incidentID
+	guard.Unlock()
+
+	if db != nil {
+		if _, err := db.Exec(`DELETE FROM crash_loops WHERE app = ?`, app); err != nil {
+			log.Printf("Crashloop: failed to clear %s: %v", app, err)
+		}
+		if incidentID != 0 {
+			if _, err := status.UpdateIncident(db, incidentID, status.IncidentResolved, message); err != nil {
+				log.Printf("Crashloop: failed to resolve incident for %s: %v", app, err)
+			}
+		}
+	}
+	return true
+}
+
+// List returns the apps with recent crashes, tripped apps first
+func List(now time.Time) []State {
+	guard.Lock()
+	defer guard.Unlock()
+
+	cutoff := now.Add(-Window)
+	var states []State
+	for app, s := range guard.apps {
+		st := State{App: app, Tripped: s.tripped, LastError: s.lastError}
+		if s.tripped {
+			st.Crashes = s.count
+			st.IncidentID = s.incidentID
+			st.TrippedAt = s.trippedAt.Unix()
+			st.RetryAt = s.retryAt.Unix()
+		} else {
+			for _, t := range s.crashes {
+				if t.After(cutoff) {
+					st.Crashes++
+				}
+			}
+			if st.Crashes == 0 {
+				continue
+			}
+		}
+		states = append(states, st)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Tripped != states[j].Tripped {
+			return states[i].Tripped
+		}
+		return states[i].App < states[j].App
+	})
+	return states
+}
+
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,171 @@
+package crashloop
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+// setupTestDB resets package state and captures notifications
+func setupTestDB(t *testing.T) (*sql.DB, *[]string) {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	var mu sync.Mutex
+	var sent []string
+	oldNotify := notify
+	notify = func(title, message string) {
+		mu.Lock()
+		sent = append(sent, title)
+		mu.Unlock()
+	}
+
+	reset := func() {
+		guard.Lock()
+		guard.db = nil
+		guard.apps = make(map[string]*appState)
+		guard.Unlock()
+	}
+	reset()
+	t.Cleanup(func() {
+		notify = oldNotify
+		reset()
+	})
+
+	if err := Init(db); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	return db, &sent
+}
+
+func crash(app string, n int, at time.Time) {
+	for i := 0; i < n; i++ {
+		RecordCrash(app, "TypeError: boom", at)
+	}
+}
+
+func TestTripsAfterThreshold(t *testing.T) {
+	db, sent := setupTestDB(t)
+	now := time.Unix(1_700_000_000, 0)
+
+	crash("app_blog", Threshold-1, now)
+	if ok, _ := Allow("app_blog", now); !ok {
+		t.Fatal("Expected app below threshold to be allowed")
+	}
+
+	crash("app_blog", 1, now)
+	ok, retry := Allow("app_blog", now)
+	if ok || retry != Cooldown {
+		t.Fatalf("Expected tripped app refused for %s, got ok=%v retry=%s", Cooldown, ok, retry)
+	}
+	if len(*sent) != 1 || (*sent)[0] != "App crash-looping" {
+		t.Errorf("Expected one crash-loop notification, got %v", *sent)
+	}
+
+	states := List(now)
+	if len(states) != 1 || !states[0].Tripped || states[0].Crashes != Threshold || states[0].IncidentID == 0 {
+		t.Fatalf("Expected tripped state with incident, got %+v", states)
+	}
+	inc, err := status.GetIncident(db, states[0].IncidentID)
+	if err != nil {
+		t.Fatalf("GetIncident failed: %v", err)
+	}
+	if !inc.IsOpen() || !inc.Affects("app_blog") || inc.Severity != status.SeverityMajor {
+		t.Errorf("Expected open major incident for app_blog, got %+v", inc)
+	}
+}
+
+func TestCrashesOutsideWindowExpire(t *testing.T) {
+	setupTestDB(t)
+	now := time.Unix(1_700_000_000, 0)
+
+	crash("app_blog", Threshold-1, now)
+	crash("app_blog", 1, now.Add(Window+time.Second))
+	if ok, _ := Allow("app_blog", now.Add(Window+time.Second)); !ok {
+		t.Error("Expected crashes outside the window not to count")
+	}
+	if states := List(now.Add(Window + time.Second)); len(states) != 1 || states[0].Crashes != 1 {
+		t.Errorf("Expected 1 recent crash, got %+v", states)
+	}
+}
+
+func TestProbeRecovery(t *testing.T) {
+	db, sent := setupTestDB(t)
+	now := time.Unix(1_700_000_000, 0)
+	crash("app_blog", Threshold, now)
+	incidentID := List(now)[0].IncidentID
+
+	// A crashing probe goes straight back into cooldown
+	probe := now.Add(Cooldown)
+	if ok, _ := Allow("app_blog", probe); !ok {
+		t.Fatal("Expected a probe after the cooldown")
+	}
+	RecordCrash("app_blog", "TypeError: still", probe)
+	if ok, retry := Allow("app_blog", probe); ok || retry != Cooldown {
+		t.Fatalf("Expected re-trip for %s, got ok=%v retry=%s", Cooldown, ok, retry)
+	}
+
+	// A successful probe restores the app and resolves the incident
+	probe = probe.Add(Cooldown)
+	RecordSuccess("app_blog")
+	if IsTripped("app_blog") || len(List(probe)) != 0 {
+		t.Error("Expected app restored")
+	}
+	inc, err := status.GetIncident(db, incidentID)
+	if err != nil {
+		t.Fatalf("GetIncident failed: %v", err)
+	}
+	if inc.Status != status.IncidentResolved {
+		t.Errorf("Expected incident resolved, got %s", inc.Status)
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM crash_loops`).Scan(&n)
+	if n != 0 {
+		t.Errorf("Expected crash_loops row removed, got %d", n)
+	}
+	if len(*sent) != 2 || (*sent)[1] != "App recovered" {
+		t.Errorf("Expected recovery notification, got %v", *sent)
+	}
+}
+
+func TestInitRestoresTripped(t *testing.T) {
+	db, _ := setupTestDB(t)
+	now := time.Now()
+	crash("app_blog", Threshold, now)
+
+	// Simulate a restart
+	guard.Lock()
+	guard.apps = make(map[string]*appState)
+	guard.Unlock()
+	if err := Init(db); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	if ok, _ := Allow("app_blog", now); ok {
+		t.Error("Expected tripped state to survive a restart")
+	}
+	if states := List(now); len(states) != 1 || states[0].LastError != "TypeError: boom" {
+		t.Errorf("Expected restored state, got %+v", states)
+	}
+}
+
+func TestReset(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+
+	if err := Reset("app_blog"); err != ErrNotTripped {
+		t.Errorf("Expected ErrNotTripped, got %v", err)
+	}
+	crash("app_blog", Threshold, now)
+	if err := Reset("app_blog"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if ok, _ := Allow("app_blog", now); !ok {
+		t.Error("Expected app allowed after reset")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -36,7 +37,9 @@ func Init(dbPath string) error {
 
 	// Open database connection
 	// Use "sqlite" driver (modernc.org/sqlite) instead of "sqlite3" (mattn/go-sqlite3)
-	db, err = sql.Open("sqlite", dbPath)
+	// busy_timeout is a per-connection setting: pass it in the DSN so every
+	// pooled connection waits for the lock, not just the first one
+	db, err = sql.Open("sqlite", withPragmas(dbPath))
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -59,12 +62,6 @@ func Init(dbPath string) error {
 		return fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
 
-	// Set busy timeout to wait for locks instead of failing immediately
-	// Use 2s (shorter than runtime timeout) to allow graceful failure and retry
-	if _, err := db.Exec("PRAGMA busy_timeout=2000"); err != nil {
-		return fmt.Errorf("failed to set busy timeout: %w", err)
-	}
-
 	// Run migrations
 	if err := runMigrations(); err != nil {
 		return fmt.Errorf("failed to run migrations: %w", err)
@@ -76,6 +73,17 @@ func Init(dbPath string) error {
 	return nil
 }
 
+// withPragmas adds per-connection settings to a database path. The busy
+// timeout is 2s (shorter than runtime timeout) to allow graceful failure and
+// retry.
+func withPragmas(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + "_pragma=busy_timeout(2000)"
+}
+
 // runMigrations executes SQL migration files in order
 func runMigrations() error {
 	if db == nil {
@@ -125,6 +133,52 @@ func RunMigrations(target *sql.DB) error {
 		{19, "net_allowlist", "migrations/019_net_allowlist.sql"},
 		{20, "net_secrets", "migrations/020_net_secrets.sql"},
 		{21, "net_log", "migrations/021_net_log.sql"},
+		{22, "alias_transforms", "migrations/022_alias_transforms.sql"},
+		{23, "analytics_inject", "migrations/023_analytics_inject.sql"},
+		{24, "canaries", "migrations/024_canaries.sql"},
+		{25, "analytics_privacy", "migrations/025_analytics_privacy.sql"},
+		{26, "app_members", "migrations/026_app_members.sql"},
+		{27, "api_key_scopes", "migrations/027_api_key_scopes.sql"},
+		{28, "event_devices", "migrations/028_event_devices.sql"},
+		{29, "status_page", "migrations/029_status_page.sql"},
+		{30, "two_factor", "migrations/030_two_factor.sql"},
+		{31, "incident_windows", "migrations/031_incident_windows.sql"},
+		{32, "passkeys", "migrations/032_passkeys.sql"},
+		{33, "app_auth", "migrations/033_app_auth.sql"},
+		{34, "slo", "migrations/034_slo.sql"},
+		{35, "impersonation", "migrations/035_impersonation.sql"},
+		{36, "synthetic_checks", "migrations/036_synthetic_checks.sql"},
+		{37, "app_usage", "migrations/037_app_usage.sql"},
+		{38, "session_metadata", "migrations/038_session_metadata.sql"},
+		{39, "usage_hooks", "migrations/039_usage_hooks.sql"},
+		{40, "app_budgets", "migrations/040_app_budgets.sql"},
+		{41, "crash_loops", "migrations/041_crash_loops.sql"},
+		{42, "runtime_usage", "migrations/042_runtime_usage.sql"},
+		{43, "fork_bases", "migrations/043_fork_bases.sql"},
+		{44, "protected", "migrations/044_protected.sql"},
+		{45, "freeze_windows", "migrations/045_freeze_windows.sql"},
+		{46, "approvals", "migrations/046_approvals.sql"},
+		{47, "dead_letter", "migrations/047_dead_letter.sql"},
+		{48, "session_hosts", "migrations/048_session_hosts.sql"},
+		{49, "security_events", "migrations/049_security_events.sql"},
+		{50, "security_ips", "migrations/050_security_ips.sql"},
+		{51, "analytics_rollups", "migrations/051_analytics_rollups.sql"},
+		{52, "uploads", "migrations/052_uploads.sql"},
+		{53, "analytics_visitors", "migrations/053_analytics_visitors.sql"},
+		{54, "deploy_limits", "migrations/054_deploy_limits.sql"},
+		{55, "analytics_geo", "migrations/055_analytics_geo.sql"},
+		{56, "analytics_campaigns", "migrations/056_analytics_campaigns.sql"},
+		{57, "redirect_expiry", "migrations/057_redirect_expiry.sql"},
+		{58, "pings", "migrations/058_pings.sql"},
+		{59, "heartbeats", "migrations/059_heartbeats.sql"},
+		{60, "doc_search", "migrations/060_doc_search.sql"},
+		{61, "s3_keys", "migrations/061_s3_keys.sql"},
+		{62, "app_mail", "migrations/062_app_mail.sql"},
+		{63, "push_keys", "migrations/063_push_keys.sql"},
+		{64, "app_grants", "migrations/064_app_grants.sql"},
+		{65, "app_system_access", "migrations/065_app_system_access.sql"},
+		{66, "failover", "migrations/066_failover.sql"},
+		{67, "blob_md5", "migrations/067_blob_md5.sql"},
 	}
 
 	// Run each migration if not already applied
@@ -0,0 +1,35 @@
+// Package dbtest opens databases for tests, with the server's schema.
+package dbtest
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// Open returns an in-memory database with all migrations run, closed when
+// the test ends. It has a single connection, as each connection to an
+// in-memory database would get a database of its own.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	return OpenFile(t, ":memory:")
+}
+
+// OpenFile is Open for a database file at path, for tests that need the
+// file itself, e.g. to back it up or open it again
+func OpenFile(t testing.TB, path string) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,4 @@
+-- Migration 022: Alias Response Transforms
+-- Per-alias response rewrites applied at the hosting layer (headers, HTML injection, find/replace)
+
+ALTER TABLE aliases ADD COLUMN transforms TEXT; -- JSON array of transform rules
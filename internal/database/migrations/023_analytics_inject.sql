@@ -0,0 +1,4 @@
+-- Migration 023: Per-app Analytics Injection Toggle
+-- Controls whether the tracking snippet is injected into served HTML
+
+ALTER TABLE apps ADD COLUMN analytics_inject INTEGER DEFAULT 1;
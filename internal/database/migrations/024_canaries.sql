@@ -0,0 +1,21 @@
+-- Migration 024: Canary Rollouts
+-- Progressive traffic shifting from a stable app to a canary app behind an alias
+
+CREATE TABLE IF NOT EXISTS canaries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subdomain TEXT NOT NULL,
+    stable_app_id TEXT NOT NULL,
+    canary_app_id TEXT NOT NULL,
+    step INTEGER NOT NULL DEFAULT 10,            -- weight added per interval (percent)
+    interval_seconds INTEGER NOT NULL DEFAULT 600,
+    max_error_rate REAL NOT NULL DEFAULT 0.05,   -- rollback threshold (0.0-1.0)
+    weight INTEGER NOT NULL DEFAULT 0,           -- current canary weight (percent)
+    status TEXT NOT NULL DEFAULT 'running',      -- running|promoted|rolled_back|aborted
+    reason TEXT,
+    last_step_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE INDEX IF NOT EXISTS idx_canaries_subdomain ON canaries(subdomain, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_canaries_running ON canaries(subdomain) WHERE status = 'running';
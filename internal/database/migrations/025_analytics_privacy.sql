@@ -0,0 +1,6 @@
+-- Migration 025: Per-app Analytics Privacy Settings
+-- analytics_collect: 0 drops all pageviews and /track events for the app
+-- analytics_honor_dnt: 1 drops events from visitors sending DNT: 1 or Sec-GPC: 1
+
+ALTER TABLE apps ADD COLUMN analytics_collect INTEGER DEFAULT 1;
+ALTER TABLE apps ADD COLUMN analytics_honor_dnt INTEGER DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Migration 026: App Membership (RBAC)
+-- Maps auth_users to apps with a per-app role. Server admins/owners
+-- bypass membership; regular users only see and modify apps they belong to.
+
+CREATE TABLE IF NOT EXISTS app_members (
+    app_id TEXT NOT NULL REFERENCES apps(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL,              -- 'owner', 'editor', 'viewer'
+    added_by TEXT,                   -- User ID, or NULL for server API keys
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (app_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_members_user ON app_members(user_id);
+
+-- API keys may act on behalf of a user (NULL = server-wide key)
+ALTER TABLE api_keys ADD COLUMN user_id TEXT REFERENCES auth_users(id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+-- Migration 027: Scoped API Keys
+-- Keys carry a single scope ('admin', 'deploy', 'read'), may be limited to
+-- one app, and may expire or be revoked without being deleted.
+
+ALTER TABLE api_keys ADD COLUMN app_id TEXT REFERENCES apps(id) ON DELETE CASCADE;
+ALTER TABLE api_keys ADD COLUMN expires_at DATETIME;   -- NULL = never expires
+ALTER TABLE api_keys ADD COLUMN revoked_at DATETIME;   -- NULL = active
+
+-- Scopes were never enforced before, so existing keys keep full access
+UPDATE api_keys SET scopes = 'admin';
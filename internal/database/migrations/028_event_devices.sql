@@ -0,0 +1,10 @@
+-- Migration 028: Event Device Breakdown
+-- Screen size comes from the tracking snippet; device class and browser
+-- family are derived at ingestion so breakdowns don't re-parse user agents.
+
+ALTER TABLE events ADD COLUMN screen_width INTEGER;
+ALTER TABLE events ADD COLUMN screen_height INTEGER;
+ALTER TABLE events ADD COLUMN device TEXT;   -- 'mobile', 'tablet', 'desktop', 'bot'
+ALTER TABLE events ADD COLUMN browser TEXT;  -- 'Chrome', 'Safari', 'Firefox', ...
+
+CREATE INDEX IF NOT EXISTS idx_events_device ON events(device);
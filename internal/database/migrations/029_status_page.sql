@@ -0,0 +1,37 @@
+-- Migration 029: Status Page
+-- Uptime checks recorded by the status monitor, and an incident log
+-- maintained via `fazt incident`. Both feed the public status.<domain> page.
+
+CREATE TABLE IF NOT EXISTS status_checks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    site TEXT NOT NULL,              -- Subdomain that was probed ('root' for the apex)
+    ok INTEGER NOT NULL,             -- 1 = up (status < 500), 0 = down
+    status_code INTEGER NOT NULL,
+    response_ms INTEGER NOT NULL,
+    checked_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_checks_site ON status_checks(site, checked_at);
+
+CREATE TABLE IF NOT EXISTS status_incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    title TEXT NOT NULL,
+    severity TEXT NOT NULL DEFAULT 'minor',       -- 'minor', 'major', 'maintenance'
+    status TEXT NOT NULL DEFAULT 'investigating', -- 'investigating', 'identified', 'monitoring', 'resolved'
+    site TEXT,                                    -- Affected site, NULL = whole server
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    resolved_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_incidents_created ON status_incidents(created_at DESC);
+
+CREATE TABLE IF NOT EXISTS status_incident_updates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    incident_id INTEGER NOT NULL REFERENCES status_incidents(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_incident_updates ON status_incident_updates(incident_id, created_at);
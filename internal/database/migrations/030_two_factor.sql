@@ -0,0 +1,32 @@
+-- Migration 030: Two-Factor Authentication (TOTP)
+-- Per-user TOTP secrets, hashed single-use recovery codes, and pending
+-- login challenges issued after the password check succeeds.
+
+CREATE TABLE IF NOT EXISTS auth_totp (
+    user_id TEXT PRIMARY KEY REFERENCES auth_users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,            -- Base32 TOTP secret
+    enabled INTEGER NOT NULL DEFAULT 0, -- 0 until the first code is confirmed
+    last_step INTEGER NOT NULL DEFAULT 0, -- Last accepted time step (replay protection)
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    enabled_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS auth_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,         -- SHA-256 of the normalized code
+    used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_recovery_codes_user ON auth_recovery_codes(user_id);
+
+-- Password verified, second factor pending. No session exists until the
+-- challenge is completed.
+CREATE TABLE IF NOT EXISTS auth_login_challenges (
+    token_hash TEXT PRIMARY KEY,     -- SHA-256 of challenge token
+    user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    remember_me INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    expires_at INTEGER NOT NULL
+);
@@ -0,0 +1,21 @@
+-- Migration 031: Incident Sites and Maintenance Windows
+-- Incidents can affect several sites, and maintenance incidents can be
+-- scheduled ahead of time with a start and end. The status monitor opens
+-- and completes windows on schedule and holds back downtime alerts for
+-- sites under active maintenance.
+
+CREATE TABLE IF NOT EXISTS status_incident_sites (
+    incident_id INTEGER NOT NULL REFERENCES status_incidents(id) ON DELETE CASCADE,
+    site TEXT NOT NULL,              -- Affected site; an incident with no rows affects the whole server
+    PRIMARY KEY (incident_id, site)
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_incident_sites_site ON status_incident_sites(site);
+
+INSERT OR IGNORE INTO status_incident_sites (incident_id, site)
+SELECT id, site FROM status_incidents WHERE site IS NOT NULL AND site != '';
+
+ALTER TABLE status_incidents DROP COLUMN site;
+
+ALTER TABLE status_incidents ADD COLUMN starts_at INTEGER; -- Maintenance window start, NULL = unscheduled
+ALTER TABLE status_incidents ADD COLUMN ends_at INTEGER;   -- Maintenance window end, NULL = open-ended
@@ -0,0 +1,26 @@
+-- Migration 032: Passkeys (WebAuthn)
+-- Registered credentials and single-use ceremony challenges. Passkey login
+-- is an alternative to the password and OAuth flows.
+
+CREATE TABLE IF NOT EXISTS auth_passkeys (
+    id TEXT PRIMARY KEY,             -- Credential ID (base64url)
+    user_id TEXT NOT NULL REFERENCES auth_users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,              -- Label chosen at registration ("MacBook", "YubiKey")
+    public_key BLOB NOT NULL,        -- COSE_Key from the attested credential data
+    algorithm INTEGER NOT NULL,      -- COSE algorithm (-7 ES256, -8 EdDSA, -257 RS256)
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    aaguid TEXT,                     -- Authenticator model, hex
+    attestation_format TEXT,         -- 'none' or 'packed'
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    last_used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_passkeys_user ON auth_passkeys(user_id);
+
+CREATE TABLE IF NOT EXISTS auth_webauthn_challenges (
+    challenge_hash TEXT PRIMARY KEY, -- SHA-256 of the base64url challenge
+    ceremony TEXT NOT NULL,          -- 'register' or 'login'
+    user_id TEXT,                    -- Registering user; NULL for login
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    expires_at INTEGER NOT NULL
+);
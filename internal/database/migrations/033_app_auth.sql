@@ -0,0 +1,39 @@
+-- Migration 033: App end-user authentication
+-- Per-app OAuth providers, users, and sessions. App users are separate from
+-- the platform accounts in auth_users and only exist within their app.
+
+CREATE TABLE IF NOT EXISTS app_auth_providers (
+    app_id TEXT NOT NULL,
+    provider TEXT NOT NULL,          -- 'google', 'github', 'discord', 'microsoft'
+    enabled INTEGER DEFAULT 1,
+    client_id TEXT NOT NULL,
+    client_secret TEXT,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (app_id, provider)
+);
+
+CREATE TABLE IF NOT EXISTS app_users (
+    id TEXT PRIMARY KEY,             -- fazt_apu_*
+    app_id TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    provider_id TEXT NOT NULL,       -- External ID from the provider
+    email TEXT,
+    name TEXT,
+    picture TEXT,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    last_login INTEGER,
+    UNIQUE (app_id, provider, provider_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_users_app ON app_users(app_id);
+
+CREATE TABLE IF NOT EXISTS app_sessions (
+    token_hash TEXT PRIMARY KEY,     -- SHA-256 of session token
+    app_id TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES app_users(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_sessions_user ON app_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_app_sessions_expires ON app_sessions(expires_at);
@@ -0,0 +1,36 @@
+-- Migration 034: Service Level Objectives
+-- Availability and latency SLOs per alias, evaluated against rollups of real
+-- site traffic. The SLO controller flushes rollups every minute and alerts
+-- when the error budget burns too fast.
+
+CREATE TABLE IF NOT EXISTS slos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    alias TEXT NOT NULL,             -- Subdomain the objective applies to
+    kind TEXT NOT NULL,              -- availability, latency
+    objective REAL NOT NULL,         -- Target fraction of good requests, e.g. 0.999
+    threshold_ms INTEGER,            -- Latency SLOs: requests slower than this are bad
+    window_days INTEGER NOT NULL DEFAULT 30,
+    alert_state TEXT NOT NULL DEFAULT '', -- '', slow_burn, fast_burn
+    alerted_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE INDEX IF NOT EXISTS idx_slos_alias ON slos(alias);
+
+-- Request counts per alias in 5-minute buckets. le_* columns count requests
+-- that completed within that many milliseconds.
+CREATE TABLE IF NOT EXISTS slo_rollups (
+    alias TEXT NOT NULL,
+    bucket INTEGER NOT NULL,         -- Bucket start (unix seconds)
+    requests INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0, -- 5xx responses
+    le_100 INTEGER NOT NULL DEFAULT 0,
+    le_250 INTEGER NOT NULL DEFAULT 0,
+    le_500 INTEGER NOT NULL DEFAULT 0,
+    le_1000 INTEGER NOT NULL DEFAULT 0,
+    le_2500 INTEGER NOT NULL DEFAULT 0,
+    le_5000 INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (alias, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_slo_rollups_bucket ON slo_rollups(bucket);
@@ -0,0 +1,7 @@
+-- Migration 035: Admin impersonation
+-- Sessions minted by an admin to act as another user. The impersonator is
+-- recorded so the session can be flagged in the UI and revoked separately.
+
+ALTER TABLE auth_sessions ADD COLUMN impersonator_id TEXT; -- Admin user ID or "api_key:<name>", NULL for normal sessions
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_impersonator ON auth_sessions(impersonator_id) WHERE impersonator_id IS NOT NULL;
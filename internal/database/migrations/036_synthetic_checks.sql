@@ -0,0 +1,29 @@
+-- Migration 036: Synthetic Transaction Checks
+-- JS scripts that exercise a site end to end (log in, add to cart, ...) and
+-- throw when an assertion fails. The status monitor runs each one as a worker
+-- job for the site's app on its own interval and alerts on state changes.
+
+CREATE TABLE IF NOT EXISTS status_synthetics (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    site TEXT NOT NULL,              -- Site the check belongs to; the script runs as its app
+    name TEXT NOT NULL,
+    script TEXT NOT NULL,            -- Path of the script in the site's files, e.g. checks/checkout.js
+    interval_seconds INTEGER NOT NULL DEFAULT 300,
+    timeout_seconds INTEGER NOT NULL DEFAULT 30,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    job_id TEXT,                     -- Worker job of the run in flight, NULL when idle
+    last_run_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE (site, name)
+);
+
+CREATE TABLE IF NOT EXISTS status_synthetic_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    synthetic_id INTEGER NOT NULL REFERENCES status_synthetics(id) ON DELETE CASCADE,
+    ok INTEGER NOT NULL,
+    error TEXT,                      -- Thrown error or failure reason
+    duration_ms INTEGER,
+    ran_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_status_synthetic_runs_check ON status_synthetic_runs(synthetic_id, ran_at DESC);
@@ -0,0 +1,18 @@
+-- Migration 037: Per-app cost accounting
+-- Approximate resource cost of serving each request, summed per app and UTC
+-- day. Requests are metered in memory and flushed every minute.
+
+CREATE TABLE IF NOT EXISTS app_usage (
+    app TEXT NOT NULL,               -- App ID, or the subdomain for sites without one
+    day TEXT NOT NULL,               -- UTC date, YYYY-MM-DD
+    requests INTEGER NOT NULL DEFAULT 0,
+    cpu_ms INTEGER NOT NULL DEFAULT 0,         -- Handler time, excluding time waiting on fazt.net.fetch
+    db_reads INTEGER NOT NULL DEFAULT 0,       -- Storage read queries
+    db_writes INTEGER NOT NULL DEFAULT 0,      -- Storage write queries
+    storage_bytes INTEGER NOT NULL DEFAULT 0,  -- Bytes written to storage (approximate growth)
+    egress_bytes INTEGER NOT NULL DEFAULT 0,   -- fazt.net.fetch request and response bodies
+    response_bytes INTEGER NOT NULL DEFAULT 0, -- Response bodies sent to clients
+    PRIMARY KEY (app, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_usage_day ON app_usage(day);
@@ -0,0 +1,6 @@
+-- Migration 038: Session client metadata
+-- Records where a session was created from so users can recognise and revoke
+-- sessions they don't expect.
+
+ALTER TABLE auth_sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE auth_sessions ADD COLUMN ip TEXT;
@@ -0,0 +1,14 @@
+-- Migration 039: Usage export hooks
+-- Webhooks that receive each completed period's per-user usage, for feeding
+-- an invoicing system. Delivered by the usage flusher and retried until the
+-- endpoint accepts them.
+
+CREATE TABLE IF NOT EXISTS usage_hooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,            -- HMAC-SHA256 key for X-Webhook-Signature
+    period TEXT NOT NULL,            -- 'day', 'week', 'month'
+    delivered_through TEXT,          -- Start of the last delivered period, YYYY-MM-DD
+    last_error TEXT,                 -- Error from the last failed delivery, NULL once delivered
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
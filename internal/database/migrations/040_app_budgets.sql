@@ -0,0 +1,15 @@
+-- Migration 040: App resource budgets
+-- Daily CPU, egress and storage budgets per app. An app over budget is put in
+-- degraded mode (fewer concurrent jobs, strict rate limit, cached fetches
+-- only) until the next UTC day or a manual override.
+
+CREATE TABLE IF NOT EXISTS app_budgets (
+    app TEXT PRIMARY KEY,            -- App ID, or the subdomain for sites without one (as in app_usage)
+    cpu_ms INTEGER,                  -- Daily handler time budget, NULL for no limit
+    egress_bytes INTEGER,            -- Daily fazt.net.fetch budget, NULL for no limit
+    storage_bytes INTEGER,           -- Daily bytes written budget, NULL for no limit
+    override TEXT,                   -- NULL follows budgets; 'on' forces degraded mode, 'off' never degrades
+    degraded_at INTEGER,             -- When degraded mode started, NULL while normal
+    reason TEXT,                     -- Why the app is degraded
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
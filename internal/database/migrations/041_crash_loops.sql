@@ -0,0 +1,13 @@
+-- Migration 041: Crash-loop isolation
+-- Serverless apps whose handlers keep crashing are tripped: their API routes
+-- get a static fallback or 503 until a cooldown passes and a probe request
+-- succeeds. Rows exist only while an app is tripped.
+
+CREATE TABLE IF NOT EXISTS crash_loops (
+    app TEXT PRIMARY KEY,            -- Site the serverless handler belongs to
+    crashes INTEGER NOT NULL,        -- Crashes that tripped it
+    last_error TEXT,                 -- Most recent crash
+    incident_id INTEGER,             -- Status page incident opened for it
+    tripped_at INTEGER NOT NULL,
+    retry_at INTEGER NOT NULL        -- When the next probe request is let through
+);
@@ -0,0 +1,19 @@
+-- Migration 042: Per-handler runtime usage
+-- CPU time and memory of serverless handler executions, summed per app,
+-- entry point and UTC day, to find the handlers that cost the most.
+-- Executions are recorded in memory and flushed with app_usage.
+
+CREATE TABLE IF NOT EXISTS runtime_usage (
+    app TEXT NOT NULL,                          -- App ID
+    entry TEXT NOT NULL,                        -- api/main.js or a route pattern (/api/users/:id)
+    day TEXT NOT NULL,                          -- UTC date, YYYY-MM-DD
+    executions INTEGER NOT NULL DEFAULT 0,
+    cpu_ms INTEGER NOT NULL DEFAULT 0,          -- JS time, excluding time waiting on fazt.net.fetch
+    max_cpu_ms INTEGER NOT NULL DEFAULT 0,      -- Slowest single execution
+    alloc_bytes INTEGER NOT NULL DEFAULT 0,     -- Bytes handed to the VM (files, storage reads, fetches, logs)
+    max_alloc_bytes INTEGER NOT NULL DEFAULT 0, -- Largest single execution
+    killed INTEGER NOT NULL DEFAULT 0,          -- Executions interrupted by the timeout or a sandbox limit
+    PRIMARY KEY (app, entry, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_runtime_usage_day ON runtime_usage(day);
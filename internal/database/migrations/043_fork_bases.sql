@@ -0,0 +1,11 @@
+-- Migration 043: Fork merge bases
+-- The files of the original as a fork last saw them: when the fork was made,
+-- then after each merge back. Merging compares both apps against this base
+-- to tell the fork's changes from deploys to the original made since.
+
+CREATE TABLE IF NOT EXISTS fork_bases (
+    app_id TEXT NOT NULL,            -- The fork
+    path TEXT NOT NULL,
+    hash TEXT NOT NULL,              -- SHA256 of the file's content
+    PRIMARY KEY (app_id, path)
+);
@@ -0,0 +1,7 @@
+-- Migration 044: Protected apps and aliases
+-- Protected apps can't be removed, and protected aliases can't be removed or
+-- repointed, unless the request is forced and confirmed by repeating the
+-- name (or forced by an owner). Guards production apps against slips.
+
+ALTER TABLE apps ADD COLUMN protected INTEGER NOT NULL DEFAULT 0;    -- 1 = removal needs force + confirm
+ALTER TABLE aliases ADD COLUMN protected INTEGER NOT NULL DEFAULT 0; -- 1 = removal or repointing needs force + confirm
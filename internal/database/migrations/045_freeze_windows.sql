@@ -0,0 +1,13 @@
+-- Migration 045: Deploy freeze windows
+-- A freeze starts whenever its cron schedule matches and lasts for its
+-- duration. While one is in effect, deploys and alias changes to apps with
+-- one of its tags are refused unless marked as an emergency.
+
+CREATE TABLE IF NOT EXISTS freeze_windows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    schedule TEXT NOT NULL,          -- 5-field cron expression, server local time
+    duration_minutes INTEGER NOT NULL,
+    tags TEXT NOT NULL DEFAULT '[]', -- JSON array of app tags; empty freezes every app
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
@@ -0,0 +1,20 @@
+-- Migration 046: Change approvals
+-- With auth.require_approval on, destructive operations (app remove, user
+-- delete, config change) are queued here until a second admin approves them.
+
+CREATE TABLE IF NOT EXISTS approvals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    operation TEXT NOT NULL,            -- app.remove, user.delete, config.change
+    target TEXT NOT NULL,               -- app ID, user ID or config key
+    summary TEXT NOT NULL DEFAULT '',
+    params TEXT NOT NULL DEFAULT '{}',  -- JSON object passed to the operation
+    status TEXT NOT NULL DEFAULT 'pending',
+    requested_by TEXT NOT NULL,         -- user ID or api_key:<name>
+    decided_by TEXT,
+    decided_at INTEGER,
+    error TEXT,                         -- why an approved operation failed
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_approvals_status ON approvals(status, created_at);
@@ -0,0 +1,20 @@
+-- Migration 047: Dead-letter jobs
+-- Jobs that fail their last attempt are kept here with their error, logs and
+-- checkpoint, so they can be inspected and replayed.
+
+CREATE TABLE IF NOT EXISTS dead_letter (
+    job_id TEXT PRIMARY KEY,
+    app_id TEXT NOT NULL,
+    handler TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',  -- JobConfig, reused on retry
+    error TEXT,
+    logs TEXT NOT NULL DEFAULT '[]',
+    checkpoint TEXT,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER,                 -- when the job was spawned (ms)
+    failed_at INTEGER NOT NULL,         -- ms
+    retried_at INTEGER,                 -- ms
+    retry_job_id TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letter_app ON dead_letter(app_id, failed_at);
@@ -0,0 +1,21 @@
+-- Migration 048: Per-host sessions
+-- Session cookies are host-only and each session is bound to the host it was
+-- issued on, so a hosted app can neither read nor replay the dashboard's
+-- session. Logins that finish on another host (OAuth callbacks land on the
+-- root domain) hand the user over with a one-time code.
+
+ALTER TABLE auth_sessions ADD COLUMN host TEXT;
+
+CREATE TABLE IF NOT EXISTS auth_handoffs (
+    code_hash TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    host TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES auth_users(id) ON DELETE CASCADE
+);
+
+-- Earlier session cookies were scoped to the parent domain and sent to every
+-- hosted app. Sign those sessions out; admin-minted impersonation sessions
+-- were never set as cookies and are kept.
+DELETE FROM auth_sessions WHERE impersonator_id IS NULL;
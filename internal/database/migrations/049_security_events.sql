@@ -0,0 +1,46 @@
+-- Migration 049: Security event stream
+-- Failed logins, logins from new devices, API key creation, role changes and
+-- first egress to newly allowlisted domains are recorded separately from the
+-- activity log, with notification rules for alerting on them.
+
+CREATE TABLE IF NOT EXISTS security_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    actor TEXT,
+    ip TEXT,
+    subject TEXT,
+    details TEXT,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_security_events_type ON security_events(type, created_at);
+CREATE INDEX IF NOT EXISTS idx_security_events_created ON security_events(created_at);
+
+-- Devices (user agents) each user has signed in from
+CREATE TABLE IF NOT EXISTS security_devices (
+    user_id TEXT NOT NULL,
+    device TEXT NOT NULL,
+    user_agent TEXT,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+    PRIMARY KEY (user_id, device)
+);
+
+-- Alert when threshold events of a type ('*' for any) happen within
+-- window_seconds. Alerts go to the webhook, or ntfy when none is set.
+CREATE TABLE IF NOT EXISTS security_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    threshold INTEGER NOT NULL DEFAULT 1,
+    window_seconds INTEGER NOT NULL DEFAULT 0,
+    webhook_url TEXT,
+    last_fired_at INTEGER,
+    created_at INTEGER NOT NULL
+);
+
+INSERT INTO security_rules (name, event_type, threshold, window_seconds, created_at) VALUES
+    ('Repeated failed logins', 'login.failed', 10, 600, unixepoch()),
+    ('Login from a new device', 'login.new_device', 1, 0, unixepoch()),
+    ('Role changed', 'role.changed', 1, 0, unixepoch());
@@ -0,0 +1,11 @@
+-- Migration 050: Login IPs
+-- Client IPs each user has signed in from, so a login from a new one can be
+-- recorded as a security event and sent as a notification.
+
+CREATE TABLE IF NOT EXISTS security_ips (
+    user_id TEXT NOT NULL,
+    ip TEXT NOT NULL,
+    first_seen INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL,
+    PRIMARY KEY (user_id, ip)
+);
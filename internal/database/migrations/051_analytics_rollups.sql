@@ -0,0 +1,36 @@
+-- Migration 051: Analytics rollups
+-- Events are counted per hour and per day, by site, path, referrer, source,
+-- type and tags, so dashboards read aggregates for older ranges instead of
+-- scanning every event, and raw events can be pruned without losing them.
+
+CREATE TABLE IF NOT EXISTS analytics_hourly (
+    hour TEXT NOT NULL, -- UTC, '2006-01-02 15:00:00'
+    domain TEXT NOT NULL,
+    source_type TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    path TEXT NOT NULL,
+    referrer TEXT NOT NULL,
+    tags TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (hour, domain, source_type, event_type, path, referrer, tags)
+);
+
+CREATE TABLE IF NOT EXISTS analytics_daily (
+    day TEXT NOT NULL, -- UTC, '2006-01-02'
+    domain TEXT NOT NULL,
+    source_type TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    path TEXT NOT NULL,
+    referrer TEXT NOT NULL,
+    tags TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (day, domain, source_type, event_type, path, referrer, tags)
+);
+
+CREATE INDEX IF NOT EXISTS idx_analytics_daily_domain ON analytics_daily(domain, day);
+
+-- How far each rollup has got: events before 'until' are rolled up
+CREATE TABLE IF NOT EXISTS analytics_rollup_state (
+    name TEXT PRIMARY KEY, -- hourly or daily
+    until TEXT NOT NULL
+);
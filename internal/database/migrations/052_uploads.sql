@@ -0,0 +1,23 @@
+-- Migration 052: Resumable uploads
+-- Large deploy bundles and blobs are sent in chunks with the tus protocol.
+-- The bytes received so far are staged in files next to the database; this
+-- table tracks each upload until it completes or expires.
+
+CREATE TABLE IF NOT EXISTS uploads (
+    id TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,                 -- deploy, blob or user_blob
+    owner TEXT NOT NULL,                -- Who may resume it: a user ID or api_key:<name>
+    app_id TEXT NOT NULL DEFAULT '',    -- Site the upload is for
+    target TEXT NOT NULL,               -- Site name for deploys, blob path otherwise
+    metadata TEXT NOT NULL DEFAULT '{}',
+    size INTEGER NOT NULL,
+    received INTEGER NOT NULL DEFAULT 0,
+    state TEXT NOT NULL DEFAULT 'uploading', -- uploading, finishing, done or failed
+    result TEXT,                        -- JSON outcome once done or failed
+    created_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    updated_at INTEGER NOT NULL DEFAULT (strftime('%s', 'now')),
+    expires_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_owner ON uploads(owner, state);
+CREATE INDEX IF NOT EXISTS idx_uploads_expires ON uploads(expires_at);
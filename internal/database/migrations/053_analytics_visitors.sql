@@ -0,0 +1,23 @@
+-- Migration 053: Privacy-preserving unique visitors
+-- Events carry a visitor ID: a hash of IP, user agent and site salted with
+-- a random value that rotates every UTC day. Old salts are deleted, so IDs
+-- can't be linked across days or traced back to an IP.
+
+ALTER TABLE events ADD COLUMN visitor_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_events_visitor ON events(visitor_id, created_at);
+
+CREATE TABLE IF NOT EXISTS analytics_salts (
+    day TEXT PRIMARY KEY, -- UTC, '2006-01-02'
+    salt BLOB NOT NULL
+);
+
+-- Unique visitors and sessions per site and day, counted before raw events
+-- are pruned
+CREATE TABLE IF NOT EXISTS analytics_visitors (
+    day TEXT NOT NULL, -- UTC, '2006-01-02'
+    domain TEXT NOT NULL,
+    visitors INTEGER NOT NULL,
+    sessions INTEGER NOT NULL,
+    PRIMARY KEY (day, domain)
+);
@@ -0,0 +1,14 @@
+-- Migration 054: Deploy limits per app and per deployer
+-- Overrides of the global server.deploy_limits.* for one app or one
+-- deployer. Each limit is the app's if set, else the deployer's, else the
+-- global one.
+
+CREATE TABLE IF NOT EXISTS deploy_limits (
+    scope TEXT NOT NULL,             -- 'app' or 'user'
+    name TEXT NOT NULL,              -- App name deployed to, or deployer ('api_key:<name>' or user ID)
+    max_bundle_bytes INTEGER,        -- Total uncompressed size, NULL to fall back
+    max_files INTEGER,               -- File count, NULL to fall back
+    max_file_bytes INTEGER,          -- Largest single file, NULL to fall back
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (scope, name)
+);
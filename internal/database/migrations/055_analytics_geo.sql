@@ -0,0 +1,16 @@
+-- Migration 055: Geo-IP enrichment for analytics
+-- With a MaxMind DB configured, events are located by IP when they are
+-- written, and counted per site, country and region by day so the counts
+-- survive raw events being pruned.
+
+ALTER TABLE events ADD COLUMN country TEXT; -- ISO 3166-1, e.g. 'US'
+ALTER TABLE events ADD COLUMN region TEXT;  -- ISO 3166-2, e.g. 'US-CA'
+
+CREATE TABLE IF NOT EXISTS analytics_geo (
+    day TEXT NOT NULL, -- UTC, '2006-01-02'
+    domain TEXT NOT NULL,
+    country TEXT NOT NULL,
+    region TEXT NOT NULL, -- '' when unknown
+    count INTEGER NOT NULL,
+    PRIMARY KEY (day, domain, country, region)
+);
@@ -0,0 +1,12 @@
+-- Migration 056: Campaign attribution for analytics
+-- Events carry their utm_source/medium/campaign parameters and the kind of
+-- referrer they came from, parsed as they are recorded, so sites and short
+-- links (/r/) share one attribution view.
+
+ALTER TABLE events ADD COLUMN utm_source TEXT;
+ALTER TABLE events ADD COLUMN utm_medium TEXT;
+ALTER TABLE events ADD COLUMN utm_campaign TEXT;
+ALTER TABLE events ADD COLUMN referrer_type TEXT; -- direct, internal, search, social or referral
+
+CREATE INDEX IF NOT EXISTS idx_events_campaign ON events(utm_campaign, created_at)
+    WHERE utm_campaign IS NOT NULL;
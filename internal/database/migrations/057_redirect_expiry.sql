@@ -0,0 +1,5 @@
+-- Migration 057: Short link expiry
+-- Links (/r/) can stop redirecting after a date; expired links answer 410
+-- Gone but are kept, with their click history, until deleted.
+
+ALTER TABLE redirects ADD COLUMN expires_at DATETIME; -- NULL = never expires
@@ -0,0 +1,31 @@
+-- Migration 058: Scheduled HTTP Pings
+-- Outbound calls (URL, method, headers, body) made on a cron schedule
+-- without an app handler, e.g. keeping a free-tier service awake or hitting
+-- a heartbeat URL. They go through the egress proxy and alert when they
+-- start failing or recover.
+
+CREATE TABLE IF NOT EXISTS pings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    method TEXT NOT NULL DEFAULT 'GET',
+    headers TEXT NOT NULL DEFAULT '{}', -- JSON object of header -> value
+    body TEXT,
+    schedule TEXT NOT NULL,             -- 5-field cron expression, server local time
+    timeout_seconds INTEGER NOT NULL DEFAULT 30,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    last_run_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE TABLE IF NOT EXISTS ping_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ping_id INTEGER NOT NULL REFERENCES pings(id) ON DELETE CASCADE,
+    ok INTEGER NOT NULL,
+    status INTEGER,                     -- HTTP status, NULL when no response
+    error TEXT,
+    duration_ms INTEGER,
+    ran_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ping_runs_ping ON ping_runs(ping_id, ran_at DESC);
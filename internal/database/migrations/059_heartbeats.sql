@@ -0,0 +1,18 @@
+-- Migration 059: Heartbeats
+-- Check-ins from external scripts (backups, cron jobs, home-lab boxes): each
+-- heartbeat has a secret URL the script calls when it runs, and the admin is
+-- notified when a check-in is missed by more than the grace period.
+
+CREATE TABLE IF NOT EXISTS heartbeats (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    token TEXT NOT NULL UNIQUE,        -- Secret in the check-in URL
+    period_seconds INTEGER NOT NULL,   -- Expected time between check-ins
+    grace_seconds INTEGER NOT NULL,    -- Extra time before a check-in counts as missed
+    status TEXT NOT NULL DEFAULT 'new', -- new (never checked in), up, down
+    enabled INTEGER NOT NULL DEFAULT 1,
+    checkins INTEGER NOT NULL DEFAULT 0,
+    last_checkin_at INTEGER,
+    last_checkin_ip TEXT,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
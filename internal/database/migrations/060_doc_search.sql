@@ -0,0 +1,59 @@
+-- Migration 060: Document Search
+-- Full-text search over document store collections. An app enables search
+-- per collection, naming the fields to index; triggers on app_docs keep an
+-- FTS5 index of those fields in step with every insert, update and delete,
+-- whichever API made it.
+
+CREATE TABLE IF NOT EXISTS app_doc_search (
+    app_id TEXT NOT NULL,
+    collection TEXT NOT NULL,
+    fields TEXT NOT NULL, -- JSON array of field paths, e.g. ["title","body","author.name"]
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (app_id, collection)
+);
+
+-- Maps documents to FTS rows. app_docs rowids can change on VACUUM, so the
+-- index is keyed by this table's rowid instead.
+CREATE TABLE IF NOT EXISTS app_doc_search_rows (
+    id INTEGER PRIMARY KEY,
+    app_id TEXT NOT NULL,
+    collection TEXT NOT NULL,
+    doc_id TEXT NOT NULL,
+    UNIQUE (app_id, collection, doc_id)
+);
+
+-- The indexed fields of a document, one per line
+CREATE VIRTUAL TABLE IF NOT EXISTS app_docs_fts USING fts5(
+    body,
+    tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS app_docs_search_insert AFTER INSERT ON app_docs
+WHEN EXISTS (SELECT 1 FROM app_doc_search WHERE app_id = NEW.app_id AND collection = NEW.collection)
+BEGIN
+    INSERT OR IGNORE INTO app_doc_search_rows (app_id, collection, doc_id) VALUES (NEW.app_id, NEW.collection, NEW.id);
+    INSERT INTO app_docs_fts (rowid, body) VALUES (
+        (SELECT id FROM app_doc_search_rows WHERE app_id = NEW.app_id AND collection = NEW.collection AND doc_id = NEW.id),
+        (SELECT group_concat(json_extract(NEW.data, '$.' || f.value), char(10))
+         FROM app_doc_search s, json_each(s.fields) f
+         WHERE s.app_id = NEW.app_id AND s.collection = NEW.collection)
+    );
+END;
+
+CREATE TRIGGER IF NOT EXISTS app_docs_search_update AFTER UPDATE OF data ON app_docs
+WHEN EXISTS (SELECT 1 FROM app_doc_search WHERE app_id = NEW.app_id AND collection = NEW.collection)
+BEGIN
+    UPDATE app_docs_fts SET body = (
+        SELECT group_concat(json_extract(NEW.data, '$.' || f.value), char(10))
+        FROM app_doc_search s, json_each(s.fields) f
+        WHERE s.app_id = NEW.app_id AND s.collection = NEW.collection
+    ) WHERE rowid = (SELECT id FROM app_doc_search_rows WHERE app_id = NEW.app_id AND collection = NEW.collection AND doc_id = NEW.id);
+END;
+
+CREATE TRIGGER IF NOT EXISTS app_docs_search_delete AFTER DELETE ON app_docs
+WHEN EXISTS (SELECT 1 FROM app_doc_search WHERE app_id = OLD.app_id AND collection = OLD.collection)
+BEGIN
+    DELETE FROM app_docs_fts WHERE rowid = (
+        SELECT id FROM app_doc_search_rows WHERE app_id = OLD.app_id AND collection = OLD.collection AND doc_id = OLD.id);
+    DELETE FROM app_doc_search_rows WHERE app_id = OLD.app_id AND collection = OLD.collection AND doc_id = OLD.id;
+END;
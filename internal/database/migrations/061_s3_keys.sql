@@ -0,0 +1,16 @@
+-- Migration 061: S3 Access Keys
+-- Per-app credentials for the S3-compatible gateway on s3.<domain>. Each key
+-- reaches one bucket, the app's blobs. SigV4 signs requests with the secret,
+-- so the server keeps it rather than a hash.
+
+CREATE TABLE IF NOT EXISTS s3_keys (
+    id TEXT PRIMARY KEY,           -- Access key ID, e.g. FZ3K9QX2M7H4T8W1RB6N
+    secret TEXT NOT NULL,          -- Secret access key
+    app_id TEXT NOT NULL,          -- The app whose blobs the key reaches
+    name TEXT NOT NULL DEFAULT '', -- What the key is for, e.g. "restic"
+    read_only INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    last_used_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_s3_keys_app ON s3_keys(app_id);
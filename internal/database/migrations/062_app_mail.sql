@@ -0,0 +1,36 @@
+-- Migration 062: App Mail
+-- Email apps send with fazt.app.mail.send. Each message is kept in the
+-- outbox, queued as a worker job that sends it with retries, and stays as the
+-- app's sent-mail log. Apps send through the server's mail settings (mail.*)
+-- unless they have their own.
+
+CREATE TABLE IF NOT EXISTS app_mail (
+    id TEXT PRIMARY KEY,                 -- e.g. mail_3f9a1c2b7d4e5f60
+    app_id TEXT NOT NULL,
+    recipients TEXT NOT NULL,            -- JSON array of addresses
+    subject TEXT NOT NULL,
+    html TEXT NOT NULL DEFAULT '',
+    text TEXT NOT NULL DEFAULT '',
+    reply_to TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'queued', -- queued, sent, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,                          -- Why the last attempt failed
+    job_id TEXT,                         -- The worker job sending it
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    sent_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_mail_app ON app_mail(app_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_app_mail_status ON app_mail(status);
+
+-- An app's own mail server and daily limit
+CREATE TABLE IF NOT EXISTS app_mail_settings (
+    app_id TEXT PRIMARY KEY,
+    smtp_host TEXT NOT NULL DEFAULT '', -- Empty sends through the server's mail server
+    smtp_port INTEGER NOT NULL DEFAULT 587,
+    smtp_username TEXT NOT NULL DEFAULT '',
+    smtp_password TEXT NOT NULL DEFAULT '',
+    smtp_from TEXT NOT NULL DEFAULT '',
+    daily_limit INTEGER,                -- NULL follows mail.daily_limit
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
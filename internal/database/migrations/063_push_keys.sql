@@ -0,0 +1,12 @@
+-- Migration 063: Web Push Keys
+-- Each app's VAPID key pair, created the first time the app uses
+-- fazt.app.push. Browsers subscribe with the public key and push services
+-- check the signatures made with the private key, so it never changes once
+-- made: a new key would orphan every subscription.
+
+CREATE TABLE IF NOT EXISTS push_keys (
+    app_id TEXT PRIMARY KEY,
+    public_key TEXT NOT NULL,     -- Uncompressed P-256 point, base64url
+    private_key TEXT NOT NULL,    -- P-256 scalar, base64url
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
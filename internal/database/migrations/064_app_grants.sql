@@ -0,0 +1,16 @@
+-- Migration 064: App Grants
+-- Which apps may call which other apps' handlers in-process with fazt.call.
+-- Apps are keyed by the site ID their handlers run under. A scope is "api"
+-- for the whole of the target's API, or a path such as /api/reports for
+-- that path and below.
+
+CREATE TABLE IF NOT EXISTS app_grants (
+    caller TEXT NOT NULL,
+    target TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT 'api',
+    created_by TEXT,                     -- The user who granted it
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    PRIMARY KEY (caller, target, scope)
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_grants_target ON app_grants(target);
@@ -0,0 +1,6 @@
+-- Migration 065: App System Access
+-- Apps with system access can read server-wide information from their
+-- handlers with fazt.sys: every app, the server's stats and every app's
+-- jobs. Only the server's admins can turn it on.
+
+ALTER TABLE apps ADD COLUMN system_access INTEGER NOT NULL DEFAULT 0; -- 1 = handlers may use fazt.sys
@@ -0,0 +1,27 @@
+-- Migration 066: Failover
+-- A primary replicates its apps to a standby peer; promoting the standby
+-- points DNS at it and makes it the primary. One row, on either side.
+
+CREATE TABLE IF NOT EXISTS failover (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    role TEXT NOT NULL DEFAULT 'primary',  -- primary or standby
+
+    -- Primary: the standby it replicates to, and how the last sync went
+    standby_url TEXT,
+    standby_token TEXT,
+    last_sync_at INTEGER,
+    last_sync_bytes INTEGER,
+    last_sync_error TEXT,
+    last_fingerprint TEXT,               -- Of the app data last sent; unchanged data isn't sent again
+
+    -- Standby: the last replica received, and the DNS records to repoint
+    replica_at INTEGER,
+    replica_apps INTEGER,
+    dns_provider TEXT,                   -- cloudflare, webhook, or a registered plugin
+    dns_settings TEXT,                   -- JSON object, provider-specific
+    dns_records TEXT,                    -- JSON array of names, e.g. ["example.com","*.example.com"]
+    dns_ip TEXT,                         -- The address they point to once promoted
+    promoted_at INTEGER,
+
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
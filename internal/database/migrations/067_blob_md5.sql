@@ -0,0 +1,6 @@
+-- Migration 067: Blob MD5
+-- S3 clients expect an object's ETag to be the MD5 of its data, and check
+-- uploads against it. hash stays the SHA-256 blobs are addressed by.
+-- Blobs stored before this get theirs the first time it's needed.
+
+ALTER TABLE app_blobs ADD COLUMN md5 TEXT;
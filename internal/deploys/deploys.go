@@ -0,0 +1,165 @@
+// Package deploys tracks deploys that are processed in the background, so
+// a client can hand over a bundle and poll for progress instead of holding
+// one request open until every file is written.
+package deploys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Deploy statuses
+const (
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+)
+
+// Stages a processing deploy goes through
+const (
+	StageQueued    = "queued"    // Received, not started
+	StageWriting   = "writing"   // Writing files
+	StageFinishing = "finishing" // Applying options, precompressing, recording
+)
+
+// retention is how long a finished deploy's status can still be read
+const retention = time.Hour
+
+// ErrInProgress refuses a deploy to a site that is still being deployed
+var ErrInProgress = errors.New("a deploy to this site is already in progress")
+
+// State is the progress of one background deploy
+type State struct {
+	ID           string                 `json:"id"`
+	Site         string                 `json:"site"`
+	Status       string                 `json:"status"`
+	Stage        string                 `json:"stage,omitempty"`
+	FilesWritten int                    `json:"files_written"`
+	FilesTotal   int                    `json:"files_total"`
+	Result       map[string]interface{} `json:"result,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	DeployedBy   string                 `json:"-"`
+	CreatedAt    time.Time              `json:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at"`
+	DoneAt       time.Time              `json:"done_at,omitempty"`
+}
+
+// Deploy is a background deploy whose state is updated as it runs
+type Deploy struct {
+	mu    sync.Mutex
+	state State
+	err   error
+}
+
+// State returns a copy of the deploy's current state
+func (d *Deploy) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// ID returns the deploy's ID
+func (d *Deploy) ID() string {
+	return d.State().ID
+}
+
+// Err returns the error a failed deploy failed with
+func (d *Deploy) Err() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+// SetStage records the stage the deploy reached
+func (d *Deploy) SetStage(stage string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Stage = stage
+	d.state.UpdatedAt = time.Now()
+}
+
+// Progress records the files written so far
+func (d *Deploy) Progress(written, total int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Stage = StageWriting
+	d.state.FilesWritten, d.state.FilesTotal = written, total
+	d.state.UpdatedAt = time.Now()
+}
+
+// Finish marks the deploy done with the deploy response
+func (d *Deploy) Finish(result map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Status, d.state.Stage, d.state.Result = StatusDone, "", result
+	d.state.UpdatedAt = time.Now()
+	d.state.DoneAt = d.state.UpdatedAt
+}
+
+// Fail marks the deploy failed
+func (d *Deploy) Fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.state.Status, d.state.Stage, d.state.Error = StatusFailed, "", err.Error()
+	d.err = err
+	d.state.UpdatedAt = time.Now()
+	d.state.DoneAt = d.state.UpdatedAt
+}
+
+// Tracker holds background deploys in memory. Statuses don't survive a
+// restart, and neither do the deploys they describe.
+type Tracker struct {
+	mu      sync.Mutex
+	deploys map[string]*Deploy
+	now     func() time.Time
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{deploys: map[string]*Deploy{}, now: time.Now}
+}
+
+// Start registers a deploy to site. Only one deploy per site runs at a
+// time, so two can't interleave their files.
+func (t *Tracker) Start(site, deployedBy string) (*Deploy, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for id, d := range t.deploys {
+		s := d.State()
+		if s.Status == StatusProcessing && s.Site == site {
+			return nil, ErrInProgress
+		}
+		if s.Status != StatusProcessing && now.Sub(s.DoneAt) > retention {
+			delete(t.deploys, id)
+		}
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	d := &Deploy{state: State{
+		ID:         "dep_" + hex.EncodeToString(b),
+		Site:       site,
+		Status:     StatusProcessing,
+		Stage:      StageQueued,
+		DeployedBy: deployedBy,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}}
+	t.deploys[d.state.ID] = d
+	return d, nil
+}
+
+// Get returns a deploy by ID
+func (t *Tracker) Get(id string) (*Deploy, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.deploys[id]
+	return d, ok
+}
@@ -0,0 +1,68 @@
+package deploys
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartOnePerSite(t *testing.T) {
+	tr := NewTracker()
+
+	d, err := tr.Start("blog", "api_key:ci")
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if !strings.HasPrefix(d.ID(), "dep_") {
+		t.Errorf("Expected a dep_ ID, got %q", d.ID())
+	}
+	if s := d.State(); s.Status != StatusProcessing || s.Stage != StageQueued {
+		t.Errorf("Expected a queued deploy, got %+v", s)
+	}
+
+	if _, err := tr.Start("blog", "api_key:ci"); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Expected a second deploy to the site to fail, got %v", err)
+	}
+	if _, err := tr.Start("docs", "api_key:ci"); err != nil {
+		t.Errorf("Expected another site unaffected, got %v", err)
+	}
+
+	d.Progress(3, 10)
+	if s := d.State(); s.Stage != StageWriting || s.FilesWritten != 3 || s.FilesTotal != 10 {
+		t.Errorf("Expected 3 of 10 files written, got %+v", s)
+	}
+
+	d.Finish(map[string]interface{}{"site": "blog"})
+	if s := d.State(); s.Status != StatusDone || s.Result["site"] != "blog" || s.DoneAt.IsZero() {
+		t.Errorf("Expected a finished deploy, got %+v", s)
+	}
+	if _, err := tr.Start("blog", "api_key:ci"); err != nil {
+		t.Errorf("Expected a new deploy once the last finished, got %v", err)
+	}
+}
+
+func TestFailAndPrune(t *testing.T) {
+	tr := NewTracker()
+
+	d, err := tr.Start("blog", "api_key:ci")
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	d.Fail(errors.New("disk full"))
+	if s := d.State(); s.Status != StatusFailed || s.Error != "disk full" || d.Err() == nil {
+		t.Errorf("Expected a failed deploy, got %+v", s)
+	}
+	if _, ok := tr.Get(d.ID()); !ok {
+		t.Fatal("Expected the failed deploy kept")
+	}
+
+	// Finished deploys are forgotten once past retention
+	tr.now = func() time.Time { return time.Now().Add(retention + time.Minute) }
+	if _, err := tr.Start("docs", "api_key:ci"); err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	if _, ok := tr.Get(d.ID()); ok {
+		t.Error("Expected the old deploy pruned")
+	}
+}
@@ -4,14 +4,15 @@ import "fmt"
 
 // Error codes for JS error handling.
 const (
-	CodeBlocked = "NET_BLOCKED" // Allowlist/IP rejected (not retryable)
-	CodeTimeout = "NET_TIMEOUT" // Upstream timeout (not retryable)
-	CodeLimit   = "NET_LIMIT"   // Concurrency limit hit (retryable)
-	CodeBudget  = "NET_BUDGET"  // Insufficient time budget (retryable)
-	CodeSize    = "NET_SIZE"    // Request/response body too large (not retryable)
-	CodeError   = "NET_ERROR"   // Other network error (not retryable)
-	CodeAuth    = "NET_AUTH"    // Secret not found or domain mismatch (not retryable)
-	CodeRate    = "NET_RATE"    // Rate limited (retryable)
+	CodeBlocked   = "NET_BLOCKED"   // Allowlist/IP rejected (not retryable)
+	CodeTimeout   = "NET_TIMEOUT"   // Upstream timeout (not retryable)
+	CodeLimit     = "NET_LIMIT"     // Concurrency limit hit (retryable)
+	CodeBudget    = "NET_BUDGET"    // Insufficient time budget (retryable)
+	CodeSize      = "NET_SIZE"      // Request/response body too large (not retryable)
+	CodeError     = "NET_ERROR"     // Other network error (not retryable)
+	CodeAuth      = "NET_AUTH"      // Secret not found or domain mismatch (not retryable)
+	CodeRate      = "NET_RATE"      // Rate limited (retryable)
+	CodeThrottled = "NET_THROTTLED" // App in degraded mode, cache miss (retryable)
 )
 
 // EgressError is a structured error with a stable code for JS error handling.
@@ -64,3 +65,7 @@ func errAuth(msg string) *EgressError {
 func errRate(msg string) *EgressError {
 	return &EgressError{Code: CodeRate, Message: msg, Retryable: true}
 }
+
+func errThrottled(msg string) *EgressError {
+	return &EgressError{Code: CodeThrottled, Message: msg, Retryable: true}
+}
@@ -0,0 +1,209 @@
+package egress
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// FixturesFile is where an app keeps its recorded fetch responses, for
+// fazt app test. Deploys skip it.
+const FixturesFile = "fetch.fixtures.json"
+
+// Fixture modes
+const (
+	FixturesRecord = "record" // fetches go out and their responses are kept
+	FixturesReplay = "replay" // fetches are answered from fixtures only
+)
+
+// Fixture is the recorded response to one outbound request
+type Fixture struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     string          `json:"body,omitempty"` // request body
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureResponse is what a fixture answers with
+type FixtureResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body"`
+}
+
+func (f Fixture) key() string {
+	return f.Method + " " + f.URL + "\n" + f.Body
+}
+
+// FixtureSet is an app's recorded fetch responses. Requests are matched on
+// method, URL and body; repeats of a request get its recordings in order,
+// then the last one again.
+type FixtureSet struct {
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// NewFixtureSet returns a set holding fixtures
+func NewFixtureSet(fixtures []Fixture) *FixtureSet {
+	s := &FixtureSet{}
+	for _, f := range fixtures {
+		f.Method = normalizeMethod(f.Method)
+		s.fixtures = append(s.fixtures, f)
+	}
+	return s
+}
+
+// LoadFixtures reads a fixtures file; a missing one is an empty set
+func LoadFixtures(path string) (*FixtureSet, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewFixtureSet(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewFixtureSet(fixtures), nil
+}
+
+// Save writes the set to a fixtures file
+func (s *FixtureSet) Save(path string) error {
+	data, err := json.MarshalIndent(s.All(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// All returns the set's fixtures, in recording order
+func (s *FixtureSet) All() []Fixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Fixture{}, s.fixtures...)
+}
+
+// Len returns the number of fixtures in the set
+func (s *FixtureSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.fixtures)
+}
+
+// Session returns a view of the set for one execution (a request, a job or
+// a test), which counts repeats of a request from zero
+func (s *FixtureSet) Session() *FixtureSession {
+	return &FixtureSession{set: s, seen: make(map[string]int)}
+}
+
+// FixtureSession replays and records fixtures for one execution
+type FixtureSession struct {
+	set  *FixtureSet
+	seen map[string]int // request key -> times seen
+}
+
+// Replay returns the response recorded for the request, or false when
+// there is none
+func (fs *FixtureSession) Replay(method, url, body string) (FixtureResponse, bool) {
+	key := Fixture{Method: normalizeMethod(method), URL: url, Body: body}.key()
+	n := fs.seen[key]
+	fs.seen[key]++
+
+	fs.set.mu.Lock()
+	defer fs.set.mu.Unlock()
+	var match *Fixture
+	for i := range fs.set.fixtures {
+		if fs.set.fixtures[i].key() != key {
+			continue
+		}
+		match = &fs.set.fixtures[i]
+		if n == 0 {
+			break
+		}
+		n--
+	}
+	if match == nil {
+		return FixtureResponse{}, false
+	}
+	resp := match.Response
+	if resp.Headers == nil {
+		resp.Headers = map[string]string{}
+	}
+	return resp, true
+}
+
+// Record keeps the response to a request, replacing the recording of the
+// same repeat of it, if any
+func (fs *FixtureSession) Record(method, url, body string, resp FixtureResponse) {
+	f := Fixture{Method: normalizeMethod(method), URL: url, Body: body, Response: resp}
+	key := f.key()
+	n := fs.seen[key]
+	fs.seen[key]++
+
+	fs.set.mu.Lock()
+	defer fs.set.mu.Unlock()
+	for i := range fs.set.fixtures {
+		if fs.set.fixtures[i].key() != key {
+			continue
+		}
+		if n == 0 {
+			fs.set.fixtures[i] = f
+			return
+		}
+		n--
+	}
+	fs.set.fixtures = append(fs.set.fixtures, f)
+}
+
+func normalizeMethod(method string) string {
+	if method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(method)
+}
+
+// Dev servers can record an app's fetches and replay them, so its handlers
+// run the same every time, offline. Held in memory; gone on restart.
+type appFixtures struct {
+	mode string
+	set  *FixtureSet
+}
+
+var (
+	fixtureApps   = make(map[string]*appFixtures)
+	fixtureAppsMu sync.RWMutex
+)
+
+// SetAppFixtures puts an app's fetches in record or replay mode with set
+func SetAppFixtures(appID, mode string, set *FixtureSet) error {
+	if mode != FixturesRecord && mode != FixturesReplay {
+		return fmt.Errorf("mode must be %q or %q", FixturesRecord, FixturesReplay)
+	}
+	fixtureAppsMu.Lock()
+	fixtureApps[appID] = &appFixtures{mode: mode, set: set}
+	fixtureAppsMu.Unlock()
+	return nil
+}
+
+// ClearAppFixtures turns record and replay off for an app and drops its
+// fixtures
+func ClearAppFixtures(appID string) {
+	fixtureAppsMu.Lock()
+	delete(fixtureApps, appID)
+	fixtureAppsMu.Unlock()
+}
+
+// AppFixtures returns an app's fixture mode and set; mode is "" when off
+func AppFixtures(appID string) (string, *FixtureSet) {
+	fixtureAppsMu.RLock()
+	defer fixtureAppsMu.RUnlock()
+	if a := fixtureApps[appID]; a != nil {
+		return a.mode, a.set
+	}
+	return "", nil
+}
@@ -0,0 +1,102 @@
+package egress
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestFixtureSession_Replay(t *testing.T) {
+	set := NewFixtureSet([]Fixture{
+		{Method: "get", URL: "https://api.test/n", Response: FixtureResponse{Status: 200, Body: "1"}},
+		{URL: "https://api.test/n", Response: FixtureResponse{Status: 200, Body: "2"}},
+		{Method: "POST", URL: "https://api.test/n", Body: `{"a":1}`, Response: FixtureResponse{Status: 201, Body: "posted"}},
+	})
+
+	// Repeats get the recordings in order, then the last one again
+	s := set.Session()
+	for _, want := range []string{"1", "2", "2"} {
+		resp, ok := s.Replay("GET", "https://api.test/n", "")
+		if !ok || resp.Body != want {
+			t.Errorf("Replay = %q, %v; want %q", resp.Body, ok, want)
+		}
+	}
+	// A new session starts over
+	if resp, _ := set.Session().Replay("", "https://api.test/n", ""); resp.Body != "1" {
+		t.Errorf("Expected a new session to start at the first recording, got %q", resp.Body)
+	}
+
+	// Matched on method and body too
+	if resp, ok := s.Replay("POST", "https://api.test/n", `{"a":1}`); !ok || resp.Status != 201 {
+		t.Errorf("Expected the POST fixture, got %+v, %v", resp, ok)
+	}
+	if _, ok := s.Replay("POST", "https://api.test/n", `{"a":2}`); ok {
+		t.Error("Expected no fixture for another body")
+	}
+}
+
+func TestFixtureSession_Record(t *testing.T) {
+	set := NewFixtureSet(nil)
+	s := set.Session()
+	s.Record("GET", "https://api.test/n", "", FixtureResponse{Status: 200, Body: "1"})
+	s.Record("GET", "https://api.test/n", "", FixtureResponse{Status: 200, Body: "2"})
+
+	// Recording again replaces the same repeat instead of adding
+	set.Session().Record("get", "https://api.test/n", "", FixtureResponse{Status: 200, Body: "1b"})
+	all := set.All()
+	if len(all) != 2 || all[0].Response.Body != "1b" || all[1].Response.Body != "2" {
+		t.Errorf("Unexpected fixtures %+v", all)
+	}
+
+	path := filepath.Join(t.TempDir(), FixturesFile)
+	if err := set.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadFixtures(path)
+	if err != nil {
+		t.Fatalf("LoadFixtures: %v", err)
+	}
+	if loaded.Len() != 2 {
+		t.Errorf("Expected 2 fixtures after a round trip, got %d", loaded.Len())
+	}
+
+	missing, err := LoadFixtures(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil || missing.Len() != 0 {
+		t.Errorf("Expected an empty set for a missing file, got %v, %v", missing, err)
+	}
+}
+
+func TestInjectNetNamespace_ReplayFixtures(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	proxy := testProxy(t, db, "")
+
+	set := NewFixtureSet([]Fixture{{URL: "https://api.test/rates", Response: FixtureResponse{Status: 200, Body: `{"eur":0.9}`}}})
+	if err := SetAppFixtures("app1", FixturesReplay, set); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ClearAppFixtures("app1") })
+
+	vm := goja.New()
+	vm.Set("fazt", vm.NewObject())
+	if err := InjectNetNamespace(vm, proxy, "app1", context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := vm.RunString(`fazt.net.fetch('https://api.test/rates').json().eur`)
+	if err != nil {
+		t.Fatalf("replayed fetch failed: %v", err)
+	}
+	if val.ToFloat() != 0.9 {
+		t.Errorf("Expected 0.9, got %v", val)
+	}
+
+	// Nothing goes out in replay mode
+	_, err = vm.RunString(`fazt.net.fetch('https://api.test/other')`)
+	if err == nil || !strings.Contains(err.Error(), "no fixture for GET https://api.test/other") {
+		t.Errorf("Expected a missing fixture error, got %v", err)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/chaos"
+	"github.com/fazt-sh/fazt/internal/sandbox"
 	"github.com/fazt-sh/fazt/internal/timeout"
+	"github.com/fazt-sh/fazt/internal/usage"
 )
 
 // InjectNetNamespace adds the fazt.net namespace with fetch to a Goja VM.
@@ -17,6 +21,13 @@ func InjectNetNamespace(vm *goja.Runtime, proxy *EgressProxy, appID string,
 	netObj := vm.NewObject()
 	callCount := 0
 
+	// Dev servers may record the app's fetches or replay recorded ones
+	fixtureMode, fixtures := AppFixtures(appID)
+	var session *FixtureSession
+	if fixtures != nil {
+		session = fixtures.Session()
+	}
+
 	netObj.Set("fetch", func(call goja.FunctionCall) goja.Value {
 		callCount++
 		if callCount > proxy.callLimit {
@@ -33,6 +44,24 @@ func InjectNetNamespace(vm *goja.Runtime, proxy *EgressProxy, appID string,
 		rawURL := call.Argument(0).String()
 		opts := parseJSOptions(vm, call)
 
+		// Apps in chaos mode may have the fetch delayed or failed
+		if err := chaos.Egress(ctx); err != nil {
+			panic(vm.NewGoError(&EgressError{Code: CodeError, Message: err.Error()}))
+		}
+
+		if fixtureMode == FixturesReplay {
+			fixture, ok := session.Replay(opts.Method, rawURL, opts.Body)
+			if !ok {
+				panic(vm.NewGoError(errNet(fmt.Sprintf("no fixture for %s %s (fetches are being replayed)", normalizeMethod(opts.Method), rawURL))))
+			}
+			return responseToJS(vm, &FetchResponse{
+				Status:  fixture.Status,
+				OK:      fixture.Status >= 200 && fixture.Status < 300,
+				Headers: fixture.Headers,
+				body:    []byte(fixture.Body),
+			})
+		}
+
 		// Get net context from budget
 		netCtx, cancel, err := budget.NetContext(ctx)
 		if err != nil {
@@ -44,10 +73,19 @@ func InjectNetNamespace(vm *goja.Runtime, proxy *EgressProxy, appID string,
 		}
 		defer cancel()
 
+		start := time.Now()
 		resp, err := proxy.Fetch(netCtx, appID, rawURL, opts)
 		if err != nil {
+			usage.Egress(ctx, len(opts.Body), time.Since(start))
 			panic(vm.NewGoError(err))
 		}
+		usage.Egress(ctx, len(opts.Body)+len(resp.body), time.Since(start))
+		if err := sandbox.Alloc(ctx, len(resp.body)); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if fixtureMode == FixturesRecord {
+			session.Record(opts.Method, rawURL, opts.Body, FixtureResponse{Status: resp.Status, Headers: resp.Headers, Body: resp.Text()})
+		}
 
 		return responseToJS(vm, resp)
 	})
@@ -14,6 +14,7 @@ import (
 	"time"
 
 
+	"github.com/fazt-sh/fazt/internal/security"
 	"github.com/fazt-sh/fazt/internal/system"
 )
 
@@ -52,6 +53,38 @@ func isBlockedIP(ip net.IP) bool {
 	return false
 }
 
+// safeDialer connects to addresses DialPublic has checked
+var safeDialer = &net.Dialer{
+	Timeout:   5 * time.Second,
+	KeepAlive: 10 * time.Second,
+}
+
+// DialPublic dials addr only if its host resolves to public addresses, so
+// URLs that serverless code or its users supply can't reach the server's
+// network. Use it as an http.Transport's DialContext.
+func DialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errBlocked(fmt.Sprintf("invalid address: %s", addr))
+	}
+
+	// Resolve DNS
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, errNet(fmt.Sprintf("DNS resolution failed: %v", err))
+	}
+
+	// Check every resolved IP
+	for _, ipAddr := range ips {
+		if isBlockedIP(ipAddr.IP) {
+			return nil, errBlocked(fmt.Sprintf("blocked IP %s for host %s", ipAddr.IP, host))
+		}
+	}
+
+	// Connect to the first valid IP
+	return safeDialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+}
+
 // isIPLiteral returns true if the host is a raw IP address (not a domain).
 func isIPLiteral(host string) bool {
 	// Strip brackets for IPv6 literals like [::1]
@@ -117,6 +150,7 @@ type EgressProxy struct {
 	rateLimiter  *RateLimiter
 	logger       *NetLogger
 	cache        *NetCache
+	cacheOnly    func(appID string) bool
 	callLimit    int
 	maxReqBody   int64
 	maxRespBody  int64
@@ -125,6 +159,7 @@ type EgressProxy struct {
 	globalLimit  int32
 	appConns     sync.Map   // map[string]*int32
 	globalConns  int32
+	newDomains   sync.Map   // allowlist entry IDs already reported as new
 }
 
 // NewEgressProxy creates a new EgressProxy with settings from system.Limits.Net.
@@ -142,36 +177,9 @@ func NewEgressProxy(allowlist *Allowlist) *EgressProxy {
 		globalLimit:  int32(netLimits.Concurrency),
 	}
 
-	// Safe dialer: validates resolved IPs before connecting
-	safeDialer := &net.Dialer{
-		Timeout:   5 * time.Second,
-		KeepAlive: 10 * time.Second,
-	}
-
 	transport := &http.Transport{
-		Proxy: nil, // CRITICAL: ignore HTTP_PROXY/HTTPS_PROXY env
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			host, port, err := net.SplitHostPort(addr)
-			if err != nil {
-				return nil, errBlocked(fmt.Sprintf("invalid address: %s", addr))
-			}
-
-			// Resolve DNS
-			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
-			if err != nil {
-				return nil, errNet(fmt.Sprintf("DNS resolution failed: %v", err))
-			}
-
-			// Check every resolved IP
-			for _, ipAddr := range ips {
-				if isBlockedIP(ipAddr.IP) {
-					return nil, errBlocked(fmt.Sprintf("blocked IP %s for host %s", ipAddr.IP, host))
-				}
-			}
-
-			// Connect to the first valid IP
-			return safeDialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
-		},
+		Proxy:                  nil, // CRITICAL: ignore HTTP_PROXY/HTTPS_PROXY env
+		DialContext:            DialPublic,
 		DisableCompression:     true, // Raw bodies so LimitReader is accurate
 		TLSHandshakeTimeout:   5 * time.Second,
 		ResponseHeaderTimeout: 5 * time.Second,
@@ -242,6 +250,30 @@ type contextKey string
 
 const ctxKeyAppID contextKey = "egress_app_id"
 
+// noteNewDomain records a security event for the first request through an
+// allowlist entry added within security.NewDomainWindow
+func (p *EgressProxy) noteNewDomain(entry *AllowlistEntry, host, appID string) {
+	if p.allowlist.db == nil || time.Since(time.Unix(entry.CreatedAt, 0)) > security.NewDomainWindow {
+		return
+	}
+	if _, seen := p.newDomains.LoadOrStore(entry.ID, true); seen {
+		return
+	}
+
+	go func() {
+		// Survives restarts: the first request may have been made before one
+		if seen, err := security.Recorded(p.allowlist.db, security.EventEgressDomain, entry.Domain, entry.CreatedAt); err != nil || seen {
+			return
+		}
+		security.Record(p.allowlist.db, security.Event{
+			Type:    security.EventEgressDomain,
+			Actor:   appID,
+			Subject: entry.Domain,
+			Details: map[string]interface{}{"host": host, "entry_app_id": entry.AppID, "allowlisted_at": entry.CreatedAt},
+		})
+	}()
+}
+
 // Fetch performs a validated outbound HTTP request.
 func (p *EgressProxy) Fetch(ctx context.Context, appID string, rawURL string, opts FetchOptions) (*FetchResponse, error) {
 	// Parse and validate URL
@@ -284,6 +316,7 @@ func (p *EgressProxy) Fetch(ctx context.Context, appID string, rawURL string, op
 	var domainMaxResp int64
 	if p.allowlist != nil {
 		if entry := p.allowlist.entryFor(host, appID); entry != nil {
+			p.noteNewDomain(entry, host, appID)
 			domainRate = entry.RateLimit
 			domainBurst = entry.RateBurst
 			if entry.MaxResponse > 0 {
@@ -332,6 +365,11 @@ func (p *EgressProxy) Fetch(ctx context.Context, appID string, rawURL string, op
 		}
 	}
 
+	// Throttled apps only get what the cache already holds
+	if p.cacheOnly != nil && p.cacheOnly(appID) {
+		return nil, errThrottled(fmt.Sprintf("app %s is throttled; only cached responses are served", appID))
+	}
+
 	// Acquire concurrency slots
 	atomic.AddInt32(&p.globalConns, 1)
 	defer atomic.AddInt32(&p.globalConns, -1)
@@ -473,6 +511,12 @@ func (p *EgressProxy) SetCache(cache *NetCache) {
 	p.cache = cache
 }
 
+// SetCacheOnly sets the check for apps whose fetches are limited to cached
+// responses.
+func (p *EgressProxy) SetCacheOnly(fn func(appID string) bool) {
+	p.cacheOnly = fn
+}
+
 // GlobalConnections returns the current global connection count (for testing).
 func (p *EgressProxy) GlobalConnections() int32 {
 	return atomic.LoadInt32(&p.globalConns)
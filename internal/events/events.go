@@ -0,0 +1,100 @@
+// Package events is an in-process bus for things that happen to an app:
+// deploys, blob writes, end-user sign-ups, failed jobs, form submissions and
+// received email.
+// Emitters publish an Event without knowing who listens; the worker pool
+// subscribes and runs the JS handlers apps register for them in
+// manifest.json ("on": {"s3.put": "hooks/thumbnail.js"}).
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Event types apps can register handlers for
+const (
+	AppDeployed   = "app.deployed"   // Files replaced by a deploy
+	S3Put         = "s3.put"         // Blob stored through fazt.app.s3 or fazt.app.user.s3
+	UserCreated   = "user.created"   // App end-user signed in for the first time
+	JobFailed     = "job.failed"     // Job failed its last attempt and was dead-lettered
+	FormSubmitted = "form.submitted" // Visitor posted a form to /__forms/<name> (spam excluded)
+	MailReceived  = "mail.received"  // Email arrived for <app>@<mail domain>
+)
+
+// Types lists every event type, in the order they're documented
+var Types = []string{AppDeployed, S3Put, UserCreated, JobFailed, FormSubmitted, MailReceived}
+
+// Event is something that happened to an app
+type Event struct {
+	Type  string                 `json:"type"`
+	AppID string                 `json:"app_id"`
+	Data  map[string]interface{} `json:"data"`
+	Time  time.Time              `json:"time"`
+}
+
+// Map returns the event as a plain map, the way JS handlers see it
+func (e Event) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   e.Type,
+		"app_id": e.AppID,
+		"data":   e.Data,
+		"time":   e.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+// Handler receives emitted events
+type Handler func(Event)
+
+type subscriber struct {
+	handler Handler
+}
+
+var (
+	subscribers []*subscriber
+	mu          sync.RWMutex
+)
+
+// Subscribe registers a handler for every event. The returned function
+// removes it.
+func Subscribe(h Handler) func() {
+	s := &subscriber{handler: h}
+	mu.Lock()
+	subscribers = append(subscribers, s)
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for i, other := range subscribers {
+			if other == s {
+				subscribers = append(subscribers[:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Emit publishes an event. Handlers run in their own goroutines, so
+// emitting never blocks the deploy, write or login that caused it.
+func Emit(eventType, appID string, data map[string]interface{}) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	e := Event{Type: eventType, AppID: appID, Data: data, Time: time.Now()}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, s := range subscribers {
+		go s.handler(e)
+	}
+}
+
+// Valid reports whether eventType is a known event type
+func Valid(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
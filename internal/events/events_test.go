@@ -0,0 +1,43 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmitSubscribe(t *testing.T) {
+	got := make(chan Event, 1)
+	unsubscribe := Subscribe(func(e Event) { got <- e })
+
+	Emit(S3Put, "app1", map[string]interface{}{"path": "a.jpg"})
+	select {
+	case e := <-got:
+		if e.Type != S3Put || e.AppID != "app1" || e.Data["path"] != "a.jpg" || e.Time.IsZero() {
+			t.Errorf("Unexpected event: %+v", e)
+		}
+		if m := e.Map(); m["type"] != S3Put || m["app_id"] != "app1" {
+			t.Errorf("Unexpected event map: %v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the event delivered")
+	}
+
+	unsubscribe()
+	Emit(AppDeployed, "app1", nil)
+	select {
+	case e := <-got:
+		t.Errorf("Expected no event after unsubscribing, got %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, eventType := range Types {
+		if !Valid(eventType) {
+			t.Errorf("Expected %s valid", eventType)
+		}
+	}
+	if Valid("s3.delete") {
+		t.Error("Expected an unknown event type refused")
+	}
+}
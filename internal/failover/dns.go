@@ -0,0 +1,211 @@
+package failover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dnsTimeout bounds updating a single record
+const dnsTimeout = 30 * time.Second
+
+// DNS provider names
+const (
+	ProviderCloudflare = "cloudflare"
+	ProviderWebhook    = "webhook"
+)
+
+// DNSProvider points a DNS record at an address
+type DNSProvider interface {
+	Name() string
+	SetRecord(ctx context.Context, name, ip string) error
+}
+
+// DNSProviderFactory makes a provider from its settings, rejecting settings
+// it can't work with
+type DNSProviderFactory func(settings map[string]string) (DNSProvider, error)
+
+var dnsProviders = struct {
+	sync.RWMutex
+	m map[string]DNSProviderFactory
+}{m: map[string]DNSProviderFactory{
+	ProviderCloudflare: newCloudflare,
+	ProviderWebhook:    newWebhook,
+}}
+
+// RegisterDNSProvider adds a DNS provider plugin, for DNS hosts other than
+// the built-in ones. Registering a name again replaces it.
+func RegisterDNSProvider(name string, factory DNSProviderFactory) {
+	dnsProviders.Lock()
+	defer dnsProviders.Unlock()
+	dnsProviders.m[name] = factory
+}
+
+// DNSProviderNames lists the providers that can be configured, sorted
+func DNSProviderNames() []string {
+	dnsProviders.RLock()
+	defer dnsProviders.RUnlock()
+	names := make([]string, 0, len(dnsProviders.m))
+	for name := range dnsProviders.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewDNSProvider makes the named provider from its settings
+func NewDNSProvider(name string, settings map[string]string) (DNSProvider, error) {
+	dnsProviders.RLock()
+	factory, ok := dnsProviders.m[name]
+	dnsProviders.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown DNS provider %q; one of %v", ErrInvalid, name, DNSProviderNames())
+	}
+	return factory(settings)
+}
+
+// recordType is the record an address goes in
+func recordType(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return "AAAA"
+	}
+	return "A"
+}
+
+var dnsClient = &http.Client{Timeout: dnsTimeout}
+
+// cloudflareAPI is Cloudflare's API, replaced in tests
+var cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// Cloudflare updates records in a Cloudflare zone. Settings: zone_id, and
+// api_token with DNS edit permission on it.
+type Cloudflare struct {
+	ZoneID   string
+	APIToken string
+}
+
+func newCloudflare(settings map[string]string) (DNSProvider, error) {
+	c := &Cloudflare{ZoneID: settings["zone_id"], APIToken: settings["api_token"]}
+	if c.ZoneID == "" || c.APIToken == "" {
+		return nil, fmt.Errorf("%w: cloudflare needs zone_id and api_token", ErrInvalid)
+	}
+	return c, nil
+}
+
+func (c *Cloudflare) Name() string { return ProviderCloudflare }
+
+// SetRecord updates the record of that name and type, or creates it.
+// Whether the record is proxied through Cloudflare is kept as it was.
+func (c *Cloudflare) SetRecord(ctx context.Context, name, ip string) error {
+	records := "/zones/" + url.PathEscape(c.ZoneID) + "/dns_records"
+	var found struct {
+		Result []struct {
+			ID string `json:"id"`
+		} `json:"result"`
+	}
+	query := url.Values{"type": {recordType(ip)}, "name": {name}}
+	if err := c.call(ctx, "GET", records+"?"+query.Encode(), nil, &found); err != nil {
+		return err
+	}
+
+	record := map[string]interface{}{
+		"type":    recordType(ip),
+		"name":    name,
+		"content": ip,
+		"ttl":     60,
+	}
+	if len(found.Result) > 0 {
+		return c.call(ctx, "PATCH", records+"/"+url.PathEscape(found.Result[0].ID), record, nil)
+	}
+	return c.call(ctx, "POST", records, record, nil)
+}
+
+// call makes a Cloudflare API request, decoding the response into out
+func (c *Cloudflare) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, cloudflareAPI+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := dnsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(data, &result)
+	if !result.Success {
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("cloudflare: %s", result.Errors[0].Message)
+		}
+		return fmt.Errorf("cloudflare: %s", resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// Webhook POSTs {"name", "type", "ip"} for each record to a URL, for DNS
+// hosts without a provider, e.g. through a script. Settings: url, and an
+// optional secret sent as a bearer token.
+type Webhook struct {
+	URL    string
+	Secret string
+}
+
+func newWebhook(settings map[string]string) (DNSProvider, error) {
+	w := &Webhook{URL: settings["url"], Secret: settings["secret"]}
+	if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: webhook needs an http(s) url", ErrInvalid)
+	}
+	return w, nil
+}
+
+func (w *Webhook) Name() string { return ProviderWebhook }
+
+func (w *Webhook) SetRecord(ctx context.Context, name, ip string) error {
+	body, _ := json.Marshal(map[string]string{"name": name, "type": recordType(ip), "ip": ip})
+	req, err := http.NewRequestWithContext(ctx, "POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fazt-failover")
+	if w.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+w.Secret)
+	}
+	resp, err := dnsClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Package failover implements disaster recovery across two peers: a primary
+// replicates its apps to a standby peer every minute, and promoting the
+// standby points the server's DNS records at it and makes it the primary.
+// The standby refuses replicas once promoted, so a primary that comes back
+// can't overwrite what changed since.
+package failover
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// Roles
+const (
+	RolePrimary = "primary" // Serves the apps; replicates them to its standby, if it has one
+	RoleStandby = "standby" // Receives replicas until promoted
+)
+
+// Common errors
+var (
+	ErrNotStandby = errors.New("this server isn't a standby")
+	ErrInvalid    = errors.New("invalid failover setting")
+)
+
+// State is a server's side of failover
+type State struct {
+	Role string `json:"role"`
+
+	// Primary
+	StandbyURL      string `json:"standby_url,omitempty"`
+	StandbyToken    string `json:"-"`
+	LastSyncAt      *int64 `json:"last_sync_at,omitempty"`
+	LastSyncBytes   int64  `json:"last_sync_bytes,omitempty"`
+	LastSyncError   string `json:"last_sync_error,omitempty"`
+	lastFingerprint string
+
+	// Standby
+	ReplicaAt   *int64    `json:"replica_at,omitempty"`
+	ReplicaApps int       `json:"replica_apps,omitempty"`
+	DNS         DNSConfig `json:"dns"`
+	PromotedAt  *int64    `json:"promoted_at,omitempty"`
+}
+
+// DNSConfig is how a standby repoints DNS at itself when promoted
+type DNSConfig struct {
+	Provider string            `json:"provider,omitempty"`
+	Settings map[string]string `json:"-"` // Credentials, so never shown
+	Records  []string          `json:"records,omitempty"`
+	IP       string            `json:"ip,omitempty"`
+}
+
+// Get returns the server's failover state; a server that was never set up
+// is a primary without a standby
+func Get(db *sql.DB) (*State, error) {
+	s := &State{Role: RolePrimary}
+	var standbyURL, standbyToken, syncError, fingerprint, provider, settings, records, ip sql.NullString
+	var syncBytes, replicaApps sql.NullInt64
+	err := db.QueryRow(`
+		SELECT role, standby_url, standby_token, last_sync_at, last_sync_bytes, last_sync_error, last_fingerprint,
+			replica_at, replica_apps, dns_provider, dns_settings, dns_records, dns_ip, promoted_at
+		FROM failover WHERE id = 1
+	`).Scan(&s.Role, &standbyURL, &standbyToken, &s.LastSyncAt, &syncBytes, &syncError, &fingerprint,
+		&s.ReplicaAt, &replicaApps, &provider, &settings, &records, &ip, &s.PromotedAt)
+	if err == sql.ErrNoRows {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.StandbyURL = standbyURL.String
+	s.StandbyToken = standbyToken.String
+	s.LastSyncBytes = syncBytes.Int64
+	s.LastSyncError = syncError.String
+	s.lastFingerprint = fingerprint.String
+	s.ReplicaApps = int(replicaApps.Int64)
+	s.DNS.Provider = provider.String
+	s.DNS.IP = ip.String
+	if settings.Valid {
+		json.Unmarshal([]byte(settings.String), &s.DNS.Settings)
+	}
+	if records.Valid {
+		json.Unmarshal([]byte(records.String), &s.DNS.Records)
+	}
+	return s, nil
+}
+
+// update sets columns of the failover row, creating it if needed
+func update(db *sql.DB, set string, args ...interface{}) error {
+	if _, err := db.Exec(`INSERT OR IGNORE INTO failover (id) VALUES (1)`); err != nil {
+		return err
+	}
+	_, err := db.Exec(`UPDATE failover SET `+set+`, updated_at = unixepoch() WHERE id = 1`, args...)
+	return err
+}
+
+// SetStandby makes this server replicate to the peer at url, which accepts
+// token as an admin API key. An empty url stops replicating.
+func SetStandby(db *sql.DB, url, token string) error {
+	url = strings.TrimSuffix(strings.TrimSpace(url), "/")
+	if url == "" {
+		return update(db, `standby_url = NULL, standby_token = NULL, last_sync_error = NULL, last_fingerprint = NULL`)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("%w: the standby's URL must start with http:// or https://", ErrInvalid)
+	}
+	if token == "" {
+		return fmt.Errorf("%w: the standby's API key is required", ErrInvalid)
+	}
+	// A new standby gets everything on the next sync
+	return update(db, `standby_url = ?, standby_token = ?, last_sync_error = NULL, last_fingerprint = NULL`, url, token)
+}
+
+// SetRole makes this server a primary or a standby. Becoming a standby lets
+// a primary's replicas replace this server's copies of its apps.
+func SetRole(db *sql.DB, role string) error {
+	switch role {
+	case RolePrimary:
+		return update(db, `role = ?`, role)
+	case RoleStandby:
+		return update(db, `role = ?, promoted_at = NULL`, role)
+	}
+	return fmt.Errorf("%w: role must be %s or %s", ErrInvalid, RolePrimary, RoleStandby)
+}
+
+// SetDNS sets the records a standby points at ip when promoted, and the
+// provider that updates them
+func SetDNS(db *sql.DB, cfg DNSConfig) error {
+	if cfg.Provider == "" {
+		return update(db, `dns_provider = NULL, dns_settings = NULL, dns_records = NULL, dns_ip = NULL`)
+	}
+	if _, err := NewDNSProvider(cfg.Provider, cfg.Settings); err != nil {
+		return err
+	}
+	if len(cfg.Records) == 0 {
+		return fmt.Errorf("%w: at least one DNS record is required", ErrInvalid)
+	}
+	if net.ParseIP(cfg.IP) == nil {
+		return fmt.Errorf("%w: %q isn't an IP address", ErrInvalid, cfg.IP)
+	}
+	settings, _ := json.Marshal(cfg.Settings)
+	records, _ := json.Marshal(cfg.Records)
+	return update(db, `dns_provider = ?, dns_settings = ?, dns_records = ?, dns_ip = ?`,
+		cfg.Provider, string(settings), string(records), cfg.IP)
+}
+
+// RecordResult is how repointing one DNS record went
+type RecordResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// Promote makes a standby the primary and points its DNS records at it.
+// The promotion stands even if a record couldn't be updated; the results
+// say which, so they can be fixed by hand.
+func Promote(ctx context.Context, db *sql.DB) ([]RecordResult, error) {
+	s, err := Get(db)
+	if err != nil {
+		return nil, err
+	}
+	if s.Role != RoleStandby {
+		return nil, ErrNotStandby
+	}
+	if err := update(db, `role = ?, promoted_at = unixepoch()`, RolePrimary); err != nil {
+		return nil, err
+	}
+
+	results := []RecordResult{}
+	if s.DNS.Provider == "" {
+		return results, nil
+	}
+	provider, err := NewDNSProvider(s.DNS.Provider, s.DNS.Settings)
+	if err != nil {
+		return results, err
+	}
+	for _, name := range s.DNS.Records {
+		r := RecordResult{Name: name}
+		rctx, cancel := context.WithTimeout(ctx, dnsTimeout)
+		if err := provider.SetRecord(rctx, name, s.DNS.IP); err != nil {
+			r.Error = err.Error()
+		}
+		cancel()
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// dir is where failover keeps replicas, next to the database at dbPath
+func dir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "failover")
+}
@@ -0,0 +1,174 @@
+package failover
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupFailoverDB(t *testing.T, name string) (*sql.DB, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".db")
+	db := dbtest.OpenFile(t, path)
+	return db, path
+}
+
+func TestReplicateAndPromote(t *testing.T) {
+	primary, primaryPath := setupFailoverDB(t, "primary")
+	standby, standbyPath := setupFailoverDB(t, "standby")
+
+	for _, q := range []string{
+		`INSERT INTO apps (id, title) VALUES ('app_blog', 'blog')`,
+		`INSERT INTO aliases (subdomain, type, targets) VALUES ('blog', 'proxy', '{"app_id":"app_blog"}')`,
+		`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+			VALUES ('blog', 'app_blog', 'index.html', 'hello', 5, 'text/html', 'h1')`,
+		`INSERT INTO app_kv (app_id, key, value) VALUES ('blog', 'visits', '3')`,
+	} {
+		if _, err := primary.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer standby-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if _, err := Receive(standby, standbyPath, r.Body); errors.Is(err, ErrNotStandby) {
+			w.WriteHeader(http.StatusConflict)
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var dnsCalls []map[string]string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var call map[string]string
+		json.NewDecoder(r.Body).Decode(&call)
+		dnsCalls = append(dnsCalls, call)
+	}))
+	defer hook.Close()
+
+	c := NewController(primary, primaryPath)
+	ctx := context.Background()
+	if err := SetStandby(primary, srv.URL+"/", "standby-key"); err != nil {
+		t.Fatalf("SetStandby failed: %v", err)
+	}
+
+	// A server that isn't a standby refuses replicas
+	if _, err := c.Sync(ctx, false); err == nil || !strings.Contains(err.Error(), "refused") {
+		t.Fatalf("Expected the replica refused before the peer is a standby, got %v", err)
+	}
+	if s, _ := Get(primary); s.LastSyncError == "" {
+		t.Error("Expected the failed sync recorded")
+	}
+
+	if err := SetRole(standby, RoleStandby); err != nil {
+		t.Fatal(err)
+	}
+	if sent, err := c.Sync(ctx, false); err != nil || sent == 0 {
+		t.Fatalf("Expected a replica sent, got %d, %v", sent, err)
+	}
+	var content, visits string
+	standby.QueryRow(`SELECT content FROM files WHERE site_id = 'blog' AND path = 'index.html'`).Scan(&content)
+	standby.QueryRow(`SELECT value FROM app_kv WHERE app_id = 'blog' AND key = 'visits'`).Scan(&visits)
+	if content != "hello" || visits != "3" {
+		t.Errorf("Expected the blog replicated, got %q, %q", content, visits)
+	}
+	if s, _ := Get(standby); s.ReplicaApps != 1 || s.ReplicaAt == nil {
+		t.Errorf("Expected the replica recorded, got %+v", s)
+	}
+
+	// Unchanged data isn't sent again; changed data is
+	if sent, err := c.Sync(ctx, false); err != nil || sent != 0 {
+		t.Errorf("Expected nothing sent without changes, got %d, %v", sent, err)
+	}
+	primary.Exec(`UPDATE app_kv SET value = '4', updated_at = updated_at + 1`)
+	if sent, err := c.Sync(ctx, false); err != nil || sent == 0 {
+		t.Errorf("Expected the change sent, got %d, %v", sent, err)
+	}
+	standby.QueryRow(`SELECT value FROM app_kv WHERE app_id = 'blog' AND key = 'visits'`).Scan(&visits)
+	if visits != "4" {
+		t.Errorf("Expected the change replicated, got %q", visits)
+	}
+
+	if err := SetDNS(standby, DNSConfig{Provider: ProviderWebhook, Settings: map[string]string{"url": hook.URL},
+		Records: []string{"example.com", "*.example.com"}, IP: "203.0.113.7"}); err != nil {
+		t.Fatalf("SetDNS failed: %v", err)
+	}
+	results, err := Promote(ctx, standby)
+	if err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Error != "" || len(dnsCalls) != 2 ||
+		dnsCalls[1]["name"] != "*.example.com" || dnsCalls[1]["ip"] != "203.0.113.7" || dnsCalls[1]["type"] != "A" {
+		t.Errorf("Expected both records repointed, got %+v, %v", results, dnsCalls)
+	}
+	if s, _ := Get(standby); s.Role != RolePrimary || s.PromotedAt == nil {
+		t.Errorf("Expected the standby promoted, got %+v", s)
+	}
+	if _, err := Promote(ctx, standby); !errors.Is(err, ErrNotStandby) {
+		t.Errorf("Expected a second promote refused, got %v", err)
+	}
+
+	// The old primary can't overwrite the promoted one
+	if _, err := c.Sync(ctx, true); err == nil {
+		t.Error("Expected replicas refused after promotion")
+	}
+}
+
+func TestCloudflareSetRecord(t *testing.T) {
+	var calls []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer cf-token" {
+			w.Write([]byte(`{"success":false,"errors":[{"message":"bad token"}]}`))
+			return
+		}
+		if r.Method == "GET" && r.URL.Query().Get("name") == "example.com" {
+			w.Write([]byte(`{"success":true,"result":[{"id":"rec1"}]}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"result":[]}`))
+	}))
+	defer api.Close()
+	old := cloudflareAPI
+	cloudflareAPI = api.URL
+	defer func() { cloudflareAPI = old }()
+
+	if _, err := NewDNSProvider(ProviderCloudflare, map[string]string{"zone_id": "z1"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Expected a missing api_token refused, got %v", err)
+	}
+	p, err := NewDNSProvider(ProviderCloudflare, map[string]string{"zone_id": "z1", "api_token": "cf-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := p.SetRecord(ctx, "example.com", "203.0.113.7"); err != nil {
+		t.Fatalf("SetRecord failed: %v", err)
+	}
+	if err := p.SetRecord(ctx, "new.example.com", "2001:db8::1"); err != nil {
+		t.Fatalf("SetRecord failed: %v", err)
+	}
+	want := []string{
+		"GET /zones/z1/dns_records", "PATCH /zones/z1/dns_records/rec1",
+		"GET /zones/z1/dns_records", "POST /zones/z1/dns_records",
+	}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+
+	bad, _ := NewDNSProvider(ProviderCloudflare, map[string]string{"zone_id": "z1", "api_token": "wrong"})
+	if err := bad.SetRecord(ctx, "example.com", "203.0.113.7"); err == nil || !strings.Contains(err.Error(), "bad token") {
+		t.Errorf("Expected Cloudflare's error, got %v", err)
+	}
+}
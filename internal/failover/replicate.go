@@ -0,0 +1,283 @@
+package failover
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/notify"
+)
+
+const (
+	// tickInterval is how often a primary checks for app data to replicate
+	tickInterval = time.Minute
+
+	// resyncInterval is how often a replica is sent even if nothing seems
+	// to have changed, for changes the fingerprint can't see, such as two
+	// writes to a row in the same second
+	resyncInterval = time.Hour
+
+	// pushTimeout bounds sending one replica
+	pushTimeout = 30 * time.Minute
+)
+
+// replicated are the tables a replica carries app data in, with the column
+// that moves when a row changes. A change to any of them is sent on the
+// next tick.
+var replicated = []struct{ table, changed string }{
+	{"apps", "updated_at"},
+	{"aliases", "updated_at"},
+	{"files", "updated_at"},
+	{"app_kv", "updated_at"},
+	{"app_docs", "updated_at"},
+	{"app_doc_search", "created_at"},
+	{"app_blobs", "updated_at"},
+	{"kv_store", "updated_at"},
+}
+
+// Controller replicates a primary's apps to its standby
+type Controller struct {
+	db     *sql.DB
+	dbPath string
+	notify func(title, message string)
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewController creates a controller for the database at dbPath, alerting
+// through notify when replication fails
+func NewController(db *sql.DB, dbPath string) *Controller {
+	return &Controller{
+		db:     db,
+		dbPath: dbPath,
+		notify: func(title, message string) {
+			if err := notify.SendOnce(notify.EventError, "failover", title, message); err != nil {
+				log.Printf("Failover: failed to send alert: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background replication loop
+func (c *Controller) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the replication loop
+func (c *Controller) Stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Tick replicates, alerting if it failed
+func (c *Controller) Tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	if _, err := c.Sync(ctx, false); err != nil {
+		log.Printf("Failover: replication failed: %v", err)
+		c.notify("Failover replication failing", "The standby isn't receiving this server's apps: "+err.Error())
+	}
+}
+
+// Sync sends the standby a replica of every app, unless nothing changed
+// since the last one it received, within resyncInterval, and force isn't
+// set. It returns the bytes
+// sent, or 0 if nothing was.
+func (c *Controller) Sync(ctx context.Context, force bool) (int64, error) {
+	s, err := Get(c.db)
+	if err != nil {
+		return 0, err
+	}
+	if s.Role != RolePrimary || s.StandbyURL == "" {
+		return 0, nil
+	}
+	fp, err := fingerprint(c.db)
+	if err != nil {
+		return 0, err
+	}
+	if !force && fp == s.lastFingerprint && s.LastSyncAt != nil &&
+		time.Since(time.Unix(*s.LastSyncAt, 0)) < resyncInterval {
+		return 0, nil
+	}
+
+	sent, err := c.push(ctx, s.StandbyURL, s.StandbyToken)
+	if err != nil {
+		update(c.db, `last_sync_error = ?`, err.Error())
+		return 0, err
+	}
+	return sent, update(c.db, `last_sync_at = unixepoch(), last_sync_bytes = ?, last_sync_error = NULL, last_fingerprint = ?`,
+		sent, fp)
+}
+
+// push snapshots the database and streams it, gzipped, to the standby
+func (c *Controller) push(ctx context.Context, standbyURL, token string) (int64, error) {
+	if err := os.MkdirAll(dir(c.dbPath), 0700); err != nil {
+		return 0, err
+	}
+	snapshot := filepath.Join(dir(c.dbPath), "outgoing.db")
+	os.Remove(snapshot)
+	if _, err := c.db.ExecContext(ctx, `VACUUM INTO ?`, snapshot); err != nil {
+		return 0, fmt.Errorf("snapshot: %w", err)
+	}
+	defer os.Remove(snapshot)
+
+	f, err := os.Open(snapshot)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	pr, pw := io.Pipe()
+	counted := &countingReader{r: pr}
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, f)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", standbyURL+"/api/failover/replica", counted)
+	if err != nil {
+		pr.Close()
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("send to standby: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(body))
+		if resp.StatusCode == http.StatusConflict {
+			return 0, fmt.Errorf("standby refused the replica; it may have been promoted: %s", msg)
+		}
+		return 0, fmt.Errorf("standby: %s: %s", resp.Status, msg)
+	}
+	return counted.n, nil
+}
+
+// countingReader counts the bytes read through it. Closing it, as the
+// HTTP client does when the standby answers early, stops the writer.
+type countingReader struct {
+	r io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) Close() error { return c.r.Close() }
+
+// fingerprint summarizes the replicated tables' row counts and latest
+// changes, so a primary only sends a replica when something changed
+func fingerprint(db *sql.DB) (string, error) {
+	parts := make([]string, 0, len(replicated))
+	for _, t := range replicated {
+		var n int64
+		var changed sql.NullString
+		err := db.QueryRow(`SELECT COUNT(*), MAX(`+t.changed+`) FROM `+t.table).Scan(&n, &changed)
+		if err != nil {
+			return "", fmt.Errorf("fingerprint %s: %w", t.table, err)
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%s", t.table, n, changed.String))
+	}
+	return strings.Join(parts, "|"), nil
+}
+
+// receiveMu keeps replicas from being written and applied at the same time
+var receiveMu sync.Mutex
+
+// Receive writes a gzipped replica sent by the primary next to the
+// database at dbPath and applies it, making every app in it match the
+// primary's. Only a standby accepts replicas.
+func Receive(db *sql.DB, dbPath string, body io.Reader) (*hosting.ReplicaResult, error) {
+	receiveMu.Lock()
+	defer receiveMu.Unlock()
+
+	s, err := Get(db)
+	if err != nil {
+		return nil, err
+	}
+	if s.Role != RoleStandby {
+		return nil, ErrNotStandby
+	}
+
+	if err := os.MkdirAll(dir(dbPath), 0700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir(dbPath), "replica.db")
+	if err := writeReplica(path, body); err != nil {
+		return nil, err
+	}
+
+	result, err := hosting.ApplyReplica(db, hosting.Backup{Name: "replica", Path: path, TakenAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return result, update(db, `replica_at = unixepoch(), replica_apps = ?`, result.Apps)
+}
+
+// writeReplica decompresses a replica to path, replacing the last one only
+// once it's complete
+func writeReplica(path string, body io.Reader) error {
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return fmt.Errorf("%w: the replica isn't gzipped: %v", ErrInvalid, err)
+	}
+	defer gz.Close()
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, gz); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		if errors.Is(err, gzip.ErrChecksum) || errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: the replica is incomplete: %v", ErrInvalid, err)
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,252 @@
+// Package freeze implements deploy freeze windows.
+//
+// A window starts whenever its cron schedule matches (in the server's local
+// time) and lasts for its duration, e.g. "0 18 * * 5" for 64 hours freezes
+// every weekend. While a window is in effect, deploys and alias changes to
+// apps carrying one of its tags are refused unless the request is marked as
+// an emergency, which is audited. A window without tags freezes every app.
+package freeze
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxDurationMinutes is the longest a freeze may last: a week
+const MaxDurationMinutes = 7 * 24 * 60
+
+// Common errors
+var (
+	ErrWindowNotFound  = errors.New("freeze window not found")
+	ErrNameRequired    = errors.New("name is required")
+	ErrNameTaken       = errors.New("a freeze window with that name already exists")
+	ErrInvalidSchedule = errors.New("invalid schedule")
+	ErrInvalidDuration = errors.New("duration must be between 1 minute and 7 days")
+)
+
+// Window is a stored freeze window
+type Window struct {
+	ID              int64    `json:"id"`
+	Name            string   `json:"name"`
+	Schedule        string   `json:"schedule"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Tags            []string `json:"tags"`
+	CreatedAt       int64    `json:"created_at"`
+
+	// ActiveUntil is set on windows in effect: when the freeze ends
+	ActiveUntil *int64 `json:"active_until,omitempty"`
+
+	schedule *Schedule
+}
+
+// Config describes a new freeze window
+type Config struct {
+	Name            string   `json:"name"`
+	Schedule        string   `json:"schedule"`
+	DurationMinutes int      `json:"duration_minutes"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// Validate checks the config and normalizes tags
+func (c *Config) Validate() error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return ErrNameRequired
+	}
+	c.Schedule = strings.Join(strings.Fields(c.Schedule), " ")
+	if _, err := ParseSchedule(c.Schedule); err != nil {
+		return err
+	}
+	if c.DurationMinutes < 1 || c.DurationMinutes > MaxDurationMinutes {
+		return ErrInvalidDuration
+	}
+	c.Tags = normalizeTags(c.Tags)
+	return nil
+}
+
+func normalizeTags(tags []string) []string {
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Describe returns a short human-readable form, e.g. "0 18 * * 5 for 64h"
+func (w *Window) Describe() string {
+	d := time.Duration(w.DurationMinutes) * time.Minute
+	s := strings.TrimSuffix(d.String(), "0s")
+	if d%time.Hour == 0 {
+		s = fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%s for %s", w.Schedule, s)
+}
+
+// Applies reports whether the window freezes an app with these tags
+func (w *Window) Applies(appTags []string) bool {
+	if len(w.Tags) == 0 {
+		return true
+	}
+	for _, t := range normalizeTags(appTags) {
+		for _, wt := range w.Tags {
+			if t == wt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Until returns when the freeze in effect at now ends, if one is. Freezes
+// started by overlapping matches end with the latest of them.
+func (w *Window) Until(now time.Time) (time.Time, bool) {
+	duration := time.Duration(w.DurationMinutes) * time.Minute
+	minute := now.Truncate(time.Minute)
+	for start := minute; start.Add(duration).After(now); start = start.Add(-time.Minute) {
+		if w.schedule.Matches(start) {
+			return start.Add(duration), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w *Window) setActive(now time.Time) {
+	if until, ok := w.Until(now); ok {
+		ts := until.Unix()
+		w.ActiveUntil = &ts
+	}
+}
+
+// Create stores a new freeze window, with ActiveUntil set if it is in
+// effect at now
+func Create(db *sql.DB, cfg Config, now time.Time) (*Window, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM freeze_windows WHERE name = ?`, cfg.Name).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrNameTaken
+	}
+
+	tags, _ := json.Marshal(cfg.Tags)
+	res, err := db.Exec(`
+		INSERT INTO freeze_windows (name, schedule, duration_minutes, tags, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, cfg.Name, cfg.Schedule, cfg.DurationMinutes, string(tags), now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := res.LastInsertId()
+	w, err := Get(db, id)
+	if err != nil {
+		return nil, err
+	}
+	w.setActive(now)
+	return w, nil
+}
+
+// Get returns a single freeze window
+func Get(db *sql.DB, id int64) (*Window, error) {
+	w, err := scanWindow(db.QueryRow(`
+		SELECT id, name, schedule, duration_minutes, tags, created_at
+		FROM freeze_windows WHERE id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWindowNotFound
+	}
+	return w, err
+}
+
+// List returns all freeze windows ordered by name, with ActiveUntil set on
+// those in effect at now
+func List(db *sql.DB, now time.Time) ([]Window, error) {
+	rows, err := db.Query(`
+		SELECT id, name, schedule, duration_minutes, tags, created_at
+		FROM freeze_windows ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []Window{}
+	for rows.Next() {
+		w, err := scanWindow(rows)
+		if err != nil {
+			return nil, err
+		}
+		w.setActive(now)
+		windows = append(windows, *w)
+	}
+	return windows, rows.Err()
+}
+
+// Delete removes a freeze window
+func Delete(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM freeze_windows WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWindowNotFound
+	}
+	return nil
+}
+
+// Active returns the freeze window in effect at now for an app with these
+// tags, or nil. Of several, it returns the one ending last.
+func Active(db *sql.DB, appTags []string, now time.Time) (*Window, error) {
+	windows, err := List(db, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var active *Window
+	for i := range windows {
+		w := &windows[i]
+		if w.ActiveUntil == nil || !w.Applies(appTags) {
+			continue
+		}
+		if active == nil || *w.ActiveUntil > *active.ActiveUntil {
+			active = w
+		}
+	}
+	return active, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWindow(row rowScanner) (*Window, error) {
+	var w Window
+	var tags string
+	if err := row.Scan(&w.ID, &w.Name, &w.Schedule, &w.DurationMinutes, &tags, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(tags), &w.Tags)
+	if w.Tags == nil {
+		w.Tags = []string{}
+	}
+
+	schedule, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("freeze window %q: %w", w.Name, err)
+	}
+	w.schedule = schedule
+	return &w, nil
+}
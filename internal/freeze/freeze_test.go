@@ -0,0 +1,111 @@
+package freeze
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// Friday 2026-10-16 18:30
+	fri := time.Date(2026, 10, 16, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr  string
+		at    time.Time
+		match bool
+	}{
+		{"* * * * *", fri, true},
+		{"30 18 * * 5", fri, true},
+		{"30 18 * * 1-4", fri, false},
+		{"*/15 9-17,18 * * *", fri, true},
+		{"*/20 * * * *", fri, false},
+		{"0 0 1 1 *", fri, false},
+		{"30 18 16 * 0", fri, true}, // either day field may match
+		{"30 18 1 * 5", fri, true},  // either day field may match
+		{"30 18 * * 7", fri, false}, // 7 is Sunday
+		{"30 18 * 10 *", fri, true},
+		{"30 18 * 11 *", fri, false},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.Matches(tt.at); got != tt.match {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.at, got, tt.match)
+		}
+	}
+
+	for _, bad := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		if _, err := ParseSchedule(bad); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("ParseSchedule(%q) = %v, want ErrInvalidSchedule", bad, err)
+		}
+	}
+}
+
+func TestActive(t *testing.T) {
+	db := dbtest.Open(t)
+	created := time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local)
+
+	// Fridays 18:00 for the weekend, for apps tagged "demo"
+	weekend, err := Create(db, Config{Name: "weekend", Schedule: "0 18 * * 5", DurationMinutes: 64 * 60, Tags: []string{" Demo "}}, created)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(weekend.Tags) != 1 || weekend.Tags[0] != "demo" {
+		t.Errorf("Expected normalized tags [demo], got %v", weekend.Tags)
+	}
+	if _, err := Create(db, Config{Name: "weekend", Schedule: "0 0 * * *", DurationMinutes: 60}, created); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("Expected ErrNameTaken, got %v", err)
+	}
+	if _, err := Create(db, Config{Name: "long", Schedule: "0 0 * * *", DurationMinutes: MaxDurationMinutes + 1}, created); !errors.Is(err, ErrInvalidDuration) {
+		t.Errorf("Expected ErrInvalidDuration, got %v", err)
+	}
+
+	saturday := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)
+	w, err := Active(db, []string{"demo", "blog"}, saturday)
+	if err != nil {
+		t.Fatalf("Active failed: %v", err)
+	}
+	if w == nil || w.ID != weekend.ID {
+		t.Fatalf("Expected the weekend window in effect, got %+v", w)
+	}
+	if end := time.Date(2026, 10, 19, 10, 0, 0, 0, time.Local).Unix(); *w.ActiveUntil != end {
+		t.Errorf("Expected the freeze to end Monday 10:00, got %s", time.Unix(*w.ActiveUntil, 0))
+	}
+
+	if w, _ := Active(db, []string{"blog"}, saturday); w != nil {
+		t.Errorf("Expected untagged apps not to be frozen, got %s", w.Name)
+	}
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, time.Local)
+	if w, _ := Active(db, []string{"demo"}, monday); w != nil {
+		t.Errorf("Expected the freeze to be over on Monday 10:00, got %s", w.Name)
+	}
+
+	// A window without tags freezes every app
+	if _, err := Create(db, Config{Name: "launch", Schedule: "0 9 19 10 *", DurationMinutes: 120}, created); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if w, _ := Active(db, nil, monday); w == nil || w.Name != "launch" {
+		t.Errorf("Expected the launch window to freeze every app, got %+v", w)
+	}
+
+	if err := Delete(db, weekend.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := Delete(db, weekend.ID); !errors.Is(err, ErrWindowNotFound) {
+		t.Errorf("Expected ErrWindowNotFound, got %v", err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	for minutes, want := range map[int]string{30: "30m", 90: "1h30m", 64 * 60: "64h"} {
+		w := Window{Schedule: "0 18 * * 5", DurationMinutes: minutes}
+		if got := w.Describe(); got != "0 18 * * 5 for "+want {
+			t.Errorf("Describe(%d minutes) = %q", minutes, got)
+		}
+	}
+}
@@ -14,11 +14,13 @@ import (
 
 // Alias represents a routing alias
 type Alias struct {
-	Subdomain string          `json:"subdomain"`
-	Type      string          `json:"type"`
-	Targets   json.RawMessage `json:"targets,omitempty"`
-	CreatedAt string          `json:"created_at"`
-	UpdatedAt string          `json:"updated_at"`
+	Subdomain  string          `json:"subdomain"`
+	Type       string          `json:"type"`
+	Targets    json.RawMessage `json:"targets,omitempty"`
+	Transforms json.RawMessage `json:"transforms,omitempty"`
+	Protected  bool            `json:"protected"`
+	CreatedAt  string          `json:"created_at"`
+	UpdatedAt  string          `json:"updated_at"`
 }
 
 // AliasTarget represents a proxy target
@@ -106,7 +108,7 @@ func AliasesListHandler(w http.ResponseWriter, r *http.Request) {
 	db.QueryRow(`SELECT COUNT(*) FROM aliases`).Scan(&total)
 
 	query := `
-		SELECT subdomain, type, targets, created_at, updated_at
+		SELECT subdomain, type, targets, protected, created_at, updated_at
 		FROM aliases
 		ORDER BY subdomain
 		LIMIT ? OFFSET ?
@@ -125,7 +127,7 @@ func AliasesListHandler(w http.ResponseWriter, r *http.Request) {
 		var targets *string
 		var createdAt, updatedAt interface{}
 
-		err := rows.Scan(&a.Subdomain, &a.Type, &targets, &createdAt, &updatedAt)
+		err := rows.Scan(&a.Subdomain, &a.Type, &targets, &a.Protected, &createdAt, &updatedAt)
 		if err != nil {
 			continue
 		}
@@ -167,15 +169,15 @@ func AliasDetailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT subdomain, type, targets, created_at, updated_at
+		SELECT subdomain, type, targets, transforms, protected, created_at, updated_at
 		FROM aliases WHERE subdomain = ?
 	`
 
 	var a Alias
-	var targets *string
+	var targets, transforms *string
 	var createdAt, updatedAt interface{}
 
-	err := db.QueryRow(query, subdomain).Scan(&a.Subdomain, &a.Type, &targets, &createdAt, &updatedAt)
+	err := db.QueryRow(query, subdomain).Scan(&a.Subdomain, &a.Type, &targets, &transforms, &a.Protected, &createdAt, &updatedAt)
 	if err == sql.ErrNoRows {
 		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
 		return
@@ -188,6 +190,9 @@ func AliasDetailHandler(w http.ResponseWriter, r *http.Request) {
 	if targets != nil && *targets != "" {
 		a.Targets = json.RawMessage(*targets)
 	}
+	if transforms != nil && *transforms != "" {
+		a.Transforms = json.RawMessage(*transforms)
+	}
 
 	if createdAt != nil {
 		a.CreatedAt = formatTime(createdAt)
@@ -248,6 +253,11 @@ func AliasCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Creating over an existing alias repoints it
+	if !requireAliasChange(w, r, db, req.Subdomain) {
+		return
+	}
+
 	// Build targets JSON
 	var targets *string
 	switch req.Type {
@@ -336,6 +346,10 @@ func AliasUpdateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAliasChange(w, r, db, subdomain) {
+		return
+	}
+
 	// Use current type if not specified
 	if req.Type == "" {
 		req.Type = currentType
@@ -420,6 +434,9 @@ func AliasDeleteHandler(w http.ResponseWriter, r *http.Request) {
 		api.ErrorResponse(w, http.StatusForbidden, "SYSTEM_ALIAS", "Cannot delete system alias", "")
 		return
 	}
+	if !requireAliasChange(w, r, db, subdomain) {
+		return
+	}
 
 	_, err = db.Exec("DELETE FROM aliases WHERE subdomain = ?", subdomain)
 	if err != nil {
@@ -457,6 +474,10 @@ func AliasReserveHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAliasChange(w, r, db, subdomain) {
+		return
+	}
+
 	query := `
 		INSERT INTO aliases (subdomain, type, targets, created_at, updated_at)
 		VALUES (?, 'reserved', NULL, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
@@ -509,6 +530,10 @@ func AliasSwapHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAliasChange(w, r, db, req.Alias1) || !requireAliasChange(w, r, db, req.Alias2) {
+		return
+	}
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -619,6 +644,10 @@ func AliasSplitHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAliasChange(w, r, db, subdomain) {
+		return
+	}
+
 	// Verify all app IDs exist
 	for _, t := range req.Targets {
 		var count int
@@ -659,6 +688,136 @@ func AliasSplitHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// TransformsRequest is the request body for configuring alias response transforms
+type TransformsRequest struct {
+	Transforms []hosting.ResponseTransform `json:"transforms"`
+}
+
+// AliasTransformsGetHandler returns the response transforms for an alias
+func AliasTransformsGetHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	transforms, err := GetAliasTransforms(subdomain)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if transforms == nil {
+		transforms = []hosting.ResponseTransform{}
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"subdomain":  subdomain,
+		"transforms": transforms,
+	})
+}
+
+// AliasTransformsUpdateHandler replaces the response transforms for an alias.
+// An empty list clears all transforms.
+func AliasTransformsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
+		return
+	}
+
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	var req TransformsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "invalid request body")
+		return
+	}
+
+	if err := hosting.ValidateTransforms(req.Transforms); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		api.InternalError(w, nil)
+		return
+	}
+
+	var aliasType string
+	err := db.QueryRow("SELECT type FROM aliases WHERE subdomain = ?", subdomain).Scan(&aliasType)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	var transforms *string
+	if len(req.Transforms) > 0 {
+		data, err := json.Marshal(req.Transforms)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		t := string(data)
+		transforms = &t
+	}
+
+	_, err = db.Exec(`UPDATE aliases SET transforms = ?, updated_at = CURRENT_TIMESTAMP WHERE subdomain = ?`, transforms, subdomain)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"subdomain":  subdomain,
+		"transforms": len(req.Transforms),
+		"message":    "Transforms updated",
+	})
+}
+
+// GetAliasTransforms returns the configured response transforms for a subdomain.
+// Returns sql.ErrNoRows if the alias does not exist, nil if none are configured.
+func GetAliasTransforms(subdomain string) ([]hosting.ResponseTransform, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, sql.ErrConnDone
+	}
+
+	var transforms *string
+	err := db.QueryRow("SELECT transforms FROM aliases WHERE subdomain = ?", subdomain).Scan(&transforms)
+	if err != nil {
+		return nil, err
+	}
+	if transforms == nil || *transforms == "" {
+		return nil, nil
+	}
+
+	var result []hosting.ResponseTransform
+	if err := json.Unmarshal([]byte(*transforms), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 // ResolveAlias resolves a subdomain to an app ID
 func ResolveAlias(subdomain string) (appID string, aliasType string, err error) {
 	db := database.GetDB()
@@ -687,8 +846,8 @@ func ResolveAlias(subdomain string) (appID string, aliasType string, err error)
 		if targets != nil {
 			var splits []SplitTarget
 			if err := json.Unmarshal([]byte(*targets), &splits); err == nil && len(splits) > 0 {
-				// TODO: Implement weighted random selection with sticky sessions
-				// For now, just return the first target
+				// Without request context, fall back to the first target.
+				// Request routing uses ResolveSplitVariant for sticky selection.
 				return splits[0].AppID, aliasType, nil
 			}
 		}
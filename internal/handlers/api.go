@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/models"
@@ -31,74 +35,62 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 		WHERE DATE(created_at) = DATE('now')
 	`).Scan(&stats.TotalEventsToday)
 
-	// Total events this week
-	db.QueryRow(`
-		SELECT COUNT(*) FROM events
-		WHERE created_at >= DATE('now', '-7 days')
-	`).Scan(&stats.TotalEventsWeek)
-
-	// Total events this month
-	db.QueryRow(`
-		SELECT COUNT(*) FROM events
-		WHERE created_at >= DATE('now', '-30 days')
-	`).Scan(&stats.TotalEventsMonth)
+	// Longer ranges count older days from the analytics rollups
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats.TotalEventsWeek, _ = analytics.Total(db, today.AddDate(0, 0, -7))
+	stats.TotalEventsMonth, _ = analytics.Total(db, today.AddDate(0, 0, -30))
+	stats.TotalEventsAllTime, _ = analytics.Total(db, time.Time{})
 
-	// Total events all time
-	db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&stats.TotalEventsAllTime)
+	// Visitors are counted per day, so a week's uniques are the sum of its days'
+	stats.UniqueVisitorsToday, stats.SessionsToday, _ = analytics.Visitors(db, today)
+	stats.UniqueVisitorsWeek, stats.SessionsWeek, _ = analytics.Visitors(db, today.AddDate(0, 0, -7))
+	stats.UniqueVisitorsMonth, stats.SessionsMonth, _ = analytics.Visitors(db, today.AddDate(0, 0, -30))
 
 	// Events by source type
-	rows, _ := db.Query(`
-		SELECT source_type, COUNT(*) as count
-		FROM events
-		GROUP BY source_type
-	`)
-	defer rows.Close()
-	for rows.Next() {
-		var sourceType string
-		var count int64
-		rows.Scan(&sourceType, &count)
-		stats.EventsBySourceType[sourceType] = count
+	bySource, _ := analytics.Breakdown(db, time.Time{}, "source_type", 0)
+	for _, c := range bySource {
+		stats.EventsBySourceType[c.Key] = c.Count
 	}
 
 	// Top 10 domains
-	rows, _ = db.Query(`
-		SELECT domain, COUNT(*) as count
-		FROM events
-		WHERE domain != ''
-		GROUP BY domain
-		ORDER BY count DESC
-		LIMIT 10
-	`)
-	defer rows.Close()
-	for rows.Next() {
-		var ds models.DomainStat
-		rows.Scan(&ds.Domain, &ds.Count)
-		stats.TopDomains = append(stats.TopDomains, ds)
+	domains, _ := analytics.Breakdown(db, time.Time{}, "domain", 0)
+	for _, c := range domains {
+		if c.Key == "" {
+			continue
+		}
+		stats.TotalUniqueDomains++
+		if len(stats.TopDomains) < 10 {
+			stats.TopDomains = append(stats.TopDomains, models.DomainStat{Domain: c.Key, Count: c.Count})
+		}
 	}
 
 	// Top 10 tags
-	rows, _ = db.Query(`
-		SELECT tags, COUNT(*) as count
-		FROM events
-		WHERE tags != ''
-		GROUP BY tags
-		ORDER BY count DESC
-		LIMIT 10
-	`)
-	defer rows.Close()
-	for rows.Next() {
-		var tagsStr string
-		var count int64
-		rows.Scan(&tagsStr, &count)
+	tags, _ := analytics.Breakdown(db, time.Time{}, "tags", 11)
+	topTags := 0
+	for _, c := range tags {
+		if c.Key == "" || topTags == 10 {
+			continue
+		}
+		topTags++
 		// Split tags and count individually
-		tags := strings.Split(tagsStr, ",")
-		for _, tag := range tags {
-			stats.TopTags = append(stats.TopTags, models.TagStat{Tag: strings.TrimSpace(tag), Count: count})
+		for _, tag := range strings.Split(c.Key, ",") {
+			stats.TopTags = append(stats.TopTags, models.TagStat{Tag: strings.TrimSpace(tag), Count: c.Count})
 		}
 	}
 
+	// Top 10 countries and regions, from events located by IP
+	stats.TopCountries, stats.TopRegions = []models.BreakdownStat{}, []models.BreakdownStat{}
+	countries, _ := analytics.GeoBreakdown(db, time.Time{}, "country", 10)
+	for _, c := range countries {
+		stats.TopCountries = append(stats.TopCountries, models.BreakdownStat{Key: c.Key, Count: c.Count})
+	}
+	regions, _ := analytics.GeoBreakdown(db, time.Time{}, "region", 10)
+	for _, c := range regions {
+		stats.TopRegions = append(stats.TopRegions, models.BreakdownStat{Key: c.Key, Count: c.Count})
+	}
+
 	// Events timeline (hourly for last 24 hours)
-	rows, _ = db.Query(`
+	rows, _ := db.Query(`
 		SELECT strftime('%Y-%m-%d %H:00', created_at) as hour, COUNT(*) as count
 		FROM events
 		WHERE created_at >= DATETIME('now', '-24 hours')
@@ -112,19 +104,36 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 		stats.EventsTimeline = append(stats.EventsTimeline, ts)
 	}
 
-	// Total unique domains
-	db.QueryRow(`SELECT COUNT(DISTINCT domain) FROM events`).Scan(&stats.TotalUniqueDomains)
-
 	// Total redirect clicks
 	db.QueryRow(`SELECT COALESCE(SUM(click_count), 0) FROM redirects`).Scan(&stats.TotalRedirectClicks)
 
 	api.Success(w, http.StatusOK, stats)
 }
 
-// EventsHandler returns paginated events with filtering
+// eventsListSpec is how GET /api/events pages, sorts and projects events
+var eventsListSpec = api.ListSpec{
+	Sorts: map[string]string{
+		"created_at": "COALESCE(created_at, '')",
+		"id":         "id",
+	},
+	DefaultSort: "-created_at",
+	ID:          "id",
+	Fields: []string{"id", "domain", "tags", "source_type", "event_type", "path", "referrer",
+		"user_agent", "ip_address", "created_at"},
+	DefaultLimit: 50,
+	MaxLimit:     500,
+}
+
+// EventsHandler returns a page of events with filtering
+// Query params: ?domain=&tags=&source_type=&limit=&cursor=&sort=&fields= (see api.ParseList)
 func EventsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		api.BadRequest(w, "Method not allowed")
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
+		return
+	}
+
+	q, ok := api.ParseList(w, r, eventsListSpec)
+	if !ok {
 		return
 	}
 
@@ -133,8 +142,6 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 	domain := query.Get("domain")
 	tags := query.Get("tags")
 	sourceType := query.Get("source_type")
-	limit := parseInt(query.Get("limit"), 50)
-	offset := parseInt(query.Get("offset"), 0)
 
 	// Build query
 	where := []string{"1=1"}
@@ -152,10 +159,16 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 		where = append(where, "source_type = ?")
 		args = append(args, sourceType)
 	}
+	if after, afterArgs := q.Where(); after != "" {
+		where = append(where, after)
+		args = append(args, afterArgs...)
+	}
 
 	whereClause := strings.Join(where, " AND ")
-	sql := "SELECT id, domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, created_at FROM events WHERE " + whereClause + " ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	args = append(args, limit, offset)
+	sql := "SELECT id, COALESCE(domain, ''), COALESCE(tags, ''), COALESCE(source_type, ''), COALESCE(event_type, ''), COALESCE(path, ''), " +
+		"COALESCE(referrer, ''), COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, " +
+		q.SortExpr() + " FROM events WHERE " + whereClause + " ORDER BY " + q.OrderBy() + " LIMIT ? OFFSET ?"
+	args = append(args, q.LimitArgs()...)
 
 	db := database.GetDB()
 	rows, err := db.Query(sql, args...)
@@ -171,8 +184,14 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 		var id int64
 		var domain, tags, sourceType, eventType, path, referrer, userAgent, ipAddress string
 		var createdAt time.Time
+		var sortKey interface{}
 
-		rows.Scan(&id, &domain, &tags, &sourceType, &eventType, &path, &referrer, &userAgent, &ipAddress, &createdAt)
+		if err := rows.Scan(&id, &domain, &tags, &sourceType, &eventType, &path, &referrer, &userAgent, &ipAddress, &createdAt, &sortKey); err != nil {
+			continue
+		}
+		if !q.Next(sortKey, id) {
+			break
+		}
 
 		events = append(events, map[string]interface{}{
 			"id":          id,
@@ -188,7 +207,7 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	api.Success(w, http.StatusOK, events)
+	api.ListSuccess(w, events, q)
 }
 
 // DomainsHandler returns list of domains with event counts
@@ -270,13 +289,227 @@ func TagsHandler(w http.ResponseWriter, r *http.Request) {
 	api.Success(w, http.StatusOK, tags)
 }
 
-// RedirectsHandler handles redirects CRUD
+// StatsBreakdownHandler returns event counts by device, browser, country,
+// region and path depth, plus a path x device heatmap of the top paths
+// GET /api/stats/breakdown?domain=tetris.zyt.app&days=30&event=pageview&limit=20
+func StatsBreakdownHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	domain := query.Get("domain")
+	days := parseInt(query.Get("days"), 30)
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	limit := parseInt(query.Get("limit"), 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	eventType := query.Get("event")
+	if eventType == "" {
+		eventType = "pageview"
+	}
+
+	where := []string{"created_at >= DATETIME('now', ?)", "event_type = ?"}
+	args := []interface{}{fmt.Sprintf("-%d days", days), eventType}
+	if domain != "" {
+		// Match the host and any subdomain of it
+		where = append(where, "(domain = ? OR domain LIKE ?)")
+		args = append(args, domain, "%."+domain)
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	db := database.GetDB()
+	b := models.Breakdown{Domain: domain, Days: days}
+
+	var err error
+	if b.Devices, err = breakdownBy(db, "COALESCE(device, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if b.Browsers, err = breakdownBy(db, "COALESCE(browser, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if b.Countries, err = breakdownBy(db, "COALESCE(country, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if b.Regions, err = breakdownBy(db, "COALESCE(region, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	for _, d := range b.Devices {
+		b.Total += d.Count
+	}
+
+	// Path x device counts; depth is computed in Go to handle trailing slashes and query strings
+	rows, err := db.Query(`
+		SELECT COALESCE(path, '/'), COALESCE(device, 'unknown'), COUNT(*)
+		FROM events
+		WHERE `+whereClause+`
+		GROUP BY 1, 2
+	`, args...)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	paths := map[string]*models.PathDeviceStat{}
+	depths := map[int]int64{}
+	for rows.Next() {
+		var path, device string
+		var count int64
+		if err := rows.Scan(&path, &device, &count); err != nil {
+			continue
+		}
+		if path == "" {
+			path = "/"
+		}
+		ps, ok := paths[path]
+		if !ok {
+			ps = &models.PathDeviceStat{Path: path, Depth: analytics.PathDepth(path), Devices: map[string]int64{}}
+			paths[path] = ps
+		}
+		ps.Devices[device] += count
+		ps.Total += count
+		depths[ps.Depth] += count
+	}
+
+	b.PathDepths = []models.DepthStat{}
+	for depth, count := range depths {
+		b.PathDepths = append(b.PathDepths, models.DepthStat{Depth: depth, Count: count})
+	}
+	sort.Slice(b.PathDepths, func(i, j int) bool { return b.PathDepths[i].Depth < b.PathDepths[j].Depth })
+
+	b.Paths = []models.PathDeviceStat{}
+	for _, ps := range paths {
+		b.Paths = append(b.Paths, *ps)
+	}
+	sort.Slice(b.Paths, func(i, j int) bool {
+		if b.Paths[i].Total != b.Paths[j].Total {
+			return b.Paths[i].Total > b.Paths[j].Total
+		}
+		return b.Paths[i].Path < b.Paths[j].Path
+	})
+	if len(b.Paths) > limit {
+		b.Paths = b.Paths[:limit]
+	}
+
+	api.Success(w, http.StatusOK, b)
+}
+
+// StatsCampaignsHandler attributes short link clicks and site pageviews to
+// utm_* campaigns, sources and mediums, and to referrer types
+// GET /api/stats/campaigns?domain=example.com&days=30&limit=20
+func StatsCampaignsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	domain := query.Get("domain")
+	days := parseInt(query.Get("days"), 30)
+	if days < 1 || days > 365 {
+		days = 30
+	}
+	limit := parseInt(query.Get("limit"), 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	where := []string{"created_at >= DATETIME('now', ?)", "(event_type = 'pageview' OR source_type = 'redirect')"}
+	args := []interface{}{fmt.Sprintf("-%d days", days)}
+	if domain != "" {
+		// Match the host and any subdomain of it; redirects are matched by slug
+		where = append(where, "(domain = ? OR domain LIKE ?)")
+		args = append(args, domain, "%."+domain)
+	}
+	whereClause := strings.Join(where, " AND ")
+
+	db := database.GetDB()
+	c := models.Campaigns{Domain: domain, Days: days}
+
+	var err error
+	if c.Sources, err = breakdownBy(db, "COALESCE(utm_source, 'none')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if c.Mediums, err = breakdownBy(db, "COALESCE(utm_medium, 'none')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if c.ReferrerTypes, err = breakdownBy(db, "COALESCE(referrer_type, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	for _, s := range c.Sources {
+		c.Total += s.Count
+	}
+
+	rows, err := db.Query(`
+		SELECT COALESCE(utm_source, ''), COALESCE(utm_medium, ''), COALESCE(utm_campaign, ''),
+			SUM(source_type = 'redirect'), SUM(source_type != 'redirect'), COUNT(DISTINCT visitor_id)
+		FROM events
+		WHERE `+whereClause+` AND (utm_source IS NOT NULL OR utm_medium IS NOT NULL OR utm_campaign IS NOT NULL)
+		GROUP BY 1, 2, 3
+		ORDER BY COUNT(*) DESC, 3, 1, 2
+		LIMIT ?
+	`, append(args, limit)...)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer rows.Close()
+
+	c.Campaigns = []models.CampaignStat{}
+	for rows.Next() {
+		var s models.CampaignStat
+		if err := rows.Scan(&s.Source, &s.Medium, &s.Campaign, &s.Clicks, &s.Pageviews, &s.Visitors); err != nil {
+			continue
+		}
+		c.Campaigns = append(c.Campaigns, s)
+	}
+	if err := rows.Err(); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, c)
+}
+
+// breakdownBy counts events grouped by a column expression
+func breakdownBy(db *sql.DB, column, whereClause string, args []interface{}) ([]models.BreakdownStat, error) {
+	rows, err := db.Query(`
+		SELECT `+column+` AS bucket, COUNT(*) AS count
+		FROM events
+		WHERE `+whereClause+`
+		GROUP BY bucket
+		ORDER BY count DESC, bucket
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.BreakdownStat{}
+	for rows.Next() {
+		var s models.BreakdownStat
+		if err := rows.Scan(&s.Key, &s.Count); err != nil {
+			continue
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// RedirectsHandler handles redirects CRUD. Listing with ?stats=true adds
+// each link's clicks in the last 7 and 30 days.
 func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
 	if r.Method == http.MethodGet {
 		// List all redirects
 		db := database.GetDB()
 		rows, err := db.Query(`
-			SELECT id, slug, destination, tags, click_count, created_at
+			SELECT id, slug, destination, COALESCE(tags, ''), click_count, created_at, expires_at
 			FROM redirects
 			ORDER BY click_count DESC
 		`)
@@ -292,37 +525,47 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 			var id, clickCount int64
 			var slug, destination, tags string
 			var createdAt time.Time
+			var expiresAt sql.NullTime
 
-			rows.Scan(&id, &slug, &destination, &tags, &clickCount, &createdAt)
+			rows.Scan(&id, &slug, &destination, &tags, &clickCount, &createdAt, &expiresAt)
 
-			redirects = append(redirects, map[string]interface{}{
+			redirect := map[string]interface{}{
 				"id":          id,
 				"slug":        slug,
 				"destination": destination,
 				"tags":        strings.Split(tags, ","),
 				"click_count": clickCount,
 				"created_at":  createdAt.Format(time.RFC3339),
-			})
+				"url":         shortLinkURL(r, slug),
+			}
+			if expiresAt.Valid {
+				redirect["expires_at"] = expiresAt.Time.Format(time.RFC3339)
+			}
+			redirects = append(redirects, redirect)
+		}
+		rows.Close()
+
+		if r.URL.Query().Get("stats") == "true" {
+			if err := addRedirectClickStats(db, redirects); err != nil {
+				api.InternalError(w, err)
+				return
+			}
 		}
 
 		api.Success(w, http.StatusOK, redirects)
 
 	} else if r.Method == http.MethodPost {
 		// Create new redirect
-		var req struct {
-			Slug        string   `json:"slug"`
-			Destination string   `json:"destination"`
-			Tags        []string `json:"tags"`
-		}
-
+		var req redirectInput
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			api.InvalidJSON(w, "Invalid JSON")
 			return
 		}
 
 		// Validate
-		if req.Slug == "" || req.Destination == "" {
-			api.BadRequest(w, "Slug and destination are required")
+		expiresAt, err := req.validate(time.Now())
+		if err != nil {
+			api.BadRequest(w, err.Error())
 			return
 		}
 
@@ -338,9 +581,9 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 		// Insert
 		tagsStr := strings.Join(req.Tags, ",")
 		result, err := db.Exec(`
-			INSERT INTO redirects (slug, destination, tags)
-			VALUES (?, ?, ?)
-		`, req.Slug, req.Destination, tagsStr)
+			INSERT INTO redirects (slug, destination, tags, expires_at)
+			VALUES (?, ?, ?, ?)
+		`, req.Slug, req.Destination, tagsStr, expiryValue(expiresAt))
 
 		if err != nil {
 			log.Printf("Error creating redirect: %v", err)
@@ -356,6 +599,8 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 			"destination": req.Destination,
 			"tags":        req.Tags,
 			"click_count": 0,
+			"expires_at":  expiresAt,
+			"url":         shortLinkURL(r, req.Slug),
 		})
 
 	} else {
@@ -363,6 +608,43 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// addRedirectClickStats sets clicks_7d and clicks_30d on listed redirects
+func addRedirectClickStats(db *sql.DB, redirects []map[string]interface{}) error {
+	rows, err := db.Query(`
+		SELECT domain,
+			SUM(created_at >= DATETIME('now', '-7 days')),
+			COUNT(*)
+		FROM events
+		WHERE source_type = 'redirect' AND created_at >= DATETIME('now', '-30 days')
+		GROUP BY domain
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type clicks struct{ week, month int64 }
+	bySlug := map[string]clicks{}
+	for rows.Next() {
+		var slug string
+		var c clicks
+		if err := rows.Scan(&slug, &c.week, &c.month); err != nil {
+			continue
+		}
+		bySlug[slug] = c
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, redirect := range redirects {
+		c := bySlug[redirect["slug"].(string)]
+		redirect["clicks_7d"] = c.week
+		redirect["clicks_30d"] = c.month
+	}
+	return nil
+}
+
 // WebhooksHandler handles webhooks CRUD
 func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
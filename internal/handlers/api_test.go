@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
+	"image/png"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/handlers/testutil"
 )
@@ -22,6 +26,17 @@ func setupAPITest(t *testing.T) {
 	})
 }
 
+// setupRedirectsAPITest is setupAPITest with an API key for the admin-only
+// redirect endpoints
+func setupRedirectsAPITest(t *testing.T) string {
+	t.Helper()
+	setupAPITest(t)
+
+	token := "redirects-token-123"
+	insertTestAPIKey(t, database.GetDB(), token)
+	return token
+}
+
 // --- StatsHandler ---
 
 func TestStatsHandler_Empty(t *testing.T) {
@@ -55,6 +70,59 @@ func TestStatsHandler_WithEvents(t *testing.T) {
 	}
 }
 
+func TestStatsHandler_UniqueVisitors(t *testing.T) {
+	setupAPITest(t)
+	db := database.GetDB()
+	for _, visitor := range []string{"v1", "v1", "v2"} {
+		db.Exec(`INSERT INTO events (domain, event_type, source_type, path, visitor_id) VALUES ('example.com', 'pageview', 'web', '/', ?)`, visitor)
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	resp := httptest.NewRecorder()
+	StatsHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if uniques, _ := data["unique_visitors_today"].(float64); uniques != 2 {
+		t.Errorf("Expected 2 unique visitors today, got %v", data["unique_visitors_today"])
+	}
+	if week, _ := data["unique_visitors_week"].(float64); week != 2 {
+		t.Errorf("Expected 2 unique visitors this week, got %v", data["unique_visitors_week"])
+	}
+}
+
+func TestStatsHandler_ReadsRollups(t *testing.T) {
+	setupAPITest(t)
+	db := database.GetDB()
+	old := time.Now().UTC().AddDate(0, 0, -60).Format("2006-01-02 15:04:05")
+	db.Exec(`INSERT INTO events (domain, event_type, source_type, path, created_at) VALUES ('old.com', 'pageview', 'web', '/', ?)`, old)
+	createTestEvent(t, db, "example.com", "pageview")
+
+	// Roll the old event up, then drop it
+	for i := 0; i < 3; i++ {
+		if err := analytics.Rollup(db, time.Now()); err != nil {
+			t.Fatalf("Rollup failed: %v", err)
+		}
+	}
+	if n, err := analytics.Prune(db, time.Now().AddDate(0, 0, -30)); err != nil || n != 1 {
+		t.Fatalf("Expected the old event pruned, got %d (%v)", n, err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	resp := httptest.NewRecorder()
+	StatsHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if total, _ := data["total_events_all_time"].(float64); total != 2 {
+		t.Errorf("Expected 2 total events, got %v", data["total_events_all_time"])
+	}
+	if month, _ := data["total_events_month"].(float64); month != 1 {
+		t.Errorf("Expected 1 event this month, got %v", data["total_events_month"])
+	}
+	if domains, _ := data["total_unique_domains"].(float64); domains != 2 {
+		t.Errorf("Expected 2 domains, got %v", data["total_unique_domains"])
+	}
+}
+
 func TestStatsHandler_MethodNotAllowed(t *testing.T) {
 	setupAPITest(t)
 
@@ -67,6 +135,110 @@ func TestStatsHandler_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+// --- StatsBreakdownHandler ---
+
+func TestStatsBreakdownHandler(t *testing.T) {
+	setupAPITest(t)
+	db := database.GetDB()
+
+	for _, e := range []struct{ domain, path, device, browser string }{
+		{"blog.example.com", "/", "mobile", "Safari"},
+		{"blog.example.com", "/posts/hello", "mobile", "Chrome"},
+		{"blog.example.com", "/posts/hello", "desktop", "Chrome"},
+		{"blog.example.com", "/posts/hello/", "desktop", "Firefox"},
+		{"other.com", "/", "desktop", "Chrome"},
+	} {
+		if _, err := db.Exec(`INSERT INTO events (domain, event_type, source_type, path, device, browser) VALUES (?, 'pageview', 'web', ?, ?, ?)`,
+			e.domain, e.path, e.device, e.browser); err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/breakdown?domain=example.com", nil)
+	resp := httptest.NewRecorder()
+	StatsBreakdownHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if total, _ := data["total"].(float64); total != 4 {
+		t.Errorf("Expected 4 events for example.com, got %v", data["total"])
+	}
+
+	devices, _ := data["devices"].([]interface{})
+	if len(devices) != 2 {
+		t.Fatalf("Expected 2 device classes, got %v", data["devices"])
+	}
+
+	depths, _ := data["path_depths"].([]interface{})
+	if len(depths) != 2 {
+		t.Fatalf("Expected depths 0 and 2, got %v", data["path_depths"])
+	}
+	if d := depths[1].(map[string]interface{}); d["depth"] != float64(2) || d["count"] != float64(3) {
+		t.Errorf("Expected 3 events at depth 2, got %v", d)
+	}
+
+	paths, _ := data["paths"].([]interface{})
+	top := paths[0].(map[string]interface{})
+	if top["path"] != "/posts/hello" || top["total"] != float64(2) {
+		t.Errorf("Expected /posts/hello as top path, got %v", top)
+	}
+}
+
+// --- StatsCampaignsHandler ---
+
+func TestStatsCampaignsHandler(t *testing.T) {
+	setupAPITest(t)
+	db := database.GetDB()
+
+	for _, e := range []struct {
+		domain, source, event                    string
+		utmSource, utmCampaign, refType, visitor interface{}
+	}{
+		{"spring", "redirect", "click", "newsletter", "spring_sale", "direct", "v1"},
+		{"blog.example.com", "hosting", "pageview", "newsletter", "spring_sale", "direct", "v1"},
+		{"blog.example.com", "hosting", "pageview", "newsletter", "spring_sale", "internal", "v1"},
+		{"blog.example.com", "hosting", "pageview", "twitter", "launch", "social", "v2"},
+		{"blog.example.com", "hosting", "pageview", nil, nil, "search", "v3"},
+		{"blog.example.com", "pixel", "open", "newsletter", "spring_sale", nil, "v4"},
+	} {
+		if _, err := db.Exec(`INSERT INTO events (domain, source_type, event_type, path, utm_source, utm_campaign, referrer_type, visitor_id) VALUES (?, ?, ?, '/', ?, ?, ?, ?)`,
+			e.domain, e.source, e.event, e.utmSource, e.utmCampaign, e.refType, e.visitor); err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/campaigns", nil)
+	resp := httptest.NewRecorder()
+	StatsCampaignsHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if total, _ := data["total"].(float64); total != 5 {
+		t.Errorf("Expected 5 clicks and pageviews, got %v", data["total"])
+	}
+
+	campaigns, _ := data["campaigns"].([]interface{})
+	if len(campaigns) != 2 {
+		t.Fatalf("Expected 2 campaigns, got %v", data["campaigns"])
+	}
+	top := campaigns[0].(map[string]interface{})
+	if top["campaign"] != "spring_sale" || top["clicks"] != float64(1) || top["pageviews"] != float64(2) || top["visitors"] != float64(1) {
+		t.Errorf("Expected spring_sale with 1 click, 2 pageviews and 1 visitor, got %v", top)
+	}
+
+	types, _ := data["referrer_types"].([]interface{})
+	if first := types[0].(map[string]interface{}); first["key"] != "direct" || first["count"] != float64(2) {
+		t.Errorf("Expected direct as top referrer type, got %v", types)
+	}
+
+	// Redirects are matched by slug
+	req = httptest.NewRequest("GET", "/api/stats/campaigns?domain=example.com", nil)
+	resp = httptest.NewRecorder()
+	StatsCampaignsHandler(resp, req)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	if total, _ := data["total"].(float64); total != 4 {
+		t.Errorf("Expected 4 pageviews for example.com, got %v", data["total"])
+	}
+}
+
 // --- EventsHandler ---
 
 func TestEventsHandler_Empty(t *testing.T) {
@@ -146,6 +318,42 @@ func TestEventsHandler_Pagination(t *testing.T) {
 	}
 }
 
+func TestEventsHandler_Cursor(t *testing.T) {
+	setupAPITest(t)
+	db := database.GetDB()
+	for i := 0; i < 5; i++ {
+		createTestEvent(t, db, "example.com", "pageview")
+	}
+
+	req := httptest.NewRequest("GET", "/api/events?limit=3", nil)
+	resp := httptest.NewRecorder()
+	EventsHandler(resp, req)
+	var page struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &page)
+	cursor, _ := page.Meta["next_cursor"].(string)
+	if len(page.Data) != 3 || page.Meta["has_more"] != true || cursor == "" {
+		t.Fatalf("Expected a first page of 3, got %s", resp.Body.String())
+	}
+
+	// Newest first, IDs breaking ties in created_at
+	req = httptest.NewRequest("GET", "/api/events?limit=3&cursor="+cursor, nil)
+	resp = httptest.NewRecorder()
+	EventsHandler(resp, req)
+	json.Unmarshal(resp.Body.Bytes(), &page)
+	if len(page.Data) != 2 || page.Meta["has_more"] != false || page.Data[0]["id"] != float64(2) {
+		t.Errorf("Expected the last 2 events, got %s", resp.Body.String())
+	}
+
+	// A cursor only continues the sort it came from
+	req = httptest.NewRequest("GET", "/api/events?sort=id&cursor="+cursor, nil)
+	resp = httptest.NewRecorder()
+	EventsHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+}
+
 func TestEventsHandler_MethodNotAllowed(t *testing.T) {
 	setupAPITest(t)
 
@@ -153,8 +361,8 @@ func TestEventsHandler_MethodNotAllowed(t *testing.T) {
 	resp := httptest.NewRecorder()
 	EventsHandler(resp, req)
 
-	if resp.Code != http.StatusBadRequest {
-		t.Errorf("Expected 400, got %d", resp.Code)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.Code)
 	}
 }
 
@@ -228,9 +436,10 @@ func TestTagsHandler_WithTags(t *testing.T) {
 // --- RedirectsHandler ---
 
 func TestRedirectsHandler_ListEmpty(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("GET", "/api/redirects", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -241,7 +450,7 @@ func TestRedirectsHandler_ListEmpty(t *testing.T) {
 }
 
 func TestRedirectsHandler_Create(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	body := map[string]interface{}{
 		"slug":        "test-slug",
@@ -250,6 +459,7 @@ func TestRedirectsHandler_Create(t *testing.T) {
 	}
 
 	req := testutil.JSONRequest("POST", "/api/redirects", body)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -259,7 +469,7 @@ func TestRedirectsHandler_Create(t *testing.T) {
 }
 
 func TestRedirectsHandler_CreateDuplicateSlug(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 	db := database.GetDB()
 	createTestRedirect(t, db, "dup-slug", "https://example.com")
 
@@ -269,6 +479,7 @@ func TestRedirectsHandler_CreateDuplicateSlug(t *testing.T) {
 	}
 
 	req := testutil.JSONRequest("POST", "/api/redirects", body)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -278,13 +489,14 @@ func TestRedirectsHandler_CreateDuplicateSlug(t *testing.T) {
 }
 
 func TestRedirectsHandler_CreateMissingFields(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	body := map[string]interface{}{
 		"slug": "no-dest",
 	}
 
 	req := testutil.JSONRequest("POST", "/api/redirects", body)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -294,12 +506,13 @@ func TestRedirectsHandler_CreateMissingFields(t *testing.T) {
 }
 
 func TestRedirectsHandler_ListWithData(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 	db := database.GetDB()
 	createTestRedirect(t, db, "slug1", "https://a.com")
 	createTestRedirect(t, db, "slug2", "https://b.com")
 
 	req := httptest.NewRequest("GET", "/api/redirects", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -310,9 +523,10 @@ func TestRedirectsHandler_ListWithData(t *testing.T) {
 }
 
 func TestRedirectsHandler_InvalidJSON(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("POST", "/api/redirects", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
@@ -323,9 +537,10 @@ func TestRedirectsHandler_InvalidJSON(t *testing.T) {
 }
 
 func TestRedirectsHandler_MethodNotAllowed(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("DELETE", "/api/redirects", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	RedirectsHandler(resp, req)
 
@@ -462,11 +677,12 @@ func TestParseInt(t *testing.T) {
 // --- DeleteRedirectHandler ---
 
 func TestDeleteRedirectHandler_Success(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 	db := database.GetDB()
 	id := createTestRedirect(t, db, "del-slug", "https://example.com")
 
 	req := httptest.NewRequest("DELETE", "/api/redirects/1", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.SetPathValue("id", fmt.Sprintf("%d", id))
 	resp := httptest.NewRecorder()
 	DeleteRedirectHandler(resp, req)
@@ -476,9 +692,10 @@ func TestDeleteRedirectHandler_Success(t *testing.T) {
 }
 
 func TestDeleteRedirectHandler_NotFound(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("DELETE", "/api/redirects/999", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.SetPathValue("id", "999")
 	resp := httptest.NewRecorder()
 	DeleteRedirectHandler(resp, req)
@@ -487,9 +704,10 @@ func TestDeleteRedirectHandler_NotFound(t *testing.T) {
 }
 
 func TestDeleteRedirectHandler_MissingID(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("DELETE", "/api/redirects/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp := httptest.NewRecorder()
 	DeleteRedirectHandler(resp, req)
 
@@ -499,9 +717,10 @@ func TestDeleteRedirectHandler_MissingID(t *testing.T) {
 }
 
 func TestDeleteRedirectHandler_InvalidID(t *testing.T) {
-	setupAPITest(t)
+	token := setupRedirectsAPITest(t)
 
 	req := httptest.NewRequest("DELETE", "/api/redirects/abc", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.SetPathValue("id", "abc")
 	resp := httptest.NewRecorder()
 	DeleteRedirectHandler(resp, req)
@@ -511,6 +730,221 @@ func TestDeleteRedirectHandler_InvalidID(t *testing.T) {
 	}
 }
 
+// --- Redirect import, stats and QR codes (redirects.go) ---
+
+func TestRedirectsHandler_RequiresAuth(t *testing.T) {
+	setupAdminAuthTest(t)
+
+	req := httptest.NewRequest("GET", "/api/redirects", nil)
+	resp := httptest.NewRecorder()
+	RedirectsHandler(resp, req)
+
+	testutil.CheckError(t, resp, http.StatusUnauthorized, "UNAUTHORIZED")
+}
+
+func TestRedirectsHandler_CreateValidation(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+
+	tests := []map[string]interface{}{
+		{"slug": "has space", "destination": "https://example.com"},
+		{"slug": "ok", "destination": "javascript:alert(1)"},
+		{"slug": "ok", "destination": "https://example.com", "expires_at": "2001-01-01"},
+		{"slug": "ok", "destination": "https://example.com", "expires": "soon"},
+	}
+	for _, body := range tests {
+		req := testutil.JSONRequest("POST", "/api/redirects", body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		RedirectsHandler(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, got %d", body, resp.Code)
+		}
+	}
+}
+
+func TestRedirectsHandler_CreateWithExpiry(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+
+	body := map[string]interface{}{
+		"slug":        "promo",
+		"destination": "https://example.com/sale",
+		"expires":     "30d",
+	}
+	req := testutil.JSONRequest("POST", "/api/redirects", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	RedirectsHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusCreated)
+	expires, err := time.Parse(time.RFC3339, fmt.Sprint(data["expires_at"]))
+	if err != nil {
+		t.Fatalf("Expected expires_at, got %v", data["expires_at"])
+	}
+	if d := time.Until(expires); d < 29*24*time.Hour || d > 31*24*time.Hour {
+		t.Errorf("Expected expiry in 30 days, got %v", expires)
+	}
+	testutil.AssertFieldEquals(t, data, "url", "http://example.com/r/promo")
+}
+
+func TestRedirectsHandler_ListStats(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+	db := database.GetDB()
+	createTestRedirect(t, db, "launch", "https://example.com")
+	for _, age := range []string{"-1 days", "-2 days", "-10 days", "-60 days"} {
+		if _, err := db.Exec(`INSERT INTO events (domain, source_type, event_type, path, created_at) VALUES ('launch', 'redirect', 'click', '/r/launch', DATETIME('now', ?))`, age); err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/redirects?stats=true", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	RedirectsHandler(resp, req)
+
+	arr := testutil.CheckSuccessArray(t, resp, http.StatusOK)
+	if len(arr) != 1 {
+		t.Fatalf("Expected 1 redirect, got %d", len(arr))
+	}
+	link := arr[0].(map[string]interface{})
+	if link["clicks_7d"] != float64(2) || link["clicks_30d"] != float64(3) {
+		t.Errorf("Expected 2 clicks in 7 days and 3 in 30, got %v and %v", link["clicks_7d"], link["clicks_30d"])
+	}
+}
+
+func TestRedirectImportHandler(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+	db := database.GetDB()
+	createTestRedirect(t, db, "existing", "https://old.example.com")
+
+	importLinks := func(update bool) map[string]interface{} {
+		body := map[string]interface{}{
+			"links": []map[string]interface{}{
+				{"slug": "new", "destination": "https://example.com/new", "tags": []string{"a", "b"}},
+				{"slug": "existing", "destination": "https://new.example.com"},
+				{"slug": "bad slug", "destination": "https://example.com"},
+				{"slug": "new", "destination": "https://example.com/again"},
+				{"slug": "dated", "destination": "https://example.com", "expires_at": "2099-01-01"},
+			},
+			"update": update,
+		}
+		req := testutil.JSONRequest("POST", "/api/redirects/import", body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		RedirectImportHandler(resp, req)
+		return testutil.CheckSuccess(t, resp, http.StatusOK)
+	}
+
+	data := importLinks(false)
+	if data["created"] != float64(2) || data["updated"] != float64(0) || data["skipped"] != float64(1) {
+		t.Errorf("Expected 2 created and 1 skipped, got %v", data)
+	}
+	errs, _ := data["errors"].([]interface{})
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", data["errors"])
+	}
+	if first := errs[0].(map[string]interface{}); first["index"] != float64(2) {
+		t.Errorf("Expected the bad slug at index 2, got %v", first)
+	}
+
+	var destination, tags string
+	db.QueryRow("SELECT destination FROM redirects WHERE slug = 'existing'").Scan(&destination)
+	if destination != "https://old.example.com" {
+		t.Errorf("Expected existing link untouched, got %s", destination)
+	}
+
+	data = importLinks(true)
+	if data["created"] != float64(0) || data["updated"] != float64(3) {
+		t.Errorf("Expected 3 updated, got %v", data)
+	}
+	db.QueryRow("SELECT destination FROM redirects WHERE slug = 'existing'").Scan(&destination)
+	if destination != "https://new.example.com" {
+		t.Errorf("Expected existing link updated, got %s", destination)
+	}
+	db.QueryRow("SELECT tags FROM redirects WHERE slug = 'new'").Scan(&tags)
+	if tags != "a,b" {
+		t.Errorf("Expected tags a,b, got %s", tags)
+	}
+}
+
+func TestRedirectStatsHandler(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+	db := database.GetDB()
+	createTestRedirect(t, db, "launch", "https://example.com")
+	for _, e := range []struct {
+		domain, country, refType, visitor string
+	}{
+		{"launch", "US", "social", "v1"},
+		{"launch", "US", "social", "v1"},
+		{"launch", "DE", "direct", "v2"},
+		{"other", "FR", "direct", "v3"},
+	} {
+		if _, err := db.Exec(`INSERT INTO events (domain, source_type, event_type, path, country, referrer_type, visitor_id) VALUES (?, 'redirect', 'click', '/r/', ?, ?, ?)`,
+			e.domain, e.country, e.refType, e.visitor); err != nil {
+			t.Fatalf("Failed to create test event: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/redirects/launch/stats", nil)
+	req.SetPathValue("slug", "launch")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	RedirectStatsHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if data["clicks"] != float64(3) || data["visitors"] != float64(2) {
+		t.Errorf("Expected 3 clicks from 2 visitors, got %v and %v", data["clicks"], data["visitors"])
+	}
+	if timeline, _ := data["timeline"].([]interface{}); len(timeline) != 1 {
+		t.Errorf("Expected 1 day in the timeline, got %v", data["timeline"])
+	}
+	countries, _ := data["countries"].([]interface{})
+	if len(countries) != 2 || countries[0].(map[string]interface{})["key"] != "US" {
+		t.Errorf("Expected US then DE, got %v", data["countries"])
+	}
+
+	req = httptest.NewRequest("GET", "/api/redirects/missing/stats", nil)
+	req.SetPathValue("slug", "missing")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	RedirectStatsHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusNotFound, "REDIRECT_NOT_FOUND")
+}
+
+func TestRedirectQRHandler(t *testing.T) {
+	token := setupRedirectsAPITest(t)
+	createTestRedirect(t, database.GetDB(), "launch", "https://example.com")
+
+	req := httptest.NewRequest("GET", "/api/redirects/launch/qr?size=300", nil)
+	req.SetPathValue("slug", "launch")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	RedirectQRHandler(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("Expected image/png, got %s", ct)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	if w := img.Bounds().Dx(); w > 300 || w < 200 {
+		t.Errorf("Expected about 300px, got %d", w)
+	}
+
+	req = httptest.NewRequest("GET", "/api/redirects/launch/qr?size=10", nil)
+	req.SetPathValue("slug", "launch")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp = httptest.NewRecorder()
+	RedirectQRHandler(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a tiny size, got %d", resp.Code)
+	}
+}
+
 // --- Webhook CRUD (webhooks.go) ---
 
 func TestDeleteWebhookHandler_Success(t *testing.T) {
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// appPrincipal is the caller of an app API request.
+// Admins (server-wide API keys and admin/owner users) bypass app membership.
+type appPrincipal struct {
+	UserID string
+	Email  string
+	Admin  bool
+	Owner  bool   // Server owner; overrides app and alias protection with force alone
+	AppID  string // Set for API keys limited to a single app
+}
+
+// unrestricted reports whether the caller may act on every app
+func (p *appPrincipal) unrestricted() bool {
+	return p.Admin && p.AppID == ""
+}
+
+type appPrincipalKey struct{}
+
+var (
+	errNoCredentials = errors.New("authentication required")
+	errScopeDenied   = errors.New("API key scope does not allow this request")
+)
+
+// AppAccess authenticates the caller (Bearer API key or session) and makes
+// them available to the app handlers for per-app role checks
+func AppAccess(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := resolveAppPrincipal(r)
+		if err == errScopeDenied {
+			api.Forbidden(w, err.Error())
+			return
+		}
+		if err != nil {
+			api.Unauthorized(w, err.Error())
+			return
+		}
+		next(w, withAppPrincipal(r, p))
+	}
+}
+
+// withAppPrincipal attaches the caller to the request for per-app role checks
+func withAppPrincipal(r *http.Request, p *appPrincipal) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), appPrincipalKey{}, p))
+}
+
+// resolveAppPrincipal identifies the caller from a Bearer API key or session cookie
+func resolveAppPrincipal(r *http.Request) (*appPrincipal, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, sql.ErrConnDone
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		key, err := hosting.AuthenticateAPIKey(db, strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			return nil, errors.New("invalid API key")
+		}
+		if !key.Allows(r.Method, r.URL.Path) {
+			return nil, errScopeDenied
+		}
+		return principalForAPIKey(db, key)
+	}
+
+	if authService == nil {
+		return nil, errNoCredentials
+	}
+	user, err := authService.GetSessionFromRequest(r)
+	if err != nil || user == nil {
+		return nil, errNoCredentials
+	}
+	return &appPrincipal{UserID: user.ID, Email: user.Email, Admin: user.IsAdmin(), Owner: user.IsOwner()}, nil
+}
+
+// principalForAPIKey returns the principal for a validated API key.
+// Keys without a user are server-wide and act as admin; keys with an app
+// are additionally limited to that app.
+func principalForAPIKey(db *sql.DB, key *hosting.APIKey) (*appPrincipal, error) {
+	if key.UserID == "" {
+		return &appPrincipal{Admin: true, AppID: key.AppID}, nil
+	}
+
+	p := &appPrincipal{UserID: key.UserID, AppID: key.AppID}
+	var role string
+	if err := db.QueryRow(`SELECT email, COALESCE(role, 'user') FROM auth_users WHERE id = ?`, key.UserID).Scan(&p.Email, &role); err != nil {
+		return nil, errors.New("API key user no longer exists")
+	}
+	p.Admin = role == "admin" || role == "owner"
+	p.Owner = role == "owner"
+	return p, nil
+}
+
+// principalFromRequest returns the caller set by AppAccess.
+// nil means the handler was invoked without AppAccess (internal use) and is unrestricted.
+func principalFromRequest(r *http.Request) *appPrincipal {
+	p, _ := r.Context().Value(appPrincipalKey{}).(*appPrincipal)
+	return p
+}
+
+// restrictedPrincipal returns the caller if their access is limited by app membership or key
+func restrictedPrincipal(r *http.Request) *appPrincipal {
+	p := principalFromRequest(r)
+	if p == nil || p.unrestricted() {
+		return nil
+	}
+	return p
+}
+
+// requireAppRole checks the caller has at least the given role on an app.
+// Non-members get 404 so app existence is not leaked.
+func requireAppRole(w http.ResponseWriter, r *http.Request, appID, need string) bool {
+	p := restrictedPrincipal(r)
+	if p == nil {
+		return true
+	}
+	if p.AppID != "" && p.AppID != appID {
+		api.NotFound(w, "APP_NOT_FOUND", "App not found")
+		return false
+	}
+	if p.Admin {
+		return true
+	}
+
+	role, err := hosting.GetAppMemberRole(database.GetDB(), appID, p.UserID)
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	if role == "" {
+		api.NotFound(w, "APP_NOT_FOUND", "App not found")
+		return false
+	}
+	if !hosting.AppRoleAllows(role, need) {
+		api.Forbidden(w, "App "+need+" role required")
+		return false
+	}
+	return true
+}
+
+// grantAppOwner makes the calling user an owner of an app they just created
+func grantAppOwner(r *http.Request, appID string) {
+	p := principalFromRequest(r)
+	if p == nil || p.UserID == "" {
+		return
+	}
+	hosting.SetAppMember(database.GetDB(), appID, p.UserID, hosting.AppRoleOwner, p.UserID)
+}
+
+// requireDeployAccess checks the caller may deploy to the app with the given name.
+// Existing apps need the editor role, and an emergency during a freeze; any
+// user may deploy a new app unless their key is limited to a single app.
+func requireDeployAccess(w http.ResponseWriter, r *http.Request, db *sql.DB, name string) (isNew bool, ok bool) {
+	var appID string
+	err := db.QueryRow(`SELECT id FROM apps WHERE title = ?`, name).Scan(&appID)
+	if err == sql.ErrNoRows {
+		if p := principalFromRequest(r); p != nil && p.AppID != "" {
+			api.Forbidden(w, "API key is limited to another app")
+			return false, false
+		}
+		return true, true
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return false, false
+	}
+	if !requireAppRole(w, r, appID, hosting.AppRoleEditor) {
+		return false, false
+	}
+	return false, requireUnfrozen(w, r, db, "deploy", appID)
+}
+
+// grantDeployedAppOwner makes the caller owner of an app created by a deploy
+func grantDeployedAppOwner(r *http.Request, db *sql.DB, name string) {
+	var appID string
+	if err := db.QueryRow(`SELECT id FROM apps WHERE title = ?`, name).Scan(&appID); err == nil {
+		grantAppOwner(r, appID)
+	}
+}
+
+// resolveAppIdentifier resolves an app ID, alias, or app title to an app ID
+func resolveAppIdentifier(db *sql.DB, identifier string) (string, error) {
+	if appid.IsValid(identifier) {
+		return identifier, nil
+	}
+
+	if resolvedID, aliasType, err := ResolveAlias(identifier); err == nil && resolvedID != "" && aliasType != "reserved" {
+		return resolvedID, nil
+	}
+
+	var id string
+	err := db.QueryRow(`SELECT id FROM apps WHERE title = ? ORDER BY updated_at DESC LIMIT 1`, identifier).Scan(&id)
+	return id, err
+}
+
+// resolveExistingApp resolves an app identifier and checks the app exists
+func resolveExistingApp(db *sql.DB, identifier string) (string, error) {
+	appID, err := resolveAppIdentifier(db, identifier)
+	if err != nil {
+		return "", err
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM apps WHERE id = ?`, appID).Scan(&count)
+	if count == 0 {
+		return "", sql.ErrNoRows
+	}
+	return appID, nil
+}
+
+// AppMemberRequest is the request body for sharing an app
+type AppMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// AppMembersListHandler lists the members of an app
+// GET /api/apps/{id}/members
+func AppMembersListHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	members, err := hosting.ListAppMembers(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":  appID,
+		"members": members,
+	})
+}
+
+// AppMemberShareHandler adds a user to an app or changes their role
+// POST /api/apps/{id}/members
+func AppMemberShareHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	var req AppMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "invalid request body")
+		return
+	}
+	if req.Email == "" {
+		api.BadRequest(w, "email is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = hosting.AppRoleViewer
+	}
+	if !hosting.IsValidAppRole(req.Role) {
+		api.BadRequest(w, hosting.ErrInvalidAppRole.Error())
+		return
+	}
+
+	userID, err := hosting.GetUserIDByEmail(db, req.Email)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "USER_NOT_FOUND", "No user with that email")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	if req.Role != hosting.AppRoleOwner && !leavesAnOwner(w, db, appID, userID) {
+		return
+	}
+
+	addedBy := ""
+	if p := principalFromRequest(r); p != nil {
+		addedBy = p.UserID
+	}
+	if err := hosting.SetAppMember(db, appID, userID, req.Role, addedBy); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":  appID,
+		"user_id": userID,
+		"email":   req.Email,
+		"role":    req.Role,
+	})
+}
+
+// AppMemberRemoveHandler removes a user from an app
+// DELETE /api/apps/{id}/members/{user}
+func AppMemberRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	userID := r.PathValue("user")
+	if strings.Contains(userID, "@") {
+		id, err := hosting.GetUserIDByEmail(db, userID)
+		if err != nil {
+			api.NotFound(w, "USER_NOT_FOUND", "No user with that email")
+			return
+		}
+		userID = id
+	}
+
+	if !leavesAnOwner(w, db, appID, userID) {
+		return
+	}
+
+	if err := hosting.RemoveAppMember(db, appID, userID); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":  appID,
+		"user_id": userID,
+		"message": "Member removed",
+	})
+}
+
+// appIDFromPath resolves the {id} path value to an app ID, writing 404 if unknown
+func appIDFromPath(w http.ResponseWriter, r *http.Request, db *sql.DB) (string, bool) {
+	identifier := r.PathValue("id")
+	if identifier == "" {
+		api.BadRequest(w, "id required")
+		return "", false
+	}
+
+	appID, err := resolveExistingApp(db, identifier)
+	if err != nil {
+		api.NotFound(w, "APP_NOT_FOUND", "App not found")
+		return "", false
+	}
+	return appID, true
+}
+
+// leavesAnOwner rejects demoting or removing the last owner of an app
+func leavesAnOwner(w http.ResponseWriter, db *sql.DB, appID, userID string) bool {
+	role, err := hosting.GetAppMemberRole(db, appID, userID)
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	if role != hosting.AppRoleOwner {
+		return true
+	}
+	owners, err := hosting.CountAppOwners(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	if owners <= 1 {
+		api.BadRequest(w, "cannot remove the last owner of an app")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// createTestUserKey creates an auth user and an API key bound to them
+func createTestUserKey(t *testing.T, userID, email string) string {
+	t.Helper()
+	db := database.GetDB()
+
+	if _, err := db.Exec(`INSERT INTO auth_users (id, email, provider, role) VALUES (?, ?, 'password', 'user')`, userID, email); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	token, err := hosting.CreateUserAPIKey(db, email, hosting.ScopeAdmin, userID)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	return token
+}
+
+func serveAppRoute(pattern string, h http.HandlerFunc, method, path, token, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, AppAccess(h))
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestAppAccess_RequiresAuth(t *testing.T) {
+	setupAppsV2Test(t)
+
+	resp := serveAppRoute("GET /api/apps", AppsListHandlerV2, "GET", "/api/apps", "", "")
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", resp.Code)
+	}
+}
+
+func TestAppAccess_MemberRoles(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+
+	mine := createTestAppV2(t, "mine")
+	other := createTestAppV2(t, "other")
+	token := createTestUserKey(t, "user_alice", "alice@example.com")
+	hosting.SetAppMember(db, mine, "user_alice", hosting.AppRoleViewer, "")
+
+	// List only shows member apps
+	resp := serveAppRoute("GET /api/apps", AppsListHandlerV2, "GET", "/api/apps?all=true", token, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), mine) || strings.Contains(resp.Body.String(), other) {
+		t.Errorf("Expected only member app in list, got %s", resp.Body.String())
+	}
+
+	// Non-member apps are hidden
+	resp = serveAppRoute("GET /api/apps/{id}", AppDetailHandlerV2, "GET", "/api/apps/"+other, token, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for non-member app, got %d", resp.Code)
+	}
+
+	// Viewer can read but not update
+	resp = serveAppRoute("GET /api/apps/{id}", AppDetailHandlerV2, "GET", "/api/apps/"+mine, token, "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected 200 for viewer, got %d", resp.Code)
+	}
+	resp = serveAppRoute("PUT /api/apps/{id}", AppUpdateHandlerV2, "PUT", "/api/apps/"+mine, token, `{"title":"renamed"}`)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for viewer update, got %d", resp.Code)
+	}
+
+	// Editor can update but not delete
+	hosting.SetAppMember(db, mine, "user_alice", hosting.AppRoleEditor, "")
+	resp = serveAppRoute("PUT /api/apps/{id}", AppUpdateHandlerV2, "PUT", "/api/apps/"+mine, token, `{"title":"renamed"}`)
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected 200 for editor update, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp = serveAppRoute("DELETE /api/apps/{id}", AppDeleteHandlerV2, "DELETE", "/api/apps/"+mine, token, "")
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for editor delete, got %d", resp.Code)
+	}
+}
+
+func TestAppAccess_CreateGrantsOwner(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+	token := createTestUserKey(t, "user_bob", "bob@example.com")
+
+	resp := serveAppRoute("POST /api/apps", AppCreateHandlerV2, "POST", "/api/apps", token, `{"title":"bobs-app"}`)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var appID string
+	db.QueryRow(`SELECT id FROM apps WHERE title = 'bobs-app'`).Scan(&appID)
+	if role, _ := hosting.GetAppMemberRole(db, appID, "user_bob"); role != hosting.AppRoleOwner {
+		t.Errorf("Expected creator to be owner, got %q", role)
+	}
+}
+
+func TestAppMembers_ShareAndRemove(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+
+	appID := createTestAppV2(t, "shared")
+	ownerToken := createTestUserKey(t, "user_owner", "owner@example.com")
+	createTestUserKey(t, "user_carol", "carol@example.com")
+	hosting.SetAppMember(db, appID, "user_owner", hosting.AppRoleOwner, "")
+
+	resp := serveAppRoute("POST /api/apps/{id}/members", AppMemberShareHandler, "POST", "/api/apps/shared/members",
+		ownerToken, `{"email":"carol@example.com","role":"editor"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if role, _ := hosting.GetAppMemberRole(db, appID, "user_carol"); role != hosting.AppRoleEditor {
+		t.Errorf("Expected carol to be editor, got %q", role)
+	}
+
+	resp = serveAppRoute("POST /api/apps/{id}/members", AppMemberShareHandler, "POST", "/api/apps/shared/members",
+		ownerToken, `{"email":"carol@example.com","role":"admin"}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid role, got %d", resp.Code)
+	}
+
+	// The last owner cannot be removed
+	resp = serveAppRoute("DELETE /api/apps/{id}/members/{user}", AppMemberRemoveHandler, "DELETE",
+		"/api/apps/shared/members/owner@example.com", ownerToken, "")
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when removing last owner, got %d", resp.Code)
+	}
+
+	resp = serveAppRoute("DELETE /api/apps/{id}/members/{user}", AppMemberRemoveHandler, "DELETE",
+		"/api/apps/shared/members/user_carol", ownerToken, "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.Code)
+	}
+	if role, _ := hosting.GetAppMemberRole(db, appID, "user_carol"); role != "" {
+		t.Errorf("Expected carol removed, got %q", role)
+	}
+}
+
+func TestAppAccess_AppScopedKey(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+
+	mine := createTestAppV2(t, "mine")
+	other := createTestAppV2(t, "other")
+	token, err := hosting.CreateScopedAPIKey(db, hosting.APIKeyOptions{Name: "ci", Scope: hosting.ScopeDeploy, AppID: mine})
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	resp := serveAppRoute("GET /api/apps", AppsListHandlerV2, "GET", "/api/apps?all=true", token, "")
+	if !strings.Contains(resp.Body.String(), mine) || strings.Contains(resp.Body.String(), other) {
+		t.Errorf("Expected only the key's app in list, got %s", resp.Body.String())
+	}
+
+	resp = serveAppRoute("GET /api/apps/{id}", AppDetailHandlerV2, "GET", "/api/apps/"+other, token, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another app, got %d", resp.Code)
+	}
+
+	// Deploy scope cannot modify apps through the API
+	resp = serveAppRoute("DELETE /api/apps/{id}", AppDeleteHandlerV2, "DELETE", "/api/apps/"+mine, token, "")
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for deploy-scoped delete, got %d", resp.Code)
+	}
+}
+
+func TestAPIKeysHandler_CreateAndRevoke(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+	createTestAppV2(t, "blog")
+
+	adminToken, _ := hosting.CreateAPIKey(db, "admin", hosting.ScopeAdmin)
+	readToken, _ := hosting.CreateAPIKey(db, "reader", hosting.ScopeRead)
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		APIKeysHandler(resp, req)
+		return resp
+	}
+
+	if resp := serve("GET", "/api/keys", readToken, ""); resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for read-scoped key, got %d", resp.Code)
+	}
+
+	resp := serve("POST", "/api/keys", adminToken, `{"name":"ci","scopes":"deploy","app":"blog","expires":"30d"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if !strings.Contains(resp.Body.String(), `"expires_at"`) {
+		t.Errorf("Expected expires_at in response, got %s", resp.Body.String())
+	}
+
+	if resp := serve("POST", "/api/keys", adminToken, `{"name":"bad","scopes":"root"}`); resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid scope, got %d", resp.Code)
+	}
+	if resp := serve("POST", "/api/keys", adminToken, `{"name":"bad","app":"missing"}`); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown app, got %d", resp.Code)
+	}
+
+	var id string
+	db.QueryRow(`SELECT id FROM api_keys WHERE name = 'reader'`).Scan(&id)
+	if resp := serve("DELETE", "/api/keys?id="+id, adminToken, ""); resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on revoke, got %d", resp.Code)
+	}
+	if _, _, err := hosting.ValidateAPIKey(db, readToken); err == nil {
+		t.Error("Expected revoked key to be rejected")
+	}
+	if resp := serve("DELETE", "/api/keys?id="+id, adminToken, ""); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 when revoking twice, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// AppAuthProviderRequest is the request body for enabling an app OAuth provider
+type AppAuthProviderRequest struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// AppAuthHandler lists an app's end-user OAuth providers
+// GET /api/apps/{id}/auth
+func AppAuthHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	providers, err := authService.ListAppProviders(appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":    appID,
+		"providers": providers,
+	})
+}
+
+// AppAuthEnableHandler configures and enables an OAuth provider for an app.
+// The client ID may be omitted to re-enable a previously configured provider.
+// PUT /api/apps/{id}/auth/providers/{provider}
+func AppAuthEnableHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	provider := r.PathValue("provider")
+	if _, known := auth.Providers[provider]; !known {
+		api.BadRequest(w, "unknown provider: "+provider)
+		return
+	}
+
+	var req AppAuthProviderRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.InvalidJSON(w, "Invalid request body")
+			return
+		}
+	}
+
+	if err := authService.SetAppProvider(appID, provider, req.ClientID, req.ClientSecret); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":   appID,
+		"provider": provider,
+		"enabled":  true,
+		"login":    "/auth/app/" + appID + "/login?provider=" + provider,
+		"callback": "/auth/app/" + appID + "/callback/" + provider,
+	})
+}
+
+// AppAuthDisableHandler disables an app's OAuth provider
+// DELETE /api/apps/{id}/auth/providers/{provider}
+func AppAuthDisableHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	provider := r.PathValue("provider")
+	if err := authService.DisableAppProvider(appID, provider); err != nil {
+		if err == auth.ErrProviderDisabled {
+			api.NotFound(w, "PROVIDER_NOT_FOUND", "Provider not configured for this app")
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":   appID,
+		"provider": provider,
+		"enabled":  false,
+	})
+}
+
+// AppAuthUsersHandler lists the end-users that have signed in to an app
+// GET /api/apps/{id}/auth/users
+func AppAuthUsersHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	users, err := authService.ListAppUsers(appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id": appID,
+		"users":  users,
+	})
+}
+
+// AppAuthUserDeleteHandler removes an app end-user and signs them out
+// DELETE /api/apps/{id}/auth/users/{user}
+func AppAuthUserDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	userID := r.PathValue("user")
+	if err := authService.DeleteAppUser(appID, userID); err != nil {
+		if err == auth.ErrAppUserNotFound {
+			api.NotFound(w, "USER_NOT_FOUND", err.Error())
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":  appID,
+		"user_id": userID,
+		"message": "User removed",
+	})
+}
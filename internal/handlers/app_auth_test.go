@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func setupAppAuthTest(t *testing.T) {
+	t.Helper()
+	setupAppsV2Test(t)
+
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(auth.NewService(database.GetDB(), "test.local", false), limiter, "v0.8.0-test")
+}
+
+func TestAppAuth_EnableAndDisable(t *testing.T) {
+	setupAppAuthTest(t)
+	db := database.GetDB()
+
+	appID := createTestAppV2(t, "notes")
+	ownerToken := createTestUserKey(t, "user_owner", "owner@example.com")
+	viewerToken := createTestUserKey(t, "user_viewer", "viewer@example.com")
+	hosting.SetAppMember(db, appID, "user_owner", hosting.AppRoleOwner, "")
+	hosting.SetAppMember(db, appID, "user_viewer", hosting.AppRoleViewer, "")
+
+	body := `{"client_id":"gh-client","client_secret":"gh-secret"}`
+
+	// Viewers can't configure sign-in
+	resp := serveAppRoute("PUT /api/apps/{id}/auth/providers/{provider}", AppAuthEnableHandler, "PUT",
+		"/api/apps/notes/auth/providers/github", viewerToken, body)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for viewer, got %d", resp.Code)
+	}
+
+	resp = serveAppRoute("PUT /api/apps/{id}/auth/providers/{provider}", AppAuthEnableHandler, "PUT",
+		"/api/apps/notes/auth/providers/myspace", ownerToken, body)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown provider, got %d", resp.Code)
+	}
+
+	resp = serveAppRoute("PUT /api/apps/{id}/auth/providers/{provider}", AppAuthEnableHandler, "PUT",
+		"/api/apps/notes/auth/providers/github", ownerToken, body)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = serveAppRoute("GET /api/apps/{id}/auth", AppAuthHandler, "GET", "/api/apps/notes/auth", viewerToken, "")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+	if !strings.Contains(resp.Body.String(), `"client_id":"gh-client"`) || strings.Contains(resp.Body.String(), "gh-secret") {
+		t.Errorf("Expected client ID without secret: %s", resp.Body.String())
+	}
+
+	resp = serveAppRoute("DELETE /api/apps/{id}/auth/providers/{provider}", AppAuthDisableHandler, "DELETE",
+		"/api/apps/notes/auth/providers/github", ownerToken, "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.Code)
+	}
+	if cfg, _ := authService.GetAppProvider(appID, "github"); cfg == nil || cfg.Enabled {
+		t.Errorf("Expected provider disabled, got %+v", cfg)
+	}
+
+	resp = serveAppRoute("DELETE /api/apps/{id}/auth/providers/{provider}", AppAuthDisableHandler, "DELETE",
+		"/api/apps/notes/auth/providers/google", ownerToken, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unconfigured provider, got %d", resp.Code)
+	}
+}
+
+func TestAppAuth_Users(t *testing.T) {
+	setupAppAuthTest(t)
+	db := database.GetDB()
+
+	appID := createTestAppV2(t, "notes")
+	ownerToken := createTestUserKey(t, "user_owner", "owner@example.com")
+	hosting.SetAppMember(db, appID, "user_owner", hosting.AppRoleOwner, "")
+
+	db.Exec(`INSERT INTO app_users (id, app_id, provider, provider_id, email) VALUES ('fazt_apu_one', ?, 'github', '1', 'one@example.com')`, appID)
+
+	resp := serveAppRoute("GET /api/apps/{id}/auth/users", AppAuthUsersHandler, "GET", "/api/apps/notes/auth/users", ownerToken, "")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "one@example.com") {
+		t.Fatalf("Expected user listed, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = serveAppRoute("DELETE /api/apps/{id}/auth/users/{user}", AppAuthUserDeleteHandler, "DELETE",
+		"/api/apps/notes/auth/users/fazt_apu_one", ownerToken, "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.Code)
+	}
+
+	resp = serveAppRoute("DELETE /api/apps/{id}/auth/users/{user}", AppAuthUserDeleteHandler, "DELETE",
+		"/api/apps/notes/auth/users/fazt_apu_one", ownerToken, "")
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.Code)
+	}
+}
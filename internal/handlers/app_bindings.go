@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/chaos"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// bindingCallPattern matches the fazt.app.* storage calls the dev shim may
+// make, e.g. "kv.get" or "user.ds.find"
+var bindingCallPattern = regexp.MustCompile(`^(user\.)?(kv|ds|s3)\.[A-Za-z]+$`)
+
+// AppBindingCallHandler runs one fazt.app.* storage call against an app's
+// real storage, so the fazt-sdk shim can back code running under Vite or
+// Node with a local dev server. Development servers only.
+// POST /api/apps/{id}/bindings {"call": "ds.find", "args": ["posts", {}], "user": "u1"}
+func AppBindingCallHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleEditor) {
+		return
+	}
+	if !config.Get().IsDevelopment() {
+		api.Forbidden(w, "Binding calls are only available on development servers")
+		return
+	}
+
+	var req struct {
+		Call string        `json:"call"`
+		Args []interface{} `json:"args"`
+		User string        `json:"user"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if !bindingCallPattern.MatchString(req.Call) {
+		api.BadRequest(w, "call must look like kv.get, ds.find or user.s3.put")
+		return
+	}
+	if strings.HasPrefix(req.Call, "user.") && req.User == "" {
+		api.BadRequest(w, "user required for fazt.app.user.* calls")
+		return
+	}
+
+	// Handlers' storage is keyed by site ID, not app ID
+	siteID, err := ResolveAppSiteID(appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	// Chaos mode disrupts the shim's calls as it does the handlers'
+	ctx := chaos.WithApp(r.Context(), siteID)
+	vm := goja.New()
+	if err := storage.InjectAppNamespace(vm, database.GetDB(), storage.GetWriter(), siteID, req.User, ctx, nil); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	// Walk fazt.app.<call> down to the function
+	target := vm.Get("fazt").ToObject(vm).Get("app")
+	for _, part := range strings.Split(req.Call, ".") {
+		obj, ok := target.(*goja.Object)
+		if !ok {
+			target = nil
+			break
+		}
+		target = obj.Get(part)
+	}
+	fn, ok := goja.AssertFunction(target)
+	if !ok {
+		api.BadRequest(w, "unknown binding: fazt.app."+req.Call)
+		return
+	}
+
+	args := make([]goja.Value, len(req.Args))
+	for i, arg := range req.Args {
+		args[i] = vm.ToValue(arg)
+	}
+	result, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		if exc, ok := err.(*goja.Exception); ok {
+			msg := exc.Error()
+			if obj, ok := exc.Value().(*goja.Object); ok {
+				if m := obj.Get("message"); m != nil && !goja.IsUndefined(m) {
+					msg = m.String()
+				}
+			}
+			api.BadRequest(w, msg)
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"result": result.Export(),
+	})
+}
+
+// devAppSiteID checks the caller may use a development-only feature on the
+// app in the path, and returns the site ID its handlers and jobs run under,
+// which such features are keyed by
+func devAppSiteID(w http.ResponseWriter, r *http.Request, feature string) (string, bool) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleEditor)
+	if !ok {
+		return "", false
+	}
+	if !config.Get().IsDevelopment() {
+		api.Forbidden(w, feature+" is only available on development servers")
+		return "", false
+	}
+	return siteID, true
+}
+
+// appSiteID checks the caller has a role on the app in the path, and
+// returns the site ID its handlers and jobs run under
+func appSiteID(w http.ResponseWriter, r *http.Request, role string) (string, bool) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, role) {
+		return "", false
+	}
+	siteID, err := ResolveAppSiteID(appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return "", false
+	}
+	return siteID, true
+}
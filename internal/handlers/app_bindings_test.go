@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func callBinding(appID string, body map[string]interface{}) *httptest.ResponseRecorder {
+	req := testutil.JSONRequest("POST", "/api/apps/"+appID+"/bindings", body)
+	req.SetPathValue("id", appID)
+	resp := httptest.NewRecorder()
+	AppBindingCallHandler(resp, req)
+	return resp
+}
+
+func TestAppBindingCallHandler(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "notes")
+
+	// Off outside development
+	resp := callBinding(appID, map[string]interface{}{"call": "kv.get", "args": []interface{}{"k"}})
+	testutil.CheckError(t, resp, http.StatusForbidden, "FORBIDDEN")
+
+	config.SetConfig(&config.Config{Server: config.ServerConfig{Domain: "test.local", Env: "development"}})
+
+	resp = callBinding(appID, map[string]interface{}{"call": "ds.insert", "args": []interface{}{"notes", map[string]interface{}{"text": "hi"}}})
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if id, _ := data["result"].(string); id == "" {
+		t.Fatalf("Expected an inserted id, got %v", data["result"])
+	}
+
+	resp = callBinding(appID, map[string]interface{}{"call": "ds.count", "args": []interface{}{"notes", map[string]interface{}{"text": "hi"}}})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "result", float64(1))
+
+	// Stored where the app's handlers read it: under the site ID
+	var count int
+	database.GetDB().QueryRow(`SELECT COUNT(*) FROM app_docs WHERE app_id = 'notes'`).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 doc under site ID notes, got %d", count)
+	}
+
+	// User-scoped storage is separate from shared storage
+	resp = callBinding(appID, map[string]interface{}{"call": "user.ds.count", "args": []interface{}{"notes", map[string]interface{}{}}, "user": "u1"})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "result", float64(0))
+
+	for _, body := range []map[string]interface{}{
+		{"call": "user.kv.get", "args": []interface{}{"k"}},
+		{"call": "media.serve", "args": []interface{}{"a.png"}},
+		{"call": "kv.nope"},
+		{"call": "ds.insert", "args": []interface{}{}},
+	} {
+		resp = callBinding(appID, body)
+		testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/chaos"
+)
+
+// AppChaosHandler shows an app's chaos settings and what they've disrupted.
+// Development servers only.
+// GET /api/apps/{id}/chaos
+func AppChaosHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Chaos mode")
+	if !ok {
+		return
+	}
+	api.Success(w, http.StatusOK, chaos.Get(siteID))
+}
+
+// AppChaosSetHandler turns chaos on for an app: its storage and egress calls
+// get random latency and fail at the given rate. Development servers only.
+// PUT /api/apps/{id}/chaos {"rate": 0.2, "latency_ms": 500, "storage": true, "egress": true}
+func AppChaosSetHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Chaos mode")
+	if !ok {
+		return
+	}
+
+	var cfg chaos.Config
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	if cfg.Rate > 0 && cfg.LatencyMS == 0 && !cfg.Storage && !cfg.Egress {
+		api.BadRequest(w, "set latency_ms, storage or egress")
+		return
+	}
+
+	chaos.Set(siteID, cfg)
+	api.Success(w, http.StatusOK, chaos.Get(siteID))
+}
+
+// AppChaosClearHandler turns chaos off for an app. Development servers only.
+// DELETE /api/apps/{id}/chaos
+func AppChaosClearHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Chaos mode")
+	if !ok {
+		return
+	}
+	chaos.Clear(siteID)
+	api.Success(w, http.StatusOK, chaos.Get(siteID))
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/chaos"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func callChaos(method, appID string, body interface{}) *httptest.ResponseRecorder {
+	req := testutil.JSONRequest(method, "/api/apps/"+appID+"/chaos", body)
+	req.SetPathValue("id", appID)
+	resp := httptest.NewRecorder()
+	switch method {
+	case "GET":
+		AppChaosHandler(resp, req)
+	case "PUT":
+		AppChaosSetHandler(resp, req)
+	case "DELETE":
+		AppChaosClearHandler(resp, req)
+	}
+	return resp
+}
+
+func TestAppChaosHandlers(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "notes")
+	t.Cleanup(func() { chaos.Clear("notes") })
+
+	// Off outside development
+	resp := callChaos("PUT", appID, map[string]interface{}{"rate": 1, "storage": true})
+	testutil.CheckError(t, resp, http.StatusForbidden, "FORBIDDEN")
+
+	config.SetConfig(&config.Config{Server: config.ServerConfig{Domain: "test.local", Env: "development"}})
+
+	for _, body := range []map[string]interface{}{
+		{"rate": 1.5, "storage": true},
+		{"rate": 0.5, "latency_ms": 20000},
+		{"rate": 0.5},
+	} {
+		resp = callChaos("PUT", appID, body)
+		testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+	}
+
+	resp = callChaos("PUT", appID, map[string]interface{}{"rate": 1, "storage": true})
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "enabled", true)
+
+	// Keyed by the site ID the app's handlers run under
+	if !chaos.Get("notes").Enabled {
+		t.Fatal("Expected chaos on for site notes")
+	}
+
+	// The shim's binding calls are disrupted too
+	resp = callBinding(appID, map[string]interface{}{"call": "kv.get", "args": []interface{}{"k"}})
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	resp = callChaos("GET", appID, nil)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "injected", float64(1))
+
+	resp = callChaos("DELETE", appID, nil)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "enabled", false)
+
+	resp = callBinding(appID, map[string]interface{}{"call": "kv.get", "args": []interface{}{"k"}})
+	testutil.CheckSuccess(t, resp, http.StatusOK)
+}
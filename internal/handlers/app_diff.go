@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// Relations between two diffed apps
+const (
+	RelationFork      = "fork"      // app was forked from against
+	RelationOriginal  = "original"  // against was forked from app
+	RelationLineage   = "lineage"   // same original, further apart
+	RelationUnrelated = "unrelated" // different lineages
+)
+
+// AppDiff compares an app with another, usually the app it was forked from
+type AppDiff struct {
+	App      AppDiffSide    `json:"app"`
+	Against  AppDiffSide    `json:"against"`
+	Relation string         `json:"relation"`
+	Files    []FileDiff     `json:"files"` // changed files only, by path
+	Metadata []MetadataDiff `json:"metadata"`
+	Summary  map[string]int `json:"summary"` // added, removed, modified, unchanged
+	// AgainstChanged reports whether the original was updated after the
+	// fork was made, i.e. the fork is behind it
+	AgainstChanged bool `json:"against_changed"`
+}
+
+// AppDiffSide is one app of a diff
+type AppDiffSide struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	ForkedFromID string `json:"forked_from_id,omitempty"`
+	OriginalID   string `json:"original_id,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+	FileCount    int    `json:"file_count"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+// FileDiff is a file that differs between the apps. Added files exist only
+// in the app, removed files only in against.
+type FileDiff struct {
+	Path        string `json:"path"`
+	Status      string `json:"status"` // added, removed, modified
+	Size        int64  `json:"size"`
+	AgainstSize int64  `json:"against_size"`
+}
+
+// MetadataDiff is an app field that differs
+type MetadataDiff struct {
+	Field   string `json:"field"`
+	App     string `json:"app"`
+	Against string `json:"against"`
+}
+
+type fileHash struct {
+	hash string
+	size int64
+}
+
+// AppDiffHandler compares an app's files and metadata with another app,
+// by default the one it was forked from
+// GET /api/apps/{id}/diff?against=<id|alias>
+func AppDiffHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	app, err := getAppByID(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	againstID := app.ForkedFromID
+	if identifier := r.URL.Query().Get("against"); identifier != "" {
+		if againstID, err = resolveExistingApp(db, identifier); err != nil {
+			api.NotFound(w, "APP_NOT_FOUND", "App to compare against not found")
+			return
+		}
+	}
+	if againstID == "" {
+		api.BadRequest(w, "app is not a fork; pass ?against=<app>")
+		return
+	}
+	if againstID == appID {
+		api.BadRequest(w, "cannot diff an app against itself")
+		return
+	}
+	if !requireAppRole(w, r, againstID, hosting.AppRoleViewer) {
+		return
+	}
+	against, err := getAppByID(db, againstID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	diff, err := diffApps(db, app, against)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, diff)
+}
+
+// diffApps computes the diff of app against another app
+func diffApps(db *sql.DB, app, against *AppV2) (*AppDiff, error) {
+	appFiles, err := appFileHashes(db, app.ID, deploySite(app))
+	if err != nil {
+		return nil, err
+	}
+	againstFiles, err := appFileHashes(db, against.ID, deploySite(against))
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &AppDiff{
+		App:      diffSide(app, appFiles),
+		Against:  diffSide(against, againstFiles),
+		Relation: relationOf(app, against),
+		Files:    []FileDiff{},
+		Metadata: []MetadataDiff{},
+		Summary:  map[string]int{"added": 0, "removed": 0, "modified": 0, "unchanged": 0},
+	}
+
+	for path, f := range appFiles {
+		other, ok := againstFiles[path]
+		status := "unchanged"
+		if !ok {
+			status = "added"
+		} else if other.hash != f.hash {
+			status = "modified"
+		}
+		diff.Summary[status]++
+		if status != "unchanged" {
+			diff.Files = append(diff.Files, FileDiff{Path: path, Status: status, Size: f.size, AgainstSize: other.size})
+		}
+	}
+	for path, f := range againstFiles {
+		if _, ok := appFiles[path]; !ok {
+			diff.Summary["removed"]++
+			diff.Files = append(diff.Files, FileDiff{Path: path, Status: "removed", AgainstSize: f.size})
+		}
+	}
+	sort.Slice(diff.Files, func(i, j int) bool { return diff.Files[i].Path < diff.Files[j].Path })
+
+	fields := []struct{ name, app, against string }{
+		{"title", app.Title, against.Title},
+		{"description", app.Description, against.Description},
+		{"tags", strings.Join(app.Tags, ","), strings.Join(against.Tags, ",")},
+		{"visibility", app.Visibility, against.Visibility},
+		{"source", app.Source, against.Source},
+		{"source_url", app.SourceURL, against.SourceURL},
+		{"source_commit", app.SourceCommit, against.SourceCommit},
+	}
+	for _, f := range fields {
+		if f.app != f.against {
+			diff.Metadata = append(diff.Metadata, MetadataDiff{Field: f.name, App: f.app, Against: f.against})
+		}
+	}
+
+	if diff.Relation == RelationFork {
+		err := db.QueryRow(`SELECT COALESCE((SELECT updated_at FROM apps WHERE id = ?) > (SELECT created_at FROM apps WHERE id = ?), 0)`,
+			against.ID, app.ID).Scan(&diff.AgainstChanged)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return diff, nil
+}
+
+// appFileHashes returns an app's files by path. Deployed files are keyed by
+// the app's name, copied (forked) files by its ID.
+func appFileHashes(db *sql.DB, appID, title string) (map[string]fileHash, error) {
+	rows, err := db.Query(`
+		SELECT path, size_bytes, COALESCE(hash, ''),
+			CASE WHEN COALESCE(hash, '') = '' THEN content END
+		FROM files
+		WHERE app_id = ? OR (site_id = ? AND app_id IS NULL)
+	`, appID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make(map[string]fileHash)
+	for rows.Next() {
+		var path, hash string
+		var size int64
+		var content []byte
+		if err := rows.Scan(&path, &size, &hash, &content); err != nil {
+			return nil, err
+		}
+		if hash == "" {
+			sum := sha256.Sum256(content)
+			hash = hex.EncodeToString(sum[:])
+		}
+		files[path] = fileHash{hash: hash, size: size}
+	}
+	return files, rows.Err()
+}
+
+// deploySite returns the site an app's deployed files are keyed by. Forks
+// share their original's title but only have copied files.
+func deploySite(app *AppV2) string {
+	if app.ForkedFromID != "" {
+		return ""
+	}
+	return app.Title
+}
+
+func diffSide(app *AppV2, files map[string]fileHash) AppDiffSide {
+	side := AppDiffSide{
+		ID:           app.ID,
+		Title:        app.Title,
+		ForkedFromID: app.ForkedFromID,
+		OriginalID:   app.OriginalID,
+		CreatedAt:    app.CreatedAt,
+		UpdatedAt:    app.UpdatedAt,
+		FileCount:    len(files),
+	}
+	for _, f := range files {
+		side.SizeBytes += f.size
+	}
+	return side
+}
+
+// relationOf describes how app and against are related by forking
+func relationOf(app, against *AppV2) string {
+	switch {
+	case app.ForkedFromID == against.ID:
+		return RelationFork
+	case against.ForkedFromID == app.ID:
+		return RelationOriginal
+	case rootOf(app) == rootOf(against):
+		return RelationLineage
+	}
+	return RelationUnrelated
+}
+
+// rootOf returns the first app of an app's lineage
+func rootOf(app *AppV2) string {
+	if app.OriginalID != "" {
+		return app.OriginalID
+	}
+	return app.ID
+}
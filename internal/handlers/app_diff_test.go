@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func insertTestFile(t *testing.T, siteID, appID, path, content string) {
+	t.Helper()
+	var appIDValue interface{}
+	if appID != "" {
+		appIDValue = appID
+	}
+	_, err := database.GetDB().Exec(`INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash, app_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		siteID, path, content, len(content), "text/plain", "", appIDValue)
+	if err != nil {
+		t.Fatalf("Failed to insert file: %v", err)
+	}
+}
+
+func TestAppDiffHandler_Fork(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+
+	parentID := createTestAppV2(t, "blog")
+	forkID := "app_fork1"
+	_, err := db.Exec(`
+		INSERT INTO apps (id, original_id, forked_from_id, title, source, visibility, created_at, updated_at)
+		VALUES (?, ?, ?, 'blog-fork', 'deploy', 'unlisted', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, forkID, parentID, parentID)
+	if err != nil {
+		t.Fatalf("Failed to create fork: %v", err)
+	}
+
+	// The original is keyed by name, the fork's copies by ID
+	insertTestFile(t, "blog", "", "index.html", "<h1>Blog</h1>")
+	insertTestFile(t, "blog", "", "api/main.js", "respond('v1')")
+	insertTestFile(t, "blog", "", "old.css", "body {}")
+	insertTestFile(t, forkID, forkID, "index.html", "<h1>Blog</h1>")
+	insertTestFile(t, forkID, forkID, "api/main.js", "respond('v2')")
+	insertTestFile(t, forkID, forkID, "new.css", "h1 {}")
+
+	req := httptest.NewRequest("GET", "/api/apps/"+forkID+"/diff", nil)
+	req.SetPathValue("id", forkID)
+	resp := httptest.NewRecorder()
+	AppDiffHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "relation", RelationFork)
+	testutil.AssertFieldEquals(t, data, "against_changed", false)
+
+	summary, _ := data["summary"].(map[string]interface{})
+	for status, want := range map[string]float64{"added": 1, "removed": 1, "modified": 1, "unchanged": 1} {
+		if summary[status] != want {
+			t.Errorf("Expected %s = %v, got %v", status, want, summary[status])
+		}
+	}
+
+	files, _ := data["files"].([]interface{})
+	want := []string{"api/main.js:modified", "new.css:added", "old.css:removed"}
+	if len(files) != len(want) {
+		t.Fatalf("Expected %d changed files, got %v", len(want), files)
+	}
+	for i, f := range files {
+		file := f.(map[string]interface{})
+		if got := file["path"].(string) + ":" + file["status"].(string); got != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], got)
+		}
+	}
+
+	metadata, _ := data["metadata"].([]interface{})
+	if len(metadata) != 1 || metadata[0].(map[string]interface{})["field"] != "title" {
+		t.Errorf("Expected only title to differ, got %v", metadata)
+	}
+}
+
+func TestAppDiffHandler_NotAFork(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "standalone")
+
+	req := httptest.NewRequest("GET", "/api/apps/"+id+"/diff", nil)
+	req.SetPathValue("id", id)
+	resp := httptest.NewRecorder()
+	AppDiffHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	other := createTestAppV2(t, "other")
+	req = httptest.NewRequest("GET", "/api/apps/"+id+"/diff?against="+other, nil)
+	req.SetPathValue("id", id)
+	resp = httptest.NewRecorder()
+	AppDiffHandler(resp, req)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "relation", RelationUnrelated)
+
+	req = httptest.NewRequest("GET", "/api/apps/"+id+"/diff?against=missing", nil)
+	req.SetPathValue("id", id)
+	resp = httptest.NewRecorder()
+	AppDiffHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusNotFound, "APP_NOT_FOUND")
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+// siteRenderer serves apps in-process for static exports, addressing them
+// as <app>.<siteDomain>; both are set by the server at startup
+var (
+	siteRenderer http.Handler
+	siteDomain   string
+)
+
+// SetSiteRenderer sets the handler static exports render an app's dynamic
+// routes through, and the domain apps are served under
+func SetSiteRenderer(handler http.Handler, domain string) {
+	siteRenderer = handler
+	siteDomain = domain
+}
+
+// AppExportHandler returns an app as a ZIP of static files any static host
+// can serve: its files but api/ and private/, and its dynamic routes
+// rendered by the server, from the manifest's "export" section and the
+// route parameters. Routes must return 200.
+// GET /api/apps/{id}/export?route=/api/posts
+func AppExportHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+	app, err := getAppByID(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	files, err := hosting.StaticExportFiles(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	routes, err := hosting.StaticExportRoutes(db, appID, r.URL.Query()["route"])
+	if errors.Is(err, hosting.ErrInvalidManifest) {
+		api.ValidationError(w, err.Error(), "manifest", "format")
+		return
+	} else if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if len(routes) > 0 && siteRenderer == nil {
+		api.ServiceUnavailable(w, "Routes can't be rendered on this server")
+		return
+	}
+
+	// Rendered routes replace files at the same path, e.g. "/" index.html
+	index := make(map[string]int, len(files))
+	for i, f := range files {
+		index[f.Path] = i
+	}
+	for _, route := range routes {
+		if _, err := hosting.StaticRoutePath(route, ""); err != nil {
+			api.ValidationError(w, err.Error(), "route", "format")
+			return
+		}
+		rec := renderRoute(app.Title, route)
+		if rec.Code != http.StatusOK {
+			api.Error(w, http.StatusUnprocessableEntity, "ROUTE_FAILED",
+				fmt.Sprintf("%s returned %d; only routes that return 200 can be exported", route, rec.Code),
+				map[string]interface{}{"route": route, "status": rec.Code})
+			return
+		}
+		path, _ := hosting.StaticRoutePath(route, rec.Header().Get("Content-Type"))
+		file := hosting.DeployFile{Path: path, Content: rec.Body.Bytes()}
+		if i, ok := index[path]; ok {
+			files[i] = file
+		} else {
+			index[path] = len(files)
+			files = append(files, file)
+		}
+	}
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+	if conflicts := hosting.StaticExportConflicts(paths); len(conflicts) > 0 {
+		api.ValidationError(w, fmt.Sprintf("%s would be both a file and a directory; export one of its routes only",
+			strings.Join(conflicts, ", ")), "route", "format")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-static.zip"`, app.Title))
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.Path)
+		if err == nil {
+			_, err = fw.Write(f.Content)
+		}
+		if err != nil {
+			// Headers are sent; the client sees a truncated ZIP
+			log.Printf("Export of %s failed: %v", app.Title, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Export of %s failed: %v", app.Title, err)
+	}
+}
+
+// renderRoute requests route from an app in-process, like a visitor
+// without a session, marked as a probe so it isn't counted as a visit
+func renderRoute(title, route string) *httptest.ResponseRecorder {
+	host := title + "." + siteDomain
+	if title == "root" {
+		host = siteDomain
+	}
+	req := httptest.NewRequest(http.MethodGet, route, nil).WithContext(status.WithProbe(context.Background()))
+	req.Host = host
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("User-Agent", "fazt-export")
+
+	rec := httptest.NewRecorder()
+	siteRenderer.ServeHTTP(rec, req)
+	return rec
+}
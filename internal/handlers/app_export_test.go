@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func TestAppExportHandler(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "blog")
+	db := database.GetDB()
+	_, err := db.Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash) VALUES
+		('blog', ?, 'index.html', '<h1>hi</h1>', 11, 'text/html', 'h1'),
+		('blog', ?, 'missing.html', 'gone', 4, 'text/html', 'h2'),
+		('blog', ?, 'manifest.json', '{"export": {"routes": ["/api/posts"]}, "routes": {"not_found": "missing.html"}}', 80, 'application/json', 'h3'),
+		('blog', ?, 'api/main.js', 'respond({})', 11, 'text/javascript', 'h4'),
+		('blog', ?, 'private/notes.txt', 'secret', 6, 'text/plain', 'h5')`,
+		appID, appID, appID, appID, appID)
+	if err != nil {
+		t.Fatalf("Failed to insert files: %v", err)
+	}
+
+	var hosts []string
+	site := http.NewServeMux()
+	site.HandleFunc("/api/posts", func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"title":"hi"}]`))
+	})
+	site.HandleFunc("/about", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<p>about</p>"))
+	})
+	SetSiteRenderer(site, "test.local")
+	t.Cleanup(func() { SetSiteRenderer(nil, "") })
+
+	export := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/apps/blog/export"+query, nil)
+		req.SetPathValue("id", "blog")
+		resp := httptest.NewRecorder()
+		AppExportHandler(resp, req)
+		return resp
+	}
+
+	resp := export("?route=/about")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(resp.Body.Bytes()), int64(resp.Body.Len()))
+	if err != nil {
+		t.Fatalf("Expected a ZIP: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		b, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(b)
+	}
+	want := map[string]string{
+		"index.html":       "<h1>hi</h1>",
+		"missing.html":     "gone",
+		"404.html":         "gone",
+		"api/posts":        `[{"title":"hi"}]`,
+		"about/index.html": "<p>about</p>",
+	}
+	for path, content := range want {
+		if files[path] != content {
+			t.Errorf("Expected %s to be %q, got %q", path, content, files[path])
+		}
+	}
+	for _, path := range []string{"api/main.js", "private/notes.txt"} {
+		if _, ok := files[path]; ok {
+			t.Errorf("Expected %s left out", path)
+		}
+	}
+	if len(hosts) != 1 || hosts[0] != "blog.test.local" {
+		t.Errorf("Expected the route rendered on blog.test.local, got %v", hosts)
+	}
+
+	// Routes that don't return 200 fail the export
+	resp = export("?route=/nope")
+	testutil.CheckError(t, resp, http.StatusUnprocessableEntity, "ROUTE_FAILED")
+	resp = export("?route=about")
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+// fixturesResponse is an app's fetch fixture mode ("" when off) and fixtures
+func fixturesResponse(siteID string) map[string]interface{} {
+	mode, set := egress.AppFixtures(siteID)
+	fixtures := []egress.Fixture{}
+	if set != nil {
+		fixtures = set.All()
+	}
+	return map[string]interface{}{
+		"mode":     mode,
+		"fixtures": fixtures,
+	}
+}
+
+// AppFixturesHandler shows an app's fetch fixture mode and the fixtures
+// recorded or loaded so far. Development servers only.
+// GET /api/apps/{id}/fixtures
+func AppFixturesHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Fetch fixtures")
+	if !ok {
+		return
+	}
+	api.Success(w, http.StatusOK, fixturesResponse(siteID))
+}
+
+// AppFixturesSetHandler puts an app's fetches in record mode, where their
+// responses become fixtures, or replay mode, where fixtures answer them and
+// nothing goes out. Fixtures in the body replace the app's; without them it
+// keeps those it has, so recording then replaying needs no upload.
+// Development servers only.
+// PUT /api/apps/{id}/fixtures {"mode": "replay", "fixtures": [...]}
+func AppFixturesSetHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Fetch fixtures")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Mode     string            `json:"mode"`
+		Fixtures *[]egress.Fixture `json:"fixtures"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	set := egress.NewFixtureSet(nil)
+	if req.Fixtures != nil {
+		set = egress.NewFixtureSet(*req.Fixtures)
+	} else if _, current := egress.AppFixtures(siteID); current != nil {
+		set = current
+	}
+	if err := egress.SetAppFixtures(siteID, req.Mode, set); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, fixturesResponse(siteID))
+}
+
+// AppFixturesClearHandler turns record and replay off for an app and drops
+// its fixtures. Development servers only.
+// DELETE /api/apps/{id}/fixtures
+func AppFixturesClearHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := devAppSiteID(w, r, "Fetch fixtures")
+	if !ok {
+		return
+	}
+	egress.ClearAppFixtures(siteID)
+	api.Success(w, http.StatusOK, fixturesResponse(siteID))
+}
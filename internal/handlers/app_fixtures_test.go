@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/egress"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func callFixtures(method, appID string, body interface{}) *httptest.ResponseRecorder {
+	req := testutil.JSONRequest(method, "/api/apps/"+appID+"/fixtures", body)
+	req.SetPathValue("id", appID)
+	resp := httptest.NewRecorder()
+	switch method {
+	case "GET":
+		AppFixturesHandler(resp, req)
+	case "PUT":
+		AppFixturesSetHandler(resp, req)
+	case "DELETE":
+		AppFixturesClearHandler(resp, req)
+	}
+	return resp
+}
+
+func TestAppFixturesHandlers(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "rates")
+	t.Cleanup(func() { egress.ClearAppFixtures("rates") })
+
+	// Off outside development
+	resp := callFixtures("PUT", appID, map[string]interface{}{"mode": "record"})
+	testutil.CheckError(t, resp, http.StatusForbidden, "FORBIDDEN")
+
+	config.SetConfig(&config.Config{Server: config.ServerConfig{Domain: "test.local", Env: "development"}})
+
+	resp = callFixtures("PUT", appID, map[string]interface{}{"mode": "rewind"})
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	resp = callFixtures("PUT", appID, map[string]interface{}{"mode": "record"})
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "mode", "record")
+
+	// Keyed by the site ID the app's handlers run under
+	_, set := egress.AppFixtures("rates")
+	if set == nil {
+		t.Fatal("Expected fixtures for site rates")
+	}
+	set.Session().Record("GET", "https://api.test/usd", "", egress.FixtureResponse{Status: 200, Body: "{}"})
+
+	// Switching to replay keeps what was recorded
+	resp = callFixtures("PUT", appID, map[string]interface{}{"mode": "replay"})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "mode", "replay")
+	if fixtures, _ := data["fixtures"].([]interface{}); len(fixtures) != 1 {
+		t.Errorf("Expected the recorded fixture, got %v", data["fixtures"])
+	}
+
+	// Uploaded fixtures replace them
+	resp = callFixtures("PUT", appID, map[string]interface{}{"mode": "replay", "fixtures": []interface{}{}})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	if fixtures, _ := data["fixtures"].([]interface{}); len(fixtures) != 0 {
+		t.Errorf("Expected no fixtures, got %v", data["fixtures"])
+	}
+
+	resp = callFixtures("DELETE", appID, nil)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "mode", "")
+}
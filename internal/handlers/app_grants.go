@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// AppGrantRequest is the request body for granting an app calls
+type AppGrantRequest struct {
+	App   string `json:"app"`   // The calling app, by name or ID
+	Scope string `json:"scope"` // "api" (default) or a path under /api/
+}
+
+// callerSiteID resolves the calling app of a grant to its site ID, writing
+// a 404 if it doesn't exist
+func callerSiteID(w http.ResponseWriter, identifier string) (string, bool) {
+	if identifier == "" {
+		api.MissingField(w, "app")
+		return "", false
+	}
+	appID, err := resolveExistingApp(database.GetDB(), identifier)
+	if err != nil {
+		api.NotFound(w, "APP_NOT_FOUND", "App not found: "+identifier)
+		return "", false
+	}
+	siteID, err := ResolveAppSiteID(appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return "", false
+	}
+	return siteID, true
+}
+
+// AppGrantsHandler lists the apps an app may call with fazt.call and the
+// apps that may call it
+// GET /api/apps/{id}/grants
+func AppGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+	grants, err := hosting.ListAppGrants(database.GetDB(), siteID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app":    siteID,
+		"grants": grants,
+	})
+}
+
+// AppGrantHandler lets another app call this one's handlers with fazt.call.
+// Only the app's owners may open it to other apps.
+// PUT /api/apps/{id}/grants {"app": "caller-app", "scope": "api"}
+func AppGrantHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := appSiteID(w, r, hosting.AppRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req AppGrantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = hosting.GrantScopeAPI
+	}
+	if !hosting.IsValidGrantScope(req.Scope) {
+		api.ValidationError(w, hosting.ErrInvalidGrantScope.Error(), "scope", "format")
+		return
+	}
+	caller, ok := callerSiteID(w, req.App)
+	if !ok {
+		return
+	}
+	if caller == target {
+		api.BadRequest(w, "an app doesn't need a grant to call itself")
+		return
+	}
+
+	createdBy := ""
+	if p := principalFromRequest(r); p != nil {
+		createdBy = p.UserID
+	}
+	if err := hosting.GrantAppCall(database.GetDB(), caller, target, req.Scope, createdBy); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"caller": caller,
+		"target": target,
+		"scope":  req.Scope,
+	})
+}
+
+// AppGrantDeleteHandler stops another app calling this one, for one scope
+// or, without ?scope=, for all
+// DELETE /api/apps/{id}/grants?app=caller-app&scope=api
+func AppGrantDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := appSiteID(w, r, hosting.AppRoleOwner)
+	if !ok {
+		return
+	}
+	caller, ok := callerSiteID(w, r.URL.Query().Get("app"))
+	if !ok {
+		return
+	}
+	removed, err := hosting.RevokeAppCall(database.GetDB(), caller, target, r.URL.Query().Get("scope"))
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"caller":  caller,
+		"target":  target,
+		"removed": removed,
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func callGrants(method, query, appID string, body interface{}, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := testutil.JSONRequest(method, "/api/apps/"+appID+"/grants"+query, body)
+	req.SetPathValue("id", appID)
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	return resp
+}
+
+func TestAppGrantHandlers(t *testing.T) {
+	setupAppsV2Test(t)
+	billing := createTestAppV2(t, "billing")
+	shop := createTestAppV2(t, "shop")
+	db := database.GetDB()
+
+	// Keyed by the site IDs the apps' handlers run under
+	resp := callGrants("PUT", "", billing, map[string]interface{}{"app": shop}, AppGrantHandler)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "caller", "shop")
+	testutil.AssertFieldEquals(t, data, "scope", "api")
+	if ok, _ := hosting.AppCallAllowed(db, "shop", "billing", "/api/charge"); !ok {
+		t.Error("Expected shop allowed to call billing")
+	}
+
+	resp = callGrants("PUT", "", billing, map[string]interface{}{"app": "shop", "scope": "/api/reports"}, AppGrantHandler)
+	testutil.CheckSuccess(t, resp, http.StatusOK)
+
+	resp = callGrants("GET", "", shop, nil, AppGrantsHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	if grants, _ := data["grants"].([]interface{}); len(grants) != 2 {
+		t.Errorf("Expected the caller to see its 2 grants, got %v", data["grants"])
+	}
+
+	resp = callGrants("PUT", "", billing, map[string]interface{}{"app": "shop", "scope": "/private"}, AppGrantHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+	resp = callGrants("PUT", "", billing, map[string]interface{}{"app": "nope"}, AppGrantHandler)
+	testutil.CheckError(t, resp, http.StatusNotFound, "APP_NOT_FOUND")
+	resp = callGrants("PUT", "", billing, map[string]interface{}{"app": "billing"}, AppGrantHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	resp = callGrants("DELETE", "?app=shop&scope=api", billing, nil, AppGrantDeleteHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "removed", float64(1))
+	if ok, _ := hosting.AppCallAllowed(db, "shop", "billing", "/api/charge"); ok {
+		t.Error("Expected the api grant revoked")
+	}
+	if ok, _ := hosting.AppCallAllowed(db, "shop", "billing", "/api/reports/daily"); !ok {
+		t.Error("Expected the /api/reports grant kept")
+	}
+
+	resp = callGrants("DELETE", "?app=shop", billing, nil, AppGrantDeleteHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "removed", float64(1))
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// AppJobsHandler lists an app's background jobs, newest first, with their
+// progress. Logs and checkpoints are left out; see AppJobHandler.
+// GET /api/apps/{id}/jobs?status=running&limit=50
+func AppJobsHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	var status *worker.JobStatus
+	switch s := worker.JobStatus(r.URL.Query().Get("status")); s {
+	case "":
+	case worker.StatusPending, worker.StatusRunning, worker.StatusDone, worker.StatusFailed, worker.StatusCancelled:
+		status = &s
+	default:
+		api.BadRequest(w, "status must be pending, running, done, failed or cancelled")
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	jobs, err := worker.List(appID, status, limit)
+	if errors.Is(err, worker.ErrPoolNotInitialized) {
+		api.ServiceUnavailable(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	infos := make([]worker.JobInfo, len(jobs))
+	for i, job := range jobs {
+		infos[i] = job.Info()
+		infos[i].Logs = nil
+		infos[i].Checkpoint = nil
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app":  appID,
+		"jobs": infos,
+	})
+}
+
+// AppJobHandler returns one of an app's jobs with its logs and checkpoint
+// GET /api/apps/{id}/jobs/{job}
+func AppJobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := appJobFromPath(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+	api.Success(w, http.StatusOK, job.Info())
+}
+
+// AppJobCancelHandler cancels a pending or running job. A cancelled daemon
+// isn't restarted.
+// POST /api/apps/{id}/jobs/{job}/cancel
+func AppJobCancelHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := appJobFromPath(w, r, hosting.AppRoleEditor)
+	if !ok {
+		return
+	}
+	if status := job.Info().Status; status != worker.StatusPending && status != worker.StatusRunning {
+		api.BadRequest(w, "job already "+string(status))
+		return
+	}
+	if err := worker.Cancel(job.ID); err != nil {
+		// Finished between the check and the cancel
+		api.BadRequest(w, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":        job.ID,
+		"cancelled": true,
+	})
+}
+
+// DeadJobsHandler lists jobs that failed their last attempt, most recent
+// first, with the error and logs they failed with
+// GET /api/system/jobs/dead?app=my-app&limit=50
+func DeadJobsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	jobs, err := worker.DeadLetters(r.URL.Query().Get("app"), limit)
+	if errors.Is(err, worker.ErrPoolNotInitialized) {
+		api.ServiceUnavailable(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"jobs": jobs,
+	})
+}
+
+// DeadJobRetryHandler replays a dead-lettered job as a new job
+// POST /api/system/jobs/dead/{id}/retry
+func DeadJobRetryHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	job, err := worker.RetryDeadLetter(id)
+	switch {
+	case errors.Is(err, worker.ErrPoolNotInitialized):
+		api.ServiceUnavailable(w, err.Error())
+		return
+	case errors.Is(err, worker.ErrJobNotFound):
+		api.NotFound(w, "JOB_NOT_FOUND", "Dead-lettered job not found")
+		return
+	case errors.Is(err, worker.ErrAlreadyRetried):
+		api.Conflict(w, err.Error())
+		return
+	case errors.Is(err, worker.ErrQueueFull), errors.Is(err, worker.ErrDaemonLimitReached):
+		api.ServiceUnavailable(w, err.Error())
+		return
+	case err != nil:
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "job", id, "retry", activity.WeightConfig,
+		map[string]interface{}{"app_id": job.AppID, "handler": job.Handler, "retry_job_id": job.ID})
+
+	api.Success(w, http.StatusOK, job.Info())
+}
+
+// appJobFromPath resolves {id} and {job}, checking the caller's role on the
+// app and that the job belongs to it
+func appJobFromPath(w http.ResponseWriter, r *http.Request, need string) (*worker.Job, bool) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, need) {
+		return nil, false
+	}
+
+	job, err := worker.Get(r.PathValue("job"))
+	if errors.Is(err, worker.ErrPoolNotInitialized) {
+		api.ServiceUnavailable(w, err.Error())
+		return nil, false
+	}
+	if err != nil || job.AppID != appID {
+		api.NotFound(w, "JOB_NOT_FOUND", "Job not found")
+		return nil, false
+	}
+	return job, true
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+// mailResponse is an app's mail settings, its usage of the day and its
+// latest messages
+func mailResponse(siteID string, messages []*mailer.Mail) (map[string]interface{}, error) {
+	db := database.GetDB()
+	settings, err := mailer.GetSettings(db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil {
+		settings.Password = ""
+	}
+	limit, err := mailer.DailyLimit(db, siteID, mailer.ServerConfig())
+	if err != nil {
+		return nil, err
+	}
+	sent, err := mailer.SentToday(db, siteID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"app":         siteID,
+		"settings":    settings,
+		"daily_limit": limit,
+		"sent_today":  sent,
+		"messages":    messages,
+	}, nil
+}
+
+// AppMailHandler lists the email an app sent with fazt.app.mail, newest
+// first, with its mail settings and how much of its daily limit it used.
+// GET /api/apps/{id}/mail?status=failed&limit=50
+func AppMailHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", mailer.StatusQueued, mailer.StatusSent, mailer.StatusFailed:
+	default:
+		api.BadRequest(w, "status must be queued, sent or failed")
+		return
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	messages, err := mailer.List(database.GetDB(), siteID, status, limit)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	resp, err := mailResponse(siteID, messages)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+// AppMailMessageHandler returns one of an app's messages with its body
+// GET /api/apps/{id}/mail/{mail}
+func AppMailMessageHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+	m, err := mailer.Get(database.GetDB(), r.PathValue("mail"))
+	if errors.Is(err, mailer.ErrNotFound) || (err == nil && m.AppID != siteID) {
+		api.NotFound(w, "MAIL_NOT_FOUND", "Message not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, m)
+}
+
+// AppMailSettingsHandler gives an app its own mail server, daily limit, or
+// both. Fields left out keep their values; without a host the app sends
+// through the server's mail server.
+// PUT /api/apps/{id}/mail/settings {"host": "smtp.example.com", "port": 587, "username": "...", "password": "...", "from": "...", "daily_limit": 500}
+func AppMailSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleOwner)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Host       *string `json:"host"`
+		Port       *int    `json:"port"`
+		Username   *string `json:"username"`
+		Password   *string `json:"password"`
+		From       *string `json:"from"`
+		DailyLimit *int    `json:"daily_limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	db := database.GetDB()
+	current, err := mailer.GetSettings(db, siteID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	var settings mailer.Settings
+	if current != nil {
+		settings = *current
+	}
+	if req.Host != nil {
+		settings.Host = *req.Host
+	}
+	if req.Username != nil {
+		settings.Username = *req.Username
+	}
+	if req.Password != nil {
+		settings.Password = *req.Password
+	}
+	if req.From != nil {
+		settings.From = *req.From
+	}
+	if req.Port != nil {
+		settings.Port = *req.Port
+	}
+	if req.DailyLimit != nil {
+		settings.DailyLimit = req.DailyLimit
+	}
+	if settings.From != "" {
+		if _, err := mail.ParseAddress(settings.From); err != nil {
+			api.BadRequest(w, "invalid from address")
+			return
+		}
+	}
+	if err := mailer.SetSettings(db, siteID, settings); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	resp, err := mailResponse(siteID, nil)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	delete(resp, "messages")
+	api.Success(w, http.StatusOK, resp)
+}
+
+// AppMailSettingsDeleteHandler drops an app's mail settings: it sends
+// through the server's mail server, with the server's daily limit, again.
+// DELETE /api/apps/{id}/mail/settings
+func AppMailSettingsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	siteID, ok := appSiteID(w, r, hosting.AppRoleOwner)
+	if !ok {
+		return
+	}
+	if _, err := mailer.DeleteSettings(database.GetDB(), siteID); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	resp, err := mailResponse(siteID, nil)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	delete(resp, "messages")
+	api.Success(w, http.StatusOK, resp)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+func callMail(method, path, appID string, body interface{}, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	req := testutil.JSONRequest(method, "/api/apps/"+appID+"/mail"+path, body)
+	req.SetPathValue("id", appID)
+	resp := httptest.NewRecorder()
+	handler(resp, req)
+	return resp
+}
+
+func TestAppMailHandlers(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "news")
+	config.SetConfig(&config.Config{
+		Server: config.ServerConfig{Domain: "test.local", Env: "test"},
+		Mail:   config.MailConfig{SMTP: config.SMTPConfig{Host: "smtp.test", Port: 587, From: "apps@test.local"}, DailyLimit: 20},
+	})
+
+	// Keyed by the site ID the app's handlers run under
+	m, err := mailer.Queue(database.GetDB(), "news", mailer.Message{To: []string{"ada@example.com"}, Subject: "Hi", HTML: "<p>Hi</p>"}, config.Get().Mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := callMail("GET", "", appID, nil, AppMailHandler)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "daily_limit", float64(20))
+	testutil.AssertFieldEquals(t, data, "sent_today", float64(1))
+	if messages, _ := data["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("Expected the queued message, got %v", data["messages"])
+	}
+
+	resp = callMail("GET", "?status=bounced", appID, nil, AppMailHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	req := testutil.JSONRequest("GET", "/api/apps/"+appID+"/mail/"+m.ID, nil)
+	req.SetPathValue("id", appID)
+	req.SetPathValue("mail", m.ID)
+	resp = httptest.NewRecorder()
+	AppMailMessageHandler(resp, req)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "html", "<p>Hi</p>")
+
+	req.SetPathValue("mail", "mail_missing")
+	resp = httptest.NewRecorder()
+	AppMailMessageHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusNotFound, "MAIL_NOT_FOUND")
+
+	// Settings merge: the limit alone keeps the server, later fields keep the limit
+	resp = callMail("PUT", "/settings", appID, map[string]interface{}{"daily_limit": 500}, AppMailSettingsHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "daily_limit", float64(500))
+
+	resp = callMail("PUT", "/settings", appID, map[string]interface{}{
+		"host": "smtp.news.example", "username": "news", "password": "secret", "from": "News <news@example.com>",
+	}, AppMailSettingsHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "daily_limit", float64(500))
+	settings, _ := data["settings"].(map[string]interface{})
+	if settings["host"] != "smtp.news.example" || settings["port"] != float64(587) || settings["has_password"] != true || settings["password"] != nil {
+		t.Errorf("Unexpected settings: %v", settings)
+	}
+
+	resp = callMail("PUT", "/settings", appID, map[string]interface{}{"from": "nope"}, AppMailSettingsHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	resp = callMail("DELETE", "/settings", appID, nil, AppMailSettingsDeleteHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "daily_limit", float64(20))
+	if data["settings"] != nil {
+		t.Errorf("Expected no settings, got %v", data["settings"])
+	}
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// MergeRequest is the body of a merge
+type MergeRequest struct {
+	Into   string `json:"into"`    // App ID or alias, default: the app the fork was made from
+	Force  bool   `json:"force"`   // Take the fork's side of conflicts
+	DryRun bool   `json:"dry_run"` // Report what would be merged without deploying
+}
+
+// AppMerge is the outcome of merging a fork back into its original
+type AppMerge struct {
+	App       AppDiffSide `json:"app"`
+	Into      AppDiffSide `json:"into"`
+	Changes   []FileMerge `json:"changes"`   // fork changes applied to into
+	Conflicts []FileMerge `json:"conflicts"` // changed on both sides since the base
+	Kept      int         `json:"kept"`      // into's own changes since the base, left alone
+	// BaseKnown is false for forks without a recorded base; the merge then
+	// only adds and updates files (see forkBase)
+	BaseKnown bool  `json:"base_known"`
+	Merged    bool  `json:"merged"`
+	FileCount int   `json:"file_count"` // into's files after the merge
+	SizeBytes int64 `json:"size_bytes"`
+	// Secrets are likely credentials the merge deployed (secret scan mode "warn")
+	Secrets []hosting.SecretFinding `json:"secrets,omitempty"`
+}
+
+// FileMerge is a file the merge changes in into
+type FileMerge struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`           // added, removed, modified
+	Into   string `json:"into,omitempty"`   // for conflicts, what into did since the base
+	Forced bool   `json:"forced,omitempty"` // conflict resolved with the fork's side
+}
+
+// AppMergeHandler applies a fork's file changes to the app it was forked
+// from as a new deploy. Files changed by both since the fork was made (or
+// last merged) are conflicts, which fail the merge unless forced.
+// POST /api/apps/{id}/merge
+func AppMergeHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	forkID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, forkID, hosting.AppRoleViewer) {
+		return
+	}
+
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		// Allow empty body
+		req = MergeRequest{}
+	}
+
+	fork, err := getAppByID(db, forkID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if fork.ForkedFromID == "" {
+		api.BadRequest(w, "app is not a fork")
+		return
+	}
+
+	intoID := fork.ForkedFromID
+	if req.Into != "" {
+		if intoID, err = resolveExistingApp(db, req.Into); err != nil {
+			api.NotFound(w, "APP_NOT_FOUND", "App to merge into not found")
+			return
+		}
+	}
+	if intoID != fork.ForkedFromID {
+		api.BadRequest(w, fmt.Sprintf("a fork can only be merged into the app it was forked from (%s)", fork.ForkedFromID))
+		return
+	}
+	if !requireAppRole(w, r, intoID, hosting.AppRoleEditor) {
+		return
+	}
+	into, err := getAppByID(db, intoID)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "APP_NOT_FOUND", "App to merge into not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if into.ForkedFromID != "" {
+		api.BadRequest(w, "cannot merge into another fork; merge it into its own original first")
+		return
+	}
+
+	forkFiles, err := appFileHashes(db, fork.ID, "")
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	intoFiles, err := appFileHashes(db, into.ID, into.Title)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	base, baseKnown, err := forkBase(db, fork, forkFiles, intoFiles)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	merge := &AppMerge{
+		App:       diffSide(fork, forkFiles),
+		Into:      diffSide(into, intoFiles),
+		BaseKnown: baseKnown,
+	}
+	merge.Changes, merge.Conflicts, merge.Kept = planMerge(base, forkFiles, intoFiles)
+	if req.Force {
+		for i := range merge.Conflicts {
+			merge.Conflicts[i].Forced = true
+		}
+	}
+
+	// The merged file set: into's files with the fork's side of each change
+	fromFork := make(map[string]bool)
+	merged := make(map[string]fileHash, len(intoFiles))
+	for path, f := range intoFiles {
+		merged[path] = f
+	}
+	for _, c := range merge.Changes {
+		fromFork[c.Path] = true
+	}
+	if req.Force {
+		for _, c := range merge.Conflicts {
+			fromFork[c.Path] = true
+		}
+	}
+	for path := range fromFork {
+		if f, ok := forkFiles[path]; ok {
+			merged[path] = f
+		} else {
+			delete(merged, path)
+		}
+	}
+	merge.FileCount = len(merged)
+	for _, f := range merged {
+		merge.SizeBytes += f.size
+	}
+
+	if req.DryRun {
+		api.Success(w, http.StatusOK, merge)
+		return
+	}
+	if len(merge.Conflicts) > 0 && !req.Force {
+		paths := make([]string, len(merge.Conflicts))
+		for i, c := range merge.Conflicts {
+			paths[i] = c.Path
+		}
+		api.Error(w, http.StatusConflict, "MERGE_CONFLICT",
+			fmt.Sprintf("%d files changed in both apps; review them or merge with force", len(paths)),
+			map[string]interface{}{"conflicts": paths})
+		return
+	}
+	if len(fromFork) == 0 {
+		// Nothing to apply: don't deploy a new version
+		api.Success(w, http.StatusOK, merge)
+		return
+	}
+
+	if !requireUnfrozen(w, r, db, "deploy", into.ID) {
+		return
+	}
+
+	files, err := mergedFiles(db, fork, into, merged, fromFork)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	limits, err := hosting.ResolveDeployLimits(db, into.Title, adminActor(r))
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if err := limits.CheckFiles(files); err != nil {
+		writeDeployError(w, err)
+		return
+	}
+	result, err := hosting.DeployFiles(into.Title, files, nil)
+	if err != nil {
+		writeDeployError(w, err)
+		return
+	}
+	logDeploySecrets(result)
+	deployedBy := "merge " + fork.ID
+	if err := hosting.RecordDeployment(db, result.SiteID, result.SizeBytes, result.FileCount, deployedBy); err != nil {
+		log.Printf("Failed to record deployment: %v", err)
+	}
+
+	// into now has every change of the fork, so the fork's files are the
+	// base of the next merge
+	tx, err := db.Begin()
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer tx.Rollback()
+	if err := saveForkBase(tx, fork.ID, forkFiles); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	log.Printf("Fork merged: %s into %s (%s), %d changes, %d forced",
+		fork.ID, into.ID, into.Title, len(merge.Changes), len(merge.Conflicts))
+	merge.Merged = true
+	merge.FileCount = result.FileCount
+	merge.SizeBytes = result.SizeBytes
+	merge.Secrets = result.Secrets
+	api.Success(w, http.StatusOK, merge)
+}
+
+// planMerge compares the fork and into with their base. Changes only the
+// fork made are applied, changes only into made are kept, and paths both
+// changed differently are conflicts.
+func planMerge(base, fork, into map[string]fileHash) (changes, conflicts []FileMerge, kept int) {
+	changes, conflicts = []FileMerge{}, []FileMerge{}
+	paths := make(map[string]bool)
+	for _, files := range []map[string]fileHash{base, fork, into} {
+		for path := range files {
+			paths[path] = true
+		}
+	}
+
+	for path := range paths {
+		f, inFork := fork[path]
+		o, inInto := into[path]
+		b, inBase := base[path]
+		if inFork == inInto && f.hash == o.hash {
+			continue
+		}
+		forkChanged := inFork != inBase || f.hash != b.hash
+		intoChanged := inInto != inBase || o.hash != b.hash
+		switch {
+		case !forkChanged:
+			kept++
+		case !intoChanged:
+			changes = append(changes, FileMerge{Path: path, Status: changeStatus(inFork, inInto)})
+		default:
+			conflicts = append(conflicts, FileMerge{
+				Path:   path,
+				Status: changeStatus(inFork, inInto),
+				Into:   changeStatus(inInto, inBase),
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Path < conflicts[j].Path })
+	return changes, conflicts, kept
+}
+
+// changeStatus describes a file going from old to new
+func changeStatus(inNew, inOld bool) string {
+	switch {
+	case !inOld:
+		return "added"
+	case !inNew:
+		return "removed"
+	}
+	return "modified"
+}
+
+// forkBase returns the files a fork and its original last had in common.
+// Forks without a recorded base, made before bases were kept or from an app
+// whose files weren't copied, get a partial one: into's version of the
+// fork's files if into hasn't changed since the fork was made. Files missing
+// from the fork are then never removed from into.
+func forkBase(db *sql.DB, fork *AppV2, forkFiles, intoFiles map[string]fileHash) (map[string]fileHash, bool, error) {
+	rows, err := db.Query(`SELECT path, hash FROM fork_bases WHERE app_id = ?`, fork.ID)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	base := make(map[string]fileHash)
+	for rows.Next() {
+		var path string
+		var f fileHash
+		if err := rows.Scan(&path, &f.hash); err != nil {
+			return nil, false, err
+		}
+		base[path] = f
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+	if len(base) > 0 {
+		return base, true, nil
+	}
+
+	var intoChanged bool
+	err = db.QueryRow(`SELECT COALESCE((SELECT updated_at FROM apps WHERE id = ?) > (SELECT created_at FROM apps WHERE id = ?), 0)`,
+		fork.ForkedFromID, fork.ID).Scan(&intoChanged)
+	if err != nil {
+		return nil, false, err
+	}
+	if !intoChanged {
+		for path := range forkFiles {
+			if f, ok := intoFiles[path]; ok {
+				base[path] = f
+			}
+		}
+	}
+	return base, false, nil
+}
+
+// saveForkBase replaces the merge base of a fork
+func saveForkBase(tx *sql.Tx, forkID string, files map[string]fileHash) error {
+	if _, err := tx.Exec(`DELETE FROM fork_bases WHERE app_id = ?`, forkID); err != nil {
+		return err
+	}
+	for path, f := range files {
+		if _, err := tx.Exec(`INSERT INTO fork_bases (app_id, path, hash) VALUES (?, ?, ?)`, forkID, path, f.hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergedFiles loads the content of the merged file set, taking paths in
+// fromFork from the fork and the rest from into
+func mergedFiles(db *sql.DB, fork, into *AppV2, merged map[string]fileHash, fromFork map[string]bool) ([]hosting.DeployFile, error) {
+	forkContent, err := appFileContents(db, fork.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	intoContent, err := appFileContents(db, into.ID, into.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]hosting.DeployFile, 0, len(merged))
+	for path := range merged {
+		content := intoContent[path]
+		if fromFork[path] {
+			content = forkContent[path]
+		}
+		files = append(files, hosting.DeployFile{Path: path, Content: content})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// appFileContents returns an app's file contents by path, keyed like
+// appFileHashes
+func appFileContents(db *sql.DB, appID, title string) (map[string][]byte, error) {
+	rows, err := db.Query(`
+		SELECT path, content FROM files
+		WHERE app_id = ? OR (site_id = ? AND app_id IS NULL)
+	`, appID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := make(map[string][]byte)
+	for rows.Next() {
+		var path string
+		var content []byte
+		if err := rows.Scan(&path, &content); err != nil {
+			return nil, err
+		}
+		files[path] = content
+	}
+	return files, rows.Err()
+}
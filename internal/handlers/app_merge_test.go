@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func createTestFork(t *testing.T, parentID, title string) string {
+	t.Helper()
+	forkID := "app_" + testutil.RandStr(8)
+	_, err := database.GetDB().Exec(`
+		INSERT INTO apps (id, original_id, forked_from_id, title, source, visibility, created_at, updated_at)
+		VALUES (?, ?, ?, ?, 'fork', 'unlisted', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+	`, forkID, parentID, parentID, title)
+	if err != nil {
+		t.Fatalf("Failed to create fork: %v", err)
+	}
+	return forkID
+}
+
+func mergeRequest(t *testing.T, forkID string, body MergeRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/api/apps/"+forkID+"/merge", bytes.NewReader(b))
+	req.SetPathValue("id", forkID)
+	resp := httptest.NewRecorder()
+	AppMergeHandler(resp, req)
+	return resp
+}
+
+func siteFileContent(t *testing.T, site, path string) string {
+	t.Helper()
+	var content string
+	err := database.GetDB().QueryRow(`SELECT content FROM files WHERE site_id = ? AND path = ?`, site, path).Scan(&content)
+	if err != nil {
+		return ""
+	}
+	return content
+}
+
+func TestAppMergeHandler(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+
+	parentID := createTestAppV2(t, "blog")
+	insertTestFile(t, "blog", "", "index.html", "v1")
+	insertTestFile(t, "blog", "", "api/main.js", "m1")
+	insertTestFile(t, "blog", "", "style.css", "s1")
+
+	// The fork starts from the original's files
+	forkID := createTestFork(t, parentID, "blog")
+	base, err := appFileHashes(db, parentID, "blog")
+	if err != nil {
+		t.Fatalf("Failed to hash files: %v", err)
+	}
+	tx, _ := db.Begin()
+	if err := saveForkBase(tx, forkID, base); err != nil {
+		t.Fatalf("Failed to save base: %v", err)
+	}
+	tx.Commit()
+
+	insertTestFile(t, forkID, forkID, "index.html", "v2")
+	insertTestFile(t, forkID, forkID, "api/main.js", "m1")
+	insertTestFile(t, forkID, forkID, "new.js", "n1")
+	// Deployed to the original since the fork was made
+	db.Exec(`UPDATE files SET content = 'm2', hash = '' WHERE site_id = 'blog' AND path = 'api/main.js'`)
+
+	resp := mergeRequest(t, forkID, MergeRequest{})
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "merged", true)
+	testutil.AssertFieldEquals(t, data, "kept", float64(1))
+
+	changes, _ := data["changes"].([]interface{})
+	want := []string{"index.html:modified", "new.js:added", "style.css:removed"}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected %d changes, got %v", len(want), changes)
+	}
+	for i, c := range changes {
+		change := c.(map[string]interface{})
+		if got := change["path"].(string) + ":" + change["status"].(string); got != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], got)
+		}
+	}
+	for path, want := range map[string]string{"index.html": "v2", "api/main.js": "m2", "new.js": "n1", "style.css": ""} {
+		if got := siteFileContent(t, "blog", path); got != want {
+			t.Errorf("Expected %s to be %q after the merge, got %q", path, want, got)
+		}
+	}
+
+	// Both change index.html after the merge
+	db.Exec(`UPDATE files SET content = 'v4', hash = '' WHERE site_id = ? AND path = 'index.html'`, forkID)
+	db.Exec(`UPDATE files SET content = 'v3', hash = '' WHERE site_id = 'blog' AND path = 'index.html'`)
+
+	resp = mergeRequest(t, forkID, MergeRequest{})
+	testutil.CheckError(t, resp, http.StatusConflict, "MERGE_CONFLICT")
+
+	resp = mergeRequest(t, forkID, MergeRequest{DryRun: true})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "merged", false)
+	conflicts, _ := data["conflicts"].([]interface{})
+	if len(conflicts) != 1 || conflicts[0].(map[string]interface{})["path"] != "index.html" {
+		t.Fatalf("Expected index.html to conflict, got %v", conflicts)
+	}
+	if got := siteFileContent(t, "blog", "index.html"); got != "v3" {
+		t.Errorf("Expected a dry run to leave the original alone, got %q", got)
+	}
+
+	resp = mergeRequest(t, forkID, MergeRequest{Force: true})
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "merged", true)
+	if got := siteFileContent(t, "blog", "index.html"); got != "v4" {
+		t.Errorf("Expected the fork's side after a forced merge, got %q", got)
+	}
+}
+
+func TestAppMergeHandler_NotAFork(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "standalone")
+
+	resp := mergeRequest(t, id, MergeRequest{})
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+
+	// Only back into the app it was forked from
+	forkID := createTestFork(t, id, "standalone")
+	other := createTestAppV2(t, "other")
+	resp = mergeRequest(t, forkID, MergeRequest{Into: other})
+	testutil.CheckError(t, resp, http.StatusBadRequest, "BAD_REQUEST")
+}
+
+func TestPlanMerge_UnknownBase(t *testing.T) {
+	fork := map[string]fileHash{"a": {hash: "1"}, "b": {hash: "2"}}
+	into := map[string]fileHash{"a": {hash: "0"}, "c": {hash: "3"}}
+
+	// Without a base, files only the fork has are added and files only into
+	// has are kept, never removed
+	changes, conflicts, kept := planMerge(map[string]fileHash{}, fork, into)
+	if len(changes) != 1 || changes[0].Path != "b" || changes[0].Status != "added" {
+		t.Errorf("Expected b to be added, got %+v", changes)
+	}
+	if len(conflicts) != 1 || conflicts[0].Path != "a" {
+		t.Errorf("Expected a to conflict, got %+v", conflicts)
+	}
+	if kept != 1 {
+		t.Errorf("Expected c to be kept, got %d", kept)
+	}
+}
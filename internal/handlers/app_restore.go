@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// RestoreRequest is the body of a restore request. The newest backup taken
+// at or before At is used, unless Backup names one.
+type RestoreRequest struct {
+	At     string `json:"at"`     // 2024-05-01T12:00 (server time) or RFC 3339
+	Backup string `json:"backup"` // A backup's name, e.g. backup_20240501_120000.db
+}
+
+// AppBackupsHandler lists the backups an app can be restored from, oldest
+// first
+// GET /api/apps/{id}/backups
+func AppBackupsHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	backups, err := hosting.ListBackups(config.Get().Database.Path)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	list := make([]map[string]interface{}, len(backups))
+	for i, b := range backups {
+		list[i] = backupJSON(b)
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":      appID,
+		"backups": list,
+	})
+}
+
+// AppRestoreHandler puts an app's files and storage back as a backup had
+// them, leaving every other app as it is
+// POST /api/apps/{id}/restore {"at": "2024-05-01T12:00"}
+func AppRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.At == "" && req.Backup == "" {
+		api.MissingField(w, "at")
+		return
+	}
+
+	backups, err := hosting.ListBackups(config.Get().Database.Path)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	var backup hosting.Backup
+	if req.Backup != "" {
+		found := false
+		for _, b := range backups {
+			if b.Name == req.Backup {
+				backup, found = b, true
+				break
+			}
+		}
+		if !found {
+			api.NotFound(w, "BACKUP_NOT_FOUND", "No backup named "+req.Backup)
+			return
+		}
+	} else {
+		at, err := hosting.ParseBackupTime(req.At)
+		if err != nil {
+			api.ValidationError(w, err.Error(), "at", "format")
+			return
+		}
+		backup, err = hosting.NearestBackup(backups, at)
+		if errors.Is(err, hosting.ErrNoBackup) {
+			api.NotFound(w, "BACKUP_NOT_FOUND", "No backup was taken at or before "+at.Format(time.RFC3339))
+			return
+		}
+	}
+
+	result, err := hosting.RestoreApp(db, backup, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":     appID,
+		"backup": backupJSON(result.Backup),
+		"sites":  result.Sites,
+		"files":  result.Files,
+		"kv":     result.KV,
+		"docs":   result.Docs,
+		"blobs":  result.Blobs,
+	})
+}
+
+// backupJSON is a backup as the API shows it
+func backupJSON(b hosting.Backup) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       b.Name,
+		"taken_at":   b.TakenAt.Format(time.RFC3339),
+		"size_bytes": b.SizeBytes,
+	}
+}
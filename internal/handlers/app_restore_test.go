@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func TestAppRestoreHandler(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "blog")
+	db := database.GetDB()
+
+	// Backups are found next to the configured database
+	dir := t.TempDir()
+	cfg := *config.Get()
+	cfg.Database.Path = filepath.Join(dir, "data.db")
+	config.SetConfig(&cfg)
+	os.MkdirAll(filepath.Join(dir, "backups"), 0755)
+
+	db.Exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('blog', 'theme', '"light"')`)
+	if _, err := db.Exec(`VACUUM INTO ?`, filepath.Join(dir, "backups", "backup_20240501_120000.db")); err != nil {
+		t.Fatal(err)
+	}
+	db.Exec(`UPDATE app_kv SET value = '"broken"'`)
+
+	call := func(method string, body interface{}, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := testutil.JSONRequest(method, "/api/apps/"+appID+"/restore", body)
+		req.SetPathValue("id", appID)
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+
+	resp := call("GET", nil, AppBackupsHandler)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if backups, _ := data["backups"].([]interface{}); len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %v", data["backups"])
+	}
+
+	resp = call("POST", map[string]interface{}{"at": "yesterday"}, AppRestoreHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+	resp = call("POST", map[string]interface{}{"at": "2024-04-30T23:00"}, AppRestoreHandler)
+	testutil.CheckError(t, resp, http.StatusNotFound, "BACKUP_NOT_FOUND")
+	resp = call("POST", map[string]interface{}{}, AppRestoreHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "MISSING_FIELD")
+
+	resp = call("POST", map[string]interface{}{"at": "2024-05-01T12:00"}, AppRestoreHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "kv", float64(1))
+	var theme string
+	db.QueryRow(`SELECT value FROM app_kv WHERE app_id = 'blog' AND key = 'theme'`).Scan(&theme)
+	if theme != `"light"` {
+		t.Errorf("Expected the backup's value restored, got %s", theme)
+	}
+}
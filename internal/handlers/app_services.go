@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// serviceLogKeepAlive is how often an idle log stream sends a comment, so
+// proxies don't close it
+const serviceLogKeepAlive = 30 * time.Second
+
+// AppServicesHandler lists the services an app's manifest.json declares,
+// with the state of the job running each
+// GET /api/apps/{id}/services
+func AppServicesHandler(w http.ResponseWriter, r *http.Request) {
+	appID, site, ok := appServiceSite(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+
+	services, err := worker.Services(site)
+	if errors.Is(err, worker.ErrPoolNotInitialized) {
+		api.ServiceUnavailable(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app":      appID,
+		"services": services,
+	})
+}
+
+// AppServiceRestartHandler stops a service's job and starts a new one from
+// the app's current code and manifest.json
+// POST /api/apps/{id}/services/{name}/restart
+func AppServiceRestartHandler(w http.ResponseWriter, r *http.Request) {
+	appID, site, ok := appServiceSite(w, r, hosting.AppRoleEditor)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	job, err := worker.RestartService(site, name)
+	switch {
+	case errors.Is(err, worker.ErrPoolNotInitialized):
+		api.ServiceUnavailable(w, err.Error())
+		return
+	case errors.Is(err, worker.ErrServiceNotFound):
+		api.NotFound(w, "SERVICE_NOT_FOUND", "Service not found")
+		return
+	case errors.Is(err, worker.ErrInvalidService):
+		api.BadRequest(w, err.Error())
+		return
+	case errors.Is(err, worker.ErrQueueFull), errors.Is(err, worker.ErrDaemonLimitReached):
+		api.ServiceUnavailable(w, err.Error())
+		return
+	case err != nil:
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "service", appID+"/"+name, "restart", activity.WeightConfig,
+		map[string]interface{}{"app_id": appID, "handler": job.Handler, "job_id": job.ID})
+
+	api.Success(w, http.StatusOK, job.Info())
+}
+
+// AppServiceLogsHandler returns the recent logs of a service's job. With
+// follow, it streams them as server-sent events instead: the recent lines,
+// then new ones as they're logged, across restarts.
+// GET /api/apps/{id}/services/{name}/logs?follow=true
+func AppServiceLogsHandler(w http.ResponseWriter, r *http.Request) {
+	_, site, ok := appServiceSite(w, r, hosting.AppRoleViewer)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	if _, declared := hosting.AppServices(site)[name]; !declared {
+		api.NotFound(w, "SERVICE_NOT_FOUND", "Service not found")
+		return
+	}
+
+	// Subscribe before reading the recent lines so none are missed between
+	var lines <-chan worker.ServiceLog
+	follow := r.URL.Query().Get("follow") == "true"
+	if follow {
+		ch, unsubscribe := worker.SubscribeServiceLogs(site, name)
+		defer unsubscribe()
+		lines = ch
+	}
+
+	job, err := worker.ServiceJob(site, name)
+	if errors.Is(err, worker.ErrPoolNotInitialized) {
+		api.ServiceUnavailable(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	recent := []worker.ServiceLog{}
+	if job != nil {
+		for _, line := range job.Info().Logs {
+			recent = append(recent, worker.ServiceLog{JobID: job.ID, Line: line})
+		}
+	}
+
+	if !follow {
+		api.Success(w, http.StatusOK, map[string]interface{}{
+			"service": name,
+			"logs":    recent,
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		api.InternalError(w, errors.New("streaming unsupported"))
+		return
+	}
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	send := func(line worker.ServiceLog) {
+		data, _ := json.Marshal(line)
+		fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+	}
+	for _, line := range recent {
+		send(line)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(serviceLogKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line := <-lines:
+			send(line)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// appServiceSite resolves {id}, checking the caller's role on the app, and
+// returns the app's ID and the site its files and jobs are kept under
+func appServiceSite(w http.ResponseWriter, r *http.Request, need string) (string, string, bool) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, need) {
+		return "", "", false
+	}
+	site, err := ResolveAppSiteID(appID)
+	if err != nil || site == "" {
+		site = appID
+	}
+	return appID, site, true
+}
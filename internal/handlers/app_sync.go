@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// maxSyncBytes caps a sync's request body, like a deploy's
+const maxSyncBytes = 100 << 20
+
+// AppSyncExportRequest is the body of POST /api/apps/{id}/sync/export
+type AppSyncExportRequest struct {
+	Items []hosting.SyncItem `json:"items"`
+}
+
+// AppSyncRequest is the body of POST /api/apps/{id}/sync: the items the
+// source has that this copy lacks or has different, with their content,
+// and this copy's items the source doesn't have
+type AppSyncRequest struct {
+	Put    []hosting.SyncData `json:"put"`
+	Remove []hosting.SyncItem `json:"remove"`
+}
+
+// AppSyncManifestHandler lists an app's files, and optionally its storage,
+// with a hash of each, for a sync to compare with another server's copy
+// GET /api/apps/{id}/sync?data=kv,ds,s3
+func AppSyncManifestHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+	kinds, err := parseSyncKinds(r.URL.Query().Get("data"))
+	if err != nil {
+		api.ValidationError(w, err.Error(), "data", "oneof")
+		return
+	}
+
+	app, err := getAppByID(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	sites, err := hosting.SyncSites(db, appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	items, err := hosting.SyncManifest(db, appID, kinds)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":    appID,
+		"title": app.Title,
+		"sites": sites,
+		"items": items,
+	})
+}
+
+// AppSyncExportHandler returns the content of items listed by the manifest
+// POST /api/apps/{id}/sync/export {"items": [...]}
+func AppSyncExportHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+	var req AppSyncExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	data, err := hosting.SyncContent(db, appID, req.Items)
+	if errors.Is(err, hosting.ErrSyncSite) {
+		api.ValidationError(w, err.Error(), "site", "oneof")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"items": data,
+	})
+}
+
+// AppSyncHandler applies a sync from another server to this copy of an
+// app. Changed files are deployed as a new version; storage is written in
+// place. An app that doesn't exist yet is created by its first sync, like
+// a deploy, which takes an admin.
+// POST /api/apps/{id}/sync
+func AppSyncHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	name := r.PathValue("id")
+	title := name
+	appID, err := resolveExistingApp(db, name)
+	if err == sql.ErrNoRows {
+		if _, ok := requireAdminAuth(w, r); !ok {
+			return
+		}
+		if err := hosting.ValidateSubdomain(name); err != nil {
+			api.ValidationError(w, err.Error(), "id", "format")
+			return
+		}
+		appID = ""
+	} else if err != nil {
+		api.InternalError(w, err)
+		return
+	} else {
+		if !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+			return
+		}
+		app, err := getAppByID(db, appID)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		if app.ForkedFromID != "" {
+			api.BadRequest(w, "cannot sync into a fork")
+			return
+		}
+		title = app.Title
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxSyncBytes)
+	var req AppSyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	var filesChanged int
+	for _, d := range req.Put {
+		if d.Kind == hosting.SyncFile {
+			filesChanged++
+		}
+	}
+	for _, i := range req.Remove {
+		if i.Kind == hosting.SyncFile {
+			filesChanged++
+		}
+	}
+
+	var fileCount int
+	if filesChanged > 0 {
+		if appID != "" && !requireUnfrozen(w, r, db, "deploy", appID) {
+			return
+		}
+		files, err := hosting.SyncFiles(db, appID, req.Put, req.Remove)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		limits, err := hosting.ResolveDeployLimits(db, title, adminActor(r))
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		if err := limits.CheckFiles(files); err != nil {
+			writeDeployError(w, err)
+			return
+		}
+		result, err := hosting.DeployFiles(title, files, nil)
+		if err != nil {
+			writeDeployError(w, err)
+			return
+		}
+		logDeploySecrets(result)
+		if err := hosting.RecordDeployment(db, result.SiteID, result.SizeBytes, result.FileCount, "sync"); err != nil {
+			log.Printf("Failed to record deployment: %v", err)
+		}
+		fileCount = result.FileCount
+		if appID == "" {
+			if appID, err = resolveExistingApp(db, title); err != nil {
+				api.InternalError(w, err)
+				return
+			}
+		}
+	} else if appID == "" {
+		api.NotFound(w, "APP_NOT_FOUND", fmt.Sprintf("App %s not found; its files must be synced first", name))
+		return
+	}
+
+	written, removed, err := hosting.ApplySyncStorage(db, appID, req.Put, req.Remove)
+	if errors.Is(err, hosting.ErrSyncSite) {
+		api.ValidationError(w, err.Error(), "site", "oneof")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "app", appID, "sync", activity.WeightDeployment, map[string]interface{}{
+		"files_changed":   filesChanged,
+		"storage_written": written,
+		"storage_removed": removed,
+	})
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":              appID,
+		"title":           title,
+		"files_changed":   filesChanged,
+		"file_count":      fileCount,
+		"storage_written": written,
+		"storage_removed": removed,
+	})
+}
+
+// parseSyncKinds reads the storage kinds a sync includes: a comma-separated
+// list of kv, ds and s3, or all
+func parseSyncKinds(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s == "all" {
+		return hosting.SyncStorageKinds, nil
+	}
+	var kinds []string
+	for _, kind := range strings.Split(s, ",") {
+		kind = strings.TrimSpace(kind)
+		valid := false
+		for _, k := range hosting.SyncStorageKinds {
+			valid = valid || k == kind
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown data %q: use kv, ds, s3 or all", kind)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
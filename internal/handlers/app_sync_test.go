@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestAppSyncHandlers(t *testing.T) {
+	setupAppsV2Test(t)
+	createTestAppV2(t, "blog")
+	db := database.GetDB()
+	db.Exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('blog', 'theme', '"dark"')`)
+
+	call := func(method, path string, body interface{}, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		req := testutil.JSONRequest(method, "/api/apps/blog/sync"+path, body)
+		req.SetPathValue("id", "blog")
+		resp := httptest.NewRecorder()
+		handler(resp, req)
+		return resp
+	}
+
+	resp := call("GET", "?data=kv,sql", nil, AppSyncManifestHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+	resp = call("GET", "?data=kv", nil, AppSyncManifestHandler)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	if items, _ := data["items"].([]interface{}); len(items) != 1 {
+		t.Fatalf("Expected the kv entry listed, got %v", data["items"])
+	}
+
+	// A sync from another server deploys the files and writes the storage
+	put := []hosting.SyncData{
+		{SyncItem: hosting.SyncItem{Kind: hosting.SyncFile, Key: "index.html"}, Data: []byte("<h1>hi</h1>")},
+		{SyncItem: hosting.SyncItem{Kind: hosting.SyncKV, Site: "blog", Key: "theme"}, Data: []byte(`"light"`)},
+	}
+	resp = call("POST", "", AppSyncRequest{Put: put}, AppSyncHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "file_count", float64(1))
+	testutil.AssertFieldEquals(t, data, "storage_written", float64(1))
+
+	var theme string
+	db.QueryRow(`SELECT value FROM app_kv WHERE app_id = 'blog' AND key = 'theme'`).Scan(&theme)
+	if theme != `"light"` {
+		t.Errorf("Expected the synced value, got %s", theme)
+	}
+	resp = call("POST", "/export", AppSyncExportRequest{Items: []hosting.SyncItem{{Kind: hosting.SyncFile, Key: "index.html"}}}, AppSyncExportHandler)
+	data = testutil.CheckSuccess(t, resp, http.StatusOK)
+	if items, _ := data["items"].([]interface{}); len(items) != 1 {
+		t.Errorf("Expected the deployed file exported, got %v", data["items"])
+	}
+
+	// Storage of sites that aren't the app's is refused
+	put = []hosting.SyncData{{SyncItem: hosting.SyncItem{Kind: hosting.SyncKV, Site: "shop", Key: "k"}}}
+	resp = call("POST", "", AppSyncRequest{Put: put}, AppSyncHandler)
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// SystemAccessRequest is the body of a system access request
+type SystemAccessRequest struct {
+	SystemAccess bool `json:"system_access"`
+}
+
+// AppSystemAccessHandler lets an app's handlers read server-wide
+// information with fazt.sys, or stops them. fazt.sys shows every app, so
+// only server admins may change it, whatever their role in the app.
+// PUT /api/apps/{id}/system {"system_access": true}
+func AppSystemAccessHandler(w http.ResponseWriter, r *http.Request) {
+	if p := principalFromRequest(r); p != nil && !p.unrestricted() {
+		api.Forbidden(w, "Changing system access requires admin access")
+		return
+	}
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok {
+		return
+	}
+
+	var req SystemAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if err := hosting.SetSystemAccess(db, appID, req.SystemAccess); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":            appID,
+		"system_access": req.SystemAccess,
+	})
+}
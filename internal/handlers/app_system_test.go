@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestAppSystemAccessHandler(t *testing.T) {
+	setupAppsV2Test(t)
+	appID := createTestAppV2(t, "dashboard")
+	db := database.GetDB()
+
+	call := func(r *http.Request, on bool) *httptest.ResponseRecorder {
+		req := testutil.JSONRequest("PUT", "/api/apps/"+appID+"/system", map[string]interface{}{"system_access": on})
+		req = req.WithContext(r.Context())
+		req.SetPathValue("id", appID)
+		resp := httptest.NewRecorder()
+		AppSystemAccessHandler(resp, req)
+		return resp
+	}
+
+	resp := call(httptest.NewRequest("PUT", "/", nil), true)
+	data := testutil.CheckSuccess(t, resp, http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "system_access", true)
+	if on, _ := hosting.HasSystemAccess(db, "dashboard"); !on {
+		t.Error("Expected system access on")
+	}
+
+	// An app owner who isn't a server admin can't give their app fazt.sys
+	owner := withAppPrincipal(httptest.NewRequest("PUT", "/", nil), &appPrincipal{UserID: "u1"})
+	resp = call(owner, false)
+	testutil.CheckError(t, resp, http.StatusForbidden, "FORBIDDEN")
+	if on, _ := hosting.HasSystemAccess(db, "dashboard"); !on {
+		t.Error("Expected system access unchanged")
+	}
+}
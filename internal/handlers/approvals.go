@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/approval"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// With approvals required, app removal, user deletion and config changes are
+// queued (202 Accepted) instead of run, and happen once a second admin
+// approves them at /api/approvals/{id}/approve.
+
+func init() {
+	approval.Register(approval.OpAppRemove, func(params map[string]string) error {
+		return deleteApps(database.GetDB(), strings.Split(params["ids"], ","))
+	})
+	approval.Register(approval.OpUserDelete, func(params map[string]string) error {
+		user, err := authService.GetUserByID(params["user_id"])
+		if err != nil {
+			return err
+		}
+		if user.IsOwner() {
+			return errors.New("cannot delete the owner")
+		}
+		return authService.DeleteUser(user.ID)
+	})
+	approval.Register(approval.OpConfigChange, func(params map[string]string) error {
+		return applyRuntimeSetting(params["key"], params["value"])
+	})
+}
+
+// ApprovalsListHandler lists approvals, newest first
+// GET /api/approvals?status=pending
+func ApprovalsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	approvals, err := approval.List(database.GetDB(), r.URL.Query().Get("status"), time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"approvals": approvals,
+		"required":  approval.Required(),
+	})
+}
+
+// ApprovalApproveHandler runs a pending operation as the second admin
+// POST /api/approvals/{id}/approve
+func ApprovalApproveHandler(w http.ResponseWriter, r *http.Request) {
+	decideApproval(w, r, "approve", approval.Approve)
+}
+
+// ApprovalRejectHandler turns down (or withdraws) a pending operation
+// POST /api/approvals/{id}/reject
+func ApprovalRejectHandler(w http.ResponseWriter, r *http.Request) {
+	decideApproval(w, r, "reject", approval.Reject)
+}
+
+func decideApproval(w http.ResponseWriter, r *http.Request, action string,
+	decide func(*sql.DB, int64, string, time.Time) (*approval.Approval, error)) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid approval id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	actor, refused := approvalDecider(r)
+	if refused != "" {
+		api.Forbidden(w, refused)
+		return
+	}
+
+	a, err := decide(database.GetDB(), id, actor, time.Now())
+	if err != nil {
+		writeApprovalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, actor, "approval", strconv.FormatInt(id, 10), action, activity.WeightSecurity,
+		map[string]interface{}{"operation": a.Operation, "target": a.Target, "status": a.Status, "requested_by": a.RequestedBy})
+
+	api.Success(w, http.StatusOK, a)
+}
+
+func writeApprovalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, approval.ErrNotFound):
+		api.NotFound(w, "APPROVAL_NOT_FOUND", err.Error())
+	case errors.Is(err, approval.ErrSelfApproval), errors.Is(err, approval.ErrRequesterUnknown):
+		api.Forbidden(w, err.Error())
+	case errors.Is(err, approval.ErrNotPending), errors.Is(err, approval.ErrExpired):
+		api.Conflict(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
+
+// approvalActor identifies who requests an approval: the user an API key
+// acts for, so they can't approve their own request with a key, else the key
+// or session user
+func approvalActor(r *http.Request) string {
+	if key := bearerAPIKey(r); key != nil {
+		return keyActor(key)
+	}
+	return adminActor(r)
+}
+
+// approvalDecider identifies the admin deciding an approval, or why they
+// can't. Server-wide API keys are refused: they have no user, so nothing
+// tells their holder apart from the requester. Keys bound to a user decide
+// as that user, who must be an admin.
+func approvalDecider(r *http.Request) (actor, refused string) {
+	if key := bearerAPIKey(r); key != nil {
+		if key.UserID == "" {
+			return "", "Approvals can't be decided with a server-wide API key; use a session or a key created for your user"
+		}
+		user, err := authService.GetUserByID(key.UserID)
+		if err != nil || !user.IsAdmin() {
+			return "", "Approvals must be decided by an admin"
+		}
+		return user.ID, ""
+	}
+	if actor := adminActor(r); actor != "" {
+		return actor, ""
+	}
+	return "", "Approvals must be decided by a user"
+}
+
+// bearerAPIKey returns the API key a request is authenticated with, if any
+func bearerAPIKey(r *http.Request) *hosting.APIKey {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil
+	}
+	key, err := hosting.AuthenticateAPIKey(database.GetDB(), strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+func keyActor(key *hosting.APIKey) string {
+	if key.UserID != "" {
+		return key.UserID
+	}
+	return "api_key:" + key.Name
+}
+
+// requireApproval checks a destructive operation may run now. With approvals
+// required it queues the operation instead, answering 202 with the approval.
+func requireApproval(w http.ResponseWriter, r *http.Request, op, target, summary string, params map[string]string) bool {
+	if !approval.Required() {
+		return true
+	}
+
+	a, err := approval.Submit(database.GetDB(), approval.Request{
+		Operation:   op,
+		Target:      target,
+		Summary:     summary,
+		Params:      params,
+		RequestedBy: approvalActor(r),
+	}, time.Now())
+	if err != nil {
+		writeApprovalError(w, err)
+		return false
+	}
+
+	activity.LogFromRequest(r, a.RequestedBy, "approval", strconv.FormatInt(a.ID, 10), "request", activity.WeightSecurity,
+		map[string]interface{}{"operation": op, "target": target})
+
+	api.Success(w, http.StatusAccepted, map[string]interface{}{
+		"approval": a,
+		"message":  pendingApprovalMessage(a),
+	})
+	return false
+}
+
+// cmdRequestApproval is the command gateway's equivalent of requireApproval
+func cmdRequestApproval(db *sql.DB, req approval.Request) (interface{}, error) {
+	a, err := approval.Submit(db, req, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	activity.LogSuccess(activity.ActorAPIKey, req.RequestedBy, "", "approval", strconv.FormatInt(a.ID, 10), "request",
+		activity.WeightSecurity, map[string]interface{}{"operation": req.Operation, "target": req.Target, "via": "cmd"})
+
+	return map[string]interface{}{
+		"approval_id": a.ID,
+		"status":      a.Status,
+		"message":     pendingApprovalMessage(a),
+	}, nil
+}
+
+func pendingApprovalMessage(a *approval.Approval) string {
+	return fmt.Sprintf("%s is pending approval #%d by another admin", a.Summary, a.ID)
+}
+
+// appRemovalSummary describes an app removal for the approval queue
+func appRemovalSummary(appID, title string, ids []string) string {
+	name := appID
+	if title != "" {
+		name = fmt.Sprintf("%s (%s)", title, appID)
+	}
+	if len(ids) > 1 {
+		return fmt.Sprintf("Remove app %s and %d forks", name, len(ids)-1)
+	}
+	return "Remove app " + name
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/approval"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestCmdAppRemove_RequiresApproval(t *testing.T) {
+	setupCmdTestDB(t)
+	approval.SetRequired(true)
+	t.Cleanup(func() { approval.SetRequired(false) })
+	db := database.GetDB()
+
+	result, err := cmdAppRemove(nil, "api_key:alice", []string{"app_test123"})
+	if err != nil {
+		t.Fatalf("Expected the removal to be queued, got %v", err)
+	}
+	id, _ := result.(map[string]interface{})["approval_id"].(int64)
+	if id == 0 {
+		t.Fatalf("Expected an approval id, got %v", result)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM apps WHERE id = 'app_test123'").Scan(&count)
+	if count != 1 {
+		t.Fatal("Expected the app to remain until approved")
+	}
+
+	if _, err := approval.Approve(db, id, "api_key:alice", time.Now()); !errors.Is(err, approval.ErrSelfApproval) {
+		t.Fatalf("Expected the requester's own approval to be refused, got %v", err)
+	}
+	a, err := approval.Approve(db, id, "api_key:bob", time.Now())
+	if err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+	if a.Status != approval.StatusExecuted {
+		t.Fatalf("Expected the removal to run, got %s (%s)", a.Status, a.Error)
+	}
+
+	db.QueryRow("SELECT COUNT(*) FROM apps WHERE id = 'app_test123'").Scan(&count)
+	if count != 0 {
+		t.Error("Expected the app removed once approved")
+	}
+	db.QueryRow("SELECT COUNT(*) FROM aliases WHERE subdomain = 'test-alias'").Scan(&count)
+	if count != 0 {
+		t.Error("Expected the app's aliases removed with it")
+	}
+}
+
+func TestApprovalDecide_RefusesServerKeys(t *testing.T) {
+	setupAppAuthTest(t)
+	approval.SetRequired(true)
+	t.Cleanup(func() { approval.SetRequired(false) })
+	db := database.GetDB()
+
+	appID := createTestAppV2(t, "notes")
+	alice := createTestUserWithRole(t, authService, "admin")
+	bob := createTestUserWithRole(t, authService, "owner")
+	aliceSession := createTestSessionToken(t, authService, alice.ID)
+	bobSession := createTestSessionToken(t, authService, bob.ID)
+	serverKey, _ := hosting.CreateScopedAPIKey(db, hosting.APIKeyOptions{Name: "deploy"})
+	aliceKey, _ := hosting.CreateUserAPIKey(db, "alice-cli", hosting.ScopeAdmin, alice.ID)
+	withKey := func(token string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	resp := serveSessions("DELETE /api/apps/{id}", AppAccess(AppDeleteHandlerV2), "DELETE", "/api/apps/"+appID, withSession(aliceSession))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("Expected the removal queued with 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	pending, _ := approval.List(db, approval.StatusPending, time.Now())
+	if len(pending) != 1 {
+		t.Fatalf("Expected one pending approval, got %d", len(pending))
+	}
+	path := fmt.Sprintf("/api/approvals/%d/approve", pending[0].ID)
+
+	// A server-wide key could be held by the requester, so it can't approve
+	resp = serveSessions("POST /api/approvals/{id}/approve", ApprovalApproveHandler, "POST", path, withKey(serverKey))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 approving with a server-wide key, got %d: %s", resp.Code, resp.Body.String())
+	}
+	// Nor reject it
+	resp = serveSessions("POST /api/approvals/{id}/reject", ApprovalRejectHandler, "POST",
+		fmt.Sprintf("/api/approvals/%d/reject", pending[0].ID), withKey(serverKey))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 rejecting with a server-wide key, got %d: %s", resp.Code, resp.Body.String())
+	}
+	// A key bound to the requester decides as the requester
+	resp = serveSessions("POST /api/approvals/{id}/approve", ApprovalApproveHandler, "POST", path, withKey(aliceKey))
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("Expected 403 approving with the requester's own key, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = serveSessions("POST /api/approvals/{id}/approve", ApprovalApproveHandler, "POST", path, withSession(bobSession))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected another admin to approve, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM apps WHERE id = ?", appID).Scan(&count)
+	if count != 0 {
+		t.Error("Expected the app removed once approved")
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"text/template"
 
 	"github.com/fazt-sh/fazt/internal/api"
@@ -204,7 +203,7 @@ func AppDeleteHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Don't allow deleting system apps
-	if title == "root" || title == "404" || title == "admin" {
+	if title == "root" || title == "404" || title == "status" || title == "admin" {
 		api.ErrorResponse(w, http.StatusForbidden, "SYSTEM_APP", "Cannot delete system app", "")
 		return
 	}
@@ -243,13 +242,17 @@ func AppFilesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get app title
-	var title string
-	err := db.QueryRow("SELECT title FROM apps WHERE id = ? OR title = ?", appID, appID).Scan(&title)
+	var id, title string
+	err := db.QueryRow("SELECT id, title FROM apps WHERE id = ? OR title = ?", appID, appID).Scan(&id, &title)
 	if err != nil {
 		api.NotFound(w, "APP_NOT_FOUND", "App not found")
 		return
 	}
 
+	if !requireAppRole(w, r, id, hosting.AppRoleViewer) {
+		return
+	}
+
 	fs := hosting.GetFileSystem()
 	files, err := fs.ListFiles(title)
 	if err != nil {
@@ -283,19 +286,23 @@ func AppSourceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	query := `
-		SELECT source, source_url, source_ref, source_commit
+		SELECT id, source, source_url, source_ref, source_commit
 		FROM apps WHERE title = ? OR id = ?
 	`
 
-	var sourceType string
+	var id, sourceType string
 	var sourceURL, sourceRef, sourceCommit *string
 
-	err := db.QueryRow(query, appID, appID).Scan(&sourceType, &sourceURL, &sourceRef, &sourceCommit)
+	err := db.QueryRow(query, appID, appID).Scan(&id, &sourceType, &sourceURL, &sourceRef, &sourceCommit)
 	if err != nil {
 		api.NotFound(w, "APP_NOT_FOUND", "App not found")
 		return
 	}
 
+	if !requireAppRole(w, r, id, hosting.AppRoleViewer) {
+		return
+	}
+
 	result := map[string]string{
 		"type": sourceType,
 	}
@@ -329,13 +336,17 @@ func AppFileContentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get app title
-	var title string
-	err := db.QueryRow("SELECT title FROM apps WHERE id = ? OR title = ?", appID, appID).Scan(&title)
+	var id, title string
+	err := db.QueryRow("SELECT id, title FROM apps WHERE id = ? OR title = ?", appID, appID).Scan(&id, &title)
 	if err != nil {
 		api.NotFound(w, "APP_NOT_FOUND", "App not found")
 		return
 	}
 
+	if !requireAppRole(w, r, id, hosting.AppRoleViewer) {
+		return
+	}
+
 	fs := hosting.GetFileSystem()
 	file, err := fs.ReadFile(title, filePath)
 	if err != nil {
@@ -418,6 +429,11 @@ func AppInstallHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	isNew, ok := requireDeployAccess(w, r, database.GetDB(), appName)
+	if !ok {
+		return
+	}
+
 	// Build step (server likely has no npm - will use existing or source)
 	deployDir := tmpDir
 	buildResult, err := build.Build(tmpDir, nil)
@@ -473,19 +489,31 @@ func AppInstallHandler(w http.ResponseWriter, r *http.Request) {
 		Commit: result.CommitSHA,
 	}
 
-	_, err = hosting.DeploySiteWithSource(zipReader, appName, sourceInfo)
+	if err := hosting.CheckDeployLimits(database.GetDB(), appName, adminActor(r), zipReader); err != nil {
+		writeDeployError(w, err)
+		return
+	}
+	deployed, err := hosting.DeploySiteWithSource(zipReader, appName, sourceInfo)
 	if err != nil {
-		api.InternalError(w, err)
+		writeDeployError(w, err)
 		return
 	}
+	logDeploySecrets(deployed)
+	if isNew {
+		grantDeployedAppOwner(r, database.GetDB(), appName)
+	}
 
 	cfg := config.Get()
-	api.Success(w, http.StatusCreated, map[string]interface{}{
+	resp := map[string]interface{}{
 		"name":   appName,
 		"url":    fmt.Sprintf("https://%s.%s", appName, cfg.Server.Domain),
 		"source": req.URL,
 		"commit": result.CommitSHA[:7],
-	})
+	}
+	if len(deployed.Secrets) > 0 {
+		resp["secrets"] = deployed.Secrets
+	}
+	api.Success(w, http.StatusCreated, resp)
 }
 
 // CreateRequest is the request body for POST /api/apps/create
@@ -518,6 +546,11 @@ func AppCreateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	isNew, ok := requireDeployAccess(w, r, database.GetDB(), req.Name)
+	if !ok {
+		return
+	}
+
 	// Default template
 	if req.Template == "" {
 		req.Template = "minimal"
@@ -564,11 +597,18 @@ func AppCreateHandler(w http.ResponseWriter, r *http.Request) {
 		URL:  req.Template,
 	}
 
+	if err := hosting.CheckDeployLimits(database.GetDB(), req.Name, adminActor(r), zipReader); err != nil {
+		writeDeployError(w, err)
+		return
+	}
 	_, err = hosting.DeploySiteWithSource(zipReader, req.Name, sourceInfo)
 	if err != nil {
-		api.InternalError(w, err)
+		writeDeployError(w, err)
 		return
 	}
+	if isNew {
+		grantDeployedAppOwner(r, database.GetDB(), req.Name)
+	}
 
 	cfg := config.Get()
 	api.Success(w, http.StatusCreated, map[string]interface{}{
@@ -706,43 +746,19 @@ func createZipFromDir(srcDir string) ([]byte, error) {
 // AppStatusHandler returns detailed status for a specific app
 // GET /api/apps/{id}/status
 // Returns app info with user counts and storage stats
-// Requires admin auth (API key or session with admin/owner role)
+// Requires viewer access to the app (see AppAccess)
 func AppStatusHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
 		return
 	}
 
-	// Require admin auth (API key or session with admin/owner role)
 	db := database.GetDB()
 	if db == nil {
 		api.InternalError(w, nil)
 		return
 	}
 
-	// Try API key auth first (CLI usage)
-	authHeader := r.Header.Get("Authorization")
-	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		_, _, err := hosting.ValidateAPIKey(db, token)
-		if err != nil {
-			api.Unauthorized(w, "Invalid API key")
-			return
-		}
-		// API key is valid, proceed
-	} else {
-		// Try session auth (UI usage)
-		user, err := authService.GetSessionFromRequest(r)
-		if err != nil {
-			api.Unauthorized(w, "Authentication required")
-			return
-		}
-		if user.Role != "admin" && user.Role != "owner" {
-			api.Error(w, http.StatusForbidden, "FORBIDDEN", "Admin or owner role required", nil)
-			return
-		}
-	}
-
 	appID := r.PathValue("id")
 	if appID == "" {
 		api.BadRequest(w, "app_id required")
@@ -803,6 +819,9 @@ func AppStatusHandler(w http.ResponseWriter, r *http.Request) {
 		api.NotFound(w, "APP_NOT_FOUND", "App not found")
 		return
 	}
+	if !requireAppRole(w, r, app.ID, hosting.AppRoleViewer) {
+		return
+	}
 	if createdAt != nil {
 		app.CreatedAt = formatTime(createdAt)
 	}
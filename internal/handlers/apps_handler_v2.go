@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -16,6 +17,7 @@ import (
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/approval"
 	"github.com/fazt-sh/fazt/internal/assets"
 	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
@@ -35,6 +37,11 @@ type AppV2 struct {
 	SourceCommit string   `json:"source_commit,omitempty"`
 	OriginalID   string   `json:"original_id,omitempty"`
 	ForkedFromID string   `json:"forked_from_id,omitempty"`
+	Analytics    bool     `json:"analytics_inject"`
+	Collect      bool     `json:"analytics_collect"`
+	HonorDNT     bool     `json:"analytics_honor_dnt"`
+	Protected    bool     `json:"protected"`
+	SystemAccess bool     `json:"system_access"`
 	FileCount    int      `json:"file_count"`
 	SizeBytes    int64    `json:"size_bytes"`
 	CreatedAt    string   `json:"created_at"`
@@ -42,7 +49,25 @@ type AppV2 struct {
 	Aliases      []string `json:"aliases,omitempty"` // Associated aliases
 }
 
-// AppsListHandlerV2 returns the list of apps with v0.10 schema
+// appsListSpec is how GET /api/apps pages, sorts and projects apps
+var appsListSpec = api.ListSpec{
+	Sorts: map[string]string{
+		"updated_at": "COALESCE(a.updated_at, '')",
+		"created_at": "COALESCE(a.created_at, '')",
+		"title":      "COALESCE(a.title, '')",
+		"id":         "a.id",
+	},
+	DefaultSort: "-updated_at",
+	ID:          "a.id",
+	Fields: []string{"id", "title", "description", "tags", "visibility", "source", "source_url", "source_ref",
+		"source_commit", "original_id", "forked_from_id", "analytics_inject", "analytics_collect",
+		"analytics_honor_dnt", "protected", "file_count", "size_bytes", "created_at", "updated_at", "aliases"},
+	DefaultLimit: 100,
+	MaxLimit:     500,
+}
+
+// AppsListHandlerV2 returns a page of apps with v0.10 schema
+// Query params: ?all=true&limit=&cursor=&sort=&fields= (see api.ParseList)
 func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
@@ -55,9 +80,21 @@ func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	q, ok := api.ParseList(w, r, appsListSpec)
+	if !ok {
+		return
+	}
+
 	// Check if visibility filter is requested (public API vs admin API)
 	showAll := r.URL.Query().Get("all") == "true"
 
+	// File totals join every file row; skip them when they aren't selected
+	withFiles := q.Wants("file_count") || q.Wants("size_bytes")
+	totals := "0 as file_count, 0 as size_bytes"
+	if withFiles {
+		totals = "COALESCE(COUNT(f.path), 0) as file_count, COALESCE(SUM(f.size_bytes), 0) as size_bytes"
+	}
+
 	query := `
 		SELECT
 			a.id,
@@ -71,24 +108,48 @@ func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 			COALESCE(a.source_commit, '') as source_commit,
 			COALESCE(a.original_id, '') as original_id,
 			COALESCE(a.forked_from_id, '') as forked_from_id,
+			a.protected,
 			a.created_at,
 			a.updated_at,
-			COALESCE(COUNT(f.path), 0) as file_count,
-			COALESCE(SUM(f.size_bytes), 0) as size_bytes
+			` + totals + `,
+			` + q.SortExpr() + `
 		FROM apps a
-		LEFT JOIN files f ON a.id = f.app_id
 	`
+	if withFiles {
+		query += " LEFT JOIN files f ON a.id = f.app_id"
+	}
 
+	conditions := []string{}
+	args := []interface{}{}
 	if !showAll {
-		query += " WHERE a.visibility = 'public'"
+		conditions = append(conditions, "a.visibility = 'public'")
+	}
+	// Regular users only see apps they are members of; app-scoped keys only their app
+	if p := restrictedPrincipal(r); p != nil {
+		if p.AppID != "" {
+			conditions = append(conditions, "a.id = ?")
+			args = append(args, p.AppID)
+		}
+		if !p.Admin {
+			conditions = append(conditions, "a.id IN (SELECT app_id FROM app_members WHERE user_id = ?)")
+			args = append(args, p.UserID)
+		}
+	}
+	if after, afterArgs := q.Where(); after != "" {
+		conditions = append(conditions, after)
+		args = append(args, afterArgs...)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += `
-		GROUP BY a.id
-		ORDER BY a.updated_at DESC
-	`
+	if withFiles {
+		query += " GROUP BY a.id"
+	}
+	query += " ORDER BY " + q.OrderBy() + " LIMIT ? OFFSET ?"
+	args = append(args, q.LimitArgs()...)
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		api.InternalError(w, err)
 		return
@@ -97,11 +158,11 @@ func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 
 	// Collect all apps first, then close cursor before querying aliases
 	// (avoids nested query deadlock — Issue 05)
-	var apps []AppV2
+	apps := []AppV2{}
 	for rows.Next() {
 		var app AppV2
 		var tagsJSON string
-		var createdAt, updatedAt interface{}
+		var createdAt, updatedAt, sortKey interface{}
 
 		err := rows.Scan(
 			&app.ID,
@@ -115,14 +176,19 @@ func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 			&app.SourceCommit,
 			&app.OriginalID,
 			&app.ForkedFromID,
+			&app.Protected,
 			&createdAt,
 			&updatedAt,
 			&app.FileCount,
 			&app.SizeBytes,
+			&sortKey,
 		)
 		if err != nil {
 			continue
 		}
+		if !q.Next(sortKey, app.ID) {
+			break
+		}
 
 		// Parse tags
 		if tagsJSON != "" {
@@ -142,11 +208,13 @@ func AppsListHandlerV2(w http.ResponseWriter, r *http.Request) {
 	rows.Close()
 
 	// Now safe to query aliases — cursor is closed
-	for i := range apps {
-		apps[i].Aliases = getAliasesForApp(db, apps[i].ID)
+	if q.Wants("aliases") {
+		for i := range apps {
+			apps[i].Aliases = getAliasesForApp(db, apps[i].ID)
+		}
 	}
 
-	api.Success(w, http.StatusOK, apps)
+	api.ListSuccess(w, apps, q)
 }
 
 // AppDetailHandlerV2 returns details for a single app by ID or alias
@@ -185,6 +253,10 @@ func AppDetailHandlerV2(w http.ResponseWriter, r *http.Request) {
 		appID = resolvedID
 	}
 
+	if !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
 	app, err := getAppByID(db, appID)
 	if err == sql.ErrNoRows {
 		api.NotFound(w, "APP_NOT_FOUND", "App not found")
@@ -262,6 +334,7 @@ func AppCreateHandlerV2(w http.ResponseWriter, r *http.Request) {
 		api.InternalError(w, err)
 		return
 	}
+	grantAppOwner(r, newID)
 
 	// Create alias if requested
 	if req.Alias != "" {
@@ -309,6 +382,9 @@ type AppUpdateRequest struct {
 	Description *string  `json:"description,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
 	Visibility  *string  `json:"visibility,omitempty"`
+	Analytics   *bool    `json:"analytics_inject,omitempty"`
+	Collect     *bool    `json:"analytics_collect,omitempty"`
+	HonorDNT    *bool    `json:"analytics_honor_dnt,omitempty"`
 }
 
 // AppUpdateHandlerV2 updates app metadata
@@ -344,6 +420,10 @@ func AppUpdateHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAppRole(w, r, appID, hosting.AppRoleEditor) {
+		return
+	}
+
 	// Build update query dynamically
 	updates := []string{}
 	args := []interface{}{}
@@ -369,6 +449,30 @@ func AppUpdateHandlerV2(w http.ResponseWriter, r *http.Request) {
 		updates = append(updates, "visibility = ?")
 		args = append(args, *req.Visibility)
 	}
+	if req.Analytics != nil {
+		updates = append(updates, "analytics_inject = ?")
+		if *req.Analytics {
+			args = append(args, 1)
+		} else {
+			args = append(args, 0)
+		}
+	}
+	if req.Collect != nil {
+		updates = append(updates, "analytics_collect = ?")
+		if *req.Collect {
+			args = append(args, 1)
+		} else {
+			args = append(args, 0)
+		}
+	}
+	if req.HonorDNT != nil {
+		updates = append(updates, "analytics_honor_dnt = ?")
+		if *req.HonorDNT {
+			args = append(args, 1)
+		} else {
+			args = append(args, 0)
+		}
+	}
 
 	if len(updates) == 0 {
 		api.BadRequest(w, "no fields to update")
@@ -384,6 +488,9 @@ func AppUpdateHandlerV2(w http.ResponseWriter, r *http.Request) {
 		api.InternalError(w, err)
 		return
 	}
+	if req.Analytics != nil || req.Title != nil {
+		hosting.ForgetAnalyticsInject()
+	}
 
 	api.Success(w, http.StatusOK, map[string]interface{}{
 		"id":      appID,
@@ -424,64 +531,43 @@ func AppDeleteHandlerV2(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Don't allow deleting system apps
-	if source == "system" {
-		api.ErrorResponse(w, http.StatusForbidden, "SYSTEM_APP", "Cannot delete system app", "")
+	if !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
 		return
 	}
 
-	// Start transaction
-	tx, err := db.Begin()
-	if err != nil {
-		api.InternalError(w, err)
+	// Don't allow deleting system apps
+	if source == "system" {
+		api.ErrorResponse(w, http.StatusForbidden, "SYSTEM_APP", "Cannot delete system app", "")
 		return
 	}
-	defer tx.Rollback()
 
 	idsToDelete := []string{appID}
 
 	// If with-forks, find all forks
 	if withForks {
 		// Find all apps with this original_id
-		rows, err := tx.Query("SELECT id FROM apps WHERE original_id = ? AND id != ?", appID, appID)
+		rows, err := db.Query("SELECT id FROM apps WHERE original_id = ? AND id != ?", appID, appID)
 		if err == nil {
-			defer rows.Close()
 			for rows.Next() {
 				var id string
 				if rows.Scan(&id) == nil {
 					idsToDelete = append(idsToDelete, id)
 				}
 			}
+			rows.Close()
 		}
 	}
 
-	// Delete files for all apps
-	for _, id := range idsToDelete {
-		_, err = tx.Exec("DELETE FROM files WHERE app_id = ?", id)
-		if err != nil {
-			api.InternalError(w, err)
-			return
-		}
-	}
-
-	// Delete apps
-	for _, id := range idsToDelete {
-		_, err = tx.Exec("DELETE FROM apps WHERE id = ?", id)
-		if err != nil {
-			api.InternalError(w, err)
-			return
-		}
+	if !requireAppRemoval(w, r, db, appID, title, idsToDelete) {
+		return
 	}
 
-	// Remove aliases pointing to deleted apps (orphan cleanup)
-	for _, id := range idsToDelete {
-		_, err = tx.Exec("DELETE FROM aliases WHERE targets LIKE ?", `%"`+id+`"%`)
-		if err != nil {
-			// Non-fatal, continue
-		}
+	if !requireApproval(w, r, approval.OpAppRemove, appID, appRemovalSummary(appID, title, idsToDelete),
+		map[string]string{"ids": strings.Join(idsToDelete, ",")}) {
+		return
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := deleteApps(db, idsToDelete); err != nil {
 		api.InternalError(w, err)
 		return
 	}
@@ -494,10 +580,45 @@ func AppDeleteHandlerV2(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// deleteApps removes apps with their files, and the aliases routing to them
+func deleteApps(db *sql.DB, ids []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Delete files for all apps
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM files WHERE app_id = ?", id); err != nil {
+			return err
+		}
+		tx.Exec("DELETE FROM fork_bases WHERE app_id = ?", id)
+	}
+
+	// Delete apps
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM apps WHERE id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	// Remove aliases pointing to deleted apps (orphan cleanup, non-fatal)
+	for _, id := range ids {
+		tx.Exec("DELETE FROM aliases WHERE targets LIKE ?", `%"`+id+`"%`)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	hosting.ForgetAnalyticsInject()
+	return nil
+}
+
 // ForkRequest represents a request to fork an app
 type ForkRequest struct {
 	Alias       string `json:"alias"`        // Optional new alias
-	CopyStorage bool   `json:"copy_storage"` // Whether to copy KV storage
+	CopyStorage bool   `json:"copy_storage"` // Whether to copy storage, anonymized per the manifest, to the alias
 }
 
 // AppForkHandler forks an app
@@ -536,6 +657,10 @@ func AppForkHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAppRole(w, r, sourceApp.ID, hosting.AppRoleViewer) {
+		return
+	}
+
 	// Generate new app ID
 	newID := appid.GenerateApp()
 
@@ -552,6 +677,26 @@ func AppForkHandler(w http.ResponseWriter, r *http.Request) {
 		originalID = sourceApp.ID
 	}
 
+	// The copied files are the base a merge back compares against
+	baseFiles, err := appFileHashes(db, sourceApp.ID, "")
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	// Storage copied to the fork is anonymized by the manifest's rules;
+	// broken rules refuse the fork rather than copy real data
+	var anonymize map[string]string
+	if req.CopyStorage && req.Alias != "" {
+		if anonymize, err = hosting.AnonymizeRulesFor(db, sourceApp.ID); errors.Is(err, hosting.ErrInvalidManifest) {
+			api.ValidationError(w, err.Error(), "anonymize", "format")
+			return
+		} else if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+	}
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -571,17 +716,21 @@ func AppForkHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Copy files
+	// Copy files, under the fork's ID as (site_id, path) is unique
 	copyQuery := `
 		INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash, created_at, updated_at)
-		SELECT site_id, ?, path, content, size_bytes, mime_type, hash, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
-		FROM files WHERE app_id = ?
+		SELECT ?1, ?1, path, content, size_bytes, mime_type, hash, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM files WHERE app_id = ?2
 	`
 	_, err = tx.Exec(copyQuery, newID, sourceApp.ID)
 	if err != nil {
 		api.InternalError(w, err)
 		return
 	}
+	if err := saveForkBase(tx, newID, baseFiles); err != nil {
+		api.InternalError(w, err)
+		return
+	}
 
 	// Copy KV storage if requested
 	if req.CopyStorage {
@@ -613,10 +762,20 @@ func AppForkHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The fork's handlers run as its alias, so its storage goes there
+	var storageCopied int64
+	if req.CopyStorage && req.Alias != "" {
+		if storageCopied, err = hosting.CopyForkStorage(tx, forkStorageSite(sourceApp), req.Alias, anonymize); err != nil {
+			api.InternalError(w, err)
+			return
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		api.InternalError(w, err)
 		return
 	}
+	grantAppOwner(r, newID)
 
 	cfg := config.Get()
 	result := map[string]interface{}{
@@ -630,6 +789,10 @@ func AppForkHandler(w http.ResponseWriter, r *http.Request) {
 		result["alias"] = req.Alias
 		result["url"] = fmt.Sprintf("https://%s.%s", req.Alias, cfg.Server.Domain)
 	}
+	if req.CopyStorage && req.Alias != "" {
+		result["storage_copied"] = storageCopied
+		result["anonymized_fields"] = len(anonymize)
+	}
 
 	api.Success(w, http.StatusCreated, result)
 }
@@ -673,6 +836,10 @@ func AppLineageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
 	// Build lineage tree starting from original
 	tree := buildLineageTree(db, originalID, nil)
 
@@ -698,6 +865,10 @@ func AppForksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
 	query := `
 		SELECT id, COALESCE(title, '') as title
 		FROM apps WHERE forked_from_id = ?
@@ -754,6 +925,11 @@ func getAppByID(db *sql.DB, appID string) (*AppV2, error) {
 			COALESCE(a.source_commit, '') as source_commit,
 			COALESCE(a.original_id, '') as original_id,
 			COALESCE(a.forked_from_id, '') as forked_from_id,
+			COALESCE(a.analytics_inject, 1) as analytics_inject,
+			COALESCE(a.analytics_collect, 1) as analytics_collect,
+			COALESCE(a.analytics_honor_dnt, 0) as analytics_honor_dnt,
+			a.protected,
+			a.system_access,
 			a.created_at,
 			a.updated_at,
 			COALESCE(COUNT(f.path), 0) as file_count,
@@ -780,6 +956,11 @@ func getAppByID(db *sql.DB, appID string) (*AppV2, error) {
 		&app.SourceCommit,
 		&app.OriginalID,
 		&app.ForkedFromID,
+		&app.Analytics,
+		&app.Collect,
+		&app.HonorDNT,
+		&app.Protected,
+		&app.SystemAccess,
 		&createdAt,
 		&updatedAt,
 		&app.FileCount,
@@ -808,6 +989,16 @@ func getAppByID(db *sql.DB, appID string) (*AppV2, error) {
 	return &app, nil
 }
 
+// forkStorageSite returns the site an app's storage is kept under, which a
+// fork of it copies: its alias for a fork, as forks share their original's
+// title, and its title otherwise
+func forkStorageSite(app *AppV2) string {
+	if app.ForkedFromID != "" && len(app.Aliases) > 0 {
+		return app.Aliases[0]
+	}
+	return app.Title
+}
+
 func getAliasesForApp(db *sql.DB, appID string) []string {
 	var aliases []string
 	rows, err := db.Query("SELECT subdomain FROM aliases WHERE targets LIKE ?", `%"`+appID+`"%`)
@@ -933,7 +1124,7 @@ func deployFilesToApp(db *sql.DB, appID string, zipReader *zip.Reader) error {
 				hash = excluded.hash,
 				updated_at = CURRENT_TIMESTAMP
 		`
-		_, err = db.Exec(query, appID, appID, cleanPath, data, len(data), hosting.GetMimeType(cleanPath), "", appID)
+		_, err = db.Exec(query, appID, appID, cleanPath, data, len(data), hosting.DetectMimeType(cleanPath, data), "", appID)
 		if err != nil {
 			return err
 		}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/fazt-sh/fazt/internal/config"
@@ -152,6 +154,60 @@ func TestAppsListHandlerV2_ShowAll(t *testing.T) {
 	}
 }
 
+func TestAppsListHandlerV2_Pages(t *testing.T) {
+	setupAppsV2Test(t)
+	for _, title := range []string{"e-app", "c-app", "a-app", "d-app", "b-app"} {
+		createTestAppV2WithVisibility(t, title, "public")
+	}
+
+	// Same updated_at for all, so pages rely on the ID breaking ties
+	var titles []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("Expected paging to end")
+		}
+		req := httptest.NewRequest("GET", "/api/apps?limit=2&sort=title&fields=id,title&cursor="+cursor, nil)
+		resp := httptest.NewRecorder()
+		AppsListHandlerV2(resp, req)
+
+		var body struct {
+			Data []map[string]interface{} `json:"data"`
+			Meta struct {
+				Limit      int    `json:"limit"`
+				HasMore    bool   `json:"has_more"`
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil || resp.Code != http.StatusOK {
+			t.Fatalf("Expected a page, got %d: %s", resp.Code, resp.Body.String())
+		}
+		for _, app := range body.Data {
+			if len(app) != 2 || app["id"] == nil {
+				t.Errorf("Expected only id and title, got %v", app)
+			}
+			titles = append(titles, app["title"].(string))
+		}
+		if body.Meta.Limit != 2 || body.Meta.HasMore != (body.Meta.NextCursor != "") {
+			t.Errorf("Unexpected meta: %+v", body.Meta)
+		}
+		if !body.Meta.HasMore {
+			break
+		}
+		cursor = body.Meta.NextCursor
+	}
+	if strings.Join(titles, ",") != "a-app,b-app,c-app,d-app,e-app" {
+		t.Errorf("Expected every app once in title order, got %v", titles)
+	}
+
+	for _, query := range []string{"sort=size", "fields=secret", "limit=0", "cursor=nope"} {
+		req := httptest.NewRequest("GET", "/api/apps?"+query, nil)
+		resp := httptest.NewRecorder()
+		AppsListHandlerV2(resp, req)
+		testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+	}
+}
+
 func TestAppsListHandlerV2_MethodNotAllowed(t *testing.T) {
 	setupAppsV2Test(t)
 
@@ -373,6 +429,27 @@ func TestAppUpdateHandlerV2_Visibility(t *testing.T) {
 	testutil.AssertFieldEquals(t, data, "message", "App updated")
 }
 
+func TestAppUpdateHandlerV2_AnalyticsInject(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "inject-app")
+	page := []byte("<html><body>Hi</body></html>")
+
+	// Read once so the flag is cached
+	if out := hosting.InjectAnalytics(page, id); !strings.Contains(string(out), "sendBeacon") {
+		t.Fatal("Expected analytics injected by default")
+	}
+
+	req := testutil.JSONRequest("PUT", "/api/v2/apps/"+id, map[string]interface{}{"analytics_inject": false})
+	req.SetPathValue("id", id)
+	resp := httptest.NewRecorder()
+	AppUpdateHandlerV2(resp, req)
+	testutil.CheckSuccess(t, resp, http.StatusOK)
+
+	if out := hosting.InjectAnalytics(page, id); strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected the update to reach pages without waiting on the cache")
+	}
+}
+
 func TestAppUpdateHandlerV2_InvalidVisibility(t *testing.T) {
 	setupAppsV2Test(t)
 	id := createTestAppV2(t, "bad-vis-app")
@@ -563,6 +640,75 @@ func TestAppForkHandler_WithAlias(t *testing.T) {
 	testutil.AssertFieldEquals(t, data, "alias", "my-fork")
 }
 
+func TestAppForkHandler_CopyStorageAnonymized(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "signups")
+	db := database.GetDB()
+	db.Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+		VALUES ('signups', ?, 'manifest.json', '{"anonymize": {"email": "email", "name": "name"}}', 50, 'application/json', 'h1')`, id)
+	db.Exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('signups', 'users', 'u1', '{"email":"ann@real.com","name":"Ann","plan":"pro"}')`)
+	db.Exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('signups', 'owner', '{"email":"ann@real.com"}')`)
+
+	body := map[string]interface{}{"alias": "signups-exp", "copy_storage": true}
+	req := testutil.JSONRequest("POST", "/api/v2/apps/"+id+"/fork", body)
+	req.SetPathValue("id", id)
+	resp := httptest.NewRecorder()
+	AppForkHandler(resp, req)
+
+	data := testutil.CheckSuccess(t, resp, http.StatusCreated)
+	testutil.AssertFieldEquals(t, data, "storage_copied", float64(2))
+	testutil.AssertFieldEquals(t, data, "anonymized_fields", float64(2))
+
+	var doc, kv string
+	db.QueryRow(`SELECT data FROM app_docs WHERE app_id = 'signups-exp' AND id = 'u1'`).Scan(&doc)
+	db.QueryRow(`SELECT value FROM app_kv WHERE app_id = 'signups-exp' AND key = 'owner'`).Scan(&kv)
+	if doc == "" || strings.Contains(doc, "ann@real.com") || strings.Contains(doc, "Ann") || !strings.Contains(doc, `"plan":"pro"`) {
+		t.Errorf("Expected the document anonymized, got %s", doc)
+	}
+	if kv == "" || strings.Contains(kv, "ann@real.com") {
+		t.Errorf("Expected the kv value anonymized, got %s", kv)
+	}
+}
+
+func TestAppForkHandler_BadAnonymizeRules(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "signups")
+	database.GetDB().Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+		VALUES ('signups', ?, 'manifest.json', '{"anonymize": {"email": "mail"}}', 33, 'application/json', 'h1')`, id)
+
+	body := map[string]interface{}{"alias": "signups-exp", "copy_storage": true}
+	req := testutil.JSONRequest("POST", "/api/v2/apps/"+id+"/fork", body)
+	req.SetPathValue("id", id)
+	resp := httptest.NewRecorder()
+	AppForkHandler(resp, req)
+
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+}
+
+func TestAppForkHandler_CorruptManifest(t *testing.T) {
+	setupAppsV2Test(t)
+	id := createTestAppV2(t, "signups")
+	db := database.GetDB()
+	db.Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+		VALUES ('signups', ?, 'manifest.json', '{"anonymize": {"email": "email"', 31, 'application/json', 'h1')`, id)
+	db.Exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('signups', 'users', 'u1', '{"email":"ann@real.com"}')`)
+
+	body := map[string]interface{}{"alias": "signups-exp", "copy_storage": true}
+	req := testutil.JSONRequest("POST", "/api/v2/apps/"+id+"/fork", body)
+	req.SetPathValue("id", id)
+	resp := httptest.NewRecorder()
+	AppForkHandler(resp, req)
+
+	// Without readable rules nothing is copied, rather than real data
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
+	var docs, forks int
+	db.QueryRow(`SELECT COUNT(*) FROM app_docs WHERE app_id = 'signups-exp'`).Scan(&docs)
+	db.QueryRow(`SELECT COUNT(*) FROM apps WHERE forked_from_id = ?`, id).Scan(&forks)
+	if docs != 0 || forks != 0 {
+		t.Errorf("Expected no fork, got %d apps and %d documents", forks, docs)
+	}
+}
+
 func TestAppForkHandler_NotFound(t *testing.T) {
 	setupAppsV2Test(t)
 
@@ -13,6 +13,8 @@ import (
 	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/security"
 )
 
 var (
@@ -53,7 +55,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get client IP for rate limiting
-	ip := getClientIP(r)
+	ip := realip.FromRequest(r)
 
 	// Check rate limit
 	if !rateLimiter.AllowLogin(ip) {
@@ -81,6 +83,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		rateLimiter.RecordAttempt(ip)
 		audit.LogFailure(req.Username, ip, "login", "/api/login", "invalid username") // LEGACY_CODE: Migrate to activity.Log()
 		activity.LogFailure(activity.ActorAnonymous, "", ip, "session", "", "login", "invalid username", activity.WeightAuth)
+		recordLoginFailure(r, req.Username, "invalid username")
 		log.Printf("Login failed: invalid username from %s", ip)
 		api.InvalidCredentials(w)
 		return
@@ -90,6 +93,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		rateLimiter.RecordAttempt(ip)
 		audit.LogFailure(req.Username, ip, "login", "/api/login", "invalid password") // LEGACY_CODE: Migrate to activity.Log()
 		activity.LogFailure(activity.ActorAnonymous, "", ip, "session", "", "login", "invalid password", activity.WeightAuth)
+		recordLoginFailure(r, req.Username, "invalid password")
 		log.Printf("Login failed: invalid password from %s", ip)
 		api.InvalidCredentials(w)
 		return
@@ -103,17 +107,115 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Second factor: no session until the TOTP or recovery code is verified.
+	// When 2FA is required but not yet set up, the challenge token lets the
+	// user enroll via /api/2fa/enroll and /api/2fa/enable.
+	enrolled := authService.TwoFactorEnabled(user.ID)
+	if enrolled || authService.Require2FA() {
+		challenge, err := authService.CreateLoginChallenge(user.ID, req.RememberMe)
+		if err != nil {
+			log.Printf("Failed to create login challenge: %v", err)
+			api.InternalError(w, err)
+			return
+		}
+
+		log.Printf("Login password verified, awaiting second factor: %s from %s", req.Username, ip)
+		api.Success(w, http.StatusOK, map[string]interface{}{
+			"message":               "Two-factor authentication required",
+			"two_factor_required":   true,
+			"two_factor_enrolled":   enrolled,
+			"two_factor_token":      challenge,
+			"two_factor_expires_in": int(auth.LoginChallengeTTL.Seconds()),
+		})
+		return
+	}
+
+	if !completeLogin(w, r, user, req.RememberMe) {
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"message": "Login successful",
+	})
+}
+
+// LoginTwoFactorHandler completes a password login with a TOTP or recovery code
+// POST /api/login/2fa (and /auth/login/2fa) {"token": "...", "code": "123456"}
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.BadRequest(w, "Method not allowed")
+		return
+	}
+
+	ip := realip.FromRequest(r)
+	if !rateLimiter.AllowLogin(ip) {
+		log.Printf("Rate limit exceeded for IP: %s", ip)
+		api.RateLimitExceeded(w, "Too many failed attempts. Please try again in 15 minutes.")
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+		Code  string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		api.MissingField(w, "code")
+		return
+	}
+
+	challenge, err := authService.CompleteLoginChallenge(req.Token, req.Code)
+	if err != nil {
+		switch err {
+		case auth.ErrTOTPNotEnrolled:
+			api.Forbidden(w, "Two-factor authentication must be set up before logging in")
+		case auth.ErrInvalidTOTPCode, auth.ErrInvalidChallenge, auth.ErrTooManyCodeAttempts:
+			rateLimiter.RecordAttempt(ip)
+			activity.LogFailure(activity.ActorAnonymous, "", ip, "session", "", "login", "invalid second factor", activity.WeightAuth)
+			recordLoginFailure(r, "", "invalid second factor")
+			log.Printf("Login failed: invalid second factor from %s", ip)
+			api.Unauthorized(w, err.Error())
+		default:
+			api.InternalError(w, err)
+		}
+		return
+	}
+
+	user, err := authService.GetUserByID(challenge.UserID)
+	if err != nil {
+		api.Unauthorized(w, auth.ErrInvalidChallenge.Error())
+		return
+	}
+
+	if !completeLogin(w, r, user, challenge.RememberMe) {
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"message": "Login successful",
+	})
+}
+
+// completeLogin creates the session, sets the cookie, and logs the login.
+// Writes an error response and returns false on failure.
+func completeLogin(w http.ResponseWriter, r *http.Request, user *auth.User, rememberMe bool) bool {
+	ip := realip.FromRequest(r)
+
 	// Create database session
 	token, err := authService.CreateSession(user.ID)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 		api.InternalError(w, err)
-		return
+		return false
 	}
+	authService.TagSession(token, r)
 
 	// Set session cookie
 	maxAge := int(auth.DefaultSessionTTL.Seconds())
-	if req.RememberMe {
+	if rememberMe {
 		maxAge = int(auth.RememberMeTTL.Seconds())
 	}
 	http.SetCookie(w, authService.SessionCookie(token, maxAge))
@@ -122,13 +224,10 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	rateLimiter.Reset(ip)
 
 	// Log successful login
-	audit.LogSuccess(req.Username, ip, "login", "/api/login") // LEGACY_CODE: Migrate to activity.Log()
+	audit.LogSuccess(user.Name, ip, "login", "/api/login") // LEGACY_CODE: Migrate to activity.Log()
 	activity.LogSuccess(activity.ActorUser, user.ID, ip, "session", "", "login", activity.WeightAuth, nil)
-	log.Printf("Login successful: %s from %s", req.Username, ip)
-
-	api.Success(w, http.StatusOK, map[string]interface{}{
-		"message": "Login successful",
-	})
+	log.Printf("Login successful: %s from %s", user.Name, ip)
+	return true
 }
 
 // LogoutHandler handles logout requests
@@ -141,19 +240,21 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete session from database
-	cookie, err := r.Cookie("fazt_session")
-	if err == nil && cookie.Value != "" {
-		authService.DeleteSession(cookie.Value)
-	}
+	authService.DeleteSession(authService.SessionToken(r))
 
 	// Clear session cookie
 	http.SetCookie(w, authService.ClearSessionCookie())
 
 	// Log logout
 	if username != "" {
-		ip := getClientIP(r)
+		ip := realip.FromRequest(r)
 		audit.LogSuccess(username, ip, "logout", "/api/logout") // LEGACY_CODE: Migrate to activity.Log()
-		activity.LogSuccess(activity.ActorUser, user.ID, ip, "session", "", "logout", activity.WeightAuth, nil)
+		if user.ImpersonatedBy != "" {
+			activity.LogSuccess(activity.ActorUser, user.ImpersonatedBy, ip, "user", user.ID, "impersonate_end", activity.WeightSecurity,
+				map[string]interface{}{"email": user.Email, "sessions": 1})
+		} else {
+			activity.LogSuccess(activity.ActorUser, user.ID, ip, "session", "", "logout", activity.WeightAuth, nil)
+		}
 	}
 
 	// Return success for API requests
@@ -232,11 +333,11 @@ func requireAdminAuth(w http.ResponseWriter, r *http.Request) (role string, ok b
 	return user.Role, true
 }
 
-// UsersListHandler returns a list of all users with pagination
-// Query params: ?offset=0&limit=20
+// UsersListHandler returns a page of users
+// Query params: ?limit=&cursor=&sort=&fields= (see api.ParseList)
 func UsersListHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		api.BadRequest(w, "Method not allowed")
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
 		return
 	}
 
@@ -245,16 +346,18 @@ func UsersListHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse pagination
-	offset, limit := api.ParsePagination(r)
+	q, ok := api.ParseList(w, r, auth.UsersListSpec)
+	if !ok {
+		return
+	}
 
-	users, total, err := authService.ListUsersPaginated(offset, limit)
+	users, err := authService.ListUsersPage(q)
 	if err != nil {
 		api.InternalError(w, err)
 		return
 	}
 
-	api.PaginatedSuccess(w, http.StatusOK, users, offset, limit, total)
+	api.ListSuccess(w, users, q)
 }
 
 // UserSetRoleHandler sets a user's role
@@ -331,6 +434,14 @@ func UserSetRoleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("User role updated: %s (%s) -> %s (by %s)", targetUser.Email, targetUser.ID, req.Role, callerRole)
+	if targetUser.Role != req.Role {
+		recordSecurityEvent(r, security.Event{
+			Type:    security.EventRoleChanged,
+			Actor:   adminActor(r),
+			Subject: targetUser.Email,
+			Details: map[string]interface{}{"user_id": targetUser.ID, "from": targetUser.Role, "to": req.Role},
+		})
+	}
 
 	api.Success(w, http.StatusOK, map[string]interface{}{
 		"message": "Role updated successfully",
@@ -434,29 +545,3 @@ func UserStatusHandler(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 }
-
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// Take the first IP if multiple are present
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	// Remove port if present
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
-	}
-
-	return ip
-}
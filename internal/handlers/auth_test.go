@@ -8,7 +8,10 @@ import (
 
 	"github.com/fazt-sh/fazt/internal/auth"
 	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/security"
 	_ "modernc.org/sqlite"
 )
 
@@ -170,6 +173,40 @@ func TestLoginHandler_InvalidCredentials(t *testing.T) {
 	testutil.CheckError(t, rr, 401, "INVALID_CREDENTIALS")
 }
 
+// TestLoginHandler_RecordsSecurityEvent tests failed logins reach the security event stream
+func TestLoginHandler_RecordsSecurityEvent(t *testing.T) {
+	silenceTestLogs(t)
+	db := setupAuthTestDB(t)
+	database.SetDB(db)
+	t.Cleanup(func() { database.SetDB(nil) })
+	service := auth.NewService(db, "test.local", false)
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(service, limiter, "v0.8.0-test")
+
+	passwordHash, _ := auth.HashPassword("correctpassword")
+	config.SetConfig(&config.Config{
+		Server: config.ServerConfig{Env: "test"},
+		Auth:   config.AuthConfig{Username: "admin", PasswordHash: passwordHash},
+	})
+
+	req := testutil.JSONRequest("POST", "/api/login", map[string]interface{}{
+		"username": "admin",
+		"password": "wrongpassword",
+	})
+	rr := httptest.NewRecorder()
+	LoginHandler(rr, req)
+	testutil.CheckError(t, rr, 401, "INVALID_CREDENTIALS")
+
+	events, err := security.List(db, security.EventLoginFailed, 0, 10)
+	if err != nil {
+		t.Fatalf("Failed to list security events: %v", err)
+	}
+	if len(events) != 1 || events[0].Actor != "admin" || events[0].Details["reason"] != "invalid password" {
+		t.Errorf("Expected one login.failed event for admin, got %+v", events)
+	}
+}
+
 // TestLoginHandler_InvalidUsername tests login with wrong username
 func TestLoginHandler_InvalidUsername(t *testing.T) {
 	silenceTestLogs(t)
@@ -695,6 +732,8 @@ func TestLoginHandler_XForwardedFor(t *testing.T) {
 		},
 	}
 	config.SetConfig(testCfg)
+	realip.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.0.0/16"})
+	t.Cleanup(func() { realip.SetTrustedProxies(nil) })
 
 	// Make failed attempts with X-Forwarded-For header
 	for i := 0; i < 5; i++ {
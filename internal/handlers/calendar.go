@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/services/ics"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// Calendar feed caching
+const (
+	calendarRefresh = time.Hour       // How often subscribers are asked to refetch
+	calendarMaxAge  = 5 * time.Minute // How long proxies and browsers may cache
+)
+
+// ValidateCalendar checks a calendar declaration, as fazt app validate does
+// before a deploy.
+func ValidateCalendar(cal hosting.AppCalendar) error {
+	if strings.TrimSpace(cal.Collection) == "" {
+		return fmt.Errorf(`calendar: "collection" is required`)
+	}
+	return nil
+}
+
+// ServeAppCalendar serves the iCalendar feed an app's manifest.json
+// declares, at /calendar.ics. Events are the documents of the calendar's ds
+// collection matching its where query, most recently created first; ones
+// that aren't valid events (no title or start) are left out rather than
+// breaking the feed for every subscriber.
+func ServeAppCalendar(w http.ResponseWriter, r *http.Request, siteID string) {
+	cal := hosting.AppCalendarFor(siteID)
+	if cal == nil {
+		api.NotFound(w, "CALENDAR_NOT_FOUND", "Calendar not found")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		api.Error(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Calendars only accept GET", nil)
+		return
+	}
+
+	docs := storage.NewSQLDocStore(database.GetDB())
+	found, err := docs.FindWithOptions(r.Context(), siteID, cal.Collection, cal.Where, &storage.FindOptions{Limit: ics.MaxEvents})
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	feed := &ics.Calendar{
+		Name:    cal.Name,
+		Refresh: calendarRefresh,
+		Events:  make([]ics.Event, 0, len(found)),
+	}
+	if feed.Name == "" {
+		feed.Name = siteID
+	}
+	for _, doc := range found {
+		rec := doc.Data
+		if rec["id"] == nil && rec["uid"] == nil {
+			rec["id"] = doc.ID
+		}
+		e, err := ics.ParseEvent(rec, siteID)
+		if err != nil {
+			continue
+		}
+		e.Updated = doc.UpdatedAt
+		feed.Events = append(feed.Events, e)
+	}
+
+	// DTSTAMP comes from each event, so an unchanged feed renders identically
+	body := feed.Render(time.Now())
+	sum := sha256.Sum256([]byte(body))
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(calendarMaxAge.Seconds())))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "calendar.ics", time.Time{}, strings.NewReader(body))
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/canary"
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// CanaryStartRequest is the request body for starting a canary rollout
+type CanaryStartRequest struct {
+	AppID        string  `json:"app_id"`
+	Step         int     `json:"step,omitempty"`           // percent per interval (default 10)
+	Interval     string  `json:"interval,omitempty"`       // Go duration (default 10m)
+	MaxErrorRate float64 `json:"max_error_rate,omitempty"` // 0.0-1.0 (default 0.05)
+}
+
+// AliasCanaryStartHandler starts a canary rollout behind an alias
+// POST /api/aliases/{subdomain}/canary
+func AliasCanaryStartHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	var req CanaryStartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "invalid request body")
+		return
+	}
+
+	if req.AppID == "" {
+		api.BadRequest(w, "app_id is required")
+		return
+	}
+
+	// The rollout repoints the alias to the canary app
+	if !requireAliasChange(w, r, database.GetDB(), subdomain) {
+		return
+	}
+
+	cfg := canary.Config{
+		Subdomain:    subdomain,
+		CanaryAppID:  req.AppID,
+		Step:         req.Step,
+		MaxErrorRate: req.MaxErrorRate,
+	}
+	if req.Interval != "" {
+		d, err := time.ParseDuration(req.Interval)
+		if err != nil {
+			api.BadRequest(w, "invalid interval: "+err.Error())
+			return
+		}
+		cfg.Interval = d
+	}
+
+	c, err := canary.Start(database.GetDB(), cfg)
+	if err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusCreated, c)
+}
+
+// AliasCanaryStatusHandler returns the latest canary for an alias with live stats
+// GET /api/aliases/{subdomain}/canary
+func AliasCanaryStatusHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	db := database.GetDB()
+	c, err := canary.Get(db, subdomain)
+	if err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	result := map[string]interface{}{
+		"canary": c,
+	}
+	if c.Status == canary.StatusRunning {
+		result["stats"] = canary.CurrentStats(db, c)
+		result["next_step_at"] = c.LastStepAt + c.IntervalSeconds
+	}
+
+	api.Success(w, http.StatusOK, result)
+}
+
+// AliasCanaryAbortHandler aborts a running canary and restores the stable app
+// DELETE /api/aliases/{subdomain}/canary
+func AliasCanaryAbortHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	c, err := canary.Abort(database.GetDB(), subdomain)
+	if err != nil {
+		writeCanaryError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, c)
+}
+
+func writeCanaryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, canary.ErrNotFound), errors.Is(err, canary.ErrNotRunning):
+		api.NotFound(w, "CANARY_NOT_FOUND", err.Error())
+	case errors.Is(err, canary.ErrAliasNotFound):
+		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
+	case errors.Is(err, canary.ErrAlreadyRunning):
+		api.Conflict(w, err.Error())
+	case errors.Is(err, canary.ErrAliasNotProxy), errors.Is(err, canary.ErrAppNotFound),
+		errors.Is(err, canary.ErrSameApp), errors.Is(err, canary.ErrInvalidConfig):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/approval"
 	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/hosting"
@@ -44,13 +46,20 @@ func CmdGatewayHandler(w http.ResponseWriter, r *http.Request) {
 		api.Unauthorized(w, "Invalid Authorization format, use: Bearer <token>")
 		return
 	}
+	actor := ""
 	db := database.GetDB()
 	if db != nil {
-		_, _, err := hosting.ValidateAPIKey(db, token)
+		key, err := hosting.AuthenticateAPIKey(db, token)
 		if err != nil {
 			api.InvalidAPIKey(w)
 			return
 		}
+		// Commands act on every app, so the key needs admin scope and an admin user
+		if p, err := principalForAPIKey(db, key); err != nil || !p.unrestricted() || !key.Allows(r.Method, "/api/cmd") {
+			api.Forbidden(w, "Command gateway requires a server API key")
+			return
+		}
+		actor = keyActor(key)
 	}
 
 	var req CmdRequest
@@ -65,7 +74,7 @@ func CmdGatewayHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Route command to appropriate handler
-	result, err := executeCommand(req.Command, req.Args)
+	result, err := executeCommand(actor, req.Command, req.Args)
 	if err != nil {
 		api.Success(w, http.StatusOK, CmdResponse{
 			Success: false,
@@ -80,8 +89,9 @@ func CmdGatewayHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// executeCommand routes a command to the appropriate handler
-func executeCommand(command string, args []string) (interface{}, error) {
+// executeCommand routes a command to the appropriate handler. actor is the
+// caller, recorded on operations queued for approval.
+func executeCommand(actor, command string, args []string) (interface{}, error) {
 	db := database.GetDB()
 	if db == nil {
 		return nil, ErrDatabaseNotInitialized
@@ -89,7 +99,7 @@ func executeCommand(command string, args []string) (interface{}, error) {
 
 	switch command {
 	case "app":
-		return executeAppCommand(args)
+		return executeAppCommand(actor, args)
 	case "server":
 		return executeServerCommand(args)
 	default:
@@ -98,7 +108,7 @@ func executeCommand(command string, args []string) (interface{}, error) {
 }
 
 // executeAppCommand handles app subcommands
-func executeAppCommand(args []string) (interface{}, error) {
+func executeAppCommand(actor string, args []string) (interface{}, error) {
 	if len(args) < 1 {
 		return nil, ErrMissingSubcommand
 	}
@@ -113,7 +123,7 @@ func executeAppCommand(args []string) (interface{}, error) {
 	case "info":
 		return cmdAppInfo(db, subArgs)
 	case "remove":
-		return cmdAppRemove(db, subArgs)
+		return cmdAppRemove(db, actor, subArgs)
 	case "link":
 		return cmdAppLink(db, subArgs)
 	case "unlink":
@@ -297,14 +307,16 @@ func cmdAppInfo(db interface{}, args []string) (interface{}, error) {
 
 	cfg := config.Get()
 	result := map[string]interface{}{
-		"id":         app.ID,
-		"title":      app.Title,
-		"visibility": app.Visibility,
-		"source":     app.Source,
-		"file_count": app.FileCount,
-		"size_bytes": app.SizeBytes,
-		"created_at": app.CreatedAt,
-		"updated_at": app.UpdatedAt,
+		"id":               app.ID,
+		"title":            app.Title,
+		"visibility":       app.Visibility,
+		"source":           app.Source,
+		"file_count":       app.FileCount,
+		"size_bytes":       app.SizeBytes,
+		"protected":        app.Protected,
+		"created_at":       app.CreatedAt,
+		"updated_at":       app.UpdatedAt,
+		"analytics_inject": app.Analytics,
 	}
 
 	if app.Description != "" {
@@ -327,7 +339,7 @@ func cmdAppInfo(db interface{}, args []string) (interface{}, error) {
 	return result, nil
 }
 
-func cmdAppRemove(db interface{}, args []string) (interface{}, error) {
+func cmdAppRemove(db interface{}, actor string, args []string) (interface{}, error) {
 	if len(args) < 1 {
 		return nil, ErrMissingArgument
 	}
@@ -353,6 +365,9 @@ func cmdAppRemove(db interface{}, args []string) (interface{}, error) {
 
 	if useAlias && !useID {
 		// Remove alias only
+		if err := cmdAliasChange(sqlDB, args, identifier); err != nil {
+			return nil, err
+		}
 		_, err := sqlDB.Exec("DELETE FROM aliases WHERE subdomain = ?", identifier)
 		if err != nil {
 			return nil, err
@@ -388,20 +403,42 @@ func cmdAppRemove(db interface{}, args []string) (interface{}, error) {
 	if withForks {
 		rows, _ := sqlDB.Query("SELECT id FROM apps WHERE original_id = ? AND id != ?", appID, appID)
 		if rows != nil {
-			defer rows.Close()
 			for rows.Next() {
 				var id string
 				if rows.Scan(&id) == nil {
 					idsToDelete = append(idsToDelete, id)
 				}
 			}
+			rows.Close()
 		}
 	}
 
+	var reasons []string
 	for _, id := range idsToDelete {
-		sqlDB.Exec("DELETE FROM files WHERE app_id = ?", id)
-		sqlDB.Exec("DELETE FROM apps WHERE id = ?", id)
-		sqlDB.Exec("DELETE FROM aliases WHERE targets LIKE ?", `%"`+id+`"%`)
+		rs, err := appProtection(sqlDB, id)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, rs...)
+	}
+	if len(reasons) > 0 {
+		if err := errProtected(args, strings.Join(reasons, ", "), identifier, appID, title); err != nil {
+			return nil, err
+		}
+	}
+
+	if approval.Required() {
+		return cmdRequestApproval(sqlDB, approval.Request{
+			Operation:   approval.OpAppRemove,
+			Target:      appID,
+			Summary:     appRemovalSummary(appID, title, idsToDelete),
+			Params:      map[string]string{"ids": strings.Join(idsToDelete, ",")},
+			RequestedBy: actor,
+		})
+	}
+
+	if err := deleteApps(sqlDB, idsToDelete); err != nil {
+		return nil, err
 	}
 
 	return map[string]interface{}{
@@ -439,6 +476,10 @@ func cmdAppLink(db interface{}, args []string) (interface{}, error) {
 		return nil, ErrNotFound
 	}
 
+	if err := cmdAliasChange(sqlDB, args, subdomain); err != nil {
+		return nil, err
+	}
+
 	// Create/update alias
 	targets := `{"app_id":"` + appID + `"}`
 	query := `
@@ -471,6 +512,10 @@ func cmdAppUnlink(db interface{}, args []string) (interface{}, error) {
 	sqlDB := database.GetDB()
 	subdomain := args[0]
 
+	if err := cmdAliasChange(sqlDB, args, subdomain); err != nil {
+		return nil, err
+	}
+
 	_, err := sqlDB.Exec("DELETE FROM aliases WHERE subdomain = ?", subdomain)
 	if err != nil {
 		return nil, err
@@ -490,6 +535,10 @@ func cmdAppReserve(db interface{}, args []string) (interface{}, error) {
 	sqlDB := database.GetDB()
 	subdomain := args[0]
 
+	if err := cmdAliasChange(sqlDB, args, subdomain); err != nil {
+		return nil, err
+	}
+
 	query := `
 		INSERT INTO aliases (subdomain, type, targets, created_at, updated_at)
 		VALUES (?, 'reserved', NULL, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
@@ -550,6 +599,15 @@ func cmdAppFork(db interface{}, args []string) (interface{}, error) {
 		return nil, ErrNotFound
 	}
 
+	// Storage copied to the fork is anonymized by the manifest's rules;
+	// broken rules refuse the fork rather than copy real data
+	var anonymize map[string]string
+	if copyStorage && newAlias != "" {
+		if anonymize, err = hosting.AnonymizeRulesFor(sqlDB, sourceApp.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate new ID
 	newID := appid.GenerateApp()
 
@@ -566,23 +624,31 @@ func cmdAppFork(db interface{}, args []string) (interface{}, error) {
 		originalID = sourceApp.ID
 	}
 
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	// Insert forked app
 	query := `
 		INSERT INTO apps (id, original_id, forked_from_id, title, description, tags, visibility, source, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, 'unlisted', 'fork', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 	`
-	_, err = sqlDB.Exec(query, newID, originalID, sourceApp.ID, sourceApp.Title, sourceApp.Description, tagsJSON)
+	_, err = tx.Exec(query, newID, originalID, sourceApp.ID, sourceApp.Title, sourceApp.Description, tagsJSON)
 	if err != nil {
 		return nil, err
 	}
 
-	// Copy files
+	// Copy files, under the fork's ID as (site_id, path) is unique
 	copyQuery := `
 		INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash, created_at, updated_at)
-		SELECT site_id, ?, path, content, size_bytes, mime_type, hash, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
-		FROM files WHERE app_id = ?
+		SELECT ?1, ?1, path, content, size_bytes, mime_type, hash, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM files WHERE app_id = ?2
 	`
-	sqlDB.Exec(copyQuery, newID, sourceApp.ID)
+	if _, err := tx.Exec(copyQuery, newID, sourceApp.ID); err != nil {
+		return nil, err
+	}
 
 	// Copy KV storage
 	if copyStorage {
@@ -591,7 +657,7 @@ func cmdAppFork(db interface{}, args []string) (interface{}, error) {
 			SELECT ?, key, value, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
 			FROM kv_store WHERE site_id = ?
 		`
-		sqlDB.Exec(kvQuery, newID, sourceApp.ID)
+		tx.Exec(kvQuery, newID, sourceApp.ID) // Ignore errors if kv_store doesn't exist
 	}
 
 	// Create alias if specified
@@ -601,7 +667,21 @@ func cmdAppFork(db interface{}, args []string) (interface{}, error) {
 			INSERT INTO aliases (subdomain, type, targets, created_at, updated_at)
 			VALUES (?, 'proxy', ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		`
-		sqlDB.Exec(aliasQuery, newAlias, aliasTargets)
+		if _, err := tx.Exec(aliasQuery, newAlias, aliasTargets); err != nil {
+			return nil, fmt.Errorf("alias %s already exists", newAlias)
+		}
+	}
+
+	// The fork's handlers run as its alias, so its storage goes there
+	var storageCopied int64
+	if copyStorage && newAlias != "" {
+		if storageCopied, err = hosting.CopyForkStorage(tx, forkStorageSite(sourceApp), newAlias, anonymize); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 
 	cfg := config.Get()
@@ -617,6 +697,10 @@ func cmdAppFork(db interface{}, args []string) (interface{}, error) {
 		result["alias"] = newAlias
 		result["url"] = "https://" + newAlias + "." + cfg.Server.Domain
 	}
+	if copyStorage && newAlias != "" {
+		result["storage_copied"] = storageCopied
+		result["anonymized_fields"] = len(anonymize)
+	}
 
 	return result, nil
 }
@@ -228,6 +228,11 @@ func TestCmdGateway_AppInfoByID(t *testing.T) {
 	data := testutil.CheckSuccess(t, rr, 200)
 	testutil.AssertFieldEquals(t, data, "success", true)
 	testutil.AssertFieldExists(t, data, "data")
+
+	// Snippet injection is on unless turned off
+	if dataMap, _ := data["data"].(map[string]interface{}); dataMap["analytics_inject"] != true {
+		t.Errorf("Expected analytics_inject true, got %v", dataMap["analytics_inject"])
+	}
 }
 
 func TestCmdGateway_AppInfoNotFound(t *testing.T) {
@@ -268,3 +273,41 @@ func TestCmdGateway_UnknownCommand(t *testing.T) {
 	testutil.AssertFieldEquals(t, data, "success", false)
 	testutil.AssertFieldExists(t, data, "error")
 }
+
+func TestCmdGateway_AppForkCopiesStorage(t *testing.T) {
+	silenceTestLogs(t)
+	setupTestConfig(t)
+	setupCmdTestDB(t)
+	db := database.GetDB()
+	db.Exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('test-app', 'users', 'u1', '{"plan":"pro"}')`)
+
+	fork := func(alias string) map[string]interface{} {
+		req := testutil.JSONRequest("POST", "/api/cmd", map[string]interface{}{
+			"command": "app",
+			"args":    []string{"fork", "test-alias", "--as", alias},
+		})
+		testutil.WithAuth(req, testCmdAPIKey)
+		rr := httptest.NewRecorder()
+		CmdGatewayHandler(rr, req)
+		return testutil.CheckSuccess(t, rr, 200)
+	}
+
+	// Storage comes from the site the app's handlers run as, whichever
+	// alias named it
+	data := fork("test-exp")
+	testutil.AssertFieldEquals(t, data, "success", true)
+	var docs int
+	db.QueryRow(`SELECT COUNT(*) FROM app_docs WHERE app_id = 'test-exp'`).Scan(&docs)
+	if docs != 1 {
+		t.Errorf("Expected the document copied, got %d", docs)
+	}
+
+	// A taken alias leaves no half-made fork behind
+	data = fork("test-alias")
+	testutil.AssertFieldEquals(t, data, "success", false)
+	var forks int
+	db.QueryRow(`SELECT COUNT(*) FROM apps WHERE forked_from_id = 'app_test123'`).Scan(&forks)
+	if forks != 1 {
+		t.Errorf("Expected only the first fork, got %d", forks)
+	}
+}
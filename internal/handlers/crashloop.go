@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/crashloop"
+)
+
+// CrashLoopListHandler lists apps with recent handler crashes and those
+// isolated for crash-looping
+// GET /api/system/crashloops
+func CrashLoopListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	states := crashloop.List(time.Now())
+	if states == nil {
+		states = []crashloop.State{}
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"apps":      states,
+		"threshold": crashloop.Threshold,
+		"window":    crashloop.Window.String(),
+		"cooldown":  crashloop.Cooldown.String(),
+	})
+}
+
+// CrashLoopResetHandler restores an isolated app without waiting for its
+// cooldown, resolving its incident
+// DELETE /api/system/crashloops/{app}
+func CrashLoopResetHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	app := r.PathValue("app")
+	if err := crashloop.Reset(app); err != nil {
+		if errors.Is(err, crashloop.ErrNotTripped) {
+			api.NotFound(w, "APP_NOT_TRIPPED", err.Error())
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"restored": app,
+	})
+}
@@ -3,33 +3,48 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/auth"
 	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/deploys"
+	"github.com/fazt-sh/fazt/internal/events"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/worker"
 )
 
+// deployTracker holds deploys while they run and their outcome for a while
+// after, for GET /api/deploy/{id}
+var deployTracker = deploys.NewTracker()
+
 // DeployHandler handles site deployments via ZIP upload
 // POST /api/deploy
 // - Multipart form with "file" (ZIP) and "site_name" field
 // - Authorization: Bearer <token> header required
+// - "async" field: "true" answers 202 with a deployment_id once the bundle
+// is received, and GET /api/deploy/{id} reports progress
 func DeployHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		api.BadRequest(w, "Method not allowed")
 		return
 	}
 
+	// Big bundles take longer to arrive than the server's read timeout
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(uploadChunkTimeout))
+
 	// Rate limit: 5 deploys per minute per IP
-	clientIP := r.RemoteAddr
-	if fwdIP := r.Header.Get("X-Forwarded-For"); fwdIP != "" {
-		clientIP = strings.Split(fwdIP, ",")[0]
-	}
+	clientIP := realip.FromRequest(r)
 	limiter := auth.GetDeployLimiter()
 	if !limiter.AllowDeploy(clientIP) {
 		api.RateLimitExceeded(w, "Rate limit exceeded: max 5 deploys per minute")
@@ -50,11 +65,24 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	db := database.GetDB()
-	keyID, keyName, err := hosting.ValidateAPIKey(db, token)
+	key, err := hosting.AuthenticateAPIKey(db, token)
 	if err != nil {
 		api.InvalidAPIKey(w)
 		return
 	}
+	keyName := key.Name
+	if !key.Allows(r.Method, "/api/deploy") {
+		api.Forbidden(w, "API key scope does not allow deploys")
+		return
+	}
+
+	// User-bound and app-scoped keys are limited to their apps
+	principal, err := principalForAPIKey(db, key)
+	if err != nil {
+		api.Unauthorized(w, err.Error())
+		return
+	}
+	r = withAppPrincipal(r, principal)
 
 	// Parse multipart form (max 100MB)
 	if err := r.ParseMultipartForm(100 << 20); err != nil {
@@ -63,29 +91,14 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get site name
-	siteName := r.FormValue("site_name")
-	if siteName == "" {
-		api.BadRequest(w, "Missing site_name field")
+	siteName, err := deploySiteName(r.FormValue("site_name"))
+	if err != nil {
+		api.BadRequest(w, err.Error())
 		return
 	}
 
-	// Smart Domain Handling: Strip root domain if present
-	// Allows "my-site.fazt.sh" -> "my-site"
-	cfg := config.Get()
-	rootDomain := cfg.Server.Domain
-	// Strip scheme if present
-	if idx := strings.Index(rootDomain, "://"); idx != -1 {
-		rootDomain = rootDomain[idx+3:]
-	}
-	// Strip suffix
-	suffix := "." + rootDomain
-	if strings.HasSuffix(strings.ToLower(siteName), suffix) {
-		siteName = siteName[:len(siteName)-len(suffix)]
-	}
-
-	// Validate site name
-	if err := hosting.ValidateSubdomain(siteName); err != nil {
-		api.BadRequest(w, "Invalid site_name: "+err.Error())
+	isNew, ok := requireDeployAccess(w, r, db, siteName)
+	if !ok {
 		return
 	}
 
@@ -118,6 +131,155 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := hosting.CheckDeployLimits(db, siteName, "api_key:"+keyName, zipReader); err != nil {
+		writeDeployError(w, err)
+		return
+	}
+
+	d, err := deployTracker.Start(siteName, "api_key:"+keyName)
+	if err != nil {
+		writeDeployError(w, err)
+		return
+	}
+
+	// Record rate limit
+	limiter.RecordDeploy(clientIP)
+
+	if r.FormValue("async") == "true" {
+		// The request's form and caller outlive it; cancellation doesn't
+		bg := r.Clone(context.WithoutCancel(r.Context()))
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("Deploy %s of %s panicked: %v", d.ID(), siteName, rec)
+					d.Fail(fmt.Errorf("deploy crashed: %v", rec))
+				}
+			}()
+			if result, err := runDeploy(bg, db, siteName, isNew, zipReader, keyName, d); err != nil {
+				log.Printf("Deploy %s of %s failed: %v", d.ID(), siteName, err)
+			} else {
+				log.Printf("Site deployed: %s by %s (key_id=%d, %s), %d files, %d bytes",
+					siteName, keyName, key.ID, d.ID(), result["file_count"], result["size_bytes"])
+			}
+		}()
+
+		api.Success(w, http.StatusAccepted, map[string]interface{}{
+			"deployment_id": d.ID(),
+			"site":          siteName,
+			"status":        deploys.StatusProcessing,
+			"status_url":    "/api/deploy/" + d.ID(),
+		})
+		return
+	}
+
+	result, err := runDeploy(r, db, siteName, isNew, zipReader, keyName, d)
+	if err != nil {
+		writeDeployError(w, err)
+		return
+	}
+
+	log.Printf("Site deployed: %s by %s (key_id=%d), %d files, %d bytes",
+		siteName, keyName, key.ID, result["file_count"], result["size_bytes"])
+
+	api.Success(w, http.StatusOK, result)
+}
+
+// DeployStatusHandler reports a deploy's progress: its stage, the files
+// written so far, and once done the deploy response and the app.deployed
+// hook job, if the app registers one. Only whoever started a deploy (or a
+// server-wide admin) can see it.
+// GET /api/deploy/{id}
+func DeployStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.BadRequest(w, "Method not allowed")
+		return
+	}
+
+	d, ok := deployTracker.Get(strings.TrimPrefix(r.URL.Path, "/api/deploy/"))
+	state := deploys.State{}
+	if ok {
+		state = d.State()
+	}
+	if p := principalFromRequest(r); !ok || (p != nil && !p.unrestricted() && adminActor(r) != state.DeployedBy) {
+		api.NotFound(w, "DEPLOY_NOT_FOUND", "Deploy not found")
+		return
+	}
+
+	resp := map[string]interface{}{"deploy": state}
+	var secretsErr *hosting.SecretsError
+	if errors.As(d.Err(), &secretsErr) {
+		resp["findings"] = secretsErr.Findings
+	}
+	if state.Status == deploys.StatusDone {
+		if hook := deployHookStatus(state); hook != nil {
+			resp["hook"] = hook
+		}
+	}
+	api.Success(w, http.StatusOK, resp)
+}
+
+// deployHookStatus finds the job the app's app.deployed hook runs as, or
+// returns nil when the app has no hook
+func deployHookStatus(state deploys.State) map[string]interface{} {
+	handler := hosting.EventHandler(state.Site, events.AppDeployed)
+	if handler == "" {
+		return nil
+	}
+	hook := map[string]interface{}{"handler": handler, "status": string(worker.StatusPending)}
+	jobs, err := worker.List(state.Site, nil, 20)
+	if err != nil {
+		return hook
+	}
+	for _, job := range jobs {
+		if job.Handler == handler && !job.CreatedAt.Before(state.CreatedAt) {
+			hook["job_id"] = job.ID
+			hook["status"] = string(job.Status)
+			if job.Error != "" {
+				hook["error"] = job.Error
+			}
+			break
+		}
+	}
+	return hook
+}
+
+// deploySiteName validates the site a deploy is for. The root domain may be
+// included: "my-site.fazt.sh" deploys "my-site".
+func deploySiteName(siteName string) (string, error) {
+	if siteName == "" {
+		return "", errors.New("Missing site_name field")
+	}
+
+	// Smart Domain Handling: Strip root domain if present
+	rootDomain := config.Get().Server.Domain
+	// Strip scheme if present
+	if idx := strings.Index(rootDomain, "://"); idx != -1 {
+		rootDomain = rootDomain[idx+3:]
+	}
+	// Strip suffix
+	suffix := "." + rootDomain
+	if strings.HasSuffix(strings.ToLower(siteName), suffix) {
+		siteName = siteName[:len(siteName)-len(suffix)]
+	}
+
+	if err := hosting.ValidateSubdomain(siteName); err != nil {
+		return "", errors.New("Invalid site_name: " + err.Error())
+	}
+	return siteName, nil
+}
+
+// runDeploy deploys a bundle the caller may deploy, applying the options in
+// the request's form (spa, analytics, precompress, source_*), and returns
+// the deploy response. Progress and the outcome are recorded on d.
+func runDeploy(r *http.Request, db *sql.DB, siteName string, isNew bool, zipReader *zip.Reader, deployedBy string, d *deploys.Deploy) (result map[string]interface{}, err error) {
+	defer func() {
+		if err != nil {
+			d.Fail(err)
+		} else {
+			d.Finish(result)
+		}
+	}()
+
 	// Check for source tracking info
 	var source *hosting.SourceInfo
 	sourceType := r.FormValue("source_type")
@@ -131,10 +293,14 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Deploy the site with source tracking
-	result, err := hosting.DeploySiteWithSource(zipReader, siteName, source)
+	deployed, err := hosting.DeploySiteWithProgress(zipReader, siteName, source, d.Progress)
 	if err != nil {
-		api.InternalError(w, err)
-		return
+		return nil, err
+	}
+	d.SetStage(deploys.StageFinishing)
+	logDeploySecrets(deployed)
+	if isNew {
+		grantDeployedAppOwner(r, db, siteName)
 	}
 
 	// Handle SPA flag
@@ -148,23 +314,73 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Handle analytics injection toggle ("true"/"false"; absent keeps current setting)
+	if analyticsFlag := r.FormValue("analytics"); analyticsFlag == "true" || analyticsFlag == "false" {
+		fs := hosting.GetFileSystem()
+		if sqlFS, ok := fs.(*hosting.SQLFileSystem); ok {
+			if err := sqlFS.SetAppAnalyticsInject(siteName, analyticsFlag == "true"); err != nil {
+				log.Printf("Warning: failed to set analytics flag for %s: %v", siteName, err)
+			}
+		}
+	}
+
+	// Precompress static files into .br and .gz variants stored next to them
+	precompressed := 0
+	if r.FormValue("precompress") == "true" {
+		n, err := hosting.PrecompressSite(siteName)
+		if err != nil {
+			log.Printf("Warning: failed to precompress %s: %v", siteName, err)
+		}
+		precompressed = n
+	}
+
 	// Record deployment
-	deployedBy := keyName
-	if err := hosting.RecordDeployment(db, result.SiteID, result.SizeBytes, result.FileCount, deployedBy); err != nil {
+	if err := hosting.RecordDeployment(db, deployed.SiteID, deployed.SizeBytes, deployed.FileCount, deployedBy); err != nil {
 		log.Printf("Failed to record deployment: %v", err)
 	}
 
-	// Record rate limit
-	limiter.RecordDeploy(clientIP)
+	result = map[string]interface{}{
+		"deployment_id": d.ID(),
+		"site":          siteName,
+		"file_count":    deployed.FileCount,
+		"size_bytes":    deployed.SizeBytes,
+		"precompressed": precompressed,
+		"message":       "Deployment successful",
+	}
+	if len(deployed.Secrets) > 0 {
+		result["secrets"] = deployed.Secrets
+	}
+	return result, nil
+}
 
-	log.Printf("Site deployed: %s by %s (key_id=%d), %d files, %d bytes",
-		siteName, keyName, keyID, result.FileCount, result.SizeBytes)
-
-	// Return success response
-	api.Success(w, http.StatusOK, map[string]interface{}{
-		"site":       siteName,
-		"file_count": result.FileCount,
-		"size_bytes": result.SizeBytes,
-		"message":    "Deployment successful",
-	})
+// writeDeployError answers a failed deploy. Deploys refused by the secret
+// scan list what was found; those over a deploy limit say which.
+func writeDeployError(w http.ResponseWriter, err error) {
+	if errors.Is(err, deploys.ErrInProgress) {
+		api.Error(w, http.StatusConflict, "DEPLOY_IN_PROGRESS", err.Error(), nil)
+		return
+	}
+	var limitErr *hosting.DeployLimitError
+	if errors.As(err, &limitErr) {
+		details := map[string]interface{}{"limit": limitErr.Limit, "max": limitErr.Max, "actual": limitErr.Actual}
+		if limitErr.Path != "" {
+			details["path"] = limitErr.Path
+		}
+		api.Error(w, http.StatusRequestEntityTooLarge, "DEPLOY_LIMIT_EXCEEDED", err.Error(), details)
+		return
+	}
+	var secretsErr *hosting.SecretsError
+	if errors.As(err, &secretsErr) {
+		api.Error(w, http.StatusUnprocessableEntity, "SECRETS_FOUND", err.Error(),
+			map[string]interface{}{"findings": secretsErr.Findings})
+		return
+	}
+	api.InternalError(w, err)
+}
+
+// logDeploySecrets warns about likely secrets a deploy went ahead with
+func logDeploySecrets(result *hosting.DeployResult) {
+	for _, f := range result.Secrets {
+		log.Printf("Warning: %s deployed a likely secret: %s", result.SiteID, f)
+	}
 }
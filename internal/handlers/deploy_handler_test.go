@@ -3,6 +3,7 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -15,6 +16,7 @@ import (
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/handlers/testutil"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 var deployIPCounter uint32
@@ -189,6 +191,26 @@ func TestDeployHandler_Success(t *testing.T) {
 	testutil.AssertFieldEquals(t, data, "message", "Deployment successful")
 }
 
+func TestDeployHandler_SecretsBlocked(t *testing.T) {
+	token := setupDeployHandlerTest(t)
+	hosting.SetSecretScanMode(hosting.SecretScanBlock)
+	t.Cleanup(func() { hosting.SetSecretScanMode(hosting.SecretScanWarn) })
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	f, _ := writer.Create(".env")
+	io.WriteString(f, "SECRET=1")
+	writer.Close()
+
+	req, _ := newDeployRequest(t, "leaky", "site.zip", buf.Bytes())
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+
+	DeployHandler(rr, req)
+
+	testutil.CheckError(t, rr, http.StatusUnprocessableEntity, "SECRETS_FOUND")
+}
+
 // TestDeployHandler_RateLimitExhaustion tests deploy rate limiting (5 per minute)
 func TestDeployHandler_RateLimitExhaustion(t *testing.T) {
 	token := setupDeployHandlerTest(t)
@@ -416,6 +438,8 @@ func TestDeployHandler_ZIPWithSpecialCharFilenames(t *testing.T) {
 // TestDeployHandler_XForwardedFor tests IP extraction from X-Forwarded-For header
 func TestDeployHandler_XForwardedFor(t *testing.T) {
 	token := setupDeployHandlerTest(t)
+	realip.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.0.0/16"})
+	t.Cleanup(func() { realip.SetTrustedProxies(nil) })
 
 	// Make 5 deploys with X-Forwarded-For header
 	for i := 0; i < 5; i++ {
@@ -439,3 +463,21 @@ func TestDeployHandler_XForwardedFor(t *testing.T) {
 	DeployHandler(rr, req)
 	testutil.CheckError(t, rr, 429, "RATE_LIMIT_EXCEEDED")
 }
+
+func TestDeployHandler_InProgress(t *testing.T) {
+	token := setupDeployHandlerTest(t)
+
+	d, err := deployTracker.Start("busy-site", "api_key:other")
+	if err != nil {
+		t.Fatalf("Start() failed: %v", err)
+	}
+	t.Cleanup(func() { d.Fail(errors.New("test done")) })
+
+	req, _ := newDeployRequest(t, "busy-site", "site.zip", buildZip(t))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+
+	DeployHandler(rr, req)
+
+	testutil.CheckError(t, rr, http.StatusConflict, "DEPLOY_IN_PROGRESS")
+}
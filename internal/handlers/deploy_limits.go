@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// DeployLimitsListHandler returns the global deploy limits and every app's
+// and deployer's own
+// GET /api/system/deploy-limits
+func DeployLimitsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	overrides, err := hosting.ListDeployLimitOverrides(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"global":    hosting.GlobalDeployLimits(),
+		"overrides": overrides,
+	})
+}
+
+// DeployLimitsSetHandler sets an app's or a deployer's deploy limits.
+// Omitted or zero limits fall back to the deployer's, then the global ones.
+// PUT /api/system/deploy-limits/{scope}/{name}
+func DeployLimitsSetHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req hosting.DeployLimits
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	override, err := hosting.SetDeployLimitOverride(database.GetDB(), r.PathValue("scope"), r.PathValue("name"), req)
+	if err != nil {
+		writeDeployLimitsError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, override)
+}
+
+// DeployLimitsDeleteHandler removes an app's or a deployer's deploy limits
+// DELETE /api/system/deploy-limits/{scope}/{name}
+func DeployLimitsDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	scope, name := r.PathValue("scope"), r.PathValue("name")
+	if err := hosting.DeleteDeployLimitOverride(database.GetDB(), scope, name); err != nil {
+		writeDeployLimitsError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": scope + "/" + name,
+	})
+}
+
+func writeDeployLimitsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, hosting.ErrDeployLimitNotFound):
+		api.NotFound(w, "DEPLOY_LIMIT_NOT_FOUND", err.Error())
+	case errors.Is(err, hosting.ErrInvalidDeployLimit):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
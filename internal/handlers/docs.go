@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/services/openapi"
+)
+
+// docsMaxAge is how long browsers and proxies may cache an app's API docs
+const docsMaxAge = time.Minute
+
+// ValidateDocs checks an API docs declaration, as fazt app validate does
+// before a deploy.
+func ValidateDocs(docs hosting.AppDocs) error {
+	for key, op := range docs.Routes {
+		if _, _, err := openapi.ParseRouteKey(key); err != nil {
+			return err
+		}
+		if err := op.Validate(); err != nil {
+			return fmt.Errorf("docs: %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ServeAppDocs serves the API docs an app's manifest.json turns on: an
+// OpenAPI spec of its file-based routes at /_docs/openapi.json, and a page
+// rendering it at /_docs. Both are built from the deployed files, so they
+// change with each deploy.
+func ServeAppDocs(w http.ResponseWriter, r *http.Request, siteID string) {
+	docs := hosting.AppDocsFor(siteID)
+	if docs == nil {
+		api.NotFound(w, "DOCS_NOT_FOUND", "API docs not found")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		api.Error(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "API docs only accept GET", nil)
+		return
+	}
+
+	var (
+		body        []byte
+		err         error
+		contentType string
+		name        string
+	)
+	spec := appSpec(siteID, docs)
+	switch r.URL.Path {
+	case "/_docs", "/_docs/":
+		body, err = openapi.RenderPage(spec, "/_docs/openapi.json")
+		contentType, name = "text/html; charset=utf-8", "index.html"
+	case "/_docs/openapi.json":
+		body, err = json.MarshalIndent(spec, "", "  ")
+		contentType, name = "application/json", "openapi.json"
+	default:
+		api.NotFound(w, "DOCS_NOT_FOUND", "API docs not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(docsMaxAge.Seconds())))
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+}
+
+// appSpec builds the OpenAPI spec of an app's deployed routes. WASI
+// handlers take every method, so they're only documented where notes
+// describe them.
+func appSpec(siteID string, docs *hosting.AppDocs) *openapi.Spec {
+	var routes []openapi.Route
+	fs := hosting.GetFileSystem()
+	for _, route := range hosting.APIRoutesFor(database.GetDB(), siteID).Routes {
+		if route.WASI {
+			continue
+		}
+		var source string
+		if file, err := fs.ReadFile(siteID, route.File); err == nil {
+			data, _ := io.ReadAll(file.Content)
+			file.Content.Close()
+			source = string(data)
+		}
+		routes = append(routes, openapi.Route{Pattern: route.Pattern, Methods: route.Methods, Source: source})
+	}
+
+	info := openapi.Info{Title: docs.Title, Version: docs.Version, Description: docs.Description}
+	if info.Title == "" {
+		info.Title = siteID
+	}
+	return openapi.Build(info, routes, docs.Routes)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/services/openapi"
+)
+
+func setupDocsTest(t *testing.T, files map[string]string) {
+	t.Helper()
+	setupSiteFilesTest(t)
+	for path, content := range files {
+		if err := hosting.GetFileSystem().WriteFile("docs-site", path,
+			strings.NewReader(content), int64(len(content)), "application/octet-stream"); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+}
+
+func getDocs(method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://docs-site.example.test"+path, nil)
+	resp := httptest.NewRecorder()
+	ServeAppDocs(resp, req, "docs-site")
+	return resp
+}
+
+func TestServeAppDocs(t *testing.T) {
+	setupDocsTest(t, map[string]string{
+		"manifest.json": `{"docs": {"title": "Shop API", "routes": {"GET /api/items": {"tags": ["items"]}}}}`,
+		"api/items/index.js": `
+/**
+ * List items
+ * @query {number} [limit=20] - Max items
+ */
+exports.GET = function(request) {};
+exports.POST = function(request) {};`,
+		"api/_lib/db.js": `exports.GET = function() {};`,
+	})
+
+	resp := getDocs("GET", "/_docs/openapi.json")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected the spec, got %d %s: %s", resp.Code, resp.Header().Get("Content-Type"), resp.Body.String())
+	}
+	var spec struct {
+		Info  map[string]string                            `json:"info"`
+		Paths map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Invalid spec: %v", err)
+	}
+	if spec.Info["title"] != "Shop API" {
+		t.Errorf("title = %q", spec.Info["title"])
+	}
+	items := spec.Paths["/api/items"]
+	if len(spec.Paths) != 1 || items["get"]["summary"] != "List items" || items["post"] == nil {
+		t.Errorf("paths = %+v", spec.Paths)
+	}
+	if tags, _ := items["get"]["tags"].([]interface{}); len(tags) != 1 || tags[0] != "items" {
+		t.Errorf("Expected the manifest's tags, got %v", items["get"]["tags"])
+	}
+
+	resp = getDocs("GET", "/_docs")
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "List items") {
+		t.Errorf("Expected the docs page, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp := getDocs("POST", "/_docs"); resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: expected 405, got %d", resp.Code)
+	}
+	if resp := getDocs("GET", "/_docs/other"); resp.Code != http.StatusNotFound {
+		t.Errorf("Unknown path: expected 404, got %d", resp.Code)
+	}
+}
+
+func TestServeAppDocs_NotDeclared(t *testing.T) {
+	setupDocsTest(t, map[string]string{"manifest.json": `{"name": "docs-site"}`})
+	if resp := getDocs("GET", "/_docs"); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without \"docs\", got %d", resp.Code)
+	}
+}
+
+func TestValidateDocs(t *testing.T) {
+	valid := hosting.AppDocs{Routes: map[string]openapi.Operation{"GET /api/items": {Summary: "List"}}}
+	if err := ValidateDocs(valid); err != nil {
+		t.Errorf("ValidateDocs: %v", err)
+	}
+	for _, routes := range []map[string]openapi.Operation{
+		{"/api/items": {}},
+		{"GET /api/items": {Responses: map[string]string{"ok": "fine"}}},
+	} {
+		if err := ValidateDocs(hosting.AppDocs{Routes: routes}); err == nil {
+			t.Errorf("%v: expected an error", routes)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/failover"
+)
+
+// failoverController replicates to the standby on demand
+var failoverController *failover.Controller
+
+// InitFailover sets the controller that replicates to the standby
+func InitFailover(c *failover.Controller) {
+	failoverController = c
+}
+
+// FailoverRoleRequest is the body of PUT /api/failover/role
+type FailoverRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// FailoverStandbyRequest is the body of PUT /api/failover/standby
+type FailoverStandbyRequest struct {
+	URL   string `json:"url"`   // The standby's admin URL; empty stops replicating
+	Token string `json:"token"` // An API key of the standby's
+}
+
+// FailoverDNSRequest is the body of PUT /api/failover/dns
+type FailoverDNSRequest struct {
+	Provider string            `json:"provider"` // Empty removes the DNS settings
+	Settings map[string]string `json:"settings"`
+	Records  []string          `json:"records"`
+	IP       string            `json:"ip"`
+}
+
+// FailoverStatusHandler shows this server's role, its standby and the last
+// replica sent or received
+// GET /api/failover
+func FailoverStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	s, err := failover.Get(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, s)
+}
+
+// FailoverRoleHandler makes this server a primary or a standby
+// PUT /api/failover/role {"role": "standby"}
+func FailoverRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	var req FailoverRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if err := failover.SetRole(database.GetDB(), req.Role); err != nil {
+		writeFailoverError(w, err, "role")
+		return
+	}
+	activity.LogFromRequest(r, adminActor(r), "failover", req.Role, "role", activity.WeightConfig, nil)
+	FailoverStatusHandler(w, r)
+}
+
+// FailoverStandbyHandler sets the peer this server replicates its apps to
+// PUT /api/failover/standby {"url": "https://admin.backup.example.com", "token": "..."}
+func FailoverStandbyHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	var req FailoverStandbyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if err := failover.SetStandby(database.GetDB(), req.URL, req.Token); err != nil {
+		writeFailoverError(w, err, "url")
+		return
+	}
+	activity.LogFromRequest(r, adminActor(r), "failover", req.URL, "standby", activity.WeightConfig, nil)
+	FailoverStatusHandler(w, r)
+}
+
+// FailoverDNSHandler sets the DNS records this standby points at itself
+// when promoted
+// PUT /api/failover/dns {"provider": "cloudflare", "settings": {...}, "records": [...], "ip": "..."}
+func FailoverDNSHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	var req FailoverDNSRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	cfg := failover.DNSConfig{Provider: req.Provider, Settings: req.Settings, Records: req.Records, IP: req.IP}
+	if err := failover.SetDNS(database.GetDB(), cfg); err != nil {
+		writeFailoverError(w, err, "dns")
+		return
+	}
+	activity.LogFromRequest(r, adminActor(r), "failover", req.Provider, "dns", activity.WeightConfig, nil)
+	FailoverStatusHandler(w, r)
+}
+
+// FailoverSyncHandler sends the standby a replica now, changed or not
+// POST /api/failover/sync
+func FailoverSyncHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	if failoverController == nil {
+		api.ServiceUnavailable(w, "failover is not running")
+		return
+	}
+	sent, err := failoverController.Sync(r.Context(), true)
+	if err != nil {
+		api.Error(w, http.StatusBadGateway, "SYNC_FAILED", err.Error(), nil)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"sent_bytes": sent,
+	})
+}
+
+// FailoverReplicaHandler receives a gzipped replica from the primary and
+// applies it. Only a standby accepts replicas.
+// POST /api/failover/replica
+func FailoverReplicaHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	result, err := failover.Receive(database.GetDB(), config.Get().Database.Path, r.Body)
+	if err != nil {
+		writeFailoverError(w, err, "")
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"apps":  result.Apps,
+		"files": result.Files,
+		"kv":    result.KV,
+		"docs":  result.Docs,
+		"blobs": result.Blobs,
+	})
+}
+
+// FailoverPromoteHandler makes this standby the primary and points its DNS
+// records at it
+// POST /api/failover/promote
+func FailoverPromoteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	// DNS updates carry on if the caller goes away
+	records, err := failover.Promote(context.WithoutCancel(r.Context()), database.GetDB())
+	if err != nil && records == nil {
+		writeFailoverError(w, err, "")
+		return
+	}
+	activity.LogFromRequest(r, adminActor(r), "failover", "primary", "promote", activity.WeightSecurity, nil)
+	data := map[string]interface{}{
+		"role":    failover.RolePrimary,
+		"records": records,
+	}
+	if err != nil {
+		data["dns_error"] = err.Error()
+	}
+	api.Success(w, http.StatusOK, data)
+}
+
+// writeFailoverError maps failover errors to API errors
+func writeFailoverError(w http.ResponseWriter, err error, field string) {
+	switch {
+	case errors.Is(err, failover.ErrNotStandby):
+		api.Conflict(w, err.Error())
+	case errors.Is(err, failover.ErrInvalid) && field == "":
+		api.BadRequest(w, err.Error())
+	case errors.Is(err, failover.ErrInvalid):
+		api.ValidationError(w, err.Error(), field, "format")
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/middleware"
+	"github.com/fazt-sh/fazt/internal/notify"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/storage"
+
+	"golang.org/x/time/rate"
+)
+
+// Form submission limits
+const (
+	formMaxBytes  = 64 * 1024 // Request body
+	formMaxFields = 50        // Fields per submission
+	formMaxLinks  = 3         // Links before a submission is flagged as spam
+
+	defaultFormCollection = "forms"
+	defaultFormHoneypot   = "_gotcha"
+)
+
+// formLimiter allows each client 5 submissions to an app in a burst, then
+// one a minute
+var formLimiter = middleware.NewRateLimiter(rate.Every(time.Minute), 5)
+
+// formNamePattern matches the names forms can be posted to
+var formNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// formLinkMarkup matches the link syntax of forum and comment spam
+var formLinkMarkup = regexp.MustCompile(`(?i)\[url=|<a\s+href`)
+
+// ValidateForm checks a form declaration, as fazt app validate does before
+// a deploy.
+func ValidateForm(name string, form hosting.AppForm) error {
+	if !formNamePattern.MatchString(name) {
+		return fmt.Errorf("form %q: name may only contain letters, digits, - and _", name)
+	}
+	if form.Redirect != "" && !validFormRedirect(form.Redirect) {
+		return fmt.Errorf("form %q: redirect must be a path like /thanks.html or an http(s) URL", name)
+	}
+	for _, origin := range form.Origins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return fmt.Errorf("form %q: origin %q must look like https://example.com", name, origin)
+		}
+	}
+	if len(form.Fields) > 0 {
+		for _, field := range form.Required {
+			if !containsString(form.Fields, field) {
+				return fmt.Errorf("form %q: required field %q isn't in fields", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+// ServeAppForm takes a submission to a form an app's manifest.json declares,
+// at /__forms/<name>. Posts must come from the app's own pages or an origin
+// the form allows. Submissions are stored in the app's ds collection, spam
+// flagged; the rest emit form.submitted and, if the form asks, notify the
+// admin. Browsers are redirected, JSON clients get JSON.
+func ServeAppForm(w http.ResponseWriter, r *http.Request, siteID string) {
+	name := strings.TrimPrefix(r.URL.Path, "/__forms/")
+	form, ok := hosting.AppForms(siteID)[name]
+	if !ok {
+		api.NotFound(w, "FORM_NOT_FOUND", "Form not found")
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodOptions {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		api.Error(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Forms only accept POST", nil)
+		return
+	}
+
+	origin, allowed := formOrigin(r, form)
+	if !allowed {
+		api.Forbidden(w, "Submissions from this origin aren't allowed")
+		return
+	}
+	if origin != "" && !sameOrigin(origin, r.Host) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", "POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if ok, wait := formLimiter.Reserve(siteID + "/" + realip.FromRequest(r)); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		api.RateLimitExceeded(w, "Too many submissions. Please try again later.")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, formMaxBytes)
+	values, err := parseFormSubmission(r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		api.PayloadTooLarge(w, "64KB")
+		return
+	}
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	wantsJSON := isJSONContent(r) || strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	// Bots fill in every field, including the one hidden from people. Let
+	// them think it worked.
+	honeypot := form.Honeypot
+	if honeypot == "" {
+		honeypot = defaultFormHoneypot
+	}
+	if strings.TrimSpace(values[honeypot]) != "" {
+		formSubmitted(w, r, form, wantsJSON)
+		return
+	}
+
+	fields := make(map[string]interface{})
+	for key, value := range values {
+		if strings.HasPrefix(key, "_") || key == honeypot {
+			continue
+		}
+		if len(form.Fields) > 0 && !containsString(form.Fields, key) {
+			continue
+		}
+		fields[key] = value
+	}
+	for _, field := range form.Required {
+		if strings.TrimSpace(values[field]) == "" {
+			api.ValidationError(w, fmt.Sprintf("%s is required", field), field, "required")
+			return
+		}
+	}
+	if email := strings.TrimSpace(values["email"]); email != "" && fields["email"] != nil {
+		if _, err := mail.ParseAddress(email); err != nil {
+			api.ValidationError(w, "email must be an email address", "email", "email")
+			return
+		}
+	}
+
+	reasons := formSpamReasons(values)
+	doc := map[string]interface{}{
+		"form":         name,
+		"fields":       fields,
+		"spam":         len(reasons) > 0,
+		"origin":       origin,
+		"referrer":     r.Referer(),
+		"user_agent":   r.UserAgent(),
+		"submitted_at": time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(reasons) > 0 {
+		doc["spam_reasons"] = reasons
+	}
+
+	collection := form.Collection
+	if collection == "" {
+		collection = defaultFormCollection
+	}
+	docs := storage.NewSQLDocStoreWithWriter(database.GetDB(), storage.GetWriter())
+	id, err := docs.Insert(r.Context(), siteID, collection, doc)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	if len(reasons) == 0 {
+		events.Emit(events.FormSubmitted, siteID, map[string]interface{}{
+			"form":       name,
+			"id":         id,
+			"collection": collection,
+			"fields":     fields,
+		})
+		if form.Notify {
+			go notify.Send(notify.EventFormSubmitted, fmt.Sprintf("%s form on %s", name, siteID), formSummary(fields))
+		}
+	}
+
+	formSubmitted(w, r, form, wantsJSON)
+}
+
+// formSubmitted answers a submission: JSON for scripts, the form's redirect
+// or a thank-you page for browsers
+func formSubmitted(w http.ResponseWriter, r *http.Request, form hosting.AppForm, wantsJSON bool) {
+	if wantsJSON {
+		api.Success(w, http.StatusOK, map[string]interface{}{"submitted": true})
+		return
+	}
+	if form.Redirect != "" && validFormRedirect(form.Redirect) {
+		http.Redirect(w, r, form.Redirect, http.StatusSeeOther)
+		return
+	}
+
+	back := "/"
+	if ref := r.Referer(); ref != "" && sameOrigin(ref, r.Host) {
+		back = ref
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html>
+<head><title>Thanks!</title><meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; text-align: center; padding: 40px;">
+<h1>Thanks!</h1>
+<p>Your submission was received.</p>
+<p><a href="%s">Go back</a></p>
+</body></html>`, html.EscapeString(back))
+}
+
+// formOrigin returns the origin a submission was posted from, taken from
+// Origin or failing that Referer, and whether the form accepts it
+func formOrigin(r *http.Request, form hosting.AppForm) (string, bool) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		u, err := url.Parse(r.Referer())
+		if err != nil || u.Host == "" {
+			return "", false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	if sameOrigin(origin, r.Host) {
+		return origin, true
+	}
+	for _, allowed := range form.Origins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return origin, true
+		}
+	}
+	return origin, false
+}
+
+// sameOrigin reports whether rawURL points at host
+func sameOrigin(rawURL, host string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, host)
+}
+
+// validFormRedirect reports whether target is a path on the app or an
+// http(s) URL, so a form can't redirect to javascript: and the like
+func validFormRedirect(target string) bool {
+	if strings.HasPrefix(target, "/") {
+		return !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\")
+	}
+	u, err := url.Parse(target)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// parseFormSubmission reads a JSON, urlencoded or multipart submission into
+// field values. Files aren't accepted.
+func parseFormSubmission(r *http.Request) (map[string]string, error) {
+	values := make(map[string]string)
+
+	if isJSONContent(r) {
+		var body map[string]interface{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(&body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, errors.New("Invalid JSON body")
+		}
+		for key, value := range body {
+			switch v := value.(type) {
+			case string:
+				values[key] = v
+			case json.Number, bool:
+				values[key] = fmt.Sprint(v)
+			case nil:
+				values[key] = ""
+			default:
+				return nil, fmt.Errorf("%s must be a string, number or boolean", key)
+			}
+		}
+	} else {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/form-data" {
+			if err := r.ParseMultipartForm(formMaxBytes); err != nil {
+				return nil, formParseError(err)
+			}
+			if len(r.MultipartForm.File) > 0 {
+				return nil, errors.New("Forms don't accept file uploads")
+			}
+		} else if err := r.ParseForm(); err != nil {
+			return nil, formParseError(err)
+		}
+		for key, vals := range r.PostForm {
+			values[key] = strings.Join(vals, ", ")
+		}
+	}
+
+	if len(values) > formMaxFields {
+		return nil, fmt.Errorf("Too many fields (max %d)", formMaxFields)
+	}
+	return values, nil
+}
+
+// formParseError passes an oversized body through and hides parser details
+func formParseError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return err
+	}
+	return errors.New("Invalid form body")
+}
+
+// isJSONContent reports whether a request's body is JSON
+func isJSONContent(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// formSpamReasons returns why a submission looks like spam, if it does
+func formSpamReasons(values map[string]string) []string {
+	var reasons []string
+	links := 0
+	markup := false
+	for _, value := range values {
+		lower := strings.ToLower(value)
+		links += strings.Count(lower, "http://") + strings.Count(lower, "https://")
+		markup = markup || formLinkMarkup.MatchString(value)
+	}
+	if links > formMaxLinks {
+		reasons = append(reasons, fmt.Sprintf("%d links", links))
+	}
+	if markup {
+		reasons = append(reasons, "link markup")
+	}
+	return reasons
+}
+
+// formSummary lists a submission's fields for a notification
+func formSummary(fields map[string]interface{}) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := fmt.Sprint(fields[key])
+		if len(value) > 200 {
+			value = value[:200] + "..."
+		}
+		lines = append(lines, key+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/middleware"
+
+	"golang.org/x/time/rate"
+)
+
+const formsTestManifest = `{
+  "forms": {
+    "contact": {"fields": ["name", "email", "message"], "required": ["email"], "redirect": "/thanks.html"},
+    "feedback": {"collection": "feedback", "origins": ["https://example.com"]}
+  }
+}`
+
+func setupFormsTest(t *testing.T) {
+	t.Helper()
+	setupSiteFilesTest(t)
+
+	if err := hosting.GetFileSystem().WriteFile("forms-site", "manifest.json",
+		strings.NewReader(formsTestManifest), int64(len(formsTestManifest)), "application/json"); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	limiter := formLimiter
+	formLimiter = middleware.NewRateLimiter(rate.Inf, 1)
+	t.Cleanup(func() { formLimiter = limiter })
+}
+
+func postForm(name string, values url.Values, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "http://forms-site.example.test/__forms/"+name, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	resp := httptest.NewRecorder()
+	ServeAppForm(resp, req, "forms-site")
+	return resp
+}
+
+// formDocs returns the documents stored in a collection of forms-site
+func formDocs(t *testing.T, collection string) []map[string]interface{} {
+	t.Helper()
+	rows, err := database.GetDB().Query(`SELECT data FROM app_docs WHERE app_id = ? AND collection = ?`, "forms-site", collection)
+	if err != nil {
+		t.Fatalf("Failed to query docs: %v", err)
+	}
+	defer rows.Close()
+
+	var docs []map[string]interface{}
+	for rows.Next() {
+		var data string
+		rows.Scan(&data)
+		var doc map[string]interface{}
+		json.Unmarshal([]byte(data), &doc)
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestServeAppForm_Stores(t *testing.T) {
+	setupFormsTest(t)
+
+	resp := postForm("contact", url.Values{
+		"name":    {"Ada"},
+		"email":   {"ada@example.com"},
+		"message": {"Hello"},
+		"extra":   {"not declared"},
+	}, "http://forms-site.example.test")
+
+	if resp.Code != http.StatusSeeOther || resp.Header().Get("Location") != "/thanks.html" {
+		t.Fatalf("Expected a redirect to /thanks.html, got %d %s. Body: %s", resp.Code, resp.Header().Get("Location"), resp.Body.String())
+	}
+
+	docs := formDocs(t, "forms")
+	if len(docs) != 1 {
+		t.Fatalf("Expected 1 stored submission, got %d", len(docs))
+	}
+	fields, _ := docs[0]["fields"].(map[string]interface{})
+	if docs[0]["form"] != "contact" || docs[0]["spam"] != false || fields["email"] != "ada@example.com" || fields["extra"] != nil {
+		t.Errorf("Unexpected submission: %+v", docs[0])
+	}
+}
+
+func TestServeAppForm_JSON(t *testing.T) {
+	setupFormsTest(t)
+
+	req := httptest.NewRequest("POST", "http://forms-site.example.test/__forms/feedback", strings.NewReader(`{"rating": 5, "comment": "Great"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "https://example.com")
+	resp := httptest.NewRecorder()
+	ServeAppForm(resp, req, "forms-site")
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. Body: %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("Expected the allowed origin in CORS headers, got %q", resp.Header().Get("Access-Control-Allow-Origin"))
+	}
+	docs := formDocs(t, "feedback")
+	if len(docs) != 1 || docs[0]["fields"].(map[string]interface{})["rating"] != "5" {
+		t.Errorf("Unexpected submissions: %+v", docs)
+	}
+}
+
+func TestServeAppForm_Rejects(t *testing.T) {
+	setupFormsTest(t)
+
+	tests := []struct {
+		name   string
+		form   string
+		values url.Values
+		origin string
+		want   int
+	}{
+		{"undeclared form", "missing", url.Values{"email": {"a@example.com"}}, "http://forms-site.example.test", http.StatusNotFound},
+		{"other origin", "contact", url.Values{"email": {"a@example.com"}}, "https://evil.example", http.StatusForbidden},
+		{"no origin", "contact", url.Values{"email": {"a@example.com"}}, "", http.StatusForbidden},
+		{"missing required", "contact", url.Values{"name": {"Ada"}}, "http://forms-site.example.test", http.StatusBadRequest},
+		{"invalid email", "contact", url.Values{"email": {"not an email"}}, "http://forms-site.example.test", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := postForm(tt.form, tt.values, tt.origin); resp.Code != tt.want {
+				t.Errorf("Expected %d, got %d. Body: %s", tt.want, resp.Code, resp.Body.String())
+			}
+		})
+	}
+	if docs := formDocs(t, "forms"); len(docs) != 0 {
+		t.Errorf("Expected nothing stored, got %d submissions", len(docs))
+	}
+}
+
+func TestServeAppForm_Spam(t *testing.T) {
+	setupFormsTest(t)
+
+	// A filled honeypot looks like it worked but isn't stored
+	resp := postForm("contact", url.Values{"email": {"bot@example.com"}, "_gotcha": {"x"}}, "http://forms-site.example.test")
+	if resp.Code != http.StatusSeeOther {
+		t.Errorf("Expected the honeypot to get a redirect, got %d", resp.Code)
+	}
+	if docs := formDocs(t, "forms"); len(docs) != 0 {
+		t.Fatalf("Expected the honeypot submission to be dropped, got %d", len(docs))
+	}
+
+	// Link spam is kept, flagged
+	resp = postForm("contact", url.Values{
+		"email":   {"spam@example.com"},
+		"message": {`Cheap <a href="https://spam.example">pills</a>`},
+	}, "http://forms-site.example.test")
+	if resp.Code != http.StatusSeeOther {
+		t.Errorf("Expected a redirect, got %d", resp.Code)
+	}
+	docs := formDocs(t, "forms")
+	if len(docs) != 1 || docs[0]["spam"] != true || docs[0]["spam_reasons"] == nil {
+		t.Errorf("Expected the submission flagged as spam, got %+v", docs)
+	}
+}
+
+func TestValidateForm(t *testing.T) {
+	valid := hosting.AppForm{Fields: []string{"email"}, Required: []string{"email"}, Redirect: "/thanks.html", Origins: []string{"https://example.com"}}
+	if err := ValidateForm("contact", valid); err != nil {
+		t.Errorf("Expected a valid form, got %v", err)
+	}
+
+	invalid := map[string]hosting.AppForm{
+		"contact/us": {},
+		"redirect":   {Redirect: "javascript:alert(1)"},
+		"relative":   {Redirect: "//evil.example"},
+		"origin":     {Origins: []string{"example.com"}},
+		"required":   {Fields: []string{"name"}, Required: []string{"email"}},
+	}
+	for name, form := range invalid {
+		if err := ValidateForm(name, form); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/freeze"
+)
+
+// During a freeze window, deploys and alias changes to the apps it covers are
+// refused unless marked as an emergency (emergency=true, or --emergency on
+// the command gateway). Emergency changes go through and are audited.
+
+// FreezesListHandler lists freeze windows and whether they are in effect
+// GET /api/freezes
+func FreezesListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	windows, err := freeze.List(database.GetDB(), time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"freezes": windows,
+	})
+}
+
+// FreezeCreateHandler defines a new freeze window
+// POST /api/freezes
+func FreezeCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req freeze.Config
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	window, err := freeze.Create(database.GetDB(), req, time.Now())
+	if err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "freeze", strconv.FormatInt(window.ID, 10), "create", activity.WeightConfig,
+		map[string]interface{}{"name": window.Name, "schedule": window.Schedule, "tags": window.Tags})
+
+	api.Success(w, http.StatusCreated, window)
+}
+
+// FreezeDeleteHandler removes a freeze window
+// DELETE /api/freezes/{id}
+func FreezeDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid freeze window id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := freeze.Delete(database.GetDB(), id); err != nil {
+		writeFreezeError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "freeze", strconv.FormatInt(id, 10), "delete", activity.WeightConfig, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeFreezeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, freeze.ErrWindowNotFound):
+		api.NotFound(w, "FREEZE_NOT_FOUND", err.Error())
+	case errors.Is(err, freeze.ErrNameTaken):
+		api.Conflict(w, err.Error())
+	case errors.Is(err, freeze.ErrNameRequired), errors.Is(err, freeze.ErrInvalidSchedule),
+		errors.Is(err, freeze.ErrInvalidDuration):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
+
+// activeFreeze returns the freeze window in effect for any of the apps, and
+// the app it freezes
+func activeFreeze(db *sql.DB, appIDs []string, now time.Time) (*freeze.Window, string, error) {
+	for _, appID := range appIDs {
+		var tagsJSON sql.NullString
+		err := db.QueryRow(`SELECT tags FROM apps WHERE id = ?`, appID).Scan(&tagsJSON)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		var tags []string
+		json.Unmarshal([]byte(tagsJSON.String), &tags)
+
+		window, err := freeze.Active(db, tags, now)
+		if err != nil || window != nil {
+			return window, appID, err
+		}
+	}
+	return nil, "", nil
+}
+
+func frozenMessage(window *freeze.Window, appID string) string {
+	until := time.Unix(*window.ActiveUntil, 0).Format("Mon Jan 2 15:04")
+	return fmt.Sprintf("app %s is frozen by %q until %s", appID, window.Name, until)
+}
+
+// requireUnfrozen checks a request may deploy to or change the routing of
+// apps. During a freeze it must be marked emergency=true, and is audited.
+func requireUnfrozen(w http.ResponseWriter, r *http.Request, db *sql.DB, change string, appIDs ...string) bool {
+	window, appID, err := activeFreeze(db, appIDs, time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	if window == nil {
+		return true
+	}
+
+	if r.FormValue("emergency") != "true" {
+		api.Error(w, http.StatusLocked, "FROZEN", frozenMessage(window, appID)+"; retry with emergency=true if this can't wait",
+			map[string]interface{}{"freeze": window.Name, "until": *window.ActiveUntil})
+		return false
+	}
+
+	actor := adminActor(r)
+	log.Printf("Emergency change during freeze %q: %s on %s by %s", window.Name, change, appID, actor)
+	activity.LogFromRequest(r, actor, "app", appID, "emergency_"+change, activity.WeightSecurity,
+		map[string]interface{}{"freeze": window.Name, "until": *window.ActiveUntil})
+	return true
+}
+
+// aliasApps returns the apps an alias routes to
+func aliasApps(db *sql.DB, subdomain string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT a.id FROM apps a JOIN aliases al ON al.subdomain = ?
+		WHERE al.targets LIKE '%"' || a.id || '"%'
+	`, subdomain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// requireAliasUnfrozen checks a request may repoint or remove an alias
+// routing to apps under a freeze
+func requireAliasUnfrozen(w http.ResponseWriter, r *http.Request, db *sql.DB, subdomain string) bool {
+	ids, err := aliasApps(db, subdomain)
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	return requireUnfrozen(w, r, db, "alias_change", ids...)
+}
+
+// errFrozen is the command gateway's equivalent of requireUnfrozen
+func errFrozen(db *sql.DB, args []string, change string, appIDs ...string) error {
+	window, appID, err := activeFreeze(db, appIDs, time.Now())
+	if err != nil || window == nil {
+		return err
+	}
+
+	emergency := false
+	for _, arg := range args {
+		if arg == "--emergency" {
+			emergency = true
+		}
+	}
+	if !emergency {
+		return cmdError(frozenMessage(window, appID) + "; rerun with --emergency if this can't wait")
+	}
+
+	log.Printf("Emergency change during freeze %q: %s on %s via command gateway (%s)", window.Name, change, appID, strings.Join(args, " "))
+	activity.LogSuccess(activity.ActorAPIKey, "", "", "app", appID, "emergency_"+change, activity.WeightSecurity,
+		map[string]interface{}{"freeze": window.Name, "until": *window.ActiveUntil, "via": "cmd"})
+	return nil
+}
+
+// cmdAliasUnfrozen checks a gateway command may repoint or remove an alias
+func cmdAliasUnfrozen(db *sql.DB, args []string, subdomain string) error {
+	ids, err := aliasApps(db, subdomain)
+	if err != nil {
+		return err
+	}
+	return errFrozen(db, args, "alias_change", ids...)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/freeze"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+// freezeNow adds a window in effect for the next hour
+func freezeNow(t *testing.T, name string, tags ...string) {
+	t.Helper()
+	_, err := freeze.Create(database.GetDB(), freeze.Config{
+		Name:            name,
+		Schedule:        "* * * * *",
+		DurationMinutes: 60,
+		Tags:            tags,
+	}, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create freeze window: %v", err)
+	}
+}
+
+func TestAliasDelete_Frozen(t *testing.T) {
+	token := setupAliasTest(t)
+	demoID := "app_" + testutil.RandStr(8)
+	otherID := "app_" + testutil.RandStr(8)
+	createAppForAlias(t, demoID)
+	createAppForAlias(t, otherID)
+	database.GetDB().Exec(`UPDATE apps SET tags = '["demo"]' WHERE id = ?`, demoID)
+	createAliasProxy(t, "keynote", demoID)
+	createAliasProxy(t, "scratch", otherID)
+	freezeNow(t, "demo-day", "demo")
+
+	resp := deleteAlias(token, "keynote", "")
+	testutil.CheckError(t, resp, http.StatusLocked, "FROZEN")
+	if !strings.Contains(resp.Body.String(), "demo-day") {
+		t.Errorf("Expected the freeze window in the error, got %s", resp.Body.String())
+	}
+
+	// Apps without the tag aren't frozen
+	testutil.CheckSuccess(t, deleteAlias(token, "scratch", ""), http.StatusOK)
+
+	testutil.CheckSuccess(t, deleteAlias(token, "keynote", "?emergency=true"), http.StatusOK)
+}
+
+func TestCmdAppLink_Frozen(t *testing.T) {
+	setupCmdTestDB(t)
+	freezeNow(t, "launch")
+
+	if _, err := cmdAppUnlink(nil, []string{"test-alias"}); err == nil || !strings.Contains(err.Error(), "--emergency") {
+		t.Fatalf("Expected unlink during a freeze to fail, got %v", err)
+	}
+	if _, err := cmdAppUnlink(nil, []string{"test-alias", "--emergency"}); err != nil {
+		t.Fatalf("Expected an emergency unlink to succeed, got %v", err)
+	}
+}
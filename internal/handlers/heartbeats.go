@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/heartbeats"
+	"github.com/fazt-sh/fazt/internal/realip"
+)
+
+// heartbeatController records check-ins and alerts on recovery
+var heartbeatController *heartbeats.Controller
+
+// InitHeartbeats sets the controller that watches heartbeats
+func InitHeartbeats(c *heartbeats.Controller) {
+	heartbeatController = c
+}
+
+// HeartbeatCreateRequest is the body of POST /api/heartbeats
+type HeartbeatCreateRequest struct {
+	Name          string `json:"name"`
+	PeriodSeconds int64  `json:"period_seconds"`
+	GraceSeconds  int64  `json:"grace_seconds"`
+}
+
+// HeartbeatCheckInHandler records a check-in. The token in the URL is the
+// only credential, so scripts can call it with a bare curl.
+// GET|HEAD|POST /api/heartbeats/{token}
+func HeartbeatCheckInHandler(w http.ResponseWriter, r *http.Request) {
+	if heartbeatController == nil {
+		api.ServiceUnavailable(w, "heartbeats are not running")
+		return
+	}
+
+	h, err := heartbeatController.CheckIn(r.PathValue("token"), realip.FromRequest(r))
+	if err != nil {
+		writeHeartbeatError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"name":   h.Name,
+		"status": h.Status,
+	})
+}
+
+// HeartbeatsListHandler lists heartbeats with their check-in URLs
+// GET /api/heartbeats
+func HeartbeatsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	list, err := heartbeats.List(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	for i := range list {
+		list[i].URL = heartbeatURL(r, list[i].Token)
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"heartbeats": list,
+	})
+}
+
+// HeartbeatCreateHandler adds a heartbeat
+// POST /api/heartbeats
+func HeartbeatCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req HeartbeatCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	h, err := heartbeats.Create(database.GetDB(), heartbeats.Config{
+		Name:   req.Name,
+		Period: time.Duration(req.PeriodSeconds) * time.Second,
+		Grace:  time.Duration(req.GraceSeconds) * time.Second,
+	}, time.Now())
+	if err != nil {
+		writeHeartbeatError(w, err)
+		return
+	}
+	h.URL = heartbeatURL(r, h.Token)
+
+	activity.LogFromRequest(r, adminActor(r), "heartbeat", h.Name, "create", activity.WeightConfig,
+		map[string]interface{}{"period_seconds": h.PeriodSeconds, "grace_seconds": h.GraceSeconds})
+
+	api.Success(w, http.StatusCreated, h)
+}
+
+// HeartbeatUpdateHandler pauses or resumes a heartbeat
+// PATCH /api/heartbeats/{name} {"enabled": false}
+func HeartbeatUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	if req.Enabled == nil {
+		api.MissingField(w, "enabled")
+		return
+	}
+
+	h, err := heartbeats.SetEnabled(database.GetDB(), r.PathValue("name"), *req.Enabled)
+	if err != nil {
+		writeHeartbeatError(w, err)
+		return
+	}
+	h.URL = heartbeatURL(r, h.Token)
+
+	action := "pause"
+	if h.Enabled {
+		action = "resume"
+	}
+	activity.LogFromRequest(r, adminActor(r), "heartbeat", h.Name, action, activity.WeightConfig, nil)
+
+	api.Success(w, http.StatusOK, h)
+}
+
+// HeartbeatDeleteHandler removes a heartbeat
+// DELETE /api/heartbeats/{name}
+func HeartbeatDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := heartbeats.Delete(database.GetDB(), name); err != nil {
+		writeHeartbeatError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "heartbeat", name, "delete", activity.WeightConfig, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": name,
+	})
+}
+
+// heartbeatURL is the URL scripts call to check in
+func heartbeatURL(r *http.Request, token string) string {
+	return requestBaseURL(r) + "/api/heartbeats/" + token
+}
+
+func writeHeartbeatError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, heartbeats.ErrNotFound):
+		api.NotFound(w, "HEARTBEAT_NOT_FOUND", err.Error())
+	case errors.Is(err, heartbeats.ErrExists):
+		api.Error(w, http.StatusConflict, "HEARTBEAT_EXISTS", err.Error(), nil)
+	case errors.Is(err, heartbeats.ErrInvalid):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/security"
 )
 
 // validEnvVarName validates environment variable names
@@ -82,10 +85,25 @@ func SitesHandler(w http.ResponseWriter, r *http.Request) {
 	api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
 }
 
-// APIKeysHandler handles API key CRUD operations
+// APIKeysHandler handles API key CRUD operations.
+// Keys are managed with an admin session or an admin-scoped server key.
 func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 	db := database.GetDB()
 
+	p, err := resolveAppPrincipal(r)
+	if err == errScopeDenied {
+		api.Forbidden(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.Unauthorized(w, err.Error())
+		return
+	}
+	if !p.unrestricted() {
+		api.Forbidden(w, "Managing API keys requires admin access")
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		// List API keys
@@ -102,8 +120,11 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		// Create new API key
 		var req struct {
-			Name   string `json:"name"`
-			Scopes string `json:"scopes"`
+			Name    string `json:"name"`
+			Scopes  string `json:"scopes"`            // admin (default), deploy, or read
+			User    string `json:"user,omitempty"`    // Email; limits the key to the user's apps
+			App     string `json:"app,omitempty"`     // App ID, alias, or title; limits the key to one app
+			Expires string `json:"expires,omitempty"` // Lifetime, e.g. "30d" or "12h"
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			api.InvalidJSON(w, "Invalid request body")
@@ -114,20 +135,74 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 			api.BadRequest(w, "Name is required")
 			return
 		}
+		if req.Scopes == "" {
+			req.Scopes = hosting.ScopeAdmin
+		}
+		if !hosting.IsValidScope(req.Scopes) {
+			api.BadRequest(w, hosting.ErrInvalidScope.Error())
+			return
+		}
+
+		opts := hosting.APIKeyOptions{Name: req.Name, Scope: req.Scopes}
+		if req.User != "" {
+			id, err := hosting.GetUserIDByEmail(db, req.User)
+			if err != nil {
+				api.NotFound(w, "USER_NOT_FOUND", "No user with that email")
+				return
+			}
+			opts.UserID = id
+		}
+		if req.App != "" {
+			appID, err := resolveExistingApp(db, req.App)
+			if err != nil {
+				api.NotFound(w, "APP_NOT_FOUND", "App not found")
+				return
+			}
+			opts.AppID = appID
+		}
+		if req.Expires != "" {
+			d, err := hosting.ParseExpiry(req.Expires)
+			if err != nil {
+				api.BadRequest(w, err.Error())
+				return
+			}
+			expiresAt := time.Now().Add(d)
+			opts.ExpiresAt = &expiresAt
+		}
 
-		token, err := hosting.CreateAPIKey(db, req.Name, req.Scopes)
+		token, err := hosting.CreateScopedAPIKey(db, opts)
 		if err != nil {
 			api.InternalError(w, err)
 			return
 		}
 
-		api.Success(w, http.StatusOK, map[string]interface{}{
+		actor := p.Email
+		if actor == "" {
+			actor = p.UserID
+		}
+		recordSecurityEvent(r, security.Event{
+			Type:    security.EventTokenCreated,
+			Actor:   actor,
+			Subject: opts.Name,
+			Details: map[string]interface{}{"scope": opts.Scope, "app_id": opts.AppID, "user_id": opts.UserID},
+		})
+
+		resp := map[string]interface{}{
 			"token":   token,
+			"name":    opts.Name,
+			"scopes":  opts.Scope,
 			"message": "API key created. Save this token - it won't be shown again!",
-		})
+		}
+		if opts.AppID != "" {
+			resp["app_id"] = opts.AppID
+		}
+		if opts.ExpiresAt != nil {
+			resp["expires_at"] = opts.ExpiresAt.UTC()
+		}
+		api.Success(w, http.StatusOK, resp)
 
 	case http.MethodDelete:
-		// Delete API key
+		// Revoke API key (kept for auditing)
 		idStr := r.URL.Query().Get("id")
 		if idStr == "" {
 			api.BadRequest(w, "ID parameter required")
@@ -140,7 +215,11 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := hosting.DeleteAPIKey(db, id); err != nil {
+		if err := hosting.RevokeAPIKey(db, id); err != nil {
+			if err == sql.ErrNoRows {
+				api.NotFound(w, "KEY_NOT_FOUND", "API key not found or already revoked")
+				return
+			}
 			api.InternalError(w, err)
 			return
 		}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/realip"
+)
+
+// UserImpersonateHandler mints a short-lived session acting as a user, so
+// admins can reproduce issues in user-scoped storage without credentials.
+// The session is flagged with impersonated_by and cannot change credentials.
+// POST /api/users/{id}/impersonate
+func UserImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	target, ok := lookupUser(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	actor := adminActor(r)
+	token, expiresAt, err := authService.CreateImpersonationSession(target.ID, actor)
+	if err != nil {
+		if errors.Is(err, auth.ErrCannotImpersonate) {
+			api.Forbidden(w, err.Error())
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, actor, realip.FromRequest(r), "user", target.ID, "impersonate_start", activity.WeightSecurity,
+		map[string]interface{}{"email": target.Email, "expires_at": expiresAt})
+	log.Printf("Impersonation started: %s acting as %s (%s)", actor, target.Email, target.ID)
+
+	api.Success(w, http.StatusCreated, map[string]interface{}{
+		"user":       target,
+		"token":      token,
+		"cookie":     "fazt_session",
+		"expires_at": expiresAt,
+	})
+}
+
+// UserImpersonateEndHandler revokes all impersonation sessions for a user
+// DELETE /api/users/{id}/impersonate
+func UserImpersonateEndHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	target, ok := lookupUser(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	ended, err := authService.EndImpersonation(target.ID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	actor := adminActor(r)
+	activity.LogSuccess(activity.ActorUser, actor, realip.FromRequest(r), "user", target.ID, "impersonate_end", activity.WeightSecurity,
+		map[string]interface{}{"email": target.Email, "sessions": ended})
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"user_id":  target.ID,
+		"sessions": ended,
+	})
+}
+
+// lookupUser finds a user by ID or email, writing a 404 when missing
+func lookupUser(w http.ResponseWriter, idOrEmail string) (*auth.User, bool) {
+	var user *auth.User
+	var err error
+	if strings.Contains(idOrEmail, "@") {
+		user, err = authService.GetUserByEmail(idOrEmail)
+	} else {
+		user, err = authService.GetUserByID(idOrEmail)
+	}
+	if err != nil {
+		api.NotFound(w, "USER_NOT_FOUND", "User not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// adminActor identifies the caller of an admin endpoint for the activity log:
+// the session user's ID, or "api_key:<name>" for CLI tokens
+func adminActor(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		if _, name, err := hosting.ValidateAPIKey(database.GetDB(), strings.TrimPrefix(authHeader, "Bearer ")); err == nil {
+			return "api_key:" + name
+		}
+	}
+	if user, err := authService.GetSessionFromRequest(r); err == nil {
+		return user.ID
+	}
+	return ""
+}
+
+// credentialUser returns the session user for endpoints that change login
+// credentials. Impersonation sessions are refused so an admin can't leave a
+// passkey or authenticator behind on the user's account.
+func credentialUser(w http.ResponseWriter, r *http.Request) (*auth.User, bool) {
+	user, err := authService.GetSessionFromRequest(r)
+	if err != nil {
+		api.Unauthorized(w, "Authentication required")
+		return nil, false
+	}
+	if user.ImpersonatedBy != "" {
+		api.Forbidden(w, "Not allowed while impersonating a user")
+		return nil, false
+	}
+	return user, true
+}
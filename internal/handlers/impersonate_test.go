@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+func serveImpersonate(h http.HandlerFunc, method, path, token string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(method+" /api/users/{id}/impersonate", h)
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestUserImpersonate(t *testing.T) {
+	setupAppAuthTest(t)
+	db := database.GetDB()
+
+	adminToken := createTestUserKey(t, "user_admin", "admin@example.com")
+	db.Exec(`INSERT INTO auth_users (id, email, name, picture, provider, role) VALUES ('user_target', 'target@example.com', 'Target', '', 'password', 'user'),
+		('user_owner', 'owner@example.com', 'Owner', '', 'password', 'owner')`)
+
+	resp := serveImpersonate(UserImpersonateHandler, "POST", "/api/users/owner@example.com/impersonate", adminToken)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for owner target, got %d", resp.Code)
+	}
+	resp = serveImpersonate(UserImpersonateHandler, "POST", "/api/users/nobody@example.com/impersonate", adminToken)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown user, got %d", resp.Code)
+	}
+
+	resp = serveImpersonate(UserImpersonateHandler, "POST", "/api/users/target@example.com/impersonate", adminToken)
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var result struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+
+	user, err := authService.ValidateSession(result.Data.Token)
+	if err != nil || user.ID != "user_target" || user.ImpersonatedBy != "api_key:admin@example.com" {
+		t.Fatalf("Expected flagged session for target, got %+v, %v", user, err)
+	}
+
+	// Credentials can't be changed while impersonating
+	req := httptest.NewRequest("POST", "/api/passkeys/register/begin", nil)
+	req.AddCookie(&http.Cookie{Name: "fazt_session", Value: result.Data.Token})
+	rec := httptest.NewRecorder()
+	PasskeyRegisterBeginHandler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for passkey registration, got %d", rec.Code)
+	}
+
+	resp = serveImpersonate(UserImpersonateEndHandler, "DELETE", "/api/users/user_target/impersonate", adminToken)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", resp.Code)
+	}
+	if _, err := authService.ValidateSession(result.Data.Token); err == nil {
+		t.Error("Impersonation session should be ended")
+	}
+}
@@ -2,54 +2,80 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
 )
 
-// LogsHandler returns logs for a specific site
+// logsListSpec is how GET /api/logs pages, sorts and projects site logs
+var logsListSpec = api.ListSpec{
+	Sorts: map[string]string{
+		"created_at": "COALESCE(created_at, '')",
+		"id":         "id",
+	},
+	DefaultSort:  "-created_at",
+	ID:           "id",
+	Fields:       []string{"id", "level", "message", "created_at"},
+	DefaultLimit: 50,
+	MaxLimit:     1000,
+}
+
+// LogsHandler returns a page of logs for a specific site
+// Query params: ?site_id=&level=&limit=&cursor=&sort=&fields= (see api.ParseList)
 func LogsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		api.BadRequest(w, "Method not allowed")
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
 		return
 	}
 
 	siteID := r.URL.Query().Get("site_id")
 	if siteID == "" {
-		api.BadRequest(w, "site_id required")
+		api.MissingField(w, "site_id")
 		return
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 50
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 1000 {
-			limit = l
-		}
+	q, ok := api.ParseList(w, r, logsListSpec)
+	if !ok {
+		return
 	}
 
+	where := "site_id = ?"
+	args := []interface{}{siteID}
+	if level := r.URL.Query().Get("level"); level != "" {
+		where += " AND level = ?"
+		args = append(args, level)
+	}
+	if after, afterArgs := q.Where(); after != "" {
+		where += " AND " + after
+		args = append(args, afterArgs...)
+	}
+	args = append(args, q.LimitArgs()...)
+
 	db := database.GetDB()
 	rows, err := db.Query(`
-		SELECT id, level, message, created_at
+		SELECT id, level, message, created_at, `+q.SortExpr()+`
 		FROM site_logs
-		WHERE site_id = ?
-		ORDER BY created_at DESC
-		LIMIT ?
-	`, siteID, limit)
+		WHERE `+where+`
+		ORDER BY `+q.OrderBy()+`
+		LIMIT ? OFFSET ?
+	`, args...)
 	if err != nil {
 		api.InternalError(w, err)
 		return
 	}
 	defer rows.Close()
 
-	var logs []map[string]interface{}
+	logs := []map[string]interface{}{}
 	for rows.Next() {
 		var id int64
 		var level, message, createdAt string
-		if err := rows.Scan(&id, &level, &message, &createdAt); err != nil {
+		var sortKey interface{}
+		if err := rows.Scan(&id, &level, &message, &createdAt, &sortKey); err != nil {
 			continue
 		}
+		if !q.Next(sortKey, id) {
+			break
+		}
 		logs = append(logs, map[string]interface{}{
 			"id":         id,
 			"level":      level,
@@ -58,7 +84,5 @@ func LogsHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	api.Success(w, http.StatusOK, map[string]interface{}{
-		"logs": logs,
-	})
+	api.ListSuccess(w, logs, q)
 }
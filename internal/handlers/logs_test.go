@@ -44,15 +44,7 @@ func TestLogsHandler_Success(t *testing.T) {
 	resp := httptest.NewRecorder()
 	LogsHandler(resp, req)
 
-	data := testutil.CheckSuccess(t, resp, http.StatusOK)
-	logs, ok := data["logs"]
-	if !ok {
-		t.Fatal("Expected 'logs' in response")
-	}
-	logsList, ok := logs.([]interface{})
-	if !ok {
-		t.Fatal("Expected 'logs' to be an array")
-	}
+	logsList := testutil.CheckSuccessArray(t, resp, http.StatusOK)
 	if len(logsList) != 2 {
 		t.Errorf("Expected 2 logs, got %d", len(logsList))
 	}
@@ -65,13 +57,9 @@ func TestLogsHandler_Empty(t *testing.T) {
 	resp := httptest.NewRecorder()
 	LogsHandler(resp, req)
 
-	data := testutil.CheckSuccess(t, resp, http.StatusOK)
-	logs := data["logs"]
-	if logs != nil {
-		logsList, ok := logs.([]interface{})
-		if ok && len(logsList) != 0 {
-			t.Errorf("Expected empty logs, got %d", len(logsList))
-		}
+	logsList := testutil.CheckSuccessArray(t, resp, http.StatusOK)
+	if len(logsList) != 0 {
+		t.Errorf("Expected empty logs, got %d", len(logsList))
 	}
 }
 
@@ -94,8 +82,8 @@ func TestLogsHandler_MethodNotAllowed(t *testing.T) {
 	resp := httptest.NewRecorder()
 	LogsHandler(resp, req)
 
-	if resp.Code != http.StatusBadRequest {
-		t.Errorf("Expected 400, got %d", resp.Code)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405, got %d", resp.Code)
 	}
 }
 
@@ -109,10 +97,9 @@ func TestLogsHandler_WithLimit(t *testing.T) {
 	resp := httptest.NewRecorder()
 	LogsHandler(resp, req)
 
-	data := testutil.CheckSuccess(t, resp, http.StatusOK)
-	logsList := data["logs"].([]interface{})
-	if len(logsList) != 2 {
-		t.Errorf("Expected 2 logs with limit, got %d", len(logsList))
+	body := testutil.CheckSuccessArray(t, resp, http.StatusOK)
+	if len(body) != 2 {
+		t.Errorf("Expected 2 logs with limit, got %d", len(body))
 	}
 }
 
@@ -120,14 +107,12 @@ func TestLogsHandler_InvalidLimit(t *testing.T) {
 	setupLogsTest(t)
 	insertTestLog(t, "inv-app", "info", "test")
 
-	// Invalid limit should fall back to default (50)
+	// Invalid list parameters are refused like everywhere else
 	req := httptest.NewRequest("GET", "/api/logs?site_id=inv-app&limit=abc", nil)
 	resp := httptest.NewRecorder()
 	LogsHandler(resp, req)
 
-	if resp.Code != http.StatusOK {
-		t.Errorf("Expected 200, got %d", resp.Code)
-	}
+	testutil.CheckError(t, resp, http.StatusBadRequest, "VALIDATION_FAILED")
 }
 
 // --- LogStreamManager ---
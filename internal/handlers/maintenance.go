@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/maintenance"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// SystemStorageHandler breaks down the database's size by table and app
+// GET /api/system/storage
+func SystemStorageHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	report, err := maintenance.Report(database.GetDB(), config.Get().Database.Path)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, report)
+}
+
+// SystemMaintenanceStatusHandler returns the current or last maintenance run
+// GET /api/system/maintenance
+func SystemMaintenanceStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	run := maintenance.Status()
+	if run == nil {
+		api.NotFound(w, "MAINTENANCE_NOT_FOUND", "No maintenance has run since the server started")
+		return
+	}
+	api.Success(w, http.StatusOK, run)
+}
+
+// SystemMaintenanceHandler starts a background vacuum, retention pruning or
+// rebuild of derived data
+// POST /api/system/maintenance {"vacuum": true, "prune_events": "90d", "prune_logs": "30d", "rebuild": ["search-index"]}
+func SystemMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Vacuum      bool   `json:"vacuum"`
+		PruneEvents string `json:"prune_events"`
+		PruneLogs   string   `json:"prune_logs"`
+		Rebuild     []string `json:"rebuild"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	for _, target := range req.Rebuild {
+		if !maintenance.IsRebuildTarget(target) {
+			api.ValidationError(w, "unknown rebuild target "+target+"; one of "+strings.Join(maintenance.RebuildTargets, ", "),
+				"rebuild", "oneof")
+			return
+		}
+	}
+
+	opts := maintenance.Options{Vacuum: req.Vacuum, Rebuild: req.Rebuild}
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"prune_events", req.PruneEvents, &opts.PruneEvents},
+		{"prune_logs", req.PruneLogs, &opts.PruneLogs},
+	} {
+		d, err := worker.ParseDuration(f.value)
+		if err != nil || (d != nil && *d < 24*time.Hour) {
+			api.ValidationError(w, f.name+" must be a duration of at least 1d, e.g. 90d", f.name, "min=1d")
+			return
+		}
+		if d != nil {
+			*f.dst = *d
+		}
+	}
+
+	run, err := maintenance.Start(database.GetDB(), config.Get().Database.Path, opts)
+	switch {
+	case errors.Is(err, maintenance.ErrRunning):
+		api.Conflict(w, err.Error())
+		return
+	case errors.Is(err, maintenance.ErrNothingToDo):
+		api.BadRequest(w, err.Error())
+		return
+	case err != nil:
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "config", "database", "maintenance", activity.WeightConfig,
+		map[string]interface{}{"vacuum": req.Vacuum, "prune_events": req.PruneEvents, "prune_logs": req.PruneLogs, "rebuild": req.Rebuild})
+
+	api.Success(w, http.StatusAccepted, run)
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/realip"
+)
+
+// PasskeyRegisterRequest is the request body for finishing a passkey registration
+type PasskeyRegisterRequest struct {
+	Name       string                           `json:"name"`
+	Credential *auth.PasskeyAttestationResponse `json:"credential"`
+}
+
+// PasskeyLoginRequest is the request body for finishing a passkey login
+type PasskeyLoginRequest struct {
+	Credential *auth.PasskeyAssertionResponse `json:"credential"`
+	RememberMe bool                           `json:"remember_me"`
+}
+
+// PasskeysListHandler lists the current user's passkeys
+// GET /api/passkeys
+func PasskeysListHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := authService.GetSessionFromRequest(r)
+	if err != nil {
+		api.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	passkeys, err := authService.ListPasskeys(user.ID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"passkeys": passkeys,
+	})
+}
+
+// PasskeyRegisterBeginHandler returns options for navigator.credentials.create()
+// POST /api/passkeys/register/begin
+func PasskeyRegisterBeginHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	options, err := authService.BeginPasskeyRegistration(user)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"publicKey": options,
+	})
+}
+
+// PasskeyRegisterFinishHandler verifies the attestation and stores the passkey
+// POST /api/passkeys/register/finish {"name": "Laptop", "credential": {...}}
+func PasskeyRegisterFinishHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req PasskeyRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.Credential == nil {
+		api.MissingField(w, "credential")
+		return
+	}
+
+	passkey, err := authService.FinishPasskeyRegistration(user, req.Name, req.Credential)
+	if err != nil {
+		writePasskeyError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, user.ID, realip.FromRequest(r), "passkey", passkey.ID, "create", activity.WeightAuth,
+		map[string]interface{}{"name": passkey.Name})
+	log.Printf("Passkey %q registered for %s", passkey.Name, user.Email)
+
+	api.Success(w, http.StatusCreated, passkey)
+}
+
+// PasskeyDeleteHandler removes one of the current user's passkeys
+// DELETE /api/passkeys/{id}
+func PasskeyDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := authService.DeletePasskey(user.ID, id); err != nil {
+		writePasskeyError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, user.ID, realip.FromRequest(r), "passkey", id, "delete", activity.WeightAuth, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+// PasskeyLoginBeginHandler returns options for navigator.credentials.get()
+// POST /auth/passkey/login/begin, /api/login/passkey/begin
+func PasskeyLoginBeginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
+		return
+	}
+
+	options, err := authService.BeginPasskeyLogin()
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"publicKey": options,
+	})
+}
+
+// PasskeyLoginFinishHandler verifies a passkey assertion and creates a session.
+// Passkeys require user verification, so no TOTP step follows.
+// POST /auth/passkey/login/finish, /api/login/passkey/finish
+func PasskeyLoginFinishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
+		return
+	}
+
+	ip := realip.FromRequest(r)
+	if !rateLimiter.AllowLogin(ip) {
+		log.Printf("Rate limit exceeded for IP: %s", ip)
+		api.RateLimitExceeded(w, "Too many failed attempts. Please try again in 15 minutes.")
+		return
+	}
+
+	var req PasskeyLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.Credential == nil {
+		api.MissingField(w, "credential")
+		return
+	}
+
+	user, err := authService.FinishPasskeyLogin(req.Credential)
+	if err != nil {
+		if errors.Is(err, auth.ErrUserNotFound) || isPasskeyVerificationError(err) {
+			rateLimiter.RecordAttempt(ip)
+			activity.LogFailure(activity.ActorAnonymous, "", ip, "session", "", "login", "passkey: "+err.Error(), activity.WeightAuth)
+			recordLoginFailure(r, "", "passkey: "+err.Error())
+			log.Printf("Login failed: passkey rejected from %s: %v", ip, err)
+			api.Unauthorized(w, err.Error())
+			return
+		}
+		api.InternalError(w, err)
+		return
+	}
+
+	if !completeLogin(w, r, user, req.RememberMe) {
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"message": "Login successful",
+	})
+}
+
+func isPasskeyVerificationError(err error) bool {
+	return errors.Is(err, auth.ErrInvalidPasskey) || errors.Is(err, auth.ErrPasskeyNotFound) ||
+		errors.Is(err, auth.ErrInvalidChallenge) || errors.Is(err, auth.ErrPasskeyCloned) ||
+		errors.Is(err, auth.ErrUnsupportedAlgorithm)
+}
+
+func writePasskeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrPasskeyNotFound):
+		api.NotFound(w, "PASSKEY_NOT_FOUND", err.Error())
+	case errors.Is(err, auth.ErrPasskeyExists):
+		api.Conflict(w, err.Error())
+	case errors.Is(err, auth.ErrInvalidChallenge), errors.Is(err, auth.ErrInvalidPasskey),
+		errors.Is(err, auth.ErrUnsupportedAlgorithm), errors.Is(err, auth.ErrUnsupportedAttestation):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
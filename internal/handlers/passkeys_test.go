@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+// TestPasskeyRegisterBegin tests registration options for a signed-in user
+func TestPasskeyRegisterBegin(t *testing.T) {
+	silenceTestLogs(t)
+	service, token := setupTestAuthService(t)
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(service, limiter, "v0.8.0-test")
+
+	rr := httptest.NewRecorder()
+	PasskeyRegisterBeginHandler(rr, testutil.JSONRequest("POST", "/api/passkeys/register/begin", nil))
+	testutil.CheckError(t, rr, 401, "UNAUTHORIZED")
+
+	req := testutil.WithSession(testutil.JSONRequest("POST", "/api/passkeys/register/begin", nil), token)
+	rr = httptest.NewRecorder()
+	PasskeyRegisterBeginHandler(rr, req)
+	data := testutil.CheckSuccess(t, rr, 200)
+
+	options, _ := data["publicKey"].(map[string]interface{})
+	rp, _ := options["rp"].(map[string]interface{})
+	if rp["id"] != "test.local" || options["challenge"] == "" {
+		t.Errorf("Unexpected creation options: %v", options)
+	}
+
+	req = testutil.WithSession(testutil.JSONRequest("GET", "/api/passkeys", nil), token)
+	rr = httptest.NewRecorder()
+	PasskeysListHandler(rr, req)
+	data = testutil.CheckSuccess(t, rr, 200)
+	if list, _ := data["passkeys"].([]interface{}); len(list) != 0 {
+		t.Errorf("Expected no passkeys, got %v", list)
+	}
+}
+
+// TestPasskeyLogin_Rejected tests that invalid assertions fail and count against the rate limit
+func TestPasskeyLogin_Rejected(t *testing.T) {
+	silenceTestLogs(t)
+	service, _ := setupTestAuthService(t)
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(service, limiter, "v0.8.0-test")
+
+	rr := httptest.NewRecorder()
+	PasskeyLoginBeginHandler(rr, testutil.JSONRequest("POST", "/auth/passkey/login/begin", nil))
+	data := testutil.CheckSuccess(t, rr, 200)
+	options, _ := data["publicKey"].(map[string]interface{})
+	if options["rpId"] != "test.local" || options["userVerification"] != "required" {
+		t.Errorf("Unexpected request options: %v", options)
+	}
+
+	rr = httptest.NewRecorder()
+	PasskeyLoginFinishHandler(rr, testutil.JSONRequest("POST", "/auth/passkey/login/finish", map[string]interface{}{}))
+	testutil.CheckError(t, rr, 400, "MISSING_FIELD")
+
+	for i := 0; i < 5; i++ {
+		req := testutil.JSONRequest("POST", "/auth/passkey/login/finish", map[string]interface{}{
+			"credential": map[string]interface{}{"id": "abc", "clientDataJSON": "e30"},
+		})
+		rr = httptest.NewRecorder()
+		PasskeyLoginFinishHandler(rr, req)
+		testutil.CheckError(t, rr, 401, "UNAUTHORIZED")
+	}
+
+	req := testutil.JSONRequest("POST", "/auth/passkey/login/finish", map[string]interface{}{
+		"credential": map[string]interface{}{"id": "abc"},
+	})
+	rr = httptest.NewRecorder()
+	PasskeyLoginFinishHandler(rr, req)
+	testutil.CheckError(t, rr, 429, "RATE_LIMIT_EXCEEDED")
+}
+
+// TestPasskeyDelete_NotFound tests deleting an unknown passkey
+func TestPasskeyDelete_NotFound(t *testing.T) {
+	silenceTestLogs(t)
+	service, token := setupTestAuthService(t)
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(service, limiter, "v0.8.0-test")
+
+	req := testutil.WithSession(testutil.JSONRequest("DELETE", "/api/passkeys/missing", nil), token)
+	req.SetPathValue("id", "missing")
+	rr := httptest.NewRecorder()
+	PasskeyDeleteHandler(rr, req)
+	testutil.CheckError(t, rr, 404, "PASSKEY_NOT_FOUND")
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/pings"
+)
+
+// pingController makes calls for "run now"
+var pingController *pings.Controller
+
+// InitPings sets the controller that calls scheduled pings
+func InitPings(c *pings.Controller) {
+	pingController = c
+}
+
+// PingCreateRequest is the body of POST /api/pings
+type PingCreateRequest struct {
+	Name           string            `json:"name"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+	Schedule       string            `json:"schedule"`
+	TimeoutSeconds int64             `json:"timeout_seconds"`
+}
+
+// PingsListHandler lists scheduled pings with their latest run
+// GET /api/pings
+func PingsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	list, err := pings.List(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"pings": list,
+	})
+}
+
+// PingCreateHandler adds a scheduled ping
+// POST /api/pings
+func PingCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req PingCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	ping, err := pings.Create(database.GetDB(), pings.Config{
+		Name:     req.Name,
+		URL:      req.URL,
+		Method:   req.Method,
+		Headers:  req.Headers,
+		Body:     req.Body,
+		Schedule: req.Schedule,
+		Timeout:  time.Duration(req.TimeoutSeconds) * time.Second,
+	}, time.Now())
+	if err != nil {
+		writePingError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "ping", ping.Name, "create", activity.WeightConfig,
+		map[string]interface{}{"url": ping.URL, "method": ping.Method, "schedule": ping.Schedule})
+
+	api.Success(w, http.StatusCreated, ping)
+}
+
+// PingUpdateHandler pauses or resumes a ping
+// PATCH /api/pings/{name} {"enabled": false}
+func PingUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	if req.Enabled == nil {
+		api.MissingField(w, "enabled")
+		return
+	}
+
+	ping, err := pings.SetEnabled(database.GetDB(), r.PathValue("name"), *req.Enabled)
+	if err != nil {
+		writePingError(w, err)
+		return
+	}
+
+	action := "pause"
+	if ping.Enabled {
+		action = "resume"
+	}
+	activity.LogFromRequest(r, adminActor(r), "ping", ping.Name, action, activity.WeightConfig, nil)
+
+	api.Success(w, http.StatusOK, ping)
+}
+
+// PingRunsHandler lists a ping's recent runs
+// GET /api/pings/{name}/runs?limit=N
+func PingRunsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	db := database.GetDB()
+	ping, err := pings.Get(db, r.PathValue("name"))
+	if err != nil {
+		writePingError(w, err)
+		return
+	}
+	runs, err := pings.ListRuns(db, ping.ID, limit)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"ping": ping,
+		"runs": runs,
+	})
+}
+
+// PingRunHandler calls a ping right away and returns the run
+// POST /api/pings/{name}/run
+func PingRunHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+	if pingController == nil {
+		api.ServiceUnavailable(w, "pings are not running")
+		return
+	}
+
+	run, err := pingController.RunNow(r.PathValue("name"))
+	if err != nil {
+		writePingError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, run)
+}
+
+// PingDeleteHandler removes a ping and its runs
+// DELETE /api/pings/{name}
+func PingDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := pings.Delete(database.GetDB(), name); err != nil {
+		writePingError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "ping", name, "delete", activity.WeightConfig, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": name,
+	})
+}
+
+func writePingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, pings.ErrNotFound):
+		api.NotFound(w, "PING_NOT_FOUND", err.Error())
+	case errors.Is(err, pings.ErrExists):
+		api.Error(w, http.StatusConflict, "PING_EXISTS", err.Error(), nil)
+	case errors.Is(err, pings.ErrRunning):
+		api.Error(w, http.StatusConflict, "PING_RUNNING", err.Error(), nil)
+	case errors.Is(err, pings.ErrInvalid):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 // 1x1 transparent GIF pixel (base64 encoded)
@@ -38,7 +39,7 @@ func PixelHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := realip.FromRequest(r)
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
 
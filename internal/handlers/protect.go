@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// Protected apps and aliases can't be removed or repointed by accident. The
+// request must be forced and confirmed by repeating the name, or be forced by
+// an owner: the server owner, or for apps an owner member of the app.
+
+// ProtectRequest is the body of a protect request
+type ProtectRequest struct {
+	Protected bool `json:"protected"`
+}
+
+// AppProtectHandler sets or clears an app's protected flag
+// PUT /api/apps/{id}/protect
+func AppProtectHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	appID, ok := appIDFromPath(w, r, db)
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleOwner) {
+		return
+	}
+
+	var req ProtectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "invalid request body")
+		return
+	}
+
+	if _, err := db.Exec(`UPDATE apps SET protected = ? WHERE id = ?`, req.Protected, appID); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"id":        appID,
+		"protected": req.Protected,
+	})
+}
+
+// AliasProtectHandler sets or clears an alias's protected flag
+// PUT /api/aliases/{subdomain}/protect
+func AliasProtectHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	var req ProtectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "invalid request body")
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		api.InternalError(w, nil)
+		return
+	}
+
+	res, err := db.Exec(`UPDATE aliases SET protected = ? WHERE subdomain = ?`, req.Protected, subdomain)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"subdomain": subdomain,
+		"protected": req.Protected,
+	})
+}
+
+// aliasProtected reports whether an alias is protected. Missing aliases aren't.
+func aliasProtected(db *sql.DB, subdomain string) (bool, error) {
+	var protected bool
+	err := db.QueryRow(`SELECT protected FROM aliases WHERE subdomain = ?`, subdomain).Scan(&protected)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return protected, err
+}
+
+// appProtection describes what protects an app: its own flag and protected
+// aliases routing to it, which removing the app would break. Empty if nothing.
+func appProtection(db *sql.DB, appID string) ([]string, error) {
+	var reasons []string
+	var protected bool
+	err := db.QueryRow(`SELECT protected FROM apps WHERE id = ?`, appID).Scan(&protected)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if protected {
+		reasons = append(reasons, "app "+appID+" is protected")
+	}
+
+	rows, err := db.Query(`SELECT subdomain FROM aliases WHERE protected = 1 AND targets LIKE ? ORDER BY subdomain`, `%"`+appID+`"%`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var subdomain string
+		if err := rows.Scan(&subdomain); err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, fmt.Sprintf("protected alias %s routes to %s", subdomain, appID))
+	}
+	return reasons, rows.Err()
+}
+
+// protectionOverride reports whether the request may change something
+// protected: force=true, plus confirm repeating one of its names unless the
+// caller is an owner. Otherwise it sends 423 PROTECTED.
+func protectionOverride(w http.ResponseWriter, r *http.Request, reason string, owner bool, names ...string) bool {
+	q := r.URL.Query()
+	forced := q.Get("force") == "true"
+	if forced && (owner || confirmsName(q["confirm"], names)) {
+		return true
+	}
+
+	msg := reason + "; retry with force=true and confirm=" + names[0]
+	if forced {
+		msg = reason + "; confirm=" + names[0] + " must repeat its name"
+	}
+	api.Error(w, http.StatusLocked, "PROTECTED", msg, map[string]interface{}{"name": names[0]})
+	return false
+}
+
+func confirmsName(confirms, names []string) bool {
+	for _, c := range confirms {
+		for _, name := range names {
+			if c != "" && c == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requireAliasChange checks a request may remove or repoint an alias.
+// Owners of the server override protection with force alone. Aliases routing
+// to frozen apps also need the change marked as an emergency.
+func requireAliasChange(w http.ResponseWriter, r *http.Request, db *sql.DB, subdomain string) bool {
+	protected, err := aliasProtected(db, subdomain)
+	if err != nil {
+		api.InternalError(w, err)
+		return false
+	}
+	if protected && !protectionOverride(w, r, "alias "+subdomain+" is protected", callerIsOwner(r, ""), subdomain) {
+		return false
+	}
+	return requireAliasUnfrozen(w, r, db, subdomain)
+}
+
+// requireAppRemoval checks a request may remove apps: the named app (ID and
+// title) and any forks removed with it. Owners of the app override protection
+// with force alone.
+func requireAppRemoval(w http.ResponseWriter, r *http.Request, db *sql.DB, appID, title string, ids []string) bool {
+	var reasons []string
+	for _, id := range ids {
+		rs, err := appProtection(db, id)
+		if err != nil {
+			api.InternalError(w, err)
+			return false
+		}
+		reasons = append(reasons, rs...)
+	}
+	if len(reasons) == 0 {
+		return true
+	}
+	names := []string{appID}
+	if title != "" {
+		names = append(names, title)
+	}
+	return protectionOverride(w, r, strings.Join(reasons, ", "), callerIsOwner(r, appID), names...)
+}
+
+// callerIsOwner reports whether the caller owns the server or, given an app,
+// is an owner member of it. Alias routes aren't wrapped in AppAccess, so the
+// caller is resolved here when needed.
+func callerIsOwner(r *http.Request, appID string) bool {
+	p := principalFromRequest(r)
+	if p == nil {
+		var err error
+		if p, err = resolveAppPrincipal(r); err != nil {
+			return false
+		}
+	}
+	if p.Owner {
+		return true
+	}
+	if appID == "" || p.UserID == "" {
+		return false
+	}
+	role, err := hosting.GetAppMemberRole(database.GetDB(), appID, p.UserID)
+	return err == nil && role == hosting.AppRoleOwner
+}
+
+// errProtected is the command gateway's equivalent of protectionOverride.
+// Gateway commands run with server keys, so --force always needs --confirm.
+func errProtected(args []string, reason string, names ...string) error {
+	flags := parseFlags(args)
+	forced := false
+	for _, arg := range args {
+		if arg == "--force" {
+			forced = true
+		}
+	}
+	if forced && confirmsName([]string{flags["confirm"]}, names) {
+		return nil
+	}
+	return cmdError(reason + "; rerun with --force --confirm " + names[0])
+}
+
+// cmdAliasChange checks a gateway command may remove or repoint an alias
+func cmdAliasChange(db *sql.DB, args []string, subdomain string) error {
+	protected, err := aliasProtected(db, subdomain)
+	if err != nil {
+		return err
+	}
+	if protected {
+		if err := errProtected(args, "alias "+subdomain+" is protected", subdomain); err != nil {
+			return err
+		}
+	}
+	return cmdAliasUnfrozen(db, args, subdomain)
+}
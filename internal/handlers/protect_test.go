@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+func protectAlias(t *testing.T, token, subdomain string) {
+	t.Helper()
+	body, _ := json.Marshal(ProtectRequest{Protected: true})
+	req := httptest.NewRequest("PUT", "/api/aliases/"+subdomain+"/protect", bytes.NewReader(body))
+	req.SetPathValue("subdomain", subdomain)
+	testutil.WithAuth(req, token)
+	resp := httptest.NewRecorder()
+	AliasProtectHandler(resp, req)
+	testutil.CheckSuccess(t, resp, http.StatusOK)
+}
+
+func deleteAlias(token, subdomain, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("DELETE", "/api/aliases/"+subdomain+query, nil)
+	req.SetPathValue("subdomain", subdomain)
+	testutil.WithAuth(req, token)
+	resp := httptest.NewRecorder()
+	AliasDeleteHandler(resp, req)
+	return resp
+}
+
+func TestAliasProtection(t *testing.T) {
+	token := setupAliasTest(t)
+	appID := "app_" + testutil.RandStr(8)
+	otherID := "app_" + testutil.RandStr(8)
+	createAppForAlias(t, appID)
+	createAppForAlias(t, otherID)
+	createAliasProxy(t, "blog", appID)
+	createAliasProxy(t, "staging", otherID)
+	protectAlias(t, token, "blog")
+
+	testutil.CheckError(t, deleteAlias(token, "blog", ""), http.StatusLocked, "PROTECTED")
+	// Forced, but the name wasn't typed again
+	testutil.CheckError(t, deleteAlias(token, "blog", "?force=true&confirm=blgo"), http.StatusLocked, "PROTECTED")
+
+	// Repointing is refused the same way
+	body, _ := json.Marshal(SwapRequest{Alias1: "blog", Alias2: "staging"})
+	req := httptest.NewRequest("POST", "/api/aliases/swap", bytes.NewReader(body))
+	testutil.WithAuth(req, token)
+	resp := httptest.NewRecorder()
+	AliasSwapHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusLocked, "PROTECTED")
+
+	body, _ = json.Marshal(AliasCreateRequest{Subdomain: "blog", AppID: otherID})
+	req = httptest.NewRequest("POST", "/api/aliases", bytes.NewReader(body))
+	testutil.WithAuth(req, token)
+	resp = httptest.NewRecorder()
+	AliasCreateHandler(resp, req)
+	testutil.CheckError(t, resp, http.StatusLocked, "PROTECTED")
+
+	if id, _, _ := ResolveAlias("blog"); id != appID {
+		t.Fatalf("Expected blog to still route to %s, got %s", appID, id)
+	}
+
+	data := testutil.CheckSuccess(t, deleteAlias(token, "blog", "?force=true&confirm=blog"), http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "message", "Alias deleted")
+}
+
+func TestAppDeleteHandlerV2_Protected(t *testing.T) {
+	setupAppsV2Test(t)
+	db := database.GetDB()
+	id := createTestAppV2(t, "blog")
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets, protected) VALUES ('www', 'proxy', ?, 1)`, `{"app_id":"`+id+`"}`)
+
+	deleteApp := func(query string, p *appPrincipal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/api/apps/"+id+query, nil)
+		req.SetPathValue("id", id)
+		if p != nil {
+			req = withAppPrincipal(req, p)
+		}
+		resp := httptest.NewRecorder()
+		AppDeleteHandlerV2(resp, req)
+		return resp
+	}
+
+	// The app isn't protected itself, but removing it would break www
+	resp := deleteApp("", nil)
+	testutil.CheckError(t, resp, http.StatusLocked, "PROTECTED")
+	if !strings.Contains(resp.Body.String(), "protected alias www") {
+		t.Errorf("Expected the protected alias in the error, got %s", resp.Body.String())
+	}
+
+	db.Exec(`UPDATE apps SET protected = 1 WHERE id = ?`, id)
+	testutil.CheckError(t, deleteApp("?force=true", &appPrincipal{Admin: true}), http.StatusLocked, "PROTECTED")
+
+	// The server owner only needs to force it
+	data := testutil.CheckSuccess(t, deleteApp("?force=true", &appPrincipal{Admin: true, Owner: true}), http.StatusOK)
+	testutil.AssertFieldEquals(t, data, "message", "App deleted")
+}
+
+func TestCmdAppRemove_Protected(t *testing.T) {
+	setupCmdTestDB(t)
+	database.GetDB().Exec(`UPDATE aliases SET protected = 1 WHERE subdomain = 'test-alias'`)
+
+	if _, err := cmdAppUnlink(nil, []string{"test-alias"}); err == nil || !strings.Contains(err.Error(), "protected") {
+		t.Fatalf("Expected unlink of a protected alias to fail, got %v", err)
+	}
+	if _, err := cmdAppRemove(nil, "", []string{"app_test123", "--force"}); err == nil {
+		t.Fatal("Expected remove without --confirm to fail")
+	}
+	if _, err := cmdAppRemove(nil, "", []string{"app_test123", "--confirm", "test-app", "--force"}); err != nil {
+		t.Fatalf("Expected a confirmed remove to succeed, got %v", err)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"database/sql"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 // RedirectHandler handles redirect tracking
@@ -27,10 +30,11 @@ func RedirectHandler(w http.ResponseWriter, r *http.Request) {
 	var destination string
 	var tags string
 	var id int64
+	var expiresAt sql.NullTime
 
 	err := db.QueryRow(`
-		SELECT id, destination, tags FROM redirects WHERE slug = ?
-	`, slug).Scan(&id, &destination, &tags)
+		SELECT id, destination, tags, expires_at FROM redirects WHERE slug = ?
+	`, slug).Scan(&id, &destination, &tags, &expiresAt)
 
 	if err == sql.ErrNoRows {
 		api.NotFound(w, "REDIRECT_NOT_FOUND", "Redirect not found")
@@ -41,6 +45,12 @@ func RedirectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Expired links are kept, with their stats, but no longer redirect
+	if expiresAt.Valid && !time.Now().Before(expiresAt.Time) {
+		api.Error(w, http.StatusGone, "REDIRECT_EXPIRED", "This link has expired", nil)
+		return
+	}
+
 	// Parse additional tags from query string
 	query := r.URL.Query()
 	extraTags := query.Get("tags")
@@ -53,10 +63,19 @@ func RedirectHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := realip.FromRequest(r)
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
 
+	// Attribute the click to the link's utm_* parameters, or failing that
+	// the destination's, so campaign links can be tagged either way
+	campaign := analytics.ParseCampaign(query)
+	if campaign.IsZero() {
+		if dest, err := url.Parse(destination); err == nil {
+			campaign = analytics.ParseCampaign(dest.Query())
+		}
+	}
+
 	// Log the click event to analytics buffer
 	analytics.Add(analytics.Event{
 		Domain:     slug, // In redirects, domain is the slug
@@ -67,6 +86,7 @@ func RedirectHandler(w http.ResponseWriter, r *http.Request) {
 		Referrer:   referrer,
 		UserAgent:  userAgent,
 		IPAddress:  ipAddress,
+		Campaign:   campaign,
 	})
 
 	// Increment click count
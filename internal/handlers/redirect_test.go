@@ -107,3 +107,28 @@ func TestRedirectHandler_ClickCountIncrement(t *testing.T) {
 		t.Errorf("Expected click_count=2, got %d", count)
 	}
 }
+
+func TestRedirectHandler_Expired(t *testing.T) {
+	setupRedirectTest(t)
+	db := database.GetDB()
+	createTestRedirect(t, db, "old", "https://example.com")
+	createTestRedirect(t, db, "later", "https://example.com")
+	db.Exec("UPDATE redirects SET expires_at = DATETIME('now', '-1 hour') WHERE slug = 'old'")
+	db.Exec("UPDATE redirects SET expires_at = DATETIME('now', '+1 hour') WHERE slug = 'later'")
+
+	req := httptest.NewRequest("GET", "/r/old", nil)
+	resp := httptest.NewRecorder()
+	RedirectHandler(resp, req)
+
+	if resp.Code != http.StatusGone {
+		t.Errorf("Expected 410 for an expired link, got %d", resp.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/r/later", nil)
+	resp = httptest.NewRecorder()
+	RedirectHandler(resp, req)
+
+	if resp.Code != http.StatusFound {
+		t.Errorf("Expected 302 before expiry, got %d", resp.Code)
+	}
+}
@@ -1,15 +1,111 @@
 package handlers
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/models"
+	"github.com/fazt-sh/fazt/internal/qrcode"
 )
 
+// maxImportLinks caps the links in one import request
+const maxImportLinks = 5000
+
+var redirectSlugPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// redirectInput is a link as sent by the dashboard, CLI or an import. The
+// expiry is either a lifetime (expires: "30d") or a date (expires_at:
+// RFC 3339 or "2006-01-02").
+type redirectInput struct {
+	Slug        string   `json:"slug"`
+	Destination string   `json:"destination"`
+	Tags        []string `json:"tags"`
+	Expires     string   `json:"expires,omitempty"`
+	ExpiresAt   string   `json:"expires_at,omitempty"`
+}
+
+// validate checks the link and returns its expiry, nil for never
+func (in *redirectInput) validate(now time.Time) (*time.Time, error) {
+	in.Slug = strings.TrimSpace(in.Slug)
+	in.Destination = strings.TrimSpace(in.Destination)
+	if in.Slug == "" || in.Destination == "" {
+		return nil, errors.New("slug and destination are required")
+	}
+	if !redirectSlugPattern.MatchString(in.Slug) {
+		return nil, fmt.Errorf("invalid slug %q: use letters, digits, '.', '_' or '-' (max 64)", in.Slug)
+	}
+	u, err := url.Parse(in.Destination)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid destination %q: must be an http(s) URL", in.Destination)
+	}
+
+	var expires time.Time
+	switch {
+	case in.Expires != "" && in.ExpiresAt != "":
+		return nil, errors.New("set expires or expires_at, not both")
+	case in.Expires != "":
+		d, err := hosting.ParseExpiry(in.Expires)
+		if err != nil {
+			return nil, err
+		}
+		expires = now.Add(d)
+	case in.ExpiresAt != "":
+		if expires, err = time.Parse(time.RFC3339, in.ExpiresAt); err != nil {
+			if expires, err = time.Parse("2006-01-02", in.ExpiresAt); err != nil {
+				return nil, fmt.Errorf("invalid expires_at %q: use RFC 3339 or YYYY-MM-DD", in.ExpiresAt)
+			}
+		}
+		if !expires.After(now) {
+			return nil, fmt.Errorf("expires_at %q is in the past", in.ExpiresAt)
+		}
+	default:
+		return nil, nil
+	}
+	expires = expires.UTC().Truncate(time.Second)
+	return &expires, nil
+}
+
+// expiryValue is an expiry as stored in redirects.expires_at
+func expiryValue(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// shortLinkURL is the public URL of a link
+func shortLinkURL(r *http.Request, slug string) string {
+	return requestBaseURL(r) + "/r/" + url.PathEscape(slug)
+}
+
+// requestBaseURL is the scheme and host the request came in on
+// (admin.<domain>, or localhost in development)
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
 // DeleteRedirectHandler handles DELETE /api/redirects/{id}
 func DeleteRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
 	// Get ID from path
 	idStr := r.PathValue("id")
 	if idStr == "" {
@@ -39,3 +135,216 @@ func DeleteRedirectHandler(w http.ResponseWriter, r *http.Request) {
 
 	api.Success(w, http.StatusOK, map[string]string{"message": "Redirect deleted"})
 }
+
+// RedirectImportHandler creates links in bulk, skipping existing slugs
+// unless update is set. Invalid links are reported and the rest imported.
+// POST /api/redirects/import
+func RedirectImportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Links  []redirectInput `json:"links"`
+		Update bool            `json:"update"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid JSON")
+		return
+	}
+	if len(req.Links) == 0 {
+		api.BadRequest(w, "No links to import")
+		return
+	}
+	if len(req.Links) > maxImportLinks {
+		api.BadRequest(w, fmt.Sprintf("Too many links: %d (max %d per import)", len(req.Links), maxImportLinks))
+		return
+	}
+
+	type importError struct {
+		Index int    `json:"index"`
+		Slug  string `json:"slug"`
+		Error string `json:"error"`
+	}
+	result := struct {
+		Created int           `json:"created"`
+		Updated int           `json:"updated"`
+		Skipped int           `json:"skipped"`
+		Errors  []importError `json:"errors"`
+	}{Errors: []importError{}}
+
+	db := database.GetDB()
+	tx, err := db.Begin()
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	seen := make(map[string]bool, len(req.Links))
+	for i := range req.Links {
+		link := &req.Links[i]
+		expiresAt, err := link.validate(now)
+		if err == nil && seen[link.Slug] {
+			err = errors.New("duplicate slug in import")
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, importError{Index: i, Slug: link.Slug, Error: err.Error()})
+			continue
+		}
+		seen[link.Slug] = true
+
+		var id int64
+		err = tx.QueryRow("SELECT id FROM redirects WHERE slug = ?", link.Slug).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.Exec(`
+				INSERT INTO redirects (slug, destination, tags, expires_at)
+				VALUES (?, ?, ?, ?)
+			`, link.Slug, link.Destination, strings.Join(link.Tags, ","), expiryValue(expiresAt))
+			if err == nil {
+				result.Created++
+			}
+		case err != nil: // Reported below
+		case !req.Update:
+			result.Skipped++
+		default:
+			_, err = tx.Exec(`
+				UPDATE redirects SET destination = ?, tags = ?, expires_at = ? WHERE id = ?
+			`, link.Destination, strings.Join(link.Tags, ","), expiryValue(expiresAt), id)
+			if err == nil {
+				result.Updated++
+			}
+		}
+		if err != nil {
+			log.Printf("Error importing redirect %s: %v", link.Slug, err)
+			api.InternalError(w, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, result)
+}
+
+// RedirectStatsHandler returns one link's clicks over time and where they
+// came from
+// GET /api/redirects/{slug}/stats?days=30
+func RedirectStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	days := parseInt(r.URL.Query().Get("days"), 30)
+	if days < 1 || days > 365 {
+		days = 30
+	}
+
+	db := database.GetDB()
+	s := models.LinkStats{Slug: r.PathValue("slug"), Days: days}
+	var expires sql.NullTime
+	err := db.QueryRow(`
+		SELECT destination, click_count, expires_at FROM redirects WHERE slug = ?
+	`, s.Slug).Scan(&s.Destination, &s.TotalClicks, &expires)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "REDIRECT_NOT_FOUND", "Redirect not found")
+		return
+	} else if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if expires.Valid {
+		s.ExpiresAt = &expires.Time
+	}
+	s.URL = shortLinkURL(r, s.Slug)
+
+	// Clicks are logged with the slug as their domain
+	whereClause := "source_type = 'redirect' AND domain = ? AND created_at >= DATETIME('now', ?)"
+	args := []interface{}{s.Slug, fmt.Sprintf("-%d days", days)}
+
+	db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT visitor_id) FROM events WHERE `+whereClause,
+		args...).Scan(&s.Clicks, &s.Visitors)
+
+	rows, err := db.Query(`
+		SELECT strftime('%Y-%m-%d', created_at) AS day, COUNT(*)
+		FROM events
+		WHERE `+whereClause+`
+		GROUP BY day
+		ORDER BY day
+	`, args...)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer rows.Close()
+	s.Timeline = []models.TimelineStat{}
+	for rows.Next() {
+		var t models.TimelineStat
+		if err := rows.Scan(&t.Timestamp, &t.Count); err != nil {
+			continue
+		}
+		s.Timeline = append(s.Timeline, t)
+	}
+
+	if s.ReferrerTypes, err = breakdownBy(db, "COALESCE(referrer_type, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if s.Countries, err = breakdownBy(db, "COALESCE(country, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if s.Devices, err = breakdownBy(db, "COALESCE(device, 'unknown')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if s.Campaigns, err = breakdownBy(db, "COALESCE(utm_campaign, 'none')", whereClause, args); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, s)
+}
+
+// RedirectQRHandler returns a QR code of the link's short URL as a PNG
+// GET /api/redirects/{slug}/qr?size=256
+func RedirectQRHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	size := parseInt(r.URL.Query().Get("size"), 256)
+	if size < 64 || size > 2048 {
+		api.ValidationError(w, "size must be between 64 and 2048", "size", "range")
+		return
+	}
+
+	slug := r.PathValue("slug")
+	var exists int
+	database.GetDB().QueryRow("SELECT COUNT(*) FROM redirects WHERE slug = ?", slug).Scan(&exists)
+	if exists == 0 {
+		api.NotFound(w, "REDIRECT_NOT_FOUND", "Redirect not found")
+		return
+	}
+
+	code, err := qrcode.Encode([]byte(shortLinkURL(r, slug)))
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	png, err := code.PNG(size)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(png)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/s3gw"
+	"github.com/fazt-sh/fazt/internal/sigv4"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// s3Region is the region S3 clients are told to sign for; the gateway
+// accepts any
+const s3Region = "us-east-1"
+
+// S3KeyCreateRequest is the body of POST /api/s3/keys
+type S3KeyCreateRequest struct {
+	App      string `json:"app"`
+	Name     string `json:"name"`
+	ReadOnly bool   `json:"read_only"`
+}
+
+// S3PresignRequest is the body of POST /api/s3/presign
+type S3PresignRequest struct {
+	App            string `json:"app"`
+	Path           string `json:"path"`
+	Method         string `json:"method"`
+	ExpiresSeconds int64  `json:"expires_seconds"`
+	KeyID          string `json:"key_id"`
+}
+
+// S3GatewayHandler serves the S3 API on s3.<domain>
+func S3GatewayHandler(w http.ResponseWriter, r *http.Request) {
+	s3gw.NewGateway(database.GetDB(), storage.GetWriter()).ServeHTTP(w, r)
+}
+
+// S3KeysListHandler lists S3 access keys, optionally for one app
+// GET /api/s3/keys?app=
+func S3KeysListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	keys, err := s3gw.ListKeys(database.GetDB(), r.URL.Query().Get("app"))
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"keys":     keys,
+		"endpoint": s3Endpoint(),
+	})
+}
+
+// S3KeyCreateHandler issues an access key for an app's bucket. The secret
+// is only returned here.
+// POST /api/s3/keys
+func S3KeyCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req S3KeyCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	if req.App == "" {
+		api.MissingField(w, "app")
+		return
+	}
+	db := database.GetDB()
+	if !appExists(db, req.App) {
+		api.NotFound(w, "APP_NOT_FOUND", "App not found")
+		return
+	}
+
+	k, err := s3gw.CreateKey(db, req.App, req.Name, req.ReadOnly)
+	if err != nil {
+		writeS3KeyError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "s3_key", k.ID, "create", activity.WeightSecurity,
+		map[string]interface{}{"app": k.AppID, "read_only": k.ReadOnly})
+
+	api.Success(w, http.StatusCreated, map[string]interface{}{
+		"key":      k,
+		"endpoint": s3Endpoint(),
+		"region":   s3Region,
+	})
+}
+
+// S3KeyDeleteHandler revokes an access key
+// DELETE /api/s3/keys/{id}
+func S3KeyDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := s3gw.DeleteKey(database.GetDB(), id); err != nil {
+		writeS3KeyError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "s3_key", id, "delete", activity.WeightSecurity, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+// S3PresignHandler returns a presigned URL for one object, signed with the
+// given key or the app's newest
+// POST /api/s3/presign
+func S3PresignHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req S3PresignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	req.Path = strings.TrimPrefix(req.Path, "/")
+	if req.App == "" {
+		api.MissingField(w, "app")
+		return
+	}
+	if req.Path == "" {
+		api.MissingField(w, "path")
+		return
+	}
+	method := strings.ToUpper(req.Method)
+	switch method {
+	case "":
+		method = http.MethodGet
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+	default:
+		api.BadRequest(w, "method must be GET, HEAD, PUT or DELETE")
+		return
+	}
+	expires := time.Hour
+	if req.ExpiresSeconds != 0 {
+		expires = time.Duration(req.ExpiresSeconds) * time.Second
+	}
+	if expires < time.Second || expires > sigv4.MaxExpires {
+		api.BadRequest(w, "expires_seconds must be between 1 and 604800")
+		return
+	}
+
+	db := database.GetDB()
+	keyID := req.KeyID
+	if keyID == "" {
+		keys, err := s3gw.ListKeys(db, req.App)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		if len(keys) == 0 {
+			api.NotFound(w, "S3_KEY_NOT_FOUND", "App has no S3 access keys; create one first")
+			return
+		}
+		keyID = keys[0].ID
+	}
+	k, err := s3gw.GetKey(db, keyID)
+	if err != nil {
+		writeS3KeyError(w, err)
+		return
+	}
+	if k.AppID != req.App {
+		api.BadRequest(w, "access key belongs to another app")
+		return
+	}
+	if k.ReadOnly && method != http.MethodGet && method != http.MethodHead {
+		api.BadRequest(w, "access key is read-only")
+		return
+	}
+
+	now := time.Now()
+	objectURL := s3Endpoint() + "/" + req.App + "/" + escapeObjectKey(req.Path)
+	signed, err := sigv4.Presign(method, objectURL, k.ID, k.Secret, s3Region, expires, now)
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"url":        signed,
+		"method":     method,
+		"key_id":     k.ID,
+		"expires_at": now.Add(expires).UTC().Format(time.RFC3339),
+	})
+}
+
+// s3Endpoint is the URL S3 clients connect to
+func s3Endpoint() string {
+	return "https://s3." + config.Get().Server.Domain
+}
+
+// escapeObjectKey escapes an object key for a URL path, keeping its slashes
+func escapeObjectKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+func appExists(db *sql.DB, app string) bool {
+	var id string
+	return db.QueryRow(`SELECT id FROM apps WHERE title = ?`, app).Scan(&id) == nil
+}
+
+func writeS3KeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, s3gw.ErrKeyNotFound):
+		api.NotFound(w, "S3_KEY_NOT_FOUND", err.Error())
+	case errors.Is(err, s3gw.ErrInvalid):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
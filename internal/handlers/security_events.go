@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/security"
+)
+
+// Security events are a short list of high-signal happenings (failed
+// logins, new devices, API keys, role changes, new egress domains) kept
+// apart from the activity log, with rules that alert on them.
+
+// recordSecurityEvent records an event caused by a request
+func recordSecurityEvent(r *http.Request, e security.Event) {
+	e.IP = realip.FromRequest(r)
+	security.Record(database.GetDB(), e)
+}
+
+// recordLoginFailure records a failed login. username is what was
+// attempted, when the login method has one.
+func recordLoginFailure(r *http.Request, username, reason string) {
+	recordSecurityEvent(r, security.Event{
+		Type:    security.EventLoginFailed,
+		Actor:   username,
+		Details: map[string]interface{}{"reason": reason, "path": r.URL.Path},
+	})
+}
+
+// SecurityEventsHandler lists recorded security events, newest first
+// GET /api/security/events?type=login.failed&since=24h&limit=100
+func SecurityEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	eventType := q.Get("type")
+	if eventType != "" && !security.ValidEventType(eventType) {
+		api.BadRequest(w, "unknown event type: "+eventType)
+		return
+	}
+
+	var since int64
+	if s := q.Get("since"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			api.BadRequest(w, "since must be a duration, e.g. 24h")
+			return
+		}
+		since = time.Now().Add(-d).Unix()
+	}
+
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > 1000 {
+			api.BadRequest(w, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	events, err := security.List(database.GetDB(), eventType, since, limit)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
+
+// SecurityRulesListHandler lists notification rules
+// GET /api/security/rules
+func SecurityRulesListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	rules, err := security.ListRules(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+	})
+}
+
+// SecurityRuleCreateHandler adds a notification rule
+// POST /api/security/rules {"name", "event_type", "threshold", "window_seconds", "webhook_url"}
+func SecurityRuleCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req security.Rule
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	rule, err := security.CreateRule(database.GetDB(), req)
+	if err != nil {
+		writeSecurityError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "security_rule", strconv.FormatInt(rule.ID, 10), "create", activity.WeightSecurity,
+		map[string]interface{}{"name": rule.Name, "event_type": rule.EventType, "threshold": rule.Threshold})
+
+	api.Success(w, http.StatusCreated, rule)
+}
+
+// SecurityRuleDeleteHandler removes a notification rule
+// DELETE /api/security/rules/{id}
+func SecurityRuleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid rule id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := security.DeleteRule(database.GetDB(), id); err != nil {
+		writeSecurityError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "security_rule", strconv.FormatInt(id, 10), "delete", activity.WeightSecurity, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeSecurityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, security.ErrRuleNotFound):
+		api.NotFound(w, "RULE_NOT_FOUND", err.Error())
+	case errors.Is(err, security.ErrInvalidRule):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/realip"
+)
+
+// SessionsListHandler returns the caller's active sessions, marking the one
+// making the request
+// GET /api/auth/sessions
+func SessionsListHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := authService.GetSessionFromRequest(r)
+	if err != nil {
+		api.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	sessions, err := authService.ListUserSessions(user.ID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	if token := authService.SessionToken(r); token != "" {
+		current := auth.SessionID(token)
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// SessionRevokeHandler signs out one of the caller's sessions
+// DELETE /api/auth/sessions/{id}
+func SessionRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := authService.RevokeSession(id, user.ID); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, user.ID, realip.FromRequest(r), "session", user.ID, "revoke", activity.WeightSecurity, nil)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"revoked": id,
+	})
+}
+
+// UserSessionsListHandler returns every active session across all users
+// GET /api/users/sessions
+func UserSessionsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	sessions, err := authService.ListAllSessions()
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// UserSessionRevokeHandler signs out one of a user's sessions
+// DELETE /api/users/{id}/sessions/{session}
+func UserSessionRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	target, ok := lookupUser(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+
+	id := r.PathValue("session")
+	if err := authService.RevokeSession(id, target.ID); err != nil {
+		writeSessionError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, adminActor(r), realip.FromRequest(r), "session", target.ID, "revoke", activity.WeightSecurity,
+		map[string]interface{}{"email": target.Email})
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"revoked": id,
+		"user_id": target.ID,
+	})
+}
+
+func writeSessionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrSessionNotFound) {
+		api.NotFound(w, "SESSION_NOT_FOUND", err.Error())
+		return
+	}
+	api.InternalError(w, err)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+func serveSessions(pattern string, h http.HandlerFunc, method, path string, authorize func(*http.Request)) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pattern, h)
+
+	req := httptest.NewRequest(method, path, nil)
+	authorize(req)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, req)
+	return resp
+}
+
+func withSession(token string) func(*http.Request) {
+	return func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: "fazt_session", Value: token})
+	}
+}
+
+func TestSessionsListAndRevoke(t *testing.T) {
+	setupAppAuthTest(t)
+	db := database.GetDB()
+
+	db.Exec(`INSERT INTO auth_users (id, email, name, picture, provider, role) VALUES ('user_a', 'a@example.com', 'A', '', 'password', 'user'),
+		('user_b', 'b@example.com', 'B', '', 'password', 'user')`)
+	current, _ := authService.CreateSession("user_a")
+	other, _ := authService.CreateSession("user_a")
+	foreign, _ := authService.CreateSession("user_b")
+
+	resp := serveSessions("GET /api/auth/sessions", SessionsListHandler, "GET", "/api/auth/sessions", withSession(current))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var result struct {
+		Data struct {
+			Sessions []auth.SessionInfo `json:"sessions"`
+		} `json:"data"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if len(result.Data.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(result.Data.Sessions))
+	}
+	for _, s := range result.Data.Sessions {
+		if s.Current != (s.ID == auth.SessionID(current)) {
+			t.Errorf("Wrong current flag on %+v", s)
+		}
+	}
+
+	// Users can't revoke someone else's session
+	resp = serveSessions("DELETE /api/auth/sessions/{id}", SessionRevokeHandler, "DELETE",
+		"/api/auth/sessions/"+auth.SessionID(foreign), withSession(current))
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user's session, got %d", resp.Code)
+	}
+
+	resp = serveSessions("DELETE /api/auth/sessions/{id}", SessionRevokeHandler, "DELETE",
+		"/api/auth/sessions/"+auth.SessionID(other), withSession(current))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if _, err := authService.ValidateSession(other); err == nil {
+		t.Error("Expected revoked session to be invalid")
+	}
+}
+
+func TestUserSessionsAdmin(t *testing.T) {
+	setupAppAuthTest(t)
+	db := database.GetDB()
+
+	adminToken := createTestUserKey(t, "user_admin", "admin@example.com")
+	db.Exec(`INSERT INTO auth_users (id, email, name, picture, provider, role) VALUES ('user_a', 'a@example.com', 'A', '', 'password', 'user')`)
+	token, _ := authService.CreateSession("user_a")
+	withKey := func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+adminToken) }
+
+	resp := serveSessions("GET /api/users/sessions", UserSessionsListHandler, "GET", "/api/users/sessions", withSession(token))
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for regular user, got %d", resp.Code)
+	}
+
+	resp = serveSessions("GET /api/users/sessions", UserSessionsListHandler, "GET", "/api/users/sessions", withKey)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	var result struct {
+		Data struct {
+			Sessions []auth.SessionInfo `json:"sessions"`
+		} `json:"data"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if len(result.Data.Sessions) != 1 || result.Data.Sessions[0].Email != "a@example.com" {
+		t.Fatalf("Expected user_a's session, got %+v", result.Data.Sessions)
+	}
+
+	pattern := "DELETE /api/users/{id}/sessions/{session}"
+	resp = serveSessions(pattern, UserSessionRevokeHandler, "DELETE", "/api/users/a@example.com/sessions/unknown", withKey)
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown session, got %d", resp.Code)
+	}
+	resp = serveSessions(pattern, UserSessionRevokeHandler, "DELETE", "/api/users/a@example.com/sessions/"+result.Data.Sessions[0].ID, withKey)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if _, err := authService.ValidateSession(token); err == nil {
+		t.Error("Expected revoked session to be invalid")
+	}
+}
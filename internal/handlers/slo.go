@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/slo"
+)
+
+// SLOsListHandler lists SLOs with their current compliance
+// GET /api/slos
+func SLOsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	db := database.GetDB()
+	slos, err := slo.List(db)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	now := time.Now()
+	reports := make([]*slo.Report, 0, len(slos))
+	for i := range slos {
+		report, err := slo.Evaluate(db, &slos[i], now)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"slos": reports,
+	})
+}
+
+// SLOCreateHandler defines a new SLO
+// POST /api/slos
+func SLOCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req slo.Config
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	s, err := slo.Create(database.GetDB(), req, time.Now())
+	if err != nil {
+		writeSLOError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusCreated, s)
+}
+
+// SLOGetHandler returns one SLO with its current compliance
+// GET /api/slos/{id}
+func SLOGetHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid SLO id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	db := database.GetDB()
+	s, err := slo.Get(db, id)
+	if err != nil {
+		writeSLOError(w, err)
+		return
+	}
+
+	report, err := slo.Evaluate(db, s, time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, report)
+}
+
+// SLODeleteHandler removes an SLO
+// DELETE /api/slos/{id}
+func SLODeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid SLO id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := slo.Delete(database.GetDB(), id); err != nil {
+		writeSLOError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeSLOError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, slo.ErrSLONotFound):
+		api.NotFound(w, "SLO_NOT_FOUND", err.Error())
+	case errors.Is(err, slo.ErrAliasNotFound), errors.Is(err, slo.ErrInvalidKind),
+		errors.Is(err, slo.ErrInvalidObjective), errors.Is(err, slo.ErrInvalidThreshold),
+		errors.Is(err, slo.ErrInvalidWindowDays):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sync"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// SplitCookieName is the cookie that pins a visitor to a split variant
+const SplitCookieName = "fazt_split"
+
+// splitCookieMaxAge keeps visitors on the same variant for 30 days
+const splitCookieMaxAge = 30 * 24 * 60 * 60
+
+// splitCounters tracks live request counts per split variant since server start
+// Keyed by subdomain, then app_id
+var (
+	splitCounters   = make(map[string]map[string]int64)
+	splitCountersMu sync.Mutex
+)
+
+// GetSplitTargets returns the split targets configured for a subdomain
+func GetSplitTargets(subdomain string) ([]SplitTarget, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, sql.ErrConnDone
+	}
+
+	var aliasType string
+	var targets *string
+	err := db.QueryRow("SELECT type, targets FROM aliases WHERE subdomain = ?", subdomain).Scan(&aliasType, &targets)
+	if err != nil {
+		return nil, err
+	}
+	if aliasType != "split" || targets == nil {
+		return nil, nil
+	}
+
+	var splits []SplitTarget
+	if err := json.Unmarshal([]byte(*targets), &splits); err != nil {
+		return nil, err
+	}
+	return splits, nil
+}
+
+// PickSplitTarget deterministically selects a target for a visitor.
+// The same subdomain + visitor ID always maps to the same bucket (0-99),
+// so a visitor keeps hitting the same variant while weights are unchanged.
+func PickSplitTarget(subdomain string, targets []SplitTarget, visitorID string) SplitTarget {
+	h := fnv.New32a()
+	h.Write([]byte(subdomain))
+	h.Write([]byte{':'})
+	h.Write([]byte(visitorID))
+	bucket := int(h.Sum32() % 100)
+
+	cumulative := 0
+	for _, t := range targets {
+		cumulative += t.Weight
+		if bucket < cumulative {
+			return t
+		}
+	}
+	return targets[len(targets)-1]
+}
+
+// ResolveSplitVariant selects the variant app for a split alias request.
+// Visitors without a fazt_split cookie are assigned a new visitor ID.
+func ResolveSplitVariant(w http.ResponseWriter, r *http.Request, subdomain string) (string, error) {
+	targets, err := GetSplitTargets(subdomain)
+	if err != nil {
+		return "", err
+	}
+	if len(targets) == 0 {
+		return "", nil
+	}
+
+	visitorID := ""
+	if cookie, err := r.Cookie(SplitCookieName); err == nil && cookie.Value != "" {
+		visitorID = cookie.Value
+	} else {
+		visitorID = appid.GenerateVisitor()
+		http.SetCookie(w, &http.Cookie{
+			Name:     SplitCookieName,
+			Value:    visitorID,
+			Path:     "/",
+			MaxAge:   splitCookieMaxAge,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	target := PickSplitTarget(subdomain, targets, visitorID)
+	recordSplitHit(subdomain, target.AppID)
+	return target.AppID, nil
+}
+
+// ResolveAppSiteID returns the VFS site ID (app title) for an app ID
+func ResolveAppSiteID(appID string) (string, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", sql.ErrConnDone
+	}
+
+	var title sql.NullString
+	if err := db.QueryRow("SELECT title FROM apps WHERE id = ?", appID).Scan(&title); err != nil {
+		return "", err
+	}
+	return title.String, nil
+}
+
+func recordSplitHit(subdomain, appID string) {
+	splitCountersMu.Lock()
+	defer splitCountersMu.Unlock()
+
+	counts, ok := splitCounters[subdomain]
+	if !ok {
+		counts = make(map[string]int64)
+		splitCounters[subdomain] = counts
+	}
+	counts[appID]++
+}
+
+func getSplitHits(subdomain string) map[string]int64 {
+	splitCountersMu.Lock()
+	defer splitCountersMu.Unlock()
+
+	result := make(map[string]int64)
+	for appID, n := range splitCounters[subdomain] {
+		result[appID] = n
+	}
+	return result
+}
+
+// SplitVariantStatus is the live status of a single split variant
+type SplitVariantStatus struct {
+	AppID     string `json:"app_id"`
+	Title     string `json:"title,omitempty"`
+	Weight    int    `json:"weight"`
+	Requests  int64  `json:"requests"`
+	Pageviews int64  `json:"pageviews_24h"`
+}
+
+// AliasSplitStatusHandler returns per-variant request counts for a split alias
+// GET /api/aliases/{subdomain}/split
+func AliasSplitStatusHandler(w http.ResponseWriter, r *http.Request) {
+	subdomain := r.PathValue("subdomain")
+	if subdomain == "" {
+		api.BadRequest(w, "subdomain required")
+		return
+	}
+
+	if !requireAliasAuth(w, r) {
+		return
+	}
+
+	targets, err := GetSplitTargets(subdomain)
+	if err == sql.ErrNoRows {
+		api.NotFound(w, "ALIAS_NOT_FOUND", "Alias not found")
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if len(targets) == 0 {
+		api.BadRequest(w, "alias is not a traffic split")
+		return
+	}
+
+	db := database.GetDB()
+	hits := getSplitHits(subdomain)
+
+	variants := make([]SplitVariantStatus, 0, len(targets))
+	for _, t := range targets {
+		v := SplitVariantStatus{
+			AppID:    t.AppID,
+			Weight:   t.Weight,
+			Requests: hits[t.AppID],
+		}
+		v.Title, _ = ResolveAppSiteID(t.AppID)
+		db.QueryRow(`
+			SELECT COUNT(*) FROM events
+			WHERE domain = ? AND tags = ? AND event_type = 'pageview' AND created_at >= DATETIME('now', '-24 hours')
+		`, t.AppID, analytics.SplitTag(subdomain)).Scan(&v.Pageviews)
+		variants = append(variants, v)
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"subdomain": subdomain,
+		"variants":  variants,
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+func TestPickSplitTarget_Sticky(t *testing.T) {
+	targets := []SplitTarget{{AppID: "app_a", Weight: 50}, {AppID: "app_b", Weight: 50}}
+
+	first := PickSplitTarget("tetris", targets, "fazt_vis_abc123def456")
+	for i := 0; i < 10; i++ {
+		if got := PickSplitTarget("tetris", targets, "fazt_vis_abc123def456"); got.AppID != first.AppID {
+			t.Fatalf("Expected sticky selection %s, got %s", first.AppID, got.AppID)
+		}
+	}
+}
+
+func TestPickSplitTarget_Weights(t *testing.T) {
+	targets := []SplitTarget{{AppID: "app_a", Weight: 90}, {AppID: "app_b", Weight: 10}}
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[PickSplitTarget("tetris", targets, fmt.Sprintf("visitor-%d", i)).AppID]++
+	}
+
+	// Allow generous tolerance around the 90/10 split
+	if counts["app_a"] < 8500 || counts["app_a"] > 9500 {
+		t.Errorf("Expected ~9000 hits for app_a, got %d", counts["app_a"])
+	}
+	if counts["app_b"] == 0 {
+		t.Error("Expected some hits for app_b")
+	}
+}
+
+func TestResolveSplitVariant_SetsCookie(t *testing.T) {
+	setupAliasTest(t)
+	db := database.GetDB()
+
+	db.Exec(`INSERT INTO apps (id, title) VALUES ('app_a', 'variant-a'), ('app_b', 'variant-b')`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('exp', 'split', '[{"app_id":"app_a","weight":50},{"app_id":"app_b","weight":50}]')`)
+
+	// First visit assigns a visitor cookie
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	variant, err := ResolveSplitVariant(rec, req, "exp")
+	if err != nil {
+		t.Fatalf("ResolveSplitVariant failed: %v", err)
+	}
+	if variant != "app_a" && variant != "app_b" {
+		t.Fatalf("Unexpected variant %q", variant)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == SplitCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("Expected fazt_split cookie to be set")
+	}
+
+	// Returning visitor keeps the same variant and gets no new cookie
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		again, _ := ResolveSplitVariant(rec, req, "exp")
+		if again != variant {
+			t.Fatalf("Expected sticky variant %s, got %s", variant, again)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Error("Expected no cookie for returning visitor")
+		}
+	}
+
+	if site, _ := ResolveAppSiteID(variant); site != "variant-a" && site != "variant-b" {
+		t.Errorf("Unexpected site ID %q", site)
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"maps"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// liveInterval is how often the live stats socket checks for changes
+	liveInterval = 2 * time.Second
+	// livePingPeriod is how often an idle socket is pinged
+	livePingPeriod = 30 * time.Second
+	// livePageviews caps the pageviews sent per message
+	livePageviews = 50
+	liveWriteWait = 10 * time.Second
+)
+
+// liveUpgrader only accepts the dashboard's own origin: the socket is
+// authenticated by the session cookie, which other sites' pages would send too
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  512,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true // Not a browser
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	},
+}
+
+// liveMessage is a message on the live stats socket: "snapshot" first, then
+// "update" with the pageviews since the previous message
+type liveMessage struct {
+	Type string `json:"type"`
+	analytics.LiveSnapshot
+}
+
+// StatsLiveHandler streams active visitors and recent pageviews over a
+// WebSocket, optionally for one site
+// GET /api/stats/live?site=blog
+func StatsLiveHandler(w http.ResponseWriter, r *http.Request) {
+	site := r.URL.Query().Get("site")
+
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade already replied
+	}
+	defer conn.Close()
+
+	// The client sends nothing; reading only notices it going away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(typ string, snap analytics.LiveSnapshot) error {
+		conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+		return conn.WriteJSON(liveMessage{Type: typ, LiveSnapshot: snap})
+	}
+
+	last := analytics.Live(site, 0, livePageviews)
+	if send("snapshot", last) != nil {
+		return
+	}
+
+	ticker := time.NewTicker(liveInterval)
+	defer ticker.Stop()
+	lastWrite := time.Now()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		// Active counts also change as visitors go idle
+		snap := analytics.Live(site, last.Seq, livePageviews)
+		if snap.Seq != last.Seq || !maps.Equal(snap.Active, last.Active) {
+			if send("update", snap) != nil {
+				return
+			}
+			last, lastWrite = snap, time.Now()
+		} else if time.Since(lastWrite) >= livePingPeriod {
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(liveWriteWait)) != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+	}
+}
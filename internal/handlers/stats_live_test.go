@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/gorilla/websocket"
+)
+
+func TestStatsLiveHandler(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(StatsLiveHandler))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/stats/live?site=live-test"
+
+	analytics.RecordLive(analytics.Event{Domain: "live-test", Path: "/first", IPAddress: "203.0.113.1"})
+	analytics.RecordLive(analytics.Event{Domain: "other", Path: "/", IPAddress: "203.0.113.1"})
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	var msg liveMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if msg.Type != "snapshot" || len(msg.Pageviews) != 1 || msg.Pageviews[0].Path != "/first" {
+		t.Fatalf("Expected a snapshot with the site's pageview, got %+v", msg)
+	}
+	if msg.Active["live-test"] != 1 || msg.Active["other"] != 0 {
+		t.Errorf("Expected only the site's active visitor, got %v", msg.Active)
+	}
+
+	analytics.RecordLive(analytics.Event{Domain: "live-test", Path: "/second", IPAddress: "203.0.113.2"})
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read update: %v", err)
+	}
+	if msg.Type != "update" || len(msg.Pageviews) != 1 || msg.Pageviews[0].Path != "/second" {
+		t.Errorf("Expected an update with only the new pageview, got %+v", msg)
+	}
+	if msg.ActiveTotal != 2 {
+		t.Errorf("Expected 2 active visitors, got %d", msg.ActiveTotal)
+	}
+}
+
+func TestStatsLiveHandler_CrossOrigin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(StatsLiveHandler))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	header := http.Header{"Origin": {"https://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, header); err == nil {
+		t.Error("Expected a cross-origin connection to be refused")
+	} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403, got %v", resp)
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+// incidentHistory is how far back the admin incident list reaches
+const incidentHistory = 90 * 24 * time.Hour
+
+// IncidentCreateRequest is the request body for opening an incident
+type IncidentCreateRequest struct {
+	Title    string   `json:"title"`
+	Severity string   `json:"severity,omitempty"`  // minor (default), major, maintenance
+	Sites    []string `json:"sites,omitempty"`     // empty = whole server
+	Message  string   `json:"message,omitempty"`   // defaults to the title
+	StartsAt int64    `json:"starts_at,omitempty"` // maintenance window (unix seconds), default now
+	EndsAt   int64    `json:"ends_at,omitempty"`   // default open until resolved
+}
+
+// IncidentUpdateRequest is the request body for posting an incident update
+type IncidentUpdateRequest struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// StatusPageHandler returns the public status page summary
+// GET /api/status (served on status.<domain>, no auth)
+func StatusPageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		api.ErrorResponse(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", "")
+		return
+	}
+
+	summary, err := status.BuildSummary(database.GetDB(), time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=30")
+	api.Success(w, http.StatusOK, summary)
+}
+
+// IncidentsListHandler lists open and recent incidents
+// GET /api/status/incidents
+func IncidentsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	incidents, err := status.ListIncidents(database.GetDB(), time.Now().Add(-incidentHistory))
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"incidents": incidents,
+	})
+}
+
+// IncidentCreateHandler opens an incident
+// POST /api/status/incidents
+func IncidentCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req IncidentCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	cfg := status.IncidentConfig{
+		Title:    req.Title,
+		Severity: req.Severity,
+		Sites:    req.Sites,
+		Message:  req.Message,
+	}
+	if req.StartsAt > 0 {
+		cfg.StartsAt = time.Unix(req.StartsAt, 0)
+	}
+	if req.EndsAt > 0 {
+		cfg.EndsAt = time.Unix(req.EndsAt, 0)
+	}
+
+	inc, err := status.CreateIncident(database.GetDB(), cfg, time.Now())
+	if err != nil {
+		writeIncidentError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusCreated, inc)
+}
+
+// IncidentUpdateHandler posts an update to an incident
+// POST /api/status/incidents/{id}/updates
+func IncidentUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid incident id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req IncidentUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	inc, err := status.UpdateIncident(database.GetDB(), id, req.Status, req.Message)
+	if err != nil {
+		writeIncidentError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, inc)
+}
+
+// IncidentDeleteHandler removes an incident from the log
+// DELETE /api/status/incidents/{id}
+func IncidentDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid incident id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := status.DeleteIncident(database.GetDB(), id); err != nil {
+		writeIncidentError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeIncidentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, status.ErrIncidentNotFound):
+		api.NotFound(w, "INCIDENT_NOT_FOUND", err.Error())
+	case errors.Is(err, status.ErrInvalidSeverity), errors.Is(err, status.ErrInvalidStatus),
+		errors.Is(err, status.ErrMissingTitle), errors.Is(err, status.ErrMissingMessage),
+		errors.Is(err, status.ErrInvalidWindow):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
+
+// SyntheticCreateRequest is the request body for adding a synthetic check
+type SyntheticCreateRequest struct {
+	Site            string `json:"site"`
+	Name            string `json:"name"`
+	Script          string `json:"script"`                     // path of a .js file in the site
+	IntervalSeconds int64  `json:"interval_seconds,omitempty"` // default 300
+	TimeoutSeconds  int64  `json:"timeout_seconds,omitempty"`  // default 30
+}
+
+// SyntheticsListHandler lists synthetic checks with their latest run
+// GET /api/status/synthetics
+func SyntheticsListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	checks, err := status.ListSynthetics(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"synthetics": checks,
+	})
+}
+
+// SyntheticCreateHandler adds a synthetic check
+// POST /api/status/synthetics
+func SyntheticCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req SyntheticCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	check, err := status.CreateSynthetic(database.GetDB(), status.SyntheticConfig{
+		Site:     req.Site,
+		Name:     req.Name,
+		Script:   req.Script,
+		Interval: time.Duration(req.IntervalSeconds) * time.Second,
+		Timeout:  time.Duration(req.TimeoutSeconds) * time.Second,
+	}, time.Now())
+	if err != nil {
+		writeSyntheticError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusCreated, check)
+}
+
+// SyntheticRunsHandler lists a synthetic check's recent runs
+// GET /api/status/synthetics/{id}/runs?limit=N
+func SyntheticRunsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid synthetic check id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	db := database.GetDB()
+	check, err := status.GetSynthetic(db, id)
+	if err != nil {
+		writeSyntheticError(w, err)
+		return
+	}
+	runs, err := status.ListSyntheticRuns(db, id, limit)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"synthetic": check,
+		"runs":      runs,
+	})
+}
+
+// SyntheticDeleteHandler removes a synthetic check and its runs
+// DELETE /api/status/synthetics/{id}
+func SyntheticDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid synthetic check id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := status.DeleteSynthetic(database.GetDB(), id); err != nil {
+		writeSyntheticError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeSyntheticError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, status.ErrSyntheticNotFound):
+		api.NotFound(w, "SYNTHETIC_NOT_FOUND", err.Error())
+	case errors.Is(err, status.ErrScriptNotFound):
+		api.NotFound(w, "SCRIPT_NOT_FOUND", err.Error())
+	case errors.Is(err, status.ErrSyntheticExists):
+		api.Error(w, http.StatusConflict, "SYNTHETIC_EXISTS", err.Error(), nil)
+	case errors.Is(err, status.ErrInvalidSynthetic), errors.Is(err, status.ErrInvalidSchedule):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// AppStorageOpsHandler returns an app's storage operation percentiles and
+// its slowest recent operations, for spotting N+1 ds.find loops
+// GET /api/apps/{id}/storage/ops?limit=50
+func AppStorageOpsHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":            appID,
+		"ops":               storage.AppOpStats(appID),
+		"slow":              storage.SlowOps(appID, slowOpsLimit(r)),
+		"slow_threshold_ms": storage.SlowOpThreshold().Milliseconds(),
+	})
+}
+
+// SystemStorageOpsHandler returns storage operation stats for every app and
+// the slow-op log across apps
+// GET /api/system/storage/ops?limit=50
+func SystemStorageOpsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"apps":              storage.AllOpStats(),
+		"slow":              storage.SlowOps("", slowOpsLimit(r)),
+		"slow_threshold_ms": storage.SlowOpThreshold().Milliseconds(),
+	})
+}
+
+// AppStorageExplainHandler shows how a document store find runs: the
+// generated SQL, the index SQLite picks, and rows scanned vs matched
+// POST /api/apps/{id}/storage/explain {"collection": "posts", "query": {...}}
+func AppStorageExplainHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	var req struct {
+		Collection string                 `json:"collection"`
+		Query      map[string]interface{} `json:"query"`
+		Limit      int                    `json:"limit"`
+		Order      string                 `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Collection == "" {
+		api.BadRequest(w, "collection required")
+		return
+	}
+
+	docs := storage.NewSQLDocStore(database.GetDB())
+	plan, err := docs.Explain(r.Context(), appID, req.Collection, req.Query,
+		&storage.FindOptions{Limit: req.Limit, Order: req.Order})
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":     appID,
+		"collection": req.Collection,
+		"plan":       plan,
+	})
+}
+
+// AppStorageCandidatesHandler lists JSON fields the app queries often in
+// large collections, which would scan fewer rows if extracted
+// GET /api/apps/{id}/storage/candidates
+func AppStorageCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	appID, ok := appIDFromPath(w, r, database.GetDB())
+	if !ok || !requireAppRole(w, r, appID, hosting.AppRoleViewer) {
+		return
+	}
+
+	candidates, err := storage.FieldCandidates(r.Context(), database.GetDB(), appID)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"app_id":     appID,
+		"candidates": candidates,
+	})
+}
+
+// SystemStorageFieldsHandler lists extracted fields and candidates across
+// apps
+// GET /api/system/storage/fields
+func SystemStorageFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	db := database.GetDB()
+	extracted, err := storage.ExtractedFields(r.Context(), db)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	candidates, err := storage.FieldCandidates(r.Context(), db, "")
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"extracted":  extracted,
+		"candidates": candidates,
+	})
+}
+
+// SystemStorageExtractHandler indexes a JSON field for all collections.
+// Building the index on a large store can take a while.
+// POST /api/system/storage/fields {"field": "author"}
+func SystemStorageExtractHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Field string `json:"field"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.BadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Field == "" {
+		api.BadRequest(w, "field required")
+		return
+	}
+
+	index, err := storage.ExtractField(r.Context(), database.GetDB(), req.Field)
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"field": req.Field,
+		"index": index,
+	})
+}
+
+// SystemStorageDropFieldHandler removes an extracted field's index
+// DELETE /api/system/storage/fields/{field}
+func SystemStorageDropFieldHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	field := r.PathValue("field")
+	if err := storage.DropExtractedField(r.Context(), database.GetDB(), field); err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"field":   field,
+		"dropped": true,
+	})
+}
+
+func slowOpsLimit(r *http.Request) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 500 {
+		return n
+	}
+	return 50
+}
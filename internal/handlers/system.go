@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"runtime"
 	"strconv"
@@ -9,14 +12,27 @@ import (
 	"github.com/fazt-sh/fazt/internal/activity"
 	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/approval"
+	"github.com/fazt-sh/fazt/internal/assets"
 	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/storage"
 	"github.com/fazt-sh/fazt/internal/system"
+	"github.com/fazt-sh/fazt/internal/usage"
 )
 
 var startTime = time.Now()
 
+// vmPoolStats reports the serverless VM pool, set by the server at startup
+var vmPoolStats func() interface{}
+
+// SetVMPoolStats sets the source of the health check's VM pool statistics
+func SetVMPoolStats(fn func() interface{}) {
+	vmPoolStats = fn
+}
+
 // SystemHealthHandler returns the system health status and metrics
 func SystemHealthHandler(w http.ResponseWriter, r *http.Request) {
 	// Require admin auth (allows both API key and session with admin role)
@@ -54,13 +70,29 @@ func SystemHealthHandler(w http.ResponseWriter, r *http.Request) {
 			"path":             config.Get().Database.Path,
 			"open_connections": dbStats.OpenConnections,
 			"in_use":           dbStats.InUse,
+			"write_queue":      storage.GetWriter().Stats(),
 		},
+		"vfs_cache": map[string]interface{}{
+			"files":     vfsStats.CachedFiles,
+			"bytes":     vfsStats.CacheSizeBytes,
+			"max_bytes": vfsStats.CacheMaxBytes,
+			"hits":      vfsStats.Hits,
+			"misses":    vfsStats.Misses,
+			"evictions": vfsStats.Evictions,
+		},
+		"js_programs": modules.Stats(),
 		"runtime": map[string]interface{}{
-			"queued_events": bufferStats.EventsQueued,
-			"goroutines":    runtime.NumGoroutine(),
+			"queued_events":  bufferStats.EventsQueued,
+			"flushed_events": bufferStats.EventsFlushed,
+			"dropped_events": bufferStats.EventsDropped,
+			"goroutines":     runtime.NumGoroutine(),
 		},
 	}
 
+	if vmPoolStats != nil {
+		response["js_vms"] = vmPoolStats()
+	}
+
 	api.Success(w, http.StatusOK, response)
 }
 
@@ -77,6 +109,16 @@ func SystemLimitsSchemaHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(system.GetSchemaJSON())
 }
 
+// FaztTypesHandler serves fazt.d.ts, the TypeScript declarations of the
+// serverless runtime, for apps that weren't created with them. Public.
+// GET /api/types/fazt.d.ts
+func FaztTypesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/typescript; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="fazt.d.ts"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(assets.FaztTypes())
+}
+
 // SystemCacheHandler returns VFS cache statistics
 func SystemCacheHandler(w http.ResponseWriter, r *http.Request) {
 	stats := hosting.GetStats()
@@ -103,6 +145,79 @@ func SystemConfigHandler(w http.ResponseWriter, r *http.Request) {
 	api.Success(w, http.StatusOK, safeCfg)
 }
 
+// runtimeSettings are the config keys that can be changed while the server
+// runs, with how to apply each
+var runtimeSettings = map[string]func(on bool){
+	"auth.require_2fa": func(on bool) {
+		authService.SetRequire2FA(on)
+		config.Get().Auth.Require2FA = on
+	},
+	"auth.require_approval": func(on bool) {
+		approval.SetRequired(on)
+		config.Get().Auth.RequireApproval = on
+	},
+}
+
+// applyRuntimeSetting stores a runtime setting and applies it right away
+func applyRuntimeSetting(key, value string) error {
+	apply, ok := runtimeSettings[key]
+	if !ok {
+		return fmt.Errorf("%s can't be changed while the server runs", key)
+	}
+	if value != "true" && value != "false" {
+		return fmt.Errorf("%s must be true or false", key)
+	}
+	if err := config.NewDBConfigStore(database.GetDB()).Set(key, value); err != nil {
+		return err
+	}
+	apply(value == "true")
+	return nil
+}
+
+// SystemConfigUpdateHandler changes a runtime setting, e.g.
+// {"key": "auth.require_2fa", "value": "true"}
+// PUT /api/system/config
+func SystemConfigUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	if _, ok := runtimeSettings[req.Key]; !ok {
+		api.BadRequest(w, fmt.Sprintf("%s can't be changed while the server runs", req.Key))
+		return
+	}
+	if req.Value != "true" && req.Value != "false" {
+		api.BadRequest(w, fmt.Sprintf("%s must be true or false", req.Key))
+		return
+	}
+
+	if !requireApproval(w, r, approval.OpConfigChange, req.Key, fmt.Sprintf("Set %s to %s", req.Key, req.Value),
+		map[string]string{"key": req.Key, "value": req.Value}) {
+		return
+	}
+
+	if err := applyRuntimeSetting(req.Key, req.Value); err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	activity.LogFromRequest(r, adminActor(r), "config", req.Key, "update", activity.WeightConfig,
+		map[string]interface{}{"value": req.Value})
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"key":   req.Key,
+		"value": req.Value,
+	})
+}
+
 // SystemCapacityHandler redirects to the unified limits endpoint.
 // LEGACY_CODE: Remove after admin UI migrates to /api/system/limits
 func SystemCapacityHandler(w http.ResponseWriter, r *http.Request) {
@@ -148,6 +263,97 @@ func SystemLogsStatsHandler(w http.ResponseWriter, r *http.Request) {
 	api.Success(w, http.StatusOK, stats)
 }
 
+// SystemUsageHandler returns approximate per-app cost: totals per app, or
+// one app's daily breakdown with ?app=
+// GET /api/system/usage?days=7&app=<id>
+func SystemUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	days := 7
+	if d, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && d > 0 && d <= 90 {
+		days = d
+	}
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+
+	// Include requests metered since the last periodic flush
+	db := database.GetDB()
+	usage.Flush(db)
+
+	if app := r.URL.Query().Get("app"); app != "" {
+		daily, err := usage.Daily(db, app, since)
+		if err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		api.Success(w, http.StatusOK, map[string]interface{}{
+			"app":   app,
+			"days":  days,
+			"daily": daily,
+		})
+		return
+	}
+
+	apps, err := usage.Summary(db, since)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"days": days,
+		"apps": apps,
+	})
+}
+
+// SystemRuntimeUsageHandler returns the serverless handlers that used the
+// most CPU time or memory, optionally for one app
+// GET /api/system/usage/runtime?days=7&sort=cpu|max_cpu|alloc|killed&limit=20&app=<id>
+func SystemRuntimeUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	days := 7
+	if d, err := strconv.Atoi(query.Get("days")); err == nil && d > 0 && d <= 90 {
+		days = d
+	}
+	since := time.Now().UTC().AddDate(0, 0, 1-days)
+	sort := query.Get("sort")
+	if sort == "" {
+		sort = "cpu"
+	}
+	limit := 20
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	// Include executions recorded since the last periodic flush
+	db := database.GetDB()
+	usage.Flush(db)
+
+	top, err := usage.Top(db, since, query.Get("app"), sort, limit)
+	if errors.Is(err, usage.ErrUnknownSort) {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	limits := system.GetLimits().Runtime
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"days":    days,
+		"sort":    sort,
+		"entries": top,
+		"limits": map[string]interface{}{
+			"cpu_ms":       limits.MaxCPU,
+			"memory_bytes": limits.MaxMemory,
+		},
+	})
+}
+
 // SystemLogsCleanupHandler deletes activity logs matching filters
 func SystemLogsCleanupHandler(w http.ResponseWriter, r *http.Request) {
 	if _, ok := requireAdminAuth(w, r); !ok {
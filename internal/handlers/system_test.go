@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/fazt-sh/fazt/internal/auth"
@@ -140,6 +141,23 @@ func TestSystemLimitsSchemaHandler_Success(t *testing.T) {
 	}
 }
 
+func TestFaztTypesHandler(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/types/fazt.d.ts", nil)
+	rr := httptest.NewRecorder()
+
+	FaztTypesHandler(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/typescript") {
+		t.Errorf("Expected a TypeScript Content-Type, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "declare var fazt: Fazt") {
+		t.Error("Expected the fazt global to be declared")
+	}
+}
+
 // TestSystemCacheHandler_Success tests VFS cache stats endpoint
 func TestSystemCacheHandler_Success(t *testing.T) {
 	setupSystemHandlerTest(t)
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/throttle"
+)
+
+// ThrottleListHandler lists app budgets with today's usage and whether each
+// app is in degraded mode
+// GET /api/system/throttle
+func ThrottleListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	statuses, err := throttle.Statuses(database.GetDB(), time.Now())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"budgets": statuses,
+	})
+}
+
+// ThrottleBudgetRequest is the body of PUT /api/system/throttle/{app}.
+// Omitted or zero limits are unlimited.
+type ThrottleBudgetRequest struct {
+	CPUMs        int64 `json:"cpu_ms"`
+	EgressBytes  int64 `json:"egress_bytes"`
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+// ThrottleBudgetSetHandler sets an app's daily budget
+// PUT /api/system/throttle/{app}
+func ThrottleBudgetSetHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req ThrottleBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	budget, err := throttle.SetBudget(database.GetDB(), r.PathValue("app"), req.CPUMs, req.EgressBytes, req.StorageBytes)
+	if err != nil {
+		writeThrottleError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, budget)
+}
+
+// ThrottleBudgetDeleteHandler removes an app's budget and override
+// DELETE /api/system/throttle/{app}
+func ThrottleBudgetDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	app := r.PathValue("app")
+	if err := throttle.Delete(database.GetDB(), app); err != nil {
+		writeThrottleError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": app,
+	})
+}
+
+// ThrottleOverrideRequest is the body of POST /api/system/throttle/{app}/override
+type ThrottleOverrideRequest struct {
+	Mode string `json:"mode"` // on, off or auto
+}
+
+// ThrottleOverrideHandler forces an app into or out of degraded mode, or
+// returns it to its budgets. It takes effect within a minute.
+// POST /api/system/throttle/{app}/override
+func ThrottleOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req ThrottleOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+
+	budget, err := throttle.SetOverride(database.GetDB(), r.PathValue("app"), req.Mode)
+	if err != nil {
+		writeThrottleError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, budget)
+}
+
+func writeThrottleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, throttle.ErrBudgetNotFound):
+		api.NotFound(w, "BUDGET_NOT_FOUND", err.Error())
+	case errors.Is(err, throttle.ErrInvalidBudget), errors.Is(err, throttle.ErrInvalidOverride):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
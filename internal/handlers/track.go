@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -11,6 +10,7 @@ import (
 	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/models"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 const maxBodySize = 10 * 1024 // 10KB
@@ -45,7 +45,7 @@ func TrackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Extract client information
-	ipAddress := extractIPAddress(r)
+	ipAddress := realip.FromRequest(r)
 	userAgent := r.UserAgent()
 	referrer := req.Referrer
 	if referrer == "" {
@@ -63,20 +63,33 @@ func TrackHandler(w http.ResponseWriter, r *http.Request) {
 	req.Path = sanitizeInput(req.Path)
 	referrer = sanitizeInput(referrer)
 
+	// Respect per-site opt-out and DNT/GPC settings; still answer 204 so
+	// clients can't tell dropped events apart
+	if !analytics.ShouldTrack(r, analytics.SiteFromHost(domain)) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
 	// Convert query params to JSON string
 	queryParamsJSON := req.ToQueryParamsJSON()
 
 	// Add to analytics buffer (LEGACY_CODE: Migrate to activity.Log())
 	analytics.Add(analytics.Event{
-		Domain:      domain,
-		Tags:        tagsStr,
-		SourceType:  "web",
-		EventType:   req.EventType,
-		Path:        req.Path,
-		Referrer:    referrer,
-		UserAgent:   userAgent,
-		IPAddress:   ipAddress,
-		QueryParams: queryParamsJSON,
+		Domain:       domain,
+		Tags:         tagsStr,
+		SourceType:   "web",
+		EventType:    req.EventType,
+		Path:         req.Path,
+		Referrer:     referrer,
+		UserAgent:    userAgent,
+		IPAddress:    ipAddress,
+		QueryParams:  queryParamsJSON,
+		ScreenWidth:  clampScreenSize(req.ScreenWidth),
+		ScreenHeight: clampScreenSize(req.ScreenHeight),
+		Campaign:     analytics.CampaignFromMap(req.QueryParams),
+		// The beacon's own Referer is the page itself, so only the
+		// reported referrer says where the visit came from
+		ReferrerType: analytics.ClassifyReferrer(sanitizeInput(req.Referrer), domain),
 	})
 
 	// Log to unified activity system
@@ -128,33 +141,6 @@ func determineDomain(req *models.TrackRequest, r *http.Request) string {
 	return "unknown"
 }
 
-// extractIPAddress gets the client's IP address from the request
-func extractIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// Take the first IP in the list
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-
-	return ip
-}
-
 // sanitizeInput removes potentially dangerous characters and limits length
 func sanitizeInput(input string) string {
 	// Trim whitespace
@@ -168,3 +154,11 @@ func sanitizeInput(input string) string {
 
 	return input
 }
+
+// clampScreenSize discards implausible screen dimensions
+func clampScreenSize(px int) int {
+	if px <= 0 || px > 16384 {
+		return 0
+	}
+	return px
+}
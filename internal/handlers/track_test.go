@@ -174,61 +174,6 @@ func TestDetermineDomain_ExplicitDomain(t *testing.T) {
 	_ = tr
 }
 
-// --- extractIPAddress ---
-
-func TestExtractIPAddress_XForwardedFor(t *testing.T) {
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Header.Set("X-Forwarded-For", "1.2.3.4, 5.6.7.8")
-
-	ip := extractIPAddress(req)
-	if ip != "1.2.3.4" {
-		t.Errorf("Expected 1.2.3.4, got %s", ip)
-	}
-}
-
-func TestExtractIPAddress_XRealIP(t *testing.T) {
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Header.Set("X-Real-IP", "10.0.0.1")
-
-	ip := extractIPAddress(req)
-	if ip != "10.0.0.1" {
-		t.Errorf("Expected 10.0.0.1, got %s", ip)
-	}
-}
-
-func TestExtractIPAddress_RemoteAddr(t *testing.T) {
-	req := httptest.NewRequest("GET", "/", nil)
-	req.RemoteAddr = "192.168.1.1:1234"
-
-	ip := extractIPAddress(req)
-	if ip != "192.168.1.1" {
-		t.Errorf("Expected 192.168.1.1, got %s", ip)
-	}
-}
-
-func TestExtractIPAddress_RemoteAddrNoPort(t *testing.T) {
-	req := httptest.NewRequest("GET", "/", nil)
-	req.RemoteAddr = "192.168.1.1"
-
-	ip := extractIPAddress(req)
-	// SplitHostPort fails without port, falls back to RemoteAddr
-	if ip != "192.168.1.1" {
-		t.Errorf("Expected 192.168.1.1, got %s", ip)
-	}
-}
-
-func TestExtractIPAddress_XFFPriority(t *testing.T) {
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Header.Set("X-Forwarded-For", "1.1.1.1")
-	req.Header.Set("X-Real-IP", "2.2.2.2")
-	req.RemoteAddr = "3.3.3.3:9999"
-
-	ip := extractIPAddress(req)
-	if ip != "1.1.1.1" {
-		t.Errorf("Expected X-Forwarded-For to take priority, got %s", ip)
-	}
-}
-
 // --- sanitizeInput ---
 
 func TestSanitizeInput_Normal(t *testing.T) {
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/realip"
+)
+
+// TwoFactorRequest is the request body for the /api/2fa endpoints.
+// Token is the login challenge from /api/login, used instead of a session
+// when 2FA is required but the user has not enrolled yet.
+type TwoFactorRequest struct {
+	Code  string `json:"code,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// TwoFactorStatusHandler returns the current user's 2FA state
+// GET /api/2fa
+func TwoFactorStatusHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := authService.GetSessionFromRequest(r)
+	if err != nil {
+		api.Unauthorized(w, "Authentication required")
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"enabled":                  authService.TwoFactorEnabled(user.ID),
+		"required":                 authService.Require2FA(),
+		"recovery_codes_remaining": authService.RecoveryCodesRemaining(user.ID),
+	})
+}
+
+// TwoFactorEnrollHandler generates a TOTP secret and provisioning URI
+// POST /api/2fa/enroll
+func TwoFactorEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	user, ok := twoFactorUser(w, r, req.Token)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := authService.EnrollTOTP(user)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"secret":           secret,
+		"provisioning_uri": uri, // Render as a QR code for authenticator apps
+	})
+}
+
+// TwoFactorEnableHandler confirms enrollment and returns recovery codes.
+// With a login challenge token, it also completes the pending login.
+// POST /api/2fa/enable {"code": "123456"}
+func TwoFactorEnableHandler(w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+	if req.Code == "" {
+		api.MissingField(w, "code")
+		return
+	}
+
+	ip := realip.FromRequest(r)
+	if req.Token != "" && !rateLimiter.AllowLogin(ip) {
+		api.RateLimitExceeded(w, "Too many failed attempts. Please try again in 15 minutes.")
+		return
+	}
+
+	user, ok := twoFactorUser(w, r, req.Token)
+	if !ok {
+		return
+	}
+
+	codes, err := authService.EnableTOTP(user.ID, req.Code)
+	if err != nil {
+		if req.Token != "" && errors.Is(err, auth.ErrInvalidTOTPCode) {
+			rateLimiter.RecordAttempt(ip)
+		}
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, user.ID, ip, "user", user.ID, "2fa_enable", activity.WeightAuth, nil)
+	log.Printf("Two-factor authentication enabled for %s", user.Email)
+
+	if req.Token != "" {
+		challenge, err := authService.GetLoginChallenge(req.Token)
+		if err != nil {
+			writeTwoFactorError(w, err)
+			return
+		}
+		authService.DeleteLoginChallenge(req.Token)
+		if !completeLogin(w, r, user, challenge.RememberMe) {
+			return
+		}
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"enabled":        true,
+		"recovery_codes": codes,
+	})
+}
+
+// TwoFactorDisableHandler turns off 2FA (not allowed when the server requires it)
+// POST /api/2fa/disable {"code": "123456"}
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	if err := authService.DisableTOTP(user.ID, req.Code); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	activity.LogSuccess(activity.ActorUser, user.ID, realip.FromRequest(r), "user", user.ID, "2fa_disable", activity.WeightAuth, nil)
+	log.Printf("Two-factor authentication disabled for %s", user.Email)
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"enabled": false,
+	})
+}
+
+// TwoFactorRecoveryCodesHandler replaces the user's recovery codes
+// POST /api/2fa/recovery-codes {"code": "123456"}
+func TwoFactorRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := credentialUser(w, r)
+	if !ok {
+		return
+	}
+
+	var req TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "Invalid request body")
+		return
+	}
+
+	codes, err := authService.RegenerateRecoveryCodes(user.ID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"recovery_codes": codes,
+	})
+}
+
+// twoFactorUser resolves the user from a login challenge token or the session
+func twoFactorUser(w http.ResponseWriter, r *http.Request, token string) (*auth.User, bool) {
+	if token != "" {
+		challenge, err := authService.GetLoginChallenge(token)
+		if err != nil {
+			api.Unauthorized(w, err.Error())
+			return nil, false
+		}
+		user, err := authService.GetUserByID(challenge.UserID)
+		if err != nil {
+			api.Unauthorized(w, auth.ErrInvalidChallenge.Error())
+			return nil, false
+		}
+		return user, true
+	}
+
+	return credentialUser(w, r)
+}
+
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidTOTPCode):
+		api.Unauthorized(w, err.Error())
+	case errors.Is(err, auth.ErrInvalidChallenge):
+		api.Unauthorized(w, err.Error())
+	case errors.Is(err, auth.ErrTOTPAlreadyEnabled):
+		api.Conflict(w, err.Error())
+	case errors.Is(err, auth.ErrTOTPNotEnrolled):
+		api.BadRequest(w, err.Error())
+	case errors.Is(err, auth.ErrTwoFactorRequired):
+		api.Forbidden(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
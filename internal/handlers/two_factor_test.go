@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/handlers/testutil"
+)
+
+// setupTwoFactorTest configures a password login for admin/testpassword
+func setupTwoFactorTest(t *testing.T) *auth.Service {
+	t.Helper()
+	silenceTestLogs(t)
+
+	service := auth.NewService(setupAuthTestDB(t), "test.local", false)
+	limiter := auth.NewRateLimiter()
+	t.Cleanup(func() { limiter.Stop() })
+	InitAuth(service, limiter, "v0.8.0-test")
+
+	passwordHash, _ := auth.HashPassword("testpassword")
+	config.SetConfig(&config.Config{
+		Server: config.ServerConfig{Env: "test"},
+		Auth: config.AuthConfig{
+			Username:     "admin",
+			PasswordHash: passwordHash,
+		},
+	})
+	return service
+}
+
+func passwordLogin(t *testing.T) map[string]interface{} {
+	t.Helper()
+	req := testutil.JSONRequest("POST", "/api/login", map[string]interface{}{
+		"username": "admin",
+		"password": "testpassword",
+	})
+	rr := httptest.NewRecorder()
+	LoginHandler(rr, req)
+	return testutil.CheckSuccess(t, rr, 200)
+}
+
+func hasSessionCookie(rr *httptest.ResponseRecorder) bool {
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "fazt_session" && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// TestLoginHandler_TwoFactor tests the password → code login flow for an enrolled user
+func TestLoginHandler_TwoFactor(t *testing.T) {
+	service := setupTwoFactorTest(t)
+
+	user, _ := service.GetOrCreateLocalAdmin("admin")
+	secret, _, _ := service.EnrollTOTP(user)
+	code, _ := auth.TOTPCode(secret, time.Now())
+	if _, err := service.EnableTOTP(user.ID, code); err != nil {
+		t.Fatalf("EnableTOTP failed: %v", err)
+	}
+
+	req := testutil.JSONRequest("POST", "/api/login", map[string]interface{}{
+		"username": "admin",
+		"password": "testpassword",
+	})
+	rr := httptest.NewRecorder()
+	LoginHandler(rr, req)
+	data := testutil.CheckSuccess(t, rr, 200)
+	testutil.AssertFieldEquals(t, data, "two_factor_required", true)
+	if hasSessionCookie(rr) {
+		t.Fatal("Password alone must not create a session")
+	}
+	token, _ := data["two_factor_token"].(string)
+
+	// Wrong code
+	req = testutil.JSONRequest("POST", "/api/login/2fa", map[string]interface{}{
+		"token": token,
+		"code":  "000000",
+	})
+	rr = httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	testutil.CheckError(t, rr, 401, "UNAUTHORIZED")
+
+	next, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+	req = testutil.JSONRequest("POST", "/api/login/2fa", map[string]interface{}{
+		"token": token,
+		"code":  next,
+	})
+	rr = httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	testutil.CheckSuccess(t, rr, 200)
+	if !hasSessionCookie(rr) {
+		t.Error("Expected fazt_session cookie after second factor")
+	}
+
+	// Challenge is single use
+	req = testutil.JSONRequest("POST", "/api/login/2fa", map[string]interface{}{
+		"token": token,
+		"code":  next,
+	})
+	rr = httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	testutil.CheckError(t, rr, 401, "UNAUTHORIZED")
+}
+
+// TestTwoFactorEnable_RequiredEnrollment tests enrolling with the login token when 2FA is required
+func TestTwoFactorEnable_RequiredEnrollment(t *testing.T) {
+	service := setupTwoFactorTest(t)
+	service.SetRequire2FA(true)
+
+	data := passwordLogin(t)
+	testutil.AssertFieldEquals(t, data, "two_factor_required", true)
+	testutil.AssertFieldEquals(t, data, "two_factor_enrolled", false)
+	token, _ := data["two_factor_token"].(string)
+
+	// Without enrollment the code step is refused
+	req := testutil.JSONRequest("POST", "/api/login/2fa", map[string]interface{}{
+		"token": token,
+		"code":  "000000",
+	})
+	rr := httptest.NewRecorder()
+	LoginTwoFactorHandler(rr, req)
+	testutil.CheckError(t, rr, 403, "FORBIDDEN")
+
+	req = testutil.JSONRequest("POST", "/api/2fa/enroll", map[string]interface{}{"token": token})
+	rr = httptest.NewRecorder()
+	TwoFactorEnrollHandler(rr, req)
+	data = testutil.CheckSuccess(t, rr, 200)
+	testutil.AssertFieldExists(t, data, "provisioning_uri")
+	secret, _ := data["secret"].(string)
+
+	code, _ := auth.TOTPCode(secret, time.Now())
+	req = testutil.JSONRequest("POST", "/api/2fa/enable", map[string]interface{}{
+		"token": token,
+		"code":  code,
+	})
+	rr = httptest.NewRecorder()
+	TwoFactorEnableHandler(rr, req)
+	data = testutil.CheckSuccess(t, rr, 200)
+	if codes, _ := data["recovery_codes"].([]interface{}); len(codes) != auth.RecoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %v", auth.RecoveryCodeCount, data["recovery_codes"])
+	}
+	if !hasSessionCookie(rr) {
+		t.Error("Expected enabling with a login token to complete the login")
+	}
+
+	// Disabling is refused while the server requires 2FA
+	var session string
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == "fazt_session" {
+			session = cookie.Value
+		}
+	}
+	req = testutil.JSONRequest("POST", "/api/2fa/disable", map[string]interface{}{"code": code})
+	req = testutil.WithSession(req, session)
+	rr = httptest.NewRecorder()
+	TwoFactorDisableHandler(rr, req)
+	testutil.CheckError(t, rr, 403, "FORBIDDEN")
+}
+
+// TestTwoFactorStatusHandler_Unauthorized tests status without a session
+func TestTwoFactorStatusHandler_Unauthorized(t *testing.T) {
+	setupTwoFactorTest(t)
+
+	rr := httptest.NewRecorder()
+	TwoFactorStatusHandler(rr, testutil.JSONRequest("GET", "/api/2fa", nil))
+	testutil.CheckError(t, rr, 401, "UNAUTHORIZED")
+}
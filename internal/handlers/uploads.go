@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/auth"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/uploads"
+	"github.com/fazt-sh/fazt/internal/virusscan"
+)
+
+// tusVersion is the resumable upload protocol version spoken (tus.io)
+const tusVersion = "1.0.0"
+
+// uploadChunkTimeout is how long one PATCH may take to arrive. The server's
+// read timeout is far shorter and would cut off chunks on slow links.
+const uploadChunkTimeout = 10 * time.Minute
+
+var uploadStore *uploads.Store
+
+// errUploadRefused is returned by a finish callback that refused a complete
+// upload and has already answered the request
+var errUploadRefused = errors.New("upload refused")
+
+// InitUploads sets the store resumable uploads are staged in
+func InitUploads(s *uploads.Store) {
+	uploadStore = s
+}
+
+// tusEndpoint serves the tus protocol at base: POST base creates an upload,
+// HEAD base/<id> returns its offset, PATCH base/<id> appends a chunk and
+// DELETE base/<id> cancels it. GET base/<id> returns the upload and, once
+// it completes, what became of it. Requests must carry a Tus-Resumable
+// header, which a cross-site form can't send.
+type tusEndpoint struct {
+	base string
+
+	// owner identifies the caller; uploads can only be resumed by whoever
+	// started them. It answers the request itself when there is no caller.
+	owner func(w http.ResponseWriter, r *http.Request) (string, bool)
+
+	// create fills in a new upload from its metadata and checks the caller
+	// may make it, answering the request itself when it refuses
+	create func(w http.ResponseWriter, r *http.Request, u *uploads.Upload) bool
+
+	// finish deploys or stores a complete upload and returns the result.
+	// errUploadRefused means it answered the request itself and the upload
+	// is discarded.
+	finish func(w http.ResponseWriter, r *http.Request, u *uploads.Upload, f *os.File) (map[string]interface{}, error)
+}
+
+func (e *tusEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if uploadStore == nil {
+		api.Error(w, http.StatusServiceUnavailable, "UPLOADS_UNAVAILABLE", "Resumable uploads are not enabled", nil)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, e.base), "/")
+	if r.Method == http.MethodOptions && id == "" {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,termination,expiration")
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(uploadStore.MaxSize(), 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != http.MethodGet && r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		api.Error(w, http.StatusPreconditionFailed, "TUS_VERSION", "Tus-Resumable: "+tusVersion+" header required", nil)
+		return
+	}
+
+	owner, ok := e.owner(w, r)
+	if !ok {
+		return
+	}
+
+	if id == "" {
+		if r.Method != http.MethodPost {
+			api.Error(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+			return
+		}
+		e.handleCreate(w, r, owner)
+		return
+	}
+
+	u, err := uploadStore.Get(id, owner)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Received, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+		w.Header().Set("Upload-Expires", time.Unix(u.ExpiresAt, 0).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		api.Success(w, http.StatusOK, u)
+	case http.MethodPatch:
+		e.handlePatch(w, r, u)
+	case http.MethodDelete:
+		if u.State == uploads.StateFinishing {
+			writeUploadError(w, uploads.ErrLocked)
+			return
+		}
+		if err := uploadStore.Delete(u); err != nil {
+			api.InternalError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		api.Error(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+	}
+}
+
+func (e *tusEndpoint) handleCreate(w http.ResponseWriter, r *http.Request, owner string) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		api.BadRequest(w, "Upload-Defer-Length is not supported; send Upload-Length")
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		api.BadRequest(w, "Upload-Length header required")
+		return
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+
+	u := &uploads.Upload{Owner: owner, Size: size, Metadata: meta}
+	if !e.create(w, r, u) {
+		return
+	}
+	if err := uploadStore.Create(u); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", e.base+"/"+u.ID)
+	w.Header().Set("Upload-Expires", time.Unix(u.ExpiresAt, 0).UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (e *tusEndpoint) handlePatch(w http.ResponseWriter, r *http.Request, u *uploads.Upload) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		api.Error(w, http.StatusUnsupportedMediaType, "UNSUPPORTED_MEDIA_TYPE", "Content-Type must be application/offset+octet-stream", nil)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		api.BadRequest(w, "Upload-Offset header required")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(uploadChunkTimeout))
+	rc.SetWriteDeadline(time.Now().Add(uploadChunkTimeout))
+
+	received, err := uploadStore.Append(u, offset, r.Body)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(received, 10))
+	if err != nil {
+		if errors.Is(err, uploads.ErrOffsetMismatch) || errors.Is(err, uploads.ErrLocked) || errors.Is(err, uploads.ErrNotUploading) {
+			writeUploadError(w, err)
+			return
+		}
+		// The client went away mid-chunk; what arrived is kept for the retry
+		log.Printf("Upload %s interrupted at %d of %d bytes: %v", u.ID, received, u.Size, err)
+		api.Error(w, http.StatusBadRequest, "UPLOAD_INTERRUPTED", "Chunk interrupted; resume from Upload-Offset", nil)
+		return
+	}
+
+	claimed, err := uploadStore.Claim(u)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	if !claimed {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	e.complete(w, r, u)
+}
+
+// complete finishes an upload once its last byte arrives. The result is
+// kept on the upload so a client that misses this response can GET it.
+func (e *tusEndpoint) complete(w http.ResponseWriter, r *http.Request, u *uploads.Upload) {
+	f, err := uploadStore.Open(u)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+	defer f.Close()
+
+	// Finish even if the client hangs up while it runs
+	r = r.WithContext(context.WithoutCancel(r.Context()))
+
+	result, err := e.finish(w, r, u, f)
+	if errors.Is(err, errUploadRefused) {
+		if err := uploadStore.Delete(u); err != nil {
+			log.Printf("Upload %s: failed to discard: %v", u.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		uploadStore.Finish(u, uploads.StateFailed, map[string]interface{}{"error": err.Error()})
+		writeUploadError(w, err)
+		return
+	}
+
+	result["upload_id"] = u.ID
+	if err := uploadStore.Finish(u, uploads.StateDone, result); err != nil {
+		log.Printf("Upload %s: failed to record result: %v", u.ID, err)
+	}
+	api.Success(w, http.StatusOK, result)
+}
+
+// writeUploadError answers a failed upload request
+func writeUploadError(w http.ResponseWriter, err error) {
+	var infected *virusscan.InfectedError
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		api.NotFound(w, "UPLOAD_NOT_FOUND", "Upload not found")
+	case errors.Is(err, uploads.ErrExpired):
+		api.Error(w, http.StatusGone, "UPLOAD_EXPIRED", "Upload expired; start it again", nil)
+	case errors.Is(err, uploads.ErrTooLarge):
+		api.Error(w, http.StatusRequestEntityTooLarge, "UPLOAD_TOO_LARGE",
+			fmt.Sprintf("Uploads are limited to %d MB", uploadStore.MaxSize()>>20), nil)
+	case errors.Is(err, uploads.ErrInsufficientStorage):
+		api.Error(w, http.StatusInsufficientStorage, "INSUFFICIENT_STORAGE", err.Error(), nil)
+	case errors.Is(err, uploads.ErrTooManyPending):
+		api.Error(w, http.StatusTooManyRequests, "TOO_MANY_UPLOADS",
+			fmt.Sprintf("At most %d unfinished uploads; finish or cancel one first", uploads.MaxPending), nil)
+	case errors.Is(err, uploads.ErrOffsetMismatch):
+		api.Error(w, http.StatusConflict, "OFFSET_MISMATCH", "Upload-Offset does not match; HEAD the upload to resume", nil)
+	case errors.Is(err, uploads.ErrLocked):
+		api.Error(w, http.StatusLocked, "UPLOAD_LOCKED", err.Error(), nil)
+	case errors.Is(err, uploads.ErrNotUploading):
+		api.Error(w, http.StatusConflict, "UPLOAD_COMPLETE", "Upload is complete; GET it for the result", nil)
+	case errors.As(err, &infected):
+		api.Error(w, http.StatusUnprocessableEntity, "VIRUS_FOUND", err.Error(),
+			map[string]interface{}{"signature": infected.Signature})
+	default:
+		writeDeployError(w, err)
+	}
+}
+
+// parseUploadMetadata decodes an Upload-Metadata header: comma-separated
+// keys, each followed by a space and its base64 value
+func parseUploadMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	if header == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("Invalid Upload-Metadata")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Upload-Metadata value for %s", key)
+		}
+		meta[key] = string(decoded)
+	}
+	return meta, nil
+}
+
+// uploadBlobPath returns the blob path an upload is stored at: its path
+// metadata, or uploads/<filename>
+func uploadBlobPath(meta map[string]string) (string, error) {
+	p := meta["path"]
+	if p == "" && meta["filename"] != "" {
+		p = "uploads/" + meta["filename"]
+	}
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", errors.New("Upload-Metadata needs a path")
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", errors.New("Invalid blob path: " + p)
+		}
+	}
+	return p, nil
+}
+
+// uploadMimeType returns the content type an upload declared
+func uploadMimeType(meta map[string]string) string {
+	for _, key := range []string{"content_type", "filetype"} {
+		if meta[key] != "" {
+			return meta[key]
+		}
+	}
+	return "application/octet-stream"
+}
+
+// withUploadForm sets the request's form to the upload's metadata, so
+// deploy options (spa, emergency, ...) read as they do on /api/deploy
+func withUploadForm(r *http.Request, meta map[string]string) *http.Request {
+	form := url.Values{}
+	for k, v := range meta {
+		form.Set(k, v)
+	}
+	r = r.Clone(r.Context())
+	r.Form = form
+	return r
+}
+
+// readUpload reads a complete upload into memory for the blob store
+func readUpload(u *uploads.Upload, f *os.File) ([]byte, error) {
+	data := make([]byte, u.Size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// deployScopedKey reports whether the caller is a deploy-scoped API key,
+// which may upload bundles but not blobs
+func deployScopedKey(r *http.Request) bool {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return false
+	}
+	key, err := hosting.AuthenticateAPIKey(database.GetDB(), strings.TrimPrefix(authHeader, "Bearer "))
+	return err == nil && key.Scope == hosting.ScopeDeploy
+}
+
+// apiUploads is the admin and CLI endpoint: deploy bundles, and blobs for
+// apps the caller edits
+var apiUploads = &tusEndpoint{
+	base: "/api/uploads",
+	owner: func(w http.ResponseWriter, r *http.Request) (string, bool) {
+		owner := adminActor(r)
+		if owner == "" {
+			if p := principalFromRequest(r); p != nil {
+				owner = p.UserID
+			}
+		}
+		if owner == "" {
+			api.Unauthorized(w, "Authentication required")
+			return "", false
+		}
+		return owner, true
+	},
+	create: func(w http.ResponseWriter, r *http.Request, u *uploads.Upload) bool {
+		db := database.GetDB()
+		switch kind := u.Metadata["kind"]; kind {
+		case uploads.KindDeploy:
+			siteName, err := deploySiteName(u.Metadata["site_name"])
+			if err != nil {
+				api.BadRequest(w, err.Error())
+				return false
+			}
+			if !auth.GetDeployLimiter().AllowDeploy(realip.FromRequest(r)) {
+				api.RateLimitExceeded(w, "Rate limit exceeded: max 5 deploys per minute")
+				return false
+			}
+			if _, ok := requireDeployAccess(w, withUploadForm(r, u.Metadata), db, siteName); !ok {
+				return false
+			}
+			u.Kind, u.AppID, u.Target = kind, siteName, siteName
+		case uploads.KindBlob:
+			if deployScopedKey(r) {
+				api.Forbidden(w, "API key scope only allows deploy uploads")
+				return false
+			}
+			blobPath, err := uploadBlobPath(u.Metadata)
+			if err != nil {
+				api.BadRequest(w, err.Error())
+				return false
+			}
+			site := u.Metadata["app"]
+			var appID string
+			if err := db.QueryRow(`SELECT id FROM apps WHERE title = ?`, site).Scan(&appID); err != nil {
+				api.NotFound(w, "APP_NOT_FOUND", "App not found")
+				return false
+			}
+			if !requireAppRole(w, r, appID, hosting.AppRoleEditor) {
+				return false
+			}
+			u.Kind, u.AppID, u.Target = kind, site, blobPath
+		default:
+			api.BadRequest(w, "Upload-Metadata kind must be deploy or blob")
+			return false
+		}
+		return true
+	},
+	finish: func(w http.ResponseWriter, r *http.Request, u *uploads.Upload, f *os.File) (map[string]interface{}, error) {
+		db := database.GetDB()
+		actor := adminActor(r)
+
+		if u.Kind == uploads.KindDeploy {
+			r = withUploadForm(r, u.Metadata)
+			isNew, ok := requireDeployAccess(w, r, db, u.Target)
+			if !ok {
+				return nil, errUploadRefused
+			}
+			zipReader, err := zip.NewReader(f, u.Size)
+			if err != nil {
+				api.BadRequest(w, "Invalid ZIP file: "+err.Error())
+				return nil, errUploadRefused
+			}
+			if err := hosting.CheckDeployLimits(db, u.Target, u.Owner, zipReader); err != nil {
+				return nil, err
+			}
+			d, err := deployTracker.Start(u.Target, u.Owner)
+			if err != nil {
+				return nil, err
+			}
+			result, err := runDeploy(r, db, u.Target, isNew, zipReader, actor, d)
+			if err != nil {
+				return nil, err
+			}
+			auth.GetDeployLimiter().RecordDeploy(realip.FromRequest(r))
+			log.Printf("Site deployed: %s by %s (resumable upload %s), %d files, %d bytes",
+				u.Target, actor, u.ID, result["file_count"], result["size_bytes"])
+			return result, nil
+		}
+
+		data, err := readUpload(u, f)
+		if err != nil {
+			return nil, err
+		}
+		blobs := storage.NewSQLBlobStoreWithWriter(db, storage.GetWriter())
+		if err := blobs.Put(r.Context(), u.AppID, u.Target, data, uploadMimeType(u.Metadata)); err != nil {
+			return nil, err
+		}
+		activity.LogFromRequest(r, actor, "blob", u.AppID+"/"+u.Target, "upload", activity.WeightDataMutation,
+			map[string]interface{}{"size": u.Size, "upload_id": u.ID})
+		return map[string]interface{}{"app": u.AppID, "path": u.Target, "size": u.Size}, nil
+	},
+}
+
+// UploadsHandler speaks the tus resumable upload protocol for deploy
+// bundles (kind=deploy, site_name) and app blobs (kind=blob, app, path)
+// OPTIONS|POST /api/uploads, HEAD|PATCH|GET|DELETE /api/uploads/{id}
+func UploadsHandler(w http.ResponseWriter, r *http.Request) {
+	apiUploads.serve(w, r)
+}
+
+// ServeAppUpload speaks the tus protocol on an app's own domain, storing a
+// signed-in user's uploads in their private blobs (fazt.app.user.s3)
+// OPTIONS|POST /_uploads, HEAD|PATCH|GET|DELETE /_uploads/{id}
+func ServeAppUpload(w http.ResponseWriter, r *http.Request, siteID string) {
+	endpoint := &tusEndpoint{
+		base: "/_uploads",
+		owner: func(w http.ResponseWriter, r *http.Request) (string, bool) {
+			if authService != nil {
+				if user, err := authService.GetAppUserFromRequest(r, siteID); err == nil && user != nil {
+					return user.ID, true
+				}
+				if user, err := authService.GetSessionFromRequest(r); err == nil && user != nil {
+					return user.ID, true
+				}
+			}
+			api.Unauthorized(w, "Sign in to upload")
+			return "", false
+		},
+		create: func(w http.ResponseWriter, r *http.Request, u *uploads.Upload) bool {
+			blobPath, err := uploadBlobPath(u.Metadata)
+			if err != nil {
+				api.BadRequest(w, err.Error())
+				return false
+			}
+			u.Kind, u.AppID, u.Target = uploads.KindUserBlob, siteID, blobPath
+			return true
+		},
+		finish: func(w http.ResponseWriter, r *http.Request, u *uploads.Upload, f *os.File) (map[string]interface{}, error) {
+			data, err := readUpload(u, f)
+			if err != nil {
+				return nil, err
+			}
+			blobs := storage.NewUserScopedBlobs(database.GetDB(), storage.GetWriter(), u.AppID, u.Owner)
+			if err := blobs.Put(r.Context(), u.Target, data, uploadMimeType(u.Metadata)); err != nil {
+				return nil, err
+			}
+			activity.LogFromRequest(r, u.Owner, "blob", u.AppID+"/u/"+u.Owner+"/"+u.Target, "upload", activity.WeightDataMutation,
+				map[string]interface{}{"size": u.Size, "upload_id": u.ID})
+			return map[string]interface{}{"path": u.Target, "size": u.Size}, nil
+		},
+	}
+	endpoint.serve(w, r)
+}
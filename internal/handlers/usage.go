@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+// UsageExportHandler returns per-user usage for a period, as JSON or CSV.
+// The period is ?month=YYYY-MM, or ?from= and ?to= dates; it defaults to the
+// current month.
+// GET /api/system/usage/export?month=2026-09&format=csv
+func UsageExportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	p, err := parseUsagePeriod(r)
+	if err != nil {
+		api.BadRequest(w, err.Error())
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		api.BadRequest(w, "format must be json or csv")
+		return
+	}
+
+	// Include requests metered since the last periodic flush
+	db := database.GetDB()
+	usage.Flush(db)
+
+	rows, err := usage.Export(db, p)
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="usage-%s-%s.csv"`, p.Start(), p.End()))
+		usage.WriteCSV(w, p, rows)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"from":  p.Start(),
+		"to":    p.End(),
+		"usage": rows,
+	})
+}
+
+func parseUsagePeriod(r *http.Request) (usage.Period, error) {
+	q := r.URL.Query()
+	if month := q.Get("month"); month != "" {
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			return usage.Period{}, errors.New("month must be YYYY-MM")
+		}
+		p := usage.MonthPeriod(t)
+		return usage.NewPeriod(p.From, p.To)
+	}
+
+	from, to := usage.MonthPeriod(time.Now()).From, time.Now()
+	var err error
+	if s := q.Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			return usage.Period{}, errors.New("from must be YYYY-MM-DD")
+		}
+	}
+	if s := q.Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			return usage.Period{}, errors.New("to must be YYYY-MM-DD")
+		}
+	}
+	p, err := usage.NewPeriod(from, to)
+	if err != nil {
+		return usage.Period{}, errors.New("period must be within the last 90 days and end after it starts")
+	}
+	return p, nil
+}
+
+// UsageHooksListHandler lists usage export hooks
+// GET /api/system/usage/hooks
+func UsageHooksListHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	hooks, err := usage.ListHooks(database.GetDB())
+	if err != nil {
+		api.InternalError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"hooks": hooks,
+	})
+}
+
+// UsageHookCreateRequest is the body of POST /api/system/usage/hooks
+type UsageHookCreateRequest struct {
+	URL    string `json:"url"`
+	Period string `json:"period"`
+	Secret string `json:"secret,omitempty"` // Generated when empty
+}
+
+// UsageHookCreateHandler registers a usage export hook. The response holds
+// the signing secret, which is not shown again.
+// POST /api/system/usage/hooks
+func UsageHookCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	var req UsageHookCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.InvalidJSON(w, "invalid request body")
+		return
+	}
+	if req.Period == "" {
+		req.Period = usage.PeriodMonth
+	}
+
+	hook, err := usage.CreateHook(database.GetDB(), req.URL, req.Period, req.Secret)
+	if err != nil {
+		writeUsageHookError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusCreated, hook)
+}
+
+// UsageHookDeleteHandler removes a usage export hook
+// DELETE /api/system/usage/hooks/{id}
+func UsageHookDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.BadRequest(w, "invalid hook id")
+		return
+	}
+
+	if _, ok := requireAdminAuth(w, r); !ok {
+		return
+	}
+
+	if err := usage.DeleteHook(database.GetDB(), id); err != nil {
+		writeUsageHookError(w, err)
+		return
+	}
+
+	api.Success(w, http.StatusOK, map[string]interface{}{
+		"deleted": id,
+	})
+}
+
+func writeUsageHookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, usage.ErrHookNotFound):
+		api.NotFound(w, "HOOK_NOT_FOUND", err.Error())
+	case errors.Is(err, usage.ErrInvalidHook):
+		api.BadRequest(w, err.Error())
+	default:
+		api.InternalError(w, err)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/fazt-sh/fazt/internal/analytics"
 	"github.com/fazt-sh/fazt/internal/api"
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 // WebhookHandler handles incoming webhooks
@@ -100,7 +101,7 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := realip.FromRequest(r)
 	userAgent := r.UserAgent()
 
 	// Add to analytics buffer
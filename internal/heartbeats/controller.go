@@ -0,0 +1,88 @@
+package heartbeats
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notify"
+)
+
+// tickInterval is how often the controller looks for missed check-ins
+const tickInterval = 30 * time.Second
+
+// Controller watches for missed check-ins and alerts when a heartbeat goes
+// missing or comes back
+type Controller struct {
+	db     *sql.DB
+	notify func(title, message string)
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewController creates a controller that alerts through notify
+func NewController(db *sql.DB) *Controller {
+	return &Controller{
+		db: db,
+		notify: func(title, message string) {
+			if err := notify.Send(notify.EventHeartbeatMissed, title, message); err != nil {
+				log.Printf("Heartbeats: failed to send alert: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background watch loop
+func (c *Controller) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick(time.Now())
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the watch loop
+func (c *Controller) Stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Tick marks heartbeats past their deadline as down and alerts, once per
+// missed check-in
+func (c *Controller) Tick(now time.Time) {
+	missed, err := markMissed(c.db, now)
+	if err != nil {
+		log.Printf("Heartbeats: failed to check deadlines: %v", err)
+	}
+	for _, h := range missed {
+		c.notify("Heartbeat missed", fmt.Sprintf("%s hasn't checked in since %s (expected every %s)",
+			h.Name, time.Unix(*h.LastCheckinAt, 0).Format("2006-01-02 15:04"),
+			time.Duration(h.PeriodSeconds)*time.Second))
+	}
+}
+
+// CheckIn records a check-in and alerts if the heartbeat was missing
+func (c *Controller) CheckIn(token, ip string) (*Heartbeat, error) {
+	h, recovered, err := CheckIn(c.db, token, ip, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if recovered {
+		c.notify("Heartbeat recovered", fmt.Sprintf("%s checked in again", h.Name))
+	}
+	return h, nil
+}
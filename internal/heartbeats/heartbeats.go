@@ -0,0 +1,266 @@
+// Package heartbeats implements check-in monitoring, a dead man's switch:
+// an external script (a backup job, a cron task on a home-lab box) calls a
+// heartbeat's secret URL whenever it runs, and the admin is notified when a
+// check-in is missed by more than the grace period, and again when check-ins
+// resume. It complements uptime monitoring for things that can't be probed
+// from outside.
+package heartbeats
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// MinPeriod is the shortest expected time between check-ins
+	MinPeriod = time.Minute
+
+	// MaxPeriod is the longest expected time between check-ins
+	MaxPeriod = 35 * 24 * time.Hour
+
+	// DefaultGrace is how late a check-in may be by default
+	DefaultGrace = 5 * time.Minute
+
+	// MaxGrace is the longest grace period
+	MaxGrace = 7 * 24 * time.Hour
+)
+
+// Statuses
+const (
+	StatusNew  = "new"  // Never checked in, or resumed: not watched until the next check-in
+	StatusUp   = "up"   // Checking in on time
+	StatusDown = "down" // A check-in was missed
+)
+
+// Common errors
+var (
+	ErrNotFound = errors.New("heartbeat not found")
+	ErrExists   = errors.New("a heartbeat with that name already exists")
+	ErrInvalid  = errors.New("invalid heartbeat")
+)
+
+// namePattern matches valid heartbeat names
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Heartbeat is a stored check-in monitor
+type Heartbeat struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	Token         string `json:"token"`
+	URL           string `json:"url,omitempty"` // Check-in URL, set by the API
+	PeriodSeconds int64  `json:"period_seconds"`
+	GraceSeconds  int64  `json:"grace_seconds"`
+	Status        string `json:"status"`
+	Enabled       bool   `json:"enabled"`
+	Checkins      int64  `json:"checkins"`
+	LastCheckinAt *int64 `json:"last_checkin_at,omitempty"`
+	LastCheckinIP string `json:"last_checkin_ip,omitempty"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+// Deadline is when a heartbeat that is up counts as down, or zero if it
+// has never checked in
+func (h *Heartbeat) Deadline() time.Time {
+	if h.LastCheckinAt == nil {
+		return time.Time{}
+	}
+	return time.Unix(*h.LastCheckinAt+h.PeriodSeconds+h.GraceSeconds, 0)
+}
+
+// Config describes a new heartbeat
+type Config struct {
+	Name   string
+	Period time.Duration // Expected time between check-ins
+	Grace  time.Duration // default 5m
+}
+
+// Validate checks the config and fills in defaults
+func (c *Config) Validate() error {
+	c.Name = strings.ToLower(strings.TrimSpace(c.Name))
+	if !namePattern.MatchString(c.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits, - and _", ErrInvalid)
+	}
+	if c.Period < MinPeriod || c.Period > MaxPeriod {
+		return fmt.Errorf("%w: period must be between %s and 35d", ErrInvalid, MinPeriod)
+	}
+	if c.Grace == 0 {
+		c.Grace = DefaultGrace
+	}
+	if c.Grace < 0 || c.Grace > MaxGrace {
+		return fmt.Errorf("%w: grace must be at most 7d", ErrInvalid)
+	}
+	return nil
+}
+
+// Create stores a new heartbeat with a fresh token. It isn't watched until
+// its first check-in.
+func Create(db *sql.DB, cfg Config, now time.Time) (*Heartbeat, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	_, err := db.Exec(`
+		INSERT INTO heartbeats (name, token, period_seconds, grace_seconds, created_at) VALUES (?, ?, ?, ?, ?)
+	`, cfg.Name, hex.EncodeToString(b), int64(cfg.Period.Seconds()), int64(cfg.Grace.Seconds()), now.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrExists
+		}
+		return nil, err
+	}
+	return Get(db, cfg.Name)
+}
+
+// Get returns a heartbeat by name
+func Get(db *sql.DB, name string) (*Heartbeat, error) {
+	return getWhere(db, `name = ?`, strings.ToLower(name))
+}
+
+// List returns all heartbeats
+func List(db *sql.DB) ([]Heartbeat, error) {
+	return queryHeartbeats(db, heartbeatSelect+` ORDER BY name`)
+}
+
+// SetEnabled pauses or resumes a heartbeat. A resumed heartbeat is new
+// again, so time spent paused isn't reported as a missed check-in.
+func SetEnabled(db *sql.DB, name string, enabled bool) (*Heartbeat, error) {
+	query := `UPDATE heartbeats SET enabled = 0 WHERE name = ?`
+	if enabled {
+		query = `UPDATE heartbeats SET enabled = 1, status = 'new' WHERE name = ? AND enabled = 0`
+	}
+	if _, err := db.Exec(query, strings.ToLower(name)); err != nil {
+		return nil, err
+	}
+	return Get(db, name)
+}
+
+// Delete removes a heartbeat; its URL stops working
+func Delete(db *sql.DB, name string) error {
+	res, err := db.Exec(`DELETE FROM heartbeats WHERE name = ?`, strings.ToLower(name))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CheckIn records a check-in by token. recovered is true when the heartbeat
+// was down and is watched, i.e. the admin was told it went missing.
+func CheckIn(db *sql.DB, token, ip string, now time.Time) (hb *Heartbeat, recovered bool, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var enabled bool
+	err = tx.QueryRow(`SELECT status, enabled FROM heartbeats WHERE token = ?`, token).Scan(&status, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE heartbeats SET status = 'up', checkins = checkins + 1, last_checkin_at = ?, last_checkin_ip = ?
+		WHERE token = ?
+	`, now.Unix(), ip, token); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	hb, err = getWhere(db, `token = ?`, token)
+	if err != nil {
+		return nil, false, err
+	}
+	return hb, status == StatusDown && enabled, nil
+}
+
+// markMissed marks watched heartbeats whose deadline passed as down and
+// returns them
+func markMissed(db *sql.DB, now time.Time) ([]Heartbeat, error) {
+	overdue, err := queryHeartbeats(db, heartbeatSelect+`
+		WHERE enabled = 1 AND status = 'up' AND last_checkin_at + period_seconds + grace_seconds < ?
+	`, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	missed := []Heartbeat{}
+	for _, h := range overdue {
+		// A check-in since the query wins
+		res, err := db.Exec(`UPDATE heartbeats SET status = 'down' WHERE id = ? AND status = 'up' AND last_checkin_at = ?`,
+			h.ID, *h.LastCheckinAt)
+		if err != nil {
+			return missed, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			h.Status = StatusDown
+			missed = append(missed, h)
+		}
+	}
+	return missed, nil
+}
+
+const heartbeatSelect = `
+	SELECT id, name, token, period_seconds, grace_seconds, status, enabled, checkins, last_checkin_at,
+		COALESCE(last_checkin_ip, ''), created_at
+	FROM heartbeats`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanHeartbeat(row rowScanner) (*Heartbeat, error) {
+	var h Heartbeat
+	var last sql.NullInt64
+	if err := row.Scan(&h.ID, &h.Name, &h.Token, &h.PeriodSeconds, &h.GraceSeconds, &h.Status, &h.Enabled,
+		&h.Checkins, &last, &h.LastCheckinIP, &h.CreatedAt); err != nil {
+		return nil, err
+	}
+	if last.Valid {
+		h.LastCheckinAt = &last.Int64
+	}
+	return &h, nil
+}
+
+func getWhere(db *sql.DB, where string, arg interface{}) (*Heartbeat, error) {
+	h, err := scanHeartbeat(db.QueryRow(heartbeatSelect+` WHERE `+where, arg))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return h, err
+}
+
+func queryHeartbeats(db *sql.DB, query string, args ...interface{}) ([]Heartbeat, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Heartbeat{}
+	for rows.Next() {
+		h, err := scanHeartbeat(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *h)
+	}
+	return list, rows.Err()
+}
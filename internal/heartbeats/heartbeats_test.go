@@ -0,0 +1,129 @@
+package heartbeats
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestCreate(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Now()
+
+	h, err := Create(db, Config{Name: "Nightly-Backup", Period: 24 * time.Hour}, now)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if h.Name != "nightly-backup" || h.PeriodSeconds != 86400 || h.GraceSeconds != 300 || h.Status != StatusNew ||
+		!h.Enabled || len(h.Token) != 32 {
+		t.Errorf("Unexpected heartbeat: %+v", h)
+	}
+
+	if _, err := Create(db, Config{Name: "nightly-backup", Period: time.Hour}, now); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+
+	invalid := map[string]Config{
+		"name":       {Name: "has space", Period: time.Hour},
+		"short":      {Name: "x", Period: 30 * time.Second},
+		"long":       {Name: "x", Period: 40 * 24 * time.Hour},
+		"long grace": {Name: "x", Period: time.Hour, Grace: 8 * 24 * time.Hour},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+		}
+	}
+
+	if err := Delete(db, "nightly-backup"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, _, err := CheckIn(db, h.Token, "", now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted heartbeat's token, got %v", err)
+	}
+}
+
+// testController returns a controller whose alerts are collected
+func testController(db *sql.DB) (*Controller, *[]string) {
+	var alerts []string
+	c := &Controller{
+		db:     db,
+		notify: func(title, message string) { alerts = append(alerts, title) },
+		done:   make(chan struct{}),
+	}
+	return c, &alerts
+}
+
+func TestMissedCheckIn(t *testing.T) {
+	db := dbtest.Open(t)
+	c, alerts := testController(db)
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	h, _ := Create(db, Config{Name: "backup", Period: time.Hour, Grace: 10 * time.Minute}, start)
+
+	// Never checked in: not watched
+	c.Tick(start.Add(24 * time.Hour))
+	if len(*alerts) != 0 {
+		t.Fatalf("Expected no alerts before the first check-in, got %v", *alerts)
+	}
+
+	got, recovered, err := CheckIn(db, h.Token, "203.0.113.7", start)
+	if err != nil || recovered || got.Status != StatusUp || got.Checkins != 1 || got.LastCheckinIP != "203.0.113.7" {
+		t.Fatalf("Unexpected check-in: %+v, %v, %v", got, recovered, err)
+	}
+
+	// Late, but within the grace period
+	c.Tick(start.Add(65 * time.Minute))
+	if len(*alerts) != 0 {
+		t.Errorf("Expected no alert within the grace period, got %v", *alerts)
+	}
+
+	// Missed: one alert, however many ticks
+	c.Tick(start.Add(71 * time.Minute))
+	c.Tick(start.Add(2 * time.Hour))
+	if len(*alerts) != 1 || (*alerts)[0] != "Heartbeat missed" {
+		t.Errorf("Expected one missed alert, got %v", *alerts)
+	}
+	if got, _ := Get(db, "backup"); got.Status != StatusDown {
+		t.Errorf("Expected status down, got %s", got.Status)
+	}
+
+	if _, err := c.CheckIn(h.Token, ""); err != nil {
+		t.Fatalf("CheckIn failed: %v", err)
+	}
+	if len(*alerts) != 2 || (*alerts)[1] != "Heartbeat recovered" {
+		t.Errorf("Expected a recovered alert, got %v", *alerts)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	db := dbtest.Open(t)
+	c, alerts := testController(db)
+	start := time.Date(2026, 10, 16, 2, 0, 0, 0, time.Local)
+
+	h, _ := Create(db, Config{Name: "cron", Period: time.Hour}, start)
+	CheckIn(db, h.Token, "", start)
+
+	SetEnabled(db, "cron", false)
+	c.Tick(start.Add(3 * time.Hour))
+	if len(*alerts) != 0 {
+		t.Errorf("Expected no alerts while paused, got %v", *alerts)
+	}
+
+	// Resumed: watched again from the next check-in
+	got, err := SetEnabled(db, "cron", true)
+	if err != nil || !got.Enabled || got.Status != StatusNew {
+		t.Fatalf("Unexpected resumed heartbeat: %+v, %v", got, err)
+	}
+	c.Tick(start.Add(4 * time.Hour))
+	if len(*alerts) != 0 {
+		t.Errorf("Expected no alert for time spent paused, got %v", *alerts)
+	}
+
+	if _, err := SetEnabled(db, "missing", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -7,7 +7,8 @@ import (
 )
 
 // analyticsScript is the minimal tracking snippet injected into HTML pages
-// It sends a pageview beacon to the admin subdomain's /track endpoint
+// It sends a pageview beacon (with screen size, referrer and the page's utm_*
+// parameters) to the admin subdomain's /track endpoint
 // The script extracts the base domain and constructs the admin URL dynamically
 // For root domains (zyt.app), uses full hostname. For subdomains (tetris.zyt.app), strips subdomain.
 const analyticsScript = `<script>(function(){
@@ -16,7 +17,8 @@ var s=h.split('.').slice(1).join('.');
 var d=(s&&s.includes('.'))?s:h;
 var p=location.port&&location.port!=='80'&&location.port!=='443'?':'+location.port:'';
 var u=location.protocol+'//admin.'+d+p+'/track';
-navigator.sendBeacon(u,JSON.stringify({h:h,p:location.pathname,e:'pageview'}))
+var q={};new URLSearchParams(location.search).forEach(function(v,k){if(k.indexOf('utm_')===0)q[k]=v});
+navigator.sendBeacon(u,JSON.stringify({h:h,p:location.pathname,e:'pageview',ref:document.referrer,q:q,sw:screen.width,sh:screen.height}))
 })();</script>`
 
 // InjectAnalytics injects the analytics tracking script into HTML content
@@ -27,30 +29,49 @@ func InjectAnalytics(content []byte, siteID string) []byte {
 		return content
 	}
 
-	// Find </body> tag (case-insensitive)
+	return injectBeforeTag(content, "</body>", analyticsScript)
+}
+
+// injectBeforeTag inserts snippet right before the last occurrence of the
+// closing tag (case-insensitive). Returns content unchanged if tag not found.
+func injectBeforeTag(content []byte, tag, snippet string) []byte {
 	lower := bytes.ToLower(content)
-	idx := bytes.LastIndex(lower, []byte("</body>"))
+	idx := bytes.LastIndex(lower, []byte(tag))
 	if idx == -1 {
-		// No body tag found, return original
 		return content
 	}
 
-	// Insert script before </body>
-	result := make([]byte, 0, len(content)+len(analyticsScript))
+	result := make([]byte, 0, len(content)+len(snippet))
 	result = append(result, content[:idx]...)
-	result = append(result, analyticsScript...)
+	result = append(result, snippet...)
 	result = append(result, content[idx:]...)
 
 	return result
 }
 
-// isAnalyticsDisabled checks if analytics is disabled for the site via manifest.json
-// Looks for: { "analytics": { "enabled": false } }
+// ForgetAnalyticsInject drops the cached per-app injection flags, for when
+// apps are changed in the database directly: their flag or title updated,
+// or the apps removed
+func ForgetAnalyticsInject() {
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		sqlFS.forgetAnalyticsInject()
+	}
+}
+
+// isAnalyticsDisabled checks if analytics injection is disabled for the site.
+// The per-app toggle (apps.analytics_inject) is checked first, then manifest.json:
+// { "analytics": { "enabled": false } }
 func isAnalyticsDisabled(siteID string) bool {
 	if fs == nil {
 		return false
 	}
 
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		if inject, err := sqlFS.GetAppAnalyticsInject(siteID); err == nil && !inject {
+			return true
+		}
+	}
+
 	// Read manifest.json from VFS
 	file, err := fs.ReadFile(siteID, "manifest.json")
 	if err != nil {
@@ -0,0 +1,258 @@
+package hosting
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Storage copied into a fork can be anonymized, so experiments don't carry
+// users' personal data. An app's manifest.json maps fields to the kind of
+// fake value that replaces them:
+//
+//	"anonymize": {"email": "email", "name": "name", "author.phone": "phone"}
+//
+// Fields are dotted paths into documents and JSON object kv values; arrays
+// along the way are walked. Fake values are derived from the real ones with
+// a salt drawn for each fork, so the same email becomes the same fake email
+// throughout a fork and lookups between records still match, while fakes
+// can't be traced back by hashing guessed values. Blobs are copied as they
+// are.
+
+// AnonymizeKinds are the fake value kinds anonymization rules can use
+var AnonymizeKinds = []string{
+	"name", "first_name", "last_name", "email", "phone", "address",
+	"username", "ip", "uuid", "text", "redact", "null",
+}
+
+var (
+	fakeFirstNames = []string{"Alex", "Blair", "Casey", "Dana", "Eli", "Frankie", "Gray", "Harper",
+		"Indy", "Jules", "Kai", "Lee", "Morgan", "Noor", "Quinn", "Robin"}
+	fakeLastNames = []string{"Abbott", "Baker", "Chen", "Diaz", "Evans", "Fischer", "Garcia", "Hughes",
+		"Ito", "Jensen", "Kowalski", "Lopez", "Moreau", "Novak", "Okafor", "Park"}
+	fakeStreets = []string{"Oak", "Maple", "Cedar", "Elm", "Pine", "Birch", "Willow", "Aspen"}
+)
+
+// ValidateAnonymizeRules checks that rules only use known fake value kinds
+func ValidateAnonymizeRules(rules map[string]string) error {
+	fields := make([]string, 0, len(rules))
+	for field := range rules {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if field == "" || strings.HasPrefix(field, ".") || strings.HasSuffix(field, ".") {
+			return fmt.Errorf("anonymize: invalid field %q", field)
+		}
+		known := false
+		for _, k := range AnonymizeKinds {
+			known = known || k == rules[field]
+		}
+		if !known {
+			return fmt.Errorf("anonymize: unknown kind %q for %s; use one of %s",
+				rules[field], field, strings.Join(AnonymizeKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// AnonymizeJSON replaces the fields rules name in a JSON object with fake
+// values derived with salt. Anything else, including JSON that isn't an
+// object, is returned unchanged.
+func AnonymizeJSON(data string, rules map[string]string, salt []byte) string {
+	if len(rules) == 0 {
+		return data
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		return data
+	}
+	for field, kind := range rules {
+		anonymizeField(doc, strings.Split(field, "."), kind, salt)
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return data
+	}
+	return string(out)
+}
+
+// anonymizeField replaces the value at path below v, walking arrays
+func anonymizeField(v interface{}, path []string, kind string, salt []byte) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		real, ok := v[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			if real != nil {
+				v[path[0]] = fakeValue(kind, real, salt)
+			}
+			return
+		}
+		anonymizeField(real, path[1:], kind, salt)
+	case []interface{}:
+		for _, item := range v {
+			anonymizeField(item, path, kind, salt)
+		}
+	}
+}
+
+// fakeValue returns a fake value of kind, derived from the real value and
+// salt so the same input always gives the same output for a salt
+func fakeValue(kind string, real interface{}, salt []byte) interface{} {
+	b, _ := json.Marshal(real)
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(kind + "\x00"))
+	mac.Write(b)
+	sum := mac.Sum(nil)
+	n := binary.BigEndian.Uint64(sum[:8])
+	first := fakeFirstNames[n%uint64(len(fakeFirstNames))]
+	last := fakeLastNames[(n>>8)%uint64(len(fakeLastNames))]
+
+	switch kind {
+	case "name":
+		return first + " " + last
+	case "first_name":
+		return first
+	case "last_name":
+		return last
+	case "email":
+		return fmt.Sprintf("%s.%s.%04d@example.com", strings.ToLower(first), strings.ToLower(last), (n>>16)%10000)
+	case "phone":
+		// 555-01xx numbers are reserved for fiction
+		return fmt.Sprintf("+1-202-555-01%02d", (n>>16)%100)
+	case "address":
+		return fmt.Sprintf("%d %s St", 1+(n>>16)%9999, fakeStreets[(n>>32)%uint64(len(fakeStreets))])
+	case "username":
+		return fmt.Sprintf("user_%x", sum[8:12])
+	case "ip":
+		// 192.0.2.0/24 is reserved for documentation
+		return fmt.Sprintf("192.0.2.%d", 1+(n>>16)%254)
+	case "uuid":
+		u := sum[16:32]
+		u[6] = u[6]&0x0f | 0x40 // Version 4
+		u[8] = u[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+	case "text":
+		return "Lorem ipsum dolor sit amet."
+	case "redact":
+		return "[redacted]"
+	}
+	return nil
+}
+
+// AnonymizeRulesFor returns the anonymization rules in an app's
+// manifest.json, checked. Malformed manifests and broken rules give
+// ErrInvalidManifest, so real data isn't copied for want of rules.
+func AnonymizeRulesFor(db *sql.DB, appID string) (map[string]string, error) {
+	manifest, err := storedManifest(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateAnonymizeRules(manifest.Anonymize); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	return manifest.Anonymize, nil
+}
+
+// CopyForkStorage copies the storage under site from to site to in tx, for
+// a fork served as to: kv entries that haven't expired, documents and
+// blobs, with documents and kv values anonymized by rules under a fresh
+// salt. It returns how many entries were copied.
+func CopyForkStorage(tx *sql.Tx, from, to string, rules map[string]string) (int64, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return 0, err
+	}
+
+	// Rows are read before writing, as the transaction has one connection
+	type kvRow struct {
+		key     string
+		value   sql.NullString
+		userID  sql.NullString
+		expires sql.NullInt64
+	}
+	type docRow struct {
+		collection, id, data string
+		userID               sql.NullString
+	}
+
+	var kv []kvRow
+	rows, err := tx.Query(`
+		SELECT key, value, user_id, expires_at FROM app_kv
+		WHERE app_id = ? AND (expires_at IS NULL OR expires_at > unixepoch())
+	`, from)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var r kvRow
+		if err := rows.Scan(&r.key, &r.value, &r.userID, &r.expires); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		kv = append(kv, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var docs []docRow
+	rows, err = tx.Query(`SELECT collection, id, data, user_id FROM app_docs WHERE app_id = ?`, from)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var r docRow
+		if err := rows.Scan(&r.collection, &r.id, &r.data, &r.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		docs = append(docs, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var copied int64
+	for _, r := range kv {
+		if r.value.Valid {
+			r.value.String = AnonymizeJSON(r.value.String, rules, salt)
+		}
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO app_kv (app_id, key, value, expires_at, user_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, to, r.key, r.value, r.expires, r.userID); err != nil {
+			return 0, err
+		}
+		copied++
+	}
+	for _, r := range docs {
+		if _, err := tx.Exec(`
+			INSERT OR REPLACE INTO app_docs (app_id, collection, id, data, user_id)
+			VALUES (?, ?, ?, ?, ?)
+		`, to, r.collection, r.id, AnonymizeJSON(r.data, rules, salt), r.userID); err != nil {
+			return 0, err
+		}
+		copied++
+	}
+	res, err := tx.Exec(`
+		INSERT OR REPLACE INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, md5, user_id)
+		SELECT ?, path, data, mime_type, size_bytes, hash, md5, user_id FROM app_blobs WHERE app_id = ?
+	`, to, from)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return copied + n, nil
+}
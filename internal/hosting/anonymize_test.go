@@ -0,0 +1,78 @@
+package hosting
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeJSON(t *testing.T) {
+	rules := map[string]string{"email": "email", "author.name": "name", "attendees.phone": "phone", "note": "null"}
+	in := `{"email":"ann@real.com","author":{"name":"Ann Lee"},"attendees":[{"phone":"+44 7700"},{"phone":"+1 415"}],"note":"hi","n":3}`
+
+	salt := []byte("fork-1")
+	out := AnonymizeJSON(in, rules, salt)
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("Expected JSON, got %s", out)
+	}
+	for _, real := range []string{"ann@real.com", "Ann Lee", "+44 7700", "+1 415"} {
+		if strings.Contains(out, real) {
+			t.Errorf("Expected %s replaced, got %s", real, out)
+		}
+	}
+	if !strings.HasSuffix(doc["email"].(string), "@example.com") {
+		t.Errorf("Expected a fake email, got %v", doc["email"])
+	}
+	if doc["note"] != nil || doc["n"] != float64(3) {
+		t.Errorf("Expected note nulled and n kept, got %v, %v", doc["note"], doc["n"])
+	}
+
+	// The same value gets the same fake, so records still match up, but
+	// only within a fork
+	if again := AnonymizeJSON(`{"email":"ann@real.com"}`, rules, salt); !strings.Contains(again, doc["email"].(string)) {
+		t.Errorf("Expected the same fake email, got %s", again)
+	}
+	if other := AnonymizeJSON(`{"email":"ann@real.com"}`, rules, []byte("fork-2")); strings.Contains(other, doc["email"].(string)) {
+		t.Errorf("Expected another fake email with another salt, got %s", other)
+	}
+	for _, s := range []string{`"plain"`, `[1,2]`, `not json`} {
+		if got := AnonymizeJSON(s, rules, salt); got != s {
+			t.Errorf("Expected %s unchanged, got %s", s, got)
+		}
+	}
+}
+
+func TestValidateAnonymizeRules(t *testing.T) {
+	if err := ValidateAnonymizeRules(map[string]string{"email": "email", "a.b": "uuid"}); err != nil {
+		t.Errorf("Expected valid rules, got %v", err)
+	}
+	for _, rules := range []map[string]string{{"email": "mail"}, {"a.": "name"}, {"": "name"}} {
+		if err := ValidateAnonymizeRules(rules); err == nil {
+			t.Errorf("Expected %v refused", rules)
+		}
+	}
+}
+
+func TestAnonymizeRulesFor(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.Exec(`INSERT INTO apps (id, title) VALUES ('app_1', 'signups')`)
+
+	// Without a manifest there's nothing to anonymize
+	if rules, err := AnonymizeRulesFor(db, "app_1"); err != nil || len(rules) != 0 {
+		t.Errorf("Expected no rules, got %v, %v", rules, err)
+	}
+
+	db.Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, hash)
+		VALUES ('signups', 'app_1', 'manifest.json', '{"anonymize": {"email": "email"}', 32, 'h1')`)
+	if _, err := AnonymizeRulesFor(db, "app_1"); !errors.Is(err, ErrInvalidManifest) {
+		t.Errorf("Expected ErrInvalidManifest for a corrupt manifest, got %v", err)
+	}
+
+	db.Exec(`UPDATE files SET content = '{"anonymize": {"email": "mail"}}' WHERE path = 'manifest.json'`)
+	if _, err := AnonymizeRulesFor(db, "app_1"); !errors.Is(err, ErrInvalidManifest) {
+		t.Errorf("Expected ErrInvalidManifest for an unknown kind, got %v", err)
+	}
+}
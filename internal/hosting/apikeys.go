@@ -0,0 +1,238 @@
+package hosting
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// API key scopes
+const (
+	ScopeAdmin  = "admin"  // Full access
+	ScopeDeploy = "deploy" // Deploy apps, read-only otherwise
+	ScopeRead   = "read"   // Read-only
+)
+
+var (
+	ErrInvalidScope  = errors.New("scope must be 'admin', 'deploy', or 'read'")
+	ErrAPIKeyExpired = errors.New("API key has expired")
+)
+
+// APIKey is an authenticated API key
+type APIKey struct {
+	ID        int64
+	Name      string
+	Scope     string
+	UserID    string // Acts for this user's app memberships ("" = server-wide)
+	AppID     string // Limited to this app ("" = all apps)
+	ExpiresAt *time.Time
+}
+
+// APIKeyOptions configures a new API key
+type APIKeyOptions struct {
+	Name      string
+	Scope     string
+	UserID    string
+	AppID     string
+	ExpiresAt *time.Time
+}
+
+// IsValidScope reports whether scope is a known API key scope
+func IsValidScope(scope string) bool {
+	return scope == ScopeAdmin || scope == ScopeDeploy || scope == ScopeRead
+}
+
+// Allows reports whether the key's scope permits the request.
+// Key management and the command gateway always require the admin scope.
+// Keys stored without a scope predate scopes and keep full access.
+// Deploy keys may also use resumable uploads, which refuse them anything
+// but bundles.
+func (k *APIKey) Allows(method, path string) bool {
+	if k.Scope == ScopeAdmin || k.Scope == "" {
+		return true
+	}
+	if strings.HasPrefix(path, "/api/keys") || strings.HasPrefix(path, "/api/cmd") {
+		return false
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return true
+	}
+	if k.Scope != ScopeDeploy {
+		return false
+	}
+	return (method == http.MethodPost && path == "/api/deploy") ||
+		path == "/api/uploads" || strings.HasPrefix(path, "/api/uploads/")
+}
+
+// Bcrypt makes every lookup expensive, and a request may be authenticated
+// more than once (middleware, then handler). Verified tokens are remembered
+// briefly by hash; the row is still re-read so revocation takes effect at once.
+const verifiedKeyTTL = time.Minute
+
+type verifiedKey struct {
+	id    int64
+	until time.Time
+}
+
+var (
+	verifiedKeysMu sync.Mutex
+	verifiedKeys   = map[[32]byte]verifiedKey{}
+)
+
+// AuthenticateAPIKey validates a token and returns the key it belongs to.
+// Revoked keys are never matched; expired keys return ErrAPIKeyExpired.
+func AuthenticateAPIKey(db *sql.DB, token string) (*APIKey, error) {
+	sum := sha256.Sum256([]byte(token))
+
+	verifiedKeysMu.Lock()
+	cached, ok := verifiedKeys[sum]
+	verifiedKeysMu.Unlock()
+
+	var key *APIKey
+	if ok && time.Now().Before(cached.until) {
+		k, err := GetAPIKey(db, cached.id)
+		if err == nil {
+			key = k
+		}
+	}
+
+	if key == nil {
+		id, err := matchAPIKey(db, token)
+		if err != nil {
+			return nil, err
+		}
+		if key, err = GetAPIKey(db, id); err != nil {
+			return nil, fmt.Errorf("invalid API key")
+		}
+		verifiedKeysMu.Lock()
+		verifiedKeys[sum] = verifiedKey{id: id, until: time.Now().Add(verifiedKeyTTL)}
+		verifiedKeysMu.Unlock()
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	db.Exec("UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", key.ID)
+	return key, nil
+}
+
+// matchAPIKey finds the active key whose hash matches token
+func matchAPIKey(db *sql.DB, token string) (int64, error) {
+	rows, err := db.Query("SELECT id, key_hash FROM api_keys WHERE revoked_at IS NULL")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query API keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var keyHash string
+		if err := rows.Scan(&id, &keyHash); err != nil {
+			continue
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(token)); err == nil {
+			return id, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read API keys: %w", err)
+	}
+
+	return 0, fmt.Errorf("invalid API key")
+}
+
+// GetAPIKey returns an active (non-revoked) key by ID
+func GetAPIKey(db *sql.DB, id int64) (*APIKey, error) {
+	k := &APIKey{}
+	var expires sql.NullTime
+	err := db.QueryRow(`
+		SELECT id, name, COALESCE(scopes, ''), COALESCE(user_id, ''), COALESCE(app_id, ''), expires_at
+		FROM api_keys WHERE id = ? AND revoked_at IS NULL
+	`, id).Scan(&k.ID, &k.Name, &k.Scope, &k.UserID, &k.AppID, &expires)
+	if err != nil {
+		return nil, err
+	}
+	if expires.Valid {
+		k.ExpiresAt = &expires.Time
+	}
+	return k, nil
+}
+
+// CreateScopedAPIKey creates an API key and returns the raw token
+func CreateScopedAPIKey(db *sql.DB, opts APIKeyOptions) (string, error) {
+	if opts.Scope == "" {
+		opts.Scope = ScopeAdmin
+	}
+	if !IsValidScope(opts.Scope) {
+		return "", ErrInvalidScope
+	}
+
+	// Generate random token (32 bytes = 64 hex chars)
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash token: %w", err)
+	}
+
+	var user, app, expires interface{}
+	if opts.UserID != "" {
+		user = opts.UserID
+	}
+	if opts.AppID != "" {
+		app = opts.AppID
+	}
+	if opts.ExpiresAt != nil {
+		expires = opts.ExpiresAt.UTC().Format("2006-01-02 15:04:05")
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO api_keys (name, key_hash, scopes, user_id, app_id, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		opts.Name, string(hash), opts.Scope, user, app, expires,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store API key: %w", err)
+	}
+
+	return token, nil
+}
+
+// RevokeAPIKey marks a key as revoked. Revoked keys stay listed for auditing.
+func RevokeAPIKey(db *sql.DB, id int64) error {
+	res, err := db.Exec("UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = ? AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ParseExpiry parses a key lifetime such as "30d", "12h", or "90m"
+func ParseExpiry(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry %q", s)
+	}
+	return d, nil
+}
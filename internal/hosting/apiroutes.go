@@ -0,0 +1,301 @@
+package hosting
+
+import (
+	"database/sql"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// APIRoute maps a URL pattern to a file under api/. Routes come from file
+// names, Next.js style:
+//
+//	api/users/index.js          -> /api/users
+//	api/users/[id].js           -> /api/users/:id
+//	api/files/[...path].js      -> /api/files/*path (one or more segments)
+//
+// A file is only a route if it exports handlers by method name
+// (exports.GET = ... or export function GET). Files or directories starting with "_" and
+// api/main.js are never routes, so helper modules stay requireable.
+//
+// A .wasm file (api/resize.wasm) is a WASI handler taking every method, run
+// CGI-style instead of in the JS runtime.
+type APIRoute struct {
+	Pattern string   `json:"pattern"`
+	File    string   `json:"file"`
+	Methods []string `json:"methods"`
+	WASI    bool     `json:"wasi,omitempty"`
+
+	segments []routeSegment
+}
+
+type routeSegment struct {
+	kind  segmentKind
+	value string // literal text, or the param name
+}
+
+type segmentKind int
+
+// Ordered by precedence: a literal segment beats a param, which beats a
+// catch-all
+const (
+	segmentStatic segmentKind = iota
+	segmentParam
+	segmentCatchAll
+)
+
+// APIRouteTable is an app's file-based routes, most specific first
+type APIRouteTable struct {
+	Routes []APIRoute `json:"routes"`
+}
+
+// HTTP methods a route file can export
+var routeMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+var (
+	methodExportRe = regexp.MustCompile(`exports\s*(?:\.\s*(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)|\[\s*['"](GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)['"]\s*\])\s*=`)
+	objectExportRe = regexp.MustCompile(`module\.exports\s*=\s*\{`)
+	objectMethodRe = regexp.MustCompile(`\b(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\b\s*[:,(}\n]`)
+	esmMethodRe    = regexp.MustCompile(`(?m)^[ \t]*export\s+(?:(?:async\s+)?function\b\s*\*?\s*|const\s+|let\s+|var\s+)(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\b`)
+	esmListRe      = regexp.MustCompile(`(?m)^[ \t]*export\s*\{([^}]*)\}`)
+	esmListItemRe  = regexp.MustCompile(`\b(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS)\s*$`)
+)
+
+var (
+	apiRoutes   = make(map[string]*APIRouteTable)
+	apiRoutesMu sync.RWMutex
+)
+
+// BuildAPIRouteTable builds a route table from an app's api/ sources,
+// keyed by VFS path. WASI handlers' sources aren't needed.
+func BuildAPIRouteTable(sources map[string]string) *APIRouteTable {
+	table := &APIRouteTable{Routes: []APIRoute{}}
+	seen := make(map[string]bool)
+
+	paths := make([]string, 0, len(sources))
+	for p := range sources {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, file := range paths {
+		route, ok := parseRouteFile(file)
+		if !ok {
+			continue
+		}
+		if route.WASI {
+			route.Methods = routeMethods
+		} else {
+			route.Methods = exportedMethods(sources[file])
+		}
+		if len(route.Methods) == 0 {
+			continue
+		}
+		// api/users.js and api/users/index.js both claim /api/users; the
+		// first in path order wins
+		if seen[route.Pattern] {
+			log.Printf("API routes: %s duplicates %s, ignored", file, route.Pattern)
+			continue
+		}
+		seen[route.Pattern] = true
+		table.Routes = append(table.Routes, route)
+	}
+
+	sort.SliceStable(table.Routes, func(i, j int) bool {
+		return moreSpecific(table.Routes[i].segments, table.Routes[j].segments)
+	})
+	return table
+}
+
+// parseRouteFile turns api/users/[id].js into its route
+func parseRouteFile(file string) (APIRoute, bool) {
+	rel, ok := strings.CutPrefix(file, "api/")
+	if !ok || rel == "main.js" {
+		return APIRoute{}, false
+	}
+	route := APIRoute{File: file}
+	switch path.Ext(rel) {
+	case ".js":
+	case ".wasm":
+		route.WASI = true
+	default:
+		return APIRoute{}, false
+	}
+	parts := strings.Split(strings.TrimSuffix(rel, path.Ext(rel)), "/")
+	if parts[len(parts)-1] == "index" {
+		parts = parts[:len(parts)-1]
+	}
+
+	pattern := []string{"/api"}
+	for i, part := range parts {
+		if part == "" || strings.HasPrefix(part, "_") {
+			return APIRoute{}, false
+		}
+		seg := routeSegment{kind: segmentStatic, value: part}
+		if name, ok := strings.CutPrefix(part, "[..."); ok && strings.HasSuffix(name, "]") {
+			if i != len(parts)-1 {
+				return APIRoute{}, false // catch-all must be last
+			}
+			seg = routeSegment{kind: segmentCatchAll, value: strings.TrimSuffix(name, "]")}
+			pattern = append(pattern, "*"+seg.value)
+		} else if strings.HasPrefix(part, "[") && strings.HasSuffix(part, "]") {
+			seg = routeSegment{kind: segmentParam, value: part[1 : len(part)-1]}
+			pattern = append(pattern, ":"+seg.value)
+		} else {
+			pattern = append(pattern, part)
+		}
+		if seg.kind != segmentStatic && seg.value == "" {
+			return APIRoute{}, false
+		}
+		route.segments = append(route.segments, seg)
+	}
+	route.Pattern = strings.Join(pattern, "/")
+	return route, true
+}
+
+// exportedMethods finds the HTTP methods a route file exports
+func exportedMethods(source string) []string {
+	found := make(map[string]bool)
+	for _, m := range methodExportRe.FindAllStringSubmatch(source, -1) {
+		found[m[1]+m[2]] = true
+	}
+	if loc := objectExportRe.FindStringIndex(source); loc != nil {
+		for _, m := range objectMethodRe.FindAllStringSubmatch(source[loc[1]:], -1) {
+			found[m[1]] = true
+		}
+	}
+	// ES modules: export function GET, export { handler as POST }
+	for _, m := range esmMethodRe.FindAllStringSubmatch(source, -1) {
+		found[m[1]] = true
+	}
+	for _, m := range esmListRe.FindAllStringSubmatch(source, -1) {
+		for _, item := range strings.Split(m[1], ",") {
+			if name := esmListItemRe.FindStringSubmatch(strings.TrimSpace(item)); name != nil {
+				found[name[1]] = true
+			}
+		}
+	}
+
+	methods := make([]string, 0, len(found))
+	for _, method := range routeMethods {
+		if found[method] {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// moreSpecific orders routes so literal segments are tried before params
+// and params before catch-alls
+func moreSpecific(a, b []routeSegment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind {
+			return a[i].kind < b[i].kind
+		}
+	}
+	return len(a) > len(b)
+}
+
+// Match returns the route for a request path and its params
+func (t *APIRouteTable) Match(path string) (*APIRoute, map[string]string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api"), "/"), "/")
+	if len(parts) == 1 && parts[0] == "" {
+		parts = nil
+	}
+
+	for i := range t.Routes {
+		if params, ok := t.Routes[i].match(parts); ok {
+			return &t.Routes[i], params, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (r *APIRoute) match(parts []string) (map[string]string, bool) {
+	params := make(map[string]string)
+	for i, seg := range r.segments {
+		if seg.kind == segmentCatchAll {
+			if i >= len(parts) {
+				return nil, false
+			}
+			params[seg.value] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch seg.kind {
+		case segmentStatic:
+			if parts[i] != seg.value {
+				return nil, false
+			}
+		case segmentParam:
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[seg.value] = parts[i]
+		}
+	}
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// Allows reports whether the route handles method. HEAD falls back to GET.
+func (r *APIRoute) Allows(method string) bool {
+	for _, m := range r.Methods {
+		if m == method || (method == "HEAD" && m == "GET") {
+			return true
+		}
+	}
+	return false
+}
+
+// APIRoutesFor returns an app's route table, building it on first use for
+// apps whose files were written outside a deploy (forks, templates)
+func APIRoutesFor(db *sql.DB, siteID string) *APIRouteTable {
+	apiRoutesMu.RLock()
+	table, ok := apiRoutes[siteID]
+	apiRoutesMu.RUnlock()
+	if ok {
+		return table
+	}
+	return LoadAPIRoutes(db, siteID)
+}
+
+// LoadAPIRoutes rebuilds an app's route table from its deployed files
+func LoadAPIRoutes(db *sql.DB, siteID string) *APIRouteTable {
+	sources := make(map[string]string)
+	rows, err := db.Query(`
+		SELECT path, CASE WHEN path LIKE '%.js' THEN content ELSE '' END FROM files
+		WHERE site_id = ? AND (path LIKE 'api/%.js' OR path LIKE 'api/%.wasm')
+	`, siteID)
+	if err != nil {
+		// Don't cache: the next request tries again
+		return &APIRouteTable{}
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path, content string
+		if err := rows.Scan(&path, &content); err == nil {
+			sources[path] = content
+		}
+	}
+
+	table := BuildAPIRouteTable(sources)
+	apiRoutesMu.Lock()
+	apiRoutes[siteID] = table
+	apiRoutesMu.Unlock()
+	return table
+}
+
+// forgetAPIRoutes drops an app's cached route table
+func forgetAPIRoutes(siteID string) {
+	apiRoutesMu.Lock()
+	delete(apiRoutes, siteID)
+	apiRoutesMu.Unlock()
+}
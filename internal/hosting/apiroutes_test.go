@@ -0,0 +1,85 @@
+package hosting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAPIRouteTable(t *testing.T) {
+	table := BuildAPIRouteTable(map[string]string{
+		"api/main.js":              `exports.GET = function() {}`,
+		"api/users/index.js":       `exports.GET = function(req) {}; exports.POST = function(req) {}`,
+		"api/users/[id].js":        `module.exports = { GET: get, DELETE(req) {} }`,
+		"api/users/me.js":          `exports["GET"] = function() {}`,
+		"api/files/[...path].js":   `exports.PUT = function() {}`,
+		"api/_lib/db.js":           `exports.GET = function() {}`,
+		"api/helpers.js":           `module.exports = function format(x) { return x }`,
+		"api/[...rest]/broken.js":  `exports.GET = function() {}`,
+		"api/users/[id]/posts.js":  `exports.GET = function() {}`,
+		"public/api/users/[id].js": `exports.GET = function() {}`,
+		"api/posts/[slug].js":      "export async function GET(req) {}\nconst del = () => {}\nexport { del as DELETE }",
+	})
+
+	var patterns []string
+	for _, r := range table.Routes {
+		patterns = append(patterns, r.Pattern)
+	}
+	want := []string{"/api/users/me", "/api/users/:id/posts", "/api/posts/:slug", "/api/users/:id", "/api/files/*path", "/api/users"}
+	if !reflect.DeepEqual(patterns, want) {
+		t.Fatalf("Routes = %v, want %v", patterns, want)
+	}
+
+	tests := []struct {
+		path    string
+		file    string
+		params  map[string]string
+		methods []string
+	}{
+		{"/api/users", "api/users/index.js", map[string]string{}, []string{"GET", "POST"}},
+		{"/api/users/", "api/users/index.js", map[string]string{}, []string{"GET", "POST"}},
+		{"/api/users/me", "api/users/me.js", map[string]string{}, []string{"GET"}},
+		{"/api/users/42", "api/users/[id].js", map[string]string{"id": "42"}, []string{"GET", "DELETE"}},
+		{"/api/users/42/posts", "api/users/[id]/posts.js", map[string]string{"id": "42"}, []string{"GET"}},
+		{"/api/files/a/b.txt", "api/files/[...path].js", map[string]string{"path": "a/b.txt"}, []string{"PUT"}},
+		{"/api/posts/hello", "api/posts/[slug].js", map[string]string{"slug": "hello"}, []string{"GET", "DELETE"}},
+		{"/api/files", "", nil, nil},
+		{"/api/other", "", nil, nil},
+	}
+	for _, tt := range tests {
+		route, params, ok := table.Match(tt.path)
+		if tt.file == "" {
+			if ok {
+				t.Errorf("Match(%q) = %s, want no match", tt.path, route.File)
+			}
+			continue
+		}
+		if !ok || route.File != tt.file || !reflect.DeepEqual(params, tt.params) || !reflect.DeepEqual(route.Methods, tt.methods) {
+			t.Errorf("Match(%q) = %v %v %v, want %s %v %v", tt.path, route, params, ok, tt.file, tt.params, tt.methods)
+		}
+	}
+
+	route, _, _ := table.Match("/api/users/42")
+	if !route.Allows("GET") || !route.Allows("HEAD") || route.Allows("POST") {
+		t.Errorf("Unexpected Allows for methods %v", route.Methods)
+	}
+}
+
+func TestAPIRouteTable_WASI(t *testing.T) {
+	table := BuildAPIRouteTable(map[string]string{
+		"api/resize/[size].wasm": "",
+		"api/_lib/math.wasm":     "",
+		"api/search.wasm":        "",
+		"api/search.js":          `exports.GET = function() {}`,
+	})
+
+	route, params, ok := table.Match("/api/resize/200")
+	if !ok || !route.WASI || params["size"] != "200" || !reflect.DeepEqual(route.Methods, routeMethods) {
+		t.Errorf("Match(/api/resize/200) = %v %v %v, want a WASI route taking every method", route, params, ok)
+	}
+	if route, _, ok := table.Match("/api/search"); !ok || route.File != "api/search.js" {
+		t.Errorf("Expected api/search.js to win /api/search, got %v", route)
+	}
+	if _, _, ok := table.Match("/api/_lib/math"); ok {
+		t.Error("Expected modules under _lib not to be routes")
+	}
+}
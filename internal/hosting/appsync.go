@@ -0,0 +1,447 @@
+package hosting
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// App sync copies one app from a fazt server to another, so two servers can
+// be primary and standby for the apps that matter. Each side lists its
+// copy's files and storage rows with a hash of each; only what differs is
+// sent, and what the other side has beyond the source is removed.
+
+// Sync item kinds
+const (
+	SyncFile = "file" // A deployed file, by path
+	SyncKV   = "kv"   // A key-value entry
+	SyncDoc  = "ds"   // A document, by collection and ID
+	SyncBlob = "s3"   // A blob, by path
+)
+
+// SyncStorageKinds are the storage kinds a sync can include besides files
+var SyncStorageKinds = []string{SyncKV, SyncDoc, SyncBlob}
+
+// ErrSyncSite is returned for storage under a site that isn't the app's
+var ErrSyncSite = errors.New("site isn't the app's")
+
+// SyncItem is a file or storage row of an app with a hash of its content.
+// Storage rows are under the site the app's handlers run as: its name or
+// one of its aliases.
+type SyncItem struct {
+	Kind       string `json:"kind"`
+	Site       string `json:"site,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Key        string `json:"key"` // Path, kv key or document ID
+	Hash       string `json:"hash"`
+	Size       int64  `json:"size"`
+}
+
+// id identifies the item within its app
+func (i SyncItem) id() string {
+	return i.Kind + "\x00" + i.Site + "\x00" + i.Collection + "\x00" + i.Key
+}
+
+// SyncData is an item with its content
+type SyncData struct {
+	SyncItem
+	Data      []byte `json:"data"`
+	MimeType  string `json:"mime_type,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+}
+
+// SyncSites returns the sites an app's storage is kept under: its name and
+// its aliases. Storage under the app's ID is left out, as IDs differ from
+// server to server.
+func SyncSites(db *sql.DB, appID string) ([]string, error) {
+	rows, err := db.Query(`
+		SELECT title FROM apps WHERE id = ? AND COALESCE(title, '') != ''
+		UNION
+		SELECT subdomain FROM aliases
+		WHERE json_extract(targets, '$.app_id') = ? AND type IN ('proxy', 'app')
+	`, appID, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sites []string
+	for rows.Next() {
+		var site string
+		if err := rows.Scan(&site); err != nil {
+			return nil, err
+		}
+		sites = append(sites, site)
+	}
+	return sites, rows.Err()
+}
+
+// SyncManifest lists an app's files, and its storage of the given kinds,
+// with a hash of each, sorted
+func SyncManifest(db *sql.DB, appID string, kinds []string) ([]SyncItem, error) {
+	title, err := appTitle(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	items := []SyncItem{}
+	rows, err := db.Query(`
+		SELECT path, size_bytes, COALESCE(hash, ''),
+			CASE WHEN COALESCE(hash, '') = '' THEN content END
+		FROM files
+		WHERE app_id = ? OR (site_id = ? AND app_id IS NULL)
+	`, appID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		i := SyncItem{Kind: SyncFile}
+		var content []byte
+		if err := rows.Scan(&i.Key, &i.Size, &i.Hash, &content); err != nil {
+			return nil, err
+		}
+		if i.Hash == "" {
+			i.Hash = syncHash(string(content))
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sites, err := SyncSites(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range kinds {
+		for _, site := range sites {
+			found, err := syncStorageItems(db, kind, site)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, found...)
+		}
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].id() < items[b].id() })
+	return items, nil
+}
+
+// syncStorageItems lists a site's storage of one kind with hashes. Expired
+// kv entries are left out.
+func syncStorageItems(db *sql.DB, kind, site string) ([]SyncItem, error) {
+	var query string
+	switch kind {
+	case SyncKV:
+		query = `SELECT '', key, COALESCE(value, ''), COALESCE(expires_at, 0), '' FROM app_kv
+			WHERE app_id = ? AND (expires_at IS NULL OR expires_at > unixepoch())`
+	case SyncDoc:
+		query = `SELECT collection, id, data, 0, '' FROM app_docs WHERE app_id = ?`
+	case SyncBlob:
+		query = `SELECT '', path, '', size_bytes, hash FROM app_blobs WHERE app_id = ?`
+	default:
+		return nil, fmt.Errorf("unknown storage kind %q", kind)
+	}
+	rows, err := db.Query(query, site)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []SyncItem
+	for rows.Next() {
+		i := SyncItem{Kind: kind, Site: site}
+		var value string
+		var n int64
+		if err := rows.Scan(&i.Collection, &i.Key, &value, &n, &i.Hash); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case SyncKV:
+			// An entry changes with its expiry as well as its value
+			i.Hash = syncHash(value + "\x00" + strconv.FormatInt(n, 10))
+			i.Size = int64(len(value))
+		case SyncDoc:
+			i.Hash = syncHash(value)
+			i.Size = int64(len(value))
+		case SyncBlob:
+			i.Size = n
+		}
+		items = append(items, i)
+	}
+	return items, rows.Err()
+}
+
+func syncHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// SyncContent returns the content of an app's items, as listed by
+// SyncManifest. Items the app no longer has are skipped.
+func SyncContent(db *sql.DB, appID string, items []SyncItem) ([]SyncData, error) {
+	title, err := appTitle(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	sites, err := syncSiteSet(db, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := []SyncData{}
+	for _, i := range items {
+		d := SyncData{SyncItem: i}
+		var userID sql.NullString
+		var expires sql.NullInt64
+		switch i.Kind {
+		case SyncFile:
+			err = db.QueryRow(`
+				SELECT content, COALESCE(mime_type, '') FROM files
+				WHERE (app_id = ? OR (site_id = ? AND app_id IS NULL)) AND path = ?
+			`, appID, title, i.Key).Scan(&d.Data, &d.MimeType)
+		case SyncKV, SyncDoc, SyncBlob:
+			if !sites[i.Site] {
+				return nil, fmt.Errorf("%w: %s", ErrSyncSite, i.Site)
+			}
+			switch i.Kind {
+			case SyncKV:
+				err = db.QueryRow(`SELECT COALESCE(value, ''), user_id, expires_at FROM app_kv WHERE app_id = ? AND key = ?`,
+					i.Site, i.Key).Scan(&d.Data, &userID, &expires)
+			case SyncDoc:
+				err = db.QueryRow(`SELECT data, user_id FROM app_docs WHERE app_id = ? AND collection = ? AND id = ?`,
+					i.Site, i.Collection, i.Key).Scan(&d.Data, &userID)
+			case SyncBlob:
+				err = db.QueryRow(`SELECT data, mime_type, user_id FROM app_blobs WHERE app_id = ? AND path = ?`,
+					i.Site, i.Key).Scan(&d.Data, &d.MimeType, &userID)
+			}
+		default:
+			return nil, fmt.Errorf("unknown sync kind %q", i.Kind)
+		}
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.UserID = userID.String
+		if expires.Valid {
+			d.ExpiresAt = &expires.Int64
+		}
+		data = append(data, d)
+	}
+	return data, nil
+}
+
+// SyncChange is an item a sync puts or removes
+type SyncChange struct {
+	SyncItem
+	Status string `json:"status"` // added, modified, removed
+}
+
+// DiffSync compares a source's items with a destination's: the source's
+// items the destination lacks are added, those it has different are
+// modified, and the destination's items the source doesn't have are
+// removed
+func DiffSync(from, to []SyncItem) []SyncChange {
+	have := make(map[string]string, len(to))
+	for _, i := range to {
+		have[i.id()] = i.Hash
+	}
+	want := make(map[string]bool, len(from))
+	changes := []SyncChange{}
+	for _, i := range from {
+		want[i.id()] = true
+		if hash, ok := have[i.id()]; !ok {
+			changes = append(changes, SyncChange{SyncItem: i, Status: "added"})
+		} else if hash != i.Hash {
+			changes = append(changes, SyncChange{SyncItem: i, Status: "modified"})
+		}
+	}
+	for _, i := range to {
+		if !want[i.id()] {
+			changes = append(changes, SyncChange{SyncItem: i, Status: "removed"})
+		}
+	}
+	return changes
+}
+
+// SyncFiles returns an app's files with the synced ones put and removed,
+// ready to deploy. An empty appID is an app that doesn't exist yet.
+func SyncFiles(db *sql.DB, appID string, put []SyncData, remove []SyncItem) ([]DeployFile, error) {
+	files := map[string][]byte{}
+	if appID != "" {
+		title, err := appTitle(db, appID)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := db.Query(`
+			SELECT path, content FROM files
+			WHERE app_id = ? OR (site_id = ? AND app_id IS NULL)
+		`, appID, title)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			var content []byte
+			if err := rows.Scan(&path, &content); err != nil {
+				return nil, err
+			}
+			files[path] = content
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, i := range remove {
+		if i.Kind == SyncFile {
+			delete(files, i.Key)
+		}
+	}
+	for _, d := range put {
+		if d.Kind == SyncFile {
+			files[d.Key] = d.Data
+		}
+	}
+	deploy := make([]DeployFile, 0, len(files))
+	for path, content := range files {
+		deploy = append(deploy, DeployFile{Path: path, Content: content})
+	}
+	sort.Slice(deploy, func(a, b int) bool { return deploy[a].Path < deploy[b].Path })
+	return deploy, nil
+}
+
+// ApplySyncStorage writes synced storage rows to an app and removes the
+// ones the source doesn't have, in one transaction. Rows must be under the
+// app's sites. It returns the rows written and removed.
+func ApplySyncStorage(db *sql.DB, appID string, put []SyncData, remove []SyncItem) (int64, int64, error) {
+	sites, err := syncSiteSet(db, appID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, d := range put {
+		if d.Kind != SyncFile && !sites[d.Site] {
+			return 0, 0, fmt.Errorf("%w: %s", ErrSyncSite, d.Site)
+		}
+	}
+	for _, i := range remove {
+		if i.Kind != SyncFile && !sites[i.Site] {
+			return 0, 0, fmt.Errorf("%w: %s", ErrSyncSite, i.Site)
+		}
+	}
+
+	var written, removed int64
+	blobSites := map[string]bool{}
+	err = storage.QueueWriteTx(context.Background(), db, func(tx *sql.Tx) error {
+		written, removed = 0, 0
+		for _, d := range put {
+			var userID interface{}
+			if d.UserID != "" {
+				userID = d.UserID
+			}
+			var err error
+			switch d.Kind {
+			case SyncFile:
+				continue
+			case SyncKV:
+				_, err = tx.Exec(`
+					INSERT INTO app_kv (app_id, key, value, expires_at, user_id, updated_at)
+					VALUES (?, ?, ?, ?, ?, unixepoch())
+					ON CONFLICT (app_id, key) DO UPDATE SET value = excluded.value,
+						expires_at = excluded.expires_at, user_id = excluded.user_id, updated_at = excluded.updated_at
+				`, d.Site, d.Key, string(d.Data), d.ExpiresAt, userID)
+			case SyncDoc:
+				_, err = tx.Exec(`
+					INSERT INTO app_docs (app_id, collection, id, data, user_id, updated_at)
+					VALUES (?, ?, ?, ?, ?, unixepoch())
+					ON CONFLICT (app_id, collection, id) DO UPDATE SET data = excluded.data,
+						user_id = excluded.user_id, updated_at = excluded.updated_at
+				`, d.Site, d.Collection, d.Key, string(d.Data), userID)
+			case SyncBlob:
+				// The source's hash is kept so the next sync sees no change
+				hash := d.Hash
+				if hash == "" {
+					hash = syncHash(string(d.Data))
+				}
+				_, err = tx.Exec(`
+					INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, user_id, updated_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, unixepoch())
+					ON CONFLICT (app_id, path) DO UPDATE SET data = excluded.data, mime_type = excluded.mime_type,
+						size_bytes = excluded.size_bytes, hash = excluded.hash, user_id = excluded.user_id,
+						updated_at = excluded.updated_at
+				`, d.Site, d.Key, d.Data, d.MimeType, len(d.Data), hash, userID)
+				blobSites[d.Site] = true
+			default:
+				return fmt.Errorf("unknown sync kind %q", d.Kind)
+			}
+			if err != nil {
+				return fmt.Errorf("write %s %s: %w", d.Kind, d.Key, err)
+			}
+			written++
+		}
+
+		for _, i := range remove {
+			var res sql.Result
+			var err error
+			switch i.Kind {
+			case SyncFile:
+				continue
+			case SyncKV:
+				res, err = tx.Exec(`DELETE FROM app_kv WHERE app_id = ? AND key = ?`, i.Site, i.Key)
+			case SyncDoc:
+				res, err = tx.Exec(`DELETE FROM app_docs WHERE app_id = ? AND collection = ? AND id = ?`,
+					i.Site, i.Collection, i.Key)
+			case SyncBlob:
+				res, err = tx.Exec(`DELETE FROM app_blobs WHERE app_id = ? AND path = ?`, i.Site, i.Key)
+				blobSites[i.Site] = true
+			default:
+				return fmt.Errorf("unknown sync kind %q", i.Kind)
+			}
+			if err != nil {
+				return fmt.Errorf("remove %s %s: %w", i.Kind, i.Key, err)
+			}
+			n, _ := res.RowsAffected()
+			removed += n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Resized copies of replaced images
+	for site := range blobSites {
+		media.ForgetApp(site)
+	}
+	return written, removed, nil
+}
+
+// syncSiteSet is SyncSites as a set
+func syncSiteSet(db *sql.DB, appID string) (map[string]bool, error) {
+	sites, err := SyncSites(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(sites))
+	for _, s := range sites {
+		set[s] = true
+	}
+	return set, nil
+}
+
+// appTitle returns an app's name
+func appTitle(db *sql.DB, appID string) (string, error) {
+	var title string
+	err := db.QueryRow(`SELECT COALESCE(title, '') FROM apps WHERE id = ?`, appID).Scan(&title)
+	return title, err
+}
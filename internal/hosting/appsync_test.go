@@ -0,0 +1,121 @@
+package hosting
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestAppSync(t *testing.T) {
+	exec := func(db *sql.DB, query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	src, dst := dbtest.Open(t), dbtest.Open(t)
+
+	// The same app has a different ID on each server
+	exec(src, `INSERT INTO apps (id, title) VALUES ('app_src', 'blog')`)
+	exec(dst, `INSERT INTO apps (id, title) VALUES ('app_dst', 'blog')`)
+	exec(src, `INSERT INTO aliases (subdomain, type, targets) VALUES ('news', 'app', '{"app_id":"app_src"}')`)
+	exec(src, `INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash) VALUES
+		('blog', 'app_src', 'index.html', 'v2', 2, 'text/html', 'h2'),
+		('blog', 'app_src', 'new.js', 'x', 1, 'text/javascript', 'h3')`)
+	exec(dst, `INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash) VALUES
+		('blog', 'app_dst', 'index.html', 'v1', 2, 'text/html', 'h1'),
+		('blog', 'app_dst', 'old.js', 'y', 1, 'text/javascript', 'h4')`)
+	exec(src, `INSERT INTO app_kv (app_id, key, value, user_id) VALUES ('blog', 'count', '2', 'u1'), ('news', 'n', '1', NULL)`)
+	exec(dst, `INSERT INTO app_kv (app_id, key, value) VALUES ('blog', 'count', '1'), ('blog', 'stale', 'x')`)
+	exec(src, `INSERT INTO app_docs (app_id, collection, id, data) VALUES ('blog', 'posts', 'p1', '{"t":"hi"}')`)
+	exec(src, `INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash) VALUES ('blog', 'a.txt', 'abc', 'text/plain', 3, 'hb')`)
+
+	from, err := SyncManifest(src, "app_src", SyncStorageKinds)
+	if err != nil {
+		t.Fatalf("SyncManifest failed: %v", err)
+	}
+	to, err := SyncManifest(dst, "app_dst", SyncStorageKinds)
+	if err != nil {
+		t.Fatalf("SyncManifest failed: %v", err)
+	}
+	if len(from) != 6 || len(to) != 4 {
+		t.Fatalf("Expected 6 and 4 items, got %+v and %+v", from, to)
+	}
+
+	status := map[string]string{}
+	var put, remove []SyncItem
+	for _, c := range DiffSync(from, to) {
+		status[c.Kind+":"+c.Site+":"+c.Key] = c.Status
+		if c.Site == "news" {
+			continue // Not an alias on dst
+		}
+		if c.Status == "removed" {
+			remove = append(remove, c.SyncItem)
+		} else {
+			put = append(put, c.SyncItem)
+		}
+	}
+	want := map[string]string{
+		"file::index.html": "modified", "file::new.js": "added", "file::old.js": "removed",
+		"kv:blog:count": "modified", "kv:blog:stale": "removed", "kv:news:n": "added",
+		"ds:blog:p1": "added", "s3:blog:a.txt": "added",
+	}
+	if len(status) != len(want) {
+		t.Errorf("Expected %v, got %v", want, status)
+	}
+	for k, v := range want {
+		if status[k] != v {
+			t.Errorf("Expected %s %s, got %q", k, v, status[k])
+		}
+	}
+
+	data, err := SyncContent(src, "app_src", put)
+	if err != nil {
+		t.Fatalf("SyncContent failed: %v", err)
+	}
+	if len(data) != len(put) {
+		t.Fatalf("Expected content of %d items, got %d", len(put), len(data))
+	}
+	if _, err := SyncContent(src, "app_src", []SyncItem{{Kind: SyncKV, Site: "shop", Key: "k"}}); !errors.Is(err, ErrSyncSite) {
+		t.Errorf("Expected another app's storage refused, got %v", err)
+	}
+
+	files, err := SyncFiles(dst, "app_dst", data, remove)
+	if err != nil {
+		t.Fatalf("SyncFiles failed: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "index.html" || string(files[0].Content) != "v2" || files[1].Path != "new.js" {
+		t.Errorf("Expected index.html and new.js from the source, got %+v", files)
+	}
+
+	written, removed, err := ApplySyncStorage(dst, "app_dst", data, remove)
+	if err != nil {
+		t.Fatalf("ApplySyncStorage failed: %v", err)
+	}
+	if written != 3 || removed != 1 {
+		t.Errorf("Expected 3 written and 1 removed, got %d, %d", written, removed)
+	}
+	if _, _, err := ApplySyncStorage(dst, "app_dst", []SyncData{{SyncItem: SyncItem{Kind: SyncKV, Site: "news", Key: "n"}}}, nil); !errors.Is(err, ErrSyncSite) {
+		t.Errorf("Expected storage under another site refused, got %v", err)
+	}
+
+	var value, userID string
+	dst.QueryRow(`SELECT value, user_id FROM app_kv WHERE app_id = 'blog' AND key = 'count'`).Scan(&value, &userID)
+	if value != "2" || userID != "u1" {
+		t.Errorf("Expected the kv entry synced, got %q, %q", value, userID)
+	}
+
+	// The storage now matches
+	after, _ := SyncManifest(dst, "app_dst", SyncStorageKinds)
+	var storageChanges int
+	for _, c := range DiffSync(from, after) {
+		if c.Kind != SyncFile && c.Site != "news" {
+			storageChanges++
+		}
+	}
+	if storageChanges != 0 {
+		t.Errorf("Expected the storage in sync, got %d changes", storageChanges)
+	}
+}
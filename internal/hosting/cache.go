@@ -0,0 +1,158 @@
+package hosting
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultCacheSize is the VFS cache budget unless configured otherwise
+const DefaultCacheSize = 64 * 1024 * 1024
+
+// cacheSize is the budget for file systems created from now on
+var cacheSize int64 = DefaultCacheSize
+
+// SetCacheSize sets the VFS cache budget in bytes, resizing the active
+// file system's cache. Zero disables caching.
+func SetCacheSize(n int64) {
+	if n < 0 {
+		n = 0
+	}
+	cacheSize = n
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		sqlFS.cache.resize(n)
+	}
+}
+
+// fileCache is a byte-size-bounded LRU of file contents, so hot static files
+// are served from memory instead of SQLite. Entries carry the file hash: a
+// rewrite with identical content (a redeploy) keeps the entry warm.
+type fileCache struct {
+	mu      sync.Mutex
+	items   map[string]*list.Element
+	order   *list.List // front = most recent
+	size    int64
+	maxSize int64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type cacheEntry struct {
+	key  string // "siteID:path"
+	file CachedFile
+}
+
+func newFileCache(maxSize int64) *fileCache {
+	return &fileCache{
+		items:   make(map[string]*list.Element),
+		order:   list.New(),
+		maxSize: maxSize,
+	}
+}
+
+// get returns a cached file, promoting it on hit
+func (c *fileCache) get(key string) (CachedFile, bool) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if ok {
+		c.order.MoveToFront(el)
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return CachedFile{}, false
+	}
+	c.hits.Add(1)
+	return el.Value.(*cacheEntry).file, true
+}
+
+// has reports whether key is cached without touching stats or order
+func (c *fileCache) has(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.items[key]
+	return ok
+}
+
+// put adds or replaces an entry, evicting LRU entries if over budget
+func (c *fileCache) put(key string, f CachedFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Don't cache files larger than 25% of the budget: one big download
+	// shouldn't evict every hot page
+	if int64(len(f.Data)) > c.maxSize/4 {
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		c.size += int64(len(f.Data)) - int64(len(entry.file.Data))
+		entry.file = f
+		c.order.MoveToFront(el)
+	} else {
+		c.items[key] = c.order.PushFront(&cacheEntry{key: key, file: f})
+		c.size += int64(len(f.Data))
+	}
+	c.evict()
+}
+
+// evict removes LRU entries until size is within budget. Caller holds mu.
+func (c *fileCache) evict() {
+	for c.size > c.maxSize && c.order.Len() > 0 {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove drops an entry. Caller holds mu.
+func (c *fileCache) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry)
+	c.size -= int64(len(entry.file.Data))
+	delete(c.items, entry.key)
+	c.order.Remove(el)
+}
+
+// invalidate drops key unless it already holds content with the given hash
+func (c *fileCache) invalidate(key, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok && (hash == "" || el.Value.(*cacheEntry).file.Hash != hash) {
+		c.remove(el)
+	}
+}
+
+// invalidatePrefix drops every entry whose key starts with prefix
+func (c *fileCache) invalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *fileCache) resize(maxSize int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSize = maxSize
+	c.evict()
+}
+
+func (c *fileCache) stats() VFSStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return VFSStats{
+		CachedFiles:    len(c.items),
+		CacheSizeBytes: c.size,
+		CacheMaxBytes:  c.maxSize,
+		Hits:           c.hits.Load(),
+		Misses:         c.misses.Load(),
+		Evictions:      c.evictions.Load(),
+	}
+}
@@ -0,0 +1,201 @@
+package hosting
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Precompressed variants live next to the original in the VFS as path.br
+// and path.gz, generated at deploy or shipped by a bundle. Clients that
+// accept both get brotli, which is usually smaller.
+var variants = []struct {
+	encoding string
+	ext      string
+	tag      string // ETag suffix, so each representation has its own ETag
+	mimeType string
+	writer   func(w io.Writer) io.WriteCloser
+}{
+	{"br", ".br", "-br", "application/x-brotli", func(w io.Writer) io.WriteCloser {
+		// Levels 10 and 11 take many times longer for a few percent
+		return brotli.NewWriterLevel(w, 9)
+	}},
+	{"gzip", ".gz", "-gz", "application/gzip", func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	}},
+}
+
+// minPrecompressSize is the smallest file worth a precompressed variant
+const minPrecompressSize = 1024
+
+// IsCompressible reports whether a content type benefits from compression
+func IsCompressible(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	if strings.HasPrefix(ct, "text/") {
+		return ct != "text/event-stream"
+	}
+	switch ct {
+	case "application/javascript", "application/json", "application/xml",
+		"application/manifest+json", "application/wasm", "image/svg+xml",
+		"application/x-javascript", "application/ld+json", "font/ttf", "font/otf":
+		return true
+	}
+	return false
+}
+
+// AcceptsEncoding reports whether an Accept-Encoding header allows enc.
+// An explicit q=0 refuses it; "*" accepts anything not listed.
+func AcceptsEncoding(header, enc string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch name {
+		case enc:
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// ETagMatches reports whether an If-None-Match header matches etag, using
+// weak comparison so compressed responses with W/ ETags still revalidate
+func ETagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateVariant returns the precompressed variant of path the client
+// prefers, with its encoding and ETag suffix. It returns a nil File when
+// there's none and the original should be served.
+func negotiateVariant(r *http.Request, path string, read func(path string) (*File, error)) (*File, string, string) {
+	accept := r.Header.Get("Accept-Encoding")
+	if accept == "" || r.Header.Get("Range") != "" {
+		return nil, "", ""
+	}
+	for _, v := range variants {
+		if !AcceptsEncoding(accept, v.encoding) {
+			continue
+		}
+		if file, err := read(path + v.ext); err == nil {
+			return file, v.encoding, v.tag
+		}
+	}
+	return nil, "", ""
+}
+
+// readVariant reads a precompressed variant, remembering misses so sites
+// without variants don't pay an extra query per request
+func (fs *SQLFileSystem) readVariant(id, path string, read func(id, path string) (*File, error)) (*File, error) {
+	key := cacheKey(id, path)
+	fs.cacheMu.RLock()
+	_, missing := fs.variantMisses[key]
+	fs.cacheMu.RUnlock()
+	if missing {
+		return nil, fmt.Errorf("file not found")
+	}
+
+	file, err := read(id, path)
+	if err != nil {
+		fs.cacheMu.Lock()
+		if len(fs.variantMisses) > 10000 {
+			fs.variantMisses = make(map[string]struct{})
+		}
+		fs.variantMisses[key] = struct{}{}
+		fs.cacheMu.Unlock()
+	}
+	return file, err
+}
+
+// PrecompressSite stores brotli and gzip variants next to every
+// compressible file of a site that doesn't have them, skipping files too
+// small or too dense to shrink. It returns the number of variants written.
+func PrecompressSite(siteID string) (int, error) {
+	entries, err := fs.ListFiles(siteID)
+	if err != nil {
+		return 0, err
+	}
+
+	existing := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		existing[e.Path] = true
+	}
+
+	count := 0
+	for _, e := range entries {
+		if e.Size < minPrecompressSize || isVariantPath(e.Path) {
+			continue
+		}
+		if !IsCompressible(GetMimeType(e.Path)) {
+			continue
+		}
+
+		var src []byte
+		for _, v := range variants {
+			if existing[e.Path+v.ext] {
+				continue
+			}
+			if src == nil {
+				file, err := fs.ReadFile(siteID, e.Path)
+				if err != nil {
+					return count, err
+				}
+				src, err = io.ReadAll(file.Content)
+				file.Content.Close()
+				if err != nil {
+					return count, err
+				}
+			}
+
+			var buf bytes.Buffer
+			cw := v.writer(&buf)
+			cw.Write(src)
+			if err := cw.Close(); err != nil {
+				return count, err
+			}
+
+			// Not worth a variant unless it saves at least 10%
+			if buf.Len()*10 > len(src)*9 {
+				continue
+			}
+			if err := fs.WriteFile(siteID, e.Path+v.ext, &buf, int64(buf.Len()), v.mimeType); err != nil {
+				return count, fmt.Errorf("failed to write %s%s: %w", e.Path, v.ext, err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func isVariantPath(path string) bool {
+	for _, v := range variants {
+		if strings.HasSuffix(path, v.ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package hosting
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		enc    string
+		want   bool
+	}{
+		{"gzip, deflate, br", "br", true},
+		{"gzip, deflate", "br", false},
+		{"br;q=0, gzip", "br", false},
+		{"GZIP", "gzip", true},
+		{"*", "br", true},
+		{"*, br;q=0", "br", false},
+		{"gzip;q=0.5", "gzip", true},
+		{"", "gzip", false},
+	}
+	for _, tt := range tests {
+		if got := AcceptsEncoding(tt.header, tt.enc); got != tt.want {
+			t.Errorf("AcceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.enc, got, tt.want)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	if !ETagMatches(`"abc-gz"`, `"abc-gz"`) || !ETagMatches(`W/"abc", "x"`, `"abc"`) || !ETagMatches("*", `"abc"`) {
+		t.Error("Expected matches")
+	}
+	if ETagMatches(`"abc"`, `"abc-gz"`) {
+		t.Error("Expected representations with different ETags not to match")
+	}
+}
+
+func TestPrecompressedVariants(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	js := []byte(strings.Repeat("console.log('hello world');\n", 100))
+	fs.WriteFile("site", "app.js", bytes.NewReader(js), int64(len(js)), "text/javascript; charset=utf-8")
+	fs.WriteFile("site", "small.css", strings.NewReader("a{}"), 3, "text/css; charset=utf-8")
+
+	n, err := PrecompressSite("site")
+	if err != nil || n != 2 {
+		t.Fatalf("Expected br and gzip variants, got %d (%v)", n, err)
+	}
+	file, _ := fs.ReadFile("site", "app.js")
+
+	// Identity for clients that don't accept gzip
+	w := httptest.NewRecorder()
+	ServeVFS(w, httptest.NewRequest("GET", "/app.js", nil), "site")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != len(js) {
+		t.Errorf("Expected identity response, got encoding %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+	}
+
+	// gzip variant with its own ETag
+	req := httptest.NewRequest("GET", "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") != `"`+file.Hash+`-gz"` {
+		t.Fatalf("Expected gzip variant, got encoding %q etag %q", w.Header().Get("Content-Encoding"), w.Header().Get("ETag"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("Expected original content type, got %q", w.Header().Get("Content-Type"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip body: %v", err)
+	}
+	if body, _ := io.ReadAll(zr); !bytes.Equal(body, js) {
+		t.Error("Decompressed body doesn't match the original")
+	}
+
+	// Revalidation against the variant's ETag
+	req.Header.Set("If-None-Match", `"`+file.Hash+`-gz"`)
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Code != 304 {
+		t.Errorf("Expected 304, got %d", w.Code)
+	}
+
+	// The brotli variant wins when accepted
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	req.Header.Del("If-None-Match")
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Header().Get("Content-Encoding") != "br" || w.Header().Get("ETag") != `"`+file.Hash+`-br"` {
+		t.Fatalf("Expected br variant, got encoding %q etag %q", w.Header().Get("Content-Encoding"), w.Header().Get("ETag"))
+	}
+	if body, _ := io.ReadAll(brotli.NewReader(w.Body)); !bytes.Equal(body, js) {
+		t.Error("Decompressed br body doesn't match the original")
+	}
+
+	// Variants a bundle ships are kept
+	fs.WriteFile("site", "lib.js", bytes.NewReader(js), int64(len(js)), "text/javascript; charset=utf-8")
+	fs.WriteFile("site", "lib.js.br", strings.NewReader("shipped"), 7, "application/octet-stream")
+	if n, err := PrecompressSite("site"); err != nil || n != 1 {
+		t.Errorf("Expected only lib.js.gz generated, got %d (%v)", n, err)
+	}
+	req = httptest.NewRequest("GET", "/lib.js", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "shipped" {
+		t.Errorf("Expected the shipped br variant, got %q", w.Header().Get("Content-Encoding"))
+	}
+
+	// Small files get no variant
+	req = httptest.NewRequest("GET", "/small.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "a{}" {
+		t.Errorf("Expected small file served as is")
+	}
+}
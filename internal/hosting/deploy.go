@@ -2,15 +2,18 @@ package hosting
 
 import (
 	"archive/zip"
+	"bufio"
+	"bytes"
 	"crypto/rand"
 	"database/sql"
 	"fmt"
-	"mime"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/services/media"
 )
 
 // DeployResult contains information about a deployment
@@ -18,6 +21,7 @@ type DeployResult struct {
 	SiteID    string
 	SizeBytes int64
 	FileCount int
+	Secrets   []SecretFinding // Likely credentials deployed anyway (secret scan mode "warn")
 }
 
 // DeploySite extracts a ZIP file to the VFS
@@ -27,28 +31,38 @@ func DeploySite(zipReader *zip.Reader, subdomain string) (*DeployResult, error)
 
 // DeploySiteWithSource extracts a ZIP file to the VFS with source tracking
 func DeploySiteWithSource(zipReader *zip.Reader, subdomain string, source *SourceInfo) (*DeployResult, error) {
-	// Validate subdomain
-	if err := ValidateSubdomain(subdomain); err != nil {
-		return nil, err
-	}
+	return DeploySiteWithProgress(zipReader, subdomain, source, nil)
+}
 
-	// Clear existing site files?
-	// The VFS WriteFile does INSERT OR UPDATE, so files are overwritten.
-	// But stale files (files removed in the new deploy) would remain.
-	// Ideally we should delete the site first or track current files.
-	// For now, let's delete the site first to ensure a clean state (Cartridge style).
-	if err := fs.DeleteSite(subdomain); err != nil {
-		return nil, fmt.Errorf("failed to clear existing site: %w", err)
+// DeploySiteWithProgress is DeploySiteWithSource, calling progress (if set)
+// after each file is written with the files written so far and the total
+func DeploySiteWithProgress(zipReader *zip.Reader, subdomain string, source *SourceInfo, progress func(written, total int)) (*DeployResult, error) {
+	// Scan before beginDeploy clears the current files
+	var secrets []SecretFinding
+	if SecretScanMode() != SecretScanOff {
+		var err error
+		if secrets, err = scanZipSecrets(zipReader); err != nil {
+			return nil, err
+		}
+		if err := checkSecrets(secrets); err != nil {
+			return nil, err
+		}
 	}
 
-	// Ensure app entry exists with source tracking
-	if err := fs.EnsureApp(subdomain, source); err != nil {
-		return nil, fmt.Errorf("failed to create app entry: %w", err)
+	if err := beginDeploy(subdomain, source); err != nil {
+		return nil, err
 	}
 
 	var totalSize int64
 	var fileCount int
 
+	total := 0
+	for _, file := range zipReader.File {
+		if !file.FileInfo().IsDir() {
+			total++
+		}
+	}
+
 	// Extract files
 	for _, file := range zipReader.File {
 		// Security: Prevent path traversal
@@ -71,16 +85,14 @@ func DeploySiteWithSource(zipReader *zip.Reader, subdomain string, source *Sourc
 			return nil, fmt.Errorf("failed to open file %s: %w", file.Name, err)
 		}
 
-		// Determine MIME type
-		ext := filepath.Ext(cleanPath)
-		mimeType := mime.TypeByExtension(ext)
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
-		}
+		// Determine MIME type, sniffing the start of files without a known extension
+		content := bufio.NewReaderSize(src, sniffLen)
+		head, _ := content.Peek(sniffLen)
+		mimeType := DetectMimeType(cleanPath, head)
 
 		// Write to VFS
 		fileSize := file.FileInfo().Size()
-		if err := fs.WriteFile(subdomain, cleanPath, src, fileSize, mimeType); err != nil {
+		if err := fs.WriteFile(subdomain, cleanPath, content, fileSize, mimeType); err != nil {
 			src.Close()
 			return nil, fmt.Errorf("failed to write file %s: %w", cleanPath, err)
 		}
@@ -88,80 +100,117 @@ func DeploySiteWithSource(zipReader *zip.Reader, subdomain string, source *Sourc
 
 		totalSize += fileSize
 		fileCount++
+		if progress != nil {
+			progress(fileCount, total)
+		}
 	}
 
-	return &DeployResult{
+	result := &DeployResult{
 		SiteID:    subdomain,
 		SizeBytes: totalSize,
 		FileCount: fileCount,
-	}, nil
+		Secrets:   secrets,
+	}
+	finishDeploy(result)
+	return result, nil
 }
 
-// ValidateAPIKey validates an API key against the database
-func ValidateAPIKey(db *sql.DB, token string) (int64, string, error) {
-	// Get all API keys from database
-	rows, err := db.Query("SELECT id, name, key_hash FROM api_keys")
-	if err != nil {
-		return 0, "", fmt.Errorf("failed to query API keys: %w", err)
-	}
+// DeployFile is a file of a deploy assembled on the server
+type DeployFile struct {
+	Path    string
+	Content []byte
+}
 
-	var matchedID int64
-	var matchedName string
-	for rows.Next() {
-		var id int64
-		var name, keyHash string
-		if err := rows.Scan(&id, &name, &keyHash); err != nil {
-			continue
+// DeployFiles replaces a site's files with the given ones, like a ZIP
+// deploy. It is used for deploys assembled on the server, e.g. merges.
+func DeployFiles(subdomain string, files []DeployFile, source *SourceInfo) (*DeployResult, error) {
+	var secrets []SecretFinding
+	if SecretScanMode() != SecretScanOff {
+		secrets = scanFilesSecrets(files)
+		if err := checkSecrets(secrets); err != nil {
+			return nil, err
 		}
+	}
 
-		// Compare token with hash
-		if err := bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(token)); err == nil {
-			matchedID = id
-			matchedName = name
-			break
-		}
+	if err := beginDeploy(subdomain, source); err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		rows.Close()
-		return 0, "", fmt.Errorf("failed to read API keys: %w", err)
+	result := &DeployResult{SiteID: subdomain, Secrets: secrets}
+	for _, file := range files {
+		mimeType := DetectMimeType(file.Path, file.Content)
+		size := int64(len(file.Content))
+		if err := fs.WriteFile(subdomain, file.Path, bytes.NewReader(file.Content), size, mimeType); err != nil {
+			return nil, fmt.Errorf("failed to write file %s: %w", file.Path, err)
+		}
+		result.SizeBytes += size
+		result.FileCount++
 	}
 
-	rows.Close()
+	finishDeploy(result)
+	return result, nil
+}
 
-	if matchedID != 0 {
-		// Update last_used_at after closing rows to avoid locking.
-		db.Exec("UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", matchedID)
-		return matchedID, matchedName, nil
+// beginDeploy clears a site's files and makes sure its app exists
+func beginDeploy(subdomain string, source *SourceInfo) error {
+	// Validate subdomain
+	if err := ValidateSubdomain(subdomain); err != nil {
+		return err
 	}
 
-	return 0, "", fmt.Errorf("invalid API key")
-}
+	// Clear existing site files?
+	// The VFS WriteFile does INSERT OR UPDATE, so files are overwritten.
+	// But stale files (files removed in the new deploy) would remain.
+	// Ideally we should delete the site first or track current files.
+	// For now, let's delete the site first to ensure a clean state (Cartridge style).
+	if err := fs.DeleteSite(subdomain); err != nil {
+		return fmt.Errorf("failed to clear existing site: %w", err)
+	}
 
-// CreateAPIKey creates a new API key and returns the raw token
-func CreateAPIKey(db *sql.DB, name string, scopes string) (string, error) {
-	// Generate random token (32 bytes = 64 hex chars)
-	token, err := generateRandomToken(32)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+	// Ensure app entry exists with source tracking
+	if err := fs.EnsureApp(subdomain, source); err != nil {
+		return fmt.Errorf("failed to create app entry: %w", err)
 	}
+	return nil
+}
 
-	// Hash the token
-	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to hash token: %w", err)
+// finishDeploy refreshes what was derived from a site's previous files and
+// announces the deploy
+func finishDeploy(result *DeployResult) {
+	// Route table for file-based serverless handlers (api/users/[id].js)
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		LoadAPIRoutes(sqlFS.db, result.SiteID)
+		// Resized copies of the previous version's images
+		media.InvalidateStatic(sqlFS.db, result.SiteID)
+		prepareResponsiveImages(sqlFS, result.SiteID)
 	}
+	// Programs compiled from the previous version are unreachable now
+	modules.Forget(result.SiteID)
 
-	// Store in database
-	_, err = db.Exec(
-		"INSERT INTO api_keys (name, key_hash, scopes) VALUES (?, ?, ?)",
-		name, string(hash), scopes,
-	)
+	events.Emit(events.AppDeployed, result.SiteID, map[string]interface{}{
+		"files":      result.FileCount,
+		"size_bytes": result.SizeBytes,
+	})
+}
+
+// ValidateAPIKey validates an API key against the database
+func ValidateAPIKey(db *sql.DB, token string) (int64, string, error) {
+	key, err := AuthenticateAPIKey(db, token)
 	if err != nil {
-		return "", fmt.Errorf("failed to store API key: %w", err)
+		return 0, "", err
 	}
+	return key.ID, key.Name, nil
+}
 
-	return token, nil
+// CreateAPIKey creates a new server-wide API key and returns the raw token
+func CreateAPIKey(db *sql.DB, name string, scopes string) (string, error) {
+	return CreateUserAPIKey(db, name, scopes, "")
+}
+
+// CreateUserAPIKey creates an API key that acts on behalf of a user and is
+// limited to that user's app memberships. An empty userID creates a server-wide key.
+func CreateUserAPIKey(db *sql.DB, name string, scopes string, userID string) (string, error) {
+	return CreateScopedAPIKey(db, APIKeyOptions{Name: name, Scope: scopes, UserID: userID})
 }
 
 // generateRandomToken generates a random hex token
@@ -184,7 +233,14 @@ func RecordDeployment(db *sql.DB, siteID string, sizeBytes int64, fileCount int,
 
 // ListAPIKeys lists all API keys (without the actual keys)
 func ListAPIKeys(db *sql.DB) ([]APIKeyInfo, error) {
-	rows, err := db.Query("SELECT id, name, scopes, created_at, last_used_at FROM api_keys ORDER BY created_at DESC")
+	rows, err := db.Query(`
+		SELECT k.id, k.name, COALESCE(k.scopes, ''), COALESCE(k.app_id, ''), COALESCE(a.title, ''),
+			COALESCE(u.email, ''), k.created_at, k.last_used_at, k.expires_at, k.revoked_at
+		FROM api_keys k
+		LEFT JOIN apps a ON a.id = k.app_id
+		LEFT JOIN auth_users u ON u.id = k.user_id
+		ORDER BY k.created_at DESC
+	`)
 	if err != nil {
 		return nil, err
 	}
@@ -193,13 +249,20 @@ func ListAPIKeys(db *sql.DB) ([]APIKeyInfo, error) {
 	var keys []APIKeyInfo
 	for rows.Next() {
 		var k APIKeyInfo
-		var lastUsed sql.NullTime
-		if err := rows.Scan(&k.ID, &k.Name, &k.Scopes, &k.CreatedAt, &lastUsed); err != nil {
+		var lastUsed, expires, revoked sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Scopes, &k.AppID, &k.AppTitle, &k.User,
+			&k.CreatedAt, &lastUsed, &expires, &revoked); err != nil {
 			continue
 		}
 		if lastUsed.Valid {
 			k.LastUsedAt = &lastUsed.Time
 		}
+		if expires.Valid {
+			k.ExpiresAt = &expires.Time
+		}
+		if revoked.Valid {
+			k.RevokedAt = &revoked.Time
+		}
 		keys = append(keys, k)
 	}
 
@@ -211,8 +274,13 @@ type APIKeyInfo struct {
 	ID         int64      `json:"id"`
 	Name       string     `json:"name"`
 	Scopes     string     `json:"scopes"`
+	AppID      string     `json:"app_id,omitempty"`
+	AppTitle   string     `json:"app_title,omitempty"`
+	User       string     `json:"user,omitempty"`
 	CreatedAt  time.Time  `json:"created_at"`
 	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
 }
 
 // DeleteAPIKey deletes an API key by ID
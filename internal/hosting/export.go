@@ -0,0 +1,149 @@
+package hosting
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+)
+
+// A static export turns an app into plain files any static host can serve,
+// so its content outlives the server: the files it serves as they are, and
+// dynamic routes rendered once by the server, e.g. a JSON API the pages
+// fetch or a feed.
+
+// AppExport lists the dynamic routes a static export of an app renders,
+// declared in manifest.json:
+//
+//	"export": {"routes": ["/api/posts", "/feed.xml", "/calendar.ics"]}
+type AppExport struct {
+	Routes []string `json:"routes"`
+}
+
+// StaticExportFiles returns the files of an app a static host can serve as
+// they are: all but api/ and private/, which only mean something on the
+// server. Static hosts serve 404.html for missing pages, so the manifest's
+// not_found page, or the index.html of an SPA, is copied there unless the
+// app has its own.
+func StaticExportFiles(db *sql.DB, appID string) ([]DeployFile, error) {
+	title, err := appTitle(db, appID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`
+		SELECT path, content FROM files
+		WHERE app_id = ? OR (site_id = ? AND app_id IS NULL)
+		ORDER BY path
+	`, appID, title)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []DeployFile
+	byPath := map[string][]byte{}
+	for rows.Next() {
+		var f DeployFile
+		if err := rows.Scan(&f.Path, &f.Content); err != nil {
+			return nil, err
+		}
+		byPath[f.Path] = f.Content
+		if !isServerOnlyPath(f.Path) {
+			files = append(files, f)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := byPath["404.html"]; !ok {
+		var manifest AppManifest
+		json.Unmarshal(byPath["manifest.json"], &manifest)
+		var spa int
+		db.QueryRow(`SELECT COALESCE(spa, 0) FROM apps WHERE id = ?`, appID).Scan(&spa)
+
+		page := ""
+		if manifest.Routes.NotFound != "" {
+			page = vfsPath(manifest.Routes.NotFound)
+		} else if spa == 1 {
+			page = "index.html"
+		}
+		if content, ok := byPath[page]; ok && !isServerOnlyPath(page) {
+			files = append(files, DeployFile{Path: "404.html", Content: content})
+		}
+	}
+	return files, nil
+}
+
+// StaticExportRoutes returns the routes a static export of an app renders:
+// those its manifest.json declares, then extra, without duplicates
+func StaticExportRoutes(db *sql.DB, appID string, extra []string) ([]string, error) {
+	manifest, err := storedManifest(db, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []string
+	if manifest.Export != nil {
+		routes = append(routes, manifest.Export.Routes...)
+	}
+	routes = append(routes, extra...)
+
+	seen := map[string]bool{}
+	var result []string
+	for _, r := range routes {
+		if !seen[r] {
+			seen[r] = true
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// StaticRoutePath returns the file a rendered route is written to. Pages
+// without an extension go in the directory's index.html, as static hosts
+// look for it there; anything else keeps its path, with index.html for a
+// trailing slash.
+func StaticRoutePath(route, contentType string) (string, error) {
+	if !strings.HasPrefix(route, "/") || strings.ContainsAny(route, "?#") {
+		return "", fmt.Errorf("route %q must be a path starting with /, without a query", route)
+	}
+	p := vfsPath(route)
+	if p == "" || p == "." {
+		return "index.html", nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if strings.HasSuffix(route, "/") || (path.Ext(p) == "" && mediaType == "text/html") {
+		return p + "/index.html", nil
+	}
+	return p, nil
+}
+
+// StaticExportConflicts returns the paths written both as a file and as a
+// directory of other files, which no file system can hold
+func StaticExportConflicts(paths []string) []string {
+	files := map[string]bool{}
+	for _, p := range paths {
+		files[p] = true
+	}
+	var conflicts []string
+	for _, p := range paths {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if files[dir] {
+				conflicts = append(conflicts, dir)
+				files[dir] = false
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+// isServerOnlyPath reports whether an app file is only served by the
+// server: api/ runs there and private/ needs a login
+func isServerOnlyPath(p string) bool {
+	return strings.HasPrefix(p, "api/") || strings.HasPrefix(p, "private/")
+}
@@ -0,0 +1,40 @@
+package hosting
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStaticRoutePath(t *testing.T) {
+	tests := []struct {
+		route, contentType, want string
+	}{
+		{"/", "text/html", "index.html"},
+		{"/about", "text/html; charset=utf-8", "about/index.html"},
+		{"/docs/", "text/plain", "docs/index.html"},
+		{"/api/posts", "application/json", "api/posts"},
+		{"/feed.xml", "application/rss+xml", "feed.xml"},
+		{"/../etc/passwd", "text/plain", "etc/passwd"},
+	}
+	for _, tt := range tests {
+		got, err := StaticRoutePath(tt.route, tt.contentType)
+		if err != nil || got != tt.want {
+			t.Errorf("StaticRoutePath(%q, %q) = %q, %v; want %q", tt.route, tt.contentType, got, err, tt.want)
+		}
+	}
+	for _, route := range []string{"about", "/search?q=x"} {
+		if _, err := StaticRoutePath(route, ""); err == nil {
+			t.Errorf("Expected %q refused", route)
+		}
+	}
+}
+
+func TestStaticExportConflicts(t *testing.T) {
+	got := StaticExportConflicts([]string{"api/posts", "api/posts/1", "api/posts/2", "index.html"})
+	if !reflect.DeepEqual(got, []string{"api/posts"}) {
+		t.Errorf("Expected api/posts, got %v", got)
+	}
+	if got := StaticExportConflicts([]string{"a/index.html", "a/b.html"}); len(got) != 0 {
+		t.Errorf("Expected no conflicts, got %v", got)
+	}
+}
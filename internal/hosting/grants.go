@@ -0,0 +1,116 @@
+package hosting
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// GrantScopeAPI is the scope granting the whole of an app's API
+const GrantScopeAPI = "api"
+
+// ErrInvalidGrantScope is returned for scopes other than "api" or an /api path
+var ErrInvalidGrantScope = errors.New("scope must be 'api' or a path under /api/")
+
+// AppGrant lets one app call another's handlers with fazt.call. Apps are
+// the site IDs their handlers run under.
+type AppGrant struct {
+	Caller    string `json:"caller"`
+	Target    string `json:"target"`
+	Scope     string `json:"scope"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// IsValidGrantScope reports whether scope is "api" or a path under /api/
+func IsValidGrantScope(scope string) bool {
+	if scope == GrantScopeAPI {
+		return true
+	}
+	return strings.HasPrefix(scope, "/api/") && len(scope) > len("/api/") &&
+		!strings.HasSuffix(scope, "/") && !strings.Contains(scope, "..")
+}
+
+// GrantAppCall lets caller call target's handlers within scope
+func GrantAppCall(db *sql.DB, caller, target, scope, createdBy string) error {
+	if !IsValidGrantScope(scope) {
+		return ErrInvalidGrantScope
+	}
+	var by interface{}
+	if createdBy != "" {
+		by = createdBy
+	}
+	_, err := db.Exec(`
+		INSERT INTO app_grants (caller, target, scope, created_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(caller, target, scope) DO NOTHING
+	`, caller, target, scope, by)
+	return err
+}
+
+// RevokeAppCall removes caller's grant on target for scope, or all of its
+// grants on target when scope is "", returning how many were removed
+func RevokeAppCall(db *sql.DB, caller, target, scope string) (int64, error) {
+	query := `DELETE FROM app_grants WHERE caller = ? AND target = ?`
+	args := []interface{}{caller, target}
+	if scope != "" {
+		query += ` AND scope = ?`
+		args = append(args, scope)
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListAppGrants returns the grants an app has given and been given
+func ListAppGrants(db *sql.DB, siteID string) ([]AppGrant, error) {
+	rows, err := db.Query(`
+		SELECT caller, target, scope, COALESCE(created_by, ''), created_at
+		FROM app_grants
+		WHERE caller = ? OR target = ?
+		ORDER BY target, caller, scope
+	`, siteID, siteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []AppGrant{}
+	for rows.Next() {
+		var g AppGrant
+		if err := rows.Scan(&g.Caller, &g.Target, &g.Scope, &g.CreatedBy, &g.CreatedAt); err != nil {
+			continue
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// AppCallAllowed reports whether caller may call target's handler at path
+func AppCallAllowed(db *sql.DB, caller, target, path string) (bool, error) {
+	rows, err := db.Query(`SELECT scope FROM app_grants WHERE caller = ? AND target = ?`, caller, target)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scope string
+		if err := rows.Scan(&scope); err != nil {
+			continue
+		}
+		if grantCovers(scope, path) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// grantCovers reports whether scope covers path
+func grantCovers(scope, path string) bool {
+	if scope == GrantScopeAPI {
+		scope = "/api"
+	}
+	return path == scope || strings.HasPrefix(path, scope+"/")
+}
@@ -3,7 +3,6 @@ package hosting
 import (
 	"fmt"
 	"io"
-	"mime"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -53,41 +52,41 @@ func ServeVFSByAppID(w http.ResponseWriter, r *http.Request, appID string) {
 	}
 	defer file.Content.Close()
 
-	// ETag Caching
-	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, file.Hash))
-	if match := r.Header.Get("If-None-Match"); match != "" {
-		if strings.Contains(match, file.Hash) {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
-	}
+	// Content Type: manifest.json "types" overrides, else the deployed type
+	manifest := sqlFS.appManifest(appID, sqlFS.ReadFileByAppID)
+	contentType := manifest.ContentType(path, file.MimeType)
 
-	// Content Type
-	contentType := file.MimeType
-	if contentType == "" {
-		contentType = mime.TypeByExtension(filepath.Ext(path))
-		if contentType == "" {
-			contentType = "application/octet-stream"
+	// Serve a precompressed variant (path.br / path.gz) when the client
+	// accepts one. Each representation gets its own ETag. HTML is rewritten
+	// per request for analytics, so it's compressed on the fly instead.
+	served, etag := file, fmt.Sprintf(`"%s"`, file.Hash)
+	if IsCompressible(contentType) && !strings.HasPrefix(contentType, "text/html") {
+		w.Header().Add("Vary", "Accept-Encoding")
+		variant, encoding, tag := negotiateVariant(r, path, func(p string) (*File, error) {
+			return sqlFS.readVariant(appID, p, sqlFS.ReadFileByAppID)
+		})
+		if variant != nil {
+			defer variant.Content.Close()
+			served = variant
+			etag = fmt.Sprintf(`"%s%s"`, file.Hash, tag)
+			w.Header().Set("Content-Encoding", encoding)
 		}
 	}
-	w.Header().Set("Content-Type", contentType)
 
-	// Cache-Control: Smart caching strategy
-	// 1. HTML files: Always revalidate (for live reload & version detection)
-	// 2. Hashed assets (/assets/*-*.ext): Cache forever (content-addressed)
-	// 3. Other files: Short cache (5 minutes)
-	if strings.HasSuffix(path, ".html") {
-		// HTML: no-cache means "revalidate with server before using cached version"
-		// This ensures version checks work reliably
-		w.Header().Set("Cache-Control", "no-cache, must-revalidate")
-	} else if strings.HasPrefix(path, "assets/") && strings.Contains(filepath.Base(path), "-") {
-		// Hashed assets: cache aggressively (filename changes when content changes)
-		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-	} else {
-		// Everything else: short cache (5 minutes)
-		w.Header().Set("Cache-Control", "public, max-age=300")
+	// Caching headers: manifest.json "cache" rules, else the defaults. They
+	// go out on 304s too, so revalidated responses keep their freshness.
+	w.Header().Set("ETag", etag)
+	if !file.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", CacheControlFor(manifest.Cache, path))
+	if notModified(r, etag, file.ModTime) {
+		writeNotModified(w)
+		return
 	}
 
+	w.Header().Set("Content-Type", contentType)
+
 	// For HTML files, inject analytics tracking script
 	if ShouldInjectAnalytics(path) {
 		data, err := io.ReadAll(file.Content)
@@ -100,10 +99,10 @@ func ServeVFSByAppID(w http.ResponseWriter, r *http.Request, appID string) {
 	}
 
 	// Content Length
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", served.Size))
 
 	// Serve content
-	if _, err := io.Copy(w, file.Content); err != nil {
+	if _, err := io.Copy(w, served.Content); err != nil {
 		// Log error?
 	}
 }
@@ -115,14 +114,20 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 		path = "/" + path
 	}
 
-	// Normalize: redirect trailing slash to non-trailing slash (except root)
-	// e.g., /about/ -> /about (301 redirect for SEO consistency)
-	if path != "/" && strings.HasSuffix(path, "/") {
-		target := strings.TrimSuffix(path, "/")
-		if r.URL.RawQuery != "" {
-			target += "?" + r.URL.RawQuery
-		}
-		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	// Routing rules from manifest.json
+	manifest := manifestFor(siteID)
+	routes := manifest.Routes
+
+	// Redirects come before any file lookup
+	if target, code, ok := routes.Redirect(path); ok {
+		redirectWithQuery(w, r, target, code)
+		return
+	}
+
+	// Normalize per the trailing slash policy (except root). By default
+	// /about/ -> /about (301 redirect for SEO consistency)
+	if target := routes.trailingSlashTarget(path); target != "" {
+		redirectWithQuery(w, r, target, http.StatusMovedPermanently)
 		return
 	}
 
@@ -148,6 +153,7 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 	}
 
 	// 1. Try exact match
+	status := http.StatusOK
 	file, err := fs.ReadFile(siteID, path)
 	if err != nil {
 		// 2. If not found, and it looks like a directory (no extension), try appending index.html
@@ -157,7 +163,16 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 			file, err = fs.ReadFile(siteID, idxPath)
 		}
 
-		// 3. If still not found, check for SPA fallback
+		// 3. If still not found, apply the manifest's rewrites
+		if err != nil {
+			if to, ok := routes.Rewrite("/" + path); ok {
+				if file, err = fs.ReadFile(siteID, vfsPath(to)); err == nil {
+					path = vfsPath(to)
+				}
+			}
+		}
+
+		// 4. If still not found, check for SPA fallback
 		if err != nil {
 			// SPA fallback: if original path looked like a route (no extension) and app has SPA enabled
 			if isRouteLikePath {
@@ -167,8 +182,16 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 					}
 				}
 			}
+		}
 
-			// 4. If still not found, 404
+		// 5. If still not found, the app's 404 page or a plain 404
+		if err != nil {
+			if routes.NotFound != "" {
+				if file, err = fs.ReadFile(siteID, vfsPath(routes.NotFound)); err == nil {
+					path = vfsPath(routes.NotFound)
+					status = http.StatusNotFound
+				}
+			}
 			if err != nil {
 				http.NotFound(w, r)
 				return
@@ -177,57 +200,81 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 	}
 	defer file.Content.Close()
 
-	// ETag Caching
-	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, file.Hash))
-	if match := r.Header.Get("If-None-Match"); match != "" {
-		if strings.Contains(match, file.Hash) {
-			w.WriteHeader(http.StatusNotModified)
-			return
-		}
+	// Content Type: manifest.json "types" overrides, else the deployed type
+	contentType := manifest.ContentType(path, file.MimeType)
+
+	// Image transform URLs: /photo.jpg?w=400 serves a resized copy
+	if status == http.StatusOK && serveTransformedImage(w, r, siteID, path, file, contentType, manifest) {
+		return
 	}
 
-	// Content Type
-	contentType := file.MimeType
-	if contentType == "" {
-		contentType = mime.TypeByExtension(filepath.Ext(path))
-		if contentType == "" {
-			contentType = "application/octet-stream"
+	// Serve a precompressed variant (path.br / path.gz) when the client
+	// accepts one. Each representation gets its own ETag. HTML is rewritten
+	// per request for analytics, so it's compressed on the fly instead.
+	served, etag := file, fmt.Sprintf(`"%s"`, file.Hash)
+	if sqlFS, ok := fs.(*SQLFileSystem); ok && IsCompressible(contentType) && !strings.HasPrefix(contentType, "text/html") {
+		w.Header().Add("Vary", "Accept-Encoding")
+		variant, encoding, tag := negotiateVariant(r, path, func(p string) (*File, error) {
+			return sqlFS.readVariant(siteID, p, sqlFS.ReadFile)
+		})
+		if variant != nil {
+			defer variant.Content.Close()
+			served = variant
+			etag = fmt.Sprintf(`"%s%s"`, file.Hash, tag)
+			w.Header().Set("Content-Encoding", encoding)
 		}
 	}
-	w.Header().Set("Content-Type", contentType)
 
-	// Cache-Control: Smart caching strategy
-	// 1. HTML files: Always revalidate (for live reload & version detection)
-	// 2. Hashed assets (/assets/*-*.ext): Cache forever (content-addressed)
-	// 3. Other files: Short cache (5 minutes)
-	if strings.HasSuffix(path, ".html") {
-		// HTML: no-cache means "revalidate with server before using cached version"
-		// This ensures version checks work reliably
-		w.Header().Set("Cache-Control", "no-cache, must-revalidate")
-	} else if strings.HasPrefix(path, "assets/") && strings.Contains(filepath.Base(path), "-") {
-		// Hashed assets: cache aggressively (filename changes when content changes)
-		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-	} else {
-		// Everything else: short cache (5 minutes)
-		w.Header().Set("Cache-Control", "public, max-age=300")
+	// Caching headers: manifest.json "cache" rules, else the defaults. They
+	// go out on 304s too, so revalidated responses keep their freshness.
+	w.Header().Set("ETag", etag)
+	if !file.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", CacheControlFor(manifest.Cache, path))
+	if status == http.StatusOK && notModified(r, etag, file.ModTime) {
+		writeNotModified(w)
+		return
 	}
 
+	w.Header().Set("Content-Type", contentType)
+
 	// For HTML files, inject analytics tracking script
 	if ShouldInjectAnalytics(path) {
 		data, err := io.ReadAll(file.Content)
 		if err == nil {
 			data = InjectAnalytics(data, siteID)
 			w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+			}
 			w.Write(data)
 			return
 		}
 	}
 
 	// Content Length
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", served.Size))
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+	}
 
 	// Serve content
-	if _, err := io.Copy(w, file.Content); err != nil {
+	if _, err := io.Copy(w, served.Content); err != nil {
 		// Log error?
 	}
 }
+
+// vfsPath turns a manifest path ("/app/index.html") into a VFS path
+func vfsPath(p string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+p)), "/")
+}
+
+// redirectWithQuery redirects to target, carrying over the request's query
+// string unless target has its own
+func redirectWithQuery(w http.ResponseWriter, r *http.Request, target string, code int) {
+	if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, code)
+}
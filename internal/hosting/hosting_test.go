@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -42,7 +43,16 @@ func setupTestDB(t *testing.T) *sql.DB {
 		key_hash TEXT NOT NULL,
 		scopes TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		last_used_at DATETIME
+		last_used_at DATETIME,
+		user_id TEXT,
+		app_id TEXT,
+		expires_at DATETIME,
+		revoked_at DATETIME
+	);
+	CREATE TABLE auth_users (
+		id TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		role TEXT
 	);
 	CREATE TABLE deployments (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -65,6 +75,7 @@ func setupTestDB(t *testing.T) *sql.DB {
 		source_ref TEXT,
 		source_commit TEXT,
 		spa INTEGER DEFAULT 0,
+		analytics_inject INTEGER DEFAULT 1,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -280,6 +291,82 @@ func TestAPIKeyOperations(t *testing.T) {
 	}
 }
 
+func TestScopedAPIKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	token, err := CreateScopedAPIKey(db, APIKeyOptions{Name: "ci", Scope: ScopeDeploy, AppID: "app_blog"})
+	if err != nil {
+		t.Fatalf("CreateScopedAPIKey() failed: %v", err)
+	}
+
+	key, err := AuthenticateAPIKey(db, token)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey() failed: %v", err)
+	}
+	if key.Scope != ScopeDeploy || key.AppID != "app_blog" {
+		t.Errorf("key = %+v, want deploy scope on app_blog", key)
+	}
+
+	tests := []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/api/apps", true},
+		{"POST", "/api/deploy", true},
+		{"PATCH", "/api/uploads/abc", true},
+		{"DELETE", "/api/apps/app_blog", false},
+		{"GET", "/api/keys", false},
+		{"POST", "/api/cmd", false},
+	}
+	for _, tt := range tests {
+		if got := key.Allows(tt.method, tt.path); got != tt.want {
+			t.Errorf("Allows(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if _, err := CreateScopedAPIKey(db, APIKeyOptions{Name: "bad", Scope: "root"}); err != ErrInvalidScope {
+		t.Errorf("CreateScopedAPIKey() with bad scope = %v, want ErrInvalidScope", err)
+	}
+
+	// Expired keys are rejected
+	past := time.Now().Add(-time.Hour)
+	expired, _ := CreateScopedAPIKey(db, APIKeyOptions{Name: "old", Scope: ScopeRead, ExpiresAt: &past})
+	if _, err := AuthenticateAPIKey(db, expired); err != ErrAPIKeyExpired {
+		t.Errorf("AuthenticateAPIKey() expired = %v, want ErrAPIKeyExpired", err)
+	}
+
+	// Revoked keys are rejected immediately, even if recently verified
+	if err := RevokeAPIKey(db, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey() failed: %v", err)
+	}
+	if _, err := AuthenticateAPIKey(db, token); err == nil {
+		t.Error("AuthenticateAPIKey() should fail for revoked key")
+	}
+	if err := RevokeAPIKey(db, key.ID); err != sql.ErrNoRows {
+		t.Errorf("RevokeAPIKey() twice = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"90m": 90 * time.Minute,
+	}
+	for in, want := range tests {
+		got, err := ParseExpiry(in)
+		if err != nil || got != want {
+			t.Errorf("ParseExpiry(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0d", "-1h", "soon", "3xd"} {
+		if _, err := ParseExpiry(in); err == nil {
+			t.Errorf("ParseExpiry(%q) should fail", in)
+		}
+	}
+}
+
 func TestSiteExists(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -516,3 +603,50 @@ func TestSPARouting(t *testing.T) {
 		t.Error("SPA should be disabled after SetAppSPA(false)")
 	}
 }
+
+func TestAnalyticsInjectToggle(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	Init(db)
+	sqlFS := fs.(*SQLFileSystem)
+
+	_, err := db.Exec(`INSERT INTO apps (id, title) VALUES (?, ?)`, "app_stats", "stats-site")
+	if err != nil {
+		t.Fatalf("Failed to create app entry: %v", err)
+	}
+
+	html := []byte("<html><body>Hello</body></html>")
+
+	// Enabled by default
+	if out := InjectAnalytics(html, "stats-site"); !strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected analytics to be injected by default")
+	}
+
+	// Disable via per-app toggle
+	if err := sqlFS.SetAppAnalyticsInject("stats-site", false); err != nil {
+		t.Fatalf("SetAppAnalyticsInject failed: %v", err)
+	}
+	if inject, _ := sqlFS.GetAppAnalyticsInject("app_stats"); inject {
+		t.Error("Expected toggle to be readable by app ID")
+	}
+	if out := InjectAnalytics(html, "stats-site"); strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected analytics not to be injected when disabled")
+	}
+
+	// Re-enable
+	sqlFS.SetAppAnalyticsInject("stats-site", true)
+	if out := InjectAnalytics(html, "stats-site"); !strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected analytics to be injected after re-enabling")
+	}
+
+	// The flag is cached: pages don't query it until it's forgotten
+	db.Exec(`UPDATE apps SET analytics_inject = 0 WHERE id = ?`, "app_stats")
+	if out := InjectAnalytics(html, "stats-site"); !strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected the cached flag to be used")
+	}
+	ForgetAnalyticsInject()
+	if out := InjectAnalytics(html, "stats-site"); strings.Contains(string(out), "sendBeacon") {
+		t.Error("Expected the flag to be read again once forgotten")
+	}
+}
@@ -0,0 +1,83 @@
+package hosting
+
+import (
+	"net/http"
+	pathpkg "path"
+	"strings"
+	"time"
+)
+
+// CacheRule sets Cache-Control for files matching Path. Rules come from the
+// "cache" list in an app's manifest.json, first match wins:
+//
+//	{ "cache": [
+//	    { "path": "assets/*", "control": "public, max-age=31536000, immutable" },
+//	    { "path": "*.json", "control": "no-store" }
+//	] }
+//
+// A pattern ending in "/*" matches everything below that directory, one
+// without a slash matches the file name in any directory, anything else is
+// matched against the full path.
+type CacheRule struct {
+	Path    string `json:"path"`
+	Control string `json:"control"`
+}
+
+// Matches reports whether the rule applies to path (no leading slash)
+func (r CacheRule) Matches(path string) bool {
+	pattern := strings.TrimPrefix(r.Path, "/")
+	if dir, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(path, dir+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		path = pathpkg.Base(path)
+	}
+	ok, _ := pathpkg.Match(pattern, path)
+	return ok
+}
+
+// CacheControlFor returns the Cache-Control value for path: the first
+// matching rule, or the built-in defaults
+func CacheControlFor(rules []CacheRule, path string) string {
+	for _, rule := range rules {
+		if rule.Control != "" && rule.Matches(path) {
+			return rule.Control
+		}
+	}
+
+	// 1. HTML files: Always revalidate (for live reload & version detection)
+	// 2. Hashed assets (/assets/*-*.ext): Cache forever (content-addressed)
+	// 3. Other files: Short cache (5 minutes)
+	if strings.HasSuffix(path, ".html") {
+		return "no-cache, must-revalidate"
+	}
+	if strings.HasPrefix(path, "assets/") && strings.Contains(pathpkg.Base(path), "-") {
+		return "public, max-age=31536000, immutable"
+	}
+	return "public, max-age=300"
+}
+
+// notModified evaluates If-None-Match, then (only without it, per RFC 9110)
+// If-Modified-Since, against the served representation
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return ETagMatches(match, etag)
+	}
+	if modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
+
+// writeNotModified sends a 304, dropping headers that only describe a body
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	h.Del("Content-Encoding")
+	w.WriteHeader(http.StatusNotModified)
+}
@@ -0,0 +1,110 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheControlFor(t *testing.T) {
+	rules := []CacheRule{
+		{Path: "/static/*", Control: "public, max-age=86400"},
+		{Path: "*.json", Control: "no-store"},
+		{Path: "docs/*.html", Control: "public, max-age=60"},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"static/app.js", "public, max-age=86400"},
+		{"static/img/logo.png", "public, max-age=86400"},
+		{"data/feed.json", "no-store"},
+		{"docs/intro.html", "public, max-age=60"},
+		{"docs/deep/intro.html", "no-cache, must-revalidate"},
+		{"index.html", "no-cache, must-revalidate"},
+		{"assets/index-a1b2c3.js", "public, max-age=31536000, immutable"},
+		{"favicon.ico", "public, max-age=300"},
+	}
+	for _, tt := range tests {
+		if got := CacheControlFor(rules, tt.path); got != tt.want {
+			t.Errorf("CacheControlFor(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	fs.WriteFile("site", "logo.svg", strings.NewReader("<svg/>"), 6, "image/svg+xml")
+	file, _ := fs.ReadFile("site", "logo.svg")
+	etag := `"` + file.Hash + `"`
+
+	w := httptest.NewRecorder()
+	ServeVFS(w, httptest.NewRequest("GET", "/logo.svg", nil), "site")
+	lastModified := w.Header().Get("Last-Modified")
+	if w.Header().Get("ETag") != etag || lastModified == "" {
+		t.Fatalf("Expected ETag and Last-Modified, got %q / %q", w.Header().Get("ETag"), lastModified)
+	}
+
+	// If-Modified-Since at or after the file's time revalidates
+	req := httptest.NewRequest("GET", "/logo.svg", nil)
+	req.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected empty 304, got %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "public, max-age=300" {
+		t.Errorf("Expected Cache-Control on 304, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	req.Header.Set("If-Modified-Since", file.ModTime.Add(-time.Hour).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for older If-Modified-Since, got %d", w.Code)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req.Header.Set("If-Modified-Since", lastModified)
+	req.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	ServeVFS(w, req, "site")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a stale ETag, got %d", w.Code)
+	}
+}
+
+func TestManifestCacheRules(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	fs.WriteFile("site", "logo.svg", strings.NewReader("<svg/>"), 6, "image/svg+xml")
+	get := func() string {
+		w := httptest.NewRecorder()
+		ServeVFS(w, httptest.NewRequest("GET", "/logo.svg", nil), "site")
+		return w.Header().Get("Cache-Control")
+	}
+
+	if got := get(); got != "public, max-age=300" {
+		t.Errorf("Expected default without manifest, got %q", got)
+	}
+
+	// Deploying a manifest takes effect on the next request
+	manifest := `{"name":"site","cache":[{"path":"*.svg","control":"public, max-age=604800"}]}`
+	fs.WriteFile("site", "manifest.json", strings.NewReader(manifest), int64(len(manifest)), "application/json")
+	if got := get(); got != "public, max-age=604800" {
+		t.Errorf("Expected manifest rule, got %q", got)
+	}
+
+	manifest = `{"name":"site","cache":[{"path":"*.svg","control":"no-store"}]}`
+	fs.WriteFile("site", "manifest.json", strings.NewReader(manifest), int64(len(manifest)), "application/json")
+	if got := get(); got != "no-store" {
+		t.Errorf("Expected updated manifest rule, got %q", got)
+	}
+}
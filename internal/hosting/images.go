@@ -0,0 +1,105 @@
+package hosting
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// AppImages controls image transform URLs (/photo.jpg?w=400&format=png)
+// for an app's deployed images, declared in manifest.json. Transforms are
+// on unless "transform" is false; sizes above the limits are clamped.
+// With "responsive" on, each deploy also pre-generates smaller widths of
+// every image and gives the app's <img> tags a srcset listing them.
+//
+//	"images": {"transform": true, "max_width": 1600, "max_height": 1600, "responsive": true}
+type AppImages struct {
+	Transform  *bool `json:"transform,omitempty"`  // default true
+	MaxWidth   int   `json:"max_width,omitempty"`  // default: the global 4096 limit
+	MaxHeight  int   `json:"max_height,omitempty"` // default: the global 4096 limit
+	Responsive bool  `json:"responsive,omitempty"` // pre-generate variants at deploy
+	Widths     []int `json:"widths,omitempty"`     // responsive widths (default 480, 960, 1600)
+}
+
+// Enabled reports whether the app serves transformed images
+func (a *AppImages) Enabled() bool {
+	return a == nil || a.Transform == nil || *a.Transform
+}
+
+// clamp scales a requested size down to the app's limits, keeping the
+// requested aspect ratio when both sides were given. Widths are rounded up
+// to the cache step later, so the width limit is rounded down to it.
+func (a *AppImages) clamp(opts media.TransformOpts) media.TransformOpts {
+	if a == nil {
+		return opts
+	}
+	step := system.GetLimits().Media.WidthStep
+	if a.MaxWidth > 0 && media.SnapToStep(opts, step).Width > a.MaxWidth {
+		maxWidth := a.MaxWidth
+		if step > 0 && maxWidth >= step {
+			maxWidth -= maxWidth % step
+		}
+		if opts.Height > 0 {
+			opts.Height = opts.Height * maxWidth / opts.Width
+		}
+		opts.Width = maxWidth
+	}
+	if a.MaxHeight > 0 && opts.Height > a.MaxHeight {
+		if opts.Width > 0 {
+			opts.Width = opts.Width * a.MaxHeight / opts.Height
+		}
+		opts.Height = a.MaxHeight
+	}
+	return opts
+}
+
+// serveTransformedImage answers an image request with transform query
+// params (?w=, ?h=, ?fit=, ?q=, ?format=) with a resized copy, cached like
+// fazt.app.media.serve variants. It returns false, having written nothing,
+// when the original should be served instead.
+func serveTransformedImage(w http.ResponseWriter, r *http.Request, siteID, path string, file *File, contentType string, manifest *AppManifest) bool {
+	if r.URL.RawQuery == "" || !media.IsImageContentType(contentType) || !manifest.Images.Enabled() {
+		return false
+	}
+	opts := manifest.Images.clamp(media.ParseTransformQuery(r.URL.Query()))
+	if !opts.HasTransform() || database == nil {
+		return false
+	}
+
+	// Each variant of each deployed version gets its own ETag
+	etag := fmt.Sprintf(`"%s-%s"`, file.Hash, opts.CacheKey())
+	w.Header().Set("ETag", etag)
+	if !file.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", file.ModTime.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", CacheControlFor(manifest.Cache, path))
+	if notModified(r, etag, file.ModTime) {
+		writeNotModified(w)
+		return true
+	}
+
+	data, err := io.ReadAll(file.Content)
+	if err != nil {
+		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		return true
+	}
+	// The file hash is part of the key, so a redeployed image never gets
+	// its old variants
+	cache := media.NewStaticMediaCache(database)
+	out, mime, err := media.ProcessAndCache(r.Context(), cache, siteID, path+"@"+file.Hash, data, opts)
+	if err != nil || mime == "" {
+		// Undecodable, too large or resize slots busy: the original,
+		// which mustn't be cached as the variant
+		out, mime = data, contentType
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	w.Header().Set("Content-Type", mime)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(out)))
+	w.Write(out)
+	return true
+}
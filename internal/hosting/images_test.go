@@ -0,0 +1,166 @@
+package hosting
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func deployImageSite(t *testing.T, site, manifest string) []byte {
+	t.Helper()
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 200, 100)))
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	files := map[string][]byte{"photo.png": img.Bytes(), "notes.txt": []byte("hi")}
+	if manifest != "" {
+		files["manifest.json"] = []byte(manifest)
+	}
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write(content)
+	}
+	zw.Close()
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if _, err := DeploySite(zr, site); err != nil {
+		t.Fatalf("DeploySite failed: %v", err)
+	}
+	return img.Bytes()
+}
+
+func TestServeVFS_ImageTransform(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	db.Exec(`CREATE TABLE app_blobs (app_id TEXT NOT NULL, path TEXT NOT NULL, data BLOB NOT NULL,
+		mime_type TEXT NOT NULL, size_bytes INTEGER NOT NULL, hash TEXT NOT NULL,
+		created_at INTEGER, updated_at INTEGER, PRIMARY KEY (app_id, path))`)
+
+	original := deployImageSite(t, "pics", `{"images": {"max_width": 120}}`)
+	deployImageSite(t, "plain", `{"images": {"transform": false}}`)
+
+	get := func(site, path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		ServeVFS(w, req, site)
+		return w
+	}
+	size := func(w *httptest.ResponseRecorder) (int, int) {
+		img, err := png.Decode(w.Body)
+		if err != nil {
+			t.Fatalf("Expected a PNG: %v", err)
+		}
+		return img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	w := get("pics", "/photo.png?w=80", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Unexpected response: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	etag := w.Header().Get("ETag")
+	if x, y := size(w); x != 100 || y != 50 {
+		t.Errorf("Expected 100x50 (80 rounded up to the width step), got %dx%d", x, y)
+	}
+
+	// Cached variants revalidate by their own ETag
+	if w := get("pics", "/photo.png?w=80", etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for the variant's ETag, got %d", w.Code)
+	}
+	var cached int
+	db.QueryRow(`SELECT COUNT(*) FROM app_blobs WHERE app_id = 'pics'`).Scan(&cached)
+	if cached != 1 {
+		t.Errorf("Expected 1 cached variant, got %d", cached)
+	}
+
+	// Clamped to the manifest's max_width
+	if x, _ := size(get("pics", "/photo.png?w=180", "")); x != 100 {
+		t.Errorf("Expected width clamped to 100, got %d", x)
+	}
+
+	// Not transformed: no params, not an image, or turned off
+	if w := get("pics", "/photo.png", ""); !bytes.Equal(w.Body.Bytes(), original) {
+		t.Error("Expected the original without transform params")
+	}
+	if w := get("pics", "/notes.txt?w=80", ""); w.Body.String() != "hi" {
+		t.Errorf("Expected the text file untouched, got %q", w.Body.String())
+	}
+	if w := get("plain", "/photo.png?w=80", ""); !bytes.Equal(w.Body.Bytes(), original) {
+		t.Error("Expected the original when transforms are off")
+	}
+
+	// A redeploy drops the old variants
+	deployImageSite(t, "pics", "")
+	db.QueryRow(`SELECT COUNT(*) FROM app_blobs WHERE app_id = 'pics'`).Scan(&cached)
+	if cached != 0 {
+		t.Errorf("Expected variants dropped on deploy, got %d", cached)
+	}
+}
+
+func TestDeploySite_ResponsiveImages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	db.Exec(`CREATE TABLE app_blobs (app_id TEXT NOT NULL, path TEXT NOT NULL, data BLOB NOT NULL,
+		mime_type TEXT NOT NULL, size_bytes INTEGER NOT NULL, hash TEXT NOT NULL,
+		created_at INTEGER, updated_at INTEGER, PRIMARY KEY (app_id, path))`)
+
+	var img bytes.Buffer
+	png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 200, 100)))
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	files := map[string]string{
+		"img/photo.png": img.String(),
+		"index.html": `<img src="img/photo.png" alt="a"><img src='/img/photo.png'/>` +
+			`<img src="img/photo.png" srcset="x.png 1x"><img src="https://cdn.example.com/img/photo.png">`,
+		"manifest.json": `{"images": {"responsive": true, "widths": [80, 150, 400]}}`,
+	}
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if _, err := DeploySite(zr, "resp"); err != nil {
+		t.Fatalf("DeploySite failed: %v", err)
+	}
+
+	// 80 snaps up to 100; 400 is wider than the image
+	var cached int
+	db.QueryRow(`SELECT COUNT(*) FROM app_blobs WHERE app_id = 'resp'`).Scan(&cached)
+	if cached != 2 {
+		t.Errorf("Expected 2 pre-generated variants, got %d", cached)
+	}
+
+	file, err := fs.ReadFile("resp", "index.html")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	page, _ := io.ReadAll(file.Content)
+	want := `<img src="img/photo.png" alt="a" srcset="img/photo.png?w=100 100w, img/photo.png?w=150 150w, img/photo.png 200w">` +
+		`<img src='/img/photo.png' srcset="/img/photo.png?w=100 100w, /img/photo.png?w=150 150w, /img/photo.png 200w" />` +
+		`<img src="img/photo.png" srcset="x.png 1x"><img src="https://cdn.example.com/img/photo.png">`
+	if string(page) != want {
+		t.Errorf("Unexpected page:\n%s\nwant:\n%s", page, want)
+	}
+
+	// Serving a listed width finds the pre-generated variant
+	req := httptest.NewRequest("GET", "/img/photo.png?w=150", nil)
+	w := httptest.NewRecorder()
+	ServeVFS(w, req, "resp")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM app_blobs WHERE app_id = 'resp'`).Scan(&cached)
+	if cached != 2 {
+		t.Errorf("Expected the variant served from the cache, got %d variants", cached)
+	}
+}
@@ -0,0 +1,247 @@
+package hosting
+
+import (
+	"archive/zip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Deploy limit scopes
+const (
+	LimitScopeApp  = "app"  // A site, by the name it's deployed to
+	LimitScopeUser = "user" // A deployer: "api_key:<name>" or a user ID
+)
+
+// Default deploy limits (server.deploy_limits.*)
+const (
+	DefaultMaxBundleBytes = 500 << 20
+	DefaultMaxFiles       = 10000
+	DefaultMaxFileBytes   = 100 << 20
+)
+
+var (
+	ErrDeployLimitNotFound = errors.New("deploy limit not found")
+	ErrInvalidDeployLimit  = errors.New("invalid deploy limit")
+)
+
+// DeployLimits bound what one deploy may write. Zero fields are unset: an
+// override leaves them to the next level, and a global zero is unlimited.
+type DeployLimits struct {
+	MaxBundleBytes int64 `json:"max_bundle_bytes,omitempty"` // Total size of the files, uncompressed
+	MaxFiles       int   `json:"max_files,omitempty"`
+	MaxFileBytes   int64 `json:"max_file_bytes,omitempty"` // Largest single file, uncompressed
+}
+
+// DeployLimitOverride is an app's or a deployer's own deploy limits
+type DeployLimitOverride struct {
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+	DeployLimits
+	UpdatedAt int64 `json:"updated_at"`
+}
+
+// DeployLimitError refuses a deploy over one of its limits
+type DeployLimitError struct {
+	Limit  string // max_bundle_bytes, max_files or max_file_bytes
+	Max    int64
+	Actual int64
+	Path   string // The file over max_file_bytes
+}
+
+func (e *DeployLimitError) Error() string {
+	switch e.Limit {
+	case "max_files":
+		return fmt.Sprintf("deploy has %d files, over the limit of %d", e.Actual, e.Max)
+	case "max_file_bytes":
+		return fmt.Sprintf("%s is %d bytes, over the per-file limit of %d", e.Path, e.Actual, e.Max)
+	}
+	return fmt.Sprintf("deploy is %d bytes, over the limit of %d", e.Actual, e.Max)
+}
+
+var globalDeployLimits = struct {
+	sync.RWMutex
+	l DeployLimits
+}{l: DeployLimits{
+	MaxBundleBytes: DefaultMaxBundleBytes,
+	MaxFiles:       DefaultMaxFiles,
+	MaxFileBytes:   DefaultMaxFileBytes,
+}}
+
+// SetDeployLimits sets the limits of deploys without their own
+func SetDeployLimits(l DeployLimits) {
+	globalDeployLimits.Lock()
+	globalDeployLimits.l = l
+	globalDeployLimits.Unlock()
+}
+
+// GlobalDeployLimits returns the limits of deploys without their own
+func GlobalDeployLimits() DeployLimits {
+	globalDeployLimits.RLock()
+	defer globalDeployLimits.RUnlock()
+	return globalDeployLimits.l
+}
+
+// or fills l's unset limits from fallback
+func (l DeployLimits) or(fallback DeployLimits) DeployLimits {
+	if l.MaxBundleBytes == 0 {
+		l.MaxBundleBytes = fallback.MaxBundleBytes
+	}
+	if l.MaxFiles == 0 {
+		l.MaxFiles = fallback.MaxFiles
+	}
+	if l.MaxFileBytes == 0 {
+		l.MaxFileBytes = fallback.MaxFileBytes
+	}
+	return l
+}
+
+// SetDeployLimitOverride creates or replaces an app's or a deployer's limits
+func SetDeployLimitOverride(db *sql.DB, scope, name string, l DeployLimits) (*DeployLimitOverride, error) {
+	if scope != LimitScopeApp && scope != LimitScopeUser {
+		return nil, fmt.Errorf("%w: scope must be app or user", ErrInvalidDeployLimit)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidDeployLimit)
+	}
+	if l.MaxBundleBytes < 0 || l.MaxFiles < 0 || l.MaxFileBytes < 0 {
+		return nil, fmt.Errorf("%w: limits must not be negative", ErrInvalidDeployLimit)
+	}
+	if l == (DeployLimits{}) {
+		return nil, fmt.Errorf("%w: set at least one limit", ErrInvalidDeployLimit)
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO deploy_limits (scope, name, max_bundle_bytes, max_files, max_file_bytes, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(scope, name) DO UPDATE SET max_bundle_bytes = excluded.max_bundle_bytes,
+			max_files = excluded.max_files, max_file_bytes = excluded.max_file_bytes, updated_at = excluded.updated_at
+	`, scope, name, nullLimit(l.MaxBundleBytes), nullLimit(int64(l.MaxFiles)), nullLimit(l.MaxFileBytes), time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	return &DeployLimitOverride{Scope: scope, Name: name, DeployLimits: l, UpdatedAt: time.Now().Unix()}, nil
+}
+
+// DeleteDeployLimitOverride removes an app's or a deployer's limits
+func DeleteDeployLimitOverride(db *sql.DB, scope, name string) error {
+	result, err := db.Exec(`DELETE FROM deploy_limits WHERE scope = ? AND name = ?`, scope, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrDeployLimitNotFound
+	}
+	return nil
+}
+
+// ListDeployLimitOverrides returns every app's and deployer's own limits
+func ListDeployLimitOverrides(db *sql.DB) ([]DeployLimitOverride, error) {
+	rows, err := db.Query(`
+		SELECT scope, name, COALESCE(max_bundle_bytes, 0), COALESCE(max_files, 0), COALESCE(max_file_bytes, 0), updated_at
+		FROM deploy_limits ORDER BY scope, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []DeployLimitOverride{}
+	for rows.Next() {
+		var o DeployLimitOverride
+		if err := rows.Scan(&o.Scope, &o.Name, &o.MaxBundleBytes, &o.MaxFiles, &o.MaxFileBytes, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
+
+// ResolveDeployLimits returns the limits of a deploy to app by deployedBy.
+// Each limit is the app's if set, else the deployer's, else the global one.
+func ResolveDeployLimits(db *sql.DB, app, deployedBy string) (DeployLimits, error) {
+	own := map[string]DeployLimits{}
+	rows, err := db.Query(`
+		SELECT scope, COALESCE(max_bundle_bytes, 0), COALESCE(max_files, 0), COALESCE(max_file_bytes, 0)
+		FROM deploy_limits WHERE (scope = ? AND name = ?) OR (scope = ? AND name = ?)
+	`, LimitScopeApp, app, LimitScopeUser, deployedBy)
+	if err != nil {
+		return DeployLimits{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scope string
+		var l DeployLimits
+		if err := rows.Scan(&scope, &l.MaxBundleBytes, &l.MaxFiles, &l.MaxFileBytes); err != nil {
+			return DeployLimits{}, err
+		}
+		own[scope] = l
+	}
+	if err := rows.Err(); err != nil {
+		return DeployLimits{}, err
+	}
+	return own[LimitScopeApp].or(own[LimitScopeUser]).or(GlobalDeployLimits()), nil
+}
+
+// CheckZip returns a *DeployLimitError if the ZIP's files exceed a limit.
+// Sizes come from the ZIP headers, which archive/zip holds entries to as
+// they are read.
+func (l DeployLimits) CheckZip(zipReader *zip.Reader) error {
+	var files []sizedFile
+	for _, file := range zipReader.File {
+		if !file.FileInfo().IsDir() {
+			files = append(files, sizedFile{file.Name, int64(file.UncompressedSize64)})
+		}
+	}
+	return l.check(files)
+}
+
+// CheckFiles returns a *DeployLimitError if files exceed a limit
+func (l DeployLimits) CheckFiles(files []DeployFile) error {
+	sized := make([]sizedFile, len(files))
+	for i, file := range files {
+		sized[i] = sizedFile{file.Path, int64(len(file.Content))}
+	}
+	return l.check(sized)
+}
+
+type sizedFile struct {
+	path string
+	size int64
+}
+
+func (l DeployLimits) check(files []sizedFile) error {
+	if l.MaxFiles > 0 && len(files) > l.MaxFiles {
+		return &DeployLimitError{Limit: "max_files", Max: int64(l.MaxFiles), Actual: int64(len(files))}
+	}
+	var total int64
+	for _, f := range files {
+		if l.MaxFileBytes > 0 && f.size > l.MaxFileBytes {
+			return &DeployLimitError{Limit: "max_file_bytes", Max: l.MaxFileBytes, Actual: f.size, Path: f.path}
+		}
+		total += f.size
+	}
+	if l.MaxBundleBytes > 0 && total > l.MaxBundleBytes {
+		return &DeployLimitError{Limit: "max_bundle_bytes", Max: l.MaxBundleBytes, Actual: total}
+	}
+	return nil
+}
+
+// CheckDeployLimits resolves the limits of a deploy to app by deployedBy
+// and checks the ZIP against them
+func CheckDeployLimits(db *sql.DB, app, deployedBy string, zipReader *zip.Reader) error {
+	limits, err := ResolveDeployLimits(db, app, deployedBy)
+	if err != nil {
+		return err
+	}
+	return limits.CheckZip(zipReader)
+}
+
+func nullLimit(n int64) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+package hosting
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func setupLimitsDB(t *testing.T) *sql.DB {
+	db := setupTestDB(t)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TABLE deploy_limits (
+		scope TEXT NOT NULL,
+		name TEXT NOT NULL,
+		max_bundle_bytes INTEGER,
+		max_files INTEGER,
+		max_file_bytes INTEGER,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (scope, name)
+	)`); err != nil {
+		t.Fatalf("Failed to create deploy_limits: %v", err)
+	}
+	return db
+}
+
+func TestResolveDeployLimits(t *testing.T) {
+	db := setupLimitsDB(t)
+	SetDeployLimits(DeployLimits{MaxBundleBytes: 1000, MaxFiles: 10, MaxFileBytes: 100})
+	t.Cleanup(func() {
+		SetDeployLimits(DeployLimits{MaxBundleBytes: DefaultMaxBundleBytes, MaxFiles: DefaultMaxFiles, MaxFileBytes: DefaultMaxFileBytes})
+	})
+
+	if _, err := SetDeployLimitOverride(db, LimitScopeUser, "api_key:ci", DeployLimits{MaxBundleBytes: 50, MaxFiles: 5}); err != nil {
+		t.Fatalf("SetDeployLimitOverride failed: %v", err)
+	}
+	if _, err := SetDeployLimitOverride(db, LimitScopeApp, "blog", DeployLimits{MaxBundleBytes: 5000}); err != nil {
+		t.Fatalf("SetDeployLimitOverride failed: %v", err)
+	}
+
+	tests := []struct {
+		app, deployedBy string
+		want            DeployLimits
+	}{
+		{"other", "api_key:other", DeployLimits{MaxBundleBytes: 1000, MaxFiles: 10, MaxFileBytes: 100}},
+		{"other", "api_key:ci", DeployLimits{MaxBundleBytes: 50, MaxFiles: 5, MaxFileBytes: 100}},
+		{"blog", "api_key:other", DeployLimits{MaxBundleBytes: 5000, MaxFiles: 10, MaxFileBytes: 100}},
+		{"blog", "api_key:ci", DeployLimits{MaxBundleBytes: 5000, MaxFiles: 5, MaxFileBytes: 100}},
+	}
+	for _, tt := range tests {
+		got, err := ResolveDeployLimits(db, tt.app, tt.deployedBy)
+		if err != nil {
+			t.Fatalf("ResolveDeployLimits failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("ResolveDeployLimits(%s, %s) = %+v, want %+v", tt.app, tt.deployedBy, got, tt.want)
+		}
+	}
+
+	if err := DeleteDeployLimitOverride(db, LimitScopeApp, "blog"); err != nil {
+		t.Fatalf("DeleteDeployLimitOverride failed: %v", err)
+	}
+	if err := DeleteDeployLimitOverride(db, LimitScopeApp, "blog"); !errors.Is(err, ErrDeployLimitNotFound) {
+		t.Errorf("Expected ErrDeployLimitNotFound, got %v", err)
+	}
+	overrides, err := ListDeployLimitOverrides(db)
+	if err != nil || len(overrides) != 1 || overrides[0].Name != "api_key:ci" || overrides[0].MaxFileBytes != 0 {
+		t.Errorf("Expected only the deployer's override left, got %+v (%v)", overrides, err)
+	}
+
+	for _, bad := range []struct {
+		scope, name string
+		l           DeployLimits
+	}{
+		{"site", "blog", DeployLimits{MaxFiles: 1}},
+		{LimitScopeApp, "", DeployLimits{MaxFiles: 1}},
+		{LimitScopeApp, "blog", DeployLimits{MaxFiles: -1}},
+		{LimitScopeApp, "blog", DeployLimits{}},
+	} {
+		if _, err := SetDeployLimitOverride(db, bad.scope, bad.name, bad.l); !errors.Is(err, ErrInvalidDeployLimit) {
+			t.Errorf("SetDeployLimitOverride(%s, %q, %+v) = %v, want ErrInvalidDeployLimit", bad.scope, bad.name, bad.l, err)
+		}
+	}
+}
+
+func TestDeployLimits_CheckZip(t *testing.T) {
+	zipReader := secretsZip(t, map[string]string{
+		"index.html":   strings.Repeat("a", 40),
+		"app.js":       strings.Repeat("b", 30),
+		"img/logo.svg": strings.Repeat("c", 20),
+	})
+
+	tests := []struct {
+		limits DeployLimits
+		limit  string
+	}{
+		{DeployLimits{}, ""},
+		{DeployLimits{MaxBundleBytes: 90, MaxFiles: 3, MaxFileBytes: 40}, ""},
+		{DeployLimits{MaxFiles: 2}, "max_files"},
+		{DeployLimits{MaxFileBytes: 39}, "max_file_bytes"},
+		{DeployLimits{MaxBundleBytes: 89}, "max_bundle_bytes"},
+	}
+	for _, tt := range tests {
+		err := tt.limits.CheckZip(zipReader)
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("CheckZip(%+v) = %v, want nil", tt.limits, err)
+			}
+			continue
+		}
+		var limitErr *DeployLimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tt.limit {
+			t.Errorf("CheckZip(%+v) = %v, want %s exceeded", tt.limits, err, tt.limit)
+		}
+	}
+
+	err := DeployLimits{MaxFileBytes: 39}.CheckZip(zipReader)
+	if limitErr, ok := err.(*DeployLimitError); !ok || limitErr.Path != "index.html" || limitErr.Actual != 40 {
+		t.Errorf("Expected index.html named as over the per-file limit, got %v", err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	iofs "io/fs"
-	"mime"
 	"net"
 	"net/http"
 	"path/filepath"
@@ -12,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/fazt-sh/fazt/internal/assets"
+	"github.com/fazt-sh/fazt/internal/realip"
 )
 
 var (
@@ -43,8 +43,9 @@ func Init(db *sql.DB) error {
 // EnsureSystemSites checks and seeds reserved sites from embedded assets
 func EnsureSystemSites() error {
 	sites := map[string]string{
-		"root": "system/root",
-		"404":  "system/404",
+		"root":   "system/root",
+		"404":    "system/404",
+		"status": "system/status",
 		// "admin" removed - now uses the deployed admin-ui app
 	}
 
@@ -80,10 +81,7 @@ func EnsureSystemSites() error {
 				defer content.Close()
 
 				info, _ := d.Info()
-				mimeType := mime.TypeByExtension(filepath.Ext(path))
-				if mimeType == "" {
-					mimeType = "application/octet-stream"
-				}
+				mimeType := GetMimeType(path)
 
 				if err := fs.WriteFile(siteID, relPath, content, info.Size(), mimeType); err != nil {
 					return fmt.Errorf("failed to write asset %s to VFS: %w", relPath, err)
@@ -148,7 +146,7 @@ func ValidateSubdomain(subdomain string) error {
 	}
 
 	// Reserved subdomains (admin removed - now serves the admin-ui app)
-	reserved := []string{"www", "api", "mail", "ftp", "smtp", "pop", "imap", "ns1", "ns2", "localhost"}
+	reserved := []string{"www", "api", "mail", "ftp", "smtp", "pop", "imap", "ns1", "ns2", "s3", "localhost"}
 	for _, r := range reserved {
 		if subdomain == r {
 			return fmt.Errorf("'%s' is a reserved subdomain", subdomain)
@@ -218,13 +216,7 @@ func DeleteSite(subdomain string) error {
 // IsLocalRequest checks if a request originates from a local/private IP.
 // Used to restrict certain routes (like /_app/) to local development only.
 func IsLocalRequest(r *http.Request) bool {
-	// Get the client IP from RemoteAddr
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		host = r.RemoteAddr
-	}
-
-	ip := net.ParseIP(host)
+	ip := net.ParseIP(realip.FromRequest(r))
 	if ip == nil {
 		return false
 	}
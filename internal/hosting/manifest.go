@@ -0,0 +1,226 @@
+package hosting
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/fazt-sh/fazt/internal/services/openapi"
+)
+
+// AppManifest holds the serving settings an app ships in its manifest.json
+type AppManifest struct {
+	Cache  []CacheRule       `json:"cache"`
+	Routes RouteRules        `json:"routes"`
+	On     map[string]string `json:"on"`    // Event type -> handler file, e.g. "s3.put": "hooks/thumbnail.js"
+	Types  map[string]string `json:"types"` // Path pattern -> Content-Type, e.g. "downloads/*": "application/octet-stream"
+
+	Services map[string]AppService `json:"services"` // Name -> long-running handler, e.g. "bot"
+	Forms    map[string]AppForm    `json:"forms"`    // Name -> form posted to /__forms/<name>, e.g. "contact"
+	Calendar *AppCalendar          `json:"calendar"` // Events served as an iCalendar feed at /calendar.ics
+	Images   *AppImages            `json:"images"`   // Image transform URLs, e.g. /photo.jpg?w=400
+	Docs     *AppDocs              `json:"docs"`     // API docs served at /_docs
+	Export   *AppExport            `json:"export"`   // Dynamic routes a static export renders
+
+	Anonymize map[string]string `json:"anonymize"` // Field -> fake value kind for storage copied into forks, e.g. "email": "email"
+
+	Runtime string `json:"runtime"` // Runtime API version the app targets, e.g. "v1"
+}
+
+// AppService is a long-running handler an app's manifest.json declares, run
+// as a daemon job from the app's next deploy on:
+//
+//	"services": {"bot": {"handler": "services/bot.js", "restart": "always", "health": "/api/health"}}
+type AppService struct {
+	Handler string `json:"handler"`          // e.g. "services/bot.js"
+	Restart string `json:"restart"`          // always (default), on-failure or never
+	Health  string `json:"health,omitempty"` // App path that answers 2xx/3xx while healthy
+	Memory  string `json:"memory,omitempty"` // e.g. "64MB" (default: the job default)
+}
+
+// AppForm is a form static pages post to /__forms/<name>, declared in
+// manifest.json. Submissions are stored in the app's ds collection.
+//
+//	"forms": {"contact": {"fields": ["name", "email", "message"], "required": ["email"], "notify": true}}
+type AppForm struct {
+	Fields     []string `json:"fields,omitempty"`     // Fields kept (default: all but _-prefixed ones)
+	Required   []string `json:"required,omitempty"`   // Fields that must not be blank
+	Collection string   `json:"collection,omitempty"` // ds collection (default "forms")
+	Redirect   string   `json:"redirect,omitempty"`   // Where browsers go after submitting, e.g. "/thanks.html"
+	Origins    []string `json:"origins,omitempty"`    // Other sites allowed to post, e.g. "https://example.com"
+	Honeypot   string   `json:"honeypot,omitempty"`   // Hidden field only bots fill in (default "_gotcha")
+	Notify     bool     `json:"notify,omitempty"`     // Notify the admin of each submission
+}
+
+// AppCalendar publishes events from an app's ds collection as an iCalendar
+// feed at /calendar.ics, declared in manifest.json, for phone and desktop
+// calendars to subscribe to.
+//
+//	"calendar": {"collection": "events", "name": "Club events", "where": {"public": true}}
+type AppCalendar struct {
+	Collection string                 `json:"collection"`      // ds collection of events
+	Name       string                 `json:"name,omitempty"`  // Calendar name shown by subscribers (default: the app ID)
+	Where      map[string]interface{} `json:"where,omitempty"` // ds query events must match, e.g. {"status": {"$ne": "draft"}}
+}
+
+// AppDocs publishes an app's API routes as an OpenAPI spec at
+// /_docs/openapi.json and a docs page at /_docs, declared in manifest.json.
+// Routes are described by JSDoc comments on their handlers (see
+// openapi.ParseAnnotations); notes here add to or override them.
+//
+//	"docs": {"title": "Shop API", "routes": {"GET /api/items": {"summary": "List items", "tags": ["items"]}}}
+type AppDocs struct {
+	Title       string                       `json:"title,omitempty"`       // Default: the app ID
+	Version     string                       `json:"version,omitempty"`     // API version (default "1.0.0")
+	Description string                       `json:"description,omitempty"` // Shown above the routes
+	Routes      map[string]openapi.Operation `json:"routes,omitempty"`      // "GET /api/items/:id" -> notes
+}
+
+// parsedManifests remembers each manifest by content hash, so it's only
+// decoded again after a deploy changes it
+var (
+	parsedManifests   = make(map[string]parsedManifest)
+	parsedManifestsMu sync.RWMutex
+)
+
+type parsedManifest struct {
+	hash     string
+	manifest *AppManifest
+}
+
+// noManifest is served for apps without a (valid) manifest.json
+var noManifest = &AppManifest{}
+
+// appManifest returns the serving settings from an app's manifest.json.
+// Missing manifests are remembered like missing precompressed variants, so
+// apps without one don't pay an extra query per request.
+func (fs *SQLFileSystem) appManifest(id string, read func(id, path string) (*File, error)) *AppManifest {
+	file, err := fs.readVariant(id, "manifest.json", read)
+	if err != nil {
+		return noManifest
+	}
+	defer file.Content.Close()
+
+	key := cacheKey(id, "manifest.json")
+	parsedManifestsMu.RLock()
+	cached, ok := parsedManifests[key]
+	parsedManifestsMu.RUnlock()
+	if ok && cached.hash == file.Hash {
+		return cached.manifest
+	}
+
+	// Malformed manifests fall back to the defaults
+	manifest := &AppManifest{}
+	if err := json.NewDecoder(file.Content).Decode(manifest); err != nil {
+		manifest = noManifest
+	}
+
+	parsedManifestsMu.Lock()
+	parsedManifests[key] = parsedManifest{hash: file.Hash, manifest: manifest}
+	parsedManifestsMu.Unlock()
+	return manifest
+}
+
+// manifestFor returns an app's manifest, or an empty one when fs isn't
+// SQL-backed
+func manifestFor(siteID string) *AppManifest {
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		return sqlFS.appManifest(siteID, sqlFS.ReadFile)
+	}
+	return noManifest
+}
+
+// ErrInvalidManifest is returned for an app whose manifest.json can't be
+// decoded, where falling back to the defaults would be unsafe
+var ErrInvalidManifest = errors.New("invalid manifest.json")
+
+// storedManifest reads an app's manifest.json from the database, for work
+// outside serving, e.g. forks and exports. A missing manifest gives the
+// defaults; a malformed one ErrInvalidManifest, as its rules, e.g. what to
+// anonymize, can't be honored.
+func storedManifest(db *sql.DB, appID string) (*AppManifest, error) {
+	var content []byte
+	err := db.QueryRow(`
+		SELECT content FROM files
+		WHERE (app_id = ?1 OR (site_id = (SELECT title FROM apps WHERE id = ?1) AND app_id IS NULL))
+		AND path = 'manifest.json'
+	`, appID).Scan(&content)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	manifest := &AppManifest{}
+	if content == nil {
+		return manifest, nil
+	}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+	return manifest, nil
+}
+
+// EventHandler returns the file an app's manifest.json registers for an
+// event type, or ""
+func EventHandler(appID, eventType string) string {
+	return manifestFor(appID).On[eventType]
+}
+
+// AppServices returns the services an app's manifest.json declares, by name
+func AppServices(appID string) map[string]AppService {
+	return manifestFor(appID).Services
+}
+
+// AppForms returns the forms an app's manifest.json declares, by name
+func AppForms(appID string) map[string]AppForm {
+	return manifestFor(appID).Forms
+}
+
+// AppCalendarFor returns the calendar feed an app's manifest.json declares,
+// or nil
+func AppCalendarFor(appID string) *AppCalendar {
+	return manifestFor(appID).Calendar
+}
+
+// AppDocsFor returns the API docs an app's manifest.json declares, or nil
+func AppDocsFor(appID string) *AppDocs {
+	return manifestFor(appID).Docs
+}
+
+// Runtime API versions an app's manifest.json can target with "runtime".
+// v0 is the original binding surface, storage included as fazt.storage.*;
+// v1 moved storage to fazt.app.* and leaves fazt.storage out. Apps without
+// a "runtime" predate the stamp and run as v0.
+const (
+	RuntimeV0      = "v0"
+	RuntimeV1      = "v1"
+	CurrentRuntime = RuntimeV1
+)
+
+// RuntimeVersions lists the runtime API versions this server runs, oldest
+// first
+var RuntimeVersions = []string{RuntimeV0, RuntimeV1}
+
+// ValidRuntime reports whether this server runs runtime API version v
+func ValidRuntime(v string) bool {
+	for _, known := range RuntimeVersions {
+		if v == known {
+			return true
+		}
+	}
+	return false
+}
+
+// AppRuntimeFor returns the runtime API version an app's manifest.json
+// targets. Unstamped apps get v0; versions newer than this server knows get
+// the current one, the closest it has.
+func AppRuntimeFor(appID string) string {
+	v := manifestFor(appID).Runtime
+	switch {
+	case v == "":
+		return RuntimeV0
+	case !ValidRuntime(v):
+		return CurrentRuntime
+	}
+	return v
+}
@@ -0,0 +1,115 @@
+package hosting
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// App member roles, from most to least privileged
+const (
+	AppRoleOwner  = "owner"
+	AppRoleEditor = "editor"
+	AppRoleViewer = "viewer"
+)
+
+var appRoleRank = map[string]int{
+	AppRoleViewer: 1,
+	AppRoleEditor: 2,
+	AppRoleOwner:  3,
+}
+
+// ErrInvalidAppRole is returned for roles other than owner/editor/viewer
+var ErrInvalidAppRole = errors.New("role must be 'owner', 'editor', or 'viewer'")
+
+// AppMember is a user's membership on an app
+type AppMember struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Name      string `json:"name,omitempty"`
+	Role      string `json:"role"`
+	AddedBy   string `json:"added_by,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// IsValidAppRole reports whether role is a known app role
+func IsValidAppRole(role string) bool {
+	_, ok := appRoleRank[role]
+	return ok
+}
+
+// AppRoleAllows reports whether a member with role have may act with role need
+func AppRoleAllows(have, need string) bool {
+	return appRoleRank[have] > 0 && appRoleRank[have] >= appRoleRank[need]
+}
+
+// GetAppMemberRole returns the user's role on an app, or "" if not a member
+func GetAppMemberRole(db *sql.DB, appID, userID string) (string, error) {
+	var role string
+	err := db.QueryRow(`SELECT role FROM app_members WHERE app_id = ? AND user_id = ?`, appID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// SetAppMember adds a user to an app or changes their role
+func SetAppMember(db *sql.DB, appID, userID, role, addedBy string) error {
+	if !IsValidAppRole(role) {
+		return ErrInvalidAppRole
+	}
+	var by interface{}
+	if addedBy != "" {
+		by = addedBy
+	}
+	_, err := db.Exec(`
+		INSERT INTO app_members (app_id, user_id, role, added_by)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(app_id, user_id) DO UPDATE SET role = excluded.role
+	`, appID, userID, role, by)
+	return err
+}
+
+// RemoveAppMember removes a user from an app
+func RemoveAppMember(db *sql.DB, appID, userID string) error {
+	_, err := db.Exec(`DELETE FROM app_members WHERE app_id = ? AND user_id = ?`, appID, userID)
+	return err
+}
+
+// ListAppMembers returns all members of an app
+func ListAppMembers(db *sql.DB, appID string) ([]AppMember, error) {
+	rows, err := db.Query(`
+		SELECT m.user_id, u.email, COALESCE(u.name, ''), m.role, COALESCE(m.added_by, ''), m.created_at
+		FROM app_members m
+		JOIN auth_users u ON u.id = m.user_id
+		WHERE m.app_id = ?
+		ORDER BY m.created_at
+	`, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []AppMember{}
+	for rows.Next() {
+		var m AppMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.Name, &m.Role, &m.AddedBy, &m.CreatedAt); err != nil {
+			continue
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// CountAppOwners returns the number of owners on an app
+func CountAppOwners(db *sql.DB, appID string) (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM app_members WHERE app_id = ? AND role = ?`, appID, AppRoleOwner).Scan(&n)
+	return n, err
+}
+
+// GetUserIDByEmail returns the auth user ID for an email address
+func GetUserIDByEmail(db *sql.DB, email string) (string, error) {
+	var id string
+	err := db.QueryRow(`SELECT id FROM auth_users WHERE email = ?`, email).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,137 @@
+package hosting
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// sniffLen is how much of a file DetectMimeType looks at
+const sniffLen = 512
+
+// mimeTypes are the types of common web formats. They take precedence over
+// the host's mime.types, which varies by system and often lacks newer
+// formats.
+var mimeTypes = map[string]string{
+	".html":        "text/html",
+	".htm":         "text/html",
+	".css":         "text/css",
+	".js":          "text/javascript",
+	".mjs":         "text/javascript",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".txt":         "text/plain",
+	".md":          "text/markdown",
+	".csv":         "text/csv",
+	".xml":         "application/xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".gif":         "image/gif",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".svg":         "image/svg+xml",
+	".ico":         "image/x-icon",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".eot":         "application/vnd.ms-fontobject",
+	".wasm":        "application/wasm",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+	".mp3":         "audio/mpeg",
+	".ogg":         "audio/ogg",
+	".wav":         "audio/wav",
+	".pdf":         "application/pdf",
+	".zip":         "application/zip",
+}
+
+// GetMimeType returns the MIME type for a file path by its extension, or
+// application/octet-stream if it's unknown
+func GetMimeType(path string) string {
+	return DetectMimeType(path, nil)
+}
+
+// DetectMimeType returns the MIME type of a file by its extension, falling
+// back to sniffing the start of its content (up to sniffLen bytes) for
+// extensionless files and unknown extensions. Text types get a UTF-8
+// charset unless they name one.
+func DetectMimeType(path string, head []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	mimeType, ok := mimeTypes[ext]
+	if !ok && ext != "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	if mimeType == "" {
+		mimeType = sniffMimeType(head)
+	}
+	return withCharset(mimeType)
+}
+
+// sniffMimeType identifies content by its magic bytes, or returns
+// application/octet-stream
+func sniffMimeType(head []byte) string {
+	if len(head) == 0 {
+		return "application/octet-stream"
+	}
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	// ISO media files name their brand after "ftyp"; http.DetectContentType
+	// only knows the MP4 ones
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "avif", "avis":
+			return "image/avif"
+		case "heic", "heix":
+			return "image/heic"
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// withCharset adds charset=utf-8 to text types without a charset
+func withCharset(mimeType string) string {
+	if mimeType == "" {
+		return "application/octet-stream"
+	}
+	base, params, _ := strings.Cut(mimeType, ";")
+	base = strings.ToLower(strings.TrimSpace(base))
+	if strings.Contains(strings.ToLower(params), "charset=") || !isTextType(base) {
+		return mimeType
+	}
+	return mimeType + "; charset=utf-8"
+}
+
+// isTextType reports whether a MIME type (without parameters) is text
+func isTextType(base string) bool {
+	switch base {
+	case "application/javascript", "application/json", "application/xml":
+		return true
+	}
+	return strings.HasPrefix(base, "text/") ||
+		strings.HasSuffix(base, "+json") || strings.HasSuffix(base, "+xml")
+}
+
+// ContentType returns the Content-Type to serve the file at path (no
+// leading slash) with: the manifest's "types" override, else the type
+// stored at deploy, else one from the extension. Of several matching
+// overrides the longest pattern wins.
+func (m *AppManifest) ContentType(path, stored string) string {
+	override, matched := "", ""
+	for pattern, mimeType := range m.Types {
+		if len(pattern) > len(matched) && (CacheRule{Path: pattern}).Matches(path) {
+			override, matched = mimeType, pattern
+		}
+	}
+	if override != "" {
+		return withCharset(override)
+	}
+	if stored != "" && stored != "application/octet-stream" {
+		return withCharset(stored)
+	}
+	return GetMimeType(path)
+}
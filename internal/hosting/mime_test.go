@@ -0,0 +1,106 @@
+package hosting
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	avif := []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00")
+
+	tests := []struct {
+		path string
+		head []byte
+		want string
+	}{
+		{"index.html", nil, "text/html; charset=utf-8"},
+		{"app.JS", nil, "text/javascript; charset=utf-8"},
+		{"app.wasm", nil, "application/wasm"},
+		{"photo.avif", nil, "image/avif"},
+		{"fonts/inter.woff2", nil, "font/woff2"},
+		{"site.webmanifest", nil, "application/manifest+json; charset=utf-8"},
+		{"logo.svg", nil, "image/svg+xml; charset=utf-8"},
+		// Extensionless and unknown extensions are sniffed
+		{"LICENSE", []byte("MIT License\n\nCopyright"), "text/plain; charset=utf-8"},
+		{"docs/page", []byte("<!DOCTYPE html><html>"), "text/html; charset=utf-8"},
+		{"thumb", png, "image/png"},
+		{"photo", avif, "image/avif"},
+		{"module", []byte("\x00asm\x01\x00\x00\x00"), "application/wasm"},
+		{"data.bin123", []byte{0x00, 0x01, 0x02, 0xff}, "application/octet-stream"},
+		{"empty", nil, "application/octet-stream"},
+		// The extension wins over the content
+		{"notes.txt", png, "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		if got := DetectMimeType(tt.path, tt.head); got != tt.want {
+			t.Errorf("DetectMimeType(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAppManifest_ContentType(t *testing.T) {
+	m := &AppManifest{Types: map[string]string{
+		"*.dat":           "text/csv",
+		"downloads/*":     "application/octet-stream",
+		"downloads/*.txt": "text/plain; charset=iso-8859-1",
+	}}
+
+	tests := []struct {
+		path, stored, want string
+	}{
+		{"export.dat", "application/octet-stream", "text/csv; charset=utf-8"},
+		{"downloads/report.pdf", "application/pdf", "application/octet-stream"},
+		{"downloads/readme.txt", "text/plain", "text/plain; charset=iso-8859-1"},
+		{"style.css", "text/css", "text/css; charset=utf-8"},
+		// Files stored before their extension was known get its type now
+		{"app.wasm", "application/octet-stream", "application/wasm"},
+		{"thumb", "image/png", "image/png"},
+	}
+	for _, tt := range tests {
+		if got := m.ContentType(tt.path, tt.stored); got != tt.want {
+			t.Errorf("ContentType(%s, %s) = %q, want %q", tt.path, tt.stored, got, tt.want)
+		}
+	}
+}
+
+func TestServeVFS_ContentType(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range map[string]string{
+		"index.html":    "<h1>Hi</h1>",
+		"CNAME":         "example.com",
+		"feed":          `<?xml version="1.0"?><rss></rss>`,
+		"manifest.json": `{"types": {"feed": "application/rss+xml"}}`,
+	} {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if _, err := DeploySite(zr, "site"); err != nil {
+		t.Fatalf("DeploySite failed: %v", err)
+	}
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		ServeVFS(w, httptest.NewRequest("GET", path, nil), "site")
+		return w.Header().Get("Content-Type")
+	}
+	if got := get("/CNAME"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Expected a sniffed text type for CNAME, got %q", got)
+	}
+	if got := get("/feed"); got != "application/rss+xml; charset=utf-8" {
+		t.Errorf("Expected the manifest's type for feed, got %q", got)
+	}
+	if got := get("/"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Expected HTML for the index, got %q", got)
+	}
+}
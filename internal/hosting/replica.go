@@ -0,0 +1,117 @@
+package hosting
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// A standby keeps a replica of its primary's apps: every app's record and
+// aliases, files and storage, copied from a snapshot of the primary's
+// database. Users, API keys and settings stay the standby's own, and apps
+// the primary deleted are left in place.
+
+// ReplicaResult counts what applying a replica copied
+type ReplicaResult struct {
+	Apps  int
+	Files int64
+	KV    int64
+	Docs  int64
+	Blobs int64
+}
+
+// ApplyReplica makes every app in a snapshot of another server's database
+// match it here, in one transaction
+func ApplyReplica(db *sql.DB, snapshot Backup) (*ReplicaResult, error) {
+	ctx := context.Background()
+	result := &ReplicaResult{}
+	var sites []string
+	err := storage.QueueWrite(ctx, func() error {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS backup`, "file:"+snapshot.Path+"?mode=ro"); err != nil {
+			return fmt.Errorf("open replica: %w", err)
+		}
+		defer conn.ExecContext(ctx, `DETACH DATABASE backup`)
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		// System sites are seeded by each server itself
+		var appIDs []string
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM backup.apps WHERE COALESCE(source, '') != 'system' ORDER BY id`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			appIDs = append(appIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if err := upsertRows(ctx, tx, "apps", "id", `COALESCE(source, '') != 'system'`); err != nil {
+			return err
+		}
+		if err := upsertRows(ctx, tx, "aliases", "subdomain", `1`); err != nil {
+			return err
+		}
+		for _, appID := range appIDs {
+			var r RestoreResult
+			if err := restoreApp(ctx, tx, appID, &r); err != nil {
+				return fmt.Errorf("app %s: %w", appID, err)
+			}
+			result.Apps++
+			result.Files += r.Files
+			result.KV += r.KV
+			result.Docs += r.Docs
+			result.Blobs += r.Blobs
+			sites = append(sites, r.Sites...)
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forgetSites(db, sites)
+	return result, nil
+}
+
+// upsertRows copies a table's rows matching where from the backup, updating
+// rows with the same key in place so rows that reference them are kept
+func upsertRows(ctx context.Context, tx *sql.Tx, table, key, where string) error {
+	columns, err := sharedColumns(ctx, tx, table)
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+	var set []string
+	for _, c := range columns {
+		if c != `"`+key+`"` {
+			set = append(set, c+" = excluded."+c)
+		}
+	}
+	list := strings.Join(columns, ", ")
+	if _, err := tx.ExecContext(ctx, `INSERT INTO main.`+table+` (`+list+`)
+		SELECT `+list+` FROM backup.`+table+` WHERE `+where+`
+		ON CONFLICT(`+key+`) DO UPDATE SET `+strings.Join(set, ", ")); err != nil {
+		return fmt.Errorf("copy %s: %w", table, err)
+	}
+	return nil
+}
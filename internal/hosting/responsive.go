@@ -0,0 +1,200 @@
+package hosting
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// defaultResponsiveWidths are pre-generated for manifests that turn
+// responsive images on without listing widths
+var defaultResponsiveWidths = []int{480, 960, 1600}
+
+var (
+	imgTagPattern    = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	imgSrcPattern    = regexp.MustCompile(`(?is)\ssrc\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	imgSrcsetPattern = regexp.MustCompile(`(?is)\ssrcset\s*=`)
+)
+
+// responsiveWidths returns the widths worth a variant of an image
+// naturalWidth wide: as the transform URLs would serve them, clamped and
+// snapped to the cache step, smallest first
+func (a *AppImages) responsiveWidths(naturalWidth int) []int {
+	widths := a.Widths
+	if len(widths) == 0 {
+		widths = defaultResponsiveWidths
+	}
+	step := system.GetLimits().Media.WidthStep
+	seen := map[int]bool{}
+	var out []int
+	for _, w := range widths {
+		if w <= 0 {
+			continue
+		}
+		w = media.SnapToStep(a.clamp(media.TransformOpts{Width: w}), step).Width
+		if w < naturalWidth && !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// prepareResponsiveImages pre-generates the variants of a freshly deployed
+// site's images, when its manifest asks for them, and adds a srcset of them
+// to the <img> tags of its HTML pages, so they cost nothing at request time
+func prepareResponsiveImages(sqlFS *SQLFileSystem, siteID string) {
+	images := sqlFS.appManifest(siteID, sqlFS.ReadFile).Images
+	if images == nil || !images.Responsive || !images.Enabled() {
+		return
+	}
+	files, err := sqlFS.ListFiles(siteID)
+	if err != nil {
+		return
+	}
+
+	// Path -> srcset, for the pages below
+	srcsets := map[string][]string{}
+	var pages []string
+	for _, entry := range files {
+		lower := strings.ToLower(entry.Path)
+		if strings.HasSuffix(lower, ".html") || strings.HasSuffix(lower, ".htm") {
+			pages = append(pages, entry.Path)
+			continue
+		}
+		srcset, err := pregenerateImage(sqlFS, siteID, entry.Path, images)
+		if err != nil {
+			log.Printf("Responsive images: %s/%s: %v", siteID, entry.Path, err)
+			continue
+		}
+		if srcset != nil {
+			srcsets[entry.Path] = srcset
+		}
+	}
+	if len(srcsets) == 0 {
+		return
+	}
+
+	for _, page := range pages {
+		// A precompressed copy would go stale
+		if exists, _ := sqlFS.Exists(siteID, page+".br"); exists {
+			continue
+		}
+		if exists, _ := sqlFS.Exists(siteID, page+".gz"); exists {
+			continue
+		}
+		file, err := sqlFS.ReadFile(siteID, page)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(file.Content)
+		file.Content.Close()
+		if err != nil {
+			continue
+		}
+		if out := addSrcsets(data, path.Dir(page), srcsets); !bytes.Equal(out, data) {
+			sqlFS.WriteFile(siteID, page, bytes.NewReader(out), int64(len(out)), file.MimeType)
+		}
+	}
+}
+
+// pregenerateImage caches the responsive variants of one deployed file and
+// returns their srcset entries, relative to the image's own URL ("?w=480
+// 480w"), or nil for files that aren't images or too small for any
+func pregenerateImage(sqlFS *SQLFileSystem, siteID, filePath string, images *AppImages) ([]string, error) {
+	file, err := sqlFS.ReadFile(siteID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Content.Close()
+	// GIFs would lose their animation
+	if !media.IsImageContentType(file.MimeType) || strings.HasPrefix(file.MimeType, "image/gif") {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(file.Content)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	widths := images.responsiveWidths(config.Width)
+	if len(widths) == 0 {
+		return nil, nil
+	}
+
+	// Keyed like serveTransformedImage's variants, which then find them
+	cache := media.NewStaticMediaCache(sqlFS.db)
+	var srcset []string
+	for _, w := range widths {
+		opts := media.TransformOpts{Width: w}
+		if err := media.Pregenerate(context.Background(), cache, siteID, filePath+"@"+file.Hash, data, opts); err != nil {
+			return nil, err
+		}
+		srcset = append(srcset, fmt.Sprintf("?w=%d %dw", w, w))
+	}
+	return append(srcset, fmt.Sprintf(" %dw", config.Width)), nil
+}
+
+// addSrcsets gives the <img> tags of an HTML page in dir a srcset of the
+// pre-generated variants of their image. Tags with a srcset of their own,
+// external images and URLs that already carry a query are left alone.
+func addSrcsets(html []byte, dir string, srcsets map[string][]string) []byte {
+	return imgTagPattern.ReplaceAllFunc(html, func(tag []byte) []byte {
+		if imgSrcsetPattern.Match(tag) {
+			return tag
+		}
+		m := imgSrcPattern.FindSubmatch(tag)
+		if m == nil {
+			return tag
+		}
+		src := string(m[1])
+		if src == "" {
+			src = string(m[2])
+		}
+		if src == "" || strings.ContainsAny(src, "?#\" ,") || strings.Contains(src, ":") || strings.HasPrefix(src, "//") {
+			return tag
+		}
+
+		target := strings.TrimPrefix(path.Clean("/"+src), "/")
+		if !strings.HasPrefix(src, "/") {
+			target = strings.TrimPrefix(path.Clean("/"+path.Join(dir, src)), "/")
+		}
+		entries, ok := srcsets[target]
+		if !ok {
+			return tag
+		}
+
+		// Entries are relative to the image URL: "?w=480 480w", " 1600w"
+		var srcset []string
+		for _, entry := range entries {
+			srcset = append(srcset, src+entry)
+		}
+		attr := fmt.Sprintf(` srcset="%s"`, strings.Join(srcset, ", "))
+
+		end := len(tag) - 1
+		if end > 0 && tag[end-1] == '/' {
+			end--
+		}
+		out := make([]byte, 0, len(tag)+len(attr))
+		out = append(out, bytes.TrimRight(tag[:end], " \t\r\n")...)
+		out = append(out, attr...)
+		if end < len(tag)-1 {
+			out = append(out, " /"...)
+		}
+		return append(out, '>')
+	})
+}
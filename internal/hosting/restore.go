@@ -0,0 +1,320 @@
+package hosting
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// Backups are copies of the whole database in the backups directory next to
+// it, named after the server's local time they were taken:
+// backups/backup_20240501_120000.db, as database.Backup writes them. One
+// app's files and storage can be put back as a backup had them without
+// touching any other app, for when a deploy or a handler went wrong.
+
+// backupTimeFormat is the timestamp in a backup's file name
+const backupTimeFormat = "20060102_150405"
+
+// ErrNoBackup is returned when no backup was taken at or before a time
+var ErrNoBackup = errors.New("no backup at or before that time")
+
+// Backup is a database backup an app can be restored from
+type Backup struct {
+	Name      string
+	Path      string
+	TakenAt   time.Time
+	SizeBytes int64
+}
+
+// RestoreResult counts the rows a restore put back
+type RestoreResult struct {
+	Backup Backup
+	Sites  []string // Site IDs the app's storage was restored under
+	Files  int64
+	KV     int64
+	Docs   int64
+	Blobs  int64
+}
+
+// restoredStorage are the tables keyed by the site IDs an app's handlers
+// run under, with their key column. app_doc_search goes before app_docs so
+// restored documents are indexed as they're inserted.
+var restoredStorage = []struct{ table, key string }{
+	{"app_kv", "app_id"},
+	{"app_doc_search", "app_id"},
+	{"app_docs", "app_id"},
+	{"app_blobs", "app_id"},
+	{"kv_store", "site_id"},
+}
+
+// BackupDir is where backups of the database at dbPath are kept
+func BackupDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// ListBackups returns the backups of the database at dbPath, oldest first.
+// Files in the backups directory not named like a backup are skipped.
+func ListBackups(dbPath string) ([]Backup, error) {
+	paths, err := filepath.Glob(filepath.Join(BackupDir(dbPath), "backup_*.db"))
+	if err != nil {
+		return nil, err
+	}
+	backups := []Backup{}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "backup_"), ".db")
+		takenAt, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Name: name, Path: path, TakenAt: takenAt, SizeBytes: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].TakenAt.Before(backups[j].TakenAt) })
+	return backups, nil
+}
+
+// NearestBackup returns the newest backup taken at or before at
+func NearestBackup(backups []Backup, at time.Time) (Backup, error) {
+	for i := len(backups) - 1; i >= 0; i-- {
+		if !backups[i].TakenAt.After(at) {
+			return backups[i], nil
+		}
+	}
+	return Backup{}, ErrNoBackup
+}
+
+// ParseBackupTime reads a restore point: RFC 3339, or a date with an
+// optional time (2024-05-01T12:00, 2024-05-01 12:00:30, 2024-05-01), which
+// is the server's local time like the backups' names
+func ParseBackupTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			if layout == "2006-01-02" {
+				// A bare date means the end of that day
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use 2024-05-01T12:00 or RFC 3339", s)
+}
+
+// RestoreApp replaces an app's files and storage with what a backup had
+// for it, in one transaction. Storage is restored under every site ID the
+// app has now or had then: its ID, title and aliases. The app's record,
+// members and settings are left as they are.
+func RestoreApp(db *sql.DB, backup Backup, appID string) (*RestoreResult, error) {
+	ctx := context.Background()
+	if _, err := os.Stat(backup.Path); err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{Backup: backup}
+	err := storage.QueueWrite(ctx, func() error {
+		// ATTACH is per connection, so the restore holds one throughout
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS backup`, "file:"+backup.Path+"?mode=ro"); err != nil {
+			return fmt.Errorf("open backup %s: %w", backup.Name, err)
+		}
+		defer conn.ExecContext(ctx, `DETACH DATABASE backup`)
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if err := restoreApp(ctx, tx, appID, result); err != nil {
+			return fmt.Errorf("restore from %s: %w", backup.Name, err)
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forgetSites(db, result.Sites)
+	return result, nil
+}
+
+// forgetSites drops what was served or compiled from the sites' previous
+// files and blobs
+func forgetSites(db *sql.DB, sites []string) {
+	sqlFS, _ := fs.(*SQLFileSystem)
+	for _, site := range sites {
+		if sqlFS != nil {
+			sqlFS.forgetSite(site)
+		}
+		LoadAPIRoutes(db, site)
+		modules.Forget(site)
+		media.ForgetApp(site)
+	}
+}
+
+// restoreApp copies an app's rows from the attached backup, replacing its
+// current ones
+func restoreApp(ctx context.Context, tx *sql.Tx, appID string, result *RestoreResult) error {
+	var found int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM backup.apps WHERE id = ?`, appID).Scan(&found); err != nil {
+		return err
+	}
+	if found == 0 {
+		return fmt.Errorf("app %s isn't in the backup", appID)
+	}
+
+	sites, err := restoredSites(ctx, tx, appID)
+	if err != nil {
+		return err
+	}
+	result.Sites = sites
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(sites)), ", ")
+	siteArgs := make([]interface{}, len(sites))
+	for i, s := range sites {
+		siteArgs[i] = s
+	}
+
+	// Files are the app's by app_id, or by site for files written before
+	// they carried one
+	filesWhere := `app_id = ? OR (app_id IS NULL AND site_id IN (` + placeholders + `))`
+	filesArgs := append([]interface{}{appID}, siteArgs...)
+	if result.Files, err = replaceRows(ctx, tx, "files", filesWhere, filesArgs); err != nil {
+		return err
+	}
+
+	for _, t := range restoredStorage {
+		n, err := replaceRows(ctx, tx, t.table, t.key+` IN (`+placeholders+`)`, siteArgs)
+		if err != nil {
+			return err
+		}
+		switch t.table {
+		case "app_kv", "kv_store":
+			result.KV += n
+		case "app_docs":
+			result.Docs = n
+		case "app_blobs":
+			result.Blobs = n
+		}
+	}
+	return nil
+}
+
+// restoredSites returns the site IDs an app's storage is keyed by, now and
+// in the backup
+func restoredSites(ctx context.Context, tx *sql.Tx, appID string) ([]string, error) {
+	seen := map[string]bool{appID: true}
+	sites := []string{appID}
+	for _, schema := range []string{"main", "backup"} {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT title FROM `+schema+`.apps WHERE id = ? AND COALESCE(title, '') != ''
+			UNION
+			SELECT subdomain FROM `+schema+`.aliases
+			WHERE json_extract(targets, '$.app_id') = ? AND type IN ('proxy', 'app')
+		`, appID, appID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var site string
+			if err := rows.Scan(&site); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if !seen[site] {
+				seen[site] = true
+				sites = append(sites, site)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sites, nil
+}
+
+// replaceRows deletes a table's rows matching where and copies in the
+// backup's. Only columns both have are copied, so a backup from an older
+// schema restores with the newer columns' defaults; a table the backup
+// doesn't have yet restores as empty. It returns the rows copied.
+func replaceRows(ctx context.Context, tx *sql.Tx, table, where string, args []interface{}) (int64, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM main.`+table+` WHERE `+where, args...); err != nil {
+		return 0, fmt.Errorf("clear %s: %w", table, err)
+	}
+
+	columns, err := sharedColumns(ctx, tx, table)
+	if err != nil || len(columns) == 0 {
+		return 0, err
+	}
+
+	list := strings.Join(columns, ", ")
+	res, err := tx.ExecContext(ctx, `INSERT INTO main.`+table+` (`+list+`)
+		SELECT `+list+` FROM backup.`+table+` WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("copy %s: %w", table, err)
+	}
+	return res.RowsAffected()
+}
+
+// sharedColumns returns the quoted columns a table has both now and in
+// the backup
+func sharedColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	current, err := tableColumns(ctx, tx, "main", table)
+	if err != nil {
+		return nil, err
+	}
+	backed, err := tableColumns(ctx, tx, "backup", table)
+	if err != nil {
+		return nil, err
+	}
+	var columns []string
+	for _, c := range current {
+		for _, b := range backed {
+			if c == b {
+				columns = append(columns, `"`+c+`"`)
+				break
+			}
+		}
+	}
+	return columns, nil
+}
+
+// tableColumns returns a table's column names in a schema, or none if the
+// table doesn't exist there
+func tableColumns(ctx context.Context, tx *sql.Tx, schema, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT name FROM pragma_table_info(?, ?)`, table, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
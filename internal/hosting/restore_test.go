@@ -0,0 +1,111 @@
+package hosting
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestRestoreApp(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "data.db")
+	db := dbtest.OpenFile(t, dbPath)
+
+	exec := func(query string, args ...interface{}) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	count := func(query string, args ...interface{}) int {
+		t.Helper()
+		var n int
+		if err := db.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		return n
+	}
+
+	exec(`INSERT INTO apps (id, title) VALUES ('app_blog', 'blog'), ('app_shop', 'shop')`)
+	exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('news', 'app', '{"app_id":"app_blog"}')`)
+	for _, site := range []string{"blog", "shop"} {
+		exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+			VALUES (?, ?, 'index.html', 'v1', 2, 'text/html', 'h1')`, site, "app_"+site)
+		exec(`INSERT INTO app_kv (app_id, key, value) VALUES (?, 'count', '1')`, site)
+	}
+	exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('news', 'visits', '7')`)
+	exec(`INSERT INTO app_doc_search (app_id, collection, fields) VALUES ('blog', 'posts', '["title"]')`)
+	exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('blog', 'posts', 'p1', '{"title":"hello world"}')`)
+	exec(`INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash) VALUES ('blog', 'a.txt', 'x', 'text/plain', 1, 'h')`)
+
+	backups := BackupDir(dbPath)
+	if err := os.MkdirAll(backups, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exec(`VACUUM INTO ?`, filepath.Join(backups, "backup_20240501_120000.db"))
+	os.WriteFile(filepath.Join(backups, "backup_notes.db"), nil, 0644)
+
+	// The bad deploy, on both apps
+	exec(`UPDATE files SET content = 'v2', hash = 'h2'`)
+	exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, mime_type, hash)
+		VALUES ('blog', 'app_blog', 'broken.js', 'x', 1, 'text/javascript', 'h3')`)
+	exec(`UPDATE app_kv SET value = '2'`)
+	exec(`DELETE FROM app_docs`)
+	exec(`DELETE FROM app_blobs`)
+
+	list, err := ListBackups(dbPath)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "backup_20240501_120000.db" {
+		t.Fatalf("Expected the one backup, got %+v", list)
+	}
+	if _, err := NearestBackup(list, time.Date(2024, 5, 1, 11, 0, 0, 0, time.Local)); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Expected no backup before the first, got %v", err)
+	}
+	at, err := ParseBackupTime("2024-05-01T12:30")
+	if err != nil {
+		t.Fatalf("ParseBackupTime failed: %v", err)
+	}
+	backup, err := NearestBackup(list, at)
+	if err != nil {
+		t.Fatalf("NearestBackup failed: %v", err)
+	}
+
+	result, err := RestoreApp(db, backup, "app_blog")
+	if err != nil {
+		t.Fatalf("RestoreApp failed: %v", err)
+	}
+	if result.Files != 1 || result.KV != 2 || result.Docs != 1 || result.Blobs != 1 {
+		t.Errorf("Unexpected restore counts: %+v", result)
+	}
+
+	if n := count(`SELECT COUNT(*) FROM files WHERE site_id = 'blog' AND content = 'v1'`); n != 1 {
+		t.Error("Expected the blog's files restored")
+	}
+	if n := count(`SELECT COUNT(*) FROM files WHERE path = 'broken.js'`); n != 0 {
+		t.Error("Expected the file added since the backup removed")
+	}
+	if n := count(`SELECT COUNT(*) FROM app_kv WHERE app_id IN ('blog', 'news') AND value = '2'`); n != 0 {
+		t.Error("Expected the blog's storage restored under its title and alias")
+	}
+	if n := count(`SELECT COUNT(*) FROM app_docs_fts WHERE app_docs_fts MATCH 'hello'`); n != 1 {
+		t.Error("Expected the restored documents searchable")
+	}
+
+	// The other app keeps its changes
+	if n := count(`SELECT COUNT(*) FROM files WHERE site_id = 'shop' AND content = 'v2'`); n != 1 {
+		t.Error("Expected the shop's files untouched")
+	}
+	if n := count(`SELECT COUNT(*) FROM app_kv WHERE app_id = 'shop' AND value = '2'`); n != 1 {
+		t.Error("Expected the shop's storage untouched")
+	}
+
+	if _, err := RestoreApp(db, backup, "app_new"); err == nil {
+		t.Error("Expected an app the backup doesn't have refused")
+	}
+}
@@ -0,0 +1,119 @@
+package hosting
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RouteRules configures how an app's URLs map to files, from the "routes"
+// section of its manifest.json:
+//
+//	{ "routes": {
+//	    "redirects": [{ "from": "/blog/*", "to": "/posts/:splat", "status": 301 }],
+//	    "rewrites": [{ "from": "/dashboard/*", "to": "/dashboard/index.html" }],
+//	    "not_found": "404.html",
+//	    "trailing_slash": "remove"
+//	} }
+//
+// Redirects apply before any file lookup. Rewrites only apply when no file
+// matches, and serve the target with a 200. "from" is an exact path or ends
+// in "/*" to match everything below it; ":splat" in "to" is replaced with
+// the part matched by "*".
+type RouteRules struct {
+	Redirects []RouteRule `json:"redirects"`
+	Rewrites  []RouteRule `json:"rewrites"`
+	// NotFound is the file served with a 404 when nothing matches
+	NotFound string `json:"not_found"`
+	// TrailingSlash is "remove" (default: /about/ -> /about), "add"
+	// (/about -> /about/ for paths without an extension) or "ignore"
+	TrailingSlash string `json:"trailing_slash"`
+}
+
+// RouteRule maps a request path to another path or URL
+type RouteRule struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"` // Redirects only, default 301
+}
+
+// Trailing slash policies
+const (
+	TrailingSlashRemove = "remove"
+	TrailingSlashAdd    = "add"
+	TrailingSlashIgnore = "ignore"
+)
+
+// Match returns the rule's target for path (with a leading slash), with
+// ":splat" expanded
+func (r RouteRule) Match(path string) (string, bool) {
+	from := "/" + strings.TrimPrefix(r.From, "/")
+	if prefix, ok := strings.CutSuffix(from, "*"); ok {
+		var splat string
+		switch {
+		case strings.HasPrefix(path, prefix):
+			splat = path[len(prefix):]
+		case path+"/" == prefix:
+			// "/blog/*" also covers "/blog"
+		default:
+			return "", false
+		}
+		return strings.ReplaceAll(r.To, ":splat", splat), true
+	}
+	if path != from {
+		return "", false
+	}
+	return strings.ReplaceAll(r.To, ":splat", ""), true
+}
+
+// RedirectStatus returns the rule's status, or 301 if unset or invalid
+func (r RouteRule) RedirectStatus() int {
+	switch r.Status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return r.Status
+	}
+	return http.StatusMovedPermanently
+}
+
+// Redirect returns the target and status of the first redirect matching path
+func (rr RouteRules) Redirect(path string) (string, int, bool) {
+	for _, rule := range rr.Redirects {
+		if to, ok := rule.Match(path); ok && to != "" {
+			return to, rule.RedirectStatus(), true
+		}
+	}
+	return "", 0, false
+}
+
+// Rewrite returns the file path of the first rewrite matching path
+func (rr RouteRules) Rewrite(path string) (string, bool) {
+	for _, rule := range rr.Rewrites {
+		if to, ok := rule.Match(path); ok && to != "" {
+			return to, true
+		}
+	}
+	return "", false
+}
+
+// trailingSlashTarget returns where the trailing slash policy redirects
+// path, or "" to serve it as is
+func (rr RouteRules) trailingSlashTarget(path string) string {
+	if path == "/" {
+		return ""
+	}
+	switch rr.TrailingSlash {
+	case TrailingSlashIgnore:
+		return ""
+	case TrailingSlashAdd:
+		last := path[strings.LastIndex(path, "/")+1:]
+		if !strings.HasSuffix(path, "/") && !strings.Contains(last, ".") {
+			return path + "/"
+		}
+		return ""
+	default:
+		if strings.HasSuffix(path, "/") {
+			return strings.TrimSuffix(path, "/")
+		}
+		return ""
+	}
+}
@@ -0,0 +1,99 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouteRuleMatch(t *testing.T) {
+	tests := []struct {
+		rule RouteRule
+		path string
+		want string
+		ok   bool
+	}{
+		{RouteRule{From: "/old", To: "/new"}, "/old", "/new", true},
+		{RouteRule{From: "/old", To: "/new"}, "/old/page", "", false},
+		{RouteRule{From: "/blog/*", To: "/posts/:splat"}, "/blog/2024/hello", "/posts/2024/hello", true},
+		{RouteRule{From: "/blog/*", To: "/posts/:splat"}, "/blog", "/posts/", true},
+		{RouteRule{From: "/blog/*", To: "/posts/:splat"}, "/blogroll", "", false},
+		{RouteRule{From: "app/*", To: "/app/index.html"}, "/app/settings/profile", "/app/index.html", true},
+		{RouteRule{From: "/*", To: "/index.html"}, "/anything", "/index.html", true},
+	}
+	for _, tt := range tests {
+		got, ok := tt.rule.Match(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%+v.Match(%q) = %q, %v; want %q, %v", tt.rule, tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTrailingSlashPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		path   string
+		want   string
+	}{
+		{"", "/about/", "/about"},
+		{"", "/about", ""},
+		{"remove", "/", ""},
+		{"add", "/about", "/about/"},
+		{"add", "/about/", ""},
+		{"add", "/style.css", ""},
+		{"ignore", "/about/", ""},
+	}
+	for _, tt := range tests {
+		rr := RouteRules{TrailingSlash: tt.policy}
+		if got := rr.trailingSlashTarget(tt.path); got != tt.want {
+			t.Errorf("policy %q: trailingSlashTarget(%q) = %q, want %q", tt.policy, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestManifestRoutes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	write := func(path, content string) {
+		fs.WriteFile("site", path, strings.NewReader(content), int64(len(content)), "text/html")
+	}
+	write("index.html", "<html>home</html>")
+	write("dashboard/index.html", "<html>dashboard</html>")
+	write("404.html", "<html>missing</html>")
+	write("manifest.json", `{"name":"site","routes":{
+		"redirects":[{"from":"/blog/*","to":"/posts/:splat","status":302}],
+		"rewrites":[{"from":"/dashboard/*","to":"/dashboard/index.html"}],
+		"not_found":"404.html"
+	}}`)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ServeVFS(w, httptest.NewRequest("GET", path, nil), "site")
+		return w
+	}
+
+	w := serve("/blog/hello?ref=feed")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/posts/hello?ref=feed" {
+		t.Errorf("Expected 302 to /posts/hello?ref=feed, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+
+	// Nested client-side routes serve the app shell
+	w = serve("/dashboard/settings/profile")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "dashboard") {
+		t.Errorf("Expected rewrite to dashboard shell, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = serve("/nope")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "missing") {
+		t.Errorf("Expected custom 404 page, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Existing files are never shadowed by rewrites
+	w = serve("/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "home") {
+		t.Errorf("Expected index, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,180 @@
+package hosting
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Secret scan modes (server.secret_scan)
+const (
+	SecretScanOff   = "off"   // Don't scan
+	SecretScanWarn  = "warn"  // Deploy, and report what was found
+	SecretScanBlock = "block" // Refuse the deploy
+)
+
+// maxSecretScanBytes is how much of each file is scanned
+const maxSecretScanBytes = 1 << 20
+
+var secretScanMode atomic.Value // string
+
+func init() {
+	secretScanMode.Store(SecretScanWarn)
+}
+
+// ValidSecretScanMode reports whether mode is a known secret scan mode
+func ValidSecretScanMode(mode string) bool {
+	return mode == SecretScanOff || mode == SecretScanWarn || mode == SecretScanBlock
+}
+
+// SetSecretScanMode sets how deploys containing likely secrets are handled
+func SetSecretScanMode(mode string) {
+	if ValidSecretScanMode(mode) {
+		secretScanMode.Store(mode)
+	}
+}
+
+// SecretScanMode returns how deploys containing likely secrets are handled
+func SecretScanMode() string {
+	return secretScanMode.Load().(string)
+}
+
+// SecretFinding is a likely credential in a deployed file
+type SecretFinding struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Line int    `json:"line,omitempty"` // 0 when the file name alone gave it away
+}
+
+func (f SecretFinding) String() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d (%s)", f.Path, f.Line, f.Kind)
+	}
+	return fmt.Sprintf("%s (%s)", f.Path, f.Kind)
+}
+
+// SecretsError refuses a deploy that contains likely secrets
+type SecretsError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsError) Error() string {
+	found := make([]string, len(e.Findings))
+	for i, f := range e.Findings {
+		found[i] = f.String()
+	}
+	return "deploy blocked, likely secrets found: " + strings.Join(found, ", ") +
+		". Remove them, or move them to private/ or env vars"
+}
+
+// secretFileNames are files that hold credentials by convention
+var secretFileNames = map[string]string{
+	".env":                 "dotenv file",
+	".npmrc":               "npm credentials",
+	".pypirc":              "PyPI credentials",
+	".netrc":               "netrc credentials",
+	".htpasswd":            "htpasswd file",
+	"id_rsa":               "SSH private key",
+	"id_dsa":               "SSH private key",
+	"id_ecdsa":             "SSH private key",
+	"id_ed25519":           "SSH private key",
+	"credentials.json":     "credentials file",
+	"service-account.json": "service account key",
+}
+
+var secretPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN (RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{"Stripe secret key", regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{24,}\b`)},
+	{"Google service account key", regexp.MustCompile(`"type":\s*"service_account"`)},
+}
+
+// secretScanned reports whether a deployed file is publicly served, and so
+// worth scanning. private/ needs a login and api/ runs on the server.
+func secretScanned(filePath string) bool {
+	return !strings.HasPrefix(filePath, "private/") && !strings.HasPrefix(filePath, "api/")
+}
+
+// ScanSecrets looks for likely credentials in a file
+func ScanSecrets(filePath string, content []byte) []SecretFinding {
+	var findings []SecretFinding
+
+	name := path.Base(filePath)
+	if kind, ok := secretFileNames[name]; ok {
+		findings = append(findings, SecretFinding{Path: filePath, Kind: kind})
+	} else if strings.HasPrefix(name, ".env.") && name != ".env.example" && name != ".env.sample" {
+		findings = append(findings, SecretFinding{Path: filePath, Kind: "dotenv file"})
+	}
+
+	if len(content) > maxSecretScanBytes {
+		content = content[:maxSecretScanBytes]
+	}
+	// Binary files (images, fonts, archives) aren't scanned
+	if bytes.IndexByte(content[:min(len(content), 8000)], 0) != -1 {
+		return findings
+	}
+
+	for _, p := range secretPatterns {
+		if loc := p.re.FindIndex(content); loc != nil {
+			line := bytes.Count(content[:loc[0]], []byte("\n")) + 1
+			findings = append(findings, SecretFinding{Path: filePath, Kind: p.kind, Line: line})
+		}
+	}
+	return findings
+}
+
+// scanZipSecrets scans the publicly served files of a ZIP deploy
+func scanZipSecrets(zipReader *zip.Reader) ([]SecretFinding, error) {
+	var findings []SecretFinding
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		filePath := strings.TrimPrefix(path.Clean(strings.ReplaceAll(file.Name, "\\", "/")), "/")
+		if !secretScanned(filePath) {
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file %s: %w", file.Name, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(src, maxSecretScanBytes))
+		src.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file.Name, err)
+		}
+		findings = append(findings, ScanSecrets(filePath, content)...)
+	}
+	return findings, nil
+}
+
+// scanFilesSecrets scans the publicly served files of a server-side deploy
+func scanFilesSecrets(files []DeployFile) []SecretFinding {
+	var findings []SecretFinding
+	for _, file := range files {
+		if secretScanned(file.Path) {
+			findings = append(findings, ScanSecrets(file.Path, file.Content)...)
+		}
+	}
+	return findings
+}
+
+// checkSecrets applies the secret scan mode to what a scan found
+func checkSecrets(findings []SecretFinding) error {
+	if len(findings) > 0 && SecretScanMode() == SecretScanBlock {
+		return &SecretsError{Findings: findings}
+	}
+	return nil
+}
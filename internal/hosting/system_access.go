@@ -0,0 +1,81 @@
+package hosting
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// Apps with system access can read server-wide information from their
+// handlers with fazt.sys, so a dashboard app can be built on fazt itself.
+// It shows what every app holds, so only the server's admins can grant it.
+
+// SystemApp is an app as fazt.sys.apps.list shows it
+type SystemApp struct {
+	ID           string
+	Title        string
+	Visibility   string
+	Aliases      []string
+	FileCount    int
+	SizeBytes    int64
+	Protected    bool
+	SystemAccess bool
+	CreatedAt    string
+	UpdatedAt    string
+}
+
+// SetSystemAccess turns an app's system access on or off
+func SetSystemAccess(db *sql.DB, appID string, on bool) error {
+	_, err := db.Exec(`UPDATE apps SET system_access = ? WHERE id = ?`, on, appID)
+	return err
+}
+
+// HasSystemAccess reports whether the app a site's handlers run as may use
+// fazt.sys. The site is the app's ID, title or one of its aliases.
+func HasSystemAccess(db *sql.DB, siteID string) (bool, error) {
+	var on bool
+	err := db.QueryRow(`
+		SELECT system_access FROM apps
+		WHERE id = ? OR title = ?
+			OR id = (SELECT json_extract(targets, '$.app_id') FROM aliases WHERE subdomain = ? AND type IN ('proxy', 'app'))
+		ORDER BY system_access DESC
+		LIMIT 1
+	`, siteID, siteID, siteID).Scan(&on)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return on, err
+}
+
+// ListSystemApps returns every app with its aliases and file totals,
+// oldest first
+func ListSystemApps(db *sql.DB) ([]SystemApp, error) {
+	rows, err := db.Query(`
+		SELECT a.id, COALESCE(a.title, ''), COALESCE(a.visibility, 'unlisted'),
+			(SELECT json_group_array(subdomain) FROM aliases
+				WHERE json_extract(targets, '$.app_id') = a.id AND type IN ('proxy', 'app')),
+			(SELECT COUNT(*) FROM files WHERE app_id = a.id),
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM files WHERE app_id = a.id),
+			a.protected, a.system_access,
+			COALESCE(a.created_at, ''), COALESCE(a.updated_at, '')
+		FROM apps a
+		ORDER BY a.created_at, a.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := []SystemApp{}
+	for rows.Next() {
+		var a SystemApp
+		var aliases string
+		if err := rows.Scan(&a.ID, &a.Title, &a.Visibility, &aliases, &a.FileCount, &a.SizeBytes,
+			&a.Protected, &a.SystemAccess, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		a.Aliases = []string{}
+		json.Unmarshal([]byte(aliases), &a.Aliases)
+		apps = append(apps, a)
+	}
+	return apps, rows.Err()
+}
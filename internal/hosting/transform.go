@@ -0,0 +1,216 @@
+package hosting
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Transform types
+const (
+	TransformHeader    = "header"    // Set (or remove) a response header
+	TransformReplace   = "replace"   // Literal find/replace in HTML bodies
+	TransformInject    = "inject"    // Insert an HTML snippet into <head> or <body>
+	TransformAnalytics = "analytics" // Insert the fazt tracking snippet
+)
+
+// MaxTransforms limits the number of transform rules per alias
+const MaxTransforms = 32
+
+// maxTransformBody is the largest HTML body that will be buffered for rewriting.
+// Larger responses are passed through untouched.
+const maxTransformBody = 5 * 1024 * 1024
+
+// ResponseTransform is a per-alias rewrite applied to responses at the hosting layer.
+// It lets operators add headers, banners or tracking to apps they don't want to fork.
+type ResponseTransform struct {
+	Type     string `json:"type"`
+	Name     string `json:"name,omitempty"`     // header: header name
+	Value    string `json:"value,omitempty"`    // header: value (empty removes the header)
+	Find     string `json:"find,omitempty"`     // replace: literal text to find
+	Replace  string `json:"replace,omitempty"`  // replace: replacement text
+	HTML     string `json:"html,omitempty"`     // inject: snippet to insert
+	Position string `json:"position,omitempty"` // inject: "head" or "body" (default)
+}
+
+// ValidateTransforms checks a list of transforms for well-formedness
+func ValidateTransforms(transforms []ResponseTransform) error {
+	if len(transforms) > MaxTransforms {
+		return fmt.Errorf("at most %d transforms allowed", MaxTransforms)
+	}
+	for i, t := range transforms {
+		switch t.Type {
+		case TransformHeader:
+			if t.Name == "" {
+				return fmt.Errorf("transform %d: name is required for header transforms", i)
+			}
+			if strings.ContainsAny(t.Name, " :\r\n") || strings.ContainsAny(t.Value, "\r\n") {
+				return fmt.Errorf("transform %d: invalid header", i)
+			}
+		case TransformReplace:
+			if t.Find == "" {
+				return fmt.Errorf("transform %d: find is required for replace transforms", i)
+			}
+		case TransformInject:
+			if t.HTML == "" {
+				return fmt.Errorf("transform %d: html is required for inject transforms", i)
+			}
+			if t.Position != "" && t.Position != "head" && t.Position != "body" {
+				return fmt.Errorf("transform %d: position must be 'head' or 'body'", i)
+			}
+		case TransformAnalytics:
+			// No options
+		default:
+			return fmt.Errorf("transform %d: unknown type %q", i, t.Type)
+		}
+	}
+	return nil
+}
+
+// hasBodyTransforms reports whether any transform rewrites the response body
+func hasBodyTransforms(transforms []ResponseTransform) bool {
+	for _, t := range transforms {
+		if t.Type != TransformHeader {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyBodyTransforms applies body transforms to HTML content in order
+func ApplyBodyTransforms(content []byte, transforms []ResponseTransform) []byte {
+	for _, t := range transforms {
+		switch t.Type {
+		case TransformReplace:
+			content = bytes.ReplaceAll(content, []byte(t.Find), []byte(t.Replace))
+		case TransformInject:
+			if t.Position == "head" {
+				content = injectBeforeTag(content, "</head>", t.HTML)
+			} else {
+				content = injectBeforeTag(content, "</body>", t.HTML)
+			}
+		case TransformAnalytics:
+			if !bytes.Contains(content, []byte(analyticsScript)) {
+				content = injectBeforeTag(content, "</body>", analyticsScript)
+			}
+		}
+	}
+	return content
+}
+
+// TransformWriter wraps a ResponseWriter and applies alias transforms.
+// Header transforms apply to every response; body transforms only to HTML,
+// which is buffered until Finish is called.
+type TransformWriter struct {
+	http.ResponseWriter
+	transforms  []ResponseTransform
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	buffering   bool
+}
+
+// NewTransformWriter creates a TransformWriter. When body transforms are present,
+// conditional request headers are stripped so a rewritten body is always produced.
+func NewTransformWriter(w http.ResponseWriter, r *http.Request, transforms []ResponseTransform) *TransformWriter {
+	if hasBodyTransforms(transforms) {
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+	}
+	return &TransformWriter{
+		ResponseWriter: w,
+		transforms:     transforms,
+		status:         http.StatusOK,
+	}
+}
+
+// WriteHeader applies header transforms and decides whether to buffer the body
+func (tw *TransformWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	tw.status = code
+
+	h := tw.Header()
+	for _, t := range tw.transforms {
+		if t.Type != TransformHeader {
+			continue
+		}
+		if t.Value == "" {
+			h.Del(t.Name)
+		} else {
+			h.Set(t.Name, t.Value)
+		}
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	if hasBodyTransforms(tw.transforms) && strings.HasPrefix(contentType, "text/html") &&
+		h.Get("Content-Encoding") == "" && code != http.StatusNotModified && code != http.StatusNoContent {
+		tw.buffering = true
+		return
+	}
+
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+// Write buffers HTML bodies and passes everything else through
+func (tw *TransformWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		if tw.Header().Get("Content-Type") == "" {
+			tw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		tw.WriteHeader(http.StatusOK)
+	}
+	if !tw.buffering {
+		return tw.ResponseWriter.Write(p)
+	}
+	if tw.buf.Len()+len(p) > maxTransformBody {
+		// Too large to rewrite - flush what we have and stream the rest unchanged
+		tw.buffering = false
+		tw.ResponseWriter.WriteHeader(tw.status)
+		if _, err := tw.ResponseWriter.Write(tw.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		tw.buf.Reset()
+		return tw.ResponseWriter.Write(p)
+	}
+	return tw.buf.Write(p)
+}
+
+// Flush implements http.Flusher. Buffered HTML is held until Finish.
+func (tw *TransformWriter) Flush() {
+	if tw.buffering {
+		return
+	}
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for WebSocket support
+func (tw *TransformWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := tw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
+}
+
+// Finish writes the transformed body. Must be called once the handler returns.
+func (tw *TransformWriter) Finish() {
+	if !tw.buffering {
+		return
+	}
+	tw.buffering = false
+
+	data := ApplyBodyTransforms(tw.buf.Bytes(), tw.transforms)
+	h := tw.Header()
+	h.Del("ETag")
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	tw.ResponseWriter.WriteHeader(tw.status)
+	tw.ResponseWriter.Write(data)
+}
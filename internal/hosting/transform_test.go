@@ -0,0 +1,125 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateTransforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		transforms []ResponseTransform
+		wantErr    bool
+	}{
+		{"empty", nil, false},
+		{"header", []ResponseTransform{{Type: TransformHeader, Name: "X-Frame-Options", Value: "DENY"}}, false},
+		{"header missing name", []ResponseTransform{{Type: TransformHeader, Value: "x"}}, true},
+		{"header injection", []ResponseTransform{{Type: TransformHeader, Name: "X-Test", Value: "a\r\nSet-Cookie: x"}}, true},
+		{"replace", []ResponseTransform{{Type: TransformReplace, Find: "foo", Replace: "bar"}}, false},
+		{"replace missing find", []ResponseTransform{{Type: TransformReplace, Replace: "bar"}}, true},
+		{"inject head", []ResponseTransform{{Type: TransformInject, HTML: "<meta>", Position: "head"}}, false},
+		{"inject bad position", []ResponseTransform{{Type: TransformInject, HTML: "<meta>", Position: "footer"}}, true},
+		{"analytics", []ResponseTransform{{Type: TransformAnalytics}}, false},
+		{"unknown", []ResponseTransform{{Type: "eval"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransforms(tt.transforms)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransforms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyBodyTransforms(t *testing.T) {
+	html := []byte("<html><head><title>Old</title></head><body><h1>Old</h1></body></html>")
+	out := ApplyBodyTransforms(html, []ResponseTransform{
+		{Type: TransformReplace, Find: "Old", Replace: "New"},
+		{Type: TransformInject, HTML: `<meta name="x">`, Position: "head"},
+		{Type: TransformInject, HTML: `<div id="banner"></div>`},
+		{Type: TransformAnalytics},
+	})
+
+	s := string(out)
+	if strings.Contains(s, "Old") {
+		t.Errorf("Expected all occurrences replaced, got %s", s)
+	}
+	if !strings.Contains(s, `<meta name="x"></head>`) {
+		t.Errorf("Expected head injection, got %s", s)
+	}
+	if !strings.Contains(s, `<div id="banner"></div>`) {
+		t.Errorf("Expected body injection, got %s", s)
+	}
+	if !strings.Contains(s, "sendBeacon") {
+		t.Errorf("Expected analytics snippet, got %s", s)
+	}
+
+	// Analytics is not injected twice
+	again := ApplyBodyTransforms(out, []ResponseTransform{{Type: TransformAnalytics}})
+	if strings.Count(string(again), "sendBeacon") != 1 {
+		t.Error("Expected analytics snippet to be injected only once")
+	}
+}
+
+func TestTransformWriter(t *testing.T) {
+	transforms := []ResponseTransform{
+		{Type: TransformHeader, Name: "X-Powered-By", Value: "fazt"},
+		{Type: TransformHeader, Name: "X-Remove-Me"},
+		{Type: TransformReplace, Find: "hello", Replace: "goodbye"},
+	}
+
+	t.Run("html body rewritten", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("If-None-Match", `"abc"`)
+		rec := httptest.NewRecorder()
+
+		tw := NewTransformWriter(rec, req, transforms)
+		tw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		tw.Header().Set("Content-Length", "18")
+		tw.Header().Set("ETag", `"abc"`)
+		tw.Header().Set("X-Remove-Me", "1")
+		tw.Write([]byte("<body>hello</body>"))
+		tw.Finish()
+
+		if req.Header.Get("If-None-Match") != "" {
+			t.Error("Expected conditional headers to be stripped")
+		}
+		if rec.Body.String() != "<body>goodbye</body>" {
+			t.Errorf("Unexpected body: %s", rec.Body.String())
+		}
+		if rec.Header().Get("Content-Length") != "20" {
+			t.Errorf("Expected Content-Length 20, got %s", rec.Header().Get("Content-Length"))
+		}
+		if rec.Header().Get("ETag") != "" {
+			t.Error("Expected ETag to be removed from rewritten body")
+		}
+		if rec.Header().Get("X-Powered-By") != "fazt" {
+			t.Error("Expected header transform to be applied")
+		}
+		if rec.Header().Get("X-Remove-Me") != "" {
+			t.Error("Expected empty-value header transform to remove header")
+		}
+	})
+
+	t.Run("non-html passes through", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/app.js", nil)
+		rec := httptest.NewRecorder()
+
+		tw := NewTransformWriter(rec, req, transforms)
+		tw.Header().Set("Content-Type", "application/javascript")
+		tw.WriteHeader(http.StatusOK)
+		tw.Write([]byte("console.log('hello')"))
+		tw.Finish()
+
+		if rec.Body.String() != "console.log('hello')" {
+			t.Errorf("Expected body untouched, got %s", rec.Body.String())
+		}
+		if rec.Header().Get("X-Powered-By") != "fazt" {
+			t.Error("Expected header transform on non-HTML response")
+		}
+	})
+}
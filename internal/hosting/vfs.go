@@ -1,17 +1,19 @@
 package hosting
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"io"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/fazt-sh/fazt/internal/appid"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/storage"
 )
 
 // FileSystem defines the interface for site storage
@@ -52,16 +54,20 @@ type CachedFile struct {
 
 // SQLFileSystem implements FileSystem using SQLite with in-memory caching
 type SQLFileSystem struct {
-	db      *sql.DB
-	cache   map[string]CachedFile
-	cacheMu sync.RWMutex
+	db              *sql.DB
+	cache           *fileCache
+	variantMisses   map[string]struct{} // precompressed variants known not to exist
+	analyticsInject map[string]bool     // per-app injection flags, by app ID or title
+	cacheMu         sync.RWMutex        // guards variantMisses and analyticsInject
 }
 
 // NewSQLFileSystem creates a new SQL-backed file system
 func NewSQLFileSystem(db *sql.DB) *SQLFileSystem {
 	return &SQLFileSystem{
-		db:    db,
-		cache: make(map[string]CachedFile),
+		db:              db,
+		cache:           newFileCache(cacheSize),
+		variantMisses:   make(map[string]struct{}),
+		analyticsInject: make(map[string]bool),
 	}
 }
 
@@ -94,14 +100,19 @@ func (fs *SQLFileSystem) WriteFile(siteID, path string, content io.Reader, size
 			updated_at = CURRENT_TIMESTAMP
 	`
 	
-	_, err = fs.db.Exec(query, siteID, path, data, size, mimeType, hashStr)
+	// Through the write queue so deploys don't fight request writes for the lock
+	err = storage.QueueWriteTx(context.Background(), fs.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(query, siteID, path, data, size, mimeType, hashStr)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("failed to write file to DB: %w", err)
 	}
 
-	// Invalidate cache
+	// Invalidate cache, unless the content is unchanged
+	fs.cache.invalidate(cacheKey(siteID, path), hashStr)
 	fs.cacheMu.Lock()
-	delete(fs.cache, cacheKey(siteID, path))
+	delete(fs.variantMisses, cacheKey(siteID, path))
 	fs.cacheMu.Unlock()
 
 	return nil
@@ -112,9 +123,7 @@ func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 	key := cacheKey(siteID, path)
 
 	// Check cache
-	fs.cacheMu.RLock()
-	if cached, ok := fs.cache[key]; ok {
-		fs.cacheMu.RUnlock()
+	if cached, ok := fs.cache.get(key); ok {
 		return &File{
 			Content:  io.NopCloser(newByteReader(cached.Data)),
 			Size:     cached.Size,
@@ -123,7 +132,6 @@ func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 			ModTime:  cached.ModTime,
 		}, nil
 	}
-	fs.cacheMu.RUnlock()
 
 	// Query DB
 	query := `
@@ -145,19 +153,13 @@ func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 	}
 
 	// Update cache
-	fs.cacheMu.Lock()
-	// Simple eviction policy: if cache too big, clear it
-	if len(fs.cache) > 1000 {
-		fs.cache = make(map[string]CachedFile)
-	}
-	fs.cache[key] = CachedFile{
+	fs.cache.put(key, CachedFile{
 		Data:     data,
 		Size:     size,
 		MimeType: mimeType,
 		Hash:     hash,
 		ModTime:  modTime,
-	}
-	fs.cacheMu.Unlock()
+	})
 
 	return &File{
 		Content:  io.NopCloser(newByteReader(data)),
@@ -172,39 +174,36 @@ func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 type VFSStats struct {
 	CachedFiles    int
 	CacheSizeBytes int64
+	CacheMaxBytes  int64
+	Hits           int64
+	Misses         int64
+	Evictions      int64
 }
 
 // GetStats returns VFS statistics
 func (fs *SQLFileSystem) GetStats() VFSStats {
-	fs.cacheMu.RLock()
-	defer fs.cacheMu.RUnlock()
-
-	var size int64
-	for _, f := range fs.cache {
-		size += f.Size
-	}
-
-	return VFSStats{
-		CachedFiles:    len(fs.cache),
-		CacheSizeBytes: size,
-	}
+	return fs.cache.stats()
 }
 
 // DeleteSite deletes all files for a site
 func (fs *SQLFileSystem) DeleteSite(siteID string) error {
-	_, err := fs.db.Exec("DELETE FROM files WHERE site_id = ?", siteID)
+	err := storage.QueueWrite(context.Background(), func() error {
+		_, err := fs.db.Exec("DELETE FROM files WHERE site_id = ?", siteID)
+		return err
+	})
 	
 	// Invalidate all files for this site in cache
+	fs.cache.invalidatePrefix(siteID + ":")
 	fs.cacheMu.Lock()
-	// Since we can't efficiently search by prefix in map, we iterate
-	// optimization: if cache is huge, maybe just clear it all?
-	// For now, iterate is okay for <1000 items
-	for k := range fs.cache {
+	for k := range fs.variantMisses {
 		if strings.HasPrefix(k, siteID+":") {
-			delete(fs.cache, k)
+			delete(fs.variantMisses, k)
 		}
 	}
+	clear(fs.analyticsInject)
 	fs.cacheMu.Unlock()
+	forgetAPIRoutes(siteID)
+	modules.Forget(siteID)
 	
 	return err
 }
@@ -213,12 +212,9 @@ func (fs *SQLFileSystem) DeleteSite(siteID string) error {
 func (fs *SQLFileSystem) Exists(siteID, path string) (bool, error) {
 	// Check cache first
 	key := cacheKey(siteID, path)
-	fs.cacheMu.RLock()
-	if _, ok := fs.cache[key]; ok {
-		fs.cacheMu.RUnlock()
+	if fs.cache.has(key) {
 		return true, nil
 	}
-	fs.cacheMu.RUnlock()
 
 	var count int
 	err := fs.db.QueryRow("SELECT COUNT(*) FROM files WHERE site_id = ? AND path = ?", siteID, path).Scan(&count)
@@ -299,9 +295,10 @@ func (fs *SQLFileSystem) EnsureApp(name string, source *SourceInfo) error {
 		}
 	}
 
-	// Check if app exists by title (via alias lookup)
+	// Check if app exists by title (via alias lookup). Forks share their
+	// original's title; deploys go to the original.
 	var existingID string
-	err := fs.db.QueryRow(`SELECT id FROM apps WHERE title = ?`, name).Scan(&existingID)
+	err := fs.db.QueryRow(`SELECT id FROM apps WHERE title = ? ORDER BY forked_from_id IS NOT NULL LIMIT 1`, name).Scan(&existingID)
 
 	if err == sql.ErrNoRows {
 		// Create new app with generated ID
@@ -310,17 +307,21 @@ func (fs *SQLFileSystem) EnsureApp(name string, source *SourceInfo) error {
 			INSERT INTO apps (id, title, source, source_url, source_ref, source_commit, created_at, updated_at)
 			VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		`
-		_, err = fs.db.Exec(query, appID, name, sourceType, sourceURL, sourceRef, sourceCommit)
-		if err != nil {
-			return fmt.Errorf("failed to create app entry: %w", err)
-		}
-
 		// Create alias pointing to this app
 		aliasQuery := `
 			INSERT OR IGNORE INTO aliases (subdomain, type, targets, created_at, updated_at)
 			VALUES (?, 'app', json_object('app_id', ?), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		`
-		fs.db.Exec(aliasQuery, name, appID)
+		err = storage.QueueWriteTx(context.Background(), fs.db, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(query, appID, name, sourceType, sourceURL, sourceRef, sourceCommit); err != nil {
+				return err
+			}
+			tx.Exec(aliasQuery, name, appID)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create app entry: %w", err)
+		}
 		return nil
 	} else if err != nil {
 		return fmt.Errorf("failed to lookup app: %w", err)
@@ -336,8 +337,10 @@ func (fs *SQLFileSystem) EnsureApp(name string, source *SourceInfo) error {
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
-	_, err = fs.db.Exec(query, sourceType, sourceURL, sourceRef, sourceCommit, existingID)
-	return err
+	return storage.QueueWrite(context.Background(), func() error {
+		_, err := fs.db.Exec(query, sourceType, sourceURL, sourceRef, sourceCommit, existingID)
+		return err
+	})
 }
 
 // GetAppSource returns source tracking info for an app
@@ -371,9 +374,7 @@ func (fs *SQLFileSystem) ReadFileByAppID(appID, path string) (*File, error) {
 	key := cacheKey(appID, path)
 
 	// Check cache
-	fs.cacheMu.RLock()
-	if cached, ok := fs.cache[key]; ok {
-		fs.cacheMu.RUnlock()
+	if cached, ok := fs.cache.get(key); ok {
 		return &File{
 			Content:  io.NopCloser(newByteReader(cached.Data)),
 			Size:     cached.Size,
@@ -382,7 +383,6 @@ func (fs *SQLFileSystem) ReadFileByAppID(appID, path string) (*File, error) {
 			ModTime:  cached.ModTime,
 		}, nil
 	}
-	fs.cacheMu.RUnlock()
 
 	// Query DB using app_id
 	query := `
@@ -404,18 +404,13 @@ func (fs *SQLFileSystem) ReadFileByAppID(appID, path string) (*File, error) {
 	}
 
 	// Update cache
-	fs.cacheMu.Lock()
-	if len(fs.cache) > 1000 {
-		fs.cache = make(map[string]CachedFile)
-	}
-	fs.cache[key] = CachedFile{
+	fs.cache.put(key, CachedFile{
 		Data:     data,
 		Size:     size,
 		MimeType: mimeType,
 		Hash:     hash,
 		ModTime:  modTime,
-	}
-	fs.cacheMu.Unlock()
+	})
 
 	return &File{
 		Content:  io.NopCloser(newByteReader(data)),
@@ -454,30 +449,48 @@ func (fs *SQLFileSystem) ListFilesByAppID(appID string) ([]FileEntry, error) {
 
 // DeleteAppFiles deletes all files for an app by app_id
 func (fs *SQLFileSystem) DeleteAppFiles(appID string) error {
-	_, err := fs.db.Exec("DELETE FROM files WHERE app_id = ?", appID)
+	err := storage.QueueWrite(context.Background(), func() error {
+		_, err := fs.db.Exec("DELETE FROM files WHERE app_id = ?", appID)
+		return err
+	})
 
 	// Invalidate cache
+	fs.cache.invalidatePrefix(appID + ":")
 	fs.cacheMu.Lock()
-	for k := range fs.cache {
+	for k := range fs.variantMisses {
 		if strings.HasPrefix(k, appID+":") {
-			delete(fs.cache, k)
+			delete(fs.variantMisses, k)
 		}
 	}
+	clear(fs.analyticsInject)
 	fs.cacheMu.Unlock()
+	forgetAPIRoutes(appID)
+	modules.Forget(appID)
 
 	return err
 }
 
+// forgetSite drops what's cached of a site's files and settings, for when they were
+// replaced without going through the file system, as a restore does
+func (fs *SQLFileSystem) forgetSite(siteID string) {
+	fs.cache.invalidatePrefix(siteID + ":")
+	fs.cacheMu.Lock()
+	for k := range fs.variantMisses {
+		if strings.HasPrefix(k, siteID+":") {
+			delete(fs.variantMisses, k)
+		}
+	}
+	clear(fs.analyticsInject)
+	fs.cacheMu.Unlock()
+}
+
 // ExistsByAppID checks if a file exists using app_id
 func (fs *SQLFileSystem) ExistsByAppID(appID, path string) (bool, error) {
 	// Check cache first
 	key := cacheKey(appID, path)
-	fs.cacheMu.RLock()
-	if _, ok := fs.cache[key]; ok {
-		fs.cacheMu.RUnlock()
+	if fs.cache.has(key) {
 		return true, nil
 	}
-	fs.cacheMu.RUnlock()
 
 	// Query DB using app_id
 	var count int
@@ -505,32 +518,43 @@ func (fs *SQLFileSystem) SetAppSPA(name string, enabled bool) error {
 	return err
 }
 
-// GetMimeType returns the MIME type for a file path
-func GetMimeType(path string) string {
-	ext := strings.ToLower(filepath.Ext(path))
-	mimeTypes := map[string]string{
-		".html": "text/html",
-		".css":  "text/css",
-		".js":   "application/javascript",
-		".json": "application/json",
-		".png":  "image/png",
-		".jpg":  "image/jpeg",
-		".jpeg": "image/jpeg",
-		".gif":  "image/gif",
-		".svg":  "image/svg+xml",
-		".ico":  "image/x-icon",
-		".woff": "font/woff",
-		".woff2": "font/woff2",
-		".ttf":  "font/ttf",
-		".eot":  "application/vnd.ms-fontobject",
-		".txt":  "text/plain",
-		".xml":  "application/xml",
-		".pdf":  "application/pdf",
-		".zip":  "application/zip",
-		".wasm": "application/wasm",
+// GetAppAnalyticsInject returns whether the tracking snippet is injected into
+// an app's HTML. It's read on every HTML page, so the flag is cached until
+// changed.
+func (fs *SQLFileSystem) GetAppAnalyticsInject(name string) (bool, error) {
+	fs.cacheMu.RLock()
+	inject, ok := fs.analyticsInject[name]
+	fs.cacheMu.RUnlock()
+	if ok {
+		return inject, nil
 	}
-	if mime, ok := mimeTypes[ext]; ok {
-		return mime
+
+	var val int
+	err := fs.db.QueryRow(`SELECT COALESCE(analytics_inject, 1) FROM apps WHERE id = ? OR title = ?`, name, name).Scan(&val)
+	if err != nil {
+		return true, err
 	}
-	return "application/octet-stream"
+	fs.cacheMu.Lock()
+	fs.analyticsInject[name] = val == 1
+	fs.cacheMu.Unlock()
+	return val == 1, nil
+}
+
+// SetAppAnalyticsInject sets the analytics injection flag for an app
+func (fs *SQLFileSystem) SetAppAnalyticsInject(name string, enabled bool) error {
+	val := 0
+	if enabled {
+		val = 1
+	}
+	_, err := fs.db.Exec(`UPDATE apps SET analytics_inject = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? OR title = ?`, val, name, name)
+	fs.forgetAnalyticsInject()
+	return err
+}
+
+// forgetAnalyticsInject drops the cached injection flags. Apps are cached by
+// ID and by title, so all of them go.
+func (fs *SQLFileSystem) forgetAnalyticsInject() {
+	fs.cacheMu.Lock()
+	clear(fs.analyticsInject)
+	fs.cacheMu.Unlock()
 }
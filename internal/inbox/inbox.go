@@ -0,0 +1,87 @@
+// Package inbox receives email for apps. An SMTP server on the mail domain
+// (apps.<domain> by default) accepts messages for <app>@<mail domain> and
+// <app>+<tag>@<mail domain>, parses them and emits a mail.received event
+// for each app, which runs the handler the app's manifest.json registers
+// ("on": {"mail.received": "hooks/inbox.js"}). Attachments are stored in
+// the app's blobs under mail/<message id>/.
+//
+// Apps without a mail.received handler don't have a mailbox: their mail is
+// refused at RCPT, before the message is sent.
+package inbox
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// NewServer returns a server delivering mail for the apps at domain to their
+// mail.received handlers
+func NewServer(db *sql.DB, domain string, maxBytes int64) *Server {
+	blobs := storage.NewSQLBlobStoreWithWriter(db, storage.GetWriter())
+	return &Server{
+		Domain:   domain,
+		MaxBytes: maxBytes,
+		Accept:   HasMailbox,
+		Deliver: func(app string, m *Message) error {
+			return Deliver(context.Background(), blobs, app, m)
+		},
+	}
+}
+
+// HasMailbox reports whether an app receives email: its manifest.json
+// registers a mail.received handler
+func HasMailbox(app string) bool {
+	return hosting.EventHandler(app, events.MailReceived) != ""
+}
+
+// Deliver stores a message's attachments in the app's blobs and emits
+// mail.received with the message
+func Deliver(ctx context.Context, blobs *storage.SQLBlobStore, app string, m *Message) error {
+	for i := range m.Attachments {
+		a := &m.Attachments[i]
+		a.Path = fmt.Sprintf("mail/%s/%d-%s", m.ID, i+1, safeFilename(a.Filename))
+		if err := blobs.Put(ctx, app, a.Path, a.Data, a.MimeType); err != nil {
+			return fmt.Errorf("failed to store attachment %s: %w", a.Filename, err)
+		}
+	}
+	events.Emit(events.MailReceived, app, m.Map())
+	return nil
+}
+
+// safeFilename keeps an attachment's file name usable as a blob path
+// segment
+func safeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/?#%"<>`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	if len(name) > 100 {
+		ext := path.Ext(name)
+		if len(ext) > 10 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:100-len(ext)], "") + ext
+	}
+	return name
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "msg_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,223 @@
+package inbox
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+const testMessage = "From: =?utf-8?q?Ada_Lovelace?= <Ada@Example.com>\r\n" +
+	"To: support@apps.example.com\r\n" +
+	"Subject: =?utf-8?q?Caf=C3=A9_order?=\r\n" +
+	"Date: Mon, 12 Oct 2026 09:30:00 +0200\r\n" +
+	"Message-ID: <abc@example.com>\r\n" +
+	"In-Reply-To: <ticket-41@apps.example.com>\r\n" +
+	"References: <ticket-40@apps.example.com> <ticket-41@apps.example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=inner\r\n" +
+	"\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Two caf=C3=A9s, please.\r\n" +
+	"--inner\r\n" +
+	"Content-Type: text/html; charset=iso-8859-1\r\n" +
+	"\r\n" +
+	"<p>Two caf\xe9s, please.</p>\r\n" +
+	"--inner--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv\r\n" +
+	"Content-Disposition: attachment; filename=\"../order.csv\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"aXRlbSxxdHkKY2FmZSwy\r\n" +
+	"--outer--\r\n"
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(testMessage))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if m.From != "ada@example.com" || m.FromName != "Ada Lovelace" || m.Subject != "Café order" {
+		t.Errorf("Unexpected sender or subject: %q %q %q", m.From, m.FromName, m.Subject)
+	}
+	if m.Date.IsZero() || m.MessageID != "<abc@example.com>" || m.InReplyTo != "<ticket-41@apps.example.com>" || len(m.References) != 2 {
+		t.Errorf("Unexpected threading headers: %+v", m)
+	}
+	if m.Text != "Two cafés, please.\r\n" && m.Text != "Two cafés, please." {
+		t.Errorf("Text = %q", m.Text)
+	}
+	if !strings.Contains(m.HTML, "cafés") {
+		t.Errorf("Expected the Latin-1 HTML body converted, got %q", m.HTML)
+	}
+	if len(m.Attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(m.Attachments))
+	}
+	if a := m.Attachments[0]; a.Filename != "../order.csv" || a.MimeType != "text/csv" || string(a.Data) != "item,qty\ncafe,2" {
+		t.Errorf("Unexpected attachment: %+v", a)
+	}
+	if m.Headers["Subject"] != "Café order" {
+		t.Errorf("Expected decoded headers, got %v", m.Headers)
+	}
+
+	// A plain message without MIME headers is its text
+	m, err = Parse([]byte("From: a@example.com\r\nSubject: Hi\r\n\r\nHello\r\n"))
+	if err != nil || m.Text != "Hello\r\n" || len(m.Attachments) != 0 {
+		t.Errorf("Unexpected plain message: %+v, %v", m, err)
+	}
+}
+
+// startServer runs a server for apps.example.com taking mail for the given
+// apps, returning its address and the deliveries it makes
+func startServer(t *testing.T, apps ...string) (string, <-chan *Message) {
+	t.Helper()
+	delivered := make(chan *Message, 10)
+	s := &Server{
+		Domain:   "apps.example.com",
+		MaxBytes: 4096,
+		Accept: func(app string) bool {
+			for _, a := range apps {
+				if a == app {
+					return true
+				}
+			}
+			return false
+		},
+		Deliver: func(app string, m *Message) error {
+			m.Headers["X-App"] = app
+			delivered <- m
+			return nil
+		},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+	return ln.Addr().String(), delivered
+}
+
+func TestServerDelivers(t *testing.T) {
+	addr, delivered := startServer(t, "support", "news")
+
+	to := []string{"Support+Ticket-42@apps.example.com", "news@apps.example.com"}
+	if err := smtp.SendMail(addr, nil, "ada@example.com", to, []byte(testMessage)); err != nil {
+		t.Fatalf("SendMail failed: %v", err)
+	}
+
+	got := map[string]*Message{}
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-delivered:
+			got[m.Headers["X-App"]] = m
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected a delivery per app")
+		}
+	}
+	support := got["support"]
+	if support == nil || support.Tag != "ticket-42" || support.MailFrom != "ada@example.com" ||
+		len(support.To) != 1 || support.To[0] != "support+ticket-42@apps.example.com" || !strings.HasPrefix(support.ID, "msg_") {
+		t.Errorf("Unexpected delivery to support: %+v", support)
+	}
+	if news := got["news"]; news == nil || news.ID == support.ID || news.Tag != "" {
+		t.Errorf("Expected news to get its own copy: %+v", news)
+	}
+}
+
+func TestServerRefuses(t *testing.T) {
+	addr, _ := startServer(t, "support")
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Mail("ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, rcpt := range []string{"nobody@apps.example.com", "support@example.org", "support@mail.apps.example.com"} {
+		if err := c.Rcpt(rcpt); err == nil || !strings.HasPrefix(err.Error(), "550") {
+			t.Errorf("Expected %s refused with 550, got %v", rcpt, err)
+		}
+	}
+	if err := c.Rcpt("support@apps.example.com"); err != nil {
+		t.Fatalf("Rcpt failed: %v", err)
+	}
+
+	// Larger than MaxBytes
+	w, err := c.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("Subject: big\r\n\r\n" + strings.Repeat("x", 5000) + "\r\n"))
+	if err := w.Close(); err == nil || !strings.HasPrefix(err.Error(), "552") {
+		t.Errorf("Expected a large message refused with 552, got %v", err)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db := dbtest.Open(t)
+
+	received := make(chan events.Event, 10)
+	defer events.Subscribe(func(e events.Event) {
+		if e.Type == events.MailReceived {
+			received <- e
+		}
+	})()
+
+	m, err := Parse([]byte(testMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.ID = "msg_1"
+	blobs := storage.NewSQLBlobStore(db)
+	if err := Deliver(context.Background(), blobs, "support", m); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	blob, err := blobs.Get(context.Background(), "support", "mail/msg_1/1-order.csv")
+	if err != nil || string(blob.Data) != "item,qty\ncafe,2" {
+		t.Fatalf("Expected the attachment stored, got %v, %v", blob, err)
+	}
+
+	select {
+	case e := <-received:
+		attachments, _ := e.Data["attachments"].([]interface{})
+		if e.AppID != "support" || e.Data["subject"] != "Café order" || len(attachments) != 1 {
+			t.Fatalf("Unexpected event: %+v", e)
+		}
+		if a := attachments[0].(map[string]interface{}); a["path"] != "mail/msg_1/1-order.csv" || a["size"] != 15 {
+			t.Errorf("Unexpected attachment: %v", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected mail.received emitted")
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"report.pdf":                      "report.pdf",
+		`C:\Users\ada\a.txt`:              "a.txt",
+		"../../etc/passwd":                "passwd",
+		"a?b#c.txt":                       "a_b_c.txt",
+		"":                                "attachment",
+		"..":                              "attachment",
+		strings.Repeat("x", 200) + ".png": strings.Repeat("x", 96) + ".png",
+	} {
+		if got := safeFilename(in); got != want {
+			t.Errorf("safeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package inbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxParts bounds how many MIME parts a message may have, so a crafted
+// message can't make parsing run away
+const maxParts = 100
+
+// Message is a received email, parsed for an app's handler
+type Message struct {
+	ID         string
+	MailFrom   string   // Envelope sender (bounces go here), "" for bounces
+	To         []string // Envelope recipients at the app
+	Tag        string   // The part after + in the first recipient, e.g. "ticket-42"
+	From       string   // From header address
+	FromName   string
+	Subject    string
+	Date       time.Time
+	MessageID  string
+	InReplyTo  string
+	References []string
+	Headers    map[string]string // First value of each header, by canonical name
+	Text       string
+	HTML       string
+
+	Attachments []Attachment
+	RemoteAddr  string
+}
+
+// Attachment is a file sent with a message. Data is stored in the app's
+// blobs at Path on delivery.
+type Attachment struct {
+	Filename string
+	MimeType string
+	Path     string
+	Data     []byte
+}
+
+// Map returns the message the way JS handlers see it in job.data.event.data
+func (m *Message) Map() map[string]interface{} {
+	attachments := make([]interface{}, 0, len(m.Attachments))
+	for _, a := range m.Attachments {
+		attachments = append(attachments, map[string]interface{}{
+			"filename":  a.Filename,
+			"mime_type": a.MimeType,
+			"size":      len(a.Data),
+			"path":      a.Path,
+		})
+	}
+	references := m.References
+	if references == nil {
+		references = []string{}
+	}
+	date := ""
+	if !m.Date.IsZero() {
+		date = m.Date.UTC().Format(time.RFC3339)
+	}
+	return map[string]interface{}{
+		"id":          m.ID,
+		"mail_from":   m.MailFrom,
+		"to":          m.To,
+		"tag":         m.Tag,
+		"from":        m.From,
+		"from_name":   m.FromName,
+		"subject":     m.Subject,
+		"date":        date,
+		"message_id":  m.MessageID,
+		"in_reply_to": m.InReplyTo,
+		"references":  references,
+		"headers":     m.Headers,
+		"text":        m.Text,
+		"html":        m.HTML,
+		"attachments": attachments,
+		"remote_addr": m.RemoteAddr,
+	}
+}
+
+// Parse reads a raw message into its headers, bodies and attachments. The
+// first text/plain and text/html parts are the bodies; other parts with a
+// file name, or marked as attachments, are attachments.
+func Parse(raw []byte) (*Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+
+	m := &Message{Headers: make(map[string]string, len(msg.Header))}
+	for name, values := range msg.Header {
+		if len(values) > 0 {
+			m.Headers[name] = decodeHeader(values[0])
+		}
+	}
+	if from, err := msg.Header.AddressList("From"); err == nil && len(from) > 0 {
+		m.From, m.FromName = strings.ToLower(from[0].Address), from[0].Name
+	}
+	m.Subject = decodeHeader(msg.Header.Get("Subject"))
+	if date, err := msg.Header.Date(); err == nil {
+		m.Date = date
+	}
+	m.MessageID = strings.TrimSpace(msg.Header.Get("Message-Id"))
+	m.InReplyTo = strings.TrimSpace(msg.Header.Get("In-Reply-To"))
+	m.References = strings.Fields(msg.Header.Get("References"))
+
+	parts := 0
+	err = m.readPart(textproto.MIMEHeader(msg.Header), msg.Body, &parts)
+	return m, err
+}
+
+// readPart reads one MIME part into m, descending into multiparts
+func (m *Message) readPart(header textproto.MIMEHeader, body io.Reader, parts *int) error {
+	if *parts++; *parts > maxParts {
+		return fmt.Errorf("message has more than %d parts", maxParts)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid multipart body: %w", err)
+			}
+			if err := m.readPart(p.Header, p, parts); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransfer(body, header.Get("Content-Transfer-Encoding")))
+	if err != nil {
+		return fmt.Errorf("invalid %s part: %w", mediaType, err)
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(dparams["filename"])
+	if filename == "" {
+		filename = decodeHeader(params["name"])
+	}
+	if disposition != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && m.Text == "":
+			m.Text = toUTF8(data, params["charset"])
+			return nil
+		case mediaType == "text/html" && m.HTML == "":
+			m.HTML = toUTF8(data, params["charset"])
+			return nil
+		case strings.HasPrefix(mediaType, "text/"):
+			return nil
+		}
+	}
+
+	if filename == "" {
+		filename = fmt.Sprintf("attachment-%d", len(m.Attachments)+1)
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			filename += exts[0]
+		}
+	}
+	m.Attachments = append(m.Attachments, Attachment{Filename: filename, MimeType: mediaType, Data: data})
+	return nil
+}
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding
+func decodeTransfer(r io.Reader, encoding string) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// headerDecoder decodes RFC 2047 encoded words in UTF-8, ASCII and Latin-1
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(toUTF8(data, charset)), nil
+	},
+}
+
+func decodeHeader(s string) string {
+	if decoded, err := headerDecoder.DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
+
+// toUTF8 converts a text body to UTF-8. Latin-1 is converted; other
+// charsets are kept as they are when they're already valid UTF-8, and
+// have their invalid bytes replaced otherwise.
+func toUTF8(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data), "�")
+}
@@ -0,0 +1,346 @@
+package inbox
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/debug"
+)
+
+const (
+	// maxRecipients bounds RCPT TO commands per message
+	maxRecipients = 20
+
+	// commandTimeout and dataTimeout are how long a client may take to send
+	// a command and a message (RFC 5321 asks for at least 5 and 10 minutes)
+	commandTimeout = 5 * time.Minute
+	dataTimeout    = 10 * time.Minute
+)
+
+// Recipient is an address at the mail domain: <app>@domain or
+// <app>+<tag>@domain
+type Recipient struct {
+	Address string
+	App     string
+	Tag     string
+}
+
+// Server receives email over SMTP for the apps at its domain. It only
+// accepts mail for apps Accept allows, and only as the final destination,
+// so it can't be used to relay mail elsewhere.
+type Server struct {
+	Domain   string // e.g. apps.example.com
+	MaxBytes int64  // Largest message accepted
+
+	// Accept reports whether an app takes email; Deliver hands a message to
+	// one app with its recipients there
+	Accept  func(app string) bool
+	Deliver func(app string, m *Message) error
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// Serve accepts connections on l until Close
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	s.listener = l
+	s.conns = make(map[net.Conn]struct{})
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting mail, dropping the sessions in progress. Senders
+// retry mail that wasn't acknowledged.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// session is one SMTP conversation
+type session struct {
+	s        *Server
+	conn     net.Conn
+	text     *textproto.Conn
+	helo     string
+	mailFrom string
+	hasFrom  bool
+	rcpts    []Recipient
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	sess := &session{s: s, conn: conn, text: textproto.NewConn(conn)}
+	sess.reply(220, "%s ESMTP fazt", s.Domain)
+
+	for {
+		conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := sess.text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		if !sess.handle(strings.ToUpper(verb), strings.TrimSpace(arg)) {
+			return
+		}
+	}
+}
+
+func (sess *session) reply(code int, format string, args ...interface{}) {
+	sess.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+func (sess *session) reset() {
+	sess.mailFrom, sess.hasFrom, sess.rcpts = "", false, nil
+}
+
+// handle answers one command, returning false to end the session
+func (sess *session) handle(verb, arg string) bool {
+	switch verb {
+	case "EHLO":
+		sess.helo = arg
+		sess.reset()
+		sess.text.PrintfLine("250-%s", sess.s.Domain)
+		sess.text.PrintfLine("250-SIZE %d", sess.s.MaxBytes)
+		sess.text.PrintfLine("250-8BITMIME")
+		sess.text.PrintfLine("250 PIPELINING")
+	case "HELO":
+		sess.helo = arg
+		sess.reset()
+		sess.reply(250, "%s", sess.s.Domain)
+	case "MAIL":
+		sess.mail(arg)
+	case "RCPT":
+		sess.rcpt(arg)
+	case "DATA":
+		return sess.data()
+	case "RSET":
+		sess.reset()
+		sess.reply(250, "OK")
+	case "NOOP":
+		sess.reply(250, "OK")
+	case "VRFY":
+		sess.reply(252, "Send some mail and see")
+	case "QUIT":
+		sess.reply(221, "Bye")
+		return false
+	default:
+		sess.reply(502, "Command not implemented")
+	}
+	return true
+}
+
+func (sess *session) mail(arg string) {
+	if sess.helo == "" {
+		sess.reply(503, "Say hello first")
+		return
+	}
+	if sess.hasFrom {
+		sess.reply(503, "Sender already given")
+		return
+	}
+	addr, params, ok := pathArg(arg, "FROM:")
+	if !ok {
+		sess.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+	for _, p := range params {
+		if k, v, _ := strings.Cut(p, "="); strings.EqualFold(k, "SIZE") {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > sess.s.MaxBytes {
+				sess.reply(552, "Message is larger than %d bytes", sess.s.MaxBytes)
+				return
+			}
+		}
+	}
+	sess.mailFrom, sess.hasFrom = addr, true
+	sess.reply(250, "OK")
+}
+
+func (sess *session) rcpt(arg string) {
+	if !sess.hasFrom {
+		sess.reply(503, "Need MAIL before RCPT")
+		return
+	}
+	if len(sess.rcpts) >= maxRecipients {
+		sess.reply(452, "Too many recipients")
+		return
+	}
+	addr, _, ok := pathArg(arg, "TO:")
+	if !ok {
+		sess.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+	rcpt, ok := sess.s.recipient(addr)
+	if !ok {
+		sess.reply(550, "Relaying denied")
+		return
+	}
+	if !sess.s.Accept(rcpt.App) {
+		sess.reply(550, "No such mailbox: %s", rcpt.Address)
+		return
+	}
+	sess.rcpts = append(sess.rcpts, rcpt)
+	sess.reply(250, "OK")
+}
+
+// data reads a message and delivers it to each app it's addressed to,
+// returning false when the connection broke
+func (sess *session) data() bool {
+	if len(sess.rcpts) == 0 {
+		sess.reply(503, "Need RCPT before DATA")
+		return true
+	}
+	sess.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	sess.conn.SetDeadline(time.Now().Add(dataTimeout))
+	dot := sess.text.DotReader()
+	raw, err := io.ReadAll(io.LimitReader(dot, sess.s.MaxBytes+1))
+	if err == nil && int64(len(raw)) > sess.s.MaxBytes {
+		_, err = io.Copy(io.Discard, dot)
+		if err == nil {
+			sess.reply(552, "Message is larger than %d bytes", sess.s.MaxBytes)
+			sess.reset()
+			return true
+		}
+	}
+	if err != nil {
+		return false
+	}
+	defer sess.reset()
+
+	code, msg := sess.deliver(raw)
+	sess.reply(code, "%s", msg)
+	return true
+}
+
+// deliver parses a message and hands it to its apps, returning the reply
+func (sess *session) deliver(raw []byte) (int, string) {
+	parsed, err := Parse(raw)
+	if err != nil {
+		return 554, "Message not accepted: " + err.Error()
+	}
+
+	// One delivery per app, with its recipients there
+	var apps []string
+	byApp := make(map[string][]Recipient)
+	for _, r := range sess.rcpts {
+		if _, ok := byApp[r.App]; !ok {
+			apps = append(apps, r.App)
+		}
+		byApp[r.App] = append(byApp[r.App], r)
+	}
+
+	var failed []string
+	var ids []string
+	for _, app := range apps {
+		// Each app gets its own copy, with its own attachment paths
+		m := *parsed
+		m.Attachments = append([]Attachment(nil), parsed.Attachments...)
+		m.Headers = make(map[string]string, len(parsed.Headers))
+		for k, v := range parsed.Headers {
+			m.Headers[k] = v
+		}
+		m.ID = newID()
+		m.MailFrom = sess.mailFrom
+		m.Tag = byApp[app][0].Tag
+		m.RemoteAddr = sess.conn.RemoteAddr().String()
+		for _, r := range byApp[app] {
+			m.To = append(m.To, r.Address)
+		}
+		if err := sess.s.Deliver(app, &m); err != nil {
+			debug.Log("inbox", "failed to deliver %s to %s: %v", m.ID, app, err)
+			failed = append(failed, app)
+			continue
+		}
+		ids = append(ids, m.ID)
+	}
+	if len(ids) == 0 {
+		return 451, "Delivery failed, try again later"
+	}
+	if len(failed) > 0 {
+		// Recipients were accepted together; the sender can't retry only
+		// the apps that failed, so they're logged instead
+		debug.Log("inbox", "message %s not delivered to %s", ids[0], strings.Join(failed, ", "))
+	}
+	return 250, "OK " + strings.Join(ids, " ")
+}
+
+// recipient parses an address at the server's domain
+func (s *Server) recipient(addr string) (Recipient, bool) {
+	local, domain, ok := strings.Cut(strings.ToLower(addr), "@")
+	if !ok || domain != strings.ToLower(s.Domain) || local == "" {
+		return Recipient{}, false
+	}
+	app, tag, _ := strings.Cut(local, "+")
+	if app == "" {
+		return Recipient{}, false
+	}
+	return Recipient{Address: strings.ToLower(addr), App: app, Tag: tag}, true
+}
+
+// pathArg parses "FROM:<addr> PARAM=x" after MAIL or "TO:<addr>" after RCPT.
+// The null sender <> is allowed.
+func pathArg(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	fields := strings.Fields(strings.TrimSpace(arg[len(prefix):]))
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	path := fields[0]
+	if !strings.HasPrefix(path, "<") || !strings.HasSuffix(path, ">") {
+		return "", nil, false
+	}
+	addr := path[1 : len(path)-1]
+	if addr != "" {
+		// Source routes (<@a,@b:user@host>) are ignored, as RFC 5321 allows
+		if i := strings.LastIndex(addr, ":"); strings.HasPrefix(addr, "@") && i >= 0 {
+			addr = addr[i+1:]
+		}
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return "", nil, false
+		}
+	}
+	return addr, fields[1:], true
+}
@@ -0,0 +1,363 @@
+// Package mailer sends the email apps send with fazt.app.mail. Messages go
+// to an outbox, a worker job per message sends it with retries, and the
+// outbox stays on as each app's sent-mail log. Apps send through the
+// server's mail server (mail.*) unless their settings name their own, and
+// at most their daily limit of messages per UTC day.
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/config"
+)
+
+// Statuses of a message
+const (
+	StatusQueued = "queued" // Waiting for its first or next attempt
+	StatusSent   = "sent"
+	StatusFailed = "failed" // Rejected, or out of attempts
+)
+
+// MaxAttempts is how many times a message is tried before it's failed
+const MaxAttempts = 5
+
+var (
+	ErrNotConfigured = errors.New("mail: no mail server configured")
+	ErrDailyLimit    = errors.New("mail: daily limit reached")
+	ErrNotFound      = errors.New("mail: message not found")
+)
+
+// Mail is a message in an app's outbox
+type Mail struct {
+	ID        string     `json:"id"`
+	AppID     string     `json:"app_id"`
+	To        []string   `json:"to"`
+	Subject   string     `json:"subject"`
+	HTML      string     `json:"html,omitempty"` // Left out of lists
+	Text      string     `json:"text,omitempty"`
+	ReplyTo   string     `json:"reply_to,omitempty"`
+	Status    string     `json:"status"`
+	Attempts  int        `json:"attempts"`
+	Error     string     `json:"error,omitempty"`
+	JobID     string     `json:"job_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
+
+func (m *Mail) message() Message {
+	return Message{To: m.To, Subject: m.Subject, HTML: m.HTML, Text: m.Text, ReplyTo: m.ReplyTo}
+}
+
+// Settings are an app's own mail server and daily limit. An empty Host
+// sends through the server's mail server.
+type Settings struct {
+	Host        string `json:"host,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"` // Write-only; see HasPassword
+	HasPassword bool   `json:"has_password"`
+	From        string `json:"from,omitempty"`
+	DailyLimit  *int   `json:"daily_limit,omitempty"` // nil follows mail.daily_limit
+}
+
+// GetSettings returns an app's mail settings, nil if it has none
+func GetSettings(db *sql.DB, appID string) (*Settings, error) {
+	var s Settings
+	var limit sql.NullInt64
+	err := db.QueryRow(`
+		SELECT smtp_host, smtp_port, smtp_username, smtp_password, smtp_from, daily_limit
+		FROM app_mail_settings WHERE app_id = ?
+	`, appID).Scan(&s.Host, &s.Port, &s.Username, &s.Password, &s.From, &limit)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit.Valid {
+		n := int(limit.Int64)
+		s.DailyLimit = &n
+	}
+	s.HasPassword = s.Password != ""
+	return &s, nil
+}
+
+// SetSettings replaces an app's mail settings
+func SetSettings(db *sql.DB, appID string, s Settings) error {
+	if s.Port == 0 {
+		s.Port = 587
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		return fmt.Errorf("mail: invalid port %d", s.Port)
+	}
+	if s.DailyLimit != nil && *s.DailyLimit < 0 {
+		return errors.New("mail: daily_limit can't be negative")
+	}
+	if s.Host == "" && (s.Username != "" || s.Password != "" || s.From != "") {
+		return errors.New("mail: host is required with a username, password or from")
+	}
+	var limit interface{}
+	if s.DailyLimit != nil {
+		limit = *s.DailyLimit
+	}
+	_, err := db.Exec(`
+		INSERT OR REPLACE INTO app_mail_settings (app_id, smtp_host, smtp_port, smtp_username, smtp_password, smtp_from, daily_limit, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, appID, s.Host, s.Port, s.Username, s.Password, s.From, limit, time.Now().Unix())
+	return err
+}
+
+// DeleteSettings drops an app's mail settings, so it sends through the
+// server's mail server with the server's limit again
+func DeleteSettings(db *sql.DB, appID string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM app_mail_settings WHERE app_id = ?`, appID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// route returns the server an app sends through and its From address
+func route(db *sql.DB, appID string, cfg config.MailConfig) (Server, string, error) {
+	server := Server{Host: cfg.SMTP.Host, Port: cfg.SMTP.Port, Username: cfg.SMTP.Username, Password: cfg.SMTP.Password}
+	from := cfg.SMTP.From
+	settings, err := GetSettings(db, appID)
+	if err != nil {
+		return Server{}, "", err
+	}
+	if settings != nil && settings.Host != "" {
+		server = Server{Host: settings.Host, Port: settings.Port, Username: settings.Username, Password: settings.Password}
+		from = settings.From
+	}
+	if server.Host == "" {
+		return Server{}, "", ErrNotConfigured
+	}
+	if from == "" {
+		from = server.Username
+	}
+	if from == "" {
+		return Server{}, "", fmt.Errorf("%w: no from address", ErrNotConfigured)
+	}
+	return server, from, nil
+}
+
+// DailyLimit returns how many messages an app may send per UTC day
+func DailyLimit(db *sql.DB, appID string, cfg config.MailConfig) (int, error) {
+	settings, err := GetSettings(db, appID)
+	if err != nil {
+		return 0, err
+	}
+	if settings != nil && settings.DailyLimit != nil {
+		return *settings.DailyLimit, nil
+	}
+	return cfg.DailyLimit, nil
+}
+
+// startOfDay is the UTC midnight daily limits count from
+func startOfDay(now time.Time) int64 {
+	return now.UTC().Truncate(24 * time.Hour).Unix()
+}
+
+// SentToday counts the messages an app queued since UTC midnight, whether
+// they went out or not
+func SentToday(db *sql.DB, appID string) (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM app_mail WHERE app_id = ? AND created_at >= ?`,
+		appID, startOfDay(time.Now())).Scan(&n)
+	return n, err
+}
+
+// Queue adds a message to an app's outbox, if it has a mail server and is
+// under its daily limit. The caller schedules its delivery.
+func Queue(db *sql.DB, appID string, msg Message, cfg config.MailConfig) (*Mail, error) {
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	if _, _, err := route(db, appID, cfg); err != nil {
+		return nil, err
+	}
+	limit, err := DailyLimit(db, appID, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	m := &Mail{
+		ID:        "mail_" + randomHex(8),
+		AppID:     appID,
+		To:        msg.To,
+		Subject:   msg.Subject,
+		HTML:      msg.HTML,
+		Text:      msg.Text,
+		ReplyTo:   msg.ReplyTo,
+		Status:    StatusQueued,
+		CreatedAt: now,
+	}
+	to, _ := json.Marshal(m.To)
+
+	// Counted in the insert, so concurrent sends can't overshoot the limit
+	res, err := db.Exec(`
+		INSERT INTO app_mail (id, app_id, recipients, subject, html, text, reply_to, status, created_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM app_mail WHERE app_id = ? AND created_at >= ?) < ?
+	`, m.ID, appID, string(to), m.Subject, m.HTML, m.Text, m.ReplyTo, m.Status, now.Unix(),
+		appID, startOfDay(now), limit)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w (%d per day)", ErrDailyLimit, limit)
+	}
+	return m, nil
+}
+
+// SetJob records the worker job sending a message
+func SetJob(db *sql.DB, id, jobID string) error {
+	_, err := db.Exec(`UPDATE app_mail SET job_id = ? WHERE id = ?`, jobID, id)
+	return err
+}
+
+// Fail gives up on a queued message, such as one whose job couldn't be
+// queued
+func Fail(db *sql.DB, id string, reason error) error {
+	_, err := db.Exec(`UPDATE app_mail SET status = ?, error = ? WHERE id = ? AND status = ?`,
+		StatusFailed, reason.Error(), id, StatusQueued)
+	return err
+}
+
+// Deliver makes one attempt at sending a queued message, recording the
+// outcome. The message is failed when the server rejects it outright or on
+// its last attempt; otherwise the error is returned for the caller to retry.
+func Deliver(ctx context.Context, db *sql.DB, id string, last bool, cfg config.MailConfig) error {
+	m, err := Get(db, id)
+	if err != nil {
+		return err
+	}
+	if m.Status != StatusQueued {
+		return nil
+	}
+
+	server, from, err := route(db, m.AppID, cfg)
+	if err == nil {
+		err = server.Send(ctx, from, m.To, m.message().compose(m.ID, from))
+	}
+	if err == nil {
+		_, err = db.Exec(`UPDATE app_mail SET status = ?, attempts = attempts + 1, error = NULL, sent_at = ? WHERE id = ?`,
+			StatusSent, time.Now().Unix(), id)
+		return err
+	}
+
+	status := StatusQueued
+	if last || Permanent(err) {
+		status = StatusFailed
+	}
+	if _, dbErr := db.Exec(`UPDATE app_mail SET status = ?, attempts = attempts + 1, error = ? WHERE id = ?`,
+		status, err.Error(), id); dbErr != nil {
+		return dbErr
+	}
+	if status == StatusFailed && !last {
+		// Rejected outright: no point retrying
+		return nil
+	}
+	return err
+}
+
+const mailColumns = `id, app_id, recipients, subject, html, text, reply_to, status, attempts, error, job_id, created_at, sent_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMail(row scanner) (*Mail, error) {
+	var m Mail
+	var to string
+	var errStr, jobID sql.NullString
+	var created int64
+	var sent sql.NullInt64
+	if err := row.Scan(&m.ID, &m.AppID, &to, &m.Subject, &m.HTML, &m.Text, &m.ReplyTo,
+		&m.Status, &m.Attempts, &errStr, &jobID, &created, &sent); err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(to), &m.To)
+	m.Error, m.JobID = errStr.String, jobID.String
+	m.CreatedAt = time.Unix(created, 0)
+	if sent.Valid {
+		t := time.Unix(sent.Int64, 0)
+		m.SentAt = &t
+	}
+	return &m, nil
+}
+
+// Get returns a message with its body
+func Get(db *sql.DB, id string) (*Mail, error) {
+	m, err := scanMail(db.QueryRow(`SELECT `+mailColumns+` FROM app_mail WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return m, err
+}
+
+// List returns an app's messages, newest first, without their bodies.
+// status filters by status when set.
+func List(db *sql.DB, appID, status string, limit int) ([]*Mail, error) {
+	query := `SELECT ` + mailColumns + ` FROM app_mail WHERE app_id = ?`
+	args := []interface{}{appID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY created_at DESC, rowid DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Mail{}
+	for rows.Next() {
+		m, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		m.HTML, m.Text = "", ""
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// Queued returns the messages still waiting to be sent, oldest first, for
+// rescheduling after a restart
+func Queued(db *sql.DB) ([]*Mail, error) {
+	rows, err := db.Query(`SELECT `+mailColumns+` FROM app_mail WHERE status = ? ORDER BY created_at`, StatusQueued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Mail
+	for rows.Next() {
+		m, err := scanMail(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+// ServerConfig returns the server's mail settings, the defaults where the
+// configuration isn't loaded (CLI commands, tests)
+func ServerConfig() config.MailConfig {
+	if config.Loaded() {
+		return config.Get().Mail
+	}
+	return config.CreateDefaultConfig().Mail
+}
@@ -0,0 +1,295 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+// received is a message the fake SMTP server accepted
+type received struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP runs a plain SMTP server on localhost that answers RCPT with
+// rcptCode, returning its config and the messages it accepts
+func fakeSMTP(t *testing.T, rcptCode int) (config.MailConfig, <-chan received) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan received, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(textproto.NewConn(conn), rcptCode, out)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return config.MailConfig{
+		SMTP:       config.SMTPConfig{Host: host, Port: n, From: "apps@example.com"},
+		DailyLimit: 100,
+	}, out
+}
+
+func serveSMTP(c *textproto.Conn, rcptCode int, out chan<- received) {
+	defer c.Close()
+	c.PrintfLine("220 fake ESMTP")
+	var msg received
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			c.PrintfLine("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = received{From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			c.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			if rcptCode != 250 {
+				c.PrintfLine("%d No such user", rcptCode)
+				continue
+			}
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			c.PrintfLine("250 OK")
+		case cmd == "DATA":
+			c.PrintfLine("354 Go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			out <- msg
+			c.PrintfLine("250 Queued")
+		case cmd == "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("250 OK")
+		}
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	msg := Message{To: []string{"Ada <ada@example.com>"}, Subject: " Hi ", HTML: "<p>Hi</p>", ReplyTo: "help@example.com"}
+	if err := msg.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if msg.To[0] != "ada@example.com" || msg.Subject != "Hi" || msg.ReplyTo != "<help@example.com>" {
+		t.Errorf("Not normalized: %+v", msg)
+	}
+
+	bad := []Message{
+		{Subject: "Hi", Text: "Hi"},
+		{To: []string{"not an address"}, Subject: "Hi", Text: "Hi"},
+		{To: []string{"a@example.com"}, Text: "Hi"},
+		{To: []string{"a@example.com"}, Subject: "Hi\r\nBcc: x@example.com", Text: "Hi"},
+		{To: []string{"a@example.com"}, Subject: "Hi"},
+		{To: []string{"a@example.com"}, Subject: "Hi", Text: strings.Repeat("x", MaxBodyBytes+1)},
+		{To: make([]string, MaxRecipients+1), Subject: "Hi", Text: "Hi"},
+	}
+	for _, m := range bad {
+		if err := m.Validate(); err == nil {
+			t.Errorf("Expected %.80v to be invalid", m)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	msg := Message{To: []string{"ada@example.com"}, Subject: "Café", HTML: "<p>Hi</p>", Text: "Hi", ReplyTo: "<help@example.com>"}
+	out := string(msg.compose("mail_1", "apps@example.com"))
+
+	for _, want := range []string{
+		"From: apps@example.com\r\n",
+		"To: ada@example.com\r\n",
+		"Reply-To: <help@example.com>\r\n",
+		"Subject: =?utf-8?q?Caf=C3=A9?=\r\n",
+		"Message-ID: <mail_1@example.com>\r\n",
+		"Content-Type: multipart/alternative;",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "text/plain") > strings.Index(out, "text/html") {
+		t.Error("Expected the text part before the HTML part")
+	}
+
+	// One body is sent as is
+	out = string(Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hi"}.compose("mail_2", "apps@example.com"))
+	if strings.Contains(out, "multipart") || !strings.Contains(out, "Content-Type: text/plain") {
+		t.Errorf("Expected a plain text email:\n%s", out)
+	}
+}
+
+func TestQueueAndDeliver(t *testing.T) {
+	db := dbtest.Open(t)
+	cfg, inbox := fakeSMTP(t, 250)
+
+	m, err := Queue(db, "app1", Message{To: []string{"ada@example.com"}, Subject: "Welcome", HTML: "<p>Hi</p>"}, cfg)
+	if err != nil {
+		t.Fatalf("Queue failed: %v", err)
+	}
+	if !strings.HasPrefix(m.ID, "mail_") || m.Status != StatusQueued {
+		t.Errorf("Unexpected mail: %+v", m)
+	}
+
+	if err := Deliver(context.Background(), db, m.ID, false, cfg); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	got := <-inbox
+	if got.From != "apps@example.com" || len(got.To) != 1 || got.To[0] != "ada@example.com" ||
+		!strings.Contains(got.Data, "Subject: Welcome") {
+		t.Errorf("Unexpected delivery: %+v", got)
+	}
+
+	sent, err := Get(db, m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Status != StatusSent || sent.Attempts != 1 || sent.SentAt == nil || sent.HTML != "<p>Hi</p>" {
+		t.Errorf("Expected the message sent: %+v", sent)
+	}
+
+	// Sent messages aren't sent again
+	if err := Deliver(context.Background(), db, m.ID, false, cfg); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-inbox:
+		t.Error("Expected no second delivery")
+	default:
+	}
+
+	list, err := List(db, "app1", "", 10)
+	if err != nil || len(list) != 1 || list[0].HTML != "" {
+		t.Errorf("Expected the message listed without its body: %+v, %v", list, err)
+	}
+}
+
+func TestDeliverFailures(t *testing.T) {
+	db := dbtest.Open(t)
+	msg := Message{To: []string{"ghost@example.com"}, Subject: "Hi", Text: "Hi"}
+
+	// Rejected outright: failed without retrying
+	cfg, _ := fakeSMTP(t, 550)
+	m, err := Queue(db, "app1", msg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Deliver(context.Background(), db, m.ID, false, cfg); err != nil {
+		t.Errorf("Expected a rejection not to be retried, got %v", err)
+	}
+	if got, _ := Get(db, m.ID); got.Status != StatusFailed || !strings.Contains(got.Error, "No such user") {
+		t.Errorf("Expected the message failed: %+v", got)
+	}
+
+	// Deferred (4xx): retried until the last attempt
+	cfg, _ = fakeSMTP(t, 451)
+	m, err = Queue(db, "app1", msg, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Deliver(context.Background(), db, m.ID, false, cfg); err == nil {
+		t.Error("Expected a temporary failure to be returned for retrying")
+	}
+	if got, _ := Get(db, m.ID); got.Status != StatusQueued || got.Attempts != 1 {
+		t.Errorf("Expected the message still queued: %+v", got)
+	}
+	if err := Deliver(context.Background(), db, m.ID, true, cfg); err == nil {
+		t.Error("Expected the last attempt's error")
+	}
+	if got, _ := Get(db, m.ID); got.Status != StatusFailed || got.Attempts != 2 {
+		t.Errorf("Expected the message failed after its last attempt: %+v", got)
+	}
+}
+
+func TestQueueLimits(t *testing.T) {
+	db := dbtest.Open(t)
+	msg := Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hi"}
+
+	if _, err := Queue(db, "app1", msg, config.MailConfig{DailyLimit: 10}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Expected ErrNotConfigured without a mail server, got %v", err)
+	}
+
+	cfg, _ := fakeSMTP(t, 250)
+	cfg.DailyLimit = 2
+	for i := 0; i < 2; i++ {
+		if _, err := Queue(db, "app1", msg, cfg); err != nil {
+			t.Fatalf("Queue %d failed: %v", i, err)
+		}
+	}
+	if _, err := Queue(db, "app1", msg, cfg); !errors.Is(err, ErrDailyLimit) {
+		t.Errorf("Expected ErrDailyLimit, got %v", err)
+	}
+	// Limits are per app
+	if _, err := Queue(db, "app2", msg, cfg); err != nil {
+		t.Errorf("Expected another app to send, got %v", err)
+	}
+	if n, _ := SentToday(db, "app1"); n != 2 {
+		t.Errorf("SentToday = %d, want 2", n)
+	}
+
+	// An app's own limit wins
+	limit := 3
+	if err := SetSettings(db, "app1", Settings{DailyLimit: &limit}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Queue(db, "app1", msg, cfg); err != nil {
+		t.Errorf("Expected the app's own limit to allow a third message, got %v", err)
+	}
+}
+
+func TestSettingsOverride(t *testing.T) {
+	db := dbtest.Open(t)
+	server, _ := fakeSMTP(t, 250)
+	own, inbox := fakeSMTP(t, 250)
+
+	if err := SetSettings(db, "app1", Settings{Host: own.SMTP.Host, Port: own.SMTP.Port, From: "hello@app.example"}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Queue(db, "app1", Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hi"}, server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Deliver(context.Background(), db, m.ID, false, server); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-inbox; got.From != "hello@app.example" {
+		t.Errorf("Expected the app's own server and sender, got %+v", got)
+	}
+
+	// Credentials need the server they belong to
+	if err := SetSettings(db, "app2", Settings{Username: "x"}); err == nil {
+		t.Error("Expected a username without a host to be refused")
+	}
+
+	if ok, err := DeleteSettings(db, "app1"); !ok || err != nil {
+		t.Errorf("DeleteSettings = %v, %v", ok, err)
+	}
+	if s, _ := GetSettings(db, "app1"); s != nil {
+		t.Errorf("Expected no settings, got %+v", s)
+	}
+}
@@ -0,0 +1,124 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Limits of one message
+const (
+	MaxRecipients = 50
+	MaxSubject    = 998 // The longest line SMTP allows
+	MaxBodyBytes  = 1 << 20
+)
+
+// Message is an email an app sends. It needs a subject and an HTML or text
+// body, or both; clients show the HTML and fall back to the text.
+type Message struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	HTML    string   `json:"html,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	ReplyTo string   `json:"reply_to,omitempty"`
+}
+
+// Validate checks a message, normalizing its addresses
+func (m *Message) Validate() error {
+	if len(m.To) == 0 {
+		return errors.New("mail: to is required")
+	}
+	if len(m.To) > MaxRecipients {
+		return fmt.Errorf("mail: at most %d recipients per message", MaxRecipients)
+	}
+	for i, to := range m.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("mail: invalid recipient %q", to)
+		}
+		m.To[i] = addr.Address
+	}
+	if m.ReplyTo != "" {
+		addr, err := mail.ParseAddress(m.ReplyTo)
+		if err != nil {
+			return fmt.Errorf("mail: invalid replyTo %q", m.ReplyTo)
+		}
+		m.ReplyTo = addr.String()
+	}
+
+	m.Subject = strings.TrimSpace(m.Subject)
+	if m.Subject == "" {
+		return errors.New("mail: subject is required")
+	}
+	if len(m.Subject) > MaxSubject || strings.ContainsAny(m.Subject, "\r\n") {
+		return errors.New("mail: subject must be one line")
+	}
+	if m.HTML == "" && m.Text == "" {
+		return errors.New("mail: html or text is required")
+	}
+	if len(m.HTML)+len(m.Text) > MaxBodyBytes {
+		return fmt.Errorf("mail: body exceeds %d bytes", MaxBodyBytes)
+	}
+	return nil
+}
+
+// compose builds the email for a message: HTML, text, or both as
+// alternatives. id becomes its Message-ID.
+func (m Message) compose(id, from string) []byte {
+	domain := "fazt.local"
+	if _, d, ok := strings.Cut(from, "@"); ok {
+		domain = strings.TrimSuffix(d, ">")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(m.To, ", "))
+	if m.ReplyTo != "" {
+		fmt.Fprintf(&b, "Reply-To: %s\r\n", m.ReplyTo)
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", id, domain)
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	switch {
+	case m.HTML != "" && m.Text != "":
+		boundary := randomHex(12)
+		fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+		// Plainest first: clients show the last part they can
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		writePart(&b, "text/plain", m.Text)
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		writePart(&b, "text/html", m.HTML)
+		fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	case m.HTML != "":
+		writePart(&b, "text/html", m.HTML)
+	default:
+		writePart(&b, "text/plain", m.Text)
+	}
+	return b.Bytes()
+}
+
+// writePart writes a body's headers and its quoted-printable content, which
+// keeps lines short whatever the app sent
+func writePart(b *bytes.Buffer, contentType, body string) {
+	fmt.Fprintf(b, "Content-Type: %s; charset=utf-8\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(b)
+	qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")))
+	qp.Close()
+	b.WriteString("\r\n")
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,327 @@
+package maintenance
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+)
+
+// Run states
+const (
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+var (
+	// ErrRunning is returned when a run is started while another is going
+	ErrRunning = errors.New("maintenance is already running")
+
+	// ErrNothingToDo is returned for a run with no vacuum, pruning or rebuild
+	ErrNothingToDo = errors.New("nothing to do: vacuum, prune or rebuild something")
+
+	// ErrUnknownTarget is returned for a rebuild of something not in RebuildTargets
+	ErrUnknownTarget = errors.New("unknown rebuild target")
+)
+
+// pruneBatch is how many rows each delete removes, so pruning a large table
+// holds the write lock in short turns instead of one long one
+const pruneBatch = 5000
+
+// vacuumPages is how many free pages each incremental vacuum step releases
+const vacuumPages = 2000
+
+// Options choose what a maintenance run does
+type Options struct {
+	Vacuum      bool          // Release free pages and shrink the file
+	PruneEvents time.Duration // Drop analytics events older than this; 0 keeps them
+	PruneLogs   time.Duration // Drop site, activity and egress logs older than this; 0 keeps them
+	Rebuild     []string      // Derived data to regenerate, from RebuildTargets
+}
+
+// Run is the progress of a maintenance run
+type Run struct {
+	ID         string           `json:"id"`
+	State      string           `json:"state"`
+	Step       string           `json:"step"`
+	Progress   float64          `json:"progress"` // 0 to 1 over all steps
+	Vacuum     bool             `json:"vacuum"`
+	Deleted    map[string]int64 `json:"deleted"`           // Rows pruned per table
+	Rebuilt    map[string]int64 `json:"rebuilt,omitempty"` // Events, variants, documents or rows covered per rebuild target
+	Reclaimed  int64            `json:"reclaimed_bytes"`   // Bytes the database file shrank by
+	Error      string           `json:"error,omitempty"`
+	StartedAt  int64            `json:"started_at"`
+	FinishedAt int64            `json:"finished_at,omitempty"`
+}
+
+// pruneTable is a table pruned by age
+type pruneTable struct {
+	table  string
+	column string
+	unix   bool // Column holds unix seconds, not a DATETIME string
+	events bool // Pruned with PruneEvents rather than PruneLogs
+}
+
+var pruneTables = []pruneTable{
+	{table: "events", column: "created_at", events: true},
+	{table: "site_logs", column: "created_at"},
+	{table: "activity_log", column: "timestamp", unix: true},
+	{table: "net_log", column: "created_at", unix: true},
+}
+
+var current = struct {
+	sync.Mutex
+	run *Run
+}{}
+
+// Status returns the current or last run, or nil if there hasn't been one
+func Status() *Run {
+	current.Lock()
+	defer current.Unlock()
+	if current.run == nil {
+		return nil
+	}
+	return current.run.copy()
+}
+
+// Start begins a maintenance run in the background on the database at path
+func Start(db *sql.DB, path string, opts Options) (*Run, error) {
+	if !opts.Vacuum && opts.PruneEvents <= 0 && opts.PruneLogs <= 0 && len(opts.Rebuild) == 0 {
+		return nil, ErrNothingToDo
+	}
+	for _, target := range opts.Rebuild {
+		if !IsRebuildTarget(target) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTarget, target)
+		}
+	}
+
+	current.Lock()
+	defer current.Unlock()
+	if current.run != nil && current.run.State == StateRunning {
+		return nil, ErrRunning
+	}
+
+	id := make([]byte, 6)
+	rand.Read(id)
+	run := &Run{
+		ID:        "mnt_" + hex.EncodeToString(id),
+		State:     StateRunning,
+		Step:      "starting",
+		Vacuum:    opts.Vacuum,
+		Deleted:   make(map[string]int64),
+		Rebuilt:   make(map[string]int64),
+		StartedAt: time.Now().Unix(),
+	}
+	current.run = run
+
+	go func() {
+		err := run.execute(db, path, opts, time.Now())
+		current.Lock()
+		defer current.Unlock()
+		run.FinishedAt = time.Now().Unix()
+		if err != nil {
+			log.Printf("Maintenance %s failed: %v", run.ID, err)
+			run.State = StateFailed
+			run.Error = err.Error()
+			return
+		}
+		run.State = StateDone
+		run.Step = "done"
+		run.Progress = 1
+	}()
+	return run.copy(), nil
+}
+
+func (r *Run) copy() *Run {
+	c := *r
+	c.Deleted = make(map[string]int64, len(r.Deleted))
+	for k, v := range r.Deleted {
+		c.Deleted[k] = v
+	}
+	c.Rebuilt = make(map[string]int64, len(r.Rebuilt))
+	for k, v := range r.Rebuilt {
+		c.Rebuilt[k] = v
+	}
+	return &c
+}
+
+// update changes the run's progress under the lock
+func (r *Run) update(fn func(r *Run)) {
+	current.Lock()
+	defer current.Unlock()
+	fn(r)
+}
+
+func (r *Run) execute(db *sql.DB, path string, opts Options, now time.Time) error {
+	var tables []pruneTable
+	for _, t := range pruneTables {
+		if (t.events && opts.PruneEvents > 0) || (!t.events && opts.PruneLogs > 0) {
+			tables = append(tables, t)
+		}
+	}
+	steps := float64(len(opts.Rebuild) + len(tables))
+	if opts.Vacuum {
+		steps++
+	}
+
+	// Rebuilds go first, so rollups are recounted from events about to be pruned
+	sizeBefore := fileSize(path)
+	for i, target := range opts.Rebuild {
+		base := float64(i) / steps
+		r.update(func(r *Run) { r.Step = "rebuilding " + target })
+		n, err := rebuild(db, target, now, func(done float64) {
+			r.update(func(r *Run) { r.Progress = base + done/steps })
+		})
+		if err != nil {
+			return fmt.Errorf("rebuild %s: %w", target, err)
+		}
+		r.update(func(r *Run) { r.Rebuilt[target] = n })
+	}
+
+	for i, t := range tables {
+		age := opts.PruneLogs
+		if t.events {
+			age = opts.PruneEvents
+		}
+		cutoff := now.Add(-age)
+		if t.events {
+			// Keep events the analytics rollups haven't counted yet
+			var err error
+			if cutoff, err = analytics.PruneCutoff(db, cutoff); err != nil {
+				return fmt.Errorf("prune %s: %w", t.table, err)
+			}
+		}
+		base := float64(len(opts.Rebuild)+i) / steps
+		err := prune(db, t, cutoff, func(deleted, total int64) {
+			r.update(func(r *Run) {
+				r.Step = "pruning " + t.table
+				r.Deleted[t.table] = deleted
+				if total > 0 {
+					r.Progress = base + float64(deleted)/float64(total)/steps
+				}
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("prune %s: %w", t.table, err)
+		}
+	}
+
+	if opts.Vacuum {
+		base := float64(len(opts.Rebuild)+len(tables)) / steps
+		err := vacuum(db, func(step string, done float64) {
+			r.update(func(r *Run) {
+				r.Step = step
+				r.Progress = base + done/steps
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("vacuum: %w", err)
+		}
+	}
+
+	if reclaimed := sizeBefore - fileSize(path); reclaimed > 0 {
+		r.update(func(r *Run) { r.Reclaimed = reclaimed })
+	}
+	return nil
+}
+
+// prune deletes a table's rows from before cutoff in batches, reporting the
+// rows deleted so far and the total to delete
+func prune(db *sql.DB, t pruneTable, cutoff time.Time, progress func(deleted, total int64)) error {
+	var before interface{} = cutoff.UTC().Format("2006-01-02 15:04:05")
+	if t.unix {
+		before = cutoff.Unix()
+	}
+
+	var total int64
+	if err := db.QueryRow(`SELECT COUNT(*) FROM `+t.table+` WHERE `+t.column+` < ?`, before).Scan(&total); err != nil {
+		return err
+	}
+	progress(0, total)
+
+	var deleted int64
+	for deleted < total {
+		res, err := db.Exec(`DELETE FROM `+t.table+` WHERE rowid IN (
+			SELECT rowid FROM `+t.table+` WHERE `+t.column+` < ? LIMIT ?
+		)`, before, pruneBatch)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			break
+		}
+		deleted += n
+		progress(deleted, total)
+	}
+	return nil
+}
+
+// vacuum releases free pages back to the filesystem. Databases created
+// without incremental auto-vacuum are switched to it, which takes one full
+// VACUUM; after that, free pages are released a batch at a time.
+func vacuum(db *sql.DB, progress func(step string, done float64)) error {
+	ctx := context.Background()
+
+	// auto_vacuum only changes on the connection that then runs VACUUM
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, `PRAGMA auto_vacuum`).Scan(&mode); err != nil {
+		return err
+	}
+	if mode != 2 {
+		progress("vacuuming (full, switching to incremental)", 0)
+		if _, err := conn.ExecContext(ctx, `PRAGMA auto_vacuum = INCREMENTAL`); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, `VACUUM`); err != nil {
+			return err
+		}
+	} else {
+		var free int64
+		if err := conn.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&free); err != nil {
+			return err
+		}
+		remaining := free
+		for remaining > 0 {
+			progress("vacuuming", float64(free-remaining)/float64(free))
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA incremental_vacuum(%d)`, vacuumPages)); err != nil {
+				return err
+			}
+			var left int64
+			if err := conn.QueryRowContext(ctx, `PRAGMA freelist_count`).Scan(&left); err != nil {
+				return err
+			}
+			if left >= remaining {
+				break
+			}
+			remaining = left
+		}
+	}
+
+	progress("checkpointing", 1)
+	_, err = conn.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+func fileSize(path string) int64 {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "data.db")
+	db := dbtest.OpenFile(t, path)
+	return db, path
+}
+
+func TestReport(t *testing.T) {
+	db, path := setupTestDB(t)
+
+	db.Exec(`INSERT INTO files (site_id, path, content, size_bytes, hash) VALUES ('blog', 'index.html', ?, 1000, 'h')`, strings.Repeat("x", 1000))
+	db.Exec(`INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash) VALUES ('blog', 'photo.jpg', ?, 'image/jpeg', 400, 'h')`, strings.Repeat("x", 400))
+	db.Exec(`INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash) VALUES ('blog', 'uploads/_media/photo-w200.jpg', ?, 'image/jpeg', 100, 'h')`, strings.Repeat("x", 100))
+	db.Exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('notes', 'k', ?)`, strings.Repeat("x", 50))
+	db.Exec(`INSERT INTO site_logs (site_id, level, message) VALUES ('notes', 'info', 'hello')`)
+
+	r, err := Report(db, path)
+	if err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+	if r.FileBytes == 0 {
+		t.Error("Expected the database file size")
+	}
+	if len(r.Tables) == 0 {
+		t.Fatal("Expected table sizes")
+	}
+	for _, tbl := range r.Tables {
+		if tbl.Name == "files" && (tbl.Rows != 1 || tbl.Category != CategoryFiles) {
+			t.Errorf("Expected 1 row in files, got %+v", tbl)
+		}
+	}
+
+	if len(r.Apps) != 2 || r.Apps[0].App != "blog" {
+		t.Fatalf("Expected blog then notes, got %+v", r.Apps)
+	}
+	blog := r.Apps[0]
+	if blog.Files != 1000 || blog.Blobs != 400 || blog.MediaCache != 100 || blog.Total != 1500 {
+		t.Errorf("Unexpected blog sizes: %+v", blog)
+	}
+	notes := r.Apps[1]
+	if notes.Data != 50 || notes.Logs != 5 {
+		t.Errorf("Unexpected notes sizes: %+v", notes)
+	}
+}
+
+func wait(t *testing.T) *Run {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if run := Status(); run != nil && run.State != StateRunning {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Maintenance didn't finish")
+	return nil
+}
+
+func TestStartPrunesAndVacuums(t *testing.T) {
+	db, path := setupTestDB(t)
+
+	old := time.Now().Add(-100 * 24 * time.Hour)
+	for i := 0; i < 20; i++ {
+		db.Exec(`INSERT INTO events (domain, source_type, event_type, path, created_at) VALUES ('blog', 'web', 'pageview', ?, ?)`,
+			strings.Repeat("p", 2000), old.UTC().Format("2006-01-02 15:04:05"))
+	}
+	db.Exec(`INSERT INTO events (domain, source_type, event_type) VALUES ('blog', 'web', 'pageview')`)
+	db.Exec(`INSERT INTO activity_log (timestamp, resource_type, action) VALUES (?, 'app', 'deploy')`, old.Unix())
+	db.Exec(`INSERT INTO activity_log (resource_type, action) VALUES ('app', 'deploy')`)
+
+	// Events are only pruned once the analytics rollups have counted them
+	for i := 0; i < 4; i++ {
+		if err := analytics.Rollup(db, time.Now()); err != nil {
+			t.Fatalf("Rollup failed: %v", err)
+		}
+	}
+
+	if _, err := Start(db, path, Options{}); err != ErrNothingToDo {
+		t.Errorf("Expected ErrNothingToDo, got %v", err)
+	}
+
+	run, err := Start(db, path, Options{Vacuum: true, PruneEvents: 90 * 24 * time.Hour, PruneLogs: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if run.State != StateRunning || run.ID == "" {
+		t.Errorf("Expected a running run, got %+v", run)
+	}
+
+	run = wait(t)
+	if run.State != StateDone {
+		t.Fatalf("Expected done, got %+v", run)
+	}
+	if run.Deleted["events"] != 20 || run.Deleted["activity_log"] != 1 {
+		t.Errorf("Expected 20 events and 1 activity entry pruned, got %v", run.Deleted)
+	}
+	if run.Progress != 1 {
+		t.Errorf("Expected progress 1, got %v", run.Progress)
+	}
+
+	var events, mode int
+	db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&events)
+	if events != 1 {
+		t.Errorf("Expected the recent event kept, got %d events", events)
+	}
+	db.QueryRow(`PRAGMA auto_vacuum`).Scan(&mode)
+	if mode != 2 {
+		t.Errorf("Expected incremental auto-vacuum after the run, got %d", mode)
+	}
+
+	// Incremental from now on
+	if _, err := Start(db, path, Options{Vacuum: true}); err != nil {
+		t.Fatalf("Second start failed: %v", err)
+	}
+	if run := wait(t); run.State != StateDone {
+		t.Errorf("Expected the incremental vacuum done, got %+v", run)
+	}
+}
+
+func TestStartRebuilds(t *testing.T) {
+	db, path := setupTestDB(t)
+
+	// Cached variants go; originals and user blobs named like them stay
+	for _, p := range []string{"_media/ab12/w200", "_media/static/cd34/w200", "u/ann/_media/ef56/w200", "photo.jpg", "u/ann/photos/_media/x.jpg"} {
+		db.Exec(`INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash) VALUES ('blog', ?, 'x', 'image/jpeg', 1, 'h')`, p)
+	}
+
+	// An index that lost its rows
+	db.Exec(`INSERT INTO app_doc_search (app_id, collection, fields) VALUES ('blog', 'posts', '["title"]')`)
+	db.Exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('blog', 'posts', 'p1', '{"title":"Sourdough"}')`)
+	db.Exec(`DELETE FROM app_docs_fts`)
+	db.Exec(`DELETE FROM app_doc_search_rows`)
+
+	// Usage metered under a subdomain before it routed to an app
+	db.Exec(`INSERT INTO apps (id, title) VALUES ('app_blog', 'blog')`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('blog', 'app', '{"app_id":"app_blog"}')`)
+	db.Exec(`INSERT INTO app_usage (app, day, requests) VALUES ('blog', ?, 3), ('app_blog', ?, 2)`,
+		time.Now().UTC().Format("2006-01-02"), time.Now().UTC().Format("2006-01-02"))
+
+	if _, err := Start(db, path, Options{Rebuild: []string{"search-index", "bogus"}}); !errors.Is(err, ErrUnknownTarget) {
+		t.Fatalf("Expected ErrUnknownTarget, got %v", err)
+	}
+	if _, err := Start(db, path, Options{Rebuild: RebuildTargets}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	run := wait(t)
+	if run.State != StateDone || run.Progress != 1 {
+		t.Fatalf("Expected done, got %+v", run)
+	}
+	if run.Rebuilt[RebuildMediaCache] != 3 || run.Rebuilt[RebuildSearchIndex] != 1 || run.Rebuilt[RebuildUsage] != 1 {
+		t.Errorf("Unexpected rebuild counts: %v", run.Rebuilt)
+	}
+
+	var blobs, matches, requests int
+	db.QueryRow(`SELECT COUNT(*) FROM app_blobs`).Scan(&blobs)
+	if blobs != 2 {
+		t.Errorf("Expected the 2 original blobs kept, got %d", blobs)
+	}
+	db.QueryRow(`SELECT COUNT(*) FROM app_docs_fts WHERE app_docs_fts MATCH 'sourdough'`).Scan(&matches)
+	if matches != 1 {
+		t.Errorf("Expected the document reindexed, got %d matches", matches)
+	}
+	db.QueryRow(`SELECT requests FROM app_usage WHERE app = 'app_blog'`).Scan(&requests)
+	if requests != 5 {
+		t.Errorf("Expected the subdomain's usage merged into the app's, got %d requests", requests)
+	}
+}
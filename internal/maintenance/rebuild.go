@@ -0,0 +1,73 @@
+package maintenance
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/analytics"
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+// Rebuild targets: derived data regenerated from its source after a bug or
+// schema change miscounted or corrupted it
+const (
+	RebuildAnalyticsRollups = "analytics-rollups" // Hourly, daily, visitor and geo rollups, from raw events
+	RebuildMediaCache       = "media-cache"       // Cached image variants, thumbnails and waveforms, made again on request
+	RebuildSearchIndex      = "search-index"      // Full-text indexes, from the documents of collections with search on
+	RebuildUsage            = "usage"             // Daily cost rows, moved from subdomains to the apps they route to
+)
+
+// RebuildTargets lists every rebuild target, in the order a run rebuilds them
+var RebuildTargets = []string{RebuildAnalyticsRollups, RebuildMediaCache, RebuildSearchIndex, RebuildUsage}
+
+// IsRebuildTarget reports whether name is one of RebuildTargets
+func IsRebuildTarget(name string) bool {
+	for _, t := range RebuildTargets {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rebuild regenerates a target's derived data, reporting progress from 0 to
+// 1, and returns how many events, variants, documents or rows it covered
+func rebuild(db *sql.DB, target string, now time.Time, progress func(done float64)) (int64, error) {
+	ctx := context.Background()
+	switch target {
+	case RebuildAnalyticsRollups:
+		return analytics.RebuildRollups(db, now, progress)
+
+	case RebuildMediaCache:
+		return media.PurgeCache(ctx, db, func(deleted, total int64) {
+			if total > 0 {
+				progress(float64(deleted) / float64(total))
+			}
+		})
+
+	case RebuildSearchIndex:
+		return storage.RebuildSearchIndex(ctx, db, func(done, total int) {
+			if total > 0 {
+				progress(float64(done) / float64(total))
+			}
+		})
+
+	case RebuildUsage:
+		// Requests are metered as they're served, so usage can't be
+		// recounted; what's pending is written first so none is missed
+		if err := usage.Flush(db); err != nil {
+			return 0, err
+		}
+		moved, err := usage.Reattribute(db)
+		if err != nil {
+			return moved, err
+		}
+		progress(0.5)
+		return moved, usage.Prune(db, now)
+	}
+	return 0, fmt.Errorf("unknown rebuild target %q", target)
+}
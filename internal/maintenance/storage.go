@@ -0,0 +1,287 @@
+// Package maintenance reports where the database's space goes, by table and
+// by app, and runs retention pruning and incremental vacuum in the
+// background so a long run never holds up requests.
+package maintenance
+
+import (
+	"database/sql"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Storage categories
+const (
+	CategoryFiles      = "files"       // Deployed site files
+	CategoryBlobs      = "blobs"       // App blob storage
+	CategoryMediaCache = "media_cache" // Resized and transcoded media variants
+	CategoryData       = "data"        // App KV and document storage
+	CategoryEvents     = "events"      // Analytics events
+	CategoryLogs       = "logs"        // Site, activity, egress and job logs
+	CategoryOther      = "other"
+)
+
+// mediaCachePrefix marks media variants in app_blobs (see services/media)
+const mediaCachePrefix = "_media/"
+
+// tableCategories sorts tables into categories; unlisted tables are other.
+// Blobs and media cache share app_blobs and are split by path.
+var tableCategories = map[string]string{
+	"files":                CategoryFiles,
+	"app_blobs":            CategoryBlobs,
+	"app_kv":               CategoryData,
+	"app_docs":             CategoryData,
+	"app_docs_fts_data":    CategoryData,
+	"app_docs_fts_content": CategoryData,
+	"app_doc_search_rows":  CategoryData,
+	"events":               CategoryEvents,
+	"site_logs":            CategoryLogs,
+	"activity_log":         CategoryLogs,
+	"net_log":              CategoryLogs,
+	"status_checks":        CategoryLogs,
+	"worker_jobs":          CategoryLogs,
+	"dead_letter":          CategoryLogs,
+}
+
+// StorageReport breaks down the database's size
+type StorageReport struct {
+	Path       string           `json:"path"`
+	FileBytes  int64            `json:"file_bytes"`  // Main database file
+	WALBytes   int64            `json:"wal_bytes"`   // Write-ahead log not yet checkpointed
+	FreeBytes  int64            `json:"free_bytes"`  // Free pages a vacuum would reclaim
+	AutoVacuum string           `json:"auto_vacuum"` // none, full or incremental
+	Estimated  bool             `json:"estimated"`   // Table sizes from content length; page stats unavailable
+	Tables     []TableSize      `json:"tables"`      // Largest first
+	Categories map[string]int64 `json:"categories"`  // Bytes per category
+	Apps       []AppSize        `json:"apps"`        // Largest first
+}
+
+// TableSize is a table's rows and bytes, indexes included
+type TableSize struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Rows     int64  `json:"rows"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// AppSize is what one app stores, by category
+type AppSize struct {
+	App        string `json:"app"`
+	Files      int64  `json:"files"`
+	Blobs      int64  `json:"blobs"`
+	MediaCache int64  `json:"media_cache"`
+	Data       int64  `json:"data"`
+	Logs       int64  `json:"logs"`
+	Total      int64  `json:"total"`
+}
+
+// Report measures the database at path
+func Report(db *sql.DB, path string) (*StorageReport, error) {
+	r := &StorageReport{Path: path, Categories: make(map[string]int64)}
+	if fi, err := os.Stat(path); err == nil {
+		r.FileBytes = fi.Size()
+	}
+	if fi, err := os.Stat(path + "-wal"); err == nil {
+		r.WALBytes = fi.Size()
+	}
+
+	var pageSize, freePages, autoVacuum int64
+	if err := db.QueryRow(`PRAGMA page_size`).Scan(&pageSize); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(`PRAGMA freelist_count`).Scan(&freePages); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(`PRAGMA auto_vacuum`).Scan(&autoVacuum); err != nil {
+		return nil, err
+	}
+	r.FreeBytes = freePages * pageSize
+	r.AutoVacuum = autoVacuumModes[autoVacuum]
+
+	tables, estimated, err := tableSizes(db)
+	if err != nil {
+		return nil, err
+	}
+	r.Estimated = estimated
+
+	apps, mediaBytes, err := appSizes(db)
+	if err != nil {
+		return nil, err
+	}
+	r.Apps = apps
+
+	for _, t := range tables {
+		r.Categories[t.Category] += t.Bytes
+	}
+	// Media variants live in app_blobs; move their share out of blobs
+	if blobs := r.Categories[CategoryBlobs]; blobs > 0 {
+		media := min(mediaBytes, blobs)
+		r.Categories[CategoryMediaCache] = media
+		r.Categories[CategoryBlobs] = blobs - media
+	}
+	r.Tables = tables
+	return r, nil
+}
+
+var autoVacuumModes = map[int64]string{0: "none", 1: "full", 2: "incremental"}
+
+// tableSizes returns each table's row count and size. Sizes come from the
+// dbstat virtual table when SQLite has it, and are estimated otherwise.
+func tableSizes(db *sql.DB) ([]TableSize, bool, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, false, err
+	}
+	var tables []TableSize
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		category, ok := tableCategories[name]
+		if !ok {
+			category = CategoryOther
+		}
+		tables = append(tables, TableSize{Name: name, Category: category})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Without dbstat, estimate from the columns that hold the bytes
+	pages, pagesErr := pageBytes(db)
+	estimated := pagesErr != nil
+	for i := range tables {
+		t := &tables[i]
+		if err := db.QueryRow(`SELECT COUNT(*) FROM "` + strings.ReplaceAll(t.Name, `"`, `""`) + `"`).Scan(&t.Rows); err != nil {
+			return nil, false, err
+		}
+		if estimated {
+			t.Bytes = estimateBytes(db, t.Name)
+		} else {
+			t.Bytes = pages[t.Name]
+		}
+	}
+
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].Bytes != tables[j].Bytes {
+			return tables[i].Bytes > tables[j].Bytes
+		}
+		return tables[i].Name < tables[j].Name
+	})
+	return tables, estimated, nil
+}
+
+// pageBytes sums the pages of each table and its indexes from dbstat
+func pageBytes(db *sql.DB) (map[string]int64, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(m.tbl_name, s.name), SUM(s.pgsize)
+		FROM dbstat s LEFT JOIN sqlite_master m ON m.name = s.name
+		GROUP BY 1
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pages := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var bytes int64
+		if err := rows.Scan(&name, &bytes); err != nil {
+			return nil, err
+		}
+		pages[name] = bytes
+	}
+	return pages, rows.Err()
+}
+
+// estimatedColumns are the columns that hold nearly all of a table's bytes
+var estimatedColumns = map[string]string{
+	"files":        "COALESCE(length(content), 0)",
+	"app_blobs":    "size_bytes",
+	"app_kv":       "COALESCE(length(value), 0)",
+	"app_docs":     "length(data)",
+	"events":       "length(COALESCE(path, '') || COALESCE(referrer, '') || COALESCE(user_agent, '') || COALESCE(query_params, '')) + 64",
+	"site_logs":    "length(message) + 32",
+	"activity_log": "COALESCE(length(details), 0) + 128",
+	"net_log":      "length(path) + 64",
+}
+
+func estimateBytes(db *sql.DB, table string) int64 {
+	expr, ok := estimatedColumns[table]
+	if !ok {
+		return 0
+	}
+	var bytes int64
+	db.QueryRow(`SELECT COALESCE(SUM(` + expr + `), 0) FROM ` + table).Scan(&bytes)
+	return bytes
+}
+
+// appSizes sums what each app stores, and the bytes of media variants
+// across all apps
+func appSizes(db *sql.DB) ([]AppSize, int64, error) {
+	apps := make(map[string]*AppSize)
+	get := func(id string) *AppSize {
+		a, ok := apps[id]
+		if !ok {
+			a = &AppSize{App: id}
+			apps[id] = a
+		}
+		return a
+	}
+
+	queries := []struct {
+		query string
+		add   func(a *AppSize, n int64)
+	}{
+		{`SELECT site_id, SUM(size_bytes) FROM files GROUP BY site_id`,
+			func(a *AppSize, n int64) { a.Files += n }},
+		{`SELECT app_id, SUM(size_bytes) FROM app_blobs WHERE instr(path, '` + mediaCachePrefix + `') = 0 GROUP BY app_id`,
+			func(a *AppSize, n int64) { a.Blobs += n }},
+		{`SELECT app_id, SUM(size_bytes) FROM app_blobs WHERE instr(path, '` + mediaCachePrefix + `') > 0 GROUP BY app_id`,
+			func(a *AppSize, n int64) { a.MediaCache += n }},
+		{`SELECT app_id, SUM(length(value)) FROM app_kv GROUP BY app_id`,
+			func(a *AppSize, n int64) { a.Data += n }},
+		{`SELECT app_id, SUM(length(data)) FROM app_docs GROUP BY app_id`,
+			func(a *AppSize, n int64) { a.Data += n }},
+		{`SELECT site_id, SUM(length(message)) FROM site_logs GROUP BY site_id`,
+			func(a *AppSize, n int64) { a.Logs += n }},
+	}
+	for _, q := range queries {
+		rows, err := db.Query(q.query)
+		if err != nil {
+			return nil, 0, err
+		}
+		for rows.Next() {
+			var id string
+			var n sql.NullInt64
+			if err := rows.Scan(&id, &n); err != nil {
+				rows.Close()
+				return nil, 0, err
+			}
+			q.add(get(id), n.Int64)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var media int64
+	list := make([]AppSize, 0, len(apps))
+	for _, a := range apps {
+		a.Total = a.Files + a.Blobs + a.MediaCache + a.Data + a.Logs
+		media += a.MediaCache
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Total != list[j].Total {
+			return list[i].Total > list[j].Total
+		}
+		return list[i].App < list[j].App
+	})
+	return list, media, nil
+}
@@ -27,8 +27,12 @@ func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 				token := strings.TrimPrefix(authHeader, "Bearer ")
 				db := database.GetDB()
 				if db != nil {
-					_, _, err := hosting.ValidateAPIKey(db, token)
+					key, err := hosting.AuthenticateAPIKey(db, token)
 					if err == nil {
+						if !key.Allows(r.Method, r.URL.Path) {
+							scopeDenied(w, r, key)
+							return
+						}
 						// Token is valid
 						next.ServeHTTP(w, r)
 						return
@@ -84,7 +88,9 @@ func requiresAuth(path string) bool {
 		"/assets/",
 		"/workbox-",
 		"/api/login",
+		"/api/2fa", // Session or pending login challenge, checked by the handler
 		"/api/deploy",
+		"/api/types/", // fazt.d.ts for app editors
 		"/auth/login",
 		"/auth/",
 	}
@@ -130,6 +136,32 @@ func AdminMiddleware(authService *auth.Service) func(http.Handler) http.Handler
 	}
 }
 
+// APIKeyScope rejects Bearer requests whose API key scope does not allow the
+// method and path. Other requests pass through to the handler's own auth.
+func APIKeyScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			if db := database.GetDB(); db != nil {
+				key, err := hosting.AuthenticateAPIKey(db, strings.TrimPrefix(authHeader, "Bearer "))
+				if err == nil && !key.Allows(r.Method, r.URL.Path) {
+					scopeDenied(w, r, key)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scopeDenied rejects a request outside the API key's scope
+func scopeDenied(w http.ResponseWriter, r *http.Request, key *hosting.APIKey) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte(`{"error":"API key scope does not allow this request","scope":"` + key.Scope + `"}`))
+	log.Printf("Access denied: API key %q (scope: %s) attempted %s %s", key.Name, key.Scope, r.Method, r.URL.Path)
+}
+
 // redirectToLogin redirects the user to the login page
 func redirectToLogin(w http.ResponseWriter, r *http.Request) {
 	// For API requests, return 401 Unauthorized
@@ -44,6 +44,10 @@ func setupAuthMiddlewareDB(t *testing.T) *sql.DB {
 		created_at INTEGER NOT NULL DEFAULT (unixepoch()),
 		expires_at INTEGER NOT NULL,
 		last_seen INTEGER,
+		impersonator_id TEXT,
+		user_agent TEXT,
+		ip TEXT,
+		host TEXT,
 		FOREIGN KEY (user_id) REFERENCES auth_users(id) ON DELETE CASCADE
 	);
 	CREATE TABLE IF NOT EXISTS api_keys (
@@ -52,7 +56,11 @@ func setupAuthMiddlewareDB(t *testing.T) *sql.DB {
 		key_hash TEXT NOT NULL,
 		scopes TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		last_used_at DATETIME
+		last_used_at DATETIME,
+		user_id TEXT,
+		app_id TEXT,
+		expires_at DATETIME,
+		revoked_at DATETIME
 	);
 	`
 	if _, err := db.Exec(schema); err != nil {
@@ -191,6 +199,75 @@ func TestAuthMiddleware_BearerToken_Invalid(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_BearerToken_Scope(t *testing.T) {
+	db, service := setupAuthMiddlewareEnv(t)
+	apiToken, err := hosting.CreateScopedAPIKey(db, hosting.APIKeyOptions{Name: "reader", Scope: hosting.ScopeRead})
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/api/apps", http.StatusOK},
+		{"DELETE", "/api/apps/app_x", http.StatusForbidden},
+		{"POST", "/api/deploy", http.StatusOK}, // public path, handler enforces scope
+		{"GET", "/api/keys", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+apiToken)
+		rr := httptest.NewRecorder()
+
+		AuthMiddleware(service)(handler).ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestAPIKeyScope(t *testing.T) {
+	db, _ := setupAuthMiddlewareEnv(t)
+	apiToken, err := hosting.CreateScopedAPIKey(db, hosting.APIKeyOptions{Name: "ci", Scope: hosting.ScopeDeploy})
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+
+	handler := APIKeyScope(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"POST", "/api/deploy", apiToken, http.StatusOK},
+		{"POST", "/api/cmd", apiToken, http.StatusForbidden},
+		{"PUT", "/api/aliases/blog", apiToken, http.StatusForbidden},
+		{"POST", "/api/cmd", "invalid-token", http.StatusOK}, // handler rejects unknown keys
+		{"POST", "/api/cmd", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rr.Code, tt.want)
+		}
+	}
+}
+
 func TestAuthMiddleware_EmptyBearerHeader(t *testing.T) {
 	_, service := setupAuthMiddlewareEnv(t)
 
@@ -470,6 +547,7 @@ func TestRequiresAuth_PublicPrefixes(t *testing.T) {
 		"/workbox-123.js",
 		"/api/login",
 		"/api/deploy",
+		"/api/types/fazt.d.ts",
 		"/auth/login",
 		"/auth/callback",
 	}
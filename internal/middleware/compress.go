@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// minCompressSize is the smallest response worth compressing. Responses
+// without a Content-Length are compressed regardless.
+const minCompressSize = 1024
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	},
+}
+
+// Compress gzips compressible responses for clients that accept it.
+// Responses that already carry a Content-Encoding (such as precompressed VFS
+// variants) pass through untouched, as do range requests and upgrades.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			accepts:        hosting.AcceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip"),
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter decides at WriteHeader whether to compress the body
+type compressWriter struct {
+	http.ResponseWriter
+	accepts     bool
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") == "" && hosting.IsCompressible(h.Get("Content-Type")) {
+		addVary(h, "Accept-Encoding")
+		if cw.shouldCompress(code, h) {
+			h.Set("Content-Encoding", "gzip")
+			h.Del("Content-Length")
+			// The compressed body is a different representation; a weak
+			// ETag still revalidates against the original
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			cw.gz = gzipWriters.Get().(*gzip.Writer)
+			cw.gz.Reset(cw.ResponseWriter)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) shouldCompress(code int, h http.Header) bool {
+	if !cw.accepts || code < 200 || code == http.StatusNoContent ||
+		code == http.StatusNotModified || code == http.StatusPartialContent {
+		return false
+	}
+	// Responses that must reach the client byte for byte (S3 objects,
+	// whose ETag is their hash)
+	if strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < minCompressSize {
+			return false
+		}
+	}
+	return true
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gz != nil {
+		return cw.gz.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher, flushing buffered compressed data first
+func (cw *compressWriter) Flush() {
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker for WebSocket support
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("underlying ResponseWriter does not implement http.Hijacker")
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the gzip stream and returns the writer to the pool
+func (cw *compressWriter) Close() {
+	if cw.gz == nil {
+		return
+	}
+	cw.gz.Close()
+	cw.gz.Reset(io.Discard)
+	gzipWriters.Put(cw.gz)
+	cw.gz = nil
+}
+
+// addVary adds a field to the Vary header unless it's already listed
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"hello":"world"}`, 200)
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		io.WriteString(w, body)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip, got %q", w.Header().Get("Content-Encoding"))
+	}
+	if w.Header().Get("ETag") != `W/"v1"` || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Expected weak ETag and Vary, got %q %q", w.Header().Get("ETag"), w.Header().Get("Vary"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip body: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Error("Decompressed body doesn't match")
+	}
+
+	// Clients that don't accept gzip get identity, still with Vary
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != body || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("Expected identity response with Vary")
+	}
+}
+
+func TestCompressSkips(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		encoding    string
+		length      string
+	}{
+		{"image", "image/png", "", ""},
+		{"already encoded", "text/javascript", "br", ""},
+		{"small", "text/html", "", "10"},
+		{"event stream", "text/event-stream", "", ""},
+	}
+	for _, tt := range tests {
+		handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			if tt.encoding != "" {
+				w.Header().Set("Content-Encoding", tt.encoding)
+			}
+			if tt.length != "" {
+				w.Header().Set("Content-Length", tt.length)
+			}
+			io.WriteString(w, "0123456789")
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Header().Get("Content-Encoding") != tt.encoding || w.Body.String() != "0123456789" {
+			t.Errorf("%s: expected response untouched, got encoding %q", tt.name, w.Header().Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/auth"
+)
+
+func TestCSRF(t *testing.T) {
+	_, service := setupAuthMiddlewareEnv(t)
+	user := createTestUser(t, service, "admin@example.com", "admin")
+	session := createTestSession(t, service, user.ID)
+	token := auth.CSRFToken(session)
+
+	handler := CSRF(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		setup  func(r *http.Request)
+		want   int
+	}{
+		{"safe method", "GET", "/api/apps", nil, http.StatusOK},
+		{"missing token", "POST", "/api/apps", nil, http.StatusForbidden},
+		{"wrong token", "DELETE", "/api/apps/x", func(r *http.Request) {
+			r.Header.Set(auth.CSRFHeader, auth.CSRFToken("other-session"))
+		}, http.StatusForbidden},
+		{"header token", "DELETE", "/api/apps/x", func(r *http.Request) {
+			r.Header.Set(auth.CSRFHeader, token)
+		}, http.StatusOK},
+		{"planted cookie alone", "POST", "/api/apps", func(r *http.Request) {
+			r.AddCookie(&http.Cookie{Name: auth.CSRFCookieName, Value: "planted"})
+			r.Header.Set(auth.CSRFHeader, "planted")
+		}, http.StatusForbidden},
+		{"bearer request", "POST", "/api/apps", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer key")
+		}, http.StatusOK},
+		{"exempt path", "POST", "/track", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session})
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCSRF_FormField(t *testing.T) {
+	_, service := setupAuthMiddlewareEnv(t)
+	user := createTestUser(t, service, "admin@example.com", "admin")
+	session := createTestSession(t, service, user.ID)
+
+	handler := CSRF(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	form := url.Values{"_csrf": {auth.CSRFToken(session)}}
+	req := httptest.NewRequest("POST", "/api/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the form token accepted, got %d", rr.Code)
+	}
+}
+
+func TestCSRF_IssuesCookie(t *testing.T) {
+	_, service := setupAuthMiddlewareEnv(t)
+	user := createTestUser(t, service, "admin@example.com", "admin")
+	session := createTestSession(t, service, user.ID)
+
+	handler := CSRF(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/apps", nil)
+	req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: session})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == auth.CSRFCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value != auth.CSRFToken(session) || cookie.HttpOnly || cookie.Domain != "" {
+		t.Fatalf("Expected a readable host-only CSRF cookie, got %+v", cookie)
+	}
+
+	// No session, no cookie: the handler's own auth answers
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/apps", nil))
+	if rr.Code != http.StatusOK || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("Expected an anonymous request passed through, got %d %v", rr.Code, rr.Result().Cookies())
+	}
+}
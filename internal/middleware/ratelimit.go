@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"math"
 	"net"
 	"net/http"
 	"strconv"
@@ -8,6 +9,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fazt-sh/fazt/internal/api"
+	"github.com/fazt-sh/fazt/internal/realip"
+
 	"golang.org/x/time/rate"
 )
 
@@ -65,22 +69,36 @@ func (rl *RateLimiter) cleanupLoop() {
 
 // Allow checks if a request from the given IP should be allowed.
 func (rl *RateLimiter) Allow(ip string) bool {
+	ok, _ := rl.Reserve(ip)
+	return ok
+}
+
+// Reserve takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (rl *RateLimiter) Reserve(key string) (bool, time.Duration) {
+	refund, wait := rl.reserve(key)
+	return refund != nil, wait
+}
+
+// reserve takes a token and returns a func that puts it back, or nil and
+// the wait when the bucket is empty.
+func (rl *RateLimiter) reserve(key string) (refund func(), wait time.Duration) {
 	// Fast path: check if limiter exists with read lock
 	rl.mu.RLock()
-	client, exists := rl.limiters[ip]
+	client, exists := rl.limiters[key]
 	rl.mu.RUnlock()
 
 	if !exists {
 		// Slow path: create new limiter with write lock
 		rl.mu.Lock()
 		// Double-check after acquiring write lock
-		client, exists = rl.limiters[ip]
+		client, exists = rl.limiters[key]
 		if !exists {
 			client = &clientLimiter{
 				limiter:  rate.NewLimiter(rl.rate, rl.burst),
 				lastSeen: time.Now(),
 			}
-			rl.limiters[ip] = client
+			rl.limiters[key] = client
 		}
 		rl.mu.Unlock()
 	}
@@ -88,19 +106,25 @@ func (rl *RateLimiter) Allow(ip string) bool {
 	// Update lastSeen (atomic operation on limiter is safe)
 	client.lastSeen = time.Now()
 
-	return client.limiter.Allow()
+	now := time.Now()
+	r := client.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return nil, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return nil, delay
+	}
+	return func() { r.CancelAt(now) }, 0
 }
 
 // Middleware returns an HTTP middleware that enforces rate limiting.
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := extractIP(r)
+		ip := realip.FromRequest(r)
 
-		if !rl.Allow(ip) {
-			w.Header().Set("Retry-After", "1")
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rl.burst))
-			w.Header().Set("X-RateLimit-Remaining", "0")
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		if ok, wait := rl.Reserve(ip); !ok {
+			tooManyRequests(w, r, rl.burst, wait)
 			return
 		}
 
@@ -108,6 +132,111 @@ func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	})
 }
 
+// Limits configures TrafficLimiter. Rates are requests per second; a zero
+// rate disables that limit.
+type Limits struct {
+	IPRate    rate.Limit // Site requests per client IP
+	IPBurst   int
+	SiteRate  rate.Limit // Requests per site (host), all clients combined
+	SiteBurst int
+	APIRate   rate.Limit // /api/* requests per client IP, instead of the site limits
+	APIBurst  int
+}
+
+// TrafficLimiter rate limits site traffic per client IP and per site, and
+// API traffic per client IP with its own budget, so a flood against one
+// site can't starve the others and API clients don't share a bucket with
+// page loads.
+type TrafficLimiter struct {
+	ip   *RateLimiter
+	site *RateLimiter
+	api  *RateLimiter
+}
+
+// NewTrafficLimiter creates a limiter enforcing l
+func NewTrafficLimiter(l Limits) *TrafficLimiter {
+	newLimiter := func(r rate.Limit, burst int) *RateLimiter {
+		if r <= 0 {
+			return nil
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		return NewRateLimiter(r, burst)
+	}
+	return &TrafficLimiter{
+		ip:   newLimiter(l.IPRate, l.IPBurst),
+		site: newLimiter(l.SiteRate, l.SiteBurst),
+		api:  newLimiter(l.APIRate, l.APIBurst),
+	}
+}
+
+// Middleware returns an HTTP middleware that enforces the limits
+func (tl *TrafficLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := realip.FromRequest(r)
+
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			if _, ok := tl.take(w, r, tl.api, ip); !ok {
+				return
+			}
+		} else {
+			refund, ok := tl.take(w, r, tl.ip, ip)
+			if !ok {
+				return
+			}
+			// A request refused by its site doesn't use up the client's budget
+			if _, ok := tl.take(w, r, tl.site, siteKey(r)); !ok {
+				refund()
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take reserves a token from rl, writing a 429 response if none is left.
+// The returned func puts the token back. A nil limiter allows everything.
+func (tl *TrafficLimiter) take(w http.ResponseWriter, r *http.Request, rl *RateLimiter, key string) (func(), bool) {
+	if rl == nil {
+		return func() {}, true
+	}
+	refund, wait := rl.reserve(key)
+	if refund == nil {
+		tooManyRequests(w, r, rl.burst, wait)
+		return nil, false
+	}
+	return refund, true
+}
+
+// siteKey identifies the site a request is for: its host without the port
+func siteKey(r *http.Request) string {
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
+
+// tooManyRequests writes a 429 with Retry-After rounded up to whole seconds.
+// API requests get the standard JSON error envelope.
+func tooManyRequests(w http.ResponseWriter, r *http.Request, limit int, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		api.RateLimitExceeded(w, "Too many requests. Please retry later.")
+		return
+	}
+	http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+}
+
 // ConnectionLimiter limits concurrent connections per IP.
 type ConnectionLimiter struct {
 	connections map[string]int
@@ -155,7 +284,7 @@ func (cl *ConnectionLimiter) Release(ip string) {
 // Middleware returns an HTTP middleware that enforces connection limits.
 func (cl *ConnectionLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := extractIP(r)
+		ip := realip.FromRequest(r)
 
 		if !cl.Acquire(ip) {
 			http.Error(w, "Too Many Connections", http.StatusServiceUnavailable)
@@ -166,31 +295,3 @@ func (cl *ConnectionLimiter) Middleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-// extractIP gets the client IP from the request.
-// Checks X-Forwarded-For and X-Real-IP headers first (for proxies),
-// then falls back to RemoteAddr.
-func extractIP(r *http.Request) string {
-	// Check X-Forwarded-For (may contain multiple IPs)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// Take the first IP (original client), before any comma
-		for i, c := range xff {
-			if c == ',' {
-				return strings.TrimSpace(xff[:i])
-			}
-		}
-		return strings.TrimSpace(xff)
-	}
-
-	// Check X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrafficLimiter(t *testing.T) {
+	tl := NewTrafficLimiter(Limits{
+		IPRate: 1, IPBurst: 2,
+		SiteRate: 1, SiteBurst: 3,
+		APIRate: 1, APIBurst: 1,
+	})
+	handler := tl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	get := func(ip, host, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = host
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Per-IP burst of 2
+	for i := 0; i < 2; i++ {
+		if rec := get("10.0.0.1", "blog.example.com", "/"); rec.Code != http.StatusOK {
+			t.Fatalf("Request %d: expected 200, got %d", i, rec.Code)
+		}
+	}
+	rec := get("10.0.0.1", "blog.example.com", "/")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 429 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// The site's shared bucket (3) is exhausted by a second client
+	if rec := get("10.0.0.2", "blog.example.com:443", "/"); rec.Code != http.StatusOK {
+		t.Errorf("Expected second client to pass, got %d", rec.Code)
+	}
+	if rec := get("10.0.0.2", "blog.example.com", "/"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected site limit, got %d", rec.Code)
+	}
+	if rec := get("10.0.0.2", "docs.example.com", "/"); rec.Code != http.StatusOK {
+		t.Errorf("Expected other sites unaffected, got %d", rec.Code)
+	}
+
+	// API traffic has its own budget and a JSON error
+	if rec := get("10.0.0.1", "admin.example.com", "/api/apps"); rec.Code != http.StatusOK {
+		t.Errorf("Expected API request to pass, got %d", rec.Code)
+	}
+	rec = get("10.0.0.1", "admin.example.com", "/api/apps")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "RATE_LIMIT_EXCEEDED") {
+		t.Errorf("Expected JSON 429, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTrafficLimiterDisabled(t *testing.T) {
+	tl := NewTrafficLimiter(Limits{})
+	handler := tl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 100; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/apps", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected no limits, got %d", rec.Code)
+		}
+	}
+}
@@ -17,8 +17,11 @@ const MaxBodySize = 1 << 20 // 1MB
 func BodySizeLimit(maxBytes int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip for paths that have their own limits (deploy has 100MB)
-			if r.URL.Path == "/api/deploy" {
+			// Skip for paths that have their own limits (deploy and app sync
+			// have 100MB, resumable uploads and the S3 gateway the configured
+			// upload size, failover replicas are a whole database from an admin)
+			if r.URL.Path == "/api/deploy" || r.URL.Path == "/api/failover/replica" || isAppSyncPath(r.URL.Path) ||
+				isUploadPath(r.URL.Path) || isS3Host(r.Host) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -190,3 +193,27 @@ func SecurityHeaders(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// isS3Host reports whether host is the S3 gateway, s3.<domain>
+func isS3Host(host string) bool {
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return config.Loaded() && host == "s3."+config.Get().Server.Domain
+}
+
+// isAppSyncPath reports whether path is an app's sync, which brings the
+// changed files and storage of an app from another server
+func isAppSyncPath(path string) bool {
+	return strings.HasPrefix(path, "/api/apps/") && strings.HasSuffix(path, "/sync")
+}
+
+// isUploadPath reports whether path is a resumable upload endpoint
+func isUploadPath(path string) bool {
+	for _, base := range []string{"/api/uploads", "/_uploads"} {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			return true
+		}
+	}
+	return false
+}
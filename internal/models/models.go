@@ -41,12 +41,13 @@ func (e *Event) TagsFromString(tagsStr string) {
 
 // Redirect represents a URL redirect with click tracking
 type Redirect struct {
-	ID          int64     `json:"id"`
-	Slug        string    `json:"slug"`
-	Destination string    `json:"destination"`
-	Tags        []string  `json:"tags"`
-	ClickCount  int64     `json:"click_count"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64      `json:"id"`
+	Slug        string     `json:"slug"`
+	Destination string     `json:"destination"`
+	Tags        []string   `json:"tags"`
+	ClickCount  int64      `json:"click_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 }
 
 // TagsToString converts tags slice to comma-separated string for storage
@@ -91,9 +92,17 @@ type Stats struct {
 	TotalEventsWeek      int64            `json:"total_events_week"`
 	TotalEventsMonth     int64            `json:"total_events_month"`
 	TotalEventsAllTime   int64            `json:"total_events_all_time"`
+	UniqueVisitorsToday  int64            `json:"unique_visitors_today"`
+	UniqueVisitorsWeek   int64            `json:"unique_visitors_week"`
+	UniqueVisitorsMonth  int64            `json:"unique_visitors_month"`
+	SessionsToday        int64            `json:"sessions_today"`
+	SessionsWeek         int64            `json:"sessions_week"`
+	SessionsMonth        int64            `json:"sessions_month"`
 	EventsBySourceType   map[string]int64 `json:"events_by_source_type"`
 	TopDomains           []DomainStat     `json:"top_domains"`
 	TopTags              []TagStat        `json:"top_tags"`
+	TopCountries         []BreakdownStat  `json:"top_countries"`
+	TopRegions           []BreakdownStat  `json:"top_regions"`
 	EventsTimeline       []TimelineStat   `json:"events_timeline"`
 	TotalUniqueDomains   int64            `json:"total_unique_domains"`
 	TotalRedirectClicks  int64            `json:"total_redirect_clicks"`
@@ -119,13 +128,15 @@ type TimelineStat struct {
 
 // TrackRequest represents an incoming tracking request
 type TrackRequest struct {
-	Hostname    string            `json:"h"`     // hostname/domain
-	Domain      string            `json:"d"`     // explicit domain override
-	Path        string            `json:"p"`     // page path
-	EventType   string            `json:"e"`     // event type
-	Tags        []string          `json:"t"`     // tags array
-	QueryParams map[string]string `json:"q"`     // query parameters
-	Referrer    string            `json:"ref"`   // referrer
+	Hostname     string            `json:"h"`   // hostname/domain
+	Domain       string            `json:"d"`   // explicit domain override
+	Path         string            `json:"p"`   // page path
+	EventType    string            `json:"e"`   // event type
+	Tags         []string          `json:"t"`   // tags array
+	QueryParams  map[string]string `json:"q"`   // query parameters
+	Referrer     string            `json:"ref"` // referrer
+	ScreenWidth  int               `json:"sw"`  // screen width (CSS pixels)
+	ScreenHeight int               `json:"sh"`  // screen height (CSS pixels)
 }
 
 // ToQueryParamsJSON converts query params map to JSON string
@@ -139,3 +150,75 @@ func (tr *TrackRequest) ToQueryParamsJSON() string {
 	}
 	return string(bytes)
 }
+
+// BreakdownStat is the event count for one value of a dimension
+type BreakdownStat struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
+}
+
+// DepthStat is the event count for a URL path depth ("/" is 0)
+type DepthStat struct {
+	Depth int   `json:"depth"`
+	Count int64 `json:"count"`
+}
+
+// PathDeviceStat is one row of the path x device heatmap
+type PathDeviceStat struct {
+	Path    string           `json:"path"`
+	Depth   int              `json:"depth"`
+	Total   int64            `json:"total"`
+	Devices map[string]int64 `json:"devices"`
+}
+
+// Breakdown groups events by device, browser, and path
+type Breakdown struct {
+	Domain     string           `json:"domain,omitempty"`
+	Days       int              `json:"days"`
+	Total      int64            `json:"total"`
+	Devices    []BreakdownStat  `json:"devices"`
+	Browsers   []BreakdownStat  `json:"browsers"`
+	Countries  []BreakdownStat  `json:"countries"`
+	Regions    []BreakdownStat  `json:"regions"`
+	PathDepths []DepthStat      `json:"path_depths"`
+	Paths      []PathDeviceStat `json:"paths"`
+}
+
+// CampaignStat is the traffic attributed to one utm_source/medium/campaign
+type CampaignStat struct {
+	Source    string `json:"source"`
+	Medium    string `json:"medium"`
+	Campaign  string `json:"campaign"`
+	Clicks    int64  `json:"clicks"`    // Short link (/r/) clicks
+	Pageviews int64  `json:"pageviews"` // Site pageviews
+	Visitors  int64  `json:"visitors"`
+}
+
+// Campaigns attributes short link clicks and site pageviews to campaigns
+// and referrer types
+type Campaigns struct {
+	Domain        string          `json:"domain,omitempty"`
+	Days          int             `json:"days"`
+	Total         int64           `json:"total"`
+	Campaigns     []CampaignStat  `json:"campaigns"`
+	Sources       []BreakdownStat `json:"sources"`
+	Mediums       []BreakdownStat `json:"mediums"`
+	ReferrerTypes []BreakdownStat `json:"referrer_types"`
+}
+
+// LinkStats is the click analytics of one short link over the last Days
+type LinkStats struct {
+	Slug          string          `json:"slug"`
+	URL           string          `json:"url"`
+	Destination   string          `json:"destination"`
+	ExpiresAt     *time.Time      `json:"expires_at,omitempty"`
+	Days          int             `json:"days"`
+	TotalClicks   int64           `json:"total_clicks"` // All time
+	Clicks        int64           `json:"clicks"`
+	Visitors      int64           `json:"visitors"`
+	Timeline      []TimelineStat  `json:"timeline"` // Clicks per day
+	ReferrerTypes []BreakdownStat `json:"referrer_types"`
+	Countries     []BreakdownStat `json:"countries"`
+	Devices       []BreakdownStat `json:"devices"`
+	Campaigns     []BreakdownStat `json:"campaigns"`
+}
@@ -0,0 +1,147 @@
+package modules
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Compiled program cache. Parsing dominates short handlers, so entry scripts
+// and modules are compiled once per version of a file and the *goja.Program
+// is shared by every VM. Keys include the content hash, so an edited file
+// is never served stale; Forget drops an app's programs when it is
+// redeployed or deleted.
+
+// MaxPrograms bounds the compiled program cache
+const MaxPrograms = 1024
+
+type programKey struct {
+	site, path, hash string
+}
+
+var programs = struct {
+	mu                      sync.Mutex
+	m                       map[programKey]*goja.Program
+	hits, misses, evictions int64
+}{m: make(map[programKey]*goja.Program)}
+
+// CacheStats describes the compiled program cache
+type CacheStats struct {
+	Programs  int   `json:"programs"`
+	Max       int   `json:"max"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+}
+
+// Script names the entry code an execution runs, for caching
+type Script struct {
+	Site string // app ID
+	Path string // file, or route pattern for generated route code
+}
+
+type scriptKey struct{}
+
+// WithScript records which app file an execution's entry code comes from
+func WithScript(ctx context.Context, site, path string) context.Context {
+	return context.WithValue(ctx, scriptKey{}, Script{Site: site, Path: path})
+}
+
+// ScriptFrom returns the script recorded by WithScript, if any
+func ScriptFrom(ctx context.Context) Script {
+	script, _ := ctx.Value(scriptKey{}).(Script)
+	return script
+}
+
+// CompileScript compiles entry code, rewriting ES module syntax. Code from
+// a named file is cached; anonymous code (path "") is compiled every time.
+func CompileScript(site, path, source string) (*goja.Program, error) {
+	if path == "" {
+		return goja.Compile("", Transform(source), false)
+	}
+	return cached(programKey{site, path, hashOf(source)}, func() (*goja.Program, error) {
+		return goja.Compile(path, Transform(source), false)
+	})
+}
+
+// compileModule returns the module function for a file. The wrapper shares
+// the file's first line, keeping error line numbers aligned with the source.
+func compileModule(site, file, source string, transform bool) (*goja.Program, error) {
+	if strings.HasPrefix(file, "stdlib:") {
+		site = "" // shared by all apps
+	}
+	return cached(programKey{site, file, hashOf(source)}, func() (*goja.Program, error) {
+		if transform {
+			source = Transform(source)
+		}
+		return goja.Compile(file, "(function (module, exports, require, __filename, __dirname) { "+source+"\n})", false)
+	})
+}
+
+// cached returns the program for key, compiling it on a miss. Concurrent
+// misses may both compile; the programs are equivalent.
+func cached(key programKey, compile func() (*goja.Program, error)) (*goja.Program, error) {
+	programs.mu.Lock()
+	program, ok := programs.m[key]
+	if ok {
+		programs.hits++
+	} else {
+		programs.misses++
+	}
+	programs.mu.Unlock()
+	if ok {
+		return program, nil
+	}
+
+	program, err := compile()
+	if err != nil {
+		return nil, err
+	}
+
+	programs.mu.Lock()
+	if _, ok := programs.m[key]; !ok && len(programs.m) >= MaxPrograms {
+		// Evict an arbitrary entry; edits leave stale hashes behind
+		for k := range programs.m {
+			delete(programs.m, k)
+			programs.evictions++
+			break
+		}
+	}
+	programs.m[key] = program
+	programs.mu.Unlock()
+	return program, nil
+}
+
+// Forget drops an app's compiled programs
+func Forget(site string) {
+	programs.mu.Lock()
+	defer programs.mu.Unlock()
+	for k := range programs.m {
+		if k.site == site {
+			delete(programs.m, k)
+		}
+	}
+}
+
+// Stats returns compiled program cache statistics
+func Stats() CacheStats {
+	programs.mu.Lock()
+	defer programs.mu.Unlock()
+	return CacheStats{
+		Programs:  len(programs.m),
+		Max:       MaxPrograms,
+		Hits:      programs.hits,
+		Misses:    programs.misses,
+		Evictions: programs.evictions,
+	}
+}
+
+// hashOf hashes a file's content the way the files table does
+func hashOf(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,194 @@
+package modules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ES module syntax. Goja runs scripts, not modules, so import and export
+// statements are rewritten to require() and exports before compiling:
+//
+//	import db, { find as f } from "./db.js"  ->  var __import0 = require("./db.js"), db = __importDefault(__import0), { find: f } = __import0;
+//	export function GET(req) { ... }          ->  function GET(req) { ... } ... exports.GET = GET;
+//	export default handler                    ->  exports.default = handler
+//
+// Statements must start a line. Exported declarations are assigned once the
+// module body has run, so bindings aren't live. Each rewrite keeps the
+// newlines of the statement it replaces, keeping line numbers in errors.
+
+const identPattern = `[A-Za-z_$][\w$]*`
+
+const specPattern = `("[^"\n]*"|'[^'\n]*')`
+
+var (
+	esmStatementRe = regexp.MustCompile(`(?m)^[ \t]*(?:import|export)\b`)
+
+	importRe           = regexp.MustCompile(`(?m)^[ \t]*import\s+(?:(` + identPattern + `)\s*,?\s*)?(?:\*\s*as\s+(` + identPattern + `)|\{([^}]*)\})?\s*from\s*` + specPattern + `[ \t]*;?`)
+	sideEffectImportRe = regexp.MustCompile(`(?m)^[ \t]*import\s*` + specPattern + `[ \t]*;?`)
+
+	exportStarRe        = regexp.MustCompile(`(?m)^[ \t]*export\s*\*\s*(?:as\s+(` + identPattern + `)\s*)?from\s*` + specPattern + `[ \t]*;?`)
+	exportFromRe        = regexp.MustCompile(`(?m)^[ \t]*export\s*\{([^}]*)\}\s*from\s*` + specPattern + `[ \t]*;?`)
+	exportListRe        = regexp.MustCompile(`(?m)^[ \t]*export\s*\{([^}]*)\}[ \t]*;?`)
+	exportDefaultDeclRe = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+((?:async\s+)?function\b\s*\*?\s*(` + identPattern + `)|class\s+(` + identPattern + `))`)
+	exportDefaultRe     = regexp.MustCompile(`(?m)^([ \t]*)export\s+default\s+`)
+	exportDeclRe        = regexp.MustCompile(`(?m)^([ \t]*)export\s+((?:async\s+)?function\b\s*\*?\s*(` + identPattern + `)|class\s+(` + identPattern + `)|(?:const|let|var)\s+(?:(` + identPattern + `)|\{([^}]*)\}))`)
+)
+
+// Transform rewrites ES module syntax to CommonJS. Sources without import
+// or export statements are returned unchanged.
+func Transform(source string) string {
+	if !esmStatementRe.MatchString(source) {
+		return source
+	}
+
+	var (
+		tail    []string // exports assigned after the body runs
+		esm     bool
+		counter int
+	)
+	temp := func(prefix string) string {
+		counter++
+		return fmt.Sprintf("__%s%d", prefix, counter-1)
+	}
+	// keepLines pads a rewrite with the newlines of the text it replaces
+	keepLines := func(match, repl string) string {
+		return repl + strings.Repeat("\n", strings.Count(match, "\n"))
+	}
+
+	source = importRe.ReplaceAllStringFunc(source, func(match string) string {
+		m := importRe.FindStringSubmatch(match)
+		def, ns, named, spec := m[1], m[2], m[3], m[4]
+		req := "require(" + spec + ")"
+
+		var decls []string
+		switch {
+		case ns != "":
+			decls = append(decls, ns+" = "+req)
+			if def != "" {
+				decls = append(decls, def+" = __importDefault("+ns+")")
+			}
+		case def != "" && named != "":
+			mod := temp("import")
+			decls = append(decls, mod+" = "+req, def+" = __importDefault("+mod+")", "{ "+destructure(named)+" } = "+mod)
+		case def != "":
+			decls = append(decls, def+" = __importDefault("+req+")")
+		default:
+			decls = append(decls, "{ "+destructure(named)+" } = "+req)
+		}
+		return keepLines(match, "var "+strings.Join(decls, ", ")+";")
+	})
+
+	source = sideEffectImportRe.ReplaceAllStringFunc(source, func(match string) string {
+		m := sideEffectImportRe.FindStringSubmatch(match)
+		return keepLines(match, "require("+m[1]+");")
+	})
+
+	source = exportStarRe.ReplaceAllStringFunc(source, func(match string) string {
+		esm = true
+		m := exportStarRe.FindStringSubmatch(match)
+		req := "require(" + m[2] + ")"
+		if m[1] != "" {
+			return keepLines(match, "exports."+m[1]+" = "+req+";")
+		}
+		return keepLines(match, `(function (m) { for (var k in m) if (k !== "default" && !(k in exports)) exports[k] = m[k]; })(`+req+");")
+	})
+
+	source = exportFromRe.ReplaceAllStringFunc(source, func(match string) string {
+		esm = true
+		m := exportFromRe.FindStringSubmatch(match)
+		mod := temp("reexport")
+		stmts := []string{"var " + mod + " = require(" + m[2] + ");"}
+		for _, b := range exportList(m[1]) {
+			stmts = append(stmts, "exports."+b.exported+" = "+mod+"."+b.local+";")
+		}
+		return keepLines(match, strings.Join(stmts, " "))
+	})
+
+	source = exportListRe.ReplaceAllStringFunc(source, func(match string) string {
+		esm = true
+		m := exportListRe.FindStringSubmatch(match)
+		for _, b := range exportList(m[1]) {
+			tail = append(tail, "exports."+b.exported+" = "+b.local+";")
+		}
+		return keepLines(match, "")
+	})
+
+	source = exportDefaultDeclRe.ReplaceAllStringFunc(source, func(match string) string {
+		m := exportDefaultDeclRe.FindStringSubmatch(match)
+		name := m[3] + m[4]
+		if name == "extends" {
+			// export default class extends Base: an anonymous class
+			return match
+		}
+		esm = true
+		tail = append(tail, "exports.default = "+name+";")
+		return keepLines(match, m[1]+m[2])
+	})
+
+	source = exportDefaultRe.ReplaceAllStringFunc(source, func(match string) string {
+		esm = true
+		m := exportDefaultRe.FindStringSubmatch(match)
+		return keepLines(match, m[1]+"exports.default = ")
+	})
+
+	source = exportDeclRe.ReplaceAllStringFunc(source, func(match string) string {
+		esm = true
+		m := exportDeclRe.FindStringSubmatch(match)
+		names := []string{m[3] + m[4] + m[5]}
+		if m[6] != "" {
+			names = names[:0]
+			for _, b := range exportList(strings.ReplaceAll(m[6], ":", " as ")) {
+				names = append(names, b.exported)
+			}
+		}
+		for _, name := range names {
+			tail = append(tail, "exports."+name+" = "+name+";")
+		}
+		return keepLines(match, m[1]+m[2])
+	})
+
+	if !esm {
+		return source
+	}
+	tail = append(tail, `Object.defineProperty(exports, "__esModule", { value: true });`)
+	return source + "\n;" + strings.Join(tail, " ")
+}
+
+// binding is one entry of an import or export list
+type binding struct {
+	local, exported string
+}
+
+// exportList parses "a, b as c" into its bindings. Destructuring defaults
+// (a = 1) are dropped.
+func exportList(list string) []binding {
+	var bindings []binding
+	for _, item := range strings.Split(list, ",") {
+		if i := strings.Index(item, "="); i >= 0 {
+			item = item[:i]
+		}
+		fields := strings.Fields(item)
+		switch {
+		case len(fields) == 1:
+			bindings = append(bindings, binding{fields[0], fields[0]})
+		case len(fields) == 3 && fields[1] == "as":
+			bindings = append(bindings, binding{fields[0], fields[2]})
+		}
+	}
+	return bindings
+}
+
+// destructure turns an import list into a destructuring pattern:
+// "a, b as c" becomes "a, b: c"
+func destructure(list string) string {
+	var parts []string
+	for _, b := range exportList(list) {
+		if b.local == b.exported {
+			parts = append(parts, b.local)
+		} else {
+			parts = append(parts, b.local+": "+b.exported)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,167 @@
+// Package modules loads JavaScript modules for the Goja runtimes. It gives
+// serverless handlers and workers the same require() and import: specifiers
+// resolve against the app's files table, bare names against the embedded
+// stdlib, and ES module syntax is rewritten to CommonJS (see Transform).
+// Compiled programs are cached by file hash and shared across VMs.
+package modules
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Loader returns the content of an app file by VFS path
+type Loader func(path string) (string, error)
+
+// FileLoader returns a Loader reading an app's deployed files
+func FileLoader(db *sql.DB, appID string) Loader {
+	return func(path string) (string, error) {
+		var content string
+		err := db.QueryRow(`
+			SELECT content FROM files
+			WHERE site_id = ? AND path = ?
+		`, appID, path).Scan(&content)
+		if err != nil {
+			return "", err
+		}
+		return content, nil
+	}
+}
+
+// registry holds the modules instantiated in one execution, so each file
+// runs once per VM and cyclic requires see a partial exports object
+type registry struct {
+	vm     *goja.Runtime
+	site   string
+	load   Loader
+	loaded map[string]*goja.Object
+}
+
+// Enable installs require() and the import helpers in vm for an app's code.
+// Specifiers in the entry code resolve against dir (e.g. "api" for
+// handlers, the job's directory for workers).
+func Enable(vm *goja.Runtime, site string, load Loader, dir string) {
+	r := &registry{vm: vm, site: site, load: load, loaded: make(map[string]*goja.Object)}
+	vm.Set("require", r.requireFrom(dir))
+	vm.Set("__importDefault", func(m goja.Value) goja.Value {
+		if obj, ok := m.(*goja.Object); ok {
+			if flag := obj.Get("__esModule"); flag != nil && flag.ToBoolean() {
+				return obj.Get("default")
+			}
+		}
+		return m
+	})
+}
+
+// requireFrom returns the require() seen by code in dir
+func (r *registry) requireFrom(dir string) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) == 0 {
+			panic(r.vm.NewGoError(fmt.Errorf("require() needs a path argument")))
+		}
+		spec := call.Argument(0).String()
+
+		// Bare specifiers name stdlib modules first
+		if !isRelative(spec) {
+			if source, ok := GetStdlibModule(spec); ok {
+				return r.instantiate("stdlib:"+spec, "", source, false)
+			}
+		}
+
+		file, source, err := r.resolve(dir, spec)
+		if err != nil {
+			panic(r.vm.NewGoError(fmt.Errorf("cannot require '%s': %w", spec, err)))
+		}
+		return r.instantiate(file, path.Dir(file), source, true)
+	}
+}
+
+// resolve finds the file a specifier names, trying the exact path, then
+// with .js, then as a directory index. Already loaded modules are not read
+// again.
+func (r *registry) resolve(dir, spec string) (string, string, error) {
+	base := resolvePath(dir, spec)
+	if base == ".." || strings.HasPrefix(base, "../") {
+		return "", "", fmt.Errorf("path is outside the app")
+	}
+	candidates := []string{base}
+	if path.Ext(base) == "" {
+		candidates = append(candidates, base+".js", base+"/index.js")
+	}
+
+	for _, file := range candidates {
+		if _, ok := r.loaded[file]; ok {
+			return file, "", nil
+		}
+	}
+	var firstErr error
+	for _, file := range candidates {
+		source, err := r.load(file)
+		if err == nil {
+			return file, source, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", "", firstErr
+}
+
+// instantiate runs a module once and returns its exports
+func (r *registry) instantiate(file, dir, source string, transform bool) goja.Value {
+	if module, ok := r.loaded[file]; ok {
+		return module.Get("exports")
+	}
+
+	module := r.vm.NewObject()
+	exports := r.vm.NewObject()
+	module.Set("exports", exports)
+	module.Set("id", file)
+	r.loaded[file] = module
+
+	if strings.HasSuffix(file, ".json") {
+		value, err := jsonParse(r.vm, source)
+		if err != nil {
+			delete(r.loaded, file)
+			panic(r.vm.NewGoError(fmt.Errorf("error in '%s': %w", file, err)))
+		}
+		module.Set("exports", value)
+		return module.Get("exports")
+	}
+
+	program, err := compileModule(r.site, file, source, transform)
+	if err == nil {
+		var fn goja.Value
+		if fn, err = r.vm.RunProgram(program); err == nil {
+			call, _ := goja.AssertFunction(fn)
+			_, err = call(exports, module, exports, r.vm.ToValue(r.requireFrom(dir)), r.vm.ToValue(file), r.vm.ToValue(dir))
+		}
+	}
+	if err != nil {
+		delete(r.loaded, file)
+		panic(r.vm.NewGoError(fmt.Errorf("error in '%s': %w", file, err)))
+	}
+	return module.Get("exports")
+}
+
+// jsonParse decodes a JSON module with the VM's own parser, so numbers and
+// objects come out as JS values
+func jsonParse(vm *goja.Runtime, source string) (goja.Value, error) {
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	return parse(goja.Undefined(), vm.ToValue(source))
+}
+
+// isRelative reports whether a specifier starts with ./ or ../
+func isRelative(spec string) bool {
+	return strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../")
+}
+
+// resolvePath joins a specifier onto the directory of the requiring file. Bare
+// specifiers that aren't stdlib modules resolve like relative ones.
+func resolvePath(dir, spec string) string {
+	return path.Join(dir, spec)
+}
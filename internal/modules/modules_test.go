@@ -0,0 +1,137 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		dir      string
+		spec     string
+		expected string
+	}{
+		{"api", "./utils.js", "api/utils.js"},
+		{"api", "./lib/helper.js", "api/lib/helper.js"},
+		{"api", "utils", "api/utils"},
+		{"api/lib", "../shared.js", "api/shared.js"},
+		{"api", "../lib/shared.js", "lib/shared.js"},
+		{"workers", "../../secret.js", "../secret.js"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if got := resolvePath(tt.dir, tt.spec); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransformKeepsLines(t *testing.T) {
+	source := "import {\n  a,\n  b as c\n} from './x.js';\nexport const d = 1;\nthrow new Error('line 6');"
+	out := Transform(source)
+	lines := strings.Split(out, "\n")
+	if len(lines) < 6 || lines[5] != "throw new Error('line 6');" {
+		t.Fatalf("expected line 6 unchanged, got:\n%s", out)
+	}
+	if !strings.Contains(out, "var { a, b: c } = require('./x.js');") {
+		t.Errorf("expected named import rewritten, got:\n%s", out)
+	}
+	if Transform("var x = 1;") != "var x = 1;" {
+		t.Error("expected sources without modules syntax unchanged")
+	}
+}
+
+func TestRequireESM(t *testing.T) {
+	files := map[string]string{
+		"lib/db.js": `export default function connect() { return "db"; }
+export const name = "db";
+export function find(id) { return { id: id }; }`,
+		"lib/index.js": `export * from "./db.js";
+export { default as connect } from "./db.js";
+export * as db from "./db.js";`,
+		"lib/config.json": `{"region": "eu"}`,
+		"lib/legacy.js":   `module.exports = function() { return "legacy"; };`,
+		"api/handler.js": `import connect, { find as lookup, name } from "../lib/db.js";
+import * as lib from "../lib";
+import config from "../lib/config.json";
+import legacy from "../lib/legacy.js";
+export default [connect(), lookup(7).id, name, lib.connect(), lib.db.name, config.region, legacy()].join(",");`,
+		"workers/escape.js": `import secret from "../../secret.js";`,
+	}
+	loader := func(path string) (string, error) {
+		if content, ok := files[path]; ok {
+			return content, nil
+		}
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+
+	for run := 0; run < 2; run++ {
+		vm := goja.New()
+		Enable(vm, "", loader, "api")
+		value, err := vm.RunString(`require("./handler.js").default`)
+		if err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+		if got := value.String(); got != "db,7,db,db,db,eu,legacy" {
+			t.Errorf("run %d: got %q", run, got)
+		}
+	}
+
+	// Each VM loads the files again, but compiles them only once
+	programs.mu.Lock()
+	_, cached := programs.m[programKey{"", "lib/db.js", hashOf(files["lib/db.js"])}]
+	programs.mu.Unlock()
+	if !cached {
+		t.Error("expected lib/db.js in the program cache")
+	}
+
+	vm := goja.New()
+	Enable(vm, "", loader, "workers")
+	if _, err := vm.RunString(`require("./escape.js")`); err == nil || !strings.Contains(err.Error(), "outside the app") {
+		t.Errorf("expected import outside the app to fail, got %v", err)
+	}
+}
+
+func TestCompileScriptCache(t *testing.T) {
+	Forget("app1")
+	before := Stats()
+
+	for i := 0; i < 3; i++ {
+		program, err := CompileScript("app1", "api/main.js", "import x from './x.js';\n1 + 1")
+		if err != nil {
+			t.Fatalf("CompileScript: %v", err)
+		}
+		if program == nil {
+			t.Fatal("expected a program")
+		}
+	}
+	if _, err := CompileScript("app1", "api/main.js", "2 + 2"); err != nil {
+		t.Fatalf("CompileScript: %v", err)
+	}
+
+	stats := Stats()
+	if hits := stats.Hits - before.Hits; hits != 2 {
+		t.Errorf("expected 2 hits, got %d", hits)
+	}
+	if misses := stats.Misses - before.Misses; misses != 2 {
+		t.Errorf("expected 2 misses (first compile, edited file), got %d", misses)
+	}
+
+	Forget("app1")
+	programs.mu.Lock()
+	for k := range programs.m {
+		if k.site == "app1" {
+			t.Errorf("expected app1 programs forgotten, found %s", k.path)
+		}
+	}
+	programs.mu.Unlock()
+
+	if _, err := CompileScript("app1", "api/main.js", "var = ;"); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
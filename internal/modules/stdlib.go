@@ -1,4 +1,4 @@
-package runtime
+package modules
 
 import (
 	"io/fs"
@@ -1,99 +1,25 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"log"
-	"net/http"
-	"time"
 
-	"github.com/fazt-sh/fazt/internal/config"
 	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/notify"
 )
 
 // Notification types
 const (
-	NotificationTrafficSpike = "traffic_spike"
-	NotificationNewDomain    = "new_domain"
-	NotificationWebhook      = "webhook_event"
-	NotificationError        = "error"
+	NotificationTrafficSpike = notify.EventTrafficSpike
+	NotificationNewDomain    = notify.EventNewDomain
+	NotificationWebhook      = notify.EventWebhook
+	NotificationError        = notify.EventError
+	NotificationSecurity     = notify.EventSecurity
 )
 
-// Send sends a notification to ntfy.sh
+// Send sends a notification over the channels routed for its type
+// (ntfy, email, webhook; see the notify package)
 func Send(title, message, notificationType string) error {
-	cfg := config.Get()
-
-	// In development mode, just log instead of sending
-	if cfg.IsDevelopment() {
-		log.Printf("[NTFY MOCK] Type: %s, Title: %s, Message: %s", notificationType, title, message)
-		return logNotification(0, notificationType, fmt.Sprintf("%s: %s", title, message))
-	}
-
-	// Check if ntfy topic is configured
-	if cfg.Ntfy.Topic == "" {
-		log.Println("ntfy.sh topic not configured, skipping notification")
-		return nil
-	}
-
-	// Prepare notification payload
-	payload := map[string]interface{}{
-		"topic":   cfg.Ntfy.Topic,
-		"title":   title,
-		"message": message,
-		"tags":    []string{"fazt", notificationType},
-	}
-
-	// Add priority based on type
-	switch notificationType {
-	case NotificationError:
-		payload["priority"] = "high"
-	case NotificationTrafficSpike:
-		payload["priority"] = "default"
-	default:
-		payload["priority"] = "low"
-	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	// Send to ntfy.sh
-	url := cfg.Ntfy.URL + "/" + cfg.Ntfy.Topic
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send notification: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ntfy.sh returned status %d", resp.StatusCode)
-	}
-
-	log.Printf("Notification sent: %s - %s", title, message)
-	return logNotification(0, notificationType, fmt.Sprintf("%s: %s", title, message))
-}
-
-// logNotification stores notification in database
-func logNotification(eventID int64, notificationType, message string) error {
-	db := database.GetDB()
-	_, err := db.Exec(`
-		INSERT INTO notifications (event_id, notification_type, message)
-		VALUES (?, ?, ?)
-	`, eventID, notificationType, message)
-	return err
+	return notify.Send(notificationType, title, message)
 }
 
 // CheckTrafficSpike detects unusual traffic patterns
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// Ntfy publishes to an ntfy topic
+type Ntfy struct {
+	URL   string // Server, e.g. https://ntfy.sh
+	Topic string
+}
+
+func (n *Ntfy) Name() string { return ChannelNtfy }
+
+func (n *Ntfy) Send(ctx context.Context, m Message) error {
+	return postJSON(ctx, strings.TrimSuffix(n.URL, "/")+"/"+n.Topic, map[string]interface{}{
+		"topic":    n.Topic,
+		"title":    m.Title,
+		"message":  m.Body,
+		"tags":     []string{"fazt", m.Event},
+		"priority": m.Priority,
+	})
+}
+
+// Webhook POSTs the message as JSON
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Name() string { return ChannelWebhook }
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	return postJSON(ctx, w.URL, m)
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "fazt-notify")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// Email sends the message through an SMTP server. Port 465 uses implicit
+// TLS; other ports upgrade with STARTTLS when the server offers it.
+type Email struct {
+	Host     string
+	Port     int
+	Username string // Empty to send without authenticating
+	Password string
+	From     string
+	To       []string
+}
+
+func (e *Email) Name() string { return ChannelEmail }
+
+func (e *Email) Send(ctx context.Context, m Message) error {
+	from := e.From
+	if from == "" {
+		from = e.Username
+	}
+	server := mailer.Server{Host: e.Host, Port: e.Port, Username: e.Username, Password: e.Password}
+	return server.Send(ctx, from, e.To, e.compose(from, m))
+}
+
+// compose builds the email for a message
+func (e *Email) compose(from string, m Message) []byte {
+	// Header values can't span lines
+	clean := strings.NewReplacer("\r", " ", "\n", " ").Replace
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", clean(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean(strings.Join(e.To, ", ")))
+	fmt.Fprintf(&b, "Subject: [fazt] %s\r\n", clean(m.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if m.Priority == "high" {
+		b.WriteString("X-Priority: 1\r\n")
+	}
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
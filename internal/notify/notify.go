@@ -0,0 +1,231 @@
+// Package notify delivers admin notifications over pluggable channels:
+// ntfy, email (SMTP) and a webhook. Each event type goes to the channels
+// routed for it (notify.route.<event>), or to every configured channel when
+// it has no route.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+)
+
+// Event types
+const (
+	EventCertFailed      = "cert.failed"      // A certificate couldn't be obtained or renewed
+	EventDiskPressure    = "disk.pressure"    // The data volume is nearly full
+	EventJobFailed       = "job.failed"       // A job failed every attempt and was dead-lettered
+	EventLoginNewIP      = "login.new_ip"     // A user signed in from an IP they haven't used before
+	EventFormSubmitted   = "form.submitted"   // A visitor submitted a form with "notify" set
+	EventPingFailed      = "ping.failed"      // A scheduled ping started failing or recovered
+	EventHeartbeatMissed = "heartbeat.missed" // A heartbeat missed its check-in or checked in again
+
+	EventError        = "error"         // Crash loops, site outages, SLO burn
+	EventSecurity     = "security"      // Security alert rules
+	EventTrafficSpike = "traffic_spike" // Unusual traffic
+	EventNewDomain    = "new_domain"    // First traffic to a domain
+	EventWebhook      = "webhook_event" // Incoming webhook
+)
+
+// Events lists the event types that can be routed
+var Events = []string{
+	EventCertFailed, EventDiskPressure, EventJobFailed, EventLoginNewIP, EventFormSubmitted, EventPingFailed, EventHeartbeatMissed,
+	EventError, EventSecurity, EventTrafficSpike, EventNewDomain, EventWebhook,
+}
+
+// Channel names
+const (
+	ChannelNtfy    = "ntfy"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// ChannelNames lists the channels an event can be routed to
+var ChannelNames = []string{ChannelNtfy, ChannelEmail, ChannelWebhook}
+
+// Cooldown is how long SendOnce holds back a repeat of the same alert
+const Cooldown = time.Hour
+
+// sendTimeout bounds delivery to a single channel
+const sendTimeout = 10 * time.Second
+
+// ValidEvent reports whether event is a known event type
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidChannel reports whether name is a known channel
+func ValidChannel(name string) bool {
+	for _, c := range ChannelNames {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Message is a notification about an event
+type Message struct {
+	Event    string `json:"event"`
+	Title    string `json:"title"`
+	Body     string `json:"message"`
+	Priority string `json:"priority"` // high, default or low
+}
+
+// Channel delivers messages to the admin
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, m Message) error
+}
+
+// Channels returns the channels configured in cfg, by name
+func Channels(cfg *config.Config) map[string]Channel {
+	channels := make(map[string]Channel)
+	if cfg.Ntfy.Topic != "" {
+		channels[ChannelNtfy] = &Ntfy{URL: cfg.Ntfy.URL, Topic: cfg.Ntfy.Topic}
+	}
+	if smtp := cfg.Notify.SMTP; smtp.Host != "" && len(smtp.To) > 0 {
+		channels[ChannelEmail] = &Email{
+			Host:     smtp.Host,
+			Port:     smtp.Port,
+			Username: smtp.Username,
+			Password: smtp.Password,
+			From:     smtp.From,
+			To:       smtp.To,
+		}
+	}
+	if cfg.Notify.WebhookURL != "" {
+		channels[ChannelWebhook] = &Webhook{URL: cfg.Notify.WebhookURL}
+	}
+	return channels
+}
+
+// Route returns the channels an event type is sent to
+func Route(cfg *config.Config, event string) []string {
+	if route, ok := cfg.Notify.Routes[event]; ok {
+		return route
+	}
+	var all []string
+	for name := range Channels(cfg) {
+		all = append(all, name)
+	}
+	sort.Strings(all)
+	return all
+}
+
+// priority ranks an event for channels that support it
+func priority(event string) string {
+	switch event {
+	case EventError, EventSecurity, EventCertFailed, EventDiskPressure, EventJobFailed, EventLoginNewIP, EventPingFailed, EventHeartbeatMissed:
+		return "high"
+	case EventTrafficSpike:
+		return "default"
+	default:
+		return "low"
+	}
+}
+
+var recent = struct {
+	sync.Mutex
+	sent map[string]time.Time
+}{sent: make(map[string]time.Time)}
+
+// Send notifies the admin of an event over the channels routed for it
+func Send(event, title, message string) error {
+	if !config.Loaded() {
+		log.Printf("Notify: configuration not loaded, skipping %s", event)
+		return nil
+	}
+	return deliver(config.Get(), Message{Event: event, Title: title, Body: message, Priority: priority(event)})
+}
+
+// SendOnce is Send for alerts that can keep recurring: one with the same
+// event and key is sent at most once per Cooldown.
+func SendOnce(event, key, title, message string) error {
+	id := event + "\x00" + key
+	now := time.Now()
+
+	recent.Lock()
+	if last, ok := recent.sent[id]; ok && now.Sub(last) < Cooldown {
+		recent.Unlock()
+		return nil
+	}
+	recent.sent[id] = now
+	recent.Unlock()
+
+	return Send(event, title, message)
+}
+
+// SendTest sends a test message to every configured channel, returning each
+// channel's result
+func SendTest(cfg *config.Config) map[string]error {
+	results := make(map[string]error)
+	m := Message{Event: "test", Title: "fazt test notification", Body: "Notifications from " + cfg.Server.Domain + " reach this channel.", Priority: "low"}
+	for name, ch := range Channels(cfg) {
+		results[name] = sendTo(ch, m)
+	}
+	return results
+}
+
+func deliver(cfg *config.Config, m Message) error {
+	// In development mode, just log instead of sending
+	if cfg.IsDevelopment() {
+		log.Printf("[NOTIFY MOCK] Event: %s, Title: %s, Message: %s", m.Event, m.Title, m.Body)
+		return logNotification(m)
+	}
+
+	channels := Channels(cfg)
+	var errs []error
+	delivered := false
+	for _, name := range Route(cfg, m.Event) {
+		ch, ok := channels[name]
+		if !ok {
+			log.Printf("Notify: %s channel not configured, skipping %s", name, m.Event)
+			continue
+		}
+		if err := sendTo(ch, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		delivered = true
+	}
+
+	if delivered {
+		log.Printf("Notification sent: %s - %s", m.Title, m.Body)
+		if err := logNotification(m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sendTo(ch Channel, m Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	return ch.Send(ctx, m)
+}
+
+// logNotification stores a sent notification in the database
+func logNotification(m Message) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	_, err := db.Exec(`
+		INSERT INTO notifications (event_id, notification_type, message)
+		VALUES (?, ?, ?)
+	`, 0, m.Event, fmt.Sprintf("%s: %s", m.Title, m.Body))
+	return err
+}
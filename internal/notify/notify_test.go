@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/config"
+)
+
+// capture records the JSON bodies POSTed to a test server
+type capture struct {
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func (c *capture) server(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		c.mu.Lock()
+		c.bodies = append(c.bodies, body)
+		c.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (c *capture) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.bodies)
+}
+
+func setupConfig(t *testing.T, ntfyURL, webhookURL string) *config.Config {
+	t.Helper()
+	cfg := config.CreateDefaultConfig()
+	cfg.Server.Env = "production"
+	cfg.Ntfy = config.NtfyConfig{URL: ntfyURL, Topic: "alerts"}
+	cfg.Notify.WebhookURL = webhookURL
+	config.SetConfig(cfg)
+	t.Cleanup(func() { config.SetConfig(nil) })
+	return cfg
+}
+
+func TestSend_Routes(t *testing.T) {
+	var ntfy, webhook capture
+	cfg := setupConfig(t, ntfy.server(t).URL, webhook.server(t).URL)
+	cfg.Notify.Routes = map[string][]string{
+		EventDiskPressure: {ChannelWebhook},
+		EventTrafficSpike: {},
+	}
+
+	// No route: every configured channel
+	if err := Send(EventCertFailed, "Certificate renew failed", "example.com"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if ntfy.count() != 1 || webhook.count() != 1 {
+		t.Fatalf("Expected both channels, got ntfy=%d webhook=%d", ntfy.count(), webhook.count())
+	}
+	if ntfy.bodies[0]["topic"] != "alerts" || ntfy.bodies[0]["priority"] != "high" {
+		t.Errorf("Unexpected ntfy payload: %v", ntfy.bodies[0])
+	}
+	if webhook.bodies[0]["event"] != EventCertFailed || webhook.bodies[0]["message"] != "example.com" {
+		t.Errorf("Unexpected webhook payload: %v", webhook.bodies[0])
+	}
+
+	// Routed to the webhook only
+	Send(EventDiskPressure, "Disk almost full", "95%")
+	if ntfy.count() != 1 || webhook.count() != 2 {
+		t.Errorf("Expected the webhook only, got ntfy=%d webhook=%d", ntfy.count(), webhook.count())
+	}
+
+	// Routed nowhere
+	Send(EventTrafficSpike, "Traffic spike", "10x")
+	if ntfy.count() != 1 || webhook.count() != 2 {
+		t.Errorf("Expected nothing sent, got ntfy=%d webhook=%d", ntfy.count(), webhook.count())
+	}
+}
+
+func TestSend_ChannelError(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	var webhook capture
+	setupConfig(t, failing.URL, webhook.server(t).URL)
+
+	err := Send(EventJobFailed, "Job failed", "boom")
+	if err == nil || !strings.Contains(err.Error(), "ntfy") {
+		t.Errorf("Expected the ntfy failure reported, got %v", err)
+	}
+	if webhook.count() != 1 {
+		t.Errorf("Expected the webhook still sent, got %d", webhook.count())
+	}
+}
+
+func TestSendOnce(t *testing.T) {
+	var webhook capture
+	setupConfig(t, "", webhook.server(t).URL)
+	cfg := config.Get()
+	cfg.Ntfy.Topic = ""
+
+	SendOnce(EventLoginNewIP, "u1 203.0.113.9", "Login from a new IP", "a")
+	SendOnce(EventLoginNewIP, "u1 203.0.113.9", "Login from a new IP", "a")
+	SendOnce(EventLoginNewIP, "u1 198.51.100.4", "Login from a new IP", "b")
+	if webhook.count() != 2 {
+		t.Errorf("Expected 2 notifications, got %d", webhook.count())
+	}
+}
+
+func TestSend_NotLoaded(t *testing.T) {
+	config.SetConfig(nil)
+	if err := Send(EventError, "x", "y"); err != nil {
+		t.Errorf("Expected sending without a config to be skipped, got %v", err)
+	}
+}
+
+func TestEmailCompose(t *testing.T) {
+	e := &Email{To: []string{"ops@example.com", "admin@example.com"}}
+	msg := string(e.compose("alerts@example.com", Message{
+		Title:    "Disk almost full\r\nBcc: evil@example.com",
+		Body:     "Line one\nLine two",
+		Priority: "high",
+	}))
+
+	for _, want := range []string{
+		"From: alerts@example.com\r\n",
+		"To: ops@example.com, admin@example.com\r\n",
+		"Subject: [fazt] Disk almost full  Bcc: evil@example.com\r\n",
+		"X-Priority: 1\r\n",
+		"\r\n\r\nLine one\r\nLine two\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Email missing %q:\n%s", want, msg)
+		}
+	}
+}
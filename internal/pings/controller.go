@@ -0,0 +1,210 @@
+package pings
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/egress"
+	"github.com/fazt-sh/fazt/internal/freeze"
+	"github.com/fazt-sh/fazt/internal/notify"
+)
+
+const (
+	// tickInterval is how often the controller looks for due pings; each
+	// minute is only handled once
+	tickInterval = 15 * time.Second
+
+	// maxCatchUp is how far back minutes missed while the controller was
+	// stalled are still honored
+	maxCatchUp = 5 * time.Minute
+)
+
+// FetchFunc makes a ping's call
+type FetchFunc func(ctx context.Context, rawURL string, opts egress.FetchOptions) (*egress.FetchResponse, error)
+
+// Controller calls pings when their schedules match, records each run and
+// alerts when a ping starts failing or recovers
+type Controller struct {
+	db     *sql.DB
+	fetch  FetchFunc
+	notify func(title, message string)
+
+	mu      sync.Mutex
+	last    time.Time      // Latest minute handled
+	running map[int64]bool // Pings with a call in flight
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewController creates a controller that calls pings through the egress
+// proxy. Pings aren't app-scoped, so only global allowlist entries apply.
+func NewController(db *sql.DB, proxy *egress.EgressProxy) *Controller {
+	return &Controller{
+		db: db,
+		fetch: func(ctx context.Context, rawURL string, opts egress.FetchOptions) (*egress.FetchResponse, error) {
+			return proxy.Fetch(ctx, "", rawURL, opts)
+		},
+		notify: func(title, message string) {
+			if err := notify.Send(notify.EventPingFailed, title, message); err != nil {
+				log.Printf("Pings: failed to send alert: %v", err)
+			}
+		},
+		running: make(map[int64]bool),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start begins the background schedule loop
+func (c *Controller) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick(time.Now())
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the schedule loop and waits for calls in flight
+func (c *Controller) Stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Tick starts the calls of enabled pings whose schedule matched a minute
+// since the last tick, once each. A ping whose previous call is still in
+// flight is skipped.
+func (c *Controller) Tick(now time.Time) {
+	minute := now.Truncate(time.Minute)
+
+	c.mu.Lock()
+	from := c.last.Add(time.Minute)
+	if c.last.IsZero() || minute.Sub(from) > maxCatchUp {
+		from = minute
+	}
+	if minute.Before(from) {
+		c.mu.Unlock()
+		return
+	}
+	c.last = minute
+	c.mu.Unlock()
+
+	pings, err := queryPings(c.db, pingSelect+` WHERE enabled = 1`)
+	if err != nil {
+		log.Printf("Pings: failed to list pings: %v", err)
+		return
+	}
+
+	for _, p := range pings {
+		schedule, err := freeze.ParseSchedule(p.Schedule)
+		if err != nil {
+			continue
+		}
+		due := false
+		for m := from; !m.After(minute); m = m.Add(time.Minute) {
+			if schedule.Matches(m) {
+				due = true
+				break
+			}
+		}
+		if !due || !c.begin(p.ID) {
+			continue
+		}
+
+		c.wg.Add(1)
+		go func(p Ping) {
+			defer c.wg.Done()
+			defer c.end(p.ID)
+			c.finish(&p, c.call(&p, now))
+		}(p)
+	}
+}
+
+// RunNow calls a ping right away, outside its schedule, and returns the run
+func (c *Controller) RunNow(name string) (*Run, error) {
+	p, err := Get(c.db, name)
+	if err != nil {
+		return nil, err
+	}
+	if !c.begin(p.ID) {
+		return nil, ErrRunning
+	}
+	defer c.end(p.ID)
+
+	run := c.call(p, time.Now())
+	c.finish(p, run)
+	return &run, nil
+}
+
+func (c *Controller) begin(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running[id] {
+		return false
+	}
+	c.running[id] = true
+	return true
+}
+
+func (c *Controller) end(id int64) {
+	c.mu.Lock()
+	delete(c.running, id)
+	c.mu.Unlock()
+}
+
+// call makes a ping's request. 2xx and 3xx responses pass.
+func (c *Controller) call(p *Ping, now time.Time) Run {
+	timeout := time.Duration(p.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.fetch(ctx, p.URL, egress.FetchOptions{
+		Method:  p.Method,
+		Headers: p.Headers,
+		Body:    p.Body,
+		Timeout: timeout,
+	})
+	run := Run{RanAt: now.Unix(), DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	run.Status = resp.Status
+	run.OK = resp.Status >= 200 && resp.Status < 400
+	if !run.OK {
+		run.Error = fmt.Sprintf("HTTP %d", resp.Status)
+	}
+	return run
+}
+
+// finish records a run and alerts when the ping changes state
+func (c *Controller) finish(p *Ping, run Run) {
+	prev, _ := lastRun(c.db, p.ID)
+	if err := recordRun(c.db, p.ID, run); err != nil {
+		log.Printf("Pings: failed to record run of %s: %v", p.Name, err)
+		return
+	}
+
+	if prev == nil && run.OK || prev != nil && prev.OK == run.OK {
+		return
+	}
+	if run.OK {
+		c.notify("Ping recovered", fmt.Sprintf("%s (%s %s) is succeeding again", p.Name, p.Method, p.URL))
+	} else {
+		c.notify("Ping failing", fmt.Sprintf("%s (%s %s) failed: %s", p.Name, p.Method, p.URL, run.Error))
+	}
+}
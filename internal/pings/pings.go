@@ -0,0 +1,315 @@
+// Package pings implements scheduled outbound HTTP calls, cron-as-a-service:
+// a URL, method, headers and body called whenever a cron schedule matches
+// (in the server's local time), with no handler to write. Calls go through
+// the egress proxy, so the allowlist, rate limits and net logs apply, and
+// the admin is notified when a ping starts failing or recovers.
+package pings
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/freeze"
+)
+
+const (
+	// DefaultTimeout bounds a call by default
+	DefaultTimeout = 30 * time.Second
+
+	// MaxTimeout is the longest a call may take
+	MaxTimeout = 2 * time.Minute
+
+	// maxHeaders is how many headers a ping may send
+	maxHeaders = 20
+
+	// runsKept is how many runs are kept per ping
+	runsKept = 500
+)
+
+// Common errors
+var (
+	ErrNotFound = errors.New("ping not found")
+	ErrExists   = errors.New("a ping with that name already exists")
+	ErrInvalid  = errors.New("invalid ping")
+	ErrRunning  = errors.New("ping is already running")
+)
+
+// Methods lists the HTTP methods a ping may use
+var Methods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// namePattern matches valid ping names
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Ping is a stored scheduled call
+type Ping struct {
+	ID             int64             `json:"id"`
+	Name           string            `json:"name"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body,omitempty"`
+	Schedule       string            `json:"schedule"`
+	TimeoutSeconds int64             `json:"timeout_seconds"`
+	Enabled        bool              `json:"enabled"`
+	LastRunAt      *int64            `json:"last_run_at,omitempty"`
+	CreatedAt      int64             `json:"created_at"`
+	LastRun        *Run              `json:"last_run,omitempty"`
+}
+
+// Run is the outcome of one call
+type Run struct {
+	OK         bool   `json:"ok"`
+	Status     int    `json:"status,omitempty"` // HTTP status, 0 when there was no response
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	RanAt      int64  `json:"ran_at"`
+}
+
+// Config describes a new ping
+type Config struct {
+	Name     string
+	URL      string
+	Method   string            // default GET
+	Headers  map[string]string // e.g. {"Authorization": "Bearer ..."}
+	Body     string
+	Schedule string        // 5-field cron expression
+	Timeout  time.Duration // default 30s
+}
+
+// Validate checks the config and fills in defaults
+func (c *Config) Validate() error {
+	c.Name = strings.ToLower(strings.TrimSpace(c.Name))
+	if !namePattern.MatchString(c.Name) {
+		return fmt.Errorf("%w: name must be lowercase letters, digits, - and _", ErrInvalid)
+	}
+
+	c.URL = strings.TrimSpace(c.URL)
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an http(s) URL", ErrInvalid)
+	}
+
+	c.Method = strings.ToUpper(strings.TrimSpace(c.Method))
+	if c.Method == "" {
+		c.Method = http.MethodGet
+	}
+	if !validMethod(c.Method) {
+		return fmt.Errorf("%w: method must be one of %s", ErrInvalid, strings.Join(Methods, ", "))
+	}
+	if c.Body != "" && (c.Method == http.MethodGet || c.Method == http.MethodHead) {
+		return fmt.Errorf("%w: %s requests can't have a body", ErrInvalid, c.Method)
+	}
+
+	if len(c.Headers) > maxHeaders {
+		return fmt.Errorf("%w: at most %d headers", ErrInvalid, maxHeaders)
+	}
+	for name := range c.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("%w: invalid header name %q", ErrInvalid, name)
+		}
+	}
+	if c.Headers == nil {
+		c.Headers = map[string]string{}
+	}
+
+	c.Schedule = strings.Join(strings.Fields(c.Schedule), " ")
+	if _, err := freeze.ParseSchedule(c.Schedule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Timeout < time.Second || c.Timeout > MaxTimeout {
+		return fmt.Errorf("%w: timeout must be between 1s and %s", ErrInvalid, MaxTimeout)
+	}
+	return nil
+}
+
+func validMethod(method string) bool {
+	for _, m := range Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Create stores a new ping. Its first call is the next time its schedule
+// matches.
+func Create(db *sql.DB, cfg Config, now time.Time) (*Ping, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	headers, _ := json.Marshal(cfg.Headers)
+	var body interface{}
+	if cfg.Body != "" {
+		body = cfg.Body
+	}
+	_, err := db.Exec(`
+		INSERT INTO pings (name, url, method, headers, body, schedule, timeout_seconds, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, cfg.Name, cfg.URL, cfg.Method, string(headers), body, cfg.Schedule, int64(cfg.Timeout.Seconds()), now.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrExists
+		}
+		return nil, err
+	}
+	return Get(db, cfg.Name)
+}
+
+// Get returns a ping by name, with its latest run
+func Get(db *sql.DB, name string) (*Ping, error) {
+	p, err := scanPing(db.QueryRow(pingSelect+` WHERE name = ?`, strings.ToLower(name)))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.LastRun, _ = lastRun(db, p.ID)
+	return p, nil
+}
+
+// List returns all pings with their latest run
+func List(db *sql.DB) ([]Ping, error) {
+	pings, err := queryPings(db, pingSelect+` ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pings {
+		pings[i].LastRun, _ = lastRun(db, pings[i].ID)
+	}
+	return pings, nil
+}
+
+// SetEnabled pauses or resumes a ping
+func SetEnabled(db *sql.DB, name string, enabled bool) (*Ping, error) {
+	res, err := db.Exec(`UPDATE pings SET enabled = ? WHERE name = ?`, enabled, strings.ToLower(name))
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return Get(db, name)
+}
+
+// Delete removes a ping and its runs
+func Delete(db *sql.DB, name string) error {
+	p, err := Get(db, name)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM pings WHERE id = ?`, p.ID); err != nil {
+		return err
+	}
+	db.Exec(`DELETE FROM ping_runs WHERE ping_id = ?`, p.ID)
+	return nil
+}
+
+// ListRuns returns a ping's runs, newest first
+func ListRuns(db *sql.DB, id int64, limit int) ([]Run, error) {
+	rows, err := db.Query(`
+		SELECT ok, COALESCE(status, 0), COALESCE(error, ''), COALESCE(duration_ms, 0), ran_at FROM ping_runs
+		WHERE ping_id = ? ORDER BY ran_at DESC, id DESC LIMIT ?
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var run Run
+		if err := rows.Scan(&run.OK, &run.Status, &run.Error, &run.DurationMs, &run.RanAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+const pingSelect = `
+	SELECT id, name, url, method, headers, COALESCE(body, ''), schedule, timeout_seconds, enabled, last_run_at, created_at
+	FROM pings`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPing(row rowScanner) (*Ping, error) {
+	var p Ping
+	var headers string
+	var lastRun sql.NullInt64
+	if err := row.Scan(&p.ID, &p.Name, &p.URL, &p.Method, &headers, &p.Body, &p.Schedule, &p.TimeoutSeconds,
+		&p.Enabled, &lastRun, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(headers), &p.Headers); err != nil || p.Headers == nil {
+		p.Headers = map[string]string{}
+	}
+	if lastRun.Valid {
+		p.LastRunAt = &lastRun.Int64
+	}
+	return &p, nil
+}
+
+func queryPings(db *sql.DB, query string, args ...interface{}) ([]Ping, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pings := []Ping{}
+	for rows.Next() {
+		p, err := scanPing(rows)
+		if err != nil {
+			return nil, err
+		}
+		pings = append(pings, *p)
+	}
+	return pings, rows.Err()
+}
+
+func lastRun(db *sql.DB, id int64) (*Run, error) {
+	runs, err := ListRuns(db, id, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// recordRun stores a run, marks when the ping last ran, and trims old runs
+func recordRun(db *sql.DB, id int64, run Run) error {
+	var status, errMsg interface{}
+	if run.Status != 0 {
+		status = run.Status
+	}
+	if run.Error != "" {
+		errMsg = run.Error
+	}
+	if _, err := db.Exec(`
+		INSERT INTO ping_runs (ping_id, ok, status, error, duration_ms, ran_at) VALUES (?, ?, ?, ?, ?, ?)
+	`, id, run.OK, status, errMsg, run.DurationMs, run.RanAt); err != nil {
+		return err
+	}
+	db.Exec(`UPDATE pings SET last_run_at = ? WHERE id = ?`, run.RanAt, id)
+	db.Exec(`
+		DELETE FROM ping_runs WHERE ping_id = ? AND id NOT IN (
+			SELECT id FROM ping_runs WHERE ping_id = ? ORDER BY ran_at DESC, id DESC LIMIT ?)
+	`, id, id, runsKept)
+	return nil
+}
@@ -0,0 +1,164 @@
+package pings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+func TestCreate(t *testing.T) {
+	db := dbtest.Open(t)
+	now := time.Now()
+
+	p, err := Create(db, Config{
+		Name:     "Keep-Awake",
+		URL:      "https://api.example.com/health",
+		Headers:  map[string]string{"Authorization": "Bearer abc"},
+		Schedule: "*/10  * * * *",
+	}, now)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if p.Name != "keep-awake" || p.Method != "GET" || p.Schedule != "*/10 * * * *" || p.TimeoutSeconds != 30 ||
+		!p.Enabled || p.Headers["Authorization"] != "Bearer abc" {
+		t.Errorf("Unexpected ping: %+v", p)
+	}
+
+	if _, err := Create(db, Config{Name: "keep-awake", URL: "https://example.com", Schedule: "* * * * *"}, now); !errors.Is(err, ErrExists) {
+		t.Errorf("Expected ErrExists, got %v", err)
+	}
+
+	invalid := map[string]Config{
+		"name":     {Name: "has space", URL: "https://example.com", Schedule: "* * * * *"},
+		"url":      {Name: "x", URL: "ftp://example.com", Schedule: "* * * * *"},
+		"method":   {Name: "x", URL: "https://example.com", Method: "TRACE", Schedule: "* * * * *"},
+		"get body": {Name: "x", URL: "https://example.com", Body: "{}", Schedule: "* * * * *"},
+		"header":   {Name: "x", URL: "https://example.com", Headers: map[string]string{"Bad Name": "v"}, Schedule: "* * * * *"},
+		"schedule": {Name: "x", URL: "https://example.com", Schedule: "every minute"},
+		"timeout":  {Name: "x", URL: "https://example.com", Schedule: "* * * * *", Timeout: 5 * time.Minute},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+		}
+	}
+
+	if p, err := SetEnabled(db, "keep-awake", false); err != nil || p.Enabled {
+		t.Errorf("Expected the ping paused, got %+v, %v", p, err)
+	}
+	if err := Delete(db, "keep-awake"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+	if _, err := Get(db, "keep-awake"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+// testController returns a controller whose calls answer with status and
+// whose alerts are collected
+func testController(t *testing.T, db *sql.DB, status *int) (*Controller, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var alerts []string
+	c := &Controller{
+		db: db,
+		fetch: func(ctx context.Context, rawURL string, opts egress.FetchOptions) (*egress.FetchResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if *status == 0 {
+				return nil, errors.New("NET_TIMEOUT: request timed out")
+			}
+			return &egress.FetchResponse{Status: *status, OK: *status < 300}, nil
+		},
+		notify: func(title, message string) {
+			mu.Lock()
+			alerts = append(alerts, title)
+			mu.Unlock()
+		},
+		running: make(map[int64]bool),
+		done:    make(chan struct{}),
+	}
+	return c, &alerts
+}
+
+func TestControllerTick(t *testing.T) {
+	db := dbtest.Open(t)
+	status := 200
+	c, _ := testController(t, db, &status)
+
+	// 2026-10-16 09:00 local
+	nine := time.Date(2026, 10, 16, 9, 0, 10, 0, time.Local)
+	Create(db, Config{Name: "hourly", URL: "https://example.com/a", Schedule: "0 * * * *"}, nine)
+	Create(db, Config{Name: "half", URL: "https://example.com/b", Schedule: "30 * * * *"}, nine)
+
+	c.Tick(nine)
+	c.Tick(nine.Add(20 * time.Second)) // Same minute: not again
+	c.wg.Wait()
+
+	hourly, _ := Get(db, "hourly")
+	half, _ := Get(db, "half")
+	runs, _ := ListRuns(db, hourly.ID, 10)
+	if len(runs) != 1 || !runs[0].OK || runs[0].Status != 200 {
+		t.Errorf("Expected one passing run of hourly, got %+v", runs)
+	}
+	if half.LastRun != nil {
+		t.Errorf("Expected half not to run at 9:00, got %+v", half.LastRun)
+	}
+
+	// A stalled controller catches up on the minutes it missed
+	c.Tick(nine.Add(27 * time.Minute))
+	c.Tick(nine.Add(32 * time.Minute))
+	c.wg.Wait()
+	if runs, _ := ListRuns(db, half.ID, 10); len(runs) != 1 {
+		t.Errorf("Expected half to run once for 9:30, got %d runs", len(runs))
+	}
+
+	// Paused pings don't run
+	SetEnabled(db, "hourly", false)
+	c.Tick(nine.Add(time.Hour))
+	c.wg.Wait()
+	if runs, _ := ListRuns(db, hourly.ID, 10); len(runs) != 1 {
+		t.Errorf("Expected a paused ping not to run, got %d runs", len(runs))
+	}
+}
+
+func TestControllerAlerts(t *testing.T) {
+	db := dbtest.Open(t)
+	status := 200
+	c, alerts := testController(t, db, &status)
+	Create(db, Config{Name: "hook", URL: "https://example.com/hook", Method: "POST", Body: "{}", Schedule: "0 3 * * *"}, time.Now())
+
+	run, err := c.RunNow("hook")
+	if err != nil || !run.OK {
+		t.Fatalf("Expected a passing run, got %+v, %v", run, err)
+	}
+
+	status = 503
+	run, _ = c.RunNow("hook")
+	if run.OK || run.Status != 503 || run.Error != "HTTP 503" {
+		t.Errorf("Unexpected failed run: %+v", run)
+	}
+	status = 0
+	run, _ = c.RunNow("hook")
+	if run.OK || run.Error == "" {
+		t.Errorf("Expected a failed run without a response, got %+v", run)
+	}
+	status = 204
+	c.RunNow("hook")
+
+	// Alerts only on changes: failing once, recovered once
+	if len(*alerts) != 2 || (*alerts)[0] != "Ping failing" || (*alerts)[1] != "Ping recovered" {
+		t.Errorf("Unexpected alerts: %v", *alerts)
+	}
+
+	if _, err := c.RunNow("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the spec's worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	tests := map[int][]int{
+		1:  nil,
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, want := range tests {
+		if got := alignmentPositions(version); !reflect.DeepEqual(got, want) {
+			t.Errorf("alignmentPositions(%d) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestNumDataCodewords(t *testing.T) {
+	tests := map[int]int{1: 16, 2: 28, 7: 124, 10: 216, 40: 2334}
+	for version, want := range tests {
+		if got := numDataCodewords(version); got != want {
+			t.Errorf("numDataCodewords(%d) = %d, want %d", version, got, want)
+		}
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	c := &Code{Size: 45, version: 7, modules: newGrid(45), isFunction: newGrid(45)}
+	c.drawFormatBits(0)
+	c.drawVersion()
+
+	// Level M, mask 0 is 101010000010010; bit 14 sits at (0, 8)
+	var format strings.Builder
+	for x := 0; x <= 5; x++ {
+		format.WriteByte(moduleChar(c.Dark(x, 8)))
+	}
+	format.WriteByte(moduleChar(c.Dark(7, 8)))
+	format.WriteByte(moduleChar(c.Dark(8, 8)))
+	format.WriteByte(moduleChar(c.Dark(8, 7)))
+	for y := 5; y >= 0; y-- {
+		format.WriteByte(moduleChar(c.Dark(8, y)))
+	}
+	if got := format.String(); got != "101010000010010" {
+		t.Errorf("format bits = %s, want 101010000010010", got)
+	}
+
+	// Version 7 is 000111110010010100; bit 17 sits at (5, Size-9)
+	var version strings.Builder
+	for i := 17; i >= 0; i-- {
+		version.WriteByte(moduleChar(c.Dark(i/3, c.Size-11+i%3)))
+	}
+	if got := version.String(); got != "000111110010010100" {
+		t.Errorf("version bits = %s, want 000111110010010100", got)
+	}
+}
+
+func moduleChar(dark bool) byte {
+	if dark {
+		return '1'
+	}
+	return '0'
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		length, size int
+	}{
+		{1, 21},
+		{14, 21},
+		{15, 25},
+		{100, 41},
+		{2331, 177},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte("a"), tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", tt.length, err)
+		}
+		if c.Size != tt.size {
+			t.Errorf("Encode(%d bytes) size = %d, want %d", tt.length, c.Size, tt.size)
+		}
+		// Finder pattern corners and the always-dark module
+		if !c.Dark(0, 0) || !c.Dark(c.Size-1, 0) || !c.Dark(0, c.Size-1) || c.Dark(7, 7) || !c.Dark(8, c.Size-8) {
+			t.Errorf("Encode(%d bytes): function patterns misplaced", tt.length)
+		}
+	}
+
+	if _, err := Encode(make([]byte, 2332)); err != ErrTooLong {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode([]byte("https://admin.example.com/r/launch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := c.PNG(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+
+	modules := c.Size + 2*quietZone
+	scale := 256 / modules
+	if w := img.Bounds().Dx(); w != modules*scale {
+		t.Errorf("Width = %d, want %d", w, modules*scale)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("Expected a light quiet zone")
+	}
+	if r, _, _, _ := img.At(quietZone*scale, quietZone*scale).RGBA(); r != 0 {
+		t.Error("Expected the top-left finder to be dark")
+	}
+}
@@ -0,0 +1,140 @@
+// Package realip resolves the client IP of a request. X-Forwarded-For and
+// X-Real-IP are only honored when the connection comes from a trusted proxy
+// (server.trusted_proxies); otherwise anyone could pick their own IP and
+// step around per-IP rate limits and audit logs.
+package realip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+type contextKey struct{}
+
+var trusted = struct {
+	sync.RWMutex
+	prefixes []netip.Prefix
+}{}
+
+// ParseTrustedProxies parses CIDRs or bare IPs, such as "10.0.0.0/8" or
+// "127.0.0.1"
+func ParseTrustedProxies(list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", s)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", s)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SetTrustedProxies replaces the proxies whose forwarding headers are
+// honored. An empty list trusts no one, so the connection's address is
+// always used.
+func SetTrustedProxies(list []string) error {
+	prefixes, err := ParseTrustedProxies(list)
+	if err != nil {
+		return err
+	}
+	trusted.Lock()
+	trusted.prefixes = prefixes
+	trusted.Unlock()
+	return nil
+}
+
+func isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	trusted.RLock()
+	defer trusted.RUnlock()
+	for _, p := range trusted.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware resolves the client IP once and stores it in the request
+// context for FromRequest
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKey{}, Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromRequest returns the client IP resolved by Middleware, resolving it
+// now for requests that didn't pass through it
+func FromRequest(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKey{}).(string); ok {
+		return ip
+	}
+	return Resolve(r)
+}
+
+// Resolve returns the client IP. Starting from the connection's address,
+// X-Forwarded-For is walked right to left while each hop is a trusted
+// proxy; the first untrusted address is the client. X-Real-IP is used when
+// a trusted proxy sends no X-Forwarded-For.
+func Resolve(r *http.Request) string {
+	ip := remoteIP(r)
+	if !isTrusted(ip) {
+		return ip
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if _, err := netip.ParseAddr(hop); err != nil {
+				// Garbage from an untrusted hop; stop at the last good address
+				break
+			}
+			ip = hop
+			if !isTrusted(hop) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		if _, err := netip.ParseAddr(xri); err == nil {
+			return xri
+		}
+	}
+	return ip
+}
+
+// remoteIP returns the host part of RemoteAddr
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
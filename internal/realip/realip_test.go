@@ -0,0 +1,87 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "127.0.0.1", "::1"}); err != nil {
+		t.Fatalf("SetTrustedProxies failed: %v", err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		xri    string
+		want   string
+	}{
+		{"no headers", "203.0.113.9:1234", "", "", "203.0.113.9"},
+		{"untrusted peer ignores XFF", "203.0.113.9:1234", "1.2.3.4", "", "203.0.113.9"},
+		{"untrusted peer ignores X-Real-IP", "203.0.113.9:1234", "", "1.2.3.4", "203.0.113.9"},
+		{"trusted peer", "127.0.0.1:1234", "1.2.3.4", "", "1.2.3.4"},
+		{"trusted IPv6 peer", "[::1]:1234", "1.2.3.4", "", "1.2.3.4"},
+		{"spoofed leftmost entry", "127.0.0.1:1234", "6.6.6.6, 1.2.3.4", "", "1.2.3.4"},
+		{"trusted hops skipped", "10.0.0.1:1234", "1.2.3.4, 10.0.0.7, 10.0.0.8", "", "1.2.3.4"},
+		{"all hops trusted", "10.0.0.1:1234", "10.0.0.5", "", "10.0.0.5"},
+		{"garbage hop", "127.0.0.1:1234", "1.2.3.4, nonsense", "", "127.0.0.1"},
+		{"X-Real-IP from trusted peer", "127.0.0.1:1234", "", "1.2.3.4", "1.2.3.4"},
+		{"XFF wins over X-Real-IP", "127.0.0.1:1234", "1.1.1.1", "2.2.2.2", "1.1.1.1"},
+		{"no port", "203.0.113.9", "1.2.3.4", "", "203.0.113.9"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.xff != "" {
+			req.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if tt.xri != "" {
+			req.Header.Set("X-Real-IP", tt.xri)
+		}
+		if got := Resolve(req); got != tt.want {
+			t.Errorf("%s: Resolve = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNoTrustedProxies(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	if got := FromRequest(req); got != "127.0.0.1" {
+		t.Errorf("Expected forwarding headers ignored by default, got %s", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	SetTrustedProxies([]string{"127.0.0.0/8"})
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	var got string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Later header changes don't affect the resolved IP
+		r.Header.Set("X-Forwarded-For", "6.6.6.6")
+		got = FromRequest(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "1.2.3.4" {
+		t.Errorf("Expected 1.2.3.4, got %s", got)
+	}
+}
+
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	for _, bad := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0"} {
+		if err := SetTrustedProxies([]string{bad}); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -43,6 +44,11 @@ type StatusResponse struct {
 	Database struct {
 		OpenConnections int `json:"open_connections"`
 		InUse           int `json:"in_use"`
+		WriteQueue      struct {
+			QueueDepth    int   `json:"queue_depth"`
+			QueueCapacity int   `json:"queue_capacity"`
+			Rejected      int64 `json:"rejected"`
+		} `json:"write_queue"`
 	} `json:"database"`
 	Runtime struct {
 		Goroutines int `json:"goroutines"`
@@ -72,10 +78,24 @@ type UpgradeResponse struct {
 
 // DeployResponse represents the /api/deploy response
 type DeployResponse struct {
-	Site      string `json:"site"`
-	FileCount int    `json:"file_count"`
-	SizeBytes int64  `json:"size_bytes"`
-	Message   string `json:"message"`
+	Site          string `json:"site"`
+	FileCount     int    `json:"file_count"`
+	SizeBytes     int64  `json:"size_bytes"`
+	Precompressed int    `json:"precompressed"`
+	Message       string `json:"message"`
+
+	// Secrets are likely credentials the server found and deployed anyway
+	Secrets []DeploySecret `json:"secrets,omitempty"`
+
+	DeploymentID string      `json:"deployment_id,omitempty"`
+	Hook         *DeployHook `json:"hook,omitempty"` // The app.deployed hook, for background deploys
+}
+
+// DeploySecret is a likely credential found in a deployed file
+type DeploySecret struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Line int    `json:"line,omitempty"`
 }
 
 // APIResponse wraps the standard API response format
@@ -90,6 +110,10 @@ type APIError struct {
 	Message string `json:"message"`
 }
 
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
 // Status checks the health of the remote peer
 func (c *Client) Status() (*StatusResponse, error) {
 	resp, err := c.doRequest("GET", "/api/system/health", nil)
@@ -126,29 +150,48 @@ func (c *Client) HealthCheck() (bool, error) {
 	return resp.StatusCode == http.StatusOK, nil
 }
 
-// Apps lists all apps on the remote peer
+// Apps lists all apps on the remote peer, following the list's pages
 func (c *Client) Apps() ([]App, error) {
-	resp, err := c.doRequest("GET", "/api/apps", nil)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	var apps []App
+	cursor := ""
+	for {
+		path := "/api/apps?limit=500"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		resp, err := c.doRequest("GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
+		var apiResp struct {
+			APIResponse
+			Meta struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"meta"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&apiResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
 
-	if apiResp.Error != nil {
-		return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
-	}
+		if apiResp.Error != nil {
+			return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+		}
 
-	var apps []App
-	if err := json.Unmarshal(apiResp.Data, &apps); err != nil {
-		return nil, fmt.Errorf("failed to decode apps: %w", err)
-	}
+		var page []App
+		if err := json.Unmarshal(apiResp.Data, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode apps: %w", err)
+		}
+		apps = append(apps, page...)
 
-	return apps, nil
+		// Servers before cursors answer everything at once
+		if apiResp.Meta.NextCursor == "" {
+			return apps, nil
+		}
+		cursor = apiResp.Meta.NextCursor
+	}
 }
 
 // Upgrade checks for or performs an upgrade
@@ -263,7 +306,10 @@ func (c *Client) Deploy(zipPath, siteName string) (*DeployResponse, error) {
 
 // DeployOptions configures deployment behavior
 type DeployOptions struct {
-	SPA bool // Enable SPA routing (clean URLs)
+	SPA         bool // Enable SPA routing (clean URLs)
+	Analytics   *bool // Turn analytics snippet injection on or off; nil keeps the app's setting
+	Precompress bool // Store .br and .gz variants of static files
+	Emergency   bool // Deploy during a freeze window (audited)
 }
 
 // DeployWithOptions deploys a ZIP file with additional options
@@ -291,6 +337,27 @@ func (c *Client) DeployWithOptions(zipPath, siteName string, opts *DeployOptions
 		}
 	}
 
+	// Add analytics field if injection is turned on or off
+	if opts != nil && opts.Analytics != nil {
+		if err := writer.WriteField("analytics", strconv.FormatBool(*opts.Analytics)); err != nil {
+			return nil, fmt.Errorf("failed to write analytics: %w", err)
+		}
+	}
+
+	// Add precompress field if enabled
+	if opts != nil && opts.Precompress {
+		if err := writer.WriteField("precompress", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write precompress: %w", err)
+		}
+	}
+
+	// Add emergency field to deploy through a freeze window
+	if opts != nil && opts.Emergency {
+		if err := writer.WriteField("emergency", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write emergency: %w", err)
+		}
+	}
+
 	// Add file
 	part, err := writer.CreateFormFile("file", filepath.Base(zipPath))
 	if err != nil {
@@ -518,6 +585,36 @@ func (c *Client) GetAppFileContent(appName, filePath string) ([]byte, error) {
 	return io.ReadAll(resp.Body)
 }
 
+// ExportStaticSite downloads an app as a ZIP of static files, with the
+// given routes rendered besides those its manifest lists
+func (c *Client) ExportStaticSite(appName string, routes []string) ([]byte, error) {
+	query := url.Values{}
+	for _, r := range routes {
+		query.Add("route", r)
+	}
+	path := "/api/apps/" + url.PathEscape(appName) + "/export"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	resp, err := c.doRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The ZIP is returned directly, not wrapped in API response
+	if resp.StatusCode != http.StatusOK {
+		var apiResp APIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiResp); err == nil && apiResp.Error != nil {
+			return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+		}
+		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
 // ProviderConfig represents an OAuth provider configuration
 type ProviderConfig struct {
 	Name        string `json:"name"`
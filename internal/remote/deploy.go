@@ -0,0 +1,240 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	deployPollInterval = 500 * time.Millisecond
+	deployTimeout      = 30 * time.Minute
+	hookWait           = 2 * time.Minute
+)
+
+// DeployProgress is reported while a background deploy runs
+type DeployProgress struct {
+	Stage        string // uploading, queued, writing, finishing, hook
+	Sent, Total  int64  // Bundle bytes uploaded
+	FilesWritten int
+	FilesTotal   int
+	Hook         *DeployHook
+}
+
+// DeployHook is the job an app's app.deployed hook runs as
+type DeployHook struct {
+	Handler string `json:"handler"`
+	JobID   string `json:"job_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// deployStatus is what GET /api/deploy/{id} returns
+type deployStatus struct {
+	Deploy struct {
+		Status       string          `json:"status"`
+		Stage        string          `json:"stage"`
+		FilesWritten int             `json:"files_written"`
+		FilesTotal   int             `json:"files_total"`
+		Result       json.RawMessage `json:"result"`
+		Error        string          `json:"error"`
+	} `json:"deploy"`
+	Hook     *DeployHook    `json:"hook"`
+	Findings []DeploySecret `json:"findings"`
+}
+
+// DeployAsync uploads a ZIP and lets the server deploy it in the background,
+// polling until it's done. progress, if set, is called as the bundle
+// uploads, as files are written, and while the app's app.deployed hook
+// runs. Servers without background deploys answer with the result directly.
+func (c *Client) DeployAsync(zipPath, siteName string, opts *DeployOptions, progress func(DeployProgress)) (*DeployResponse, error) {
+	if progress == nil {
+		progress = func(DeployProgress) {}
+	}
+
+	body, contentType, err := deployForm(zipPath, siteName, opts, map[string]string{"async": "true"})
+	if err != nil {
+		return nil, err
+	}
+	total := int64(body.Len())
+
+	req, err := http.NewRequest("POST", c.peer.URL+"/api/deploy", &progressReader{r: body, total: total, progress: progress})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = total
+	req.Header.Set("Content-Type", contentType)
+	if c.peer.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.peer.Token)
+	}
+
+	resp, err := (&http.Client{Timeout: uploadChunkTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return decodeDeploy(resp.Body)
+	case http.StatusAccepted:
+	default:
+		return nil, decodeAPIError(resp)
+	}
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	var accepted struct {
+		DeploymentID string `json:"deployment_id"`
+	}
+	if err := json.Unmarshal(apiResp.Data, &accepted); err != nil || accepted.DeploymentID == "" {
+		return nil, errors.New("server did not return a deployment ID")
+	}
+	return c.waitForBackgroundDeploy(accepted.DeploymentID, progress)
+}
+
+// waitForBackgroundDeploy polls a deploy until it finishes, then waits a
+// while for its app.deployed hook
+func (c *Client) waitForBackgroundDeploy(id string, progress func(DeployProgress)) (*DeployResponse, error) {
+	deadline := time.Now().Add(deployTimeout)
+	var deploy *DeployResponse
+	var failures int
+	for {
+		status, err := c.deployStatus(id)
+		if err != nil {
+			if failures++; failures > uploadRetries {
+				return nil, err
+			}
+		} else {
+			failures = 0
+			d := status.Deploy
+			switch d.Status {
+			case "failed":
+				if len(status.Findings) > 0 {
+					return nil, fmt.Errorf("SECRETS_FOUND: %s", d.Error)
+				}
+				return nil, fmt.Errorf("deploy failed: %s", d.Error)
+			case "done":
+				if deploy == nil {
+					deploy = &DeployResponse{}
+					if err := json.Unmarshal(d.Result, deploy); err != nil {
+						return nil, fmt.Errorf("failed to decode deploy response: %w", err)
+					}
+					deadline = time.Now().Add(hookWait)
+				}
+				deploy.Hook = status.Hook
+				if status.Hook == nil || status.Hook.Status == "done" || status.Hook.Status == "failed" || status.Hook.Status == "cancelled" {
+					return deploy, nil
+				}
+				progress(DeployProgress{Stage: "hook", FilesWritten: d.FilesWritten, FilesTotal: d.FilesTotal, Hook: status.Hook})
+			default:
+				progress(DeployProgress{Stage: d.Stage, FilesWritten: d.FilesWritten, FilesTotal: d.FilesTotal})
+			}
+		}
+
+		if time.Now().After(deadline) {
+			if deploy != nil {
+				// The deploy is done; the hook just hasn't finished yet
+				return deploy, nil
+			}
+			return nil, fmt.Errorf("timed out waiting for deploy %s", id)
+		}
+		time.Sleep(deployPollInterval)
+	}
+}
+
+// deployStatus fetches GET /api/deploy/{id}
+func (c *Client) deployStatus(id string) (*deployStatus, error) {
+	resp, err := c.doRequest("GET", "/api/deploy/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResp.Error != nil {
+		return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	var status deployStatus
+	if err := json.Unmarshal(apiResp.Data, &status); err != nil {
+		return nil, fmt.Errorf("failed to decode deploy status: %w", err)
+	}
+	return &status, nil
+}
+
+// deployForm builds the multipart body of a deploy
+func deployForm(zipPath, siteName string, opts *DeployOptions, extra map[string]string) (*bytes.Buffer, string, error) {
+	file, err := os.Open(zipPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open zip file: %w", err)
+	}
+	defer file.Close()
+
+	fields := map[string]string{"site_name": siteName}
+	if opts != nil {
+		if opts.SPA {
+			fields["spa"] = "true"
+		}
+		if opts.Analytics != nil {
+			fields["analytics"] = strconv.FormatBool(*opts.Analytics)
+		}
+		if opts.Precompress {
+			fields["precompress"] = "true"
+		}
+		if opts.Emergency {
+			fields["emergency"] = "true"
+		}
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return nil, "", fmt.Errorf("failed to write %s: %w", k, err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", filepath.Base(zipPath))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, "", fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to close writer: %w", err)
+	}
+	return &buf, writer.FormDataContentType(), nil
+}
+
+// progressReader reports upload progress as the request body is read
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress func(DeployProgress)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	if n > 0 {
+		p.progress(DeployProgress{Stage: "uploading", Sent: p.sent, Total: p.total})
+	}
+	return n, err
+}
@@ -0,0 +1,41 @@
+package remote
+
+import (
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeployFormAnalytics(t *testing.T) {
+	zipPath := filepath.Join(t.TempDir(), "site.zip")
+	if err := os.WriteFile(zipPath, []byte("zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	on, off := true, false
+	tests := []struct {
+		analytics *bool
+		want      string
+	}{
+		{nil, ""}, // keeps the app's setting
+		{&on, "true"},
+		{&off, "false"},
+	}
+
+	for _, tt := range tests {
+		body, contentType, err := deployForm(zipPath, "blog", &DeployOptions{Analytics: tt.analytics}, nil)
+		if err != nil {
+			t.Fatalf("deployForm failed: %v", err)
+		}
+		_, params, _ := mime.ParseMediaType(contentType)
+		form, err := multipart.NewReader(body, params["boundary"]).ReadForm(1 << 20)
+		if err != nil {
+			t.Fatalf("Failed to parse form: %v", err)
+		}
+		if got := form.Value["analytics"]; (tt.want == "" && len(got) != 0) || (tt.want != "" && (len(got) != 1 || got[0] != tt.want)) {
+			t.Errorf("analytics %v: got field %v, want %q", tt.analytics, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package remote
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResumableThreshold is the bundle size above which deploys are uploaded
+// in resumable chunks rather than in a single request
+const ResumableThreshold = 16 << 20
+
+const (
+	tusVersion         = "1.0.0"
+	uploadChunkSize    = 5 << 20
+	uploadRetries      = 5
+	uploadChunkTimeout = 5 * time.Minute
+)
+
+// uploadInfo is an upload as GET /api/uploads/{id} returns it
+type uploadInfo struct {
+	Received int64           `json:"received"`
+	Size     int64           `json:"size"`
+	State    string          `json:"state"`
+	Result   json.RawMessage `json:"result"`
+}
+
+// DeployResumable deploys a ZIP through the tus upload endpoint. Chunks
+// that fail are retried from wherever the server got to, so a flaky link
+// only resends what was lost. progress, if set, is called after every chunk.
+func (c *Client) DeployResumable(zipPath, siteName string, opts *DeployOptions, progress func(sent, total int64)) (*DeployResponse, error) {
+	file, err := os.Open(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat zip file: %w", err)
+	}
+	size := info.Size()
+
+	meta := map[string]string{
+		"kind":      "deploy",
+		"site_name": siteName,
+		"filename":  filepath.Base(zipPath),
+	}
+	if opts != nil {
+		if opts.SPA {
+			meta["spa"] = "true"
+		}
+		if opts.Analytics != nil {
+			meta["analytics"] = strconv.FormatBool(*opts.Analytics)
+		}
+		if opts.Precompress {
+			meta["precompress"] = "true"
+		}
+		if opts.Emergency {
+			meta["emergency"] = "true"
+		}
+	}
+
+	location, err := c.createUpload(size, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: uploadChunkTimeout}
+	var offset int64
+	for attempt := 0; ; {
+		end := offset + uploadChunkSize
+		if end > size {
+			end = size
+		}
+		chunk := io.NewSectionReader(file, offset, end-offset)
+
+		req, err := http.NewRequest(http.MethodPatch, location, chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.ContentLength = end - offset
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		req.Header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		c.setUploadHeaders(req)
+
+		resp, err := client.Do(req)
+		if err == nil {
+			switch {
+			case resp.StatusCode == http.StatusOK:
+				// The last chunk: the deploy ran and this is its result
+				defer resp.Body.Close()
+				if progress != nil {
+					progress(size, size)
+				}
+				return decodeDeploy(resp.Body)
+			case resp.StatusCode == http.StatusNoContent:
+				resp.Body.Close()
+				offset, _ = strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+				attempt = 0
+				if progress != nil {
+					progress(offset, size)
+				}
+				continue
+			}
+			var retry bool
+			retry, err = decodeUploadError(resp)
+			resp.Body.Close()
+			if !retry {
+				return nil, err
+			}
+		}
+
+		attempt++
+		if attempt > uploadRetries {
+			return nil, fmt.Errorf("upload failed after %d retries: %w", uploadRetries, err)
+		}
+		time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+
+		// Resume from whatever the server kept
+		upload, headErr := c.uploadStatus(location)
+		if headErr != nil {
+			continue
+		}
+		if upload.State != "uploading" {
+			// Every byte arrived but the response was lost
+			return c.waitForDeploy(location)
+		}
+		offset = upload.Received
+		if progress != nil {
+			progress(offset, size)
+		}
+	}
+}
+
+// createUpload starts a tus upload and returns its URL
+func (c *Client) createUpload(size int64, meta map[string]string) (string, error) {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + " " + base64.StdEncoding.EncodeToString([]byte(meta[k]))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.peer.URL+"/api/uploads", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	req.Header.Set("Upload-Metadata", strings.Join(pairs, ","))
+	c.setUploadHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return "", decodeAPIError(resp)
+	}
+
+	base, err := url.Parse(c.peer.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer URL: %w", err)
+	}
+	location, err := base.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return "", errors.New("server did not return an upload location")
+	}
+	return location.String(), nil
+}
+
+// uploadStatus fetches an upload's progress and, once complete, its result
+func (c *Client) uploadStatus(location string) (*uploadInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setUploadHeaders(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResp.Error != nil {
+		return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	var upload uploadInfo
+	if err := json.Unmarshal(apiResp.Data, &upload); err != nil {
+		return nil, fmt.Errorf("failed to decode upload: %w", err)
+	}
+	return &upload, nil
+}
+
+// waitForDeploy polls a complete upload until its deploy has finished
+func (c *Client) waitForDeploy(location string) (*DeployResponse, error) {
+	deadline := time.Now().Add(uploadChunkTimeout)
+	for {
+		upload, err := c.uploadStatus(location)
+		if err != nil {
+			return nil, err
+		}
+		switch upload.State {
+		case "done":
+			var deploy DeployResponse
+			if err := json.Unmarshal(upload.Result, &deploy); err != nil {
+				return nil, fmt.Errorf("failed to decode deploy response: %w", err)
+			}
+			return &deploy, nil
+		case "failed":
+			var failure struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal(upload.Result, &failure)
+			return nil, fmt.Errorf("deploy failed: %s", failure.Error)
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("timed out waiting for the deploy to finish")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (c *Client) setUploadHeaders(req *http.Request) {
+	req.Header.Set("Tus-Resumable", tusVersion)
+	if c.peer.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.peer.Token)
+	}
+}
+
+// decodeDeploy reads a deploy response envelope
+func decodeDeploy(body io.Reader) (*DeployResponse, error) {
+	var apiResp APIResponse
+	if err := json.NewDecoder(body).Decode(&apiResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResp.Error != nil {
+		return nil, fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	var deploy DeployResponse
+	if err := json.Unmarshal(apiResp.Data, &deploy); err != nil {
+		return nil, fmt.Errorf("failed to decode deploy response: %w", err)
+	}
+	return &deploy, nil
+}
+
+// decodeUploadError turns a failed chunk's response into an error and
+// reports whether resuming the upload may get past it
+func decodeUploadError(resp *http.Response) (retry bool, err error) {
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil || apiResp.Error == nil {
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	err = fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	switch apiResp.Error.Code {
+	case "UPLOAD_INTERRUPTED", "OFFSET_MISMATCH", "UPLOAD_LOCKED":
+		return true, err
+	}
+	return resp.StatusCode >= 500, err
+}
+
+// decodeAPIError turns an error response into an error
+func decodeAPIError(resp *http.Response) error {
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err == nil && apiResp.Error != nil {
+		return fmt.Errorf("%s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	return fmt.Errorf("unexpected status %s", resp.Status)
+}
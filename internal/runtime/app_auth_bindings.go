@@ -0,0 +1,105 @@
+package runtime
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/dop251/goja"
+)
+
+// InjectAppAuthNamespace adds fazt.app.auth.* functions to a Goja VM.
+// These cover the app's own end-users, who sign in through the OAuth
+// providers configured for the app (fazt app auth enable).
+func InjectAppAuthNamespace(vm *goja.Runtime, authCtx *AuthContext, app *AppContext) error {
+	// Get or create fazt object
+	faztVal := vm.Get("fazt")
+	var fazt *goja.Object
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		fazt = vm.NewObject()
+		vm.Set("fazt", fazt)
+	} else {
+		fazt = faztVal.ToObject(vm)
+	}
+
+	// Get or create fazt.app object
+	appVal := fazt.Get("app")
+	var appObj *goja.Object
+	if appVal == nil || goja.IsUndefined(appVal) {
+		appObj = vm.NewObject()
+		fazt.Set("app", appObj)
+	} else {
+		appObj = appVal.ToObject(vm)
+	}
+
+	var profile map[string]interface{}
+	if authCtx != nil {
+		profile, _ = authCtx.AppUser.(map[string]interface{})
+	}
+
+	loginURL := func(provider, redirect string) string {
+		params := url.Values{}
+		if provider != "" {
+			params.Set("provider", provider)
+		}
+		params.Set("redirect", redirect)
+		return fmt.Sprintf("/auth/app/%s/login?%s", app.ID, params.Encode())
+	}
+
+	authObj := vm.NewObject()
+
+	// fazt.app.auth.currentUser() - returns the signed-in app user's profile or null
+	authObj.Set("currentUser", func(call goja.FunctionCall) goja.Value {
+		if profile == nil {
+			return goja.Null()
+		}
+		return vm.ToValue(map[string]interface{}{
+			"id":       profile["id"],
+			"email":    profile["email"],
+			"name":     profile["name"],
+			"picture":  profile["picture"],
+			"provider": profile["provider"],
+		})
+	})
+
+	// fazt.app.auth.isLoggedIn() - returns true if an app user is signed in
+	authObj.Set("isLoggedIn", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(profile != nil)
+	})
+
+	// fazt.app.auth.loginURL(provider?, redirect?) - returns the app login URL
+	authObj.Set("loginURL", func(call goja.FunctionCall) goja.Value {
+		provider := ""
+		if arg := call.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			provider = arg.String()
+		}
+		redirect := "/"
+		if arg := call.Argument(1); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			redirect = arg.String()
+		}
+		return vm.ToValue(loginURL(provider, redirect))
+	})
+
+	// fazt.app.auth.logoutURL(redirect?) - returns the app logout URL
+	authObj.Set("logoutURL", func(call goja.FunctionCall) goja.Value {
+		redirect := "/"
+		if arg := call.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			redirect = arg.String()
+		}
+		return vm.ToValue(fmt.Sprintf("/auth/app/%s/logout?redirect=%s", app.ID, url.QueryEscape(redirect)))
+	})
+
+	// fazt.app.auth.requireLogin(provider?) - redirects to the app login if not signed in
+	authObj.Set("requireLogin", func(call goja.FunctionCall) goja.Value {
+		if profile != nil {
+			return goja.Undefined()
+		}
+		provider := ""
+		if arg := call.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			provider = arg.String()
+		}
+		panic(vm.NewGoError(&AuthRedirectError{URL: loginURL(provider, "/")}))
+	})
+
+	appObj.Set("auth", authObj)
+	return nil
+}
@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func TestAppAuth_CurrentUser(t *testing.T) {
+	vm := goja.New()
+	app := &AppContext{ID: "app1", Name: "notes"}
+	authCtx := &AuthContext{AppUser: map[string]interface{}{
+		"id":       "fazt_apu_abc",
+		"email":    "octo@example.com",
+		"name":     "Octo",
+		"picture":  "",
+		"provider": "github",
+	}}
+
+	if err := InjectAppAuthNamespace(vm, authCtx, app); err != nil {
+		t.Fatalf("InjectAppAuthNamespace failed: %v", err)
+	}
+
+	val, err := vm.RunString(`fazt.app.auth.currentUser().email + "|" + fazt.app.auth.isLoggedIn()`)
+	if err != nil {
+		t.Fatalf("RunString failed: %v", err)
+	}
+	if got := val.String(); got != "octo@example.com|true" {
+		t.Errorf("Expected signed-in profile, got %s", got)
+	}
+
+	val, _ = vm.RunString(`fazt.app.auth.loginURL("github", "/notes")`)
+	if got := val.String(); got != "/auth/app/app1/login?provider=github&redirect=%2Fnotes" {
+		t.Errorf("Unexpected login URL: %s", got)
+	}
+}
+
+func TestAppAuth_SignedOut(t *testing.T) {
+	vm := goja.New()
+	app := &AppContext{ID: "app1"}
+
+	// A platform user is not an app user
+	authCtx := &AuthContext{User: map[string]interface{}{"id": "fazt_usr_x"}}
+	if err := InjectAppAuthNamespace(vm, authCtx, app); err != nil {
+		t.Fatalf("InjectAppAuthNamespace failed: %v", err)
+	}
+
+	val, _ := vm.RunString(`fazt.app.auth.currentUser()`)
+	if !goja.IsNull(val) {
+		t.Errorf("Expected null currentUser, got %v", val)
+	}
+
+	_, err := vm.RunString(`fazt.app.auth.requireLogin()`)
+	if err == nil {
+		t.Fatal("Expected requireLogin to throw")
+	}
+	if !strings.Contains(err.Error(), "auth redirect to /auth/app/app1/login?redirect=%2F") {
+		t.Errorf("Unexpected redirect: %v", err)
+	}
+}
+
+func TestAppCSRF_TokenVerify(t *testing.T) {
+	// Anonymous visitor: the token() call issues a secret cookie
+	req := httptest.NewRequest("GET", "/form", nil)
+	csrf := NewAppCSRF(req, "app1", &AuthContext{})
+	vm := goja.New()
+	if err := InjectAppCSRFNamespace(vm, csrf); err != nil {
+		t.Fatalf("InjectAppCSRFNamespace failed: %v", err)
+	}
+	if csrf.Cookie() != nil {
+		t.Error("Expected no cookie before a token is issued")
+	}
+	token, _ := vm.RunString(`fazt.app.csrf.token()`)
+	cookie := csrf.Cookie()
+	if cookie == nil || !cookie.HttpOnly || cookie.Name != AppCSRFCookieName {
+		t.Fatalf("Expected an HttpOnly secret cookie, got %+v", cookie)
+	}
+
+	// The visitor posts the form back with the cookie
+	req = httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(cookie)
+	vm = goja.New()
+	InjectAppCSRFNamespace(vm, NewAppCSRF(req, "app1", &AuthContext{}))
+	vm.Set("token", token.String())
+	val, _ := vm.RunString(`fazt.app.csrf.verify(token) + "|" + fazt.app.csrf.verify("forged") + "|" + fazt.app.csrf.verify()`)
+	if got := val.String(); got != "true|false|false" {
+		t.Errorf("Unexpected verify results: %s", got)
+	}
+
+	// Another app on the same secret gets a different token
+	if NewAppCSRF(req, "app2", &AuthContext{}).Verify(token.String()) {
+		t.Error("Expected a token for app1 refused by app2")
+	}
+}
+
+func TestAppCSRF_BoundToSession(t *testing.T) {
+	req := httptest.NewRequest("POST", "/form", nil)
+	req.AddCookie(&http.Cookie{Name: AppCSRFCookieName, Value: "planted"})
+
+	attacker := NewAppCSRF(req, "app1", &AuthContext{}).Token()
+	victim := NewAppCSRF(req, "app1", &AuthContext{SessionID: "session-token"})
+	if victim.Verify(attacker) {
+		t.Error("Expected a token for a planted secret refused for a signed-in user")
+	}
+	if victim.Cookie() != nil {
+		t.Error("Expected no secret cookie for a signed-in user")
+	}
+}
@@ -0,0 +1,119 @@
+package runtime
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/dop251/goja"
+)
+
+// AppCSRFCookieName holds an anonymous visitor's CSRF secret on an app host
+const AppCSRFCookieName = "fazt_app_csrf"
+
+// AppCSRF issues and checks the CSRF tokens of one app request. Tokens are
+// derived from the session the request authenticated with, so a cookie
+// planted by a sibling subdomain can't forge them. Visitors without a
+// session get a random secret in a host-only cookie instead.
+type AppCSRF struct {
+	appID  string
+	secret string
+	secure bool
+	fresh  bool        // secret was generated for this request
+	issued atomic.Bool // token() was called
+}
+
+// NewAppCSRF prepares CSRF tokens for an app request
+func NewAppCSRF(r *http.Request, appID string, authCtx *AuthContext) *AppCSRF {
+	c := &AppCSRF{appID: appID, secure: r.TLS != nil}
+	if authCtx != nil && authCtx.SessionID != "" {
+		c.secret = authCtx.SessionID
+	} else if cookie, err := r.Cookie(AppCSRFCookieName); err == nil && cookie.Value != "" {
+		c.secret = cookie.Value
+	} else {
+		b := make([]byte, 32)
+		rand.Read(b)
+		c.secret = hex.EncodeToString(b)
+		c.fresh = true
+	}
+	return c
+}
+
+// Token returns the request's CSRF token
+func (c *AppCSRF) Token() string {
+	c.issued.Store(true)
+	return c.expected()
+}
+
+// Verify reports whether token is the request's CSRF token
+func (c *AppCSRF) Verify(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(c.expected()), []byte(token)) == 1
+}
+
+func (c *AppCSRF) expected() string {
+	sum := sha256.Sum256([]byte("csrf:" + c.appID + ":" + c.secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Cookie returns the cookie to set for a secret generated on this request
+// once a token was handed out, or nil
+func (c *AppCSRF) Cookie() *http.Cookie {
+	if !c.fresh || !c.issued.Load() {
+		return nil
+	}
+	return &http.Cookie{
+		Name:     AppCSRFCookieName,
+		Value:    c.secret,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// InjectAppCSRFNamespace adds fazt.app.csrf.* functions to a Goja VM.
+// Apps put token() in their forms (or an X-CSRF-Token header) and check it
+// with verify() before acting on a POST.
+func InjectAppCSRFNamespace(vm *goja.Runtime, csrf *AppCSRF) error {
+	// Get or create fazt object
+	faztVal := vm.Get("fazt")
+	var fazt *goja.Object
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		fazt = vm.NewObject()
+		vm.Set("fazt", fazt)
+	} else {
+		fazt = faztVal.ToObject(vm)
+	}
+
+	// Get or create fazt.app object
+	appVal := fazt.Get("app")
+	var appObj *goja.Object
+	if appVal == nil || goja.IsUndefined(appVal) {
+		appObj = vm.NewObject()
+		fazt.Set("app", appObj)
+	} else {
+		appObj = appVal.ToObject(vm)
+	}
+
+	csrfObj := vm.NewObject()
+
+	// fazt.app.csrf.token() - returns the token to embed in a form
+	csrfObj.Set("token", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(csrf.Token())
+	})
+
+	// fazt.app.csrf.verify(token) - returns true if token matches this visitor's
+	csrfObj.Set("verify", func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		if goja.IsUndefined(arg) || goja.IsNull(arg) {
+			return vm.ToValue(false)
+		}
+		return vm.ToValue(csrf.Verify(arg.String()))
+	})
+
+	appObj.Set("csrf", csrfObj)
+	return nil
+}
@@ -14,6 +14,8 @@ type UserInfo struct {
 	Picture  string
 	Role     string
 	Provider string
+
+	ImpersonatedBy string // Admin acting as this user, empty for normal sessions
 }
 
 // InjectAuthNamespace adds fazt.auth.* functions to a Goja VM
@@ -57,6 +59,9 @@ func InjectAuthNamespace(vm *goja.Runtime, authCtx *AuthContext, app *AppContext
 			if provider, ok := umap["provider"].(string); ok {
 				userInfo.Provider = provider
 			}
+			if by, ok := umap["impersonated_by"].(string); ok {
+				userInfo.ImpersonatedBy = by
+			}
 		}
 	}
 
@@ -65,14 +70,18 @@ func InjectAuthNamespace(vm *goja.Runtime, authCtx *AuthContext, app *AppContext
 		if userInfo == nil {
 			return goja.Null()
 		}
-		return vm.ToValue(map[string]interface{}{
+		user := map[string]interface{}{
 			"id":       userInfo.ID,
 			"email":    userInfo.Email,
 			"name":     userInfo.Name,
 			"picture":  userInfo.Picture,
 			"role":     userInfo.Role,
 			"provider": userInfo.Provider,
-		})
+		}
+		if userInfo.ImpersonatedBy != "" {
+			user["impersonated_by"] = userInfo.ImpersonatedBy
+		}
+		return vm.ToValue(user)
 	})
 
 	// fazt.auth.isLoggedIn() - returns true if user is authenticated
@@ -0,0 +1,195 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// maxCallDepth bounds chains of fazt.call, so apps calling each other in a
+// loop fail instead of holding a VM each until they time out
+const maxCallDepth = 4
+
+// callKey is the context key of the call a request was made by
+type callKey struct{}
+
+// appCall is the fazt.call a request was made by
+type appCall struct {
+	caller string
+	depth  int
+}
+
+// callOf returns the fazt.call a request was made by, or nil
+func callOf(ctx context.Context) *appCall {
+	c, _ := ctx.Value(callKey{}).(*appCall)
+	return c
+}
+
+// InjectCallNamespace adds fazt.call(app, path, opts), which runs another
+// app's handler in-process, if that app granted this one the path with
+// `fazt app grant`
+func InjectCallNamespace(vm *goja.Runtime, h *ServerlessHandler, app *AppContext, ctx context.Context) error {
+	faztVal := vm.Get("fazt")
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		return nil
+	}
+	fazt := faztVal.ToObject(vm)
+
+	// fazt.call(app, path, {method, query, headers, body}) - returns
+	// {status, headers, body}; JSON bodies are parsed
+	fazt.Set("call", func(call goja.FunctionCall) goja.Value {
+		target := call.Argument(0).String()
+		if goja.IsUndefined(call.Argument(0)) || target == "" {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call requires an app name")))
+		}
+		u, err := url.Parse(call.Argument(1).String())
+		if err != nil || goja.IsUndefined(call.Argument(1)) {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call requires a path such as /api/items")))
+		}
+		u.Path = path.Clean("/" + u.Path)
+		if !IsServerlessPath(u.Path) && u.Path != "/api" {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call: %s is not an /api path", u.Path)))
+		}
+
+		depth := 1
+		if c := callOf(ctx); c != nil {
+			depth = c.depth + 1
+		}
+		if depth > maxCallDepth {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call: calls nest more than %d deep", maxCallDepth)))
+		}
+		allowed, err := hosting.AppCallAllowed(h.db, app.ID, target, u.Path)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if !allowed {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call: %s may not call %s %s (fazt app grant %s --to %s)",
+				app.ID, target, u.Path, app.ID, target)))
+		}
+
+		r, err := callRequest(vm, ctx, target, u, call.Argument(2))
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.call: %w", err)))
+		}
+		r = r.WithContext(context.WithValue(r.Context(), callKey{}, &appCall{caller: app.ID, depth: depth}))
+
+		rec := newCallRecorder()
+		h.HandleRequest(rec, r, target, target)
+		return vm.ToValue(rec.result())
+	})
+	return nil
+}
+
+// callRequest builds the request for a fazt.call from its options
+func callRequest(vm *goja.Runtime, ctx context.Context, target string, u *url.URL, optsVal goja.Value) (*http.Request, error) {
+	method := http.MethodGet
+	query := u.Query()
+	headers := http.Header{}
+	var body io.Reader
+
+	if !goja.IsUndefined(optsVal) && !goja.IsNull(optsVal) {
+		opts := optsVal.ToObject(vm)
+		if v := opts.Get("method"); v != nil && !goja.IsUndefined(v) {
+			method = strings.ToUpper(v.String())
+		}
+		if v := opts.Get("query"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			if m, ok := v.Export().(map[string]interface{}); ok {
+				for k, val := range m {
+					query.Set(k, fmt.Sprint(val))
+				}
+			}
+		}
+		if v := opts.Get("headers"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			if m, ok := v.Export().(map[string]interface{}); ok {
+				for k, val := range m {
+					headers.Set(k, fmt.Sprint(val))
+				}
+			}
+		}
+		if v := opts.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			if s, ok := v.Export().(string); ok {
+				body = strings.NewReader(s)
+				if headers.Get("Content-Type") == "" {
+					headers.Set("Content-Type", "text/plain; charset=utf-8")
+				}
+			} else {
+				raw, err := json.Marshal(v.Export())
+				if err != nil {
+					return nil, err
+				}
+				body = bytes.NewReader(raw)
+				headers.Set("Content-Type", "application/json")
+			}
+		}
+	}
+	// The caller's cookies and sessions never reach the other app
+	headers.Del("Cookie")
+	headers.Del("Authorization")
+
+	r, err := http.NewRequestWithContext(ctx, method, "http://"+target+u.Path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header = headers
+	r.RemoteAddr = "127.0.0.1:0"
+	return r, nil
+}
+
+// callRecorder collects the response of a fazt.call
+type callRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newCallRecorder() *callRecorder {
+	return &callRecorder{header: http.Header{}}
+}
+
+func (c *callRecorder) Header() http.Header { return c.header }
+
+func (c *callRecorder) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+func (c *callRecorder) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+// result is the response as fazt.call returns it
+func (c *callRecorder) result() map[string]interface{} {
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	headers := make(map[string]interface{}, len(c.header))
+	for k, v := range c.header {
+		headers[k] = v[0]
+	}
+	var body interface{} = c.body.String()
+	if strings.Contains(c.header.Get("Content-Type"), "application/json") && c.body.Len() > 0 {
+		var parsed interface{}
+		if json.Unmarshal(c.body.Bytes(), &parsed) == nil {
+			body = parsed
+		}
+	}
+	return map[string]interface{}{
+		"status":  status,
+		"headers": headers,
+		"body":    body,
+	}
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func setupCallTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := dbtest.Open(t)
+
+	files := map[string]string{
+		"shop": `
+			var r = fazt.call("billing", "/api/charge?currency=eur", {method: "post", body: {amount: 5}})
+			respond({status: r.status, body: r.body})
+		`,
+		"billing": `
+			respond(201, {caller: request.caller || null, method: request.method, amount: request.body.amount, currency: request.query.currency})
+		`,
+	}
+	for site, code := range files {
+		if _, err := db.Exec(`
+			INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash)
+			VALUES (?, 'api/main.js', ?, ?, 'application/javascript', 'test-hash')
+		`, site, code, len(code)); err != nil {
+			t.Fatal(err)
+		}
+		hosting.LoadAPIRoutes(db, site)
+	}
+	return db
+}
+
+func serveApp(h *ServerlessHandler, site string, r *http.Request) map[string]interface{} {
+	rec := httptest.NewRecorder()
+	h.HandleRequest(rec, r, site, site)
+	var body map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	body["_status"] = float64(rec.Code)
+	return body
+}
+
+func TestFaztCall(t *testing.T) {
+	db := setupCallTestDB(t)
+	h := NewServerlessHandler(db)
+
+	// Without a grant the call throws
+	body := serveApp(h, "shop", httptest.NewRequest("GET", "/api/checkout", nil))
+	if body["_status"] != float64(500) || !strings.Contains(body["error"].(string), "may not call billing") {
+		t.Fatalf("Expected the ungranted call refused, got %v", body)
+	}
+
+	// A grant on another path doesn't cover /api/charge
+	if err := hosting.GrantAppCall(db, "shop", "billing", "/api/reports", ""); err != nil {
+		t.Fatal(err)
+	}
+	if body := serveApp(h, "shop", httptest.NewRequest("GET", "/api/checkout", nil)); body["_status"] != float64(500) {
+		t.Fatalf("Expected a grant on /api/reports not to cover /api/charge, got %v", body)
+	}
+
+	if err := hosting.GrantAppCall(db, "shop", "billing", hosting.GrantScopeAPI, ""); err != nil {
+		t.Fatal(err)
+	}
+	body = serveApp(h, "shop", httptest.NewRequest("GET", "/api/checkout", nil))
+	resp, _ := body["body"].(map[string]interface{})
+	if body["status"] != float64(201) || resp["caller"] != "shop" || resp["method"] != "POST" ||
+		resp["amount"] != float64(5) || resp["currency"] != "eur" {
+		t.Errorf("Unexpected call result: %v", body)
+	}
+
+	// Requests from outside have no caller, whatever their headers say
+	r := httptest.NewRequest("POST", "/api/charge", strings.NewReader(`{"amount": 1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Fazt-Caller", "shop")
+	if body := serveApp(h, "billing", r); body["caller"] != nil {
+		t.Errorf("Expected no caller on an outside request, got %v", body)
+	}
+}
+
+func TestFaztCallLimits(t *testing.T) {
+	db := setupCallTestDB(t)
+	h := NewServerlessHandler(db)
+	hosting.GrantAppCall(db, "shop", "billing", hosting.GrantScopeAPI, "")
+
+	run := func(ctx context.Context, code string) error {
+		vm := goja.New()
+		vm.Set("fazt", vm.NewObject())
+		InjectCallNamespace(vm, h, &AppContext{ID: "shop", Name: "shop"}, ctx)
+		_, err := vm.RunString(code)
+		return err
+	}
+
+	deep := context.WithValue(context.Background(), callKey{}, &appCall{caller: "billing", depth: maxCallDepth})
+	if err := run(deep, `fazt.call("billing", "/api/charge")`); err == nil || !strings.Contains(err.Error(), "nest") {
+		t.Errorf("Expected a call past the depth limit refused, got %v", err)
+	}
+	for _, path := range []string{"/private/config.json", "/api/../private/x", "/"} {
+		if err := run(context.Background(), `fazt.call("billing", "`+path+`")`); err == nil {
+			t.Errorf("%s: expected a path outside /api refused", path)
+		}
+	}
+}
@@ -2,12 +2,21 @@ package runtime
 
 import (
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/hosting"
 )
 
 // AppContext contains information about the current app.
 type AppContext struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Runtime string   `json:"runtime"` // Runtime API version, "" = v0
+	CSRF    *AppCSRF `json:"-"`
+}
+
+// legacyStorage reports whether the app runs as a runtime API version that
+// still has fazt.storage.*
+func (a *AppContext) legacyStorage() bool {
+	return a.Runtime == "" || a.Runtime == hosting.RuntimeV0
 }
 
 // EnvVars provides environment variable access.
@@ -78,6 +87,13 @@ func InjectFaztNamespace(vm *goja.Runtime, app *AppContext, env EnvVars, result
 	// fazt.version
 	fazt.Set("version", "0.8.0")
 
+	// fazt.runtime: the runtime API version the app runs as
+	runtime := hosting.RuntimeV0
+	if app != nil && app.Runtime != "" {
+		runtime = app.Runtime
+	}
+	fazt.Set("runtime", runtime)
+
 	vm.Set("fazt", fazt)
 	return nil
 }
@@ -7,20 +7,30 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/chaos"
+	"github.com/fazt-sh/fazt/internal/crashloop"
 	"github.com/fazt-sh/fazt/internal/debug"
 	"github.com/fazt-sh/fazt/internal/egress"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/sandbox"
 	imgservice "github.com/fazt-sh/fazt/internal/services/image"
 	"github.com/fazt-sh/fazt/internal/services/media"
+	wasmservice "github.com/fazt-sh/fazt/internal/services/wasm"
 	"github.com/fazt-sh/fazt/internal/storage"
 	"github.com/fazt-sh/fazt/internal/system"
 	"github.com/fazt-sh/fazt/internal/timeout"
+	"github.com/fazt-sh/fazt/internal/webpush"
 	"github.com/fazt-sh/fazt/internal/worker"
 )
 
@@ -28,6 +38,7 @@ import (
 type AuthContext struct {
 	User      interface{} // *auth.User
 	SessionID string
+	AppUser   interface{} // *auth.AppUser signed in to this app, if any
 }
 
 // AuthProvider is an interface for getting auth from a request
@@ -36,6 +47,17 @@ type AuthProvider interface {
 	Domain() string
 }
 
+// AppUserProvider is implemented by auth providers that support app end-user logins
+type AppUserProvider interface {
+	GetAppUserFromRequest(r *http.Request, appID string) (interface{}, error)
+}
+
+// SessionTokenProvider is implemented by auth providers that can name the
+// platform session a request authenticated with
+type SessionTokenProvider interface {
+	SessionToken(r *http.Request) string
+}
+
 // ServerlessHandler handles requests to /api/* paths by executing JavaScript.
 type ServerlessHandler struct {
 	runtime      *Runtime
@@ -43,6 +65,7 @@ type ServerlessHandler struct {
 	storage      *storage.Storage
 	authProvider AuthProvider
 	egressProxy  *egress.EgressProxy
+	injectors    []VMInjector
 }
 
 // NewServerlessHandler creates a new serverless handler.
@@ -64,6 +87,22 @@ func (h *ServerlessHandler) SetEgressProxy(proxy *egress.EgressProxy) {
 	h.egressProxy = proxy
 }
 
+// AddInjector adds a binding installed after the built-in ones, so it can
+// replace them (fazt app test stubs fazt.net.fetch this way).
+func (h *ServerlessHandler) AddInjector(injector VMInjector) {
+	h.injectors = append(h.injectors, injector)
+}
+
+// SetAppLimit caps an app's concurrent executions; 0 removes the cap.
+func (h *ServerlessHandler) SetAppLimit(appID string, limit int) {
+	h.runtime.SetAppLimit(appID, limit)
+}
+
+// PoolStats returns the VM pool statistics.
+func (h *ServerlessHandler) PoolStats() PoolStats {
+	return h.runtime.Stats()
+}
+
 // NewServerlessHandlerWithRuntime creates a handler with a custom runtime.
 func NewServerlessHandlerWithRuntime(db *sql.DB, rt *Runtime) *ServerlessHandler {
 	return &ServerlessHandler{
@@ -81,17 +120,46 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 
 	debug.Log("runtime", "req=%s app=%s path=%s method=%s started", reqID, appName, r.URL.Path, r.Method)
 
-	// Load api/main.js from the app's files
-	mainJS, err := h.loadFile(appID, "api/main.js")
-	if err != nil {
-		// No serverless handler found
-		debug.RuntimeReq(reqID, appName, r.URL.Path, 404, time.Since(start))
-		http.Error(w, "No serverless handler found", http.StatusNotFound)
+	// Crash-looping apps are isolated until a probe request succeeds
+	if ok, retryAfter := crashloop.Allow(appID, start); !ok {
+		h.serveDegraded(w, r, appID, retryAfter)
+		debug.RuntimeReq(reqID, appName, r.URL.Path, 503, time.Since(start))
 		return
 	}
 
+	// File-based routes (api/users/[id].js) come first, then api/main.js
+	var code, entry string
+	var params map[string]string
+	if route, p, ok := hosting.APIRoutesFor(h.db, appID).Match(r.URL.Path); ok {
+		if !route.Allows(r.Method) {
+			debug.RuntimeReq(reqID, appName, r.URL.Path, 405, time.Since(start))
+			w.Header().Set("Allow", strings.Join(route.Methods, ", "))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": "Method not allowed",
+			})
+			return
+		}
+		if route.WASI {
+			h.serveWASI(w, r, appID, appName, route, p, reqID, start)
+			return
+		}
+		code, entry, params = routeHandlerCode(route.File), route.Pattern, p
+	} else {
+		mainJS, err := h.loadFile(appID, "api/main.js")
+		if err != nil {
+			// No serverless handler found
+			debug.RuntimeReq(reqID, appName, r.URL.Path, 404, time.Since(start))
+			http.Error(w, "No serverless handler found", http.StatusNotFound)
+			return
+		}
+		code, entry = mainJS, "api/main.js"
+	}
+
 	// Build request object
 	req := buildRequest(r)
+	req.Params = params
 
 	// Create file loader for require()
 	loader := func(path string) (string, error) {
@@ -103,22 +171,38 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 
 	// Create app context
 	app := &AppContext{
-		ID:   appID,
-		Name: appName,
+		ID:      appID,
+		Name:    appName,
+		Runtime: hosting.AppRuntimeFor(appID),
 	}
 
 	// Extract auth context from request if auth provider is configured
 	var authCtx *AuthContext
 	if h.authProvider != nil {
+		authCtx = &AuthContext{}
 		if user, err := h.authProvider.GetSessionFromRequest(r); err == nil && user != nil {
-			authCtx = &AuthContext{User: user}
+			authCtx.User = user
+			if p, ok := h.authProvider.(SessionTokenProvider); ok {
+				authCtx.SessionID = p.SessionToken(r)
+			}
+		}
+		if p, ok := h.authProvider.(AppUserProvider); ok {
+			if appUser, err := p.GetAppUserFromRequest(r, appID); err == nil && appUser != nil {
+				authCtx.AppUser = appUser
+				// An app end-user session takes precedence over the platform user
+				if c, err := r.Cookie("fazt_app_session"); err == nil {
+					authCtx.SessionID = c.Value
+				}
+			}
 		}
 	}
+	app.CSRF = NewAppCSRF(r, appID, authCtx)
 
 	// Execute with a timeout and budget tracking
 	cfg := timeout.DefaultConfig()
 	execCtx, cancel := context.WithTimeout(ctx, cfg.RequestTimeout)
 	defer cancel()
+	execCtx = modules.WithScript(execCtx, appID, entry)
 
 	// Budget tracks the JS execution window (5s), not the full request (10s).
 	// This ensures admission control matches actual VM lifetime.
@@ -126,7 +210,7 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 	defer budgetCancel()
 	budget := timeout.NewBudget(budgetCtx, cfg)
 
-	result := h.executeWithFazt(execCtx, mainJS, req, loader, app, env, authCtx, budget)
+	result := h.executeIsolated(execCtx, code, req, loader, app, env, authCtx, budget)
 
 	// Persist logs to database
 	h.persistLogs(appID, result.Logs, result.Error)
@@ -155,7 +239,8 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 
 		// Check for retryable errors (overload, timeout, insufficient time, egress limits)
 		errMsg := result.Error.Error()
-		if storage.IsRetryableError(result.Error) ||
+		if errors.Is(result.Error, ErrBusy) ||
+			storage.IsRetryableError(result.Error) ||
 			egress.IsRetryableError(result.Error) ||
 			strings.Contains(errMsg, "queue full") ||
 			strings.Contains(errMsg, "SQLITE_BUSY") ||
@@ -172,15 +257,22 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 			return
 		}
 
+		crashloop.RecordCrash(appID, errMsg, time.Now())
 		debug.RuntimeReq(reqID, appName, r.URL.Path, 500, time.Since(start))
 		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		body := map[string]interface{}{
 			"error": result.Error.Error(),
 			"logs":  result.Logs,
-		})
+		}
+		if limit := LimitOf(result.Error); limit != "" {
+			body["limit"] = limit
+		}
+		json.NewEncoder(w).Encode(body)
 		return
 	}
 
+	crashloop.RecordSuccess(appID)
+
 	// Write response
 	if result.Response == nil {
 		result.Response = &Response{Status: 200}
@@ -190,6 +282,9 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 	for k, v := range result.Response.Headers {
 		w.Header().Set(k, v)
 	}
+	if cookie := app.CSRF.Cookie(); cookie != nil {
+		http.SetCookie(w, cookie)
+	}
 
 	// Set content type if not set
 	if w.Header().Get("Content-Type") == "" {
@@ -224,6 +319,61 @@ func (h *ServerlessHandler) HandleRequest(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// routeHandlerCode loads a route file as a module and calls the export named
+// after the request method. The file runs with its own require(), so its
+// imports resolve against its directory.
+func routeHandlerCode(file string) string {
+	return fmt.Sprintf(`(function() {
+var routes = require(%q);
+var handler = routes[request.method];
+if (typeof handler !== "function" && request.method === "HEAD") handler = routes.GET;
+if (typeof handler !== "function") return respond(405, { error: "Method not allowed" });
+return handler(request);
+})()`, "./"+strings.TrimPrefix(file, "api/"))
+}
+
+// PanicError is a Go panic recovered while running a handler
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// executeIsolated runs the handler, turning a panic in a binding into an
+// error so one app can't take the request goroutine down with it
+func (h *ServerlessHandler) executeIsolated(ctx context.Context, code string, req *Request, loader FileLoader, app *AppContext, env EnvVars, authCtx *AuthContext, budget *timeout.Budget) (result *ExecuteResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("PANIC in app %s: %v", app.ID, p)
+			result = &ExecuteResult{Logs: make([]LogEntry, 0), Error: &PanicError{Value: p}}
+		}
+	}()
+	return h.executeWithFazt(ctx, code, req, loader, app, env, authCtx, budget)
+}
+
+// serveDegraded answers for a tripped app: its api/fallback.json if it
+// ships one, otherwise a 503 saying when the next attempt is let through
+func (h *ServerlessHandler) serveDegraded(w http.ResponseWriter, r *http.Request, appID string, retryAfter time.Duration) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Fazt-Degraded", "crash-loop")
+
+	if fallback, err := h.loadFile(appID, "api/fallback.json"); err == nil {
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, fallback)
+		return
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":     "App is temporarily unavailable after repeated errors",
+		"retryable": true,
+	})
+}
+
 // generateRequestID creates a short random request ID for tracing.
 func generateRequestID() string {
 	b := make([]byte, 4)
@@ -236,14 +386,29 @@ func (h *ServerlessHandler) executeWithFazt(ctx context.Context, code string, re
 	// Create injectors for fazt namespace and storage
 	result := &ExecuteResult{Logs: make([]LogEntry, 0)}
 
+	// Bindings charge memory and spawns to the execution's sandbox tracker
+	ctx = sandbox.WithTracker(ctx, sandbox.New(sandbox.DefaultLimits()))
+	// Reads see the request's own earlier writes, even ones that timed out
+	ctx = storage.WithReadYourWrites(ctx)
+	// Storage and egress get the app's chaos settings, if any
+	if app != nil {
+		ctx = chaos.WithApp(ctx, app.ID)
+	}
+
 	faztInjector := func(vm *goja.Runtime) error {
 		return InjectFaztNamespace(vm, app, env, result)
 	}
 
-	// LEGACY_CODE: fazt.storage.* - use fazt.app.* instead
+	// LEGACY_CODE: fazt.storage.* - use fazt.app.* instead. Only apps on
+	// runtime v0 get it, logging each binding they still use.
 	storageInjector := func(vm *goja.Runtime) error {
-		if app != nil && app.ID != "" {
-			return storage.InjectStorageNamespace(vm, h.storage, app.ID, ctx, budget)
+		if app != nil && app.ID != "" && app.legacyStorage() {
+			if err := storage.InjectStorageNamespace(vm, h.storage, app.ID, ctx, budget); err != nil {
+				return err
+			}
+			storage.DeprecateStorageNamespace(vm, hosting.RuntimeV0, func(line string) {
+				result.Logs = append(result.Logs, LogEntry{Level: "warn", Message: line, Time: time.Now()})
+			})
 		}
 		return nil
 	}
@@ -252,14 +417,11 @@ func (h *ServerlessHandler) executeWithFazt(ctx context.Context, code string, re
 	appStorageInjector := func(vm *goja.Runtime) error {
 		if app != nil && app.ID != "" {
 			userID := ""
-			if authCtx != nil && authCtx.User != nil {
-				// Extract user ID from auth context
-				if u, ok := authCtx.User.(interface{ GetID() string }); ok {
-					userID = u.GetID()
-				} else if m, ok := authCtx.User.(map[string]interface{}); ok {
-					if id, ok := m["id"].(string); ok {
-						userID = id
-					}
+			if authCtx != nil {
+				// An app end-user session takes precedence over the platform user
+				userID = userIDOf(authCtx.AppUser)
+				if userID == "" {
+					userID = userIDOf(authCtx.User)
 				}
 			}
 			return storage.InjectAppNamespace(vm, h.db, storage.GetWriter(), app.ID, userID, ctx, budget)
@@ -281,10 +443,59 @@ func (h *ServerlessHandler) executeWithFazt(ctx context.Context, code string, re
 		return nil
 	}
 
+	mailInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			return worker.InjectMailNamespace(vm, h.db, app.ID)
+		}
+		return nil
+	}
+
+	pushInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			userID := ""
+			if authCtx != nil {
+				userID = userIDOf(authCtx.AppUser)
+				if userID == "" {
+					userID = userIDOf(authCtx.User)
+				}
+			}
+			return webpush.InjectPushNamespace(vm, h.db, app.ID, userID, ctx)
+		}
+		return nil
+	}
+
+	callInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			return InjectCallNamespace(vm, h, app, ctx)
+		}
+		return nil
+	}
+
+	sysInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			return InjectSysNamespace(vm, h.db, app)
+		}
+		return nil
+	}
+
 	authInjector := func(vm *goja.Runtime) error {
 		return InjectAuthNamespace(vm, authCtx, app)
 	}
 
+	appAuthInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			return InjectAppAuthNamespace(vm, authCtx, app)
+		}
+		return nil
+	}
+
+	csrfInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.CSRF != nil {
+			return InjectAppCSRFNamespace(vm, app.CSRF)
+		}
+		return nil
+	}
+
 	privateInjector := func(vm *goja.Runtime) error {
 		if app != nil && app.ID != "" {
 			privateLoader := NewPrivateFileLoader(h.db, app.ID)
@@ -304,7 +515,36 @@ func (h *ServerlessHandler) executeWithFazt(ctx context.Context, code string, re
 		return imgservice.InjectImageNamespace(vm)
 	}
 
-	return h.runtime.ExecuteWithInjectors(ctx, code, req, loader, faztInjector, storageInjector, appStorageInjector, realtimeInjector, workerInjector, authInjector, privateInjector, netInjector, imageInjector)
+	utilInjector := func(vm *goja.Runtime) error {
+		return InjectUtilNamespace(vm, app)
+	}
+
+	// Modules load from the app's files and close when the request ends
+	wasmInjector := func(vm *goja.Runtime) error {
+		if app != nil && app.ID != "" {
+			return wasmservice.InjectWasmNamespace(vm, ctx, loader)
+		}
+		return nil
+	}
+
+	injectors := []VMInjector{faztInjector, storageInjector, appStorageInjector, realtimeInjector, workerInjector, mailInjector, pushInjector, callInjector, sysInjector, authInjector, appAuthInjector, csrfInjector, privateInjector, netInjector, imageInjector, utilInjector, wasmInjector}
+	out := h.runtime.ExecuteWithInjectors(ctx, code, req, loader, append(injectors, h.injectors...)...)
+	// fazt.log lines and deprecation warnings collect in the injectors' result
+	out.Logs = append(out.Logs, result.Logs...)
+	return out
+}
+
+// userIDOf extracts the user ID from an auth context user
+func userIDOf(user interface{}) string {
+	if u, ok := user.(interface{ GetID() string }); ok {
+		return u.GetID()
+	}
+	if m, ok := user.(map[string]interface{}); ok {
+		if id, ok := m["id"].(string); ok {
+			return id
+		}
+	}
+	return ""
 }
 
 // loadFile loads a file from the VFS for a given app.
@@ -351,13 +591,7 @@ func buildRequest(r *http.Request) *Request {
 		}
 	}
 
-	// Parse headers
-	headers := make(map[string]string)
-	for k, v := range r.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
-		}
-	}
+	headers := requestHeaders(r)
 
 	// Parse body
 	var body interface{}
@@ -386,7 +620,7 @@ func buildRequest(r *http.Request) *Request {
 		}
 	}
 
-	return &Request{
+	req := &Request{
 		Method:  r.Method,
 		Path:    r.URL.Path,
 		Query:   query,
@@ -394,6 +628,48 @@ func buildRequest(r *http.Request) *Request {
 		Body:    body,
 		Files:   files,
 	}
+	if c := callOf(r.Context()); c != nil {
+		req.Caller = c.caller
+	}
+	return req
+}
+
+// requestHeaders returns the first value of each request header. Apps see
+// the signed-in user through fazt.auth, never the platform's session cookies.
+func requestHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string)
+	for k, v := range r.Header {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+	if _, ok := headers["Cookie"]; ok {
+		if cookie := appCookies(r); cookie != "" {
+			headers["Cookie"] = cookie
+		} else {
+			delete(headers, "Cookie")
+		}
+	}
+	return headers
+}
+
+// platformCookies are the session cookies fazt reads itself
+var platformCookies = map[string]bool{
+	"fazt_session":     true, // auth.SessionCookieName
+	"fazt_app_session": true, // auth.AppSessionCookieName
+	AppCSRFCookieName:  true,
+}
+
+// appCookies returns the request's cookies, minus platform cookies, as a
+// Cookie header
+func appCookies(r *http.Request) string {
+	var kept []string
+	for _, c := range r.Cookies() {
+		if !platformCookies[c.Name] {
+			kept = append(kept, c.Name+"="+c.Value)
+		}
+	}
+	return strings.Join(kept, "; ")
 }
 
 // parseMultipartFiles extracts uploaded files from a multipart request.
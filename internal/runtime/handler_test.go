@@ -3,6 +3,7 @@ package runtime
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
@@ -125,6 +126,24 @@ func TestBuildRequest_JSONBody(t *testing.T) {
 	}
 }
 
+func TestBuildRequest_StripsPlatformCookies(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/me", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Cookie", "theme=dark; fazt_session=secret; fazt_app_session=secret2; fazt_app_csrf=secret3; cart=3")
+
+	result := buildRequest(req)
+	if got := result.Headers["Cookie"]; got != "theme=dark; cart=3" {
+		t.Errorf("Cookie header = %q, want platform cookies removed", got)
+	}
+
+	req.Header.Set("Cookie", "fazt_session=secret")
+	if _, ok := buildRequest(req).Headers["Cookie"]; ok {
+		t.Error("Expected no Cookie header when only platform cookies were sent")
+	}
+}
+
 func TestFileUpload_ArrayBufferInVM(t *testing.T) {
 	// Test that files are injected as ArrayBuffer in the VM
 	rt := NewRuntime(1, 2*time.Second)
@@ -187,3 +206,95 @@ func TestFileUpload_ArrayBufferInVM(t *testing.T) {
 		t.Errorf("expected dataLength %d, got %v", len(fileData), body["dataLength"])
 	}
 }
+
+func TestRouteHandlerCode(t *testing.T) {
+	rt := NewRuntime(1, 2*time.Second)
+	ctx := context.Background()
+
+	source := `exports.GET = function(req) {
+	return respond({ id: req.params.id, method: req.method });
+};
+exports.DELETE = function(req) { return respond(204); };`
+	esmSource := `export function GET(req) {
+	return respond({ id: req.params.id, method: req.method });
+}
+const remove = () => respond(204);
+export { remove as DELETE };`
+
+	files := map[string]string{
+		"api/users/[id].js":   source,
+		"api/posts/[slug].js": esmSource,
+	}
+	loader := func(path string) (string, error) {
+		if content, ok := files[path]; ok {
+			return content, nil
+		}
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+
+	for file := range files {
+		for _, tt := range []struct {
+			method string
+			status int
+		}{
+			{"GET", 200},
+			{"HEAD", 200},
+			{"DELETE", 204},
+			{"POST", 405},
+		} {
+			req := &Request{
+				Method:  tt.method,
+				Path:    "/api/users/42",
+				Query:   map[string]string{},
+				Headers: map[string]string{},
+				Params:  map[string]string{"id": "42"},
+			}
+			result := rt.ExecuteWithFiles(ctx, routeHandlerCode(file), req, loader)
+			if result.Error != nil {
+				t.Fatalf("%s %s: Execute failed: %v", file, tt.method, result.Error)
+			}
+			if result.Response.Status != tt.status {
+				t.Errorf("%s %s: expected status %d, got %d", file, tt.method, tt.status, result.Response.Status)
+			}
+			if tt.method == "GET" {
+				body, _ := result.Response.Body.(map[string]interface{})
+				if body["id"] != "42" || body["method"] != "GET" {
+					t.Errorf("expected params in handler, got %v", body)
+				}
+			}
+		}
+	}
+}
+
+func TestExecuteWithFazt_RuntimeVersions(t *testing.T) {
+	h := NewServerlessHandler(nil)
+	code := `
+		var legacy = typeof fazt.storage === 'object'
+		fazt.log.info('ran')
+		respond({runtime: fazt.runtime, legacy: legacy})
+	`
+	run := func(runtime string) *ExecuteResult {
+		app := &AppContext{ID: "app1", Name: "notes", Runtime: runtime}
+		result := h.executeWithFazt(context.Background(), code, &Request{Method: "GET", Path: "/api"}, nil, app, EnvVars{}, nil, nil)
+		if result.Error != nil {
+			t.Fatalf("%s: execution failed: %v", runtime, result.Error)
+		}
+		return result
+	}
+
+	// Unstamped apps run as v0, fazt.storage included
+	body := run("").Response.Body.(map[string]interface{})
+	if body["runtime"] != "v0" || body["legacy"] != true {
+		t.Errorf("Expected v0 with fazt.storage, got %v", body)
+	}
+
+	result := run("v1")
+	body = result.Response.Body.(map[string]interface{})
+	if body["runtime"] != "v1" || body["legacy"] != false {
+		t.Errorf("Expected v1 without fazt.storage, got %v", body)
+	}
+	// fazt.log lines reach the result
+	if len(result.Logs) != 1 || result.Logs[0].Message != "ran" {
+		t.Errorf("Expected the fazt.log line, got %+v", result.Logs)
+	}
+}
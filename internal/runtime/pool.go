@@ -0,0 +1,216 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/debug"
+)
+
+// VM pool. Executions borrow a pre-warmed VM and give it back when done.
+// Goja can't reset a runtime in place, and handlers can leave globals or
+// patched builtins behind, so a returned VM is replaced by a fresh one built
+// in the background: every request starts clean without paying for
+// goja.New on its path. The pool is bounded. When it is empty, or the app
+// already runs its share of executions, a request queues until a VM frees
+// up or the queue timeout passes.
+
+// Pool defaults
+const (
+	DefaultAppConcurrency = 20              // Concurrent executions per app
+	DefaultQueueTimeout   = 2 * time.Second // Wait for a VM before giving up
+)
+
+// ErrBusy is returned when no VM became free within the queue timeout
+var ErrBusy = errors.New("runtime busy: queue full")
+
+// PoolConfig configures the VM pool.
+type PoolConfig struct {
+	Size           int           // VMs, i.e. concurrent executions of all apps
+	AppConcurrency int           // Concurrent executions per app
+	QueueTimeout   time.Duration // How long an execution waits for a VM
+}
+
+// DefaultPoolConfig returns sensible defaults.
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		Size:           MaxPoolSize,
+		AppConcurrency: DefaultAppConcurrency,
+		QueueTimeout:   DefaultQueueTimeout,
+	}
+}
+
+// PoolStats describes the VM pool
+type PoolStats struct {
+	Size           int            `json:"size"`
+	Idle           int            `json:"idle"`
+	AppConcurrency int            `json:"app_concurrency"`
+	Running        map[string]int `json:"running"` // executions by app
+	Waiting        int            `json:"waiting"`
+	Acquired       int64          `json:"acquired"`
+	Queued         int64          `json:"queued"` // executions that had to wait
+	Timeouts       int64          `json:"timeouts"`
+}
+
+// appSlots caps concurrent executions per app
+type appSlots struct {
+	mu      sync.Mutex
+	running map[string]int
+	limits  map[string]int // caps below the default, e.g. for throttled apps
+	freed   chan struct{}  // closed and replaced whenever a slot frees up
+
+	waiting                    int
+	acquired, queued, timeouts int64
+}
+
+func newAppSlots() *appSlots {
+	return &appSlots{
+		running: make(map[string]int),
+		limits:  make(map[string]int),
+		freed:   make(chan struct{}),
+	}
+}
+
+// acquireVM takes a VM for one of app's executions, queueing while the app
+// is at its limit or the pool is empty. Release it with releaseVM.
+func (r *Runtime) acquireVM(ctx context.Context, app string) (*goja.Runtime, error) {
+	timer := time.NewTimer(r.queueTimeout)
+	defer timer.Stop()
+
+	s := r.slots
+	s.mu.Lock()
+	s.waiting++
+	waited := false
+	for {
+		limit := r.appConcurrency
+		if l, ok := s.limits[app]; ok && l < limit {
+			limit = l
+		}
+		if app == "" || s.running[app] < limit {
+			break
+		}
+		freed := s.freed
+		waited = true
+		s.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			return nil, r.giveUp(ErrBusy)
+		case <-ctx.Done():
+			return nil, r.giveUp(ctx.Err())
+		}
+		s.mu.Lock()
+	}
+	s.running[app]++
+	s.mu.Unlock()
+
+	var vm *goja.Runtime
+	select {
+	case vm = <-r.pool:
+	default:
+		waited = true
+		debug.Log("runtime", "pool empty, app=%s waiting for a VM", app)
+		select {
+		case vm = <-r.pool:
+		case <-timer.C:
+			r.releaseSlot(app)
+			return nil, r.giveUp(ErrBusy)
+		case <-ctx.Done():
+			r.releaseSlot(app)
+			return nil, r.giveUp(ctx.Err())
+		}
+	}
+
+	s.mu.Lock()
+	s.waiting--
+	s.acquired++
+	if waited {
+		s.queued++
+	}
+	s.mu.Unlock()
+	debug.RuntimePool(r.poolSize, len(r.pool))
+	return vm, nil
+}
+
+// giveUp leaves the queue, passing err through
+func (r *Runtime) giveUp(err error) error {
+	s := r.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waiting--
+	if err == ErrBusy {
+		s.timeouts++
+	}
+	return err
+}
+
+// releaseVM ends one of app's executions. The used VM is dropped and a
+// fresh one takes its place in the pool.
+func (r *Runtime) releaseVM(app string) {
+	r.releaseSlot(app)
+	go func() {
+		r.pool <- goja.New()
+	}()
+}
+
+// returnVM ends one of app's executions that didn't touch its VM, such as a
+// WASI handler, putting the VM back as is
+func (r *Runtime) returnVM(app string, vm *goja.Runtime) {
+	r.releaseSlot(app)
+	r.pool <- vm
+}
+
+func (r *Runtime) releaseSlot(app string) {
+	s := r.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[app]--; s.running[app] <= 0 {
+		delete(s.running, app)
+	}
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
+
+// SetAppLimit caps how many of an app's executions run at once, below the
+// pool's per-app concurrency. Executions over the cap queue. A limit of 0
+// removes the cap.
+func (r *Runtime) SetAppLimit(app string, limit int) {
+	s := r.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit <= 0 {
+		delete(s.limits, app)
+	} else {
+		s.limits[app] = limit
+	}
+	// Waiters re-check against the new limit
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
+
+// Stats returns VM pool statistics
+func (r *Runtime) Stats() PoolStats {
+	s := r.slots
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	running := make(map[string]int, len(s.running))
+	for app, n := range s.running {
+		if app != "" {
+			running[app] = n
+		}
+	}
+	return PoolStats{
+		Size:           r.poolSize,
+		Idle:           len(r.pool),
+		AppConcurrency: r.appConcurrency,
+		Running:        running,
+		Waiting:        s.waiting,
+		Acquired:       s.acquired,
+		Queued:         s.queued,
+		Timeouts:       s.timeouts,
+	}
+}
@@ -0,0 +1,100 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPool_FreshVMPerRequest(t *testing.T) {
+	r := NewRuntime(1, time.Second)
+	ctx := context.Background()
+	req := &Request{Method: "GET", Path: "/test"}
+
+	if result := r.Execute(ctx, `globalThis.leak = 1; Array.prototype.extra = 2; 0`, req); result.Error != nil {
+		t.Fatalf("Execute failed: %v", result.Error)
+	}
+	result := r.Execute(ctx, `typeof leak + " " + typeof [].extra`, req)
+	if result.Error != nil {
+		t.Fatalf("Execute failed: %v", result.Error)
+	}
+	if result.Response.Body != "undefined undefined" {
+		t.Errorf("expected a clean VM, got %v", result.Response.Body)
+	}
+}
+
+func TestPool_AppConcurrency(t *testing.T) {
+	r := NewRuntimeWithPool(PoolConfig{Size: 4, AppConcurrency: 1, QueueTimeout: 50 * time.Millisecond}, time.Second)
+	ctx := context.Background()
+
+	if _, err := r.acquireVM(ctx, "app_a"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := r.acquireVM(ctx, "app_a"); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy over the app's limit, got %v", err)
+	}
+	if _, err := r.acquireVM(ctx, "app_b"); err != nil {
+		t.Errorf("expected other apps unaffected, got %v", err)
+	}
+
+	// A queued execution runs once the app's slot frees up
+	done := make(chan error)
+	go func() {
+		_, err := r.acquireVM(ctx, "app_a")
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	r.releaseVM("app_a")
+	if err := <-done; err != nil {
+		t.Errorf("expected queued execution to get a VM, got %v", err)
+	}
+
+	stats := r.Stats()
+	if stats.Timeouts != 1 || stats.Queued < 1 || stats.Running["app_a"] != 1 || stats.Running["app_b"] != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestPool_SetAppLimit(t *testing.T) {
+	r := NewRuntimeWithPool(PoolConfig{Size: 4, AppConcurrency: 4, QueueTimeout: 50 * time.Millisecond}, time.Second)
+	ctx := context.Background()
+
+	r.SetAppLimit("app_a", 1)
+	if _, err := r.acquireVM(ctx, "app_a"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if _, err := r.acquireVM(ctx, "app_a"); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy over the cap, got %v", err)
+	}
+
+	r.SetAppLimit("app_a", 0)
+	if _, err := r.acquireVM(ctx, "app_a"); err != nil {
+		t.Errorf("expected cap removed, got %v", err)
+	}
+}
+
+func TestPool_EmptyPoolTimesOut(t *testing.T) {
+	r := NewRuntimeWithPool(PoolConfig{Size: 1, QueueTimeout: 50 * time.Millisecond}, time.Second)
+	ctx := context.Background()
+
+	if _, err := r.acquireVM(ctx, "app_a"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	start := time.Now()
+	if _, err := r.acquireVM(ctx, "app_b"); !errors.Is(err, ErrBusy) {
+		t.Errorf("expected ErrBusy with no VM free, got %v", err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("expected to queue for the timeout, waited %v", waited)
+	}
+	if stats := r.Stats(); stats.Running["app_b"] != 0 || stats.Waiting != 0 {
+		t.Errorf("expected timed out execution to leave no trace, got %+v", stats)
+	}
+
+	// The released VM is replaced, so the pool refills
+	r.releaseVM("app_a")
+	if _, err := r.acquireVM(ctx, "app_b"); err != nil {
+		t.Errorf("expected a fresh VM after release, got %v", err)
+	}
+}
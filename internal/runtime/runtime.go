@@ -5,12 +5,14 @@ package runtime
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/dop251/goja"
-	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
 )
 
 const (
@@ -20,10 +22,12 @@ const (
 
 // Runtime manages JavaScript execution.
 type Runtime struct {
-	pool     chan *goja.Runtime
-	poolSize int
-	timeout  time.Duration
-	mu       sync.RWMutex
+	pool           chan *goja.Runtime
+	poolSize       int
+	appConcurrency int
+	queueTimeout   time.Duration
+	slots          *appSlots
+	timeout        time.Duration
 }
 
 // Request represents an HTTP request passed to JavaScript.
@@ -34,6 +38,8 @@ type Request struct {
 	Headers map[string]string      `json:"headers"`
 	Body    interface{}            `json:"body"`
 	Files   map[string]FileUpload  `json:"files,omitempty"`
+	Params  map[string]string      `json:"params,omitempty"` // From file-based routes, e.g. api/users/[id].js
+	Caller  string                 `json:"caller,omitempty"` // The app that made the request with fazt.call
 }
 
 // FileUpload represents an uploaded file from a multipart form.
@@ -66,25 +72,40 @@ type LogEntry struct {
 	Time    time.Time `json:"time"`
 }
 
-// NewRuntime creates a new JavaScript runtime manager.
+// NewRuntime creates a new JavaScript runtime manager with poolSize VMs.
 func NewRuntime(poolSize int, timeout time.Duration) *Runtime {
-	if poolSize <= 0 {
-		poolSize = MaxPoolSize
+	cfg := DefaultPoolConfig()
+	cfg.Size = poolSize
+	return NewRuntimeWithPool(cfg, timeout)
+}
+
+// NewRuntimeWithPool creates a runtime manager with a configured VM pool.
+func NewRuntimeWithPool(cfg PoolConfig, timeout time.Duration) *Runtime {
+	if cfg.Size <= 0 {
+		cfg.Size = MaxPoolSize
+	}
+	if cfg.AppConcurrency <= 0 {
+		cfg.AppConcurrency = DefaultAppConcurrency
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
 	}
 	if timeout <= 0 {
 		timeout = DefaultTimeout
 	}
 
 	r := &Runtime{
-		pool:     make(chan *goja.Runtime, poolSize),
-		poolSize: poolSize,
-		timeout:  timeout,
+		pool:           make(chan *goja.Runtime, cfg.Size),
+		poolSize:       cfg.Size,
+		appConcurrency: cfg.AppConcurrency,
+		queueTimeout:   cfg.QueueTimeout,
+		slots:          newAppSlots(),
+		timeout:        timeout,
 	}
 
 	// Pre-warm the pool
-	for i := 0; i < poolSize; i++ {
-		vm := goja.New()
-		r.pool <- vm
+	for i := 0; i < cfg.Size; i++ {
+		r.pool <- goja.New()
 	}
 
 	return r
@@ -95,27 +116,6 @@ func (r *Runtime) Timeout() time.Duration {
 	return r.timeout
 }
 
-// getVM gets a VM from the pool or creates a new one.
-func (r *Runtime) getVM() *goja.Runtime {
-	select {
-	case vm := <-r.pool:
-		debug.RuntimePool(r.poolSize, len(r.pool))
-		return vm
-	default:
-		debug.Log("runtime", "pool empty, creating new VM")
-		return goja.New()
-	}
-}
-
-// returnVM returns a VM to the pool.
-func (r *Runtime) returnVM(vm *goja.Runtime) {
-	select {
-	case r.pool <- vm:
-	default:
-		debug.Log("runtime", "pool full, discarding VM")
-	}
-}
-
 // Execute runs JavaScript code with the given request context.
 func (r *Runtime) Execute(ctx context.Context, code string, req *Request) *ExecuteResult {
 	start := time.Now()
@@ -123,8 +123,14 @@ func (r *Runtime) Execute(ctx context.Context, code string, req *Request) *Execu
 		Logs: make([]LogEntry, 0),
 	}
 
-	vm := r.getVM()
-	defer r.returnVM(vm)
+	app := modules.ScriptFrom(ctx).Site
+	vm, err := r.acquireVM(ctx, app)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer r.releaseVM(app)
 
 	// Set up timeout
 	done := make(chan struct{})
@@ -155,8 +161,12 @@ func (r *Runtime) Execute(ctx context.Context, code string, req *Request) *Execu
 		vm.ClearInterrupt()
 	}()
 
+	// CPU, memory, call stack and spawn limits
+	tracker, unbind := bindSandbox(ctx, vm)
+	defer func() { unbind(result) }()
+
 	// Inject globals
-	if err := r.injectGlobals(vm, req, result); err != nil {
+	if err := r.injectGlobals(vm, req, result, tracker); err != nil {
 		result.Error = fmt.Errorf("failed to inject globals: %w", err)
 		result.Duration = time.Since(start)
 		return result
@@ -176,8 +186,32 @@ func (r *Runtime) Execute(ctx context.Context, code string, req *Request) *Execu
 	return result
 }
 
+// bindSandbox binds the execution's Tracker to vm, creating one with the
+// default limits when the caller didn't, and starts its CPU clock. The
+// returned unbind detaches it and records the execution's runtime usage.
+func bindSandbox(ctx context.Context, vm *goja.Runtime) (*sandbox.Tracker, func(*ExecuteResult)) {
+	tracker := sandbox.FromContext(ctx)
+	if tracker == nil {
+		tracker = sandbox.New(sandbox.DefaultLimits())
+	}
+	tracker.Bind(vm)
+	stop := tracker.Watch(usage.FromContext(ctx).EgressWait)
+
+	return tracker, func(result *ExecuteResult) {
+		stop()
+		tracker.Unbind()
+		if script := modules.ScriptFrom(ctx); script.Site != "" {
+			usage.RecordExecution(script.Site, script.Path, usage.Execution{
+				CPU:        tracker.CPU(),
+				AllocBytes: tracker.Memory(),
+				Killed:     Killed(result.Error),
+			})
+		}
+	}
+}
+
 // injectGlobals sets up the JavaScript execution environment.
-func (r *Runtime) injectGlobals(vm *goja.Runtime, req *Request, result *ExecuteResult) error {
+func (r *Runtime) injectGlobals(vm *goja.Runtime, req *Request, result *ExecuteResult, tracker *sandbox.Tracker) error {
 	// Inject request object
 	reqObj := vm.NewObject()
 	reqObj.Set("method", req.Method)
@@ -185,9 +219,20 @@ func (r *Runtime) injectGlobals(vm *goja.Runtime, req *Request, result *ExecuteR
 	reqObj.Set("query", req.Query)
 	reqObj.Set("headers", req.Headers)
 	reqObj.Set("body", req.Body)
+	params := req.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+	reqObj.Set("params", params)
+	if req.Caller != "" {
+		reqObj.Set("caller", req.Caller)
+	}
 	if len(req.Files) > 0 {
 		filesObj := vm.NewObject()
 		for name, file := range req.Files {
+			if err := tracker.Alloc(len(file.Data)); err != nil {
+				return err
+			}
 			fileObj := vm.NewObject()
 			fileObj.Set("name", file.Name)
 			fileObj.Set("type", file.Type)
@@ -275,6 +320,9 @@ func (r *Runtime) injectGlobals(vm *goja.Runtime, req *Request, result *ExecuteR
 					msg = fmt.Sprintf(msg, toInterfaceSlice(parts[1:])...)
 				}
 			}
+			if tracker.Alloc(len(msg)) != nil {
+				return goja.Undefined()
+			}
 			result.Logs = append(result.Logs, LogEntry{
 				Level:   level,
 				Message: msg,
@@ -344,8 +392,14 @@ func (r *Runtime) ExecuteWithFiles(ctx context.Context, mainCode string, req *Re
 		Logs: make([]LogEntry, 0),
 	}
 
-	vm := r.getVM()
-	defer r.returnVM(vm)
+	app := modules.ScriptFrom(ctx).Site
+	vm, err := r.acquireVM(ctx, app)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer r.releaseVM(app)
 
 	// Set up timeout
 	done := make(chan struct{})
@@ -376,19 +430,27 @@ func (r *Runtime) ExecuteWithFiles(ctx context.Context, mainCode string, req *Re
 		vm.ClearInterrupt()
 	}()
 
+	// CPU, memory, call stack and spawn limits
+	tracker, unbind := bindSandbox(ctx, vm)
+	defer func() { unbind(result) }()
+
 	// Inject globals
-	if err := r.injectGlobals(vm, req, result); err != nil {
+	if err := r.injectGlobals(vm, req, result, tracker); err != nil {
 		result.Error = fmt.Errorf("failed to inject globals: %w", err)
 		result.Duration = time.Since(start)
 		return result
 	}
 
 	// Inject require with file loading
-	moduleCache := make(map[string]goja.Value)
-	r.injectRequire(vm, fileLoader, "api", moduleCache)
+	script := modules.ScriptFrom(ctx)
+	modules.Enable(vm, script.Site, modules.Loader(fileLoader), "api")
 
-	// Execute the code
-	value, err := vm.RunString(mainCode)
+	// Execute the code, compiled once per version of the file
+	program, err := modules.CompileScript(script.Site, script.Path, mainCode)
+	var value goja.Value
+	if err == nil {
+		value, err = vm.RunProgram(program)
+	}
 	result.Duration = time.Since(start)
 
 	if err != nil {
@@ -404,98 +466,6 @@ func (r *Runtime) ExecuteWithFiles(ctx context.Context, mainCode string, req *Re
 // FileLoader is a function that loads file content by path.
 type FileLoader func(path string) (string, error)
 
-// injectRequire adds the require() function for module loading.
-func (r *Runtime) injectRequire(vm *goja.Runtime, loader FileLoader, basePath string, cache map[string]goja.Value) {
-	vm.Set("require", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			panic(vm.NewGoError(fmt.Errorf("require() needs a path argument")))
-		}
-
-		path := call.Argument(0).String()
-
-		// 1. Check stdlib first for bare specifiers (not relative paths)
-		if !isRelativePath(path) {
-			if source, ok := GetStdlibModule(path); ok {
-				cacheKey := "stdlib:" + path
-				if cached, ok := cache[cacheKey]; ok {
-					return cached
-				}
-
-				// Wrap in module pattern
-				moduleCode := fmt.Sprintf(`
-(function() {
-    var module = { exports: {} };
-    var exports = module.exports;
-    %s
-    return module.exports;
-})()
-`, source)
-
-				result, err := vm.RunString(moduleCode)
-				if err != nil {
-					panic(vm.NewGoError(fmt.Errorf("stdlib %s error: %w", path, err)))
-				}
-
-				cache[cacheKey] = result
-				return result
-			}
-		}
-
-		// 2. Fall back to local file resolution
-		resolved := resolvePath(basePath, path)
-
-		// Check cache
-		if cached, ok := cache[resolved]; ok {
-			return cached
-		}
-
-		// Load the file
-		content, err := loader(resolved)
-		if err != nil {
-			panic(vm.NewGoError(fmt.Errorf("cannot require '%s': %w", path, err)))
-		}
-
-		// Wrap in module pattern
-		moduleCode := fmt.Sprintf(`
-(function() {
-    var module = { exports: {} };
-    var exports = module.exports;
-    %s
-    return module.exports;
-})()
-`, content)
-
-		// Execute
-		result, err := vm.RunString(moduleCode)
-		if err != nil {
-			panic(vm.NewGoError(fmt.Errorf("error in '%s': %w", path, err)))
-		}
-
-		// Cache the result
-		cache[resolved] = result
-		return result
-	})
-}
-
-// isRelativePath checks if a path starts with ./ or ../
-func isRelativePath(path string) bool {
-	return len(path) >= 2 && (path[:2] == "./" || (len(path) >= 3 && path[:3] == "../"))
-}
-
-// resolvePath resolves a relative require path.
-func resolvePath(basePath, requirePath string) string {
-	// Handle relative paths
-	if len(requirePath) >= 2 && requirePath[:2] == "./" {
-		return basePath + "/" + requirePath[2:]
-	}
-	if len(requirePath) >= 3 && requirePath[:3] == "../" {
-		// For now, don't allow escaping the base path
-		return basePath + "/" + requirePath
-	}
-	// Bare specifier - treat as relative to base
-	return basePath + "/" + requirePath
-}
-
 // toInterfaceSlice converts a string slice to interface slice.
 func toInterfaceSlice(ss []string) []interface{} {
 	result := make([]interface{}, len(ss))
@@ -520,8 +490,14 @@ func (r *Runtime) ExecuteWithInjectors(ctx context.Context, mainCode string, req
 		Logs: make([]LogEntry, 0),
 	}
 
-	vm := r.getVM()
-	defer r.returnVM(vm)
+	app := modules.ScriptFrom(ctx).Site
+	vm, err := r.acquireVM(ctx, app)
+	if err != nil {
+		result.Error = err
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer r.releaseVM(app)
 
 	// Set up timeout
 	done := make(chan struct{})
@@ -552,16 +528,20 @@ func (r *Runtime) ExecuteWithInjectors(ctx context.Context, mainCode string, req
 		vm.ClearInterrupt()
 	}()
 
+	// CPU, memory, call stack and spawn limits
+	tracker, unbind := bindSandbox(ctx, vm)
+	defer func() { unbind(result) }()
+
 	// Inject globals
-	if err := r.injectGlobals(vm, req, result); err != nil {
+	if err := r.injectGlobals(vm, req, result, tracker); err != nil {
 		result.Error = fmt.Errorf("failed to inject globals: %w", err)
 		result.Duration = time.Since(start)
 		return result
 	}
 
 	// Inject require with file loading
-	moduleCache := make(map[string]goja.Value)
-	r.injectRequire(vm, fileLoader, "api", moduleCache)
+	script := modules.ScriptFrom(ctx)
+	modules.Enable(vm, script.Site, modules.Loader(fileLoader), "api")
 
 	// Run custom injectors
 	for _, injector := range injectors {
@@ -572,8 +552,12 @@ func (r *Runtime) ExecuteWithInjectors(ctx context.Context, mainCode string, req
 		}
 	}
 
-	// Execute the code
-	value, err := vm.RunString(mainCode)
+	// Execute the code, compiled once per version of the file
+	program, err := modules.CompileScript(script.Site, script.Path, mainCode)
+	var value goja.Value
+	if err == nil {
+		value, err = vm.RunProgram(program)
+	}
 	result.Duration = time.Since(start)
 
 	if err != nil {
@@ -594,6 +578,7 @@ type JSError struct {
 	Line    int    `json:"line,omitempty"`
 	Column  int    `json:"column,omitempty"`
 	Context string `json:"context,omitempty"`
+	Limit   string `json:"limit,omitempty"` // Sandbox limit hit, for LimitError
 }
 
 func (e *JSError) Error() string {
@@ -606,12 +591,47 @@ func (e *JSError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Type, e.Message)
 }
 
+// LimitOf returns the sandbox limit an execution error hit ("cpu",
+// "memory", "spawn" or "call_stack"), or "" if it isn't a limit violation
+func LimitOf(err error) string {
+	var jsErr *JSError
+	if errors.As(err, &jsErr) {
+		return jsErr.Limit
+	}
+	var limitErr *sandbox.LimitError
+	if errors.As(err, &limitErr) {
+		return limitErr.Kind
+	}
+	return ""
+}
+
+// Killed reports whether an execution was interrupted by its timeout or a
+// sandbox limit, rather than failing on its own
+func Killed(err error) bool {
+	var jsErr *JSError
+	if errors.As(err, &jsErr) && jsErr.Type == "TimeoutError" {
+		return true
+	}
+	return LimitOf(err) != ""
+}
+
 // formatJSError creates a detailed error from a Goja error
 func formatJSError(err error, code string) error {
 	if err == nil {
 		return nil
 	}
 
+	// Handle sandbox limits, whether they interrupted the VM or surfaced
+	// first as a binding error
+	var limitErr *sandbox.LimitError
+	if errors.As(err, &limitErr) {
+		return &JSError{
+			Type:    "LimitError",
+			Message: limitErr.Error(),
+			Limit:   limitErr.Kind,
+		}
+	}
+
 	// Handle timeout interrupts
 	if jserr, ok := err.(*goja.InterruptedError); ok {
 		return &JSError{
@@ -620,6 +640,15 @@ func formatJSError(err error, code string) error {
 		}
 	}
 
+	// Handle runaway recursion (uncatchable in JS)
+	if _, ok := err.(*goja.StackOverflowError); ok {
+		return &JSError{
+			Type:    "LimitError",
+			Message: "maximum call stack size exceeded",
+			Limit:   "call_stack",
+		}
+	}
+
 	// Handle syntax errors (compile time)
 	if syntaxErr, ok := err.(*goja.CompilerSyntaxError); ok {
 		jsErr := &JSError{
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	"github.com/fazt-sh/fazt/internal/sandbox"
 )
 
 func TestNewRuntime(t *testing.T) {
@@ -286,27 +288,6 @@ func TestExecuteWithFiles_RequireNotFound(t *testing.T) {
 	}
 }
 
-func TestResolvePath(t *testing.T) {
-	tests := []struct {
-		base     string
-		require  string
-		expected string
-	}{
-		{"api", "./utils.js", "api/utils.js"},
-		{"api", "./lib/helper.js", "api/lib/helper.js"},
-		{"api", "utils", "api/utils"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.require, func(t *testing.T) {
-			result := resolvePath(tt.base, tt.require)
-			if result != tt.expected {
-				t.Errorf("expected %s, got %s", tt.expected, result)
-			}
-		})
-	}
-}
-
 func TestJSError(t *testing.T) {
 	t.Run("error with line number and context", func(t *testing.T) {
 		err := &JSError{
@@ -420,3 +401,50 @@ func TestSplitLines(t *testing.T) {
 
 // Suppress unused import warning
 var _ = fmt.Sprintf
+
+func TestExecute_CallStackLimit(t *testing.T) {
+	r := NewRuntime(1, time.Second)
+
+	req := &Request{Method: "GET", Path: "/test"}
+	result := r.Execute(context.Background(), `function f() { try { return f() } catch (e) { return 1 } } f()`, req)
+
+	if LimitOf(result.Error) != "call_stack" {
+		t.Fatalf("expected call stack limit, got %v", result.Error)
+	}
+}
+
+func TestExecute_MemoryLimit(t *testing.T) {
+	r := NewRuntime(1, time.Second)
+	ctx := sandbox.WithTracker(context.Background(), sandbox.New(sandbox.Limits{MaxMemory: 1024}))
+
+	req := &Request{Method: "GET", Path: "/test"}
+	result := r.Execute(ctx, `var s = "x".repeat(100); while (true) { console.log(s) }`, req)
+
+	jsErr, ok := result.Error.(*JSError)
+	if !ok || jsErr.Type != "LimitError" || jsErr.Limit != "memory" {
+		t.Fatalf("expected memory LimitError, got %v", result.Error)
+	}
+	if len(result.Logs) > 10 {
+		t.Errorf("expected logging to stop at the limit, got %d entries", len(result.Logs))
+	}
+
+	// The pooled VM is usable again afterwards
+	result = r.Execute(context.Background(), `"ok"`, req)
+	if result.Error != nil || result.Response.Body != "ok" {
+		t.Errorf("expected clean VM after limit, got %v", result.Error)
+	}
+}
+
+func TestExecute_FileUploadCharged(t *testing.T) {
+	r := NewRuntime(1, time.Second)
+	ctx := sandbox.WithTracker(context.Background(), sandbox.New(sandbox.Limits{MaxMemory: 10}))
+
+	req := &Request{Method: "POST", Path: "/upload", Files: map[string]FileUpload{
+		"file": {Name: "big.bin", Size: 100, Data: make([]byte, 100)},
+	}}
+	result := r.Execute(ctx, `"ok"`, req)
+
+	if LimitOf(result.Error) != "memory" {
+		t.Errorf("expected memory limit for oversized upload, got %v", result.Error)
+	}
+}
@@ -0,0 +1,195 @@
+package runtime
+
+import (
+	"database/sql"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/usage"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// serverStart is when the server started, for fazt.sys.stats
+var serverStart = time.Now()
+
+// InjectSysNamespace adds fazt.sys, read-only information about the whole
+// server: fazt.sys.apps.list(), fazt.sys.stats() and fazt.sys.jobs(opts).
+// Every app gets the namespace, but its calls throw unless an admin gave
+// the app system access with `fazt app system <app>`.
+func InjectSysNamespace(vm *goja.Runtime, db *sql.DB, app *AppContext) error {
+	faztVal := vm.Get("fazt")
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		return nil
+	}
+	fazt := faztVal.ToObject(vm)
+
+	// Checked on first use, so requests that don't touch fazt.sys don't pay for it
+	var checked, allowed bool
+	require := func(name string) {
+		if !checked {
+			on, err := hosting.HasSystemAccess(db, app.ID)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("fazt.sys.%s: %w", name, err)))
+			}
+			checked, allowed = true, on
+		}
+		if !allowed {
+			panic(vm.NewGoError(fmt.Errorf("fazt.sys.%s: %s has no system access (fazt app system %s)", name, app.ID, app.ID)))
+		}
+	}
+
+	sys := vm.NewObject()
+	apps := vm.NewObject()
+
+	// fazt.sys.apps.list() - every app, oldest first
+	apps.Set("list", func(call goja.FunctionCall) goja.Value {
+		require("apps.list")
+		list, err := hosting.ListSystemApps(db)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		result := make([]interface{}, len(list))
+		for i, a := range list {
+			aliases := make([]interface{}, len(a.Aliases))
+			for j, alias := range a.Aliases {
+				aliases[j] = alias
+			}
+			result[i] = map[string]interface{}{
+				"id":           a.ID,
+				"title":        a.Title,
+				"visibility":   a.Visibility,
+				"aliases":      aliases,
+				"fileCount":    a.FileCount,
+				"sizeBytes":    a.SizeBytes,
+				"protected":    a.Protected,
+				"systemAccess": a.SystemAccess,
+				"createdAt":    a.CreatedAt,
+				"updatedAt":    a.UpdatedAt,
+			}
+		}
+		return vm.ToValue(result)
+	})
+	sys.Set("apps", apps)
+
+	// fazt.sys.stats() - the server's version, uptime, memory, caches,
+	// job pool and today's requests
+	sys.Set("stats", func(call goja.FunctionCall) goja.Value {
+		require("stats")
+		return vm.ToValue(sysStats(vm, db))
+	})
+
+	// fazt.sys.jobs({app, status, limit}) - every app's recent jobs, newest
+	// first, without their data, results or logs
+	sys.Set("jobs", func(call goja.FunctionCall) goja.Value {
+		require("jobs")
+		appID := ""
+		var status *worker.JobStatus
+		limit := 50
+		if opts := call.Argument(0); !goja.IsUndefined(opts) && !goja.IsNull(opts) {
+			o := opts.ToObject(vm)
+			if v := o.Get("app"); v != nil && !goja.IsUndefined(v) {
+				appID = v.String()
+			}
+			if v := o.Get("status"); v != nil && !goja.IsUndefined(v) {
+				s := worker.JobStatus(v.String())
+				status = &s
+			}
+			if v := o.Get("limit"); v != nil && !goja.IsUndefined(v) {
+				limit = int(v.ToInteger())
+			}
+		}
+		if limit <= 0 || limit > 500 {
+			limit = 50
+		}
+
+		jobs, err := worker.List(appID, status, limit)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		result := make([]interface{}, len(jobs))
+		for i, job := range jobs {
+			j := map[string]interface{}{
+				"id":       job.ID,
+				"app":      job.AppID,
+				"handler":  job.Handler,
+				"status":   string(job.Status),
+				"progress": int(job.Progress * 100),
+				"attempt":  job.Attempt,
+			}
+			if job.Error != "" {
+				j["error"] = job.Error
+			}
+			if !job.CreatedAt.IsZero() {
+				j["createdAt"] = job.CreatedAt.UnixMilli()
+			}
+			if !job.StartedAt.IsZero() {
+				j["startedAt"] = job.StartedAt.UnixMilli()
+			}
+			if !job.DoneAt.IsZero() {
+				j["completedAt"] = job.DoneAt.UnixMilli()
+			}
+			result[i] = j
+		}
+		return vm.ToValue(result)
+	})
+
+	fazt.Set("sys", sys)
+	return nil
+}
+
+// sysStats gathers fazt.sys.stats
+func sysStats(vm *goja.Runtime, db *sql.DB) map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	var apps int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM apps`).Scan(&apps); err != nil {
+		panic(vm.NewGoError(err))
+	}
+	var requests, cpuMs int64
+	today, err := usage.Summary(db, time.Now().UTC().Truncate(24*time.Hour))
+	if err != nil {
+		panic(vm.NewGoError(err))
+	}
+	for _, u := range today {
+		requests += u.Requests
+		cpuMs += u.CPUMs
+	}
+
+	dbStats := database.GetDBStats()
+	vfs := hosting.GetStats()
+	stats := map[string]interface{}{
+		"version":       config.Version,
+		"uptimeSeconds": int64(time.Since(serverStart).Seconds()),
+		"goroutines":    runtime.NumGoroutine(),
+		"memoryMB":      float64(m.Alloc) / 1024 / 1024,
+		"apps":          apps,
+		"today": map[string]interface{}{
+			"requests": requests,
+			"cpuMs":    cpuMs,
+		},
+		"database": map[string]interface{}{
+			"openConnections": dbStats.OpenConnections,
+			"inUse":           dbStats.InUse,
+		},
+		"cache": map[string]interface{}{
+			"files":  vfs.CachedFiles,
+			"bytes":  vfs.CacheSizeBytes,
+			"hits":   vfs.Hits,
+			"misses": vfs.Misses,
+		},
+	}
+	if pool := worker.Stats(); pool != nil {
+		stats["jobs"] = map[string]interface{}{
+			"active": pool.ActiveJobs,
+			"queued": pool.QueuedJobs,
+			"total":  pool.TotalJobs,
+		}
+	}
+	return stats
+}
@@ -0,0 +1,50 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestFaztSys(t *testing.T) {
+	db := dbtest.Open(t)
+	db.Exec(`INSERT INTO apps (id, title, visibility) VALUES ('app_dash', 'dashboard', 'private'), ('app_blog', 'blog', 'public')`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('ops', 'app', '{"app_id":"app_dash"}')`)
+	db.Exec(`INSERT INTO files (site_id, app_id, path, content, size_bytes, hash) VALUES ('blog', 'app_blog', 'index.html', 'hi', 2, 'h')`)
+
+	run := func(site, code string) (goja.Value, error) {
+		vm := goja.New()
+		vm.Set("fazt", vm.NewObject())
+		InjectSysNamespace(vm, db, &AppContext{ID: site, Name: site})
+		return vm.RunString(code)
+	}
+
+	if _, err := run("ops", `fazt.sys.apps.list()`); err == nil || !strings.Contains(err.Error(), "no system access") {
+		t.Fatalf("Expected fazt.sys refused without system access, got %v", err)
+	}
+
+	if err := hosting.SetSystemAccess(db, "app_dash", true); err != nil {
+		t.Fatal(err)
+	}
+	// The alias runs as the app it routes to
+	v, err := run("ops", `
+		var apps = fazt.sys.apps.list()
+		var blog = apps.filter(function(a) { return a.id === 'app_blog' })[0]
+		var dash = apps.filter(function(a) { return a.id === 'app_dash' })[0]
+		apps.length + '|' + blog.fileCount + '|' + blog.sizeBytes + '|' + dash.aliases.join(',') + '|' +
+			dash.systemAccess + '|' + (fazt.sys.stats().apps)
+	`)
+	if err != nil {
+		t.Fatalf("fazt.sys failed: %v", err)
+	}
+	if got := v.String(); got != "2|1|2|ops|true|2" {
+		t.Errorf("Unexpected fazt.sys results: %s", got)
+	}
+
+	if _, err := run("blog", `fazt.sys.stats()`); err == nil {
+		t.Error("Expected another app refused")
+	}
+}
@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"context"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/assets"
+	"github.com/fazt-sh/fazt/internal/egress"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	imgservice "github.com/fazt-sh/fazt/internal/services/image"
+	wasmservice "github.com/fazt-sh/fazt/internal/services/wasm"
+	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+// TestFaztTypes_CoverBindings keeps the hand-written fazt.d.ts honest: every
+// member of fazt.* and job.* a VM gets must be declared there
+func TestFaztTypes_CoverBindings(t *testing.T) {
+	ctx := context.Background()
+	app := &AppContext{ID: "app1", Name: "notes"}
+	app.CSRF = NewAppCSRF(httptest.NewRequest("GET", "/", nil), app.ID, nil)
+	authCtx := &AuthContext{}
+
+	vm := goja.New()
+	injectors := []VMInjector{
+		func(vm *goja.Runtime) error { return InjectFaztNamespace(vm, app, EnvVars{}, &ExecuteResult{}) },
+		func(vm *goja.Runtime) error {
+			return storage.InjectStorageNamespace(vm, &storage.Storage{}, app.ID, ctx, nil)
+		},
+		func(vm *goja.Runtime) error { return storage.InjectAppNamespace(vm, nil, nil, app.ID, "u1", ctx, nil) },
+		func(vm *goja.Runtime) error { return hosting.InjectRealtimeNamespace(vm, app.ID) },
+		func(vm *goja.Runtime) error { return worker.InjectWorkerNamespace(vm, app.ID, ctx) },
+		func(vm *goja.Runtime) error { return InjectAuthNamespace(vm, authCtx, app) },
+		func(vm *goja.Runtime) error { return InjectAppAuthNamespace(vm, authCtx, app) },
+		func(vm *goja.Runtime) error { return InjectAppCSRFNamespace(vm, app.CSRF) },
+		func(vm *goja.Runtime) error { return InjectPrivateNamespace(vm, NewPrivateFileLoader(nil, app.ID)) },
+		func(vm *goja.Runtime) error { return egress.InjectNetNamespace(vm, nil, app.ID, ctx, nil) },
+		func(vm *goja.Runtime) error { return imgservice.InjectImageNamespace(vm) },
+		func(vm *goja.Runtime) error { return InjectUtilNamespace(vm, app) },
+		func(vm *goja.Runtime) error { return wasmservice.InjectWasmNamespace(vm, ctx, nil) },
+		func(vm *goja.Runtime) error { return worker.InjectJobContext(vm, &worker.Job{ID: "job1"}) },
+	}
+	for i, inject := range injectors {
+		if err := inject(vm); err != nil {
+			t.Fatalf("injector %d failed: %v", i, err)
+		}
+	}
+
+	// Member names, with the path they were found at for the error message
+	members := map[string]string{}
+	var walk func(obj *goja.Object, path string, depth int)
+	walk = func(obj *goja.Object, path string, depth int) {
+		for _, key := range obj.Keys() {
+			if _, seen := members[key]; !seen {
+				members[key] = path + "." + key
+			}
+			if child, ok := obj.Get(key).(*goja.Object); ok && depth < 4 {
+				if _, isFn := goja.AssertFunction(child); !isFn {
+					walk(child, path+"."+key, depth+1)
+				}
+			}
+		}
+	}
+	walk(vm.Get("fazt").ToObject(vm), "fazt", 0)
+	walk(vm.Get("job").ToObject(vm), "job", 0)
+
+	types := assets.FaztTypes()
+	var missing []string
+	for key, path := range members {
+		declared := regexp.MustCompile(`\b` + regexp.QuoteMeta(key) + `(<[^(]*>)?\??\s*[:(]`)
+		if !declared.Match(types) {
+			missing = append(missing, path)
+		}
+	}
+	sort.Strings(missing)
+	for _, path := range missing {
+		t.Errorf("%s is not declared in fazt.d.ts", path)
+	}
+}
@@ -0,0 +1,127 @@
+package runtime
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/qrcode"
+	"github.com/fazt-sh/fazt/internal/services/ics"
+	"github.com/fazt-sh/fazt/internal/services/vcard"
+)
+
+// QR code image bounds, in pixels
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 2048
+)
+
+// InjectUtilNamespace adds fazt.util.* to the VM: small helpers personal
+// apps keep needing, for calendars, contact cards and QR codes. Calendar
+// UIDs are qualified with the app ID, as in the app's /calendar.ics feed.
+func InjectUtilNamespace(vm *goja.Runtime, app *AppContext) error {
+	faztVal := vm.Get("fazt")
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		return fmt.Errorf("fazt object not found on VM")
+	}
+	fazt := faztVal.ToObject(vm)
+
+	domain := ""
+	if app != nil {
+		domain = app.ID
+	}
+
+	util := vm.NewObject()
+
+	// fazt.util.ics(events, { name, refresh }) -> string
+	util.Set("ics", func(call goja.FunctionCall) goja.Value {
+		records, ok := call.Argument(0).Export().([]interface{})
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.ics: events must be an array")))
+		}
+		if len(records) > ics.MaxEvents {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.ics: at most %d events", ics.MaxEvents)))
+		}
+
+		cal := &ics.Calendar{Events: make([]ics.Event, 0, len(records))}
+		if opts, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+			cal.Name, _ = opts["name"].(string)
+			switch v := opts["refresh"].(type) {
+			case int64:
+				cal.Refresh = time.Duration(v) * time.Second
+			case float64:
+				cal.Refresh = time.Duration(v * float64(time.Second))
+			}
+		}
+
+		for i, r := range records {
+			rec, ok := r.(map[string]interface{})
+			if !ok {
+				panic(vm.NewGoError(fmt.Errorf("fazt.util.ics: event %d must be an object", i)))
+			}
+			e, err := ics.ParseEvent(rec, domain)
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("fazt.util.ics: %w", err)))
+			}
+			cal.Events = append(cal.Events, e)
+		}
+
+		return vm.ToValue(cal.Render(time.Now()))
+	})
+
+	// fazt.util.vcard(contact) -> string
+	util.Set("vcard", func(call goja.FunctionCall) goja.Value {
+		rec, ok := call.Argument(0).Export().(map[string]interface{})
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.vcard: contact must be an object")))
+		}
+		card, err := vcard.ParseCard(rec)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.vcard: %w", err)))
+		}
+		return vm.ToValue(card.Render())
+	})
+
+	// fazt.util.qr(text, { size }) -> { data, mime, size, width }, shaped
+	// like a blob from fazt.app.s3.get: data is the PNG, base64-encoded
+	util.Set("qr", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) == 0 || goja.IsUndefined(call.Argument(0)) || goja.IsNull(call.Argument(0)) {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.qr requires text")))
+		}
+		text := call.Argument(0).String()
+
+		size := defaultQRSize
+		if opts, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+			switch v := opts["size"].(type) {
+			case int64:
+				size = int(v)
+			case float64:
+				size = int(v)
+			}
+		}
+		if size < minQRSize || size > maxQRSize {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.qr: size must be between %d and %d", minQRSize, maxQRSize)))
+		}
+
+		code, err := qrcode.Encode([]byte(text))
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.qr: %w", err)))
+		}
+		png, err := code.PNG(size)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.util.qr: %w", err)))
+		}
+
+		return vm.ToValue(map[string]interface{}{
+			"data":  base64.StdEncoding.EncodeToString(png),
+			"mime":  "image/png",
+			"size":  len(png),
+			"width": code.Width(size),
+		})
+	})
+
+	fazt.Set("util", util)
+	return nil
+}
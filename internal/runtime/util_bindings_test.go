@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+)
+
+func setupUtilVM(t *testing.T) *goja.Runtime {
+	t.Helper()
+	vm := goja.New()
+	vm.Set("fazt", vm.NewObject())
+	if err := InjectUtilNamespace(vm, &AppContext{ID: "club"}); err != nil {
+		t.Fatalf("InjectUtilNamespace failed: %v", err)
+	}
+	return vm
+}
+
+func TestUtilICS(t *testing.T) {
+	vm := setupUtilVM(t)
+
+	v, err := vm.RunString(`fazt.util.ics([{ id: "a", title: "Talk", start: new Date(Date.UTC(2026, 10, 2, 18)).toISOString() }], { name: "Talks" })`)
+	if err != nil {
+		t.Fatalf("fazt.util.ics failed: %v", err)
+	}
+	if out := v.String(); !strings.Contains(out, "UID:a@club\r\n") || !strings.Contains(out, "DTSTART:20261102T180000Z") {
+		t.Errorf("Unexpected calendar:\n%s", out)
+	}
+
+	if _, err := vm.RunString(`fazt.util.ics([{ start: "2026-11-02" }])`); err == nil {
+		t.Error("Expected an event without a title to throw")
+	}
+}
+
+func TestUtilVCard(t *testing.T) {
+	vm := setupUtilVM(t)
+
+	v, err := vm.RunString(`fazt.util.vcard({ name: "Ada Lovelace", email: "ada@example.com", phone: [{ type: "cell", value: "+44 20 7946 0000" }] })`)
+	if err != nil {
+		t.Fatalf("fazt.util.vcard failed: %v", err)
+	}
+	out := v.String()
+	if !strings.HasPrefix(out, "BEGIN:VCARD\r\n") || !strings.Contains(out, "N:Lovelace;Ada;;;\r\n") || !strings.Contains(out, "TEL;TYPE=CELL:") {
+		t.Errorf("Unexpected card:\n%s", out)
+	}
+
+	if _, err := vm.RunString(`fazt.util.vcard({ org: "No name" })`); err == nil {
+		t.Error("Expected a contact without a name to throw")
+	}
+}
+
+func TestUtilQR(t *testing.T) {
+	vm := setupUtilVM(t)
+
+	v, err := vm.RunString(`fazt.util.qr("https://example.com/ada", { size: 300 })`)
+	if err != nil {
+		t.Fatalf("fazt.util.qr failed: %v", err)
+	}
+	obj := v.ToObject(vm)
+	png, err := base64.StdEncoding.DecodeString(obj.Get("data").String())
+	if err != nil || !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Fatalf("Expected base64 PNG data, got error %v", err)
+	}
+	if obj.Get("mime").String() != "image/png" || obj.Get("size").ToInteger() != int64(len(png)) {
+		t.Errorf("Unexpected mime or size: %v, %v", obj.Get("mime"), obj.Get("size"))
+	}
+	if w := obj.Get("width").ToInteger(); w > 300 || w < 150 {
+		t.Errorf("Expected a width close to 300, got %d", w)
+	}
+
+	if _, err := vm.RunString(`fazt.util.qr("x", { size: 10 })`); err == nil {
+		t.Error("Expected a tiny size to throw")
+	}
+	if _, err := vm.RunString(`fazt.util.qr()`); err == nil {
+		t.Error("Expected missing text to throw")
+	}
+}
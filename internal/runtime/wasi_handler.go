@@ -0,0 +1,106 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/crashloop"
+	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/hosting"
+	wasmservice "github.com/fazt-sh/fazt/internal/services/wasm"
+	"github.com/fazt-sh/fazt/internal/system"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+// serveWASI runs a WASI route (api/resize.wasm) CGI-style: the request in
+// environment variables and on stdin, the response on stdout. It takes a
+// pool slot like a JS execution, and stderr goes to the app's logs.
+func (h *ServerlessHandler) serveWASI(w http.ResponseWriter, r *http.Request, appID, appName string, route *hosting.APIRoute, params map[string]string, reqID string, start time.Time) {
+	fail := func(status int, body map[string]interface{}) {
+		debug.RuntimeReq(reqID, appName, r.URL.Path, status, time.Since(start))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+
+	code, err := h.loadFile(appID, route.File)
+	if err != nil {
+		debug.RuntimeReq(reqID, appName, r.URL.Path, 404, time.Since(start))
+		http.Error(w, "No serverless handler found", http.StatusNotFound)
+		return
+	}
+
+	maxBody := system.GetLimits().Storage.MaxUpload
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		fail(http.StatusBadRequest, map[string]interface{}{"error": "Failed to read request body"})
+		return
+	}
+	if int64(len(body)) > maxBody {
+		fail(http.StatusRequestEntityTooLarge, map[string]interface{}{"error": "Request body too large"})
+		return
+	}
+
+	vm, err := h.runtime.acquireVM(r.Context(), appID)
+	if err != nil {
+		w.Header().Set("Retry-After", "1")
+		fail(http.StatusServiceUnavailable, map[string]interface{}{
+			"error":     "Server overloaded, please retry",
+			"retryable": true,
+		})
+		return
+	}
+	defer h.runtime.returnVM(appID, vm)
+
+	limits := wasmservice.DefaultLimits()
+	limits.Timeout = h.runtime.Timeout()
+	runStart := time.Now()
+	resp, stderr, err := wasmservice.RunHandler(r.Context(), []byte(code), &wasmservice.Request{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Headers: requestHeaders(r),
+		Params:  params,
+		Env:     h.loadEnvVars(appID),
+		Body:    body,
+	}, limits)
+
+	killed := errors.Is(err, wasmservice.ErrTimeout)
+	usage.RecordExecution(appID, route.Pattern, usage.Execution{
+		CPU:        time.Since(runStart),
+		AllocBytes: int64(len(body)),
+		Killed:     killed,
+	})
+
+	var logs []LogEntry
+	for _, line := range strings.Split(strings.TrimRight(stderr, "\n"), "\n") {
+		if line != "" {
+			logs = append(logs, LogEntry{Level: "error", Message: line})
+		}
+	}
+	h.persistLogs(appID, logs, err)
+
+	if err != nil {
+		crashloop.RecordCrash(appID, err.Error(), time.Now())
+		body := map[string]interface{}{"error": err.Error()}
+		if killed {
+			body["limit"] = "timeout"
+		}
+		fail(http.StatusInternalServerError, body)
+		return
+	}
+	crashloop.RecordSuccess(appID)
+
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.Status)
+	debug.RuntimeReq(reqID, appName, r.URL.Path, resp.Status, time.Since(start))
+	if r.Method != http.MethodHead {
+		w.Write(resp.Body)
+	}
+}
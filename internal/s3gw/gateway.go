@@ -0,0 +1,543 @@
+// Package s3gw serves a subset of the S3 REST API on s3.<domain>, backed by
+// app blob storage, so S3 tools (rclone, restic, the AWS CLI and SDKs) can
+// read and write an app's files directly. Each app is a bucket named after
+// it, reached with the app's access keys; requests are path-style
+// (s3.<domain>/<app>/<key>) and signed with SigV4, in the header or as a
+// presigned URL. Supported: ListBuckets, HeadBucket, GetBucketLocation,
+// ListObjects (V1 and V2), Get/Head/Put/Copy/DeleteObject. Objects are
+// uploaded in a single PUT; multipart uploads aren't supported.
+package s3gw
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+	"github.com/fazt-sh/fazt/internal/realip"
+	"github.com/fazt-sh/fazt/internal/sigv4"
+	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/system"
+	"github.com/fazt-sh/fazt/internal/virusscan"
+)
+
+const (
+	// maxListKeys caps the objects and prefixes in one listing
+	maxListKeys = 1000
+
+	// maxObjectKey is the longest object key, in bytes, as in S3
+	maxObjectKey = 1024
+
+	// xmlns is the namespace of S3 responses
+	xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
+
+// unsupportedSubresources are query parameters that select an S3 feature
+// the gateway doesn't implement
+var unsupportedSubresources = []string{
+	"acl", "tagging", "uploads", "uploadId", "versioning", "versions", "lifecycle", "policy",
+	"cors", "website", "torrent", "retention", "legal-hold", "attributes", "delete", "restore",
+}
+
+// Gateway serves the S3 API
+type Gateway struct {
+	db    *sql.DB
+	blobs *storage.SQLBlobStore
+	now   func() time.Time
+}
+
+// NewGateway creates a gateway over app blob storage
+func NewGateway(db *sql.DB, writer *storage.WriteQueue) *Gateway {
+	return &Gateway{
+		db:    db,
+		blobs: storage.NewSQLBlobStoreWithWriter(db, writer),
+		now:   time.Now,
+	}
+}
+
+// s3Error is an S3 error response
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+var (
+	errNoSuchKey      = &s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist."}
+	errNotImplemented = &s3Error{http.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented."}
+)
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var key *Key
+	auth, err := sigv4.Verify(r, func(id string) (string, error) {
+		k, err := GetKey(g.db, id)
+		if errors.Is(err, ErrKeyNotFound) {
+			return "", sigv4.ErrUnknownKey
+		}
+		if err != nil {
+			return "", err
+		}
+		key = k
+		return k.Secret, nil
+	}, g.now())
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+	touchKey(g.db, key.ID, g.now())
+
+	bucket, object, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			writeError(w, r, methodNotAllowed())
+			return
+		}
+		g.listBuckets(w, key)
+		return
+	}
+	if bucket != key.AppID {
+		writeError(w, r, &s3Error{http.StatusForbidden, "AccessDenied", "This access key can only reach bucket " + key.AppID + "."})
+		return
+	}
+	if key.ReadOnly && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, &s3Error{http.StatusForbidden, "AccessDenied", "This access key is read-only."})
+		return
+	}
+	q := r.URL.Query()
+	for _, sub := range unsupportedSubresources {
+		if q.Has(sub) {
+			writeError(w, r, errNotImplemented)
+			return
+		}
+	}
+
+	if object == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodGet && q.Has("location"):
+			writeXML(w, http.StatusOK, locationConstraint{Xmlns: xmlns})
+		case r.Method == http.MethodGet:
+			g.listObjects(w, r, bucket)
+		case r.Method == http.MethodPut:
+			writeError(w, r, &s3Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "Each app has one bucket, which already exists."})
+		default:
+			writeError(w, r, methodNotAllowed())
+		}
+		return
+	}
+
+	if len(object) > maxObjectKey || !utf8.ValidString(object) {
+		writeError(w, r, &s3Error{http.StatusBadRequest, "KeyTooLongError", "Your key is too long."})
+		return
+	}
+	if strings.HasPrefix(object, "/") || strings.Contains(object, "//") {
+		writeError(w, r, &s3Error{http.StatusBadRequest, "InvalidArgument", "Object keys may not start with / or contain //."})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		g.getObject(w, r, bucket, object)
+	case http.MethodHead:
+		g.headObject(w, r, bucket, object)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			g.copyObject(w, r, key, bucket, object)
+			return
+		}
+		g.putObject(w, r, auth, key, bucket, object)
+	case http.MethodDelete:
+		g.deleteObject(w, r, key, bucket, object)
+	default:
+		writeError(w, r, methodNotAllowed())
+	}
+}
+
+func (g *Gateway) listBuckets(w http.ResponseWriter, key *Key) {
+	writeXML(w, http.StatusOK, listAllMyBucketsResult{
+		Xmlns: xmlns,
+		Owner: owner{ID: key.AppID, DisplayName: key.AppID},
+		Buckets: []bucketEntry{{
+			Name:         key.AppID,
+			CreationDate: time.Unix(key.CreatedAt, 0).UTC().Format(time.RFC3339),
+		}},
+	})
+}
+
+func (g *Gateway) getObject(w http.ResponseWriter, r *http.Request, bucket, object string) {
+	blob, err := g.blobs.Get(r.Context(), bucket, object)
+	if err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+	if blob == nil {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	meta, err := g.blobs.GetMeta(r.Context(), bucket, object)
+	if err != nil || meta == nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+
+	setObjectHeaders(w, r, meta)
+	http.ServeContent(w, r, "", meta.UpdatedAt, bytes.NewReader(blob.Data))
+}
+
+func (g *Gateway) headObject(w http.ResponseWriter, r *http.Request, bucket, object string) {
+	meta, err := g.blobs.GetMeta(r.Context(), bucket, object)
+	if err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+	if meta == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	setObjectHeaders(w, r, meta)
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	w.Header().Set("Last-Modified", meta.UpdatedAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+}
+
+// setObjectHeaders sets the headers GetObject and HeadObject share,
+// honouring the response-* overrides presigned download links use
+func setObjectHeaders(w http.ResponseWriter, r *http.Request, meta *storage.BlobMeta) {
+	h := w.Header()
+	h.Set("Content-Type", meta.MimeType)
+	h.Set("ETag", etag(meta.MD5))
+	h.Set("Accept-Ranges", "bytes")
+	// Objects are served as stored; compressing them would change the ETag
+	h.Set("Cache-Control", "no-transform")
+
+	q := r.URL.Query()
+	for param, header := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-disposition": "Content-Disposition",
+		"response-cache-control":       "Cache-Control",
+		"response-content-language":    "Content-Language",
+		"response-expires":             "Expires",
+		"response-content-encoding":    "Content-Encoding",
+	} {
+		if v := q.Get(param); v != "" {
+			h.Set(header, v)
+		}
+	}
+}
+
+func (g *Gateway) putObject(w http.ResponseWriter, r *http.Request, auth *sigv4.Auth, key *Key, bucket, object string) {
+	maxUpload := system.GetLimits().Storage.MaxUpload
+	if r.ContentLength > maxUpload && auth.PayloadHash != sigv4.StreamingPayload && auth.PayloadHash != sigv4.StreamingUnsignedTrailer {
+		writeError(w, r, toS3Error(sigv4.ErrTooLarge))
+		return
+	}
+	data, err := auth.ReadBody(r.Body, maxUpload)
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+
+	if sum := r.Header.Get("Content-MD5"); sum != "" {
+		want, err := base64.StdEncoding.DecodeString(sum)
+		got := md5.Sum(data)
+		if err != nil || !bytes.Equal(want, got[:]) {
+			writeError(w, r, &s3Error{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received."})
+			return
+		}
+	}
+
+	mimeType := r.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if err := g.blobs.Put(r.Context(), bucket, object, data, mimeType); err != nil {
+		var infected *virusscan.InfectedError
+		if errors.As(err, &infected) {
+			writeError(w, r, &s3Error{http.StatusForbidden, "AccessDenied", err.Error()})
+			return
+		}
+		writeError(w, r, internalError(err))
+		return
+	}
+	logWrite(r, key, bucket, object, "put", map[string]interface{}{"size": len(data)})
+
+	w.Header().Set("ETag", etag(md5Hex(data)))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) copyObject(w http.ResponseWriter, r *http.Request, key *Key, bucket, object string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeError(w, r, &s3Error{http.StatusBadRequest, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey."})
+		return
+	}
+	source, _, _ = strings.Cut(source, "?") // ?versionId: there is only one version
+	srcBucket, srcObject, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if srcBucket != bucket || srcObject == "" {
+		writeError(w, r, &s3Error{http.StatusForbidden, "AccessDenied", "Objects can only be copied within bucket " + bucket + "."})
+		return
+	}
+
+	meta, err := g.blobs.GetMeta(r.Context(), bucket, srcObject)
+	if err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+	if meta == nil {
+		writeError(w, r, errNoSuchKey)
+		return
+	}
+	if err := g.blobs.Copy(r.Context(), bucket, srcObject, object); err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+	logWrite(r, key, bucket, object, "copy", map[string]interface{}{"source": srcObject, "size": meta.Size})
+
+	writeXML(w, http.StatusOK, copyObjectResult{
+		LastModified: g.now().UTC().Format(time.RFC3339),
+		ETag:         etag(meta.MD5),
+	})
+}
+
+func (g *Gateway) deleteObject(w http.ResponseWriter, r *http.Request, key *Key, bucket, object string) {
+	if err := g.blobs.Delete(r.Context(), bucket, object); err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+	logWrite(r, key, bucket, object, "delete", nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logWrite records a change made through the gateway in the activity log
+func logWrite(r *http.Request, key *Key, bucket, object, action string, details map[string]interface{}) {
+	activity.Log(activity.Entry{
+		ActorType:    activity.ActorAPIKey,
+		ActorID:      key.ID,
+		ActorIP:      realip.FromRequest(r),
+		ActorUA:      r.UserAgent(),
+		ResourceType: "blob",
+		ResourceID:   bucket + "/" + object,
+		Action:       "s3_" + action,
+		Result:       "success",
+		Weight:       activity.WeightDataMutation,
+		Details:      details,
+	})
+}
+
+// listObjects answers ListObjectsV2 (list-type=2) and the original
+// ListObjects, which rclone still uses by default
+func (g *Gateway) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	urlEncode := q.Get("encoding-type") == "url"
+	if enc := q.Get("encoding-type"); enc != "" && enc != "url" {
+		writeError(w, r, &s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid Encoding Method specified in Request"})
+		return
+	}
+
+	maxKeys := maxListKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, &s3Error{http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer"})
+			return
+		}
+		maxKeys = min(n, maxListKeys)
+	}
+
+	v2 := q.Get("list-type") == "2"
+	after := q.Get("marker")
+	if v2 {
+		after = q.Get("start-after")
+		if token := q.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				writeError(w, r, &s3Error{http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect"})
+				return
+			}
+			after = string(decoded)
+		}
+	}
+
+	page, err := g.list(r.Context(), bucket, prefix, delimiter, after, maxKeys)
+	if err != nil {
+		writeError(w, r, internalError(err))
+		return
+	}
+
+	enc := func(s string) string {
+		if urlEncode {
+			return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/")
+		}
+		return s
+	}
+	contents := make([]objectEntry, len(page.objects))
+	for i, b := range page.objects {
+		contents[i] = objectEntry{
+			Key:          enc(b.Path),
+			LastModified: b.UpdatedAt.UTC().Format(time.RFC3339),
+			ETag:         etag(b.MD5),
+			Size:         b.Size,
+			StorageClass: "STANDARD",
+		}
+	}
+	prefixes := make([]commonPrefix, len(page.prefixes))
+	for i, p := range page.prefixes {
+		prefixes[i] = commonPrefix{Prefix: enc(p)}
+	}
+	encodingType := ""
+	if urlEncode {
+		encodingType = "url"
+	}
+
+	if v2 {
+		result := listBucketResultV2{
+			Xmlns:             xmlns,
+			Name:              bucket,
+			Prefix:            enc(prefix),
+			Delimiter:         enc(delimiter),
+			MaxKeys:           maxKeys,
+			KeyCount:          len(contents) + len(prefixes),
+			IsTruncated:       page.truncated,
+			ContinuationToken: q.Get("continuation-token"),
+			StartAfter:        enc(q.Get("start-after")),
+			EncodingType:      encodingType,
+			Contents:          contents,
+			CommonPrefixes:    prefixes,
+		}
+		if page.truncated {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(page.last))
+		}
+		writeXML(w, http.StatusOK, result)
+		return
+	}
+
+	result := listBucketResultV1{
+		Xmlns:          xmlns,
+		Name:           bucket,
+		Prefix:         enc(prefix),
+		Marker:         enc(q.Get("marker")),
+		Delimiter:      enc(delimiter),
+		MaxKeys:        maxKeys,
+		IsTruncated:    page.truncated,
+		EncodingType:   encodingType,
+		Contents:       contents,
+		CommonPrefixes: prefixes,
+	}
+	if page.truncated {
+		result.NextMarker = enc(page.last)
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// listPage is one page of a listing
+type listPage struct {
+	objects   []storage.BlobMeta
+	prefixes  []string
+	truncated bool
+	last      string // Last key or common prefix returned, where the next page starts
+}
+
+// list pages through the blobs under prefix after a key, rolling keys
+// that contain delimiter past the prefix up into common prefixes. A listing
+// that resumes after a common prefix skips the rest of it.
+func (g *Gateway) list(ctx context.Context, bucket, prefix, delimiter, after string, maxKeys int) (*listPage, error) {
+	page := &listPage{}
+	if delimiter != "" && len(after) > len(prefix) && strings.HasPrefix(after, prefix) && strings.HasSuffix(after, delimiter) {
+		after += string(utf8.MaxRune)
+	}
+
+	for {
+		batch, err := g.blobs.ListAfter(ctx, bucket, prefix, after, maxKeys+1)
+		if err != nil {
+			return nil, err
+		}
+
+		skipped := false
+		for _, b := range batch {
+			entry, isPrefix := b.Path, false
+			if delimiter != "" {
+				if i := strings.Index(b.Path[len(prefix):], delimiter); i >= 0 {
+					entry, isPrefix = b.Path[:len(prefix)+i+len(delimiter)], true
+				}
+			}
+			if len(page.objects)+len(page.prefixes) == maxKeys {
+				page.truncated = true
+				return page, nil
+			}
+
+			page.last = entry
+			if isPrefix {
+				page.prefixes = append(page.prefixes, entry)
+				// Skip the rest of the prefix with a fresh query
+				after = entry + string(utf8.MaxRune)
+				skipped = true
+				break
+			}
+			page.objects = append(page.objects, b)
+			after = b.Path
+		}
+
+		if !skipped && len(batch) <= maxKeys {
+			return page, nil
+		}
+	}
+}
+
+// toS3Error maps a verification or body error to its S3 error
+func toS3Error(err error) *s3Error {
+	var e *sigv4.Error
+	if errors.As(err, &e) {
+		return &s3Error{e.Status, e.Code, e.Message}
+	}
+	return internalError(err)
+}
+
+func internalError(err error) *s3Error {
+	if err != nil {
+		log.Printf("S3 gateway: %v", err)
+	}
+	return &s3Error{http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again."}
+}
+
+func methodNotAllowed() *s3Error {
+	return &s3Error{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource."}
+}
+
+// writeError answers with an S3 error document; HEAD responses have no body
+func writeError(w http.ResponseWriter, r *http.Request, e *s3Error) {
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.Status)
+		return
+	}
+	writeXML(w, e.Status, errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Resource:  r.URL.Path,
+		RequestID: r.Header.Get("X-Request-ID"),
+	})
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(v)
+}
+
+func etag(sum string) string {
+	return `"` + sum + `"`
+}
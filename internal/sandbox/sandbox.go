@@ -0,0 +1,257 @@
+// Package sandbox bounds what a single JS execution can consume beyond its
+// wall-clock timeout: CPU time, the bytes handed to the VM, how deep it can
+// recurse and how many background jobs it can start. Goja has no heap
+// accounting, so memory is tracked where data enters the VM (request files,
+// storage reads, fetch responses, console output) rather than sampled from
+// the Go heap.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// Limit kinds reported in LimitError
+const (
+	KindCPU    = "cpu"
+	KindMemory = "memory"
+	KindSpawn  = "spawn"
+)
+
+const (
+	// DefaultMaxCallStack bounds nested JS calls so runaway recursion fails
+	// fast instead of growing the goroutine stack
+	DefaultMaxCallStack = 1024
+
+	// DefaultMaxSpawns bounds fazt.worker.spawn() calls per execution
+	DefaultMaxSpawns = 10
+
+	// cpuTick is how often a watched execution's CPU time is checked
+	cpuTick = 10 * time.Millisecond
+)
+
+// Limits are the per-execution bounds. Zero means unlimited.
+type Limits struct {
+	MaxCPU       time.Duration
+	MaxMemory    int64
+	MaxCallStack int
+	MaxSpawns    int
+}
+
+// DefaultLimits returns the limits for serverless executions
+func DefaultLimits() Limits {
+	limits := system.GetLimits().Runtime
+	return Limits{
+		MaxCPU:       time.Duration(limits.MaxCPU) * time.Millisecond,
+		MaxMemory:    limits.MaxMemory,
+		MaxCallStack: DefaultMaxCallStack,
+		MaxSpawns:    DefaultMaxSpawns,
+	}
+}
+
+// LimitError reports an execution that went over one of its limits
+type LimitError struct {
+	Kind  string `json:"kind"`
+	Limit int64  `json:"limit"`
+}
+
+func (e *LimitError) Error() string {
+	switch e.Kind {
+	case KindCPU:
+		return fmt.Sprintf("CPU time limit exceeded (%dms)", e.Limit)
+	case KindMemory:
+		return fmt.Sprintf("memory limit exceeded (%d bytes)", e.Limit)
+	case KindSpawn:
+		return fmt.Sprintf("spawn limit exceeded (%d jobs)", e.Limit)
+	}
+	return fmt.Sprintf("%s limit exceeded", e.Kind)
+}
+
+// IsLimitError reports whether err is, or wraps, a LimitError
+func IsLimitError(err error) bool {
+	var le *LimitError
+	return errors.As(err, &le)
+}
+
+// Tracker accounts for one execution. It travels in the context so storage,
+// egress and worker bindings can charge it; all methods are safe on a nil
+// Tracker, which is what code outside a sandboxed execution sees.
+type Tracker struct {
+	limits Limits
+	memory atomic.Int64
+	spawns atomic.Int64
+
+	mu sync.Mutex
+	vm *goja.Runtime
+
+	start time.Time
+	wait  func() time.Duration
+}
+
+type trackerKey struct{}
+
+// New creates a Tracker with the given limits
+func New(limits Limits) *Tracker {
+	return &Tracker{limits: limits}
+}
+
+// WithTracker returns a context carrying t
+func WithTracker(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, trackerKey{}, t)
+}
+
+// FromContext returns the execution's Tracker, or nil outside one
+func FromContext(ctx context.Context) *Tracker {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// Bind applies the call stack limit to vm and makes it the VM interrupted
+// when a limit is hit. Call Unbind before returning a pooled VM.
+func (t *Tracker) Bind(vm *goja.Runtime) {
+	if t == nil {
+		return
+	}
+	if t.limits.MaxCallStack > 0 {
+		vm.SetMaxCallStackSize(t.limits.MaxCallStack)
+	}
+	t.mu.Lock()
+	t.vm = vm
+	t.mu.Unlock()
+}
+
+// Unbind detaches the VM so a late charge can't interrupt its next user
+func (t *Tracker) Unbind() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.vm = nil
+	t.mu.Unlock()
+}
+
+// Watch starts the execution's CPU clock and enforces MaxCPU: a ticker
+// compares CPU time with the limit and interrupts the VM once it is over,
+// which stops a handler that loops forever. wait reports time spent blocked
+// outside JS (e.g. on fetches), which isn't CPU time; it may be nil. Call
+// the returned stop when the execution ends.
+func (t *Tracker) Watch(wait func() time.Duration) (stop func()) {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	t.start = time.Now()
+	t.wait = wait
+	t.mu.Unlock()
+	if t.limits.MaxCPU <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(cpuTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if t.CPU() > t.limits.MaxCPU {
+					t.fail(&LimitError{Kind: KindCPU, Limit: t.limits.MaxCPU.Milliseconds()})
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// CPU returns the execution's CPU time: time since Watch, less time spent
+// waiting
+func (t *Tracker) CPU() time.Duration {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	start, wait := t.start, t.wait
+	t.mu.Unlock()
+	if start.IsZero() {
+		return 0
+	}
+	cpu := time.Since(start)
+	if wait != nil {
+		cpu -= wait()
+	}
+	if cpu < 0 {
+		return 0
+	}
+	return cpu
+}
+
+// Alloc charges n bytes handed to the VM. Going over the memory limit
+// interrupts the VM, which JS can't catch, and returns the LimitError so
+// the caller can stop before handing over more.
+func (t *Tracker) Alloc(n int) error {
+	if t == nil || n <= 0 {
+		return nil
+	}
+	used := t.memory.Add(int64(n))
+	if t.limits.MaxMemory <= 0 || used <= t.limits.MaxMemory {
+		return nil
+	}
+	return t.fail(&LimitError{Kind: KindMemory, Limit: t.limits.MaxMemory})
+}
+
+// Spawn counts a background job started by the execution
+func (t *Tracker) Spawn() error {
+	if t == nil {
+		return nil
+	}
+	n := t.spawns.Add(1)
+	if t.limits.MaxSpawns <= 0 || n <= int64(t.limits.MaxSpawns) {
+		return nil
+	}
+	return t.fail(&LimitError{Kind: KindSpawn, Limit: int64(t.limits.MaxSpawns)})
+}
+
+// Memory returns the bytes charged so far
+func (t *Tracker) Memory() int64 {
+	if t == nil {
+		return 0
+	}
+	return t.memory.Load()
+}
+
+func (t *Tracker) fail(err *LimitError) error {
+	t.mu.Lock()
+	if t.vm != nil {
+		t.vm.Interrupt(err)
+	}
+	t.mu.Unlock()
+	return err
+}
+
+// Alloc charges n bytes to the context's Tracker
+func Alloc(ctx context.Context, n int) error {
+	return FromContext(ctx).Alloc(n)
+}
+
+// Spawn counts a background job against the context's Tracker
+func Spawn(ctx context.Context) error {
+	return FromContext(ctx).Spawn()
+}
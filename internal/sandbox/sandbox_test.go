@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func TestAllocLimit(t *testing.T) {
+	tr := New(Limits{MaxMemory: 100})
+	ctx := WithTracker(context.Background(), tr)
+
+	if err := Alloc(ctx, 60); err != nil {
+		t.Fatalf("Expected allocation under the limit, got %v", err)
+	}
+	err := Alloc(ctx, 60)
+	if !IsLimitError(err) {
+		t.Fatalf("Expected a LimitError, got %v", err)
+	}
+	if le := err.(*LimitError); le.Kind != KindMemory || le.Limit != 100 {
+		t.Errorf("Unexpected error %+v", le)
+	}
+	if tr.Memory() != 120 {
+		t.Errorf("Expected 120 bytes charged, got %d", tr.Memory())
+	}
+
+	// Outside a sandboxed execution nothing is tracked
+	if err := Alloc(context.Background(), 1<<30); err != nil {
+		t.Errorf("Expected no limit without a tracker, got %v", err)
+	}
+}
+
+func TestSpawnLimit(t *testing.T) {
+	ctx := WithTracker(context.Background(), New(Limits{MaxSpawns: 2}))
+	for i := 0; i < 2; i++ {
+		if err := Spawn(ctx); err != nil {
+			t.Fatalf("Spawn %d: %v", i, err)
+		}
+	}
+	if err := Spawn(ctx); !IsLimitError(err) {
+		t.Errorf("Expected a LimitError, got %v", err)
+	}
+
+	unlimited := WithTracker(context.Background(), New(Limits{}))
+	for i := 0; i < 100; i++ {
+		if err := Spawn(unlimited); err != nil {
+			t.Fatalf("Expected no spawn limit, got %v", err)
+		}
+	}
+}
+
+func TestLimitInterruptsVM(t *testing.T) {
+	vm := goja.New()
+	tr := New(Limits{MaxMemory: 10})
+	tr.Bind(vm)
+	defer tr.Unbind()
+
+	// JS can't catch a limit violation
+	vm.Set("grab", func(n int) { tr.Alloc(n) })
+	_, err := vm.RunString(`try { grab(100); while (true) {} } catch (e) { "caught" }`)
+	if _, ok := err.(*goja.InterruptedError); !ok {
+		t.Fatalf("Expected an interrupt, got %v", err)
+	}
+	if !IsLimitError(err) {
+		t.Errorf("Expected the interrupt to carry a LimitError, got %v", err)
+	}
+}
+
+func TestCPULimitInterruptsVM(t *testing.T) {
+	vm := goja.New()
+	tr := New(Limits{MaxCPU: 50 * time.Millisecond})
+	tr.Bind(vm)
+	defer tr.Unbind()
+	stop := tr.Watch(nil)
+	defer stop()
+
+	_, err := vm.RunString(`while (true) {}`)
+	var le *LimitError
+	if !errors.As(err, &le) || le.Kind != KindCPU || le.Limit != 50 {
+		t.Fatalf("Expected a CPU LimitError, got %v", err)
+	}
+}
+
+func TestCPUExcludesWait(t *testing.T) {
+	tr := New(Limits{})
+	stop := tr.Watch(func() time.Duration { return time.Hour })
+	defer stop()
+	if cpu := tr.CPU(); cpu != 0 {
+		t.Errorf("Expected waiting to be excluded, got %v", cpu)
+	}
+}
@@ -0,0 +1,202 @@
+package security
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Security event types. These form a high-signal stream kept apart from the
+// activity log, which records every change.
+const (
+	EventLoginFailed  = "login.failed"      // Wrong password, second factor or passkey
+	EventNewDevice    = "login.new_device"  // Login from a user agent the user hasn't used before
+	EventNewIP        = "login.new_ip"      // Login from a client IP the user hasn't used before
+	EventTokenCreated = "token.created"     // API key created
+	EventRoleChanged  = "role.changed"      // User's role changed
+	EventEgressDomain = "egress.new_domain" // First request to a recently allowlisted domain
+)
+
+// EventTypes lists every security event type
+var EventTypes = []string{EventLoginFailed, EventNewDevice, EventNewIP, EventTokenCreated, EventRoleChanged, EventEgressDomain}
+
+// Severities
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// defaultSeverity is used when an event is recorded without one
+var defaultSeverity = map[string]string{
+	EventLoginFailed:  SeverityWarning,
+	EventNewDevice:    SeverityWarning,
+	EventNewIP:        SeverityInfo,
+	EventTokenCreated: SeverityInfo,
+	EventRoleChanged:  SeverityWarning,
+	EventEgressDomain: SeverityInfo,
+}
+
+// NewDomainWindow is how long after being allowlisted a domain's first
+// egress request is reported
+const NewDomainWindow = 24 * time.Hour
+
+// Event is a recorded security event
+type Event struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Actor     string                 `json:"actor,omitempty"`   // Who acted: user, attempted username or app
+	IP        string                 `json:"ip,omitempty"`      // Client IP, when there is a request
+	Subject   string                 `json:"subject,omitempty"` // What it's about: user, key or domain
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt int64                  `json:"created_at"`
+}
+
+// ValidEventType reports whether t is a known event type
+func ValidEventType(t string) bool {
+	for _, known := range EventTypes {
+		if known == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Record stores a security event and fires the notification rules it
+// trips. Failures are logged, never returned: recording must not break the
+// login or change that caused the event.
+func Record(db *sql.DB, e Event) {
+	if db == nil {
+		return
+	}
+	if e.Severity == "" {
+		e.Severity = defaultSeverity[e.Type]
+	}
+	if e.CreatedAt == 0 {
+		e.CreatedAt = time.Now().Unix()
+	}
+
+	var details interface{}
+	if len(e.Details) > 0 {
+		b, _ := json.Marshal(e.Details)
+		details = string(b)
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO security_events (type, severity, actor, ip, subject, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.Type, e.Severity, e.Actor, e.IP, e.Subject, details, e.CreatedAt)
+	if err != nil {
+		log.Printf("Failed to record security event %s: %v", e.Type, err)
+		return
+	}
+	e.ID, _ = result.LastInsertId()
+
+	if err := evaluateRules(db, e); err != nil {
+		log.Printf("Failed to evaluate security rules for %s: %v", e.Type, err)
+	}
+}
+
+// List returns recorded events, newest first. An empty eventType returns
+// every type; since limits them to events at or after a Unix time.
+func List(db *sql.DB, eventType string, since int64, limit int) ([]Event, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, type, severity, COALESCE(actor, ''), COALESCE(ip, ''), COALESCE(subject, ''),
+		       COALESCE(details, ''), created_at
+		FROM security_events WHERE created_at >= ?
+	`
+	args := []interface{}{since}
+	if eventType != "" {
+		query += " AND type = ?"
+		args = append(args, eventType)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var details string
+		if err := rows.Scan(&e.ID, &e.Type, &e.Severity, &e.Actor, &e.IP, &e.Subject, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if details != "" {
+			json.Unmarshal([]byte(details), &e.Details)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Recorded reports whether an event of a type about subject was recorded at
+// or after since
+func Recorded(db *sql.DB, eventType, subject string, since int64) (bool, error) {
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM security_events WHERE type = ? AND subject = ? AND created_at >= ?
+	`, eventType, subject, since).Scan(&n)
+	return n > 0, err
+}
+
+// NewDevice remembers the user agent a user signed in with and reports
+// whether it is new for a user who has signed in before. A user's first
+// device is not reported.
+func NewDevice(db *sql.DB, userID, userAgent string, now time.Time) (bool, error) {
+	sum := sha256.Sum256([]byte(userAgent))
+	device := hex.EncodeToString(sum[:])
+
+	var known, seen int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(device = ?), 0) FROM security_devices WHERE user_id = ?
+	`, device, userID).Scan(&known, &seen)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO security_devices (user_id, device, user_agent, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(user_id, device) DO UPDATE SET last_seen = excluded.last_seen
+	`, userID, device, userAgent, now.Unix(), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	return known > 0 && seen == 0, nil
+}
+
+// NewIP remembers the client IP a user signed in from and reports whether it
+// is new for a user who has signed in before. A user's first IP is not
+// reported.
+func NewIP(db *sql.DB, userID, ip string, now time.Time) (bool, error) {
+	var known, seen int
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(ip = ?), 0) FROM security_ips WHERE user_id = ?
+	`, ip, userID).Scan(&known, &seen)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO security_ips (user_id, ip, first_seen, last_seen)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, ip) DO UPDATE SET last_seen = excluded.last_seen
+	`, userID, ip, now.Unix(), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	return known > 0 && seen == 0, nil
+}
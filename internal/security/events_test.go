@@ -0,0 +1,206 @@
+package security
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+	// Start without the default rules
+	if _, err := db.Exec(`DELETE FROM security_rules`); err != nil {
+		t.Fatalf("Failed to clear rules: %v", err)
+	}
+	return db
+}
+
+// captureAlerts replaces notify for the test and returns the alerts sent
+func captureAlerts(t *testing.T) chan Alert {
+	t.Helper()
+	alerts := make(chan Alert, 10)
+	orig := notify
+	notify = func(rule Rule, alert Alert) { alerts <- alert }
+	t.Cleanup(func() { notify = orig })
+	return alerts
+}
+
+func expectAlerts(t *testing.T, alerts chan Alert, want int) []Alert {
+	t.Helper()
+	var got []Alert
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case a := <-alerts:
+			got = append(got, a)
+		case <-timeout:
+			if len(got) != want {
+				t.Fatalf("Expected %d alerts, got %d: %+v", want, len(got), got)
+			}
+			return got
+		}
+	}
+}
+
+func TestRecordAndList(t *testing.T) {
+	db := setupTestDB(t)
+
+	Record(db, Event{Type: EventLoginFailed, Actor: "admin", IP: "203.0.113.9", CreatedAt: 100,
+		Details: map[string]interface{}{"reason": "invalid password"}})
+	Record(db, Event{Type: EventTokenCreated, Actor: "cli", Subject: "deploy-bot", CreatedAt: 200})
+
+	events, err := List(db, "", 0, 10)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(events) != 2 || events[0].Type != EventTokenCreated {
+		t.Fatalf("Expected 2 events newest first, got %+v", events)
+	}
+	failed := events[1]
+	if failed.Severity != SeverityWarning || failed.IP != "203.0.113.9" || failed.Details["reason"] != "invalid password" {
+		t.Errorf("Unexpected event: %+v", failed)
+	}
+
+	events, _ = List(db, EventLoginFailed, 0, 10)
+	if len(events) != 1 {
+		t.Errorf("Expected 1 login.failed event, got %d", len(events))
+	}
+	events, _ = List(db, "", 150, 10)
+	if len(events) != 1 || events[0].Type != EventTokenCreated {
+		t.Errorf("Expected only the event since 150, got %+v", events)
+	}
+}
+
+func TestRules_EveryEvent(t *testing.T) {
+	db := setupTestDB(t)
+	alerts := captureAlerts(t)
+
+	if _, err := CreateRule(db, Rule{Name: "Roles", EventType: EventRoleChanged}); err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+
+	Record(db, Event{Type: EventRoleChanged, Subject: "bob@example.com"})
+	Record(db, Event{Type: EventTokenCreated, Subject: "ci"})
+	Record(db, Event{Type: EventRoleChanged, Subject: "eve@example.com"})
+
+	got := expectAlerts(t, alerts, 2)
+	for _, a := range got {
+		if a.Rule != "Roles" || a.Event.Type != EventRoleChanged {
+			t.Errorf("Unexpected alert: %+v", a)
+		}
+	}
+}
+
+func TestRules_Threshold(t *testing.T) {
+	db := setupTestDB(t)
+	alerts := captureAlerts(t)
+
+	if _, err := CreateRule(db, Rule{Name: "Brute force", EventType: EventLoginFailed, Threshold: 3, Window: 60}); err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+
+	// Two within the window, one outside it
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1000})
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1100})
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1110})
+	expectAlerts(t, alerts, 0)
+
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1120})
+	got := expectAlerts(t, alerts, 1)
+	if got[0].Count != 3 {
+		t.Errorf("Expected a count of 3, got %d", got[0].Count)
+	}
+
+	// Counting starts again after the rule fires
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1130})
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1140})
+	expectAlerts(t, alerts, 0)
+	Record(db, Event{Type: EventLoginFailed, CreatedAt: 1150})
+	expectAlerts(t, alerts, 1)
+}
+
+func TestCreateRule_Invalid(t *testing.T) {
+	db := setupTestDB(t)
+
+	bad := []Rule{
+		{EventType: EventLoginFailed},
+		{Name: "x", EventType: "login.weird"},
+		{Name: "x", EventType: EventLoginFailed, Threshold: 5},
+		{Name: "x", EventType: AnyEvent, WebhookURL: "ftp://example.com"},
+	}
+	for _, r := range bad {
+		if _, err := CreateRule(db, r); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("CreateRule(%+v) = %v, want ErrInvalidRule", r, err)
+		}
+	}
+
+	rule, err := CreateRule(db, Rule{Name: "Anything", EventType: AnyEvent, WebhookURL: "https://hooks.example.com/x"})
+	if err != nil {
+		t.Fatalf("CreateRule: %v", err)
+	}
+	rules, _ := ListRules(db)
+	if len(rules) != 1 || rules[0].WebhookURL != "https://hooks.example.com/x" {
+		t.Errorf("Unexpected rules: %+v", rules)
+	}
+
+	if err := DeleteRule(db, rule.ID); err != nil {
+		t.Fatalf("DeleteRule: %v", err)
+	}
+	if err := DeleteRule(db, rule.ID); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("Expected ErrRuleNotFound, got %v", err)
+	}
+}
+
+func TestNewDevice(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Unix(1000, 0)
+
+	steps := []struct {
+		user, agent string
+		want        bool
+	}{
+		{"u1", "Firefox", false}, // first device
+		{"u1", "Firefox", false}, // known
+		{"u1", "Safari", true},   // new
+		{"u1", "Safari", false},
+		{"u2", "Safari", false}, // another user's first device
+	}
+	for i, s := range steps {
+		got, err := NewDevice(db, s.user, s.agent, now)
+		if err != nil {
+			t.Fatalf("NewDevice: %v", err)
+		}
+		if got != s.want {
+			t.Errorf("Step %d: NewDevice(%s, %s) = %v, want %v", i, s.user, s.agent, got, s.want)
+		}
+	}
+}
+
+func TestNewIP(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Unix(1000, 0)
+
+	steps := []struct {
+		user, ip string
+		want     bool
+	}{
+		{"u1", "203.0.113.9", false},  // first IP
+		{"u1", "203.0.113.9", false},  // known
+		{"u1", "198.51.100.4", true},  // new
+		{"u2", "198.51.100.4", false}, // another user's first IP
+	}
+	for i, s := range steps {
+		got, err := NewIP(db, s.user, s.ip, now)
+		if err != nil {
+			t.Fatalf("NewIP: %v", err)
+		}
+		if got != s.want {
+			t.Errorf("Step %d: NewIP(%s, %s) = %v, want %v", i, s.user, s.ip, got, s.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package security
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notifier"
+)
+
+// AnyEvent matches every event type in a rule
+const AnyEvent = "*"
+
+// Common errors
+var (
+	ErrRuleNotFound = errors.New("security rule not found")
+	ErrInvalidRule  = errors.New("invalid security rule")
+)
+
+// Rule alerts when Threshold events of a type happen within Window seconds.
+// A threshold of 1 alerts on every event. Alerts go to WebhookURL as JSON,
+// or to ntfy when it is empty.
+type Rule struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	EventType   string `json:"event_type"`
+	Threshold   int    `json:"threshold"`
+	Window      int    `json:"window_seconds"`
+	WebhookURL  string `json:"webhook_url,omitempty"`
+	LastFiredAt *int64 `json:"last_fired_at,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// Alert is what a firing rule sends
+type Alert struct {
+	Rule  string `json:"rule"`
+	Count int    `json:"count"`
+	Event Event  `json:"event"`
+}
+
+// notify delivers an alert. Tests replace it.
+var notify = func(rule Rule, alert Alert) {
+	if rule.WebhookURL == "" {
+		title := "Security: " + rule.Name
+		if err := notifier.Send(title, alert.describe(), notifier.NotificationSecurity); err != nil {
+			log.Printf("Failed to send security alert: %v", err)
+		}
+		return
+	}
+
+	body, _ := json.Marshal(alert)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(rule.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send security alert to webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("Security alert webhook returned %d", resp.StatusCode)
+	}
+}
+
+func (a Alert) describe() string {
+	e := a.Event
+	msg := e.Type
+	if e.Subject != "" {
+		msg += " " + e.Subject
+	}
+	if e.Actor != "" && e.Actor != e.Subject {
+		msg += " by " + e.Actor
+	}
+	if e.IP != "" {
+		msg += " from " + e.IP
+	}
+	if a.Count > 1 {
+		msg = fmt.Sprintf("%d events, latest: %s", a.Count, msg)
+	}
+	return msg
+}
+
+// Validate checks a rule before it is stored
+func (r *Rule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if r.EventType != AnyEvent && !ValidEventType(r.EventType) {
+		return fmt.Errorf("%w: unknown event type %q (expected one of %s or %s)",
+			ErrInvalidRule, r.EventType, strings.Join(EventTypes, ", "), AnyEvent)
+	}
+	if r.Threshold < 1 {
+		r.Threshold = 1
+	}
+	if r.Threshold > 1 && r.Window <= 0 {
+		return fmt.Errorf("%w: a threshold above 1 needs a window", ErrInvalidRule)
+	}
+	if r.Window < 0 {
+		return fmt.Errorf("%w: window must not be negative", ErrInvalidRule)
+	}
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: webhook must be an http(s) URL", ErrInvalidRule)
+		}
+	}
+	return nil
+}
+
+// CreateRule stores a new notification rule
+func CreateRule(db *sql.DB, r Rule) (*Rule, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	r.CreatedAt = time.Now().Unix()
+
+	var webhook interface{}
+	if r.WebhookURL != "" {
+		webhook = r.WebhookURL
+	}
+	res, err := db.Exec(`
+		INSERT INTO security_rules (name, event_type, threshold, window_seconds, webhook_url, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, r.Name, r.EventType, r.Threshold, r.Window, webhook, r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	r.ID, _ = res.LastInsertId()
+	return &r, nil
+}
+
+// ListRules returns all notification rules
+func ListRules(db *sql.DB) ([]Rule, error) {
+	return queryRules(db, `
+		SELECT id, name, event_type, threshold, window_seconds, COALESCE(webhook_url, ''), last_fired_at, created_at
+		FROM security_rules ORDER BY id
+	`)
+}
+
+// DeleteRule removes a notification rule
+func DeleteRule(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM security_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrRuleNotFound
+	}
+	return nil
+}
+
+func queryRules(db *sql.DB, query string, args ...interface{}) ([]Rule, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []Rule{}
+	for rows.Next() {
+		var r Rule
+		var lastFired sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Name, &r.EventType, &r.Threshold, &r.Window, &r.WebhookURL, &lastFired, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if lastFired.Valid {
+			r.LastFiredAt = &lastFired.Int64
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// evaluateRules fires the rules a just-recorded event trips. Counting only
+// events after a rule last fired keeps a burst from alerting once per event.
+func evaluateRules(db *sql.DB, e Event) error {
+	rules, err := queryRules(db, `
+		SELECT id, name, event_type, threshold, window_seconds, COALESCE(webhook_url, ''), last_fired_at, created_at
+		FROM security_rules WHERE event_type = ? OR event_type = ?
+	`, e.Type, AnyEvent)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		count := 1
+		if rule.Threshold > 1 {
+			since := e.CreatedAt - int64(rule.Window)
+			if rule.LastFiredAt != nil && *rule.LastFiredAt >= since {
+				since = *rule.LastFiredAt + 1
+			}
+			query := `SELECT COUNT(*) FROM security_events WHERE created_at >= ?`
+			args := []interface{}{since}
+			if rule.EventType != AnyEvent {
+				query += ` AND type = ?`
+				args = append(args, rule.EventType)
+			}
+			if err := db.QueryRow(query, args...).Scan(&count); err != nil {
+				return err
+			}
+			if count < rule.Threshold {
+				continue
+			}
+		}
+
+		if _, err := db.Exec(`UPDATE security_rules SET last_fired_at = ? WHERE id = ?`, e.CreatedAt, rule.ID); err != nil {
+			return err
+		}
+		go notify(rule, Alert{Rule: rule.Name, Count: count, Event: e})
+	}
+	return nil
+}
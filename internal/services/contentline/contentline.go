@@ -0,0 +1,46 @@
+// Package contentline writes the content lines iCalendar (RFC 5545) and
+// vCard (RFC 2426, RFC 6350) documents are made of, which share their
+// folding and text escaping rules.
+package contentline
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LineLimit is the longest a content line may be, in octets, before it is
+// folded onto a continuation line
+const LineLimit = 75
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// EscapeText escapes a text value (RFC 5545 section 3.3.11, RFC 6350
+// section 3.4)
+func EscapeText(s string) string {
+	return textEscaper.Replace(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+// WriteFolded writes a content line ending in CRLF, folding it at
+// LineLimit octets without splitting a UTF-8 sequence (RFC 5545 section
+// 3.1, RFC 6350 section 3.2)
+func WriteFolded(b *strings.Builder, s string) {
+	limit := LineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = LineLimit - 1 // The leading space counts
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,48 @@
+package contentline
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscapeText(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":                   "plain",
+		`a\b`:                     `a\\b`,
+		"Smith; Jones, Co":        `Smith\; Jones\, Co`,
+		"one\r\ntwo\nthree\rfour": `one\ntwo\nthree\nfour`,
+	} {
+		if got := EscapeText(in); got != want {
+			t.Errorf("EscapeText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteFolded(t *testing.T) {
+	var b strings.Builder
+	WriteFolded(&b, "SHORT:x")
+	if b.String() != "SHORT:x\r\n" {
+		t.Errorf("Expected a short line unchanged, got %q", b.String())
+	}
+
+	long := "NOTE:" + strings.Repeat("é", 100)
+	b.Reset()
+	WriteFolded(&b, long)
+
+	var unfolded strings.Builder
+	for i, line := range strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n") {
+		if len(line) > LineLimit {
+			t.Errorf("Line longer than %d octets: %q", LineLimit, line)
+		}
+		if i > 0 {
+			if !strings.HasPrefix(line, " ") {
+				t.Fatalf("Expected continuation lines to start with a space, got %q", line)
+			}
+			line = line[1:]
+		}
+		unfolded.WriteString(line)
+	}
+	if unfolded.String() != long {
+		t.Errorf("Expected folded lines to unfold to the original, got %q", unfolded.String())
+	}
+}
@@ -0,0 +1,239 @@
+// Package ics renders iCalendar (RFC 5545) feeds, the format phone and
+// desktop calendars subscribe to, from plain event records.
+package ics
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/services/contentline"
+)
+
+// MaxEvents caps the events rendered into one calendar
+const MaxEvents = 5000
+
+const (
+	dateFormat     = "20060102"
+	dateTimeFormat = "20060102T150405Z"
+)
+
+// Event statuses
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// Event is one calendar entry
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	URL         string
+	Status      string    // TENTATIVE, CONFIRMED or CANCELLED; empty to omit
+	Start       time.Time // Local midnight of the day for an all-day event
+	End         time.Time // Zero: an hour after Start, or the next day for an all-day event
+	AllDay      bool
+	Updated     time.Time // DTSTAMP; zero uses the time the feed is rendered
+}
+
+// Calendar is a named list of events
+type Calendar struct {
+	Name    string
+	Refresh time.Duration // How often subscribers should refetch; zero to omit
+	Events  []Event
+}
+
+// Render returns the calendar as an iCalendar document
+func (c *Calendar) Render(now time.Time) string {
+	var b strings.Builder
+	line := func(s string) {
+		contentline.WriteFolded(&b, s)
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//fazt//fazt//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + contentline.EscapeText(c.Name))
+	}
+	if c.Refresh > 0 {
+		line(fmt.Sprintf("REFRESH-INTERVAL;VALUE=DURATION:PT%dM", int(math.Ceil(c.Refresh.Minutes()))))
+		line(fmt.Sprintf("X-PUBLISHED-TTL:PT%dM", int(math.Ceil(c.Refresh.Minutes()))))
+	}
+
+	stamp := now.UTC().Format(dateTimeFormat)
+	for _, e := range c.Events {
+		line("BEGIN:VEVENT")
+		line("UID:" + contentline.EscapeText(e.UID))
+		if e.Updated.IsZero() {
+			line("DTSTAMP:" + stamp)
+		} else {
+			line("DTSTAMP:" + e.Updated.UTC().Format(dateTimeFormat))
+		}
+		if e.AllDay {
+			end := e.End
+			if end.IsZero() || !end.After(e.Start) {
+				end = e.Start.AddDate(0, 0, 1)
+			}
+			line("DTSTART;VALUE=DATE:" + e.Start.Format(dateFormat))
+			line("DTEND;VALUE=DATE:" + end.Format(dateFormat))
+		} else {
+			end := e.End
+			if end.IsZero() || end.Before(e.Start) {
+				end = e.Start.Add(time.Hour)
+			}
+			line("DTSTART:" + e.Start.UTC().Format(dateTimeFormat))
+			line("DTEND:" + end.UTC().Format(dateTimeFormat))
+		}
+		line("SUMMARY:" + contentline.EscapeText(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + contentline.EscapeText(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION:" + contentline.EscapeText(e.Location))
+		}
+		if e.URL != "" {
+			line("URL:" + e.URL)
+		}
+		if e.Status != "" {
+			line("STATUS:" + e.Status)
+		}
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// ParseEvent builds an event from a record, as apps store them in the
+// document store or pass them to fazt.util.ics:
+//
+//	{ id, title, start, end, allDay, description, location, url, status }
+//
+// start and end may be ISO 8601 strings, epoch milliseconds, or a
+// YYYY-MM-DD date, which makes the event all-day. summary is accepted for
+// title and uid for id; without either, the UID is derived from the title
+// and start so it stays stable across renders. domain qualifies the UID.
+func ParseEvent(rec map[string]interface{}, domain string) (Event, error) {
+	var e Event
+
+	e.Summary = stringField(rec, "title", "summary")
+	if e.Summary == "" {
+		return e, fmt.Errorf("event needs a title")
+	}
+	e.Description = stringField(rec, "description")
+	e.Location = stringField(rec, "location")
+	e.URL = stringField(rec, "url")
+
+	start, startDate, err := parseTime(rec["start"])
+	if err != nil {
+		return e, fmt.Errorf("event %q: start: %w", e.Summary, err)
+	}
+	if start.IsZero() {
+		return e, fmt.Errorf("event %q needs a start", e.Summary)
+	}
+	end, endDate, err := parseTime(rec["end"])
+	if err != nil {
+		return e, fmt.Errorf("event %q: end: %w", e.Summary, err)
+	}
+	e.Start, e.End = start, end
+
+	allDay, _ := rec["allDay"].(bool)
+	e.AllDay = allDay || (startDate && (end.IsZero() || endDate))
+	if e.AllDay {
+		e.Start = startOfDay(e.Start)
+		if !e.End.IsZero() {
+			e.End = startOfDay(e.End)
+			if endDate {
+				// A date end is the last day of the event; DTEND is exclusive
+				e.End = e.End.AddDate(0, 0, 1)
+			}
+		}
+	}
+	if !e.End.IsZero() && e.End.Before(e.Start) {
+		return e, fmt.Errorf("event %q ends before it starts", e.Summary)
+	}
+
+	if status := strings.ToUpper(stringField(rec, "status")); status != "" {
+		switch status {
+		case StatusTentative, StatusConfirmed, StatusCancelled:
+			e.Status = status
+		case "CANCELED":
+			e.Status = StatusCancelled
+		default:
+			return e, fmt.Errorf("event %q: status must be tentative, confirmed or cancelled", e.Summary)
+		}
+	}
+
+	uid := stringField(rec, "uid", "id")
+	if uid == "" {
+		sum := sha1.Sum([]byte(e.Summary + "\n" + e.Start.UTC().Format(time.RFC3339)))
+		uid = hex.EncodeToString(sum[:8])
+	}
+	if !strings.Contains(uid, "@") && domain != "" {
+		uid += "@" + domain
+	}
+	e.UID = uid
+
+	return e, nil
+}
+
+// parseTime reads a time from a record field; date reports a YYYY-MM-DD
+// value. A missing field is the zero time.
+func parseTime(v interface{}) (t time.Time, date bool, err error) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, false, nil
+	case time.Time:
+		return v, false, nil
+	case string:
+		if v == "" {
+			return time.Time{}, false, nil
+		}
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			return t, true, nil
+		}
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, false, nil
+			}
+		}
+		return time.Time{}, false, fmt.Errorf("%q is not an ISO 8601 date or time", v)
+	case float64:
+		return time.UnixMilli(int64(v)), false, nil
+	case int64:
+		return time.UnixMilli(v), false, nil
+	case int:
+		return time.UnixMilli(int64(v)), false, nil
+	default:
+		return time.Time{}, false, fmt.Errorf("expected a date string or epoch milliseconds")
+	}
+}
+
+// startOfDay drops the time of day, keeping the date as written
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// stringField returns the first of keys set to a non-empty string
+func stringField(rec map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := rec[k].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64, int64, int:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package ics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/services/contentline"
+)
+
+func TestParseEvent(t *testing.T) {
+	e, err := ParseEvent(map[string]interface{}{
+		"id":    "evt1",
+		"title": "Board meeting",
+		"start": "2026-11-02T18:30:00+01:00",
+		"end":   float64(time.Date(2026, 11, 2, 19, 30, 0, 0, time.UTC).UnixMilli()),
+	}, "club")
+	if err != nil {
+		t.Fatalf("ParseEvent failed: %v", err)
+	}
+	if e.UID != "evt1@club" || e.AllDay || !e.Start.Equal(time.Date(2026, 11, 2, 17, 30, 0, 0, time.UTC)) || e.End.Sub(e.Start) != 2*time.Hour {
+		t.Errorf("Unexpected event: %+v", e)
+	}
+
+	// A date makes the event all-day, and a date end is inclusive
+	e, _ = ParseEvent(map[string]interface{}{"title": "Retreat", "start": "2026-12-04", "end": "2026-12-06"}, "club")
+	if !e.AllDay || e.End.Format("2006-01-02") != "2026-12-07" {
+		t.Errorf("Expected an all-day event ending on the 7th, got %+v", e)
+	}
+	again, _ := ParseEvent(map[string]interface{}{"title": "Retreat", "start": "2026-12-04"}, "club")
+	if again.UID != e.UID || !strings.HasSuffix(e.UID, "@club") {
+		t.Errorf("Expected a stable derived UID, got %q and %q", e.UID, again.UID)
+	}
+
+	invalid := []map[string]interface{}{
+		{"start": "2026-12-04"},
+		{"title": "No start"},
+		{"title": "Bad start", "start": "next tuesday"},
+		{"title": "Backwards", "start": "2026-12-04T10:00:00Z", "end": "2026-12-04T09:00:00Z"},
+		{"title": "Bad status", "start": "2026-12-04", "status": "maybe"},
+	}
+	for _, rec := range invalid {
+		if _, err := ParseEvent(rec, "club"); err == nil {
+			t.Errorf("Expected %v to be rejected", rec)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	cal := &Calendar{
+		Name:    "Club, events",
+		Refresh: time.Hour,
+		Events: []Event{
+			{
+				UID:         "evt1@club",
+				Summary:     "Board meeting; agenda",
+				Description: "Line one\nLine two",
+				Start:       time.Date(2026, 11, 2, 17, 30, 0, 0, time.UTC),
+				Status:      StatusConfirmed,
+			},
+			{
+				UID:     "evt2@club",
+				Summary: "Retreat",
+				Start:   time.Date(2026, 12, 4, 0, 0, 0, 0, time.UTC),
+				AllDay:  true,
+			},
+		},
+	}
+	out := cal.Render(now)
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"X-WR-CALNAME:Club\\, events\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT60M\r\n",
+		"DTSTAMP:20261016T120000Z\r\n",
+		"DTSTART:20261102T173000Z\r\nDTEND:20261102T183000Z\r\n",
+		"SUMMARY:Board meeting\\; agenda\r\n",
+		"DESCRIPTION:Line one\\nLine two\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"DTSTART;VALUE=DATE:20261204\r\nDTEND;VALUE=DATE:20261205\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Expected two events, got:\n%s", out)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	cal := &Calendar{Events: []Event{{
+		UID:         "x@y",
+		Summary:     "Long",
+		Description: strings.Repeat("é", 100),
+		Start:       time.Now(),
+	}}}
+	out := cal.Render(time.Now())
+
+	var unfolded strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > contentline.LineLimit {
+			t.Errorf("Line longer than %d octets: %q", contentline.LineLimit, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+			continue
+		}
+		unfolded.WriteString("\n" + line)
+	}
+	if !strings.Contains(unfolded.String(), "DESCRIPTION:"+strings.Repeat("é", 100)) {
+		t.Errorf("Expected folded lines to unfold to the original, got:\n%s", out)
+	}
+}
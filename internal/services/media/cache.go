@@ -7,14 +7,19 @@ import (
 	"encoding/hex"
 )
 
-const mediaCachePrefix = "_media/"
+const (
+	mediaCachePrefix  = "_media/"
+	staticCachePrefix = "_media/static/"
+)
 
 // MediaCache stores and retrieves processed image variants in app_blobs.
 // Variants are stored under _media/{hash}/{transform_key} paths,
 // optionally scoped to a user: u/{userId}/_media/{hash}/{transform_key}.
+// Variants of a site's static files live under _media/static/.
 type MediaCache struct {
 	db     *sql.DB
 	userID string // empty = shared/app-level cache
+	static bool   // variants of deployed files, not blobs
 }
 
 // NewMediaCache creates a media cache for shared (app-level) blobs.
@@ -29,9 +34,21 @@ func NewUserMediaCache(db *sql.DB, userID string) *MediaCache {
 	return &MediaCache{db: db, userID: userID}
 }
 
+// NewStaticMediaCache creates a media cache for a site's deployed files.
+// Its entries are dropped on each deploy by InvalidateStatic.
+func NewStaticMediaCache(db *sql.DB) *MediaCache {
+	return &MediaCache{db: db, static: true}
+}
+
 // Get retrieves a cached variant. Checks in-memory LRU first, then DB.
 func (c *MediaCache) Get(ctx context.Context, appID, blobPath string, opts TransformOpts) ([]byte, string, error) {
-	key := c.cacheKey(blobPath, opts)
+	return c.GetVariant(ctx, appID, blobPath, opts.CacheKey())
+}
+
+// GetVariant retrieves a cached variant by its own key, for derived files
+// that aren't image transforms (thumbnails, waveforms).
+func (c *MediaCache) GetVariant(ctx context.Context, appID, blobPath, variant string) ([]byte, string, error) {
+	key := c.cacheKey(blobPath, variant)
 
 	// Check in-memory cache first
 	mc := getMemCache()
@@ -60,7 +77,12 @@ func (c *MediaCache) Get(ctx context.Context, appID, blobPath string, opts Trans
 
 // Put stores a processed variant in both DB and in-memory cache.
 func (c *MediaCache) Put(ctx context.Context, appID, blobPath string, opts TransformOpts, data []byte, mimeType string) error {
-	key := c.cacheKey(blobPath, opts)
+	return c.PutVariant(ctx, appID, blobPath, opts.CacheKey(), data, mimeType)
+}
+
+// PutVariant stores a variant under its own key (see GetVariant).
+func (c *MediaCache) PutVariant(ctx context.Context, appID, blobPath, variant string, data []byte, mimeType string) error {
+	key := c.cacheKey(blobPath, variant)
 	_, err := c.db.ExecContext(ctx,
 		`INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, updated_at)
 		 VALUES (?, ?, ?, ?, ?, '', strftime('%s', 'now'))
@@ -87,12 +109,15 @@ func (c *MediaCache) Invalidate(ctx context.Context, appID, blobPath string) err
 }
 
 // cacheKey builds the full blob path for a cached variant.
-func (c *MediaCache) cacheKey(blobPath string, opts TransformOpts) string {
-	return c.prefix() + pathHash(blobPath) + "/" + opts.CacheKey()
+func (c *MediaCache) cacheKey(blobPath, variant string) string {
+	return c.prefix() + pathHash(blobPath) + "/" + variant
 }
 
 // prefix returns the path prefix for cache entries.
 func (c *MediaCache) prefix() string {
+	if c.static {
+		return staticCachePrefix
+	}
 	if c.userID != "" {
 		return "u/" + c.userID + "/" + mediaCachePrefix
 	}
@@ -117,3 +142,55 @@ func InvalidateForPath(db *sql.DB, appID, blobPath, userID string) {
 	getMemCache().invalidatePrefix(appID, prefix)
 	db.Exec(`DELETE FROM app_blobs WHERE app_id = ? AND path LIKE ?`, appID, prefix+"%")
 }
+
+// InvalidateStatic deletes every cached variant of a site's deployed files
+// (DB + memory), for when a deploy replaces them.
+func InvalidateStatic(db *sql.DB, appID string) {
+	getMemCache().invalidatePrefix(appID, staticCachePrefix)
+	db.Exec(`DELETE FROM app_blobs WHERE app_id = ? AND path LIKE ?`, appID, staticCachePrefix+"%")
+}
+
+// ForgetApp drops every cached variant of an app from memory, for when its
+// blobs were replaced wholesale, as a restore does. The variants stored in
+// the DB went with the blobs.
+func ForgetApp(appID string) {
+	getMemCache().invalidatePrefix(appID, "")
+}
+
+// cachedVariant matches the app_blobs paths of cached variants, shared,
+// static and per-user, but not user blobs that merely contain "_media/"
+const cachedVariant = `(substr(path, 1, 7) = '` + mediaCachePrefix + `'
+	OR (substr(path, 1, 2) = 'u/' AND substr(path, instr(substr(path, 3), '/') + 3, 7) = '` + mediaCachePrefix + `'))`
+
+// purgeBatch is how many variants each delete of PurgeCache removes
+const purgeBatch = 500
+
+// PurgeCache deletes every cached variant of every app (DB + memory), so
+// each is made again from its original when next requested. It deletes in
+// batches, reporting the variants deleted so far and the total, and
+// returns how many it deleted.
+func PurgeCache(ctx context.Context, db *sql.DB, progress func(deleted, total int64)) (int64, error) {
+	var total int64
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM app_blobs WHERE `+cachedVariant).Scan(&total); err != nil {
+		return 0, err
+	}
+	progress(0, total)
+
+	var deleted int64
+	for deleted < total {
+		res, err := db.ExecContext(ctx, `DELETE FROM app_blobs WHERE rowid IN (
+			SELECT rowid FROM app_blobs WHERE `+cachedVariant+` LIMIT ?
+		)`, purgeBatch)
+		if err != nil {
+			return deleted, err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			break
+		}
+		deleted += n
+		progress(deleted, total)
+	}
+	getMemCache().clear()
+	return deleted, nil
+}
@@ -0,0 +1,205 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// MaxWaveformSamples caps the amplitudes a waveform can have.
+const MaxWaveformSamples = 2000
+
+// waveformRate is the sample rate audio is decoded at for waveforms; plenty
+// for peaks, and small enough to decode a long podcast in memory
+const waveformRate = 8000
+
+// ErrNoFFmpeg is returned by extraction when the server has no ffmpeg.
+var ErrNoFFmpeg = errors.New("ffmpeg not available")
+
+// IsAudioContentType returns true if the content type is an audio type.
+func IsAudioContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "audio/")
+}
+
+// ThumbnailAndCache returns the cached poster frame of a stored video at
+// atSeconds, extracting it with ffmpeg on a miss. Waits for a transcode slot.
+func ThumbnailAndCache(ctx context.Context, cache *MediaCache, appID, blobPath string, data []byte, atSeconds float64) ([]byte, string, error) {
+	if !system.GetLimits().Video.FFmpegAvailable {
+		return nil, "", ErrNoFFmpeg
+	}
+	variant := "thumb_" + strconv.FormatInt(int64(atSeconds*1000), 10) + "ms"
+	if cached, mime, err := cache.GetVariant(ctx, appID, blobPath, variant); err == nil && cached != nil {
+		return cached, mime, nil
+	}
+
+	if err := acquireTranscodeSlot(ctx); err != nil {
+		return nil, "", err
+	}
+	defer func() { <-getTranscodeSem() }()
+
+	frame, err := ExtractThumbnail(ctx, data, atSeconds, system.GetLimits().Video.OutputMaxHeight)
+	if err != nil {
+		return nil, "", err
+	}
+	_ = cache.PutVariant(ctx, appID, blobPath, variant, frame, "image/jpeg")
+	return frame, "image/jpeg", nil
+}
+
+// WaveformAndCache returns the cached waveform of a stored audio or video
+// file, computing it with ffmpeg on a miss. Waits for a transcode slot.
+func WaveformAndCache(ctx context.Context, cache *MediaCache, appID, blobPath string, data []byte, samples int) ([]float64, error) {
+	if !system.GetLimits().Video.FFmpegAvailable {
+		return nil, ErrNoFFmpeg
+	}
+	variant := "wave_" + strconv.Itoa(samples)
+	if cached, _, err := cache.GetVariant(ctx, appID, blobPath, variant); err == nil && cached != nil {
+		var peaks []float64
+		if err := json.Unmarshal(cached, &peaks); err == nil {
+			return peaks, nil
+		}
+	}
+
+	if err := acquireTranscodeSlot(ctx); err != nil {
+		return nil, err
+	}
+	defer func() { <-getTranscodeSem() }()
+
+	peaks, err := ExtractWaveform(ctx, data, samples)
+	if err != nil {
+		return nil, err
+	}
+	if encoded, err := json.Marshal(peaks); err == nil {
+		_ = cache.PutVariant(ctx, appID, blobPath, variant, encoded, "application/json")
+	}
+	return peaks, nil
+}
+
+// acquireTranscodeSlot waits for one of the slots background transcodes
+// share, so extraction can't starve the server's CPU either
+func acquireTranscodeSlot(ctx context.Context) error {
+	select {
+	case getTranscodeSem() <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ExtractThumbnail runs ffmpeg to grab the frame of a video at atSeconds as
+// a JPEG at most maxHeight tall. Times past the end use the last second.
+// Runs at nice +19 with -threads 1.
+func ExtractThumbnail(ctx context.Context, input []byte, atSeconds float64, maxHeight int) ([]byte, error) {
+	if info, err := ProbeVideo(input); err == nil && info.Duration > 0 && atSeconds > info.Duration-1 {
+		atSeconds = math.Max(0, info.Duration-1)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "fazt-thumb-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input")
+	outputPath := filepath.Join(tmpDir, "thumb.jpg")
+	if err := os.WriteFile(inputPath, input, 0600); err != nil {
+		return nil, fmt.Errorf("write input: %w", err)
+	}
+
+	args := []string{
+		"-n", "19", "ffmpeg",
+		"-ss", strconv.FormatFloat(atSeconds, 'f', 3, 64),
+		"-i", inputPath,
+		"-frames:v", "1",
+		"-threads", "1",
+		"-q:v", "3",
+	}
+	if maxHeight > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", maxHeight))
+	}
+	args = append(args, "-f", "image2", "-y", outputPath)
+
+	cmd := exec.CommandContext(ctx, "nice", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %v: %s", err, string(output[:min(500, len(output))]))
+	}
+
+	frame, err := os.ReadFile(outputPath)
+	if err != nil || len(frame) == 0 {
+		return nil, fmt.Errorf("no frame at %gs", atSeconds)
+	}
+	return frame, nil
+}
+
+// ExtractWaveform runs ffmpeg to decode the sound of an audio or video file
+// and returns its peak amplitude (0-1) in each of samples equal slices.
+// Runs at nice +19 with -threads 1.
+func ExtractWaveform(ctx context.Context, input []byte, samples int) ([]float64, error) {
+	if samples < 1 || samples > MaxWaveformSamples {
+		return nil, fmt.Errorf("samples must be between 1 and %d", MaxWaveformSamples)
+	}
+
+	// A file, not stdin: MP4s with the moov box at the end need seeking
+	tmpDir, err := os.MkdirTemp("", "fazt-wave-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input")
+	if err := os.WriteFile(inputPath, input, 0600); err != nil {
+		return nil, fmt.Errorf("write input: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "nice",
+		"-n", "19", "ffmpeg",
+		"-i", inputPath,
+		"-vn",
+		"-threads", "1",
+		"-ac", "1",
+		"-ar", strconv.Itoa(waveformRate),
+		"-f", "s16le",
+		"pipe:1",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := stderr.Bytes()
+		return nil, fmt.Errorf("ffmpeg: %v: %s", err, string(msg[:min(500, len(msg))]))
+	}
+	if stdout.Len() < 2 {
+		return nil, errors.New("no audio track")
+	}
+	return pcmPeaks(stdout.Bytes(), samples), nil
+}
+
+// pcmPeaks splits signed 16-bit little-endian mono PCM into n slices and
+// returns each one's peak amplitude, 0-1 to 3 decimals
+func pcmPeaks(pcm []byte, n int) []float64 {
+	frames := len(pcm) / 2
+	peaks := make([]float64, n)
+	for i := range peaks {
+		start, end := i*frames/n, (i+1)*frames/n
+		var peak int
+		for f := start; f < end; f++ {
+			v := int(int16(binary.LittleEndian.Uint16(pcm[f*2:])))
+			if v < 0 {
+				v = -v
+			}
+			peak = max(peak, v)
+		}
+		peaks[i] = math.Round(math.Min(float64(peak)/32767, 1)*1000) / 1000
+	}
+	return peaks
+}
@@ -0,0 +1,94 @@
+package media
+
+import (
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// --- Waveform tests ---
+
+func pcm(values ...int16) []byte {
+	out := make([]byte, 2*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(v))
+	}
+	return out
+}
+
+func TestPCMPeaks(t *testing.T) {
+	peaks := pcmPeaks(pcm(0, 100, -32768, 16384, 0, 0, 3277, -3277), 4)
+	want := []float64{0.003, 1, 0, 0.1}
+	for i := range want {
+		if peaks[i] != want[i] {
+			t.Errorf("peaks = %v, want %v", peaks, want)
+			break
+		}
+	}
+}
+
+func TestPCMPeaks_MoreSamplesThanFrames(t *testing.T) {
+	peaks := pcmPeaks(pcm(16384, -16384), 4)
+	if len(peaks) != 4 {
+		t.Fatalf("Expected 4 peaks, got %d", len(peaks))
+	}
+	for _, p := range peaks {
+		if p != 0 && p != 0.5 {
+			t.Errorf("Unexpected peak %v in %v", p, peaks)
+		}
+	}
+}
+
+func TestExtractWaveform(t *testing.T) {
+	// 4 frames of s16le: 0, 16384, -32767, 3277
+	fakeFFmpeg(t, `printf '\000\000\000\100\001\200\315\014'`)
+
+	peaks, err := ExtractWaveform(context.Background(), []byte("audio"), 2)
+	if err != nil {
+		t.Fatalf("ExtractWaveform: %v", err)
+	}
+	if len(peaks) != 2 || peaks[0] != 0.5 || peaks[1] != 1 {
+		t.Errorf("peaks = %v, want [0.5 1]", peaks)
+	}
+
+	if _, err := ExtractWaveform(context.Background(), []byte("audio"), MaxWaveformSamples+1); err == nil {
+		t.Error("Expected an error for too many samples")
+	}
+}
+
+func TestExtractWaveform_NoAudio(t *testing.T) {
+	fakeFFmpeg(t, `exit 0`)
+	if _, err := ExtractWaveform(context.Background(), []byte("video"), 10); err == nil || !strings.Contains(err.Error(), "no audio") {
+		t.Errorf("Expected a no audio error, got %v", err)
+	}
+}
+
+// --- Thumbnail tests ---
+
+func TestExtractThumbnail(t *testing.T) {
+	// Writes its -ss value to the output file, the last argument
+	fakeFFmpeg(t, `prev=""
+for a; do
+	[ "$prev" = "-ss" ] && at="$a"
+	prev="$a"
+	out="$a"
+done
+printf "frame@%s" "$at" > "$out"`)
+
+	frame, err := ExtractThumbnail(context.Background(), []byte("video"), 2.5, 720)
+	if err != nil {
+		t.Fatalf("ExtractThumbnail: %v", err)
+	}
+	if string(frame) != "frame@2.500" {
+		t.Errorf("Unexpected frame %q", frame)
+	}
+}
+
+func TestExtractThumbnail_Fails(t *testing.T) {
+	fakeFFmpeg(t, `echo "Invalid data found" >&2; exit 1`)
+	_, err := ExtractThumbnail(context.Background(), []byte("video"), 0, 720)
+	if err == nil || !strings.Contains(err.Error(), "Invalid data found") {
+		t.Errorf("Expected ffmpeg's error, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// HLSPrefix is the blob path prefix for HLS renditions of a video.
+const HLSPrefix = "_v/hls/"
+
+// HLSMaster is the name of the playlist listing a video's renditions.
+const HLSMaster = "master.m3u8"
+
+// HLSSegmentSeconds is the target length of an HLS segment.
+const HLSSegmentSeconds = 6
+
+// HLSPath returns the blob path of one file (playlist or segment) of a
+// video's HLS renditions.
+func HLSPath(originalPath, file string) string {
+	return HLSPrefix + originalPath + "/" + file
+}
+
+// hlsFilePattern matches the files TranscodeToHLS writes: master.m3u8,
+// 720p.m3u8 and 720p_000.ts
+var hlsFilePattern = regexp.MustCompile(`^(master|\d+p)\.m3u8$|^\d+p_\d+\.ts$`)
+
+// HLSFileFromQuery returns the HLS file a request asks for with ?hls=:
+// ?hls (or ?hls=master.m3u8) for the master playlist, and the names the
+// playlists link to for renditions and segments. ok is false when the
+// request isn't for HLS; file is empty when it names a file that can't exist.
+func HLSFileFromQuery(q url.Values) (file string, ok bool) {
+	if q == nil || !q.Has("hls") {
+		return "", false
+	}
+	file = q.Get("hls")
+	if file == "" {
+		file = HLSMaster
+	}
+	if !hlsFilePattern.MatchString(file) {
+		return "", true
+	}
+	return file, true
+}
+
+// hlsRendition is one rung of the HLS ladder
+type hlsRendition struct {
+	Height       int
+	VideoBitrate int // kbit/s
+}
+
+// hlsLadder is the renditions a video gets, up to its own height and the
+// server's OutputMaxHeight
+var hlsLadder = []hlsRendition{
+	{Height: 360, VideoBitrate: 800},
+	{Height: 480, VideoBitrate: 1400},
+	{Height: 720, VideoBitrate: 2800},
+	{Height: 1080, VideoBitrate: 5000},
+}
+
+const hlsAudioBitrate = 128 // kbit/s
+
+// hlsRenditions returns the rungs for a video sourceHeight tall (0 when
+// unknown). A video shorter than the lowest rung gets one at its own height.
+func hlsRenditions(sourceHeight, maxHeight int) []hlsRendition {
+	limit := maxHeight
+	if sourceHeight > 0 && (limit <= 0 || sourceHeight < limit) {
+		limit = sourceHeight
+	}
+	var out []hlsRendition
+	for _, r := range hlsLadder {
+		if limit <= 0 || r.Height <= limit {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, hlsRendition{Height: limit - limit%2, VideoBitrate: hlsLadder[0].VideoBitrate})
+	}
+	return out
+}
+
+func (r hlsRendition) name() string {
+	return fmt.Sprintf("%dp", r.Height)
+}
+
+// TranscodeToHLS runs ffmpeg to cut video data into H.264+AAC HLS
+// renditions, one per rung of the ladder that fits the video, with
+// keyframes aligned across them so players can switch at any segment.
+// It returns the files by name: each rendition's playlist and segments,
+// and the master playlist. Playlists link to their files as "?hls=<name>",
+// relative to the URL the video is served at. Blocks until complete; runs
+// at nice +19 with -threads 1.
+func TranscodeToHLS(ctx context.Context, input []byte, info *VideoInfo, maxHeight int) (map[string][]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "fazt-hls-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input")
+	if err := os.WriteFile(inputPath, input, 0600); err != nil {
+		return nil, fmt.Errorf("write input: %w", err)
+	}
+	outDir := filepath.Join(tmpDir, "out")
+	if err := os.Mkdir(outDir, 0700); err != nil {
+		return nil, fmt.Errorf("create output dir: %w", err)
+	}
+
+	renditions := hlsRenditions(info.Height, maxHeight)
+	for _, r := range renditions {
+		args := []string{
+			"-n", "19", "ffmpeg",
+			"-i", inputPath,
+			"-c:v", "libx264",
+			"-preset", "medium",
+			"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+			"-maxrate", fmt.Sprintf("%dk", r.VideoBitrate*107/100),
+			"-bufsize", fmt.Sprintf("%dk", r.VideoBitrate*3/2),
+			"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%d)", HLSSegmentSeconds),
+			"-vf", fmt.Sprintf("scale=-2:%d", r.Height),
+			"-threads", "1",
+			"-c:a", "aac",
+			"-b:a", fmt.Sprintf("%dk", hlsAudioBitrate),
+			"-f", "hls",
+			"-hls_time", fmt.Sprint(HLSSegmentSeconds),
+			"-hls_playlist_type", "vod",
+			"-hls_segment_filename", filepath.Join(outDir, r.name()+"_%03d.ts"),
+			"-y", filepath.Join(outDir, r.name()+".m3u8"),
+		}
+		cmd := exec.CommandContext(ctx, "nice", args...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("ffmpeg %s: %v: %s", r.name(), err, string(output[:min(500, len(output))]))
+		}
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return nil, fmt.Errorf("read output: %w", err)
+	}
+	files := make(map[string][]byte, len(entries)+1)
+	for _, entry := range entries {
+		if !hlsFilePattern.MatchString(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(outDir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read output: %w", err)
+		}
+		if strings.HasSuffix(entry.Name(), ".m3u8") {
+			data = linkPlaylist(data)
+		}
+		files[entry.Name()] = data
+	}
+	for _, r := range renditions {
+		if _, ok := files[r.name()+".m3u8"]; !ok {
+			return nil, fmt.Errorf("ffmpeg wrote no %s playlist", r.name())
+		}
+	}
+	files[HLSMaster] = masterPlaylist(renditions, info)
+	return files, nil
+}
+
+// linkPlaylist points a playlist's URI lines at "?hls=<name>", so they
+// resolve against the URL the video is served at
+func linkPlaylist(playlist []byte) []byte {
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(playlist))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line = "?hls=" + url.QueryEscape(filepath.Base(line))
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// masterPlaylist lists the renditions, smallest first, for players to
+// pick from by bandwidth
+func masterPlaylist(renditions []hlsRendition, info *VideoInfo) []byte {
+	var out bytes.Buffer
+	out.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range renditions {
+		fmt.Fprintf(&out, "#EXT-X-STREAM-INF:BANDWIDTH=%d", (r.VideoBitrate+hlsAudioBitrate)*1000)
+		if info.Width > 0 && info.Height > 0 {
+			width := (info.Width*r.Height/info.Height + 1) &^ 1
+			fmt.Fprintf(&out, ",RESOLUTION=%dx%d", width, r.Height)
+		}
+		fmt.Fprintf(&out, "\n?hls=%s.m3u8\n", r.name())
+	}
+	return out.Bytes()
+}
+
+// HLSMimeType returns the content type of an HLS file by its name.
+func HLSMimeType(file string) string {
+	if strings.HasSuffix(file, ".m3u8") {
+		return "application/vnd.apple.mpegurl"
+	}
+	return "video/mp2t"
+}
@@ -0,0 +1,142 @@
+package media
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// --- HLS query tests ---
+
+func TestHLSFileFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		file  string
+		ok    bool
+	}{
+		{"", "", false},
+		{"w=480", "", false},
+		{"hls", HLSMaster, true},
+		{"hls=master.m3u8", HLSMaster, true},
+		{"hls=720p.m3u8", "720p.m3u8", true},
+		{"hls=720p_004.ts", "720p_004.ts", true},
+		{"hls=../secret.ts", "", true},
+		{"hls=720p.mp4", "", true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		file, ok := HLSFileFromQuery(q)
+		if file != tt.file || ok != tt.ok {
+			t.Errorf("%q: got (%q, %v), want (%q, %v)", tt.query, file, ok, tt.file, tt.ok)
+		}
+	}
+}
+
+// --- HLS ladder tests ---
+
+func TestHLSRenditions(t *testing.T) {
+	tests := []struct {
+		source, max int
+		want        []int
+	}{
+		{1080, 720, []int{360, 480, 720}},
+		{540, 1080, []int{360, 480}},
+		{0, 720, []int{360, 480, 720}},
+		{240, 720, []int{240}},
+	}
+	for _, tt := range tests {
+		var got []int
+		for _, r := range hlsRenditions(tt.source, tt.max) {
+			got = append(got, r.Height)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("source %d, max %d: got %v, want %v", tt.source, tt.max, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("source %d, max %d: got %v, want %v", tt.source, tt.max, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+// --- HLS transcode tests ---
+
+// fakeFFmpeg puts an ffmpeg on PATH that runs script, a shell script
+// standing in for it
+func fakeFFmpeg(t *testing.T, script string) {
+	t.Helper()
+	fakeCommand(t, "ffmpeg", script)
+}
+
+// fakeCommand puts a command called name on PATH that runs script
+func fakeCommand(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// fakeHLSScript writes two segments and a playlist for each HLS run
+const fakeHLSScript = `seg=""
+prev=""
+for a; do
+	[ "$prev" = "-hls_segment_filename" ] && seg="$a"
+	prev="$a"
+	out="$a"
+done
+f0=$(printf "$seg" 0)
+f1=$(printf "$seg" 1)
+echo seg0 > "$f0"
+echo seg1 > "$f1"
+printf '#EXTM3U\n#EXTINF:6.0,\n%s\n#EXTINF:2.0,\n%s\n#EXT-X-ENDLIST\n' "$(basename "$f0")" "$(basename "$f1")" > "$out"
+`
+
+func TestTranscodeToHLS(t *testing.T) {
+	fakeFFmpeg(t, fakeHLSScript)
+
+	info := &VideoInfo{Width: 1920, Height: 1080}
+	files, err := TranscodeToHLS(context.Background(), []byte("video"), info, 720)
+	if err != nil {
+		t.Fatalf("TranscodeToHLS: %v", err)
+	}
+
+	for _, name := range []string{HLSMaster, "360p.m3u8", "480p.m3u8", "720p.m3u8", "360p_000.ts", "720p_001.ts"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in output", name)
+		}
+	}
+
+	// Playlists link relative to the URL the video is served at
+	playlist := string(files["720p.m3u8"])
+	if !strings.Contains(playlist, "\n?hls=720p_000.ts\n") || !strings.Contains(playlist, "#EXT-X-ENDLIST") {
+		t.Errorf("Unexpected rendition playlist:\n%s", playlist)
+	}
+	master := string(files[HLSMaster])
+	if !strings.Contains(master, "BANDWIDTH=928000,RESOLUTION=640x360\n?hls=360p.m3u8\n") {
+		t.Errorf("Unexpected master playlist:\n%s", master)
+	}
+	if strings.Contains(master, "1080p") {
+		t.Errorf("Expected no rendition above the max height:\n%s", master)
+	}
+}
+
+func TestHLSMimeType(t *testing.T) {
+	if got := HLSMimeType(HLSMaster); got != "application/vnd.apple.mpegurl" {
+		t.Errorf("playlist: got %q", got)
+	}
+	if got := HLSMimeType("360p_000.ts"); got != "video/mp2t" {
+		t.Errorf("segment: got %q", got)
+	}
+}
@@ -44,28 +44,46 @@ func IsImageContentType(contentType string) bool {
 }
 
 // ProcessImage resizes image data according to opts and returns the processed bytes + mime type.
+// With only a format set, the image is converted at its own size.
 func ProcessImage(data []byte, opts TransformOpts) ([]byte, string, error) {
 	fit := imgservice.Fit(opts.Fit)
 	if fit == "" {
 		fit = imgservice.FitContain
 	}
 
-	result, err := imgservice.Resize(data, imgservice.ResizeOpts{
-		Width:   opts.Width,
-		Height:  opts.Height,
-		Fit:     fit,
-		Quality: opts.Quality,
-	})
-	if err != nil {
-		return nil, "", err
+	var out []byte
+	format := imgservice.Format(opts.Format)
+	if opts.Width <= 0 && opts.Height <= 0 {
+		img, srcFormat, err := imgservice.Decode(data)
+		if err != nil {
+			return nil, "", err
+		}
+		if format == "" {
+			format = srcFormat
+		}
+		if out, err = imgservice.EncodeToBytes(img, format, opts.Quality); err != nil {
+			return nil, "", err
+		}
+	} else {
+		result, err := imgservice.Resize(data, imgservice.ResizeOpts{
+			Width:   opts.Width,
+			Height:  opts.Height,
+			Fit:     fit,
+			Format:  format,
+			Quality: opts.Quality,
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		out, format = result.Data, result.Format
 	}
 
 	mime := "image/jpeg"
-	if result.Format == imgservice.FormatPNG {
+	if format == imgservice.FormatPNG {
 		mime = "image/png"
 	}
 
-	return result.Data, mime, nil
+	return out, mime, nil
 }
 
 // ProcessAndCache checks the cache for a variant, or processes the image and caches the result.
@@ -115,3 +133,32 @@ func ProcessAndCache(ctx context.Context, cache *MediaCache, appID, originalPath
 
 	return processed, mime, nil
 }
+
+// Pregenerate processes and caches a variant ahead of any request for it,
+// for work done at deploy time. Unlike ProcessAndCache it waits for a
+// resize slot instead of giving up when all are busy.
+func Pregenerate(ctx context.Context, cache *MediaCache, appID, originalPath string, data []byte, opts TransformOpts) error {
+	limits := system.GetLimits().Media
+	if int64(len(data)) > limits.MaxSourceBytes {
+		return nil
+	}
+	opts = SnapToStep(opts, limits.WidthStep)
+
+	if cached, _, err := cache.Get(ctx, appID, originalPath, opts); err == nil && cached != nil {
+		return nil
+	}
+
+	sem := getResizeSem()
+	select {
+	case sem <- struct{}{}:
+		defer func() { <-sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	processed, mime, err := ProcessImage(data, opts)
+	if err != nil {
+		return err
+	}
+	return cache.Put(ctx, appID, originalPath, opts, processed, mime)
+}
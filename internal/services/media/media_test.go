@@ -108,6 +108,18 @@ func TestParseTransformQuery_MaxDimension(t *testing.T) {
 	}
 }
 
+func TestParseTransformQuery_Format(t *testing.T) {
+	for in, want := range map[string]string{"png": "png", "jpg": "jpeg", "jpeg": "jpeg", "webp": "", "gif": ""} {
+		opts := ParseTransformQuery(url.Values{"format": {in}})
+		if opts.Format != want {
+			t.Errorf("format=%s: Format = %q, want %q", in, opts.Format, want)
+		}
+		if opts.HasTransform() != (want != "") {
+			t.Errorf("format=%s: HasTransform() = %v", in, opts.HasTransform())
+		}
+	}
+}
+
 // --- CacheKey tests ---
 
 func TestCacheKey_Deterministic(t *testing.T) {
@@ -128,6 +140,13 @@ func TestCacheKey_DefaultValues(t *testing.T) {
 	}
 }
 
+func TestCacheKey_WithFormat(t *testing.T) {
+	opts := TransformOpts{Width: 200, Format: "png"}
+	if key := opts.CacheKey(); key != "200x0_contain_q85_png" {
+		t.Errorf("CacheKey = %q, want 200x0_contain_q85_png", key)
+	}
+}
+
 func TestCacheKey_WithFitAndQuality(t *testing.T) {
 	opts := TransformOpts{Width: 200, Height: 200, Fit: "cover", Quality: 70}
 	key := opts.CacheKey()
@@ -256,6 +275,35 @@ func TestProcessImage_ResizePNG(t *testing.T) {
 	}
 }
 
+func TestProcessImage_ConvertFormat(t *testing.T) {
+	data := makeTestJPEG(120, 80)
+
+	// Format only: converted at the original size
+	processed, mime, err := ProcessImage(data, TransformOpts{Format: "png"})
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+	if mime != "image/png" {
+		t.Errorf("mime = %q, want image/png", mime)
+	}
+	img, err := png.Decode(bytes.NewReader(processed))
+	if err != nil {
+		t.Fatalf("Expected PNG output: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 80 {
+		t.Errorf("size = %dx%d, want 120x80", b.Dx(), b.Dy())
+	}
+
+	// With a resize
+	processed, mime, err = ProcessImage(makeTestPNG(400, 300), TransformOpts{Width: 200, Format: "jpeg"})
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(processed)); err != nil || mime != "image/jpeg" {
+		t.Errorf("Expected JPEG output, got %s (%v)", mime, err)
+	}
+}
+
 func TestProcessImage_CoverFit(t *testing.T) {
 	data := makeTestJPEG(800, 600)
 	opts := TransformOpts{Width: 200, Height: 200, Fit: "cover"}
@@ -128,3 +128,13 @@ func (c *memCache) invalidatePrefix(appID, pathPrefix string) {
 		}
 	}
 }
+
+// clear removes every entry.
+func (c *memCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.size = 0
+}
@@ -0,0 +1,215 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// DefaultPreviewSize is the longest side of a document preview when none is
+// asked for.
+const DefaultPreviewSize = 512
+
+// MinPreviewSize and MaxPreviewSize bound the longest side of a preview.
+const (
+	MinPreviewSize = 16
+	MaxPreviewSize = 2048
+)
+
+// ErrNoPreviewer is returned by previews when the server lacks the
+// converter a document needs (pdftoppm, and LibreOffice for office files).
+var ErrNoPreviewer = errors.New("document previews not available")
+
+// officeExtensions maps the office formats LibreOffice can preview to the
+// extension it detects each by
+var officeExtensions = map[string]string{
+	// Microsoft Office
+	"application/msword":            ".doc",
+	"application/vnd.ms-excel":      ".xls",
+	"application/vnd.ms-powerpoint": ".ppt",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+
+	// OpenDocument
+	"application/vnd.oasis.opendocument.text":         ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":  ".ods",
+	"application/vnd.oasis.opendocument.presentation": ".odp",
+
+	// Rich text
+	"application/rtf": ".rtf",
+}
+
+// baseContentType drops a content type's parameters ("; charset=...")
+func baseContentType(contentType string) string {
+	base, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(base))
+}
+
+// IsPDFContentType returns true if the content type is PDF.
+func IsPDFContentType(contentType string) bool {
+	return baseContentType(contentType) == "application/pdf"
+}
+
+// IsOfficeContentType returns true if the content type is a word processor,
+// spreadsheet or presentation format LibreOffice can convert.
+func IsOfficeContentType(contentType string) bool {
+	_, ok := officeExtensions[baseContentType(contentType)]
+	return ok
+}
+
+// IsDocumentContentType returns true if the content type can be previewed
+// as a document.
+func IsDocumentContentType(contentType string) bool {
+	return IsPDFContentType(contentType) || IsOfficeContentType(contentType)
+}
+
+// PreviewFromQuery returns the preview size a request asks for with
+// ?preview (DefaultPreviewSize) or ?preview=<px>. ok is false when the
+// request isn't for a preview; size is 0 when it asks for an invalid one.
+func PreviewFromQuery(q url.Values) (size int, ok bool) {
+	if q == nil || !q.Has("preview") {
+		return 0, false
+	}
+	v := q.Get("preview")
+	if v == "" {
+		return DefaultPreviewSize, true
+	}
+	size, err := strconv.Atoi(v)
+	if err != nil || size < MinPreviewSize || size > MaxPreviewSize {
+		return 0, true
+	}
+	return size, true
+}
+
+// PreviewAndCache returns the cached PNG of the first page of a stored PDF
+// or office document, size pixels on its longest side, rendering it on a
+// miss. Waits for a transcode slot.
+func PreviewAndCache(ctx context.Context, cache *MediaCache, appID, blobPath string, data []byte, contentType string, size int) ([]byte, string, error) {
+	limits := system.GetLimits().Media
+	office := IsOfficeContentType(contentType)
+	if !limits.PDFAvailable || (office && !limits.OfficeAvailable) {
+		return nil, "", ErrNoPreviewer
+	}
+	variant := "preview_" + strconv.Itoa(size)
+	if cached, mime, err := cache.GetVariant(ctx, appID, blobPath, variant); err == nil && cached != nil {
+		return cached, mime, nil
+	}
+
+	if err := acquireTranscodeSlot(ctx); err != nil {
+		return nil, "", err
+	}
+	defer func() { <-getTranscodeSem() }()
+
+	pdf := data
+	if office {
+		var err error
+		if pdf, err = ConvertToPDF(ctx, data, contentType); err != nil {
+			return nil, "", err
+		}
+	}
+	page, err := RenderPDFPage(ctx, pdf, size)
+	if err != nil {
+		return nil, "", err
+	}
+	_ = cache.PutVariant(ctx, appID, blobPath, variant, page, "image/png")
+	return page, "image/png", nil
+}
+
+// RenderPDFPage runs pdftoppm to render the first page of a PDF as a PNG
+// size pixels on its longest side. Runs at nice +19.
+func RenderPDFPage(ctx context.Context, pdf []byte, size int) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "fazt-preview-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input.pdf")
+	if err := os.WriteFile(inputPath, pdf, 0600); err != nil {
+		return nil, fmt.Errorf("write input: %w", err)
+	}
+
+	// -singlefile writes <root>.png rather than <root>-1.png
+	cmd := exec.CommandContext(ctx, "nice",
+		"-n", "19", "pdftoppm",
+		"-png",
+		"-f", "1", "-l", "1",
+		"-singlefile",
+		"-scale-to", strconv.Itoa(size),
+		inputPath,
+		filepath.Join(tmpDir, "page"),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pdftoppm: %v: %s", err, string(output[:min(500, len(output))]))
+	}
+
+	page, err := os.ReadFile(filepath.Join(tmpDir, "page.png"))
+	if err != nil || len(page) == 0 {
+		return nil, errors.New("no page rendered")
+	}
+	return page, nil
+}
+
+// ConvertToPDF runs LibreOffice headless to convert an office document to
+// PDF. Each run gets its own profile, so conversions don't contend for the
+// user's lock. Runs at nice +19.
+func ConvertToPDF(ctx context.Context, input []byte, contentType string) ([]byte, error) {
+	ext, ok := officeExtensions[baseContentType(contentType)]
+	if !ok {
+		return nil, fmt.Errorf("can't convert %s to PDF", contentType)
+	}
+	bin := officeCommand()
+	if bin == "" {
+		return nil, ErrNoPreviewer
+	}
+
+	tmpDir, err := os.MkdirTemp("", "fazt-office-*")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	inputPath := filepath.Join(tmpDir, "input"+ext)
+	outDir := filepath.Join(tmpDir, "out")
+	if err := os.WriteFile(inputPath, input, 0600); err != nil {
+		return nil, fmt.Errorf("write input: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "nice",
+		"-n", "19", bin,
+		"-env:UserInstallation=file://"+filepath.ToSlash(filepath.Join(tmpDir, "profile")),
+		"--headless",
+		"--convert-to", "pdf",
+		"--outdir", outDir,
+		inputPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", bin, err, string(output[:min(500, len(output))]))
+	}
+
+	pdf, err := os.ReadFile(filepath.Join(outDir, "input.pdf"))
+	if err != nil || len(pdf) == 0 {
+		return nil, errors.New("document could not be converted")
+	}
+	return pdf, nil
+}
+
+// officeCommand returns the LibreOffice binary on PATH, or "" when there
+// is none
+func officeCommand() string {
+	for _, name := range []string{"soffice", "libreoffice"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package media
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// fakePdftoppmScript writes its -scale-to value to <root>.png, the last
+// argument
+const fakePdftoppmScript = `prev=""
+for a; do
+	[ "$prev" = "-scale-to" ] && size="$a"
+	prev="$a"
+	root="$a"
+done
+printf "page@%s" "$size" > "$root.png"`
+
+// --- Preview query tests ---
+
+func TestPreviewFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		size  int
+		ok    bool
+	}{
+		{"", 0, false},
+		{"w=480", 0, false},
+		{"preview", DefaultPreviewSize, true},
+		{"preview=256", 256, true},
+		{"preview=8", 0, true},
+		{"preview=big", 0, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		size, ok := PreviewFromQuery(q)
+		if size != tt.size || ok != tt.ok {
+			t.Errorf("%q: got (%d, %v), want (%d, %v)", tt.query, size, ok, tt.size, tt.ok)
+		}
+	}
+}
+
+func TestIsDocumentContentType(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"application/pdf":                         true,
+		"Application/PDF; name=a.pdf":             true,
+		"application/vnd.oasis.opendocument.text": true,
+		"application/vnd.ms-excel":                true,
+		"application/zip":                         false,
+		"text/plain":                              false,
+		"image/png":                               false,
+	} {
+		if got := IsDocumentContentType(ct); got != want {
+			t.Errorf("IsDocumentContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
+
+// --- Rendering tests ---
+
+func TestRenderPDFPage(t *testing.T) {
+	fakeCommand(t, "pdftoppm", fakePdftoppmScript)
+
+	page, err := RenderPDFPage(context.Background(), []byte("%PDF-1.4"), 256)
+	if err != nil {
+		t.Fatalf("RenderPDFPage: %v", err)
+	}
+	if string(page) != "page@256" {
+		t.Errorf("page = %q", page)
+	}
+}
+
+func TestRenderPDFPage_Fails(t *testing.T) {
+	fakeCommand(t, "pdftoppm", `echo "Syntax Error: Couldn't find trailer dictionary" >&2; exit 1`)
+
+	_, err := RenderPDFPage(context.Background(), []byte("not a pdf"), 256)
+	if err == nil || !strings.Contains(err.Error(), "trailer dictionary") {
+		t.Errorf("Expected pdftoppm's error, got %v", err)
+	}
+}
+
+func TestConvertToPDF(t *testing.T) {
+	// Copies its input to <outdir>/input.pdf, as LibreOffice names it
+	fakeCommand(t, "soffice", `prev=""
+for a; do
+	[ "$prev" = "--outdir" ] && out="$a"
+	prev="$a"
+	in="$a"
+done
+case "$in" in *.docx) ;; *) exit 1 ;; esac
+mkdir -p "$out" && cp "$in" "$out/input.pdf"`)
+
+	pdf, err := ConvertToPDF(context.Background(), []byte("doc"), "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	if err != nil {
+		t.Fatalf("ConvertToPDF: %v", err)
+	}
+	if string(pdf) != "doc" {
+		t.Errorf("pdf = %q", pdf)
+	}
+
+	if _, err := ConvertToPDF(context.Background(), []byte("x"), "application/zip"); err == nil {
+		t.Error("Expected an error for a format LibreOffice isn't used for")
+	}
+}
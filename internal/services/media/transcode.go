@@ -39,6 +39,7 @@ func getTranscodeSem() chan struct{} {
 // TranscodeResult describes the outcome of a transcode request.
 type TranscodeResult struct {
 	Status string `json:"status"` // "queued", "compatible", "no_ffmpeg", "too_large", "not_video"
+	HLS    bool   `json:"hls"`    // HLS renditions queued
 }
 
 // StoreFunc stores a blob. Used as callback from background transcoding.
@@ -47,6 +48,9 @@ type StoreFunc func(ctx context.Context, path string, data []byte, mime string)
 // QueueTranscode checks if video data needs transcoding and queues it if so.
 // Returns immediately — transcoding happens in the background.
 // storeResult is called from the background goroutine to persist the variant.
+//
+// Every video also gets HLS renditions for adaptive streaming, stored under
+// HLSPath; the master playlist is stored last, once the rest is in place.
 func QueueTranscode(appID, blobPath string, data []byte, mime string, storeResult StoreFunc) TranscodeResult {
 	limits := system.GetLimits().Video
 
@@ -67,10 +71,6 @@ func QueueTranscode(appID, blobPath string, data []byte, mime string, storeResul
 		return TranscodeResult{Status: "not_video"}
 	}
 
-	if info.Compatible {
-		return TranscodeResult{Status: "compatible"}
-	}
-
 	// Queue background transcode
 	go func() {
 		sem := getTranscodeSem()
@@ -78,22 +78,48 @@ func QueueTranscode(appID, blobPath string, data []byte, mime string, storeResul
 		defer func() { <-sem }()
 
 		bgCtx := context.Background()
-		result, err := TranscodeToH264(bgCtx, data, limits.OutputMaxHeight)
+		if !info.Compatible {
+			result, err := TranscodeToH264(bgCtx, data, limits.OutputMaxHeight)
+			if err != nil {
+				debug.Log("media", "transcode failed for %s/%s: %v", appID, blobPath, err)
+				return
+			}
+
+			variantPath := VariantPath(blobPath)
+			if err := storeResult(bgCtx, variantPath, result, "video/mp4"); err != nil {
+				debug.Log("media", "failed to store variant for %s/%s: %v", appID, blobPath, err)
+				return
+			}
+
+			debug.Log("media", "transcoded %s/%s → H.264 (%d → %d bytes)", appID, blobPath, len(data), len(result))
+		}
+
+		files, err := TranscodeToHLS(bgCtx, data, info, limits.OutputMaxHeight)
 		if err != nil {
-			debug.Log("media", "transcode failed for %s/%s: %v", appID, blobPath, err)
+			debug.Log("media", "HLS transcode failed for %s/%s: %v", appID, blobPath, err)
 			return
 		}
-
-		variantPath := VariantPath(blobPath)
-		if err := storeResult(bgCtx, variantPath, result, "video/mp4"); err != nil {
-			debug.Log("media", "failed to store variant for %s/%s: %v", appID, blobPath, err)
+		for name, file := range files {
+			if name == HLSMaster {
+				continue
+			}
+			if err := storeResult(bgCtx, HLSPath(blobPath, name), file, HLSMimeType(name)); err != nil {
+				debug.Log("media", "failed to store HLS file for %s/%s: %v", appID, blobPath, err)
+				return
+			}
+		}
+		if err := storeResult(bgCtx, HLSPath(blobPath, HLSMaster), files[HLSMaster], HLSMimeType(HLSMaster)); err != nil {
+			debug.Log("media", "failed to store HLS playlist for %s/%s: %v", appID, blobPath, err)
 			return
 		}
 
-		debug.Log("media", "transcoded %s/%s → H.264 (%d → %d bytes)", appID, blobPath, len(data), len(result))
+		debug.Log("media", "transcoded %s/%s → HLS (%d files)", appID, blobPath, len(files))
 	}()
 
-	return TranscodeResult{Status: "queued"}
+	if info.Compatible {
+		return TranscodeResult{Status: "compatible", HLS: true}
+	}
+	return TranscodeResult{Status: "queued", HLS: true}
 }
 
 // TranscodeToH264 runs ffmpeg to convert video data to H.264+AAC MP4.
@@ -30,11 +30,12 @@ type TransformOpts struct {
 	Height  int
 	Fit     string // "contain", "cover", "fill"
 	Quality int    // 1-100, 0 = default (85)
+	Format  string // "jpeg", "png", "" = same as the source
 }
 
 // HasTransform returns true if any transform parameter is set.
 func (t TransformOpts) HasTransform() bool {
-	return t.Width > 0 || t.Height > 0
+	return t.Width > 0 || t.Height > 0 || t.Format != ""
 }
 
 // CacheKey returns a deterministic string for caching this transform variant.
@@ -47,7 +48,11 @@ func (t TransformOpts) CacheKey() string {
 	if q <= 0 {
 		q = 85
 	}
-	return strconv.Itoa(t.Width) + "x" + strconv.Itoa(t.Height) + "_" + fit + "_q" + strconv.Itoa(q)
+	key := strconv.Itoa(t.Width) + "x" + strconv.Itoa(t.Height) + "_" + fit + "_q" + strconv.Itoa(q)
+	if t.Format != "" {
+		key += "_" + t.Format
+	}
+	return key
 }
 
 // ParseTransformQuery extracts transform options from URL query parameters.
@@ -56,6 +61,8 @@ func (t TransformOpts) CacheKey() string {
 //	?h=600        → height 600, auto width
 //	?w=200&h=200&fit=cover  → thumbnail
 //	?q=70         → quality override
+//	?format=png   → convert (jpeg or png; webp is ignored, there's no
+//	                pure-Go WebP encoder)
 func ParseTransformQuery(q url.Values) TransformOpts {
 	opts := TransformOpts{}
 
@@ -80,6 +87,12 @@ func ParseTransformQuery(q url.Values) TransformOpts {
 			opts.Quality = n
 		}
 	}
+	switch q.Get("format") {
+	case "jpeg", "jpg":
+		opts.Format = "jpeg"
+	case "png":
+		opts.Format = "png"
+	}
 
 	return opts
 }
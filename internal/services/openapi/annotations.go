@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// docCommentRe matches a /** ... */ comment and the handler export right
+// after it: exports.GET =, exports["GET"] =, export function GET,
+// export const GET, or a GET: member of module.exports = {...}
+var docCommentRe = regexp.MustCompile(`/\*\*((?:[^*]|\*+[^*/])*)\*+/\s*(?:` +
+	`exports\s*(?:\.\s*([A-Z]+)|\[\s*['"]([A-Z]+)['"]\s*\])\s*=` +
+	`|export\s+(?:(?:async\s+)?function\b\s*\*?\s*|const\s+|let\s+|var\s+)([A-Z]+)\b` +
+	`|([A-Z]+)\s*:)`)
+
+// typedTagRe splits "{number} [limit=20] - Max items" into type, name and
+// description
+var typedTagRe = regexp.MustCompile(`^(?:\{([^}]*)\}\s*)?(\[[^\]]*\]|\S+)?\s*(?:-\s*)?(.*)$`)
+
+// ParseAnnotations returns the operations described by a route file's
+// JSDoc-style comments, by method. A comment describes the handler export
+// right after it:
+//
+//	/**
+//	 * Get an item
+//	 *
+//	 * Longer description, as many lines as needed.
+//	 * @tag items
+//	 * @param {string} id - Item ID
+//	 * @query {boolean} [full=false] - Include the item's history
+//	 * @header {string} X-Request-Id - Traced through the logs
+//	 * @body {object} The fields to change
+//	 * @returns The item
+//	 * @response 404 No such item
+//	 * @auth
+//	 * @deprecated
+//	 */
+//	exports.GET = function(request) { ... }
+//
+// The first line is the summary (or @summary), the rest up to the first tag
+// the description. @param is a path param when the route has one by that
+// name, a query param otherwise; [name] is optional, [name=x] defaults to x.
+func ParseAnnotations(source string) map[string]Operation {
+	ops := make(map[string]Operation)
+	for _, m := range docCommentRe.FindAllStringSubmatch(source, -1) {
+		method := m[2] + m[3] + m[4] + m[5]
+		if !validMethod(method) {
+			continue
+		}
+		ops[method] = parseComment(m[1])
+	}
+	return ops
+}
+
+// parseComment reads one doc comment's text and tags
+func parseComment(comment string) Operation {
+	var op Operation
+	var text []string
+	var tags []string // "@tag rest", continuation lines joined on
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		switch {
+		case strings.HasPrefix(line, "@"):
+			tags = append(tags, line)
+		case len(tags) > 0:
+			if line != "" {
+				tags[len(tags)-1] += " " + line
+			}
+		default:
+			text = append(text, line)
+		}
+	}
+
+	// Summary, blank lines, then the description
+	for len(text) > 0 && text[0] == "" {
+		text = text[1:]
+	}
+	if len(text) > 0 {
+		op.Summary, text = text[0], text[1:]
+	}
+	op.Description = strings.TrimSpace(strings.Join(text, "\n"))
+
+	for _, tag := range tags {
+		name, rest, _ := strings.Cut(tag, " ")
+		rest = strings.TrimSpace(rest)
+		switch name {
+		case "@summary":
+			op.Summary = rest
+		case "@description":
+			op.Description = rest
+		case "@tag", "@tags":
+			for _, t := range strings.Split(rest, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					op.Tags = append(op.Tags, t)
+				}
+			}
+		case "@param", "@query", "@header":
+			if p, ok := parseParam(rest); ok {
+				switch name {
+				case "@query":
+					p.In = "query"
+				case "@header":
+					p.In = "header"
+				}
+				op.Params = append(op.Params, p)
+			}
+		case "@body":
+			t, desc := splitType(rest)
+			op.Body = &Body{Type: t, Description: desc}
+		case "@returns", "@return":
+			// A type is allowed, as in JSDoc, but only the text is kept
+			_, desc := splitType(rest)
+			op.addResponse("200", desc)
+		case "@response":
+			status, desc, _ := strings.Cut(rest, " ")
+			if statusRe.MatchString(status) {
+				op.addResponse(status, strings.TrimSpace(desc))
+			}
+		case "@auth":
+			op.Auth = true
+		case "@deprecated":
+			op.Deprecated = true
+		}
+	}
+	return op
+}
+
+// parseParam reads "{type} [name=default] - description"
+func parseParam(s string) (Param, bool) {
+	m := typedTagRe.FindStringSubmatch(s)
+	if m == nil || m[2] == "" {
+		return Param{}, false
+	}
+	p := Param{Type: strings.ToLower(m[1]), Description: strings.TrimSpace(m[3]), Required: true}
+	name := m[2]
+	if inner, ok := strings.CutPrefix(name, "["); ok {
+		name, p.Required = strings.TrimSuffix(inner, "]"), false
+		if n, def, ok := strings.Cut(name, "="); ok {
+			name, p.Default = n, def
+		}
+	}
+	p.Name = strings.TrimSpace(name)
+	return p, p.Name != ""
+}
+
+// splitType reads "{type} - description"
+func splitType(s string) (typ, desc string) {
+	if inner, ok := strings.CutPrefix(s, "{"); ok {
+		if t, after, ok := strings.Cut(inner, "}"); ok {
+			typ, s = strings.ToLower(strings.TrimSpace(t)), after
+		}
+	}
+	s = strings.TrimSpace(s)
+	return typ, strings.TrimSpace(strings.TrimPrefix(s, "- "))
+}
+
+// addResponse documents a status, described by its status text when desc
+// is empty
+func (op *Operation) addResponse(status, desc string) {
+	if op.Responses == nil {
+		op.Responses = make(map[string]string)
+	}
+	if desc == "" {
+		code, _ := strconv.Atoi(status)
+		desc = http.StatusText(code)
+	}
+	if desc == "" {
+		desc = "Response"
+	}
+	op.Responses[status] = desc
+}
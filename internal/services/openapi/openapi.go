@@ -0,0 +1,364 @@
+// Package openapi documents an app's API as an OpenAPI 3.1 spec: its
+// file-based routes and the methods they export, described by JSDoc-style
+// comments on the handlers and by notes in manifest.json. It also renders
+// a spec as a browsable page.
+package openapi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Version is the OpenAPI version of the specs built here
+const Version = "3.1.0"
+
+// Methods lists the HTTP methods an operation can have, in the order docs
+// show them
+var Methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+
+// Param is a parameter an operation takes
+type Param struct {
+	Name        string `json:"name"`
+	In          string `json:"in,omitempty"`   // path, query (default) or header
+	Type        string `json:"type,omitempty"` // string (default), number, integer, boolean, array or object; "string[]" for arrays of a type
+	Required    bool   `json:"required,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Body is an operation's request body
+type Body struct {
+	Type        string `json:"type,omitempty"`         // object (default), array or string
+	ContentType string `json:"content_type,omitempty"` // application/json (default)
+	Description string `json:"description,omitempty"`
+}
+
+// Operation describes one method of a route, as JSDoc tags on its handler
+// or as notes in manifest.json:
+//
+//	"GET /api/items/:id": {"summary": "Get an item", "responses": {"404": "No such item"}}
+type Operation struct {
+	Summary     string            `json:"summary,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Params      []Param           `json:"params,omitempty"` // Path params are documented whether listed or not
+	Body        *Body             `json:"body,omitempty"`
+	Responses   map[string]string `json:"responses,omitempty"` // Status -> description
+	Auth        bool              `json:"auth,omitempty"`      // Needs a signed-in user
+	Deprecated  bool              `json:"deprecated,omitempty"`
+}
+
+// Route is a file-based route to document
+type Route struct {
+	Pattern string   // e.g. /api/users/:id or /api/files/*path
+	Methods []string // Methods its file exports
+	Source  string   // The file's source, for annotations
+}
+
+// Info titles a spec
+type Info struct {
+	Title       string
+	Version     string // Default "1.0.0"
+	Description string
+}
+
+var (
+	paramTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true, "array": true, "object": true}
+	paramIns   = map[string]bool{"path": true, "query": true, "header": true}
+	statusRe   = regexp.MustCompile(`^([1-5][0-9][0-9]|[1-5]XX|default)$`)
+)
+
+// ParseRouteKey splits a manifest note's key, "GET /api/items/:id", into
+// its method and route pattern
+func ParseRouteKey(key string) (method, pattern string, err error) {
+	method, pattern, ok := strings.Cut(strings.TrimSpace(key), " ")
+	method, pattern = strings.ToUpper(method), strings.TrimSpace(pattern)
+	if !ok || !validMethod(method) || !strings.HasPrefix(pattern, "/") {
+		return "", "", fmt.Errorf("docs: route %q must be a method and a path, e.g. \"GET /api/items/:id\"", key)
+	}
+	return method, pattern, nil
+}
+
+// Validate checks an operation's params, body and responses
+func (op Operation) Validate() error {
+	for _, p := range op.Params {
+		if p.Name == "" {
+			return fmt.Errorf("params need a name")
+		}
+		if p.In != "" && !paramIns[p.In] {
+			return fmt.Errorf("param %q: in must be path, query or header", p.Name)
+		}
+		if p.Type != "" && !paramTypes[strings.TrimSuffix(p.Type, "[]")] {
+			return fmt.Errorf("param %q: unknown type %q", p.Name, p.Type)
+		}
+	}
+	if op.Body != nil && op.Body.Type != "" && !paramTypes[strings.TrimSuffix(op.Body.Type, "[]")] {
+		return fmt.Errorf("body: unknown type %q", op.Body.Type)
+	}
+	for status := range op.Responses {
+		if !statusRe.MatchString(status) {
+			return fmt.Errorf("response %q must be an HTTP status, e.g. \"404\"", status)
+		}
+	}
+	return nil
+}
+
+func validMethod(method string) bool {
+	for _, m := range Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Build returns the spec for routes: each method a route's file exports,
+// described by its annotations and then by notes, keyed like
+// "GET /api/items/:id". Notes for routes no file declares, such as ones
+// api/main.js answers, are documented too.
+func Build(info Info, routes []Route, notes map[string]Operation) *Spec {
+	spec := &Spec{
+		OpenAPI: Version,
+		Info:    SpecInfo{Title: info.Title, Version: info.Version, Description: info.Description},
+		Paths:   make(map[string]PathItem),
+	}
+	if spec.Info.Version == "" {
+		spec.Info.Version = "1.0.0"
+	}
+
+	// Notes by pattern, so unclaimed ones can be added after
+	noted := make(map[string]map[string]Operation)
+	for key, op := range notes {
+		method, pattern, err := ParseRouteKey(key)
+		if err != nil {
+			continue
+		}
+		if noted[pattern] == nil {
+			noted[pattern] = make(map[string]Operation)
+		}
+		noted[pattern][method] = op
+	}
+
+	for _, route := range routes {
+		annotated := ParseAnnotations(route.Source)
+		for _, method := range route.Methods {
+			op := merge(annotated[method], noted[route.Pattern][method])
+			delete(noted[route.Pattern], method)
+			spec.add(method, route.Pattern, op)
+		}
+	}
+	for pattern, ops := range noted {
+		for method, op := range ops {
+			spec.add(method, pattern, op)
+		}
+	}
+	return spec
+}
+
+// merge lays notes over annotations: fields notes set win, params and
+// responses are combined
+func merge(base, over Operation) Operation {
+	if over.Summary != "" {
+		base.Summary = over.Summary
+	}
+	if over.Description != "" {
+		base.Description = over.Description
+	}
+	if len(over.Tags) > 0 {
+		base.Tags = over.Tags
+	}
+	params := append([]Param{}, base.Params...)
+	for _, p := range over.Params {
+		replaced := false
+		for i := range params {
+			if params[i].Name == p.Name && params[i].In == p.In {
+				params[i], replaced = p, true
+			}
+		}
+		if !replaced {
+			params = append(params, p)
+		}
+	}
+	base.Params = params
+	if over.Body != nil {
+		base.Body = over.Body
+	}
+	if len(over.Responses) > 0 {
+		responses := make(map[string]string, len(base.Responses)+len(over.Responses))
+		for status, desc := range base.Responses {
+			responses[status] = desc
+		}
+		for status, desc := range over.Responses {
+			responses[status] = desc
+		}
+		base.Responses = responses
+	}
+	base.Auth = base.Auth || over.Auth
+	base.Deprecated = base.Deprecated || over.Deprecated
+	return base
+}
+
+// pathParams returns the params of a route pattern: ":id" is one segment,
+// "*path" one or more
+func pathParams(pattern string) (names []string, catchAll map[string]bool) {
+	catchAll = make(map[string]bool)
+	for _, seg := range strings.Split(pattern, "/") {
+		switch {
+		case strings.HasPrefix(seg, ":") && len(seg) > 1:
+			names = append(names, seg[1:])
+		case strings.HasPrefix(seg, "*") && len(seg) > 1:
+			names = append(names, seg[1:])
+			catchAll[seg[1:]] = true
+		}
+	}
+	return names, catchAll
+}
+
+// specPath turns /api/users/:id into /api/users/{id}
+func specPath(pattern string) string {
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		if (strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*")) && len(seg) > 1 {
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// add documents one method of a route
+func (s *Spec) add(method, pattern string, op Operation) {
+	path := specPath(pattern)
+	out := &SpecOperation{
+		OperationID: operationID(method, pattern),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Deprecated:  op.Deprecated,
+		Responses:   make(map[string]SpecResponse),
+	}
+
+	// Path params first, in route order, then the rest as listed
+	names, catchAll := pathParams(pattern)
+	inPath := make(map[string]bool)
+	for _, name := range names {
+		inPath[name] = true
+		p := Param{Name: name, In: "path"}
+		for _, listed := range op.Params {
+			if listed.Name == name && (listed.In == "" || listed.In == "path") {
+				p = listed
+			}
+		}
+		if p.Description == "" && catchAll[name] {
+			p.Description = "One or more path segments"
+		}
+		p.In, p.Required = "path", true
+		out.Parameters = append(out.Parameters, specParam(p))
+	}
+	for _, p := range op.Params {
+		if p.In == "path" || (p.In == "" && inPath[p.Name]) {
+			continue
+		}
+		if p.In == "" {
+			p.In = "query"
+		}
+		out.Parameters = append(out.Parameters, specParam(p))
+	}
+
+	if op.Body != nil {
+		contentType := op.Body.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		bodyType := op.Body.Type
+		if bodyType == "" {
+			bodyType = "object"
+		}
+		out.RequestBody = &SpecRequestBody{
+			Description: op.Body.Description,
+			Content:     map[string]MediaType{contentType: {Schema: schemaFor(bodyType)}},
+		}
+	}
+
+	for status, desc := range op.Responses {
+		out.Responses[status] = SpecResponse{Description: desc}
+	}
+	if len(out.Responses) == 0 {
+		out.Responses["200"] = SpecResponse{Description: "OK"}
+	}
+
+	if op.Auth {
+		out.Security = []map[string][]string{{SessionScheme: {}}}
+		s.Components = &Components{SecuritySchemes: map[string]SecurityScheme{
+			SessionScheme: {
+				Type:        "apiKey",
+				In:          "cookie",
+				Name:        SessionCookie,
+				Description: "Signed in with the app's sign-in (fazt.app.auth)",
+			},
+		}}
+		if _, ok := out.Responses["401"]; !ok {
+			out.Responses["401"] = SpecResponse{Description: "Not signed in"}
+		}
+	}
+
+	if s.Paths[path] == nil {
+		s.Paths[path] = make(PathItem)
+	}
+	s.Paths[path][strings.ToLower(method)] = out
+}
+
+func specParam(p Param) SpecParameter {
+	schema := schemaFor(p.Type)
+	if p.Default != "" {
+		schema.Default = p.Default
+	}
+	return SpecParameter{Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: schema}
+}
+
+// schemaFor turns a type ("number", "string[]") into a schema
+func schemaFor(t string) Schema {
+	if item, ok := strings.CutSuffix(t, "[]"); ok {
+		items := schemaFor(item)
+		return Schema{Type: "array", Items: &items}
+	}
+	if !paramTypes[t] {
+		t = "string"
+	}
+	return Schema{Type: t}
+}
+
+var operationIDRe = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// operationID names an operation after its method and path:
+// GET /api/users/:id -> get_api_users_id
+func operationID(method, pattern string) string {
+	return strings.ToLower(method) + "_" + strings.Trim(operationIDRe.ReplaceAllString(pattern, "_"), "_")
+}
+
+// Entry is one operation of a spec, for listing
+type Entry struct {
+	Method string
+	Path   string
+	*SpecOperation
+}
+
+// Entries lists a spec's operations by path, then method
+func (s *Spec) Entries() []Entry {
+	paths := make([]string, 0, len(s.Paths))
+	for p := range s.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var entries []Entry
+	for _, p := range paths {
+		for _, m := range Methods {
+			if op := s.Paths[p][strings.ToLower(m)]; op != nil {
+				entries = append(entries, Entry{Method: m, Path: p, SpecOperation: op})
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,195 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+)
+
+const itemSource = `
+var db = require("./_lib/db.js");
+
+/**
+ * Get an item
+ *
+ * Looks the item up by ID.
+ * Drafts are left out.
+ * @tag items
+ * @param {string} id - Item ID
+ * @query {boolean} [full=false] - Include its history,
+ *   newest first
+ * @returns {object} The item
+ * @response 404 No such item
+ */
+exports.GET = function(request) {};
+
+/** Helper, not a handler */
+function check() {}
+
+/**
+ * Update an item
+ * @body {object} - The fields to change
+ * @response 204
+ * @auth
+ * @deprecated
+ */
+exports["PUT"] = function(request) {};
+
+exports.DELETE = function(request) {};
+`
+
+func TestParseAnnotations(t *testing.T) {
+	ops := ParseAnnotations(itemSource)
+	if len(ops) != 2 {
+		t.Fatalf("Expected GET and PUT, got %+v", ops)
+	}
+
+	get := ops["GET"]
+	if get.Summary != "Get an item" || get.Description != "Looks the item up by ID.\nDrafts are left out." {
+		t.Errorf("GET summary/description = %q / %q", get.Summary, get.Description)
+	}
+	if len(get.Tags) != 1 || get.Tags[0] != "items" {
+		t.Errorf("GET tags = %v", get.Tags)
+	}
+	if len(get.Params) != 2 {
+		t.Fatalf("GET params = %+v", get.Params)
+	}
+	if p := get.Params[0]; p.Name != "id" || p.Type != "string" || !p.Required || p.In != "" || p.Description != "Item ID" {
+		t.Errorf("id param = %+v", p)
+	}
+	if p := get.Params[1]; p.Name != "full" || p.In != "query" || p.Required || p.Default != "false" || p.Description != "Include its history, newest first" {
+		t.Errorf("full param = %+v", p)
+	}
+	if get.Responses["200"] != "The item" || get.Responses["404"] != "No such item" {
+		t.Errorf("GET responses = %v", get.Responses)
+	}
+
+	put := ops["PUT"]
+	if put.Body == nil || put.Body.Type != "object" || put.Body.Description != "The fields to change" {
+		t.Errorf("PUT body = %+v", put.Body)
+	}
+	if put.Responses["204"] != "No Content" || !put.Auth || !put.Deprecated {
+		t.Errorf("PUT = %+v", put)
+	}
+}
+
+func TestParseAnnotations_ESM(t *testing.T) {
+	ops := ParseAnnotations(`
+/** List things */
+export async function GET(request) {}
+
+/** Make a thing */
+export const POST = (request) => {}`)
+	if ops["GET"].Summary != "List things" || ops["POST"].Summary != "Make a thing" {
+		t.Errorf("ops = %+v", ops)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	routes := []Route{
+		{Pattern: "/api/items/:id", Methods: []string{"GET", "PUT", "DELETE"}, Source: itemSource},
+		{Pattern: "/api/files/*path", Methods: []string{"GET"}},
+	}
+	notes := map[string]Operation{
+		"GET /api/items/:id": {Summary: "Fetch an item", Responses: map[string]string{"410": "Gone for good"}},
+		"POST /api/search":   {Summary: "Search", Body: &Body{Description: "The query"}},
+		"bogus":              {Summary: "Ignored"},
+	}
+	spec := Build(Info{Title: "Shop"}, routes, notes)
+
+	if spec.OpenAPI != Version || spec.Info.Title != "Shop" || spec.Info.Version != "1.0.0" {
+		t.Errorf("info = %+v", spec.Info)
+	}
+	get := spec.Paths["/api/items/{id}"]["get"]
+	if get == nil {
+		t.Fatalf("Expected GET /api/items/{id}, got %+v", spec.Paths)
+	}
+	// Notes win; responses are combined
+	if get.Summary != "Fetch an item" || get.OperationID != "get_api_items_id" {
+		t.Errorf("GET = %+v", get)
+	}
+	if len(get.Responses) != 3 || get.Responses["410"].Description != "Gone for good" {
+		t.Errorf("GET responses = %+v", get.Responses)
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0].In != "path" || !get.Parameters[0].Required || get.Parameters[1].Schema.Default != "false" {
+		t.Errorf("GET params = %+v", get.Parameters)
+	}
+
+	put := spec.Paths["/api/items/{id}"]["put"]
+	if put.RequestBody == nil || put.RequestBody.Content["application/json"].Schema.Type != "object" {
+		t.Errorf("PUT body = %+v", put.RequestBody)
+	}
+	if len(put.Security) != 1 || put.Responses["401"].Description == "" || spec.Components == nil {
+		t.Errorf("Expected PUT to need a session, got %+v", put)
+	}
+
+	// Unannotated methods are still listed, with path params
+	if del := spec.Paths["/api/items/{id}"]["delete"]; del == nil || del.Responses["200"].Description != "OK" {
+		t.Errorf("DELETE = %+v", del)
+	}
+	files := spec.Paths["/api/files/{path}"]["get"]
+	if files == nil || files.Parameters[0].Description != "One or more path segments" {
+		t.Errorf("catch-all = %+v", files)
+	}
+
+	// Notes for routes without a file, as api/main.js answers
+	if search := spec.Paths["/api/search"]["post"]; search == nil || search.Summary != "Search" {
+		t.Errorf("Expected the noted POST /api/search, got %+v", spec.Paths["/api/search"])
+	}
+	if len(spec.Paths) != 3 {
+		t.Errorf("paths = %v", spec.Paths)
+	}
+}
+
+func TestParseRouteKeyAndValidate(t *testing.T) {
+	if m, p, err := ParseRouteKey("get  /api/items/:id"); err != nil || m != "GET" || p != "/api/items/:id" {
+		t.Errorf("ParseRouteKey = %q, %q, %v", m, p, err)
+	}
+	for _, key := range []string{"/api/items", "FETCH /api/items", "GET api/items"} {
+		if _, _, err := ParseRouteKey(key); err == nil {
+			t.Errorf("%q: expected an error", key)
+		}
+	}
+
+	bad := []Operation{
+		{Params: []Param{{Name: "x", In: "cookie"}}},
+		{Params: []Param{{Name: "x", Type: "date"}}},
+		{Responses: map[string]string{"ok": "fine"}},
+	}
+	for _, op := range bad {
+		if err := op.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", op)
+		}
+	}
+	ok := Operation{Params: []Param{{Name: "tags", Type: "string[]"}}, Responses: map[string]string{"4XX": "Client error"}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestRenderPage(t *testing.T) {
+	spec := Build(Info{Title: "Shop <API>"}, []Route{
+		{Pattern: "/api/items/:id", Methods: []string{"GET", "PUT"}, Source: itemSource},
+		{Pattern: "/api/health", Methods: []string{"GET"}},
+	}, nil)
+	page, err := RenderPage(spec, "/_docs/openapi.json")
+	if err != nil {
+		t.Fatalf("RenderPage: %v", err)
+	}
+	html := string(page)
+	for _, want := range []string{
+		"Shop &lt;API&gt;",
+		`href="/_docs/openapi.json"`,
+		"<h2>items</h2>",
+		"/api/items/{id}",
+		"Sign-in required",
+		"No such item",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %q in the page", want)
+		}
+	}
+	// Untagged routes come first
+	if strings.Index(html, "<h2>Endpoints</h2>") > strings.Index(html, "<h2>items</h2>") {
+		t.Error("Expected untagged routes before tagged ones")
+	}
+}
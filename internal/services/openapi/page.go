@@ -0,0 +1,125 @@
+package openapi
+
+import (
+	"bytes"
+	"html/template"
+	"sort"
+	"strings"
+)
+
+// pageTemplate lays a spec out on one self-contained page: no scripts, so
+// it works offline and under any CSP
+var pageTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"lower": strings.ToLower,
+	"statuses": func(responses map[string]SpecResponse) []string {
+		out := make([]string, 0, len(responses))
+		for status := range responses {
+			out = append(out, status)
+		}
+		sort.Strings(out)
+		return out
+	},
+	"schema": func(s Schema) string {
+		if s.Type == "array" && s.Items != nil {
+			return s.Items.Type + "[]"
+		}
+		return s.Type
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Spec.Info.Title}} API</title>
+<style>
+body{font:15px/1.5 system-ui,sans-serif;margin:0 auto;max-width:960px;padding:2rem 1rem;color:#1f2328}
+h1{margin:0}h2{margin-top:2.5rem;border-bottom:1px solid #d0d7de;padding-bottom:.3rem}
+.meta{color:#59636e}.op{border:1px solid #d0d7de;border-radius:6px;margin:1rem 0;padding:.75rem 1rem}
+.op.deprecated{opacity:.6}.line{display:flex;gap:.75rem;align-items:baseline;flex-wrap:wrap}
+.method{font:bold 12px monospace;padding:.15rem .5rem;border-radius:4px;color:#fff;background:#59636e}
+.get{background:#1a7f37}.post{background:#0969da}.put,.patch{background:#9a6700}.delete{background:#cf222e}
+code,.path{font-family:ui-monospace,monospace}.path{font-weight:600}
+table{border-collapse:collapse;width:100%;margin:.5rem 0}td,th{text-align:left;padding:.3rem .5rem;border-top:1px solid #d0d7de;vertical-align:top}
+.badge{font-size:12px;border:1px solid #d0d7de;border-radius:1em;padding:0 .5rem}.desc{white-space:pre-line}
+</style>
+</head>
+<body>
+<h1>{{.Spec.Info.Title}}</h1>
+<p class="meta">Version {{.Spec.Info.Version}} · <a href="{{.SpecURL}}">OpenAPI spec</a></p>
+{{with .Spec.Info.Description}}<p class="desc">{{.}}</p>{{end}}
+{{range .Groups}}
+<h2>{{.Name}}</h2>
+{{range .Entries}}
+<section class="op{{if .Deprecated}} deprecated{{end}}" id="{{.OperationID}}">
+<div class="line">
+<span class="method {{lower .Method}}">{{.Method}}</span>
+<span class="path">{{.Path}}</span>
+{{with .Summary}}<span>{{.}}</span>{{end}}
+{{if .Security}}<span class="badge">Sign-in required</span>{{end}}
+{{if .Deprecated}}<span class="badge">Deprecated</span>{{end}}
+</div>
+{{with .Description}}<p class="desc">{{.}}</p>{{end}}
+{{if .Parameters}}
+<table>
+<tr><th>Parameter</th><th>In</th><th>Type</th><th>Description</th></tr>
+{{range .Parameters}}<tr><td><code>{{.Name}}</code>{{if .Required}} *{{end}}</td><td>{{.In}}</td><td>{{schema .Schema}}{{with .Schema.Default}} = {{.}}{{end}}</td><td>{{.Description}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{with .RequestBody}}
+<p><strong>Body</strong>{{range $type, $media := .Content}} <code>{{$type}}</code> {{schema $media.Schema}}{{end}}{{with .Description}}: {{.}}{{end}}</p>
+{{end}}
+<table>
+<tr><th>Status</th><th>Response</th></tr>
+{{$responses := .Responses}}{{range statuses .Responses}}<tr><td><code>{{.}}</code></td><td>{{(index $responses .).Description}}</td></tr>
+{{end}}
+</table>
+</section>
+{{end}}
+{{else}}
+<p>No API routes yet.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// pageGroup is the operations shown under one heading
+type pageGroup struct {
+	Name    string
+	Entries []Entry
+}
+
+// RenderPage renders a spec as an HTML page, operations grouped by their
+// first tag (untagged ones first, under "Endpoints"). specURL is linked for
+// download.
+func RenderPage(spec *Spec, specURL string) ([]byte, error) {
+	var groups []pageGroup
+	index := make(map[string]int)
+	for _, e := range spec.Entries() {
+		name := "Endpoints"
+		if len(e.Tags) > 0 {
+			name = e.Tags[0]
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, pageGroup{Name: name})
+		}
+		groups[i].Entries = append(groups[i].Entries, e)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Name == "Endpoints") != (groups[j].Name == "Endpoints") {
+			return groups[i].Name == "Endpoints"
+		}
+		return groups[i].Name < groups[j].Name
+	})
+
+	var buf bytes.Buffer
+	err := pageTemplate.Execute(&buf, map[string]interface{}{
+		"Spec":    spec,
+		"SpecURL": specURL,
+		"Groups":  groups,
+	})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,84 @@
+package openapi
+
+// The security scheme operations marked auth require: the app end-user
+// session cookie (auth.AppSessionCookieName)
+const (
+	SessionScheme = "appSession"
+	SessionCookie = "fazt_app_session"
+)
+
+// Spec is an OpenAPI document
+type Spec struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       SpecInfo            `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// SpecInfo is a spec's title block
+type SpecInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem is a path's operations, by lowercase method
+type PathItem map[string]*SpecOperation
+
+// SpecOperation is one method of a path
+type SpecOperation struct {
+	OperationID string                  `json:"operationId"`
+	Summary     string                  `json:"summary,omitempty"`
+	Description string                  `json:"description,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	Parameters  []SpecParameter         `json:"parameters,omitempty"`
+	RequestBody *SpecRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]SpecResponse `json:"responses"`
+	Security    []map[string][]string   `json:"security,omitempty"`
+	Deprecated  bool                    `json:"deprecated,omitempty"`
+}
+
+// SpecParameter is a path, query or header parameter
+type SpecParameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// SpecRequestBody is what an operation accepts, by content type
+type SpecRequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType holds the schema of one content type
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Schema is the (shallow) JSON schema of a value
+type Schema struct {
+	Type    string      `json:"type,omitempty"`
+	Items   *Schema     `json:"items,omitempty"`
+	Default interface{} `json:"default,omitempty"`
+}
+
+// SpecResponse is one status an operation answers with
+type SpecResponse struct {
+	Description string `json:"description"`
+}
+
+// Components holds the security schemes operations refer to
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is how a client authenticates
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
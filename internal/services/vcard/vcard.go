@@ -0,0 +1,261 @@
+// Package vcard builds vCard 3.0 (RFC 2426) contact cards, the version
+// phones import most reliably, from plain contact records.
+package vcard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/services/contentline"
+)
+
+// maxValues caps the entries of one kind (emails, phones, ...) on a card
+const maxValues = 20
+
+var birthdayRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// Value is an email, phone number or URL with an optional type, e.g.
+// "work", "home" or "cell"
+type Value struct {
+	Type  string
+	Value string
+}
+
+// Address is a postal address
+type Address struct {
+	Type       string
+	Street     string
+	City       string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+// Card is a contact
+type Card struct {
+	Name      string // Full name, as displayed
+	FirstName string
+	LastName  string
+	Org       string
+	Title     string
+	Emails    []Value
+	Phones    []Value
+	URLs      []Value
+	Addresses []Address
+	Birthday  string // YYYY-MM-DD
+	Photo     string // Image URL
+	Note      string
+}
+
+// ParseCard builds a card from a record, as apps pass them to
+// fazt.util.vcard:
+//
+//	{ name, firstName, lastName, org, title, email, phone, url, address, birthday, photo, note }
+//
+// email, phone and url may be a string, an array of strings, or an array of
+// { type, value } objects; address a street string, an object of street,
+// city, region, postalCode, country and type, or an array of them. Without
+// firstName and lastName, name is split at its last space.
+func ParseCard(rec map[string]interface{}) (Card, error) {
+	c := Card{
+		Name:      stringField(rec, "name"),
+		FirstName: stringField(rec, "firstName"),
+		LastName:  stringField(rec, "lastName"),
+		Org:       stringField(rec, "org"),
+		Title:     stringField(rec, "title"),
+		Birthday:  stringField(rec, "birthday"),
+		Photo:     stringField(rec, "photo"),
+		Note:      stringField(rec, "note"),
+	}
+	if c.Name == "" {
+		c.Name = strings.TrimSpace(c.FirstName + " " + c.LastName)
+	}
+	if c.Name == "" {
+		return c, fmt.Errorf("contact needs a name")
+	}
+	if c.FirstName == "" && c.LastName == "" {
+		if i := strings.LastIndex(c.Name, " "); i > 0 {
+			c.FirstName, c.LastName = c.Name[:i], c.Name[i+1:]
+		} else {
+			c.FirstName = c.Name
+		}
+	}
+	if c.Birthday != "" && !birthdayRe.MatchString(c.Birthday) {
+		return c, fmt.Errorf("birthday must be YYYY-MM-DD")
+	}
+	if c.Photo != "" && !strings.HasPrefix(c.Photo, "https://") && !strings.HasPrefix(c.Photo, "http://") {
+		return c, fmt.Errorf("photo must be an http(s) URL")
+	}
+
+	var err error
+	if c.Emails, err = parseValues(rec["email"], "email"); err != nil {
+		return c, err
+	}
+	if c.Phones, err = parseValues(rec["phone"], "phone"); err != nil {
+		return c, err
+	}
+	if c.URLs, err = parseValues(rec["url"], "url"); err != nil {
+		return c, err
+	}
+	if c.Addresses, err = parseAddresses(rec["address"]); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// Render returns the card as a vCard document
+func (c *Card) Render() string {
+	var b strings.Builder
+	line := func(s string) {
+		contentline.WriteFolded(&b, s)
+	}
+
+	line("BEGIN:VCARD")
+	line("VERSION:3.0")
+	line("N:" + contentline.EscapeText(c.LastName) + ";" + contentline.EscapeText(c.FirstName) + ";;;")
+	line("FN:" + contentline.EscapeText(c.Name))
+	if c.Org != "" {
+		line("ORG:" + contentline.EscapeText(c.Org))
+	}
+	if c.Title != "" {
+		line("TITLE:" + contentline.EscapeText(c.Title))
+	}
+	for _, v := range c.Emails {
+		line("EMAIL" + typeParam("INTERNET", v.Type) + ":" + contentline.EscapeText(v.Value))
+	}
+	for _, v := range c.Phones {
+		line("TEL" + typeParam("", v.Type) + ":" + contentline.EscapeText(v.Value))
+	}
+	for _, v := range c.URLs {
+		line("URL" + typeParam("", v.Type) + ":" + v.Value)
+	}
+	for _, a := range c.Addresses {
+		line("ADR" + typeParam("", a.Type) + ":;;" + strings.Join([]string{
+			contentline.EscapeText(a.Street), contentline.EscapeText(a.City), contentline.EscapeText(a.Region),
+			contentline.EscapeText(a.PostalCode), contentline.EscapeText(a.Country),
+		}, ";"))
+	}
+	if c.Birthday != "" {
+		line("BDAY:" + c.Birthday)
+	}
+	if c.Photo != "" {
+		line("PHOTO;VALUE=URI:" + c.Photo)
+	}
+	if c.Note != "" {
+		line("NOTE:" + contentline.EscapeText(c.Note))
+	}
+	line("END:VCARD")
+	return b.String()
+}
+
+// parseValues reads a string, an array of strings, or an array of
+// { type, value } objects
+func parseValues(v interface{}, field string) ([]Value, error) {
+	var items []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	default:
+		items = []interface{}{v}
+	}
+	if len(items) > maxValues {
+		return nil, fmt.Errorf("%s: at most %d entries", field, maxValues)
+	}
+
+	values := make([]Value, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			if item != "" {
+				values = append(values, Value{Value: item})
+			}
+		case map[string]interface{}:
+			val := stringField(item, "value")
+			if val == "" {
+				return nil, fmt.Errorf("%s: each entry needs a value", field)
+			}
+			values = append(values, Value{Type: stringField(item, "type"), Value: val})
+		default:
+			return nil, fmt.Errorf("%s must be a string, or an array of strings or { type, value } objects", field)
+		}
+	}
+	return values, nil
+}
+
+// parseAddresses reads a street string, an address object, or an array of
+// either
+func parseAddresses(v interface{}) ([]Address, error) {
+	var items []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		items = v
+	default:
+		items = []interface{}{v}
+	}
+	if len(items) > maxValues {
+		return nil, fmt.Errorf("address: at most %d entries", maxValues)
+	}
+
+	addrs := make([]Address, 0, len(items))
+	for _, item := range items {
+		switch item := item.(type) {
+		case string:
+			if item != "" {
+				addrs = append(addrs, Address{Street: item})
+			}
+		case map[string]interface{}:
+			addrs = append(addrs, Address{
+				Type:       stringField(item, "type"),
+				Street:     stringField(item, "street"),
+				City:       stringField(item, "city"),
+				Region:     stringField(item, "region"),
+				PostalCode: stringField(item, "postalCode"),
+				Country:    stringField(item, "country"),
+			})
+		default:
+			return nil, fmt.Errorf("address must be a string, an object, or an array of them")
+		}
+	}
+	return addrs, nil
+}
+
+// typeParam renders the TYPE parameter from a default and an app's type,
+// keeping only letters so a type can't inject parameters
+func typeParam(base, typ string) string {
+	types := []string{}
+	if base != "" {
+		types = append(types, base)
+	}
+	typ = strings.ToUpper(strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			return r
+		}
+		return -1
+	}, typ))
+	if typ == "MOBILE" {
+		typ = "CELL"
+	}
+	if typ != "" {
+		types = append(types, typ)
+	}
+	if len(types) == 0 {
+		return ""
+	}
+	return ";TYPE=" + strings.Join(types, ",")
+}
+
+// stringField returns a record's string or number field
+func stringField(rec map[string]interface{}, key string) string {
+	switch v := rec[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64, int64, int:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package vcard
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/services/contentline"
+)
+
+func TestParseCard(t *testing.T) {
+	c, err := ParseCard(map[string]interface{}{
+		"name":  "Ada King Lovelace",
+		"org":   "Analytical Engines, Ltd",
+		"email": "ada@example.com",
+		"phone": []interface{}{
+			map[string]interface{}{"type": "mobile", "value": "+44 20 7946 0000"},
+			"+44 20 7946 0001",
+		},
+		"address": map[string]interface{}{"street": "12 St James's Sq", "city": "London", "country": "UK"},
+	})
+	if err != nil {
+		t.Fatalf("ParseCard failed: %v", err)
+	}
+	if c.FirstName != "Ada King" || c.LastName != "Lovelace" || len(c.Emails) != 1 || len(c.Phones) != 2 || len(c.Addresses) != 1 {
+		t.Errorf("Unexpected card: %+v", c)
+	}
+
+	invalid := []map[string]interface{}{
+		{"org": "No name"},
+		{"name": "A", "birthday": "12/10/1815"},
+		{"name": "A", "photo": "javascript:alert(1)"},
+		{"name": "A", "email": []interface{}{map[string]interface{}{"type": "work"}}},
+		{"name": "A", "phone": true},
+	}
+	for _, rec := range invalid {
+		if _, err := ParseCard(rec); err == nil {
+			t.Errorf("Expected %v to be rejected", rec)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c := Card{
+		Name:      "Ada Lovelace",
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+		Org:       "Engines; Ltd",
+		Emails:    []Value{{Value: "ada@example.com"}},
+		Phones:    []Value{{Type: "cell;X-EVIL=1", Value: "+44 20 7946 0000"}},
+		URLs:      []Value{{Value: "https://ada.example.com"}},
+		Addresses: []Address{{Type: "work", Street: "12 Sq", City: "London"}},
+		Birthday:  "1815-12-10",
+		Note:      "Line one\nLine two",
+	}
+	out := c.Render()
+
+	for _, want := range []string{
+		"BEGIN:VCARD\r\nVERSION:3.0\r\n",
+		"N:Lovelace;Ada;;;\r\n",
+		"FN:Ada Lovelace\r\n",
+		"ORG:Engines\\; Ltd\r\n",
+		"EMAIL;TYPE=INTERNET:ada@example.com\r\n",
+		"TEL;TYPE=CELLXEVIL:+44 20 7946 0000\r\n",
+		"URL:https://ada.example.com\r\n",
+		"ADR;TYPE=WORK:;;12 Sq;London;;;\r\n",
+		"BDAY:1815-12-10\r\n",
+		"NOTE:Line one\\nLine two\r\n",
+		"END:VCARD\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	c = Card{Name: "Long", FirstName: "Long", Note: strings.Repeat("ü", 80)}
+	for _, line := range strings.Split(c.Render(), "\r\n") {
+		if len(line) > contentline.LineLimit {
+			t.Errorf("Line longer than %d octets: %q", contentline.LineLimit, line)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/sandbox"
+)
+
+// MaxInstances caps the modules one execution can load
+const MaxInstances = 4
+
+// InjectWasmNamespace adds fazt.wasm.load() to a Goja VM. load reads an app
+// file by path; instances are closed when ctx is done.
+// Must be called after the fazt object already exists on the VM.
+func InjectWasmNamespace(vm *goja.Runtime, ctx context.Context, load func(path string) (string, error)) error {
+	loaded := make(map[string]*goja.Object)
+	limits := DefaultLimits()
+
+	wasmObj := vm.NewObject()
+
+	// fazt.wasm.load(path) → { exports: { fn(...) }, memory: { read, readString, write, size } }
+	// Loading a path again returns the same instance.
+	wasmObj.Set("load", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("fazt.wasm.load requires (path)")))
+		}
+		file := path.Clean("/" + call.Argument(0).String())[1:]
+		if obj, ok := loaded[file]; ok {
+			return obj
+		}
+		if len(loaded) >= MaxInstances {
+			panic(vm.NewGoError(fmt.Errorf("fazt.wasm.load: at most %d modules per execution", MaxInstances)))
+		}
+
+		code, err := load(file)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.wasm.load: %s not found", file)))
+		}
+		inst, err := Load(ctx, []byte(code), limits)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("fazt.wasm.load %s: %w", file, err)))
+		}
+
+		obj := instanceToJS(vm, ctx, inst)
+		loaded[file] = obj
+		return obj
+	})
+
+	// Attach to fazt namespace
+	faztVal := vm.Get("fazt")
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		return fmt.Errorf("fazt object not found on VM")
+	}
+	fazt := faztVal.ToObject(vm)
+	fazt.Set("wasm", wasmObj)
+	return nil
+}
+
+// instanceToJS wraps an Instance's exported functions and memory.
+func instanceToJS(vm *goja.Runtime, ctx context.Context, inst *Instance) *goja.Object {
+	exports := vm.NewObject()
+	for _, name := range inst.Exports() {
+		exports.Set(name, func(call goja.FunctionCall) goja.Value {
+			args := make([]float64, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				args[i] = arg.ToFloat()
+			}
+			results, err := inst.Call(ctx, name, args...)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			switch len(results) {
+			case 0:
+				return goja.Undefined()
+			case 1:
+				return vm.ToValue(results[0])
+			}
+			return vm.ToValue(results)
+		})
+	}
+
+	memory := vm.NewObject()
+
+	// memory.read(ptr, length) → ArrayBuffer
+	memory.Set("read", func(call goja.FunctionCall) goja.Value {
+		data := readMemory(vm, ctx, inst, call)
+		return vm.ToValue(vm.NewArrayBuffer(data))
+	})
+
+	// memory.readString(ptr, length) → string (UTF-8)
+	memory.Set("readString", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(string(readMemory(vm, ctx, inst, call)))
+	})
+
+	// memory.write(ptr, ArrayBuffer | string)
+	memory.Set("write", func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 2 {
+			panic(vm.NewGoError(fmt.Errorf("memory.write requires (ptr, data)")))
+		}
+		var data []byte
+		switch v := call.Argument(1).Export().(type) {
+		case goja.ArrayBuffer:
+			data = v.Bytes()
+		case []byte:
+			data = v
+		case string:
+			data = []byte(v)
+		default:
+			panic(vm.NewGoError(fmt.Errorf("memory.write: data must be an ArrayBuffer or string")))
+		}
+		if err := inst.Write(uint32(call.Argument(0).ToInteger()), data); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return goja.Undefined()
+	})
+
+	// memory.size() → bytes
+	memory.Set("size", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(inst.MemorySize())
+	})
+
+	obj := vm.NewObject()
+	obj.Set("exports", exports)
+	obj.Set("memory", memory)
+	return obj
+}
+
+// readMemory reads (ptr, length) from the instance, charging the bytes to
+// the execution's sandbox.
+func readMemory(vm *goja.Runtime, ctx context.Context, inst *Instance, call goja.FunctionCall) []byte {
+	if len(call.Arguments) < 2 {
+		panic(vm.NewGoError(fmt.Errorf("memory.read requires (ptr, length)")))
+	}
+	ptr, n := call.Argument(0).ToInteger(), call.Argument(1).ToInteger()
+	if ptr < 0 || n < 0 || ptr+n > int64(inst.MemorySize()) {
+		panic(vm.NewGoError(fmt.Errorf("memory.read: %d bytes at %d is out of bounds", n, ptr)))
+	}
+	if err := sandbox.Alloc(ctx, int(n)); err != nil {
+		panic(vm.NewGoError(err))
+	}
+	data, err := inst.Read(uint32(ptr), uint32(n))
+	if err != nil {
+		panic(vm.NewGoError(err))
+	}
+	return data
+}
@@ -0,0 +1,223 @@
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// MaxOutput caps what a handler can write to stdout, and to stderr
+const MaxOutput = 10 * 1024 * 1024
+
+// Request is an HTTP request for a WASI handler.
+type Request struct {
+	Method  string
+	Path    string
+	Query   string            // Raw query string
+	Headers map[string]string // Passed as HTTP_* variables
+	Params  map[string]string // Route params, passed as PARAM_* variables
+	Env     map[string]string // The app's environment variables
+	Body    []byte            // Passed on stdin
+}
+
+// Response is a WASI handler's parsed CGI response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// RunHandler runs a WASI command module (one exporting _start, as built by
+// wasi-sdk, Rust's wasm32-wasip1, Go's GOOS=wasip1 or py2wasm) as an HTTP
+// handler, CGI-style: the request in environment variables and on stdin,
+// and a CGI response (headers, a blank line, the body) on stdout. Modules
+// get no filesystem or network. Whatever the module wrote to stderr is
+// returned even when it fails.
+func RunHandler(ctx context.Context, code []byte, req *Request, limits Limits) (*Response, string, error) {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	rt := newRuntime(ctx, limits)
+	defer rt.Close(context.Background())
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		return nil, "", err
+	}
+
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid module: %w", err)
+	}
+	if err := checkCommand(compiled); err != nil {
+		return nil, "", err
+	}
+
+	stdout := &cappedBuffer{max: MaxOutput}
+	stderr := &cappedBuffer{max: MaxOutput}
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs("handler").
+		WithStdin(bytes.NewReader(req.Body)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithRandSource(rand.Reader)
+	env := cgiEnv(req)
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		config = config.WithEnv(k, env[k])
+	}
+
+	_, err = rt.InstantiateModule(ctx, compiled, config)
+	var exit *sys.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, stderr.String(), fmt.Errorf("handler: %w (%dms)", ErrTimeout, limits.Timeout.Milliseconds())
+		}
+		if errors.As(err, &exit) {
+			return nil, stderr.String(), fmt.Errorf("handler exited with code %d", exit.ExitCode())
+		}
+		return nil, stderr.String(), fmt.Errorf("handler: %w", err)
+	}
+	if stdout.full {
+		return nil, stderr.String(), fmt.Errorf("handler wrote more than %d bytes", MaxOutput)
+	}
+
+	resp, err := parseCGI(stdout.buf.Bytes())
+	return resp, stderr.String(), err
+}
+
+// ValidateHandler checks that code is a WASI command module fazt can run.
+func ValidateHandler(ctx context.Context, code []byte) error {
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCompilationCache(cache))
+	defer rt.Close(ctx)
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		return fmt.Errorf("invalid module: %w", err)
+	}
+	return checkCommand(compiled)
+}
+
+// checkCommand makes sure a module is a WASI command that imports nothing
+// but WASI
+func checkCommand(compiled wazero.CompiledModule) error {
+	if _, ok := compiled.ExportedFunctions()["_start"]; !ok {
+		return errors.New("not a WASI command: no _start export (build an executable, not a library)")
+	}
+	for _, fn := range compiled.ImportedFunctions() {
+		if module, name, _ := fn.Import(); module != wasi_snapshot_preview1.ModuleName {
+			return fmt.Errorf("module imports %s.%s: only %s imports are supported", module, name, wasi_snapshot_preview1.ModuleName)
+		}
+	}
+	return nil
+}
+
+// cgiEnv returns the CGI/1.1 variables for a request, over the app's own
+func cgiEnv(req *Request) map[string]string {
+	env := make(map[string]string)
+	for k, v := range req.Env {
+		env[k] = v
+	}
+	for k, v := range req.Headers {
+		name := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		switch name {
+		case "CONTENT_TYPE", "CONTENT_LENGTH":
+			env[name] = v
+		case "PROXY":
+			// httpoxy: never let a request set HTTP_PROXY
+		default:
+			env["HTTP_"+name] = v
+		}
+	}
+	for k, v := range req.Params {
+		env["PARAM_"+strings.ToUpper(k)] = v
+	}
+	env["GATEWAY_INTERFACE"] = "CGI/1.1"
+	env["SERVER_PROTOCOL"] = "HTTP/1.1"
+	env["SERVER_SOFTWARE"] = "fazt"
+	env["REQUEST_METHOD"] = req.Method
+	env["PATH_INFO"] = req.Path
+	env["QUERY_STRING"] = req.Query
+	env["CONTENT_LENGTH"] = strconv.Itoa(len(req.Body))
+
+	// WASI can't pass NUL bytes in the environment
+	for k, v := range env {
+		if strings.ContainsRune(k, 0) || strings.ContainsRune(v, 0) || strings.ContainsRune(k, '=') {
+			delete(env, k)
+		}
+	}
+	return env
+}
+
+// parseCGI parses a CGI response: headers, a blank line, then the body. A
+// Status header sets the status, and a Location without one redirects.
+func parseCGI(out []byte) (*Response, error) {
+	r := bufio.NewReader(bytes.NewReader(out))
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if len(out) == 0 {
+			return nil, errors.New("handler wrote no response")
+		}
+		return nil, fmt.Errorf("handler wrote an invalid CGI response: %w", err)
+	}
+	body, _ := io.ReadAll(r)
+
+	resp := &Response{Status: http.StatusOK, Header: http.Header(header), Body: body}
+	if status := resp.Header.Get("Status"); status != "" {
+		code, _, _ := strings.Cut(strings.TrimSpace(status), " ")
+		n, err := strconv.Atoi(code)
+		if err != nil || n < 100 || n > 999 {
+			return nil, fmt.Errorf("handler wrote an invalid Status header: %q", status)
+		}
+		resp.Status = n
+		resp.Header.Del("Status")
+	} else if resp.Header.Get("Location") != "" {
+		resp.Status = http.StatusFound
+	}
+	if resp.Header.Get("Content-Type") == "" {
+		resp.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	return resp, nil
+}
+
+// cappedBuffer is a buffer that fails writes past max bytes
+type cappedBuffer struct {
+	buf  bytes.Buffer
+	max  int
+	full bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.full = true
+		return 0, io.ErrShortWrite
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	return b.buf.String()
+}
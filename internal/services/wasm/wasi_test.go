@@ -0,0 +1,116 @@
+package wasm
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cgiWasm is a WASI command that writes "Status: 201 Created", a
+// Content-Type header and the body "hi" to stdout
+var cgiWasm, _ = hex.DecodeString("0061736d01000000010c0260047f7f7f7f017f60000002230116776173695f736e617073686f745f" +
+	"70726576696577310866645f77726974650000030201010503010001071302066d656d6f7279020006" +
+	"5f737461727400010a0f010d00410141004101410810001a0b0b46020041000b0810000000330000" +
+	"000041100b335374617475733a2032303120437265617465640d0a436f6e74656e742d547970653a" +
+	"20746578742f706c61696e0d0a0d0a6869")
+
+func TestRunHandler(t *testing.T) {
+	limits := Limits{MaxMemory: 1 << 20, Timeout: time.Second}
+	resp, stderr, err := RunHandler(context.Background(), cgiWasm, &Request{Method: "GET", Path: "/api/hi"}, limits)
+	if err != nil {
+		t.Fatalf("RunHandler failed: %v (stderr %q)", err, stderr)
+	}
+	if resp.Status != 201 || resp.Header.Get("Content-Type") != "text/plain" || string(resp.Body) != "hi" {
+		t.Errorf("Unexpected response %d %v %q", resp.Status, resp.Header, resp.Body)
+	}
+	if resp.Header.Get("Status") != "" {
+		t.Error("Expected the Status header to be removed")
+	}
+
+	if _, _, err := RunHandler(context.Background(), mathWasm, &Request{Method: "GET"}, limits); err == nil || !strings.Contains(err.Error(), "_start") {
+		t.Errorf("Expected a module without _start to fail, got %v", err)
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	ctx := context.Background()
+	if err := ValidateHandler(ctx, cgiWasm); err != nil {
+		t.Errorf("Expected a WASI command to validate, got %v", err)
+	}
+	if err := ValidateHandler(ctx, mathWasm); err == nil {
+		t.Error("Expected a library module to fail")
+	}
+	if err := ValidateHandler(ctx, []byte("not wasm")); err == nil || !strings.Contains(err.Error(), "invalid module") {
+		t.Errorf("Expected garbage to fail, got %v", err)
+	}
+}
+
+func TestCGIEnv(t *testing.T) {
+	env := cgiEnv(&Request{
+		Method: "POST",
+		Path:   "/api/users/42",
+		Query:  "a=1",
+		Headers: map[string]string{
+			"content-type":    "application/json",
+			"x-forwarded-for": "1.2.3.4",
+			"proxy":           "http://evil",
+		},
+		Params: map[string]string{"id": "42"},
+		Env:    map[string]string{"API_KEY": "secret", "BAD": "a\x00b"},
+		Body:   []byte(`{"a":1}`),
+	})
+
+	want := map[string]string{
+		"REQUEST_METHOD":       "POST",
+		"PATH_INFO":            "/api/users/42",
+		"QUERY_STRING":         "a=1",
+		"CONTENT_TYPE":         "application/json",
+		"CONTENT_LENGTH":       "7",
+		"HTTP_X_FORWARDED_FOR": "1.2.3.4",
+		"PARAM_ID":             "42",
+		"API_KEY":              "secret",
+		"GATEWAY_INTERFACE":    "CGI/1.1",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+	for _, k := range []string{"HTTP_PROXY", "BAD", "HTTP_CONTENT_TYPE"} {
+		if _, ok := env[k]; ok {
+			t.Errorf("Expected %s not to be set", k)
+		}
+	}
+}
+
+func TestParseCGI(t *testing.T) {
+	tests := []struct {
+		out    string
+		status int
+		ctype  string
+		body   string
+	}{
+		{"Content-Type: text/html\r\n\r\n<p>hi</p>", 200, "text/html", "<p>hi</p>"},
+		{"Status: 404 Not Found\n\nmissing", 404, "text/plain; charset=utf-8", "missing"},
+		{"Location: /login\n\n", 302, "text/plain; charset=utf-8", ""},
+		{"Status: 301\nLocation: /new\n\n", 301, "text/plain; charset=utf-8", ""},
+	}
+	for _, tt := range tests {
+		resp, err := parseCGI([]byte(tt.out))
+		if err != nil {
+			t.Errorf("parseCGI(%q) failed: %v", tt.out, err)
+			continue
+		}
+		if resp.Status != tt.status || resp.Header.Get("Content-Type") != tt.ctype || string(resp.Body) != tt.body {
+			t.Errorf("parseCGI(%q) = %d %q %q, want %d %q %q", tt.out, resp.Status, resp.Header.Get("Content-Type"), resp.Body, tt.status, tt.ctype, tt.body)
+		}
+	}
+
+	for _, out := range []string{"", "Status: abc\n\n", "no headers here"} {
+		if _, err := parseCGI([]byte(out)); err == nil {
+			t.Errorf("Expected parseCGI(%q) to fail", out)
+		}
+	}
+}
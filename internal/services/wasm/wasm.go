@@ -0,0 +1,201 @@
+// Package wasm runs WebAssembly modules shipped with apps, for code too slow
+// for the JS interpreter. Pure Go runtime via wazero — no CGO required.
+//
+// Modules loaded from JS get no WASI and no host imports: they compute on
+// their own linear memory, which callers read and write to pass data in and
+// out. WASI command modules can instead serve HTTP requests CGI-style (see
+// RunHandler).
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/system"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// pageSize is the size of a WebAssembly memory page
+const pageSize = 64 * 1024
+
+// ErrTimeout is returned when a call runs past Limits.Timeout
+var ErrTimeout = errors.New("time limit exceeded")
+
+// cache shares compiled code between instances, so each module is compiled
+// once per process
+var cache = wazero.NewCompilationCache()
+
+// Limits bound a module instance.
+type Limits struct {
+	MaxMemory int64         // Linear memory cap, rounded down to whole pages
+	Timeout   time.Duration // Per call
+}
+
+// DefaultLimits returns the limits from the system's runtime limits.
+func DefaultLimits() Limits {
+	limits := system.GetLimits().Runtime
+	return Limits{
+		MaxMemory: limits.WasmMemory,
+		Timeout:   time.Duration(limits.WasmTimeout) * time.Millisecond,
+	}
+}
+
+// Instance is a module instantiated for one execution. It isn't safe for
+// concurrent use.
+type Instance struct {
+	runtime wazero.Runtime
+	module  api.Module
+	limits  Limits
+}
+
+// Load compiles (or reuses the compiled) code and instantiates it. The
+// instance is closed when ctx is done.
+func Load(ctx context.Context, code []byte, limits Limits) (*Instance, error) {
+	rt := newRuntime(ctx, limits)
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("invalid module: %w", err)
+	}
+	if imports := compiled.ImportedFunctions(); len(imports) > 0 {
+		module, name, _ := imports[0].Import()
+		rt.Close(ctx)
+		return nil, fmt.Errorf("module imports %s.%s: host imports aren't supported", module, name)
+	}
+
+	mod, err := rt.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("instantiate: %w", err)
+	}
+	context.AfterFunc(ctx, func() { rt.Close(context.Background()) })
+	return &Instance{runtime: rt, module: mod, limits: limits}, nil
+}
+
+// newRuntime returns a runtime enforcing limits' memory cap, whose modules
+// stop when the context of their call is done
+func newRuntime(ctx context.Context, limits Limits) wazero.Runtime {
+	config := wazero.NewRuntimeConfig().
+		WithCompilationCache(cache).
+		WithCloseOnContextDone(true)
+	if pages := limits.MaxMemory / pageSize; pages > 0 {
+		config = config.WithMemoryLimitPages(uint32(min(pages, 65536)))
+	}
+	return wazero.NewRuntimeWithConfig(ctx, config)
+}
+
+// Exports returns the names of the module's exported functions, sorted.
+func (in *Instance) Exports() []string {
+	var names []string
+	for name := range in.module.ExportedFunctionDefinitions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Call runs an exported function. Arguments are converted to its parameter
+// types (integers truncate) and results back to float64; i32 results are
+// signed. A call that times out closes the instance.
+func (in *Instance) Call(ctx context.Context, name string, args ...float64) ([]float64, error) {
+	fn := in.module.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("%s is not an exported function", name)
+	}
+	def := fn.Definition()
+	params := def.ParamTypes()
+	if len(args) != len(params) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, len(params), len(args))
+	}
+
+	stack := make([]uint64, len(params))
+	for i, typ := range params {
+		stack[i] = encode(typ, args[i])
+	}
+
+	callCtx := ctx
+	if in.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, in.limits.Timeout)
+		defer cancel()
+	}
+	out, err := fn.Call(callCtx, stack...)
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s: %w (%dms)", name, ErrTimeout, in.limits.Timeout.Milliseconds())
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	results := make([]float64, len(out))
+	for i, typ := range def.ResultTypes() {
+		results[i] = decode(typ, out[i])
+	}
+	return results, nil
+}
+
+// Read copies n bytes of the instance's memory at ptr.
+func (in *Instance) Read(ptr, n uint32) ([]byte, error) {
+	mem := in.module.Memory()
+	if mem == nil {
+		return nil, errors.New("module exports no memory")
+	}
+	buf, ok := mem.Read(ptr, n)
+	if !ok {
+		return nil, fmt.Errorf("read of %d bytes at %d is out of bounds (memory is %d bytes)", n, ptr, mem.Size())
+	}
+	return append([]byte(nil), buf...), nil
+}
+
+// Write copies data into the instance's memory at ptr.
+func (in *Instance) Write(ptr uint32, data []byte) error {
+	mem := in.module.Memory()
+	if mem == nil {
+		return errors.New("module exports no memory")
+	}
+	if !mem.Write(ptr, data) {
+		return fmt.Errorf("write of %d bytes at %d is out of bounds (memory is %d bytes)", len(data), ptr, mem.Size())
+	}
+	return nil
+}
+
+// MemorySize returns the size of the instance's memory in bytes.
+func (in *Instance) MemorySize() uint32 {
+	if mem := in.module.Memory(); mem != nil {
+		return mem.Size()
+	}
+	return 0
+}
+
+// Close releases the instance before its context is done.
+func (in *Instance) Close(ctx context.Context) error {
+	return in.runtime.Close(ctx)
+}
+
+func encode(typ api.ValueType, v float64) uint64 {
+	switch typ {
+	case api.ValueTypeI32:
+		return api.EncodeI32(int32(int64(v)))
+	case api.ValueTypeI64:
+		return api.EncodeI64(int64(v))
+	case api.ValueTypeF32:
+		return api.EncodeF32(float32(v))
+	}
+	return api.EncodeF64(v)
+}
+
+func decode(typ api.ValueType, v uint64) float64 {
+	switch typ {
+	case api.ValueTypeI32:
+		return float64(api.DecodeI32(v))
+	case api.ValueTypeI64:
+		return float64(int64(v))
+	case api.ValueTypeF32:
+		return float64(api.DecodeF32(v))
+	}
+	return api.DecodeF64(v)
+}
@@ -0,0 +1,123 @@
+package wasm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// mathWasm exports add(i32, i32) i32, spin() (loops forever) and a memory
+// starting with "hello"
+var mathWasm, _ = hex.DecodeString("0061736d01000000010a0260027f7f017f600000030302000105030100010717" +
+	"03036164640000047370696e0001066d656d6f727902000a11020700200020016a0b070003400c000b0b" +
+	"0b0b010041000b0568656c6c6f")
+
+// importWasm imports env.log
+var importWasm, _ = hex.DecodeString("0061736d01000000010401600000020b0103656e76036c6f670000")
+
+func TestInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	inst, err := Load(ctx, mathWasm, Limits{MaxMemory: 1 << 20, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if got := strings.Join(inst.Exports(), ","); got != "add,spin" {
+		t.Errorf("Expected exports add,spin, got %s", got)
+	}
+
+	results, err := inst.Call(ctx, "add", 2, 3)
+	if err != nil || len(results) != 1 || results[0] != 5 {
+		t.Errorf("Expected add(2, 3) = 5, got %v, %v", results, err)
+	}
+	if results, _ := inst.Call(ctx, "add", 2147483647, 1); results[0] != -2147483648 {
+		t.Errorf("Expected i32 results to wrap and be signed, got %v", results)
+	}
+	if _, err := inst.Call(ctx, "add", 1); err == nil {
+		t.Error("Expected a call with too few arguments to fail")
+	}
+	if _, err := inst.Call(ctx, "missing"); err == nil {
+		t.Error("Expected calling a missing export to fail")
+	}
+
+	if data, err := inst.Read(0, 5); err != nil || string(data) != "hello" {
+		t.Errorf("Expected memory to start with hello, got %q, %v", data, err)
+	}
+	if err := inst.Write(100, []byte("wasm")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if data, _ := inst.Read(100, 4); string(data) != "wasm" {
+		t.Errorf("Expected to read back the write, got %q", data)
+	}
+	if _, err := inst.Read(inst.MemorySize()-2, 4); err == nil {
+		t.Error("Expected a read past the end of memory to fail")
+	}
+
+	if _, err := inst.Call(ctx, "spin"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Expected spin to time out, got %v", err)
+	}
+}
+
+func TestLoad_Rejects(t *testing.T) {
+	ctx := context.Background()
+	limits := Limits{MaxMemory: 1 << 20, Timeout: time.Second}
+
+	if _, err := Load(ctx, []byte("not wasm"), limits); err == nil {
+		t.Error("Expected invalid code to fail")
+	}
+	if _, err := Load(ctx, importWasm, limits); err == nil || !strings.Contains(err.Error(), "env.log") {
+		t.Errorf("Expected a module with host imports to fail naming the import, got %v", err)
+	}
+	// The module's memory needs a 64KB page
+	inst, err := Load(ctx, mathWasm, Limits{MaxMemory: 1024})
+	if err != nil {
+		t.Fatalf("Expected limits under a page to be ignored, got %v", err)
+	}
+	inst.Close(ctx)
+}
+
+func TestInjectWasmNamespace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	loads := 0
+	vm := goja.New()
+	vm.Set("fazt", vm.NewObject())
+	err := InjectWasmNamespace(vm, ctx, func(path string) (string, error) {
+		loads++
+		if path == "lib/math.wasm" {
+			return string(mathWasm), nil
+		}
+		return "", sql.ErrNoRows
+	})
+	if err != nil {
+		t.Fatalf("InjectWasmNamespace failed: %v", err)
+	}
+
+	v, err := vm.RunString(`
+		var lib = fazt.wasm.load("lib/math.wasm");
+		lib.memory.write(16, "from js");
+		[lib.exports.add(40, 2), lib.memory.readString(0, 5), lib.memory.readString(16, 7),
+			fazt.wasm.load("/lib/../lib/math.wasm") === lib].join(",")
+	`)
+	if err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+	if got := v.String(); got != "42,hello,from js,true" {
+		t.Errorf("Unexpected result: %s", got)
+	}
+	if loads != 1 {
+		t.Errorf("Expected the module to be read once, got %d", loads)
+	}
+
+	if _, err := vm.RunString(`fazt.wasm.load("lib/missing.wasm")`); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected a missing module to throw, got %v", err)
+	}
+}
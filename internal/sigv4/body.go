@@ -0,0 +1,143 @@
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxChunkHeader bounds a chunk's "<size>;chunk-signature=<sig>" line
+const maxChunkHeader = 256
+
+// ReadBody reads a verified request's body, at most max bytes of content,
+// and checks it against what the request was signed with: its SHA-256, or
+// each chunk's signature for an aws-chunked body.
+func (a *Auth) ReadBody(body io.Reader, max int64) ([]byte, error) {
+	switch a.PayloadHash {
+	case UnsignedPayload:
+		return readAll(body, max)
+	case StreamingPayload:
+		return a.readChunked(body, max, true)
+	case StreamingUnsignedTrailer:
+		return a.readChunked(body, max, false)
+	}
+
+	if len(a.PayloadHash) != sha256.Size*2 {
+		return nil, &Error{"NotImplemented", fmt.Sprintf("Payload signing %q is not supported", a.PayloadHash), http.StatusNotImplemented}
+	}
+	data, err := readAll(body, max)
+	if err != nil {
+		return nil, err
+	}
+	if hashHex(data) != strings.ToLower(a.PayloadHash) {
+		return nil, ErrContentMismatch
+	}
+	return data, nil
+}
+
+func readAll(body io.Reader, max int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > max {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// readChunked decodes an aws-chunked body: chunks of "<hex size>[;chunk-
+// signature=<sig>]\r\n<data>\r\n" ending with an empty chunk, then any
+// trailing headers. Each signature chains from the one before it, starting
+// from the request's.
+func (a *Auth) readChunked(body io.Reader, max int64, signed bool) ([]byte, error) {
+	br := bufio.NewReader(body)
+	var data bytes.Buffer
+	prev := a.signature
+	for {
+		line, err := readLine(br)
+		if err == io.EOF {
+			return nil, incompleteBody("body ended before the final chunk")
+		}
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, ext, _ := strings.Cut(line, ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size < 0 {
+			return nil, incompleteBody("invalid chunk size %q", sizeHex)
+		}
+		if int64(data.Len())+size > max {
+			return nil, ErrTooLarge
+		}
+
+		start := data.Len()
+		if _, err := io.CopyN(&data, br, size); err != nil {
+			return nil, incompleteBody("chunk shorter than its size")
+		}
+		chunk := data.Bytes()[start:]
+
+		if signed {
+			sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+			if !ok {
+				return nil, incompleteBody("chunk is missing its signature")
+			}
+			want := hex.EncodeToString(hmacSHA256(a.signingKey, "AWS4-HMAC-SHA256-PAYLOAD\n"+a.amzDate+"\n"+
+				a.scope+"\n"+prev+"\n"+emptySHA256+"\n"+hashHex(chunk)))
+			if !hmac.Equal([]byte(sig), []byte(want)) {
+				return nil, ErrSignatureMismatch
+			}
+			prev = sig
+		}
+
+		if size == 0 {
+			// Trailing headers (checksums) up to a blank line; unsigned
+			// bodies may end without one
+			for {
+				line, err := readLine(br)
+				if err == io.EOF || (err == nil && line == "") {
+					return data.Bytes(), nil
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if line, err := readLine(br); err != nil || line != "" {
+			return nil, incompleteBody("chunk data not followed by CRLF")
+		}
+	}
+}
+
+// readLine reads a CRLF-terminated line without its terminator
+func readLine(br *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF && len(line) == 0 {
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", incompleteBody("body ended mid-line")
+		}
+		if b == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		if len(line) >= maxChunkHeader {
+			return "", incompleteBody("chunk header too long")
+		}
+		line = append(line, b)
+	}
+}
+
+func incompleteBody(format string, args ...interface{}) *Error {
+	return &Error{"IncompleteBody", "Invalid aws-chunked body: " + fmt.Sprintf(format, args...), http.StatusBadRequest}
+}
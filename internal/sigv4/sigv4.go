@@ -0,0 +1,350 @@
+// Package sigv4 verifies AWS Signature Version 4 on S3 requests, signed in
+// the Authorization header or presigned in the query string, and reads
+// their bodies: plain, checked against the signed SHA-256, or aws-chunked
+// with each chunk's signature checked in turn.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm is the only signing algorithm accepted
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// Payload hashes that aren't a hex SHA-256 of the body
+const (
+	UnsignedPayload          = "UNSIGNED-PAYLOAD"
+	StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+const (
+	amzDateFormat = "20060102T150405Z"
+	scopeFormat   = "20060102"
+
+	// MaxSkew is how far a header-signed request's date may be from the
+	// server's clock
+	MaxSkew = 15 * time.Minute
+
+	// MaxExpires is the longest a presigned URL may be valid
+	MaxExpires = 7 * 24 * time.Hour
+
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Error is a verification failure, carrying the S3 error code and HTTP
+// status a gateway should answer with
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func accessDenied(format string, args ...interface{}) *Error {
+	return &Error{"AccessDenied", fmt.Sprintf(format, args...), http.StatusForbidden}
+}
+
+func malformed(format string, args ...interface{}) *Error {
+	return &Error{"AuthorizationHeaderMalformed", fmt.Sprintf(format, args...), http.StatusBadRequest}
+}
+
+// Verification errors a gateway may want to tell apart
+var (
+	ErrSignatureMismatch = &Error{"SignatureDoesNotMatch",
+		"The request signature we calculated does not match the signature you provided", http.StatusForbidden}
+	ErrContentMismatch = &Error{"XAmzContentSHA256Mismatch",
+		"The provided 'x-amz-content-sha256' header does not match what was computed", http.StatusBadRequest}
+	ErrTooLarge = &Error{"EntityTooLarge",
+		"Your proposed upload exceeds the maximum allowed size", http.StatusRequestEntityTooLarge}
+)
+
+// ErrUnknownKey is returned by a SecretFunc for an access key it doesn't know
+var ErrUnknownKey = errors.New("unknown access key")
+
+// SecretFunc returns the secret for an access key ID, or ErrUnknownKey
+type SecretFunc func(accessKeyID string) (string, error)
+
+// Auth is a verified request's signer and what its body is signed with
+type Auth struct {
+	AccessKeyID string
+	Region      string
+	PayloadHash string // Hex SHA-256, UnsignedPayload, StreamingPayload or StreamingUnsignedTrailer
+	Presigned   bool
+
+	signingKey []byte
+	amzDate    string
+	scope      string
+	signature  string // Seed signature of a streaming body
+}
+
+// Verify checks a request's signature, in the Authorization header or the
+// query string, against the secret of the access key it names.
+func Verify(r *http.Request, secret SecretFunc, now time.Time) (*Auth, error) {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != "" {
+		return verifyPresigned(r, q, secret, now)
+	}
+
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return nil, accessDenied("Anonymous access is not allowed; sign requests with an access key")
+	}
+	if !strings.HasPrefix(header, Algorithm+" ") {
+		return nil, malformed("Only %s signatures are supported", Algorithm)
+	}
+
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(header, Algorithm+" "), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			fields[k] = v
+		}
+	}
+	credential, signedHeaders, signature := fields["Credential"], fields["SignedHeaders"], fields["Signature"]
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return nil, malformed("The authorization header is malformed")
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, accessDenied("AWS authentication requires a valid X-Amz-Date header")
+	}
+	if d := now.Sub(t); d > MaxSkew || d < -MaxSkew {
+		return nil, &Error{"RequestTimeTooSkewed",
+			"The difference between the request time and the server's time is too large", http.StatusForbidden}
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, &Error{"MissingSecurityHeader", "Your request is missing the x-amz-content-sha256 header", http.StatusBadRequest}
+	}
+
+	a, err := newAuth(credential, amzDate, t, secret)
+	if err != nil {
+		return nil, err
+	}
+	a.PayloadHash = payloadHash
+
+	canonical := canonicalRequest(r, q, "", strings.Split(signedHeaders, ";"), payloadHash)
+	if !hmac.Equal([]byte(a.sign(canonical)), []byte(signature)) {
+		return nil, ErrSignatureMismatch
+	}
+	a.signature = signature
+	return a, nil
+}
+
+func verifyPresigned(r *http.Request, q url.Values, secret SecretFunc, now time.Time) (*Auth, error) {
+	if q.Get("X-Amz-Algorithm") != Algorithm {
+		return nil, malformed("Only %s signatures are supported", Algorithm)
+	}
+	credential, signedHeaders, signature := q.Get("X-Amz-Credential"), q.Get("X-Amz-SignedHeaders"), q.Get("X-Amz-Signature")
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return nil, accessDenied("Query-string authentication requires X-Amz-Credential, X-Amz-SignedHeaders and X-Amz-Signature")
+	}
+
+	amzDate := q.Get("X-Amz-Date")
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, accessDenied("X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+	}
+	seconds, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxExpires {
+		return nil, accessDenied("X-Amz-Expires must be between 1 and %d seconds", int(MaxExpires.Seconds()))
+	}
+	if t.After(now.Add(MaxSkew)) {
+		return nil, accessDenied("Request is not valid yet")
+	}
+	if now.After(t.Add(time.Duration(seconds) * time.Second)) {
+		return nil, accessDenied("Request has expired")
+	}
+
+	a, err := newAuth(credential, amzDate, t, secret)
+	if err != nil {
+		return nil, err
+	}
+	a.PayloadHash = UnsignedPayload
+	a.Presigned = true
+	if h := q.Get("X-Amz-Content-Sha256"); h != "" {
+		a.PayloadHash = h
+	}
+
+	canonical := canonicalRequest(r, q, "X-Amz-Signature", strings.Split(signedHeaders, ";"), a.PayloadHash)
+	if !hmac.Equal([]byte(a.sign(canonical)), []byte(signature)) {
+		return nil, ErrSignatureMismatch
+	}
+	return a, nil
+}
+
+// newAuth parses a credential, <key>/<yyyymmdd>/<region>/s3/aws4_request,
+// and derives its signing key
+func newAuth(credential, amzDate string, t time.Time, secret SecretFunc) (*Auth, error) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return nil, malformed("The credential is malformed; expected \"<key>/<yyyymmdd>/<region>/s3/aws4_request\"")
+	}
+	if parts[1] != t.Format(scopeFormat) {
+		return nil, malformed("The credential date %s doesn't match the request date", parts[1])
+	}
+
+	key, err := secret(parts[0])
+	if errors.Is(err, ErrUnknownKey) {
+		return nil, &Error{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records", http.StatusForbidden}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{
+		AccessKeyID: parts[0],
+		Region:      parts[2],
+		signingKey:  signingKey(key, parts[1], parts[2]),
+		amzDate:     amzDate,
+		scope:       strings.Join(parts[1:], "/"),
+	}, nil
+}
+
+// sign returns the signature of a canonical request
+func (a *Auth) sign(canonical string) string {
+	return hex.EncodeToString(hmacSHA256(a.signingKey,
+		Algorithm+"\n"+a.amzDate+"\n"+a.scope+"\n"+hashHex([]byte(canonical))))
+}
+
+// Presign returns a URL for method on rawURL (scheme, host and path) that
+// works without credentials until expires has passed, signed for the host
+// header only, as S3 clients presign.
+func Presign(method, rawURL, accessKeyID, secret, region string, expires time.Duration, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if expires < time.Second || expires > MaxExpires {
+		return "", fmt.Errorf("expiry must be between 1s and %s", MaxExpires)
+	}
+
+	now = now.UTC()
+	date := now.Format(scopeFormat)
+	a := &Auth{
+		signingKey: signingKey(secret, date, region),
+		amzDate:    now.Format(amzDateFormat),
+		scope:      date + "/" + region + "/s3/aws4_request",
+	}
+
+	q := u.Query()
+	q.Set("X-Amz-Algorithm", Algorithm)
+	q.Set("X-Amz-Credential", accessKeyID+"/"+a.scope)
+	q.Set("X-Amz-Date", a.amzDate)
+	q.Set("X-Amz-Expires", strconv.Itoa(int(expires.Seconds())))
+	q.Set("X-Amz-SignedHeaders", "host")
+
+	r := &http.Request{Method: method, URL: u, Host: u.Host, Header: http.Header{}}
+	signature := a.sign(canonicalRequest(r, q, "", []string{"host"}, UnsignedPayload))
+
+	u.RawQuery = canonicalQuery(q, "") + "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// canonicalRequest builds the request as SigV4 signs it, leaving out the
+// query parameter skip
+func canonicalRequest(r *http.Request, q url.Values, skip string, signedHeaders []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(headerValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(q, skip),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// canonicalQuery encodes and sorts the query parameters
+func canonicalQuery(q url.Values, skip string) string {
+	pairs := make([]string, 0, len(q))
+	for k, values := range q {
+		if k == skip {
+			continue
+		}
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// headerValue is a signed header's value, its whitespace trimmed and
+// repeated values joined
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		if v := r.Header.Get("Content-Length"); v != "" {
+			return v
+		}
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	values := r.Header.Values(name)
+	for i, v := range values {
+		values[i] = strings.Join(strings.Fields(v), " ")
+	}
+	return strings.Join(values, ",")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, and
+// slashes too when encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func signingKey(secret, date, region string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, "s3")
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,227 @@
+package slo
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notifier"
+	"github.com/fazt-sh/fazt/internal/status"
+)
+
+const (
+	// tickInterval is how often rollups are flushed and SLOs evaluated
+	tickInterval = time.Minute
+
+	// minRequests is the fewest requests in the long window before a burn
+	// alert fires, so a single failure on an idle site doesn't page
+	minRequests = 10
+)
+
+// burnRule fires when both the long and short window burn faster than Rate.
+// The short window makes the alert reset quickly once the problem stops.
+type burnRule struct {
+	State string
+	Long  time.Duration
+	Short time.Duration
+	Rate  float64
+}
+
+// burnRules are checked in order; the first match wins. At 14.4x a 30-day
+// budget is 2% gone in an hour, at 6x it is 5% gone in six hours.
+var burnRules = []burnRule{
+	{State: AlertFastBurn, Long: time.Hour, Short: 5 * time.Minute, Rate: 14.4},
+	{State: AlertSlowBurn, Long: 6 * time.Hour, Short: 30 * time.Minute, Rate: 6},
+}
+
+// burnWindows are the windows reported by Evaluate
+var burnWindows = []struct {
+	Name string
+	D    time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"6h", 6 * time.Hour},
+}
+
+// Report is an SLO's compliance over its window
+type Report struct {
+	SLO
+	Requests        int64              `json:"requests"`
+	BadRequests     int64              `json:"bad_requests"`
+	SLI             float64            `json:"sli"`              // Fraction of good requests; 1 with no traffic
+	BudgetRemaining float64            `json:"budget_remaining"` // Fraction of the error budget left; negative when exhausted
+	BurnRates       map[string]float64 `json:"burn_rates"`       // Budget consumption relative to sustainable, by window
+}
+
+// Evaluate computes the SLO's report as of now
+func Evaluate(db *sql.DB, s *SLO, now time.Time) (*Report, error) {
+	total, bad, err := s.counts(db, time.Duration(s.WindowDays)*24*time.Hour, now)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{SLO: *s, Requests: total, BadRequests: bad, SLI: 1, BudgetRemaining: 1, BurnRates: map[string]float64{}}
+	if total > 0 {
+		r.SLI = 1 - float64(bad)/float64(total)
+		r.BudgetRemaining = 1 - (float64(bad)/float64(total))/s.budget()
+	}
+
+	for _, w := range burnWindows {
+		rate, _, err := s.burnRate(db, w.D, now)
+		if err != nil {
+			return nil, err
+		}
+		r.BurnRates[w.Name] = rate
+	}
+	return r, nil
+}
+
+// budget is the allowed fraction of bad requests
+func (s *SLO) budget() float64 {
+	return 1 - s.Objective
+}
+
+// burnRate returns how fast the budget is consumed over d (1 = exactly on
+// budget) and the number of requests it is based on
+func (s *SLO) burnRate(db *sql.DB, d time.Duration, now time.Time) (float64, int64, error) {
+	total, bad, err := s.counts(db, d, now)
+	if err != nil || total == 0 {
+		return 0, total, err
+	}
+	return (float64(bad) / float64(total)) / s.budget(), total, nil
+}
+
+// burnState returns the alert state the SLO is in as of now
+func (s *SLO) burnState(db *sql.DB, now time.Time) (string, float64, error) {
+	for _, rule := range burnRules {
+		long, n, err := s.burnRate(db, rule.Long, now)
+		if err != nil {
+			return AlertNone, 0, err
+		}
+		if n < minRequests || long < rule.Rate {
+			continue
+		}
+		short, _, err := s.burnRate(db, rule.Short, now)
+		if err != nil {
+			return AlertNone, 0, err
+		}
+		if short >= rule.Rate {
+			return rule.State, long, nil
+		}
+	}
+	return AlertNone, 0, nil
+}
+
+// Controller flushes request rollups and raises burn-rate alerts
+type Controller struct {
+	db     *sql.DB
+	notify func(title, message string)
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewController creates an SLO controller
+func NewController(db *sql.DB) *Controller {
+	return &Controller{
+		db: db,
+		notify: func(title, message string) {
+			if err := notifier.Send(title, message, notifier.NotificationError); err != nil {
+				log.Printf("SLO: failed to send alert: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background evaluation loop
+func (c *Controller) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick(time.Now())
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the loop and flushes any remaining counts
+func (c *Controller) Stop() {
+	close(c.done)
+	c.wg.Wait()
+	if err := Flush(c.db); err != nil {
+		log.Printf("SLO: failed to flush rollups: %v", err)
+	}
+}
+
+// Tick flushes rollups, evaluates every SLO, alerts when an SLO starts
+// burning (or burns faster) and when it recovers, and prunes old rollups.
+// Alerts are held back while the alias is under maintenance.
+func (c *Controller) Tick(now time.Time) {
+	if err := Flush(c.db); err != nil {
+		log.Printf("SLO: failed to flush rollups: %v", err)
+	}
+
+	slos, err := List(c.db)
+	if err != nil {
+		log.Printf("SLO: failed to list SLOs: %v", err)
+		return
+	}
+
+	for i := range slos {
+		s := &slos[i]
+		state, rate, err := s.burnState(c.db, now)
+		if err != nil {
+			log.Printf("SLO: failed to evaluate %s: %v", s.Describe(), err)
+			continue
+		}
+		if state == s.AlertState {
+			continue
+		}
+		if err := setAlertState(c.db, s.ID, state, now); err != nil {
+			log.Printf("SLO: failed to update %s: %v", s.Describe(), err)
+			continue
+		}
+
+		// Stepping down from fast to slow burn isn't news
+		escalated := state == AlertFastBurn || (state == AlertSlowBurn && s.AlertState == AlertNone)
+		if state != AlertNone && !escalated {
+			continue
+		}
+		if maint, err := status.InMaintenance(c.db, s.Alias); err != nil || maint {
+			continue
+		}
+
+		if state == AlertNone {
+			c.notify("SLO recovered", fmt.Sprintf("%s %s is no longer burning its error budget", s.Alias, s.Describe()))
+			continue
+		}
+
+		report, err := Evaluate(c.db, s, now)
+		if err != nil {
+			log.Printf("SLO: failed to evaluate %s: %v", s.Describe(), err)
+			continue
+		}
+		title := "SLO burning"
+		if state == AlertFastBurn {
+			title = "SLO burning fast"
+		}
+		c.notify(title, fmt.Sprintf("%s %s is burning its error budget at %.1fx; %.0f%% of the budget remains",
+			s.Alias, s.Describe(), rate, report.BudgetRemaining*100))
+	}
+
+	if err := Prune(c.db, now); err != nil {
+		log.Printf("SLO: failed to prune rollups: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package slo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// bucketSize is the rollup granularity
+const bucketSize = 5 * time.Minute
+
+type rollupKey struct {
+	alias  string
+	bucket int64
+}
+
+type rollupCounts struct {
+	requests int64
+	errors   int64
+	within   []int64 // per LatencyBuckets
+}
+
+// pending holds counts recorded since the last Flush
+var pending = struct {
+	sync.Mutex
+	counts map[rollupKey]*rollupCounts
+}{counts: make(map[rollupKey]*rollupCounts)}
+
+// Record counts one served request toward the alias's rollups.
+// It only touches memory; the Controller flushes counts to the database.
+func Record(alias string, status int, elapsed time.Duration) {
+	recordAt(alias, status, elapsed, time.Now())
+}
+
+func recordAt(alias string, status int, elapsed time.Duration, now time.Time) {
+	key := rollupKey{alias: alias, bucket: bucketStart(now)}
+	ms := elapsed.Milliseconds()
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	c := pending.counts[key]
+	if c == nil {
+		c = &rollupCounts{within: make([]int64, len(LatencyBuckets))}
+		pending.counts[key] = c
+	}
+	c.requests++
+	if status >= 500 {
+		c.errors++
+	}
+	for i, bound := range LatencyBuckets {
+		if ms <= int64(bound) {
+			c.within[i]++
+		}
+	}
+}
+
+func bucketStart(t time.Time) int64 {
+	return t.Truncate(bucketSize).Unix()
+}
+
+func latencyColumn(ms int) string {
+	return fmt.Sprintf("le_%d", ms)
+}
+
+// Flush writes pending counts to slo_rollups. Counts that fail to write are
+// put back so they are retried on the next flush.
+func Flush(db *sql.DB) error {
+	pending.Lock()
+	counts := pending.counts
+	pending.counts = make(map[rollupKey]*rollupCounts)
+	pending.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	cols := make([]string, len(LatencyBuckets))
+	updates := make([]string, len(LatencyBuckets))
+	for i, bound := range LatencyBuckets {
+		cols[i] = latencyColumn(bound)
+		updates[i] = fmt.Sprintf("%s = %s + excluded.%s", cols[i], cols[i], cols[i])
+	}
+	query := fmt.Sprintf(`
+		INSERT INTO slo_rollups (alias, bucket, requests, errors, %s)
+		VALUES (?, ?, ?, ?%s)
+		ON CONFLICT(alias, bucket) DO UPDATE SET
+			requests = requests + excluded.requests,
+			errors = errors + excluded.errors,
+			%s
+	`, strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)), strings.Join(updates, ",\n\t\t\t"))
+
+	// One transaction through the write queue rather than a commit per row
+	err := storage.QueueWriteTx(context.Background(), db, func(tx *sql.Tx) error {
+		for key, c := range counts {
+			args := []interface{}{key.alias, key.bucket, c.requests, c.errors}
+			for _, n := range c.within {
+				args = append(args, n)
+			}
+			if _, err := tx.Exec(query, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for key, c := range counts {
+			restore(key, c)
+		}
+	}
+	return err
+}
+
+// restore merges unflushed counts back into pending
+func restore(key rollupKey, c *rollupCounts) {
+	pending.Lock()
+	defer pending.Unlock()
+
+	existing := pending.counts[key]
+	if existing == nil {
+		pending.counts[key] = c
+		return
+	}
+	existing.requests += c.requests
+	existing.errors += c.errors
+	for i := range existing.within {
+		existing.within[i] += c.within[i]
+	}
+}
+
+// Prune deletes rollups older than the longest compliance window
+func Prune(db *sql.DB, now time.Time) error {
+	_, err := db.Exec(`DELETE FROM slo_rollups WHERE bucket < ?`, now.AddDate(0, 0, -MaxWindowDays).Unix())
+	return err
+}
+
+// counts returns total and bad requests for the SLO over [now-d, now]
+func (s *SLO) counts(db *sql.DB, d time.Duration, now time.Time) (total, bad int64, err error) {
+	badExpr := "errors"
+	if s.Kind == KindLatency {
+		badExpr = "requests - " + latencyColumn(s.ThresholdMs)
+	}
+	err = db.QueryRow(fmt.Sprintf(`
+		SELECT COALESCE(SUM(requests), 0), COALESCE(SUM(%s), 0)
+		FROM slo_rollups WHERE alias = ? AND bucket > ? AND bucket <= ?
+	`, badExpr), s.Alias, now.Add(-d).Unix()-int64(bucketSize.Seconds()), now.Unix()).Scan(&total, &bad)
+	return total, bad, err
+}
@@ -0,0 +1,228 @@
+// Package slo tracks service level objectives for hosted sites.
+//
+// Every request served by a site is counted into 5-minute rollups in
+// slo_rollups (status probes are excluded). Operators define availability or
+// latency objectives per alias with `fazt slo`; Evaluate turns the rollups
+// into an SLI, remaining error budget, and burn rates. The Controller alerts
+// using multiwindow burn-rate rules rather than raw thresholds, so a brief
+// blip doesn't page but a sustained drain on the budget does.
+package slo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SLO kinds
+const (
+	KindAvailability = "availability"
+	KindLatency      = "latency"
+)
+
+// Alert states
+const (
+	AlertNone     = ""
+	AlertSlowBurn = "slow_burn"
+	AlertFastBurn = "fast_burn"
+)
+
+const (
+	// DefaultWindowDays is the compliance window when none is given
+	DefaultWindowDays = 30
+
+	// MaxWindowDays is the longest compliance window; rollups are kept this long
+	MaxWindowDays = 90
+)
+
+// LatencyBuckets are the latency bounds (in milliseconds) rollups are
+// counted into. Latency SLO thresholds must be one of them.
+var LatencyBuckets = []int{100, 250, 500, 1000, 2500, 5000}
+
+// Common errors
+var (
+	ErrSLONotFound       = errors.New("SLO not found")
+	ErrAliasNotFound     = errors.New("alias not found")
+	ErrInvalidKind       = errors.New("kind must be 'availability' or 'latency'")
+	ErrInvalidObjective  = errors.New("objective must be between 0 and 1 (exclusive), e.g. 0.999")
+	ErrInvalidThreshold  = errors.New("latency threshold must be one of 100, 250, 500, 1000, 2500, 5000 ms")
+	ErrInvalidWindowDays = errors.New("window must be between 1 and 90 days")
+)
+
+// SLO is a stored objective
+type SLO struct {
+	ID          int64   `json:"id"`
+	Alias       string  `json:"alias"`
+	Kind        string  `json:"kind"`
+	Objective   float64 `json:"objective"`
+	ThresholdMs int     `json:"threshold_ms,omitempty"`
+	WindowDays  int     `json:"window_days"`
+	AlertState  string  `json:"alert_state"`
+	AlertedAt   *int64  `json:"alerted_at,omitempty"`
+	CreatedAt   int64   `json:"created_at"`
+}
+
+// Config describes a new SLO
+type Config struct {
+	Alias       string  `json:"alias"`
+	Kind        string  `json:"kind"`
+	Objective   float64 `json:"objective"`
+	ThresholdMs int     `json:"threshold_ms,omitempty"`
+	WindowDays  int     `json:"window_days,omitempty"`
+}
+
+// Validate checks the config and fills in defaults
+func (c *Config) Validate() error {
+	c.Alias = strings.ToLower(strings.TrimSpace(c.Alias))
+	if c.Alias == "" {
+		return ErrAliasNotFound
+	}
+	switch c.Kind {
+	case KindAvailability:
+		c.ThresholdMs = 0
+	case KindLatency:
+		if !isLatencyBucket(c.ThresholdMs) {
+			return ErrInvalidThreshold
+		}
+	default:
+		return ErrInvalidKind
+	}
+	if c.Objective <= 0 || c.Objective >= 1 {
+		return ErrInvalidObjective
+	}
+	if c.WindowDays == 0 {
+		c.WindowDays = DefaultWindowDays
+	}
+	if c.WindowDays < 1 || c.WindowDays > MaxWindowDays {
+		return ErrInvalidWindowDays
+	}
+	return nil
+}
+
+// Describe returns a short human-readable form, e.g. "availability 99.9% (30d)"
+func (s *SLO) Describe() string {
+	target := formatPercent(s.Objective)
+	if s.Kind == KindLatency {
+		return fmt.Sprintf("latency %s under %dms (%dd)", target, s.ThresholdMs, s.WindowDays)
+	}
+	return fmt.Sprintf("availability %s (%dd)", target, s.WindowDays)
+}
+
+func isLatencyBucket(ms int) bool {
+	for _, b := range LatencyBuckets {
+		if b == ms {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPercent(f float64) string {
+	s := fmt.Sprintf("%.3f", f*100)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return s + "%"
+}
+
+// Create stores a new SLO. The alias must exist (the root site is "root").
+func Create(db *sql.DB, cfg Config, now time.Time) (*SLO, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Alias != "root" {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM aliases WHERE subdomain = ?`, cfg.Alias).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrAliasNotFound
+		}
+	}
+
+	var threshold interface{}
+	if cfg.Kind == KindLatency {
+		threshold = cfg.ThresholdMs
+	}
+
+	res, err := db.Exec(`
+		INSERT INTO slos (alias, kind, objective, threshold_ms, window_days, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, cfg.Alias, cfg.Kind, cfg.Objective, threshold, cfg.WindowDays, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := res.LastInsertId()
+	return Get(db, id)
+}
+
+// Get returns a single SLO
+func Get(db *sql.DB, id int64) (*SLO, error) {
+	s, err := scanSLO(db.QueryRow(`
+		SELECT id, alias, kind, objective, threshold_ms, window_days, alert_state, alerted_at, created_at
+		FROM slos WHERE id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSLONotFound
+	}
+	return s, err
+}
+
+// List returns all SLOs ordered by alias
+func List(db *sql.DB) ([]SLO, error) {
+	rows, err := db.Query(`
+		SELECT id, alias, kind, objective, threshold_ms, window_days, alert_state, alerted_at, created_at
+		FROM slos ORDER BY alias, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slos := []SLO{}
+	for rows.Next() {
+		s, err := scanSLO(rows)
+		if err != nil {
+			return nil, err
+		}
+		slos = append(slos, *s)
+	}
+	return slos, rows.Err()
+}
+
+// Delete removes an SLO. Rollups are shared per alias and are kept.
+func Delete(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM slos WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSLONotFound
+	}
+	return nil
+}
+
+// setAlertState records a burn alert transition
+func setAlertState(db *sql.DB, id int64, state string, now time.Time) error {
+	_, err := db.Exec(`UPDATE slos SET alert_state = ?, alerted_at = ? WHERE id = ?`, state, now.Unix(), id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSLO(row rowScanner) (*SLO, error) {
+	var s SLO
+	var threshold, alertedAt sql.NullInt64
+	if err := row.Scan(&s.ID, &s.Alias, &s.Kind, &s.Objective, &threshold, &s.WindowDays, &s.AlertState, &alertedAt, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.ThresholdMs = int(threshold.Int64)
+	if alertedAt.Valid {
+		s.AlertedAt = &alertedAt.Int64
+	}
+	return &s, nil
+}
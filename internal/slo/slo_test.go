@@ -0,0 +1,193 @@
+package slo
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES ('blog', 'proxy', '{"app_id":"app_blog"}')`)
+	return db
+}
+
+// record counts n requests and flushes them
+func record(t *testing.T, db *sql.DB, alias string, n, status int, elapsed time.Duration, at time.Time) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		recordAt(alias, status, elapsed, at)
+	}
+	if err := Flush(db); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	cases := []struct {
+		cfg  Config
+		want error
+	}{
+		{Config{Alias: "blog", Kind: "speed", Objective: 0.99}, ErrInvalidKind},
+		{Config{Alias: "blog", Kind: KindAvailability, Objective: 99.9}, ErrInvalidObjective},
+		{Config{Alias: "blog", Kind: KindLatency, Objective: 0.99, ThresholdMs: 300}, ErrInvalidThreshold},
+		{Config{Alias: "blog", Kind: KindAvailability, Objective: 0.99, WindowDays: 365}, ErrInvalidWindowDays},
+		{Config{Alias: "nope", Kind: KindAvailability, Objective: 0.99}, ErrAliasNotFound},
+	}
+	for _, tc := range cases {
+		if _, err := Create(db, tc.cfg, now); !errors.Is(err, tc.want) {
+			t.Errorf("Create(%+v) = %v, want %v", tc.cfg, err, tc.want)
+		}
+	}
+
+	s, err := Create(db, Config{Alias: "Blog", Kind: KindLatency, Objective: 0.99, ThresholdMs: 500}, now)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if s.Alias != "blog" || s.WindowDays != DefaultWindowDays || s.ThresholdMs != 500 {
+		t.Errorf("Unexpected SLO: %+v", s)
+	}
+	if got := s.Describe(); got != "latency 99% under 500ms (30d)" {
+		t.Errorf("Describe() = %q", got)
+	}
+
+	if err := Delete(db, s.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := Get(db, s.ID); !errors.Is(err, ErrSLONotFound) {
+		t.Errorf("Expected ErrSLONotFound, got %v", err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	avail, _ := Create(db, Config{Alias: "blog", Kind: KindAvailability, Objective: 0.99}, now)
+	latency, _ := Create(db, Config{Alias: "blog", Kind: KindLatency, Objective: 0.9, ThresholdMs: 250}, now)
+
+	// Two days ago: 1000 fast successes. Now: 10 errors and 90 slow successes.
+	record(t, db, "blog", 1000, 200, 50*time.Millisecond, now.Add(-48*time.Hour))
+	record(t, db, "blog", 10, 503, 50*time.Millisecond, now)
+	record(t, db, "blog", 90, 200, 400*time.Millisecond, now)
+
+	r, err := Evaluate(db, avail, now)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if r.Requests != 1100 || r.BadRequests != 10 {
+		t.Errorf("Expected 1100 requests and 10 bad, got %d/%d", r.Requests, r.BadRequests)
+	}
+	// 10/1100 bad against a 1% budget leaves ~9% of the budget
+	if math.Abs(r.BudgetRemaining-(1-(10.0/1100)/0.01)) > 1e-9 {
+		t.Errorf("Unexpected budget remaining: %v", r.BudgetRemaining)
+	}
+	// Last hour: 10% errors against a 1% budget
+	if math.Abs(r.BurnRates["1h"]-10) > 1e-9 {
+		t.Errorf("Expected 1h burn rate 10, got %v", r.BurnRates["1h"])
+	}
+
+	r, _ = Evaluate(db, latency, now)
+	if r.BadRequests != 90 {
+		t.Errorf("Expected 90 slow requests, got %d", r.BadRequests)
+	}
+
+	// Another alias is unaffected
+	other := &SLO{Alias: "wiki", Kind: KindAvailability, Objective: 0.99, WindowDays: 30}
+	r, _ = Evaluate(db, other, now)
+	if r.Requests != 0 || r.SLI != 1 || r.BudgetRemaining != 1 {
+		t.Errorf("Expected empty report, got %+v", r)
+	}
+}
+
+func TestControllerBurnAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	s, _ := Create(db, Config{Alias: "blog", Kind: KindAvailability, Objective: 0.99}, now)
+
+	var alerts []string
+	c := NewController(db)
+	c.notify = func(title, message string) { alerts = append(alerts, title+": "+message) }
+
+	// Too little traffic to alert, even if all of it fails
+	record(t, db, "blog", 5, 500, time.Millisecond, now)
+	c.Tick(now)
+	if len(alerts) != 0 {
+		t.Fatalf("Expected no alert on low traffic, got %v", alerts)
+	}
+
+	// 50% errors is a 50x burn
+	record(t, db, "blog", 20, 500, time.Millisecond, now)
+	record(t, db, "blog", 25, 200, time.Millisecond, now)
+	c.Tick(now)
+	if len(alerts) != 1 || !strings.HasPrefix(alerts[0], "SLO burning fast: blog availability 99% (30d)") {
+		t.Fatalf("Expected fast burn alert, got %v", alerts)
+	}
+	if got, _ := Get(db, s.ID); got.AlertState != AlertFastBurn {
+		t.Errorf("Expected fast_burn state, got %q", got.AlertState)
+	}
+
+	// Still burning: no repeat
+	c.Tick(now.Add(time.Minute))
+	if len(alerts) != 1 {
+		t.Fatalf("Expected no repeat alert, got %v", alerts)
+	}
+
+	// Healthy traffic an hour later clears the short windows
+	later := now.Add(7 * time.Hour)
+	record(t, db, "blog", 100, 200, time.Millisecond, later)
+	c.Tick(later)
+	if len(alerts) != 2 || !strings.HasPrefix(alerts[1], "SLO recovered") {
+		t.Fatalf("Expected recovery alert, got %v", alerts)
+	}
+}
+
+func TestControllerMaintenanceSuppressesAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	s, _ := Create(db, Config{Alias: "blog", Kind: KindAvailability, Objective: 0.99}, now)
+	db.Exec(`INSERT INTO status_incidents (title, severity, status) VALUES ('Upgrade', 'maintenance', 'in_progress')`)
+
+	var alerts []string
+	c := NewController(db)
+	c.notify = func(title, message string) { alerts = append(alerts, title) }
+
+	record(t, db, "blog", 50, 503, time.Millisecond, now)
+	c.Tick(now)
+	if len(alerts) != 0 {
+		t.Errorf("Expected alerts held during maintenance, got %v", alerts)
+	}
+	if got, _ := Get(db, s.ID); got.AlertState != AlertFastBurn {
+		t.Errorf("State should still track the burn, got %q", got.AlertState)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	record(t, db, "blog", 1, 200, time.Millisecond, now.AddDate(0, 0, -MaxWindowDays-1))
+	record(t, db, "blog", 1, 200, time.Millisecond, now)
+
+	if err := Prune(db, now); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM slo_rollups`).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 rollup after prune, got %d", count)
+	}
+}
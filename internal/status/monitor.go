@@ -0,0 +1,239 @@
+package status
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notifier"
+)
+
+const (
+	// probeInterval is how often every site is checked
+	probeInterval = time.Minute
+
+	// probeTimeout bounds a single probe; slower responses count as down
+	probeTimeout = 10 * time.Second
+
+	// retention is how long individual checks are kept
+	retention = 90 * 24 * time.Hour
+)
+
+type probeKey struct{}
+
+// IsProbe reports whether the request was issued by the status monitor.
+// Probes are in-process, so the marker cannot be forged by clients.
+// Analytics skips them so uptime checks don't inflate pageviews.
+func IsProbe(r *http.Request) bool {
+	return r.Context().Value(probeKey{}) != nil
+}
+
+// WithProbe marks requests made with ctx as in-process probes, for other
+// server-side requests that shouldn't count as visits, e.g. static exports
+func WithProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeKey{}, true)
+}
+
+// Monitor periodically probes every site and records the results.
+// It alerts when a site goes down or recovers, except while the site is
+// under an in-progress maintenance window.
+type Monitor struct {
+	db      *sql.DB
+	handler http.Handler
+	domain  string
+	notify  func(title, message string)
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewMonitor creates a monitor that probes sites through handler, addressing
+// them as <site>.<domain> (the root site uses the bare domain)
+func NewMonitor(db *sql.DB, handler http.Handler, domain string) *Monitor {
+	return &Monitor{
+		db:      db,
+		handler: handler,
+		domain:  domain,
+		notify: func(title, message string) {
+			if err := notifier.Send(title, message, notifier.NotificationError); err != nil {
+				log.Printf("Status: failed to send alert: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start begins the background probe loop
+func (m *Monitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(probeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.Tick(time.Now())
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the probe loop
+func (m *Monitor) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Tick opens and completes due maintenance windows, probes every site
+// once, runs due synthetic checks, alerts on state changes, and prunes old
+// checks
+func (m *Monitor) Tick(now time.Time) {
+	if _, err := AdvanceWindows(m.db, now); err != nil {
+		log.Printf("Status: failed to advance maintenance windows: %v", err)
+	}
+
+	sites, err := Sites(m.db, false)
+	if err != nil {
+		log.Printf("Status: failed to list sites: %v", err)
+		return
+	}
+
+	for _, site := range sites {
+		wasUp, checked := m.lastState(site)
+		code, elapsed := m.probe(site, "/")
+		if err := RecordCheck(m.db, site, code, elapsed, now); err != nil {
+			log.Printf("Status: failed to record check for %s: %v", site, err)
+			continue
+		}
+
+		isUp := code > 0 && code < 500
+		if !checked || isUp == wasUp {
+			continue
+		}
+		if maint, err := InMaintenance(m.db, site); err != nil || maint {
+			continue
+		}
+		if isUp {
+			m.notify("Site recovered", fmt.Sprintf("%s is back up (HTTP %d)", site, code))
+		} else {
+			reason := fmt.Sprintf("HTTP %d", code)
+			if code == 0 {
+				reason = fmt.Sprintf("no response within %s", probeTimeout)
+			}
+			m.notify("Site down", fmt.Sprintf("%s is down (%s)", site, reason))
+		}
+	}
+
+	m.runSynthetics(now)
+
+	m.db.Exec(`DELETE FROM status_checks WHERE checked_at < ?`, now.Add(-retention).Unix())
+}
+
+// lastState returns whether the site's latest recorded check was up, and
+// whether it has been checked at all
+func (m *Monitor) lastState(site string) (up bool, checked bool) {
+	var ok int
+	err := m.db.QueryRow(`SELECT ok FROM status_checks WHERE site = ? ORDER BY checked_at DESC, id DESC LIMIT 1`, site).Scan(&ok)
+	if err != nil {
+		return false, false
+	}
+	return ok == 1, true
+}
+
+// Probe requests path from a site in-process, like an uptime check, and
+// returns the status code: 0 when it timed out. App services' health checks
+// go through it.
+func (m *Monitor) Probe(site, path string) int {
+	code, _ := m.probe(site, path)
+	return code
+}
+
+// probe requests path from the site and returns the status code and
+// latency. A timed-out probe returns status 0.
+func (m *Monitor) probe(site, path string) (int, time.Duration) {
+	host := m.siteHost(site)
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	ctx = WithProbe(ctx)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil).WithContext(ctx)
+	req.Host = host
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("User-Agent", "fazt-status-monitor")
+
+	rec := &statusRecorder{}
+	start := time.Now()
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		defer func() {
+			if recover() != nil {
+				rec.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		m.handler.ServeHTTP(rec, req)
+	}()
+
+	select {
+	case <-finished:
+		return rec.code(), time.Since(start)
+	case <-ctx.Done():
+		return 0, time.Since(start)
+	}
+}
+
+// siteHost returns the public host name for a site
+func (m *Monitor) siteHost(site string) string {
+	if site == "root" {
+		return m.domain
+	}
+	return site + "." + m.domain
+}
+
+// statusRecorder captures the status code and discards the body
+type statusRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+	status int
+}
+
+func (r *statusRecorder) Header() http.Header {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.header == nil {
+		r.header = http.Header{}
+	}
+	return r.header
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
+
+func (r *statusRecorder) code() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
@@ -0,0 +1,708 @@
+// Package status powers the public status page served on status.<domain>.
+//
+// A Monitor probes every proxied site in-process on a fixed interval and
+// records the result in status_checks. Incidents are written by admins via
+// `fazt incident`; maintenance incidents can be scheduled as windows, which
+// the Monitor opens and completes on time. Summary combines both into
+// per-site uptime, daily uptime bars, and an hourly response-time sparkline.
+package status
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Incident severities
+const (
+	SeverityMinor       = "minor"
+	SeverityMajor       = "major"
+	SeverityMaintenance = "maintenance"
+)
+
+// Incident statuses. Scheduled and in_progress are used by maintenance windows.
+const (
+	IncidentInvestigating = "investigating"
+	IncidentIdentified    = "identified"
+	IncidentMonitoring    = "monitoring"
+	IncidentScheduled     = "scheduled"
+	IncidentInProgress    = "in_progress"
+	IncidentResolved      = "resolved"
+)
+
+// Overall and per-site states
+const (
+	StateOperational = "operational"
+	StateDegraded    = "degraded"
+	StateOutage      = "outage"
+	StateMaintenance = "maintenance"
+	StateUnknown     = "unknown"
+)
+
+// Summary windows
+const (
+	historyDays    = 30
+	sparklineHours = 24
+	incidentDays   = 14
+)
+
+// Common errors
+var (
+	ErrIncidentNotFound = errors.New("incident not found")
+	ErrInvalidSeverity  = errors.New("severity must be 'minor', 'major', or 'maintenance'")
+	ErrInvalidStatus    = errors.New("status must be 'investigating', 'identified', 'monitoring', 'scheduled', 'in_progress', or 'resolved'")
+	ErrMissingTitle     = errors.New("title is required")
+	ErrMissingMessage   = errors.New("message is required")
+	ErrInvalidWindow    = errors.New("only maintenance incidents can be scheduled, and the window must end after it starts")
+)
+
+// Incident is an entry in the status page incident log
+type Incident struct {
+	ID         int64            `json:"id"`
+	Title      string           `json:"title"`
+	Severity   string           `json:"severity"`
+	Status     string           `json:"status"`
+	Sites      []string         `json:"sites"`               // Affected sites, empty = whole server
+	StartsAt   *int64           `json:"starts_at,omitempty"` // Maintenance window
+	EndsAt     *int64           `json:"ends_at,omitempty"`
+	CreatedAt  int64            `json:"created_at"`
+	UpdatedAt  int64            `json:"updated_at"`
+	ResolvedAt *int64           `json:"resolved_at,omitempty"`
+	Updates    []IncidentUpdate `json:"updates"`
+}
+
+// IncidentConfig describes a new incident
+type IncidentConfig struct {
+	Title    string
+	Severity string   // Defaults to minor
+	Sites    []string // Empty = whole server
+	Message  string   // First update, defaults to the title
+
+	// Maintenance window. A zero StartsAt starts the window immediately;
+	// a zero EndsAt leaves it open until resolved by hand.
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+// Validate applies defaults and checks the configuration
+func (c *IncidentConfig) Validate() error {
+	if c.Title == "" {
+		return ErrMissingTitle
+	}
+	if c.Severity == "" {
+		c.Severity = SeverityMinor
+	}
+	if !IsValidSeverity(c.Severity) {
+		return ErrInvalidSeverity
+	}
+	if c.Message == "" {
+		c.Message = c.Title
+	}
+	if c.Severity != SeverityMaintenance && (!c.StartsAt.IsZero() || !c.EndsAt.IsZero()) {
+		return ErrInvalidWindow
+	}
+	if !c.EndsAt.IsZero() && !c.StartsAt.IsZero() && !c.EndsAt.After(c.StartsAt) {
+		return ErrInvalidWindow
+	}
+	c.Sites = normalizeSites(c.Sites)
+	return nil
+}
+
+// Affects reports whether the incident covers site
+func (inc *Incident) Affects(site string) bool {
+	if len(inc.Sites) == 0 {
+		return true
+	}
+	for _, s := range inc.Sites {
+		if s == site {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen reports whether the incident is currently in effect. Resolved
+// incidents and maintenance that hasn't started yet are not.
+func (inc *Incident) IsOpen() bool {
+	return inc.Status != IncidentResolved && inc.Status != IncidentScheduled
+}
+
+// IncidentUpdate is a timestamped message on an incident
+type IncidentUpdate struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// SiteStatus is the current state and history of one monitored site
+type SiteStatus struct {
+	Site       string     `json:"site"`
+	State      string     `json:"state"`
+	Uptime24h  float64    `json:"uptime_24h"` // Percent, -1 if no checks
+	Uptime30d  float64    `json:"uptime_30d"`
+	ResponseMs int64      `json:"response_ms"` // Latest check
+	Sparkline  []int64    `json:"sparkline"`   // Hourly average response time, oldest first (0 = no data)
+	Days       []DayStats `json:"days"`        // Daily uptime, oldest first
+	CheckedAt  int64      `json:"checked_at,omitempty"`
+}
+
+// DayStats is the uptime for one day
+type DayStats struct {
+	Date   string  `json:"date"`
+	Uptime float64 `json:"uptime"` // Percent, -1 if no checks
+	Checks int     `json:"checks"`
+}
+
+// Summary is everything the status page renders
+type Summary struct {
+	State       string       `json:"state"`
+	Sites       []SiteStatus `json:"sites"`
+	Incidents   []Incident   `json:"incidents"`
+	GeneratedAt int64        `json:"generated_at"`
+}
+
+// IsValidSeverity reports whether s is a known incident severity
+func IsValidSeverity(s string) bool {
+	return s == SeverityMinor || s == SeverityMajor || s == SeverityMaintenance
+}
+
+// IsValidStatus reports whether s is a known incident status
+func IsValidStatus(s string) bool {
+	switch s {
+	case IncidentInvestigating, IncidentIdentified, IncidentMonitoring,
+		IncidentScheduled, IncidentInProgress, IncidentResolved:
+		return true
+	}
+	return false
+}
+
+// normalizeSites trims, de-duplicates, and sorts a site list
+func normalizeSites(sites []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, s := range sites {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// CreateIncident opens an incident with its first update. Maintenance
+// with a future start is created as scheduled, otherwise as in progress.
+func CreateIncident(db *sql.DB, cfg IncidentConfig, now time.Time) (*Incident, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	status := IncidentInvestigating
+	var startsAt, endsAt interface{}
+	if cfg.Severity == SeverityMaintenance {
+		status = IncidentInProgress
+		if cfg.StartsAt.IsZero() {
+			cfg.StartsAt = now
+		}
+		if cfg.StartsAt.After(now) {
+			status = IncidentScheduled
+		}
+		startsAt = cfg.StartsAt.Unix()
+		if !cfg.EndsAt.IsZero() {
+			if !cfg.EndsAt.After(cfg.StartsAt) {
+				return nil, ErrInvalidWindow
+			}
+			endsAt = cfg.EndsAt.Unix()
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO status_incidents (title, severity, status, starts_at, ends_at) VALUES (?, ?, ?, ?, ?)`,
+		cfg.Title, cfg.Severity, status, startsAt, endsAt)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+
+	for _, site := range cfg.Sites {
+		if _, err := tx.Exec(`INSERT INTO status_incident_sites (incident_id, site) VALUES (?, ?)`, id, site); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`INSERT INTO status_incident_updates (incident_id, status, message) VALUES (?, ?, ?)`,
+		id, status, cfg.Message); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetIncident(db, id)
+}
+
+// UpdateIncident posts an update and moves the incident to the given status.
+// An empty status keeps the incident's current one.
+func UpdateIncident(db *sql.DB, id int64, status, message string) (*Incident, error) {
+	if status == "" {
+		if err := db.QueryRow(`SELECT status FROM status_incidents WHERE id = ?`, id).Scan(&status); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, ErrIncidentNotFound
+			}
+			return nil, err
+		}
+	}
+	if !IsValidStatus(status) {
+		return nil, ErrInvalidStatus
+	}
+	if message == "" {
+		return nil, ErrMissingMessage
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE status_incidents
+		SET status = ?, updated_at = unixepoch(),
+			resolved_at = CASE WHEN ? = 'resolved' THEN COALESCE(resolved_at, unixepoch()) ELSE NULL END
+		WHERE id = ?
+	`, status, status, id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrIncidentNotFound
+	}
+
+	if _, err := tx.Exec(`INSERT INTO status_incident_updates (incident_id, status, message) VALUES (?, ?, ?)`,
+		id, status, message); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return GetIncident(db, id)
+}
+
+// DeleteIncident removes an incident, its updates, and its sites
+func DeleteIncident(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM status_incidents WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrIncidentNotFound
+	}
+	db.Exec(`DELETE FROM status_incident_updates WHERE incident_id = ?`, id)
+	db.Exec(`DELETE FROM status_incident_sites WHERE incident_id = ?`, id)
+	return nil
+}
+
+// GetIncident returns an incident with its updates
+func GetIncident(db *sql.DB, id int64) (*Incident, error) {
+	inc, err := scanIncident(db.QueryRow(`
+		SELECT id, title, severity, status, starts_at, ends_at, created_at, updated_at, resolved_at
+		FROM status_incidents WHERE id = ?
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrIncidentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadDetails(db, []*Incident{inc}); err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// ListIncidents returns open incidents plus those created since the given time, newest first
+func ListIncidents(db *sql.DB, since time.Time) ([]Incident, error) {
+	rows, err := db.Query(`
+		SELECT id, title, severity, status, starts_at, ends_at, created_at, updated_at, resolved_at
+		FROM status_incidents
+		WHERE status != ? OR created_at >= ?
+		ORDER BY created_at DESC, id DESC
+	`, IncidentResolved, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Incident
+	for rows.Next() {
+		if inc, err := scanIncident(rows); err == nil {
+			list = append(list, inc)
+		}
+	}
+	rows.Close()
+
+	if err := loadDetails(db, list); err != nil {
+		return nil, err
+	}
+
+	incidents := make([]Incident, 0, len(list))
+	for _, inc := range list {
+		incidents = append(incidents, *inc)
+	}
+	return incidents, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIncident(row rowScanner) (*Incident, error) {
+	var inc Incident
+	var startsAt, endsAt, resolved sql.NullInt64
+	if err := row.Scan(&inc.ID, &inc.Title, &inc.Severity, &inc.Status, &startsAt, &endsAt,
+		&inc.CreatedAt, &inc.UpdatedAt, &resolved); err != nil {
+		return nil, err
+	}
+	if startsAt.Valid {
+		inc.StartsAt = &startsAt.Int64
+	}
+	if endsAt.Valid {
+		inc.EndsAt = &endsAt.Int64
+	}
+	if resolved.Valid {
+		inc.ResolvedAt = &resolved.Int64
+	}
+	inc.Sites = []string{}
+	inc.Updates = []IncidentUpdate{}
+	return &inc, nil
+}
+
+// loadDetails fills in the sites and updates (newest first) for each incident
+func loadDetails(db *sql.DB, incidents []*Incident) error {
+	for _, inc := range incidents {
+		rows, err := db.Query(`SELECT site FROM status_incident_sites WHERE incident_id = ? ORDER BY site`, inc.ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var site string
+			if err := rows.Scan(&site); err == nil {
+				inc.Sites = append(inc.Sites, site)
+			}
+		}
+		rows.Close()
+
+		rows, err = db.Query(`
+			SELECT status, message, created_at FROM status_incident_updates
+			WHERE incident_id = ? ORDER BY created_at DESC, id DESC
+		`, inc.ID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var u IncidentUpdate
+			if err := rows.Scan(&u.Status, &u.Message, &u.CreatedAt); err == nil {
+				inc.Updates = append(inc.Updates, u)
+			}
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// RecordCheck stores the result of one probe
+func RecordCheck(db *sql.DB, site string, statusCode int, elapsed time.Duration, at time.Time) error {
+	ok := 0
+	if statusCode > 0 && statusCode < 500 {
+		ok = 1
+	}
+	_, err := db.Exec(`INSERT INTO status_checks (site, ok, status_code, response_ms, checked_at) VALUES (?, ?, ?, ?, ?)`,
+		site, ok, statusCode, elapsed.Milliseconds(), at.Unix())
+	return err
+}
+
+// Sites returns the monitored sites: the root site plus every alias that
+// proxies to an app. publicOnly limits aliases to apps with public visibility.
+func Sites(db *sql.DB, publicOnly bool) ([]string, error) {
+	query := `
+		SELECT a.subdomain FROM aliases a
+		JOIN apps p ON p.id = json_extract(a.targets, '$.app_id')
+		WHERE a.type = 'proxy'`
+	if publicOnly {
+		query += ` AND p.visibility = 'public'`
+	}
+	query += ` ORDER BY a.subdomain`
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sites := []string{"root"}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err == nil && s != "root" {
+			sites = append(sites, s)
+		}
+	}
+	return sites, rows.Err()
+}
+
+// BuildSummary assembles the status page data for the public sites
+func BuildSummary(db *sql.DB, now time.Time) (*Summary, error) {
+	sites, err := Sites(db, true)
+	if err != nil {
+		return nil, err
+	}
+
+	incidents, err := ListIncidents(db, now.AddDate(0, 0, -incidentDays))
+	if err != nil {
+		return nil, err
+	}
+	// Only show incidents for listed sites or the whole server, and only
+	// name the listed sites
+	listed := map[string]bool{}
+	for _, s := range sites {
+		listed[s] = true
+	}
+	visible := []Incident{}
+	for _, inc := range incidents {
+		if len(inc.Sites) == 0 {
+			visible = append(visible, inc)
+			continue
+		}
+		public := []string{}
+		for _, s := range inc.Sites {
+			if listed[s] {
+				public = append(public, s)
+			}
+		}
+		if len(public) > 0 {
+			inc.Sites = public
+			visible = append(visible, inc)
+		}
+	}
+
+	summary := &Summary{
+		Sites:       make([]SiteStatus, 0, len(sites)),
+		Incidents:   visible,
+		GeneratedAt: now.Unix(),
+	}
+	for _, site := range sites {
+		st, err := siteStatus(db, site, now)
+		if err != nil {
+			return nil, err
+		}
+		if underMaintenance(visible, site) {
+			st.State = StateMaintenance
+		}
+		summary.Sites = append(summary.Sites, *st)
+	}
+
+	summary.State = overallState(summary.Sites, visible)
+	return summary, nil
+}
+
+// siteStatus computes uptime, daily bars, and the response-time sparkline for a site
+func siteStatus(db *sql.DB, site string, now time.Time) (*SiteStatus, error) {
+	st := &SiteStatus{Site: site, State: StateUnknown, Uptime24h: -1, Uptime30d: -1}
+
+	var ok int
+	var code int
+	err := db.QueryRow(`
+		SELECT ok, status_code, response_ms, checked_at FROM status_checks
+		WHERE site = ? ORDER BY checked_at DESC, id DESC LIMIT 1
+	`, site).Scan(&ok, &code, &st.ResponseMs, &st.CheckedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		st.State = StateOutage
+		if ok == 1 {
+			st.State = StateOperational
+		}
+	}
+
+	st.Uptime24h = uptimeSince(db, site, now.Add(-24*time.Hour))
+	st.Uptime30d = uptimeSince(db, site, now.AddDate(0, 0, -historyDays))
+
+	// Daily bars (UTC days)
+	today := now.UTC().Truncate(24 * time.Hour)
+	start := today.AddDate(0, 0, -(historyDays - 1))
+	byDay := map[string]DayStats{}
+	rows, err := db.Query(`
+		SELECT date(checked_at, 'unixepoch') AS day, COUNT(*), SUM(ok)
+		FROM status_checks WHERE site = ? AND checked_at >= ?
+		GROUP BY day
+	`, site, start.Unix())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var d DayStats
+		var up int
+		if err := rows.Scan(&d.Date, &d.Checks, &up); err == nil {
+			d.Uptime = percent(up, d.Checks)
+			byDay[d.Date] = d
+		}
+	}
+	rows.Close()
+
+	st.Days = make([]DayStats, 0, historyDays)
+	for i := 0; i < historyDays; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		d, found := byDay[date]
+		if !found {
+			d = DayStats{Date: date, Uptime: -1}
+		}
+		st.Days = append(st.Days, d)
+	}
+
+	// Hourly sparkline
+	hourStart := now.Truncate(time.Hour).Add(-(sparklineHours - 1) * time.Hour)
+	st.Sparkline = make([]int64, sparklineHours)
+	rows, err = db.Query(`
+		SELECT (checked_at - ?) / 3600 AS bucket, CAST(AVG(response_ms) AS INTEGER)
+		FROM status_checks WHERE site = ? AND checked_at >= ?
+		GROUP BY bucket
+	`, hourStart.Unix(), site, hourStart.Unix())
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var bucket int
+		var avg int64
+		if err := rows.Scan(&bucket, &avg); err == nil && bucket >= 0 && bucket < sparklineHours {
+			st.Sparkline[bucket] = avg
+		}
+	}
+	rows.Close()
+
+	return st, nil
+}
+
+func uptimeSince(db *sql.DB, site string, since time.Time) float64 {
+	var total, up int
+	db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(ok), 0) FROM status_checks WHERE site = ? AND checked_at >= ?`,
+		site, since.Unix()).Scan(&total, &up)
+	return percent(up, total)
+}
+
+// percent returns up/total as a percentage rounded to two decimals, or -1 with no data
+func percent(up, total int) float64 {
+	if total == 0 {
+		return -1
+	}
+	return math.Round(float64(up)*10000/float64(total)) / 100
+}
+
+// overallState derives the page headline from site states and open incidents
+func overallState(sites []SiteStatus, incidents []Incident) string {
+	// Sites under maintenance are expected to be down
+	down := 0
+	for _, s := range sites {
+		if s.State == StateOutage {
+			down++
+		}
+	}
+
+	maintenance := false
+	degraded := false
+	for _, inc := range incidents {
+		if !inc.IsOpen() {
+			continue
+		}
+		switch inc.Severity {
+		case SeverityMaintenance:
+			maintenance = true
+		case SeverityMajor:
+			if len(inc.Sites) == 0 {
+				return StateOutage
+			}
+			degraded = true
+		default:
+			degraded = true
+		}
+	}
+
+	switch {
+	case down > 0 && down == len(sites):
+		return StateOutage
+	case down > 0 || degraded:
+		return StateDegraded
+	case maintenance:
+		return StateMaintenance
+	default:
+		return StateOperational
+	}
+}
+
+// underMaintenance reports whether an in-progress maintenance incident covers site
+func underMaintenance(incidents []Incident, site string) bool {
+	for _, inc := range incidents {
+		if inc.Severity == SeverityMaintenance && inc.IsOpen() && inc.Affects(site) {
+			return true
+		}
+	}
+	return false
+}
+
+// InMaintenance reports whether site is covered by an in-progress maintenance window
+func InMaintenance(db *sql.DB, site string) (bool, error) {
+	var count int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM status_incidents i
+		WHERE i.severity = ? AND i.status NOT IN (?, ?)
+		AND (NOT EXISTS (SELECT 1 FROM status_incident_sites s WHERE s.incident_id = i.id)
+			OR EXISTS (SELECT 1 FROM status_incident_sites s WHERE s.incident_id = i.id AND s.site = ?))
+	`, SeverityMaintenance, IncidentScheduled, IncidentResolved, site).Scan(&count)
+	return count > 0, err
+}
+
+// AdvanceWindows starts scheduled maintenance whose start has passed and
+// resolves in-progress maintenance whose end has passed. It returns the
+// number of incidents changed.
+func AdvanceWindows(db *sql.DB, now time.Time) (int, error) {
+	transitions := []struct {
+		from, to, column, message string
+	}{
+		{IncidentScheduled, IncidentInProgress, "starts_at", "Scheduled maintenance is in progress."},
+		{IncidentInProgress, IncidentResolved, "ends_at", "Scheduled maintenance has been completed."},
+	}
+
+	changed := 0
+	for _, t := range transitions {
+		rows, err := db.Query(`
+			SELECT id FROM status_incidents
+			WHERE severity = ? AND status = ? AND `+t.column+` IS NOT NULL AND `+t.column+` <= ?
+		`, SeverityMaintenance, t.from, now.Unix())
+		if err != nil {
+			return changed, err
+		}
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if _, err := UpdateIncident(db, id, t.to, t.message); err != nil {
+				return changed, err
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
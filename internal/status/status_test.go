@@ -0,0 +1,354 @@
+package status
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	db.Exec(`INSERT INTO apps (id, title, visibility) VALUES ('app_blog', 'blog', 'public'), ('app_wiki', 'wiki', 'private')`)
+	db.Exec(`INSERT INTO aliases (subdomain, type, targets) VALUES
+		('blog', 'proxy', '{"app_id":"app_blog"}'),
+		('wiki', 'proxy', '{"app_id":"app_wiki"}'),
+		('old', 'redirect', '{"url":"https://example.com"}')`)
+	return db
+}
+
+func TestSites(t *testing.T) {
+	db := setupTestDB(t)
+
+	all, err := Sites(db, false)
+	if err != nil {
+		t.Fatalf("Sites failed: %v", err)
+	}
+	if len(all) != 3 || all[0] != "root" || all[1] != "blog" || all[2] != "wiki" {
+		t.Errorf("Sites(all) = %v, want [root blog wiki]", all)
+	}
+
+	public, _ := Sites(db, true)
+	if len(public) != 2 || public[1] != "blog" {
+		t.Errorf("Sites(public) = %v, want [root blog]", public)
+	}
+}
+
+func TestIncidentLifecycle(t *testing.T) {
+	db := setupTestDB(t)
+
+	now := time.Now()
+
+	if _, err := CreateIncident(db, IncidentConfig{}, now); !errors.Is(err, ErrMissingTitle) {
+		t.Errorf("Expected ErrMissingTitle, got %v", err)
+	}
+	if _, err := CreateIncident(db, IncidentConfig{Title: "Down", Severity: "critical"}, now); !errors.Is(err, ErrInvalidSeverity) {
+		t.Errorf("Expected ErrInvalidSeverity, got %v", err)
+	}
+	if _, err := CreateIncident(db, IncidentConfig{Title: "Down", StartsAt: now}, now); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("Expected ErrInvalidWindow for a scheduled non-maintenance incident, got %v", err)
+	}
+
+	inc, err := CreateIncident(db, IncidentConfig{Title: "Blog is slow", Sites: []string{"wiki", " Blog ", "blog"}}, now)
+	if err != nil {
+		t.Fatalf("CreateIncident failed: %v", err)
+	}
+	if inc.Severity != SeverityMinor || inc.Status != IncidentInvestigating || len(inc.Updates) != 1 ||
+		inc.Updates[0].Message != "Blog is slow" || inc.StartsAt != nil {
+		t.Errorf("Unexpected new incident: %+v", inc)
+	}
+	if len(inc.Sites) != 2 || inc.Sites[0] != "blog" || inc.Sites[1] != "wiki" {
+		t.Errorf("Expected sites [blog wiki], got %v", inc.Sites)
+	}
+	if !inc.Affects("blog") || inc.Affects("root") {
+		t.Errorf("Unexpected Affects results for sites %v", inc.Sites)
+	}
+
+	// Empty status keeps the current one
+	inc, err = UpdateIncident(db, inc.ID, "", "Still looking")
+	if err != nil {
+		t.Fatalf("UpdateIncident failed: %v", err)
+	}
+	if inc.Status != IncidentInvestigating || len(inc.Updates) != 2 || inc.Updates[0].Message != "Still looking" {
+		t.Errorf("Unexpected incident after update: %+v", inc)
+	}
+
+	inc, err = UpdateIncident(db, inc.ID, IncidentResolved, "Fixed")
+	if err != nil {
+		t.Fatalf("UpdateIncident failed: %v", err)
+	}
+	if inc.Status != IncidentResolved || inc.ResolvedAt == nil {
+		t.Errorf("Expected resolved incident with resolved_at, got %+v", inc)
+	}
+
+	if _, err := UpdateIncident(db, 999, IncidentMonitoring, "x"); !errors.Is(err, ErrIncidentNotFound) {
+		t.Errorf("Expected ErrIncidentNotFound, got %v", err)
+	}
+	if _, err := UpdateIncident(db, inc.ID, "fixed", "x"); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("Expected ErrInvalidStatus, got %v", err)
+	}
+
+	// Resolved incidents drop out once older than the window
+	if list, _ := ListIncidents(db, time.Now().Add(time.Hour)); len(list) != 0 {
+		t.Errorf("Expected no incidents after window, got %d", len(list))
+	}
+	if list, _ := ListIncidents(db, time.Now().Add(-time.Hour)); len(list) != 1 {
+		t.Errorf("Expected 1 recent incident, got %d", len(list))
+	}
+
+	if err := DeleteIncident(db, inc.ID); err != nil {
+		t.Fatalf("DeleteIncident failed: %v", err)
+	}
+	var sites int
+	db.QueryRow(`SELECT COUNT(*) FROM status_incident_sites`).Scan(&sites)
+	if sites != 0 {
+		t.Errorf("Expected incident sites to be deleted, got %d", sites)
+	}
+	if _, err := GetIncident(db, inc.ID); !errors.Is(err, ErrIncidentNotFound) {
+		t.Errorf("Expected deleted incident to be gone, got %v", err)
+	}
+}
+
+func TestBuildSummary(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	// root: 3 of 4 checks up in the last day; blog: never checked
+	RecordCheck(db, "root", 200, 40*time.Millisecond, now.Add(-3*time.Hour))
+	RecordCheck(db, "root", 502, 10*time.Millisecond, now.Add(-2*time.Hour))
+	RecordCheck(db, "root", 200, 60*time.Millisecond, now.Add(-time.Hour))
+	RecordCheck(db, "root", 404, 20*time.Millisecond, now)
+	RecordCheck(db, "wiki", 500, 5*time.Millisecond, now)
+
+	summary, err := BuildSummary(db, now)
+	if err != nil {
+		t.Fatalf("BuildSummary failed: %v", err)
+	}
+	if len(summary.Sites) != 2 {
+		t.Fatalf("Expected root and blog, got %+v", summary.Sites)
+	}
+
+	root := summary.Sites[0]
+	if root.State != StateOperational || root.Uptime24h != 75 || root.ResponseMs != 20 {
+		t.Errorf("Unexpected root status: state=%s uptime=%v ms=%d", root.State, root.Uptime24h, root.ResponseMs)
+	}
+	if len(root.Days) != historyDays || len(root.Sparkline) != sparklineHours {
+		t.Errorf("Unexpected history lengths: days=%d sparkline=%d", len(root.Days), len(root.Sparkline))
+	}
+	if root.Sparkline[sparklineHours-1] != 20 {
+		t.Errorf("Expected latest sparkline bucket to be 20ms, got %v", root.Sparkline)
+	}
+
+	blog := summary.Sites[1]
+	if blog.State != StateUnknown || blog.Uptime24h != -1 {
+		t.Errorf("Expected unknown blog status, got state=%s uptime=%v", blog.State, blog.Uptime24h)
+	}
+	if summary.State != StateOperational {
+		t.Errorf("Expected operational, got %s", summary.State)
+	}
+
+	// Incidents on private sites are hidden, and private sites are not named
+	CreateIncident(db, IncidentConfig{Title: "Wiki broken", Severity: SeverityMajor, Sites: []string{"wiki"}}, now)
+	if summary, _ = BuildSummary(db, now); len(summary.Incidents) != 0 || summary.State != StateOperational {
+		t.Errorf("Expected private incident to be hidden, got %d incidents, state %s", len(summary.Incidents), summary.State)
+	}
+
+	// Maintenance on blog shows as maintenance; scheduled windows don't count yet
+	CreateIncident(db, IncidentConfig{Title: "Later", Severity: SeverityMaintenance, StartsAt: now.Add(time.Hour)}, now)
+	CreateIncident(db, IncidentConfig{Title: "Blog upgrade", Severity: SeverityMaintenance, Sites: []string{"blog", "wiki"}}, now)
+	summary, _ = BuildSummary(db, now)
+	if summary.State != StateMaintenance || summary.Sites[1].State != StateMaintenance || summary.Sites[0].State != StateOperational {
+		t.Errorf("Expected blog under maintenance, got state %s, sites %+v", summary.State, summary.Sites)
+	}
+	for _, inc := range summary.Incidents {
+		if inc.Title == "Blog upgrade" && (len(inc.Sites) != 1 || inc.Sites[0] != "blog") {
+			t.Errorf("Expected only public sites to be listed, got %v", inc.Sites)
+		}
+	}
+
+	// Open server-wide major incidents mean outage
+	CreateIncident(db, IncidentConfig{Title: "Database down", Severity: SeverityMajor}, now)
+	if summary, _ = BuildSummary(db, now); len(summary.Incidents) != 3 || summary.State != StateOutage {
+		t.Errorf("Expected outage with 3 incidents, got %d incidents, state %s", len(summary.Incidents), summary.State)
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	if _, err := CreateIncident(db, IncidentConfig{
+		Title: "Backwards", Severity: SeverityMaintenance, StartsAt: now, EndsAt: now.Add(-time.Minute),
+	}, now); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("Expected ErrInvalidWindow, got %v", err)
+	}
+
+	inc, err := CreateIncident(db, IncidentConfig{
+		Title:    "DB upgrade",
+		Severity: SeverityMaintenance,
+		Sites:    []string{"blog"},
+		StartsAt: now.Add(time.Hour),
+		EndsAt:   now.Add(2 * time.Hour),
+	}, now)
+	if err != nil {
+		t.Fatalf("CreateIncident failed: %v", err)
+	}
+	if inc.Status != IncidentScheduled || inc.StartsAt == nil || inc.EndsAt == nil || inc.IsOpen() {
+		t.Fatalf("Expected scheduled window, got %+v", inc)
+	}
+
+	check := func(at time.Time, wantStatus string, wantMaint bool) {
+		t.Helper()
+		if _, err := AdvanceWindows(db, at); err != nil {
+			t.Fatalf("AdvanceWindows failed: %v", err)
+		}
+		got, _ := GetIncident(db, inc.ID)
+		if got.Status != wantStatus {
+			t.Errorf("At %s: expected status %s, got %s", at.Sub(now), wantStatus, got.Status)
+		}
+		if maint, _ := InMaintenance(db, "blog"); maint != wantMaint {
+			t.Errorf("At %s: expected InMaintenance(blog) = %v", at.Sub(now), wantMaint)
+		}
+	}
+
+	check(now.Add(30*time.Minute), IncidentScheduled, false)
+	check(now.Add(time.Hour), IncidentInProgress, true)
+	if maint, _ := InMaintenance(db, "wiki"); maint {
+		t.Error("wiki is not covered by the window")
+	}
+	check(now.Add(2*time.Hour), IncidentResolved, false)
+
+	got, _ := GetIncident(db, inc.ID)
+	if len(got.Updates) != 3 || got.ResolvedAt == nil {
+		t.Errorf("Expected 3 updates and resolved_at, got %+v", got)
+	}
+
+	// Server-wide maintenance without an end stays open until resolved by hand
+	open, _ := CreateIncident(db, IncidentConfig{Title: "Moving hosts", Severity: SeverityMaintenance}, now)
+	if open.Status != IncidentInProgress {
+		t.Errorf("Expected immediate window to be in progress, got %s", open.Status)
+	}
+	AdvanceWindows(db, now.Add(24*time.Hour))
+	if maint, _ := InMaintenance(db, "wiki"); !maint {
+		t.Error("Expected server-wide window to cover wiki")
+	}
+}
+
+func TestOverallState(t *testing.T) {
+	up := SiteStatus{State: StateOperational}
+	down := SiteStatus{State: StateOutage}
+	maint := Incident{Severity: SeverityMaintenance, Status: IncidentInvestigating}
+	minor := Incident{Severity: SeverityMinor, Status: IncidentIdentified}
+	resolved := Incident{Severity: SeverityMajor, Status: IncidentResolved}
+
+	tests := []struct {
+		name      string
+		sites     []SiteStatus
+		incidents []Incident
+		want      string
+	}{
+		{"all up", []SiteStatus{up, up}, nil, StateOperational},
+		{"resolved ignored", []SiteStatus{up}, []Incident{resolved}, StateOperational},
+		{"one down", []SiteStatus{up, down}, nil, StateDegraded},
+		{"all down", []SiteStatus{down, down}, nil, StateOutage},
+		{"minor incident", []SiteStatus{up}, []Incident{minor}, StateDegraded},
+		{"maintenance", []SiteStatus{up}, []Incident{maint}, StateMaintenance},
+		{"degraded beats maintenance", []SiteStatus{up}, []Incident{maint, minor}, StateDegraded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overallState(tt.sites, tt.incidents); got != tt.want {
+				t.Errorf("overallState() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonitorTick(t *testing.T) {
+	db := setupTestDB(t)
+
+	var probed []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsProbe(r) {
+			t.Errorf("Expected probe marker on %s", r.Host)
+		}
+		probed = append(probed, r.Host)
+		if r.Host == "wiki.example.com" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	now := time.Now()
+	db.Exec(`INSERT INTO status_checks (site, ok, status_code, response_ms, checked_at) VALUES ('root', 1, 200, 1, ?)`,
+		now.Add(-retention-time.Hour).Unix())
+
+	m := NewMonitor(db, handler, "example.com")
+	m.notify = func(title, message string) {}
+	m.Tick(now)
+
+	if len(probed) != 3 || probed[0] != "example.com" || probed[1] != "blog.example.com" {
+		t.Errorf("Unexpected probed hosts: %v", probed)
+	}
+
+	var total, up int
+	db.QueryRow(`SELECT COUNT(*), SUM(ok) FROM status_checks`).Scan(&total, &up)
+	if total != 3 || up != 2 {
+		t.Errorf("Expected 3 checks (2 up) after pruning, got %d (%d up)", total, up)
+	}
+
+	if IsProbe(httptest.NewRequest("GET", "/", nil)) {
+		t.Error("Regular requests must not be probes")
+	}
+}
+
+func TestMonitorAlerts(t *testing.T) {
+	db := setupTestDB(t)
+
+	down := map[string]bool{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down[r.Host] {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+
+	var alerts []string
+	m := NewMonitor(db, handler, "example.com")
+	m.notify = func(title, message string) { alerts = append(alerts, message) }
+
+	now := time.Now()
+	m.Tick(now)
+	if len(alerts) != 0 {
+		t.Fatalf("Expected no alerts on first check, got %v", alerts)
+	}
+
+	// blog goes down, wiki goes down under maintenance
+	CreateIncident(db, IncidentConfig{Title: "Wiki upgrade", Severity: SeverityMaintenance, Sites: []string{"wiki"}}, now)
+	down["blog.example.com"] = true
+	down["wiki.example.com"] = true
+	m.Tick(now.Add(time.Minute))
+	if len(alerts) != 1 || alerts[0] != "blog is down (HTTP 502)" {
+		t.Errorf("Expected only a blog down alert, got %v", alerts)
+	}
+
+	// Still down: no repeat alerts
+	m.Tick(now.Add(2 * time.Minute))
+	if len(alerts) != 1 {
+		t.Errorf("Expected no repeat alerts, got %v", alerts)
+	}
+
+	down["blog.example.com"] = false
+	m.Tick(now.Add(3 * time.Minute))
+	if len(alerts) != 2 || alerts[1] != "blog is back up (HTTP 200)" {
+		t.Errorf("Expected a blog recovery alert, got %v", alerts)
+	}
+}
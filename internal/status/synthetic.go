@@ -0,0 +1,349 @@
+package status
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+const (
+	// DefaultSyntheticInterval is how often a synthetic check runs by default
+	DefaultSyntheticInterval = 5 * time.Minute
+
+	// DefaultSyntheticTimeout bounds a synthetic run by default
+	DefaultSyntheticTimeout = 30 * time.Second
+
+	minSyntheticInterval = time.Minute
+	maxSyntheticTimeout  = 5 * time.Minute
+
+	// syntheticRunsKept is how many runs are kept per check
+	syntheticRunsKept = 500
+)
+
+// Synthetic check errors
+var (
+	ErrSyntheticNotFound = errors.New("synthetic check not found")
+	ErrSyntheticExists   = errors.New("a synthetic check with that name already exists for the site")
+	ErrInvalidSynthetic  = errors.New("site, name, and a .js script path are required")
+	ErrInvalidSchedule   = errors.New("interval must be at least 1m and timeout between 1s and 5m (and shorter than the interval)")
+	ErrScriptNotFound    = errors.New("script not found in the site's files (deploy it first)")
+)
+
+// Synthetic is a scripted transaction check. The script is a worker handler
+// in the site's files; it uses fazt.net.fetch() against job.data.url and
+// throws when an assertion fails. A run passes when the job completes.
+type Synthetic struct {
+	ID              int64         `json:"id"`
+	Site            string        `json:"site"`
+	Name            string        `json:"name"`
+	Script          string        `json:"script"`
+	IntervalSeconds int64         `json:"interval_seconds"`
+	TimeoutSeconds  int64         `json:"timeout_seconds"`
+	Enabled         bool          `json:"enabled"`
+	LastRunAt       *int64        `json:"last_run_at,omitempty"`
+	CreatedAt       int64         `json:"created_at"`
+	LastRun         *SyntheticRun `json:"last_run,omitempty"`
+}
+
+// SyntheticRun is the outcome of one run
+type SyntheticRun struct {
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	RanAt      int64  `json:"ran_at"`
+}
+
+// SyntheticConfig describes a new synthetic check
+type SyntheticConfig struct {
+	Site     string
+	Name     string
+	Script   string
+	Interval time.Duration // default 5m
+	Timeout  time.Duration // default 30s
+}
+
+// Validate checks the config and fills in defaults
+func (c *SyntheticConfig) Validate() error {
+	c.Site = strings.ToLower(strings.TrimSpace(c.Site))
+	c.Name = strings.TrimSpace(c.Name)
+	c.Script = strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(c.Script)), "/")
+	if c.Site == "" || c.Name == "" || !strings.HasSuffix(c.Script, ".js") {
+		return ErrInvalidSynthetic
+	}
+	if c.Interval == 0 {
+		c.Interval = DefaultSyntheticInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultSyntheticTimeout
+	}
+	if c.Interval < minSyntheticInterval || c.Timeout < time.Second || c.Timeout > maxSyntheticTimeout || c.Timeout >= c.Interval {
+		return ErrInvalidSchedule
+	}
+	return nil
+}
+
+// CreateSynthetic adds a synthetic check. The first run happens on the next
+// monitor tick.
+func CreateSynthetic(db *sql.DB, cfg SyntheticConfig, now time.Time) (*Synthetic, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	db.QueryRow(`SELECT EXISTS(SELECT 1 FROM files WHERE site_id = ? AND path = ?)`, cfg.Site, cfg.Script).Scan(&exists)
+	if !exists {
+		return nil, ErrScriptNotFound
+	}
+
+	res, err := db.Exec(`
+		INSERT INTO status_synthetics (site, name, script, interval_seconds, timeout_seconds, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, cfg.Site, cfg.Name, cfg.Script, int64(cfg.Interval.Seconds()), int64(cfg.Timeout.Seconds()), now.Unix())
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, ErrSyntheticExists
+		}
+		return nil, err
+	}
+
+	id, _ := res.LastInsertId()
+	return GetSynthetic(db, id)
+}
+
+// DeleteSynthetic removes a synthetic check and its runs
+func DeleteSynthetic(db *sql.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM status_synthetics WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSyntheticNotFound
+	}
+	db.Exec(`DELETE FROM status_synthetic_runs WHERE synthetic_id = ?`, id)
+	return nil
+}
+
+// GetSynthetic returns a synthetic check with its latest run
+func GetSynthetic(db *sql.DB, id int64) (*Synthetic, error) {
+	s, err := scanSynthetic(db.QueryRow(syntheticSelect+` WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrSyntheticNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.LastRun, _ = lastSyntheticRun(db, s.ID)
+	return s, nil
+}
+
+// ListSynthetics returns all synthetic checks with their latest run
+func ListSynthetics(db *sql.DB) ([]Synthetic, error) {
+	rows, err := db.Query(syntheticSelect + ` ORDER BY site, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []Synthetic{}
+	for rows.Next() {
+		s, err := scanSynthetic(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range checks {
+		checks[i].LastRun, _ = lastSyntheticRun(db, checks[i].ID)
+	}
+	return checks, nil
+}
+
+// ListSyntheticRuns returns a check's runs, newest first
+func ListSyntheticRuns(db *sql.DB, id int64, limit int) ([]SyntheticRun, error) {
+	rows, err := db.Query(`
+		SELECT ok, COALESCE(error, ''), COALESCE(duration_ms, 0), ran_at FROM status_synthetic_runs
+		WHERE synthetic_id = ? ORDER BY ran_at DESC, id DESC LIMIT ?
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []SyntheticRun{}
+	for rows.Next() {
+		var run SyntheticRun
+		if err := rows.Scan(&run.OK, &run.Error, &run.DurationMs, &run.RanAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+const syntheticSelect = `
+	SELECT id, site, name, script, interval_seconds, timeout_seconds, enabled, last_run_at, created_at
+	FROM status_synthetics`
+
+// syntheticRow adds the in-flight job to a scanned check
+type syntheticRow struct {
+	Synthetic
+	jobID string
+}
+
+func scanSynthetic(row rowScanner) (*Synthetic, error) {
+	var s Synthetic
+	var lastRun sql.NullInt64
+	if err := row.Scan(&s.ID, &s.Site, &s.Name, &s.Script, &s.IntervalSeconds, &s.TimeoutSeconds,
+		&s.Enabled, &lastRun, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if lastRun.Valid {
+		s.LastRunAt = &lastRun.Int64
+	}
+	return &s, nil
+}
+
+func lastSyntheticRun(db *sql.DB, id int64) (*SyntheticRun, error) {
+	runs, err := ListSyntheticRuns(db, id, 1)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return &runs[0], nil
+}
+
+// recordSyntheticRun stores a run, clears the in-flight job, and trims old runs
+func recordSyntheticRun(db *sql.DB, id int64, run SyntheticRun) error {
+	var errMsg interface{}
+	if run.Error != "" {
+		errMsg = run.Error
+	}
+	if _, err := db.Exec(`
+		INSERT INTO status_synthetic_runs (synthetic_id, ok, error, duration_ms, ran_at) VALUES (?, ?, ?, ?, ?)
+	`, id, run.OK, errMsg, run.DurationMs, run.RanAt); err != nil {
+		return err
+	}
+	db.Exec(`UPDATE status_synthetics SET job_id = NULL WHERE id = ?`, id)
+	db.Exec(`
+		DELETE FROM status_synthetic_runs WHERE synthetic_id = ? AND id NOT IN (
+			SELECT id FROM status_synthetic_runs WHERE synthetic_id = ? ORDER BY ran_at DESC, id DESC LIMIT ?)
+	`, id, id, syntheticRunsKept)
+	return nil
+}
+
+// runSynthetics collects finished runs, alerting when a check starts failing
+// or recovers, then starts runs that are due
+func (m *Monitor) runSynthetics(now time.Time) {
+	rows, err := m.db.Query(`
+		SELECT id, site, name, script, interval_seconds, timeout_seconds, enabled, last_run_at, created_at, COALESCE(job_id, '')
+		FROM status_synthetics WHERE enabled = 1 OR job_id IS NOT NULL
+	`)
+	if err != nil {
+		return
+	}
+	var checks []syntheticRow
+	for rows.Next() {
+		var c syntheticRow
+		var lastRun sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Site, &c.Name, &c.Script, &c.IntervalSeconds, &c.TimeoutSeconds,
+			&c.Enabled, &lastRun, &c.CreatedAt, &c.jobID); err == nil {
+			if lastRun.Valid {
+				c.LastRunAt = &lastRun.Int64
+			}
+			checks = append(checks, c)
+		}
+	}
+	rows.Close()
+
+	for _, c := range checks {
+		if c.jobID != "" {
+			m.collectSynthetic(&c, now)
+			continue
+		}
+		if !c.Enabled || (c.LastRunAt != nil && now.Unix() < *c.LastRunAt+c.IntervalSeconds) {
+			continue
+		}
+		m.startSynthetic(&c, now)
+	}
+}
+
+// startSynthetic spawns a worker job for the check's script
+func (m *Monitor) startSynthetic(c *syntheticRow, now time.Time) {
+	timeout := time.Duration(c.TimeoutSeconds) * time.Second
+	cfg := worker.DefaultJobConfig()
+	cfg.Timeout = &timeout
+	cfg.UniqueKey = fmt.Sprintf("synthetic:%d", c.ID)
+	cfg.Data = map[string]interface{}{
+		"check": c.Name,
+		"site":  c.Site,
+		"url":   "https://" + m.siteHost(c.Site),
+	}
+
+	job, err := worker.Spawn(c.Site, c.Script, cfg)
+	if err != nil {
+		// Count a failure to start as a failed run so a broken setup alerts
+		m.finishSynthetic(c, SyntheticRun{OK: false, Error: "failed to start: " + err.Error(), RanAt: now.Unix()})
+		m.db.Exec(`UPDATE status_synthetics SET last_run_at = ? WHERE id = ?`, now.Unix(), c.ID)
+		return
+	}
+	m.db.Exec(`UPDATE status_synthetics SET job_id = ?, last_run_at = ? WHERE id = ?`, job.ID, now.Unix(), c.ID)
+}
+
+// collectSynthetic records the outcome of a check's in-flight job, if done
+func (m *Monitor) collectSynthetic(c *syntheticRow, now time.Time) {
+	run := SyntheticRun{RanAt: now.Unix()}
+
+	job, err := worker.Get(c.jobID)
+	if err != nil {
+		run.Error = "run lost (server restarted?)"
+		m.finishSynthetic(c, run)
+		return
+	}
+
+	status, errMsg, startedAt, doneAt := job.Outcome()
+	if status == worker.StatusPending || status == worker.StatusRunning {
+		return
+	}
+	run.OK = status == worker.StatusDone
+	run.Error = errMsg
+	if !startedAt.IsZero() {
+		run.RanAt = startedAt.Unix()
+		if !doneAt.IsZero() {
+			run.DurationMs = doneAt.Sub(startedAt).Milliseconds()
+		}
+	}
+	if !run.OK && run.Error == "" {
+		run.Error = "run " + string(status)
+	}
+
+	m.finishSynthetic(c, run)
+}
+
+// finishSynthetic records a run and alerts when the check changes state
+func (m *Monitor) finishSynthetic(c *syntheticRow, run SyntheticRun) {
+	prev, _ := lastSyntheticRun(m.db, c.ID)
+	if err := recordSyntheticRun(m.db, c.ID, run); err != nil {
+		return
+	}
+
+	if prev == nil && run.OK || prev != nil && prev.OK == run.OK {
+		return
+	}
+	if maint, err := InMaintenance(m.db, c.Site); err != nil || maint {
+		return
+	}
+	if run.OK {
+		m.notify("Synthetic check recovered", fmt.Sprintf("%s on %s is passing again", c.Name, c.Site))
+	} else {
+		m.notify("Synthetic check failing", fmt.Sprintf("%s on %s failed: %s", c.Name, c.Site, run.Error))
+	}
+}
@@ -0,0 +1,153 @@
+package status
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/worker"
+)
+
+func TestCreateSynthetic(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Now()
+
+	if _, err := CreateSynthetic(db, SyntheticConfig{Site: "blog", Name: "login", Script: "checks/login.js"}, now); !errors.Is(err, ErrScriptNotFound) {
+		t.Fatalf("Expected ErrScriptNotFound, got %v", err)
+	}
+
+	db.Exec(`INSERT INTO files (site_id, path, content, size_bytes, hash) VALUES ('blog', 'checks/login.js', 'ok', 2, 'h')`)
+	s, err := CreateSynthetic(db, SyntheticConfig{Site: "Blog", Name: "login", Script: "/checks/login.js"}, now)
+	if err != nil {
+		t.Fatalf("CreateSynthetic failed: %v", err)
+	}
+	if s.Site != "blog" || s.Script != "checks/login.js" || s.IntervalSeconds != 300 || s.TimeoutSeconds != 30 || !s.Enabled {
+		t.Errorf("Unexpected check: %+v", s)
+	}
+
+	if _, err := CreateSynthetic(db, SyntheticConfig{Site: "blog", Name: "login", Script: "checks/login.js"}, now); !errors.Is(err, ErrSyntheticExists) {
+		t.Errorf("Expected ErrSyntheticExists, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  SyntheticConfig
+		want error
+	}{
+		{"missing name", SyntheticConfig{Site: "blog", Script: "checks/login.js"}, ErrInvalidSynthetic},
+		{"not js", SyntheticConfig{Site: "blog", Name: "x", Script: "index.html"}, ErrInvalidSynthetic},
+		{"interval too short", SyntheticConfig{Site: "blog", Name: "x", Script: "a.js", Interval: 30 * time.Second}, ErrInvalidSchedule},
+		{"timeout over interval", SyntheticConfig{Site: "blog", Name: "x", Script: "a.js", Interval: time.Minute, Timeout: 2 * time.Minute}, ErrInvalidSchedule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); !errors.Is(err, tt.want) {
+				t.Errorf("Validate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := DeleteSynthetic(db, s.ID); err != nil {
+		t.Errorf("DeleteSynthetic failed: %v", err)
+	}
+	if _, err := GetSynthetic(db, s.ID); !errors.Is(err, ErrSyntheticNotFound) {
+		t.Errorf("Expected ErrSyntheticNotFound after delete, got %v", err)
+	}
+}
+
+func TestMonitorSynthetics(t *testing.T) {
+	db := setupTestDB(t)
+
+	worker.InitWithConfig(db, worker.DefaultPoolConfig())
+	t.Cleanup(func() { worker.Shutdown(context.Background()) })
+
+	var failing atomic.Bool
+	var gotURL atomic.Value
+	worker.SetExecutor(func(ctx context.Context, job *worker.Job, code string) (interface{}, error) {
+		gotURL.Store(job.Config.Data["url"])
+		if failing.Load() {
+			return nil, errors.New("cart is empty")
+		}
+		return nil, nil
+	})
+
+	db.Exec(`INSERT INTO files (site_id, path, content, size_bytes, hash) VALUES ('blog', 'checks/cart.js', 'ok', 2, 'h')`)
+	check, err := CreateSynthetic(db, SyntheticConfig{Site: "blog", Name: "cart", Script: "checks/cart.js", Interval: time.Minute, Timeout: 10 * time.Second}, time.Now())
+	if err != nil {
+		t.Fatalf("CreateSynthetic failed: %v", err)
+	}
+
+	var alerts []string
+	m := NewMonitor(db, http.NotFoundHandler(), "example.com")
+	m.notify = func(title, message string) { alerts = append(alerts, title) }
+
+	// run starts the check on one tick and collects it on the next
+	now := time.Now()
+	run := func() SyntheticRun {
+		t.Helper()
+		m.runSynthetics(now)
+		var jobID string
+		db.QueryRow(`SELECT COALESCE(job_id, '') FROM status_synthetics WHERE id = ?`, check.ID).Scan(&jobID)
+		if jobID == "" {
+			t.Fatal("Expected a job to be started")
+		}
+		waitForJob(t, jobID)
+		m.runSynthetics(now)
+		now = now.Add(time.Minute)
+
+		c, _ := GetSynthetic(db, check.ID)
+		if c.LastRun == nil {
+			t.Fatal("Expected a recorded run")
+		}
+		return *c.LastRun
+	}
+
+	if r := run(); !r.OK {
+		t.Errorf("Expected a passing run, got %+v", r)
+	}
+	if gotURL.Load() != "https://blog.example.com" {
+		t.Errorf("Expected job.data.url for the site, got %v", gotURL.Load())
+	}
+
+	// Not due yet: nothing starts
+	m.runSynthetics(now.Add(-30 * time.Second))
+	var jobID string
+	db.QueryRow(`SELECT COALESCE(job_id, '') FROM status_synthetics WHERE id = ?`, check.ID).Scan(&jobID)
+	if jobID != "" {
+		t.Error("Expected no run before the interval elapses")
+	}
+
+	failing.Store(true)
+	if r := run(); r.OK || r.Error != "cart is empty" {
+		t.Errorf("Expected a failing run, got %+v", r)
+	}
+	run()
+	failing.Store(false)
+	run()
+
+	if len(alerts) != 2 || alerts[0] != "Synthetic check failing" || alerts[1] != "Synthetic check recovered" {
+		t.Errorf("Expected failing and recovered alerts, got %v", alerts)
+	}
+
+	runs, _ := ListSyntheticRuns(db, check.ID, 10)
+	if len(runs) != 4 {
+		t.Errorf("Expected 4 runs, got %d", len(runs))
+	}
+}
+
+func waitForJob(t *testing.T, jobID string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if job, err := worker.Get(jobID); err == nil {
+			if status, _, _, _ := job.Outcome(); status != worker.StatusPending && status != worker.StatusRunning {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Job %s did not finish", jobID)
+}
@@ -4,12 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/dop251/goja"
-	"github.com/fazt-sh/fazt/internal/debug"
 	"github.com/fazt-sh/fazt/internal/services/media"
 	"github.com/fazt-sh/fazt/internal/timeout"
 )
@@ -60,6 +61,9 @@ func InjectAppNamespace(vm *goja.Runtime, db *sql.DB, writer *WriteQueue, appID,
 	dsObj.Set("update", makeDSUpdate(vm, storage.Docs, appID, ctx, budget))
 	dsObj.Set("delete", makeDSDelete(vm, storage.Docs, appID, ctx, budget))
 	dsObj.Set("count", makeDSCount(vm, storage.Docs, appID, ctx, budget))
+	dsObj.Set("enableSearch", makeDSEnableSearch(vm, storage.Docs, appID, ctx, budget))
+	dsObj.Set("disableSearch", makeDSDisableSearch(vm, storage.Docs, appID, ctx, budget))
+	dsObj.Set("search", makeDSSearch(vm, storage.Docs, appID, ctx, budget))
 	appObj.Set("ds", dsObj)
 
 	// fazt.app.s3 (shared)
@@ -75,6 +79,10 @@ func InjectAppNamespace(vm *goja.Runtime, db *sql.DB, writer *WriteQueue, appID,
 	mediaObj.Set("serve", makeMediaServe(vm, storage.Blobs, appID, db, ctx, budget))
 	mediaObj.Set("probe", makeMediaProbe(vm))
 	mediaObj.Set("transcode", makeMediaTranscode(vm, storage.Blobs, appID, ctx, budget))
+	sharedBlob := func(ctx context.Context, path string) (*Blob, error) { return storage.Blobs.Get(ctx, appID, path) }
+	mediaObj.Set("thumbnail", makeMediaThumbnail(vm, sharedBlob, media.NewMediaCache(db), appID, ctx, budget))
+	mediaObj.Set("waveform", makeMediaWaveform(vm, sharedBlob, media.NewMediaCache(db), appID, ctx, budget))
+	mediaObj.Set("preview", makeMediaPreview(vm, sharedBlob, media.NewMediaCache(db), appID, ctx, budget))
 	appObj.Set("media", mediaObj)
 
 	// Create user-scoped storage: fazt.app.user.*
@@ -102,6 +110,9 @@ func InjectAppNamespace(vm *goja.Runtime, db *sql.DB, writer *WriteQueue, appID,
 		userDSObj.Set("update", makeUserDSUpdate(vm, userDocs, ctx, budget))
 		userDSObj.Set("delete", makeUserDSDelete(vm, userDocs, ctx, budget))
 		userDSObj.Set("count", makeUserDSCount(vm, userDocs, ctx, budget))
+		userDSObj.Set("enableSearch", makeUserDSEnableSearch(vm, userDocs, ctx, budget))
+		userDSObj.Set("disableSearch", makeUserDSDisableSearch(vm, userDocs, ctx, budget))
+		userDSObj.Set("search", makeUserDSSearch(vm, userDocs, ctx, budget))
 		userObj.Set("ds", userDSObj)
 
 		// fazt.app.user.s3
@@ -117,6 +128,9 @@ func InjectAppNamespace(vm *goja.Runtime, db *sql.DB, writer *WriteQueue, appID,
 		userMediaObj.Set("serve", makeUserMediaServe(vm, userBlobs, appID, userID, db, ctx, budget))
 		userMediaObj.Set("probe", makeMediaProbe(vm))
 		userMediaObj.Set("transcode", makeUserMediaTranscode(vm, userBlobs, appID, ctx, budget))
+		userMediaObj.Set("thumbnail", makeMediaThumbnail(vm, userBlobs.Get, media.NewUserMediaCache(db, userID), appID, ctx, budget))
+		userMediaObj.Set("waveform", makeMediaWaveform(vm, userBlobs.Get, media.NewUserMediaCache(db, userID), appID, ctx, budget))
+		userMediaObj.Set("preview", makeMediaPreview(vm, userBlobs.Get, media.NewUserMediaCache(db, userID), appID, ctx, budget))
 		userObj.Set("media", userMediaObj)
 	} else {
 		// User not logged in - create stub bindings that throw errors
@@ -140,6 +154,9 @@ func InjectAppNamespace(vm *goja.Runtime, db *sql.DB, writer *WriteQueue, appID,
 		userDSObj.Set("update", stubFunc("ds.update"))
 		userDSObj.Set("delete", stubFunc("ds.delete"))
 		userDSObj.Set("count", stubFunc("ds.count"))
+		userDSObj.Set("enableSearch", stubFunc("ds.enableSearch"))
+		userDSObj.Set("disableSearch", stubFunc("ds.disableSearch"))
+		userDSObj.Set("search", stubFunc("ds.search"))
 		userObj.Set("ds", userDSObj)
 
 		userS3Obj := vm.NewObject()
@@ -295,7 +312,7 @@ func makeUserDSInsert(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context,
 		}
 
 		id, err := ds.Insert(opCtx, collection, doc)
-		debug.StorageOp("user.insert", ds.appID, collection, doc, 1, time.Since(start))
+		trackOp("user.insert", ds.appID, collection, doc, 1, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -347,7 +364,7 @@ func makeUserDSFind(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context, b
 		}
 
 		docs, err := ds.FindWithOptions(opCtx, collection, query, opts)
-		debug.StorageOp("user.find", ds.appID, collection, query, int64(len(docs)), time.Since(start))
+		trackOp("user.find", ds.appID, collection, query, int64(len(docs)), time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -396,7 +413,7 @@ func makeUserDSFindOne(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context
 		if doc != nil {
 			rows = 1
 		}
-		debug.StorageOp("user.findOne", ds.appID, collection, query, rows, time.Since(start))
+		trackOp("user.findOne", ds.appID, collection, query, rows, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -440,7 +457,7 @@ func makeUserDSUpdate(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context,
 		}
 
 		count, err := ds.Update(opCtx, collection, query, changes)
-		debug.StorageOp("user.update", ds.appID, collection, query, count, time.Since(start))
+		trackOp("user.update", ds.appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -470,7 +487,7 @@ func makeUserDSDelete(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context,
 		}
 
 		count, err := ds.Delete(opCtx, collection, query)
-		debug.StorageOp("user.delete", ds.appID, collection, query, count, time.Since(start))
+		trackOp("user.delete", ds.appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -502,7 +519,7 @@ func makeUserDSCount(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context,
 		}
 
 		count, err := ds.Count(opCtx, collection, query)
-		debug.StorageOp("user.count", ds.appID, collection, query, count, time.Since(start))
+		trackOp("user.count", ds.appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -511,6 +528,213 @@ func makeUserDSCount(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context,
 	}
 }
 
+// Search bindings
+
+func makeDSEnableSearch(vm *goja.Runtime, ds DocStore, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		start := time.Now()
+		collection, fields := enableSearchArgs(vm, call)
+
+		sqlDS, ok := ds.(*SQLDocStore)
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("ds.enableSearch requires SQLDocStore")))
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		err = sqlDS.EnableSearch(opCtx, appID, collection, fields)
+		trackOp("enableSearch", appID, collection, fields, 0, time.Since(start))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return goja.Undefined()
+	}
+}
+
+func makeDSDisableSearch(vm *goja.Runtime, ds DocStore, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("ds.disableSearch requires collection")))
+		}
+
+		sqlDS, ok := ds.(*SQLDocStore)
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("ds.disableSearch requires SQLDocStore")))
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		if err := sqlDS.DisableSearch(opCtx, appID, call.Argument(0).String()); err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return goja.Undefined()
+	}
+}
+
+func makeDSSearch(vm *goja.Runtime, ds DocStore, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		start := time.Now()
+		collection, query, opts := searchArgs(vm, call)
+
+		sqlDS, ok := ds.(*SQLDocStore)
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("ds.search requires SQLDocStore")))
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		results, err := sqlDS.Search(opCtx, appID, collection, query, opts)
+		trackOp("search", appID, collection, query, int64(len(results)), time.Since(start))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return searchResultsValue(vm, results)
+	}
+}
+
+func makeUserDSEnableSearch(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		start := time.Now()
+		collection, fields := enableSearchArgs(vm, call)
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		err = ds.EnableSearch(opCtx, collection, fields)
+		trackOp("user.enableSearch", ds.appID, collection, fields, 0, time.Since(start))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return goja.Undefined()
+	}
+}
+
+func makeUserDSDisableSearch(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("ds.disableSearch requires collection")))
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		if err := ds.DisableSearch(opCtx, call.Argument(0).String()); err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return goja.Undefined()
+	}
+}
+
+func makeUserDSSearch(vm *goja.Runtime, ds *UserScopedDocs, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		start := time.Now()
+		collection, query, opts := searchArgs(vm, call)
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		results, err := ds.Search(opCtx, collection, query, opts)
+		trackOp("user.search", ds.appID, collection, query, int64(len(results)), time.Since(start))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+
+		return searchResultsValue(vm, results)
+	}
+}
+
+// enableSearchArgs reads ds.enableSearch(collection, fields)
+func enableSearchArgs(vm *goja.Runtime, call goja.FunctionCall) (string, []string) {
+	if len(call.Arguments) < 2 {
+		panic(vm.NewGoError(fmt.Errorf("ds.enableSearch requires collection and fields")))
+	}
+
+	list, ok := call.Argument(1).Export().([]interface{})
+	if !ok {
+		panic(vm.NewGoError(fmt.Errorf("ds.enableSearch requires an array of field names")))
+	}
+	fields := make([]string, len(list))
+	for i, f := range list {
+		name, ok := f.(string)
+		if !ok {
+			panic(vm.NewGoError(fmt.Errorf("ds.enableSearch field names must be strings")))
+		}
+		fields[i] = name
+	}
+
+	return call.Argument(0).String(), fields
+}
+
+// searchArgs reads ds.search(collection, query, { limit, offset, raw })
+func searchArgs(vm *goja.Runtime, call goja.FunctionCall) (string, string, *SearchOptions) {
+	if len(call.Arguments) < 2 {
+		panic(vm.NewGoError(fmt.Errorf("ds.search requires collection and query")))
+	}
+
+	opts := &SearchOptions{}
+	if len(call.Arguments) >= 3 && !goja.IsUndefined(call.Argument(2)) && !goja.IsNull(call.Argument(2)) {
+		if o, ok := call.Argument(2).Export().(map[string]interface{}); ok {
+			if limit, ok := o["limit"].(int64); ok {
+				opts.Limit = int(limit)
+			} else if limit, ok := o["limit"].(float64); ok {
+				opts.Limit = int(limit)
+			}
+			if offset, ok := o["offset"].(int64); ok {
+				opts.Offset = int(offset)
+			} else if offset, ok := o["offset"].(float64); ok {
+				opts.Offset = int(offset)
+			}
+			if raw, ok := o["raw"].(bool); ok {
+				opts.Raw = raw
+			}
+		}
+	}
+
+	return call.Argument(0).String(), call.Argument(1).String(), opts
+}
+
+// searchResultsValue converts results to documents as ds.find returns
+// them, plus _score and _snippet
+func searchResultsValue(vm *goja.Runtime, results []SearchResult) goja.Value {
+	out := make([]interface{}, len(results))
+	for i, r := range results {
+		obj := r.Data
+		obj["id"] = r.ID
+		obj["_createdAt"] = r.CreatedAt.UnixMilli()
+		obj["_updatedAt"] = r.UpdatedAt.UnixMilli()
+		obj["_score"] = r.Score
+		obj["_snippet"] = r.Snippet
+		out[i] = obj
+	}
+	return vm.ToValue(out)
+}
+
 // User S3 bindings
 
 func makeUserS3Put(vm *goja.Runtime, blobs *UserScopedBlobs, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
@@ -651,14 +875,14 @@ func makeUserS3List(vm *goja.Runtime, blobs *UserScopedBlobs, ctx context.Contex
 // Media cache invalidation helpers
 
 // makeS3PutWithMediaInvalidation wraps makeS3Put to invalidate media cache
-// when an image blob is overwritten.
+// when an image, video, audio or document blob is overwritten.
 func makeS3PutWithMediaInvalidation(vm *goja.Runtime, blobs BlobStore, appID string, db *sql.DB, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
 	inner := makeS3Put(vm, blobs, appID, ctx, budget)
 	return func(call goja.FunctionCall) goja.Value {
 		result := inner(call)
 		if len(call.Arguments) >= 3 && !goja.IsUndefined(call.Argument(2)) {
 			mime := call.Argument(2).String()
-			if strings.HasPrefix(mime, "image/") {
+			if strings.HasPrefix(mime, "image/") || media.IsVideoContentType(mime) || media.IsAudioContentType(mime) || media.IsDocumentContentType(mime) {
 				path := call.Argument(0).String()
 				media.InvalidateForPath(db, appID, path, "")
 			}
@@ -668,14 +892,14 @@ func makeS3PutWithMediaInvalidation(vm *goja.Runtime, blobs BlobStore, appID str
 }
 
 // makeUserS3PutWithMediaInvalidation wraps makeUserS3Put to invalidate media cache
-// when an image blob is overwritten.
+// when an image, video, audio or document blob is overwritten.
 func makeUserS3PutWithMediaInvalidation(vm *goja.Runtime, blobs *UserScopedBlobs, appID, userID string, db *sql.DB, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
 	inner := makeUserS3Put(vm, blobs, ctx, budget)
 	return func(call goja.FunctionCall) goja.Value {
 		result := inner(call)
 		if len(call.Arguments) >= 3 && !goja.IsUndefined(call.Argument(2)) {
 			mime := call.Argument(2).String()
-			if strings.HasPrefix(mime, "image/") {
+			if strings.HasPrefix(mime, "image/") || media.IsVideoContentType(mime) || media.IsAudioContentType(mime) || media.IsDocumentContentType(mime) {
 				path := call.Argument(0).String()
 				media.InvalidateForPath(db, appID, path, userID)
 			}
@@ -718,6 +942,8 @@ func makeUserS3DeleteWithMediaInvalidation(vm *goja.Runtime, blobs *UserScopedBl
 // Reads transform opts from HTTP query params (via context).
 // On cache hit → returns cached variant. On miss → fetches original, resizes, caches.
 // No transform params → returns original unchanged.
+// ?hls= → returns a file of the video's HLS renditions (see media.HLSFileFromQuery).
+// ?preview → returns a document's first page as a PNG (see media.PreviewFromQuery).
 func makeMediaServe(vm *goja.Runtime, blobs BlobStore, appID string, db *sql.DB, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
 	return func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
@@ -732,6 +958,34 @@ func makeMediaServe(vm *goja.Runtime, blobs BlobStore, appID string, db *sql.DB,
 
 		path := call.Argument(0).String()
 
+		// ?hls= → a file of the video's HLS renditions; null until transcoded
+		if file, ok := media.HLSFileFromQuery(media.QueryFromContext(ctx)); ok {
+			if file == "" {
+				return goja.Null()
+			}
+			hls, err := blobs.Get(opCtx, appID, media.HLSPath(path, file))
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			if hls == nil {
+				return goja.Null()
+			}
+			return vm.ToValue(map[string]interface{}{
+				"data": base64.StdEncoding.EncodeToString(hls.Data),
+				"mime": hls.MimeType,
+				"size": hls.Size,
+			})
+		}
+
+		// ?preview → a document's first page; null for other files
+		if size, ok := media.PreviewFromQuery(media.QueryFromContext(ctx)); ok {
+			blob, err := blobs.Get(opCtx, appID, path)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return documentPreview(vm, opCtx, blob, media.NewMediaCache(db), appID, path, size)
+		}
+
 		// Parse transform opts from HTTP query params
 		opts := media.TransformOpts{}
 		if q := media.QueryFromContext(ctx); q != nil {
@@ -838,6 +1092,7 @@ func makeMediaTranscode(vm *goja.Runtime, blobs BlobStore, appID string, ctx con
 		result := media.QueueTranscode(appID, path, blob.Data, blob.MimeType, storeFunc)
 		return vm.ToValue(map[string]interface{}{
 			"status": result.Status,
+			"hls":    result.HLS,
 		})
 	}
 }
@@ -872,10 +1127,163 @@ func makeUserMediaTranscode(vm *goja.Runtime, blobs *UserScopedBlobs, appID stri
 		result := media.QueueTranscode(appID, path, blob.Data, blob.MimeType, storeFunc)
 		return vm.ToValue(map[string]interface{}{
 			"status": result.Status,
+			"hls":    result.HLS,
 		})
 	}
 }
 
+// makeMediaThumbnail creates fazt.app.media.thumbnail(path, atSeconds) /
+// fazt.app.user.media.thumbnail(...). Returns the poster frame of a stored
+// video as a JPEG (cached), or null when the blob is missing or the server
+// has no ffmpeg.
+func makeMediaThumbnail(vm *goja.Runtime, getBlob func(context.Context, string) (*Blob, error), cache *media.MediaCache, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("media.thumbnail requires a path")))
+		}
+		path := call.Argument(0).String()
+		atSeconds := 0.0
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+			atSeconds = call.Argument(1).ToFloat()
+			if math.IsNaN(atSeconds) || math.IsInf(atSeconds, 0) || atSeconds < 0 {
+				panic(vm.NewGoError(fmt.Errorf("media.thumbnail atSeconds must be a number >= 0")))
+			}
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		blob, err := getBlob(opCtx, path)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if blob == nil {
+			return goja.Null()
+		}
+		if !media.IsVideoContentType(blob.MimeType) {
+			panic(vm.NewGoError(fmt.Errorf("media.thumbnail: %s is not a video", path)))
+		}
+
+		frame, mime, err := media.ThumbnailAndCache(opCtx, cache, appID, path, blob.Data, atSeconds)
+		if errors.Is(err, media.ErrNoFFmpeg) {
+			return goja.Null()
+		}
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(map[string]interface{}{
+			"data": base64.StdEncoding.EncodeToString(frame),
+			"mime": mime,
+			"size": len(frame),
+		})
+	}
+}
+
+// makeMediaWaveform creates fazt.app.media.waveform(path, samples) /
+// fazt.app.user.media.waveform(...). Returns the peak amplitudes (0-1) of a
+// stored audio or video file's sound (cached), or null when the blob is
+// missing or the server has no ffmpeg.
+func makeMediaWaveform(vm *goja.Runtime, getBlob func(context.Context, string) (*Blob, error), cache *media.MediaCache, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("media.waveform requires a path")))
+		}
+		path := call.Argument(0).String()
+		samples := 100
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+			samples = int(call.Argument(1).ToInteger())
+			if samples < 1 || samples > media.MaxWaveformSamples {
+				panic(vm.NewGoError(fmt.Errorf("media.waveform samples must be between 1 and %d", media.MaxWaveformSamples)))
+			}
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		blob, err := getBlob(opCtx, path)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if blob == nil {
+			return goja.Null()
+		}
+		if !media.IsAudioContentType(blob.MimeType) && !media.IsVideoContentType(blob.MimeType) {
+			panic(vm.NewGoError(fmt.Errorf("media.waveform: %s is not audio or video", path)))
+		}
+
+		peaks, err := media.WaveformAndCache(opCtx, cache, appID, path, blob.Data, samples)
+		if errors.Is(err, media.ErrNoFFmpeg) {
+			return goja.Null()
+		}
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(peaks)
+	}
+}
+
+// makeMediaPreview creates fazt.app.media.preview(path, size) /
+// fazt.app.user.media.preview(...). Returns the first page of a stored PDF
+// or office document as a PNG (cached), or null when the blob is missing
+// or the server has no converter for it.
+func makeMediaPreview(vm *goja.Runtime, getBlob func(context.Context, string) (*Blob, error), cache *media.MediaCache, appID string, ctx context.Context, budget *timeout.Budget) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("media.preview requires a path")))
+		}
+		path := call.Argument(0).String()
+		size := media.DefaultPreviewSize
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) {
+			size = int(call.Argument(1).ToInteger())
+			if size < media.MinPreviewSize || size > media.MaxPreviewSize {
+				panic(vm.NewGoError(fmt.Errorf("media.preview size must be between %d and %d", media.MinPreviewSize, media.MaxPreviewSize)))
+			}
+		}
+
+		opCtx, cancel, err := getOpContext(vm, ctx, budget)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer cancel()
+
+		blob, err := getBlob(opCtx, path)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if blob != nil && !media.IsDocumentContentType(blob.MimeType) {
+			panic(vm.NewGoError(fmt.Errorf("media.preview: %s is not a PDF or office document", path)))
+		}
+		return documentPreview(vm, opCtx, blob, cache, appID, path, size)
+	}
+}
+
+// documentPreview renders a document blob's preview for media.preview and
+// ?preview. Null when the blob is missing, isn't a document, the size is
+// invalid (0) or the server has no converter for it.
+func documentPreview(vm *goja.Runtime, ctx context.Context, blob *Blob, cache *media.MediaCache, appID, path string, size int) goja.Value {
+	if blob == nil || size == 0 || !media.IsDocumentContentType(blob.MimeType) {
+		return goja.Null()
+	}
+	page, mime, err := media.PreviewAndCache(ctx, cache, appID, path, blob.Data, blob.MimeType, size)
+	if errors.Is(err, media.ErrNoPreviewer) {
+		return goja.Null()
+	}
+	if err != nil {
+		panic(vm.NewGoError(err))
+	}
+	return vm.ToValue(map[string]interface{}{
+		"data": base64.StdEncoding.EncodeToString(page),
+		"mime": mime,
+		"size": len(page),
+	})
+}
+
 // makeMediaProbe creates fazt.app.media.probe(data) / fazt.app.user.media.probe(data).
 // Accepts an ArrayBuffer of video data and returns codec/dimension/duration info.
 func makeMediaProbe(vm *goja.Runtime) func(goja.FunctionCall) goja.Value {
@@ -930,6 +1338,34 @@ func makeUserMediaServe(vm *goja.Runtime, blobs *UserScopedBlobs, appID, userID
 
 		path := call.Argument(0).String()
 
+		// ?hls= → a file of the video's HLS renditions; null until transcoded
+		if file, ok := media.HLSFileFromQuery(media.QueryFromContext(ctx)); ok {
+			if file == "" {
+				return goja.Null()
+			}
+			hls, err := blobs.Get(opCtx, media.HLSPath(path, file))
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			if hls == nil {
+				return goja.Null()
+			}
+			return vm.ToValue(map[string]interface{}{
+				"data": base64.StdEncoding.EncodeToString(hls.Data),
+				"mime": hls.MimeType,
+				"size": hls.Size,
+			})
+		}
+
+		// ?preview → a document's first page; null for other files
+		if size, ok := media.PreviewFromQuery(media.QueryFromContext(ctx)); ok {
+			blob, err := blobs.Get(opCtx, path)
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return documentPreview(vm, opCtx, blob, media.NewUserMediaCache(db, userID), appID, path, size)
+		}
+
 		// Parse transform opts from HTTP query params
 		opts := media.TransformOpts{}
 		if q := media.QueryFromContext(ctx); q != nil {
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/chaos"
 	"github.com/fazt-sh/fazt/internal/debug"
 	"github.com/fazt-sh/fazt/internal/timeout"
 )
@@ -65,11 +66,65 @@ func InjectStorageNamespace(vm *goja.Runtime, storage *Storage, appID string, ct
 	return nil
 }
 
+// DeprecateStorageNamespace makes the fazt.storage.* bindings of a VM report
+// their first call each to warn, as a logfmt line naming the fazt.app.*
+// replacement and the runtime API version the app runs as:
+//
+//	deprecated api=fazt.storage.kv.get use=fazt.app.kv.get runtime=v0
+func DeprecateStorageNamespace(vm *goja.Runtime, runtime string, warn func(line string)) {
+	faztObj, ok := vm.Get("fazt").(*goja.Object)
+	if !ok {
+		return
+	}
+	storageObj, ok := faztObj.Get("storage").(*goja.Object)
+	if !ok {
+		return
+	}
+
+	warned := map[string]bool{}
+	for _, ns := range []string{"kv", "ds", "s3"} {
+		nsObj, ok := storageObj.Get(ns).(*goja.Object)
+		if !ok {
+			continue
+		}
+		for _, method := range nsObj.Keys() {
+			fn, ok := goja.AssertFunction(nsObj.Get(method))
+			if !ok {
+				continue
+			}
+			api := "fazt.storage." + ns + "." + method
+			replacement := "fazt.app." + ns + "." + method
+			if api == "fazt.storage.ds.deleteOldest" {
+				replacement = "fazt.app.ds.delete"
+			}
+			nsObj.Set(method, func(call goja.FunctionCall) goja.Value {
+				if !warned[api] {
+					warned[api] = true
+					warn(fmt.Sprintf("deprecated api=%s use=%s runtime=%s", api, replacement, runtime))
+				}
+				result, err := fn(call.This, call.Arguments...)
+				if err != nil {
+					panic(err)
+				}
+				return result
+			})
+		}
+	}
+}
+
 // getOpContext creates a scoped context for a storage operation.
 // If budget is nil, returns the parent context unchanged.
 // If budget has insufficient time, returns an error.
+// Under WithReadYourWrites it first waits for the request's earlier writes.
+// Apps in chaos mode may have the operation delayed or failed first.
 func getOpContext(vm *goja.Runtime, parent context.Context, budget *timeout.Budget) (context.Context, func(), error) {
+	if err := chaos.Storage(parent); err != nil {
+		return nil, nil, err
+	}
 	if budget == nil {
+		if err := AwaitWrites(parent); err != nil {
+			return nil, nil, err
+		}
 		return parent, func() {}, nil
 	}
 
@@ -81,6 +136,10 @@ func getOpContext(vm *goja.Runtime, parent context.Context, budget *timeout.Budg
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := AwaitWrites(ctx); err != nil {
+		cancel()
+		return nil, nil, err
+	}
 	return ctx, cancel, nil
 }
 
@@ -229,7 +288,7 @@ func makeDSInsert(vm *goja.Runtime, ds DocStore, appID string, ctx context.Conte
 		}
 
 		id, err := ds.Insert(opCtx, appID, collection, doc)
-		debug.StorageOp("insert", appID, collection, doc, 1, time.Since(start))
+		trackOp("insert", appID, collection, doc, 1, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -290,7 +349,7 @@ func makeDSFind(vm *goja.Runtime, ds DocStore, appID string, ctx context.Context
 		} else {
 			docs, err = ds.Find(opCtx, appID, collection, query)
 		}
-		debug.StorageOp("find", appID, collection, query, int64(len(docs)), time.Since(start))
+		trackOp("find", appID, collection, query, int64(len(docs)), time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -345,7 +404,7 @@ func makeDSFindOne(vm *goja.Runtime, ds DocStore, appID string, ctx context.Cont
 		if len(docs) > 0 {
 			rows = 1
 		}
-		debug.StorageOp("findOne", appID, collection, query, rows, time.Since(start))
+		trackOp("findOne", appID, collection, query, rows, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -392,7 +451,7 @@ func makeDSUpdate(vm *goja.Runtime, ds DocStore, appID string, ctx context.Conte
 		}
 
 		count, err := ds.Update(opCtx, appID, collection, query, changes)
-		debug.StorageOp("update", appID, collection, query, count, time.Since(start))
+		trackOp("update", appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -423,7 +482,7 @@ func makeDSDelete(vm *goja.Runtime, ds DocStore, appID string, ctx context.Conte
 		}
 
 		count, err := ds.Delete(opCtx, appID, collection, query)
-		debug.StorageOp("delete", appID, collection, query, count, time.Since(start))
+		trackOp("delete", appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -468,7 +527,7 @@ func makeDSCount(vm *goja.Runtime, ds DocStore, appID string, ctx context.Contex
 				count = int64(len(docs))
 			}
 		}
-		debug.StorageOp("count", appID, collection, query, count, time.Since(start))
+		trackOp("count", appID, collection, query, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
@@ -500,7 +559,7 @@ func makeDSDeleteOldest(vm *goja.Runtime, ds DocStore, appID string, ctx context
 		}
 
 		count, err := sqlDS.DeleteOldest(opCtx, appID, collection, keepCount)
-		debug.StorageOp("deleteOldest", appID, collection, map[string]interface{}{"keepCount": keepCount}, count, time.Since(start))
+		trackOp("deleteOldest", appID, collection, map[string]interface{}{"keepCount": keepCount}, count, time.Since(start))
 		if err != nil {
 			panic(vm.NewGoError(err))
 		}
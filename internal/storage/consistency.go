@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// pendingWrites tracks the writes a request has queued that the write
+// worker hasn't finished yet. A write whose caller timed out stays queued
+// and may still commit; reads wait for it so a handler never misses its own
+// earlier write, or sees it appear halfway through.
+type pendingWrites struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+func (p *pendingWrites) add() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.n++
+}
+
+// release marks a write settled, or dropped before it was queued
+func (p *pendingWrites) release() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+}
+
+// settled returns a channel closed once no writes are pending
+func (p *pendingWrites) settled() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.n == 0 {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return p.idle
+}
+
+type pendingWritesKey struct{}
+
+// WithReadYourWrites returns a context whose storage operations see every
+// write queued earlier under it. Use one per request or job execution.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pendingWritesKey{}, &pendingWrites{})
+}
+
+func pendingFrom(ctx context.Context) *pendingWrites {
+	p, _ := ctx.Value(pendingWritesKey{}).(*pendingWrites)
+	return p
+}
+
+// AwaitWrites blocks until the writes queued under ctx have settled, or ctx
+// is done. It returns immediately without WithReadYourWrites.
+func AwaitWrites(ctx context.Context) error {
+	p := pendingFrom(ctx)
+	if p == nil {
+		return nil
+	}
+
+	select {
+	case <-p.settled():
+		return nil
+	case <-ctx.Done():
+		return &StorageError{
+			Op:        "read",
+			Cause:     fmt.Errorf("earlier writes still pending: %w", ctx.Err()),
+			Retryable: true,
+		}
+	}
+}
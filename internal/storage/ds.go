@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
 	"github.com/google/uuid"
 )
 
@@ -65,6 +67,7 @@ func (s *SQLDocStore) Insert(ctx context.Context, appID, collection string, doc
 		})
 	}
 
+	usage.Write(ctx, len(dataJSON))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -91,38 +94,13 @@ func (s *SQLDocStore) Find(ctx context.Context, appID, collection string, query
 
 // FindWithOptions retrieves documents with pagination and ordering.
 func (s *SQLDocStore) FindWithOptions(ctx context.Context, appID, collection string, query map[string]interface{}, opts *FindOptions) ([]Document, error) {
-	qb := NewQueryBuilder()
-	whereClause, args, err := qb.Build(query)
+	sqlQuery, fullArgs, err := findSQL(appID, collection, query, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build query: %w", err)
-	}
-
-	// Prepend app_id and collection to args
-	fullArgs := make([]interface{}, 0, len(args)+2)
-	fullArgs = append(fullArgs, appID, collection)
-	fullArgs = append(fullArgs, args...)
-
-	// Determine order
-	order := "DESC"
-	if opts != nil && opts.Order == "asc" {
-		order = "ASC"
-	}
-
-	sqlQuery := fmt.Sprintf(`
-		SELECT id, data, created_at, updated_at FROM app_docs
-		WHERE app_id = ? AND collection = ? AND %s
-		ORDER BY created_at %s
-	`, whereClause, order)
-
-	// Add limit/offset if specified
-	if opts != nil && opts.Limit > 0 {
-		sqlQuery += fmt.Sprintf(" LIMIT %d", opts.Limit)
-		if opts.Offset > 0 {
-			sqlQuery += fmt.Sprintf(" OFFSET %d", opts.Offset)
-		}
+		return nil, err
 	}
 
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err = withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, sqlQuery, fullArgs...)
@@ -141,6 +119,10 @@ func (s *SQLDocStore) FindWithOptions(ctx context.Context, appID, collection str
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
+		if err := sandbox.Alloc(ctx, len(dataJSON)); err != nil {
+			return nil, err
+		}
+
 		var data map[string]interface{}
 		if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal document: %w", err)
@@ -157,6 +139,42 @@ func (s *SQLDocStore) FindWithOptions(ctx context.Context, appID, collection str
 	return docs, nil
 }
 
+// findSQL builds the SELECT used by FindWithOptions
+func findSQL(appID, collection string, query map[string]interface{}, opts *FindOptions) (string, []interface{}, error) {
+	qb := NewQueryBuilder()
+	whereClause, args, err := qb.Build(query)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to build query: %w", err)
+	}
+
+	// Prepend app_id and collection to args
+	fullArgs := make([]interface{}, 0, len(args)+2)
+	fullArgs = append(fullArgs, appID, collection)
+	fullArgs = append(fullArgs, args...)
+
+	// Determine order
+	order := "DESC"
+	if opts != nil && opts.Order == "asc" {
+		order = "ASC"
+	}
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT id, data, created_at, updated_at FROM app_docs
+		WHERE app_id = ? AND collection = ? AND %s
+		ORDER BY created_at %s
+	`, whereClause, order)
+
+	// Add limit/offset if specified
+	if opts != nil && opts.Limit > 0 {
+		sqlQuery += fmt.Sprintf(" LIMIT %d", opts.Limit)
+		if opts.Offset > 0 {
+			sqlQuery += fmt.Sprintf(" OFFSET %d", opts.Offset)
+		}
+	}
+
+	return sqlQuery, fullArgs, nil
+}
+
 // FindOne retrieves a single document by ID.
 func (s *SQLDocStore) FindOne(ctx context.Context, appID, collection, id string) (*Document, error) {
 	query := `
@@ -165,6 +183,7 @@ func (s *SQLDocStore) FindOne(ctx context.Context, appID, collection, id string)
 	`
 	var docID, dataJSON string
 	var createdAt, updatedAt int64
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, appID, collection, id).Scan(&docID, &dataJSON, &createdAt, &updatedAt)
 	})
@@ -175,6 +194,10 @@ func (s *SQLDocStore) FindOne(ctx context.Context, appID, collection, id string)
 		return nil, fmt.Errorf("failed to query document: %w", err)
 	}
 
+	if err := sandbox.Alloc(ctx, len(dataJSON)); err != nil {
+		return nil, err
+	}
+
 	var data map[string]interface{}
 	if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal document: %w", err)
@@ -225,6 +248,7 @@ func (s *SQLDocStore) Update(ctx context.Context, appID, collection string, quer
 		})
 	}
 
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -264,6 +288,7 @@ func (s *SQLDocStore) Delete(ctx context.Context, appID, collection string, quer
 		})
 	}
 
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -295,6 +320,7 @@ func (s *SQLDocStore) Count(ctx context.Context, appID, collection string, query
 	`, whereClause)
 
 	var count int64
+	usage.Read(ctx)
 	err = withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, sqlQuery, fullArgs...).Scan(&count)
 	})
@@ -334,6 +360,7 @@ func (s *SQLDocStore) DeleteOldest(ctx context.Context, appID, collection string
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -354,6 +381,7 @@ func (s *SQLDocStore) Collections(ctx context.Context, appID string) ([]string,
 		ORDER BY collection
 	`
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, query, appID)
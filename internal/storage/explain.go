@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// QueryPlan describes how the document store runs a find: the SQL it
+// generates, SQLite's plan for it, and how many rows it touches.
+type QueryPlan struct {
+	SQL  string        `json:"sql"`
+	Args []interface{} `json:"args"`
+	Plan []string      `json:"plan"` // EXPLAIN QUERY PLAN, one step per line
+	// Index narrows the rows read; empty means a full table scan
+	Index string `json:"index"`
+	// IndexedFields are query fields answered by an index: the id and
+	// session columns, or an extracted field the plan uses. Every other
+	// field is read with json_extract per scanned row.
+	IndexedFields []string `json:"indexed_fields"`
+	JSONFields    []string `json:"json_fields"`
+	Scanned       int64    `json:"scanned"` // rows the index leaves to examine
+	Matched       int64    `json:"matched"`
+}
+
+// indexedFields are the query fields stored in indexed columns
+var indexedFields = map[string]bool{"id": true, "session": true}
+
+var planIndexRe = regexp.MustCompile(`USING (?:COVERING )?INDEX (\S+)|USING (PRIMARY KEY)`)
+
+// Explain reports how Find would execute query, without returning documents
+func (s *SQLDocStore) Explain(ctx context.Context, appID, collection string, query map[string]interface{}, opts *FindOptions) (*QueryPlan, error) {
+	sqlQuery, args, err := findSQL(appID, collection, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan := &QueryPlan{
+		SQL:           strings.Join(strings.Fields(sqlQuery), " "),
+		Args:          args,
+		Plan:          []string{},
+		IndexedFields: []string{},
+		JSONFields:    []string{},
+	}
+
+	var rows *sql.Rows
+	err = withRetry(ctx, func() error {
+		var err error
+		rows, err = s.db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+sqlQuery, args...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			return nil, fmt.Errorf("failed to scan plan: %w", err)
+		}
+		plan.Plan = append(plan.Plan, detail)
+		if m := planIndexRe.FindStringSubmatch(detail); m != nil && plan.Index == "" {
+			plan.Index = m[1] + m[2]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	// Rows scanned are those matching the indexed conditions alone; the JSON
+	// conditions are then evaluated on each of them.
+	indexed := make(map[string]interface{})
+	for field, value := range query {
+		if indexedFields[field] || plan.Index == fieldIndexName(field) {
+			indexed[field] = value
+			plan.IndexedFields = append(plan.IndexedFields, field)
+		} else {
+			plan.JSONFields = append(plan.JSONFields, field)
+		}
+	}
+	sort.Strings(plan.IndexedFields)
+	sort.Strings(plan.JSONFields)
+
+	if plan.Index == "" {
+		err = withRetry(ctx, func() error {
+			return s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM app_docs").Scan(&plan.Scanned)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to count documents: %w", err)
+		}
+	} else if plan.Scanned, err = s.Count(ctx, appID, collection, indexed); err != nil {
+		return nil, err
+	}
+	if plan.Matched, err = s.Count(ctx, appID, collection, query); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	db := setupTestDB(t)
+	if _, err := db.Exec(`CREATE INDEX idx_app_docs_session_id ON app_docs(app_id, collection, session_id)`); err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	ds := NewSQLDocStore(db)
+	ctx := context.Background()
+
+	for i, author := range []string{"x", "y", "x", "z"} {
+		session := "s1"
+		if i > 1 {
+			session = "s2"
+		}
+		if _, err := ds.Insert(ctx, "app", "posts", map[string]interface{}{"author": author, "session": session}); err != nil {
+			t.Fatalf("Insert failed: %v", err)
+		}
+	}
+
+	plan, err := ds.Explain(ctx, "app", "posts", map[string]interface{}{"author": "x"}, nil)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if !strings.Contains(plan.SQL, "json_extract(data, '$.author') = ?") {
+		t.Errorf("Expected json_extract on author, got %q", plan.SQL)
+	}
+	if plan.Index == "" || len(plan.Plan) == 0 {
+		t.Errorf("Expected an index in the plan, got %+v", plan.Plan)
+	}
+	if plan.Scanned != 4 || plan.Matched != 2 {
+		t.Errorf("Expected 4 scanned, 2 matched, got %d, %d", plan.Scanned, plan.Matched)
+	}
+	if len(plan.JSONFields) != 1 || len(plan.IndexedFields) != 0 {
+		t.Errorf("Unexpected fields: indexed %v, json %v", plan.IndexedFields, plan.JSONFields)
+	}
+
+	// session is an indexed column, so only that session's rows are scanned
+	plan, err = ds.Explain(ctx, "app", "posts", map[string]interface{}{"author": "x", "session": "s2"}, nil)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if plan.Scanned != 2 || plan.Matched != 1 {
+		t.Errorf("Expected 2 scanned, 1 matched, got %d, %d", plan.Scanned, plan.Matched)
+	}
+	if len(plan.IndexedFields) != 1 || plan.IndexedFields[0] != "session" {
+		t.Errorf("Expected session to be indexed, got %v", plan.IndexedFields)
+	}
+
+	if _, err := ds.Explain(ctx, "app", "posts", map[string]interface{}{"n": map[string]interface{}{"$bogus": 1}}, nil); err == nil {
+		t.Error("Expected error for unknown operator")
+	}
+}
@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hot field extraction. The document store filters JSON fields with
+// json_extract(data, '$.field'), which SQLite evaluates for every document
+// in the collection. A field that is queried often in a large collection
+// can be extracted: an index on the json_extract expression works as an
+// indexed virtual generated column, and because QueryBuilder emits the same
+// expression, existing queries pick it up without being rewritten.
+
+const (
+	fieldIndexPrefix = "idx_app_docs_field_"
+
+	// AdvisorInterval is how often the field advisor looks for candidates
+	AdvisorInterval = time.Hour
+
+	// A field becomes a candidate once it has been queried this often, in
+	// a collection at least this large, matching at most this share of it
+	candidateMinQueries = 50
+	candidateMinDocs    = 1000
+	candidateMaxMatch   = 0.1
+)
+
+var (
+	fieldNameRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+	indexFieldRe = regexp.MustCompile(`'\$\.([^']+)'`)
+)
+
+// FieldCandidate is a JSON field worth extracting into an index
+type FieldCandidate struct {
+	App        string  `json:"app"`
+	Collection string  `json:"collection"`
+	Field      string  `json:"field"`
+	Queries    int64   `json:"queries"`     // since the server started
+	Docs       int64   `json:"docs"`        // in the collection
+	AvgMatched float64 `json:"avg_matched"` // documents matched per query
+	Index      string  `json:"index"`       // set once extracted
+}
+
+type fieldKey struct{ app, collection, field string }
+
+type fieldUsage struct {
+	queries, matched int64
+}
+
+var fieldStats = struct {
+	mu    sync.Mutex
+	usage map[fieldKey]*fieldUsage
+}{usage: make(map[fieldKey]*fieldUsage)}
+
+// observeFields records the JSON fields a shared-collection query filters
+// on. User-scoped collections are split per user and stay small, so they
+// aren't tracked.
+func observeFields(op, app, collection string, query interface{}, rows int64) {
+	switch op {
+	case "find", "findOne", "count", "update", "delete":
+	default:
+		return
+	}
+	q, ok := query.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	fieldStats.mu.Lock()
+	defer fieldStats.mu.Unlock()
+	for field := range q {
+		if indexedFields[field] || !fieldNameRe.MatchString(field) {
+			continue
+		}
+		key := fieldKey{app, collection, field}
+		u := fieldStats.usage[key]
+		if u == nil {
+			u = &fieldUsage{}
+			fieldStats.usage[key] = u
+		}
+		u.queries++
+		u.matched += rows
+	}
+}
+
+// fieldIndexName returns the index an extracted field lives in
+func fieldIndexName(field string) string {
+	return fieldIndexPrefix + strings.ReplaceAll(field, ".", "__")
+}
+
+// ExtractField indexes a JSON field across all document collections and
+// returns the index name. It is a no-op if the field is already extracted.
+func ExtractField(ctx context.Context, db *sql.DB, field string) (string, error) {
+	if indexedFields[field] {
+		return "", fmt.Errorf("%s is already stored in an indexed column", field)
+	}
+	if !fieldNameRe.MatchString(field) {
+		return "", fmt.Errorf("invalid field name: %q", field)
+	}
+
+	name := fieldIndexName(field)
+	stmt := fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON app_docs(app_id, collection, json_extract(data, '$.%s'))`,
+		name, escapeJSONPath(field))
+	err := QueueWrite(ctx, func() error {
+		_, err := db.ExecContext(ctx, stmt)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to extract field: %w", err)
+	}
+	return name, nil
+}
+
+// DropExtractedField removes a field's index
+func DropExtractedField(ctx context.Context, db *sql.DB, field string) error {
+	if !fieldNameRe.MatchString(field) {
+		return fmt.Errorf("invalid field name: %q", field)
+	}
+	return QueueWrite(ctx, func() error {
+		_, err := db.ExecContext(ctx, "DROP INDEX IF EXISTS "+fieldIndexName(field))
+		return err
+	})
+}
+
+// ExtractedFields returns the extracted fields and their index names
+func ExtractedFields(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT name, sql FROM sqlite_master WHERE type = 'index' AND tbl_name = 'app_docs' AND sql IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extracted fields: %w", err)
+	}
+	defer rows.Close()
+
+	fields := make(map[string]string)
+	for rows.Next() {
+		var name, stmt string
+		if err := rows.Scan(&name, &stmt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if !strings.HasPrefix(name, fieldIndexPrefix) {
+			continue
+		}
+		if m := indexFieldRe.FindStringSubmatch(stmt); m != nil {
+			fields[m[1]] = name
+		}
+	}
+	return fields, rows.Err()
+}
+
+// FieldCandidates returns the fields worth extracting for an app (all apps
+// if empty), busiest first. Already extracted fields are included with
+// their index set.
+func FieldCandidates(ctx context.Context, db *sql.DB, app string) ([]FieldCandidate, error) {
+	fieldStats.mu.Lock()
+	usage := make(map[fieldKey]fieldUsage)
+	for key, u := range fieldStats.usage {
+		if (app == "" || key.app == app) && u.queries >= candidateMinQueries {
+			usage[key] = *u
+		}
+	}
+	fieldStats.mu.Unlock()
+
+	extracted, err := ExtractedFields(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	type collKey struct{ app, collection string }
+	sizes := make(map[collKey]int64)
+	candidates := make([]FieldCandidate, 0)
+	for key, u := range usage {
+		ck := collKey{key.app, key.collection}
+		docs, ok := sizes[ck]
+		if !ok {
+			err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM app_docs WHERE app_id = ? AND collection = ?`,
+				key.app, key.collection).Scan(&docs)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count documents: %w", err)
+			}
+			sizes[ck] = docs
+		}
+
+		avg := float64(u.matched) / float64(u.queries)
+		if docs < candidateMinDocs || avg > float64(docs)*candidateMaxMatch {
+			continue
+		}
+		candidates = append(candidates, FieldCandidate{
+			App:        key.app,
+			Collection: key.collection,
+			Field:      key.field,
+			Queries:    u.queries,
+			Docs:       docs,
+			AvgMatched: avg,
+			Index:      extracted[key.field],
+		})
+	}
+
+	// Rows a scan reads per query times queries: the work an index saves
+	sort.Slice(candidates, func(i, j int) bool {
+		wi := candidates[i].Queries * candidates[i].Docs
+		wj := candidates[j].Queries * candidates[j].Docs
+		if wi != wj {
+			return wi > wj
+		}
+		return candidates[i].Field < candidates[j].Field
+	})
+	return candidates, nil
+}
+
+// resetFieldStats clears observed field usage (for tests)
+func resetFieldStats() {
+	fieldStats.mu.Lock()
+	fieldStats.usage = make(map[fieldKey]*fieldUsage)
+	fieldStats.mu.Unlock()
+}
+
+// FieldAdvisor periodically looks for fields worth extracting and logs
+// each new candidate once
+type FieldAdvisor struct {
+	db       *sql.DB
+	reported map[fieldKey]bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFieldAdvisor creates a field advisor
+func NewFieldAdvisor(db *sql.DB) *FieldAdvisor {
+	return &FieldAdvisor{
+		db:       db,
+		reported: make(map[fieldKey]bool),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins the background advisor loop
+func (a *FieldAdvisor) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(AdvisorInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.Tick()
+			case <-a.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the loop
+func (a *FieldAdvisor) Stop() {
+	close(a.done)
+	a.wg.Wait()
+}
+
+// Tick logs candidates that haven't been reported yet
+func (a *FieldAdvisor) Tick() {
+	candidates, err := FieldCandidates(context.Background(), a.db, "")
+	if err != nil {
+		log.Printf("Storage: field advisor failed: %v", err)
+		return
+	}
+	for _, c := range candidates {
+		key := fieldKey{c.App, c.Collection, c.Field}
+		if c.Index != "" || a.reported[key] {
+			continue
+		}
+		a.reported[key] = true
+		log.Printf("Storage: %s/%s filters on %q in %d queries over %d docs; extract with: fazt app storage ds extract %s",
+			c.App, c.Collection, c.Field, c.Queries, c.Docs, c.Field)
+	}
+}
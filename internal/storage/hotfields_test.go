@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFieldCandidatesAndExtraction(t *testing.T) {
+	resetFieldStats()
+	db := setupTestDB(t)
+	ds := NewSQLDocStore(db)
+	ctx := context.Background()
+
+	for i := 0; i < 1200; i++ {
+		if _, err := db.Exec(`INSERT INTO app_docs (app_id, collection, id, data) VALUES ('app', 'posts', ?, ?)`,
+			fmt.Sprintf("p%d", i), fmt.Sprintf(`{"author":"a%d","kind":"post"}`, i%100)); err != nil {
+			t.Fatalf("insert failed: %v", err)
+		}
+	}
+
+	// author is selective; kind matches every document
+	for i := 0; i < candidateMinQueries; i++ {
+		observeFields("find", "app", "posts", map[string]interface{}{"author": "a1", "id": "x"}, 12)
+		observeFields("find", "app", "posts", map[string]interface{}{"kind": "post"}, 1200)
+		observeFields("user.find", "app", "notes", map[string]interface{}{"title": "t"}, 1)
+	}
+
+	candidates, err := FieldCandidates(ctx, db, "app")
+	if err != nil {
+		t.Fatalf("FieldCandidates failed: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Field != "author" || candidates[0].Docs != 1200 || candidates[0].Index != "" {
+		t.Fatalf("Expected author as the only candidate, got %+v", candidates)
+	}
+
+	index, err := ExtractField(ctx, db, "author")
+	if err != nil {
+		t.Fatalf("ExtractField failed: %v", err)
+	}
+	fields, err := ExtractedFields(ctx, db)
+	if err != nil || fields["author"] != index {
+		t.Fatalf("Expected author in extracted fields, got %v (%v)", fields, err)
+	}
+
+	plan, err := ds.Explain(ctx, "app", "posts", map[string]interface{}{"author": "a1"}, nil)
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if plan.Index != index || plan.Scanned != 12 || plan.Matched != 12 {
+		t.Errorf("Expected %s to narrow the scan to 12 rows, got index %q, %d scanned", index, plan.Index, plan.Scanned)
+	}
+
+	candidates, _ = FieldCandidates(ctx, db, "app")
+	if len(candidates) != 1 || candidates[0].Index != index {
+		t.Errorf("Expected candidate to report its index, got %+v", candidates)
+	}
+
+	if err := DropExtractedField(ctx, db, "author"); err != nil {
+		t.Fatalf("DropExtractedField failed: %v", err)
+	}
+	if fields, _ := ExtractedFields(ctx, db); len(fields) != 0 {
+		t.Errorf("Expected no extracted fields after drop, got %v", fields)
+	}
+
+	for _, field := range []string{"id", "a'b", "x) ; DROP TABLE app_docs; --"} {
+		if _, err := ExtractField(ctx, db, field); err == nil {
+			t.Errorf("Expected ExtractField(%q) to fail", field)
+		}
+	}
+}
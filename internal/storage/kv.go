@@ -8,6 +8,9 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
 )
 
 // SQLKVStore implements KVStore using SQLite.
@@ -77,6 +80,7 @@ func (s *SQLKVStore) Set(ctx context.Context, appID, key string, value interface
 	}
 
 	// Use write queue if available, otherwise direct write
+	usage.Write(ctx, len(valueJSON))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -116,6 +120,7 @@ func (s *SQLKVStore) Get(ctx context.Context, appID, key string) (interface{}, e
 	`
 	var valueJSON string
 	var expiresAt sql.NullInt64
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, appID, key).Scan(&valueJSON, &expiresAt)
 	})
@@ -126,6 +131,10 @@ func (s *SQLKVStore) Get(ctx context.Context, appID, key string) (interface{}, e
 		return nil, fmt.Errorf("failed to get key: %w", err)
 	}
 
+	if err := sandbox.Alloc(ctx, len(valueJSON)); err != nil {
+		return nil, err
+	}
+
 	var value interface{}
 	if err := json.Unmarshal([]byte(valueJSON), &value); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal value: %w", err)
@@ -163,6 +172,7 @@ func (s *SQLKVStore) Delete(ctx context.Context, appID, key string) error {
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -189,6 +199,7 @@ func (s *SQLKVStore) List(ctx context.Context, appID, prefix string) ([]KVEntry,
 		ORDER BY key
 	`
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, query, appID, prefix+"%")
@@ -207,6 +218,10 @@ func (s *SQLKVStore) List(ctx context.Context, appID, prefix string) ([]KVEntry,
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
+		if err := sandbox.Alloc(ctx, len(valueJSON)); err != nil {
+			return nil, err
+		}
+
 		var value interface{}
 		if err := json.Unmarshal([]byte(valueJSON), &value); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal value: %w", err)
@@ -295,6 +310,7 @@ func (s *SQLKVStore) HasKey(ctx context.Context, appID, key string) (bool, error
 		LIMIT 1
 	`
 	var exists int
+	usage.Read(ctx)
 	err := s.db.QueryRowContext(ctx, query, appID, key).Scan(&exists)
 	if err == sql.ErrNoRows {
 		return false, nil
@@ -317,6 +333,7 @@ func (s *SQLKVStore) Keys(ctx context.Context, appID, prefix string) ([]string,
 	escapedPrefix := strings.ReplaceAll(prefix, "%", "\\%")
 	escapedPrefix = strings.ReplaceAll(escapedPrefix, "_", "\\_")
 
+	usage.Read(ctx)
 	rows, err := s.db.QueryContext(ctx, query, appID, escapedPrefix+"%")
 	if err != nil {
 		return nil, fmt.Errorf("failed to list keys: %w", err)
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/debug"
+)
+
+// DefaultSlowOpThreshold is how long a storage operation may take before it
+// lands in the slow-op log
+const DefaultSlowOpThreshold = 100 * time.Millisecond
+
+const (
+	opSamples  = 256 // recent durations kept per app and op for percentiles
+	slowOpsMax = 500 // slow-op log entries kept, across apps
+)
+
+// OpStats summarizes one kind of storage operation for an app. Percentiles
+// cover the most recent 256 calls; counts cover the whole process lifetime.
+type OpStats struct {
+	Op    string  `json:"op"`
+	Count int64   `json:"count"`
+	Slow  int64   `json:"slow"`
+	Rows  int64   `json:"rows"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// SlowOp is an entry in the slow-op log. Query is the query's shape with
+// values elided, so documents and user input aren't retained.
+type SlowOp struct {
+	App        string  `json:"app"`
+	Op         string  `json:"op"`
+	Collection string  `json:"collection"`
+	Query      string  `json:"query"`
+	Rows       int64   `json:"rows"`
+	DurationMs float64 `json:"duration_ms"`
+	At         int64   `json:"at"`
+}
+
+type opSeries struct {
+	count, slow, rows int64
+	max               time.Duration
+	samples           [opSamples]time.Duration
+	next              int // ring position; samples filled up to min(count, opSamples)
+}
+
+var opMetrics = struct {
+	mu        sync.Mutex
+	apps      map[string]map[string]*opSeries
+	slow      []SlowOp // ring, oldest overwritten first
+	slowNext  int
+	threshold time.Duration
+}{
+	apps:      make(map[string]map[string]*opSeries),
+	threshold: DefaultSlowOpThreshold,
+}
+
+// SetSlowOpThreshold sets the duration above which operations are logged as
+// slow. Zero or less restores the default.
+func SetSlowOpThreshold(d time.Duration) {
+	if d <= 0 {
+		d = DefaultSlowOpThreshold
+	}
+	opMetrics.mu.Lock()
+	opMetrics.threshold = d
+	opMetrics.mu.Unlock()
+}
+
+// SlowOpThreshold returns the current slow-op threshold
+func SlowOpThreshold() time.Duration {
+	opMetrics.mu.Lock()
+	defer opMetrics.mu.Unlock()
+	return opMetrics.threshold
+}
+
+// trackOp records a storage operation's timing for per-app metrics and the
+// slow-op log, notes the fields it filters on, and logs it in debug mode
+func trackOp(op, app, collection string, query interface{}, rows int64, duration time.Duration) {
+	debug.StorageOp(op, app, collection, query, rows, duration)
+	observeFields(op, app, collection, query, rows)
+
+	opMetrics.mu.Lock()
+	defer opMetrics.mu.Unlock()
+
+	ops := opMetrics.apps[app]
+	if ops == nil {
+		ops = make(map[string]*opSeries)
+		opMetrics.apps[app] = ops
+	}
+	s := ops[op]
+	if s == nil {
+		s = &opSeries{}
+		ops[op] = s
+	}
+	s.count++
+	s.rows += rows
+	if duration > s.max {
+		s.max = duration
+	}
+	s.samples[s.next] = duration
+	s.next = (s.next + 1) % opSamples
+
+	if duration < opMetrics.threshold {
+		return
+	}
+	s.slow++
+	entry := SlowOp{
+		App:        app,
+		Op:         op,
+		Collection: collection,
+		Query:      queryShape(query),
+		Rows:       rows,
+		DurationMs: ms(duration),
+		At:         time.Now().Unix(),
+	}
+	if len(opMetrics.slow) < slowOpsMax {
+		opMetrics.slow = append(opMetrics.slow, entry)
+	} else {
+		opMetrics.slow[opMetrics.slowNext] = entry
+	}
+	opMetrics.slowNext = (opMetrics.slowNext + 1) % slowOpsMax
+}
+
+// AppOpStats returns an app's storage operation stats, sorted by op
+func AppOpStats(app string) []OpStats {
+	opMetrics.mu.Lock()
+	defer opMetrics.mu.Unlock()
+
+	stats := make([]OpStats, 0, len(opMetrics.apps[app]))
+	for op, s := range opMetrics.apps[app] {
+		stats = append(stats, s.summary(op))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Op < stats[j].Op })
+	return stats
+}
+
+// AllOpStats returns storage operation stats for every app seen
+func AllOpStats() map[string][]OpStats {
+	opMetrics.mu.Lock()
+	apps := make([]string, 0, len(opMetrics.apps))
+	for app := range opMetrics.apps {
+		apps = append(apps, app)
+	}
+	opMetrics.mu.Unlock()
+
+	all := make(map[string][]OpStats, len(apps))
+	for _, app := range apps {
+		all[app] = AppOpStats(app)
+	}
+	return all
+}
+
+// SlowOps returns up to limit slow-op log entries, newest first. An empty
+// app returns entries for all apps.
+func SlowOps(app string, limit int) []SlowOp {
+	opMetrics.mu.Lock()
+	defer opMetrics.mu.Unlock()
+
+	result := make([]SlowOp, 0)
+	n := len(opMetrics.slow)
+	for i := 1; i <= n && len(result) < limit; i++ {
+		entry := opMetrics.slow[(opMetrics.slowNext-i+n)%n]
+		if app == "" || entry.App == app {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// resetOpMetrics clears all stats (for tests)
+func resetOpMetrics() {
+	opMetrics.mu.Lock()
+	defer opMetrics.mu.Unlock()
+	opMetrics.apps = make(map[string]map[string]*opSeries)
+	opMetrics.slow = nil
+	opMetrics.slowNext = 0
+}
+
+func (s *opSeries) summary(op string) OpStats {
+	n := int(s.count)
+	if n > opSamples {
+		n = opSamples
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, s.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return OpStats{
+		Op:    op,
+		Count: s.count,
+		Slow:  s.slow,
+		Rows:  s.rows,
+		P50Ms: ms(percentile(sorted, 0.50)),
+		P95Ms: ms(percentile(sorted, 0.95)),
+		P99Ms: ms(percentile(sorted, 0.99)),
+		MaxMs: ms(s.max),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// queryShape renders a query with its values elided, e.g.
+// {"author":?,"score":{"$gt":?}}, keeping operators and field names only
+func queryShape(query interface{}) string {
+	var b strings.Builder
+	writeShape(&b, query)
+	return b.String()
+}
+
+func writeShape(b *strings.Builder, v interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		b.WriteString("?")
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b.WriteString("{")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(`"` + k + `":`)
+		if sub, ok := m[k].(map[string]interface{}); ok && isOperatorMap(sub) {
+			writeShape(b, sub)
+		} else {
+			b.WriteString("?")
+		}
+	}
+	b.WriteString("}")
+}
+
+// isOperatorMap reports whether m holds query operators ($gt, $in, ...)
+// rather than a nested document value
+func isOperatorMap(m map[string]interface{}) bool {
+	for k := range m {
+		if !strings.HasPrefix(k, "$") {
+			return false
+		}
+	}
+	return len(m) > 0
+}
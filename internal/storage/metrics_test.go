@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackOpPercentilesAndSlowLog(t *testing.T) {
+	resetOpMetrics()
+	SetSlowOpThreshold(50 * time.Millisecond)
+	defer SetSlowOpThreshold(0)
+
+	// 100 finds of 1..100ms: p50 50ms, p95 95ms, 51 slow
+	for i := 1; i <= 100; i++ {
+		trackOp("find", "app_a", "posts", map[string]interface{}{"author": "x"}, 2, time.Duration(i)*time.Millisecond)
+	}
+	trackOp("insert", "app_b", "posts", map[string]interface{}{"title": "secret"}, 1, 200*time.Millisecond)
+
+	stats := AppOpStats("app_a")
+	if len(stats) != 1 {
+		t.Fatalf("Expected one op for app_a, got %+v", stats)
+	}
+	s := stats[0]
+	if s.Op != "find" || s.Count != 100 || s.Rows != 200 || s.Slow != 51 {
+		t.Errorf("Unexpected counts: %+v", s)
+	}
+	if s.P50Ms != 50 || s.P95Ms != 95 || s.P99Ms != 99 || s.MaxMs != 100 {
+		t.Errorf("Unexpected percentiles: %+v", s)
+	}
+
+	slow := SlowOps("app_a", 3)
+	if len(slow) != 3 || slow[0].DurationMs != 100 || slow[2].DurationMs != 98 {
+		t.Errorf("Expected the 3 newest slow finds, got %+v", slow)
+	}
+	if slow[0].Query != `{"author":?}` {
+		t.Errorf("Expected query shape without values, got %q", slow[0].Query)
+	}
+
+	all := SlowOps("", 500)
+	if len(all) != 52 || all[0].App != "app_b" || all[0].Query != `{"title":?}` {
+		t.Errorf("Expected newest entry from app_b first, got %d entries: %+v", len(all), all[0])
+	}
+}
+
+func TestQueryShape(t *testing.T) {
+	q := map[string]interface{}{
+		"score":  map[string]interface{}{"$gt": 10, "$lt": 20},
+		"author": "x",
+		"meta":   map[string]interface{}{"lang": "en"},
+	}
+	if got := queryShape(q); got != `{"author":?,"meta":?,"score":{"$gt":?,"$lt":?}}` {
+		t.Errorf("Unexpected shape %q", got)
+	}
+	if got := queryShape(nil); got != "?" {
+		t.Errorf("Expected ? for nil query, got %q", got)
+	}
+}
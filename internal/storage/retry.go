@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,6 +12,9 @@ const (
 	initialBackoff = 20 * time.Millisecond
 )
 
+// busyRetries counts retries of transient lock errors, for WriteStats
+var busyRetries atomic.Int64
+
 // withRetry executes an operation with exponential backoff on transient errors.
 func withRetry(ctx context.Context, op func() error) error {
 	backoff := initialBackoff
@@ -28,6 +32,7 @@ func withRetry(ctx context.Context, op func() error) error {
 		}
 
 		lastErr = err
+		busyRetries.Add(1)
 
 		// Check if context is done before sleeping
 		select {
@@ -2,14 +2,41 @@ package storage
 
 import (
 	"context"
+	"crypto/md5"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
+
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
 )
 
+// UploadScanner checks a blob before it is stored; an error refuses the
+// upload. path is the normalized path the app wrote, before user scoping.
+type UploadScanner func(ctx context.Context, appID, path string, data []byte) error
+
+var uploadScanner atomic.Value // scannerHolder
+
+type scannerHolder struct{ fn UploadScanner }
+
+// SetUploadScanner sets the check run on every blob upload; nil disables it.
+func SetUploadScanner(fn UploadScanner) {
+	uploadScanner.Store(scannerHolder{fn})
+}
+
+func scanUpload(ctx context.Context, appID, path string, data []byte) error {
+	if h, ok := uploadScanner.Load().(scannerHolder); ok && h.fn != nil {
+		return h.fn(ctx, appID, path, data)
+	}
+	return nil
+}
+
 // SQLBlobStore implements BlobStore using SQLite.
 type SQLBlobStore struct {
 	db     *sql.DB
@@ -31,28 +58,34 @@ func (s *SQLBlobStore) Put(ctx context.Context, appID, path string, data []byte,
 	// Normalize path
 	path = normalizePath(path)
 
+	if err := scanUpload(ctx, appID, path, data); err != nil {
+		return err
+	}
+
 	// Calculate hash
 	hash := sha256Hash(data)
 
 	query := `
-		INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
+		INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, md5, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
 		ON CONFLICT(app_id, path) DO UPDATE SET
 			data = excluded.data,
 			mime_type = excluded.mime_type,
 			size_bytes = excluded.size_bytes,
 			hash = excluded.hash,
+			md5 = excluded.md5,
 			updated_at = strftime('%s', 'now')
 	`
 
 	writeOp := func() error {
 		return withRetry(ctx, func() error {
-			_, err := s.db.ExecContext(ctx, query, appID, path, data, mimeType, len(data), hash)
+			_, err := s.db.ExecContext(ctx, query, appID, path, data, mimeType, len(data), hash, md5Hash(data))
 			return err
 		})
 	}
 
 	var err error
+	usage.Write(ctx, len(data))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -62,6 +95,12 @@ func (s *SQLBlobStore) Put(ctx context.Context, appID, path string, data []byte,
 		return fmt.Errorf("failed to store blob: %w", err)
 	}
 
+	events.Emit(events.S3Put, appID, map[string]interface{}{
+		"path":      path,
+		"mime_type": mimeType,
+		"size":      len(data),
+		"hash":      hash,
+	})
 	return nil
 }
 
@@ -77,6 +116,7 @@ func (s *SQLBlobStore) Get(ctx context.Context, appID, path string) (*Blob, erro
 	var mimeType, hash string
 	var size int64
 
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, appID, path).Scan(&data, &mimeType, &size, &hash)
 	})
@@ -87,6 +127,10 @@ func (s *SQLBlobStore) Get(ctx context.Context, appID, path string) (*Blob, erro
 		return nil, fmt.Errorf("failed to get blob: %w", err)
 	}
 
+	if err := sandbox.Alloc(ctx, len(data)); err != nil {
+		return nil, err
+	}
+
 	return &Blob{
 		Data:     data,
 		MimeType: mimeType,
@@ -109,6 +153,7 @@ func (s *SQLBlobStore) Delete(ctx context.Context, appID, path string) error {
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -131,6 +176,7 @@ func (s *SQLBlobStore) List(ctx context.Context, appID, prefix string) ([]BlobMe
 		ORDER BY path
 	`
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, query, appID, prefix+"%")
@@ -160,12 +206,63 @@ func (s *SQLBlobStore) List(ctx context.Context, appID, prefix string) ([]BlobMe
 	return blobs, nil
 }
 
+// ListAfter returns metadata, with hashes, for up to limit blobs under a
+// prefix whose paths sort after after, in path order. Unlike List, the
+// prefix is matched exactly, without LIKE wildcards, so it pages through
+// large listings.
+func (s *SQLBlobStore) ListAfter(ctx context.Context, appID, prefix, after string, limit int) ([]BlobMeta, error) {
+	query := `
+		SELECT path, mime_type, size_bytes, hash, COALESCE(md5, ''), updated_at FROM app_blobs
+		WHERE app_id = ? AND path > ? AND path >= ? AND (? = '' OR path < ?) AND substr(path, 1, ?) = ?
+		ORDER BY path
+		LIMIT ?
+	`
+	var rows *sql.Rows
+	usage.Read(ctx)
+	err := withRetry(ctx, func() error {
+		var err error
+		// The range bounds let the index find the prefix; substr makes it exact
+		rows, err = s.db.QueryContext(ctx, query, appID, after, prefix, prefix, prefix+string(utf8.MaxRune),
+			utf8.RuneCountInString(prefix), prefix, limit)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	defer rows.Close()
+
+	blobs := []BlobMeta{}
+	for rows.Next() {
+		var b BlobMeta
+		var updatedAt int64
+		if err := rows.Scan(&b.Path, &b.MimeType, &b.Size, &b.Hash, &b.MD5, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		b.UpdatedAt = time.Unix(updatedAt, 0)
+		blobs = append(blobs, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range blobs {
+		if blobs[i].MD5 == "" {
+			if blobs[i].MD5, err = s.fillMD5(ctx, appID, blobs[i].Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return blobs, nil
+}
+
 // Exists checks if a blob exists.
 func (s *SQLBlobStore) Exists(ctx context.Context, appID, path string) (bool, error) {
 	path = normalizePath(path)
 
 	query := `SELECT 1 FROM app_blobs WHERE app_id = ? AND path = ? LIMIT 1`
 	var exists int
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, appID, path).Scan(&exists)
 	})
@@ -183,14 +280,15 @@ func (s *SQLBlobStore) GetMeta(ctx context.Context, appID, path string) (*BlobMe
 	path = normalizePath(path)
 
 	query := `
-		SELECT path, mime_type, size_bytes, updated_at FROM app_blobs
+		SELECT path, mime_type, size_bytes, hash, COALESCE(md5, ''), updated_at FROM app_blobs
 		WHERE app_id = ? AND path = ?
 	`
-	var blobPath, mimeType string
+	var blobPath, mimeType, hash, sum string
 	var size, updatedAt int64
 
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
-		return s.db.QueryRowContext(ctx, query, appID, path).Scan(&blobPath, &mimeType, &size, &updatedAt)
+		return s.db.QueryRowContext(ctx, query, appID, path).Scan(&blobPath, &mimeType, &size, &hash, &sum, &updatedAt)
 	})
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -198,23 +296,59 @@ func (s *SQLBlobStore) GetMeta(ctx context.Context, appID, path string) (*BlobMe
 	if err != nil {
 		return nil, fmt.Errorf("failed to get blob metadata: %w", err)
 	}
+	if sum == "" {
+		if sum, err = s.fillMD5(ctx, appID, blobPath); err != nil {
+			return nil, err
+		}
+	}
 
 	return &BlobMeta{
 		Path:      blobPath,
 		MimeType:  mimeType,
 		Size:      size,
+		Hash:      hash,
+		MD5:       sum,
 		UpdatedAt: time.Unix(updatedAt, 0),
 	}, nil
 }
 
+// fillMD5 records the MD5 of a blob stored before MD5s were, or written
+// without one, and returns it
+func (s *SQLBlobStore) fillMD5(ctx context.Context, appID, path string) (string, error) {
+	var data []byte
+	err := withRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `SELECT data FROM app_blobs WHERE app_id = ? AND path = ?`, appID, path).Scan(&data)
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read blob: %w", err)
+	}
+	sum := md5Hash(data)
+
+	writeOp := func() error {
+		return withRetry(ctx, func() error {
+			_, err := s.db.ExecContext(ctx, `UPDATE app_blobs SET md5 = ? WHERE app_id = ? AND path = ?`, sum, appID, path)
+			return err
+		})
+	}
+	if s.writer != nil {
+		err = s.writer.Write(ctx, writeOp)
+	} else {
+		err = writeOp()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to store blob md5: %w", err)
+	}
+	return sum, nil
+}
+
 // Copy copies a blob to a new path.
 func (s *SQLBlobStore) Copy(ctx context.Context, appID, srcPath, dstPath string) error {
 	srcPath = normalizePath(srcPath)
 	dstPath = normalizePath(dstPath)
 
 	query := `
-		INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, created_at, updated_at)
-		SELECT app_id, ?, data, mime_type, size_bytes, hash, strftime('%s', 'now'), strftime('%s', 'now')
+		INSERT INTO app_blobs (app_id, path, data, mime_type, size_bytes, hash, md5, created_at, updated_at)
+		SELECT app_id, ?, data, mime_type, size_bytes, hash, md5, strftime('%s', 'now'), strftime('%s', 'now')
 		FROM app_blobs
 		WHERE app_id = ? AND path = ?
 		ON CONFLICT(app_id, path) DO UPDATE SET
@@ -222,6 +356,7 @@ func (s *SQLBlobStore) Copy(ctx context.Context, appID, srcPath, dstPath string)
 			mime_type = excluded.mime_type,
 			size_bytes = excluded.size_bytes,
 			hash = excluded.hash,
+			md5 = excluded.md5,
 			updated_at = strftime('%s', 'now')
 	`
 	var result sql.Result
@@ -234,6 +369,7 @@ func (s *SQLBlobStore) Copy(ctx context.Context, appID, srcPath, dstPath string)
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -263,6 +399,7 @@ func (s *SQLBlobStore) Move(ctx context.Context, appID, srcPath, dstPath string)
 func (s *SQLBlobStore) TotalSize(ctx context.Context, appID string) (int64, error) {
 	query := `SELECT COALESCE(SUM(size_bytes), 0) FROM app_blobs WHERE app_id = ?`
 	var total int64
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, appID).Scan(&total)
 	})
@@ -284,6 +421,11 @@ func normalizePath(path string) string {
 }
 
 // sha256Hash computes the SHA256 hash of data.
+func md5Hash(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func sha256Hash(data []byte) string {
 	h := sha256.New()
 	h.Write(data)
@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
 	"github.com/google/uuid"
 )
 
@@ -64,6 +67,7 @@ func (s *UserScopedKV) Set(ctx context.Context, key string, value interface{}, t
 		})
 	}
 
+	usage.Write(ctx, len(valueJSON))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -90,6 +94,7 @@ func (s *UserScopedKV) Get(ctx context.Context, key string) (interface{}, error)
 		AND (expires_at IS NULL OR expires_at > strftime('%s', 'now'))
 	`
 	var valueJSON string
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, s.appID, scopedKey).Scan(&valueJSON)
 	})
@@ -100,6 +105,10 @@ func (s *UserScopedKV) Get(ctx context.Context, key string) (interface{}, error)
 		return nil, fmt.Errorf("failed to get key: %w", err)
 	}
 
+	if err := sandbox.Alloc(ctx, len(valueJSON)); err != nil {
+		return nil, err
+	}
+
 	var value interface{}
 	if err := json.Unmarshal([]byte(valueJSON), &value); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal value: %w", err)
@@ -121,6 +130,7 @@ func (s *UserScopedKV) Delete(ctx context.Context, key string) error {
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -143,6 +153,7 @@ func (s *UserScopedKV) List(ctx context.Context, prefix string) ([]KVEntry, erro
 		ORDER BY key
 	`
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, query, s.appID, scopedPrefix+"%")
@@ -164,6 +175,10 @@ func (s *UserScopedKV) List(ctx context.Context, prefix string) ([]KVEntry, erro
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
+		if err := sandbox.Alloc(ctx, len(valueJSON)); err != nil {
+			return nil, err
+		}
+
 		var value interface{}
 		if err := json.Unmarshal([]byte(valueJSON), &value); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal value: %w", err)
@@ -241,6 +256,7 @@ func (s *UserScopedDocs) Insert(ctx context.Context, collection string, doc map[
 		})
 	}
 
+	usage.Write(ctx, len(dataJSON))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -290,6 +306,7 @@ func (s *UserScopedDocs) FindWithOptions(ctx context.Context, collection string,
 	}
 
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err = withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, sqlQuery, fullArgs...)
@@ -308,6 +325,10 @@ func (s *UserScopedDocs) FindWithOptions(ctx context.Context, collection string,
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
+		if err := sandbox.Alloc(ctx, len(dataJSON)); err != nil {
+			return nil, err
+		}
+
 		var data map[string]interface{}
 		if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal document: %w", err)
@@ -371,6 +392,7 @@ func (s *UserScopedDocs) Update(ctx context.Context, collection string, query, c
 		})
 	}
 
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -410,6 +432,7 @@ func (s *UserScopedDocs) Delete(ctx context.Context, collection string, query ma
 		})
 	}
 
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -422,6 +445,22 @@ func (s *UserScopedDocs) Delete(ctx context.Context, collection string, query ma
 	return result.RowsAffected()
 }
 
+// EnableSearch indexes fields of the user's collection (see
+// SQLDocStore.EnableSearch).
+func (s *UserScopedDocs) EnableSearch(ctx context.Context, collection string, fields []string) error {
+	return NewSQLDocStoreWithWriter(s.db, s.writer).EnableSearch(ctx, s.appID, s.scopeCollection(collection), fields)
+}
+
+// DisableSearch drops the index of the user's collection.
+func (s *UserScopedDocs) DisableSearch(ctx context.Context, collection string) error {
+	return NewSQLDocStoreWithWriter(s.db, s.writer).DisableSearch(ctx, s.appID, s.scopeCollection(collection))
+}
+
+// Search returns the user's documents matching a query, most relevant first.
+func (s *UserScopedDocs) Search(ctx context.Context, collection, query string, opts *SearchOptions) ([]SearchResult, error) {
+	return NewSQLDocStoreWithWriter(s.db, s.writer).Search(ctx, s.appID, s.scopeCollection(collection), query, opts)
+}
+
 // Count returns the number of documents matching a query.
 func (s *UserScopedDocs) Count(ctx context.Context, collection string, query map[string]interface{}) (int64, error) {
 	qb := NewQueryBuilder()
@@ -441,6 +480,7 @@ func (s *UserScopedDocs) Count(ctx context.Context, collection string, query map
 	`, whereClause)
 
 	var count int64
+	usage.Read(ctx)
 	err = withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, sqlQuery, fullArgs...).Scan(&count)
 	})
@@ -476,28 +516,34 @@ func (s *UserScopedBlobs) scopePath(path string) string {
 
 // Put stores a blob.
 func (s *UserScopedBlobs) Put(ctx context.Context, path string, data []byte, mimeType string) error {
+	if err := scanUpload(ctx, s.appID, normalizePath(path), data); err != nil {
+		return err
+	}
+
 	scopedPath := s.scopePath(path)
 	hash := sha256Hash(data)
 
 	query := `
-		INSERT INTO app_blobs (app_id, user_id, path, data, mime_type, size_bytes, hash, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
+		INSERT INTO app_blobs (app_id, user_id, path, data, mime_type, size_bytes, hash, md5, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now'))
 		ON CONFLICT(app_id, path) DO UPDATE SET
 			data = excluded.data,
 			mime_type = excluded.mime_type,
 			size_bytes = excluded.size_bytes,
 			hash = excluded.hash,
+			md5 = excluded.md5,
 			updated_at = strftime('%s', 'now')
 	`
 
 	writeOp := func() error {
 		return withRetry(ctx, func() error {
-			_, err := s.db.ExecContext(ctx, query, s.appID, s.userID, scopedPath, data, mimeType, len(data), hash)
+			_, err := s.db.ExecContext(ctx, query, s.appID, s.userID, scopedPath, data, mimeType, len(data), hash, md5Hash(data))
 			return err
 		})
 	}
 
 	var err error
+	usage.Write(ctx, len(data))
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -507,6 +553,13 @@ func (s *UserScopedBlobs) Put(ctx context.Context, path string, data []byte, mim
 		return fmt.Errorf("failed to store blob: %w", err)
 	}
 
+	events.Emit(events.S3Put, s.appID, map[string]interface{}{
+		"path":      scopedPath,
+		"user_id":   s.userID,
+		"mime_type": mimeType,
+		"size":      len(data),
+		"hash":      hash,
+	})
 	return nil
 }
 
@@ -522,6 +575,7 @@ func (s *UserScopedBlobs) Get(ctx context.Context, path string) (*Blob, error) {
 	var mimeType, hash string
 	var size int64
 
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		return s.db.QueryRowContext(ctx, query, s.appID, scopedPath).Scan(&data, &mimeType, &size, &hash)
 	})
@@ -532,6 +586,10 @@ func (s *UserScopedBlobs) Get(ctx context.Context, path string) (*Blob, error) {
 		return nil, fmt.Errorf("failed to get blob: %w", err)
 	}
 
+	if err := sandbox.Alloc(ctx, len(data)); err != nil {
+		return nil, err
+	}
+
 	return &Blob{
 		Data:     data,
 		MimeType: mimeType,
@@ -554,6 +612,7 @@ func (s *UserScopedBlobs) Delete(ctx context.Context, path string) error {
 	}
 
 	var err error
+	usage.Write(ctx, 0)
 	if s.writer != nil {
 		err = s.writer.Write(ctx, writeOp)
 	} else {
@@ -576,6 +635,7 @@ func (s *UserScopedBlobs) List(ctx context.Context, prefix string) ([]BlobMeta,
 		ORDER BY path
 	`
 	var rows *sql.Rows
+	usage.Read(ctx)
 	err := withRetry(ctx, func() error {
 		var err error
 		rows, err = s.db.QueryContext(ctx, query, s.appID, scopedPrefix+"%")
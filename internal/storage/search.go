@@ -0,0 +1,421 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+// Full-text search. An app enables search on a collection by naming the
+// fields to index; the config lives in app_doc_search, and triggers on
+// app_docs (migration 060) write those fields of every inserted or updated
+// document into a shared FTS5 table, so documents written through any API
+// are searchable without the bindings knowing about it. Enabling search, or
+// changing the fields, rebuilds the collection's index. A user-scoped
+// collection is its own collection here, indexed per user.
+
+const (
+	// maxSearchFields caps the fields indexed per collection
+	maxSearchFields = 16
+
+	// DefaultSearchLimit and MaxSearchLimit bound the results of a search
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+
+	// snippetTokens is the length of a result's excerpt
+	snippetTokens = 16
+)
+
+// Snippet markers, swapped for <mark> tags once the excerpt is escaped
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// ErrSearchNotEnabled is returned by Search on a collection without an index
+var ErrSearchNotEnabled = errors.New("search is not enabled for this collection; call ds.enableSearch first")
+
+var searchTermRe = regexp.MustCompile(`[\p{L}\p{N}_]+`)
+
+// SearchOptions configures Search
+type SearchOptions struct {
+	Limit  int  // Max results (default 20, max 100)
+	Offset int  // Skip this many results
+	Raw    bool // The query is FTS5 syntax (AND, OR, NOT, "phrases", prefix*) rather than plain words
+}
+
+// SearchResult is a matching document with its relevance and an excerpt
+type SearchResult struct {
+	Document
+	Score   float64 `json:"score"`   // Higher is more relevant
+	Snippet string  `json:"snippet"` // HTML-escaped, matches wrapped in <mark>
+}
+
+// SearchFields returns the fields indexed for a collection, or nil if
+// search isn't enabled on it
+func (s *SQLDocStore) SearchFields(ctx context.Context, appID, collection string) ([]string, error) {
+	var fieldsJSON string
+	err := withRetry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, `SELECT fields FROM app_doc_search WHERE app_id = ? AND collection = ?`,
+			appID, collection).Scan(&fieldsJSON)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search config: %w", err)
+	}
+
+	var fields []string
+	if err := json.Unmarshal([]byte(fieldsJSON), &fields); err != nil {
+		return nil, fmt.Errorf("invalid search config: %w", err)
+	}
+	return fields, nil
+}
+
+// EnableSearch indexes fields of a collection for Search and keeps them
+// indexed as documents change. Calling it again with the same fields is
+// cheap, so handlers can call it on every request; different fields
+// rebuild the index.
+func (s *SQLDocStore) EnableSearch(ctx context.Context, appID, collection string, fields []string) error {
+	if len(fields) == 0 || len(fields) > maxSearchFields {
+		return fmt.Errorf("search needs 1 to %d fields", maxSearchFields)
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !fieldNameRe.MatchString(f) {
+			return fmt.Errorf("invalid field name: %q", f)
+		}
+		if seen[f] {
+			return fmt.Errorf("duplicate field: %q", f)
+		}
+		seen[f] = true
+	}
+
+	current, err := s.SearchFields(ctx, appID, collection)
+	if err != nil {
+		return err
+	}
+	if equalStrings(current, fields) {
+		return nil
+	}
+
+	fieldsJSON, _ := json.Marshal(fields)
+	writeOp := func() error {
+		return withRetry(ctx, func() error {
+			tx, err := s.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO app_doc_search (app_id, collection, fields) VALUES (?, ?, ?)
+				ON CONFLICT (app_id, collection) DO UPDATE SET fields = excluded.fields
+			`, appID, collection, string(fieldsJSON)); err != nil {
+				return err
+			}
+			if err := buildSearchIndex(ctx, tx, appID, collection, string(fieldsJSON)); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+
+	usage.Write(ctx, 0)
+	if s.writer != nil {
+		err = s.writer.Write(ctx, writeOp)
+	} else {
+		err = writeOp()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable search: %w", err)
+	}
+	return nil
+}
+
+// DisableSearch drops a collection's index
+func (s *SQLDocStore) DisableSearch(ctx context.Context, appID, collection string) error {
+	writeOp := func() error {
+		return withRetry(ctx, func() error {
+			tx, err := s.db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+
+			if err := clearSearchIndex(ctx, tx, appID, collection); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM app_doc_search WHERE app_id = ? AND collection = ?`,
+				appID, collection); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+
+	var err error
+	usage.Write(ctx, 0)
+	if s.writer != nil {
+		err = s.writer.Write(ctx, writeOp)
+	} else {
+		err = writeOp()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to disable search: %w", err)
+	}
+	return nil
+}
+
+// RebuildSearchIndex reindexes every collection with search enabled from
+// its documents, and drops index rows left behind by deleted collections,
+// reporting the collections done so far and the total. It returns how many
+// documents were indexed.
+func RebuildSearchIndex(ctx context.Context, db *sql.DB, progress func(done, total int)) (int64, error) {
+	type searchConfig struct{ appID, collection, fields string }
+	var configs []searchConfig
+	rows, err := db.QueryContext(ctx, `SELECT app_id, collection, fields FROM app_doc_search ORDER BY app_id, collection`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var c searchConfig
+		if err := rows.Scan(&c.appID, &c.collection, &c.fields); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		configs = append(configs, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	progress(0, len(configs))
+
+	var indexed int64
+	for i, c := range configs {
+		err := QueueWrite(ctx, func() error {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if err := buildSearchIndex(ctx, tx, c.appID, c.collection, c.fields); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+		if err != nil {
+			return indexed, fmt.Errorf("reindex %s/%s: %w", c.appID, c.collection, err)
+		}
+		var n int64
+		if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM app_doc_search_rows WHERE app_id = ? AND collection = ?`,
+			c.appID, c.collection).Scan(&n); err != nil {
+			return indexed, err
+		}
+		indexed += n
+		progress(i+1, len(configs))
+	}
+
+	err = QueueWrite(ctx, func() error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM app_doc_search_rows WHERE NOT EXISTS (
+				SELECT 1 FROM app_doc_search s WHERE s.app_id = app_doc_search_rows.app_id
+					AND s.collection = app_doc_search_rows.collection)
+		`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM app_docs_fts WHERE rowid NOT IN (SELECT id FROM app_doc_search_rows)
+		`); err != nil {
+			return err
+		}
+		// Merge the index's segments, which reindexing leaves many of
+		if _, err := tx.ExecContext(ctx, `INSERT INTO app_docs_fts (app_docs_fts) VALUES ('optimize')`); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return indexed, fmt.Errorf("clean up index: %w", err)
+	}
+	return indexed, nil
+}
+
+// buildSearchIndex replaces a collection's index with its documents'
+// fields, given as a JSON array
+func buildSearchIndex(ctx context.Context, tx *sql.Tx, appID, collection, fieldsJSON string) error {
+	if err := clearSearchIndex(ctx, tx, appID, collection); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO app_doc_search_rows (app_id, collection, doc_id)
+		SELECT app_id, collection, id FROM app_docs WHERE app_id = ? AND collection = ?
+	`, appID, collection); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO app_docs_fts (rowid, body)
+		SELECT r.id, (SELECT group_concat(json_extract(d.data, '$.' || f.value), char(10)) FROM json_each(?) f)
+		FROM app_doc_search_rows r
+		JOIN app_docs d ON d.app_id = r.app_id AND d.collection = r.collection AND d.id = r.doc_id
+		WHERE r.app_id = ? AND r.collection = ?
+	`, fieldsJSON, appID, collection)
+	return err
+}
+
+// clearSearchIndex removes a collection's documents from the index
+func clearSearchIndex(ctx context.Context, tx *sql.Tx, appID, collection string) error {
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM app_docs_fts WHERE rowid IN (
+			SELECT id FROM app_doc_search_rows WHERE app_id = ? AND collection = ?)
+	`, appID, collection); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM app_doc_search_rows WHERE app_id = ? AND collection = ?`,
+		appID, collection)
+	return err
+}
+
+// Search returns the documents of a collection matching a query, most
+// relevant first. A plain query matches documents containing every word,
+// the last one as a prefix so results can follow typing.
+func (s *SQLDocStore) Search(ctx context.Context, appID, collection, query string, opts *SearchOptions) ([]SearchResult, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	fields, err := s.SearchFields(ctx, appID, collection)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, ErrSearchNotEnabled
+	}
+
+	match := query
+	if !opts.Raw {
+		match = searchMatch(query)
+	}
+	results := []SearchResult{}
+	if strings.TrimSpace(match) == "" {
+		return results, nil
+	}
+
+	sqlQuery := `
+		SELECT d.id, d.data, d.created_at, d.updated_at,
+			snippet(app_docs_fts, 0, char(2), char(3), '…', ?), bm25(app_docs_fts)
+		FROM app_docs_fts
+		JOIN app_doc_search_rows r ON r.id = app_docs_fts.rowid
+		JOIN app_docs d ON d.app_id = r.app_id AND d.collection = r.collection AND d.id = r.doc_id
+		WHERE app_docs_fts MATCH ? AND r.app_id = ? AND r.collection = ?
+		ORDER BY bm25(app_docs_fts)
+		LIMIT ? OFFSET ?
+	`
+	args := []interface{}{snippetTokens, match, appID, collection, limit, max(opts.Offset, 0)}
+
+	var rows *sql.Rows
+	usage.Read(ctx)
+	err = withRetry(ctx, func() error {
+		var err error
+		rows, err = s.db.QueryContext(ctx, sqlQuery, args...)
+		return err
+	})
+	if err != nil {
+		if opts.Raw {
+			return nil, fmt.Errorf("invalid search query: %w", err)
+		}
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, dataJSON, snippet string
+		var createdAt, updatedAt int64
+		var rank float64
+		if err := rows.Scan(&id, &dataJSON, &createdAt, &updatedAt, &snippet, &rank); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if err := sandbox.Alloc(ctx, len(dataJSON)); err != nil {
+			return nil, err
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(dataJSON), &data); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal document: %w", err)
+		}
+
+		results = append(results, SearchResult{
+			Document: Document{
+				ID:        id,
+				Data:      data,
+				CreatedAt: time.Unix(createdAt, 0),
+				UpdatedAt: time.Unix(updatedAt, 0),
+			},
+			Score:   -rank, // bm25 ranks better matches lower
+			Snippet: highlightSnippet(snippet),
+		})
+	}
+	if err := rows.Err(); err != nil {
+		if opts.Raw {
+			return nil, fmt.Errorf("invalid search query: %w", err)
+		}
+		return nil, fmt.Errorf("failed to search documents: %w", err)
+	}
+
+	return results, nil
+}
+
+// searchMatch turns plain words into an FTS5 query: each word quoted, so
+// operators and punctuation in user input are just text, and the last one
+// matched as a prefix
+func searchMatch(query string) string {
+	terms := searchTermRe.FindAllString(query, -1)
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	if len(terms) > 0 {
+		terms[len(terms)-1] += "*"
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlightSnippet escapes an excerpt for HTML and marks the matches
+func highlightSnippet(s string) string {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, snippetOpen, "<mark>")
+	return strings.ReplaceAll(s, snippetClose, "</mark>")
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
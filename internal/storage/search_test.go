@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func TestDocSearch(t *testing.T) {
+	db := dbtest.Open(t)
+	ds := NewSQLDocStore(db)
+	ctx := context.Background()
+
+	ds.Insert(ctx, "blog", "posts", map[string]interface{}{
+		"id": "p1", "title": "Baking sourdough bread", "body": "A starter, flour and patience.",
+	})
+	ds.Insert(ctx, "blog", "posts", map[string]interface{}{
+		"id": "p2", "title": "Running a home server", "body": "Backups <b>matter</b>: bake them into cron.",
+	})
+	ds.Insert(ctx, "other", "posts", map[string]interface{}{"id": "x1", "title": "Bread for another app"})
+
+	if _, err := ds.Search(ctx, "blog", "posts", "bread", nil); !errors.Is(err, ErrSearchNotEnabled) {
+		t.Fatalf("Expected ErrSearchNotEnabled, got %v", err)
+	}
+
+	// Existing documents are indexed when search is enabled
+	if err := ds.EnableSearch(ctx, "blog", "posts", []string{"title", "body"}); err != nil {
+		t.Fatalf("EnableSearch failed: %v", err)
+	}
+	results, err := ds.Search(ctx, "blog", "posts", "bread", nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "p1" || results[0].Data["title"] != "Baking sourdough bread" {
+		t.Fatalf("Expected p1 only, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "<mark>bread</mark>") || results[0].Score <= 0 {
+		t.Errorf("Unexpected snippet or score: %q, %v", results[0].Snippet, results[0].Score)
+	}
+
+	// Stemming, prefixes, and snippets escaped for HTML
+	results, _ = ds.Search(ctx, "blog", "posts", "bak", nil)
+	if len(results) != 2 {
+		t.Errorf("Expected the prefix to match both posts, got %d", len(results))
+	}
+	results, _ = ds.Search(ctx, "blog", "posts", "backups", nil)
+	if len(results) != 1 || strings.Contains(results[0].Snippet, "<b>") || !strings.Contains(results[0].Snippet, "&lt;b&gt;") {
+		t.Errorf("Expected an escaped snippet, got %+v", results)
+	}
+
+	// Operators in plain queries are just words
+	if _, err := ds.Search(ctx, "blog", "posts", `bread" OR (`, nil); err != nil {
+		t.Errorf("Expected a plain query to never fail, got %v", err)
+	}
+	if _, err := ds.Search(ctx, "blog", "posts", `bread" OR (`, &SearchOptions{Raw: true}); err == nil {
+		t.Error("Expected a malformed raw query to fail")
+	}
+	results, _ = ds.Search(ctx, "blog", "posts", "sourdough OR cron", &SearchOptions{Raw: true})
+	if len(results) != 2 {
+		t.Errorf("Expected a raw OR query to match both posts, got %d", len(results))
+	}
+
+	// Writes after enabling keep the index in step
+	ds.Insert(ctx, "blog", "posts", map[string]interface{}{"id": "p3", "title": "Rye bread"})
+	ds.Update(ctx, "blog", "posts", map[string]interface{}{"id": "p1"}, map[string]interface{}{"title": "Baking focaccia"})
+	ds.Delete(ctx, "blog", "posts", map[string]interface{}{"id": "p2"})
+
+	results, _ = ds.Search(ctx, "blog", "posts", "bread", nil)
+	if len(results) != 1 || results[0].ID != "p3" {
+		t.Errorf("Expected only p3 to match bread after the writes, got %+v", results)
+	}
+	if results, _ := ds.Search(ctx, "blog", "posts", "cron", nil); len(results) != 0 {
+		t.Errorf("Expected the deleted post to be gone, got %+v", results)
+	}
+
+	// Changing the fields rebuilds the index
+	if err := ds.EnableSearch(ctx, "blog", "posts", []string{"title"}); err != nil {
+		t.Fatalf("EnableSearch failed: %v", err)
+	}
+	if results, _ := ds.Search(ctx, "blog", "posts", "starter", nil); len(results) != 0 {
+		t.Errorf("Expected body no longer to be searched, got %+v", results)
+	}
+
+	if err := ds.EnableSearch(ctx, "blog", "posts", []string{"title; DROP"}); err == nil {
+		t.Error("Expected an invalid field name to be rejected")
+	}
+
+	if err := ds.DisableSearch(ctx, "blog", "posts"); err != nil {
+		t.Fatalf("DisableSearch failed: %v", err)
+	}
+	var rows int
+	db.QueryRow(`SELECT COUNT(*) FROM app_docs_fts`).Scan(&rows)
+	if rows != 0 {
+		t.Errorf("Expected the index emptied, got %d rows", rows)
+	}
+}
+
+func TestUserDocSearch(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	alice := NewUserScopedDocs(db, nil, "notes", "alice")
+	bob := NewUserScopedDocs(db, nil, "notes", "bob")
+
+	alice.Insert(ctx, "notes", map[string]interface{}{"text": "Buy milk"})
+	bob.Insert(ctx, "notes", map[string]interface{}{"text": "Milk the cows"})
+
+	alice.EnableSearch(ctx, "notes", []string{"text"})
+	results, err := alice.Search(ctx, "notes", "milk", nil)
+	if err != nil || len(results) != 1 || results[0].Data["text"] != "Buy milk" {
+		t.Errorf("Expected only alice's note, got %+v (%v)", results, err)
+	}
+	if _, err := bob.Search(ctx, "notes", "milk", nil); !errors.Is(err, ErrSearchNotEnabled) {
+		t.Errorf("Expected search to be enabled per user, got %v", err)
+	}
+}
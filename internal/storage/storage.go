@@ -29,12 +29,35 @@ func GetWriter() *WriteQueue {
 
 // QueueWrite submits a write operation to the global queue.
 // This is the preferred way for non-storage packages (like analytics) to do writes.
+// fn is retried on SQLITE_BUSY, so it must be a single statement or a
+// transaction that is safe to run again.
 func QueueWrite(ctx context.Context, fn func() error) error {
+	retried := func() error { return withRetry(ctx, fn) }
 	if globalWriter == nil {
 		// Fallback: execute directly (shouldn't happen in production)
-		return fn()
+		return retried()
 	}
-	return globalWriter.Write(ctx, fn)
+	return globalWriter.Write(ctx, retried)
+}
+
+// QueueWriteTx submits a transactional write to the global queue, where it
+// may share a transaction with other small writes. Prefer it over QueueWrite
+// for frequent writes of a few statements.
+func QueueWriteTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	if globalWriter == nil {
+		return withRetry(ctx, func() error {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if err := fn(tx); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+	return globalWriter.WriteTx(ctx, db, fn)
 }
 
 // KVStore provides key-value storage operations.
@@ -90,6 +113,8 @@ type BlobMeta struct {
 	Path      string    `json:"path"`
 	MimeType  string    `json:"mime"`
 	Size      int64     `json:"size"`
+	Hash      string    `json:"hash,omitempty"` // SHA-256 of the data, where the listing includes it
+	MD5       string    `json:"md5,omitempty"`  // MD5 of the data, an S3 object's ETag, where the listing includes it
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
@@ -3,10 +3,20 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/services/media"
+	"github.com/fazt-sh/fazt/internal/system"
+	"github.com/fazt-sh/fazt/internal/usage"
 	_ "modernc.org/sqlite"
 )
 
@@ -63,6 +73,7 @@ func setupTestDB(t *testing.T) *sql.DB {
 			mime_type TEXT NOT NULL,
 			size_bytes INTEGER NOT NULL,
 			hash TEXT NOT NULL,
+			md5 TEXT,
 			created_at INTEGER DEFAULT (strftime('%s', 'now')),
 			updated_at INTEGER DEFAULT (strftime('%s', 'now')),
 			PRIMARY KEY (app_id, path)
@@ -219,6 +230,30 @@ func TestKVStore(t *testing.T) {
 }
 
 // TestDocStore tests the document store.
+func TestStorageUsageMetering(t *testing.T) {
+	db := setupTestDB(t)
+	kv := NewSQLKVStore(db)
+	defer kv.Close()
+	blobs := NewSQLBlobStore(db)
+	ctx, meter := usage.WithMeter(context.Background())
+
+	if err := kv.Set(ctx, "test-app", "k", "hello", nil); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := blobs.Put(ctx, "test-app", "a.txt", []byte("0123456789"), "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	kv.Get(ctx, "test-app", "k")
+	kv.Get(ctx, "test-app", "k") // served from cache, not a query
+	kv.List(ctx, "test-app", "")
+	blobs.Get(ctx, "test-app", "a.txt")
+
+	c := meter.Cost(0, 0)
+	if c.DBReads != 3 || c.DBWrites != 2 || c.StorageBytes != int64(len(`"hello"`))+10 {
+		t.Errorf("Unexpected metered cost: %+v", c)
+	}
+}
+
 func TestDocStore(t *testing.T) {
 	db := setupTestDB(t)
 	ds := NewSQLDocStore(db)
@@ -502,4 +537,230 @@ func TestBlobStore(t *testing.T) {
 			t.Error("expected non-empty hash")
 		}
 	})
+
+	t.Run("MD5", func(t *testing.T) {
+		blobs.Put(ctx, appID, "md5-test.txt", []byte("hello"), "text/plain")
+		const want = "5d41402abc4b2a76b9719d911017c592"
+
+		meta, err := blobs.GetMeta(ctx, appID, "md5-test.txt")
+		if err != nil || meta.MD5 != want {
+			t.Fatalf("GetMeta MD5 = %q, %v; want %s", meta.MD5, err, want)
+		}
+
+		// Blobs stored before MD5s were get theirs when first read
+		db.Exec(`UPDATE app_blobs SET md5 = NULL WHERE app_id = ?`, appID)
+		list, err := blobs.ListAfter(ctx, appID, "md5-", "", 10)
+		if err != nil || len(list) != 1 || list[0].MD5 != want {
+			t.Fatalf("ListAfter = %+v, %v", list, err)
+		}
+		var stored sql.NullString
+		db.QueryRow(`SELECT md5 FROM app_blobs WHERE app_id = ? AND path = 'md5-test.txt'`, appID).Scan(&stored)
+		if stored.String != want {
+			t.Errorf("Expected the MD5 to be stored, got %q", stored.String)
+		}
+	})
+}
+
+func TestUploadScanner(t *testing.T) {
+	db := setupTestDB(t)
+	db.Exec(`ALTER TABLE app_blobs ADD COLUMN user_id TEXT`)
+	blobs := NewSQLBlobStore(db)
+	userBlobs := NewUserScopedBlobs(db, nil, "test-app", "user_1")
+	ctx := context.Background()
+
+	var scanned []string
+	SetUploadScanner(func(ctx context.Context, appID, path string, data []byte) error {
+		scanned = append(scanned, appID+":"+path)
+		if string(data) == "infected" {
+			return errors.New("virus found")
+		}
+		return nil
+	})
+	defer SetUploadScanner(nil)
+
+	if err := blobs.Put(ctx, "test-app", "/uploads/a.txt", []byte("infected"), "text/plain"); err == nil {
+		t.Fatal("Expected the scanner to refuse the upload")
+	}
+	if blob, _ := blobs.Get(ctx, "test-app", "uploads/a.txt"); blob != nil {
+		t.Error("Expected a refused upload not stored")
+	}
+	if err := userBlobs.Put(ctx, "avatar.png", []byte("clean"), "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// The scanner sees the path the app wrote, before user scoping
+	if len(scanned) != 2 || scanned[0] != "test-app:uploads/a.txt" || scanned[1] != "test-app:avatar.png" {
+		t.Errorf("Unexpected scanned paths: %v", scanned)
+	}
+}
+
+func TestDeprecateStorageNamespace(t *testing.T) {
+	db := setupTestDB(t)
+	vm := goja.New()
+	if err := InjectStorageNamespace(vm, New(db), "app1", context.Background(), nil); err != nil {
+		t.Fatalf("InjectStorageNamespace failed: %v", err)
+	}
+
+	var lines []string
+	DeprecateStorageNamespace(vm, "v0", func(line string) { lines = append(lines, line) })
+
+	val, err := vm.RunString(`
+		fazt.storage.kv.set('a', 1)
+		fazt.storage.kv.set('b', 2)
+		var caught = false
+		try { fazt.storage.ds.insert() } catch (e) { caught = true }
+		fazt.storage.kv.get('a') + (caught ? '|caught' : '')
+	`)
+	if err != nil {
+		t.Fatalf("RunString failed: %v", err)
+	}
+	if got := val.String(); got != "1|caught" {
+		t.Errorf("Expected the bindings to work as before, got %s", got)
+	}
+
+	// One line per binding used, however often
+	want := []string{
+		"deprecated api=fazt.storage.kv.set use=fazt.app.kv.set runtime=v0",
+		"deprecated api=fazt.storage.ds.insert use=fazt.app.ds.insert runtime=v0",
+		"deprecated api=fazt.storage.kv.get use=fazt.app.kv.get runtime=v0",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected warnings:\n%s", strings.Join(lines, "\n"))
+	}
+}
+
+func TestMediaServe_HLS(t *testing.T) {
+	db := setupTestDB(t)
+	ctx := context.Background()
+	blobs := New(db).Blobs
+	blobs.Put(ctx, "app1", "clip.mp4", []byte("original"), "video/mp4")
+	blobs.Put(ctx, "app1", media.HLSPath("clip.mp4", media.HLSMaster), []byte("#EXTM3U\n"), media.HLSMimeType(media.HLSMaster))
+
+	serve := func(query string) goja.Value {
+		t.Helper()
+		q, _ := url.ParseQuery(query)
+		vm := goja.New()
+		if err := InjectAppNamespace(vm, db, nil, "app1", "", media.WithQuery(ctx, q), nil); err != nil {
+			t.Fatalf("InjectAppNamespace failed: %v", err)
+		}
+		val, err := vm.RunString(`var f = fazt.app.media.serve('clip.mp4'); f ? f.mime : null`)
+		if err != nil {
+			t.Fatalf("media.serve failed: %v", err)
+		}
+		return val
+	}
+
+	if got := serve("hls").String(); got != "application/vnd.apple.mpegurl" {
+		t.Errorf("Expected the master playlist, got %q", got)
+	}
+	if got := serve("").String(); got != "video/mp4" {
+		t.Errorf("Expected the original without ?hls, got %q", got)
+	}
+	// Not transcoded yet, or not a file HLS writes
+	for _, query := range []string{"hls=360p.m3u8", "hls=../clip.mp4"} {
+		if got := serve(query); !goja.IsNull(got) {
+			t.Errorf("%s: expected null, got %v", query, got)
+		}
+	}
+}
+
+func TestMediaThumbnailWaveform_NoFFmpeg(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	system.ResetCachedLimits()
+	t.Cleanup(system.ResetCachedLimits)
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	blobs := New(db).Blobs
+	blobs.Put(ctx, "app1", "clip.mp4", []byte("video"), "video/mp4")
+	blobs.Put(ctx, "app1", "notes.txt", []byte("text"), "text/plain")
+
+	vm := goja.New()
+	if err := InjectAppNamespace(vm, db, nil, "app1", "", ctx, nil); err != nil {
+		t.Fatalf("InjectAppNamespace failed: %v", err)
+	}
+	val, err := vm.RunString(`
+		var media = fazt.app.media
+		var threw = []
+		try { media.thumbnail('notes.txt') } catch (e) { threw.push('type') }
+		try { media.waveform('clip.mp4', 5000) } catch (e) { threw.push('samples') }
+		try { media.thumbnail('clip.mp4', -1) } catch (e) { threw.push('at') }
+		[media.thumbnail('clip.mp4', 1), media.waveform('clip.mp4'), media.thumbnail('missing.mp4'), threw.join(',')]
+	`)
+	if err != nil {
+		t.Fatalf("script failed: %v", err)
+	}
+	got := val.Export().([]interface{})
+	// Without ffmpeg, and for missing files, both return null
+	for i := 0; i < 3; i++ {
+		if got[i] != nil {
+			t.Errorf("result %d: expected null, got %v", i, got[i])
+		}
+	}
+	if got[3] != "type,samples,at" {
+		t.Errorf("Expected bad arguments to throw, got %v", got[3])
+	}
+}
+
+func TestMediaPreview(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	// A pdftoppm that writes "page@<size>"; no LibreOffice
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor a; do [ \"$prev\" = -scale-to ] && size=$a; prev=$a; root=$a; done\nprintf page@%s $size > $root.png\n"
+	if err := os.WriteFile(filepath.Join(bin, "pdftoppm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/usr/bin:/bin")
+	system.ResetCachedLimits()
+	t.Cleanup(system.ResetCachedLimits)
+
+	db := setupTestDB(t)
+	ctx := context.Background()
+	blobs := New(db).Blobs
+	blobs.Put(ctx, "app1", "report.pdf", []byte("%PDF-1.4"), "application/pdf")
+	blobs.Put(ctx, "app1", "notes.docx", []byte("doc"), "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	blobs.Put(ctx, "app1", "notes.txt", []byte("text"), "text/plain")
+
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	run := func(query, script string) goja.Value {
+		t.Helper()
+		q, _ := url.ParseQuery(query)
+		vm := goja.New()
+		if err := InjectAppNamespace(vm, db, nil, "app1", "", media.WithQuery(ctx, q), nil); err != nil {
+			t.Fatalf("InjectAppNamespace failed: %v", err)
+		}
+		val, err := vm.RunString(`function page(f) { return f ? f.mime + ":" + f.data : null }
+` + script)
+		if err != nil {
+			t.Fatalf("script failed: %v", err)
+		}
+		return val
+	}
+
+	got := run("", `
+		var media = fazt.app.media
+		var threw = []
+		try { media.preview('notes.txt') } catch (e) { threw.push('type') }
+		try { media.preview('report.pdf', 4096) } catch (e) { threw.push('size') }
+		[page(media.preview('report.pdf', 128)), page(media.preview('report.pdf')),
+		 media.preview('notes.docx'), media.preview('missing.pdf'), threw.join(',')]
+	`).Export().([]interface{})
+	want := []interface{}{"image/png:" + b64("page@128"), "image/png:" + b64("page@512"), nil, nil, "type,size"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// ?preview serves the same; other files get null
+	if got := run("preview=64", `page(fazt.app.media.serve('report.pdf'))`).String(); got != "image/png:"+b64("page@64") {
+		t.Errorf("?preview: got %q", got)
+	}
+	for _, path := range []string{"notes.txt", "missing.pdf"} {
+		if got := run("preview", `page(fazt.app.media.serve('`+path+`'))`); !goja.IsNull(got) {
+			t.Errorf("?preview of %s: expected null, got %v", path, got)
+		}
+	}
 }
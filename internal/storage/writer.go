@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -10,18 +11,45 @@ import (
 
 // WriteQueue serializes all write operations to prevent SQLITE_BUSY errors.
 // All storage writes go through this queue, processed by a single goroutine.
+// Transactional writes queued back to back are batched into one transaction,
+// each in its own savepoint, so a burst costs one commit instead of many.
 type WriteQueue struct {
 	queue    chan writeOp
 	queueLen int32 // atomic counter for monitoring
 	maxQueue int
+	maxBatch int
 	done     chan struct{}
 	wg       sync.WaitGroup
+
+	// Backpressure metrics
+	peak          atomic.Int32
+	writes        atomic.Int64
+	failed        atomic.Int64
+	rejected      atomic.Int64
+	waited        atomic.Int64
+	batches       atomic.Int64
+	batchedWrites atomic.Int64
+	dequeues      atomic.Int64
+	waitNanos     atomic.Int64
+	maxWaitNanos  atomic.Int64
 }
 
 type writeOp struct {
-	fn   func() error
-	done chan error
-	ctx  context.Context
+	fn     func() error
+	db     *sql.DB // Set with txFn for batchable writes
+	txFn   func(tx *sql.Tx) error
+	done   chan error
+	ctx    context.Context
+	queued time.Time
+
+	pending *pendingWrites // the request's read-your-writes tracker, if any
+}
+
+// settle reports the op's result and releases it from its request's
+// pending writes
+func (op writeOp) settle(err error) {
+	op.done <- err
+	op.pending.release()
 }
 
 // WriteQueueConfig configures the write queue.
@@ -33,6 +61,10 @@ type WriteQueueConfig struct {
 	// Workers is the number of write workers. Keep at 1 for SQLite.
 	// Default: 1
 	Workers int
+
+	// MaxBatch is the most transactional writes committed together.
+	// Default: 64
+	MaxBatch int
 }
 
 // DefaultWriteQueueConfig returns sensible defaults.
@@ -40,6 +72,7 @@ func DefaultWriteQueueConfig() WriteQueueConfig {
 	return WriteQueueConfig{
 		QueueSize: 1000,
 		Workers:   1, // SQLite only supports 1 writer
+		MaxBatch:  64,
 	}
 }
 
@@ -51,10 +84,14 @@ func NewWriteQueue(cfg WriteQueueConfig) *WriteQueue {
 	if cfg.Workers <= 0 {
 		cfg.Workers = 1
 	}
+	if cfg.MaxBatch <= 0 {
+		cfg.MaxBatch = 64
+	}
 
 	wq := &WriteQueue{
 		queue:    make(chan writeOp, cfg.QueueSize),
 		maxQueue: cfg.QueueSize,
+		maxBatch: cfg.MaxBatch,
 		done:     make(chan struct{}),
 	}
 
@@ -67,39 +104,164 @@ func NewWriteQueue(cfg WriteQueueConfig) *WriteQueue {
 	return wq
 }
 
-// worker processes writes sequentially.
+// worker processes writes sequentially, batching consecutive transactional
+// writes against the same database.
 func (wq *WriteQueue) worker() {
 	defer wq.wg.Done()
 
+	var next *writeOp
 	for {
-		select {
-		case op := <-wq.queue:
-			atomic.AddInt32(&wq.queueLen, -1)
+		var op writeOp
+		if next != nil {
+			op, next = *next, nil
+		} else {
+			select {
+			case op = <-wq.queue:
+				wq.dequeued(op)
+			case <-wq.done:
+				return
+			}
+		}
 
-			// Check if context already cancelled
+		if op.txFn == nil {
+			wq.run(op)
+			continue
+		}
+
+		batch := []writeOp{op}
+	drain:
+		for len(batch) < wq.maxBatch {
 			select {
-			case <-op.ctx.Done():
-				op.done <- op.ctx.Err()
-				continue
+			case o := <-wq.queue:
+				wq.dequeued(o)
+				if o.txFn != nil && o.db == op.db {
+					batch = append(batch, o)
+					continue
+				}
+				// Keep order: run it right after the batch
+				next = &o
+				break drain
 			default:
+				break drain
 			}
+		}
+		wq.runBatch(batch)
+	}
+}
+
+// dequeued records an op leaving the queue and how long it waited
+func (wq *WriteQueue) dequeued(op writeOp) {
+	atomic.AddInt32(&wq.queueLen, -1)
+	wq.dequeues.Add(1)
+	wait := int64(time.Since(op.queued))
+	wq.waitNanos.Add(wait)
+	for {
+		max := wq.maxWaitNanos.Load()
+		if wait <= max || wq.maxWaitNanos.CompareAndSwap(max, wait) {
+			break
+		}
+	}
+}
 
-			// Execute the write
-			err := op.fn()
-			op.done <- err
+// run executes a single write
+func (wq *WriteQueue) run(op writeOp) {
+	// Check if context already cancelled
+	if err := op.ctx.Err(); err != nil {
+		op.settle(err)
+		return
+	}
 
-		case <-wq.done:
-			return
+	err := op.fn()
+	wq.finished(err)
+	op.settle(err)
+}
+
+// runBatch executes transactional writes in one transaction. Each write
+// gets a savepoint, so one failing rolls back only its own changes.
+func (wq *WriteQueue) runBatch(batch []writeOp) {
+	live := batch[:0]
+	for _, op := range batch {
+		if err := op.ctx.Err(); err != nil {
+			op.settle(err)
+			continue
 		}
+		live = append(live, op)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	errs := make([]error, len(live))
+	err := withRetry(context.Background(), func() error {
+		tx, err := live[0].db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		for i, op := range live {
+			errs[i] = runSavepoint(tx, op.txFn)
+			if isRetryable(errs[i]) {
+				// The lock is lost for the whole transaction; retry it all
+				return errs[i]
+			}
+		}
+		return tx.Commit()
+	})
+
+	if len(live) > 1 {
+		wq.batches.Add(1)
+		wq.batchedWrites.Add(int64(len(live)))
+	}
+	for i, op := range live {
+		if err != nil {
+			errs[i] = err
+		}
+		wq.finished(errs[i])
+		op.settle(errs[i])
+	}
+}
+
+func runSavepoint(tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT write_op"); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Exec("ROLLBACK TO write_op")
+		tx.Exec("RELEASE write_op")
+		return err
+	}
+	_, err := tx.Exec("RELEASE write_op")
+	return err
+}
+
+func (wq *WriteQueue) finished(err error) {
+	wq.writes.Add(1)
+	if err != nil {
+		wq.failed.Add(1)
 	}
 }
 
 // Write queues a write operation and waits for completion.
 // Returns ErrQueueFull if the queue is at capacity.
 // Returns ErrInsufficientTime if deadline won't allow operation to complete.
+// Writes without a deadline (background work) wait for room instead of
+// being rejected when the queue is full.
 func (wq *WriteQueue) Write(ctx context.Context, fn func() error) error {
+	return wq.enqueue(ctx, writeOp{fn: fn})
+}
+
+// WriteTx queues a write that runs inside a transaction on db, possibly
+// shared with other queued writes. fn must not commit or roll back tx; an
+// error it returns undoes only its own changes.
+func (wq *WriteQueue) WriteTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	return wq.enqueue(ctx, writeOp{db: db, txFn: fn})
+}
+
+func (wq *WriteQueue) enqueue(ctx context.Context, op writeOp) error {
 	// Admission control: check if we have enough time before queueing
-	if deadline, ok := ctx.Deadline(); ok {
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
 		remaining := time.Until(deadline)
 		// Estimate queue wait based on current depth (30ms per queued op estimate)
 		queueDepth := atomic.LoadInt32(&wq.queueLen)
@@ -108,6 +270,7 @@ func (wq *WriteQueue) Write(ctx context.Context, fn func() error) error {
 		minRequired := estimatedWait + 500*time.Millisecond
 
 		if remaining < minRequired {
+			wq.rejected.Add(1)
 			return &StorageError{
 				Op:        "write_queue",
 				Cause:     fmt.Errorf("insufficient time: need %v, have %v (queue depth: %d)", minRequired, remaining, queueDepth),
@@ -117,19 +280,43 @@ func (wq *WriteQueue) Write(ctx context.Context, fn func() error) error {
 	}
 
 	done := make(chan error, 1)
-	op := writeOp{
-		fn:   fn,
-		done: done,
-		ctx:  ctx,
+	op.done = done
+	op.ctx = ctx
+	op.queued = time.Now()
+
+	// Once queued, the op counts as pending for its request until the worker
+	// settles it, even if the caller stops waiting
+	op.pending = pendingFrom(ctx)
+	if op.pending != nil {
+		op.pending.add()
 	}
 
 	// Try to queue the operation
+	depth := atomic.AddInt32(&wq.queueLen, 1)
 	select {
 	case wq.queue <- op:
-		atomic.AddInt32(&wq.queueLen, 1)
 	default:
-		// Queue is full
-		return ErrQueueFull
+		if hasDeadline {
+			// Queue is full
+			atomic.AddInt32(&wq.queueLen, -1)
+			op.pending.release()
+			wq.rejected.Add(1)
+			return ErrQueueFull
+		}
+		wq.waited.Add(1)
+		select {
+		case wq.queue <- op:
+		case <-ctx.Done():
+			atomic.AddInt32(&wq.queueLen, -1)
+			op.pending.release()
+			return ctx.Err()
+		}
+	}
+	for {
+		peak := wq.peak.Load()
+		if depth <= peak || wq.peak.CompareAndSwap(peak, depth) {
+			break
+		}
 	}
 
 	// Wait for completion or context cancellation
@@ -204,19 +391,46 @@ type WriteStats struct {
 	QueueDepth    int     `json:"queue_depth"`
 	QueueCapacity int     `json:"queue_capacity"`
 	Utilization   float64 `json:"utilization"` // 0.0 - 1.0
+	PeakDepth     int     `json:"peak_depth"`
+
+	Writes        int64   `json:"writes"` // Completed, including failed
+	Failed        int64   `json:"failed"`
+	Rejected      int64   `json:"rejected"` // Queue full or not enough time left
+	Waited        int64   `json:"waited"`   // Background writes that waited for room
+	Batches       int64   `json:"batches"`  // Transactions shared by several writes
+	BatchedWrites int64   `json:"batched_writes"`
+	BusyRetries   int64   `json:"busy_retries"` // SQLITE_BUSY retries, process-wide
+	AvgWaitMs     float64 `json:"avg_wait_ms"`  // Time spent queued
+	MaxWaitMs     float64 `json:"max_wait_ms"`
 }
 
 // Stats returns current write queue statistics.
 func (wq *WriteQueue) Stats() WriteStats {
+	if wq == nil {
+		return WriteStats{}
+	}
 	depth := wq.QueueDepth()
 	capacity := wq.QueueCapacity()
 	util := 0.0
 	if capacity > 0 {
 		util = float64(depth) / float64(capacity)
 	}
-	return WriteStats{
+	stats := WriteStats{
 		QueueDepth:    depth,
 		QueueCapacity: capacity,
 		Utilization:   util,
+		PeakDepth:     int(wq.peak.Load()),
+		Writes:        wq.writes.Load(),
+		Failed:        wq.failed.Load(),
+		Rejected:      wq.rejected.Load(),
+		Waited:        wq.waited.Load(),
+		Batches:       wq.batches.Load(),
+		BatchedWrites: wq.batchedWrites.Load(),
+		BusyRetries:   busyRetries.Load(),
+		MaxWaitMs:     float64(wq.maxWaitNanos.Load()) / float64(time.Millisecond),
+	}
+	if dequeued := wq.dequeues.Load(); dequeued > 0 {
+		stats.AvgWaitMs = float64(wq.waitNanos.Load()) / float64(dequeued) / float64(time.Millisecond)
 	}
+	return stats
 }
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockWorker occupies the queue's worker until the returned func is called
+func blockWorker(t *testing.T, wq *WriteQueue) func() {
+	t.Helper()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go wq.Write(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	return func() { close(release) }
+}
+
+func TestWriteQueueBatchesTransactions(t *testing.T) {
+	db := setupTestDB(t)
+	wq := NewWriteQueue(WriteQueueConfig{QueueSize: 100})
+	defer wq.Close()
+
+	release := blockWorker(t, wq)
+
+	boom := errors.New("boom")
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = wq.WriteTx(context.Background(), db, func(tx *sql.Tx) error {
+				if _, err := tx.Exec(`INSERT INTO app_kv (app_id, key, value) VALUES ('app', ?, '1')`, i); err != nil {
+					return err
+				}
+				if i == 3 {
+					return boom
+				}
+				return nil
+			})
+		}(i)
+	}
+	for wq.QueueDepth() < 10 {
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	wg.Wait()
+
+	for i, err := range errs {
+		if i == 3 && err != boom {
+			t.Errorf("Expected the failing write to return its error, got %v", err)
+		} else if i != 3 && err != nil {
+			t.Errorf("Write %d failed: %v", i, err)
+		}
+	}
+
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM app_kv WHERE app_id = 'app'`).Scan(&n)
+	if n != 9 {
+		t.Errorf("Expected the failed write alone rolled back (9 rows), got %d", n)
+	}
+
+	stats := wq.Stats()
+	if stats.Batches != 1 || stats.BatchedWrites != 10 {
+		t.Errorf("Expected one batch of 10, got %d batches of %d writes", stats.Batches, stats.BatchedWrites)
+	}
+	if stats.Writes != 11 || stats.Failed != 1 || stats.PeakDepth < 10 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestWriteQueueBackpressure(t *testing.T) {
+	wq := NewWriteQueue(WriteQueueConfig{QueueSize: 1})
+	defer wq.Close()
+
+	release := blockWorker(t, wq)
+
+	// Fill the single slot
+	go wq.Write(context.Background(), func() error { return nil })
+	for wq.QueueDepth() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// A request write with a deadline is rejected
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := wq.Write(ctx, func() error { return nil }); err != ErrQueueFull {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+
+	// A background write waits for room
+	done := make(chan error, 1)
+	go func() { done <- wq.Write(context.Background(), func() error { return nil }) }()
+	time.Sleep(20 * time.Millisecond)
+	release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Background write failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Background write never ran")
+	}
+
+	stats := wq.Stats()
+	if stats.Rejected != 1 || stats.Waited != 1 {
+		t.Errorf("Expected 1 rejected and 1 waited, got %+v", stats)
+	}
+}
+
+func TestReadYourWritesAwaitsAbandonedWrite(t *testing.T) {
+	wq := NewWriteQueue(WriteQueueConfig{QueueSize: 10})
+	defer wq.Close()
+
+	reqCtx := WithReadYourWrites(context.Background())
+	opCtx, cancel := context.WithTimeout(reqCtx, 600*time.Millisecond)
+	defer cancel()
+
+	// The caller gives up while the write is still running
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var committed bool
+	go wq.Write(opCtx, func() error {
+		close(started)
+		<-release
+		committed = true
+		return nil
+	})
+	<-started
+	<-opCtx.Done()
+
+	// A read under an expired context reports the pending write
+	expired, cancelExpired := context.WithCancel(reqCtx)
+	cancelExpired()
+	if err := AwaitWrites(expired); !IsRetryableError(err) {
+		t.Errorf("Expected retryable error while writes are pending, got %v", err)
+	}
+
+	awaited := make(chan error, 1)
+	go func() { awaited <- AwaitWrites(reqCtx) }()
+	select {
+	case <-awaited:
+		t.Fatal("Expected read to wait for the pending write")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-awaited:
+		if err != nil || !committed {
+			t.Errorf("Expected the write settled before the read, got %v (committed %v)", err, committed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read never resumed")
+	}
+
+	// Without the option reads never wait
+	if err := AwaitWrites(context.Background()); err != nil {
+		t.Errorf("Expected no wait without read-your-writes, got %v", err)
+	}
+}
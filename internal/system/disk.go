@@ -0,0 +1,107 @@
+package system
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notify"
+)
+
+const (
+	// DiskPressurePercent is the share of the data volume in use that
+	// raises a disk pressure alert
+	DiskPressurePercent = 90
+
+	// diskRecoveredPercent is how far usage must drop before another alert
+	diskRecoveredPercent = 85
+
+	diskCheckInterval = 5 * time.Minute
+)
+
+// Disk is the size of a filesystem and the space left for unprivileged use
+type Disk struct {
+	Total uint64 `json:"total"`
+	Free  uint64 `json:"free"`
+}
+
+// UsedPercent returns the share of the filesystem in use
+func (d Disk) UsedPercent() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Total-d.Free) / float64(d.Total) * 100
+}
+
+// DiskMonitor alerts when the filesystem holding the database fills up.
+// It alerts once when usage crosses DiskPressurePercent, and again only
+// after usage has dropped back below diskRecoveredPercent.
+type DiskMonitor struct {
+	path     string
+	usage    func(path string) (Disk, error)
+	notify   func(title, message string)
+	pressure bool
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewDiskMonitor creates a monitor for the filesystem holding path
+func NewDiskMonitor(path string) *DiskMonitor {
+	return &DiskMonitor{
+		path:  path,
+		usage: DiskUsage,
+		notify: func(title, message string) {
+			if err := notify.Send(notify.EventDiskPressure, title, message); err != nil {
+				log.Printf("Disk: failed to send alert: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start checks disk usage now and then every few minutes
+func (m *DiskMonitor) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.Check()
+
+		ticker := time.NewTicker(diskCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.Check()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the check loop
+func (m *DiskMonitor) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Check reads disk usage and alerts on the crossing into pressure
+func (m *DiskMonitor) Check() {
+	disk, err := m.usage(m.path)
+	if err != nil {
+		log.Printf("Disk: failed to read usage of %s: %v", m.path, err)
+		return
+	}
+
+	used := disk.UsedPercent()
+	switch {
+	case used >= DiskPressurePercent && !m.pressure:
+		m.pressure = true
+		m.notify("Disk almost full",
+			fmt.Sprintf("The volume holding %s is %.0f%% full, %.1f GB free. Writes fail once it fills up.",
+				m.path, used, float64(disk.Free)/(1<<30)))
+	case used < diskRecoveredPercent && m.pressure:
+		m.pressure = false
+	}
+}
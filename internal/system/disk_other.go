@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package system
+
+import "errors"
+
+// DiskUsage returns the size and free space of the filesystem holding path
+func DiskUsage(path string) (Disk, error) {
+	return Disk{}, errors.New("disk usage is not supported on this platform")
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package system
+
+import "syscall"
+
+// DiskUsage returns the size and free space of the filesystem holding path
+func DiskUsage(path string) (Disk, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Disk{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return Disk{Total: uint64(st.Blocks) * bsize, Free: uint64(st.Bavail) * bsize}, nil
+}
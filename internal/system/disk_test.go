@@ -0,0 +1,43 @@
+package system
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	disk, err := DiskUsage(os.TempDir())
+	if err != nil {
+		t.Skipf("DiskUsage not supported: %v", err)
+	}
+	if disk.Total == 0 || disk.Free > disk.Total {
+		t.Errorf("Unexpected disk usage: %+v", disk)
+	}
+}
+
+func TestDiskMonitor_AlertsOnceUntilRecovered(t *testing.T) {
+	var alerts int
+	used := uint64(50)
+	m := NewDiskMonitor("/data")
+	m.usage = func(string) (Disk, error) { return Disk{Total: 100, Free: 100 - used}, nil }
+	m.notify = func(title, message string) { alerts++ }
+
+	steps := []struct {
+		used uint64
+		want int
+	}{
+		{50, 0},
+		{92, 1}, // crossed into pressure
+		{95, 1}, // still under pressure, no repeat
+		{88, 1}, // not recovered yet
+		{80, 1}, // recovered
+		{91, 2}, // pressure again
+	}
+	for i, s := range steps {
+		used = s.used
+		m.Check()
+		if alerts != s.want {
+			t.Errorf("Step %d (%d%% used): %d alerts, want %d", i, s.used, alerts, s.want)
+		}
+	}
+}
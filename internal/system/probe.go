@@ -51,6 +51,9 @@ type Storage struct {
 type Runtime struct {
 	ExecTimeout int   `json:"exec_timeout" label:"Exec Timeout" desc:"Serverless execution timeout" unit:"ms" range:"100,10000"`
 	MaxMemory   int64 `json:"max_memory"   label:"Max Memory"   desc:"Per-execution memory limit"   unit:"bytes" range:"1048576,268435456"`
+	MaxCPU      int   `json:"max_cpu"      label:"Max CPU"      desc:"Per-execution CPU time limit" unit:"ms"    range:"10,10000"`
+	WasmMemory  int64 `json:"wasm_memory"  label:"WASM Memory"  desc:"Per-module memory limit"      unit:"bytes" range:"1048576,268435456"`
+	WasmTimeout int   `json:"wasm_timeout" label:"WASM Timeout" desc:"Per-call WASM time limit"     unit:"ms"    range:"10,10000"`
 }
 
 // Capacity holds capacity estimates based on stress testing.
@@ -93,6 +96,10 @@ type Media struct {
 	MaxSourceBytes int64 `json:"max_source_bytes"  label:"Max Source"      desc:"Max input image size"           unit:"bytes" range:"1048576,52428800"`
 	WidthStep      int   `json:"width_step"        label:"Width Step"      desc:"Cache widths divisible by this" range:"10,200"`
 	CacheMemoryMB  int   `json:"cache_memory_mb"   label:"Cache Memory"    desc:"In-memory LRU for variants"     unit:"MB" range:"0,512"`
+
+	// Document previews degrade like video: no converter → null, no error
+	PDFAvailable    bool `json:"pdf_available"    label:"PDF Previews"    desc:"pdftoppm detected at startup"    readonly:"true"`
+	OfficeAvailable bool `json:"office_available" label:"Office Previews" desc:"LibreOffice detected at startup" readonly:"true"`
 }
 
 // Video holds video processing limits. Transcoding degrades gracefully:
@@ -174,6 +181,19 @@ func GetLimits() *Limits {
 		ffmpegAvailable = true
 	}
 
+	// Documents: detect pdftoppm (poppler) and LibreOffice for previews
+	pdfAvailable := false
+	if _, err := exec.LookPath("pdftoppm"); err == nil {
+		pdfAvailable = true
+	}
+	officeAvailable := false
+	for _, name := range []string{"soffice", "libreoffice"} {
+		if _, err := exec.LookPath(name); err == nil {
+			officeAvailable = true
+			break
+		}
+	}
+
 	videoConcurrency := 1
 	videoMaxDuration := 120  // 2 min on small VPS
 	videoMaxInputMB := 100
@@ -207,6 +227,9 @@ func GetLimits() *Limits {
 		Runtime: Runtime{
 			ExecTimeout: 5000,            // 5s
 			MaxMemory:   50 * 1024 * 1024, // 50MB per execution
+			MaxCPU:      3000,             // 3s of JS, excluding fetch waits
+			WasmMemory:  64 * 1024 * 1024, // 64MB of linear memory per module
+			WasmTimeout: 2000,             // 2s per call into a module
 		},
 		Capacity: Capacity{
 			Users:       baseUsers * scaleFactor,
@@ -218,10 +241,12 @@ func GetLimits() *Limits {
 			Timeout:     5000,
 		},
 		Media: Media{
-			Concurrency:    mediaConcurrency,
-			MaxSourceBytes: 20 * 1024 * 1024, // 20MB — large phone photos are ~12MB
-			WidthStep:      50,
-			CacheMemoryMB:  mediaCacheMB,
+			Concurrency:     mediaConcurrency,
+			MaxSourceBytes:  20 * 1024 * 1024, // 20MB — large phone photos are ~12MB
+			WidthStep:       50,
+			CacheMemoryMB:   mediaCacheMB,
+			PDFAvailable:    pdfAvailable,
+			OfficeAvailable: officeAvailable,
 		},
 		Video: Video{
 			FFmpegAvailable: ffmpegAvailable,
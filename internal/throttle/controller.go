@@ -0,0 +1,136 @@
+package throttle
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/notifier"
+	"github.com/fazt-sh/fazt/internal/usage"
+)
+
+// tickInterval is how often usage is checked against budgets
+const tickInterval = time.Minute
+
+// Controller moves apps in and out of degraded mode as they cross their
+// budgets. Budgets are daily, so apps recover at the start of the next UTC
+// day unless an override holds them.
+type Controller struct {
+	db     *sql.DB
+	notify func(title, message string)
+	done   chan struct{}
+	wg     sync.WaitGroup
+
+	// OnChange is called when an app enters or leaves degraded mode, so
+	// the caller can apply limits that live in other packages
+	OnChange func(app string, degraded bool)
+}
+
+// NewController creates a throttle controller
+func NewController(db *sql.DB) *Controller {
+	return &Controller{
+		db: db,
+		notify: func(title, message string) {
+			if err := notifier.Send(title, message, notifier.NotificationError); err != nil {
+				log.Printf("Throttle: failed to send notification: %v", err)
+			}
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// Start restores degraded apps from the database and begins the background
+// loop
+func (c *Controller) Start() {
+	c.Tick(time.Now())
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(tickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Tick(time.Now())
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the loop
+func (c *Controller) Stop() {
+	close(c.done)
+	c.wg.Wait()
+}
+
+// Tick compares today's usage with each budget, records and announces
+// transitions, and brings the in-memory degraded set in line
+func (c *Controller) Tick(now time.Time) {
+	if err := usage.Flush(c.db); err != nil {
+		log.Printf("Throttle: failed to flush usage: %v", err)
+	}
+
+	budgets, err := List(c.db)
+	if err != nil {
+		log.Printf("Throttle: failed to list budgets: %v", err)
+		return
+	}
+	today, err := usageToday(c.db, now)
+	if err != nil {
+		log.Printf("Throttle: failed to read usage: %v", err)
+		return
+	}
+
+	seen := make(map[string]bool, len(budgets))
+	for _, b := range budgets {
+		seen[b.App] = true
+
+		var reason string
+		switch b.Override {
+		case OverrideOn:
+			reason = "manual override"
+		case OverrideOff:
+		default:
+			reason = b.exceeded(today[b.App])
+		}
+		want := reason != ""
+
+		switch {
+		case want && b.DegradedAt == 0:
+			if _, err := c.db.Exec(`UPDATE app_budgets SET degraded_at = ?, reason = ? WHERE app = ?`,
+				now.Unix(), reason, b.App); err != nil {
+				log.Printf("Throttle: failed to update %s: %v", b.App, err)
+				continue
+			}
+			c.notify("App throttled", fmt.Sprintf("%s is in degraded mode: %s", b.App, reason))
+		case !want && b.DegradedAt != 0:
+			if _, err := c.db.Exec(`UPDATE app_budgets SET degraded_at = NULL, reason = NULL WHERE app = ?`,
+				b.App); err != nil {
+				log.Printf("Throttle: failed to update %s: %v", b.App, err)
+				continue
+			}
+			c.notify("App restored", fmt.Sprintf("%s is no longer in degraded mode", b.App))
+		}
+		c.apply(b.App, want)
+	}
+
+	// Budgets deleted while the app was degraded
+	for _, app := range degradedApps() {
+		if !seen[app] {
+			c.apply(app, false)
+			c.notify("App restored", fmt.Sprintf("%s is no longer in degraded mode", app))
+		}
+	}
+}
+
+func (c *Controller) apply(app string, on bool) {
+	if setDegraded(app, on) && c.OnChange != nil {
+		c.OnChange(app, on)
+	}
+}
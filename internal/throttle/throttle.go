@@ -0,0 +1,270 @@
+// Package throttle puts apps that exceed their daily resource budgets into
+// degraded mode: fewer concurrent jobs and executions, a strict request rate
+// limit and cached-only outbound fetches. A noisy app slows itself down
+// instead of taking the whole server with it.
+package throttle
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/usage"
+	"golang.org/x/time/rate"
+)
+
+// Overrides
+const (
+	OverrideAuto = "auto" // Follow budgets
+	OverrideOn   = "on"   // Always degraded
+	OverrideOff  = "off"  // Never degraded
+)
+
+// Degraded mode limits
+const (
+	DegradedRate       = 5  // Requests per second per app
+	DegradedBurst      = 10 // Request burst per app
+	DegradedWorkers    = 1  // Concurrent worker jobs per app
+	DegradedExecutions = 2  // Concurrent serverless executions per app
+)
+
+var (
+	ErrBudgetNotFound  = errors.New("budget not found")
+	ErrInvalidBudget   = errors.New("invalid budget")
+	ErrInvalidOverride = errors.New("override must be on, off or auto")
+)
+
+// Budget is an app's daily resource budget. Zero limits are unlimited.
+type Budget struct {
+	App          string `json:"app"`
+	CPUMs        int64  `json:"cpu_ms,omitempty"`
+	EgressBytes  int64  `json:"egress_bytes,omitempty"`
+	StorageBytes int64  `json:"storage_bytes,omitempty"`
+	Override     string `json:"override"`
+	DegradedAt   int64  `json:"degraded_at,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+// Status is a budget with the app's usage so far today
+type Status struct {
+	Budget
+	Today    usage.Cost `json:"today"`
+	Degraded bool       `json:"degraded"`
+}
+
+// SetBudget creates or replaces an app's limits, keeping its override
+func SetBudget(db *sql.DB, app string, cpuMs, egressBytes, storageBytes int64) (*Budget, error) {
+	if app == "" {
+		return nil, fmt.Errorf("%w: app is required", ErrInvalidBudget)
+	}
+	if cpuMs < 0 || egressBytes < 0 || storageBytes < 0 {
+		return nil, fmt.Errorf("%w: limits must not be negative", ErrInvalidBudget)
+	}
+	if cpuMs == 0 && egressBytes == 0 && storageBytes == 0 {
+		return nil, fmt.Errorf("%w: set at least one limit", ErrInvalidBudget)
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO app_budgets (app, cpu_ms, egress_bytes, storage_bytes, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(app) DO UPDATE SET cpu_ms = excluded.cpu_ms, egress_bytes = excluded.egress_bytes,
+			storage_bytes = excluded.storage_bytes, updated_at = excluded.updated_at
+	`, app, nullLimit(cpuMs), nullLimit(egressBytes), nullLimit(storageBytes), time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	return Get(db, app)
+}
+
+// SetOverride forces an app into or out of degraded mode, or hands it back
+// to its budgets. The controller applies it on its next tick.
+func SetOverride(db *sql.DB, app, mode string) (*Budget, error) {
+	var override interface{}
+	switch mode {
+	case OverrideOn, OverrideOff:
+		override = mode
+	case OverrideAuto:
+	default:
+		return nil, ErrInvalidOverride
+	}
+	if app == "" {
+		return nil, fmt.Errorf("%w: app is required", ErrInvalidBudget)
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO app_budgets (app, override, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(app) DO UPDATE SET override = excluded.override, updated_at = excluded.updated_at
+	`, app, override, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	return Get(db, app)
+}
+
+// Delete removes an app's budget and override. A degraded app is restored
+// on the controller's next tick.
+func Delete(db *sql.DB, app string) error {
+	result, err := db.Exec(`DELETE FROM app_budgets WHERE app = ?`, app)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
+
+const budgetColumns = `app, COALESCE(cpu_ms, 0), COALESCE(egress_bytes, 0), COALESCE(storage_bytes, 0),
+	COALESCE(override, 'auto'), COALESCE(degraded_at, 0), COALESCE(reason, ''), updated_at`
+
+func scanBudget(s interface{ Scan(...interface{}) error }) (*Budget, error) {
+	var b Budget
+	err := s.Scan(&b.App, &b.CPUMs, &b.EgressBytes, &b.StorageBytes, &b.Override, &b.DegradedAt, &b.Reason, &b.UpdatedAt)
+	return &b, err
+}
+
+// Get returns an app's budget
+func Get(db *sql.DB, app string) (*Budget, error) {
+	b, err := scanBudget(db.QueryRow(`SELECT `+budgetColumns+` FROM app_budgets WHERE app = ?`, app))
+	if err == sql.ErrNoRows {
+		return nil, ErrBudgetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// List returns all budgets
+func List(db *sql.DB) ([]Budget, error) {
+	rows, err := db.Query(`SELECT ` + budgetColumns + ` FROM app_budgets ORDER BY app`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []Budget{}
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, *b)
+	}
+	return budgets, rows.Err()
+}
+
+// Statuses returns every budget with the app's usage since the start of
+// the UTC day containing now
+func Statuses(db *sql.DB, now time.Time) ([]Status, error) {
+	budgets, err := List(db)
+	if err != nil {
+		return nil, err
+	}
+	today, err := usageToday(db, now)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, 0, len(budgets))
+	for _, b := range budgets {
+		out = append(out, Status{Budget: b, Today: today[b.App], Degraded: b.DegradedAt != 0})
+	}
+	return out, nil
+}
+
+func usageToday(db *sql.DB, now time.Time) (map[string]usage.Cost, error) {
+	apps, err := usage.Summary(db, now)
+	if err != nil {
+		return nil, err
+	}
+	today := make(map[string]usage.Cost, len(apps))
+	for _, a := range apps {
+		today[a.App] = a.Cost
+	}
+	return today, nil
+}
+
+// exceeded returns why cost is over the budget, or "" when it isn't
+func (b *Budget) exceeded(cost usage.Cost) string {
+	switch {
+	case b.CPUMs > 0 && cost.CPUMs > b.CPUMs:
+		return fmt.Sprintf("CPU %dms over the daily budget of %dms", cost.CPUMs, b.CPUMs)
+	case b.EgressBytes > 0 && cost.EgressBytes > b.EgressBytes:
+		return fmt.Sprintf("egress %d bytes over the daily budget of %d bytes", cost.EgressBytes, b.EgressBytes)
+	case b.StorageBytes > 0 && cost.StorageBytes > b.StorageBytes:
+		return fmt.Sprintf("storage writes %d bytes over the daily budget of %d bytes", cost.StorageBytes, b.StorageBytes)
+	}
+	return ""
+}
+
+func nullLimit(n int64) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// degraded holds a request limiter for each app in degraded mode. It is
+// read on every request, so lookups don't touch the database.
+var degraded = struct {
+	sync.RWMutex
+	apps map[string]*rate.Limiter
+}{apps: make(map[string]*rate.Limiter)}
+
+// IsDegraded reports whether an app is in degraded mode
+func IsDegraded(app string) bool {
+	degraded.RLock()
+	defer degraded.RUnlock()
+	_, ok := degraded.apps[app]
+	return ok
+}
+
+// Allow takes a request token for an app. It always allows apps that aren't
+// degraded; otherwise it returns false and how long until the next token.
+func Allow(app string) (bool, time.Duration) {
+	degraded.RLock()
+	limiter, ok := degraded.apps[app]
+	degraded.RUnlock()
+	if !ok {
+		return true, 0
+	}
+
+	r := limiter.Reserve()
+	if d := r.Delay(); d > 0 {
+		r.Cancel()
+		return false, d
+	}
+	return true, 0
+}
+
+// setDegraded adds or removes an app from degraded mode, returning whether
+// anything changed
+func setDegraded(app string, on bool) bool {
+	degraded.Lock()
+	defer degraded.Unlock()
+	_, was := degraded.apps[app]
+	if on == was {
+		return false
+	}
+	if on {
+		degraded.apps[app] = rate.NewLimiter(DegradedRate, DegradedBurst)
+	} else {
+		delete(degraded.apps, app)
+	}
+	return true
+}
+
+// degradedApps returns the apps currently in degraded mode
+func degradedApps() []string {
+	degraded.RLock()
+	defer degraded.RUnlock()
+	apps := make([]string, 0, len(degraded.apps))
+	for app := range degraded.apps {
+		apps = append(apps, app)
+	}
+	return apps
+}
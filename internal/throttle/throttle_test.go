@@ -0,0 +1,129 @@
+package throttle
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	t.Cleanup(func() {
+		for _, app := range degradedApps() {
+			setDegraded(app, false)
+		}
+	})
+	return db
+}
+
+func TestSetBudgetValidation(t *testing.T) {
+	db := setupTestDB(t)
+
+	if _, err := SetBudget(db, "app_blog", 0, 0, 0); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("Expected an empty budget to fail, got %v", err)
+	}
+	if _, err := SetBudget(db, "app_blog", -1, 0, 0); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("Expected a negative limit to fail, got %v", err)
+	}
+	if _, err := SetOverride(db, "app_blog", "maybe"); err != ErrInvalidOverride {
+		t.Errorf("Expected ErrInvalidOverride, got %v", err)
+	}
+	if err := Delete(db, "app_blog"); err != ErrBudgetNotFound {
+		t.Errorf("Expected ErrBudgetNotFound, got %v", err)
+	}
+
+	SetOverride(db, "app_blog", OverrideOff)
+	b, err := SetBudget(db, "app_blog", 1000, 0, 2048)
+	if err != nil {
+		t.Fatalf("SetBudget failed: %v", err)
+	}
+	if b.CPUMs != 1000 || b.EgressBytes != 0 || b.StorageBytes != 2048 || b.Override != OverrideOff {
+		t.Errorf("Expected limits set and override kept, got %+v", b)
+	}
+}
+
+func TestControllerTick(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	var notes []string
+	changes := map[string]bool{}
+	c := NewController(db)
+	c.notify = func(title, message string) { notes = append(notes, title+": "+message) }
+	c.OnChange = func(app string, degraded bool) { changes[app] = degraded }
+
+	SetBudget(db, "app_blog", 1000, 0, 0)
+	SetBudget(db, "docs", 0, 1<<20, 0)
+	db.Exec(`INSERT INTO app_usage (app, day, requests, cpu_ms) VALUES ('app_blog', '2026-10-16', 10, 1500),
+		('app_blog', '2026-10-15', 10, 9999), ('docs', '2026-10-16', 10, 9999)`)
+
+	c.Tick(now)
+	if !IsDegraded("app_blog") || IsDegraded("docs") {
+		t.Fatalf("Expected only app_blog degraded")
+	}
+	if len(notes) != 1 || !strings.Contains(notes[0], "App throttled: app_blog") || !strings.Contains(notes[0], "CPU") {
+		t.Errorf("Expected a throttle notification, got %v", notes)
+	}
+	if degraded, ok := changes["app_blog"]; !ok || !degraded {
+		t.Errorf("Expected OnChange for app_blog, got %v", changes)
+	}
+
+	// Strict rate limit while degraded; other apps unaffected
+	for i := 0; i < DegradedBurst; i++ {
+		if ok, _ := Allow("app_blog"); !ok {
+			t.Fatalf("Request %d: expected burst to be allowed", i)
+		}
+	}
+	if ok, wait := Allow("app_blog"); ok || wait <= 0 {
+		t.Errorf("Expected request to be refused with a wait, got %v %v", ok, wait)
+	}
+	if ok, _ := Allow("docs"); !ok {
+		t.Error("Expected an app that isn't degraded to be allowed")
+	}
+
+	// State survives a restart: a fresh controller restores without notifying
+	setDegraded("app_blog", false)
+	notes = nil
+	c.Tick(now)
+	if !IsDegraded("app_blog") || len(notes) != 0 {
+		t.Errorf("Expected state restored silently, got degraded=%v notes=%v", IsDegraded("app_blog"), notes)
+	}
+
+	statuses, _ := Statuses(db, now)
+	if len(statuses) != 2 || !statuses[0].Degraded || statuses[0].Today.CPUMs != 1500 || statuses[0].Reason == "" {
+		t.Errorf("Unexpected statuses %+v", statuses)
+	}
+
+	// Manual overrides win over budgets
+	SetOverride(db, "app_blog", OverrideOff)
+	SetOverride(db, "docs", OverrideOn)
+	c.Tick(now)
+	if IsDegraded("app_blog") || !IsDegraded("docs") {
+		t.Errorf("Expected overrides applied")
+	}
+
+	// The next UTC day starts with a clean slate
+	SetOverride(db, "app_blog", OverrideAuto)
+	c.Tick(now.Add(24 * time.Hour))
+	if IsDegraded("app_blog") {
+		t.Error("Expected app_blog to recover the next day")
+	}
+
+	// Removing a budget restores the app
+	notes = nil
+	Delete(db, "docs")
+	c.Tick(now.Add(24 * time.Hour))
+	if IsDegraded("docs") || changes["docs"] {
+		t.Error("Expected docs restored after its budget was removed")
+	}
+	if len(notes) != 1 || !strings.HasPrefix(notes[0], "App restored: docs") {
+		t.Errorf("Expected a restore notification, got %v", notes)
+	}
+}
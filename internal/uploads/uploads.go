@@ -0,0 +1,384 @@
+// Package uploads stages resumable uploads. Clients send large deploy
+// bundles and blobs in chunks (see the tus handlers); the bytes received so
+// far are kept in a file per upload next to the database, so an upload
+// survives dropped connections and server restarts until it expires.
+package uploads
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+// Upload kinds
+const (
+	KindDeploy   = "deploy"    // A site's ZIP bundle
+	KindBlob     = "blob"      // An app blob, uploaded by an admin or app editor
+	KindUserBlob = "user_blob" // A signed-in user's own blob on an app
+)
+
+// Upload states
+const (
+	StateUploading = "uploading"
+	StateFinishing = "finishing" // Every byte received, being deployed or stored
+	StateDone      = "done"
+	StateFailed    = "failed"
+)
+
+// Defaults for server.uploads.*
+const (
+	DefaultMaxMB       = 512
+	DefaultExpireHours = 24
+)
+
+// MaxPending is how many unfinished uploads one caller may have open
+const MaxPending = 10
+
+// cleanupInterval is how often expired uploads are removed
+const cleanupInterval = 15 * time.Minute
+
+var (
+	ErrNotFound            = errors.New("upload not found")
+	ErrExpired             = errors.New("upload expired")
+	ErrTooLarge            = errors.New("upload exceeds the maximum size")
+	ErrInsufficientStorage = errors.New("not enough disk space for this upload")
+	ErrTooManyPending      = errors.New("too many unfinished uploads")
+	ErrOffsetMismatch      = errors.New("upload offset does not match")
+	ErrLocked              = errors.New("upload is being written by another request")
+	ErrNotUploading        = errors.New("upload is already complete")
+)
+
+// Upload is a resumable upload and how far it has got
+type Upload struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Owner     string            `json:"-"`
+	AppID     string            `json:"app_id,omitempty"`
+	Target    string            `json:"target"` // Site name for deploys, blob path otherwise
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Size      int64             `json:"size"`
+	Received  int64             `json:"received"`
+	State     string            `json:"state"`
+	Result    json.RawMessage   `json:"result,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	ExpiresAt int64             `json:"expires_at"`
+}
+
+// Complete reports whether every byte has been received
+func (u *Upload) Complete() bool {
+	return u.Received == u.Size
+}
+
+// Store keeps upload state in the database and the bytes in dir
+type Store struct {
+	db     *sql.DB
+	dir    string
+	maxMB  int
+	expire time.Duration
+	usage  func(path string) (system.Disk, error)
+
+	mu      sync.Mutex
+	writing map[string]bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewStore creates a store staging uploads in dir. maxMB caps each upload;
+// uploads not finished within expireHours are removed.
+func NewStore(db *sql.DB, dir string, maxMB, expireHours int) (*Store, error) {
+	if maxMB <= 0 {
+		maxMB = DefaultMaxMB
+	}
+	if expireHours <= 0 {
+		expireHours = DefaultExpireHours
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	return &Store{
+		db:      db,
+		dir:     dir,
+		maxMB:   maxMB,
+		expire:  time.Duration(expireHours) * time.Hour,
+		usage:   system.DiskUsage,
+		writing: map[string]bool{},
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// MaxSize returns the largest upload accepted, in bytes
+func (s *Store) MaxSize() int64 {
+	return int64(s.maxMB) << 20
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+// Create registers an upload of size bytes after checking it fits: within
+// the size limit, within the owner's unfinished uploads, and in the free
+// disk space left once other unfinished uploads arrive. The space counted
+// is twice the size, for the staged file and the stored copy.
+func (s *Store) Create(u *Upload) error {
+	if u.Size <= 0 {
+		return fmt.Errorf("upload length must be positive")
+	}
+	if u.Size > s.MaxSize() {
+		return ErrTooLarge
+	}
+
+	var pending, outstanding int64
+	err := s.db.QueryRow(`
+		SELECT COALESCE(SUM(owner = ?), 0), COALESCE(SUM(size - received), 0)
+		FROM uploads WHERE state = ? AND expires_at > ?
+	`, u.Owner, StateUploading, time.Now().Unix()).Scan(&pending, &outstanding)
+	if err != nil {
+		return err
+	}
+	if pending >= MaxPending {
+		return ErrTooManyPending
+	}
+	if disk, err := s.usage(s.dir); err == nil && int64(disk.Free) < 2*(u.Size+outstanding) {
+		return ErrInsufficientStorage
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	now := time.Now()
+	u.ID = hex.EncodeToString(id)
+	u.State = StateUploading
+	u.Received = 0
+	u.CreatedAt = now.Unix()
+	u.ExpiresAt = now.Add(s.expire).Unix()
+
+	f, err := os.OpenFile(s.path(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to stage upload: %w", err)
+	}
+	f.Close()
+
+	meta, _ := json.Marshal(u.Metadata)
+	_, err = s.db.Exec(`
+		INSERT INTO uploads (id, kind, owner, app_id, target, metadata, size, received, state, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?)
+	`, u.ID, u.Kind, u.Owner, u.AppID, u.Target, string(meta), u.Size, u.State, u.CreatedAt, u.CreatedAt, u.ExpiresAt)
+	if err != nil {
+		os.Remove(s.path(u.ID))
+		return err
+	}
+	return nil
+}
+
+// Get returns an upload if owner started it. Expired uploads that haven't
+// been cleaned up yet return ErrExpired.
+func (s *Store) Get(id, owner string) (*Upload, error) {
+	u := &Upload{}
+	var meta string
+	var result sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, kind, owner, app_id, target, metadata, size, received, state, result, created_at, expires_at
+		FROM uploads WHERE id = ?
+	`, id).Scan(&u.ID, &u.Kind, &u.Owner, &u.AppID, &u.Target, &meta, &u.Size, &u.Received, &u.State, &result, &u.CreatedAt, &u.ExpiresAt)
+	if err == sql.ErrNoRows || (err == nil && u.Owner != owner) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(meta), &u.Metadata)
+	if result.Valid {
+		u.Result = json.RawMessage(result.String)
+	}
+	if time.Now().Unix() >= u.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return u, nil
+}
+
+// Append writes a chunk starting at offset, which must be where the upload
+// got to. Whatever arrives before r fails is kept, so a client that loses
+// its connection resumes from the last byte received. It returns the new
+// offset.
+func (s *Store) Append(u *Upload, offset int64, r io.Reader) (int64, error) {
+	s.mu.Lock()
+	if s.writing[u.ID] {
+		s.mu.Unlock()
+		return u.Received, ErrLocked
+	}
+	s.writing[u.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.writing, u.ID)
+		s.mu.Unlock()
+	}()
+
+	// Re-read under the lock: another request may have moved it on
+	if err := s.db.QueryRow(`SELECT received, state FROM uploads WHERE id = ?`, u.ID).Scan(&u.Received, &u.State); err != nil {
+		return u.Received, err
+	}
+	if u.State != StateUploading {
+		return u.Received, ErrNotUploading
+	}
+	if offset != u.Received {
+		return u.Received, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.path(u.ID), os.O_WRONLY, 0600)
+	if err != nil {
+		return u.Received, fmt.Errorf("failed to open staged upload: %w", err)
+	}
+	defer f.Close()
+
+	// Bytes past the recorded offset were written by a request that died
+	// before recording them; they are sent again
+	if err := f.Truncate(offset); err != nil {
+		return u.Received, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return u.Received, err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(r, u.Size-offset))
+	if n > 0 {
+		if err := f.Sync(); err != nil {
+			return u.Received, err
+		}
+		if _, err := s.db.Exec(`UPDATE uploads SET received = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, offset+n, u.ID); err != nil {
+			return u.Received, err
+		}
+		u.Received = offset + n
+	}
+	return u.Received, copyErr
+}
+
+// Claim marks a complete upload as being finished. Only one request gets
+// to finish an upload; Claim reports false to the others.
+func (s *Store) Claim(u *Upload) (bool, error) {
+	if !u.Complete() {
+		return false, nil
+	}
+	res, err := s.db.Exec(`UPDATE uploads SET state = ?, updated_at = strftime('%s', 'now') WHERE id = ? AND state = ?`,
+		StateFinishing, u.ID, StateUploading)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	if n == 1 {
+		u.State = StateFinishing
+	}
+	return n == 1, nil
+}
+
+// Open returns the bytes of a complete upload
+func (s *Store) Open(u *Upload) (*os.File, error) {
+	if !u.Complete() {
+		return nil, fmt.Errorf("upload is incomplete: %d of %d bytes", u.Received, u.Size)
+	}
+	return os.Open(s.path(u.ID))
+}
+
+// Finish records the outcome of a complete upload and removes its bytes.
+// The record is kept until the upload expires, so a client that missed the
+// response can still read the result.
+func (s *Store) Finish(u *Upload, state string, result interface{}) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.path(u.ID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Uploads: failed to remove %s: %v", u.ID, err)
+	}
+	u.State = state
+	u.Result = b
+	_, err = s.db.Exec(`UPDATE uploads SET state = ?, result = ?, updated_at = strftime('%s', 'now') WHERE id = ?`, state, string(b), u.ID)
+	return err
+}
+
+// Delete removes an upload and its bytes
+func (s *Store) Delete(u *Upload) error {
+	if err := os.Remove(s.path(u.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM uploads WHERE id = ?`, u.ID)
+	return err
+}
+
+// Cleanup removes expired uploads and staged files without a record,
+// returning how many uploads were removed
+func (s *Store) Cleanup(now time.Time) (int, error) {
+	rows, err := s.db.Query(`SELECT id FROM uploads WHERE expires_at <= ?`, now.Unix())
+	if err != nil {
+		return 0, err
+	}
+	var expired []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, id)
+	}
+	rows.Close()
+
+	for _, id := range expired {
+		os.Remove(s.path(id))
+		if _, err := s.db.Exec(`DELETE FROM uploads WHERE id = ?`, id); err != nil {
+			return 0, err
+		}
+	}
+
+	// Files left behind by a crash between staging and recording
+	entries, _ := os.ReadDir(s.dir)
+	for _, e := range entries {
+		id := e.Name()[:len(e.Name())-len(filepath.Ext(e.Name()))]
+		var n int
+		s.db.QueryRow(`SELECT COUNT(*) FROM uploads WHERE id = ?`, id).Scan(&n)
+		if info, err := e.Info(); n == 0 && err == nil && now.Sub(info.ModTime()) > time.Hour {
+			os.Remove(filepath.Join(s.dir, e.Name()))
+		}
+	}
+	return len(expired), nil
+}
+
+// Start removes expired uploads in the background until Stop is called
+func (s *Store) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.Cleanup(time.Now()); err != nil {
+				log.Printf("Uploads: cleanup failed: %v", err)
+			} else if n > 0 {
+				log.Printf("Uploads: removed %d expired uploads", n)
+			}
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends the background cleanup
+func (s *Store) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
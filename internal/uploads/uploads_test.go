@@ -0,0 +1,171 @@
+package uploads
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/system"
+)
+
+func setupStore(t *testing.T) *Store {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	s, err := NewStore(db, t.TempDir(), 1, 24)
+	if err != nil {
+		t.Fatalf("NewStore() failed: %v", err)
+	}
+	s.usage = func(string) (system.Disk, error) {
+		return system.Disk{Total: 1 << 30, Free: 1 << 30}, nil
+	}
+	return s
+}
+
+// failingReader returns some bytes, then fails like a dropped connection
+type failingReader struct {
+	data string
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	s := setupStore(t)
+
+	u := &Upload{Kind: KindBlob, Owner: "user_1", AppID: "blog", Target: "uploads/a.txt", Size: 11}
+	if err := s.Create(u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// A chunk cut off mid-way keeps what arrived
+	n, err := s.Append(u, 0, &failingReader{data: "hello"})
+	if err == nil || n != 5 {
+		t.Fatalf("Expected an interrupted chunk at offset 5, got %d (%v)", n, err)
+	}
+
+	got, err := s.Get(u.ID, "user_1")
+	if err != nil || got.Received != 5 {
+		t.Fatalf("Expected 5 bytes received, got %+v (%v)", got, err)
+	}
+	if _, err := s.Get(u.ID, "user_2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected another owner to get ErrNotFound, got %v", err)
+	}
+
+	if _, err := s.Append(got, 0, strings.NewReader("hello world")); !errors.Is(err, ErrOffsetMismatch) {
+		t.Errorf("Expected a stale offset to fail, got %v", err)
+	}
+
+	// Bytes past the declared length are ignored
+	if n, err := s.Append(got, 5, strings.NewReader(" world!!!")); err != nil || n != 11 {
+		t.Fatalf("Expected the upload complete at 11, got %d (%v)", n, err)
+	}
+	if !got.Complete() {
+		t.Error("Expected the upload to be complete")
+	}
+
+	claimed, err := s.Claim(got)
+	if err != nil || !claimed {
+		t.Fatalf("Expected to claim the upload, got %v (%v)", claimed, err)
+	}
+	if again, _ := s.Claim(got); again {
+		t.Error("Expected a second claim to be refused")
+	}
+	if _, err := s.Append(got, 11, strings.NewReader("x")); !errors.Is(err, ErrNotUploading) {
+		t.Errorf("Expected appends to a finishing upload to fail, got %v", err)
+	}
+
+	f, err := s.Open(got)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "hello world" {
+		t.Errorf("Expected 'hello world', got %q", data)
+	}
+
+	if err := s.Finish(got, StateDone, map[string]interface{}{"path": "uploads/a.txt"}); err != nil {
+		t.Fatalf("Finish() failed: %v", err)
+	}
+	done, err := s.Get(u.ID, "user_1")
+	if err != nil || done.State != StateDone || !strings.Contains(string(done.Result), "uploads/a.txt") {
+		t.Errorf("Expected the result kept, got %+v (%v)", done, err)
+	}
+	if _, err := os.Stat(s.path(u.ID)); !os.IsNotExist(err) {
+		t.Error("Expected the staged file removed once finished")
+	}
+}
+
+func TestCreateLimits(t *testing.T) {
+	s := setupStore(t)
+
+	if err := s.Create(&Upload{Owner: "user_1", Size: 2 << 20}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected an upload over the limit to fail, got %v", err)
+	}
+
+	for i := 0; i < MaxPending; i++ {
+		if err := s.Create(&Upload{Kind: KindDeploy, Owner: "user_1", Size: 100}); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+	}
+	if err := s.Create(&Upload{Kind: KindDeploy, Owner: "user_1", Size: 100}); !errors.Is(err, ErrTooManyPending) {
+		t.Errorf("Expected too many pending uploads to fail, got %v", err)
+	}
+	if err := s.Create(&Upload{Kind: KindDeploy, Owner: "user_2", Size: 100}); err != nil {
+		t.Errorf("Expected another owner unaffected, got %v", err)
+	}
+
+	// Space already promised to unfinished uploads counts against new ones
+	s.usage = func(string) (system.Disk, error) {
+		return system.Disk{Total: 1 << 30, Free: 2 * (11*100 + 100)}, nil
+	}
+	if err := s.Create(&Upload{Kind: KindDeploy, Owner: "user_3", Size: 100}); err != nil {
+		t.Errorf("Expected an upload that fits to be created, got %v", err)
+	}
+	if err := s.Create(&Upload{Kind: KindDeploy, Owner: "user_3", Size: 100}); !errors.Is(err, ErrInsufficientStorage) {
+		t.Errorf("Expected an upload that doesn't fit to fail, got %v", err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	s := setupStore(t)
+
+	u := &Upload{Kind: KindDeploy, Owner: "user_1", Size: 100}
+	if err := s.Create(u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	orphan := filepath.Join(s.dir, "orphan.part")
+	os.WriteFile(orphan, []byte("x"), 0600)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(orphan, old, old)
+
+	if n, err := s.Cleanup(time.Now()); err != nil || n != 0 {
+		t.Errorf("Expected nothing expired yet, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected the orphaned file removed")
+	}
+
+	if n, err := s.Cleanup(time.Now().Add(25 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("Expected one expired upload removed, got %d (%v)", n, err)
+	}
+	if _, err := os.Stat(s.path(u.ID)); !os.IsNotExist(err) {
+		t.Error("Expected the expired upload's file removed")
+	}
+	if _, err := s.Get(u.ID, "user_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the expired upload gone, got %v", err)
+	}
+}
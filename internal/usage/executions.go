@@ -0,0 +1,186 @@
+package usage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Execution is the resource usage of one serverless handler run
+type Execution struct {
+	CPU        time.Duration // JS time, excluding fetch waits
+	AllocBytes int64         // Bytes handed to the VM
+	Killed     bool          // Interrupted by the timeout or a sandbox limit
+}
+
+// ExecutionCost sums the executions of one handler
+type ExecutionCost struct {
+	Executions    int64 `json:"executions"`
+	CPUMs         int64 `json:"cpu_ms"`
+	MaxCPUMs      int64 `json:"max_cpu_ms"`
+	AllocBytes    int64 `json:"alloc_bytes"`
+	MaxAllocBytes int64 `json:"max_alloc_bytes"`
+	Killed        int64 `json:"killed"`
+}
+
+func (c *ExecutionCost) add(o ExecutionCost) {
+	c.Executions += o.Executions
+	c.CPUMs += o.CPUMs
+	c.MaxCPUMs = max(c.MaxCPUMs, o.MaxCPUMs)
+	c.AllocBytes += o.AllocBytes
+	c.MaxAllocBytes = max(c.MaxAllocBytes, o.MaxAllocBytes)
+	c.Killed += o.Killed
+}
+
+type entryKey struct {
+	app   string
+	entry string
+	day   string
+}
+
+// pendingExecutions holds executions recorded since the last Flush
+var pendingExecutions = struct {
+	sync.Mutex
+	costs map[entryKey]*ExecutionCost
+}{costs: make(map[entryKey]*ExecutionCost)}
+
+// RecordExecution adds a finished execution of an app's entry (api/main.js
+// or a route pattern) to its daily runtime usage
+func RecordExecution(app, entry string, e Execution) {
+	recordExecutionAt(app, entry, e, time.Now())
+}
+
+func recordExecutionAt(app, entry string, e Execution, now time.Time) {
+	c := ExecutionCost{
+		Executions:    1,
+		CPUMs:         e.CPU.Milliseconds(),
+		MaxCPUMs:      e.CPU.Milliseconds(),
+		AllocBytes:    e.AllocBytes,
+		MaxAllocBytes: e.AllocBytes,
+	}
+	if e.Killed {
+		c.Killed = 1
+	}
+	key := entryKey{app: app, entry: entry, day: now.UTC().Format(dayFormat)}
+
+	pendingExecutions.Lock()
+	defer pendingExecutions.Unlock()
+
+	total := pendingExecutions.costs[key]
+	if total == nil {
+		total = &ExecutionCost{}
+		pendingExecutions.costs[key] = total
+	}
+	total.add(c)
+}
+
+// flushExecutions writes executions taken from pending to runtime_usage
+func flushExecutions(tx *sql.Tx, costs map[entryKey]*ExecutionCost) error {
+	for key, c := range costs {
+		if _, err := tx.Exec(`
+			INSERT INTO runtime_usage (app, entry, day, executions, cpu_ms, max_cpu_ms, alloc_bytes, max_alloc_bytes, killed)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(app, entry, day) DO UPDATE SET
+				executions = executions + excluded.executions,
+				cpu_ms = cpu_ms + excluded.cpu_ms,
+				max_cpu_ms = MAX(max_cpu_ms, excluded.max_cpu_ms),
+				alloc_bytes = alloc_bytes + excluded.alloc_bytes,
+				max_alloc_bytes = MAX(max_alloc_bytes, excluded.max_alloc_bytes),
+				killed = killed + excluded.killed
+		`, key.app, key.entry, key.day, c.Executions, c.CPUMs, c.MaxCPUMs, c.AllocBytes, c.MaxAllocBytes, c.Killed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// takeExecutions empties pendingExecutions, returning what it held
+func takeExecutions() map[entryKey]*ExecutionCost {
+	pendingExecutions.Lock()
+	defer pendingExecutions.Unlock()
+	costs := pendingExecutions.costs
+	pendingExecutions.costs = make(map[entryKey]*ExecutionCost)
+	return costs
+}
+
+// restoreExecutions merges unflushed executions back into pending
+func restoreExecutions(costs map[entryKey]*ExecutionCost) {
+	pendingExecutions.Lock()
+	defer pendingExecutions.Unlock()
+	for key, c := range costs {
+		if existing := pendingExecutions.costs[key]; existing != nil {
+			existing.add(*c)
+			continue
+		}
+		pendingExecutions.costs[key] = c
+	}
+}
+
+// EntryUsage is the runtime usage of one of an app's handlers over a period
+type EntryUsage struct {
+	App   string `json:"app"`
+	Name  string `json:"name,omitempty"` // App title
+	Entry string `json:"entry"`
+	ExecutionCost
+	AvgCPUMs int64 `json:"avg_cpu_ms"`
+}
+
+// ErrUnknownSort is returned by Top for a sort it doesn't know
+var ErrUnknownSort = errors.New("unknown sort (use cpu, max_cpu, alloc or killed)")
+
+// Top sort orders
+var topOrders = map[string]string{
+	"cpu":     "SUM(r.cpu_ms)",
+	"max_cpu": "MAX(r.max_cpu_ms)",
+	"alloc":   "SUM(r.alloc_bytes)",
+	"killed":  "SUM(r.killed)",
+}
+
+// Top returns the handlers that used the most since the given day, ordered
+// by sort (cpu, max_cpu, alloc or killed). app limits it to one app when
+// not empty.
+func Top(db *sql.DB, since time.Time, app, sort string, limit int) ([]EntryUsage, error) {
+	order, ok := topOrders[sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSort, sort)
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+		SELECT r.app, COALESCE(MAX(a.title), ''), r.entry,
+			SUM(r.executions), SUM(r.cpu_ms), MAX(r.max_cpu_ms),
+			SUM(r.alloc_bytes), MAX(r.max_alloc_bytes), SUM(r.killed)
+		FROM runtime_usage r LEFT JOIN apps a ON a.id = r.app
+		WHERE r.day >= ?`
+	args := []interface{}{since.UTC().Format(dayFormat)}
+	if app != "" {
+		query += ` AND r.app = ?`
+		args = append(args, app)
+	}
+	query += ` GROUP BY r.app, r.entry ORDER BY ` + order + ` DESC, r.app, r.entry LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []EntryUsage{}
+	for rows.Next() {
+		var u EntryUsage
+		if err := rows.Scan(&u.App, &u.Name, &u.Entry, &u.Executions, &u.CPUMs, &u.MaxCPUMs,
+			&u.AllocBytes, &u.MaxAllocBytes, &u.Killed); err != nil {
+			return nil, err
+		}
+		if u.Executions > 0 {
+			u.AvgCPUMs = u.CPUMs / u.Executions
+		}
+		entries = append(entries, u)
+	}
+	return entries, rows.Err()
+}
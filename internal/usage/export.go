@@ -0,0 +1,114 @@
+package usage
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidPeriod is returned for export ranges that are empty or reach
+// past the retention window
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// UserUsage is one app's cost over an export period, attributed to the
+// user billed for it: the app's earliest owner. UserID is empty for apps
+// without an owner, including sites deployed without an app ID.
+type UserUsage struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+	App    string `json:"app"`
+	Name   string `json:"name,omitempty"`
+	Cost
+}
+
+// Period is a range of whole UTC days, both ends inclusive
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// NewPeriod returns the period covering the days from and to fall on
+func NewPeriod(from, to time.Time) (Period, error) {
+	p := Period{From: truncateDay(from), To: truncateDay(to)}
+	if p.To.Before(p.From) || p.From.Before(truncateDay(time.Now().Add(-Retention))) {
+		return Period{}, ErrInvalidPeriod
+	}
+	return p, nil
+}
+
+// MonthPeriod returns the calendar month containing t
+func MonthPeriod(t time.Time) Period {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Period{From: start, To: start.AddDate(0, 1, -1)}
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Start returns the first day as YYYY-MM-DD
+func (p Period) Start() string { return p.From.Format(dayFormat) }
+
+// End returns the last day as YYYY-MM-DD
+func (p Period) End() string { return p.To.Format(dayFormat) }
+
+// Export returns each app's cost over a period with the user it is billed
+// to, grouped by user
+func Export(db *sql.DB, p Period) ([]UserUsage, error) {
+	rows, err := db.Query(`
+		SELECT x.owner, COALESCE(au.email, ''), x.app, x.name,
+			x.requests, x.cpu_ms, x.db_reads, x.db_writes, x.storage_bytes, x.egress_bytes, x.response_bytes
+		FROM (
+			SELECT u.app AS app, COALESCE(MAX(a.title), '') AS name,
+				COALESCE((SELECT m.user_id FROM app_members m WHERE m.app_id = u.app AND m.role = 'owner'
+					ORDER BY m.created_at, m.user_id LIMIT 1), '') AS owner,
+				SUM(u.requests) AS requests, SUM(u.cpu_ms) AS cpu_ms, SUM(u.db_reads) AS db_reads,
+				SUM(u.db_writes) AS db_writes, SUM(u.storage_bytes) AS storage_bytes,
+				SUM(u.egress_bytes) AS egress_bytes, SUM(u.response_bytes) AS response_bytes
+			FROM app_usage u LEFT JOIN apps a ON a.id = u.app
+			WHERE u.day >= ? AND u.day <= ?
+			GROUP BY u.app
+		) x LEFT JOIN auth_users au ON au.id = x.owner
+		ORDER BY x.owner = '', au.email, x.owner, x.app
+	`, p.Start(), p.End())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []UserUsage{}
+	for rows.Next() {
+		var u UserUsage
+		if err := rows.Scan(&u.UserID, &u.Email, &u.App, &u.Name, &u.Requests, &u.CPUMs, &u.DBReads,
+			&u.DBWrites, &u.StorageBytes, &u.EgressBytes, &u.ResponseBytes); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// WriteCSV writes an export as CSV with a header row
+func WriteCSV(w io.Writer, p Period, rows []UserUsage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"period_start", "period_end", "user_id", "email", "app", "name", "requests", "cpu_ms",
+		"db_reads", "db_writes", "storage_bytes", "egress_bytes", "response_bytes"})
+	for _, u := range rows {
+		cw.Write([]string{
+			p.Start(), p.End(), u.UserID, u.Email, u.App, u.Name,
+			strconv.FormatInt(u.Requests, 10),
+			strconv.FormatInt(u.CPUMs, 10),
+			strconv.FormatInt(u.DBReads, 10),
+			strconv.FormatInt(u.DBWrites, 10),
+			strconv.FormatInt(u.StorageBytes, 10),
+			strconv.FormatInt(u.EgressBytes, 10),
+			strconv.FormatInt(u.ResponseBytes, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,77 @@
+package usage
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	flushInterval = time.Minute      // How often pending costs are written
+	hookInterval  = 10 * time.Minute // How often due or failed hooks are attempted
+)
+
+// Flusher periodically writes metered costs to app_usage and runtime_usage
+// and delivers usage hooks
+type Flusher struct {
+	db       *sql.DB
+	client   *http.Client
+	lastHook time.Time
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewFlusher creates a usage flusher
+func NewFlusher(db *sql.DB) *Flusher {
+	return &Flusher{
+		db:     db,
+		client: &http.Client{Timeout: 30 * time.Second},
+		done:   make(chan struct{}),
+	}
+}
+
+// Start begins the background flush loop
+func (f *Flusher) Start() {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.Tick(time.Now())
+			case <-f.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the loop and flushes any remaining costs
+func (f *Flusher) Stop() {
+	close(f.done)
+	f.wg.Wait()
+	if err := Flush(f.db); err != nil {
+		log.Printf("Usage: failed to flush: %v", err)
+	}
+}
+
+// Tick flushes pending costs, prunes old days and delivers due hooks
+func (f *Flusher) Tick(now time.Time) {
+	if err := Flush(f.db); err != nil {
+		log.Printf("Usage: failed to flush: %v", err)
+	}
+	if err := Prune(f.db, now); err != nil {
+		log.Printf("Usage: failed to prune: %v", err)
+	}
+	if now.Sub(f.lastHook) >= hookInterval {
+		f.lastHook = now
+		if err := DeliverHooks(f.db, f.client, now); err != nil {
+			log.Printf("Usage: failed to deliver hooks: %v", err)
+		}
+	}
+}
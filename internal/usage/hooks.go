@@ -0,0 +1,198 @@
+package usage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Hook periods
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// HookEvent is the event name sent with each delivery
+const HookEvent = "usage.period"
+
+var (
+	ErrHookNotFound = errors.New("usage hook not found")
+	ErrInvalidHook  = errors.New("invalid usage hook")
+)
+
+// Hook posts each completed period's export to an external endpoint, such
+// as an invoicing system. Payloads are signed with the hook's secret.
+type Hook struct {
+	ID               int64  `json:"id"`
+	URL              string `json:"url"`
+	Secret           string `json:"secret,omitempty"` // Only returned when the hook is created
+	Period           string `json:"period"`
+	DeliveredThrough string `json:"delivered_through,omitempty"`
+	LastError        string `json:"last_error,omitempty"`
+	CreatedAt        int64  `json:"created_at"`
+}
+
+// HookPayload is the JSON body of a hook delivery
+type HookPayload struct {
+	Event  string      `json:"event"`
+	Period string      `json:"period"`
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Usage  []UserUsage `json:"usage"`
+}
+
+// CreateHook registers a hook. A secret is generated when none is given.
+func CreateHook(db *sql.DB, rawURL, period, secret string) (*Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be http(s)", ErrInvalidHook)
+	}
+	if period != PeriodDay && period != PeriodWeek && period != PeriodMonth {
+		return nil, fmt.Errorf("%w: period must be day, week or month", ErrInvalidHook)
+	}
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	now := time.Now().Unix()
+	result, err := db.Exec(`INSERT INTO usage_hooks (url, secret, period, created_at) VALUES (?, ?, ?, ?)`,
+		rawURL, secret, period, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+	return &Hook{ID: id, URL: rawURL, Secret: secret, Period: period, CreatedAt: now}, nil
+}
+
+// ListHooks returns all hooks without their secrets
+func ListHooks(db *sql.DB) ([]Hook, error) {
+	rows, err := db.Query(`
+		SELECT id, url, period, COALESCE(delivered_through, ''), COALESCE(last_error, ''), created_at
+		FROM usage_hooks ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Hook{}
+	for rows.Next() {
+		var h Hook
+		if err := rows.Scan(&h.ID, &h.URL, &h.Period, &h.DeliveredThrough, &h.LastError, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteHook removes a hook
+func DeleteHook(db *sql.DB, id int64) error {
+	result, err := db.Exec(`DELETE FROM usage_hooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrHookNotFound
+	}
+	return nil
+}
+
+// LastPeriod returns the most recent complete period before now: yesterday,
+// last Monday-to-Sunday week, or last calendar month
+func LastPeriod(period string, now time.Time) Period {
+	today := truncateDay(now)
+	switch period {
+	case PeriodWeek:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		return Period{From: monday.AddDate(0, 0, -7), To: monday.AddDate(0, 0, -1)}
+	case PeriodMonth:
+		return MonthPeriod(today.AddDate(0, 0, -today.Day()))
+	default:
+		yesterday := today.AddDate(0, 0, -1)
+		return Period{From: yesterday, To: yesterday}
+	}
+}
+
+// DeliverHooks posts the last complete period to each hook that hasn't
+// received it yet. Failed deliveries are recorded and retried on the next
+// call.
+func DeliverHooks(db *sql.DB, client *http.Client, now time.Time) error {
+	rows, err := db.Query(`SELECT id, url, secret, period, COALESCE(delivered_through, '') FROM usage_hooks`)
+	if err != nil {
+		return err
+	}
+	var hooks []Hook
+	for rows.Next() {
+		var h Hook
+		if err := rows.Scan(&h.ID, &h.URL, &h.Secret, &h.Period, &h.DeliveredThrough); err != nil {
+			rows.Close()
+			return err
+		}
+		hooks = append(hooks, h)
+	}
+	rows.Close()
+
+	for _, h := range hooks {
+		p := LastPeriod(h.Period, now)
+		if h.DeliveredThrough >= p.Start() {
+			continue
+		}
+		if err := deliver(db, client, h, p); err != nil {
+			db.Exec(`UPDATE usage_hooks SET last_error = ? WHERE id = ?`, err.Error(), h.ID)
+			continue
+		}
+		db.Exec(`UPDATE usage_hooks SET delivered_through = ?, last_error = NULL WHERE id = ?`, p.Start(), h.ID)
+	}
+	return nil
+}
+
+func deliver(db *sql.DB, client *http.Client, h Hook, p Period) error {
+	rows, err := Export(db, p)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(HookPayload{Event: HookEvent, Period: h.Period, From: p.Start(), To: p.End(), Usage: rows})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", HookEvent)
+	req.Header.Set("X-Webhook-Signature", Sign(h.Secret, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in X-Webhook-Signature
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,145 @@
+package usage
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, _ := time.Parse(dayFormat, s)
+	return t
+}
+
+func TestExport(t *testing.T) {
+	db := setupTestDB(t)
+	db.Exec(`INSERT INTO auth_users (id, email, provider, role) VALUES ('user_ann', 'ann@example.com', 'password', 'user'),
+		('user_bob', 'bob@example.com', 'password', 'user')`)
+	db.Exec(`INSERT INTO app_members (app_id, user_id, role, created_at) VALUES ('app_blog', 'user_bob', 'owner', 2),
+		('app_blog', 'user_ann', 'owner', 1)`)
+	db.Exec(`INSERT INTO app_usage (app, day, requests, cpu_ms) VALUES ('app_blog', '2026-09-01', 10, 100),
+		('app_blog', '2026-09-30', 5, 50), ('app_blog', '2026-10-01', 99, 999), ('docs', '2026-09-15', 3, 30)`)
+
+	p := MonthPeriod(day("2026-09-17"))
+	if p.Start() != "2026-09-01" || p.End() != "2026-09-30" {
+		t.Fatalf("MonthPeriod = %s..%s", p.Start(), p.End())
+	}
+
+	rows, err := Export(db, p)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %+v", rows)
+	}
+	// Billed to the earliest owner; unowned sites come last
+	if r := rows[0]; r.UserID != "user_ann" || r.Email != "ann@example.com" || r.App != "app_blog" || r.Name != "blog" ||
+		r.Requests != 15 || r.CPUMs != 150 {
+		t.Errorf("Unexpected first row %+v", r)
+	}
+	if r := rows[1]; r.UserID != "" || r.App != "docs" || r.Requests != 3 {
+		t.Errorf("Unexpected second row %+v", r)
+	}
+
+	var out strings.Builder
+	if err := WriteCSV(&out, p, rows); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "2026-09-01,2026-09-30,user_ann,ann@example.com,app_blog,blog,15,150,") {
+		t.Errorf("Unexpected CSV:\n%s", out.String())
+	}
+
+	if _, err := NewPeriod(time.Now(), time.Now().AddDate(0, 0, -1)); err != ErrInvalidPeriod {
+		t.Errorf("Expected reversed period to fail, got %v", err)
+	}
+	if _, err := NewPeriod(time.Now().Add(-2*Retention), time.Now()); err != ErrInvalidPeriod {
+		t.Errorf("Expected period past retention to fail, got %v", err)
+	}
+}
+
+func TestLastPeriod(t *testing.T) {
+	now := day("2026-10-16").Add(15 * time.Hour) // A Friday
+	tests := []struct {
+		period   string
+		from, to string
+	}{
+		{PeriodDay, "2026-10-15", "2026-10-15"},
+		{PeriodWeek, "2026-10-05", "2026-10-11"},
+		{PeriodMonth, "2026-09-01", "2026-09-30"},
+	}
+	for _, tt := range tests {
+		p := LastPeriod(tt.period, now)
+		if p.Start() != tt.from || p.End() != tt.to {
+			t.Errorf("LastPeriod(%s) = %s..%s, want %s..%s", tt.period, p.Start(), p.End(), tt.from, tt.to)
+		}
+	}
+	if p := LastPeriod(PeriodWeek, day("2026-10-12")); p.Start() != "2026-10-05" {
+		t.Errorf("Expected Monday to close the previous week, got %s", p.Start())
+	}
+}
+
+func TestDeliverHooks(t *testing.T) {
+	db := setupTestDB(t)
+	db.Exec(`INSERT INTO app_usage (app, day, requests) VALUES ('app_blog', '2026-09-10', 7)`)
+
+	var received []HookPayload
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Webhook-Signature") != Sign("s3cret", body) {
+			t.Errorf("Bad signature %q", r.Header.Get("X-Webhook-Signature"))
+		}
+		var payload HookPayload
+		json.Unmarshal(body, &payload)
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	if _, err := CreateHook(db, "ftp://example.com", PeriodMonth, ""); err == nil {
+		t.Error("Expected non-http URL to fail")
+	}
+	if _, err := CreateHook(db, server.URL, "year", ""); err == nil {
+		t.Error("Expected unknown period to fail")
+	}
+	generated, err := CreateHook(db, server.URL, PeriodMonth, "")
+	if err != nil || len(generated.Secret) != 64 {
+		t.Fatalf("Expected a generated secret, got %+v, %v", generated, err)
+	}
+	DeleteHook(db, generated.ID)
+	if err := DeleteHook(db, generated.ID); err != ErrHookNotFound {
+		t.Errorf("Expected ErrHookNotFound, got %v", err)
+	}
+
+	hook, _ := CreateHook(db, server.URL, PeriodMonth, "s3cret")
+	now := day("2026-10-16")
+
+	DeliverHooks(db, server.Client(), now)
+	hooks, _ := ListHooks(db)
+	if len(hooks) != 1 || hooks[0].DeliveredThrough != "" || hooks[0].LastError == "" || hooks[0].Secret != "" {
+		t.Fatalf("Expected a recorded failure and hidden secret, got %+v", hooks)
+	}
+
+	fail = false
+	DeliverHooks(db, server.Client(), now)
+	DeliverHooks(db, server.Client(), now.Add(time.Hour))
+	if len(received) != 1 {
+		t.Fatalf("Expected one delivery, got %d", len(received))
+	}
+	if p := received[0]; p.Event != HookEvent || p.From != "2026-09-01" || p.To != "2026-09-30" ||
+		len(p.Usage) != 1 || p.Usage[0].Requests != 7 {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+	hooks, _ = ListHooks(db)
+	if hooks[0].ID != hook.ID || hooks[0].DeliveredThrough != "2026-09-01" || hooks[0].LastError != "" {
+		t.Errorf("Expected delivery recorded, got %+v", hooks[0])
+	}
+}
@@ -0,0 +1,330 @@
+// Package usage attributes an approximate cost to each request (handler
+// time, storage queries, bytes written, egress) and sums it per app and day,
+// answering "what is this app actually costing my box".
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Retention is how long daily usage is kept
+const Retention = 90 * 24 * time.Hour
+
+// dayFormat is the layout of the app_usage day column
+const dayFormat = "2006-01-02"
+
+// Meter collects the cost of one request. It travels in the request context
+// so storage and egress bindings can add to it; all methods are safe on a
+// nil Meter, which is what code outside a metered request sees.
+type Meter struct {
+	dbReads      atomic.Int64
+	dbWrites     atomic.Int64
+	storageBytes atomic.Int64
+	egressBytes  atomic.Int64
+	egressWait   atomic.Int64 // nanoseconds spent waiting on fetches
+}
+
+// EgressWait returns the time the request has spent waiting on fetches
+func (m *Meter) EgressWait() time.Duration {
+	if m == nil {
+		return 0
+	}
+	return time.Duration(m.egressWait.Load())
+}
+
+type meterKey struct{}
+
+// WithMeter returns a context carrying a new Meter
+func WithMeter(ctx context.Context) (context.Context, *Meter) {
+	m := &Meter{}
+	return context.WithValue(ctx, meterKey{}, m), m
+}
+
+// FromContext returns the request's Meter, or nil outside a metered request
+func FromContext(ctx context.Context) *Meter {
+	if ctx == nil {
+		return nil
+	}
+	m, _ := ctx.Value(meterKey{}).(*Meter)
+	return m
+}
+
+// Read counts a storage read query
+func Read(ctx context.Context) {
+	if m := FromContext(ctx); m != nil {
+		m.dbReads.Add(1)
+	}
+}
+
+// Write counts a storage write query that stored n bytes
+func Write(ctx context.Context, n int) {
+	if m := FromContext(ctx); m != nil {
+		m.dbWrites.Add(1)
+		m.storageBytes.Add(int64(n))
+	}
+}
+
+// Egress counts an outbound fetch that moved n bytes and blocked for wait
+func Egress(ctx context.Context, n int, wait time.Duration) {
+	if m := FromContext(ctx); m != nil {
+		m.egressBytes.Add(int64(n))
+		m.egressWait.Add(int64(wait))
+	}
+}
+
+// Cost is the resource usage of one or more requests
+type Cost struct {
+	Requests      int64 `json:"requests"`
+	CPUMs         int64 `json:"cpu_ms"`
+	DBReads       int64 `json:"db_reads"`
+	DBWrites      int64 `json:"db_writes"`
+	StorageBytes  int64 `json:"storage_bytes"`
+	EgressBytes   int64 `json:"egress_bytes"`
+	ResponseBytes int64 `json:"response_bytes"`
+}
+
+func (c *Cost) add(o Cost) {
+	c.Requests += o.Requests
+	c.CPUMs += o.CPUMs
+	c.DBReads += o.DBReads
+	c.DBWrites += o.DBWrites
+	c.StorageBytes += o.StorageBytes
+	c.EgressBytes += o.EgressBytes
+	c.ResponseBytes += o.ResponseBytes
+}
+
+// Cost returns what the meter has collected for a request that took elapsed
+// and sent responseBytes. Time spent waiting on fetches is not counted as CPU.
+func (m *Meter) Cost(elapsed time.Duration, responseBytes int64) Cost {
+	c := Cost{Requests: 1, ResponseBytes: responseBytes}
+	if m != nil {
+		elapsed -= time.Duration(m.egressWait.Load())
+		c.DBReads = m.dbReads.Load()
+		c.DBWrites = m.dbWrites.Load()
+		c.StorageBytes = m.storageBytes.Load()
+		c.EgressBytes = m.egressBytes.Load()
+	}
+	if elapsed > 0 {
+		c.CPUMs = elapsed.Milliseconds()
+	}
+	return c
+}
+
+type dayKey struct {
+	app string
+	day string
+}
+
+// pending holds costs recorded since the last Flush
+var pending = struct {
+	sync.Mutex
+	costs map[dayKey]*Cost
+}{costs: make(map[dayKey]*Cost)}
+
+// Record adds a finished request's cost to the app's daily usage. It only
+// touches memory; the Flusher writes totals to the database.
+func Record(app string, c Cost) {
+	recordAt(app, c, time.Now())
+}
+
+func recordAt(app string, c Cost, now time.Time) {
+	key := dayKey{app: app, day: now.UTC().Format(dayFormat)}
+
+	pending.Lock()
+	defer pending.Unlock()
+
+	total := pending.costs[key]
+	if total == nil {
+		total = &Cost{}
+		pending.costs[key] = total
+	}
+	total.add(c)
+}
+
+// writeTx runs Flush's transaction. storage imports this package, so the
+// server hands in its write queue with SetWriter.
+var writeTx = func(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetWriter routes flushes through a serialized writer such as
+// storage.QueueWriteTx
+func SetWriter(w func(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error) {
+	writeTx = w
+}
+
+// Flush writes pending costs to app_usage and executions to runtime_usage.
+// Costs that fail to write are put back so they are retried on the next
+// flush.
+func Flush(db *sql.DB) error {
+	pending.Lock()
+	costs := pending.costs
+	pending.costs = make(map[dayKey]*Cost)
+	pending.Unlock()
+	executions := takeExecutions()
+
+	if len(costs) == 0 && len(executions) == 0 {
+		return nil
+	}
+
+	err := writeTx(context.Background(), db, func(tx *sql.Tx) error {
+		for key, c := range costs {
+			if _, err := tx.Exec(`
+				INSERT INTO app_usage (app, day, requests, cpu_ms, db_reads, db_writes, storage_bytes, egress_bytes, response_bytes)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT(app, day) DO UPDATE SET
+					requests = requests + excluded.requests,
+					cpu_ms = cpu_ms + excluded.cpu_ms,
+					db_reads = db_reads + excluded.db_reads,
+					db_writes = db_writes + excluded.db_writes,
+					storage_bytes = storage_bytes + excluded.storage_bytes,
+					egress_bytes = egress_bytes + excluded.egress_bytes,
+					response_bytes = response_bytes + excluded.response_bytes
+			`, key.app, key.day, c.Requests, c.CPUMs, c.DBReads, c.DBWrites, c.StorageBytes, c.EgressBytes, c.ResponseBytes); err != nil {
+				return err
+			}
+		}
+		return flushExecutions(tx, executions)
+	})
+	if err != nil {
+		for key, c := range costs {
+			restore(key, c)
+		}
+		restoreExecutions(executions)
+	}
+	return err
+}
+
+// restore merges an unflushed cost back into pending
+func restore(key dayKey, c *Cost) {
+	pending.Lock()
+	defer pending.Unlock()
+
+	if existing := pending.costs[key]; existing != nil {
+		existing.add(*c)
+		return
+	}
+	pending.costs[key] = c
+}
+
+// Prune deletes daily usage older than Retention
+func Prune(db *sql.DB, now time.Time) error {
+	cutoff := now.Add(-Retention).UTC().Format(dayFormat)
+	if _, err := db.Exec(`DELETE FROM app_usage WHERE day < ?`, cutoff); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM runtime_usage WHERE day < ?`, cutoff)
+	return err
+}
+
+// aliasedSites lists the subdomains routed to an app, with its ID
+const aliasedSites = `SELECT al.subdomain, a.id FROM aliases al
+	JOIN apps a ON a.id = json_extract(al.targets, '$.app_id')
+	WHERE al.type IN ('proxy', 'app') AND al.subdomain != a.id`
+
+// Reattribute moves usage recorded under a subdomain to the app it routes
+// to, merging their days, for sites metered before they became apps. It
+// returns how many rows were moved.
+func Reattribute(db *sql.DB) (int64, error) {
+	var moved int64
+	err := writeTx(context.Background(), db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`
+			INSERT INTO app_usage (app, day, requests, cpu_ms, db_reads, db_writes, storage_bytes, egress_bytes, response_bytes)
+			SELECT s.id, u.day, ` + costColumns + `
+			FROM app_usage u JOIN (` + aliasedSites + `) s ON s.subdomain = u.app
+			GROUP BY s.id, u.day
+			ON CONFLICT(app, day) DO UPDATE SET
+				requests = requests + excluded.requests,
+				cpu_ms = cpu_ms + excluded.cpu_ms,
+				db_reads = db_reads + excluded.db_reads,
+				db_writes = db_writes + excluded.db_writes,
+				storage_bytes = storage_bytes + excluded.storage_bytes,
+				egress_bytes = egress_bytes + excluded.egress_bytes,
+				response_bytes = response_bytes + excluded.response_bytes
+		`); err != nil {
+			return err
+		}
+		res, err := tx.Exec(`DELETE FROM app_usage WHERE app IN (SELECT subdomain FROM (` + aliasedSites + `))`)
+		if err != nil {
+			return err
+		}
+		moved, _ = res.RowsAffected()
+		return nil
+	})
+	return moved, err
+}
+
+// AppUsage is an app's total cost over a period
+type AppUsage struct {
+	App  string `json:"app"`
+	Name string `json:"name,omitempty"` // App title, when app is an app ID
+	Cost
+}
+
+// DayUsage is an app's cost on one UTC day
+type DayUsage struct {
+	Day string `json:"day"`
+	Cost
+}
+
+const costColumns = `SUM(requests), SUM(cpu_ms), SUM(db_reads), SUM(db_writes),
+	SUM(storage_bytes), SUM(egress_bytes), SUM(response_bytes)`
+
+// Summary returns each app's total cost since the given day, most CPU first
+func Summary(db *sql.DB, since time.Time) ([]AppUsage, error) {
+	rows, err := db.Query(`
+		SELECT u.app, COALESCE(MAX(a.title), ''), `+costColumns+`
+		FROM app_usage u LEFT JOIN apps a ON a.id = u.app
+		WHERE u.day >= ? GROUP BY u.app ORDER BY SUM(u.cpu_ms) DESC, u.app
+	`, since.UTC().Format(dayFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := []AppUsage{}
+	for rows.Next() {
+		var u AppUsage
+		if err := rows.Scan(&u.App, &u.Name, &u.Requests, &u.CPUMs, &u.DBReads, &u.DBWrites,
+			&u.StorageBytes, &u.EgressBytes, &u.ResponseBytes); err != nil {
+			return nil, err
+		}
+		apps = append(apps, u)
+	}
+	return apps, rows.Err()
+}
+
+// Daily returns an app's cost per day since the given day, oldest first
+func Daily(db *sql.DB, app string, since time.Time) ([]DayUsage, error) {
+	rows, err := db.Query(`
+		SELECT day, `+costColumns+` FROM app_usage
+		WHERE app = ? AND day >= ? GROUP BY day ORDER BY day
+	`, app, since.UTC().Format(dayFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []DayUsage{}
+	for rows.Next() {
+		var u DayUsage
+		if err := rows.Scan(&u.Day, &u.Requests, &u.CPUMs, &u.DBReads, &u.DBWrites,
+			&u.StorageBytes, &u.EgressBytes, &u.ResponseBytes); err != nil {
+			return nil, err
+		}
+		days = append(days, u)
+	}
+	return days, rows.Err()
+}
@@ -0,0 +1,141 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db := dbtest.Open(t)
+
+	db.Exec(`INSERT INTO apps (id, title, visibility) VALUES ('app_blog', 'blog', 'public')`)
+	return db
+}
+
+func TestMeter(t *testing.T) {
+	// Outside a metered request everything is a no-op
+	Read(context.Background())
+	Write(context.Background(), 10)
+	if c := FromContext(context.Background()).Cost(5*time.Millisecond, 0); c.CPUMs != 5 || c.DBReads != 0 {
+		t.Errorf("Expected an unmetered cost of 5ms and no reads, got %+v", c)
+	}
+
+	ctx, m := WithMeter(context.Background())
+	child, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	Read(child)
+	Read(child)
+	Write(child, 120)
+	Write(child, 0)
+	Egress(child, 2048, 30*time.Millisecond)
+
+	c := m.Cost(100*time.Millisecond, 512)
+	want := Cost{Requests: 1, CPUMs: 70, DBReads: 2, DBWrites: 2, StorageBytes: 120, EgressBytes: 2048, ResponseBytes: 512}
+	if c != want {
+		t.Errorf("Cost() = %+v, want %+v", c, want)
+	}
+
+	// Fetch time is never counted as negative CPU
+	Egress(child, 0, time.Second)
+	if c := m.Cost(100*time.Millisecond, 0); c.CPUMs != 0 {
+		t.Errorf("Expected CPU to floor at 0, got %d", c.CPUMs)
+	}
+}
+
+func TestFlushAndSummary(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	recordAt("app_blog", Cost{Requests: 1, CPUMs: 10, DBReads: 2}, now)
+	recordAt("app_blog", Cost{Requests: 1, CPUMs: 30, DBWrites: 1, StorageBytes: 100}, now)
+	recordAt("wiki", Cost{Requests: 1, CPUMs: 5, ResponseBytes: 900}, now)
+	recordAt("app_blog", Cost{Requests: 1, CPUMs: 7}, now.AddDate(0, 0, -1))
+	if err := Flush(db); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A second flush adds to the same day
+	recordAt("app_blog", Cost{Requests: 1, CPUMs: 20, EgressBytes: 64}, now)
+	if err := Flush(db); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	apps, err := Summary(db, now)
+	if err != nil {
+		t.Fatalf("Summary failed: %v", err)
+	}
+	if len(apps) != 2 {
+		t.Fatalf("Expected 2 apps today, got %+v", apps)
+	}
+	blog := apps[0]
+	if blog.App != "app_blog" || blog.Name != "blog" || blog.Requests != 3 || blog.CPUMs != 60 ||
+		blog.DBReads != 2 || blog.DBWrites != 1 || blog.StorageBytes != 100 || blog.EgressBytes != 64 {
+		t.Errorf("Unexpected blog usage: %+v", blog)
+	}
+	if apps[1].App != "wiki" || apps[1].Name != "" || apps[1].ResponseBytes != 900 {
+		t.Errorf("Unexpected wiki usage: %+v", apps[1])
+	}
+
+	daily, err := Daily(db, "app_blog", now.AddDate(0, 0, -6))
+	if err != nil {
+		t.Fatalf("Daily failed: %v", err)
+	}
+	if len(daily) != 2 || daily[0].Day != "2026-10-15" || daily[0].CPUMs != 7 || daily[1].CPUMs != 60 {
+		t.Errorf("Unexpected daily usage: %+v", daily)
+	}
+
+	if err := Prune(db, now.Add(Retention)); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if daily, _ := Daily(db, "app_blog", time.Time{}); len(daily) != 1 || daily[0].Day != "2026-10-16" {
+		t.Errorf("Expected only today after pruning, got %+v", daily)
+	}
+}
+
+func TestRuntimeTop(t *testing.T) {
+	db := setupTestDB(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	recordExecutionAt("app_blog", "api/main.js", Execution{CPU: 40 * time.Millisecond, AllocBytes: 100}, now)
+	recordExecutionAt("app_blog", "api/main.js", Execution{CPU: 20 * time.Millisecond, AllocBytes: 300}, now)
+	recordExecutionAt("app_blog", "/api/users/:id", Execution{CPU: 3 * time.Second, AllocBytes: 50, Killed: true}, now)
+	recordExecutionAt("wiki", "api/main.js", Execution{CPU: 5 * time.Millisecond}, now.AddDate(0, 0, -10))
+	if err := Flush(db); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	recordExecutionAt("app_blog", "api/main.js", Execution{CPU: 60 * time.Millisecond, AllocBytes: 100}, now)
+	if err := Flush(db); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	since := now.AddDate(0, 0, -6)
+	top, err := Top(db, since, "", "alloc", 0)
+	if err != nil {
+		t.Fatalf("Top failed: %v", err)
+	}
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 handlers in the last week, got %+v", top)
+	}
+	main := top[0]
+	if main.Entry != "api/main.js" || main.Name != "blog" || main.Executions != 3 || main.CPUMs != 120 ||
+		main.MaxCPUMs != 60 || main.AvgCPUMs != 40 || main.AllocBytes != 500 || main.MaxAllocBytes != 300 || main.Killed != 0 {
+		t.Errorf("Unexpected api/main.js usage: %+v", main)
+	}
+
+	top, _ = Top(db, since, "app_blog", "killed", 1)
+	if len(top) != 1 || top[0].Entry != "/api/users/:id" || top[0].Killed != 1 || top[0].MaxCPUMs != 3000 {
+		t.Errorf("Expected the killed route first, got %+v", top)
+	}
+
+	if _, err := Top(db, since, "", "bogus", 0); !errors.Is(err, ErrUnknownSort) {
+		t.Errorf("Expected ErrUnknownSort, got %v", err)
+	}
+}
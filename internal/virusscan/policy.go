@@ -0,0 +1,146 @@
+package virusscan
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/activity"
+)
+
+// InfectedError refuses an upload ClamAV flagged
+type InfectedError struct {
+	Path      string
+	Signature string
+}
+
+func (e *InfectedError) Error() string {
+	return fmt.Sprintf("upload %s blocked: virus found (%s)", e.Path, e.Signature)
+}
+
+// Policy decides which uploads are scanned and records every verdict in
+// the activity log. Uploads that can't be scanned are refused.
+type Policy struct {
+	Scanner Scanner
+	Mode    string
+
+	// Paths are glob patterns over blob paths; * matches within a segment
+	// and ** across segments. Empty scans every upload.
+	Paths []string
+}
+
+// NewPolicy returns the policy for mode, or nil for off
+func NewPolicy(mode, address string, paths []string) (*Policy, error) {
+	for _, p := range paths {
+		if err := ValidPattern(p); err != nil {
+			return nil, err
+		}
+	}
+	scanner, err := NewScanner(mode, address)
+	if err != nil || scanner == nil {
+		return nil, err
+	}
+	return &Policy{Scanner: scanner, Mode: mode, Paths: paths}, nil
+}
+
+// Matches reports whether an upload to blobPath is scanned
+func (p *Policy) Matches(blobPath string) bool {
+	if len(p.Paths) == 0 {
+		return true
+	}
+	for _, pattern := range p.Paths {
+		if Match(pattern, blobPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check scans an upload if its path matches, returning an InfectedError
+// for infected files and an error when the scan itself fails
+func (p *Policy) Check(ctx context.Context, appID, blobPath string, data []byte) error {
+	if !p.Matches(blobPath) {
+		return nil
+	}
+
+	result, err := p.Scanner.Scan(ctx, data)
+	details := map[string]interface{}{
+		"app_id":  appID,
+		"path":    blobPath,
+		"size":    len(data),
+		"scanner": p.Mode,
+	}
+	entry := activity.Entry{
+		ActorType:    activity.ActorSystem,
+		ResourceType: "blob",
+		ResourceID:   appID + "/" + blobPath,
+		Action:       "scan",
+		Result:       "success",
+		Weight:       activity.WeightDataMutation,
+		Details:      details,
+	}
+
+	switch {
+	case err != nil:
+		details["error"] = err.Error()
+		entry.Result = "failure"
+		entry.Weight = activity.WeightSecurity
+		activity.Log(entry)
+		log.Printf("Virus scan of %s/%s failed, upload refused: %v", appID, blobPath, err)
+		return fmt.Errorf("upload %s blocked: virus scan failed", blobPath)
+	case result.Infected:
+		details["signature"] = result.Signature
+		entry.Result = "failure"
+		entry.Weight = activity.WeightSecurity
+		activity.Log(entry)
+		log.Printf("Virus found in %s/%s (%s), upload refused", appID, blobPath, result.Signature)
+		return &InfectedError{Path: blobPath, Signature: result.Signature}
+	}
+	activity.Log(entry)
+	return nil
+}
+
+// ValidPattern reports whether pattern is a well-formed path glob
+func ValidPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("empty path pattern")
+	}
+	for _, seg := range strings.Split(pattern, "/") {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Match reports whether blobPath matches pattern, where ** matches any
+// number of path segments, e.g. "uploads/**" or "**/*.exe"
+func Match(pattern, blobPath string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(blobPath, "/"), "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
@@ -0,0 +1,176 @@
+// Package virusscan checks uploaded blobs with ClamAV before they are
+// stored, either through a clamd socket or by running clamscan.
+package virusscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Scan modes (server.virus_scan.mode)
+const (
+	ModeOff   = "off"   // Don't scan
+	ModeClamd = "clamd" // Stream to clamd over a unix socket or TCP
+	ModeExec  = "exec"  // Run clamscan or clamdscan on each upload
+)
+
+// Defaults used when no address is configured
+const (
+	DefaultClamdAddress = "/run/clamav/clamd.ctl"
+	DefaultExecPath     = "clamscan"
+)
+
+// scanTimeout bounds a single scan when the caller's context has no deadline
+const scanTimeout = 30 * time.Second
+
+// chunkSize is how much is sent per INSTREAM chunk
+const chunkSize = 64 << 10
+
+// ValidMode reports whether mode is a known scan mode
+func ValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeClamd || mode == ModeExec
+}
+
+// Result is the verdict on one upload
+type Result struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature,omitempty"` // What ClamAV found, when infected
+}
+
+// Scanner scans a blob's content
+type Scanner interface {
+	Scan(ctx context.Context, data []byte) (Result, error)
+}
+
+// NewScanner returns the scanner for mode, or nil for off. address is the
+// clamd socket path or host:port, or the clamscan binary; empty uses the
+// default for the mode.
+func NewScanner(mode, address string) (Scanner, error) {
+	switch mode {
+	case "", ModeOff:
+		return nil, nil
+	case ModeClamd:
+		if address == "" {
+			address = DefaultClamdAddress
+		}
+		return &Clamd{Address: address}, nil
+	case ModeExec:
+		if address == "" {
+			address = DefaultExecPath
+		}
+		return &Exec{Path: address}, nil
+	}
+	return nil, fmt.Errorf("unknown virus scan mode %q", mode)
+}
+
+// Clamd streams uploads to a clamd daemon with the INSTREAM command
+type Clamd struct {
+	// Address is a unix socket path (starting with /) or host:port
+	Address string
+}
+
+// Scan sends data to clamd and parses its verdict
+func (c *Clamd) Scan(ctx context.Context, data []byte) (Result, error) {
+	network := "tcp"
+	if strings.HasPrefix(c.Address, "/") {
+		network = "unix"
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, c.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamd unreachable: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(scanTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("clamd write failed: %w", err)
+	}
+	var size [4]byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		binary.BigEndian.PutUint32(size[:], uint32(n))
+		if _, err := conn.Write(size[:]); err != nil {
+			return Result{}, fmt.Errorf("clamd write failed: %w", err)
+		}
+		if _, err := conn.Write(data[:n]); err != nil {
+			return Result{}, fmt.Errorf("clamd write failed: %w", err)
+		}
+		data = data[n:]
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return Result{}, fmt.Errorf("clamd write failed: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return Result{}, fmt.Errorf("clamd read failed: %w", err)
+	}
+	return parseReply(strings.TrimRight(reply, "\x00\n"), "stream:")
+}
+
+// Exec runs clamscan (or clamdscan) with the upload on stdin
+type Exec struct {
+	Path string
+}
+
+// Scan runs the scanner binary; exit status 1 means a virus was found
+func (e *Exec) Scan(ctx context.Context, data []byte) (Result, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, scanTimeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, e.Path, "--no-summary", "-")
+	cmd.Stdin = bytes.NewReader(data)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return Result{}, nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.HasSuffix(line, " FOUND") {
+				return parseReply(line, "stdin:")
+			}
+		}
+		return Result{Infected: true, Signature: "unknown"}, nil
+	}
+	return Result{}, fmt.Errorf("%s failed: %v: %s", e.Path, err, strings.TrimSpace(out.String()))
+}
+
+// parseReply parses a ClamAV verdict like "stream: OK" or
+// "stream: Eicar-Signature FOUND"
+func parseReply(reply, prefix string) (Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, prefix))
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	}
+	return Result{}, fmt.Errorf("clamav: %s", verdict)
+}
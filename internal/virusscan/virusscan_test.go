@@ -0,0 +1,170 @@
+package virusscan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeClamd answers INSTREAM requests, flagging streams containing "EICAR"
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var stream bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					io.CopyN(&stream, r, int64(size))
+				}
+				if bytes.Contains(stream.Bytes(), []byte("EICAR")) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+				conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestClamdScan(t *testing.T) {
+	c := &Clamd{Address: fakeClamd(t)}
+
+	result, err := c.Scan(context.Background(), bytes.Repeat([]byte("x"), 3*chunkSize))
+	if err != nil || result.Infected {
+		t.Errorf("Expected a clean result, got %+v (%v)", result, err)
+	}
+
+	result, err = c.Scan(context.Background(), []byte("X5O!P%@AP EICAR test"))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Signature" {
+		t.Errorf("Expected Eicar-Signature, got %+v (%v)", result, err)
+	}
+
+	down := &Clamd{Address: filepath.Join(t.TempDir(), "clamd.sock")}
+	if _, err := down.Scan(context.Background(), []byte("x")); err == nil {
+		t.Error("Expected an unreachable clamd to fail")
+	}
+}
+
+func TestExecScan(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "clamscan")
+	os.WriteFile(script, []byte(`#!/bin/sh
+if grep -q EICAR; then echo "stdin: Eicar-Signature FOUND"; exit 1; fi
+echo "stdin: OK"
+`), 0755)
+	e := &Exec{Path: script}
+
+	if result, err := e.Scan(context.Background(), []byte("hello")); err != nil || result.Infected {
+		t.Errorf("Expected a clean result, got %+v (%v)", result, err)
+	}
+	if result, err := e.Scan(context.Background(), []byte("EICAR")); err != nil || result.Signature != "Eicar-Signature" {
+		t.Errorf("Expected Eicar-Signature, got %+v (%v)", result, err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"uploads/**", "uploads/a.pdf", true},
+		{"uploads/**", "uploads/2024/06/a.pdf", true},
+		{"uploads/**", "avatars/a.png", false},
+		{"**/*.exe", "setup.exe", true},
+		{"**/*.exe", "a/b/setup.exe", true},
+		{"**/*.exe", "a/b/setup.txt", false},
+		{"docs/*.pdf", "docs/a.pdf", true},
+		{"docs/*.pdf", "docs/x/a.pdf", false},
+		{"/docs/**/", "docs/a.pdf", true},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+
+	if ValidPattern("uploads/[") == nil {
+		t.Error("Expected a malformed pattern to be rejected")
+	}
+}
+
+type stubScanner struct {
+	result Result
+	err    error
+	calls  int
+}
+
+func (s *stubScanner) Scan(ctx context.Context, data []byte) (Result, error) {
+	s.calls++
+	return s.result, s.err
+}
+
+func TestPolicyCheck(t *testing.T) {
+	scanner := &stubScanner{result: Result{Infected: true, Signature: "Eicar-Signature"}}
+	p := &Policy{Scanner: scanner, Mode: ModeClamd, Paths: []string{"uploads/**"}}
+
+	if err := p.Check(context.Background(), "app_1", "avatars/me.png", []byte("x")); err != nil || scanner.calls != 0 {
+		t.Errorf("Expected paths outside the patterns not scanned, got %v after %d scans", err, scanner.calls)
+	}
+
+	var infected *InfectedError
+	err := p.Check(context.Background(), "app_1", "uploads/a.pdf", []byte("x"))
+	if !errors.As(err, &infected) || infected.Signature != "Eicar-Signature" {
+		t.Errorf("Expected an InfectedError, got %v", err)
+	}
+
+	// Uploads that can't be scanned are refused
+	scanner.result, scanner.err = Result{}, errors.New("clamd unreachable")
+	if err := p.Check(context.Background(), "app_1", "uploads/a.pdf", []byte("x")); err == nil {
+		t.Error("Expected a failed scan to refuse the upload")
+	}
+
+	scanner.err = nil
+	if err := p.Check(context.Background(), "app_1", "uploads/a.pdf", []byte("x")); err != nil {
+		t.Errorf("Expected a clean upload allowed, got %v", err)
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	if p, err := NewPolicy(ModeOff, "", nil); p != nil || err != nil {
+		t.Errorf("Expected no policy when off, got %+v (%v)", p, err)
+	}
+	p, err := NewPolicy(ModeClamd, "", []string{"uploads/**"})
+	if err != nil || p.Scanner.(*Clamd).Address != DefaultClamdAddress {
+		t.Errorf("Expected the default clamd socket, got %+v (%v)", p, err)
+	}
+	if _, err := NewPolicy("sophos", "", nil); err == nil {
+		t.Error("Expected an unknown mode to fail")
+	}
+}
@@ -0,0 +1,224 @@
+package webpush
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// Collection is the user-scoped ds collection subscriptions are kept in
+const Collection = "push_subscriptions"
+
+// InjectPushNamespace adds fazt.app.push.* to a Goja VM, for handlers and
+// jobs. userID is the signed-in user subscribe and unsubscribe act for, ""
+// when there's none.
+func InjectPushNamespace(vm *goja.Runtime, db *sql.DB, appID, userID string, ctx context.Context) error {
+	faztVal := vm.Get("fazt")
+	var fazt *goja.Object
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		fazt = vm.NewObject()
+		vm.Set("fazt", fazt)
+	} else {
+		fazt = faztVal.ToObject(vm)
+	}
+
+	appVal := fazt.Get("app")
+	var appObj *goja.Object
+	if appVal == nil || goja.IsUndefined(appVal) {
+		appObj = vm.NewObject()
+		fazt.Set("app", appObj)
+	} else {
+		appObj = appVal.ToObject(vm)
+	}
+
+	keys := func() *Keys {
+		k, err := AppKeys(db, appID)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return k
+	}
+	docs := func(user string) *storage.UserScopedDocs {
+		return storage.NewUserScopedDocs(db, storage.GetWriter(), appID, user)
+	}
+
+	pushObj := vm.NewObject()
+
+	// fazt.app.push.publicKey() - the applicationServerKey browsers
+	// subscribe with
+	pushObj.Set("publicKey", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(keys().PublicKey())
+	})
+
+	// fazt.app.push.subscribe(subscription) - keeps a browser's
+	// PushSubscription for the signed-in user, replacing one with the same
+	// endpoint. Returns the subscription's ID.
+	pushObj.Set("subscribe", func(call goja.FunctionCall) goja.Value {
+		if userID == "" {
+			panic(vm.NewGoError(fmt.Errorf("push.subscribe requires a signed-in user")))
+		}
+		sub := subscriptionArg(vm, call.Argument(0), "push.subscribe")
+		store := docs(userID)
+		if _, err := store.Delete(ctx, Collection, map[string]interface{}{"endpoint": sub.Endpoint}); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		id, err := store.Insert(ctx, Collection, subscriptionDoc(sub))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(id)
+	})
+
+	// fazt.app.push.unsubscribe(endpoint?) - forgets one of the signed-in
+	// user's subscriptions, or all of them. Returns how many were removed.
+	pushObj.Set("unsubscribe", func(call goja.FunctionCall) goja.Value {
+		if userID == "" {
+			panic(vm.NewGoError(fmt.Errorf("push.unsubscribe requires a signed-in user")))
+		}
+		query := map[string]interface{}{}
+		if arg := call.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			query["endpoint"] = arg.String()
+		}
+		n, err := docs(userID).Delete(ctx, Collection, query)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(n)
+	})
+
+	// fazt.app.push.send(subscription, payload, {ttl, urgency, topic}) -
+	// sends one message. Objects are sent as JSON. Returns {status, gone};
+	// gone subscriptions should be dropped.
+	pushObj.Set("send", func(call goja.FunctionCall) goja.Value {
+		sub := subscriptionArg(vm, call.Argument(0), "push.send")
+		payload := payloadArg(vm, call.Argument(1))
+		status, err := keys().Send(sub, payload, Subject(), optionsArg(vm, call.Argument(2)))
+		if err != nil && !errors.Is(err, ErrGone) {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(map[string]interface{}{
+			"status": status,
+			"gone":   errors.Is(err, ErrGone),
+		})
+	})
+
+	// fazt.app.push.sendToUser(userId, payload, {ttl, urgency, topic}) -
+	// sends to each of a user's subscriptions, removing gone ones. Returns
+	// {sent, removed, failed}.
+	pushObj.Set("sendToUser", func(call goja.FunctionCall) goja.Value {
+		user := call.Argument(0).String()
+		if goja.IsUndefined(call.Argument(0)) || user == "" {
+			panic(vm.NewGoError(fmt.Errorf("push.sendToUser requires a user ID")))
+		}
+		payload := payloadArg(vm, call.Argument(1))
+		opts := optionsArg(vm, call.Argument(2))
+
+		store := docs(user)
+		found, err := store.Find(ctx, Collection, map[string]interface{}{})
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		k := keys()
+		sent, removed, failed := 0, 0, 0
+		for _, doc := range found {
+			var sub Subscription
+			raw, _ := json.Marshal(doc.Data)
+			if json.Unmarshal(raw, &sub) != nil {
+				failed++
+				continue
+			}
+			_, err := k.Send(&sub, payload, Subject(), opts)
+			switch {
+			case errors.Is(err, ErrGone):
+				if _, err := store.Delete(ctx, Collection, map[string]interface{}{"id": doc.ID}); err == nil {
+					removed++
+				}
+			case err != nil:
+				failed++
+			default:
+				sent++
+			}
+		}
+		return vm.ToValue(map[string]interface{}{
+			"sent":    sent,
+			"removed": removed,
+			"failed":  failed,
+		})
+	})
+
+	appObj.Set("push", pushObj)
+	return nil
+}
+
+// subscriptionArg reads a PushSubscription (or its toJSON()) argument
+func subscriptionArg(vm *goja.Runtime, arg goja.Value, fn string) *Subscription {
+	if goja.IsUndefined(arg) || goja.IsNull(arg) {
+		panic(vm.NewGoError(fmt.Errorf("%s requires a subscription {endpoint, keys: {p256dh, auth}}", fn)))
+	}
+	raw, err := json.Marshal(arg.Export())
+	if err != nil {
+		panic(vm.NewGoError(fmt.Errorf("%s: %w", fn, err)))
+	}
+	var sub Subscription
+	if err := json.Unmarshal(raw, &sub); err != nil {
+		panic(vm.NewGoError(fmt.Errorf("%s: invalid subscription", fn)))
+	}
+	if err := sub.Validate(); err != nil {
+		panic(vm.NewGoError(fmt.Errorf("%s: %w", fn, err)))
+	}
+	return &sub
+}
+
+// payloadArg reads a payload: strings as-is, anything else as JSON
+func payloadArg(vm *goja.Runtime, arg goja.Value) []byte {
+	if goja.IsUndefined(arg) || goja.IsNull(arg) {
+		return nil
+	}
+	if s, ok := arg.Export().(string); ok {
+		return []byte(s)
+	}
+	raw, err := json.Marshal(arg.Export())
+	if err != nil {
+		panic(vm.NewGoError(fmt.Errorf("push payload: %w", err)))
+	}
+	return raw
+}
+
+// optionsArg reads {ttl, urgency, topic}
+func optionsArg(vm *goja.Runtime, arg goja.Value) Options {
+	var opts Options
+	if goja.IsUndefined(arg) || goja.IsNull(arg) {
+		return opts
+	}
+	obj := arg.ToObject(vm)
+	if v := obj.Get("ttl"); v != nil && !goja.IsUndefined(v) {
+		opts.TTL = int(v.ToInteger())
+	}
+	if v := obj.Get("urgency"); v != nil && !goja.IsUndefined(v) {
+		opts.Urgency = v.String()
+	}
+	if v := obj.Get("topic"); v != nil && !goja.IsUndefined(v) {
+		opts.Topic = v.String()
+	}
+	return opts
+}
+
+// subscriptionDoc is how a subscription is stored
+func subscriptionDoc(sub *Subscription) map[string]interface{} {
+	doc := map[string]interface{}{
+		"endpoint": sub.Endpoint,
+		"keys": map[string]interface{}{
+			"p256dh": sub.Keys.P256dh,
+			"auth":   sub.Keys.Auth,
+		},
+	}
+	if sub.ExpirationTime != nil {
+		doc["expirationTime"] = *sub.ExpirationTime
+	}
+	return doc
+}
@@ -0,0 +1,47 @@
+package webpush
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fazt-sh/fazt/internal/config"
+)
+
+// AppKeys returns an app's VAPID keys, creating them on first use. Browsers
+// subscribe with the public key, so the keys live as long as the app.
+func AppKeys(db *sql.DB, appID string) (*Keys, error) {
+	var private string
+	err := db.QueryRow(`SELECT private_key FROM push_keys WHERE app_id = ?`, appID).Scan(&private)
+	if err == sql.ErrNoRows {
+		keys, err := GenerateKeys()
+		if err != nil {
+			return nil, err
+		}
+		// Concurrent first uses race here; whichever key lands is kept
+		if _, err := db.Exec(`
+			INSERT OR IGNORE INTO push_keys (app_id, public_key, private_key)
+			VALUES (?, ?, ?)
+		`, appID, keys.PublicKey(), keys.PrivateKey()); err != nil {
+			return nil, fmt.Errorf("failed to store push keys: %w", err)
+		}
+		err = db.QueryRow(`SELECT private_key FROM push_keys WHERE app_id = ?`, appID).Scan(&private)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load push keys: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load push keys: %w", err)
+	}
+	return ParseKeys(private)
+}
+
+// Subject is the contact push services see for fazt's messages: the
+// server's URL
+func Subject() string {
+	domain := "localhost"
+	if config.Loaded() {
+		domain = config.Get().Server.Domain
+	}
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://")
+	return "https://" + domain
+}
@@ -0,0 +1,272 @@
+// Package webpush sends Web Push notifications (RFC 8030) with VAPID
+// authentication (RFC 8292) and aes128gcm payload encryption (RFC 8291), so
+// apps can notify their users' browsers without a third-party push service.
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/egress"
+)
+
+// MaxPayload is the largest payload that fits one 4096-byte record
+const MaxPayload = 4096 - 86 - 17
+
+// recordSize is the aes128gcm record size
+const recordSize = 4096
+
+// ErrGone means the push service no longer knows the subscription (404 or
+// 410); it should be forgotten
+var ErrGone = errors.New("push subscription is gone")
+
+// Client sends push messages. It only dials public addresses, since
+// subscription endpoints come from browsers.
+var Client = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: &http.Transport{DialContext: egress.DialPublic},
+}
+
+// Subscription is a browser's PushSubscription, as its toJSON() gives it
+type Subscription struct {
+	Endpoint       string `json:"endpoint"`
+	ExpirationTime *int64 `json:"expirationTime,omitempty"`
+	Keys           struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Validate checks the subscription has an https endpoint and both keys
+func (s *Subscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("subscription endpoint must be an https URL")
+	}
+	if s.Keys.P256dh == "" || s.Keys.Auth == "" {
+		return fmt.Errorf("subscription requires keys.p256dh and keys.auth")
+	}
+	return nil
+}
+
+// Options are a message's delivery options
+type Options struct {
+	TTL     int    // Seconds the push service keeps an undelivered message
+	Urgency string // very-low, low, normal or high
+	Topic   string // Replaces an undelivered message with the same topic
+}
+
+// Keys is a VAPID key pair
+type Keys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateKeys creates a VAPID key pair
+func GenerateKeys() (*Keys, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{private: key}, nil
+}
+
+// ParseKeys reads a key pair from its private key, as PrivateKey gives it
+func ParseKeys(private string) (*Keys, error) {
+	d, err := decode(private)
+	if err != nil || len(d) != 32 {
+		return nil, fmt.Errorf("invalid VAPID private key")
+	}
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	key.Curve = elliptic.P256()
+	key.X, key.Y = key.Curve.ScalarBaseMult(d)
+	return &Keys{private: key}, nil
+}
+
+// PublicKey is the uncompressed public key, base64url encoded: the
+// applicationServerKey browsers subscribe with
+func (k *Keys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.publicBytes())
+}
+
+// PrivateKey is the private key, base64url encoded
+func (k *Keys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.private.D.FillBytes(make([]byte, 32)))
+}
+
+func (k *Keys) publicBytes() []byte {
+	pub, _ := k.private.PublicKey.ECDH()
+	return pub.Bytes()
+}
+
+// Send encrypts payload for sub and posts it to the push service, returning
+// the service's status. subject identifies the sender to the push service:
+// a mailto: or https: URL.
+func (k *Keys) Send(sub *Subscription, payload []byte, subject string, opts Options) (int, error) {
+	if err := sub.Validate(); err != nil {
+		return 0, err
+	}
+	if len(payload) > MaxPayload {
+		return 0, fmt.Errorf("push payload is %d bytes, over the %d byte limit", len(payload), MaxPayload)
+	}
+	body, err := Encrypt(sub, payload)
+	if err != nil {
+		return 0, err
+	}
+	auth, err := k.authorization(sub.Endpoint, subject, time.Now().Add(12*time.Hour))
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = 24 * 60 * 60
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", strconv.Itoa(opts.TTL))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("push service unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return resp.StatusCode, ErrGone
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("push service answered %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp.StatusCode, nil
+}
+
+// authorization signs the VAPID JWT for endpoint's push service
+func (k *Keys) authorization(endpoint, subject string, exp time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": exp.Unix(),
+		"sub": subject,
+	})
+	signed := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return "vapid t=" + token + ", k=" + k.PublicKey(), nil
+}
+
+// Encrypt encrypts payload for sub as a single aes128gcm record
+func Encrypt(sub *Subscription, payload []byte) ([]byte, error) {
+	uaBytes, err := decode(sub.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription p256dh key")
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription p256dh key")
+	}
+	authSecret, err := decode(sub.Keys.Auth)
+	if err != nil || len(authSecret) < 16 {
+		return nil, fmt.Errorf("invalid subscription auth secret")
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encrypt(uaPublic, authSecret, asPrivate, salt, payload)
+}
+
+func encrypt(uaPublic *ecdh.PublicKey, authSecret []byte, asPrivate *ecdh.PrivateKey, salt, payload []byte) ([]byte, error) {
+	secret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic.Bytes()...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm, err := hkdf.Key(sha256.New, secret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key ID length and the server's public key
+	out := make([]byte, 0, 86+len(payload)+17)
+	out = append(out, salt...)
+	out = binary.BigEndian.AppendUint32(out, recordSize)
+	out = append(out, byte(len(asPublic)))
+	out = append(out, asPublic...)
+
+	// A 0x02 delimiter marks the last (and only) record
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(out, nonce, plaintext, nil), nil
+}
+
+// decode reads base64url, with or without padding, as browsers vary
+func decode(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,282 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+)
+
+// browser is a user agent's side of a subscription
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T, endpoint string) (*browser, *Subscription) {
+	t.Helper()
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &browser{key: key, auth: make([]byte, 16)}
+	rand.Read(b.auth)
+
+	sub := &Subscription{Endpoint: endpoint}
+	sub.Keys.P256dh = base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes())
+	sub.Keys.Auth = base64.RawURLEncoding.EncodeToString(b.auth)
+	return b, sub
+}
+
+// decrypt decrypts an aes128gcm body as RFC 8291 has the user agent do
+func (b *browser) decrypt(t *testing.T, body []byte) string {
+	t.Helper()
+	salt, rs, idlen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	if rs != 4096 || idlen != 65 {
+		t.Fatalf("Unexpected header: rs=%d idlen=%d", rs, idlen)
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(body[21 : 21+idlen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _ := b.key.ECDH(asPublic)
+	info := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	info = append(info, asPublic.Bytes()...)
+	ikm, _ := hkdf.Key(sha256.New, secret, b.auth, string(info), 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if plain[len(plain)-1] != 0x02 {
+		t.Fatalf("Expected the last record delimiter, got %x", plain[len(plain)-1])
+	}
+	return string(plain[:len(plain)-1])
+}
+
+// pushService is a fake push service recording the requests it gets
+func pushService(t *testing.T, status int) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	t.Helper()
+	reqs, bodies := make(chan *http.Request, 10), make(chan []byte, 10)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- r
+		bodies <- body
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := Client
+	Client = srv.Client()
+	t.Cleanup(func() { Client = prev })
+	return srv, reqs, bodies
+}
+
+func TestEncrypt(t *testing.T) {
+	b, sub := newBrowser(t, "https://push.example.com/send/1")
+	body, err := Encrypt(sub, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.decrypt(t, body); got != "hello" {
+		t.Errorf("Expected hello, got %q", got)
+	}
+
+	sub.Keys.P256dh = "bm90IGEga2V5"
+	if _, err := Encrypt(sub, []byte("hello")); err == nil {
+		t.Error("Expected an invalid key refused")
+	}
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseKeys(keys.PrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.PublicKey() != keys.PublicKey() {
+		t.Error("Expected the same public key from the parsed private key")
+	}
+	if raw, _ := base64.RawURLEncoding.DecodeString(keys.PublicKey()); len(raw) != 65 || raw[0] != 0x04 {
+		t.Errorf("Expected an uncompressed P-256 point, got %d bytes", len(raw))
+	}
+	if _, err := ParseKeys("short"); err == nil {
+		t.Error("Expected an invalid private key refused")
+	}
+}
+
+func TestSend(t *testing.T) {
+	srv, reqs, bodies := pushService(t, http.StatusCreated)
+	b, sub := newBrowser(t, srv.URL+"/send/abc")
+	keys, _ := GenerateKeys()
+
+	status, err := keys.Send(sub, []byte(`{"title":"hi"}`), "https://example.com", Options{TTL: 60, Urgency: "high", Topic: "news"})
+	if err != nil || status != http.StatusCreated {
+		t.Fatalf("Send: %d %v", status, err)
+	}
+
+	req, body := <-reqs, <-bodies
+	if got := b.decrypt(t, body); got != `{"title":"hi"}` {
+		t.Errorf("Unexpected payload %q", got)
+	}
+	for header, want := range map[string]string{"Content-Encoding": "aes128gcm", "TTL": "60", "Urgency": "high", "Topic": "news"} {
+		if got := req.Header.Get(header); got != want {
+			t.Errorf("%s: expected %q, got %q", header, want, got)
+		}
+	}
+
+	// Authorization: vapid t=<JWT>, k=<public key>
+	var token, k string
+	for _, part := range strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "vapid "), ", ") {
+		if v, ok := strings.CutPrefix(part, "t="); ok {
+			token = v
+		} else if v, ok := strings.CutPrefix(part, "k="); ok {
+			k = v
+		}
+	}
+	if k != keys.PublicKey() {
+		t.Errorf("Expected k to be the public key, got %q", k)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a JWT, got %q", token)
+	}
+	var claims struct {
+		Aud string `json:"aud"`
+		Sub string `json:"sub"`
+		Exp int64  `json:"exp"`
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(raw, &claims)
+	if claims.Aud != srv.URL || claims.Sub != "https://example.com" || claims.Exp == 0 {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+
+	pubBytes, _ := base64.RawURLEncoding.DecodeString(k)
+	x, y := elliptic.Unmarshal(elliptic.P256(), pubBytes)
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Error("Expected the JWT signed with the VAPID key")
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	srv, _, _ := pushService(t, http.StatusGone)
+	_, sub := newBrowser(t, srv.URL+"/send/old")
+	keys, _ := GenerateKeys()
+
+	if status, err := keys.Send(sub, []byte("x"), "https://example.com", Options{}); !errors.Is(err, ErrGone) || status != http.StatusGone {
+		t.Errorf("Expected ErrGone, got %d %v", status, err)
+	}
+	if _, err := keys.Send(sub, make([]byte, MaxPayload+1), "https://example.com", Options{}); err == nil {
+		t.Error("Expected an oversized payload refused")
+	}
+	sub.Endpoint = "http://push.example.com/send"
+	if _, err := keys.Send(sub, []byte("x"), "https://example.com", Options{}); err == nil {
+		t.Error("Expected a plain http endpoint refused")
+	}
+}
+
+func TestAppKeys(t *testing.T) {
+	db := dbtest.Open(t)
+	first, err := AppKeys(db, "app_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := AppKeys(db, "app_1")
+	other, _ := AppKeys(db, "app_2")
+	if first.PublicKey() != again.PublicKey() {
+		t.Error("Expected an app's keys kept")
+	}
+	if first.PublicKey() == other.PublicKey() {
+		t.Error("Expected apps to have their own keys")
+	}
+}
+
+func TestPushBindings(t *testing.T) {
+	db := dbtest.Open(t)
+	srv, _, bodies := pushService(t, http.StatusCreated)
+	b, sub := newBrowser(t, srv.URL+"/send/live")
+	subJSON, _ := json.Marshal(sub)
+
+	vm := goja.New()
+	if err := InjectPushNamespace(vm, db, "app_1", "user_1", context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	vm.Set("sub", string(subJSON))
+
+	v, err := vm.RunString(`
+		const s = JSON.parse(sub);
+		fazt.app.push.subscribe(s);
+		fazt.app.push.subscribe(s); // same endpoint, kept once
+		const r = fazt.app.push.sendToUser("user_1", {title: "hi"});
+		JSON.stringify([fazt.app.push.publicKey().length, r.sent, r.removed, r.failed]);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != `[87,1,0,0]` {
+		t.Errorf("Unexpected result %s", v)
+	}
+	if got := b.decrypt(t, <-bodies); got != `{"title":"hi"}` {
+		t.Errorf("Unexpected payload %q", got)
+	}
+
+	// Jobs have no user to subscribe for
+	anon := goja.New()
+	InjectPushNamespace(anon, db, "app_1", "", context.Background())
+	anon.Set("sub", string(subJSON))
+	if _, err := anon.RunString(`fazt.app.push.subscribe(JSON.parse(sub))`); err == nil {
+		t.Error("Expected subscribe without a user to throw")
+	}
+}
+
+func TestSendToUserRemovesGone(t *testing.T) {
+	db := dbtest.Open(t)
+	srv, _, _ := pushService(t, http.StatusGone)
+	_, sub := newBrowser(t, srv.URL+"/send/old")
+	subJSON, _ := json.Marshal(sub)
+
+	vm := goja.New()
+	InjectPushNamespace(vm, db, "app_1", "user_1", context.Background())
+	vm.Set("sub", string(subJSON))
+	v, err := vm.RunString(`
+		fazt.app.push.subscribe(JSON.parse(sub));
+		const a = fazt.app.push.sendToUser("user_1", "x");
+		const b = fazt.app.push.sendToUser("user_1", "x");
+		JSON.stringify([a.sent, a.removed, b.sent, b.removed]);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.String() != `[0,1,0,0]` {
+		t.Errorf("Unexpected result %s", v)
+	}
+}
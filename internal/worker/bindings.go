@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/sandbox"
 )
 
 // InjectWorkerNamespace adds fazt.worker.* to a Goja VM.
@@ -24,7 +25,7 @@ func InjectWorkerNamespace(vm *goja.Runtime, appID string, ctx context.Context)
 	workerObj := vm.NewObject()
 
 	// fazt.worker.spawn(handler, options)
-	workerObj.Set("spawn", makeWorkerSpawn(vm, appID))
+	workerObj.Set("spawn", makeWorkerSpawn(vm, appID, ctx))
 
 	// fazt.worker.get(jobId)
 	workerObj.Set("get", makeWorkerGet(vm, appID))
@@ -38,17 +39,26 @@ func InjectWorkerNamespace(vm *goja.Runtime, appID string, ctx context.Context)
 	// fazt.worker.wait(jobId, options) - poll until done
 	workerObj.Set("wait", makeWorkerWait(vm, ctx))
 
+	// fazt.worker.queue(name, options) - define a queue
+	workerObj.Set("queue", makeWorkerQueue(vm, appID))
+
 	fazt.Set("worker", workerObj)
 	return nil
 }
 
 // makeWorkerSpawn creates the fazt.worker.spawn() function.
-func makeWorkerSpawn(vm *goja.Runtime, appID string) func(goja.FunctionCall) goja.Value {
+// Each call counts against the execution's spawn limit so a single request
+// can't fan out into unbounded background jobs.
+func makeWorkerSpawn(vm *goja.Runtime, appID string, ctx context.Context) func(goja.FunctionCall) goja.Value {
 	return func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			panic(vm.NewGoError(fmt.Errorf("worker.spawn requires handler path")))
 		}
 
+		if err := sandbox.Spawn(ctx); err != nil {
+			panic(vm.NewGoError(err))
+		}
+
 		handler := call.Argument(0).String()
 
 		// Parse options
@@ -118,15 +128,13 @@ func parseSpawnOptions(cfg *JobConfig, opts map[string]interface{}) {
 	}
 
 	// priority: 'low' | 'normal' | 'high'
-	if priority, ok := opts["priority"].(string); ok {
-		switch priority {
-		case "low":
-			cfg.Priority = -1
-		case "normal":
-			cfg.Priority = 0
-		case "high":
-			cfg.Priority = 1
-		}
+	if priority, ok := parsePriority(opts["priority"]); ok {
+		cfg.Priority = priority
+	}
+
+	// queue: 'emails'
+	if queue, ok := opts["queue"].(string); ok && validQueueName(queue) {
+		cfg.Queue = queue
 	}
 
 	// uniqueKey: 'sync-user-123'
@@ -147,6 +155,66 @@ func parseSpawnOptions(cfg *JobConfig, opts map[string]interface{}) {
 	}
 }
 
+// parsePriority reads 'low' | 'normal' | 'high' as -1, 0, 1.
+func parsePriority(v interface{}) (int, bool) {
+	switch v {
+	case "low":
+		return -1, true
+	case "normal":
+		return 0, true
+	case "high":
+		return 1, true
+	}
+	return 0, false
+}
+
+func validQueueName(name string) bool {
+	return name != "" && len(name) <= 64
+}
+
+// makeWorkerQueue creates the fazt.worker.queue() function, which sets how
+// many of the app's jobs in a queue run at once and the queue's priority:
+//
+//	fazt.worker.queue('webhooks', { priority: 'high' })
+//	fazt.worker.queue('thumbnails', { concurrency: 2, priority: 'low' })
+func makeWorkerQueue(vm *goja.Runtime, appID string) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		if len(call.Arguments) < 1 {
+			panic(vm.NewGoError(fmt.Errorf("worker.queue requires a queue name")))
+		}
+
+		name := call.Argument(0).String()
+		if !validQueueName(name) {
+			panic(vm.NewGoError(fmt.Errorf("invalid queue name: %q", name)))
+		}
+
+		var cfg QueueConfig
+		if len(call.Arguments) >= 2 && !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+			if opts, ok := call.Argument(1).Export().(map[string]interface{}); ok {
+				switch c := opts["concurrency"].(type) {
+				case int64:
+					cfg.Concurrency = int(c)
+				case float64:
+					cfg.Concurrency = int(c)
+				}
+				if priority, ok := parsePriority(opts["priority"]); ok {
+					cfg.Priority = priority
+				} else if opts["priority"] != nil {
+					panic(vm.NewGoError(fmt.Errorf("queue priority must be 'low', 'normal' or 'high'")))
+				}
+			}
+		}
+		if cfg.Concurrency < 0 {
+			panic(vm.NewGoError(fmt.Errorf("queue concurrency must be positive")))
+		}
+
+		if err := SetQueue(appID, name, cfg); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return goja.Undefined()
+	}
+}
+
 // makeWorkerGet creates the fazt.worker.get() function.
 func makeWorkerGet(vm *goja.Runtime, appID string) func(goja.FunctionCall) goja.Value {
 	return func(call goja.FunctionCall) goja.Value {
@@ -284,6 +352,7 @@ func jobToJS(job *Job) map[string]interface{} {
 		"status":   string(job.Status),
 		"progress": int(job.Progress * 100), // Convert 0-1 to 0-100
 		"attempt":  job.Attempt,
+		"queue":    queueName(job),
 	}
 
 	if job.Config.Data != nil {
@@ -318,7 +387,8 @@ func jobToJS(job *Job) map[string]interface{} {
 }
 
 // InjectJobContext adds job.* to a Goja VM for use inside worker handlers.
-// This provides the job object with progress(), log(), checkpoint(), etc.
+// This provides the job object with progress(), log(), checkpoint(),
+// restoreCheckpoint(), etc.
 func InjectJobContext(vm *goja.Runtime, job *Job) error {
 	jobObj := vm.NewObject()
 
@@ -328,6 +398,7 @@ func InjectJobContext(vm *goja.Runtime, job *Job) error {
 	jobObj.Set("attempt", job.Attempt)
 	jobObj.Set("memory", job.Config.MemoryBytes)
 	jobObj.Set("daemon", job.Config.Daemon)
+	jobObj.Set("service", job.Config.Service)
 
 	// Dynamic cancelled property (getter)
 	jobObj.DefineAccessorProperty("cancelled", vm.ToValue(func(call goja.FunctionCall) goja.Value {
@@ -385,8 +456,9 @@ func InjectJobContext(vm *goja.Runtime, job *Job) error {
 		return goja.Undefined()
 	})
 
-	// job.getCheckpoint() - explicit getter for checkpoint
-	jobObj.Set("getCheckpoint", func(call goja.FunctionCall) goja.Value {
+	// job.restoreCheckpoint() - state saved by a previous attempt or run,
+	// or null on the first one
+	restoreCheckpoint := func(call goja.FunctionCall) goja.Value {
 		checkpoint, err := job.GetCheckpoint()
 		if err != nil {
 			panic(vm.NewGoError(err))
@@ -395,7 +467,11 @@ func InjectJobContext(vm *goja.Runtime, job *Job) error {
 			return goja.Null()
 		}
 		return vm.ToValue(checkpoint)
-	})
+	}
+	jobObj.Set("restoreCheckpoint", restoreCheckpoint)
+
+	// job.getCheckpoint() - older name of restoreCheckpoint
+	jobObj.Set("getCheckpoint", restoreCheckpoint)
 
 	vm.Set("job", jobObj)
 	return nil
@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/storage"
+)
+
+// DeadLetter is a job that failed its last attempt, kept with the context
+// needed to debug and replay it.
+type DeadLetter struct {
+	JobID      string                 `json:"job_id"`
+	AppID      string                 `json:"app_id"`
+	Handler    string                 `json:"handler"`
+	Queue      string                 `json:"queue"`
+	Error      string                 `json:"error"`
+	Logs       []string               `json:"logs"`
+	Checkpoint json.RawMessage        `json:"checkpoint,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Attempts   int                    `json:"attempts"`
+	CreatedAt  time.Time              `json:"created_at"`
+	FailedAt   time.Time              `json:"failed_at"`
+	RetriedAt  *time.Time             `json:"retried_at,omitempty"`
+	RetryJobID string                 `json:"retry_job_id,omitempty"`
+
+	config JobConfig
+}
+
+// deadLetter records a job that exhausted its retries.
+func (p *Pool) deadLetter(job *Job) {
+	job.mu.RLock()
+	configJSON, _ := json.Marshal(job.Config)
+	logsJSON, _ := json.Marshal(job.Logs)
+	args := []interface{}{
+		job.ID, job.AppID, job.Handler, string(configJSON), nullString(job.Error),
+		string(logsJSON), nullString(job.Checkpoint), job.Attempt,
+		nullTime(job.CreatedAt), time.Now().UnixMilli(),
+	}
+	failed := map[string]interface{}{
+		"job_id":   job.ID,
+		"handler":  job.Handler,
+		"error":    job.Error,
+		"attempts": job.Attempt,
+	}
+	job.mu.RUnlock()
+
+	err := storage.QueueWriteTx(context.Background(), p.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO dead_letter (
+				job_id, app_id, handler, config, error, logs, checkpoint,
+				attempts, created_at, failed_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, args...)
+		return err
+	})
+	if err != nil {
+		debug.Log("worker", "failed to dead-letter job %s: %v", job.ID, err)
+		return
+	}
+	debug.Log("worker", "job %s dead-lettered after %d attempts", job.ID, job.Attempt)
+
+	events.Emit(events.JobFailed, job.AppID, failed)
+}
+
+// DeadLetters returns dead-lettered jobs, most recent failure first. An
+// empty appID returns every app's.
+func (p *Pool) DeadLetters(appID string, limit int) ([]DeadLetter, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT job_id, app_id, handler, config, error, logs, checkpoint,
+		       attempts, created_at, failed_at, retried_at, retry_job_id
+		FROM dead_letter
+	`
+	args := []interface{}{}
+	if appID != "" {
+		query += " WHERE app_id = ?"
+		args = append(args, appID)
+	}
+	query += " ORDER BY failed_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, *d)
+	}
+	return letters, rows.Err()
+}
+
+// DeadLetter returns one dead-lettered job.
+func (p *Pool) DeadLetter(jobID string) (*DeadLetter, error) {
+	d, err := scanDeadLetter(p.db.QueryRow(`
+		SELECT job_id, app_id, handler, config, error, logs, checkpoint,
+		       attempts, created_at, failed_at, retried_at, retry_job_id
+		FROM dead_letter WHERE job_id = ?
+	`, jobID))
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	return d, err
+}
+
+// RetryDeadLetter replays a dead-lettered job as a new job with the same
+// handler, options and data, starting from its last checkpoint. Each dead
+// letter is replayed once; if the replay fails too, it is dead-lettered in
+// turn.
+func (p *Pool) RetryDeadLetter(jobID string) (*Job, error) {
+	d, err := p.DeadLetter(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if d.RetryJobID != "" {
+		return nil, fmt.Errorf("%w: %s", ErrAlreadyRetried, d.RetryJobID)
+	}
+
+	job, err := p.spawn(d.AppID, d.Handler, d.config, string(d.Checkpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.db.Exec(`
+		UPDATE dead_letter SET retried_at = ?, retry_job_id = ? WHERE job_id = ?
+	`, time.Now().UnixMilli(), job.ID, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func scanDeadLetter(row interface{ Scan(...interface{}) error }) (*DeadLetter, error) {
+	var d DeadLetter
+	var configJSON, logsJSON string
+	var errorStr, checkpoint, retryJobID sql.NullString
+	var createdAt, retriedAt sql.NullInt64
+	var failedAt int64
+
+	err := row.Scan(&d.JobID, &d.AppID, &d.Handler, &configJSON, &errorStr, &logsJSON,
+		&checkpoint, &d.Attempts, &createdAt, &failedAt, &retriedAt, &retryJobID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(configJSON), &d.config); err != nil {
+		d.config = DefaultJobConfig()
+	}
+	d.config.MaxAttempts = max(d.config.MaxAttempts, 1)
+	d.Queue = queueName(&Job{Config: d.config})
+	d.Data = d.config.Data
+	json.Unmarshal([]byte(logsJSON), &d.Logs)
+	if d.Logs == nil {
+		d.Logs = []string{}
+	}
+	d.Error = errorStr.String
+	if checkpoint.Valid && json.Valid([]byte(checkpoint.String)) {
+		d.Checkpoint = json.RawMessage(checkpoint.String)
+	}
+	if createdAt.Valid {
+		d.CreatedAt = time.UnixMilli(createdAt.Int64)
+	}
+	d.FailedAt = time.UnixMilli(failedAt)
+	if retriedAt.Valid {
+		t := time.UnixMilli(retriedAt.Int64)
+		d.RetriedAt = &t
+	}
+	d.RetryJobID = retryJobID.String
+	return &d, nil
+}
@@ -9,4 +9,7 @@ var (
 	ErrQueueFull          = errors.New("job queue full")
 	ErrDaemonLimitReached = errors.New("max daemon workers reached")
 	ErrMemoryPoolFull     = errors.New("memory pool exhausted")
+	ErrAlreadyRetried     = errors.New("dead-lettered job already retried")
+	ErrServiceNotFound    = errors.New("service not declared in manifest.json")
+	ErrInvalidService     = errors.New("invalid service in manifest.json")
 )
@@ -3,18 +3,29 @@ package worker
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"path"
+	"time"
 
 	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/chaos"
 	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/egress"
 	"github.com/fazt-sh/fazt/internal/hosting"
+	"github.com/fazt-sh/fazt/internal/modules"
+	"github.com/fazt-sh/fazt/internal/sandbox"
+	"github.com/fazt-sh/fazt/internal/services/wasm"
 	"github.com/fazt-sh/fazt/internal/storage"
+	"github.com/fazt-sh/fazt/internal/timeout"
+	"github.com/fazt-sh/fazt/internal/webpush"
 )
 
 // Executor executes worker JavaScript code with job context.
 type Executor struct {
-	db      *sql.DB
-	storage *storage.Storage
+	db          *sql.DB
+	storage     *storage.Storage
+	egressProxy *egress.EgressProxy
 }
 
 // NewExecutor creates a new worker executor.
@@ -25,37 +36,98 @@ func NewExecutor(db *sql.DB) *Executor {
 	}
 }
 
+// SetEgressProxy enables fazt.net.fetch() for worker code.
+func (e *Executor) SetEgressProxy(proxy *egress.EgressProxy) {
+	e.egressProxy = proxy
+}
+
 // Execute runs the worker code with job context injected.
 func (e *Executor) Execute(ctx context.Context, job *Job, code string) (interface{}, error) {
 	vm := goja.New()
 
+	// Bound recursion and child spawns. Bytes handed to a job aren't capped:
+	// a long job reading in batches would add up to its memory budget, which
+	// the pool already reserves when it admits the job.
+	limits := sandbox.Limits{MaxCallStack: sandbox.DefaultMaxCallStack, MaxSpawns: sandbox.DefaultMaxSpawns}
+	if job.Config.Daemon {
+		limits.MaxSpawns = 0
+	}
+	tracker := sandbox.New(limits)
+	tracker.Bind(vm)
+	defer tracker.Unbind()
+	ctx = sandbox.WithTracker(ctx, tracker)
+	ctx = storage.WithReadYourWrites(ctx)
+	ctx = chaos.WithApp(ctx, job.AppID)
+
 	// Inject console
 	injectConsole(vm, job)
 
 	// Inject sleep helper
 	InjectSleepHelper(vm)
 
+	// Inject require() so workers share modules with handlers; relative
+	// imports resolve against the worker file's directory
+	modules.Enable(vm, job.AppID, modules.FileLoader(e.db, job.AppID), path.Dir(job.Handler))
+
 	// Inject job context (job.id, job.data, job.progress(), etc.)
 	if err := InjectJobContext(vm, job); err != nil {
 		return nil, fmt.Errorf("failed to inject job context: %w", err)
 	}
 
-	// Inject storage namespace (fazt.storage.*)
+	// Inject app storage (fazt.app.*), with no user: jobs aren't requests
 	// Workers don't use a Budget since they have different timeout requirements
-	if err := storage.InjectStorageNamespace(vm, e.storage, job.AppID, ctx, nil); err != nil {
+	if err := storage.InjectAppNamespace(vm, e.db, storage.GetWriter(), job.AppID, "", ctx, nil); err != nil {
 		return nil, fmt.Errorf("failed to inject storage: %w", err)
 	}
 
+	// LEGACY_CODE: fazt.storage.*, for apps still on runtime v0, logging
+	// each binding they use to the job
+	if runtime := hosting.AppRuntimeFor(job.AppID); runtime == hosting.RuntimeV0 {
+		if err := storage.InjectStorageNamespace(vm, e.storage, job.AppID, ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to inject storage: %w", err)
+		}
+		storage.DeprecateStorageNamespace(vm, runtime, job.AddLog)
+	}
+
 	// Inject realtime namespace (fazt.realtime.*)
 	if err := hosting.InjectRealtimeNamespace(vm, job.AppID); err != nil {
 		return nil, fmt.Errorf("failed to inject realtime: %w", err)
 	}
 
+	// Inject mail namespace (fazt.app.mail.*)
+	if err := InjectMailNamespace(vm, e.db, job.AppID); err != nil {
+		return nil, fmt.Errorf("failed to inject mail: %w", err)
+	}
+
+	// Inject push namespace (fazt.app.push.*); jobs have no signed-in user
+	if err := webpush.InjectPushNamespace(vm, e.db, job.AppID, "", ctx); err != nil {
+		return nil, fmt.Errorf("failed to inject push: %w", err)
+	}
+
 	// Inject worker namespace for spawning child jobs (fazt.worker.*)
 	if err := InjectWorkerNamespace(vm, job.AppID, ctx); err != nil {
 		return nil, fmt.Errorf("failed to inject worker namespace: %w", err)
 	}
 
+	// Inject net namespace (fazt.net.fetch). Each call is bounded by the
+	// job's remaining time; jobs without a timeout (daemons) only get the
+	// per-call limit.
+	if e.egressProxy != nil {
+		cfg := timeout.DefaultConfig()
+		cfg.RequestTimeout = 365 * 24 * time.Hour
+		budget := timeout.NewBudget(ctx, cfg)
+		if err := egress.InjectNetNamespace(vm, e.egressProxy, job.AppID, ctx, budget); err != nil {
+			return nil, fmt.Errorf("failed to inject net namespace: %w", err)
+		}
+	}
+
+	// Inject wasm namespace (fazt.wasm.load). Modules close when the job ends.
+	wasmCtx, closeWasm := context.WithCancel(ctx)
+	defer closeWasm()
+	if err := wasm.InjectWasmNamespace(vm, wasmCtx, modules.FileLoader(e.db, job.AppID)); err != nil {
+		return nil, fmt.Errorf("failed to inject wasm namespace: %w", err)
+	}
+
 	// Set up interrupt on context cancellation
 	done := make(chan struct{})
 	go func() {
@@ -71,7 +143,8 @@ func (e *Executor) Execute(ctx context.Context, job *Job, code string) (interfac
 		vm.ClearInterrupt()
 	}()
 
-	// Wrap code in module pattern and execute
+	// Wrap code in module pattern and execute, compiled once per version
+	// of the worker file
 	wrappedCode := fmt.Sprintf(`
 (function() {
     var module = { exports: {} };
@@ -81,6 +154,10 @@ func (e *Executor) Execute(ctx context.Context, job *Job, code string) (interfac
     if (typeof module.exports === 'function') {
         return module.exports(job);
     }
+    // Same for an ES module's default export
+    if (typeof module.exports.default === 'function') {
+        return module.exports.default(job);
+    }
     // If there's a handler function, call it
     if (typeof handler === 'function') {
         return handler(job);
@@ -89,9 +166,20 @@ func (e *Executor) Execute(ctx context.Context, job *Job, code string) (interfac
 })()
 `, code)
 
-	value, err := vm.RunString(wrappedCode)
+	program, err := modules.CompileScript(job.AppID, job.Handler, wrappedCode)
+	var value goja.Value
+	if err == nil {
+		value, err = vm.RunProgram(program)
+	}
 	if err != nil {
-		// Check for interrupt
+		// Check for limit violations and interrupts
+		var limitErr *sandbox.LimitError
+		if errors.As(err, &limitErr) {
+			return nil, limitErr
+		}
+		if _, ok := err.(*goja.StackOverflowError); ok {
+			return nil, fmt.Errorf("maximum call stack size exceeded")
+		}
 		if interruptErr, ok := err.(*goja.InterruptedError); ok {
 			if job.IsCancelled() {
 				return nil, fmt.Errorf("job cancelled")
@@ -150,10 +238,12 @@ func injectConsole(vm *goja.Runtime, job *Job) {
 	vm.Set("console", console)
 }
 
-// SetupGlobalExecutor configures the global pool to use the executor.
-func SetupGlobalExecutor(db *sql.DB) {
+// SetupGlobalExecutor configures the global pool to use the executor and
+// returns it so optional bindings (e.g. egress) can be attached.
+func SetupGlobalExecutor(db *sql.DB) *Executor {
 	executor := NewExecutor(db)
 	SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
 		return executor.Execute(ctx, job, code)
 	})
+	return executor
 }
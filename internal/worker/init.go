@@ -6,11 +6,15 @@ import (
 	"sync"
 
 	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/events"
 )
 
 var (
 	globalPool *Pool
 	poolMu     sync.RWMutex
+
+	// stopTriggers unsubscribes the global pool from the event bus
+	stopTriggers func()
 )
 
 // Init initializes the global worker pool.
@@ -23,6 +27,7 @@ func Init(db *sql.DB) error {
 	}
 
 	globalPool = NewPool(db, DefaultPoolConfig())
+	stopTriggers = events.Subscribe(globalPool.trigger)
 
 	debug.Log("worker", "initialized global worker pool")
 	return nil
@@ -38,6 +43,7 @@ func InitWithConfig(db *sql.DB, cfg PoolConfig) error {
 	}
 
 	globalPool = NewPool(db, cfg)
+	stopTriggers = events.Subscribe(globalPool.trigger)
 
 	debug.Log("worker", "initialized global worker pool with custom config")
 	return nil
@@ -68,6 +74,16 @@ func SetListenerCountFunc(fn ListenerCountFunc) {
 	}
 }
 
+// SetHealthCheckFunc sets the function the global pool polls services'
+// health paths with.
+func SetHealthCheckFunc(fn HealthCheckFunc) {
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+	if globalPool != nil {
+		globalPool.SetHealthCheckFunc(fn)
+	}
+}
+
 // Shutdown gracefully shuts down the global worker pool.
 func Shutdown(ctx context.Context) error {
 	poolMu.Lock()
@@ -77,6 +93,7 @@ func Shutdown(ctx context.Context) error {
 		return nil
 	}
 
+	stopTriggers()
 	err := globalPool.Shutdown(ctx)
 	globalPool = nil
 	return err
@@ -146,6 +163,58 @@ func List(appID string, status *JobStatus, limit int) ([]*Job, error) {
 	return pool.List(appID, status, limit)
 }
 
+// SetAppLimit caps an app's concurrent jobs in the global pool; 0 removes
+// the cap.
+func SetAppLimit(appID string, limit int) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool != nil {
+		pool.SetAppLimit(appID, limit)
+	}
+}
+
+// DeadLetters returns dead-lettered jobs from the global pool.
+func DeadLetters(appID string, limit int) ([]DeadLetter, error) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+
+	return pool.DeadLetters(appID, limit)
+}
+
+// RetryDeadLetter replays a dead-lettered job in the global pool.
+func RetryDeadLetter(jobID string) (*Job, error) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+
+	return pool.RetryDeadLetter(jobID)
+}
+
+// SetQueue defines an app's queue in the global pool.
+func SetQueue(appID, name string, cfg QueueConfig) error {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return ErrPoolNotInitialized
+	}
+
+	pool.SetQueue(appID, name, cfg)
+	return nil
+}
+
 // Stats returns current pool statistics.
 func Stats() *PoolStats {
 	poolMu.RLock()
@@ -159,3 +228,43 @@ func Stats() *PoolStats {
 	stats := pool.Stats()
 	return &stats
 }
+
+// Services returns an app's declared services from the global pool.
+func Services(appID string) ([]ServiceInfo, error) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+
+	return pool.Services(appID)
+}
+
+// ServiceJob returns the job running one of an app's services in the global
+// pool, or the last one that ran it.
+func ServiceJob(appID, name string) (*Job, error) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+
+	return pool.ServiceJob(appID, name)
+}
+
+// RestartService restarts one of an app's services in the global pool.
+func RestartService(appID, name string) (*Job, error) {
+	poolMu.RLock()
+	pool := globalPool
+	poolMu.RUnlock()
+
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+
+	return pool.RestartService(appID, name)
+}
@@ -37,9 +37,13 @@ type JobConfig struct {
 	MaxAttempts int           `json:"max_attempts"`
 	RetryDelay  time.Duration `json:"retry_delay"`
 
-	// Priority: -1 (low), 0 (normal), 1 (high)
+	// Priority: -1 (low), 0 (normal), 1 (high). Orders jobs within a queue.
 	Priority int `json:"priority"`
 
+	// Queue is the app's named queue the job runs in, e.g. "emails" or
+	// "video" (default: DefaultQueue). See QueueConfig.
+	Queue string `json:"queue,omitempty"`
+
 	// UniqueKey prevents duplicate jobs
 	UniqueKey string `json:"unique_key,omitempty"`
 
@@ -49,6 +53,12 @@ type JobConfig struct {
 	// Idle timeout - stop if no listeners on IdleChannel for this duration
 	IdleTimeout *time.Duration `json:"idle_timeout,omitempty"`
 	IdleChannel string         `json:"idle_channel,omitempty"`
+
+	// Service is the manifest.json service the job runs ("" for spawned
+	// jobs), with its restart policy and health path. See services.go.
+	Service string `json:"service,omitempty"`
+	Restart string `json:"restart,omitempty"`
+	Health  string `json:"health,omitempty"`
 }
 
 // DefaultJobConfig returns sensible defaults.
@@ -92,6 +102,7 @@ type Job struct {
 	mu        sync.RWMutex
 	cancelled bool
 	cancelFn  func()
+	saveFn    func() // persists the job, e.g. after a checkpoint
 }
 
 // NewJob creates a new job with the given configuration.
@@ -132,6 +143,14 @@ func (j *Job) SetCancelFunc(fn func()) {
 	j.cancelFn = fn
 }
 
+// SetSaveFunc sets the function that persists the job. Checkpoints are
+// saved right away so a crash doesn't lose them.
+func (j *Job) SetSaveFunc(fn func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.saveFn = fn
+}
+
 // SetProgress updates the job progress (0.0 - 1.0).
 func (j *Job) SetProgress(p float64) {
 	j.mu.Lock()
@@ -152,21 +171,32 @@ func (j *Job) GetProgress() float64 {
 	return j.Progress
 }
 
-// AddLog appends a log entry.
+// Outcome returns the job's status, error, and start and finish times.
+func (j *Job) Outcome() (JobStatus, string, time.Time, time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.Status, j.Error, j.StartedAt, j.DoneAt
+}
+
+// AddLog appends a log entry. A service's entries are also streamed to
+// followers (see SubscribeServiceLogs).
 func (j *Job) AddLog(msg string) {
+	line := fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg)
 	j.mu.Lock()
-	defer j.mu.Unlock()
-	j.Logs = append(j.Logs, fmt.Sprintf("[%s] %s", time.Now().Format("15:04:05"), msg))
+	j.Logs = append(j.Logs, line)
 	// Keep only last 100 logs
 	if len(j.Logs) > 100 {
 		j.Logs = j.Logs[len(j.Logs)-100:]
 	}
+	j.mu.Unlock()
+
+	if j.Config.Service != "" {
+		publishServiceLog(j, line)
+	}
 }
 
 // SetCheckpoint saves checkpoint data for recovery.
 func (j *Job) SetCheckpoint(data interface{}) error {
-	j.mu.Lock()
-	defer j.mu.Unlock()
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		return err
@@ -175,7 +205,15 @@ func (j *Job) SetCheckpoint(data interface{}) error {
 	if len(bytes) > 1024*1024 {
 		return fmt.Errorf("checkpoint too large: %d bytes (max 1MB)", len(bytes))
 	}
+
+	j.mu.Lock()
 	j.Checkpoint = string(bytes)
+	save := j.saveFn
+	j.mu.Unlock()
+
+	if save != nil {
+		save()
+	}
 	return nil
 }
 
@@ -193,6 +231,65 @@ func (j *Job) GetCheckpoint() (map[string]interface{}, error) {
 	return data, nil
 }
 
+// JobInfo is a snapshot of a job for monitoring.
+type JobInfo struct {
+	ID           string          `json:"id"`
+	AppID        string          `json:"app_id"`
+	Handler      string          `json:"handler"`
+	Queue        string          `json:"queue"`
+	Status       JobStatus       `json:"status"`
+	Progress     int             `json:"progress"` // 0 - 100
+	Attempt      int             `json:"attempt"`
+	MaxAttempts  int             `json:"max_attempts"`
+	Daemon       bool            `json:"daemon"`
+	Service      string          `json:"service,omitempty"`
+	RestartCount int             `json:"restart_count,omitempty"`
+	Result       json.RawMessage `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Logs         []string        `json:"logs,omitempty"`
+	Checkpoint   json.RawMessage `json:"checkpoint,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+	StartedAt    *time.Time      `json:"started_at,omitempty"`
+	DoneAt       *time.Time      `json:"done_at,omitempty"`
+}
+
+// Info returns a snapshot of the job.
+func (j *Job) Info() JobInfo {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	info := JobInfo{
+		ID:           j.ID,
+		AppID:        j.AppID,
+		Handler:      j.Handler,
+		Queue:        queueName(j),
+		Status:       j.Status,
+		Progress:     int(j.Progress * 100),
+		Attempt:      j.Attempt,
+		MaxAttempts:  j.Config.MaxAttempts,
+		Daemon:       j.Config.Daemon,
+		Service:      j.Config.Service,
+		RestartCount: j.RestartCount,
+		Error:        j.Error,
+		Logs:         append([]string{}, j.Logs...),
+		CreatedAt:    j.CreatedAt,
+	}
+	if json.Valid([]byte(j.Result)) {
+		info.Result = json.RawMessage(j.Result)
+	}
+	if json.Valid([]byte(j.Checkpoint)) {
+		info.Checkpoint = json.RawMessage(j.Checkpoint)
+	}
+	if !j.StartedAt.IsZero() {
+		started := j.StartedAt
+		info.StartedAt = &started
+	}
+	if !j.DoneAt.IsZero() {
+		done := j.DoneAt
+		info.DoneAt = &done
+	}
+	return info
+}
+
 // MarkRunning transitions job to running state.
 func (j *Job) MarkRunning() {
 	j.mu.Lock()
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+// MailHandler is the built-in handler of the jobs sending apps' email
+const MailHandler = "fazt:mail"
+
+// mailRetryDelay is the wait between attempts at sending a message
+var mailRetryDelay = time.Minute
+
+// builtins are handlers that run server code instead of an app's file, by
+// name. Their names can't collide with app files, which have no scheme.
+var builtins = map[string]func(ctx context.Context, db *sql.DB, job *Job) (interface{}, error){
+	MailHandler: sendMail,
+}
+
+// sendMail makes one attempt at sending the message in the job's data
+func sendMail(ctx context.Context, db *sql.DB, job *Job) (interface{}, error) {
+	id, _ := job.Config.Data["id"].(string)
+	last := job.Attempt >= job.Config.MaxAttempts
+	if err := mailer.Deliver(ctx, db, id, last, mailer.ServerConfig()); err != nil {
+		return nil, err
+	}
+	m, err := mailer.Get(db, id)
+	if err != nil {
+		return nil, err
+	}
+	job.AddLog(fmt.Sprintf("Mail %s %s", id, m.Status))
+	return map[string]interface{}{"id": id, "status": m.Status}, nil
+}
+
+// SpawnMail queues the job sending a message from an app's outbox, tried
+// up to mailer.MaxAttempts times
+func SpawnMail(appID, id string) (*Job, error) {
+	pool := GetPool()
+	if pool == nil {
+		return nil, ErrPoolNotInitialized
+	}
+	return pool.spawnMail(appID, id)
+}
+
+func (p *Pool) spawnMail(appID, id string) (*Job, error) {
+	cfg := DefaultJobConfig()
+	timeout := 2 * time.Minute
+	cfg.Timeout = &timeout
+	cfg.MemoryBytes = 1024 * 1024
+	cfg.MaxAttempts = mailer.MaxAttempts
+	cfg.RetryDelay = mailRetryDelay
+	cfg.UniqueKey = id
+	cfg.Data = map[string]interface{}{"id": id}
+
+	job, err := p.Spawn(appID, MailHandler, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := mailer.SetJob(p.db, id, job.ID); err != nil {
+		debug.Log("worker", "failed to record job for mail %s: %v", id, err)
+	}
+	return job, nil
+}
+
+// ResumeMail queues the jobs for messages still waiting to be sent, such as
+// ones a restart interrupted.
+func ResumeMail() error {
+	pool := GetPool()
+	if pool == nil {
+		return ErrPoolNotInitialized
+	}
+	queued, err := mailer.Queued(pool.db)
+	if err != nil {
+		return err
+	}
+	for _, m := range queued {
+		if _, err := pool.spawnMail(m.AppID, m.ID); err != nil {
+			return err
+		}
+	}
+	if len(queued) > 0 {
+		debug.Log("worker", "resumed %d queued mail", len(queued))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+// InjectMailNamespace adds fazt.app.mail.* to a Goja VM, for handlers and
+// jobs. Mail goes out through the app's mail server, or the server's (fazt
+// server set-mail), from a job that retries it.
+func InjectMailNamespace(vm *goja.Runtime, db *sql.DB, appID string) error {
+	faztVal := vm.Get("fazt")
+	var fazt *goja.Object
+	if faztVal == nil || goja.IsUndefined(faztVal) {
+		fazt = vm.NewObject()
+		vm.Set("fazt", fazt)
+	} else {
+		fazt = faztVal.ToObject(vm)
+	}
+
+	appVal := fazt.Get("app")
+	var appObj *goja.Object
+	if appVal == nil || goja.IsUndefined(appVal) {
+		appObj = vm.NewObject()
+		fazt.Set("app", appObj)
+	} else {
+		appObj = appVal.ToObject(vm)
+	}
+
+	mailObj := vm.NewObject()
+
+	// fazt.app.mail.send({to, subject, html, text, replyTo}) - queues an
+	// email, returning {id, status}. Throws when the app is out of its daily
+	// limit or has no mail server.
+	mailObj.Set("send", func(call goja.FunctionCall) goja.Value {
+		arg := call.Argument(0)
+		if goja.IsUndefined(arg) || goja.IsNull(arg) {
+			panic(vm.NewGoError(fmt.Errorf("mail.send requires {to, subject, html}")))
+		}
+		opts := arg.ToObject(vm)
+
+		var msg mailer.Message
+		switch to := opts.Get("to").Export().(type) {
+		case string:
+			msg.To = []string{to}
+		case []interface{}:
+			for _, addr := range to {
+				msg.To = append(msg.To, fmt.Sprint(addr))
+			}
+		}
+		msg.Subject = optString(opts, "subject")
+		msg.HTML = optString(opts, "html")
+		msg.Text = optString(opts, "text")
+		msg.ReplyTo = optString(opts, "replyTo")
+
+		m, err := mailer.Queue(db, appID, msg, mailer.ServerConfig())
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if _, err := SpawnMail(appID, m.ID); err != nil {
+			mailer.Fail(db, m.ID, err)
+			panic(vm.NewGoError(fmt.Errorf("mail.send: %w", err)))
+		}
+		return vm.ToValue(map[string]interface{}{
+			"id":     m.ID,
+			"status": m.Status,
+		})
+	})
+
+	appObj.Set("mail", mailObj)
+	return nil
+}
+
+// optString reads a string option, "" when it's missing
+func optString(opts *goja.Object, name string) string {
+	v := opts.Get(name)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return ""
+	}
+	return v.String()
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/config"
+	"github.com/fazt-sh/fazt/internal/database/dbtest"
+	"github.com/fazt-sh/fazt/internal/mailer"
+)
+
+func TestMailJobRetries(t *testing.T) {
+	db := dbtest.Open(t)
+
+	old := mailRetryDelay
+	mailRetryDelay = 10 * time.Millisecond
+	defer func() { mailRetryDelay = old }()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	// Queued for a server that's gone by the time the job runs (the server's
+	// mail settings aren't loaded in tests), so every attempt fails
+	cfg := config.MailConfig{SMTP: config.SMTPConfig{Host: "127.0.0.1", Port: 1, From: "apps@example.com"}, DailyLimit: 10}
+	m, err := mailer.Queue(db, "app-1", mailer.Message{To: []string{"ada@example.com"}, Subject: "Hi", Text: "Hi"}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := pool.spawnMail("app-1", m.ID)
+	if err != nil {
+		t.Fatalf("spawnMail failed: %v", err)
+	}
+	if job.Handler != MailHandler || job.Config.MaxAttempts != mailer.MaxAttempts {
+		t.Errorf("Unexpected job: %+v", job)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := mailer.Get(db, m.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status == mailer.StatusFailed {
+			if got.Attempts != mailer.MaxAttempts || got.JobID != job.ID || got.Error == "" {
+				t.Errorf("Expected %d recorded attempts by job %s: %+v", mailer.MaxAttempts, job.ID, got)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the message to fail after its attempts: %+v", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
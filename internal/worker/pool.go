@@ -6,11 +6,13 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/storage"
 )
 
 // Default limits
@@ -31,6 +33,10 @@ type PoolConfig struct {
 	MaxQueueDepth       int   // Queued jobs per app
 	MemoryPoolBytes     int64 // Total memory for all workers
 	MaxDaemonsPerApp    int   // Max daemon workers per app
+
+	// Named queues, e.g. "emails" or "video", for every app. Jobs in queues
+	// not listed here run at normal priority up to MaxConcurrentPerApp.
+	Queues map[string]QueueConfig
 }
 
 // DefaultPoolConfig returns sensible defaults.
@@ -53,13 +59,21 @@ type Pool struct {
 	jobs   map[string]*Job
 	jobsMu sync.RWMutex
 
-	// Queue for pending jobs
-	queue chan *Job
+	// Pending jobs and per-app queues (see queue.go)
+	pending      []*Job
+	queues       map[string]QueueConfig // app queue definitions by queueKey
+	queueRunning map[string]int         // running jobs by queueKey
+	pendingMu    sync.Mutex
+	wake         chan struct{} // signalled when a job may be able to start
 
 	// Per-app tracking
 	appJobs   map[string]int // count of running jobs per app
+	appLimits map[string]int // concurrency caps below MaxConcurrentPerApp, e.g. for throttled apps
 	appJobsMu sync.RWMutex
 
+	// Serializes starting and stopping services (see services.go)
+	servicesMu sync.Mutex
+
 	// Resource budget
 	allocatedMemory int64
 	memoryMu        sync.RWMutex
@@ -75,6 +89,9 @@ type Pool struct {
 
 	// Listener count function (for idle timeout checking)
 	listenerCountFn ListenerCountFunc
+
+	// Health check function (for services with a health path)
+	healthCheckFn HealthCheckFunc
 }
 
 // JobExecutor executes a job and returns the result.
@@ -83,6 +100,10 @@ type JobExecutor func(ctx context.Context, job *Job, code string) (interface{},
 // ListenerCountFunc returns the number of WebSocket listeners for a channel.
 type ListenerCountFunc func(appID, channel string) int
 
+// HealthCheckFunc requests path from an app and returns the response's
+// status code, 0 when it didn't answer in time.
+type HealthCheckFunc func(appID, path string) int
+
 // NewPool creates a new worker pool.
 func NewPool(db *sql.DB, cfg PoolConfig) *Pool {
 	if cfg.MaxConcurrentTotal <= 0 {
@@ -102,12 +123,15 @@ func NewPool(db *sql.DB, cfg PoolConfig) *Pool {
 	}
 
 	p := &Pool{
-		config:  cfg,
-		db:      db,
-		jobs:    make(map[string]*Job),
-		appJobs: make(map[string]int),
-		queue:   make(chan *Job, cfg.MaxQueueDepth*10), // Allow some queue depth
-		done:    make(chan struct{}),
+		config:       cfg,
+		db:           db,
+		jobs:         make(map[string]*Job),
+		queues:       make(map[string]QueueConfig),
+		queueRunning: make(map[string]int),
+		wake:         make(chan struct{}, cfg.MaxConcurrentTotal),
+		appJobs:      make(map[string]int),
+		appLimits:    make(map[string]int),
+		done:         make(chan struct{}),
 	}
 
 	// Start worker goroutines
@@ -132,26 +156,42 @@ func (p *Pool) SetListenerCountFunc(fn ListenerCountFunc) {
 	p.listenerCountFn = fn
 }
 
-// worker is a goroutine that processes jobs from the queue.
+// SetHealthCheckFunc sets the function services' health paths are polled with.
+func (p *Pool) SetHealthCheckFunc(fn HealthCheckFunc) {
+	p.healthCheckFn = fn
+}
+
+// worker is a goroutine that runs pending jobs as they become startable.
 func (p *Pool) worker(id int) {
 	defer p.wg.Done()
 
 	for {
 		select {
-		case job := <-p.queue:
-			if job == nil {
-				continue
-			}
-			p.executeJob(job)
-
 		case <-p.done:
 			return
+		default:
 		}
+
+		job := p.dequeue()
+		if job == nil {
+			select {
+			case <-p.wake:
+			case <-p.done:
+				return
+			}
+			continue
+		}
+		p.executeJob(job)
 	}
 }
 
 // Spawn creates and queues a new job.
 func (p *Pool) Spawn(appID, handler string, cfg JobConfig) (*Job, error) {
+	return p.spawn(appID, handler, cfg, "")
+}
+
+// spawn creates and queues a job, starting from a checkpoint if given.
+func (p *Pool) spawn(appID, handler string, cfg JobConfig, checkpoint string) (*Job, error) {
 	p.mu.Lock()
 	if p.closed {
 		p.mu.Unlock()
@@ -175,7 +215,7 @@ func (p *Pool) Spawn(appID, handler string, cfg JobConfig) (*Job, error) {
 		// Check queue depth
 		queuedCount := p.queuedCountForApp(appID)
 		if queuedCount >= p.config.MaxQueueDepth {
-			return nil, fmt.Errorf("queue full for app %s", appID)
+			return nil, fmt.Errorf("%w for app %s", ErrQueueFull, appID)
 		}
 	}
 
@@ -183,7 +223,7 @@ func (p *Pool) Spawn(appID, handler string, cfg JobConfig) (*Job, error) {
 	if cfg.Daemon {
 		daemonCount := p.daemonCountForApp(appID)
 		if daemonCount >= p.config.MaxDaemonsPerApp {
-			return nil, fmt.Errorf("max daemons (%d) reached for app %s",
+			return nil, fmt.Errorf("%w (%d) for app %s", ErrDaemonLimitReached,
 				p.config.MaxDaemonsPerApp, appID)
 		}
 	}
@@ -197,6 +237,7 @@ func (p *Pool) Spawn(appID, handler string, cfg JobConfig) (*Job, error) {
 	id := generateJobID()
 
 	job := NewJob(id, appID, handler, cfg)
+	job.Checkpoint = checkpoint
 
 	// Persist to database
 	if err := p.persistJob(job); err != nil {
@@ -209,44 +250,38 @@ func (p *Pool) Spawn(appID, handler string, cfg JobConfig) (*Job, error) {
 	p.jobsMu.Unlock()
 
 	// Queue for execution
-	select {
-	case p.queue <- job:
-		debug.Log("worker", "job %s queued: handler=%s app=%s", job.ID, handler, appID)
-	default:
-		// Queue is full, but we already checked, so this shouldn't happen
-		return nil, fmt.Errorf("failed to queue job")
-	}
+	p.enqueue(job)
+	debug.Log("worker", "job %s queued: handler=%s app=%s queue=%s", job.ID, handler, appID, queueName(job))
 
 	return job, nil
 }
 
-// executeJob runs a single job.
+// executeJob runs a single job, holding the queue slot dequeue took.
 func (p *Pool) executeJob(job *Job) {
-	// Try to allocate memory
+	defer p.releaseQueueSlot(job)
+
+	// Requeue if the app is at its concurrency cap or the pool is full
+	// (wait for a slot or memory)
+	if !p.acquireAppSlot(job.AppID) {
+		p.requeue(job)
+		return
+	}
 	if !p.allocateMemory(job.Config.MemoryBytes) {
-		// Requeue if pool full (wait for memory)
-		time.Sleep(100 * time.Millisecond)
-		select {
-		case p.queue <- job:
-		case <-p.done:
-			return
-		}
+		p.releaseAppSlot(job.AppID)
+		p.requeue(job)
 		return
 	}
 	defer p.releaseMemory(job.Config.MemoryBytes)
+	defer p.releaseAppSlot(job.AppID)
 
-	// Increment app job count
-	p.appJobsMu.Lock()
-	p.appJobs[job.AppID]++
-	p.appJobsMu.Unlock()
-	defer func() {
-		p.appJobsMu.Lock()
-		p.appJobs[job.AppID]--
-		if p.appJobs[job.AppID] <= 0 {
-			delete(p.appJobs, job.AppID)
-		}
-		p.appJobsMu.Unlock()
-	}()
+	// Cancelled while queued
+	if job.IsCancelled() {
+		job.AddLog("Job cancelled")
+		job.MarkCancelled()
+		p.updateJobStatus(job)
+		p.handleJobComplete(job)
+		return
+	}
 
 	// Mark as running
 	job.MarkRunning()
@@ -265,6 +300,7 @@ func (p *Pool) executeJob(job *Job) {
 
 	// Allow job cancellation
 	job.SetCancelFunc(cancel)
+	job.SetSaveFunc(func() { p.updateJobStatus(job) })
 
 	// Start idle watcher if configured
 	var idleReason string
@@ -272,26 +308,39 @@ func (p *Pool) executeJob(job *Job) {
 		go p.watchIdleTimeout(ctx, cancel, job, &idleReason)
 	}
 
+	// Poll a service's health path, restarting it when it stops answering
+	var unhealthyReason string
+	if job.Config.Health != "" {
+		go p.watchHealth(ctx, cancel, job, &unhealthyReason)
+	}
+
 	// Execute the job
 	debug.Log("worker", "job %s started: handler=%s", job.ID, job.Handler)
 
-	if p.executor == nil {
-		job.MarkFailed(fmt.Errorf("no executor configured"))
-		p.updateJobStatus(job)
-		return
-	}
+	var result interface{}
+	var err error
+	if builtin, ok := builtins[job.Handler]; ok {
+		// Server code run on the app's behalf, e.g. sending its email
+		result, err = builtin(ctx, p.db, job)
+	} else {
+		if p.executor == nil {
+			job.MarkFailed(fmt.Errorf("no executor configured"))
+			p.updateJobStatus(job)
+			return
+		}
 
-	// Load the handler code from VFS
-	code, err := p.loadHandlerCode(job.AppID, job.Handler)
-	if err != nil {
-		job.MarkFailed(fmt.Errorf("failed to load handler: %w", err))
-		p.updateJobStatus(job)
-		p.handleJobComplete(job)
-		return
-	}
+		// Load the handler code from VFS
+		code, loadErr := p.loadHandlerCode(job.AppID, job.Handler)
+		if loadErr != nil {
+			job.MarkFailed(fmt.Errorf("failed to load handler: %w", loadErr))
+			p.updateJobStatus(job)
+			p.handleJobComplete(job)
+			return
+		}
 
-	// Execute
-	result, err := p.executor(ctx, job, code)
+		// Execute
+		result, err = p.executor(ctx, job, code)
+	}
 
 	// Handle result
 	if err != nil {
@@ -304,6 +353,10 @@ func (p *Pool) executeJob(job *Job) {
 			job.MarkDone(map[string]interface{}{"reason": "idle_timeout"})
 			// Disable daemon restart for idle stop
 			job.Config.Daemon = false
+		} else if unhealthyReason != "" {
+			// Stopped by the health watcher: fails, so the service restarts
+			job.AddLog(unhealthyReason)
+			job.MarkFailed(errors.New(unhealthyReason))
 		} else if ctx.Err() == context.Canceled || job.IsCancelled() {
 			job.AddLog("Job cancelled")
 			job.MarkCancelled()
@@ -338,17 +391,21 @@ func (p *Pool) handleJobComplete(job *Job) {
 
 			// Delay before retry
 			time.AfterFunc(job.Config.RetryDelay, func() {
-				select {
-				case p.queue <- job:
-				case <-p.done:
-				}
+				p.enqueue(job)
 			})
 			shouldRemove = false
-		} else if job.Config.Daemon {
+		} else if job.Config.Daemon && job.Config.Restart != RestartNever {
 			// Daemon restart with backoff
 			p.scheduleDaemonRestart(job)
 			shouldRemove = false
+		} else {
+			// Out of attempts: keep it for inspection and replay
+			p.deadLetter(job)
 		}
+	} else if job.Status == StatusDone && job.Config.Daemon && job.Config.Restart == RestartAlways {
+		// Services that should always run are restarted after exiting too
+		p.scheduleDaemonRestart(job)
+		shouldRemove = false
 	}
 
 	if shouldRemove {
@@ -389,10 +446,7 @@ func (p *Pool) scheduleDaemonRestart(job *Job) {
 		}
 		p.mu.Unlock()
 
-		select {
-		case p.queue <- job:
-		case <-p.done:
-		}
+		p.enqueue(job)
 	})
 }
 
@@ -511,6 +565,15 @@ func (p *Pool) List(appID string, status *JobStatus, limit int) ([]*Job, error)
 		jobs = append(jobs, job)
 	}
 
+	// Active jobs have progress and logs not yet persisted
+	p.jobsMu.RLock()
+	for i, job := range jobs {
+		if live, ok := p.jobs[job.ID]; ok {
+			jobs[i] = live
+		}
+	}
+	p.jobsMu.RUnlock()
+
 	return jobs, nil
 }
 
@@ -621,12 +684,9 @@ func (p *Pool) RestoreDaemons() error {
 		p.jobs[job.ID] = job
 		p.jobsMu.Unlock()
 
-		select {
-		case p.queue <- job:
-			restored++
-			debug.Log("worker", "restored daemon %s: handler=%s", job.ID, job.Handler)
-		default:
-		}
+		p.enqueue(job)
+		restored++
+		debug.Log("worker", "restored daemon %s: handler=%s", job.ID, job.Handler)
 	}
 
 	if restored > 0 {
@@ -661,6 +721,7 @@ func (p *Pool) Stats() PoolStats {
 		AllocatedMemory: allocated,
 		PoolMemory:      p.config.MemoryPoolBytes,
 		MemoryUsedPct:   float64(allocated) / float64(p.config.MemoryPoolBytes),
+		Queues:          p.queueStats(),
 	}
 }
 
@@ -672,10 +733,53 @@ type PoolStats struct {
 	AllocatedMemory int64   `json:"allocated_memory"`
 	PoolMemory      int64   `json:"pool_memory"`
 	MemoryUsedPct   float64 `json:"memory_used_pct"`
+
+	// Queues lists app queues with jobs or definitions of their own
+	Queues []QueueStats `json:"queues"`
+}
+
+// SetAppLimit caps how many of an app's jobs run at once, below
+// MaxConcurrentPerApp. Jobs over the cap wait in the queue. A limit of 0
+// removes the cap.
+func (p *Pool) SetAppLimit(appID string, limit int) {
+	p.appJobsMu.Lock()
+	defer p.appJobsMu.Unlock()
+	if limit <= 0 {
+		delete(p.appLimits, appID)
+		return
+	}
+	p.appLimits[appID] = limit
 }
 
 // Helper functions
 
+func (p *Pool) acquireAppSlot(appID string) bool {
+	p.appJobsMu.Lock()
+	defer p.appJobsMu.Unlock()
+
+	if limit, ok := p.appLimits[appID]; ok && p.appJobs[appID] >= limit {
+		return false
+	}
+	p.appJobs[appID]++
+	return true
+}
+
+func (p *Pool) releaseAppSlot(appID string) {
+	p.appJobsMu.Lock()
+	defer p.appJobsMu.Unlock()
+	p.appJobs[appID]--
+	if p.appJobs[appID] <= 0 {
+		delete(p.appJobs, appID)
+	}
+}
+
+// requeue puts back a job that couldn't start for lack of an app slot or
+// memory, after a pause so workers don't spin on it.
+func (p *Pool) requeue(job *Job) {
+	time.Sleep(100 * time.Millisecond)
+	p.enqueue(job)
+}
+
 func (p *Pool) allocateMemory(bytes int64) bool {
 	p.memoryMu.Lock()
 	defer p.memoryMu.Unlock()
@@ -742,41 +846,47 @@ func (p *Pool) persistJob(job *Job) error {
 	configJSON, _ := json.Marshal(job.Config)
 	logsJSON, _ := json.Marshal(job.Logs)
 
-	_, err := p.db.Exec(`
-		INSERT INTO worker_jobs (
-			id, app_id, handler, status, config, progress,
-			result, error, logs, checkpoint, attempt, restart_count,
-			daemon_backoff_ms, created_at, started_at, done_at, last_healthy_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`,
-		job.ID, job.AppID, job.Handler, string(job.Status), string(configJSON),
-		job.Progress, nullString(job.Result), nullString(job.Error),
-		string(logsJSON), nullString(job.Checkpoint), job.Attempt,
-		job.RestartCount, int64(job.DaemonBackoff/time.Millisecond),
-		nullTime(job.CreatedAt), nullTime(job.StartedAt),
-		nullTime(job.DoneAt), nullTime(job.LastHealthyAt),
-	)
-	return err
+	// Job writes are small and frequent: let the write queue batch them
+	return storage.QueueWriteTx(context.Background(), p.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO worker_jobs (
+				id, app_id, handler, status, config, progress,
+				result, error, logs, checkpoint, attempt, restart_count,
+				daemon_backoff_ms, created_at, started_at, done_at, last_healthy_at
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			job.ID, job.AppID, job.Handler, string(job.Status), string(configJSON),
+			job.Progress, nullString(job.Result), nullString(job.Error),
+			string(logsJSON), nullString(job.Checkpoint), job.Attempt,
+			job.RestartCount, int64(job.DaemonBackoff/time.Millisecond),
+			nullTime(job.CreatedAt), nullTime(job.StartedAt),
+			nullTime(job.DoneAt), nullTime(job.LastHealthyAt),
+		)
+		return err
+	})
 }
 
 func (p *Pool) updateJobStatus(job *Job) {
 	configJSON, _ := json.Marshal(job.Config)
 	logsJSON, _ := json.Marshal(job.Logs)
 
-	p.db.Exec(`
-		UPDATE worker_jobs SET
-			status = ?, config = ?, progress = ?, result = ?, error = ?,
-			logs = ?, checkpoint = ?, attempt = ?, restart_count = ?,
-			daemon_backoff_ms = ?, started_at = ?, done_at = ?, last_healthy_at = ?
-		WHERE id = ?
-	`,
-		string(job.Status), string(configJSON), job.Progress,
-		nullString(job.Result), nullString(job.Error),
-		string(logsJSON), nullString(job.Checkpoint), job.Attempt,
-		job.RestartCount, int64(job.DaemonBackoff/time.Millisecond),
-		nullTime(job.StartedAt), nullTime(job.DoneAt), nullTime(job.LastHealthyAt),
-		job.ID,
-	)
+	storage.QueueWriteTx(context.Background(), p.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE worker_jobs SET
+				status = ?, config = ?, progress = ?, result = ?, error = ?,
+				logs = ?, checkpoint = ?, attempt = ?, restart_count = ?,
+				daemon_backoff_ms = ?, started_at = ?, done_at = ?, last_healthy_at = ?
+			WHERE id = ?
+		`,
+			string(job.Status), string(configJSON), job.Progress,
+			nullString(job.Result), nullString(job.Error),
+			string(logsJSON), nullString(job.Checkpoint), job.Attempt,
+			job.RestartCount, int64(job.DaemonBackoff/time.Millisecond),
+			nullTime(job.StartedAt), nullTime(job.DoneAt), nullTime(job.LastHealthyAt),
+			job.ID,
+		)
+		return err
+	})
 }
 
 func (p *Pool) loadJob(id string) (*Job, error) {
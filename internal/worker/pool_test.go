@@ -3,6 +3,7 @@ package worker
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -48,6 +49,26 @@ func testDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to create worker_jobs table: %v", err)
 	}
 
+	_, err = db.Exec(`
+		CREATE TABLE dead_letter (
+			job_id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			handler TEXT NOT NULL,
+			config TEXT DEFAULT '{}',
+			error TEXT,
+			logs TEXT DEFAULT '[]',
+			checkpoint TEXT,
+			attempts INTEGER DEFAULT 1,
+			created_at INTEGER,
+			failed_at INTEGER NOT NULL,
+			retried_at INTEGER,
+			retry_job_id TEXT
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create dead_letter table: %v", err)
+	}
+
 	// Create files table for handler loading
 	_, err = db.Exec(`
 		CREATE TABLE files (
@@ -352,3 +373,124 @@ func TestPoolList(t *testing.T) {
 		t.Errorf("Jobs count = %d, want 2", len(jobs))
 	}
 }
+
+func TestPoolCheckpointAndProgress(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	started := make(chan struct{})
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		job.SetProgress(0.4)
+		job.AddLog("halfway")
+		if err := job.SetCheckpoint(map[string]interface{}{"cursor": 5}); err != nil {
+			return nil, err
+		}
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`,
+		"app-1", "workers/test.js", "return true;")
+	job, _ := pool.Spawn("app-1", "workers/test.js", DefaultJobConfig())
+	<-started
+
+	// Checkpoints are persisted right away, for crash recovery
+	var checkpoint sql.NullString
+	for i := 0; i < 20 && !checkpoint.Valid; i++ {
+		db.QueryRow(`SELECT checkpoint FROM worker_jobs WHERE id = ?`, job.ID).Scan(&checkpoint)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if checkpoint.String != `{"cursor":5}` {
+		t.Errorf("Checkpoint = %q, want it persisted", checkpoint.String)
+	}
+
+	// Listing shows live progress and logs of running jobs
+	jobs, err := pool.List("app-1", nil, 10)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("List = %v, %v", jobs, err)
+	}
+	info := jobs[0].Info()
+	if info.Status != StatusRunning || info.Progress != 40 || len(info.Logs) != 1 || string(info.Checkpoint) != `{"cursor":5}` {
+		t.Errorf("Unexpected job info: %+v", info)
+	}
+
+	pool.Cancel(job.ID)
+}
+
+func TestPoolDeadLetter(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	checkpoints := make(chan string, 2)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		checkpoints <- job.Checkpoint
+		job.AddLog("fetching page 3")
+		job.SetCheckpoint(map[string]interface{}{"page": 3})
+		return nil, errors.New("upstream timed out")
+	})
+
+	db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`,
+		"app-1", "workers/test.js", "return true;")
+	cfg := DefaultJobConfig()
+	cfg.MaxAttempts = 1
+	cfg.Data = map[string]interface{}{"feed": "news"}
+	job, _ := pool.Spawn("app-1", "workers/test.js", cfg)
+	<-checkpoints
+
+	// The failed job is kept with its error, logs (the app's and the error
+	// line) and checkpoint
+	var dead *DeadLetter
+	var err error
+	for i := 0; i < 50; i++ {
+		if dead, err = pool.DeadLetter(job.ID); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected the job dead-lettered: %v", err)
+	}
+	if dead.Error != "upstream timed out" || len(dead.Logs) != 2 || string(dead.Checkpoint) != `{"page":3}` {
+		t.Errorf("Unexpected dead letter: %+v", dead)
+	}
+	if dead.Data["feed"] != "news" || dead.Queue != DefaultQueue {
+		t.Errorf("Expected the job's options kept, got %+v", dead)
+	}
+
+	letters, err := pool.DeadLetters("app-1", 10)
+	if err != nil || len(letters) != 1 {
+		t.Fatalf("DeadLetters = %v, %v", letters, err)
+	}
+	if letters, _ := pool.DeadLetters("app-2", 10); len(letters) != 0 {
+		t.Errorf("Expected no dead letters for another app, got %v", letters)
+	}
+
+	// A retry starts a new job from the last checkpoint, once
+	retry, err := pool.RetryDeadLetter(job.ID)
+	if err != nil {
+		t.Fatalf("RetryDeadLetter error: %v", err)
+	}
+	if retry.ID == job.ID || retry.Config.Data["feed"] != "news" {
+		t.Errorf("Unexpected retry job: %+v", retry)
+	}
+	if checkpoint := <-checkpoints; checkpoint != `{"page":3}` {
+		t.Errorf("Retry started from checkpoint %q, want the dead letter's", checkpoint)
+	}
+	if _, err := pool.RetryDeadLetter(job.ID); !errors.Is(err, ErrAlreadyRetried) {
+		t.Errorf("Expected a second retry refused, got %v", err)
+	}
+	if dead, _ := pool.DeadLetter(job.ID); dead.RetryJobID != retry.ID || dead.RetriedAt == nil {
+		t.Errorf("Expected the retry recorded, got %+v", dead)
+	}
+
+	if _, err := pool.RetryDeadLetter("job_missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultQueue is the queue jobs run in when JobConfig.Queue is empty.
+const DefaultQueue = "default"
+
+// QueueConfig configures a named job queue. Each app gets its own instance
+// of a queue: concurrency limits the app's jobs in it, not all apps'.
+type QueueConfig struct {
+	Concurrency int `json:"concurrency"` // Jobs running at once (0 = MaxConcurrentPerApp)
+	Priority    int `json:"priority"`    // Higher queues start first
+}
+
+// QueueStats describes one app's queue.
+type QueueStats struct {
+	AppID       string `json:"app_id"`
+	Name        string `json:"name"`
+	Priority    int    `json:"priority"`
+	Concurrency int    `json:"concurrency"`
+	Running     int    `json:"running"`
+	Queued      int    `json:"queued"`
+}
+
+// SetQueue defines an app's queue. Pool-wide definitions (PoolConfig.Queues)
+// apply to apps that haven't defined the queue themselves.
+func (p *Pool) SetQueue(appID, name string, cfg QueueConfig) {
+	p.pendingMu.Lock()
+	p.queues[queueKey(appID, name)] = cfg
+	p.pendingMu.Unlock()
+	p.signal()
+}
+
+func queueName(job *Job) string {
+	if job.Config.Queue == "" {
+		return DefaultQueue
+	}
+	return job.Config.Queue
+}
+
+func queueKey(appID, name string) string {
+	if name == "" {
+		name = DefaultQueue
+	}
+	return appID + "/" + name
+}
+
+// queueConfig returns an app queue's settings. pendingMu must be held.
+func (p *Pool) queueConfig(appID, name string) QueueConfig {
+	cfg, ok := p.queues[queueKey(appID, name)]
+	if !ok {
+		cfg = p.config.Queues[name]
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = p.config.MaxConcurrentPerApp
+	}
+	return cfg
+}
+
+// enqueue adds a job to the pending list and wakes a worker.
+func (p *Pool) enqueue(job *Job) {
+	p.pendingMu.Lock()
+	p.pending = append(p.pending, job)
+	p.pendingMu.Unlock()
+	p.signal()
+}
+
+// dequeue takes the next job to run: from the highest priority queue with a
+// free slot, then by job priority, oldest first. The job holds its queue slot
+// until releaseQueueSlot. Returns nil if no job can start.
+func (p *Pool) dequeue() *Job {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	best := -1
+	var bestQueue QueueConfig
+	for i, job := range p.pending {
+		name := queueName(job)
+		cfg := p.queueConfig(job.AppID, name)
+		if p.queueRunning[queueKey(job.AppID, name)] >= cfg.Concurrency {
+			continue
+		}
+		if best >= 0 && (cfg.Priority < bestQueue.Priority ||
+			(cfg.Priority == bestQueue.Priority && job.Config.Priority <= p.pending[best].Config.Priority)) {
+			continue
+		}
+		best, bestQueue = i, cfg
+	}
+	if best < 0 {
+		return nil
+	}
+
+	job := p.pending[best]
+	p.pending = append(p.pending[:best], p.pending[best+1:]...)
+	p.queueRunning[queueKey(job.AppID, queueName(job))]++
+	return job
+}
+
+// releaseQueueSlot frees the slot a dequeued job held, which may let a
+// waiting job in the same queue start.
+func (p *Pool) releaseQueueSlot(job *Job) {
+	key := queueKey(job.AppID, queueName(job))
+	p.pendingMu.Lock()
+	p.queueRunning[key]--
+	if p.queueRunning[key] <= 0 {
+		delete(p.queueRunning, key)
+	}
+	p.pendingMu.Unlock()
+	p.signal()
+}
+
+// signal wakes an idle worker, if any.
+func (p *Pool) signal() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// queueStats returns each app queue with running or queued jobs, or a
+// definition of its own.
+func (p *Pool) queueStats() []QueueStats {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+
+	byKey := make(map[string]*QueueStats)
+	get := func(key string) *QueueStats {
+		if s, ok := byKey[key]; ok {
+			return s
+		}
+		appID, name, _ := strings.Cut(key, "/")
+		cfg := p.queueConfig(appID, name)
+		s := &QueueStats{AppID: appID, Name: name, Priority: cfg.Priority, Concurrency: cfg.Concurrency}
+		byKey[key] = s
+		return s
+	}
+	for key := range p.queues {
+		get(key)
+	}
+	for key, n := range p.queueRunning {
+		get(key).Running = n
+	}
+	for _, job := range p.pending {
+		get(queueKey(job.AppID, queueName(job))).Queued++
+	}
+
+	stats := make([]QueueStats, 0, len(byKey))
+	for _, s := range byKey {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].AppID != stats[j].AppID {
+			return stats[i].AppID < stats[j].AppID
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	"testing"
+)
+
+// newQueueTestPool returns a pool without workers, so tests drive dequeue
+func newQueueTestPool(cfg PoolConfig) *Pool {
+	return &Pool{
+		config:       cfg,
+		jobs:         make(map[string]*Job),
+		queues:       make(map[string]QueueConfig),
+		queueRunning: make(map[string]int),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+func queuedJob(id, appID, queue string, priority int) *Job {
+	cfg := DefaultJobConfig()
+	cfg.Queue = queue
+	cfg.Priority = priority
+	return NewJob(id, appID, "workers/"+id+".js", cfg)
+}
+
+func TestQueuePriority(t *testing.T) {
+	pool := newQueueTestPool(DefaultPoolConfig())
+	pool.SetQueue("app-1", "thumbnails", QueueConfig{Concurrency: 2, Priority: -1})
+	pool.SetQueue("app-1", "webhooks", QueueConfig{Priority: 1})
+
+	// A flood of thumbnails, then webhooks and a normal job
+	for _, id := range []string{"thumb-1", "thumb-2", "thumb-3", "thumb-4"} {
+		pool.enqueue(queuedJob(id, "app-1", "thumbnails", 0))
+	}
+	pool.enqueue(queuedJob("plain", "app-1", "", 0))
+	pool.enqueue(queuedJob("hook-1", "app-1", "webhooks", 0))
+	pool.enqueue(queuedJob("hook-2", "app-1", "webhooks", 1))
+
+	var order []string
+	for job := pool.dequeue(); job != nil; job = pool.dequeue() {
+		order = append(order, job.ID)
+	}
+
+	// Webhooks first (higher job priority first), then the default queue,
+	// then thumbnails up to the queue's concurrency
+	want := []string{"hook-2", "hook-1", "plain", "thumb-1", "thumb-2"}
+	if len(order) != len(want) {
+		t.Fatalf("dequeue order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("dequeue order = %v, want %v", order, want)
+		}
+	}
+
+	// Finishing a thumbnail frees a slot for the next one
+	pool.releaseQueueSlot(&Job{AppID: "app-1", Config: JobConfig{Queue: "thumbnails"}})
+	if job := pool.dequeue(); job == nil || job.ID != "thumb-3" {
+		t.Fatalf("Expected thumb-3 after a slot was freed, got %v", job)
+	}
+	if job := pool.dequeue(); job != nil {
+		t.Fatalf("Expected thumbnails capped at 2 running, got %s", job.ID)
+	}
+}
+
+func TestQueueStats(t *testing.T) {
+	cfg := DefaultPoolConfig()
+	cfg.Queues = map[string]QueueConfig{"emails": {Concurrency: 1, Priority: 1}}
+	pool := newQueueTestPool(cfg)
+
+	// Pool-wide definitions apply to every app, each with its own slots
+	pool.enqueue(queuedJob("a-1", "app-a", "emails", 0))
+	pool.enqueue(queuedJob("a-2", "app-a", "emails", 0))
+	pool.enqueue(queuedJob("b-1", "app-b", "emails", 0))
+	pool.dequeue()
+	pool.dequeue()
+
+	stats := pool.queueStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 queues, got %+v", stats)
+	}
+	a, b := stats[0], stats[1]
+	if a.AppID != "app-a" || a.Name != "emails" || a.Running != 1 || a.Queued != 1 || a.Concurrency != 1 || a.Priority != 1 {
+		t.Errorf("Unexpected app-a stats: %+v", a)
+	}
+	if b.AppID != "app-b" || b.Running != 1 || b.Queued != 0 {
+		t.Errorf("Unexpected app-b stats: %+v", b)
+	}
+}
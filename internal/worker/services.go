@@ -0,0 +1,331 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// Restart policies of a manifest.json service
+const (
+	RestartAlways    = "always"     // Restart after exiting or failing (default)
+	RestartOnFailure = "on-failure" // Restart after failing, like a spawned daemon
+	RestartNever     = "never"      // Run once per deploy
+)
+
+// healthFailures is how many health checks in a row a service may fail
+// before it's restarted
+const healthFailures = 3
+
+// healthInterval is how often a service's health path is polled (shortened
+// in tests)
+var healthInterval = 30 * time.Second
+
+// appServices looks up an app's declared services (replaced in tests)
+var appServices = hosting.AppServices
+
+// ServiceInfo is a service an app declares and the job running it.
+type ServiceInfo struct {
+	Name         string     `json:"name"`
+	Handler      string     `json:"handler"`
+	Restart      string     `json:"restart"`
+	Health       string     `json:"health,omitempty"`
+	Status       string     `json:"status"` // The job's status, or "stopped" before it first runs
+	JobID        string     `json:"job_id,omitempty"`
+	RestartCount int        `json:"restart_count"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	HealthyAt    *time.Time `json:"healthy_at,omitempty"` // Last passed health check or clean exit
+	Error        string     `json:"error,omitempty"`
+}
+
+// serviceConfig is the job config a declared service runs with: a daemon
+// without a timeout, restarted per its policy
+func serviceConfig(name string, svc hosting.AppService) (JobConfig, error) {
+	cfg := DefaultJobConfig()
+	if svc.Handler == "" {
+		return cfg, fmt.Errorf("%w: %q has no handler", ErrInvalidService, name)
+	}
+	cfg.Timeout = nil
+	cfg.Daemon = true
+	cfg.Service = name
+
+	cfg.Restart = svc.Restart
+	switch cfg.Restart {
+	case "":
+		cfg.Restart = RestartAlways
+	case RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		return cfg, fmt.Errorf("%w: %q restart must be always, on-failure or never", ErrInvalidService, name)
+	}
+
+	if svc.Health != "" && !validHealthPath(svc.Health) {
+		return cfg, fmt.Errorf("%w: %q health must be a path like /api/health", ErrInvalidService, name)
+	}
+	cfg.Health = svc.Health
+
+	if svc.Memory != "" {
+		mem, err := ParseMemory(svc.Memory)
+		if err != nil {
+			return cfg, fmt.Errorf("%w: %q memory must be a size like 64MB", ErrInvalidService, name)
+		}
+		cfg.MemoryBytes = mem
+	}
+	return cfg, nil
+}
+
+// ValidateService checks a service declaration, as fazt app validate does
+// before a deploy.
+func ValidateService(name string, svc hosting.AppService) error {
+	_, err := serviceConfig(name, svc)
+	return err
+}
+
+// validHealthPath reports whether path can be requested from the app as is.
+// Request URIs never carry a host, so protocol-relative paths are refused
+// by hand.
+func validHealthPath(path string) bool {
+	_, err := url.ParseRequestURI(path)
+	return err == nil && strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.ContainsAny(path, " \t\r\n")
+}
+
+// syncServices (re)starts the services an app's manifest.json declares, so
+// they run the code just deployed, and stops the ones it no longer declares.
+func (p *Pool) syncServices(appID string) {
+	p.servicesMu.Lock()
+	defer p.servicesMu.Unlock()
+
+	for _, job := range p.serviceJobs(appID) {
+		p.Cancel(job.ID)
+		debug.Log("worker", "stopped service %s (%s) for app %s", job.Config.Service, job.ID, appID)
+	}
+
+	declared := appServices(appID)
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		job, err := p.startService(appID, name, declared[name])
+		if err != nil {
+			debug.Log("worker", "failed to start service %s for app %s: %v", name, appID, err)
+			continue
+		}
+		debug.Log("worker", "started service %s (%s) for app %s", name, job.ID, appID)
+	}
+}
+
+// startService spawns the job a service runs as
+func (p *Pool) startService(appID, name string, svc hosting.AppService) (*Job, error) {
+	cfg, err := serviceConfig(name, svc)
+	if err != nil {
+		return nil, err
+	}
+	return p.Spawn(appID, svc.Handler, cfg)
+}
+
+// RestartService stops a service's job, if it's running, and starts a new
+// one from the app's current code and manifest.json.
+func (p *Pool) RestartService(appID, name string) (*Job, error) {
+	svc, ok := appServices(appID)[name]
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+
+	p.servicesMu.Lock()
+	defer p.servicesMu.Unlock()
+
+	for _, job := range p.serviceJobs(appID) {
+		if job.Config.Service == name {
+			p.Cancel(job.ID)
+		}
+	}
+	return p.startService(appID, name, svc)
+}
+
+// Services returns the services an app declares, by name, with the state of
+// their latest job.
+func (p *Pool) Services(appID string) ([]ServiceInfo, error) {
+	declared := appServices(appID)
+	infos := make([]ServiceInfo, 0, len(declared))
+	for name, svc := range declared {
+		info := ServiceInfo{
+			Name:    name,
+			Handler: svc.Handler,
+			Restart: svc.Restart,
+			Health:  svc.Health,
+			Status:  "stopped",
+		}
+		if info.Restart == "" {
+			info.Restart = RestartAlways
+		}
+
+		job, err := p.ServiceJob(appID, name)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			j := job.Info()
+			info.Status = string(j.Status)
+			info.JobID = j.ID
+			info.RestartCount = j.RestartCount
+			info.StartedAt = j.StartedAt
+			info.Error = j.Error
+			job.mu.RLock()
+			if !job.LastHealthyAt.IsZero() {
+				healthy := job.LastHealthyAt
+				info.HealthyAt = &healthy
+			}
+			job.mu.RUnlock()
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// ServiceJob returns the job running a service, or the last one that ran
+// it, nil if it never ran.
+func (p *Pool) ServiceJob(appID, name string) (*Job, error) {
+	for _, job := range p.serviceJobs(appID) {
+		if job.Config.Service == name {
+			return job, nil
+		}
+	}
+
+	var id string
+	err := p.db.QueryRow(`
+		SELECT id FROM worker_jobs
+		WHERE app_id = ? AND json_extract(config, '$.service') = ?
+		ORDER BY created_at DESC LIMIT 1
+	`, appID, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(id)
+}
+
+// serviceJobs returns an app's service jobs that are running or waiting to
+// (re)start, leaving out ones being stopped
+func (p *Pool) serviceJobs(appID string) []*Job {
+	p.jobsMu.RLock()
+	defer p.jobsMu.RUnlock()
+
+	var jobs []*Job
+	for _, job := range p.jobs {
+		if job.AppID != appID || job.Config.Service == "" || job.IsCancelled() {
+			continue
+		}
+		if status, _, _, _ := job.Outcome(); status == StatusPending || status == StatusRunning {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+// watchHealth polls a service's health path while it runs and stops it
+// once healthFailures checks in a row fail, recording why in reason. Passed
+// checks count as healthy time, which resets the restart backoff.
+func (p *Pool) watchHealth(ctx context.Context, cancel context.CancelFunc, job *Job, reason *string) {
+	path := job.Config.Health
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Set once the server's handler exists, after restored daemons start
+			check := p.healthCheckFn
+			if check == nil {
+				continue
+			}
+
+			status := check(job.AppID, path)
+			if status >= 200 && status < 400 {
+				failures = 0
+				job.mu.Lock()
+				job.LastHealthyAt = time.Now()
+				job.mu.Unlock()
+				continue
+			}
+
+			failures++
+			if status == 0 {
+				job.AddLog(fmt.Sprintf("Health check %s: no response", path))
+			} else {
+				job.AddLog(fmt.Sprintf("Health check %s: status %d", path, status))
+			}
+			if failures >= healthFailures {
+				*reason = fmt.Sprintf("Health check %s failed %d times in a row, restarting", path, failures)
+				debug.Log("worker", "job %s: %s", job.ID, *reason)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// ServiceLog is a line a service's job logged.
+type ServiceLog struct {
+	JobID string `json:"job_id"`
+	Line  string `json:"line"`
+}
+
+// Followers of services' logs, by app and service
+var (
+	serviceLogSubs   = make(map[string]map[chan ServiceLog]struct{})
+	serviceLogSubsMu sync.RWMutex
+)
+
+// SubscribeServiceLogs streams the lines a service logs from now on, across
+// restarts, until the returned function is called. Lines a slow follower
+// can't keep up with are dropped.
+func SubscribeServiceLogs(appID, service string) (<-chan ServiceLog, func()) {
+	key := appID + "/" + service
+	ch := make(chan ServiceLog, 100)
+
+	serviceLogSubsMu.Lock()
+	if serviceLogSubs[key] == nil {
+		serviceLogSubs[key] = make(map[chan ServiceLog]struct{})
+	}
+	serviceLogSubs[key][ch] = struct{}{}
+	serviceLogSubsMu.Unlock()
+
+	return ch, func() {
+		serviceLogSubsMu.Lock()
+		defer serviceLogSubsMu.Unlock()
+		delete(serviceLogSubs[key], ch)
+		if len(serviceLogSubs[key]) == 0 {
+			delete(serviceLogSubs, key)
+		}
+	}
+}
+
+// publishServiceLog sends a line a service logged to its followers
+func publishServiceLog(job *Job, line string) {
+	serviceLogSubsMu.RLock()
+	defer serviceLogSubsMu.RUnlock()
+
+	for ch := range serviceLogSubs[job.AppID+"/"+job.Config.Service] {
+		select {
+		case ch <- ServiceLog{JobID: job.ID, Line: line}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestServiceConfig(t *testing.T) {
+	cfg, err := serviceConfig("bot", hosting.AppService{Handler: "services/bot.js", Health: "/api/health", Memory: "64MB"})
+	if err != nil {
+		t.Fatalf("serviceConfig error: %v", err)
+	}
+	if !cfg.Daemon || cfg.Timeout != nil || cfg.Service != "bot" || cfg.Restart != RestartAlways ||
+		cfg.Health != "/api/health" || cfg.MemoryBytes != 64*1024*1024 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	invalid := map[string]hosting.AppService{
+		"no handler": {},
+		"restart":    {Handler: "services/bot.js", Restart: "sometimes"},
+		"health url": {Handler: "services/bot.js", Health: "https://example.com/health"},
+		"health //":  {Handler: "services/bot.js", Health: "//example.com/health"},
+		"health ws":  {Handler: "services/bot.js", Health: "/api/health check"},
+		"memory":     {Handler: "services/bot.js", Memory: "lots"},
+	}
+	for name, svc := range invalid {
+		if _, err := serviceConfig("bot", svc); !errors.Is(err, ErrInvalidService) {
+			t.Errorf("%s: expected ErrInvalidService, got %v", name, err)
+		}
+	}
+}
+
+// mockServices declares services for app-1 until the test ends
+func mockServices(t *testing.T, services map[string]hosting.AppService) {
+	appServices = func(appID string) map[string]hosting.AppService {
+		if appID != "app-1" {
+			return nil
+		}
+		return services
+	}
+	t.Cleanup(func() { appServices = hosting.AppServices })
+}
+
+func TestPoolSyncServices(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	services := map[string]hosting.AppService{
+		"bot":    {Handler: "services/bot.js"},
+		"poller": {Handler: "services/poll.js", Restart: RestartOnFailure},
+	}
+	mockServices(t, services)
+
+	started := make(chan *Job, 4)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		started <- job
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	for _, path := range []string{"services/bot.js", "services/poll.js", "services/bot2.js"} {
+		db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`, "app-1", path, "return true;")
+	}
+
+	pool.syncServices("app-1")
+	first := map[string]*Job{}
+	for i := 0; i < 2; i++ {
+		select {
+		case job := <-started:
+			first[job.Config.Service] = job
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected both services to start")
+		}
+	}
+	if first["bot"].Handler != "services/bot.js" || first["poller"].Config.Restart != RestartOnFailure {
+		t.Errorf("Unexpected service jobs: %+v", first)
+	}
+
+	infos, err := pool.Services("app-1")
+	if err != nil {
+		t.Fatalf("Services error: %v", err)
+	}
+	if len(infos) != 2 || infos[0].Name != "bot" || infos[0].Status != string(StatusRunning) ||
+		infos[0].JobID != first["bot"].ID || infos[1].Restart != RestartOnFailure {
+		t.Errorf("Unexpected services: %+v", infos)
+	}
+
+	// A deploy that drops the poller and changes the bot's handler
+	delete(services, "poller")
+	services["bot"] = hosting.AppService{Handler: "services/bot2.js"}
+	pool.syncServices("app-1")
+
+	select {
+	case job := <-started:
+		if job.Config.Service != "bot" || job.Handler != "services/bot2.js" {
+			t.Errorf("Expected the new bot to start, got %s %s", job.Config.Service, job.Handler)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the bot to start again")
+	}
+	time.Sleep(100 * time.Millisecond)
+	for _, job := range first {
+		if status, _, _, _ := job.Outcome(); status != StatusCancelled {
+			t.Errorf("Expected %s to be stopped, got %s", job.Config.Service, status)
+		}
+	}
+	select {
+	case job := <-started:
+		t.Errorf("Expected no other service to start, got %s", job.Config.Service)
+	default:
+	}
+}
+
+func TestPoolServiceRestartPolicy(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	mockServices(t, map[string]hosting.AppService{
+		"always": {Handler: "services/always.js"},
+		"once":   {Handler: "services/once.js", Restart: RestartNever},
+	})
+
+	runs := make(chan string, 8)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		runs <- job.Config.Service
+		return nil, nil
+	})
+	for _, path := range []string{"services/always.js", "services/once.js"} {
+		db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`, "app-1", path, "return true;")
+	}
+
+	pool.syncServices("app-1")
+
+	// Exits cleanly: "always" comes back after the first 1s backoff
+	counts := map[string]int{}
+	deadline := time.After(2500 * time.Millisecond)
+	for counts["always"] < 2 {
+		select {
+		case name := <-runs:
+			counts[name]++
+		case <-deadline:
+			t.Fatalf("Expected the always service to restart, got %v", counts)
+		}
+	}
+	if counts["once"] != 1 {
+		t.Errorf("Expected the never service to run once, got %d", counts["once"])
+	}
+
+	infos, _ := pool.Services("app-1")
+	for _, info := range infos {
+		if info.Name == "once" && info.Status != string(StatusDone) {
+			t.Errorf("Expected the never service to be done, got %s", info.Status)
+		}
+	}
+}
+
+func TestPoolRestartService(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	mockServices(t, map[string]hosting.AppService{"bot": {Handler: "services/bot.js"}})
+
+	started := make(chan *Job, 4)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		started <- job
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`, "app-1", "services/bot.js", "return true;")
+
+	if _, err := pool.RestartService("app-1", "missing"); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("Expected ErrServiceNotFound, got %v", err)
+	}
+
+	// Restarting a stopped service starts it
+	first, err := pool.RestartService("app-1", "bot")
+	if err != nil {
+		t.Fatalf("RestartService error: %v", err)
+	}
+	<-started
+
+	second, err := pool.RestartService("app-1", "bot")
+	if err != nil {
+		t.Fatalf("RestartService error: %v", err)
+	}
+	select {
+	case job := <-started:
+		if job.ID != second.ID || second.ID == first.ID {
+			t.Errorf("Expected a new job for the restart, got %s", job.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the bot to start again")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if status, _, _, _ := first.Outcome(); status != StatusCancelled {
+		t.Errorf("Expected the first job to be stopped, got %s", status)
+	}
+	if job, _ := pool.ServiceJob("app-1", "bot"); job == nil || job.ID != second.ID {
+		t.Errorf("Expected ServiceJob to return the new job")
+	}
+}
+
+func TestPoolServiceHealth(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	healthInterval = 20 * time.Millisecond
+	defer func() { healthInterval = 30 * time.Second }()
+
+	mockServices(t, map[string]hosting.AppService{"bot": {Handler: "services/bot.js", Health: "/api/health"}})
+	pool.SetHealthCheckFunc(func(appID, path string) int {
+		if appID != "app-1" || path != "/api/health" {
+			t.Errorf("Unexpected health check %s %s", appID, path)
+		}
+		return 503
+	})
+
+	runs := make(chan *Job, 4)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		runs <- job
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`, "app-1", "services/bot.js", "return true;")
+
+	pool.syncServices("app-1")
+	job := <-runs
+
+	// Three failed checks stop it, then it restarts after the backoff
+	select {
+	case again := <-runs:
+		if again.ID != job.ID || again.RestartCount != 1 {
+			t.Errorf("Expected the same job to restart, got %s (restart %d)", again.ID, again.RestartCount)
+		}
+	case <-time.After(2500 * time.Millisecond):
+		t.Fatal("Expected the unhealthy service to restart")
+	}
+
+	logs := strings.Join(job.Info().Logs, "\n")
+	if !strings.Contains(logs, "Health check /api/health: status 503") || !strings.Contains(logs, "failed 3 times in a row") {
+		t.Errorf("Expected health check failures in the logs, got:\n%s", logs)
+	}
+}
+
+func TestSubscribeServiceLogs(t *testing.T) {
+	cfg := DefaultJobConfig()
+	cfg.Service = "bot"
+	job := NewJob("job_1", "app-1", "services/bot.js", cfg)
+	other := NewJob("job_2", "app-2", "services/bot.js", cfg)
+
+	lines, unsubscribe := SubscribeServiceLogs("app-1", "bot")
+	job.AddLog("connected")
+	other.AddLog("another app")
+
+	select {
+	case line := <-lines:
+		if line.JobID != "job_1" || !strings.HasSuffix(line.Line, "] connected") {
+			t.Errorf("Unexpected line: %+v", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the logged line")
+	}
+	select {
+	case line := <-lines:
+		t.Errorf("Expected no more lines, got %+v", line)
+	default:
+	}
+
+	unsubscribe()
+	job.AddLog("after unsubscribe")
+	select {
+	case line := <-lines:
+		t.Errorf("Expected no lines after unsubscribing, got %+v", line)
+	default:
+	}
+}
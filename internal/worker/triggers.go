@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"github.com/fazt-sh/fazt/internal/debug"
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+// eventHandler looks up an app's handler for an event (replaced in tests)
+var eventHandler = hosting.EventHandler
+
+// trigger runs the handler an app's manifest.json registers for an event
+// ("on": {"s3.put": "hooks/thumbnail.js"}) as a job. The handler gets the
+// event as job.data.event.
+func (p *Pool) trigger(e events.Event) {
+	// Services pick up the deployed code and manifest.json
+	if e.Type == events.AppDeployed {
+		p.syncServices(e.AppID)
+	}
+
+	handler := eventHandler(e.AppID, e.Type)
+	if handler == "" {
+		return
+	}
+	// A failing job.failed handler would otherwise keep triggering itself
+	if e.Type == events.JobFailed && e.Data["handler"] == handler {
+		return
+	}
+
+	cfg := DefaultJobConfig()
+	cfg.Data = map[string]interface{}{"event": e.Map()}
+	job, err := p.Spawn(e.AppID, handler, cfg)
+	if err != nil {
+		debug.Log("worker", "failed to run %s handler %s for app %s: %v", e.Type, handler, e.AppID, err)
+		return
+	}
+	debug.Log("worker", "job %s triggered by %s for app %s", job.ID, e.Type, e.AppID)
+}
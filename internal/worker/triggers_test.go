@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fazt-sh/fazt/internal/events"
+	"github.com/fazt-sh/fazt/internal/hosting"
+)
+
+func TestPoolTrigger(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	pool := NewPool(db, DefaultPoolConfig())
+	defer pool.Shutdown(context.Background())
+
+	handlers := map[string]string{
+		events.S3Put:     "hooks/thumbnail.js",
+		events.JobFailed: "hooks/alert.js",
+	}
+	eventHandler = func(appID, eventType string) string {
+		if appID != "app-1" {
+			return ""
+		}
+		return handlers[eventType]
+	}
+	defer func() { eventHandler = hosting.EventHandler }()
+
+	ran := make(chan *Job, 4)
+	pool.SetExecutor(func(ctx context.Context, job *Job, code string) (interface{}, error) {
+		ran <- job
+		return nil, nil
+	})
+	db.Exec(`INSERT INTO files (site_id, path, content) VALUES (?, ?, ?)`,
+		"app-1", "hooks/thumbnail.js", "return true;")
+
+	pool.trigger(events.Event{Type: events.S3Put, AppID: "app-1", Data: map[string]interface{}{"path": "photos/a.jpg"}, Time: time.Now()})
+
+	select {
+	case job := <-ran:
+		event, _ := job.Config.Data["event"].(map[string]interface{})
+		data, _ := event["data"].(map[string]interface{})
+		if job.Handler != "hooks/thumbnail.js" || event["type"] != events.S3Put || data["path"] != "photos/a.jpg" {
+			t.Errorf("Unexpected triggered job: %s %v", job.Handler, job.Config.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the s3.put handler to run")
+	}
+
+	// No handler registered, another app's handlers, or the job.failed
+	// handler failing itself: nothing runs
+	pool.trigger(events.Event{Type: events.AppDeployed, AppID: "app-1"})
+	pool.trigger(events.Event{Type: events.S3Put, AppID: "app-2"})
+	pool.trigger(events.Event{Type: events.JobFailed, AppID: "app-1", Data: map[string]interface{}{"handler": "hooks/alert.js"}})
+
+	select {
+	case job := <-ran:
+		t.Errorf("Expected no job, got %s", job.Handler)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -1,8 +1,8 @@
 // Session-Scoped CRUD API Template
 // Copy this to api/main.js and customize for your app
 
-var ds = fazt.storage.ds
-var kv = fazt.storage.kv
+var ds = fazt.app.ds
+var kv = fazt.app.kv
 
 // Generate unique ID
 function genId() {
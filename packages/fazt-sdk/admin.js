@@ -24,7 +24,7 @@ export function createAdminNamespace(http) {
      */
     apps: {
       /** List all apps (includes unlisted apps in admin context) */
-      list: () => http.get('/api/apps', { params: { all: 'true' } }),
+      list: () => http.list('/api/apps', { all: 'true', limit: 500 }),
 
       /** Get app by ID or name */
       get: (id) => http.get(`/api/apps/${id}`),
@@ -106,6 +106,10 @@ export function createAdminNamespace(http) {
       /** Get database stats */
       db: () => http.get('/api/system/db'),
 
+      /** Get approximate cost per app */
+      usage: (options = {}) => http.get('/api/system/usage', { params: options }),
+      // options: { days, app }
+
       /** @deprecated Use limits() instead — returns same nested data */
       capacity: () => http.get('/api/system/capacity')
     },
@@ -134,8 +138,11 @@ export function createAdminNamespace(http) {
      */
     events: {
       /** List events with optional filters */
-      list: (options = {}) => http.get('/api/events', { params: options })
-      // options: { domain, tags, source_type, event_type, limit, offset }
+      list: (options = {}) => http.get('/api/events', { params: options }),
+      // options: { domain, tags, source_type, limit, cursor, sort, fields }
+
+      /** One page of events with its meta ({ data, meta: { next_cursor } }) */
+      page: (options = {}) => http.get('/api/events', { params: options, raw: true })
     },
 
     /**
@@ -1,5 +1,5 @@
 #!/bin/bash
-# Build fazt-sdk into a single ES module
+# Build fazt-sdk and its fazt.* shim into single ES modules
 # Usage: ./build.sh
 set -e
 
@@ -14,3 +14,10 @@ $ESBUILD "$DIR/index.js" \
   --outfile="$DIR/dist/fazt-sdk.mjs"
 
 echo "Built: $(wc -c < "$DIR/dist/fazt-sdk.mjs" | tr -d ' ') bytes → dist/fazt-sdk.mjs"
+
+$ESBUILD "$DIR/shim.js" \
+  --bundle \
+  --format=esm \
+  --outfile="$DIR/dist/fazt-shim.mjs"
+
+echo "Built: $(wc -c < "$DIR/dist/fazt-shim.mjs" | tr -d ' ') bytes → dist/fazt-shim.mjs"
@@ -13,6 +13,16 @@ import { ApiError } from './errors.js'
  */
 const defaultAdapter = (url, options) => fetch(url, options)
 
+/**
+ * Read the dashboard's CSRF token from the fazt_csrf cookie
+ * @returns {string|null}
+ */
+function csrfToken() {
+  if (typeof document === 'undefined') return null
+  const match = document.cookie.match(/(?:^|;\s*)fazt_csrf=([^;]*)/)
+  return match ? decodeURIComponent(match[1]) : null
+}
+
 /**
  * Create HTTP client
  * @param {import('./types.js').ClientOptions} options
@@ -52,11 +62,15 @@ export function createHttpClient(options = {}) {
 
     const isFormData = typeof FormData !== 'undefined' && requestOptions.body instanceof FormData
 
+    const method = requestOptions.method || 'GET'
+    const csrf = method !== 'GET' && method !== 'HEAD' ? csrfToken() : null
+
     const config = {
-      method: requestOptions.method || 'GET',
+      method,
       headers: {
         ...(isFormData ? {} : { 'Content-Type': 'application/json' }),
         'Accept': 'application/json',
+        ...(csrf ? { 'X-CSRF-Token': csrf } : {}),
         ...requestOptions.headers
       },
       credentials: 'include',
@@ -114,6 +128,8 @@ export function createHttpClient(options = {}) {
     }
 
     // Fazt API wraps responses in { success: true, data: ... }
+    // raw keeps the envelope, for the meta of list responses
+    if (requestOptions.raw) return data
     return data?.data !== undefined ? data.data : data
   }
 
@@ -139,6 +155,8 @@ export function createHttpClient(options = {}) {
       xhr.open('POST', url)
       xhr.withCredentials = true
       xhr.setRequestHeader('Accept', 'application/json')
+      const csrf = csrfToken()
+      if (csrf) xhr.setRequestHeader('X-CSRF-Token', csrf)
 
       if (onProgress) {
         xhr.upload.addEventListener('progress', (e) => {
@@ -203,6 +221,22 @@ export function createHttpClient(options = {}) {
     /** PATCH request */
     patch: (path, body, options) => request(path, { ...options, method: 'PATCH', body }),
 
+    /**
+     * GET every page of a list endpoint, following meta.next_cursor
+     * @param {string} path
+     * @param {Object} [params] - Query parameters, e.g. { sort, fields }
+     */
+    list: async (path, params = {}) => {
+      const items = []
+      let cursor
+      do {
+        const page = await request(path, { params: { ...params, cursor }, method: 'GET', raw: true })
+        items.push(...(page?.data || []))
+        cursor = page?.meta?.next_cursor
+      } while (cursor)
+      return items
+    },
+
     /** Upload with progress */
     upload
   }
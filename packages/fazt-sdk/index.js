@@ -16,6 +16,9 @@
  * Mock mode:
  *   import { createClient, mockAdapter } from 'fazt-sdk'
  *   const client = createClient({ adapter: mockAdapter })
+ *
+ * Server-side fazt.* API (run api/ handlers under Node or Vite):
+ *   import { createFazt } from 'fazt-sdk/shim'
  */
 
 import { createHttpClient } from './client.js'
@@ -59,6 +59,13 @@ const routes = {
     https: false,
     ntfy: false
   }),
+  'GET /api/system/usage': (params, body, query) => ({
+    days: parseInt(query?.days) || 7,
+    apps: [
+      { app: 'app_01hw3xyz', name: 'momentum', requests: 18422, cpu_ms: 96310, db_reads: 40211, db_writes: 5120, storage_bytes: 2411520, egress_bytes: 0, response_bytes: 88342528 },
+      { app: 'app_02hw3abc', name: 'nexus', requests: 9310, cpu_ms: 51877, db_reads: 12004, db_writes: 402, storage_bytes: 98304, egress_bytes: 15728640, response_bytes: 20971520 }
+    ]
+  }),
   'GET /auth/session': () => ({ authenticated: true, user }),
   'POST /auth/logout': () => ({ message: 'Logged out' }),
   'GET /api/me': () => user,
@@ -70,6 +77,8 @@ const routes = {
     events_by_source_type: { 'api': 5000, 'web': 3000 },
     top_domains: [],
     top_tags: [],
+    top_countries: [{ key: 'US', count: 4120 }, { key: 'DE', count: 1210 }],
+    top_regions: [{ key: 'US-CA', count: 1830 }, { key: 'US-NY', count: 940 }],
     events_timeline: []
   }),
   'GET /api/events': (params, body, query) => {
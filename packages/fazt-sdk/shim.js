@@ -0,0 +1,626 @@
+/**
+ * fazt-sdk Shim
+ * The server-side fazt.* API outside fazt, so api/ handlers run under Node
+ * or Vitest for unit tests and in the browser to mock the backend
+ *
+ * In-memory (storage starts empty, fetch only answers mocks):
+ *   import { createFazt } from 'fazt-sdk/shim'
+ *   import * as items from './api/items/index.js'
+ *
+ *   const shim = createFazt({ app: 'shop' }).install()
+ *   shim.fazt.app.ds.insert('items', { name: 'pen' })
+ *   const res = shim.request(items, 'GET', '/api/items')
+ *
+ * Dev server (storage calls hit the app's storage on a development server):
+ *   createFazt({ app: 'shop', server: 'http://admin.localhost:4698', token })
+ */
+
+const BINDINGS = {
+  kv: ['set', 'get', 'delete', 'list'],
+  ds: ['insert', 'find', 'findOne', 'update', 'delete', 'count'],
+  s3: ['put', 'get', 'delete', 'list']
+}
+
+/**
+ * Create a fazt.* API
+ * @param {Object} [options]
+ * @param {string} [options.app] - App name, also fazt.app.id (default: 'app')
+ * @param {Object} [options.user] - Signed-in user ({ id, email, name, role })
+ * @param {Object} [options.env] - Values for fazt.env.get()
+ * @param {string} [options.server] - Dev server URL; omit for in-memory storage
+ * @param {string} [options.token] - API key for the dev server
+ */
+export function createFazt(options = {}) {
+  const app = options.app || 'app'
+  const env = { ...options.env }
+  const backend = options.server
+    ? createServerBackend(options.server, app, options.token)
+    : createMemoryBackend()
+
+  let user = options.user || null
+  let globals = null
+  let mocks = []
+  let calls = []
+
+  const fazt = {
+    app: { id: app, name: app, ...bindings('', backend, () => null) },
+    auth: createAuth(app, () => user),
+    env: {
+      get: (key, fallback) => (key in env ? env[key] : fallback),
+      has: (key) => key in env
+    },
+    log: {
+      info: (msg) => console.info(`[${app}]`, msg),
+      warn: (msg) => console.warn(`[${app}]`, msg),
+      error: (msg) => console.error(`[${app}]`, msg),
+      debug: (msg) => console.debug(`[${app}]`, msg)
+    },
+    net: { fetch: mockedFetch },
+    version: '0.8.0'
+  }
+  fazt.app.user = bindings('user.', backend, () => user)
+
+  function mockedFetch(url, opts = {}) {
+    const req = {
+      method: (opts.method || 'GET').toUpperCase(),
+      url: String(url),
+      headers: { ...opts.headers },
+      body: opts.body === undefined ? '' : String(opts.body)
+    }
+    calls.push(req)
+
+    const mock = mocks.findLast(m =>
+      (!m.method || m.method === req.method) &&
+      (m.url === req.url || (m.prefix && req.url.startsWith(m.url)))
+    )
+    if (!mock) {
+      throw new Error(`fetch ${req.method} ${req.url}: no mock matches; register one with mockFetch("${req.method} ${req.url}", {...})`)
+    }
+    return {
+      status: mock.status,
+      ok: mock.status >= 200 && mock.status < 300,
+      headers: { ...mock.headers },
+      text: () => mock.body,
+      json: () => JSON.parse(mock.body)
+    }
+  }
+
+  const shim = {
+    fazt,
+    respond,
+
+    /**
+     * Set fazt and respond as globals, where handlers expect them. request
+     * is set for the duration of each request() call.
+     * @param {Object} [target] - Defaults to globalThis
+     */
+    install(target = globalThis) {
+      target.fazt = fazt
+      target.respond = respond
+      globals = target
+      return shim
+    },
+
+    /** Change the signed-in user; null signs out */
+    setUser(next) {
+      user = next || null
+    },
+
+    /**
+     * Call a handler like fazt would. handler is a route module (its GET,
+     * POST, ... exports) or a function taking the request.
+     * opts: body, headers, query, params, user
+     * @returns {{status: number, headers: Object, body: *}}
+     */
+    request(handler, method, path, opts = {}) {
+      method = method.toUpperCase()
+      const url = new URL(path, 'http://localhost')
+      const query = Object.fromEntries(url.searchParams)
+      for (const [k, v] of Object.entries(opts.query || {})) query[k] = String(v)
+
+      const req = {
+        method,
+        path: url.pathname,
+        params: { ...opts.params },
+        query,
+        body: opts.body === undefined ? null : JSON.parse(JSON.stringify(opts.body)),
+        headers: lowerKeys(opts.headers)
+      }
+
+      let fn = handler
+      if (typeof handler !== 'function') {
+        fn = handler[method] || (method === 'HEAD' ? handler.GET : undefined)
+        if (typeof fn !== 'function') {
+          const allow = Object.keys(handler).filter(k => /^[A-Z]+$/.test(k) && typeof handler[k] === 'function')
+          return { status: 405, headers: { allow: allow.join(', ') }, body: { error: 'Method not allowed' } }
+        }
+      }
+
+      const signedIn = user
+      if ('user' in opts) user = opts.user || null
+      if (globals) globals.request = req
+      try {
+        return toResponse(fn(req))
+      } catch (e) {
+        return errorResponse(e)
+      } finally {
+        user = signedIn
+      }
+    },
+
+    /**
+     * Answer fazt.net.fetch calls. pattern is a URL, optionally prefixed with
+     * a method and ending in "*" to match a prefix; the latest match wins.
+     * @param {string} pattern - e.g. 'GET https://api.example.com/*'
+     * @param {{status?: number, headers?: Object, body?: *}} [resp]
+     */
+    mockFetch(pattern, resp = {}) {
+      const m = { method: '', status: resp.status || 200, headers: lowerKeys(resp.headers), body: '' }
+      const space = pattern.indexOf(' ')
+      if (space !== -1) {
+        m.method = pattern.slice(0, space).toUpperCase()
+        pattern = pattern.slice(space + 1).trim()
+      }
+      m.prefix = pattern.endsWith('*')
+      m.url = m.prefix ? pattern.slice(0, -1) : pattern
+      if (typeof resp.body === 'string') {
+        m.body = resp.body
+      } else if (resp.body !== undefined) {
+        m.body = JSON.stringify(resp.body)
+        m.headers['content-type'] ??= 'application/json'
+      }
+      mocks.push(m)
+    },
+
+    /** The {method, url, headers, body} of each fetch so far */
+    fetchCalls() {
+      return calls.map(c => ({ ...c }))
+    },
+
+    /** Forget fetch mocks and calls, and empty in-memory storage */
+    reset() {
+      mocks = []
+      calls = []
+      backend.reset()
+    }
+  }
+  return shim
+}
+
+/**
+ * Build a handler response, with the same arguments as fazt's respond():
+ * respond(body), respond(status), respond(status, body[, headers])
+ */
+export function respond(...args) {
+  if (args.length === 0) return { status: 200, body: null }
+  if (args.length === 1) {
+    return typeof args[0] === 'number'
+      ? { status: args[0], body: null }
+      : { status: 200, body: args[0] }
+  }
+  const resp = { status: typeof args[0] === 'number' ? args[0] : 200, body: args[1] }
+  if (args[2] && typeof args[2] === 'object') resp.headers = args[2]
+  return resp
+}
+
+// Normalize what a handler returned
+function toResponse(result) {
+  if (!result || typeof result !== 'object' || !('status' in result)) {
+    return { status: 200, headers: {}, body: result === undefined ? null : result }
+  }
+  return { status: result.status, headers: lowerKeys(result.headers), body: result.body ?? null }
+}
+
+// Thrown errors become the responses fazt would send
+function errorResponse(e) {
+  if (e && e.redirect) {
+    return { status: 307, headers: { location: e.redirect }, body: null }
+  }
+  if (e && e.status === 403) {
+    return { status: 403, headers: {}, body: { error: e.message } }
+  }
+  return { status: 500, headers: {}, body: { error: e && e.message ? e.message : String(e) } }
+}
+
+function lowerKeys(obj = {}) {
+  const out = {}
+  for (const [k, v] of Object.entries(obj || {})) out[k.toLowerCase()] = String(v)
+  return out
+}
+
+// bindings builds fazt.app.kv/ds/s3, or fazt.app.user.* when prefix is
+// "user.", calling into the backend
+function bindings(prefix, backend, currentUser) {
+  const ns = {}
+  for (const [store, methods] of Object.entries(BINDINGS)) {
+    ns[store] = {}
+    for (const method of methods) {
+      const call = prefix + store + '.' + method
+      ns[store][method] = (...args) => {
+        let userId = ''
+        if (prefix) {
+          userId = currentUser()?.id || ''
+          if (!userId) throw new Error(`fazt.app.${call} requires login`)
+        }
+        return backend.call(call, args, userId)
+      }
+    }
+  }
+  return ns
+}
+
+function createAuth(app, currentUser) {
+  const is = (role) => {
+    const u = currentUser()
+    if (!u) return false
+    const has = u.role || 'user'
+    if (role === 'user') return true
+    if (role === 'admin') return has === 'owner' || has === 'admin'
+    return has === role
+  }
+  const loginURL = (redirect = '/') => `/auth/login?redirect=${redirect}&app=${app}`
+  const demand = (role) => {
+    if (!currentUser()) throw Object.assign(new Error(`auth redirect to ${loginURL()}`), { redirect: loginURL() })
+    if (role && !is(role)) throw Object.assign(new Error(`forbidden: requires role '${role}'`), { status: 403 })
+  }
+
+  return {
+    getUser() {
+      const u = currentUser()
+      if (!u) return null
+      return { id: u.id, email: u.email || '', name: u.name || '', picture: u.picture || '', role: u.role || 'user', provider: u.provider || '' }
+    },
+    isLoggedIn: () => !!currentUser(),
+    isOwner: () => is('owner'),
+    isAdmin: () => is('admin'),
+    hasRole: (role) => is(role),
+    requireLogin: () => demand(),
+    requireRole: (role) => demand(role),
+    requireOwner: () => demand('owner'),
+    requireAdmin: () => demand('admin'),
+    getLoginURL: loginURL,
+    getLogoutURL: () => '/auth/logout'
+  }
+}
+
+// ---------------------------------------------------------------------------
+// In-memory backend: mirrors fazt's storage semantics, lost on reset()
+// ---------------------------------------------------------------------------
+
+function createMemoryBackend() {
+  let scopes = new Map()
+
+  // Each user gets separate stores, like fazt.app.user.*
+  function scope(userId) {
+    const key = userId ? 'u/' + userId : ''
+    if (!scopes.has(key)) scopes.set(key, { kv: new Map(), ds: new Map(), s3: new Map(), seq: 0 })
+    return scopes.get(key)
+  }
+
+  const impl = {
+    kv: {
+      set(s, key, value, ttl) {
+        if (key === undefined || value === undefined) throw new Error('kv.set requires key and value')
+        s.kv.set(String(key), { value: clone(value), expiresAt: ttl > 0 ? Date.now() + ttl : undefined })
+      },
+      get(s, key) {
+        const entry = live(s.kv, String(key))
+        return entry ? clone(entry.value) : undefined
+      },
+      delete(s, key) {
+        s.kv.delete(String(key))
+      },
+      list(s, prefix = '') {
+        return [...s.kv.keys()].filter(k => k.startsWith(prefix) && live(s.kv, k)).sort().map(k => {
+          const { value, expiresAt } = s.kv.get(k)
+          return expiresAt ? { key: k, value: clone(value), expiresAt } : { key: k, value: clone(value) }
+        })
+      }
+    },
+
+    ds: {
+      insert(s, col, doc) {
+        if (col === undefined || !doc || typeof doc !== 'object') throw new Error('ds.insert requires collection and document')
+        const { id: given, ...data } = clone(doc)
+        const id = typeof given === 'string' && given ? given : randomUUID()
+        const now = Date.now()
+        collection(s, col).set(id, { id, data, createdAt: now, updatedAt: now, seq: s.seq++ })
+        return id
+      },
+      find(s, col, query = {}, opts = {}) {
+        let docs = matching(s, col, query).sort((a, b) => a.createdAt - b.createdAt || a.seq - b.seq)
+        if (opts.order !== 'asc') docs.reverse()
+        if (opts.limit > 0) {
+          const offset = opts.offset > 0 ? opts.offset : 0
+          docs = docs.slice(offset, offset + opts.limit)
+        }
+        return docs.map(output)
+      },
+      findOne(s, col, query) {
+        if (typeof query === 'string') query = { id: query }
+        const doc = matching(s, col, query || {})[0]
+        return doc ? output(doc) : null
+      },
+      update(s, col, query, changes) {
+        const docs = matching(s, col, query || {})
+        for (const doc of docs) {
+          applyChanges(doc.data, changes || {})
+          doc.updatedAt = Date.now()
+        }
+        return docs.length
+      },
+      delete(s, col, query) {
+        const docs = matching(s, col, query || {})
+        for (const doc of docs) collection(s, col).delete(doc.id)
+        return docs.length
+      },
+      count(s, col, query) {
+        return matching(s, col, query || {}).length
+      }
+    },
+
+    s3: {
+      put(s, path, data, mime = 'application/octet-stream') {
+        if (path === undefined || data === undefined) throw new Error('s3.put requires path and data')
+        const bytes = toBytes(data)
+        s.s3.set(normalizePath(path), { bytes, mime, hash: sha256Hex(bytes), updatedAt: Date.now() })
+      },
+      get(s, path) {
+        const blob = s.s3.get(normalizePath(path))
+        if (!blob) return null
+        return { data: toBase64(blob.bytes), mime: blob.mime, size: blob.bytes.length, hash: blob.hash }
+      },
+      delete(s, path) {
+        s.s3.delete(normalizePath(path))
+      },
+      list(s, prefix = '') {
+        prefix = normalizePath(prefix)
+        return [...s.s3.entries()].filter(([p]) => p.startsWith(prefix)).sort(([a], [b]) => (a < b ? -1 : 1))
+          .map(([path, b]) => ({ path, mime: b.mime, size: b.bytes.length, updatedAt: b.updatedAt }))
+      }
+    }
+  }
+
+  function collection(s, col) {
+    if (!s.ds.has(col)) s.ds.set(col, new Map())
+    return s.ds.get(col)
+  }
+
+  function matching(s, col, query) {
+    return [...collection(s, col).values()].filter(doc => matches(doc, query))
+  }
+
+  return {
+    call(name, args, userId) {
+      const [store, method] = name.replace(/^user\./, '').split('.')
+      return impl[store][method](scope(userId), ...args)
+    },
+    reset() {
+      scopes = new Map()
+    }
+  }
+}
+
+function live(map, key) {
+  const entry = map.get(key)
+  if (entry && entry.expiresAt !== undefined && entry.expiresAt <= Date.now()) {
+    map.delete(key)
+    return undefined
+  }
+  return entry
+}
+
+function output(doc) {
+  return { ...clone(doc.data), id: doc.id, _createdAt: doc.createdAt, _updatedAt: doc.updatedAt }
+}
+
+// field reads a dotted path ("author.name") from a document
+function field(doc, name) {
+  if (name === 'id') return doc.id
+  let v = doc.data
+  for (const part of name.split('.')) {
+    if (v === null || typeof v !== 'object') return undefined
+    v = v[part]
+  }
+  return v
+}
+
+function matches(doc, query) {
+  return Object.entries(query).every(([name, cond]) => {
+    const v = field(doc, name)
+    const isOps = cond && typeof cond === 'object' && !Array.isArray(cond) &&
+      Object.keys(cond).some(k => k.startsWith('$'))
+    if (!isOps) return same(v, cond)
+
+    return Object.entries(cond).every(([op, arg]) => {
+      switch (op) {
+        case '$eq': return same(v, arg)
+        case '$ne': return v == null || !same(v, arg)
+        case '$gt': return v != null && v > arg
+        case '$gte': return v != null && v >= arg
+        case '$lt': return v != null && v < arg
+        case '$lte': return v != null && v <= arg
+        case '$in':
+          if (!Array.isArray(arg)) throw new Error('$in requires an array')
+          return arg.some(a => same(v, a))
+        case '$nin':
+          if (!Array.isArray(arg)) throw new Error('$nin requires an array')
+          return v == null || !arg.some(a => same(v, a))
+        case '$contains': return Array.isArray(v) && v.some(x => same(x, arg))
+        default: throw new Error(`unknown operator: ${op}`)
+      }
+    })
+  })
+}
+
+function same(a, b) {
+  return a === b || JSON.stringify(a) === JSON.stringify(b)
+}
+
+// applyChanges runs $set/$unset/$inc; other keys are set as-is
+function applyChanges(data, changes) {
+  for (const [key, value] of Object.entries(changes)) {
+    if (key === '$set') {
+      for (const [f, v] of Object.entries(value)) setField(data, f, clone(v))
+    } else if (key === '$unset') {
+      for (const f of Object.keys(value)) setField(data, f, undefined)
+    } else if (key === '$inc') {
+      for (const [f, n] of Object.entries(value)) {
+        const cur = field({ data }, f)
+        setField(data, f, (typeof cur === 'number' ? cur : 0) + n)
+      }
+    } else {
+      setField(data, key, clone(value))
+    }
+  }
+}
+
+function setField(data, name, value) {
+  const parts = name.split('.')
+  let obj = data
+  for (const part of parts.slice(0, -1)) {
+    if (obj[part] === null || typeof obj[part] !== 'object') {
+      if (value === undefined) return
+      obj[part] = {}
+    }
+    obj = obj[part]
+  }
+  const last = parts[parts.length - 1]
+  if (value === undefined) delete obj[last]
+  else obj[last] = value
+}
+
+function clone(v) {
+  return v === undefined ? undefined : JSON.parse(JSON.stringify(v))
+}
+
+function normalizePath(path) {
+  return String(path).replace(/^\/+/, '')
+}
+
+function randomUUID() {
+  if (globalThis.crypto?.randomUUID) return globalThis.crypto.randomUUID()
+  return 'xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx'.replace(/[xy]/g, c => {
+    const r = (Math.random() * 16) | 0
+    return (c === 'x' ? r : (r & 0x3) | 0x8).toString(16)
+  })
+}
+
+function toBytes(data) {
+  if (typeof data === 'string') return new TextEncoder().encode(data)
+  if (data instanceof ArrayBuffer) return new Uint8Array(data.slice(0))
+  if (ArrayBuffer.isView(data)) return new Uint8Array(data.buffer.slice(data.byteOffset, data.byteOffset + data.byteLength))
+  throw new Error('s3.put data must be string or ArrayBuffer')
+}
+
+function toBase64(bytes) {
+  let bin = ''
+  for (let i = 0; i < bytes.length; i++) bin += String.fromCharCode(bytes[i])
+  return btoa(bin)
+}
+
+// sha256Hex matches the hash fazt stores for blobs. Synchronous, unlike
+// crypto.subtle, because bindings are.
+function sha256Hex(bytes) {
+  const K = []
+  const H = [0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19]
+  const isPrime = n => { for (let d = 2; d * d <= n; d++) if (n % d === 0) return false; return true }
+  const frac = x => ((x - Math.floor(x)) * 0x100000000) >>> 0
+  for (let n = 2; K.length < 64; n++) if (isPrime(n)) K.push(frac(Math.cbrt(n)))
+
+  const len = bytes.length
+  const padded = new Uint8Array(((len + 9 + 63) >> 6) << 6)
+  padded.set(bytes)
+  padded[len] = 0x80
+  const view = new DataView(padded.buffer)
+  view.setUint32(padded.length - 8, Math.floor(len / 0x20000000))
+  view.setUint32(padded.length - 4, (len << 3) >>> 0)
+
+  const rotr = (x, n) => (x >>> n) | (x << (32 - n))
+  const w = new Uint32Array(64)
+  for (let off = 0; off < padded.length; off += 64) {
+    for (let i = 0; i < 16; i++) w[i] = view.getUint32(off + i * 4)
+    for (let i = 16; i < 64; i++) {
+      const s0 = rotr(w[i - 15], 7) ^ rotr(w[i - 15], 18) ^ (w[i - 15] >>> 3)
+      const s1 = rotr(w[i - 2], 17) ^ rotr(w[i - 2], 19) ^ (w[i - 2] >>> 10)
+      w[i] = (w[i - 16] + s0 + w[i - 7] + s1) >>> 0
+    }
+    let [a, b, c, d, e, f, g, h] = H
+    for (let i = 0; i < 64; i++) {
+      const t1 = (h + (rotr(e, 6) ^ rotr(e, 11) ^ rotr(e, 25)) + ((e & f) ^ (~e & g)) + K[i] + w[i]) >>> 0
+      const t2 = ((rotr(a, 2) ^ rotr(a, 13) ^ rotr(a, 22)) + ((a & b) ^ (a & c) ^ (b & c))) >>> 0
+      h = g; g = f; f = e; e = (d + t1) >>> 0
+      d = c; c = b; b = a; a = (t1 + t2) >>> 0
+    }
+    H[0] = (H[0] + a) >>> 0; H[1] = (H[1] + b) >>> 0; H[2] = (H[2] + c) >>> 0; H[3] = (H[3] + d) >>> 0
+    H[4] = (H[4] + e) >>> 0; H[5] = (H[5] + f) >>> 0; H[6] = (H[6] + g) >>> 0; H[7] = (H[7] + h) >>> 0
+  }
+  return H.map(x => x.toString(16).padStart(8, '0')).join('')
+}
+
+// ---------------------------------------------------------------------------
+// Dev server backend: POST /api/apps/{app}/bindings on a development server
+// ---------------------------------------------------------------------------
+
+function createServerBackend(server, app, token) {
+  const url = server.replace(/\/+$/, '') + '/api/apps/' + encodeURIComponent(app) + '/bindings'
+  const headers = { 'Content-Type': 'application/json' }
+  if (token) headers.Authorization = 'Bearer ' + token
+
+  return {
+    call(name, args, userId) {
+      if (/\bs3\.put$/.test(name) && typeof args[1] !== 'string') {
+        throw new Error('s3.put against a dev server takes string data')
+      }
+      const res = postSync(url, headers, JSON.stringify({ call: name, args, user: userId || undefined }))
+      let payload
+      try {
+        payload = JSON.parse(res.text)
+      } catch {
+        throw new Error(`fazt dev server returned ${res.status}: ${res.text.slice(0, 200)}`)
+      }
+      if (res.status >= 400) {
+        throw new Error(payload.error?.message || `fazt dev server returned ${res.status}`)
+      }
+      const result = payload.data.result
+      // kv.get returns undefined for a missing key; JSON can only say null
+      return result === null && /\bkv\.get$/.test(name) ? undefined : result
+    },
+    reset() {}
+  }
+}
+
+// Bindings are synchronous, so the dev server is called synchronously: a
+// blocking XHR in the browser, a child process in Node
+const NODE_POST = `
+let input = ''
+for await (const chunk of process.stdin) input += chunk
+const { url, headers, body } = JSON.parse(input)
+try {
+  const res = await fetch(url, { method: 'POST', headers, body })
+  process.stdout.write(JSON.stringify({ status: res.status, text: await res.text() }))
+} catch (e) {
+  process.stdout.write(JSON.stringify({ error: e.cause?.message || e.message }))
+}
+`
+
+function postSync(url, headers, body) {
+  if (typeof XMLHttpRequest !== 'undefined') {
+    const xhr = new XMLHttpRequest()
+    xhr.open('POST', url, false)
+    for (const [k, v] of Object.entries(headers)) xhr.setRequestHeader(k, v)
+    xhr.send(body)
+    return { status: xhr.status, text: xhr.responseText }
+  }
+
+  const childProcess = globalThis.process?.getBuiltinModule?.('node:child_process')
+  if (!childProcess) {
+    throw new Error('fazt-sdk/shim: the dev server backend needs a browser or Node 20.16+')
+  }
+  const out = JSON.parse(childProcess.execFileSync(process.execPath, ['--input-type=module', '-e', NODE_POST], {
+    input: JSON.stringify({ url, headers, body }),
+    encoding: 'utf8'
+  }))
+  if (out.error) throw new Error(`fazt-sdk/shim: can't reach ${url}: ${out.error}`)
+  return out
+}
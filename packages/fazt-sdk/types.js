@@ -60,6 +60,19 @@
  * @property {boolean} ntfy - Ntfy notifications enabled
  */
 
+/**
+ * @typedef {Object} AppUsage
+ * @property {string} app - App ID (or subdomain for sites without an app)
+ * @property {string} [name] - App title
+ * @property {number} requests - Requests served
+ * @property {number} cpu_ms - Handler time, excluding fetch waits
+ * @property {number} db_reads - Storage read queries
+ * @property {number} db_writes - Storage write queries
+ * @property {number} storage_bytes - Bytes written to storage
+ * @property {number} egress_bytes - fazt.net.fetch bytes
+ * @property {number} response_bytes - Response bytes served
+ */
+
 /**
  * @typedef {Object} User
  * @property {string} id - User ID
@@ -102,6 +115,7 @@
  * @property {Object|string|FormData} [body] - Request body
  * @property {Object} [params] - Query parameters
  * @property {AbortSignal} [signal] - Abort signal
+ * @property {boolean} [raw] - Resolve with the whole { data, meta } envelope
  */
 
 /**